@@ -3,6 +3,7 @@ package build
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -12,24 +13,32 @@ import (
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/cmd/werf/common"
-	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/events"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/image"
-	"github.com/werf/werf/pkg/logging"
 	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/storage/lrumeta"
-	"github.com/werf/werf/pkg/storage/manager"
-	"github.com/werf/werf/pkg/tmp_manager"
 	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/werf"
 	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
-var commonCmdData common.CmdData
+type command struct {
+	cmdData struct {
+		CalculateDigestsOnly bool
+	}
+	commonCmdData common.CmdData
+}
 
 func NewCmd() *cobra.Command {
+	return (&command{}).newCmd()
+}
+
+func (c *command) newCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "build [IMAGE_NAME...]",
 		Short: "Build images",
@@ -43,7 +52,13 @@ func NewCmd() *cobra.Command {
   $ werf build --introspect-error
 
   # Build images and store/use stages from repo
-  $ werf build --repo harbor.company.io/werf`,
+  $ werf build --repo harbor.company.io/werf
+
+  # Build images and save them into the tarball, which can be loaded with docker load
+  $ werf build --output type=docker-archive,dest=images.tar
+
+  # Check whether all images are already built in the repo
+  $ werf build --repo harbor.company.io/werf --calculate-digests-only | jq .FullyCached`,
 		Long: common.GetLongCommandDescription(`Build images that are described in werf.yaml.
 
 The result of build command is built images pushed into the specified repo (or locally if repo is not specified).
@@ -54,11 +69,16 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 			common.CmdEnvAnno: common.EnvsDescription(common.WerfDebugAnsibleArgs),
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := common.BackgroundContext()
+			ctx := common.GetContext(cmd)
 
 			defer global_warnings.PrintGlobalWarnings(ctx)
 
-			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+			// only the digests json is expected in the stdout
+			if c.cmdData.CalculateDigestsOnly && !*c.commonCmdData.LogVerbose && !*c.commonCmdData.LogDebug {
+				*c.commonCmdData.LogQuiet = true
+			}
+
+			if err := common.ProcessLogOptions(&c.commonCmdData); err != nil {
 				common.PrintHelp(cmd)
 				return err
 			}
@@ -66,72 +86,103 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 			common.LogVersion()
 
 			return common.LogRunningTime(func() error {
-				return runMain(ctx, args)
+				return c.runMain(ctx, args)
 			})
 		},
 	}
 
-	common.SetupDir(&commonCmdData, cmd)
-	common.SetupGitWorkTree(&commonCmdData, cmd)
-	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
-	common.SetupConfigPath(&commonCmdData, cmd)
-	common.SetupEnvironment(&commonCmdData, cmd)
-
-	common.SetupGiterminismOptions(&commonCmdData, cmd)
-
-	common.SetupTmpDir(&commonCmdData, cmd)
-	common.SetupHomeDir(&commonCmdData, cmd)
-	common.SetupSSHKey(&commonCmdData, cmd)
-
-	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
-
-	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo, to pull base images")
-	common.SetupInsecureRegistry(&commonCmdData, cmd)
-	common.SetupInsecureHelmDependencies(&commonCmdData, cmd)
-	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
-
-	common.SetupIntrospectAfterError(&commonCmdData, cmd)
-	common.SetupIntrospectBeforeError(&commonCmdData, cmd)
-	common.SetupIntrospectStage(&commonCmdData, cmd)
-
-	common.SetupLogOptions(&commonCmdData, cmd)
-	common.SetupLogProjectDir(&commonCmdData, cmd)
-
-	common.SetupSynchronization(&commonCmdData, cmd)
-	common.SetupKubeConfig(&commonCmdData, cmd)
-	common.SetupKubeConfigBase64(&commonCmdData, cmd)
-	common.SetupKubeContext(&commonCmdData, cmd)
-
-	common.SetupReportPath(&commonCmdData, cmd)
-	common.SetupReportFormat(&commonCmdData, cmd)
-
-	common.SetupVirtualMerge(&commonCmdData, cmd)
-	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
-	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
-
-	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
-	common.SetupFollow(&commonCmdData, cmd)
-
-	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
-	common.SetupAllowedDockerStorageVolumeUsage(&commonCmdData, cmd)
-	common.SetupAllowedDockerStorageVolumeUsageMargin(&commonCmdData, cmd)
-	common.SetupAllowedLocalCacheVolumeUsage(&commonCmdData, cmd)
-	common.SetupAllowedLocalCacheVolumeUsageMargin(&commonCmdData, cmd)
-	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
-
-	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDir(&c.commonCmdData, cmd)
+	common.SetupGitWorkTree(&c.commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&c.commonCmdData, cmd)
+	common.SetupConfigPath(&c.commonCmdData, cmd)
+	common.SetupEnvironment(&c.commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&c.commonCmdData, cmd)
+
+	common.SetupTmpDir(&c.commonCmdData, cmd)
+	common.SetupTmpDirQuota(&c.commonCmdData, cmd)
+	common.SetupHomeDir(&c.commonCmdData, cmd)
+	common.SetupSSHKey(&c.commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&c.commonCmdData, cmd)
+	common.SetupGitSubmodules(&c.commonCmdData, cmd)
+	common.SetupGitSharedObjects(&c.commonCmdData, cmd)
+	common.SetupDockerfileSecrets(&c.commonCmdData, cmd)
+
+	common.SetupSecondaryStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupCacheStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupCacheFromImages(&c.commonCmdData, cmd)
+	common.SetupChangedOnly(&c.commonCmdData, cmd)
+	common.SetupStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupFinalStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupRepoReadOnly(&c.commonCmdData, cmd)
+	common.SetupRegistryMirrors(&c.commonCmdData, cmd)
+	common.SetupOutput(&c.commonCmdData, cmd)
+
+	common.SetupDockerConfig(&c.commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo, to pull base images")
+	common.SetupInsecureRegistry(&c.commonCmdData, cmd)
+	common.SetupInsecureHelmDependencies(&c.commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&c.commonCmdData, cmd)
+
+	common.SetupIntrospectAfterError(&c.commonCmdData, cmd)
+	common.SetupIntrospectBeforeError(&c.commonCmdData, cmd)
+	common.SetupIntrospectShell(&c.commonCmdData, cmd)
+	common.SetupKeepFailedStageContainer(&c.commonCmdData, cmd)
+	common.SetupIntrospectStage(&c.commonCmdData, cmd)
+
+	common.SetupLogOptions(&c.commonCmdData, cmd)
+	common.SetupLogProjectDir(&c.commonCmdData, cmd)
+
+	common.SetupSynchronization(&c.commonCmdData, cmd)
+	common.SetupKubeConfig(&c.commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&c.commonCmdData, cmd)
+	common.SetupKubeContext(&c.commonCmdData, cmd)
+
+	common.SetupReportPath(&c.commonCmdData, cmd)
+	common.SetupReportFormat(&c.commonCmdData, cmd)
+	common.SetupReportSupplyChainPath(&c.commonCmdData, cmd)
+	common.SetupEventsPath(&c.commonCmdData, cmd)
+	common.SetupScanOptions(&c.commonCmdData, cmd)
+	common.SetupStageLogsOptions(&c.commonCmdData, cmd)
+	common.SetupGraph(&c.commonCmdData, cmd)
+
+	common.SetupVirtualMerge(&c.commonCmdData, cmd)
+	common.SetupVirtualMergeFromCommit(&c.commonCmdData, cmd)
+	common.SetupVirtualMergeIntoCommit(&c.commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&c.commonCmdData, cmd)
+
+	common.SetupParallelOptions(&c.commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
+	common.SetupFollow(&c.commonCmdData, cmd)
+
+	common.SetupDisableAutoHostCleanup(&c.commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsage(&c.commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsageMargin(&c.commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsage(&c.commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsageMargin(&c.commonCmdData, cmd)
+	common.SetupDockerServerStoragePath(&c.commonCmdData, cmd)
+
+	common.SetupPlatform(&c.commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&c.commonCmdData, cmd)
+	common.SetupRemoteBuilder(&c.commonCmdData, cmd)
+
+	cmd.Flags().BoolVarP(&c.cmdData.CalculateDigestsOnly, "calculate-digests-only", "", common.GetBoolEnvironmentDefaultFalse("WERF_CALCULATE_DIGESTS_ONLY"), `Calculate the images stages digests and select the stages already stored in the repo without building and publishing anything, print the result as json into the stdout: the stages digests, whether the images are built and the images names. The docker server is not used if --repo is specified. The digests of the stages following the first not built stage cannot be calculated and are not printed (default $WERF_CALCULATE_DIGESTS_ONLY)`)
 
 	return cmd
 }
 
-func runMain(ctx context.Context, args []string) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+func (c *command) runMain(ctx context.Context, args []string) error {
+	if err := werf.Init(*c.commonCmdData.TmpDir, *c.commonCmdData.HomeDir, *c.commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&c.commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitEvents(&c.commonCmdData); err != nil {
+		return err
+	}
+	defer events.Close()
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -149,38 +200,55 @@ func runMain(ctx context.Context, args []string) error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&c.commonCmdData)
+	if err != nil {
 		return err
 	}
 
-	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
-	ctxWithDockerCli, err := docker.NewContext(ctx)
-	if err != nil {
+	// the digests are calculated by the registry reads, only the local stages storage requires the docker server
+	withoutDockerServer := c.cmdData.CalculateDigestsOnly && common.GetOptionalStagesStorageAddress(&c.commonCmdData) != storage.LocalStorageAddress
+
+	if withoutDockerServer {
+		if err := docker.InitConfig(*c.commonCmdData.DockerConfig); err != nil {
+			return err
+		}
+	} else if err := docker.Init(ctx, *c.commonCmdData.DockerConfig, *c.commonCmdData.LogVerbose, *c.commonCmdData.LogDebug, *c.commonCmdData.Platform); err != nil {
 		return err
 	}
-	ctx = ctxWithDockerCli
 
-	if err := common.DockerRegistryInit(ctxWithDockerCli, &commonCmdData); err != nil {
+	if err := common.InitDockerfileBuilder(&c.commonCmdData); err != nil {
 		return err
 	}
 
-	defer func() {
-		if err := common.RunAutoHostCleanup(ctx, &commonCmdData); err != nil {
-			logboek.Context(ctx).Error().LogF("Auto host cleanup failed: %s\n", err)
+	if err := common.InitRemoteBuilder(ctx, &c.commonCmdData); err != nil {
+		return err
+	}
+
+	if !withoutDockerServer {
+		ctxWithDockerCli, err := docker.NewContext(ctx)
+		if err != nil {
+			return err
 		}
-	}()
+		ctx = ctxWithDockerCli
+	}
 
-	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
-	if err != nil {
+	if err := common.DockerRegistryInit(ctx, &c.commonCmdData); err != nil {
 		return err
 	}
 
-	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+	if !c.cmdData.CalculateDigestsOnly {
+		defer func() {
+			if err := common.RunAutoHostCleanup(ctx, &c.commonCmdData); err != nil {
+				logboek.Context(ctx).Error().LogF("Auto host cleanup failed: %s\n", err)
+			}
+		}()
+	}
 
-	if err := ssh_agent.Init(ctx, common.GetSSHKey(&commonCmdData)); err != nil {
+	if err := ssh_agent.Init(ctx, common.GetSSHKey(&c.commonCmdData)); err != nil {
 		return fmt.Errorf("cannot initialize ssh agent: %s", err)
 	}
 	defer func() {
@@ -190,91 +258,81 @@ func runMain(ctx context.Context, args []string) error {
 		}
 	}()
 
-	if *commonCmdData.Follow {
+	giterminismManager, err := common.GetGiterminismManager(&c.commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&c.commonCmdData, giterminismManager.ProjectDir())
+
+	if err := common.InitSSHKnownHosts(ctx, &c.commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	if *c.commonCmdData.Follow {
 		logboek.LogOptionalLn()
-		return common.FollowGitHead(ctx, &commonCmdData, func(ctx context.Context, headCommitGiterminismManager giterminism_manager.Interface) error {
-			return run(ctx, headCommitGiterminismManager, args)
+		return common.FollowGitHead(ctx, &c.commonCmdData, func(ctx context.Context, headCommitGiterminismManager giterminism_manager.Interface) error {
+			return c.run(ctx, headCommitGiterminismManager, args)
 		})
 	} else {
-		return run(ctx, giterminismManager, args)
+		return c.run(ctx, giterminismManager, args)
 	}
 }
 
-func run(ctx context.Context, giterminismManager giterminism_manager.Interface, imagesToProcess []string) error {
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+func (c *command) run(ctx context.Context, giterminismManager giterminism_manager.Interface, imagesToProcess []string) error {
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &c.commonCmdData, giterminismManager, common.GetWerfConfigOptions(&c.commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
-	projectName := werfConfig.Meta.Project
-
-	for _, imageToProcess := range imagesToProcess {
-		if !werfConfig.HasImageOrArtifact(imageToProcess) {
-			return fmt.Errorf("specified image %s is not found in werf.yaml", logging.ImageLogName(imageToProcess, false))
-		}
+	if err := common.InitRegistryMirrors(&c.commonCmdData, werfConfig); err != nil {
+		return err
 	}
 
-	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	outputOptions, err := common.GetOutputOptions(&c.commonCmdData)
 	if err != nil {
-		return fmt.Errorf("getting project tmp dir failed: %s", err)
+		return err
 	}
-	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
 	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
 
-	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
-	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&c.commonCmdData)
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &c.commonCmdData)
 	if err != nil {
 		return err
 	}
-	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+	finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &c.commonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
-
-	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
-	if err != nil {
-		return err
-	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
-	if err != nil {
-		return err
-	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
-	if err != nil {
-		return err
-	}
-	secondaryStagesStorageList, err := common.GetSecondaryStagesStorageList(stagesStorage, containerRuntime, &commonCmdData)
-	if err != nil {
-		return err
-	}
-	cacheStagesStorageList, err := common.GetCacheStagesStorageList(containerRuntime, &commonCmdData)
+	storageOptions, err := common.GetStorageOptions(&c.commonCmdData, stagesStorage, finalStagesStorage, containerRuntime)
 	if err != nil {
 		return err
 	}
 
-	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
-
-	buildOptions, err := common.GetBuildOptions(&commonCmdData, werfConfig)
+	buildOptions, err := common.GetBuildOptions(&c.commonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
 
-	conveyorOptions, err := common.GetConveyorOptionsWithParallel(&commonCmdData, buildOptions)
+	conveyorOptions, err := common.GetConveyorOptionsWithParallel(&c.commonCmdData, buildOptions)
 	if err != nil {
 		return err
 	}
 
-	logboek.LogOptionalLn()
-
-	conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, imagesToProcess, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, conveyorOptions)
-	defer conveyorWithRetry.Terminate()
-
-	if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
-		return c.Build(ctx, buildOptions)
-	}); err != nil {
-		return err
-	}
-
-	return nil
+	conveyorOptions.ChangedOnly = *c.commonCmdData.ChangedOnly
+
+	_, err = flows.Build(ctx, giterminismManager, werfConfig, flows.BuildOptions{
+		ImageNames:           imagesToProcess,
+		ContainerRuntime:     containerRuntime,
+		Storage:              storageOptions,
+		ConveyorOptions:      conveyorOptions,
+		BuildOptions:         buildOptions,
+		CacheFromImages:      common.GetCacheFromImages(&c.commonCmdData),
+		OutputOptions:        outputOptions,
+		CalculateDigestsOnly: c.cmdData.CalculateDigestsOnly,
+		DigestsOutput:        os.Stdout,
+	})
+
+	return err
 }
@@ -21,6 +21,8 @@ import (
 
 	"github.com/werf/werf/pkg/werf/global_warnings"
 
+	"github.com/werf/werf/pkg/deploy/bootstrap"
+	"github.com/werf/werf/pkg/deploy/bundles"
 	"github.com/werf/werf/pkg/deploy/helm"
 
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
@@ -87,6 +89,8 @@ func NewCmd() *cobra.Command {
 	common.SetupSetDockerConfigJsonValue(&commonCmdData, cmd)
 	common.SetupSet(&commonCmdData, cmd)
 	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetJson(&commonCmdData, cmd)
+	common.SetupSetLiteral(&commonCmdData, cmd)
 	common.SetupSetFile(&commonCmdData, cmd)
 	common.SetupValues(&commonCmdData, cmd)
 
@@ -112,7 +116,7 @@ func NewCmd() *cobra.Command {
 func runApply() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -130,6 +134,11 @@ func runApply() error {
 		return err
 	}
 
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&commonCmdData), common.GetSetLiteral(&commonCmdData))
+	if err != nil {
+		return err
+	}
+
 	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
 	if err != nil {
 		return err
@@ -170,21 +179,8 @@ func runApply() error {
 	defer os.RemoveAll(bundleTmpDir)
 
 	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
-		if cmd := cmd_helm.NewChartPullCmd(actionConfig, logboek.Context(ctx).OutStream()); cmd != nil {
-			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
-			}
-		}
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	if err := logboek.Context(ctx).LogProcess("Exporting bundle %q", bundleRef).DoError(func() error {
-		if cmd := cmd_helm.NewChartExportCmd(actionConfig, logboek.Context(ctx).OutStream(), cmd_helm.ChartExportCmdOptions{Destination: bundleTmpDir}); cmd != nil {
-			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error pushing bundle %q: %s", bundleRef, err)
-			}
+		if err := bundles.Pull(ctx, bundleRef, bundleTmpDir); err != nil {
+			return fmt.Errorf("error pulling bundle %q: %s", bundleRef, err)
 		}
 		return nil
 	}); err != nil {
@@ -215,17 +211,34 @@ func runApply() error {
 	if *commonCmdData.Environment != "" {
 		postRenderer.Add(map[string]string{"project.werf.io/env": *commonCmdData.Environment}, nil)
 	}
+	helm.NewDeploySteps(ctx, postRenderer).
+		Add(helm.NewBeforeHooksResourcesCreator(actionConfig.KubeClient, releaseName, namespace)).
+		Add(helm.NewWavesDeployer(actionConfig.KubeClient, releaseName, namespace, time.Duration(cmdData.Timeout)*time.Second)).
+		Attach(actionConfig.Releases)
+
+	var pullTokenSecret string
+	if *commonCmdData.DockerConfigJsonPullTokens {
+		pullTokenSecret = helpers.GetPullTokenSecretName(releaseName)
+
+		if err := bootstrap.SyncImagePullSecret(ctx, kube.Client, namespace, pullTokenSecret, repoAddress); err != nil {
+			return fmt.Errorf("unable to sync pull token secret: %s", err)
+		}
+	}
+
+	postRenderer.SetImagePullSecrets(common.GetImagePullSecrets(&commonCmdData, pullTokenSecret))
 
 	if vals, err := helpers.GetBundleServiceValues(ctx, helpers.ServiceValuesOptions{
 		Env:                      *commonCmdData.Environment,
 		Namespace:                namespace,
 		SetDockerConfigJsonValue: *commonCmdData.SetDockerConfigJsonValue,
 		DockerConfigPath:         *commonCmdData.DockerConfig,
+		PullTokenSecret:          pullTokenSecret,
 	}); err != nil {
 		return fmt.Errorf("error creating service values: %s", err)
 	} else {
 		bundle.SetServiceValues(vals)
 	}
+	bundle.SetCommandLineValues(commandLineValues)
 
 	loader.GlobalLoadOptions = &loader.LoadOptions{
 		ChartExtender: bundle,
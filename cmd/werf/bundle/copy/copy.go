@@ -0,0 +1,90 @@
+package copy
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	From    string
+	To      string
+	SignKey string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "copy",
+		Short: "Copy published bundle into another container registry",
+		Long: common.GetLongCommandDescription(`Copy published bundle and all images used by the bundle into another container registry, e.g. to promote the release into the air-gapped environment.
+
+Images, including the images of the meta.dependencies projects, are copied into the destination repo with the same tags and image references in the bundle values are rewritten to point to the destination repo. Copied images and the bundle could be signed with the cosign key (the cosign CLI is required).`),
+		DisableFlagsInUseLine: true,
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(runCopy)
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to pull the bundle and images from the source repo and to push them into the destination repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupPlatform(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.From, "from", "", os.Getenv("WERF_FROM"), "Source bundle address in the REPO:TAG format ($WERF_FROM by default)")
+	cmd.Flags().StringVarP(&cmdData.To, "to", "", os.Getenv("WERF_TO"), "Destination bundle address in the REPO:TAG format ($WERF_TO by default)")
+	cmd.Flags().StringVarP(&cmdData.SignKey, "sign-key", "", os.Getenv("WERF_SIGN_KEY"), "Sign copied images and bundle with the specified cosign private key ($WERF_SIGN_KEY by default)")
+
+	return cmd
+}
+
+func runCopy() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if cmdData.From == "" {
+		return fmt.Errorf("--from=REPO:TAG param required")
+	}
+	if cmdData.To == "" {
+		return fmt.Errorf("--to=REPO:TAG param required")
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	return bundles.Copy(ctx, cmdData.From, cmdData.To, bundles.CopyOptions{SignKey: cmdData.SignKey})
+}
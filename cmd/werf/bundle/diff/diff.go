@@ -0,0 +1,258 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cobra"
+
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/storage/driver"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	Tag string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show changes between bundle and deployed release",
+		Long: common.GetLongCommandDescription(`Take latest bundle from the specified container registry using specified version tag, render it as a helm chart and show the diff between the rendered manifests and the manifests of the release currently deployed into the Kubernetes cluster.
+
+Resources are matched by kind, namespace and name. All resources of the bundle are shown as added if the release has not been deployed yet. Empty output means there are no changes.`),
+		DisableFlagsInUseLine: true,
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(runDiff)
+		},
+	}
+
+	common.SetupEnvironment(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd) // FIXME
+	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupInsecureHelmDependencies(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptionsDefaultQuiet(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupAddAnnotations(&commonCmdData, cmd)
+	common.SetupAddLabels(&commonCmdData, cmd)
+
+	common.SetupSetDockerConfigJsonValue(&commonCmdData, cmd)
+	common.SetupSet(&commonCmdData, cmd)
+	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetJson(&commonCmdData, cmd)
+	common.SetupSetLiteral(&commonCmdData, cmd)
+	common.SetupSetFile(&commonCmdData, cmd)
+	common.SetupValues(&commonCmdData, cmd)
+
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupRelease(&commonCmdData, cmd)
+	common.SetupNamespace(&commonCmdData, cmd)
+
+	defaultTag := os.Getenv("WERF_TAG")
+	if defaultTag == "" {
+		defaultTag = "latest"
+	}
+	cmd.Flags().StringVarP(&cmdData.Tag, "tag", "", defaultTag, "Provide exact tag version of the bundle to compare with the deployed release ($WERF_TAG or latest by default)")
+
+	return cmd
+}
+
+func runDiff() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&commonCmdData), common.GetSetLiteral(&commonCmdData))
+	if err != nil {
+		return err
+	}
+
+	repoAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	namespace := common.GetNamespace(&commonCmdData)
+	releaseName, err := common.GetRequiredRelease(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
+
+	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := helm.InitActionConfig(ctx, nil, namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
+		KubeConfigOptions: kube.KubeConfigOptions{
+			Context:          *commonCmdData.KubeContext,
+			ConfigPath:       *commonCmdData.KubeConfig,
+			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
+		},
+	}); err != nil {
+		return err
+	}
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{}
+
+	bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
+
+	bundleTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+	defer os.RemoveAll(bundleTmpDir)
+
+	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
+		if err := bundles.Pull(ctx, bundleRef, bundleTmpDir); err != nil {
+			return fmt.Errorf("error pulling bundle %q: %s", bundleRef, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	bundle := chart_extender.NewBundle(ctx, bundleTmpDir, cmd_helm.Settings, registryClientHandle, chart_extender.BundleOptions{})
+
+	postRenderer, err := bundle.GetPostRenderer()
+	if err != nil {
+		return err
+	}
+
+	postRenderer.Add(userExtraAnnotations, userExtraLabels)
+	if *commonCmdData.Environment != "" {
+		postRenderer.Add(map[string]string{"project.werf.io/env": *commonCmdData.Environment}, nil)
+	}
+
+	var pullTokenSecret string
+	if *commonCmdData.DockerConfigJsonPullTokens {
+		pullTokenSecret = helpers.GetPullTokenSecretName(releaseName)
+	}
+
+	postRenderer.SetImagePullSecrets(common.GetImagePullSecrets(&commonCmdData, pullTokenSecret))
+
+	if vals, err := helpers.GetBundleServiceValues(ctx, helpers.ServiceValuesOptions{
+		Env:                      *commonCmdData.Environment,
+		Namespace:                namespace,
+		SetDockerConfigJsonValue: *commonCmdData.SetDockerConfigJsonValue,
+		DockerConfigPath:         *commonCmdData.DockerConfig,
+		PullTokenSecret:          pullTokenSecret,
+	}); err != nil {
+		return fmt.Errorf("error creating service values: %s", err)
+	} else {
+		bundle.SetServiceValues(vals)
+	}
+	bundle.SetCommandLineValues(commandLineValues)
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{
+		ChartExtender: bundle,
+	}
+
+	var renderedManifests bytes.Buffer
+	helmTemplateCmd, _ := cmd_helm.NewTemplateCmd(actionConfig, &renderedManifests, cmd_helm.TemplateCmdOptions{
+		PostRenderer: postRenderer,
+		ValueOpts: &values.Options{
+			ValueFiles:   common.GetValues(&commonCmdData),
+			StringValues: common.GetSetString(&commonCmdData),
+			Values:       common.GetSet(&commonCmdData),
+			FileValues:   common.GetSetFile(&commonCmdData),
+		},
+		Validate:    common.NewBool(true),
+		IncludeCrds: common.NewBool(false),
+		IsUpgrade:   common.NewBool(true),
+	})
+	if err := helmTemplateCmd.RunE(helmTemplateCmd, []string{releaseName, bundle.Dir}); err != nil {
+		return fmt.Errorf("helm templates rendering failed: %s", err)
+	}
+
+	deployedManifests, err := getDeployedManifests(actionConfig, releaseName)
+	if err != nil {
+		return err
+	}
+
+	diff, err := helm.DiffManifests("deployed", deployedManifests, "bundle", renderedManifests.String())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stdout, diff)
+
+	return nil
+}
+
+func getDeployedManifests(actionConfig *action.Configuration, releaseName string) (string, error) {
+	rel, err := action.NewGet(actionConfig).Run(releaseName)
+	if err == driver.ErrReleaseNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to get release %q: %s", releaseName, err)
+	}
+
+	var manifests bytes.Buffer
+	fmt.Fprintln(&manifests, strings.TrimSpace(rel.Manifest))
+	for _, hook := range rel.Hooks {
+		fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+	}
+
+	return manifests.String(), nil
+}
@@ -0,0 +1,49 @@
+package diff
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/action"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestGetDeployedManifests(t *testing.T) {
+	actionConfig := &action.Configuration{
+		Releases:   storage.Init(driver.NewMemory()),
+		KubeClient: &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+	}
+
+	manifests, err := getDeployedManifests(actionConfig, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifests != "" {
+		t.Fatalf("expected no manifests for the missing release, got %q", manifests)
+	}
+
+	if err := actionConfig.Releases.Create(&release.Release{
+		Name:     "app",
+		Version:  1,
+		Info:     &release.Info{Status: release.StatusDeployed},
+		Manifest: "\n---\n# Source: app/templates/service.yaml\nkind: Service\n\n",
+		Hooks: []*release.Hook{
+			{Path: "app/templates/job.yaml", Manifest: "kind: Job"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	manifests, err = getDeployedManifests(actionConfig, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "---\n# Source: app/templates/service.yaml\nkind: Service\n---\n# Source: app/templates/job.yaml\nkind: Job\n"
+	if manifests != expected {
+		t.Fatalf("expected manifests %q, got %q", expected, manifests)
+	}
+}
@@ -6,10 +6,9 @@ import (
 
 	"github.com/werf/werf/pkg/werf/global_warnings"
 
-	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/deploy/bundles"
 
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
-	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 
 	"github.com/spf13/cobra"
@@ -78,7 +77,7 @@ func NewCmd() *cobra.Command {
 func runApply() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -93,37 +92,14 @@ func runApply() error {
 
 	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
 
-	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
-	if err != nil {
-		return fmt.Errorf("unable to create helm registry client: %s", err)
-	}
-
-	actionConfig := new(action.Configuration)
-	if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
-		return err
-	}
-
 	loader.GlobalLoadOptions = &loader.LoadOptions{}
 
 	// FIXME: support semver-pattern
 	bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
 
 	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
-		if cmd := cmd_helm.NewChartPullCmd(actionConfig, logboek.Context(ctx).OutStream()); cmd != nil {
-			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
-			}
-		}
-		return nil
-	}); err != nil {
-		return err
-	}
-
-	if err := logboek.Context(ctx).LogProcess("Saving bundle into directory").DoError(func() error {
-		if cmd := cmd_helm.NewChartExportCmd(actionConfig, logboek.Context(ctx).OutStream(), cmd_helm.ChartExportCmdOptions{Destination: cmdData.Destination}); cmd != nil {
-			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error saving bundle to the directory: %s", err)
-			}
+		if err := bundles.Pull(ctx, bundleRef, cmdData.Destination); err != nil {
+			return fmt.Errorf("error pulling bundle %q: %s", bundleRef, err)
 		}
 		return nil
 	}); err != nil {
@@ -20,6 +20,7 @@ import (
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/image"
@@ -73,17 +74,26 @@ func NewCmd() *cobra.Command {
 	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
+	common.SetupDockerfileSecrets(&commonCmdData, cmd)
 
 	common.SetupIntrospectAfterError(&commonCmdData, cmd)
 	common.SetupIntrospectBeforeError(&commonCmdData, cmd)
+	common.SetupIntrospectShell(&commonCmdData, cmd)
+	common.SetupKeepFailedStageContainer(&commonCmdData, cmd)
 	common.SetupIntrospectStage(&commonCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupRepoReadOnly(&commonCmdData, cmd)
+	common.SetupRegistryMirrors(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo and to pull base images")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
@@ -100,20 +110,28 @@ func NewCmd() *cobra.Command {
 
 	common.SetupSet(&commonCmdData, cmd)
 	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetJson(&commonCmdData, cmd)
+	common.SetupSetLiteral(&commonCmdData, cmd)
 	common.SetupSetFile(&commonCmdData, cmd)
 	common.SetupValues(&commonCmdData, cmd)
 
 	common.SetupReportPath(&commonCmdData, cmd)
 	common.SetupReportFormat(&commonCmdData, cmd)
+	common.SetupReportSupplyChainPath(&commonCmdData, cmd)
+	common.SetupScanOptions(&commonCmdData, cmd)
+	common.SetupStageLogsOptions(&commonCmdData, cmd)
 
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&commonCmdData, cmd)
 
 	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
 
 	common.SetupSkipBuild(&commonCmdData, cmd)
 	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
 
 	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
 	common.SetupAllowedDockerStorageVolumeUsage(&commonCmdData, cmd)
@@ -128,10 +146,14 @@ func NewCmd() *cobra.Command {
 }
 
 func runExport(ctx context.Context) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -149,7 +171,12 @@ func runExport(ctx context.Context) error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
@@ -157,6 +184,14 @@ func runExport(ctx context.Context) error {
 		return err
 	}
 
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
 	ctxWithDockerCli, err := docker.NewContext(ctx)
 	if err != nil {
 		return err
@@ -180,14 +215,18 @@ func runExport(ctx context.Context) error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
-	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: *commonCmdData.Environment})
+	werfConfigPath, werfConfig, dependencies, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: *commonCmdData.Environment})
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.InitRegistryMirrors(&commonCmdData, werfConfig); err != nil {
+		return err
+	}
+
 	projectName := werfConfig.Meta.Project
 
-	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	chartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
 	if err != nil {
 		return fmt.Errorf("getting helm chart dir failed: %s", err)
 	}
@@ -208,12 +247,21 @@ func runExport(ctx context.Context) error {
 		}
 	}()
 
-	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	userExtraAnnotations, err := common.GetUserExtraAnnotationsWithWerfConfig(&commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
 
-	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&commonCmdData), common.GetSetLiteral(&commonCmdData))
+	if err != nil {
+		return err
+	}
+
+	userExtraLabels, err := common.GetUserExtraLabelsWithWerfConfig(&commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
@@ -239,7 +287,7 @@ func runExport(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+		finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &commonCmdData, werfConfig)
 		if err != nil {
 			return err
 		}
@@ -247,11 +295,11 @@ func runExport(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+		stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 		if err != nil {
 			return err
 		}
-		storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+		storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 		if err != nil {
 			return err
 		}
@@ -265,6 +313,9 @@ func runExport(ctx context.Context) error {
 		}
 
 		storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+		if common.IsRepoReadOnly(&commonCmdData) {
+			storageManager.EnableReadOnly()
+		}
 
 		imagesRepository = storageManager.GetStagesStorage().String()
 
@@ -314,11 +365,18 @@ func runExport(ctx context.Context) error {
 		return err
 	}
 
-	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{Env: *commonCmdData.Environment}); err != nil {
+	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{Env: *commonCmdData.Environment, Dependencies: dependencies}); err != nil {
 		return fmt.Errorf("error creating service values: %s", err)
 	} else {
 		wc.SetServiceValues(vals)
 	}
+	wc.SetCommandLineValues(commandLineValues)
+
+	if buildInfo, err := common.GetBundleBuildInfo(&commonCmdData, giterminismManager, werfConfig, imagesInfoGetters); err != nil {
+		return fmt.Errorf("unable to get bundle build info: %s", err)
+	} else {
+		wc.SetBundleBuildInfo(buildInfo)
+	}
 
 	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
 
@@ -15,12 +15,10 @@ import (
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/werf/global_warnings"
 
-	"github.com/werf/werf/pkg/deploy/helm"
-
+	"github.com/werf/werf/pkg/deploy/bundles"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
-	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli/values"
@@ -33,6 +31,7 @@ import (
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/ssh_agent"
 	"github.com/werf/werf/pkg/storage/lrumeta"
@@ -55,6 +54,8 @@ func NewCmd() *cobra.Command {
 		Long: common.GetLongCommandDescription(`Publish bundle into the container registry. werf bundle contains built images defined in the werf.yaml, helm chart, service values which contain built images tags, any custom values and set values params provided during publish invocation, werf addon templates (like werf_image).
 
 Published into container registry bundle can be rolled out by the "werf bundle" command.
+
+Bundle is stored as the standard OCI helm chart, so it can also be pulled and installed by the helm CLI with the OCI registries support. werf bundle metadata is kept in the manifest annotations.
 `),
 		DisableFlagsInUseLine: true,
 		Annotations: map[string]string{
@@ -84,17 +85,26 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
+	common.SetupDockerfileSecrets(&commonCmdData, cmd)
 
 	common.SetupIntrospectAfterError(&commonCmdData, cmd)
 	common.SetupIntrospectBeforeError(&commonCmdData, cmd)
+	common.SetupIntrospectShell(&commonCmdData, cmd)
+	common.SetupKeepFailedStageContainer(&commonCmdData, cmd)
 	common.SetupIntrospectStage(&commonCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupRepoReadOnly(&commonCmdData, cmd)
+	common.SetupRegistryMirrors(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo and to pull base images")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
@@ -115,15 +125,21 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 
 	common.SetupSet(&commonCmdData, cmd)
 	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetJson(&commonCmdData, cmd)
+	common.SetupSetLiteral(&commonCmdData, cmd)
 	common.SetupSetFile(&commonCmdData, cmd)
 	common.SetupValues(&commonCmdData, cmd)
 
 	common.SetupReportPath(&commonCmdData, cmd)
 	common.SetupReportFormat(&commonCmdData, cmd)
+	common.SetupReportSupplyChainPath(&commonCmdData, cmd)
+	common.SetupScanOptions(&commonCmdData, cmd)
+	common.SetupStageLogsOptions(&commonCmdData, cmd)
 
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&commonCmdData, cmd)
 
 	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
 
@@ -136,6 +152,8 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 
 	common.SetupSkipBuild(&commonCmdData, cmd)
 	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
 
 	defaultTag := os.Getenv("WERF_TAG")
 	if defaultTag == "" {
@@ -147,10 +165,14 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 }
 
 func runPublish(ctx context.Context) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -168,7 +190,12 @@ func runPublish(ctx context.Context) error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
@@ -176,6 +203,14 @@ func runPublish(ctx context.Context) error {
 		return err
 	}
 
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
 	ctxWithDockerCli, err := docker.NewContext(ctx)
 	if err != nil {
 		return err
@@ -199,14 +234,18 @@ func runPublish(ctx context.Context) error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
-	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: *commonCmdData.Environment})
+	werfConfigPath, werfConfig, dependencies, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: *commonCmdData.Environment})
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.InitRegistryMirrors(&commonCmdData, werfConfig); err != nil {
+		return err
+	}
+
 	projectName := werfConfig.Meta.Project
 
-	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	chartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
 	if err != nil {
 		return fmt.Errorf("getting helm chart dir failed: %s", err)
 	}
@@ -227,12 +266,21 @@ func runPublish(ctx context.Context) error {
 		}
 	}()
 
-	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	userExtraAnnotations, err := common.GetUserExtraAnnotationsWithWerfConfig(&commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
 
-	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&commonCmdData), common.GetSetLiteral(&commonCmdData))
+	if err != nil {
+		return err
+	}
+
+	userExtraLabels, err := common.GetUserExtraLabelsWithWerfConfig(&commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
@@ -258,7 +306,7 @@ func runPublish(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+		finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &commonCmdData, werfConfig)
 		if err != nil {
 			return err
 		}
@@ -266,11 +314,11 @@ func runPublish(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+		stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 		if err != nil {
 			return err
 		}
-		storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+		storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 		if err != nil {
 			return err
 		}
@@ -284,6 +332,9 @@ func runPublish(ctx context.Context) error {
 		}
 
 		storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+		if common.IsRepoReadOnly(&commonCmdData) {
+			storageManager.EnableReadOnly()
+		}
 
 		imagesRepository = storageManager.StagesStorage.String()
 
@@ -332,11 +383,18 @@ func runPublish(ctx context.Context) error {
 	if err := wc.SetWerfConfig(werfConfig); err != nil {
 		return err
 	}
-	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{Env: *commonCmdData.Environment}); err != nil {
+	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{Env: *commonCmdData.Environment, Dependencies: dependencies}); err != nil {
 		return fmt.Errorf("error creating service values: %s", err)
 	} else {
 		wc.SetServiceValues(vals)
 	}
+	wc.SetCommandLineValues(commandLineValues)
+
+	if buildInfo, err := common.GetBundleBuildInfo(&commonCmdData, giterminismManager, werfConfig, imagesInfoGetters); err != nil {
+		return fmt.Errorf("unable to get bundle build info: %s", err)
+	} else {
+		wc.SetBundleBuildInfo(buildInfo)
+	}
 
 	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
 
@@ -365,29 +423,8 @@ func runPublish(ctx context.Context) error {
 
 		bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
 
-		if err := logboek.Context(ctx).LogProcess("Saving bundle to the local chart helm cache").DoError(func() error {
-			actionConfig := new(action.Configuration)
-			if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
-				return err
-			}
-
-			helmChartSaveCmd := cmd_helm.NewChartSaveCmd(actionConfig, logboek.Context(ctx).OutStream())
-			if err := helmChartSaveCmd.RunE(helmChartSaveCmd, []string{bundle.Dir, bundleRef}); err != nil {
-				return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
-			}
-			return nil
-		}); err != nil {
-			return err
-		}
-
 		if err := logboek.Context(ctx).LogProcess("Pushing bundle %q", bundleRef).DoError(func() error {
-			actionConfig := new(action.Configuration)
-			if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
-				return err
-			}
-
-			helmChartPushCmd := cmd_helm.NewChartPushCmd(actionConfig, logboek.Context(ctx).OutStream())
-			if err := helmChartPushCmd.RunE(helmChartPushCmd, []string{bundleRef}); err != nil {
+			if err := bundles.Publish(ctx, bundle.Dir, bundleRef); err != nil {
 				return fmt.Errorf("error pushing bundle %q: %s", bundleRef, err)
 			}
 			return nil
@@ -0,0 +1,396 @@
+package rebuild
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cobra"
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	Tag string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuild images of the published bundle and verify they are reproduced",
+		Long: common.GetLongCommandDescription(`Take the bundle from the specified container registry using specified version tag, checkout the werf.yaml from the commit the bundle has been published from and build the bundle images locally.
+
+The ids of the built images are compared with the image ids recorded in the bundle, so the image is reproduced only if its content is the same. The command fails if any of the bundle images is not reproduced.
+
+The project git repo with the recorded commit is required. Bundles published in the development mode or by the werf versions without build info recording cannot be rebuilt.`),
+		DisableFlagsInUseLine: true,
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := common.BackgroundContext()
+
+			defer global_warnings.PrintGlobalWarnings(ctx)
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return runRebuild(ctx)
+			})
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
+	common.SetupDockerfileSecrets(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd) // FIXME
+	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified repo and to pull base images")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupInsecureHelmDependencies(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupIntrospectAfterError(&commonCmdData, cmd)
+	common.SetupIntrospectBeforeError(&commonCmdData, cmd)
+	common.SetupIntrospectShell(&commonCmdData, cmd)
+	common.SetupKeepFailedStageContainer(&commonCmdData, cmd)
+	common.SetupIntrospectStage(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+
+	common.SetupReportPath(&commonCmdData, cmd)
+	common.SetupReportFormat(&commonCmdData, cmd)
+	common.SetupReportSupplyChainPath(&commonCmdData, cmd)
+	common.SetupScanOptions(&commonCmdData, cmd)
+	common.SetupStageLogsOptions(&commonCmdData, cmd)
+
+	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
+
+	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsage(&commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsageMargin(&commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsage(&commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsageMargin(&commonCmdData, cmd)
+	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
+
+	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
+
+	defaultTag := os.Getenv("WERF_TAG")
+	if defaultTag == "" {
+		defaultTag = "latest"
+	}
+	cmd.Flags().StringVarP(&cmdData.Tag, "tag", "", defaultTag, "Provide exact tag version of the bundle to rebuild ($WERF_TAG or latest by default)")
+
+	return cmd
+}
+
+func runRebuild(ctx context.Context) error {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := lrumeta.Init(); err != nil {
+		return err
+	}
+
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+		return err
+	}
+
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	if err := common.DockerRegistryInit(ctxWithDockerCli, &commonCmdData); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := common.RunAutoHostCleanup(ctx, &commonCmdData); err != nil {
+			logboek.Context(ctx).Error().LogF("Auto host cleanup failed: %s\n", err)
+		}
+	}()
+
+	if *commonCmdData.Dev {
+		return fmt.Errorf("bundle images cannot be rebuilt in the development mode")
+	}
+
+	repoAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{}
+
+	bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
+
+	bundleTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+	defer os.RemoveAll(bundleTmpDir)
+
+	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
+		if err := bundles.Pull(ctx, bundleRef, bundleTmpDir); err != nil {
+			return fmt.Errorf("error pulling bundle %q: %s", bundleRef, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	buildInfo, err := chart_extender.ReadBundleBuildInfo(bundleTmpDir)
+	if err != nil {
+		return fmt.Errorf("unable to read bundle %q build info: %s", bundleRef, err)
+	} else if buildInfo == nil {
+		return fmt.Errorf("bundle %q has no build info: the bundle has been published in the development mode or by werf version older than the current one", bundleRef)
+	}
+
+	logboek.Context(ctx).Default().LogF("Bundle %q has been published by werf %s from the commit %s\n", bundleRef, buildInfo.WerfVersion, buildInfo.Commit)
+	logboek.LogOptionalLn()
+
+	giterminismManager, err := common.GetGiterminismManagerForCommit(&commonCmdData, buildInfo.Commit)
+	if err != nil {
+		return err
+	}
+
+	if giterminismManager.RelativeToGitProjectDir() != buildInfo.RelativeToGitProjectDir {
+		return fmt.Errorf("bundle %q has been published from the project dir %q relative to the git work tree, but the current project dir is %q: use --dir option to specify the same project dir", bundleRef, buildInfo.RelativeToGitProjectDir, giterminismManager.RelativeToGitProjectDir())
+	}
+
+	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+
+	_, werfConfig, err := config.GetWerfConfig(ctx, buildInfo.WerfConfigPath, buildInfo.WerfConfigTemplatesDir, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: buildInfo.Env})
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	projectName := werfConfig.Meta.Project
+	if projectName != buildInfo.Project {
+		return fmt.Errorf("bundle %q has been published for the project %q, but the werf config project is %q", bundleRef, buildInfo.Project, projectName)
+	}
+
+	var imagesToProcess []string
+	for imageName := range buildInfo.Images {
+		if !werfConfig.HasImageOrArtifact(imageName) {
+			return fmt.Errorf("bundle image %q not found in the werf config at the commit %s", imageName, buildInfo.Commit)
+		}
+		imagesToProcess = append(imagesToProcess, imageName)
+	}
+	sort.Strings(imagesToProcess)
+
+	if len(imagesToProcess) == 0 {
+		logboek.Context(ctx).Default().LogLnHighlight("Bundle has no images to rebuild")
+		return nil
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	if err := ssh_agent.Init(ctx, common.GetSSHKey(&commonCmdData)); err != nil {
+		return fmt.Errorf("cannot initialize ssh agent: %s", err)
+	}
+	defer func() {
+		err := ssh_agent.Terminate()
+		if err != nil {
+			logboek.Warn().LogF("WARNING: ssh agent termination failed: %s\n", err)
+		}
+	}()
+
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+
+	// the images are always built locally, the repo contains the bundle only
+	stagesStorage, err := common.GetStagesStorage(storage.LocalStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
+	if err != nil {
+		return err
+	}
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
+	if err != nil {
+		return err
+	}
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return err
+	}
+
+	storageManager := manager.NewStorageManager(projectName, stagesStorage, nil, nil, nil, storageLockManager, stagesStorageCache)
+
+	buildOptions, err := common.GetBuildOptions(&commonCmdData, werfConfig)
+	if err != nil {
+		return err
+	}
+
+	parallelTasksLimit, err := common.GetParallelTasksLimit(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting parallel tasks limit failed: %s", err)
+	}
+
+	dockerfileSecrets, err := common.GetDockerfileSecrets(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	conveyorOptions := build.ConveyorOptions{
+		Parallel:           !(buildOptions.ImageBuildOptions.IntrospectAfterError || buildOptions.ImageBuildOptions.IntrospectBeforeError) && *commonCmdData.Parallel,
+		ParallelTasksLimit: parallelTasksLimit,
+		DockerfileSecrets:  dockerfileSecrets,
+	}
+
+	logboek.LogOptionalLn()
+
+	var imagesInfoGetters []*image.InfoGetter
+
+	conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, imagesToProcess, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, conveyorOptions)
+	defer conveyorWithRetry.Terminate()
+
+	if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
+		if err := c.Build(ctx, buildOptions); err != nil {
+			return err
+		}
+
+		imagesInfoGetters = c.GetImageInfoGetters()
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	logboek.LogOptionalLn()
+
+	return verifyBundleImages(ctx, buildInfo, imagesToProcess, imagesInfoGetters)
+}
+
+// verifyBundleImages compares the ids of the built images with the ids of the bundle images.
+// The same stage digest only means the same build instructions and sources, while the same image id means the same image content.
+func verifyBundleImages(ctx context.Context, buildInfo *chart_extender.BundleBuildInfo, imagesToProcess []string, imagesInfoGetters []*image.InfoGetter) error {
+	builtImages := map[string]*image.InfoGetter{}
+	for _, imageInfoGetter := range imagesInfoGetters {
+		builtImages[imageInfoGetter.WerfImageName] = imageInfoGetter
+	}
+
+	var notReproduced int
+	for _, imageName := range imagesToProcess {
+		recordedImage := buildInfo.Images[imageName]
+
+		var builtImageID, builtStageDigest string
+		if builtImage, ok := builtImages[imageName]; ok {
+			builtImageID, builtStageDigest = builtImage.ImageID, builtImage.StageDigest
+		}
+
+		switch {
+		case recordedImage.ImageID == "":
+			notReproduced++
+			logboek.Context(ctx).Error().LogF("Image %s cannot be verified: the bundle has no image id recorded\n", imageName)
+		case builtImageID == recordedImage.ImageID:
+			logboek.Context(ctx).Default().LogF("Image %s is reproduced: image id %s\n", imageName, builtImageID)
+		case builtStageDigest != recordedImage.StageDigest:
+			notReproduced++
+			logboek.Context(ctx).Error().LogF("Image %s is not reproduced: the bundle image id %s, the built image id %s (the stage digest %s differs from the bundle stage digest %s)\n", imageName, recordedImage.ImageID, builtImageID, builtStageDigest, recordedImage.StageDigest)
+		default:
+			notReproduced++
+			logboek.Context(ctx).Error().LogF("Image %s is not reproduced: the bundle image id %s, the built image id %s (the same stage digest %s)\n", imageName, recordedImage.ImageID, builtImageID, builtStageDigest)
+		}
+	}
+
+	if notReproduced != 0 {
+		return fmt.Errorf("%d of %d bundle images are not reproduced", notReproduced, len(imagesToProcess))
+	}
+
+	logboek.Context(ctx).Default().LogFHighlight("All %d bundle images are reproduced\n", len(imagesToProcess))
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package rebuild
+
+import (
+	"context"
+	"testing"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestVerifyBundleImages(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+	buildInfo := &chart_extender.BundleBuildInfo{
+		Images: map[string]chart_extender.BundleBuildInfoImage{
+			"app":    {StageDigest: "digest-app", ImageID: "sha256:app"},
+			"worker": {StageDigest: "digest-worker", ImageID: "sha256:worker"},
+			"legacy": {StageDigest: "digest-legacy"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		images      []string
+		built       []*image.InfoGetter
+		expectError bool
+	}{
+		{
+			name:   "same image ids",
+			images: []string{"app", "worker"},
+			built: []*image.InfoGetter{
+				image.NewInfoGetter("app", "", "", "digest-app", "", "sha256:app"),
+				image.NewInfoGetter("worker", "", "", "other-digest", "", "sha256:worker"),
+			},
+		},
+		{
+			name:   "same stage digest, other image id",
+			images: []string{"app"},
+			built: []*image.InfoGetter{
+				image.NewInfoGetter("app", "", "", "digest-app", "", "sha256:other"),
+			},
+			expectError: true,
+		},
+		{
+			name:        "image not built",
+			images:      []string{"app"},
+			expectError: true,
+		},
+		{
+			name:   "no recorded image id",
+			images: []string{"legacy"},
+			built: []*image.InfoGetter{
+				image.NewInfoGetter("legacy", "", "", "digest-legacy", "", "sha256:legacy"),
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyBundleImages(ctx, buildInfo, tt.images, tt.built)
+			if tt.expectError && err == nil {
+				t.Fatal("expected error, got nil")
+			} else if !tt.expectError && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cobra"
+
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/values"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	Tag          string
+	RenderOutput string
+	Validate     bool
+	IncludeCRDs  bool
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render Kubernetes manifests from bundle",
+		Long: common.GetLongCommandDescription(`Take latest bundle from the specified container registry using specified version tag and render it as a helm chart into Kubernetes manifests.
+
+Use --validate option to validate rendered manifests against the API schemas of the Kubernetes cluster specified by the kube config options.`),
+		DisableFlagsInUseLine: true,
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(runRender)
+		},
+	}
+
+	common.SetupEnvironment(&commonCmdData, cmd)
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd) // FIXME
+	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupInsecureHelmDependencies(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptionsDefaultQuiet(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupAddAnnotations(&commonCmdData, cmd)
+	common.SetupAddLabels(&commonCmdData, cmd)
+
+	common.SetupSetDockerConfigJsonValue(&commonCmdData, cmd)
+	common.SetupSet(&commonCmdData, cmd)
+	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetJson(&commonCmdData, cmd)
+	common.SetupSetLiteral(&commonCmdData, cmd)
+	common.SetupSetFile(&commonCmdData, cmd)
+	common.SetupValues(&commonCmdData, cmd)
+
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupRelease(&commonCmdData, cmd)
+	common.SetupNamespace(&commonCmdData, cmd)
+
+	defaultTag := os.Getenv("WERF_TAG")
+	if defaultTag == "" {
+		defaultTag = "latest"
+	}
+	cmd.Flags().StringVarP(&cmdData.Tag, "tag", "", defaultTag, "Provide exact tag version of the bundle to render ($WERF_TAG or latest by default)")
+
+	cmd.Flags().BoolVarP(&cmdData.Validate, "validate", "", common.GetBoolEnvironmentDefaultFalse("WERF_VALIDATE"), "Validate your manifests against the Kubernetes cluster you are currently pointing at (default $WERF_VALIDATE)")
+	cmd.Flags().BoolVarP(&cmdData.IncludeCRDs, "include-crds", "", common.GetBoolEnvironmentDefaultTrue("WERF_INCLUDE_CRDS"), "Include CRDs in the templated output (default $WERF_INCLUDE_CRDS)")
+
+	cmd.Flags().StringVarP(&cmdData.RenderOutput, "output", "", os.Getenv("WERF_RENDER_OUTPUT"), "Write render output to the specified file instead of stdout ($WERF_RENDER_OUTPUT by default)")
+
+	return cmd
+}
+
+func runRender() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&commonCmdData), common.GetSetLiteral(&commonCmdData))
+	if err != nil {
+		return err
+	}
+
+	repoAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	namespace := common.GetNamespace(&commonCmdData)
+	releaseName, err := common.GetRequiredRelease(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
+
+	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := helm.InitActionConfig(ctx, nil, namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
+		KubeConfigOptions: kube.KubeConfigOptions{
+			Context:          *commonCmdData.KubeContext,
+			ConfigPath:       *commonCmdData.KubeConfig,
+			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
+		},
+	}); err != nil {
+		return err
+	}
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{}
+
+	bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
+
+	bundleTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+	defer os.RemoveAll(bundleTmpDir)
+
+	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
+		if err := bundles.Pull(ctx, bundleRef, bundleTmpDir); err != nil {
+			return fmt.Errorf("error pulling bundle %q: %s", bundleRef, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	bundle := chart_extender.NewBundle(ctx, bundleTmpDir, cmd_helm.Settings, registryClientHandle, chart_extender.BundleOptions{})
+
+	postRenderer, err := bundle.GetPostRenderer()
+	if err != nil {
+		return err
+	}
+
+	postRenderer.Add(userExtraAnnotations, userExtraLabels)
+	if *commonCmdData.Environment != "" {
+		postRenderer.Add(map[string]string{"project.werf.io/env": *commonCmdData.Environment}, nil)
+	}
+
+	var pullTokenSecret string
+	if *commonCmdData.DockerConfigJsonPullTokens {
+		pullTokenSecret = helpers.GetPullTokenSecretName(releaseName)
+	}
+
+	postRenderer.SetImagePullSecrets(common.GetImagePullSecrets(&commonCmdData, pullTokenSecret))
+
+	if vals, err := helpers.GetBundleServiceValues(ctx, helpers.ServiceValuesOptions{
+		Env:                      *commonCmdData.Environment,
+		Namespace:                namespace,
+		SetDockerConfigJsonValue: *commonCmdData.SetDockerConfigJsonValue,
+		DockerConfigPath:         *commonCmdData.DockerConfig,
+		PullTokenSecret:          pullTokenSecret,
+	}); err != nil {
+		return fmt.Errorf("error creating service values: %s", err)
+	} else {
+		bundle.SetServiceValues(vals)
+	}
+	bundle.SetCommandLineValues(commandLineValues)
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{
+		ChartExtender: bundle,
+	}
+
+	var output io.Writer
+	if cmdData.RenderOutput != "" {
+		if f, err := os.Create(cmdData.RenderOutput); err != nil {
+			return fmt.Errorf("unable to open file %q: %s", cmdData.RenderOutput, err)
+		} else {
+			defer f.Close()
+			output = f
+		}
+	} else {
+		output = os.Stdout
+	}
+
+	helmTemplateCmd, _ := cmd_helm.NewTemplateCmd(actionConfig, output, cmd_helm.TemplateCmdOptions{
+		PostRenderer: postRenderer,
+		ValueOpts: &values.Options{
+			ValueFiles:   common.GetValues(&commonCmdData),
+			StringValues: common.GetSetString(&commonCmdData),
+			Values:       common.GetSet(&commonCmdData),
+			FileValues:   common.GetSetFile(&commonCmdData),
+		},
+		Validate:    &cmdData.Validate,
+		IncludeCrds: &cmdData.IncludeCRDs,
+	})
+	if err := helmTemplateCmd.RunE(helmTemplateCmd, []string{releaseName, bundle.Dir}); err != nil {
+		return fmt.Errorf("helm templates rendering failed: %s", err)
+	}
+
+	return nil
+}
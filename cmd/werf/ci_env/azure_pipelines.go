@@ -0,0 +1,39 @@
+package ci_env
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azurePipelines maps the Azure Pipelines predefined variables (https://docs.microsoft.com/en-us/azure/devops/pipelines/build/variables).
+type azurePipelines struct{}
+
+func (azurePipelines) Name() string {
+	return "azure-pipelines"
+}
+
+func (azurePipelines) Detect() bool {
+	return strings.EqualFold(os.Getenv("TF_BUILD"), "true")
+}
+
+func (azurePipelines) Envs() ciSystemEnvs {
+	var buildURL string
+	collectionURI := os.Getenv("SYSTEM_TEAMFOUNDATIONCOLLECTIONURI")
+	teamProject := os.Getenv("SYSTEM_TEAMPROJECT")
+	buildID := os.Getenv("BUILD_BUILDID")
+	if collectionURI != "" && teamProject != "" && buildID != "" {
+		buildURL = fmt.Sprintf("%s/%s/_build/results?buildId=%s", strings.TrimSuffix(collectionURI, "/"), url.PathEscape(teamProject), buildID)
+	}
+
+	return ciSystemEnvs{
+		// the environment of the deployment job
+		Env:           os.Getenv("ENVIRONMENT_NAME"),
+		ProjectGitURL: gitURLToWebURL(os.Getenv("BUILD_REPOSITORY_URI")),
+		Commit:        os.Getenv("BUILD_SOURCEVERSION"),
+		Annotations: []ciSystemAnnotation{
+			{EnvName: "WERF_ADD_ANNOTATION_AZURE_PIPELINES_BUILD_URL", Key: "azure-pipelines.ci.werf.io/build-url", Value: buildURL},
+		},
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/Masterminds/semver"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
 	"github.com/werf/logboek"
@@ -32,6 +33,7 @@ var cmdData struct {
 	TaggingStrategyStub string
 	AsFile              bool
 	AsEnvFile           bool
+	AsGithubEnvFile     bool
 	OutputFilePath      string
 	Shell               string
 }
@@ -45,17 +47,28 @@ func NewCmd() *cobra.Command {
 		Short:                 "Generate werf environment variables for specified CI system",
 		Long: `Generate werf environment variables for specified CI system.
 
-Currently supported only GitLab (gitlab) and GitHub (github) CI systems`,
+Currently supported GitLab (gitlab), GitHub (github), CircleCI (circleci), Jenkins (jenkins), TeamCity (teamcity) and Azure Pipelines (azure-pipelines) CI systems. The CI system is detected by its predefined variables when auto is specified.
+
+GitLab and GitHub provide the container registry and the credentials for the job, for the other CI systems the repo should be set by $WERF_REPO, and the login into the repo is performed if $WERF_REPO_LOGIN_USERNAME and $WERF_REPO_LOGIN_PASSWORD are set`,
 		Example: `  # Load generated werf environment variables on GitLab job runner
   $ . $(werf ci-env gitlab --as-file)
 
+  # Load generated werf environment variables for the detected CI system
+  $ . $(werf ci-env auto --as-file)
+
   # Load generated werf environment variables on GitLab job runner using powershell
   $ Invoke-Expression -Command "werf ci-env gitlab --as-file --shell powershell" | Out-String -OutVariable WERF_CI_ENV_SCRIPT_PATH
   $ . $WERF_CI_ENV_SCRIPT_PATH.Trim()
 
   # Load generated werf environment variables on GitLab job runner using cmd.exe
   $ FOR /F "tokens=*" %g IN ('werf ci-env gitlab --as-file --shell cmdexe') do (SET WERF_CI_ENV_SCRIPT_PATH=%g)
-  $ %WERF_CI_ENV_SCRIPT_PATH%`,
+  $ %WERF_CI_ENV_SCRIPT_PATH%
+
+  # Load generated werf environment variables using fish
+  $ source (werf ci-env gitlab --as-file --shell fish)
+
+  # Pass generated werf environment variables to the next steps of GitHub Actions job
+  $ werf ci-env github --as-github-env-file`,
 		RunE: runCIEnv,
 	}
 
@@ -77,8 +90,9 @@ Currently supported only GitLab (gitlab) and GitHub (github) CI systems`,
 
 	cmd.Flags().BoolVarP(&cmdData.AsFile, "as-file", "", common.GetBoolEnvironmentDefaultFalse("WERF_AS_FILE"), "Create the script and print the path for sourcing (default $WERF_AS_FILE).")
 	cmd.Flags().BoolVarP(&cmdData.AsEnvFile, "as-env-file", "", common.GetBoolEnvironmentDefaultFalse("WERF_AS_ENV_FILE"), "Create the .env file and print the path for sourcing (default $WERF_AS_ENV_FILE).")
+	cmd.Flags().BoolVarP(&cmdData.AsGithubEnvFile, "as-github-env-file", "", common.GetBoolEnvironmentDefaultFalse("WERF_AS_GITHUB_ENV_FILE"), "Append the variables to the GitHub Actions environment file $GITHUB_ENV, so that they are available in the next steps of the job (default $WERF_AS_GITHUB_ENV_FILE).")
 	cmd.Flags().StringVarP(&cmdData.OutputFilePath, "output-file-path", "o", os.Getenv("WERF_OUTPUT_FILE_PATH"), "Write to custom file (default $WERF_OUTPUT_FILE_PATH).")
-	cmd.Flags().StringVarP(&cmdData.Shell, "shell", "", os.Getenv("WERF_SHELL"), "Set to cmdexe, powershell or use the default behaviour that is compatible with any unix shell (default $WERF_SHELL).")
+	cmd.Flags().StringVarP(&cmdData.Shell, "shell", "", os.Getenv("WERF_SHELL"), "Set to cmdexe, powershell, fish or use the default behaviour that is compatible with any unix shell (default $WERF_SHELL).")
 	cmd.Flags().StringVarP(&cmdData.TaggingStrategyStub, "tagging-strategy", "", "", `stub`)
 	cmd.Flag("tagging-strategy").Hidden = true
 
@@ -90,7 +104,7 @@ func runCIEnv(cmd *cobra.Command, args []string) error {
 
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -131,40 +145,69 @@ func runCIEnv(cmd *cobra.Command, args []string) error {
 	}
 
 	switch cmdData.Shell {
-	case "", "default", "cmdexe", "powershell":
+	case "", "default", "cmdexe", "powershell", "fish":
 	default:
 		common.PrintHelp(cmd)
 		return fmt.Errorf("provided shell %q not supported", cmdData.Shell)
 	}
 
+	var outputModes int
+	for _, enabled := range []bool{cmdData.AsFile, cmdData.AsEnvFile, cmdData.AsGithubEnvFile} {
+		if enabled {
+			outputModes++
+		}
+	}
+	if outputModes > 1 {
+		common.PrintHelp(cmd)
+		return fmt.Errorf("only one of --as-file, --as-env-file and --as-github-env-file can be specified")
+	}
+
+	var githubEnvFilePath string
+	if cmdData.AsGithubEnvFile {
+		githubEnvFilePath = os.Getenv("GITHUB_ENV")
+		if githubEnvFilePath == "" {
+			return fmt.Errorf("--as-github-env-file requires $GITHUB_ENV set by GitHub Actions runner")
+		}
+	}
+
 	var w io.Writer
-	if cmdData.AsFile || cmdData.AsEnvFile {
+	if cmdData.AsFile || cmdData.AsEnvFile || cmdData.AsGithubEnvFile {
 		w = bytes.NewBuffer(nil)
 	} else {
 		w = os.Stdout
 	}
 
-	ciSystem := args[0]
-	switch ciSystem {
-	case "github":
-		err := generateGithubEnvs(ctx, w, dockerConfig)
+	ciSystemName := args[0]
+	if ciSystemName == autoCISystem {
+		ciSystemName, err = detectCISystemName()
 		if err != nil {
-			if !cmdData.AsFile && !cmdData.AsEnvFile {
-				writeError(w, err.Error())
-			}
 			return err
 		}
+	}
+
+	var generateEnvs func(ctx context.Context, w io.Writer, dockerConfig string) error
+	switch ciSystemName {
+	case "github":
+		generateEnvs = generateGithubEnvs
 	case "gitlab":
-		err := generateGitlabEnvs(ctx, w, dockerConfig)
-		if err != nil {
-			if !cmdData.AsFile && !cmdData.AsEnvFile {
-				writeError(w, err.Error())
-			}
-			return err
-		}
+		generateEnvs = generateGitlabEnvs
 	default:
-		common.PrintHelp(cmd)
-		return fmt.Errorf("provided ci system %q not supported", ciSystem)
+		system := getCISystem(ciSystemName)
+		if system == nil {
+			common.PrintHelp(cmd)
+			return fmt.Errorf("provided ci system %q not supported", ciSystemName)
+		}
+
+		generateEnvs = func(ctx context.Context, w io.Writer, dockerConfig string) error {
+			return generateCISystemEnvs(ctx, w, dockerConfig, system)
+		}
+	}
+
+	if err := generateEnvs(ctx, w, dockerConfig); err != nil {
+		if !cmdData.AsFile && !cmdData.AsEnvFile && !cmdData.AsGithubEnvFile {
+			writeError(w, err.Error())
+		}
+		return err
 	}
 
 	if cmdData.AsFile || cmdData.AsEnvFile {
@@ -176,6 +219,10 @@ func runCIEnv(cmd *cobra.Command, args []string) error {
 		if cmdData.OutputFilePath == "" {
 			fmt.Println(sourceFilePath)
 		}
+	} else if cmdData.AsGithubEnvFile {
+		if err := appendGithubEnvFile(githubEnvFilePath, w.(*bytes.Buffer).Bytes()); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -254,6 +301,12 @@ func generateGitlabEnvs(ctx context.Context, w io.Writer, dockerConfig string) e
 	}
 	writeEnv(w, "WERF_ADD_ANNOTATION_GITLAB_CI_JOB_URL", gitlabCiJobUrl, false)
 
+	writeHeader(w, "CLEANUP", true)
+	// CI_JOB_TOKEN does not grant access to the merge requests API, the token with read_api scope should be set by WERF_MERGE_REQUESTS_TOKEN
+	writeEnv(w, "WERF_MERGE_REQUESTS_API", "gitlab", false)
+	writeEnv(w, "WERF_MERGE_REQUESTS_API_URL", os.Getenv("CI_API_V4_URL"), false)
+	writeEnv(w, "WERF_MERGE_REQUESTS_PROJECT", os.Getenv("CI_PROJECT_ID"), false)
+
 	writeHeader(w, "OTHER", true)
 
 	werfLogColorMode := "on"
@@ -333,6 +386,9 @@ func generateGithubEnvs(ctx context.Context, w io.Writer, dockerConfig string) e
 
 	writeHeader(w, "CLEANUP", true)
 	writeEnv(w, "WERF_REPO_GITHUB_TOKEN", ciGithubToken, false)
+	writeEnv(w, "WERF_MERGE_REQUESTS_API", "github", false)
+	writeEnv(w, "WERF_MERGE_REQUESTS_API_URL", os.Getenv("GITHUB_API_URL"), false)
+	writeEnv(w, "WERF_MERGE_REQUESTS_PROJECT", ciGithubOwnerWithProject, false)
 
 	if err := generateOther(w); err != nil {
 		return err
@@ -398,11 +454,11 @@ func writeError(w io.Writer, errMsg string) {
 
 func writeHeader(w io.Writer, header string, withNewLine bool) {
 	if withNewLine {
-		writeLn(w, "")
+		writeCommentLn(w, "")
 	}
 
 	headerLine := commentLine(header)
-	writeLn(w, headerLine)
+	writeCommentLn(w, headerLine)
 
 	if *commonCmdData.LogVerbose {
 		if withNewLine {
@@ -418,7 +474,7 @@ func writeEnv(w io.Writer, key, value string, override bool) {
 
 	if !override && os.Getenv(key) != "" {
 		skipLine := skipLine(fmt.Sprintf("%s (%s)", envLine, os.Getenv(key)))
-		writeLn(w, skipLine)
+		writeCommentLn(w, skipLine)
 
 		if *commonCmdData.LogVerbose {
 			writeEcho(w, skipLine)
@@ -429,23 +485,33 @@ func writeEnv(w io.Writer, key, value string, override bool) {
 
 	if value == "" {
 		envLine = commentLine(envLine)
+		writeCommentLn(w, envLine)
+	} else {
+		writeLn(w, envLine)
 	}
 
-	writeLn(w, envLine)
-
 	if *commonCmdData.LogVerbose {
 		writeEcho(w, envLine)
 	}
 }
 
 func writeEcho(w io.Writer, message string) {
-	if cmdData.AsEnvFile {
+	if cmdData.AsEnvFile || cmdData.AsGithubEnvFile {
 		return
 	}
 
 	writeLn(w, fmt.Sprintf("echo '%s'", message))
 }
 
+// writeCommentLn writes the comment or empty line unless the output is the GitHub Actions environment file, which does not support comments.
+func writeCommentLn(w io.Writer, message string) {
+	if cmdData.AsGithubEnvFile {
+		return
+	}
+
+	writeLn(w, message)
+}
+
 func writeLn(w io.Writer, message string) {
 	_, err := fmt.Fprintln(w, message)
 	if err != nil {
@@ -454,7 +520,13 @@ func writeLn(w io.Writer, message string) {
 }
 
 func envLine(envKey, envValue string) string {
-	if cmdData.AsEnvFile {
+	// the multiline value is written into the GitHub Actions environment file using the heredoc-style delimiter
+	if cmdData.AsGithubEnvFile && strings.Contains(envValue, "\n") {
+		delimiter := githubEnvFileDelimiter(envValue)
+		return fmt.Sprintf("%s<<%s\n%s\n%s", envKey, delimiter, envValue, delimiter)
+	}
+
+	if cmdData.AsEnvFile || cmdData.AsGithubEnvFile {
 		return strings.Join([]string{envKey, envValue}, "=")
 	}
 
@@ -462,8 +534,12 @@ func envLine(envKey, envValue string) string {
 	switch cmdData.Shell {
 	case "powershell":
 		exportFormat = "$Env:%s = \"%s\""
+		envValue = powershellEscaper.Replace(envValue)
 	case "cmd.exe":
 		exportFormat = "set %s=%s"
+	case "fish":
+		exportFormat = "set -gx %s \"%s\""
+		envValue = fishEscaper.Replace(envValue)
 	default:
 		exportFormat = "export %s=\"%s\""
 	}
@@ -471,6 +547,21 @@ func envLine(envKey, envValue string) string {
 	return fmt.Sprintf(exportFormat, envKey, envValue)
 }
 
+func githubEnvFileDelimiter(envValue string) string {
+	for {
+		delimiter := fmt.Sprintf("ghadelimiter_%s", uuid.New().String())
+		if !strings.Contains(envValue, delimiter) {
+			return delimiter
+		}
+	}
+}
+
+var (
+	// the characters special within double-quoted strings
+	powershellEscaper = strings.NewReplacer("`", "``", "\"", "`\"", "$", "`$")
+	fishEscaper       = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "$", "\\$")
+)
+
 func skipLine(message string) string {
 	return commentLine(fmt.Sprintf("skip %s", message))
 }
@@ -526,6 +617,8 @@ func createSourceFile(data []byte) (string, error) {
 				tempFilePattern += ".bat"
 			case "powershell":
 				tempFilePattern += ".ps1"
+			case "fish":
+				tempFilePattern += ".fish"
 			}
 		} else {
 			tempFilePattern = fmt.Sprintf(".env_%d_*", time.Now().Unix())
@@ -544,3 +637,17 @@ func createSourceFile(data []byte) (string, error) {
 
 	return f.Name(), nil
 }
+
+func appendGithubEnvFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open GitHub Actions environment file %s: %s", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("unable to write GitHub Actions environment file %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package ci_env
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvLine(t *testing.T) {
+	defer func(asEnvFile, asGithubEnvFile bool, shell string) {
+		cmdData.AsEnvFile, cmdData.AsGithubEnvFile, cmdData.Shell = asEnvFile, asGithubEnvFile, shell
+	}(cmdData.AsEnvFile, cmdData.AsGithubEnvFile, cmdData.Shell)
+
+	for _, tc := range []struct {
+		name            string
+		asEnvFile       bool
+		asGithubEnvFile bool
+		shell           string
+		value           string
+		expected        string
+	}{
+		{name: "default", value: `a"b`, expected: `export KEY="a"b"`},
+		{name: "cmd.exe", shell: "cmd.exe", value: "a b", expected: "set KEY=a b"},
+		{name: "powershell", shell: "powershell", value: "a\"$b`", expected: "$Env:KEY = \"a`\"`$b``\""},
+		{name: "fish", shell: "fish", value: `a"$b\`, expected: `set -gx KEY "a\"\$b\\"`},
+		{name: "env file", asEnvFile: true, shell: "fish", value: "a b", expected: "KEY=a b"},
+		{name: "github env file", asGithubEnvFile: true, value: "a b", expected: "KEY=a b"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmdData.AsEnvFile, cmdData.AsGithubEnvFile, cmdData.Shell = tc.asEnvFile, tc.asGithubEnvFile, tc.shell
+
+			if line := envLine("KEY", tc.value); line != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, line)
+			}
+		})
+	}
+}
+
+func TestEnvLineGithubEnvFileMultiline(t *testing.T) {
+	defer func(asGithubEnvFile bool) { cmdData.AsGithubEnvFile = asGithubEnvFile }(cmdData.AsGithubEnvFile)
+	cmdData.AsGithubEnvFile = true
+
+	value := "first\nsecond"
+	lines := strings.Split(envLine("KEY", value), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected the heredoc-style record of 4 lines, got %q", lines)
+	}
+
+	delimiter := strings.TrimPrefix(lines[0], "KEY<<")
+	if delimiter == lines[0] || !strings.HasPrefix(delimiter, "ghadelimiter_") {
+		t.Fatalf("unexpected record header %q", lines[0])
+	}
+	if strings.Join(lines[1:3], "\n") != value || lines[3] != delimiter {
+		t.Fatalf("unexpected record %q", lines)
+	}
+}
+
+func TestAppendGithubEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "github_env")
+	if err := ioutil.WriteFile(path, []byte("EXISTING=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := appendGithubEnvFile(path, []byte("KEY=value\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "EXISTING=1\nKEY=value\n" {
+		t.Fatalf("unexpected GitHub Actions environment file content %q", data)
+	}
+}
@@ -0,0 +1,173 @@
+package ci_env
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/werf/werf/pkg/docker"
+)
+
+const autoCISystem = "auto"
+
+// ciSystem maps the predefined variables of the CI system to the werf environment variables.
+type ciSystem interface {
+	// Name is the CI_SYSTEM argument of the command
+	Name() string
+	// Detect reports whether the command is running in the job of the CI system
+	Detect() bool
+	// Envs returns the values mapped from the predefined variables of the CI system
+	Envs() ciSystemEnvs
+}
+
+type ciSystemEnvs struct {
+	// Env is the deploy environment (WERF_ENV)
+	Env string
+	// ProjectGitURL is the web URL of the project repository (project.werf.io/git annotation)
+	ProjectGitURL string
+	// Commit is the commit of the job (ci.werf.io/commit annotation)
+	Commit string
+	// Annotations are the CI system specific annotations, e.g. the pipeline and job URLs
+	Annotations []ciSystemAnnotation
+}
+
+type ciSystemAnnotation struct {
+	// EnvName is the WERF_ADD_ANNOTATION_* env name
+	EnvName string
+	Key     string
+	Value   string
+}
+
+var ciSystems = []ciSystem{circleCI{}, jenkins{}, teamCity{}, azurePipelines{}}
+
+func getCISystem(ciSystemName string) ciSystem {
+	for _, system := range ciSystems {
+		if system.Name() == ciSystemName {
+			return system
+		}
+	}
+
+	return nil
+}
+
+// detectCISystemName returns the name of the CI system the command is running in.
+func detectCISystemName() (string, error) {
+	switch {
+	case os.Getenv("GITLAB_CI") == "true":
+		return "gitlab", nil
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return "github", nil
+	}
+
+	for _, system := range ciSystems {
+		if system.Detect() {
+			return system.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to detect ci system: specify CI_SYSTEM explicitly")
+}
+
+// generateCISystemEnvs generates the envs for the CI systems without the built-in container registry: the repo is not generated and login is performed only if WERF_REPO, WERF_REPO_LOGIN_USERNAME and WERF_REPO_LOGIN_PASSWORD are set.
+func generateCISystemEnvs(ctx context.Context, w io.Writer, dockerConfig string, system ciSystem) error {
+	werfRepoEnv := os.Getenv("WERF_REPO")
+	repoLoginUsernameEnv := os.Getenv("WERF_REPO_LOGIN_USERNAME")
+	repoLoginPasswordEnv := os.Getenv("WERF_REPO_LOGIN_PASSWORD")
+	if werfRepoEnv != "" && repoLoginUsernameEnv != "" && repoLoginPasswordEnv != "" {
+		repository, err := name.NewRepository(werfRepoEnv)
+		if err != nil {
+			return fmt.Errorf("unable to parse repo %s: %s", werfRepoEnv, err)
+		}
+
+		registry := repository.RegistryStr()
+		if err := docker.Login(ctx, repoLoginUsernameEnv, repoLoginPasswordEnv, registry); err != nil {
+			return fmt.Errorf("unable to login into docker registry %s: %s", registry, err)
+		}
+	}
+
+	envs := system.Envs()
+
+	writeHeader(w, "DOCKER CONFIG", false)
+	writeEnv(w, "DOCKER_CONFIG", dockerConfig, true)
+
+	writeHeader(w, "REPO", true)
+	writeEnv(w, "WERF_REPO", "", false)
+
+	writeHeader(w, "DEPLOY", true)
+	writeEnv(w, "WERF_ENV", envs.Env, false)
+
+	var projectGit string
+	if envs.ProjectGitURL != "" {
+		projectGit = fmt.Sprintf("project.werf.io/git=%s", envs.ProjectGitURL)
+	}
+	writeEnv(w, "WERF_ADD_ANNOTATION_PROJECT_GIT", projectGit, false)
+
+	var ciCommit string
+	if envs.Commit != "" {
+		ciCommit = fmt.Sprintf("ci.werf.io/commit=%s", envs.Commit)
+	}
+	writeEnv(w, "WERF_ADD_ANNOTATION_CI_COMMIT", ciCommit, false)
+
+	for _, annotation := range envs.Annotations {
+		var value string
+		if annotation.Value != "" {
+			value = fmt.Sprintf("%s=%s", annotation.Key, annotation.Value)
+		}
+		writeEnv(w, annotation.EnvName, value, false)
+	}
+
+	if err := generateOther(w); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// gitURLToWebURL converts the repository clone URL (https, ssh or scp-like) to the web URL, e.g. git@github.com:owner/repo.git to https://github.com/owner/repo.
+func gitURLToWebURL(gitURL string) string {
+	if gitURL == "" {
+		return ""
+	}
+
+	if !strings.Contains(gitURL, "://") {
+		// scp-like syntax: [user@]host:path
+		parts := strings.SplitN(gitURL, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return ""
+		}
+
+		host := parts[0]
+		if ind := strings.LastIndex(host, "@"); ind != -1 {
+			host = host[ind+1:]
+		}
+
+		gitURL = fmt.Sprintf("ssh://%s/%s", host, strings.TrimPrefix(parts[1], "/"))
+	}
+
+	u, err := url.Parse(gitURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		u.User = nil
+	case "ssh", "git":
+		u.Scheme = "https"
+		u.User = nil
+		u.Host = u.Hostname()
+	default:
+		return ""
+	}
+
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u.String()
+}
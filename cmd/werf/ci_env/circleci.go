@@ -0,0 +1,33 @@
+package ci_env
+
+import (
+	"fmt"
+	"os"
+)
+
+// circleCI maps the CircleCI built-in environment variables (https://circleci.com/docs/2.0/env-vars/#built-in-environment-variables).
+type circleCI struct{}
+
+func (circleCI) Name() string {
+	return "circleci"
+}
+
+func (circleCI) Detect() bool {
+	return os.Getenv("CIRCLECI") == "true"
+}
+
+func (circleCI) Envs() ciSystemEnvs {
+	var workflowURL string
+	if workflowID := os.Getenv("CIRCLE_WORKFLOW_ID"); workflowID != "" {
+		workflowURL = fmt.Sprintf("https://app.circleci.com/pipelines/workflows/%s", workflowID)
+	}
+
+	return ciSystemEnvs{
+		ProjectGitURL: gitURLToWebURL(os.Getenv("CIRCLE_REPOSITORY_URL")),
+		Commit:        os.Getenv("CIRCLE_SHA1"),
+		Annotations: []ciSystemAnnotation{
+			{EnvName: "WERF_ADD_ANNOTATION_CIRCLECI_WORKFLOW_URL", Key: "circleci.ci.werf.io/workflow-url", Value: workflowURL},
+			{EnvName: "WERF_ADD_ANNOTATION_CIRCLECI_JOB_URL", Key: "circleci.ci.werf.io/job-url", Value: os.Getenv("CIRCLE_BUILD_URL")},
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package ci_env
+
+import (
+	"os"
+)
+
+// jenkins maps the Jenkins environment variables (https://www.jenkins.io/doc/book/pipeline/jenkinsfile/#using-environment-variables), GIT_* variables are set by the Git plugin.
+type jenkins struct{}
+
+func (jenkins) Name() string {
+	return "jenkins"
+}
+
+func (jenkins) Detect() bool {
+	return os.Getenv("JENKINS_URL") != ""
+}
+
+func (jenkins) Envs() ciSystemEnvs {
+	return ciSystemEnvs{
+		ProjectGitURL: gitURLToWebURL(os.Getenv("GIT_URL")),
+		Commit:        os.Getenv("GIT_COMMIT"),
+		Annotations: []ciSystemAnnotation{
+			{EnvName: "WERF_ADD_ANNOTATION_JENKINS_JOB_URL", Key: "jenkins.ci.werf.io/job-url", Value: os.Getenv("JOB_URL")},
+			{EnvName: "WERF_ADD_ANNOTATION_JENKINS_BUILD_URL", Key: "jenkins.ci.werf.io/build-url", Value: os.Getenv("BUILD_URL")},
+		},
+	}
+}
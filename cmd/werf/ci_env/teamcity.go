@@ -0,0 +1,30 @@
+package ci_env
+
+import (
+	"os"
+)
+
+// teamCity maps the TeamCity predefined environment variables (https://www.jetbrains.com/help/teamcity/predefined-build-parameters.html).
+type teamCity struct{}
+
+func (teamCity) Name() string {
+	return "teamcity"
+}
+
+func (teamCity) Detect() bool {
+	return os.Getenv("TEAMCITY_VERSION") != ""
+}
+
+func (teamCity) Envs() ciSystemEnvs {
+	return ciSystemEnvs{
+		Commit: os.Getenv("BUILD_VCS_NUMBER"),
+		Annotations: []ciSystemAnnotation{
+			{EnvName: "WERF_ADD_ANNOTATION_TEAMCITY_PROJECT", Key: "teamcity.ci.werf.io/project", Value: os.Getenv("TEAMCITY_PROJECT_NAME")},
+			{EnvName: "WERF_ADD_ANNOTATION_TEAMCITY_BUILD_CONF", Key: "teamcity.ci.werf.io/build-conf", Value: os.Getenv("TEAMCITY_BUILDCONF_NAME")},
+			{EnvName: "WERF_ADD_ANNOTATION_TEAMCITY_BUILD_NUMBER", Key: "teamcity.ci.werf.io/build-number", Value: os.Getenv("BUILD_NUMBER")},
+			// TeamCity does not pass the server URL to the build environment by default,
+			// the build configuration parameter env.BUILD_URL=%teamcity.serverUrl%/viewLog.html?buildId=%teamcity.build.id% should be defined to get the annotation
+			{EnvName: "WERF_ADD_ANNOTATION_TEAMCITY_BUILD_URL", Key: "teamcity.ci.werf.io/build-url", Value: os.Getenv("BUILD_URL")},
+		},
+	}
+}
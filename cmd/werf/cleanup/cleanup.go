@@ -3,29 +3,35 @@ package cleanup
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/cmd/werf/common"
-	"github.com/werf/werf/pkg/cleaning"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/storage/lrumeta"
-	"github.com/werf/werf/pkg/storage/manager"
 	"github.com/werf/werf/pkg/tmp_manager"
 	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/werf"
 	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
-var commonCmdData common.CmdData
+type command struct {
+	commonCmdData common.CmdData
+}
 
 func NewCmd() *cobra.Command {
+	return (&command{}).newCmd()
+}
+
+func (c *command) newCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:                   "cleanup",
 		DisableFlagsInUseLine: true,
@@ -37,70 +43,73 @@ The command works according to special rules called cleanup policies, which the
 It is safe to run this command periodically (daily is enough) by automated cleanup job in parallel with other werf commands such as build, converge and host cleanup.`),
 		Example: `  $ werf cleanup --repo registry.mydomain.com/myproject/werf`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := common.BackgroundContext()
+			ctx := common.GetContext(cmd)
 
 			defer global_warnings.PrintGlobalWarnings(ctx)
 
-			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+			if err := common.ProcessLogOptions(&c.commonCmdData); err != nil {
 				common.PrintHelp(cmd)
 				return err
 			}
 			common.LogVersion()
 
 			return common.LogRunningTime(func() error {
-				return runCleanup(ctx)
+				return c.runCleanup(ctx)
 			})
 		},
 	}
 
-	common.SetupDir(&commonCmdData, cmd)
-	common.SetupGitWorkTree(&commonCmdData, cmd)
-	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
-	common.SetupConfigPath(&commonCmdData, cmd)
-	common.SetupEnvironment(&commonCmdData, cmd)
-
-	common.SetupGiterminismOptions(&commonCmdData, cmd)
-
-	common.SetupTmpDir(&commonCmdData, cmd)
-	common.SetupHomeDir(&commonCmdData, cmd)
-
-	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
-
-	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and delete images from the specified repo")
-	common.SetupInsecureRegistry(&commonCmdData, cmd)
-	common.SetupInsecureHelmDependencies(&commonCmdData, cmd)
-	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
-
-	common.SetupScanContextNamespaceOnly(&commonCmdData, cmd)
-	common.SetupDryRun(&commonCmdData, cmd)
-
-	common.SetupLogOptions(&commonCmdData, cmd)
-	common.SetupLogProjectDir(&commonCmdData, cmd)
-
-	common.SetupSynchronization(&commonCmdData, cmd)
-	common.SetupKubeConfig(&commonCmdData, cmd)
-	common.SetupKubeConfigBase64(&commonCmdData, cmd)
-	common.SetupKubeContext(&commonCmdData, cmd)
-	common.SetupWithoutKube(&commonCmdData, cmd)
-	common.SetupKeepStagesBuiltWithinLastNHours(&commonCmdData, cmd)
-
-	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
-	common.SetupAllowedDockerStorageVolumeUsage(&commonCmdData, cmd)
-	common.SetupAllowedDockerStorageVolumeUsageMargin(&commonCmdData, cmd)
-	common.SetupAllowedLocalCacheVolumeUsage(&commonCmdData, cmd)
-	common.SetupAllowedLocalCacheVolumeUsageMargin(&commonCmdData, cmd)
-	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
-	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDir(&c.commonCmdData, cmd)
+	common.SetupGitWorkTree(&c.commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&c.commonCmdData, cmd)
+	common.SetupConfigPath(&c.commonCmdData, cmd)
+	common.SetupEnvironment(&c.commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&c.commonCmdData, cmd)
+
+	common.SetupTmpDir(&c.commonCmdData, cmd)
+	common.SetupHomeDir(&c.commonCmdData, cmd)
+
+	common.SetupSecondaryStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupCacheStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupFinalStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupParallelOptions(&c.commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+	common.SetupParallelTaskTimeout(&c.commonCmdData, cmd)
+
+	common.SetupDockerConfig(&c.commonCmdData, cmd, "Command needs granted permissions to read, pull and delete images from the specified repo")
+	common.SetupInsecureRegistry(&c.commonCmdData, cmd)
+	common.SetupInsecureHelmDependencies(&c.commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&c.commonCmdData, cmd)
+
+	common.SetupScanContextNamespaceOnly(&c.commonCmdData, cmd)
+	common.SetupDryRun(&c.commonCmdData, cmd)
+
+	common.SetupLogOptions(&c.commonCmdData, cmd)
+	common.SetupLogProjectDir(&c.commonCmdData, cmd)
+
+	common.SetupSynchronization(&c.commonCmdData, cmd)
+	common.SetupKubeConfig(&c.commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&c.commonCmdData, cmd)
+	common.SetupKubeContext(&c.commonCmdData, cmd)
+	common.SetupWithoutKube(&c.commonCmdData, cmd)
+	common.SetupKeepStagesBuiltWithinLastNHours(&c.commonCmdData, cmd)
+	common.SetupKeepImagesSeenWithinLastNHours(&c.commonCmdData, cmd)
+	common.SetupKeepOpenMergeRequestsImages(&c.commonCmdData, cmd)
+
+	common.SetupDisableAutoHostCleanup(&c.commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsage(&c.commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsageMargin(&c.commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsage(&c.commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsageMargin(&c.commonCmdData, cmd)
+	common.SetupDockerServerStoragePath(&c.commonCmdData, cmd)
+	common.SetupPlatform(&c.commonCmdData, cmd)
 
 	return cmd
 }
 
-func runCleanup(ctx context.Context) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+func (c *command) runCleanup(ctx context.Context) error {
+	if err := werf.Init(*c.commonCmdData.TmpDir, *c.commonCmdData.HomeDir, *c.commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -113,7 +122,7 @@ func runCleanup(ctx context.Context) error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *c.commonCmdData.LogVerbose || *c.commonCmdData.LogDebug}); err != nil {
 		return err
 	}
 
@@ -125,7 +134,7 @@ func runCleanup(ctx context.Context) error {
 		return err
 	}
 
-	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+	if err := docker.Init(ctx, *c.commonCmdData.DockerConfig, *c.commonCmdData.LogVerbose, *c.commonCmdData.LogDebug, *c.commonCmdData.Platform); err != nil {
 		return err
 	}
 
@@ -135,27 +144,27 @@ func runCleanup(ctx context.Context) error {
 	}
 	ctx = ctxWithDockerCli
 
-	if err := common.DockerRegistryInit(ctxWithDockerCli, &commonCmdData); err != nil {
+	if err := common.DockerRegistryInit(ctxWithDockerCli, &c.commonCmdData); err != nil {
 		return err
 	}
 
 	defer func() {
-		if err := common.RunAutoHostCleanup(ctx, &commonCmdData); err != nil {
+		if err := common.RunAutoHostCleanup(ctx, &c.commonCmdData); err != nil {
 			logboek.Context(ctx).Error().LogF("Auto host cleanup failed: %s\n", err)
 		}
 	}()
 
-	common.SetupOndemandKubeInitializer(*commonCmdData.KubeContext, *commonCmdData.KubeConfig, *commonCmdData.KubeConfigBase64, *commonCmdData.KubeConfigPathMergeList)
+	common.SetupOndemandKubeInitializer(*c.commonCmdData.KubeContext, *c.commonCmdData.KubeConfig, *c.commonCmdData.KubeConfigBase64, *c.commonCmdData.KubeConfigPathMergeList)
 	if err := common.GetOndemandKubeInitializer().Init(ctx); err != nil {
 		return err
 	}
 
-	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	giterminismManager, err := common.GetGiterminismManager(&c.commonCmdData)
 	if err != nil {
 		return err
 	}
 
-	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+	common.ProcessLogProjectDir(&c.commonCmdData, giterminismManager.ProjectDir())
 
 	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
 	if err != nil {
@@ -163,105 +172,54 @@ func runCleanup(ctx context.Context) error {
 	}
 	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &c.commonCmdData, giterminismManager, common.GetWerfConfigOptions(&c.commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
-	if !werfConfig.Meta.GitWorktree.GetForceShallowClone() && !werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
-		isShallow, err := giterminismManager.LocalGitRepo().IsShallowClone()
-		if err != nil {
-			return fmt.Errorf("check shallow clone failed: %s", err)
-		}
-
-		if isShallow {
-			logboek.Warn().LogLn("Git shallow clone should not be used with images cleanup commands due to incompleteness of the repository history that is extremely essential for proper work.")
-			logboek.Warn().LogLn("It is recommended to enable automatic fetch of origin git branches and tags during cleanup process with the gitWorktree.allowFetchOriginBranchesAndTags=true werf.yaml directive (which is enabled by default, http://werf.io/documentation/reference/werf_yaml.html#git-worktree).")
-			logboek.Warn().LogLn("If you still want to use shallow clone, add gitWorktree.forceShallowClone=true directive into werf.yaml (http://werf.io/documentation/reference/werf_yaml.html#git-worktree).")
-
-			return fmt.Errorf("git shallow clone is not allowed")
-		}
-	}
-
-	if werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
-		if err := giterminismManager.LocalGitRepo().SyncWithOrigin(ctx); err != nil {
-			return fmt.Errorf("synchronization failed: %s", err)
-		}
-	}
-
-	projectName := werfConfig.Meta.Project
-
 	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
 
-	stagesStorageAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	stagesStorageAddress, err := common.GetStagesStorageAddress(&c.commonCmdData)
 	if err != nil {
 		logboek.Context(ctx).Default().LogLnDetails(`The "werf cleanup" command is only used to cleaning the container registry. In case you need to clean the runner or the localhost, use the commands of the "werf host" group.
 It is worth noting that auto-cleaning is enabled by default, and manual use is usually not required (if not, we would appreciate feedback and creating an issue https://github.com/werf/werf/issues/new).`)
 
 		return err
 	}
-	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
-	if err != nil {
-		return err
-	}
-	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
-	if err != nil {
-		return err
-	}
-
-	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
-	if err != nil {
-		return err
-	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
-	if err != nil {
-		return err
-	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &c.commonCmdData)
 	if err != nil {
 		return err
 	}
-	secondaryStagesStorageList, err := common.GetSecondaryStagesStorageList(stagesStorage, containerRuntime, &commonCmdData)
+	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &c.commonCmdData)
 	if err != nil {
 		return err
 	}
-	cacheStagesStorageList, err := common.GetCacheStagesStorageList(containerRuntime, &commonCmdData)
+	storageOptions, err := common.GetStorageOptions(&c.commonCmdData, stagesStorage, finalStagesStorage, containerRuntime)
 	if err != nil {
 		return err
 	}
 
-	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
-
-	if *commonCmdData.Parallel {
-		storageManager.EnableParallel(int(*commonCmdData.ParallelTasksLimit))
-	}
-
-	imagesNames, err := common.GetManagedImagesNames(ctx, projectName, stagesStorage, werfConfig)
-	if err != nil {
-		return err
-	}
-	logboek.Debug().LogF("Managed images names: %v\n", imagesNames)
-
-	kubernetesContextClients, err := common.GetKubernetesContextClients(&commonCmdData)
+	kubernetesContextClients, err := common.GetKubernetesContextClients(&c.commonCmdData)
 	if err != nil {
 		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
 	}
 
-	cleanupOptions := cleaning.CleanupOptions{
-		ImageNameList:                           imagesNames,
-		LocalGit:                                giterminismManager.LocalGitRepo(),
-		KubernetesContextClients:                kubernetesContextClients,
-		KubernetesNamespaceRestrictionByContext: common.GetKubernetesNamespaceRestrictionByContext(&commonCmdData, kubernetesContextClients),
-		WithoutKube:                             *commonCmdData.WithoutKube,
-		GitHistoryBasedCleanupOptions:           werfConfig.Meta.Cleanup,
-		KeepStagesBuiltWithinLastNHours:         *commonCmdData.KeepStagesBuiltWithinLastNHours,
-		DryRun:                                  *commonCmdData.DryRun,
-	}
-
-	logboek.LogOptionalLn()
-	if err := cleaning.Cleanup(ctx, projectName, storageManager, storageLockManager, cleanupOptions); err != nil {
+	mergeRequestsAPI, err := common.GetMergeRequestsAPI(&c.commonCmdData)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return flows.Cleanup(ctx, giterminismManager, werfConfig, flows.CleanupOptions{
+		Storage:                                 storageOptions,
+		Parallel:                                *c.commonCmdData.Parallel,
+		ParallelTasksLimit:                      int(*c.commonCmdData.ParallelTasksLimit),
+		ParallelTaskTimeout:                     time.Duration(*c.commonCmdData.ParallelTaskTimeoutSeconds) * time.Second,
+		KubernetesContextClients:                kubernetesContextClients,
+		KubernetesNamespaceRestrictionByContext: common.GetKubernetesNamespaceRestrictionByContext(&c.commonCmdData, kubernetesContextClients),
+		WithoutKube:                             *c.commonCmdData.WithoutKube,
+		KeepStagesBuiltWithinLastNHours:         *c.commonCmdData.KeepStagesBuiltWithinLastNHours,
+		KeepImagesSeenWithinLastNHours:          *c.commonCmdData.KeepImagesSeenWithinLastNHours,
+		MergeRequestsAPI:                        mergeRequestsAPI,
+		DryRun:                                  *c.commonCmdData.DryRun,
+	})
 }
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// GetBundleBuildInfo returns the build info of the images to save into the published bundle.
+// There is no build info in the development mode, because the images are built from the uncommitted changes.
+func GetBundleBuildInfo(cmdData *CmdData, giterminismManager giterminism_manager.Interface, werfConfig *config.WerfConfig, imagesInfoGetters []*image.InfoGetter) (*chart_extender.BundleBuildInfo, error) {
+	if giterminismManager.Dev() {
+		return nil, nil
+	}
+
+	werfConfigPath, err := GetCustomWerfConfigRelPath(giterminismManager, cmdData)
+	if err != nil {
+		return nil, err
+	}
+
+	werfConfigTemplatesDir, err := GetCustomWerfConfigTemplatesDirRelPath(giterminismManager, cmdData)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &chart_extender.BundleBuildInfo{
+		WerfVersion:             werf.Version,
+		Project:                 werfConfig.Meta.Project,
+		Env:                     *cmdData.Environment,
+		Commit:                  giterminismManager.HeadCommit(),
+		RelativeToGitProjectDir: giterminismManager.RelativeToGitProjectDir(),
+		WerfConfigPath:          werfConfigPath,
+		WerfConfigTemplatesDir:  werfConfigTemplatesDir,
+		Images:                  map[string]chart_extender.BundleBuildInfoImage{},
+	}
+
+	for _, imageInfoGetter := range imagesInfoGetters {
+		info.Images[imageInfoGetter.WerfImageName] = chart_extender.BundleBuildInfoImage{
+			Name:        imageInfoGetter.GetName(),
+			StageDigest: imageInfoGetter.StageDigest,
+			ImageID:     imageInfoGetter.ImageID,
+		}
+	}
+
+	return info, nil
+}
@@ -10,13 +10,19 @@ import (
 )
 
 func GetConveyorOptions(commonCmdData *CmdData) build.ConveyorOptions {
-	return build.ConveyorOptions{
+	conveyorOptions := build.ConveyorOptions{
 		LocalGitRepoVirtualMergeOptions: stage.VirtualMergeOptions{
-			VirtualMerge:           *commonCmdData.VirtualMerge,
+			VirtualMerge:           *commonCmdData.VirtualMerge || *commonCmdData.VirtualMergeInto != "",
 			VirtualMergeFromCommit: *commonCmdData.VirtualMergeFromCommit,
 			VirtualMergeIntoCommit: *commonCmdData.VirtualMergeIntoCommit,
 		},
 	}
+
+	if commonCmdData.Environment != nil {
+		conveyorOptions.Environment = *commonCmdData.Environment
+	}
+
+	return conveyorOptions
 }
 
 func GetConveyorOptionsWithParallel(commonCmdData *CmdData, buildStagesOptions build.BuildOptions) (build.ConveyorOptions, error) {
@@ -30,6 +36,13 @@ func GetConveyorOptionsWithParallel(commonCmdData *CmdData, buildStagesOptions b
 
 	conveyorOptions.ParallelTasksLimit = parallelTasksLimit
 
+	dockerfileSecrets, err := GetDockerfileSecrets(commonCmdData)
+	if err != nil {
+		return conveyorOptions, err
+	}
+
+	conveyorOptions.DockerfileSecrets = dockerfileSecrets
+
 	return conveyorOptions, nil
 }
 
@@ -44,14 +57,35 @@ func GetBuildOptions(commonCmdData *CmdData, werfConfig *config.WerfConfig) (bui
 		return buildOptions, err
 	}
 
+	scanOptions, err := GetScanOptions(commonCmdData)
+	if err != nil {
+		return buildOptions, err
+	}
+
+	stageLogsOptions, err := GetStageLogsOptions(commonCmdData)
+	if err != nil {
+		return buildOptions, err
+	}
+
+	graphOptions, err := GetGraphOptions(commonCmdData)
+	if err != nil {
+		return buildOptions, err
+	}
+
 	buildOptions = build.BuildOptions{
 		ImageBuildOptions: container_runtime.BuildOptions{
-			IntrospectAfterError:  *commonCmdData.IntrospectAfterError,
-			IntrospectBeforeError: *commonCmdData.IntrospectBeforeError,
+			IntrospectAfterError:     *commonCmdData.IntrospectAfterError,
+			IntrospectBeforeError:    *commonCmdData.IntrospectBeforeError,
+			IntrospectShell:          *commonCmdData.IntrospectShell,
+			KeepFailedStageContainer: *commonCmdData.KeepFailedStageContainer,
 		},
-		IntrospectOptions: introspectOptions,
-		ReportPath:        *commonCmdData.ReportPath,
-		ReportFormat:      reportFormat,
+		IntrospectOptions:     introspectOptions,
+		ReportPath:            *commonCmdData.ReportPath,
+		ReportFormat:          reportFormat,
+		ReportSupplyChainPath: *commonCmdData.ReportSupplyChainPath,
+		ScanOptions:           scanOptions,
+		StageLogsOptions:      stageLogsOptions,
+		GraphOptions:          graphOptions,
 	}
 
 	return buildOptions, nil
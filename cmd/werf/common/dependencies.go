@@ -0,0 +1,34 @@
+package common
+
+import (
+	"context"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// GetRequiredWerfConfigWithDependencies loads the werf config, the images of the other werf projects declared in the meta.dependencies directive are resolved and available in the config templates as {{ .Dependencies.NAME }}.
+func GetRequiredWerfConfigWithDependencies(ctx context.Context, cmdData *CmdData, giterminismManager giterminism_manager.Interface, opts config.WerfConfigOptions) (string, *config.WerfConfig, map[string]config.DependencyTemplateData, error) {
+	opts.ResolveDependencies = GetDependenciesResolver(cmdData, giterminismManager)
+
+	werfConfigPath, werfConfig, err := GetRequiredWerfConfig(ctx, cmdData, giterminismManager, opts)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return werfConfigPath, werfConfig, werfConfig.Dependencies, nil
+}
+
+// GetDependenciesResolver returns the resolver of the meta dependencies for the config.WerfConfigOptions.
+func GetDependenciesResolver(cmdData *CmdData, giterminismManager giterminism_manager.Interface) func(ctx context.Context, dependencies []config.MetaDependency) (map[string]config.DependencyTemplateData, error) {
+	return flows.NewDependenciesResolver(giterminismManager, flows.DependenciesOptions{
+		Repo: *cmdData.StagesStorage,
+		NewStagesStorage: func(repo string) (storage.StagesStorage, error) {
+			containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+			return GetStagesStorage(repo, containerRuntime, cmdData)
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const testDependencyStageDigest = "2604b86b2c7a1c6d19c62601aadb19e7d5c6bb8f17bc2bf26a390ea7"
+
+const testWerfConfigWithDependencies = `project: app
+configVersion: 1
+dependencies:
+- project: backend
+  image: api
+  repo: %s
+  commit: %s
+---
+image: app
+from: {{ .Dependencies.api.Image }}
+`
+
+// newDependenciesTestCmdData sets up the options of the commands loading the werf config with images as the build and stage commands do.
+func newDependenciesTestCmdData(t *testing.T, projectDir string) *CmdData {
+	cmdData := &CmdData{}
+	cmd := &cobra.Command{}
+
+	SetupDir(cmdData, cmd)
+	SetupGitWorkTree(cmdData, cmd)
+	SetupConfigTemplatesDir(cmdData, cmd)
+	SetupConfigPath(cmdData, cmd)
+	SetupEnvironment(cmdData, cmd)
+	SetupGiterminismOptions(cmdData, cmd)
+	SetupStagesStorageOptions(cmdData, cmd)
+
+	for flag, value := range map[string]string{"dir": projectDir, "insecure-registry": "true", "repo": ""} {
+		if err := cmd.Flags().Set(flag, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return cmdData
+}
+
+func runTestGit(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+func TestGetRequiredWerfConfigWithDependencies(t *testing.T) {
+	ctx := context.Background()
+
+	if err := werf.Init(t.TempDir(), t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := git_repo.Init(gitDataManager); err != nil {
+		t.Fatal(err)
+	}
+	if err := true_git.Init(true_git.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	registryServer := httptest.NewServer(registry.New())
+	defer registryServer.Close()
+	repo := strings.TrimPrefix(registryServer.URL, "http://") + "/backend"
+
+	projectDir := t.TempDir()
+	cmdData := newDependenciesTestCmdData(t, projectDir)
+
+	// the image api of the backend project built for the commit 1111111 is stored in the repo
+	stagesStorage, err := GetStagesStorage(repo, &container_runtime.LocalDockerServerRuntime{}, cmdData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageImageName := stagesStorage.ConstructStageImageName("backend", testDependencyStageDigest, 1611836746968)
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(stageImageName, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := stagesStorage.PutImageMetadata(ctx, "backend", "api", "1111111", fmt.Sprintf("%s-%d", testDependencyStageDigest, 1611836746968)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(projectDir, "werf.yaml"), []byte(fmt.Sprintf(testWerfConfigWithDependencies, repo, "1111111")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runTestGit(t, projectDir, "init")
+	runTestGit(t, projectDir, "add", "werf.yaml")
+	runTestGit(t, projectDir, "commit", "-m", "init")
+
+	giterminismManager, err := GetGiterminismManager(cmdData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// werf build loads the config with the rendered file path logged, werf stage image and the other stage commands without it
+	_, buildWerfConfig, dependencies, err := GetRequiredWerfConfigWithDependencies(ctx, cmdData, giterminismManager, GetWerfConfigOptions(cmdData, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stageImageWerfConfig, stageImageDependencies, err := GetRequiredWerfConfigWithDependencies(ctx, cmdData, giterminismManager, GetWerfConfigOptions(cmdData, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dependencies["api"].Image != stageImageName {
+		t.Fatalf("expected the dependency image %q, got %q", stageImageName, dependencies["api"].Image)
+	}
+	if !reflect.DeepEqual(dependencies, stageImageDependencies) {
+		t.Fatalf("expected the same dependencies for werf build and werf stage image, got %+v and %+v", dependencies, stageImageDependencies)
+	}
+	if from := buildWerfConfig.GetStapelImage("app").From; from != stageImageName {
+		t.Fatalf("expected the image app to be based on the dependency image %q, got %q", stageImageName, from)
+	}
+	if from := stageImageWerfConfig.GetStapelImage("app").From; from != buildWerfConfig.GetStapelImage("app").From {
+		t.Fatalf("expected the same base image for werf build and werf stage image, got %q and %q", buildWerfConfig.GetStapelImage("app").From, from)
+	}
+
+	// without the resolved dependencies the image is based on the empty image name
+	_, werfConfigWithoutDependencies, err := GetRequiredWerfConfig(ctx, cmdData, giterminismManager, GetWerfConfigOptions(cmdData, false))
+	if err == nil && werfConfigWithoutDependencies.GetStapelImage("app").From == stageImageName {
+		t.Fatal("expected the config rendered without the dependencies to differ")
+	}
+}
@@ -1,109 +1,15 @@
 package common
 
 import (
-	"bytes"
 	"fmt"
 	"strings"
-	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
 	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/giterminism_manager"
 	"github.com/werf/werf/pkg/image"
-	"github.com/werf/werf/pkg/slug"
 )
 
-func GetHelmRelease(releaseOption string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
-	if releaseOption != "" {
-		err := slug.ValidateHelmRelease(releaseOption)
-		if err != nil {
-			return "", fmt.Errorf("bad Helm release specified %q: %s", releaseOption, err)
-		}
-		return releaseOption, nil
-	}
-
-	var releaseTemplate string
-	if werfConfig.Meta.Deploy.HelmRelease != nil {
-		releaseTemplate = *werfConfig.Meta.Deploy.HelmRelease
-	} else if environmentOption == "" {
-		releaseTemplate = "[[ project ]]"
-	} else {
-		releaseTemplate = "[[ project ]]-[[ env ]]"
-	}
-
-	renderedRelease, err := renderDeployParamTemplate("release", releaseTemplate, environmentOption, werfConfig)
-	if err != nil {
-		return "", fmt.Errorf("cannot render Helm release name by template %q: %s", releaseTemplate, err)
-	}
-
-	if renderedRelease == "" {
-		return "", fmt.Errorf("Helm release rendered by template %q is empty: release name cannot be empty", releaseTemplate)
-	}
-
-	var helmReleaseSlug bool
-	if werfConfig.Meta.Deploy.HelmReleaseSlug != nil {
-		helmReleaseSlug = *werfConfig.Meta.Deploy.HelmReleaseSlug
-	} else {
-		helmReleaseSlug = true
-	}
-
-	if helmReleaseSlug {
-		return slug.HelmRelease(renderedRelease), nil
-	}
-
-	err = slug.ValidateHelmRelease(renderedRelease)
-	if err != nil {
-		return "", fmt.Errorf("bad Helm release %q rendered by template %q: %s", renderedRelease, releaseTemplate, err)
-	}
-
-	return renderedRelease, nil
-}
-
-func GetKubernetesNamespace(namespaceOption string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
-	if namespaceOption != "" {
-		err := slug.ValidateKubernetesNamespace(namespaceOption)
-		if err != nil {
-			return "", fmt.Errorf("bad Kubernetes namespace specified %q: %s", namespaceOption, err)
-		}
-		return namespaceOption, nil
-	}
-
-	var namespaceTemplate string
-	if werfConfig.Meta.Deploy.Namespace != nil {
-		namespaceTemplate = *werfConfig.Meta.Deploy.Namespace
-	} else if environmentOption == "" {
-		namespaceTemplate = "[[ project ]]"
-	} else {
-		namespaceTemplate = "[[ project ]]-[[ env ]]"
-	}
-
-	renderedNamespace, err := renderDeployParamTemplate("namespace", namespaceTemplate, environmentOption, werfConfig)
-	if err != nil {
-		return "", fmt.Errorf("cannot render Kubernetes namespace by template %q: %s", namespaceTemplate, err)
-	}
-
-	if renderedNamespace == "" {
-		return "", fmt.Errorf("Kubernetes namespace rendered by template %q is empty: namespace cannot be empty", namespaceTemplate)
-	}
-
-	var namespaceSlug bool
-	if werfConfig.Meta.Deploy.NamespaceSlug != nil {
-		namespaceSlug = *werfConfig.Meta.Deploy.NamespaceSlug
-	} else {
-		namespaceSlug = true
-	}
-
-	if namespaceSlug {
-		return slug.KubernetesNamespace(renderedNamespace), nil
-	}
-
-	err = slug.ValidateKubernetesNamespace(renderedNamespace)
-	if err != nil {
-		return "", fmt.Errorf("bad Kubernetes namespace %q rendered by template %q: %s", renderedNamespace, namespaceTemplate, err)
-	}
-
-	return renderedNamespace, nil
-}
-
 func GetUserExtraAnnotations(cmdData *CmdData) (map[string]string, error) {
 	extraAnnotationMap := map[string]string{}
 	var addAnnotations []string
@@ -140,34 +46,51 @@ func GetUserExtraLabels(cmdData *CmdData) (map[string]string, error) {
 	return extraLabelMap, nil
 }
 
-func renderDeployParamTemplate(templateName, templateText string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
-	tmpl := template.New(templateName).Delims("[[", "]]")
-
-	funcMap := sprig.TxtFuncMap()
-	delete(funcMap, "env")
-	delete(funcMap, "expandenv")
+// GetUserExtraAnnotationsWithWerfConfig returns the werf.yaml metadata.annotations merged with the annotations specified by --add-annotation and $WERF_ADD_ANNOTATION_*, the latter take precedence.
+func GetUserExtraAnnotationsWithWerfConfig(cmdData *CmdData, werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface) (map[string]string, error) {
+	extraAnnotationMap, err := werfConfig.Meta.Metadata.RenderAnnotations(GetMetadataTemplateData(cmdData, werfConfig, giterminismManager))
+	if err != nil {
+		return nil, err
+	}
 
-	funcMap["project"] = func() string {
-		return werfConfig.Meta.Project
+	userExtraAnnotations, err := GetUserExtraAnnotations(cmdData)
+	if err != nil {
+		return nil, err
 	}
 
-	funcMap["env"] = func() (string, error) {
-		return environmentOption, nil
+	for key, value := range userExtraAnnotations {
+		extraAnnotationMap[key] = value
 	}
 
-	tmpl = tmpl.Funcs(funcMap)
+	return extraAnnotationMap, nil
+}
 
-	tmpl, err := tmpl.Parse(templateText)
+// GetUserExtraLabelsWithWerfConfig returns the werf.yaml metadata.labels merged with the labels specified by --add-label and $WERF_ADD_LABEL_*, the latter take precedence.
+func GetUserExtraLabelsWithWerfConfig(cmdData *CmdData, werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface) (map[string]string, error) {
+	extraLabelMap, err := werfConfig.Meta.Metadata.RenderLabels(GetMetadataTemplateData(cmdData, werfConfig, giterminismManager))
 	if err != nil {
-		return "", fmt.Errorf("bad template: %s", err)
+		return nil, err
 	}
 
-	buf := bytes.NewBuffer(nil)
-	if err := tmpl.ExecuteTemplate(buf, templateName, nil); err != nil {
-		return "", err
+	userExtraLabels, err := GetUserExtraLabels(cmdData)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range userExtraLabels {
+		extraLabelMap[key] = value
+	}
+
+	return extraLabelMap, nil
+}
+
+func GetMetadataTemplateData(cmdData *CmdData, werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface) config.MetadataTemplateData {
+	var environment string
+	if cmdData.Environment != nil {
+		environment = *cmdData.Environment
 	}
 
-	return buf.String(), nil
+	return flows.GetMetadataTemplateData(environment, werfConfig, giterminismManager)
 }
 
 func StubImageInfoGetters(werfConfig *config.WerfConfig) (list []*image.InfoGetter) {
@@ -180,7 +103,7 @@ func StubImageInfoGetters(werfConfig *config.WerfConfig) (list []*image.InfoGett
 	}
 
 	for _, imageName := range imagesNames {
-		list = append(list, image.NewInfoGetter(imageName, fmt.Sprintf("%s:%s", StubRepoAddress, StubTag), StubTag))
+		list = append(list, image.NewInfoGetter(imageName, fmt.Sprintf("%s:%s", StubRepoAddress, StubTag), StubTag, image.StubStageDigest, "", ""))
 	}
 
 	return list
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestStubImageInfoGetters(t *testing.T) {
+	werfConfig := &config.WerfConfig{
+		StapelImages:         []*config.StapelImage{{StapelImageBase: &config.StapelImageBase{Name: "backend"}}},
+		ImagesFromDockerfile: []*config.ImageFromDockerfile{{Name: "frontend"}},
+	}
+
+	getters := StubImageInfoGetters(werfConfig)
+	if len(getters) != 2 {
+		t.Fatalf("expected 2 stub image info getters, got %d", len(getters))
+	}
+
+	for i, imageName := range []string{"backend", "frontend"} {
+		getter := getters[i]
+		if getter.GetWerfImageName() != imageName {
+			t.Errorf("expected the image %q, got %q", imageName, getter.GetWerfImageName())
+		}
+		if getter.GetTag() != StubTag {
+			t.Errorf("expected the stub tag %q of the image %q, got %q", StubTag, imageName, getter.GetTag())
+		}
+		if getter.GetStageDigest() != image.StubStageDigest {
+			t.Errorf("expected the stub stage digest %q of the image %q, got %q", image.StubStageDigest, imageName, getter.GetStageDigest())
+		}
+	}
+}
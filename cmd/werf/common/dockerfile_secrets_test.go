@@ -0,0 +1,71 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetDockerfileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	npmrcPath := filepath.Join(dir, ".npmrc")
+	if err := ioutil.WriteFile(npmrcPath, []byte("token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tokenPath := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenPath, []byte("token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldValue, isSet := os.LookupEnv("WERF_BUILD_SECRET_NPMRC")
+	defer func() {
+		if isSet {
+			_ = os.Setenv("WERF_BUILD_SECRET_NPMRC", oldValue)
+		} else {
+			_ = os.Unsetenv("WERF_BUILD_SECRET_NPMRC")
+		}
+	}()
+	if err := os.Setenv("WERF_BUILD_SECRET_NPMRC", "id=npmrc,src="+npmrcPath); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := GetDockerfileSecrets(&CmdData{Secrets: &[]string{"source=" + tokenPath + ",id=token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(secrets) != 2 {
+		t.Fatalf("expected 2 secrets, got %d", len(secrets))
+	}
+	if secrets[0].ID != "npmrc" || secrets[0].Src != npmrcPath {
+		t.Errorf("unexpected secret from the env: %+v", secrets[0])
+	}
+	if secrets[1].ID != "token" || secrets[1].Src != tokenPath {
+		t.Errorf("unexpected secret from the option: %+v", secrets[1])
+	}
+}
+
+func TestGetDockerfileSecrets_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenPath, []byte("token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		spec, expectedErr string
+	}{
+		{"token", "expected format id=ID,src=PATH"},
+		{"id=token", "expected format id=ID,src=PATH"},
+		{"src=" + tokenPath, "expected format id=ID,src=PATH"},
+		{"id=token,src=" + tokenPath + ",type=file", `unsupported field "type"`},
+		{"id=token,src=" + filepath.Join(dir, "missing"), `secret "token" file`},
+		{"id=token,src=" + dir, `secret "token" file`},
+	} {
+		if _, err := GetDockerfileSecrets(&CmdData{Secrets: &[]string{tc.spec}}); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%q: expected error %q, got %v", tc.spec, tc.expectedErr, err)
+		}
+	}
+}
@@ -13,6 +13,7 @@ type Env string
 const (
 	CmdEnvAnno                  string = "environment"
 	DisableOptionsInUseLineAnno string = "disableOptionsInUseLine"
+	SetupErrorAnno              string = "setupError"
 
 	WerfDebugAnsibleArgs Env = "WERF_DEBUG_ANSIBLE_ARGS"
 	WerfSecretKey        Env = "WERF_SECRET_KEY"
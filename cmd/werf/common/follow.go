@@ -3,16 +3,57 @@ package common
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/werf/logboek"
 	"github.com/werf/logboek/pkg/style"
 	"github.com/werf/logboek/pkg/types"
 
+	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/util/fswatcher"
 )
 
+// followStatus is the result of the last command run in follow mode.
+type followStatus struct {
+	Commit     string
+	Err        error
+	FinishedAt time.Time
+	Duration   time.Duration
+}
+
+func (status *followStatus) String() string {
+	if status.Err != nil {
+		return fmt.Sprintf("Last run failed in %s at %s (commit %s): %s", status.Duration.Round(time.Millisecond), status.FinishedAt.Format("15:04:05"), status.Commit, status.Err)
+	}
+
+	return fmt.Sprintf("Last run succeeded in %s at %s (commit %s)", status.Duration.Round(time.Millisecond), status.FinishedAt.Format("15:04:05"), status.Commit)
+}
+
+// logFollowStatusLine prints the single line with the last run result and the wait message, the line is the last output while waiting for the changes.
+func logFollowStatusLine(ctx context.Context, status *followStatus, waitMessage string) {
+	switch {
+	case status == nil:
+		logboek.Context(ctx).LogLn(waitMessage)
+	case status.Err != nil:
+		logboek.Context(ctx).Warn().LogF("%s. %s\n", status, waitMessage)
+	default:
+		logboek.Context(ctx).Default().LogFHighlight("%s. %s\n", status, waitMessage)
+	}
+
+	logboek.Context(ctx).LogOptionalLn()
+}
+
+// FollowGitHead runs the task for the current HEAD commit (or the worktree state in development mode) and then for each new one.
+// The git repository (or the worktree in development mode) is watched for the changes, the rapid changes are debounced (--follow-debounce).
+// The task errors do not stop the follow mode, the status line with the result of the last run is printed while waiting for the next changes.
 func FollowGitHead(ctx context.Context, cmdData *CmdData, taskFunc func(ctx context.Context, iterGiterminismManager giterminism_manager.Interface) error) error {
+	debounce, err := time.ParseDuration(*cmdData.FollowDebounce)
+	if err != nil {
+		return fmt.Errorf("bad --follow-debounce given: %s", err)
+	}
+
 	var waitMessage string
 	if *cmdData.Dev {
 		waitMessage = "Waiting for new changes ..."
@@ -20,45 +61,70 @@ func FollowGitHead(ctx context.Context, cmdData *CmdData, taskFunc func(ctx cont
 		waitMessage = "Waiting for the new commit ..."
 	}
 
-	var savedHeadCommit string
-	iterFunc := func() error {
-		giterminismManager, err := GetGiterminismManager(cmdData)
-		if err != nil {
-			return fmt.Errorf("unable to get giterminism manager: %s", err)
-		}
+	giterminismManager, err := GetGiterminismManager(cmdData)
+	if err != nil {
+		return fmt.Errorf("unable to get giterminism manager: %s", err)
+	}
 
-		currentHeadCommit := giterminismManager.HeadCommit()
-		if savedHeadCommit != currentHeadCommit {
-			savedHeadCommit = currentHeadCommit
+	watcher, err := newFollowWatcher(giterminismManager.LocalGitRepo(), *cmdData.Dev)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	var savedHeadCommit string
+	var lastStatus *followStatus
+	for {
+		if giterminismManager != nil && giterminismManager.HeadCommit() != savedHeadCommit {
+			savedHeadCommit = giterminismManager.HeadCommit()
 
-			if err := logboek.Context(ctx).LogProcess("Commit %q", savedHeadCommit).
+			startedAt := time.Now()
+			taskErr := logboek.Context(ctx).LogProcess("Commit %q", savedHeadCommit).
 				Options(func(options types.LogProcessOptionsInterface) {
 					options.Style(style.Highlight())
 				}).
 				DoError(func() error {
 					return taskFunc(ctx, giterminismManager)
-				}); err != nil {
-				return err
+				})
+
+			lastStatus = &followStatus{
+				Commit:     savedHeadCommit,
+				Err:        taskErr,
+				FinishedAt: time.Now(),
+				Duration:   time.Since(startedAt),
 			}
 
-			logboek.Context(ctx).LogLn(waitMessage)
-			logboek.Context(ctx).LogOptionalLn()
-		} else {
-			time.Sleep(1 * time.Second)
+			logFollowStatusLine(ctx, lastStatus, waitMessage)
+		}
+
+		if err := watcher.WaitForChanges(ctx, debounce); err != nil {
+			return err
 		}
 
-		return nil
+		if giterminismManager, err = GetGiterminismManager(cmdData); err != nil {
+			logboek.Context(ctx).Warn().LogF("Unable to get giterminism manager: %s\n", err)
+			logFollowStatusLine(ctx, lastStatus, waitMessage)
+		}
 	}
+}
 
-	if err := iterFunc(); err != nil {
-		return err
+// newFollowWatcher watches the worktree except the ignored files in development mode, and HEAD and refs of the git repository otherwise.
+func newFollowWatcher(localGitRepo *git_repo.Local, dev bool) (*fswatcher.Watcher, error) {
+	watcher, err := fswatcher.New(fswatcher.Options{SkipDirs: []string{".git"}, Gitignore: true})
+	if err != nil {
+		return nil, err
 	}
 
-	for {
-		if err := iterFunc(); err != nil {
-			logboek.Context(ctx).Warn().LogLn(err)
-			logboek.Context(ctx).LogLn(waitMessage)
-			logboek.Context(ctx).LogOptionalLn()
-		}
+	if dev {
+		err = watcher.AddTree(localGitRepo.WorkTreeDir)
+	} else if err = watcher.AddDir(localGitRepo.GitDir); err == nil {
+		err = watcher.AddTree(filepath.Join(localGitRepo.GitDir, "refs"))
 	}
+
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return watcher, nil
 }
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFollowStatus_String(t *testing.T) {
+	finishedAt := time.Date(2021, 1, 1, 12, 30, 15, 0, time.UTC)
+
+	status := &followStatus{Commit: "abc", FinishedAt: finishedAt, Duration: 1500 * time.Millisecond}
+	if got, expected := status.String(), "Last run succeeded in 1.5s at 12:30:15 (commit abc)"; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	status.Err = errors.New("build failed")
+	if got, expected := status.String(), "Last run failed in 1.5s at 12:30:15 (commit abc): build failed"; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
@@ -2,15 +2,18 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/werf/kubedog/pkg/kube"
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/giterminism_manager"
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
 	helm_v3 "helm.sh/helm/v3/cmd/helm"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/postrender"
 )
 
 func NewHelmRegistryClientHandle(ctx context.Context, commonCmdData *CmdData) (*helm_v3.RegistryClientHandle, error) {
@@ -24,7 +27,15 @@ func NewHelmRegistryClientHandle(ctx context.Context, commonCmdData *CmdData) (*
 func NewActionConfig(ctx context.Context, kubeInitializer helm.KubeInitializer, namespace string, commonCmdData *CmdData, registryClientHandle *helm_v3.RegistryClientHandle) (*action.Configuration, error) {
 	actionConfig := new(action.Configuration)
 
-	if err := helm.InitActionConfig(ctx, kubeInitializer, namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
+	if err := helm.InitActionConfig(ctx, kubeInitializer, namespace, cmd_helm.Settings, registryClientHandle, actionConfig, getInitActionConfigOptions(commonCmdData)); err != nil {
+		return nil, err
+	}
+
+	return actionConfig, nil
+}
+
+func getInitActionConfigOptions(commonCmdData *CmdData) helm.InitActionConfigOptions {
+	return helm.InitActionConfigOptions{
 		StatusProgressPeriod:      time.Duration(*commonCmdData.StatusProgressPeriodSeconds) * time.Second,
 		HooksStatusProgressPeriod: time.Duration(*commonCmdData.HooksStatusProgressPeriodSeconds) * time.Second,
 		KubeConfigOptions: kube.KubeConfigOptions{
@@ -33,9 +44,46 @@ func NewActionConfig(ctx context.Context, kubeInitializer helm.KubeInitializer,
 			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
 		},
 		ReleasesHistoryMax: *commonCmdData.ReleasesHistoryMax,
-	}); err != nil {
+	}
+}
+
+// GetPostRenderer chains the user post-renderers returned by the GetUserPostRenderers before the werf post-renderer,
+// so that werf annotations and labels are set on the resources added by the user post-renderers.
+func GetPostRenderer(ctx context.Context, commonCmdData *CmdData, giterminismManager giterminism_manager.Interface, werfPostRenderer postrender.PostRenderer) (postrender.PostRenderer, error) {
+	postRenderers, err := GetUserPostRenderers(ctx, commonCmdData, giterminismManager)
+	if err != nil {
 		return nil, err
 	}
 
-	return actionConfig, nil
+	if len(postRenderers) == 0 {
+		return werfPostRenderer, nil
+	}
+
+	return helm.NewChainPostRenderer(append(postRenderers, werfPostRenderer)...), nil
+}
+
+// GetUserPostRenderers returns the post-renderers specified by the --post-renderer and --post-renderer-kustomize-dir options.
+// The kustomization dir is read by the giterminism file reader.
+func GetUserPostRenderers(ctx context.Context, commonCmdData *CmdData, giterminismManager giterminism_manager.Interface) ([]postrender.PostRenderer, error) {
+	var postRenderers []postrender.PostRenderer
+
+	if *commonCmdData.PostRenderer != "" {
+		execPostRenderer, err := postrender.NewExec(*commonCmdData.PostRenderer)
+		if err != nil {
+			return nil, fmt.Errorf("bad --post-renderer value: %s", err)
+		}
+
+		postRenderers = append(postRenderers, execPostRenderer)
+	}
+
+	if *commonCmdData.PostRendererKustomizeDir != "" {
+		files, err := giterminismManager.FileReader().LoadKustomizationDir(ctx, *commonCmdData.PostRendererKustomizeDir)
+		if err != nil {
+			return nil, fmt.Errorf("bad --post-renderer-kustomize-dir value: %s", err)
+		}
+
+		postRenderers = append(postRenderers, helm.NewKustomizePostRenderer(*commonCmdData.PostRendererKustomizeDir, files))
+	}
+
+	return postRenderers, nil
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/werf/werf/pkg/host_cleaning"
+	"github.com/werf/werf/pkg/remote_builder"
 )
 
 func RunAutoHostCleanup(ctx context.Context, cmdData *CmdData) error {
@@ -30,6 +31,7 @@ func RunAutoHostCleanup(ctx context.Context, cmdData *CmdData) error {
 		AllowedLocalCacheVolumeUsagePercentage:          cmdData.AllowedLocalCacheVolumeUsage,
 		AllowedLocalCacheVolumeUsageMarginPercentage:    cmdData.AllowedLocalCacheVolumeUsageMargin,
 		DockerServerStoragePath:                         *cmdData.DockerServerStoragePath,
+		SkipLocalDockerServer:                           remote_builder.IsEnabled(),
 	})
 }
 
@@ -42,13 +44,13 @@ func SetupAllowedDockerStorageVolumeUsage(cmdData *CmdData, cmd *cobra.Command)
 	envVarName := "WERF_ALLOWED_DOCKER_STORAGE_VOLUME_USAGE"
 
 	var defaultVal uint
-	if v := GetUint64EnvVarStrict(envVarName); v != nil {
+	if v := GetUint64EnvVarStrict(cmd, envVarName); v != nil {
 		defaultVal = uint(*v)
 	} else {
 		defaultVal = uint(host_cleaning.DefaultAllowedDockerStorageVolumeUsagePercentage)
 	}
 	if defaultVal > 100 {
-		TerminateWithError(fmt.Sprintf("bad %s value: specify percentage between 0 and 100", envVarName), 1)
+		AddSetupError(cmd, fmt.Errorf("bad %s value: specify percentage between 0 and 100", envVarName))
 	}
 
 	cmdData.AllowedDockerStorageVolumeUsage = new(uint)
@@ -59,13 +61,13 @@ func SetupAllowedDockerStorageVolumeUsageMargin(cmdData *CmdData, cmd *cobra.Com
 	envVarName := "WERF_ALLOWED_DOCKER_STORAGE_VOLUME_USAGE_MARGIN"
 
 	var defaultVal uint
-	if v := GetUint64EnvVarStrict(envVarName); v != nil {
+	if v := GetUint64EnvVarStrict(cmd, envVarName); v != nil {
 		defaultVal = uint(*v)
 	} else {
 		defaultVal = uint(host_cleaning.DefaultAllowedDockerStorageVolumeUsageMarginPercentage)
 	}
 	if defaultVal > 100 {
-		TerminateWithError(fmt.Sprintf("bad %s value: specify percentage between 0 and 100", envVarName), 1)
+		AddSetupError(cmd, fmt.Errorf("bad %s value: specify percentage between 0 and 100", envVarName))
 	}
 
 	cmdData.AllowedDockerStorageVolumeUsageMargin = new(uint)
@@ -81,13 +83,13 @@ func SetupAllowedLocalCacheVolumeUsage(cmdData *CmdData, cmd *cobra.Command) {
 	envVarName := "WERF_ALLOWED_LOCAL_CACHE_VOLUME_USAGE"
 
 	var defaultVal uint
-	if v := GetUint64EnvVarStrict(envVarName); v != nil {
+	if v := GetUint64EnvVarStrict(cmd, envVarName); v != nil {
 		defaultVal = uint(*v)
 	} else {
 		defaultVal = uint(host_cleaning.DefaultAllowedLocalCacheVolumeUsagePercentage)
 	}
 	if defaultVal > 100 {
-		TerminateWithError(fmt.Sprintf("bad %s value: specify percentage between 0 and 100", envVarName), 1)
+		AddSetupError(cmd, fmt.Errorf("bad %s value: specify percentage between 0 and 100", envVarName))
 	}
 
 	cmdData.AllowedLocalCacheVolumeUsage = new(uint)
@@ -98,13 +100,13 @@ func SetupAllowedLocalCacheVolumeUsageMargin(cmdData *CmdData, cmd *cobra.Comman
 	envVarName := "WERF_ALLOWED_LOCAL_CACHE_VOLUME_USAGE_MARGIN"
 
 	var defaultVal uint
-	if v := GetUint64EnvVarStrict(envVarName); v != nil {
+	if v := GetUint64EnvVarStrict(cmd, envVarName); v != nil {
 		defaultVal = uint(*v)
 	} else {
 		defaultVal = uint(host_cleaning.DefaultAllowedLocalCacheVolumeUsageMarginPercentage)
 	}
 	if defaultVal > 100 {
-		TerminateWithError(fmt.Sprintf("bad %s value: specify percentage between 0 and 100", envVarName), 1)
+		AddSetupError(cmd, fmt.Errorf("bad %s value: specify percentage between 0 and 100", envVarName))
 	}
 
 	cmdData.AllowedLocalCacheVolumeUsageMargin = new(uint)
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/werf/pkg/deploy/lock_manager"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/locks_inspector"
+	"github.com/werf/werf/pkg/storage"
+)
+
+type KubernetesLocksSource struct {
+	Client        kubernetes.Interface
+	Namespace     string
+	ConfigMapName string
+	LockType      locks_inspector.LockType
+
+	// UseLeases locks are stored in the Lease objects labeled by the ProjectName instead of the ConfigMap
+	UseLeases   bool
+	ProjectName string
+}
+
+func (source *KubernetesLocksSource) String() string {
+	if source.UseLeases {
+		return fmt.Sprintf("leases of the project %q in the namespace %q", source.ProjectName, source.Namespace)
+	}
+	return fmt.Sprintf("cm/%s in the namespace %q", source.ConfigMapName, source.Namespace)
+}
+
+func (source *KubernetesLocksSource) ListLocks(ctx context.Context) ([]*locks_inspector.LockDesc, error) {
+	if source.UseLeases {
+		return locks_inspector.ListKubernetesLeaseLocks(ctx, source.Client, source.Namespace, source.ProjectName, source.LockType)
+	}
+	return locks_inspector.ListKubernetesLocks(ctx, source.Client, source.Namespace, source.ConfigMapName, source.LockType)
+}
+
+func (source *KubernetesLocksSource) ReleaseStaleLocks(ctx context.Context, dryRun bool) ([]*locks_inspector.LockDesc, error) {
+	if source.UseLeases {
+		return locks_inspector.ReleaseStaleKubernetesLeaseLocks(ctx, source.Client, source.Namespace, source.ProjectName, source.LockType, dryRun)
+	}
+	return locks_inspector.ReleaseStaleKubernetesLocks(ctx, source.Client, source.Namespace, source.ConfigMapName, source.LockType, dryRun)
+}
+
+// GetKubernetesLocksSources returns the ConfigMaps with the stage locks of the project (--synchronization=kubernetes://NAMESPACE)
+// and with the release locks of the specified release namespace.
+func GetKubernetesLocksSources(ctx context.Context, cmdData *CmdData, releaseNamespace string) ([]*KubernetesLocksSource, error) {
+	var sources []*KubernetesLocksSource
+
+	switch {
+	case *cmdData.Synchronization == "" || *cmdData.Synchronization == storage.LocalStorageAddress:
+	case strings.HasPrefix(*cmdData.Synchronization, "kubernetes://"):
+		if *cmdData.ProjectName == "" {
+			logboek.Context(ctx).Warn().LogLn("WARNING: Stage locks are skipped: --project-name (or $WERF_PROJECT_NAME) should be specified for --synchronization=kubernetes://NAMESPACE")
+			break
+		}
+
+		synchronization, err := GetSynchronization(ctx, cmdData, *cmdData.ProjectName, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := flows.GetSynchronizationKubeClient(synchronization)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, &KubernetesLocksSource{
+			Client:        client,
+			Namespace:     synchronization.KubeParams.Namespace,
+			ConfigMapName: flows.GetSynchronizationConfigMapName(*cmdData.ProjectName),
+			LockType:      locks_inspector.StageLock,
+			UseLeases:     synchronization.KubeLeases,
+			ProjectName:   *cmdData.ProjectName,
+		})
+	default:
+		logboek.Context(ctx).Warn().LogF("WARNING: Stage locks are skipped: locks of the http synchronization server %s cannot be inspected\n", *cmdData.Synchronization)
+	}
+
+	if releaseNamespace != "" {
+		if err := GetOndemandKubeInitializer().Init(ctx); err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, &KubernetesLocksSource{
+			Client:        kube.Client,
+			Namespace:     releaseNamespace,
+			ConfigMapName: lock_manager.ConfigMapName,
+			LockType:      locks_inspector.ReleaseLock,
+		})
+	}
+
+	return sources, nil
+}
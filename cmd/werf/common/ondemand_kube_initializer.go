@@ -1,25 +1,13 @@
 package common
 
 import (
-	"context"
-	"fmt"
-
-	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/werf/pkg/flows"
 )
 
-var ondemandKubeInitializer *OndemandKubeInitializer
-
-type OndemandKubeInitializer struct {
-	KubeContext             string
-	KubeConfig              string
-	KubeConfigBase64        string
-	KubeConfigPathMergeList []string
-
-	initialized bool
-}
+var ondemandKubeInitializer *flows.OndemandKubeInitializer
 
 func SetupOndemandKubeInitializer(kubeContext, kubeConfig, kubeConfigBase64 string, kubeConfigPathMergeList []string) {
-	ondemandKubeInitializer = &OndemandKubeInitializer{
+	ondemandKubeInitializer = &flows.OndemandKubeInitializer{
 		KubeContext:             kubeContext,
 		KubeConfig:              kubeConfig,
 		KubeConfigBase64:        kubeConfigBase64,
@@ -27,29 +15,6 @@ func SetupOndemandKubeInitializer(kubeContext, kubeConfig, kubeConfigBase64 stri
 	}
 }
 
-func GetOndemandKubeInitializer() *OndemandKubeInitializer {
+func GetOndemandKubeInitializer() *flows.OndemandKubeInitializer {
 	return ondemandKubeInitializer
 }
-
-func (initializer *OndemandKubeInitializer) Init(ctx context.Context) error {
-	if initializer.initialized {
-		return nil
-	}
-
-	if err := kube.Init(kube.InitOptions{KubeConfigOptions: kube.KubeConfigOptions{
-		Context:             initializer.KubeContext,
-		ConfigPath:          initializer.KubeConfig,
-		ConfigDataBase64:    initializer.KubeConfigBase64,
-		ConfigPathMergeList: initializer.KubeConfigPathMergeList,
-	}}); err != nil {
-		return fmt.Errorf("cannot initialize kube: %s", err)
-	}
-
-	if err := InitKubedog(ctx); err != nil {
-		return fmt.Errorf("cannot init kubedog: %s", err)
-	}
-
-	initializer.initialized = true
-
-	return nil
-}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func newOutputTestCmdData(output, stagesStorage, finalStagesStorage string) *CmdData {
+	return &CmdData{Output: &output, StagesStorage: &stagesStorage, FinalStagesStorage: &finalStagesStorage}
+}
+
+func TestGetOutputOptions(t *testing.T) {
+	opts, err := GetOutputOptions(newOutputTestCmdData("", "registry.example.com/repo", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts != nil {
+		t.Fatalf("expected no output options without --output, got %+v", opts)
+	}
+
+	for _, stagesStorage := range []string{"", storage.LocalStorageAddress} {
+		opts, err := GetOutputOptions(newOutputTestCmdData("type=local,dest=/out", stagesStorage, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := (build.OutputOptions{Type: build.OutputLocal, Dest: "/out"}); opts == nil || *opts != expected {
+			t.Fatalf("expected output options %+v, got %+v", expected, opts)
+		}
+	}
+
+	for _, cmdData := range []*CmdData{
+		newOutputTestCmdData("type=local,dest=/out", "registry.example.com/repo", ""),
+		newOutputTestCmdData("type=local,dest=/out", "", "registry.example.com/final"),
+		newOutputTestCmdData("type=oci,dest=/out", "", ""),
+	} {
+		if opts, err := GetOutputOptions(cmdData); err == nil {
+			t.Fatalf("expected error for --output=%s --repo=%s --final-repo=%s, got %+v", *cmdData.Output, *cmdData.StagesStorage, *cmdData.FinalStagesStorage, opts)
+		}
+	}
+}
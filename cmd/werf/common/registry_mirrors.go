@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/flows"
+)
+
+func SetupRegistryMirrors(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.RegistryMirrors = new([]string)
+	cmd.Flags().StringArrayVarP(cmdData.RegistryMirrors, "registry-mirror", "", []string{}, `Specify one or multiple registry mirrors or pull-through proxies in the format [REGISTRY=]MIRROR (e.g. mirror.gcr.io, quay.io=nexus.example.com/quay-proxy). Mirrors are tried in the specified order before the registry when pulling base images and stages, Docker Hub is mirrored if the registry is not specified. The credentials of the mirrors are taken from the docker config. Mirrors from werf.yaml registryMirrors directive are tried after the specified ones.
+Also, can be specified with $WERF_REGISTRY_MIRROR_* (e.g. $WERF_REGISTRY_MIRROR_1=..., $WERF_REGISTRY_MIRROR_2=...)`)
+}
+
+func GetRegistryMirrors(cmdData *CmdData) []string {
+	if cmdData.RegistryMirrors == nil {
+		return nil
+	}
+
+	return append(PredefinedValuesByEnvNamePrefix("WERF_REGISTRY_MIRROR_"), *cmdData.RegistryMirrors...)
+}
+
+// InitRegistryMirrors configures the registry mirrors specified by the options and werf.yaml for all pulls of the process.
+func InitRegistryMirrors(cmdData *CmdData, werfConfig *config.WerfConfig) error {
+	var mirrors []*docker_registry.RegistryMirror
+	for _, spec := range GetRegistryMirrors(cmdData) {
+		mirror, err := docker_registry.ParseRegistryMirror(spec)
+		if err != nil {
+			return fmt.Errorf("bad --registry-mirror value: %s", err)
+		}
+
+		mirrors = append(mirrors, mirror)
+	}
+
+	flows.InitRegistryMirrors(mirrors, werfConfig)
+
+	return nil
+}
@@ -25,6 +25,9 @@ type RepoData struct {
 	HarborUsername    *string
 	HarborPassword    *string
 	QuayToken         *string
+
+	InsecureRegistry      *bool
+	SkipTlsVerifyRegistry *bool
 }
 
 func (d *RepoData) GetContainerRegistry() string {
@@ -235,6 +238,54 @@ func SetupQuayTokenForRepoData(repoData *RepoData, cmd *cobra.Command, paramName
 	)
 }
 
+func SetupInsecureRegistryForRepoData(repoData *RepoData, cmd *cobra.Command, paramName string, paramEnvNames []string) {
+	repoData.InsecureRegistry = new(bool)
+	cmd.Flags().BoolVarP(
+		repoData.InsecureRegistry,
+		paramName,
+		"",
+		getBoolDefaultValueByParamEnvNames(paramEnvNames),
+		fmt.Sprintf("Use plain HTTP requests when accessing %s, HTTPS is tried first (default %s)", repoData.DesignationStorageName, strings.Join(getParamEnvNamesForUsageDescription(paramEnvNames), ", ")),
+	)
+}
+
+func SetupSkipTlsVerifyRegistryForRepoData(repoData *RepoData, cmd *cobra.Command, paramName string, paramEnvNames []string) {
+	repoData.SkipTlsVerifyRegistry = new(bool)
+	cmd.Flags().BoolVarP(
+		repoData.SkipTlsVerifyRegistry,
+		paramName,
+		"",
+		getBoolDefaultValueByParamEnvNames(paramEnvNames),
+		fmt.Sprintf("Skip TLS certificate validation when accessing %s (default %s)", repoData.DesignationStorageName, strings.Join(getParamEnvNamesForUsageDescription(paramEnvNames), ", ")),
+	)
+}
+
+// GetDockerRegistryOptions returns the registry options of the repo, the global --insecure-registry and --skip-tls-verify-registry options are applied to all repos.
+func (d *RepoData) GetDockerRegistryOptions(cmdData *CmdData) docker_registry.DockerRegistryOptions {
+	opts := docker_registry.DockerRegistryOptions{
+		InsecureRegistry:      *cmdData.InsecureRegistry,
+		SkipTlsVerifyRegistry: *cmdData.SkipTlsVerifyRegistry,
+	}
+
+	if d.InsecureRegistry != nil && *d.InsecureRegistry {
+		opts.InsecureRegistry = true
+	}
+	if d.SkipTlsVerifyRegistry != nil && *d.SkipTlsVerifyRegistry {
+		opts.SkipTlsVerifyRegistry = true
+	}
+
+	return opts
+}
+
+func getBoolDefaultValueByParamEnvNames(paramEnvNames []string) bool {
+	for _, paramEnvName := range paramEnvNames {
+		if GetBoolEnvironmentDefaultFalse(paramEnvName) {
+			return true
+		}
+	}
+	return false
+}
+
 func getDefaultValueByParamEnvNames(paramEnvNames []string) string {
 	var defaultValue string
 	for _, paramEnvName := range paramEnvNames {
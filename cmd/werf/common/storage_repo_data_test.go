@@ -0,0 +1,116 @@
+package common
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+func newRepoDataTestCmd() (*cobra.Command, *CmdData) {
+	cmd := &cobra.Command{}
+	cmdData := &CmdData{}
+
+	SetupInsecureRegistry(cmdData, cmd)
+	SetupSkipTlsVerifyRegistry(cmdData, cmd)
+	SetupCommonFinalRepoData(cmdData, cmd)
+	SetupSecondaryStagesStorageOptions(cmdData, cmd)
+	SetupCacheStagesStorageOptions(cmdData, cmd)
+
+	return cmd, cmdData
+}
+
+func TestRepoData_GetDockerRegistryOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name                                            string
+		args                                            []string
+		expectedFinal, expectedSecondary, expectedCache docker_registry.DockerRegistryOptions
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name:              "global options apply to all repos",
+			args:              []string{"--insecure-registry", "--skip-tls-verify-registry"},
+			expectedFinal:     docker_registry.DockerRegistryOptions{InsecureRegistry: true, SkipTlsVerifyRegistry: true},
+			expectedSecondary: docker_registry.DockerRegistryOptions{InsecureRegistry: true, SkipTlsVerifyRegistry: true},
+			expectedCache:     docker_registry.DockerRegistryOptions{InsecureRegistry: true, SkipTlsVerifyRegistry: true},
+		},
+		{
+			name:          "final repo options",
+			args:          []string{"--final-repo-insecure-registry", "--final-repo-skip-tls-verify-registry"},
+			expectedFinal: docker_registry.DockerRegistryOptions{InsecureRegistry: true, SkipTlsVerifyRegistry: true},
+		},
+		{
+			name:              "secondary repo options",
+			args:              []string{"--secondary-repo-insecure-registry"},
+			expectedSecondary: docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+		},
+		{
+			name:          "cache repo options",
+			args:          []string{"--cache-repo-skip-tls-verify-registry"},
+			expectedCache: docker_registry.DockerRegistryOptions{SkipTlsVerifyRegistry: true},
+		},
+		{
+			name:              "repo options do not disable global options",
+			args:              []string{"--insecure-registry", "--cache-repo-insecure-registry=false", "--secondary-repo-skip-tls-verify-registry"},
+			expectedFinal:     docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+			expectedSecondary: docker_registry.DockerRegistryOptions{InsecureRegistry: true, SkipTlsVerifyRegistry: true},
+			expectedCache:     docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, cmdData := newRepoDataTestCmd()
+			if err := cmd.ParseFlags(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, check := range []struct {
+				repoData *RepoData
+				expected docker_registry.DockerRegistryOptions
+			}{
+				{cmdData.CommonFinalRepoData, tc.expectedFinal},
+				{cmdData.SecondaryRepoData, tc.expectedSecondary},
+				{cmdData.CacheRepoData, tc.expectedCache},
+			} {
+				if got := check.repoData.GetDockerRegistryOptions(cmdData); got != check.expected {
+					t.Errorf("%s: expected %+v, got %+v", check.repoData.DesignationStorageName, check.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetupInsecureRegistryForRepoData_EnvDefault(t *testing.T) {
+	for _, name := range []string{"WERF_CACHE_REPO_INSECURE_REGISTRY", "WERF_FINAL_REPO_SKIP_TLS_VERIFY_REGISTRY"} {
+		oldValue, isSet := os.LookupEnv(name)
+		defer func(name string) {
+			if isSet {
+				_ = os.Setenv(name, oldValue)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}(name)
+
+		if err := os.Setenv(name, "true"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd, cmdData := newRepoDataTestCmd()
+	if err := cmd.ParseFlags(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := cmdData.CacheRepoData.GetDockerRegistryOptions(cmdData), (docker_registry.DockerRegistryOptions{InsecureRegistry: true}); got != expected {
+		t.Errorf("cache repos: expected %+v, got %+v", expected, got)
+	}
+	if got, expected := cmdData.CommonFinalRepoData.GetDockerRegistryOptions(cmdData), (docker_registry.DockerRegistryOptions{SkipTlsVerifyRegistry: true}); got != expected {
+		t.Errorf("final repo: expected %+v, got %+v", expected, got)
+	}
+	if got, expected := cmdData.SecondaryRepoData.GetDockerRegistryOptions(cmdData), (docker_registry.DockerRegistryOptions{}); got != expected {
+		t.Errorf("secondary repos: expected %+v, got %+v", expected, got)
+	}
+}
@@ -6,25 +6,11 @@ import (
 	"os"
 	"strings"
 
-	"github.com/werf/werf/pkg/werf/global_warnings"
-
-	"github.com/werf/werf/pkg/werf/locker_with_retry"
-
-	"github.com/werf/lockgate/pkg/distributed_locker"
-	"github.com/werf/logboek"
-
 	"github.com/spf13/cobra"
 
-	"k8s.io/client-go/dynamic"
-
-	"k8s.io/client-go/kubernetes"
-
-	"github.com/werf/kubedog/pkg/kube"
-
-	"github.com/werf/werf/pkg/storage/synchronization_server"
-
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/storage"
-	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
 func SetupSynchronization(cmdData *CmdData, cmd *cobra.Command) {
@@ -40,20 +26,36 @@ Default:
  - %s if --repo has been specified.
 
 The same address should be specified for all werf processes that work with a single repo. :local address allows execution of werf processes from a single host only`, storage.DefaultHttpSynchronizationServer))
-}
 
-type SynchronizationType string
+	cmdData.SynchronizationTLSCert = new(string)
+	cmdData.SynchronizationTLSKey = new(string)
+	cmdData.SynchronizationTLSCA = new(string)
 
-const (
-	LocalSynchronization      SynchronizationType = "LocalSynchronization"
-	KubernetesSynchronization SynchronizationType = "KubernetesSynchronization"
-	HttpSynchronization       SynchronizationType = "HttpSynchronization"
-)
+	cmd.Flags().StringVarP(cmdData.SynchronizationTLSCert, "synchronization-tls-cert", "", os.Getenv("WERF_SYNCHRONIZATION_TLS_CERT"), "Client certificate file to authenticate on the https synchronization server (default $WERF_SYNCHRONIZATION_TLS_CERT)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationTLSKey, "synchronization-tls-key", "", os.Getenv("WERF_SYNCHRONIZATION_TLS_KEY"), "Client certificate key file to authenticate on the https synchronization server (default $WERF_SYNCHRONIZATION_TLS_KEY)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationTLSCA, "synchronization-tls-ca", "", os.Getenv("WERF_SYNCHRONIZATION_TLS_CA"), "CA certificate file to verify the https synchronization server certificate (default $WERF_SYNCHRONIZATION_TLS_CA)")
+
+	cmdData.SynchronizationOIDCIssuer = new(string)
+	cmdData.SynchronizationOIDCClientID = new(string)
+	cmdData.SynchronizationOIDCClientSecret = new(string)
+	cmdData.SynchronizationOIDCScopes = new(string)
+
+	cmd.Flags().StringVarP(cmdData.SynchronizationOIDCIssuer, "synchronization-oidc-issuer", "", os.Getenv("WERF_SYNCHRONIZATION_OIDC_ISSUER"), `OIDC issuer URL to get the bearer token for the http synchronization server with the client credentials flow.
+The token is refreshed automatically before the expiration (default $WERF_SYNCHRONIZATION_OIDC_ISSUER)`)
+	cmd.Flags().StringVarP(cmdData.SynchronizationOIDCClientID, "synchronization-oidc-client-id", "", os.Getenv("WERF_SYNCHRONIZATION_OIDC_CLIENT_ID"), "OIDC client id to get the synchronization server bearer token (default $WERF_SYNCHRONIZATION_OIDC_CLIENT_ID)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationOIDCClientSecret, "synchronization-oidc-client-secret", "", os.Getenv("WERF_SYNCHRONIZATION_OIDC_CLIENT_SECRET"), "OIDC client secret to get the synchronization server bearer token (default $WERF_SYNCHRONIZATION_OIDC_CLIENT_SECRET)")
+	cmd.Flags().StringVarP(cmdData.SynchronizationOIDCScopes, "synchronization-oidc-scopes", "", os.Getenv("WERF_SYNCHRONIZATION_OIDC_SCOPES"), "Comma-separated OIDC scopes of the synchronization server bearer token (default $WERF_SYNCHRONIZATION_OIDC_SCOPES)")
 
-type SynchronizationParams struct {
-	Address             string
-	SynchronizationType SynchronizationType
-	KubeParams          *storage.KubernetesSynchronizationParams
+	cmdData.SynchronizationKubernetesLeases = new(bool)
+
+	cmd.Flags().BoolVarP(cmdData.SynchronizationKubernetesLeases, "synchronization-kubernetes-leases", "", GetBoolEnvironmentDefaultFalse("WERF_SYNCHRONIZATION_KUBERNETES_LEASES"), `Store locks of the kubernetes synchronization in the separate Lease objects and stages storage cache records in the ConfigMap keys changed by the merge patch.
+Reduces the API server load and the lock acquisition latency for large parallel builds.
+All werf processes working with the project should use the same value: the project ConfigMap is marked on the first use of the option and the processes without the option fail then (default $WERF_SYNCHRONIZATION_KUBERNETES_LEASES)`)
+
+	cmdData.SynchronizationToken = new(string)
+
+	cmd.Flags().StringVarP(cmdData.SynchronizationToken, "synchronization-token", "", os.Getenv("WERF_SYNCHRONIZATION_TOKEN"), `Token to authenticate on the http synchronization server started by the "werf synchronization" command with the --auth-config option.
+The token should be allowed to access the project (default $WERF_SYNCHRONIZATION_TOKEN)`)
 }
 
 func checkSynchronizationKubernetesParamsForWarnings(cmdData *CmdData) {
@@ -107,123 +109,50 @@ func checkSynchronizationKubernetesParamsForWarnings(cmdData *CmdData) {
 	}
 }
 
-func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName string, stagesStorage storage.StagesStorage) (*SynchronizationParams, error) {
-	getKubeParamsFunc := func(address string, commonKubeInitializer *OndemandKubeInitializer) (*SynchronizationParams, error) {
-		res := &SynchronizationParams{}
-		res.SynchronizationType = KubernetesSynchronization
-		res.Address = address
-
-		if params, err := storage.ParseKubernetesSynchronization(res.Address); err != nil {
-			return nil, fmt.Errorf("unable to parse synchronization address %s: %s", res.Address, err)
-		} else {
-			res.KubeParams = params
-		}
-
-		if res.KubeParams.ConfigPath == "" {
-			res.KubeParams.ConfigPath = commonKubeInitializer.KubeConfig
-		}
-		if res.KubeParams.ConfigContext == "" {
-			res.KubeParams.ConfigContext = commonKubeInitializer.KubeContext
-		}
-		if res.KubeParams.ConfigDataBase64 == "" {
-			res.KubeParams.ConfigDataBase64 = commonKubeInitializer.KubeConfigBase64
-		}
-		if res.KubeParams.ConfigPathMergeList == nil {
-			res.KubeParams.ConfigPathMergeList = commonKubeInitializer.KubeConfigPathMergeList
-		}
+func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName string, stagesStorage storage.StagesStorage) (*flows.SynchronizationParams, error) {
+	opts, err := getSynchronizationOptions(cmdData)
+	if err != nil {
+		return nil, err
+	}
 
-		return res, nil
+	return flows.GetSynchronization(ctx, projectName, stagesStorage, opts)
+}
 
+func getSynchronizationOptions(cmdData *CmdData) (flows.SynchronizationOptions, error) {
+	opts := flows.SynchronizationOptions{
+		Address:          *cmdData.Synchronization,
+		KubeLeases:       *cmdData.SynchronizationKubernetesLeases,
+		TLSCert:          *cmdData.SynchronizationTLSCert,
+		TLSKey:           *cmdData.SynchronizationTLSKey,
+		TLSCA:            *cmdData.SynchronizationTLSCA,
+		OIDCIssuer:       *cmdData.SynchronizationOIDCIssuer,
+		OIDCClientID:     *cmdData.SynchronizationOIDCClientID,
+		OIDCClientSecret: *cmdData.SynchronizationOIDCClientSecret,
+		Token:            *cmdData.SynchronizationToken,
+		ReadOnly:         IsRepoReadOnly(cmdData),
 	}
 
-	getHttpParamsFunc := func(synchronization string, stagesStorage storage.StagesStorage) (*SynchronizationParams, error) {
-		var address string
-		if err := logboek.Default().LogProcess(fmt.Sprintf("Getting client id for the http synchronization server")).
-			DoError(func() error {
-				if clientID, err := synchronization_server.GetOrCreateClientID(ctx, projectName, synchronization_server.NewSynchronizationClient(synchronization), stagesStorage); err != nil {
-					return fmt.Errorf("unable to get synchronization client id: %s", err)
-				} else {
-					address = fmt.Sprintf("%s/%s", synchronization, clientID)
-					logboek.Default().LogF("Using clientID %q for http synchronization server at address %s\n", clientID, address)
-					return nil
-				}
-			}); err != nil {
-			return nil, err
-		}
+	if kubeInitializer := GetOndemandKubeInitializer(); kubeInitializer != nil {
+		opts.KubeConfigOptions = kubeInitializer.KubeConfigOptions()
+	}
 
-		return &SynchronizationParams{Address: address, SynchronizationType: HttpSynchronization}, nil
+	if opts.OIDCIssuer != "" && opts.OIDCClientID == "" {
+		return flows.SynchronizationOptions{}, fmt.Errorf("--synchronization-oidc-client-id (or WERF_SYNCHRONIZATION_OIDC_CLIENT_ID env var) should be specified with --synchronization-oidc-issuer")
 	}
 
-	if *cmdData.Synchronization == "" {
-		if stagesStorage.Address() == storage.LocalStorageAddress {
-			return &SynchronizationParams{SynchronizationType: LocalSynchronization, Address: storage.LocalStorageAddress}, nil
-		} else {
-			return getHttpParamsFunc(storage.DefaultHttpSynchronizationServer, stagesStorage)
-		}
-	} else if *cmdData.Synchronization == storage.LocalStorageAddress {
-		return &SynchronizationParams{Address: *cmdData.Synchronization, SynchronizationType: LocalSynchronization}, nil
-	} else if strings.HasPrefix(*cmdData.Synchronization, "kubernetes://") {
-		checkSynchronizationKubernetesParamsForWarnings(cmdData)
-		return getKubeParamsFunc(*cmdData.Synchronization, GetOndemandKubeInitializer())
-	} else if strings.HasPrefix(*cmdData.Synchronization, "http://") || strings.HasPrefix(*cmdData.Synchronization, "https://") {
-		return getHttpParamsFunc(*cmdData.Synchronization, stagesStorage)
-	} else {
-		return nil, fmt.Errorf("only --synchronization=%s or --synchronization=kubernetes://NAMESPACE or --synchronization=http[s]://HOST:PORT/CLIENT_ID is supported, got %q", storage.LocalStorageAddress, *cmdData.Synchronization)
+	if opts.OIDCIssuer != "" && opts.Token != "" {
+		return flows.SynchronizationOptions{}, fmt.Errorf("--synchronization-token (or WERF_SYNCHRONIZATION_TOKEN env var) cannot be used with --synchronization-oidc-issuer")
 	}
-}
 
-func GetStagesStorageCache(synchronization *SynchronizationParams) (storage.StagesStorageCache, error) {
-	switch synchronization.SynchronizationType {
-	case LocalSynchronization:
-		return storage.NewFileStagesStorageCache(werf.GetStagesStorageCacheDir()), nil
-	case KubernetesSynchronization:
-		if config, err := kube.GetKubeConfig(kube.KubeConfigOptions{
-			ConfigPath:          synchronization.KubeParams.ConfigPath,
-			ConfigDataBase64:    synchronization.KubeParams.ConfigDataBase64,
-			ConfigPathMergeList: synchronization.KubeParams.ConfigPathMergeList,
-			Context:             synchronization.KubeParams.ConfigContext,
-		}); err != nil {
-			return nil, fmt.Errorf("unable to load synchronization kube config (context %q): %s", synchronization.KubeParams.ConfigContext, err)
-		} else if client, err := kubernetes.NewForConfig(config.Config); err != nil {
-			return nil, fmt.Errorf("unable to create synchronization kubernetes client: %s", err)
-		} else {
-			return storage.NewKubernetesStagesStorageCache(synchronization.KubeParams.Namespace, client, func(projectName string) string {
-				return fmt.Sprintf("werf-%s", projectName)
-			}), nil
+	for _, scope := range strings.Split(*cmdData.SynchronizationOIDCScopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			opts.OIDCScopes = append(opts.OIDCScopes, scope)
 		}
-	case HttpSynchronization:
-		return synchronization_server.NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/stages-storage-cache", synchronization.Address)), nil
-	default:
-		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
 	}
-}
 
-func GetStorageLockManager(ctx context.Context, synchronization *SynchronizationParams) (storage.LockManager, error) {
-	switch synchronization.SynchronizationType {
-	case LocalSynchronization:
-		return storage.NewGenericLockManager(werf.GetHostLocker()), nil
-	case KubernetesSynchronization:
-		if config, err := kube.GetKubeConfig(kube.KubeConfigOptions{
-			ConfigPath:          synchronization.KubeParams.ConfigPath,
-			ConfigDataBase64:    synchronization.KubeParams.ConfigDataBase64,
-			ConfigPathMergeList: synchronization.KubeParams.ConfigPathMergeList,
-			Context:             synchronization.KubeParams.ConfigContext,
-		}); err != nil {
-			return nil, fmt.Errorf("unable to load synchronization kube config %q (context %q): %s", synchronization.KubeParams.ConfigPath, synchronization.KubeParams.ConfigContext, err)
-		} else if dynamicClient, err := dynamic.NewForConfig(config.Config); err != nil {
-			return nil, fmt.Errorf("unable to create synchronization kubernetes dynamic client: %s", err)
-		} else if client, err := kubernetes.NewForConfig(config.Config); err != nil {
-			return nil, fmt.Errorf("unable to create synchronization kubernetes client: %s", err)
-		} else {
-			return storage.NewKubernetesLockManager(synchronization.KubeParams.Namespace, client, dynamicClient, func(projectName string) string {
-				return fmt.Sprintf("werf-%s", projectName)
-			}), nil
-		}
-	case HttpSynchronization:
-		locker := distributed_locker.NewHttpLocker(fmt.Sprintf("%s/locker", synchronization.Address))
-		lockerWithRetry := locker_with_retry.NewLockerWithRetry(ctx, locker, locker_with_retry.LockerWithRetryOptions{MaxAcquireAttempts: 10, MaxReleaseAttempts: 10})
-		return storage.NewGenericLockManager(lockerWithRetry), nil
-	default:
-		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
+	if strings.HasPrefix(opts.Address, "kubernetes://") {
+		checkSynchronizationKubernetesParamsForWarnings(cmdData)
 	}
+
+	return opts, nil
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -41,6 +42,7 @@ type newCmdOptions struct {
 type composeCmdData struct {
 	RawComposeOptions        string
 	RawComposeCommandOptions string
+	ComposeOverride          bool
 
 	WerfImagesToProcess []string
 
@@ -153,6 +155,16 @@ services:
     image: $WERF_FRONTEND_DOCKER_IMAGE_NAME
   backend:
     image: $WERF_GEODATA_BACKEND_DOCKER_IMAGE_NAME
+
+Instead of using the environment variables in the docker compose configuration, werf can generate the docker-compose.werf.yaml override file with the built images of the compose services and pass it to docker-compose after the base compose files. The services with the same names as the werf images are mapped automatically, other services can be mapped explicitly in werf.yaml. The override file is generated with --docker-compose-override option or when the services are mapped in werf.yaml:
+
+# werf.yaml
+project: x
+configVersion: 1
+compose:
+  services:
+    backend: geodata-backend
+    worker: geodata-backend
 `
 
 	long = common.GetLongCommandDescription(long)
@@ -208,13 +220,19 @@ services:
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupRepoReadOnly(&commonCmdData, cmd)
+	common.SetupRegistryMirrors(&commonCmdData, cmd)
 
 	common.SetupSkipBuild(&commonCmdData, cmd)
 
@@ -240,6 +258,7 @@ services:
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&commonCmdData, cmd)
 
 	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
 	common.SetupAllowedDockerStorageVolumeUsage(&commonCmdData, cmd)
@@ -249,9 +268,12 @@ services:
 	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
 
 	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
 
 	cmd.Flags().StringVarP(&cmdData.RawComposeOptions, "docker-compose-options", "", os.Getenv("WERF_DOCKER_COMPOSE_OPTIONS"), "Define docker-compose options (default $WERF_DOCKER_COMPOSE_OPTIONS)")
 	cmd.Flags().StringVarP(&cmdData.RawComposeCommandOptions, "docker-compose-command-options", "", os.Getenv("WERF_DOCKER_COMPOSE_COMMAND_OPTIONS"), "Define docker-compose command options (default $WERF_DOCKER_COMPOSE_COMMAND_OPTIONS)")
+	cmd.Flags().BoolVarP(&cmdData.ComposeOverride, "docker-compose-override", "", common.GetBoolEnvironmentDefaultFalse("WERF_DOCKER_COMPOSE_OVERRIDE"), "Generate docker-compose.werf.yaml override file with the built images of the compose services and pass it to docker-compose (default $WERF_DOCKER_COMPOSE_OVERRIDE or true if compose.services directive is specified in werf.yaml)")
 	cmd.Flags().StringVarP(&cmdData.ComposeBinPath, "docker-compose-bin-path", "", os.Getenv("WERF_DOCKER_COMPOSE_BIN_PATH"), "Define docker-compose bin path (default $WERF_DOCKER_COMPOSE_BIN_PATH)")
 
 	return cmd
@@ -294,10 +316,14 @@ func checkDetachDockerComposeOption(cmdData composeCmdData) error {
 func runMain(dockerComposeCmdName string, cmdData composeCmdData, commonCmdData common.CmdData, followSupport bool) error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -315,7 +341,12 @@ func runMain(dockerComposeCmdName string, cmdData composeCmdData, commonCmdData
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
@@ -323,6 +354,14 @@ func runMain(dockerComposeCmdName string, cmdData composeCmdData, commonCmdData
 		return err
 	}
 
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
 	ctxWithDockerCli, err := docker.NewContext(ctx)
 	if err != nil {
 		return err
@@ -356,6 +395,10 @@ func runMain(dockerComposeCmdName string, cmdData composeCmdData, commonCmdData
 		}
 	}()
 
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
 	if followSupport && *commonCmdData.Follow {
 		if err := checkDetachDockerComposeOption(cmdData); err != nil {
 			return err
@@ -381,11 +424,15 @@ func runMain(dockerComposeCmdName string, cmdData composeCmdData, commonCmdData
 }
 
 func run(ctx context.Context, giterminismManager giterminism_manager.Interface, commonCmdData common.CmdData, cmdData composeCmdData, dockerComposeCmdName string) error {
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.InitRegistryMirrors(&commonCmdData, werfConfig); err != nil {
+		return err
+	}
+
 	for _, imageToProcess := range cmdData.WerfImagesToProcess {
 		if !werfConfig.HasImageOrArtifact(imageToProcess) {
 			return fmt.Errorf("specified image %s is not found in werf.yaml", logging.ImageLogName(imageToProcess, false))
@@ -406,7 +453,7 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 	if err != nil {
 		return err
 	}
-	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+	finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &commonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -414,11 +461,11 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 	if err != nil {
 		return err
 	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 	if err != nil {
 		return err
 	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 	if err != nil {
 		return err
 	}
@@ -432,13 +479,16 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 	}
 
 	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+	if common.IsRepoReadOnly(&commonCmdData) {
+		storageManager.EnableReadOnly()
+	}
 
 	logboek.Context(ctx).Info().LogOptionalLn()
 
 	conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, cmdData.WerfImagesToProcess, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, common.GetConveyorOptions(&commonCmdData))
 	defer conveyorWithRetry.Terminate()
 
-	var envArray []string
+	var envArray, imagesNames []string
 	if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
 		if *commonCmdData.SkipBuild {
 			if err := c.ShouldBeBuilt(ctx); err != nil {
@@ -457,14 +507,30 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 		}
 
 		envArray = c.GetImagesEnvArray()
+		imagesNames = c.GetExportedImagesNames()
 
 		return nil
 	}); err != nil {
 		return err
 	}
 
+	composeOptions := cmdData.ComposeOptions
+
+	var override *composeOverride
+	if cmdData.ComposeOverride || len(werfConfig.Meta.Compose.Services) != 0 {
+		override, err = generateComposeOverride(composeOptions, werfConfig, imagesNames)
+		if err != nil {
+			return err
+		}
+
+		composeOptions, err = withComposeOverrideFile(composeOptions, override.Path)
+		if err != nil {
+			return err
+		}
+	}
+
 	var dockerComposeArgs []string
-	dockerComposeArgs = append(dockerComposeArgs, cmdData.ComposeOptions...)
+	dockerComposeArgs = append(dockerComposeArgs, composeOptions...)
 	dockerComposeArgs = append(dockerComposeArgs, dockerComposeCmdName)
 	dockerComposeArgs = append(dockerComposeArgs, cmdData.ComposeCommandOptions...)
 
@@ -478,9 +544,18 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 		for _, env := range envArray {
 			fmt.Println("export", env)
 		}
+		if override != nil {
+			fmt.Printf("cat > %s <<'EOF'\n%sEOF\n", override.Path, override.Content)
+		}
 		fmt.Printf("docker-compose %s\n", strings.Join(dockerComposeArgs, " "))
 		return nil
 	} else {
+		if override != nil {
+			if err := override.Write(); err != nil {
+				return err
+			}
+		}
+
 		cmd := exec.Command("docker-compose", dockerComposeArgs...)
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
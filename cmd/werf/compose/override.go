@@ -0,0 +1,182 @@
+package compose
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/util"
+)
+
+const composeOverrideFileName = "docker-compose.werf.yaml"
+
+var (
+	defaultComposeFileNames         = []string{"docker-compose.yml", "docker-compose.yaml"}
+	defaultComposeOverrideFileNames = []string{"docker-compose.override.yml", "docker-compose.override.yaml"}
+)
+
+type composeOverride struct {
+	Path    string
+	Content []byte
+}
+
+type composeFile struct {
+	Version  string                 `yaml:"version,omitempty"`
+	Services map[string]interface{} `yaml:"services,omitempty"`
+}
+
+type composeOverrideService struct {
+	Image string `yaml:"image"`
+}
+
+type composeOverrideFile struct {
+	Version  string                            `yaml:"version,omitempty"`
+	Services map[string]composeOverrideService `yaml:"services"`
+}
+
+// generateComposeOverride maps the compose services to the built werf images by the werf.yaml compose.services directive,
+// other services are mapped to the werf images with the same names.
+func generateComposeOverride(composeOptions []string, werfConfig *config.WerfConfig, imagesNames []string) (*composeOverride, error) {
+	composeFiles, err := getComposeFiles(composeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var version string
+	servicesImages := map[string]string{}
+	for _, path := range composeFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read compose file %s: %s", path, err)
+		}
+
+		var file composeFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("unable to parse compose file %s: %s", path, err)
+		}
+
+		if version == "" {
+			version = file.Version
+		}
+
+		for service := range file.Services {
+			if util.IsStringsContainValue(imagesNames, service) {
+				servicesImages[service] = service
+			}
+		}
+	}
+
+	for service, imageName := range werfConfig.Meta.Compose.Services {
+		if util.IsStringsContainValue(imagesNames, imageName) {
+			servicesImages[service] = imageName
+		} else {
+			delete(servicesImages, service)
+		}
+	}
+
+	if len(servicesImages) == 0 {
+		return nil, fmt.Errorf("no compose services found for the werf images %s: specify services with the compose.services directive of werf.yaml or name the services after the werf images", strings.Join(imagesNames, ", "))
+	}
+
+	override := composeOverrideFile{Version: version, Services: map[string]composeOverrideService{}}
+	for service, imageName := range servicesImages {
+		override.Services[service] = composeOverrideService{Image: fmt.Sprintf("${%s}", build.GetImageEnvName(imageName))}
+	}
+
+	data, err := yaml.Marshal(override)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal compose override: %s", err)
+	}
+
+	return &composeOverride{
+		Path:    filepath.Join(filepath.Dir(composeFiles[0]), composeOverrideFileName),
+		Content: append([]byte("# Generated by werf, do not edit\n"), data...),
+	}, nil
+}
+
+func (o *composeOverride) Write() error {
+	if err := ioutil.WriteFile(o.Path, o.Content, 0644); err != nil {
+		return fmt.Errorf("unable to write compose override file %s: %s", o.Path, err)
+	}
+
+	return nil
+}
+
+// getComposeFiles returns the files passed with the -f/--file docker-compose options,
+// the files from $COMPOSE_FILE or the default compose files from the working directory.
+func getComposeFiles(composeOptions []string) ([]string, error) {
+	var files []string
+	for ind, option := range composeOptions {
+		switch {
+		case option == "-f" || option == "--file":
+			if ind+1 < len(composeOptions) {
+				files = append(files, composeOptions[ind+1])
+			}
+		case strings.HasPrefix(option, "--file="):
+			files = append(files, strings.TrimPrefix(option, "--file="))
+		}
+	}
+
+	if len(files) != 0 {
+		return files, nil
+	}
+
+	if composeFileEnv := os.Getenv("COMPOSE_FILE"); composeFileEnv != "" {
+		separator := string(os.PathListSeparator)
+		if s := os.Getenv("COMPOSE_PATH_SEPARATOR"); s != "" {
+			separator = s
+		}
+
+		return strings.Split(composeFileEnv, separator), nil
+	}
+
+	for _, names := range [][]string{defaultComposeFileNames, defaultComposeOverrideFileNames} {
+		for _, name := range names {
+			if exist, err := util.RegularFileExists(name); err != nil {
+				return nil, fmt.Errorf("unable to check existence of %s: %s", name, err)
+			} else if exist {
+				files = append(files, name)
+				break
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("compose file not found: expected one of %s in the working directory or -f/--file option in --docker-compose-options", strings.Join(defaultComposeFileNames, ", "))
+	}
+
+	return files, nil
+}
+
+// withComposeOverrideFile returns docker-compose options with the override file added after the base compose files.
+func withComposeOverrideFile(composeOptions []string, overridePath string) ([]string, error) {
+	composeFiles, err := getComposeFiles(composeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, path := range composeFiles {
+		res = append(res, "-f", path)
+	}
+	res = append(res, "-f", overridePath)
+
+	for ind := 0; ind < len(composeOptions); ind++ {
+		option := composeOptions[ind]
+		switch {
+		case option == "-f" || option == "--file":
+			ind++
+		case strings.HasPrefix(option, "--file="):
+		default:
+			res = append(res, option)
+		}
+	}
+
+	return res, nil
+}
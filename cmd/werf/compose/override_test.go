@@ -0,0 +1,147 @@
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+func chdirForTest(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func writeComposeFileForTest(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetComposeFiles(t *testing.T) {
+	t.Setenv("COMPOSE_FILE", "")
+	t.Setenv("COMPOSE_PATH_SEPARATOR", "")
+
+	files, err := getComposeFiles([]string{"-f", "a.yml", "--file=b.yml", "--project-name", "app", "--file", "c.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a.yml", "b.yml", "c.yml"}; !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected files %v from the options, got %v", expected, files)
+	}
+
+	t.Setenv("COMPOSE_FILE", "a.yml,b.yml")
+	t.Setenv("COMPOSE_PATH_SEPARATOR", ",")
+	files, err = getComposeFiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a.yml", "b.yml"}; !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected files %v from $COMPOSE_FILE, got %v", expected, files)
+	}
+
+	t.Setenv("COMPOSE_FILE", "")
+	t.Setenv("COMPOSE_PATH_SEPARATOR", "")
+	chdirForTest(t, t.TempDir())
+
+	if _, err := getComposeFiles(nil); err == nil {
+		t.Fatal("expected error without compose files")
+	}
+
+	writeComposeFileForTest(t, "docker-compose.yaml", "services: {}\n")
+	writeComposeFileForTest(t, "docker-compose.override.yml", "services: {}\n")
+	files, err = getComposeFiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"docker-compose.yaml", "docker-compose.override.yml"}; !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected default files %v, got %v", expected, files)
+	}
+}
+
+func TestWithComposeOverrideFile(t *testing.T) {
+	t.Setenv("COMPOSE_FILE", "")
+
+	options, err := withComposeOverrideFile([]string{"--project-name", "app", "-f", "a.yml", "--file=b.yml"}, "docker-compose.werf.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"-f", "a.yml", "-f", "b.yml", "-f", "docker-compose.werf.yaml", "--project-name", "app"}
+	if !reflect.DeepEqual(options, expected) {
+		t.Fatalf("expected options %v, got %v", expected, options)
+	}
+}
+
+func TestGenerateComposeOverride(t *testing.T) {
+	t.Setenv("COMPOSE_FILE", "")
+
+	dir := t.TempDir()
+	composeFile := filepath.Join(dir, "docker-compose.yml")
+	writeComposeFileForTest(t, composeFile, `version: "3.8"
+services:
+  backend:
+    build: .
+  web:
+    image: nginx
+  db:
+    image: postgres
+`)
+
+	werfConfig := &config.WerfConfig{Meta: &config.Meta{Compose: config.MetaCompose{Services: map[string]string{"web": "frontend"}}}}
+
+	override, err := generateComposeOverride([]string{"-f", composeFile}, werfConfig, []string{"backend", "frontend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := filepath.Join(dir, composeOverrideFileName); override.Path != expected {
+		t.Fatalf("expected override path %q, got %q", expected, override.Path)
+	}
+
+	expectedContent := `# Generated by werf, do not edit
+version: "3.8"
+services:
+  backend:
+    image: ${WERF_BACKEND_DOCKER_IMAGE_NAME}
+  web:
+    image: ${WERF_FRONTEND_DOCKER_IMAGE_NAME}
+`
+	if string(override.Content) != expectedContent {
+		t.Fatalf("expected override content:\n%s\ngot:\n%s", expectedContent, override.Content)
+	}
+
+	if err := override.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(override.Path); err != nil {
+		t.Fatal(err)
+	} else if string(data) != expectedContent {
+		t.Fatalf("unexpected written override content:\n%s", data)
+	}
+}
+
+func TestGenerateComposeOverride_NoServices(t *testing.T) {
+	t.Setenv("COMPOSE_FILE", "")
+
+	composeFile := filepath.Join(t.TempDir(), "docker-compose.yml")
+	writeComposeFileForTest(t, composeFile, "services:\n  db:\n    image: postgres\n")
+
+	// the compose.services directive overrides the same name mapping
+	werfConfig := &config.WerfConfig{Meta: &config.Meta{Compose: config.MetaCompose{Services: map[string]string{"db": "other"}}}}
+
+	_, err := generateComposeOverride([]string{"-f", composeFile}, werfConfig, []string{"db"})
+	if err == nil || !strings.Contains(err.Error(), "no compose services found for the werf images db") {
+		t.Fatalf("expected no compose services error, got %v", err)
+	}
+}
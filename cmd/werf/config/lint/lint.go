@@ -0,0 +1,140 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/giterminism_manager/file_reader"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+var cmdData struct {
+	printSchema bool
+}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "lint",
+		DisableFlagsInUseLine: true,
+		Short:                 "Validate werf.yaml and giterminism config",
+		Long: common.GetLongCommandDescription(`Validate werf.yaml and giterminism config.
+
+werf.yaml is validated against the JSON schema (use --print-schema to get it), all unknown fields, deprecated directives and type errors are reported at once with the positions in the rendered werf.yaml (use werf config render to get it).
+
+werf-giterminism.yaml is validated first the same way, the unknown fields are reported as errors though werf ignores them on the config loading.`),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if cmdData.printSchema {
+				schema, err := config.GetWerfConfigJSONSchema()
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(string(schema))
+				return nil
+			}
+
+			return run()
+		},
+	}
+
+	cmd.Flags().BoolVarP(&cmdData.printSchema, "print-schema", "", false, "Print werf.yaml JSON schema and exit")
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.InitReadOnly(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := git_repo.Init(gitdata.GetHostGitDataManagerReadOnly()); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismConfigContent, err := common.ReadGiterminismConfig(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if giterminismConfigContent != nil {
+		if err := printLintIssues(file_reader.GiterminismConfigName, config.LintGiterminismConfig(giterminismConfigContent)); err != nil {
+			return err
+		}
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	customWerfConfigRelPath, err := common.GetCustomWerfConfigRelPath(giterminismManager, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	customWerfConfigTemplatesDirRelPath, err := common.GetCustomWerfConfigTemplatesDirRelPath(giterminismManager, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	configPath, issues, err := config.LintWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return err
+	}
+
+	if relPath, err := filepath.Rel(giterminismManager.ProjectDir(), configPath); err == nil {
+		configPath = relPath
+	}
+
+	return printLintIssues(configPath, issues)
+}
+
+func printLintIssues(configPath string, issues []*config.LintIssue) error {
+	var errorsNumber int
+	for _, issue := range issues {
+		if issue.Severity == config.LintIssueError {
+			errorsNumber++
+		}
+
+		fmt.Fprintln(os.Stderr, issue.Format(configPath))
+	}
+
+	if errorsNumber != 0 {
+		return fmt.Errorf("%s validation failed: %d error(s) found", configPath, errorsNumber)
+	}
+
+	return nil
+}
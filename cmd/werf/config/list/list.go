@@ -53,16 +53,11 @@ func NewCmd() *cobra.Command {
 func run() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.InitReadOnly(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
-	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting host git data manager: %s", err)
-	}
-
-	if err := git_repo.Init(gitDataManager); err != nil {
+	if err := git_repo.Init(gitdata.GetHostGitDataManagerReadOnly()); err != nil {
 		return err
 	}
 
@@ -75,7 +70,7 @@ func run() error {
 		return err
 	}
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(common.BackgroundContext(), &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
 	if err != nil {
 		return err
 	}
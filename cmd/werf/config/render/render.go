@@ -1,12 +1,17 @@
 package render
 
 import (
+	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/werf/logboek"
+
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/true_git"
@@ -28,16 +33,11 @@ func NewCmd() *cobra.Command {
 				return err
 			}
 
-			if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+			if err := werf.InitReadOnly(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 				return fmt.Errorf("initialization error: %s", err)
 			}
 
-			gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
-			if err != nil {
-				return fmt.Errorf("error getting host git data manager: %s", err)
-			}
-
-			if err := git_repo.Init(gitDataManager); err != nil {
+			if err := git_repo.Init(gitdata.GetHostGitDataManagerReadOnly()); err != nil {
 				return err
 			}
 
@@ -50,8 +50,18 @@ func NewCmd() *cobra.Command {
 				return err
 			}
 
+			if err := docker.InitConfig(*commonCmdData.DockerConfig); err != nil {
+				return err
+			}
+
 			configOpts := common.GetWerfConfigOptions(&commonCmdData, false)
 
+			// the rendered config is printed to stdout, so the dependencies resolving is logged to stderr
+			resolveDependencies := common.GetDependenciesResolver(&commonCmdData, giterminismManager)
+			configOpts.ResolveDependencies = func(ctx context.Context, dependencies []config.MetaDependency) (map[string]config.DependencyTemplateData, error) {
+				return resolveDependencies(logboek.NewContext(ctx, logboek.NewLogger(os.Stderr, os.Stderr)), dependencies)
+			}
+
 			customWerfConfigRelPath, err := common.GetCustomWerfConfigRelPath(giterminismManager, &commonCmdData)
 			if err != nil {
 				return err
@@ -62,7 +72,7 @@ func NewCmd() *cobra.Command {
 				return err
 			}
 
-			return config.RenderWerfConfig(common.BackgroundContext(), customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, args, giterminismManager, configOpts)
+			return config.RenderWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, args, giterminismManager, configOpts)
 		},
 	}
 
@@ -74,6 +84,9 @@ func NewCmd() *cobra.Command {
 
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
 
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read images from the specified repo to resolve the werf.yaml dependencies")
+
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 
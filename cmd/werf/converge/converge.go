@@ -3,25 +3,13 @@ package converge
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"time"
 
-	"helm.sh/helm/v3/pkg/postrender"
-
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/giterminism_manager"
 
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
-	"github.com/werf/werf/pkg/deploy/helm/command_helpers"
-	"github.com/werf/werf/pkg/deploy/helm/maintenance_helper"
-
-	cmd_helm "helm.sh/helm/v3/cmd/helm"
-	helm_v3 "helm.sh/helm/v3/cmd/helm"
-	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart"
-	"helm.sh/helm/v3/pkg/chart/loader"
+
 	"helm.sh/helm/v3/pkg/cli/values"
 
 	"github.com/spf13/cobra"
@@ -30,31 +18,36 @@ import (
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/cmd/werf/common"
-	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
-	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
-	"github.com/werf/werf/pkg/deploy/lock_manager"
 	"github.com/werf/werf/pkg/deploy/secrets_manager"
+	"github.com/werf/werf/pkg/dev_sync"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/events"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/ssh_agent"
 	"github.com/werf/werf/pkg/storage/lrumeta"
-	"github.com/werf/werf/pkg/storage/manager"
-	"github.com/werf/werf/pkg/tmp_manager"
 	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/werf"
 	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
-var cmdData struct {
-	Timeout      int
-	AutoRollback bool
+type command struct {
+	cmdData struct {
+		Timeout      int
+		AutoRollback bool
+		Canary       bool
+		Sync         bool
+	}
+	commonCmdData common.CmdData
 }
 
-var commonCmdData common.CmdData
-
 func NewCmd() *cobra.Command {
+	return (&command{}).newCmd()
+}
+
+func (c *command) newCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "converge",
 		Short: "Build and push images, then deploy application into Kubernetes",
@@ -72,11 +65,11 @@ werf converge --repo registry.mydomain.com/web --env production`,
 			common.CmdEnvAnno: common.EnvsDescription(common.WerfDebugAnsibleArgs, common.WerfSecretKey),
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := common.BackgroundContext()
+			ctx := common.GetContext(cmd)
 
 			defer global_warnings.PrintGlobalWarnings(ctx)
 
-			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+			if err := common.ProcessLogOptions(&c.commonCmdData); err != nil {
 				common.PrintHelp(cmd)
 				return err
 			}
@@ -84,94 +77,136 @@ werf converge --repo registry.mydomain.com/web --env production`,
 			common.LogVersion()
 
 			return common.LogRunningTime(func() error {
-				return runMain(ctx)
+				return c.runMain(ctx)
 			})
 		},
 	}
 
-	common.SetupDir(&commonCmdData, cmd)
-	common.SetupGitWorkTree(&commonCmdData, cmd)
-	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
-	common.SetupConfigPath(&commonCmdData, cmd)
-	common.SetupEnvironment(&commonCmdData, cmd)
-
-	common.SetupGiterminismOptions(&commonCmdData, cmd)
-
-	common.SetupTmpDir(&commonCmdData, cmd)
-	common.SetupHomeDir(&commonCmdData, cmd)
-	common.SetupSSHKey(&commonCmdData, cmd)
-
-	common.SetupIntrospectAfterError(&commonCmdData, cmd)
-	common.SetupIntrospectBeforeError(&commonCmdData, cmd)
-	common.SetupIntrospectStage(&commonCmdData, cmd)
-
-	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupStagesStorageOptions(&commonCmdData, cmd)
-	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
-
-	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo, to pull base images")
-	common.SetupInsecureRegistry(&commonCmdData, cmd)
-	common.SetupInsecureHelmDependencies(&commonCmdData, cmd)
-	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
-
-	common.SetupLogOptions(&commonCmdData, cmd)
-	common.SetupLogProjectDir(&commonCmdData, cmd)
-
-	common.SetupSynchronization(&commonCmdData, cmd)
-
-	common.SetupKubeConfig(&commonCmdData, cmd)
-	common.SetupKubeConfigBase64(&commonCmdData, cmd)
-	common.SetupKubeContext(&commonCmdData, cmd)
-
-	common.SetupStatusProgressPeriod(&commonCmdData, cmd)
-	common.SetupHooksStatusProgressPeriod(&commonCmdData, cmd)
-	common.SetupReleasesHistoryMax(&commonCmdData, cmd)
-
-	common.SetupRelease(&commonCmdData, cmd)
-	common.SetupNamespace(&commonCmdData, cmd)
-	common.SetupAddAnnotations(&commonCmdData, cmd)
-	common.SetupAddLabels(&commonCmdData, cmd)
-
-	common.SetupSetDockerConfigJsonValue(&commonCmdData, cmd)
-	common.SetupSet(&commonCmdData, cmd)
-	common.SetupSetString(&commonCmdData, cmd)
-	common.SetupSetFile(&commonCmdData, cmd)
-	common.SetupValues(&commonCmdData, cmd)
-	common.SetupSecretValues(&commonCmdData, cmd)
-	common.SetupIgnoreSecretKey(&commonCmdData, cmd)
-
-	common.SetupReportPath(&commonCmdData, cmd)
-	common.SetupReportFormat(&commonCmdData, cmd)
-
-	common.SetupVirtualMerge(&commonCmdData, cmd)
-	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
-	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
-
-	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
-	common.SetupSkipBuild(&commonCmdData, cmd)
-	common.SetupPlatform(&commonCmdData, cmd)
-	common.SetupFollow(&commonCmdData, cmd)
-
-	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
-	common.SetupAllowedDockerStorageVolumeUsage(&commonCmdData, cmd)
-	common.SetupAllowedDockerStorageVolumeUsageMargin(&commonCmdData, cmd)
-	common.SetupAllowedLocalCacheVolumeUsage(&commonCmdData, cmd)
-	common.SetupAllowedLocalCacheVolumeUsageMargin(&commonCmdData, cmd)
-	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
-
-	cmd.Flags().IntVarP(&cmdData.Timeout, "timeout", "t", 0, "Resources tracking timeout in seconds")
-	cmd.Flags().BoolVarP(&cmdData.AutoRollback, "auto-rollback", "R", common.GetBoolEnvironmentDefaultFalse("WERF_AUTO_ROLLBACK"), "Enable auto rollback of the failed release to the previous deployed release version when current deploy process have failed ($WERF_AUTO_ROLLBACK by default)")
-	cmd.Flags().BoolVarP(&cmdData.AutoRollback, "atomic", "", common.GetBoolEnvironmentDefaultFalse("WERF_ATOMIC"), "Enable auto rollback of the failed release to the previous deployed release version when current deploy process have failed ($WERF_ATOMIC by default)")
+	common.SetupDir(&c.commonCmdData, cmd)
+	common.SetupGitWorkTree(&c.commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&c.commonCmdData, cmd)
+	common.SetupConfigPath(&c.commonCmdData, cmd)
+	common.SetupEnvironment(&c.commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&c.commonCmdData, cmd)
+
+	common.SetupTmpDir(&c.commonCmdData, cmd)
+	common.SetupTmpDirQuota(&c.commonCmdData, cmd)
+	common.SetupHomeDir(&c.commonCmdData, cmd)
+	common.SetupSSHKey(&c.commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&c.commonCmdData, cmd)
+	common.SetupGitSubmodules(&c.commonCmdData, cmd)
+	common.SetupGitSharedObjects(&c.commonCmdData, cmd)
+	common.SetupDockerfileSecrets(&c.commonCmdData, cmd)
+
+	common.SetupIntrospectAfterError(&c.commonCmdData, cmd)
+	common.SetupIntrospectBeforeError(&c.commonCmdData, cmd)
+	common.SetupIntrospectShell(&c.commonCmdData, cmd)
+	common.SetupKeepFailedStageContainer(&c.commonCmdData, cmd)
+	common.SetupIntrospectStage(&c.commonCmdData, cmd)
+
+	common.SetupSecondaryStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupCacheStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupFinalStagesStorageOptions(&c.commonCmdData, cmd)
+	common.SetupRepoReadOnly(&c.commonCmdData, cmd)
+	common.SetupRegistryMirrors(&c.commonCmdData, cmd)
+
+	common.SetupDockerConfig(&c.commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo, to pull base images")
+	common.SetupInsecureRegistry(&c.commonCmdData, cmd)
+	common.SetupInsecureHelmDependencies(&c.commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&c.commonCmdData, cmd)
+
+	common.SetupLogOptions(&c.commonCmdData, cmd)
+	common.SetupLogProjectDir(&c.commonCmdData, cmd)
+
+	common.SetupSynchronization(&c.commonCmdData, cmd)
+
+	common.SetupKubeConfig(&c.commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&c.commonCmdData, cmd)
+	common.SetupKubeContext(&c.commonCmdData, cmd)
+
+	common.SetupStatusProgressPeriod(&c.commonCmdData, cmd)
+	common.SetupHooksStatusProgressPeriod(&c.commonCmdData, cmd)
+	common.SetupReleasesHistoryMax(&c.commonCmdData, cmd)
+
+	common.SetupRelease(&c.commonCmdData, cmd)
+	common.SetupNamespace(&c.commonCmdData, cmd)
+	common.SetupAddAnnotations(&c.commonCmdData, cmd)
+	common.SetupAddLabels(&c.commonCmdData, cmd)
+	common.SetupPostRenderer(&c.commonCmdData, cmd)
+
+	common.SetupSetDockerConfigJsonValue(&c.commonCmdData, cmd)
+	common.SetupImagePullSecret(&c.commonCmdData, cmd, true)
+	common.SetupSet(&c.commonCmdData, cmd)
+	common.SetupSetString(&c.commonCmdData, cmd)
+	common.SetupSetJson(&c.commonCmdData, cmd)
+	common.SetupSetLiteral(&c.commonCmdData, cmd)
+	common.SetupSetFile(&c.commonCmdData, cmd)
+	common.SetupValues(&c.commonCmdData, cmd)
+	common.SetupSecretValues(&c.commonCmdData, cmd)
+	common.SetupIgnoreSecretKey(&c.commonCmdData, cmd)
+
+	common.SetupReportPath(&c.commonCmdData, cmd)
+	common.SetupReportFormat(&c.commonCmdData, cmd)
+	common.SetupReportSupplyChainPath(&c.commonCmdData, cmd)
+	common.SetupEventsPath(&c.commonCmdData, cmd)
+	common.SetupScanOptions(&c.commonCmdData, cmd)
+	common.SetupStageLogsOptions(&c.commonCmdData, cmd)
+
+	common.SetupVirtualMerge(&c.commonCmdData, cmd)
+	common.SetupVirtualMergeFromCommit(&c.commonCmdData, cmd)
+	common.SetupVirtualMergeIntoCommit(&c.commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&c.commonCmdData, cmd)
+
+	common.SetupParallelOptions(&c.commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
+	common.SetupSkipBuild(&c.commonCmdData, cmd)
+	common.SetupChangedOnly(&c.commonCmdData, cmd)
+	common.SetupPlatform(&c.commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&c.commonCmdData, cmd)
+	common.SetupRemoteBuilder(&c.commonCmdData, cmd)
+	common.SetupFollow(&c.commonCmdData, cmd)
+
+	common.SetupDisableAutoHostCleanup(&c.commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsage(&c.commonCmdData, cmd)
+	common.SetupAllowedDockerStorageVolumeUsageMargin(&c.commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsage(&c.commonCmdData, cmd)
+	common.SetupAllowedLocalCacheVolumeUsageMargin(&c.commonCmdData, cmd)
+	common.SetupDockerServerStoragePath(&c.commonCmdData, cmd)
+
+	cmd.Flags().IntVarP(&c.cmdData.Timeout, "timeout", "t", 0, "Resources tracking timeout in seconds")
+	cmd.Flags().BoolVarP(&c.cmdData.AutoRollback, "auto-rollback", "R", common.GetBoolEnvironmentDefaultFalse("WERF_AUTO_ROLLBACK"), "Enable auto rollback of the failed release to the previous deployed release version when current deploy process have failed ($WERF_AUTO_ROLLBACK by default)")
+	cmd.Flags().BoolVarP(&c.cmdData.AutoRollback, "atomic", "", common.GetBoolEnvironmentDefaultFalse("WERF_ATOMIC"), "Enable auto rollback of the failed release to the previous deployed release version when current deploy process have failed ($WERF_ATOMIC by default)")
+	cmd.Flags().BoolVarP(&c.cmdData.Sync, "sync", "", common.GetBoolEnvironmentDefaultFalse("WERF_SYNC"), `After the deploy, keep syncing the changed project files into the running containers of the workloads annotated with werf.io/dev-sync until interrupted ($WERF_SYNC by default).
+Requires development mode (--dev) and cannot be used with --follow`)
+	cmd.Flags().BoolVarP(&c.cmdData.Canary, "canary", "", common.GetBoolEnvironmentDefaultFalse("WERF_CANARY"), "Deploy and analyse the canary variant of the Deployments annotated with werf.io/canary=true before updating the release: the failed canary is rolled back and the release is not updated, the release is rolled back if its update fails after the canary is promoted ($WERF_CANARY by default)")
 
 	return cmd
 }
 
-func runMain(ctx context.Context) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+func (c *command) runMain(ctx context.Context) error {
+	if c.cmdData.Sync {
+		if !*c.commonCmdData.Dev {
+			return fmt.Errorf("--sync requires development mode (--dev)")
+		}
+		if *c.commonCmdData.Follow {
+			return fmt.Errorf("--sync cannot be used with --follow")
+		}
+	}
+
+	if err := werf.Init(*c.commonCmdData.TmpDir, *c.commonCmdData.HomeDir, *c.commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&c.commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitEvents(&c.commonCmdData); err != nil {
+		return err
+	}
+	defer events.Close()
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -189,11 +224,24 @@ func runMain(ctx context.Context) error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&c.commonCmdData)
+	if err != nil {
 		return err
 	}
 
-	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+	if err := true_git.Init(gitOptions); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *c.commonCmdData.DockerConfig, *c.commonCmdData.LogVerbose, *c.commonCmdData.LogDebug, *c.commonCmdData.Platform); err != nil {
+		return err
+	}
+
+	if err := common.InitDockerfileBuilder(&c.commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &c.commonCmdData); err != nil {
 		return err
 	}
 
@@ -203,24 +251,17 @@ func runMain(ctx context.Context) error {
 	}
 	ctx = ctxWithDockerCli
 
-	if err := common.DockerRegistryInit(ctxWithDockerCli, &commonCmdData); err != nil {
+	if err := common.DockerRegistryInit(ctxWithDockerCli, &c.commonCmdData); err != nil {
 		return err
 	}
 
 	defer func() {
-		if err := common.RunAutoHostCleanup(ctx, &commonCmdData); err != nil {
+		if err := common.RunAutoHostCleanup(ctx, &c.commonCmdData); err != nil {
 			logboek.Context(ctx).Error().LogF("Auto host cleanup failed: %s\n", err)
 		}
 	}()
 
-	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
-	if err != nil {
-		return err
-	}
-
-	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
-
-	if err := ssh_agent.Init(ctx, common.GetSSHKey(&commonCmdData)); err != nil {
+	if err := ssh_agent.Init(ctx, common.GetSSHKey(&c.commonCmdData)); err != nil {
 		return fmt.Errorf("cannot initialize ssh agent: %s", err)
 	}
 	defer func() {
@@ -230,41 +271,48 @@ func runMain(ctx context.Context) error {
 		}
 	}()
 
-	common.SetupOndemandKubeInitializer(*commonCmdData.KubeContext, *commonCmdData.KubeConfig, *commonCmdData.KubeConfigBase64, *commonCmdData.KubeConfigPathMergeList)
+	giterminismManager, err := common.GetGiterminismManager(&c.commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&c.commonCmdData, giterminismManager.ProjectDir())
+
+	if err := common.InitSSHKnownHosts(ctx, &c.commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	common.SetupOndemandKubeInitializer(*c.commonCmdData.KubeContext, *c.commonCmdData.KubeConfig, *c.commonCmdData.KubeConfigBase64, *c.commonCmdData.KubeConfigPathMergeList)
 	if err := common.GetOndemandKubeInitializer().Init(ctx); err != nil {
 		return err
 	}
 
-	if *commonCmdData.Follow {
+	if *c.commonCmdData.Follow {
 		logboek.LogOptionalLn()
-		return common.FollowGitHead(ctx, &commonCmdData, func(ctx context.Context, headCommitGiterminismManager giterminism_manager.Interface) error {
-			return run(ctx, headCommitGiterminismManager)
+		return common.FollowGitHead(ctx, &c.commonCmdData, func(ctx context.Context, headCommitGiterminismManager giterminism_manager.Interface) error {
+			return c.run(ctx, headCommitGiterminismManager)
 		})
 	} else {
-		return run(ctx, giterminismManager)
+		return c.run(ctx, giterminismManager)
 	}
 }
 
-func run(ctx context.Context, giterminismManager giterminism_manager.Interface) error {
-	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+func (c *command) run(ctx context.Context, giterminismManager giterminism_manager.Interface) error {
+	werfConfigPath, werfConfig, dependencies, err := common.GetRequiredWerfConfigWithDependencies(ctx, &c.commonCmdData, giterminismManager, common.GetWerfConfigOptions(&c.commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
-	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
-	if err != nil {
-		return fmt.Errorf("getting helm chart dir failed: %s", err)
+	if err := common.InitRegistryMirrors(&c.commonCmdData, werfConfig); err != nil {
+		return err
 	}
 
-	projectName := werfConfig.Meta.Project
-
-	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	chartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
 	if err != nil {
-		return fmt.Errorf("getting project tmp dir failed: %s", err)
+		return fmt.Errorf("getting helm chart dir failed: %s", err)
 	}
-	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
-	buildOptions, err := common.GetBuildOptions(&commonCmdData, werfConfig)
+	buildOptions, err := common.GetBuildOptions(&c.commonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -272,293 +320,150 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 	var imagesInfoGetters []*image.InfoGetter
 	var imagesRepository string
 	if len(werfConfig.StapelImages) != 0 || len(werfConfig.ImagesFromDockerfile) != 0 {
-		stagesStorageAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+		stagesStorageAddress, err := common.GetStagesStorageAddress(&c.commonCmdData)
 		if err != nil {
 			return err
 		}
 		containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
-		stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+		stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &c.commonCmdData)
 		if err != nil {
 			return err
 		}
-		finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+		finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &c.commonCmdData, werfConfig)
 		if err != nil {
 			return err
 		}
 		logboek.LogOptionalLn()
-		synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
-		if err != nil {
-			return err
-		}
-		stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
-		if err != nil {
-			return err
-		}
-		storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
-		if err != nil {
-			return err
-		}
-		secondaryStagesStorageList, err := common.GetSecondaryStagesStorageList(stagesStorage, containerRuntime, &commonCmdData)
-		if err != nil {
-			return err
-		}
-		cacheStagesStorageList, err := common.GetCacheStagesStorageList(containerRuntime, &commonCmdData)
+		storageOptions, err := common.GetStorageOptions(&c.commonCmdData, stagesStorage, finalStagesStorage, containerRuntime)
 		if err != nil {
 			return err
 		}
 
-		storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+		imagesRepository = stagesStorage.String()
 
-		imagesRepository = storageManager.StagesStorage.String()
-
-		conveyorOptions, err := common.GetConveyorOptionsWithParallel(&commonCmdData, buildOptions)
+		conveyorOptions, err := common.GetConveyorOptionsWithParallel(&c.commonCmdData, buildOptions)
 		if err != nil {
 			return err
 		}
 
-		conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, nil, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, conveyorOptions)
-		defer conveyorWithRetry.Terminate()
-
-		if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
-			if *commonCmdData.SkipBuild {
-				if err := c.ShouldBeBuilt(ctx); err != nil {
-					return err
-				}
-			} else {
-				if err := c.Build(ctx, buildOptions); err != nil {
-					return err
-				}
-			}
-
-			imagesInfoGetters = c.GetImageInfoGetters()
+		conveyorOptions.ChangedOnly = *c.commonCmdData.ChangedOnly
 
-			return nil
-		}); err != nil {
+		imagesInfoGetters, err = flows.Build(ctx, giterminismManager, werfConfig, flows.BuildOptions{
+			ContainerRuntime: containerRuntime,
+			Storage:          storageOptions,
+			ConveyorOptions:  conveyorOptions,
+			BuildOptions:     buildOptions,
+			SkipBuild:        *c.commonCmdData.SkipBuild,
+		})
+		if err != nil {
 			return err
 		}
 
 		logboek.LogOptionalLn()
 	}
 
-	secretsManager := secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{DisableSecretsDecryption: *commonCmdData.IgnoreSecretKey})
-
-	releaseName, err := common.GetHelmRelease(*commonCmdData.Release, *commonCmdData.Environment, werfConfig)
+	releaseName, err := flows.GetHelmRelease(*c.commonCmdData.Release, *c.commonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
 
-	namespace, err := common.GetKubernetesNamespace(*commonCmdData.Namespace, *commonCmdData.Environment, werfConfig)
+	targets, err := flows.GetDeployTargets(*c.commonCmdData.Namespace, *c.commonCmdData.KubeContext, *c.commonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
 
-	kubeConfigOptions := kube.KubeConfigOptions{
-		Context:          *commonCmdData.KubeContext,
-		ConfigPath:       *commonCmdData.KubeConfig,
-		ConfigDataBase64: *commonCmdData.KubeConfigBase64,
+	if c.cmdData.Sync && len(werfConfig.Meta.Deploy.Targets) != 0 {
+		return fmt.Errorf("--sync cannot be used with the deploy targets")
 	}
 
-	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
+	userExtraAnnotations, err := common.GetUserExtraAnnotationsWithWerfConfig(&c.commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
 
-	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
+	userExtraLabels, err := common.GetUserExtraLabelsWithWerfConfig(&c.commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
 
-	var lockManager *lock_manager.LockManager
-	if m, err := lock_manager.NewLockManager(namespace); err != nil {
-		return fmt.Errorf("unable to create lock manager: %s", err)
-	} else {
-		lockManager = m
-	}
-
-	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&c.commonCmdData), common.GetSetLiteral(&c.commonCmdData))
 	if err != nil {
-		return fmt.Errorf("unable to create helm registry client: %s", err)
-	}
-
-	wc := chart_extender.NewWerfChart(ctx, giterminismManager, secretsManager, chartDir, cmd_helm.Settings, registryClientHandle, chart_extender.WerfChartOptions{
-		SecretValueFiles: common.GetSecretValues(&commonCmdData),
-		ExtraAnnotations: userExtraAnnotations,
-		ExtraLabels:      userExtraLabels,
-	})
-
-	if err := wc.SetEnv(*commonCmdData.Environment); err != nil {
 		return err
 	}
-	if err := wc.SetWerfConfig(werfConfig); err != nil {
-		return err
-	}
-
-	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{
-		Namespace:                namespace,
-		Env:                      *commonCmdData.Environment,
-		SetDockerConfigJsonValue: *commonCmdData.SetDockerConfigJsonValue,
-		DockerConfigPath:         *commonCmdData.DockerConfig,
-	}); err != nil {
-		return fmt.Errorf("error creating service values: %s", err)
-	} else {
-		wc.SetServiceValues(vals)
-	}
-
-	loader.GlobalLoadOptions = &loader.LoadOptions{
-		ChartExtender:               wc,
-		SubchartExtenderFactoryFunc: func() chart.ChartExtender { return chart_extender.NewWerfSubchart() },
-	}
-
-	valueOpts := &values.Options{
-		ValueFiles:   common.GetValues(&commonCmdData),
-		StringValues: common.GetSetString(&commonCmdData),
-		Values:       common.GetSet(&commonCmdData),
-		FileValues:   common.GetSetFile(&commonCmdData),
-	}
 
-	postRenderer, err := wc.GetPostRenderer()
+	userPostRenderers, err := common.GetUserPostRenderers(ctx, &c.commonCmdData, giterminismManager)
 	if err != nil {
 		return err
 	}
 
-	actionConfig, err := common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle)
-	if err != nil {
-		return err
-	}
-	maintenanceHelper := createMaintenanceHelper(ctx, actionConfig, kubeConfigOptions)
-
-	if err := migrateHelm2ToHelm3(ctx, releaseName, namespace, maintenanceHelper, postRenderer, valueOpts, filepath.Join(giterminismManager.ProjectDir(), chartDir), registryClientHandle); err != nil {
-		return err
-	}
-
-	actionConfig, err = common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle)
-	if err != nil {
+	if err := flows.Deploy(ctx, giterminismManager, werfConfig, targets, flows.DeployOptions{
+		ChartDir:                   chartDir,
+		ReleaseName:                releaseName,
+		Env:                        *c.commonCmdData.Environment,
+		ImagesRepository:           imagesRepository,
+		ImagesInfoGetters:          imagesInfoGetters,
+		Dependencies:               dependencies,
+		SecretsManager:             secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{DisableSecretsDecryption: *c.commonCmdData.IgnoreSecretKey}),
+		SecretValueFiles:           common.GetSecretValues(&c.commonCmdData),
+		ExtraAnnotations:           userExtraAnnotations,
+		ExtraLabels:                userExtraLabels,
+		CommandLineValues:          commandLineValues,
+		ValuesOptions:              values.Options{ValueFiles: common.GetValues(&c.commonCmdData), StringValues: common.GetSetString(&c.commonCmdData), Values: common.GetSet(&c.commonCmdData), FileValues: common.GetSetFile(&c.commonCmdData)},
+		ImagePullSecret:            *c.commonCmdData.ImagePullSecret,
+		DockerConfigJsonPullTokens: *c.commonCmdData.DockerConfigJsonPullTokens,
+		SetDockerConfigJsonValue:   *c.commonCmdData.SetDockerConfigJsonValue,
+		DockerConfigPath:           *c.commonCmdData.DockerConfig,
+		PostRenderers:              userPostRenderers,
+		KubeConfigOptions:          common.GetOndemandKubeInitializer().KubeConfigOptions(),
+		KubeInitializer:            common.GetOndemandKubeInitializer(),
+		InsecureHelmDependencies:   *c.commonCmdData.InsecureHelmDependencies,
+		StatusProgressPeriod:       time.Duration(*c.commonCmdData.StatusProgressPeriodSeconds) * time.Second,
+		HooksStatusProgressPeriod:  time.Duration(*c.commonCmdData.HooksStatusProgressPeriodSeconds) * time.Second,
+		ReleasesHistoryMax:         *c.commonCmdData.ReleasesHistoryMax,
+		Timeout:                    time.Duration(c.cmdData.Timeout) * time.Second,
+		AutoRollback:               c.cmdData.AutoRollback,
+		Canary:                     c.cmdData.Canary,
+	}); err != nil {
 		return err
 	}
 
-	helmUpgradeCmd, _ := cmd_helm.NewUpgradeCmd(actionConfig, logboek.OutStream(), cmd_helm.UpgradeCmdOptions{
-		PostRenderer:    postRenderer,
-		ValueOpts:       valueOpts,
-		CreateNamespace: common.NewBool(true),
-		Install:         common.NewBool(true),
-		Wait:            common.NewBool(true),
-		Atomic:          common.NewBool(cmdData.AutoRollback),
-		Timeout:         common.NewDuration(time.Duration(cmdData.Timeout) * time.Second),
-	})
-
-	return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
-		if err := helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, filepath.Join(giterminismManager.ProjectDir(), chartDir)}); err != nil {
-			return fmt.Errorf("helm upgrade have failed: %s", err)
-		}
-		return nil
-	})
-}
-
-func createMaintenanceHelper(ctx context.Context, actionConfig *action.Configuration, kubeConfigOptions kube.KubeConfigOptions) *maintenance_helper.MaintenanceHelper {
-	maintenanceOpts := maintenance_helper.MaintenanceHelperOptions{
-		KubeConfigOptions: kubeConfigOptions,
-	}
-
-	for _, val := range []string{
-		os.Getenv("WERF_HELM2_RELEASE_STORAGE_NAMESPACE"),
-		os.Getenv("WERF_HELM_RELEASE_STORAGE_NAMESPACE"),
-		os.Getenv("TILLER_NAMESPACE"),
-	} {
-		if val != "" {
-			maintenanceOpts.Helm2ReleaseStorageNamespace = val
-			break
-		}
+	if c.cmdData.Sync {
+		return c.runDevSync(ctx, targets[0], giterminismManager, releaseName)
 	}
 
-	for _, val := range []string{
-		os.Getenv("WERF_HELM2_RELEASE_STORAGE_TYPE"),
-		os.Getenv("WERF_HELM_RELEASE_STORAGE_TYPE"),
-	} {
-		if val != "" {
-			maintenanceOpts.Helm2ReleaseStorageType = val
-			break
-		}
-	}
-
-	return maintenance_helper.NewMaintenanceHelper(actionConfig, maintenanceOpts)
+	return nil
 }
 
-func migrateHelm2ToHelm3(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper, postRenderer postrender.PostRenderer, valueOpts *values.Options, fullChartDir string, registryClientHandle *helm_v3.RegistryClientHandle) error {
-	if helm2Exists, err := checkHelm2AvailableAndReleaseExists(ctx, releaseName, namespace, maintenanceHelper); err != nil {
-		return fmt.Errorf("error checking availability of helm 2 and existance of helm 2 release %q: %s", releaseName, err)
-	} else if !helm2Exists {
-		return nil
-	}
-
-	if helm3Exists, err := checkHelm3ReleaseExists(ctx, releaseName, namespace, maintenanceHelper); err != nil {
-		return fmt.Errorf("error checking existance of helm 3 release %q: %s", releaseName, err)
-	} else if helm3Exists {
-		// helm 2 exists and helm 3 exists
-		// migration not needed, but we should warn user that some helm 2 release with the same name exists
-
-		logboek.Context(ctx).Warn().LogF("### Helm 2 and helm 3 release %q exists at the same time ###\n", releaseName)
-		logboek.Context(ctx).Warn().LogLn()
-		logboek.Context(ctx).Warn().LogF("Found existing helm 2 release %q while there is existing helm 3 release %q in the %q namespace!\n", releaseName, releaseName, namespace)
-		logboek.Context(ctx).Warn().LogF("werf will continue deploy process into helm 3 release %q in the %q namespace\n", releaseName, namespace)
-		logboek.Context(ctx).Warn().LogF("To disable this warning please remove old helm 2 release %q metadata (fox example using: kubectl -n kube-system delete cm RELEASE_NAME.VERSION)\n", releaseName)
-		logboek.Context(ctx).Warn().LogLn()
-
-		return nil
+// runDevSync syncs the changed project files into the workloads of the deployed release annotated with werf.io/dev-sync until interrupted.
+func (c *command) runDevSync(ctx context.Context, target *flows.DeployTarget, giterminismManager giterminism_manager.Interface, releaseName string) error {
+	kubeConfig, err := kube.GetKubeConfig(kube.KubeConfigOptions{
+		Context:             target.KubeContext,
+		ConfigPath:          *c.commonCmdData.KubeConfig,
+		ConfigDataBase64:    *c.commonCmdData.KubeConfigBase64,
+		ConfigPathMergeList: *c.commonCmdData.KubeConfigPathMergeList,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to load kube config: %s", err)
 	}
 
-	logboek.Context(ctx).Warn().LogFDetails("Found existing helm 2 release %q, will try to render helm 3 templates and migrate existing release resources to helm 3\n", releaseName)
-
-	logboek.Context(ctx).Default().LogOptionalLn()
-	if err := logboek.Context(ctx).LogProcess("Rendering helm 3 templates for the current project state").DoError(func() error {
-		actionConfig, err := common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle)
-		if err != nil {
-			return err
-		}
-
-		helmTemplateCmd, _ := cmd_helm.NewTemplateCmd(actionConfig, ioutil.Discard, cmd_helm.TemplateCmdOptions{
-			PostRenderer: postRenderer,
-			ValueOpts:    valueOpts,
-			Validate:     common.NewBool(true),
-			IncludeCrds:  common.NewBool(true),
-			IsUpgrade:    common.NewBool(true),
-		})
-		return helmTemplateCmd.RunE(helmTemplateCmd, []string{releaseName, fullChartDir})
-	}); err != nil {
+	syncTargets, err := dev_sync.DiscoverTargets(ctx, kube.Client, target.Namespace, releaseName)
+	if err != nil {
 		return err
 	}
 
-	if err := logboek.Context(ctx).Default().LogProcess("Migrating helm 2 release %q to helm 3 in the %q namespace", releaseName, namespace).DoError(func() error {
-		if err := maintenance_helper.Migrate2To3(ctx, releaseName, releaseName, namespace, maintenanceHelper); err != nil {
-			return fmt.Errorf("error migrating existing helm 2 release %q to helm 3 release %q in the namespace %q: %s", releaseName, releaseName, namespace, err)
-		}
+	if len(syncTargets) == 0 {
+		logboek.Context(ctx).Warn().LogF("WARNING: no workloads annotated with %s found in the release %q, nothing to sync\n", dev_sync.SyncAnnoName, releaseName)
 		return nil
-	}); err != nil {
-		return err
 	}
 
-	return nil
-}
-
-func checkHelm2AvailableAndReleaseExists(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper) (bool, error) {
-	if available, err := maintenanceHelper.CheckHelm2StorageAvailable(ctx); err != nil {
-		return false, err
-	} else if available {
-		foundHelm2Release, err := maintenanceHelper.IsHelm2ReleaseExist(ctx, releaseName)
-		if err != nil {
-			return false, fmt.Errorf("error checking existance of helm 2 release %q: %s", releaseName, err)
+	logboek.Context(ctx).LogOptionalLn()
+	logboek.Context(ctx).Default().LogBlock("Syncing project files (press Ctrl+C to stop)").Do(func() {
+		for _, syncTarget := range syncTargets {
+			for _, mapping := range syncTarget.Mappings {
+				logboek.Context(ctx).Default().LogF("%s -> %s container %s:%s\n", mapping.LocalPath, syncTarget, syncTarget.Container, mapping.ContainerPath)
+			}
 		}
+	})
 
-		return foundHelm2Release, nil
-	}
-
-	return false, nil
-}
-
-func checkHelm3ReleaseExists(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper) (bool, error) {
-	foundHelm3Release, err := maintenanceHelper.IsHelm3ReleaseExist(ctx, releaseName)
-	if err != nil {
-		return false, fmt.Errorf("error checking existance of helm 3 release %q: %s", releaseName, err)
-	}
-
-	return foundHelm3Release, nil
+	return dev_sync.NewSyncer(kube.Client, kubeConfig.Config, giterminismManager.ProjectDir(), syncTargets, common.GetDevIgnore(&c.commonCmdData)).Run(ctx)
 }
@@ -0,0 +1,102 @@
+package publish_cleanup_policies
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/cleaning"
+	"github.com/werf/werf/pkg/cosign"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	SignKey string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "publish-cleanup-policies FILE REPO:TAG",
+		DisableFlagsInUseLine: true,
+		Short:                 "Publish cleanup policy set into container registry",
+		Long: common.GetLongCommandDescription(`Publish cleanup policy set into container registry as the OCI artifact.
+
+The policy set file has the same format as the cleanup section of werf.yaml (keepPolicies directive). Published policy set could be referenced in werf.yaml of the projects with the cleanup.policySets directive, so that werf merges policies of the set with the local ones on cleanup.`),
+		Example: `  $ werf cr publish-cleanup-policies cleanup-policies.yaml registry.mydomain.com/platform/cleanup-policies:v1 --sign-key cosign.key`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := common.GetContext(cmd)
+
+			defer global_warnings.PrintGlobalWarnings(ctx)
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if err := common.ValidateArgumentCount(2, args, cmd); err != nil {
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return runPublish(ctx, args[0], args[1])
+			})
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to push into the specified repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupPlatform(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.SignKey, "sign-key", "", os.Getenv("WERF_SIGN_KEY"), "Sign published policy set with the specified cosign private key ($WERF_SIGN_KEY by default)")
+
+	return cmd
+}
+
+func runPublish(ctx context.Context, file, ref string) error {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %s", file, err)
+	}
+
+	return logboek.Context(ctx).LogProcess("Publishing cleanup policy set %s", ref).DoError(func() error {
+		if err := cleaning.PublishCleanupPolicySet(ctx, data, ref); err != nil {
+			return err
+		}
+
+		if cmdData.SignKey != "" {
+			return cosign.Sign(ctx, ref, cmdData.SignKey)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/api"
+	"github.com/werf/werf/pkg/daemon"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	Socket string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run werf daemon",
+		Long: common.GetLongCommandDescription(`Run werf daemon, which serves build, converge and cleanup requests on the local unix socket.
+
+The daemon reuses the warm caches between the requests, so IDE plugins and orchestration tools can drive werf without starting a new werf process for each operation.
+
+The daemon is the gRPC service werf.daemon.v1.Daemon with the server streaming methods Build, Converge and Cleanup.
+The messages are encoded in json (the application/grpc+json content type), the request is the json options of the operation:
+
+  {"Dir": "/path/to/project", "Env": "production", "Repo": "registry.example.com/project", "ImageNames": ["backend"]}
+
+The response is the stream of the events: started, log (with out or err stream and data), progress (with the image, stage and resource events, as in the --events-path stream) and finished (with error if the operation failed).
+Go programs can use the client from the github.com/werf/werf/pkg/daemon package.
+Requests are processed one at a time, since the requests for the different projects cannot share the werf runtime concurrently.`),
+		Example:               `  $ werf daemon --socket /tmp/werf.sock`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return runDaemon()
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.Socket, "socket", "", os.Getenv("WERF_DAEMON_SOCKET"), "Serve requests on the specified unix socket (default ~/.werf/service/daemon.sock or $WERF_DAEMON_SOCKET)")
+
+	return cmd
+}
+
+func runDaemon() error {
+	ctx := common.BackgroundContext()
+
+	if err := api.Init(ctx, api.InitOptions{
+		TmpDir:                *commonCmdData.TmpDir,
+		HomeDir:               *commonCmdData.HomeDir,
+		HomeIsolationKey:      *commonCmdData.HomeIsolationKey,
+		DockerConfig:          *commonCmdData.DockerConfig,
+		InsecureRegistry:      *commonCmdData.InsecureRegistry,
+		SkipTlsVerifyRegistry: *commonCmdData.SkipTlsVerifyRegistry,
+		SSHKeys:               common.GetSSHKey(&commonCmdData),
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		if err := api.Terminate(ctx); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: werf runtime termination failed: %s\n", err)
+		}
+	}()
+
+	socketPath := cmdData.Socket
+	if socketPath == "" {
+		socketPath = filepath.Join(werf.GetServiceDir(), "daemon.sock")
+	}
+
+	listener, err := daemon.Listen(socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(socketPath)
+
+	grpcServer := grpc.NewServer()
+	daemon.NewServer(daemon.APIOperations{}).Register(grpcServer)
+
+	logboek.Context(ctx).LogF("Serving werf daemon on %s\n", socketPath)
+
+	return grpcServer.Serve(listener)
+}
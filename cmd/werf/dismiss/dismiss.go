@@ -22,6 +22,7 @@ import (
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/lock_manager"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/true_git"
@@ -30,8 +31,10 @@ import (
 )
 
 var cmdData struct {
-	WithNamespace bool
-	WithHooks     bool
+	WithNamespace          bool
+	WithHooks              bool
+	KeepNamespaceResources []string
+	OnlyKinds              []string
 }
 
 var commonCmdData common.CmdData
@@ -44,6 +47,8 @@ func NewCmd() *cobra.Command {
 
 Helm Release will be purged and optionally Kubernetes Namespace.
 
+Dismiss could be selective: the release resources matching --keep-namespace-resources patterns (e.g. PVCs and Secrets with the data) are left in the namespace when the release is purged, and with --only-kinds only the release resources of the specified kinds are deleted while the release itself is kept. Use --dry-run to list the resources that would be deleted and kept.
+
 Environment is a required param for the dismiss by default, because it is needed to construct Helm Release name and Kubernetes Namespace. Either --env or $WERF_ENV should be specified for command.
 
 Read more info about Helm Release name, Kubernetes Namespace and how to change it: https://werf.io/documentation/advanced/helm/releases/naming.html`),
@@ -54,7 +59,13 @@ Read more info about Helm Release name, Kubernetes Namespace and how to change i
   $ werf dismiss --env my-feature-branch --with-namespace
 
   # Dismiss project using specified helm release name and namespace
-  $ werf dismiss --release myrelease --namespace myns`,
+  $ werf dismiss --release myrelease --namespace myns
+
+  # Dismiss project, but keep all PVCs and Secrets with names starting with 'db-'
+  $ werf dismiss --env dev --keep-namespace-resources 'PersistentVolumeClaim/*' --keep-namespace-resources 'Secret/db-*'
+
+  # List the Deployments and StatefulSets of the release to be deleted without deleting anything
+  $ werf dismiss --env dev --only-kinds Deployment --only-kinds StatefulSet --dry-run`,
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := common.BackgroundContext()
@@ -115,14 +126,36 @@ Read more info about Helm Release name, Kubernetes Namespace and how to change i
 
 	common.SetupPlatform(&commonCmdData, cmd)
 
+	common.SetupDryRun(&commonCmdData, cmd)
+
 	cmd.Flags().BoolVarP(&cmdData.WithNamespace, "with-namespace", "", common.GetBoolEnvironmentDefaultFalse("WERF_WITH_NAMESPACE"), "Delete Kubernetes Namespace after purging Helm Release (default $WERF_WITH_NAMESPACE)")
 	cmd.Flags().BoolVarP(&cmdData.WithHooks, "with-hooks", "", common.GetBoolEnvironmentDefaultTrue("WERF_WITH_HOOKS"), "Delete Helm Release hooks getting from existing revisions (default $WERF_WITH_HOOKS or true)")
+	cmd.Flags().StringArrayVarP(&cmdData.KeepNamespaceResources, "keep-namespace-resources", "", []string{}, `Keep the release resources matching the pattern in the namespace (can specify multiple).
+Format: KIND/NAME, the kind is case-insensitive and the name is the glob pattern (e.g. PersistentVolumeClaim/*, Secret/db-*).
+Also, can be specified with $WERF_KEEP_NAMESPACE_RESOURCES_* (e.g. $WERF_KEEP_NAMESPACE_RESOURCES_PVC=PersistentVolumeClaim/*)`)
+	cmd.Flags().StringArrayVarP(&cmdData.OnlyKinds, "only-kinds", "", []string{}, `Delete only the release resources of the specified kinds and keep the release (can specify multiple).
+Also, can be specified with $WERF_ONLY_KINDS_* (e.g. $WERF_ONLY_KINDS_1=Deployment, $WERF_ONLY_KINDS_2=StatefulSet)`)
 
 	return cmd
 }
 
 func runDismiss(ctx context.Context) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	keepResources, err := helm.ParseResourceSelectors(append(common.PredefinedValuesByEnvNamePrefix("WERF_KEEP_NAMESPACE_RESOURCES_"), cmdData.KeepNamespaceResources...))
+	if err != nil {
+		return fmt.Errorf("bad --keep-namespace-resources: %s", err)
+	}
+
+	selectiveDismissOptions := helm.SelectiveDismissOptions{
+		KeepResources: keepResources,
+		OnlyKinds:     append(common.PredefinedValuesByEnvNamePrefix("WERF_ONLY_KINDS_"), cmdData.OnlyKinds...),
+		DryRun:        *commonCmdData.DryRun,
+	}
+
+	if cmdData.WithNamespace && selectiveDismissOptions.IsSelective() {
+		return fmt.Errorf("--with-namespace cannot be used with --keep-namespace-resources or --only-kinds: namespace deletion deletes all resources")
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -166,7 +199,7 @@ func runDismiss(ctx context.Context) error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
-	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	werfConfigPath, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
@@ -177,12 +210,12 @@ func runDismiss(ctx context.Context) error {
 		return err
 	}
 
-	releaseName, err := common.GetHelmRelease(*commonCmdData.Release, *commonCmdData.Environment, werfConfig)
+	releaseName, err := flows.GetHelmRelease(*commonCmdData.Release, *commonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
 
-	namespace, err := common.GetKubernetesNamespace(*commonCmdData.Namespace, *commonCmdData.Environment, werfConfig)
+	namespace, err := flows.GetKubernetesNamespace(*commonCmdData.Namespace, *commonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -194,7 +227,7 @@ func runDismiss(ctx context.Context) error {
 		lockManager = m
 	}
 
-	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	chartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
 	if err != nil {
 		return fmt.Errorf("getting helm chart dir failed: %s", err)
 	}
@@ -227,6 +260,16 @@ func runDismiss(ctx context.Context) error {
 		return err
 	}
 
+	if len(selectiveDismissOptions.OnlyKinds) != 0 {
+		return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
+			return helm.DeleteReleaseResourcesByKinds(ctx, actionConfig, releaseName, selectiveDismissOptions)
+		})
+	}
+
+	if selectiveDismissOptions.DryRun {
+		return helm.LogSelectiveDismiss(ctx, actionConfig, releaseName, selectiveDismissOptions)
+	}
+
 	helmUninstallCmd := cmd_helm.NewUninstallCmd(actionConfig, logboek.Context(ctx).OutStream(), cmd_helm.UninstallCmdOptions{
 		DeleteNamespace: &cmdData.WithNamespace,
 		DeleteHooks:     &cmdData.WithHooks,
@@ -237,6 +280,14 @@ func runDismiss(ctx context.Context) error {
 		return helmUninstallCmd.RunE(helmUninstallCmd, []string{releaseName})
 	} else {
 		return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
+			if selectiveDismissOptions.IsSelective() {
+				if err := helm.LogSelectiveDismiss(ctx, actionConfig, releaseName, selectiveDismissOptions); err != nil {
+					return err
+				}
+
+				actionConfig.KubeClient = helm.NewSelectiveDismissKubeClient(actionConfig.KubeClient, selectiveDismissOptions.KeepResources)
+			}
+
 			return helmUninstallCmd.RunE(helmUninstallCmd, []string{releaseName})
 		})
 	}
@@ -2,6 +2,7 @@ package export
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -17,8 +18,10 @@ import (
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/giterminism_manager"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
 	"github.com/werf/werf/pkg/slug"
@@ -43,7 +46,10 @@ func NewExportCmd() *cobra.Command {
 All meta-information related to werf is removed from the exported images, and then images are completely under the user's responsibility`),
 		DisableFlagsInUseLine: true,
 		Example: `  # Export images to Docker Hub and GitHub Container Registry
-  $ werf export --tag=index.docker.io/company/project:%image%-latest --tag=ghcr.io/company/project/%image%:latest`,
+  $ werf export --tag=index.docker.io/company/project:%image%-latest --tag=ghcr.io/company/project/%image%:latest
+
+  # Export images of the release tagged in git to the customer-facing registry
+  $ werf export --tag=registry.example.com/app:%image%-%git_tag%`,
 		Annotations: map[string]string{
 			common.DisableOptionsInUseLineAnno: "1",
 		},
@@ -74,13 +80,19 @@ All meta-information related to werf is removed from the exported images, and th
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupRepoReadOnly(&commonCmdData, cmd)
+	common.SetupRegistryMirrors(&commonCmdData, cmd)
 
 	common.SetupSkipBuild(&commonCmdData, cmd)
 
@@ -102,11 +114,15 @@ All meta-information related to werf is removed from the exported images, and th
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&commonCmdData, cmd)
 
 	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
 
 	cmd.Flags().StringArrayVarP(&tagTemplateList, "tag", "", []string{}, `Set a tag template (can specify multiple).
-It is necessary to use image name shortcut %image% or %image_slug% if multiple images are exported (e.g. REPO:TAG-%image% or REPO-%image%:TAG)`)
+It is necessary to use image name shortcut %image% or %image_slug% if multiple images are exported (e.g. REPO:TAG-%image% or REPO-%image%:TAG).
+The git shortcuts %git_commit% and %git_tag% are replaced with the head commit and the git tag pointing to the head commit (e.g. REPO:%image%-%git_tag%)`)
 
 	return cmd
 }
@@ -114,10 +130,14 @@ It is necessary to use image name shortcut %image% or %image_slug% if multiple i
 func run(imagesToProcess, tagTemplateList []string) error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -135,7 +155,12 @@ func run(imagesToProcess, tagTemplateList []string) error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
@@ -143,6 +168,14 @@ func run(imagesToProcess, tagTemplateList []string) error {
 		return err
 	}
 
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
 	ctxWithDockerCli, err := docker.NewContext(ctx)
 	if err != nil {
 		return err
@@ -170,11 +203,19 @@ func run(imagesToProcess, tagTemplateList []string) error {
 		}
 	}()
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.InitRegistryMirrors(&commonCmdData, werfConfig); err != nil {
+		return err
+	}
+
 	for _, imageToProcess := range imagesToProcess {
 		if !werfConfig.HasImageOrArtifact(imageToProcess) {
 			return fmt.Errorf("specified image %s is not found in werf.yaml", logging.ImageLogName(imageToProcess, false))
@@ -195,7 +236,7 @@ func run(imagesToProcess, tagTemplateList []string) error {
 	if err != nil {
 		return err
 	}
-	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+	finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &commonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -203,11 +244,11 @@ func run(imagesToProcess, tagTemplateList []string) error {
 	if err != nil {
 		return err
 	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 	if err != nil {
 		return err
 	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 	if err != nil {
 		return err
 	}
@@ -221,6 +262,9 @@ func run(imagesToProcess, tagTemplateList []string) error {
 	}
 
 	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+	if common.IsRepoReadOnly(&commonCmdData) {
+		storageManager.EnableReadOnly()
+	}
 
 	logboek.Context(ctx).Info().LogOptionalLn()
 
@@ -234,7 +278,7 @@ func run(imagesToProcess, tagTemplateList []string) error {
 			}
 		}
 
-		tagFuncList, err := getTagFuncList(imagesToProcess, tagTemplateList)
+		tagFuncList, err := getTagFuncList(imagesToProcess, tagTemplateList, newTagTemplateGitFuncs(ctx, giterminismManager))
 		if err != nil {
 			return err
 		}
@@ -251,7 +295,31 @@ func run(imagesToProcess, tagTemplateList []string) error {
 	})
 }
 
-func getTagFuncList(imageNameList, tagTemplateList []string) ([]func(string) string, error) {
+// newTagTemplateGitFuncs returns the tag template functions which are replaced with the same git data for all images.
+func newTagTemplateGitFuncs(ctx context.Context, giterminismManager giterminism_manager.Interface) map[string]interface{} {
+	return map[string]interface{}{
+		"git_commit": func() string { return giterminismManager.HeadCommit() },
+		"git_tag": func() (string, error) {
+			headCommit := giterminismManager.HeadCommit()
+
+			tags, err := giterminismManager.LocalGitRepo().CommitTagsList(ctx, headCommit)
+			if err != nil {
+				return "", fmt.Errorf("unable to get git tags of the commit %s: %s", headCommit, err)
+			}
+
+			switch len(tags) {
+			case 0:
+				return "", fmt.Errorf("%%git_tag%% requires the git tag pointing to the head commit %s", headCommit)
+			case 1:
+				return tags[0], nil
+			default:
+				return "", fmt.Errorf("%%git_tag%% is ambiguous: git tags %s point to the head commit %s", strings.Join(tags, ", "), headCommit)
+			}
+		},
+	}
+}
+
+func getTagFuncList(imageNameList, tagTemplateList []string, gitFuncs map[string]interface{}) ([]func(string) string, error) {
 	templateName := "--tag"
 	tmpl := template.New(templateName).Delims("%", "%")
 	tmpl = tmpl.Funcs(map[string]interface{}{
@@ -259,6 +327,7 @@ func getTagFuncList(imageNameList, tagTemplateList []string) ([]func(string) str
 		"image_slug":      func() string { return "%[2]s" },
 		"image_safe_slug": func() string { return "%[3]s" },
 	})
+	tmpl = tmpl.Funcs(gitFuncs)
 
 	var tagFuncList []func(string) string
 	for _, tagTemplate := range tagTemplateList {
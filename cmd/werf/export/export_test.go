@@ -0,0 +1,148 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+func newTestGitFuncs(gitTag string, gitTagErr error) map[string]interface{} {
+	return map[string]interface{}{
+		"git_commit": func() string { return "0123456789abcdef" },
+		"git_tag":    func() (string, error) { return gitTag, gitTagErr },
+	}
+}
+
+func TestGetTagFuncList_GitShortcuts(t *testing.T) {
+	for _, tc := range []struct {
+		tagTemplate string
+		expected    map[string]string
+	}{
+		{
+			tagTemplate: "registry.example.com/app:%image%-%git_tag%",
+			expected: map[string]string{
+				"backend":  "registry.example.com/app:backend-v1.0.0",
+				"frontend": "registry.example.com/app:frontend-v1.0.0",
+			},
+		},
+		{
+			tagTemplate: "registry.example.com/app/%image_slug%:%git_commit%",
+			expected: map[string]string{
+				"backend":  "registry.example.com/app/backend:0123456789abcdef",
+				"frontend": "registry.example.com/app/frontend:0123456789abcdef",
+			},
+		},
+	} {
+		tagFuncList, err := getTagFuncList([]string{"backend", "frontend"}, []string{tc.tagTemplate}, newTestGitFuncs("v1.0.0", nil))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.tagTemplate, err)
+		}
+
+		for imageName, expected := range tc.expected {
+			if tag := tagFuncList[0](imageName); tag != expected {
+				t.Errorf("%s: expected %q for the image %q, got %q", tc.tagTemplate, expected, imageName, tag)
+			}
+		}
+	}
+}
+
+func TestGetTagFuncList_GitShortcutsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		imageNameList []string
+		tagTemplate   string
+		gitTagErr     error
+		expectedErr   string
+	}{
+		{
+			imageNameList: []string{"backend"},
+			tagTemplate:   "registry.example.com/app:%git_tag%",
+			gitTagErr:     errors.New("%git_tag% requires the git tag pointing to the head commit 0123456789abcdef"),
+			expectedErr:   "%git_tag% requires the git tag pointing to the head commit",
+		},
+		{
+			// the git shortcuts are the same for all images
+			imageNameList: []string{"backend", "frontend"},
+			tagTemplate:   "registry.example.com/app:%git_commit%",
+			expectedErr:   "invalid tag template",
+		},
+	} {
+		if _, err := getTagFuncList(tc.imageNameList, []string{tc.tagTemplate}, newTestGitFuncs("v1.0.0", tc.gitTagErr)); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%s: expected error %q, got %v", tc.tagTemplate, tc.expectedErr, err)
+		}
+	}
+}
+
+type testGiterminismManager struct {
+	giterminism_manager.Interface
+
+	localGitRepo *git_repo.Local
+	headCommit   string
+}
+
+func (m *testGiterminismManager) LocalGitRepo() *git_repo.Local {
+	return m.localGitRepo
+}
+
+func (m *testGiterminismManager) HeadCommit() string {
+	return m.headCommit
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=werf", "GIT_AUTHOR_EMAIL=werf@example.com",
+		"GIT_COMMITTER_NAME=werf", "GIT_COMMITTER_EMAIL=werf@example.com",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %s\n%s", args, err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+func TestNewTagTemplateGitFuncs(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "commit", "-q", "--allow-empty", "-m", "initial")
+	headCommit := runGit(t, dir, "rev-parse", "HEAD")
+
+	localGitRepo, err := git_repo.OpenLocalRepo(ctx, "own", dir, git_repo.OpenLocalRepoOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitFuncs := newTagTemplateGitFuncs(ctx, &testGiterminismManager{localGitRepo: localGitRepo, headCommit: headCommit})
+	gitCommitFunc := gitFuncs["git_commit"].(func() string)
+	gitTagFunc := gitFuncs["git_tag"].(func() (string, error))
+
+	if commit := gitCommitFunc(); commit != headCommit {
+		t.Errorf("expected the head commit %q, got %q", headCommit, commit)
+	}
+
+	if _, err := gitTagFunc(); err == nil || !strings.Contains(err.Error(), "%git_tag% requires the git tag pointing to the head commit") {
+		t.Errorf("expected the no git tag error, got %v", err)
+	}
+
+	runGit(t, dir, "tag", "v1.0.0")
+	if tag, err := gitTagFunc(); err != nil || tag != "v1.0.0" {
+		t.Errorf("expected the git tag v1.0.0, got %q (%v)", tag, err)
+	}
+
+	runGit(t, dir, "tag", "-a", "latest", "-m", "latest")
+	if _, err := gitTagFunc(); err == nil || !strings.Contains(err.Error(), "%git_tag% is ambiguous: git tags latest, v1.0.0 point to the head commit") {
+		t.Errorf("expected the ambiguous git tag error, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/moby/buildkit/frontend/dockerfile/dockerignore"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/giterminism_manager/file_reader"
+	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "audit",
+		DisableFlagsInUseLine: true,
+		Short:                 "Find all places in the project requiring loosening giterminism",
+		Long: common.GetLongCommandDescription(`Find all places in the project requiring loosening giterminism.
+
+The command reads werf.yaml, the config templates, the Dockerfiles, the stapel scripts, the build contexts of the images and the helm chart the same way as other werf commands do, but does not fail on the first giterminism violation. All uncommitted files, symlinks pointing outside the git work tree, env variables, mounts and other directives not allowed by the current giterminism config are printed along with the werf-giterminism.yaml allowing them (the places that cannot be allowed by the giterminism config are printed without it).
+
+The command eases migration of the projects to giterminism: review each found place, fix the ones that can be fixed and allow the rest.`),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	// the audit is performed against the strict giterminism
+	commonCmdData.LooseGiterminism = new(bool)
+	commonCmdData.Dev = new(bool)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.InitReadOnly(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := git_repo.Init(gitdata.GetHostGitDataManagerReadOnly()); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManagerForAudit(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	auditErr := auditProject(ctx, giterminismManager)
+
+	records := giterminismManager.Audit().Records()
+	if len(records) == 0 {
+		if auditErr == nil {
+			logboek.Context(ctx).Default().LogLn("No places requiring loosening giterminism found")
+		}
+
+		return auditErr
+	}
+
+	fmt.Printf("Found %d place(s) requiring loosening giterminism:\n", len(records))
+	for _, record := range records {
+		fmt.Printf(" - %s\n", record.Message)
+	}
+
+	giterminismConfig, err := giterminismManager.Audit().GiterminismConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nThe following %s allows the places (except the ones that must be fixed):\n\n%s", file_reader.GiterminismConfigName, giterminismConfig)
+
+	if auditErr != nil {
+		return fmt.Errorf("audit was interrupted: %s", auditErr)
+	}
+
+	return nil
+}
+
+func auditProject(ctx context.Context, giterminismManager giterminism_manager.Interface) error {
+	customWerfConfigRelPath, err := common.GetCustomWerfConfigRelPath(giterminismManager, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	customWerfConfigTemplatesDirRelPath, err := common.GetCustomWerfConfigTemplatesDirRelPath(giterminismManager, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	werfConfigPath, werfConfig, err := config.GetWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return err
+	}
+
+	fileReader := giterminismManager.FileReader()
+
+	for _, imageConfig := range werfConfig.ImagesFromDockerfile {
+		if _, err := fileReader.ReadDockerfile(ctx, filepath.Join(imageConfig.Context, imageConfig.Dockerfile)); err != nil {
+			return err
+		}
+
+		var dockerignorePatterns []string
+		for _, relContextDockerignorePath := range []string{imageConfig.Dockerfile + ".dockerignore", ".dockerignore"} {
+			relDockerignorePath := filepath.Join(imageConfig.Context, relContextDockerignorePath)
+			if exist, err := fileReader.IsDockerignoreExistAnywhere(ctx, relDockerignorePath); err != nil {
+				return err
+			} else if exist {
+				dockerignoreData, err := fileReader.ReadDockerignore(ctx, relDockerignorePath)
+				if err != nil {
+					return err
+				}
+
+				dockerignorePatterns, err = dockerignore.ReadAll(bytes.NewReader(dockerignoreData))
+				if err != nil {
+					return fmt.Errorf("unable to read %q file: %s", relDockerignorePath, err)
+				}
+				break
+			}
+		}
+
+		// the whole context is inspected, the files not used by the Dockerfile instructions are ignored by the build though
+		contextRelativeToGitWorkTree := filepath.Join(giterminismManager.RelativeToGitProjectDir(), imageConfig.Context)
+		var contextAddFilesRelativeToGitWorkTree []string
+		for _, addFile := range imageConfig.ContextAddFiles {
+			contextAddFilesRelativeToGitWorkTree = append(contextAddFilesRelativeToGitWorkTree, filepath.Join(contextRelativeToGitWorkTree, addFile))
+		}
+
+		if err := giterminismManager.Inspector().InspectBuildContextFiles(ctx, path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+			BasePath:     contextRelativeToGitWorkTree,
+			ExcludeGlobs: contextAddFilesRelativeToGitWorkTree,
+			Matchers: []path_matcher.PathMatcher{
+				path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+					BasePath:             contextRelativeToGitWorkTree,
+					DockerignorePatterns: dockerignorePatterns,
+				}),
+			},
+		})); err != nil {
+			return err
+		}
+	}
+
+	var stapelImageBaseConfigs []*config.StapelImageBase
+	for _, imageConfig := range werfConfig.StapelImages {
+		stapelImageBaseConfigs = append(stapelImageBaseConfigs, imageConfig.StapelImageBase)
+	}
+	for _, artifactConfig := range werfConfig.Artifacts {
+		stapelImageBaseConfigs = append(stapelImageBaseConfigs, artifactConfig.StapelImageBase)
+	}
+
+	for _, imageBaseConfig := range stapelImageBaseConfigs {
+		if imageBaseConfig.Git != nil {
+			for _, gitLocal := range imageBaseConfig.Git.Local {
+				if err := giterminismManager.Inspector().InspectBuildContextFiles(ctx, path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+					BasePath:     gitLocal.GitMappingAdd(),
+					IncludeGlobs: gitLocal.IncludePaths,
+					ExcludeGlobs: gitLocal.ExcludePaths,
+				})); err != nil {
+					return err
+				}
+			}
+		}
+
+		if imageBaseConfig.Scripts == nil {
+			continue
+		}
+
+		for _, scriptPath := range imageBaseConfig.Scripts.Paths() {
+			if _, err := fileReader.ReadStapelScript(ctx, scriptPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	helmChartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fileReader.LoadChartDir(ctx, filepath.Join(giterminismManager.ProjectDir(), helmChartDir)); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/werf/kubedog/pkg/kube"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/helm/maintenance_helper"
+)
+
+var adoptCmdData struct {
+	Release   string
+	Force     bool
+	DryRun    bool
+	Manifests string
+}
+
+func NewAdoptCmd(actionConfig *action.Configuration) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "adopt RESOURCE...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Make existing resources owned by the helm 3 release",
+		Long: common.GetLongCommandDescription(`Make existing Kubernetes resources created outside werf (e.g. with kubectl or kustomize) owned by the helm 3 release without recreation.
+
+Resources are specified in the TYPE/NAME format (e.g. deploy/backend, svc/backend) and looked up in the namespace specified with the --namespace option. Only the release annotations and the managed-by label of the resources are changed.
+
+The rendered release manifests can be specified with the --manifests option to check the adopted resources with the server-side dry-run update: the immutable fields (e.g. Deployment selector), which differ from the manifests and would fail the next deploy, are reported.
+
+Resources which belong to another existing release are never adopted. Resources annotated with the release which does not exist anymore are adopted only with the --force option.`),
+		Example: `  # Adopt the Deployment and the Service created with kubectl into the release myapp-production
+  $ werf helm adopt --release myapp-production --namespace myapp-production deploy/backend svc/backend
+
+  # Check the immutable fields of the resources against the release manifests before adoption
+  $ werf render --env production > manifests.yaml
+  $ werf helm adopt --release myapp-production --namespace myapp-production --manifests manifests.yaml --dry-run deploy/backend svc/backend`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("at least one RESOURCE required")
+			}
+
+			if adoptCmdData.Release == "" {
+				return fmt.Errorf("--release=RELEASE param required")
+			}
+
+			maintenanceHelper := maintenance_helper.NewMaintenanceHelper(actionConfig, maintenance_helper.MaintenanceHelperOptions{
+				KubeConfigOptions: kube.KubeConfigOptions{
+					Context:          *_commonCmdData.KubeContext,
+					ConfigPath:       *_commonCmdData.KubeConfig,
+					ConfigDataBase64: *_commonCmdData.KubeConfigBase64,
+				},
+			})
+
+			return maintenanceHelper.AdoptResources(common.BackgroundContext(), adoptCmdData.Release, cmd_helm.Settings.Namespace(), args, maintenance_helper.AdoptResourcesOptions{
+				Force:         adoptCmdData.Force,
+				DryRun:        adoptCmdData.DryRun,
+				ManifestsPath: adoptCmdData.Manifests,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&adoptCmdData.Release, "release", "", os.Getenv("WERF_RELEASE"), "Release which should own the resources (default $WERF_RELEASE)")
+	cmd.Flags().BoolVarP(&adoptCmdData.Force, "force", "", common.GetBoolEnvironmentDefaultFalse("WERF_FORCE"), "Take over the resources annotated with the release which does not exist anymore (default $WERF_FORCE)")
+	cmd.Flags().BoolVarP(&adoptCmdData.DryRun, "dry-run", "", common.GetBoolEnvironmentDefaultFalse("WERF_DRY_RUN"), "Only report the resources to be adopted without changing them (default $WERF_DRY_RUN)")
+	cmd.Flags().StringVarP(&adoptCmdData.Manifests, "manifests", "", os.Getenv("WERF_MANIFESTS"), "Check the immutable fields of the resources against the rendered release manifests file, e.g. saved from the werf render output (default $WERF_MANIFESTS)")
+
+	return cmd
+}
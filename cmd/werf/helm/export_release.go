@@ -0,0 +1,45 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/helm/maintenance_helper"
+)
+
+var exportReleaseCmdData struct {
+	Dir string
+}
+
+func NewExportReleaseCmd(actionConfig *action.Configuration) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "export-release RELEASE_NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 "Export stored revisions of the helm 3 release into files",
+		Long: common.GetLongCommandDescription(`Export every stored revision of the helm 3 release (manifests, hooks, values and the whole release record) into the v1, v2, ... subdirectories of the specified directory.
+
+Exported release could be restored with the "werf helm import-release" command, e.g. when the release storage secrets are corrupted or accidentally deleted.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ValidateArgumentCount(1, args, cmd); err != nil {
+				return err
+			}
+
+			if exportReleaseCmdData.Dir == "" {
+				return fmt.Errorf("--dir=DIR param required")
+			}
+
+			maintenanceHelper := maintenance_helper.NewMaintenanceHelper(actionConfig, maintenance_helper.MaintenanceHelperOptions{})
+
+			return maintenanceHelper.ExportHelm3Release(common.BackgroundContext(), args[0], exportReleaseCmdData.Dir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&exportReleaseCmdData.Dir, "dir", "", os.Getenv("WERF_DIR"), "Directory to export release revisions into (default $WERF_DIR)")
+
+	return cmd
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/image"
@@ -59,6 +60,9 @@ These values includes project name, docker images ids and other`),
 	common.SetupTmpDir(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupHomeDir(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupSSHKey(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupSSHKnownHosts(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupGitSubmodules(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupGitSharedObjects(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&getAutogeneratedValuedCmdData, cmd)
@@ -72,6 +76,7 @@ These values includes project name, docker images ids and other`),
 	common.SetupVirtualMerge(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupVirtualMergeInto(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupNamespace(&getAutogeneratedValuedCmdData, cmd)
 
@@ -93,7 +98,7 @@ func runGetServiceValues() error {
 
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*getAutogeneratedValuedCmdData.TmpDir, *getAutogeneratedValuedCmdData.HomeDir); err != nil {
+	if err := werf.Init(*getAutogeneratedValuedCmdData.TmpDir, *getAutogeneratedValuedCmdData.HomeDir, *getAutogeneratedValuedCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -114,7 +119,12 @@ func runGetServiceValues() error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *getAutogeneratedValuedCmdData.LogVerbose || *getAutogeneratedValuedCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&getAutogeneratedValuedCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
@@ -137,7 +147,13 @@ func runGetServiceValues() error {
 		return err
 	}
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &getAutogeneratedValuedCmdData, giterminismManager, common.GetWerfConfigOptions(&getAutogeneratedValuedCmdData, false))
+	werfConfigOptions := common.GetWerfConfigOptions(&getAutogeneratedValuedCmdData, false)
+	// the dependencies are resolved with the repo, the stub values are used with --stub-tags
+	if !*getAutogeneratedValuedCmdData.StubTags {
+		werfConfigOptions.ResolveDependencies = common.GetDependenciesResolver(&getAutogeneratedValuedCmdData, giterminismManager)
+	}
+
+	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &getAutogeneratedValuedCmdData, giterminismManager, werfConfigOptions)
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
@@ -145,7 +161,7 @@ func runGetServiceValues() error {
 	projectName := werfConfig.Meta.Project
 	environment := *getAutogeneratedValuedCmdData.Environment
 
-	namespace, err := common.GetKubernetesNamespace(*getAutogeneratedValuedCmdData.Namespace, environment, werfConfig)
+	namespace, err := flows.GetKubernetesNamespace(*getAutogeneratedValuedCmdData.Namespace, environment, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -160,6 +176,10 @@ func runGetServiceValues() error {
 		}
 	}()
 
+	if err := common.InitSSHKnownHosts(ctx, &getAutogeneratedValuedCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
 	var imagesRepository string
 	var imagesInfoGetters []*image.InfoGetter
 	if *getAutogeneratedValuedCmdData.StubTags {
@@ -181,7 +201,7 @@ func runGetServiceValues() error {
 		if err != nil {
 			return err
 		}
-		finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &getAutogeneratedValuedCmdData)
+		finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &getAutogeneratedValuedCmdData, werfConfig)
 		if err != nil {
 			return err
 		}
@@ -189,11 +209,11 @@ func runGetServiceValues() error {
 		if err != nil {
 			return err
 		}
-		stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+		stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 		if err != nil {
 			return err
 		}
-		storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+		storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 		if err != nil {
 			return err
 		}
@@ -225,7 +245,7 @@ func runGetServiceValues() error {
 		}
 	}
 
-	serviceValues, err := helpers.GetServiceValues(ctx, projectName, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{Namespace: namespace, Env: environment})
+	serviceValues, err := helpers.GetServiceValues(ctx, projectName, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{Namespace: namespace, Env: environment, Dependencies: werfConfig.Dependencies})
 	if err != nil {
 		return fmt.Errorf("error creating service values: %s", err)
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/true_git"
@@ -49,16 +50,11 @@ func NewGetNamespaceCmd() *cobra.Command {
 func runGetNamespace() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*getNamespaceCmdData.TmpDir, *getNamespaceCmdData.HomeDir); err != nil {
+	if err := werf.InitReadOnly(*getNamespaceCmdData.TmpDir, *getNamespaceCmdData.HomeDir, *getNamespaceCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
-	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting host git data manager: %s", err)
-	}
-
-	if err := git_repo.Init(gitDataManager); err != nil {
+	if err := git_repo.Init(gitdata.GetHostGitDataManagerReadOnly()); err != nil {
 		return err
 	}
 
@@ -71,12 +67,12 @@ func runGetNamespace() error {
 		return err
 	}
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(common.BackgroundContext(), &getNamespaceCmdData, giterminismManager, common.GetWerfConfigOptions(&getNamespaceCmdData, false))
+	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &getNamespaceCmdData, giterminismManager, common.GetWerfConfigOptions(&getNamespaceCmdData, false))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
-	namespace, err := common.GetKubernetesNamespace("", *getNamespaceCmdData.Environment, werfConfig)
+	namespace, err := flows.GetKubernetesNamespace("", *getNamespaceCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/true_git"
@@ -49,16 +50,11 @@ func NewGetReleaseCmd() *cobra.Command {
 func runGetRelease() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*getReleaseCmdData.TmpDir, *getReleaseCmdData.HomeDir); err != nil {
+	if err := werf.InitReadOnly(*getReleaseCmdData.TmpDir, *getReleaseCmdData.HomeDir, *getReleaseCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
-	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting host git data manager: %s", err)
-	}
-
-	if err := git_repo.Init(gitDataManager); err != nil {
+	if err := git_repo.Init(gitdata.GetHostGitDataManagerReadOnly()); err != nil {
 		return err
 	}
 
@@ -71,12 +67,12 @@ func runGetRelease() error {
 		return err
 	}
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(common.BackgroundContext(), &getReleaseCmdData, giterminismManager, common.GetWerfConfigOptions(&getReleaseCmdData, false))
+	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &getReleaseCmdData, giterminismManager, common.GetWerfConfigOptions(&getReleaseCmdData, false))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
-	release, err := common.GetHelmRelease("", *getReleaseCmdData.Environment, werfConfig)
+	release, err := flows.GetHelmRelease("", *getReleaseCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -17,6 +17,7 @@ import (
 	helm_secret_file_edit "github.com/werf/werf/cmd/werf/helm/secret/file/edit"
 	helm_secret_file_encrypt "github.com/werf/werf/cmd/werf/helm/secret/file/encrypt"
 	helm_secret_generate_secret_key "github.com/werf/werf/cmd/werf/helm/secret/generate_secret_key"
+	helm_secret_rotate "github.com/werf/werf/cmd/werf/helm/secret/rotate"
 	helm_secret_rotate_secret_key "github.com/werf/werf/cmd/werf/helm/secret/rotate_secret_key"
 	helm_secret_values_decrypt "github.com/werf/werf/cmd/werf/helm/secret/values/decrypt"
 	helm_secret_values_edit "github.com/werf/werf/cmd/werf/helm/secret/values/edit"
@@ -100,6 +101,10 @@ func NewCmd() *cobra.Command {
 		NewGetNamespaceCmd(),
 		NewGetReleaseCmd(),
 		NewMigrate2To3Cmd(),
+		NewExportReleaseCmd(actionConfig),
+		NewImportReleaseCmd(actionConfig),
+		NewAdoptCmd(actionConfig),
+		NewRepairReleaseCmd(actionConfig),
 		cmd_helm.NewRegistryCmd(actionConfig, os.Stdout),
 	)
 
@@ -118,7 +123,7 @@ func NewCmd() *cobra.Command {
 			cmd.RunE = func(cmd *cobra.Command, args []string) error {
 				// NOTE: Common init block for all runnable commands.
 
-				if err := werf.Init(*_commonCmdData.TmpDir, *_commonCmdData.HomeDir); err != nil {
+				if err := werf.Init(*_commonCmdData.TmpDir, *_commonCmdData.HomeDir, *_commonCmdData.HomeIsolationKey); err != nil {
 					return err
 				}
 
@@ -218,6 +223,7 @@ func secretCmd() *cobra.Command {
 		helm_secret_encrypt.NewCmd(),
 		helm_secret_decrypt.NewCmd(),
 		helm_secret_rotate_secret_key.NewCmd(),
+		helm_secret_rotate.NewCmd(),
 	)
 
 	return cmd
@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/helm/maintenance_helper"
+)
+
+var importReleaseCmdData struct {
+	Dir       string
+	Overwrite bool
+}
+
+func NewImportReleaseCmd(actionConfig *action.Configuration) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "import-release",
+		DisableFlagsInUseLine: true,
+		Short:                 "Import helm 3 release revisions exported by the export-release command",
+		Long: common.GetLongCommandDescription(`Import helm 3 release revisions exported by the "werf helm export-release" command into the release storage of the specified namespace.
+
+Only release records are restored, Kubernetes resources of the release are not changed. Revisions already existing in the release storage are skipped unless --overwrite option is specified.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if importReleaseCmdData.Dir == "" {
+				return fmt.Errorf("--dir=DIR param required")
+			}
+
+			maintenanceHelper := maintenance_helper.NewMaintenanceHelper(actionConfig, maintenance_helper.MaintenanceHelperOptions{})
+
+			return maintenanceHelper.ImportHelm3Release(common.BackgroundContext(), importReleaseCmdData.Dir, *cmd_helm.Settings.GetNamespaceP(), maintenance_helper.ImportHelm3ReleaseOptions{
+				Overwrite: importReleaseCmdData.Overwrite,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&importReleaseCmdData.Dir, "dir", "", os.Getenv("WERF_DIR"), "Directory with the exported release revisions (default $WERF_DIR)")
+	cmd.Flags().BoolVarP(&importReleaseCmdData.Overwrite, "overwrite", "", common.GetBoolEnvironmentDefaultFalse("WERF_OVERWRITE"), "Overwrite release revisions already existing in the release storage (default $WERF_OVERWRITE)")
+
+	return cmd
+}
@@ -25,6 +25,7 @@ var migrate2ToCmdData struct {
 	Release         string
 	TargetRelease   string
 	TargetNamespace string
+	AllReleases     bool
 
 	Helm2ReleaseStorageNamespace string
 	Helm2ReleaseStorageType      string
@@ -45,7 +46,7 @@ func setupHelm2ReleaseStorageNamespace(cmd *cobra.Command) {
 		}
 	}
 
-	cmd.Flags().StringVarP(&migrate2ToCmdData.Helm2ReleaseStorageNamespace, "helm2-release-storage-namespace", "", defaultValue, fmt.Sprintf("Helm 2 release storage namespace (same as --tiller-namespace for regular helm 2, defaults to $WERF_HELM2_RELEASE_STORAGE_NAMESPACE, or $WERF_HELM_RELEASE_STORAGE_NAMESPACE, or $TILLER_NAMESPACE, or \"kube-system\")"))
+	cmd.Flags().StringVarP(&migrate2ToCmdData.Helm2ReleaseStorageNamespace, "helm2-release-storage-namespace", "", defaultValue, fmt.Sprintf("Helm 2 release storage namespace (same as --tiller-namespace for regular helm 2, defaults to $WERF_HELM2_RELEASE_STORAGE_NAMESPACE, or $WERF_HELM_RELEASE_STORAGE_NAMESPACE, or $TILLER_NAMESPACE). If neither storage namespace nor storage type is specified, werf discovers helm 2 release configmaps and secrets in all namespaces (in \"kube-system\" namespace only if listing in all namespaces is forbidden), or uses \"kube-system\" namespace if nothing found"))
 }
 
 func setupHelm2ReleaseStorageType(cmd *cobra.Command) {
@@ -62,7 +63,7 @@ func setupHelm2ReleaseStorageType(cmd *cobra.Command) {
 		}
 	}
 
-	cmd.Flags().StringVarP(&migrate2ToCmdData.Helm2ReleaseStorageType, "helm2-release-storage-type", "", defaultValue, fmt.Sprintf("Helm w storage driver to use. One of %[1]q or %[2]q, defaults to $WERF_HELM2_RELEASE_STORAGE_TYPE, or $WERF_HELM_RELEASE_STORAGE_TYPE, or %[1]q)", "configmap", "secret"))
+	cmd.Flags().StringVarP(&migrate2ToCmdData.Helm2ReleaseStorageType, "helm2-release-storage-type", "", defaultValue, fmt.Sprintf("Helm 2 storage driver to use. One of %[1]q or %[2]q, defaults to $WERF_HELM2_RELEASE_STORAGE_TYPE, or $WERF_HELM_RELEASE_STORAGE_TYPE, or %[1]q)", "configmap", "secret"))
 }
 
 func NewMigrate2To3Cmd() *cobra.Command {
@@ -70,6 +71,13 @@ func NewMigrate2To3Cmd() *cobra.Command {
 		Use:                   "migrate2to3",
 		DisableFlagsInUseLine: true,
 		Short:                 "Start a migration of your existing helm 2 release to helm 3",
+		Long: common.GetLongCommandDescription(`Start a migration of your existing helm 2 release to helm 3.
+
+The single helm 2 release specified with the --release option is migrated into the helm 3 release in the --target-namespace.
+
+With the --all-releases option all helm 2 releases found in the helm 2 release storage are migrated into the helm 3 releases with the same names. Each release is migrated into the namespace of the helm 2 release, unless the --target-namespace option is specified.
+
+Use the --dry-run option to print the planned conversions without changing anything in the cluster.`),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := common.ProcessLogOptions(&migrate2To3CommonCmdData); err != nil {
 				common.PrintHelp(cmd)
@@ -87,11 +95,14 @@ func NewMigrate2To3Cmd() *cobra.Command {
 	common.SetupKubeConfigBase64(&migrate2To3CommonCmdData, cmd)
 	common.SetupKubeContext(&migrate2To3CommonCmdData, cmd)
 
+	common.SetupDryRun(&migrate2To3CommonCmdData, cmd)
+
 	common.SetupLogOptions(&migrate2To3CommonCmdData, cmd)
 
 	cmd.Flags().StringVarP(&migrate2ToCmdData.Release, "release", "", os.Getenv("WERF_RELEASE"), "Existing helm 2 release name which should be migrated to helm 3 (default $WERF_RELEASE). Option also sets target name for a new helm 3 release, use --target-release option (or $WERF_TARGET_RELEASE) to specify a different helm 3 release name.")
 	cmd.Flags().StringVarP(&migrate2ToCmdData.TargetRelease, "target-release", "", os.Getenv("WERF_TARGET_RELEASE"), "Target helm 3 release name (optional, default $WERF_TARGET_RELEASE, or the value of --release option, or $WERF_RELEASE)")
-	cmd.Flags().StringVarP(&migrate2ToCmdData.TargetNamespace, "target-namespace", "", os.Getenv("WERF_NAMESPACE"), "Target kubernetes namespace for a new helm 3 release (default $WERF_NAMESPACE)")
+	cmd.Flags().StringVarP(&migrate2ToCmdData.TargetNamespace, "target-namespace", "", os.Getenv("WERF_NAMESPACE"), "Target kubernetes namespace for a new helm 3 release (default $WERF_NAMESPACE). With --all-releases option each release is migrated into the namespace of the helm 2 release by default.")
+	cmd.Flags().BoolVarP(&migrate2ToCmdData.AllReleases, "all-releases", "", common.GetBoolEnvironmentDefaultFalse("WERF_ALL_RELEASES"), "Migrate all helm 2 releases found in the helm 2 release storage (default $WERF_ALL_RELEASES)")
 
 	setupHelm2ReleaseStorageNamespace(cmd)
 	setupHelm2ReleaseStorageType(cmd)
@@ -100,7 +111,7 @@ func NewMigrate2To3Cmd() *cobra.Command {
 }
 
 func runMigrate2To3(ctx context.Context) error {
-	if err := werf.Init(*migrate2To3CommonCmdData.TmpDir, *migrate2To3CommonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*migrate2To3CommonCmdData.TmpDir, *migrate2To3CommonCmdData.HomeDir, *migrate2To3CommonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -109,9 +120,30 @@ func runMigrate2To3(ctx context.Context) error {
 		return err
 	}
 
+	kubeConfigOptions := kube.KubeConfigOptions{
+		Context:          *migrate2To3CommonCmdData.KubeContext,
+		ConfigPath:       *migrate2To3CommonCmdData.KubeConfig,
+		ConfigDataBase64: *migrate2To3CommonCmdData.KubeConfigBase64,
+	}
+
+	registryClientHandler, err := common.NewHelmRegistryClientHandle(ctx, &migrate2To3CommonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+
+	migrateOpts := maintenance_helper.Migrate2To3Options{DryRun: *migrate2To3CommonCmdData.DryRun}
+
+	if migrate2ToCmdData.AllReleases {
+		if migrate2ToCmdData.Release != "" || migrate2ToCmdData.TargetRelease != "" {
+			return fmt.Errorf("--release and --target-release options cannot be used with --all-releases option")
+		}
+
+		return runMigrate2To3AllReleases(ctx, kubeConfigOptions, registryClientHandler, migrateOpts)
+	}
+
 	existingReleaseName := migrate2ToCmdData.Release
 	if existingReleaseName == "" {
-		return fmt.Errorf("--release (or WERF_RELEASE env var) required! This option specifies existing helm 2 release name which should be migrated to helm 3, option also sets target name for a new helm 3 release, use --target-release option (or $WERF_TARGET_RELEASE) to specify a different helm 3 release name.")
+		return fmt.Errorf("--release (or WERF_RELEASE env var) required! This option specifies existing helm 2 release name which should be migrated to helm 3, option also sets target name for a new helm 3 release, use --target-release option (or $WERF_TARGET_RELEASE) to specify a different helm 3 release name. Use --all-releases option to migrate all helm 2 releases.")
 	}
 
 	targetReleaseName := migrate2ToCmdData.TargetRelease
@@ -124,30 +156,112 @@ func runMigrate2To3(ctx context.Context) error {
 		return fmt.Errorf("--target-namespace (or WERF_TARGET_NAMESPACE env var) required! Please specify target namespace for a new helm 3 release explicitly (specify \"default\" for the default namespace).")
 	}
 
-	kubeConfigOptions := kube.KubeConfigOptions{
-		Context:          *migrate2To3CommonCmdData.KubeContext,
-		ConfigPath:       *migrate2To3CommonCmdData.KubeConfig,
-		ConfigDataBase64: *migrate2To3CommonCmdData.KubeConfigBase64,
+	maintenanceHelper, err := newMigrate2To3MaintenanceHelper(ctx, targetNamespace, kubeConfigOptions, registryClientHandler)
+	if err != nil {
+		return err
 	}
 
-	registryClientHandler, err := common.NewHelmRegistryClientHandle(ctx, &migrate2To3CommonCmdData)
-	if err != nil {
-		return fmt.Errorf("unable to create helm registry client: %s", err)
+	if err := checkHelm2StorageAvailable(ctx, maintenanceHelper); err != nil {
+		return err
 	}
 
-	actionConfig := new(action.Configuration)
-	if err := helm.InitActionConfig(ctx, common.GetOndemandKubeInitializer(), targetNamespace, cmd_helm.Settings, registryClientHandler, actionConfig, helm.InitActionConfigOptions{KubeConfigOptions: kubeConfigOptions}); err != nil {
+	if err := maintenance_helper.Migrate2To3(ctx, existingReleaseName, targetReleaseName, targetNamespace, maintenanceHelper, migrateOpts); err != nil {
+		return err
+	}
+
+	if migrateOpts.DryRun {
+		return nil
+	}
+
+	logboek.Context(ctx).Default().LogOptionalLn()
+	logboek.Context(ctx).Default().LogFDetails(`Migration to werf v1.2 is almost done, please run "werf converge" command to bring resources to the state which is described in the repository .helm/templates directory.
+
+Make sure "werf converge" command uses release name %q and namespace %q!
+`, targetReleaseName, targetNamespace)
+
+	return nil
+}
+
+func runMigrate2To3AllReleases(ctx context.Context, kubeConfigOptions kube.KubeConfigOptions, registryClientHandler *cmd_helm.RegistryClientHandle, migrateOpts maintenance_helper.Migrate2To3Options) error {
+	// Helm 3 storage is not used to list helm 2 releases.
+	listHelper := maintenance_helper.NewMaintenanceHelper(nil, newMigrate2To3MaintenanceHelperOptions(kubeConfigOptions))
+
+	if err := checkHelm2StorageAvailable(ctx, listHelper); err != nil {
 		return err
 	}
 
-	maintenanceOpts := maintenance_helper.MaintenanceHelperOptions{
+	releasesData, err := listHelper.GetHelm2ReleasesData(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(releasesData) == 0 {
+		logboek.Context(ctx).Default().LogFDetails("No helm 2 releases found\n")
+		return nil
+	}
+
+	type migratedRelease struct {
+		Name      string
+		Namespace string
+	}
+	var migratedReleases []migratedRelease
+
+	for _, releaseData := range releasesData {
+		releaseName := releaseData.Release.Name
+
+		targetNamespace := migrate2ToCmdData.TargetNamespace
+		if targetNamespace == "" {
+			targetNamespace = releaseData.Release.Namespace
+		}
+		if targetNamespace == "" {
+			targetNamespace = "default"
+		}
+
+		maintenanceHelper, err := newMigrate2To3MaintenanceHelper(ctx, targetNamespace, kubeConfigOptions, registryClientHandler)
+		if err != nil {
+			return err
+		}
+
+		if err := maintenance_helper.Migrate2To3(ctx, releaseName, releaseName, targetNamespace, maintenanceHelper, migrateOpts); err != nil {
+			return fmt.Errorf("error migrating helm 2 release %q: %s", releaseName, err)
+		}
+
+		migratedReleases = append(migratedReleases, migratedRelease{Name: releaseName, Namespace: targetNamespace})
+	}
+
+	if migrateOpts.DryRun {
+		return nil
+	}
+
+	logboek.Context(ctx).Default().LogOptionalLn()
+	logboek.Context(ctx).Default().LogFDetails("Migration to werf v1.2 is almost done, please run \"werf converge\" command for each project to bring resources to the state which is described in the repository .helm/templates directory.\n\n")
+	logboek.Context(ctx).Default().LogFDetails("Make sure \"werf converge\" command uses the following release names and namespaces:\n")
+	for _, rel := range migratedReleases {
+		logboek.Context(ctx).Default().LogFDetails("  - release %q, namespace %q\n", rel.Name, rel.Namespace)
+	}
+
+	return nil
+}
+
+func newMigrate2To3MaintenanceHelperOptions(kubeConfigOptions kube.KubeConfigOptions) maintenance_helper.MaintenanceHelperOptions {
+	return maintenance_helper.MaintenanceHelperOptions{
 		Helm2ReleaseStorageNamespace: migrate2ToCmdData.Helm2ReleaseStorageNamespace,
 		Helm2ReleaseStorageType:      migrate2ToCmdData.Helm2ReleaseStorageType,
 		KubeConfigOptions:            kubeConfigOptions,
+		DiscoverHelm2ReleaseStorages: true,
 	}
+}
 
-	maintenanceHelper := maintenance_helper.NewMaintenanceHelper(actionConfig, maintenanceOpts)
+func newMigrate2To3MaintenanceHelper(ctx context.Context, namespace string, kubeConfigOptions kube.KubeConfigOptions, registryClientHandler *cmd_helm.RegistryClientHandle) (*maintenance_helper.MaintenanceHelper, error) {
+	actionConfig := new(action.Configuration)
+	if err := helm.InitActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, cmd_helm.Settings, registryClientHandler, actionConfig, helm.InitActionConfigOptions{KubeConfigOptions: kubeConfigOptions}); err != nil {
+		return nil, err
+	}
 
+	return maintenance_helper.NewMaintenanceHelper(actionConfig, newMigrate2To3MaintenanceHelperOptions(kubeConfigOptions)), nil
+}
+
+func checkHelm2StorageAvailable(ctx context.Context, maintenanceHelper *maintenance_helper.MaintenanceHelper) error {
 	if available, err := maintenanceHelper.CheckHelm2StorageAvailable(ctx); err != nil {
 		return err
 	} else if !available {
@@ -155,15 +269,5 @@ func runMigrate2To3(ctx context.Context) error {
 	}
 	logboek.Context(ctx).Default().LogFDetails(" + Helm 2 release storage is available\n")
 
-	if err := maintenance_helper.Migrate2To3(ctx, existingReleaseName, targetReleaseName, targetNamespace, maintenanceHelper); err != nil {
-		return err
-	}
-
-	logboek.Context(ctx).Default().LogOptionalLn()
-	logboek.Context(ctx).Default().LogFDetails(`Migration to werf v1.2 is almost done, please run "werf converge" command to bring resources to the state which is described in the repository .helm/templates directory.
-
-Make sure "werf converge" command uses release name %q and namespace %q!
-`, targetReleaseName, targetNamespace)
-
 	return nil
 }
@@ -0,0 +1,42 @@
+package helm
+
+import (
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/helm/maintenance_helper"
+)
+
+var repairReleaseCmdData struct {
+	DryRun bool
+}
+
+func NewRepairReleaseCmd(actionConfig *action.Configuration) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "repair-release RELEASE_NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 "Rebuild the stored manifest of the helm 3 release from the cluster state",
+		Long: common.GetLongCommandDescription(`Rebuild the stored manifest of the last helm 3 release revision from the current cluster state.
+
+The stored manifest is used in the three-way merge on the next deploy, so the manifest which drifted from the cluster state or is corrupted results in wrong patches or resources recreation. Each stored resource is replaced with the live object pruned to the fields of the stored resource. Resources not found in the cluster are kept as is. The rebuilt manifest is saved as the new release revision, so the previous revision is kept in the release history.
+
+Use --dry-run option to print the diff of the stored and the rebuilt manifests. It is recommended to save the release with the "werf helm export-release" command before the repair.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ValidateArgumentCount(1, args, cmd); err != nil {
+				return err
+			}
+
+			maintenanceHelper := maintenance_helper.NewMaintenanceHelper(actionConfig, maintenance_helper.MaintenanceHelperOptions{})
+
+			return maintenanceHelper.RepairHelm3Release(common.BackgroundContext(), args[0], maintenance_helper.RepairHelm3ReleaseOptions{
+				DryRun: repairReleaseCmdData.DryRun,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&repairReleaseCmdData.DryRun, "dry-run", "", common.GetBoolEnvironmentDefaultFalse("WERF_DRY_RUN"), "Print the diff of the stored and the rebuilt manifests without saving the release (default $WERF_DRY_RUN)")
+
+	return cmd
+}
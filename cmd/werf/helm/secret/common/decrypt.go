@@ -69,12 +69,12 @@ func secretDecrypt(ctx context.Context, m *secrets_manager.SecretsManager, worki
 	encodedData = bytes.TrimSpace(encodedData)
 
 	if options.Values {
-		data, err = encoder.DecryptYamlData(encodedData)
+		data, err = encoder.DecryptYamlFile(options.FilePath, encodedData)
 		if err != nil {
 			return err
 		}
 	} else {
-		data, err = encoder.Decrypt(encodedData)
+		data, err = encoder.DecryptFile(options.FilePath, encodedData)
 		if err != nil {
 			return err
 		}
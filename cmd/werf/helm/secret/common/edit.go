@@ -40,6 +40,11 @@ func SecretEdit(ctx context.Context, m *secrets_manager.SecretsManager, workingD
 		return err
 	}
 
+	// the existing file is encrypted again with the same backend
+	if encodedData != nil {
+		encoder = encoder.WithSopsEncryption(secret.IsSopsEncryptedData(encodedData))
+	}
+
 	tmpFilePath := filepath.Join(werf.GetTmpDir(), fmt.Sprintf("werf-edit-secret-%s.yaml", uuid.NewV4().String()))
 	defer os.RemoveAll(tmpFilePath)
 
@@ -70,12 +75,12 @@ func SecretEdit(ctx context.Context, m *secrets_manager.SecretsManager, workingD
 
 		var newEncodedData []byte
 		if values {
-			newEncodedData, err = encoder.EncryptYamlData(newData)
+			newEncodedData, err = encoder.EncryptYamlFile(filePath, newData)
 			if err != nil {
 				return err
 			}
 		} else {
-			newEncodedData, err = encoder.Encrypt(newData)
+			newEncodedData, err = encoder.EncryptFile(filePath, newData)
 			if err != nil {
 				return err
 			}
@@ -84,7 +89,8 @@ func SecretEdit(ctx context.Context, m *secrets_manager.SecretsManager, workingD
 		}
 
 		if !bytes.Equal(data, newData) {
-			if values {
+			// sops encrypts the whole values file and authenticates it with the mac, so the unchanged values cannot be preserved
+			if values && !encoder.IsSopsEncryption() {
 				newEncodedData, err = prepareResultValuesData(data, encodedData, newData, newEncodedData)
 				if err != nil {
 					return err
@@ -140,12 +146,12 @@ func readEditedFile(filePath string, values bool, encoder *secret.YamlEncoder) (
 		encodedData = bytes.TrimSpace(encodedData)
 
 		if values {
-			data, err = encoder.DecryptYamlData(encodedData)
+			data, err = encoder.DecryptYamlFile(filePath, encodedData)
 			if err != nil {
 				return nil, nil, err
 			}
 		} else {
-			data, err = encoder.Decrypt(encodedData)
+			data, err = encoder.DecryptFile(filePath, encodedData)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -63,13 +63,19 @@ func secretEncrypt(ctx context.Context, m *secrets_manager.SecretsManager, worki
 		return ExpectedFilePathOrPipeError()
 	}
 
+	// the path of the encrypted file is used by sops to select the creation rule
+	encodedFilePath := options.OutputFilePath
+	if encodedFilePath == "" {
+		encodedFilePath = options.FilePath
+	}
+
 	if options.Values {
-		encodedData, err = encoder.EncryptYamlData(data)
+		encodedData, err = encoder.EncryptYamlFile(encodedFilePath, data)
 		if err != nil {
 			return err
 		}
 	} else {
-		encodedData, err = encoder.Encrypt(data)
+		encodedData, err = encoder.EncryptFile(encodedFilePath, data)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,427 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/deploy/secret_values_provider"
+	"github.com/werf/werf/pkg/deploy/secrets_manager"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/secret"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type RotateOptions struct {
+	// OldKey is the werf AES key the files are currently encrypted with
+	OldKey string
+	// NewKey is the werf AES key to encrypt the files with, the key from $WERF_SECRET_KEY or .werf_secret_key file is used by default
+	NewKey string
+	// NewSecretsBackend is the encryption backend to convert the files into, the backend of the files is kept by default
+	NewSecretsBackend string
+	// DryRun only prints the files to be re-encrypted
+	DryRun bool
+	// SecretValuesPaths are the additional secret values files
+	SecretValuesPaths []string
+}
+
+// Rotate re-encrypts all secret files of the project: the chart secret files, the secret values files of the werf.yaml Dockerfile secrets
+// and the specified secret values files.
+func Rotate(ctx context.Context, cmd *cobra.Command, commonCmdData *common.CmdData, opts RotateOptions) error {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	werfConfigPath, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, commonCmdData, giterminismManager, common.GetWerfConfigOptions(commonCmdData, true))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	helmChartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	if err != nil {
+		return fmt.Errorf("getting helm chart dir failed: %s", err)
+	}
+
+	if opts.OldKey == "" {
+		common.PrintHelp(cmd)
+		return fmt.Errorf("--old-key=KEY param or $WERF_OLD_SECRET_KEY required")
+	}
+
+	if err := secrets_manager.ValidateSecretsBackend(opts.NewSecretsBackend); err != nil {
+		common.PrintHelp(cmd)
+		return fmt.Errorf("bad --new-secrets-backend: %s", err)
+	}
+
+	if opts.NewSecretsBackend == secrets_manager.SecretsBackendSops && opts.NewKey != "" {
+		common.PrintHelp(cmd)
+		return fmt.Errorf("--new-key cannot be used with --new-secrets-backend=sops")
+	}
+
+	oldEncoder, err := newAesYamlEncoder(opts.OldKey)
+	if err != nil {
+		return fmt.Errorf("check old encryption key: %s", err)
+	}
+
+	// the files encrypted with sops are decrypted only to convert them into werf AES encryption
+	regenerateSopsFiles := opts.NewSecretsBackend == secrets_manager.SecretsBackendAes
+	if regenerateSopsFiles {
+		oldEncoder.WithSops(giterminismManager.ProjectDir(), false)
+	}
+
+	var newEncoder *secret.YamlEncoder
+	switch {
+	case opts.NewSecretsBackend == secrets_manager.SecretsBackendSops:
+		newEncoder, err = secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{SecretsBackend: secrets_manager.SecretsBackendSops}).GetYamlEncoder(ctx, giterminismManager.ProjectDir())
+		if err != nil {
+			return err
+		}
+	case opts.NewKey != "":
+		newEncoder, err = newAesYamlEncoder(opts.NewKey)
+		if err != nil {
+			return fmt.Errorf("check new encryption key: %s", err)
+		}
+	default:
+		newEncoder, err = secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{SecretsBackend: secrets_manager.SecretsBackendAes}).GetYamlEncoder(ctx, giterminismManager.ProjectDir())
+		if err != nil {
+			common.PrintHelp(cmd)
+			return err
+		}
+	}
+
+	secretValuesPaths := append(getDockerfileSecretValuesPaths(werfConfig, giterminismManager.ProjectDir()), opts.SecretValuesPaths...)
+
+	regeneratedSecrets, err := RegenerateSecrets(newEncoder, oldEncoder, regenerateSopsFiles, filepath.Join(giterminismManager.ProjectDir(), helmChartDir), secretValuesPaths...)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		for _, filePath := range regeneratedSecrets.FilesPaths() {
+			logboek.Context(ctx).Default().LogF("File %q would be re-encrypted\n", filePath)
+		}
+
+		return nil
+	}
+
+	return regeneratedSecrets.Save()
+}
+
+// getDockerfileSecretValuesPaths returns the secret values files used by the Dockerfile secrets of werf.yaml images,
+// the default chart secret values file is used by the secrets without the file specified and is processed with the chart secret files.
+func getDockerfileSecretValuesPaths(werfConfig *config.WerfConfig, projectDir string) []string {
+	var paths []string
+	for _, image := range werfConfig.ImagesFromDockerfile {
+		for _, dockerfileSecret := range image.Secrets {
+			if dockerfileSecret.SecretValuesFile != "" {
+				paths = append(paths, filepath.Join(projectDir, dockerfileSecret.SecretValuesFile))
+			}
+		}
+	}
+
+	return paths
+}
+
+func newAesYamlEncoder(key string) (*secret.YamlEncoder, error) {
+	enc, err := secret.NewAesEncoder([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.NewYamlEncoder(enc), nil
+}
+
+// RegeneratedSecrets contains the original and the re-encrypted data of the secret files by the file path.
+type RegeneratedSecrets struct {
+	OriginalFilesData    map[string][]byte
+	RegeneratedFilesData map[string][]byte
+}
+
+// RegenerateSecrets decrypts with the old key and encrypts with the new key the raw secret files in the chart secret folder,
+// the chart secret values file secret-values.yaml and the specified additional secret values files.
+// The files encrypted with sops are regenerated only if regenerateSopsFiles is set, their keys are managed by sops.
+// The secret values fetched from the external secret managers are skipped.
+func RegenerateSecrets(newEncoder, oldEncoder *secret.YamlEncoder, regenerateSopsFiles bool, helmChartDir string, secretValuesPaths ...string) (*RegeneratedSecrets, error) {
+	var secretFilesPaths []string
+
+	isHelmChartDirExist, err := util.FileExists(helmChartDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if isHelmChartDirExist {
+		defaultSecretValuesPath := filepath.Join(helmChartDir, "secret-values.yaml")
+		isDefaultSecretValuesExist, err := util.FileExists(defaultSecretValuesPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if isDefaultSecretValuesExist {
+			secretValuesPaths = append(secretValuesPaths, defaultSecretValuesPath)
+		}
+
+		secretDirectory := filepath.Join(helmChartDir, "secret")
+		isSecretDirectoryExist, err := util.FileExists(secretDirectory)
+		if err != nil {
+			return nil, err
+		}
+
+		if isSecretDirectoryExist {
+			err = filepath.Walk(secretDirectory,
+				func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+
+					fileInfo, err := os.Stat(path)
+					if err != nil {
+						return err
+					}
+
+					if !fileInfo.IsDir() {
+						secretFilesPaths = append(secretFilesPaths, path)
+					}
+
+					return nil
+				})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var secretValuesFilesPaths []string
+	for _, path := range secretValuesPaths {
+		if secret_values_provider.IsSecretValuesURI(path) {
+			logboek.Default().LogF("Skipping %q: the secret values are stored in the external secret manager\n", path)
+			continue
+		}
+
+		secretValuesFilesPaths = append(secretValuesFilesPaths, path)
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	secretFilesData, err := readFilesToDecode(secretFilesPaths, pwd)
+	if err != nil {
+		return nil, err
+	}
+
+	secretValuesFilesData, err := readFilesToDecode(secretValuesFilesPaths, pwd)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RegeneratedSecrets{
+		OriginalFilesData:    map[string][]byte{},
+		RegeneratedFilesData: map[string][]byte{},
+	}
+
+	for _, filesData := range []map[string][]byte{secretFilesData, secretValuesFilesData} {
+		for filePath, fileData := range filesData {
+			if !regenerateSopsFiles && secret.IsSopsEncryptedData(fileData) {
+				logboek.Default().LogF("Skipping file %q: the file is encrypted with sops\n", filePath)
+				delete(filesData, filePath)
+				continue
+			}
+
+			res.OriginalFilesData[filePath] = fileData
+		}
+	}
+
+	if err := regenerateSecrets(secretFilesData, res.RegeneratedFilesData, oldEncoder.DecryptFile, newEncoder.EncryptFile); err != nil {
+		return nil, err
+	}
+
+	if err := regenerateSecrets(secretValuesFilesData, res.RegeneratedFilesData, oldEncoder.DecryptYamlFile, newEncoder.EncryptYamlFile); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Save replaces all regenerated files: the new data is written and synced into the temporary files next to the original files first,
+// then the temporary files replace the original files. If any original file cannot be replaced, the already replaced files are restored,
+// so that the secrets are never left encrypted with different keys.
+func (s *RegeneratedSecrets) Save() error {
+	tmpFilesPaths := map[string]string{}
+	defer func() {
+		for _, tmpFilePath := range tmpFilesPaths {
+			_ = os.Remove(tmpFilePath)
+		}
+	}()
+
+	for _, filePath := range s.FilesPaths() {
+		tmpFilePath, err := writeTmpFile(filePath, append(bytes.TrimSpace(s.RegeneratedFilesData[filePath]), []byte("\n")...))
+		if err != nil {
+			return err
+		}
+		tmpFilesPaths[filePath] = tmpFilePath
+	}
+
+	var savedFilesPaths []string
+	for _, filePath := range s.FilesPaths() {
+		if err := logboek.LogProcess(fmt.Sprintf("Saving file %q", filePath)).DoError(func() error {
+			return os.Rename(tmpFilesPaths[filePath], filePath)
+		}); err != nil {
+			if restoreErr := s.restore(savedFilesPaths); restoreErr != nil {
+				return fmt.Errorf("%s\nunable to restore the original files: %s", err, restoreErr)
+			}
+
+			return err
+		}
+
+		delete(tmpFilesPaths, filePath)
+		savedFilesPaths = append(savedFilesPaths, filePath)
+	}
+
+	return nil
+}
+
+func (s *RegeneratedSecrets) restore(filesPaths []string) error {
+	for _, filePath := range filesPaths {
+		tmpFilePath, err := writeTmpFile(filePath, s.OriginalFilesData[filePath])
+		if err != nil {
+			return err
+		}
+
+		if err := os.Rename(tmpFilePath, filePath); err != nil {
+			_ = os.Remove(tmpFilePath)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FilesPaths returns the sorted paths of the regenerated files.
+func (s *RegeneratedSecrets) FilesPaths() []string {
+	var filesPaths []string
+	for filePath := range s.RegeneratedFilesData {
+		filesPaths = append(filesPaths, filePath)
+	}
+	sort.Strings(filesPaths)
+
+	return filesPaths
+}
+
+// writeTmpFile writes the data into the temporary file with the mode of the file in the same dir, so that the file could be replaced with rename.
+func writeTmpFile(filePath string, data []byte) (string, error) {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filePath), fmt.Sprintf(".%s.*", filepath.Base(filePath)))
+	if err != nil {
+		return "", err
+	}
+
+	if err := func() error {
+		defer tmpFile.Close()
+
+		if _, err := tmpFile.Write(data); err != nil {
+			return fmt.Errorf("unable to write %q: %s", tmpFile.Name(), err)
+		}
+
+		if err := tmpFile.Sync(); err != nil {
+			return fmt.Errorf("unable to sync %q: %s", tmpFile.Name(), err)
+		}
+
+		return tmpFile.Chmod(fileInfo.Mode())
+	}(); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+func regenerateSecrets(filesData, regeneratedFilesData map[string][]byte, decodeFunc, encodeFunc func(string, []byte) ([]byte, error)) error {
+	for filePath, fileData := range filesData {
+		err := logboek.LogProcess(fmt.Sprintf("Regenerating file %q", filePath)).
+			DoError(func() error {
+				data, err := decodeFunc(filePath, bytes.TrimSpace(fileData))
+				if err != nil {
+					return fmt.Errorf("check old encryption key and file data: %s", err)
+				}
+
+				resultData, err := encodeFunc(filePath, data)
+				if err != nil {
+					return err
+				}
+
+				regeneratedFilesData[filePath] = resultData
+
+				return nil
+			})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readFilesToDecode reads the files as is by the path relative to the working directory, the file specified several times is read once.
+func readFilesToDecode(filePaths []string, pwd string) (map[string][]byte, error) {
+	filesData := map[string][]byte{}
+	for _, filePath := range filePaths {
+		if filepath.IsAbs(filePath) {
+			relFilePath, err := filepath.Rel(pwd, filePath)
+			if err != nil {
+				return nil, err
+			}
+			filePath = relFilePath
+		}
+		filePath = filepath.Clean(filePath)
+
+		if _, exist := filesData[filePath]; exist {
+			continue
+		}
+
+		fileData, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		filesData[filePath] = fileData
+	}
+
+	return filesData, nil
+}
@@ -0,0 +1,197 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/secret"
+)
+
+func newTestYamlEncoder(t *testing.T) *secret.YamlEncoder {
+	key, err := secret.GenerateAesSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoder, err := newAesYamlEncoder(string(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encoder
+}
+
+// setupRotateTestDir creates the chart with the secret files encrypted with the encoder and changes the working directory into the project dir.
+func setupRotateTestDir(t *testing.T, encoder *secret.YamlEncoder) string {
+	dir, err := ioutil.TempDir("", "werf-rotate-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile := func(relPath string, data []byte) {
+		path := filepath.Join(dir, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	secretFileData, err := encoder.EncryptFile("", []byte("secret file data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(".helm/secret/nested/file", append(secretFileData, '\n'))
+
+	for _, relPath := range []string{".helm/secret-values.yaml", "docker-secret-values.yaml"} {
+		secretValuesData, err := encoder.EncryptYamlData([]byte("key: " + relPath + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(relPath, secretValuesData)
+	}
+
+	writeFile(".helm/secret/sops-file", []byte("key: ENC[AES256_GCM,data:Tr7o=,iv:1=,tag:2=,type:str]\nsops:\n  mac: ENC[AES256_GCM,data:3=,iv:4=,tag:5=,type:str]\n  version: 3.7.1\n"))
+
+	prevWorkingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = os.Chdir(prevWorkingDir)
+		_ = os.RemoveAll(dir)
+	})
+
+	return dir
+}
+
+func TestRegenerateSecrets(t *testing.T) {
+	oldEncoder := newTestYamlEncoder(t)
+	newEncoder := newTestYamlEncoder(t)
+	dir := setupRotateTestDir(t, oldEncoder)
+
+	regeneratedSecrets, err := RegenerateSecrets(newEncoder, oldEncoder, false, filepath.Join(dir, ".helm"), filepath.Join(dir, "docker-secret-values.yaml"), "docker-secret-values.yaml", "vault://secret/data/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedFilesPaths := []string{
+		filepath.Join(".helm", "secret-values.yaml"),
+		filepath.Join(".helm", "secret", "nested", "file"),
+		"docker-secret-values.yaml",
+	}
+	if !reflect.DeepEqual(regeneratedSecrets.FilesPaths(), expectedFilesPaths) {
+		t.Fatalf("\n[EXPECTED]: %v\n[GOT]: %v", expectedFilesPaths, regeneratedSecrets.FilesPaths())
+	}
+
+	data, err := newEncoder.DecryptFile("", regeneratedSecrets.RegeneratedFilesData[expectedFilesPaths[1]])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "secret file data" {
+		t.Errorf("unexpected decrypted secret file data: %q", data)
+	}
+
+	data, err = newEncoder.DecryptYamlData(regeneratedSecrets.RegeneratedFilesData["docker-secret-values.yaml"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != "key: docker-secret-values.yaml" {
+		t.Errorf("unexpected decrypted secret values data: %q", data)
+	}
+
+	if _, err := RegenerateSecrets(newEncoder, newTestYamlEncoder(t), false, filepath.Join(dir, ".helm")); err == nil || !strings.Contains(err.Error(), "check old encryption key") {
+		t.Errorf("expected old key error, got: %v", err)
+	}
+}
+
+func TestRegeneratedSecrets_Save(t *testing.T) {
+	dir := setupRotateTestDir(t, newTestYamlEncoder(t))
+
+	if err := ioutil.WriteFile("a-file", []byte("a original\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	regeneratedSecrets := &RegeneratedSecrets{
+		OriginalFilesData:    map[string][]byte{"a-file": []byte("a original\n")},
+		RegeneratedFilesData: map[string][]byte{"a-file": []byte("a regenerated")},
+	}
+
+	if err := regeneratedSecrets.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile("a-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a regenerated\n" {
+		t.Errorf("unexpected saved data: %q", data)
+	}
+
+	info, err := os.Stat("a-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("expected file mode to be kept, got %s", info.Mode())
+	}
+
+	assertNoTmpFiles(t, dir)
+}
+
+func TestRegeneratedSecrets_SaveRestoresReplacedFilesOnError(t *testing.T) {
+	dir := setupRotateTestDir(t, newTestYamlEncoder(t))
+
+	if err := ioutil.WriteFile("a-file", []byte("a original\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rename of the file over the non-empty directory fails
+	if err := os.MkdirAll(filepath.Join("z-file", "nested"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	regeneratedSecrets := &RegeneratedSecrets{
+		OriginalFilesData:    map[string][]byte{"a-file": []byte("a original\n"), "z-file": nil},
+		RegeneratedFilesData: map[string][]byte{"a-file": []byte("a regenerated"), "z-file": []byte("z regenerated")},
+	}
+
+	if err := regeneratedSecrets.Save(); err == nil {
+		t.Fatal("expected save error")
+	}
+
+	data, err := ioutil.ReadFile("a-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a original\n" {
+		t.Errorf("expected the replaced file to be restored, got: %q", data)
+	}
+
+	assertNoTmpFiles(t, dir)
+}
+
+func assertNoTmpFiles(t *testing.T, dir string) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".a-file.") || strings.HasPrefix(info.Name(), ".z-file.") {
+			t.Errorf("unexpected tmp file %q", info.Name())
+		}
+	}
+}
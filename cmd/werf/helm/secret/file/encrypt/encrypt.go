@@ -12,6 +12,7 @@ import (
 	"github.com/werf/werf/pkg/deploy/secrets_manager"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/werf"
 )
 
@@ -27,7 +28,8 @@ func NewCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		Short:                 "Encrypt file data",
 		Long: common.GetLongCommandDescription(`Encrypt data from FILE_PATH or pipe.
-Encryption key should be in $WERF_SECRET_KEY or .werf_secret_key file`),
+Encryption key should be in $WERF_SECRET_KEY or .werf_secret_key file.
+The data is encrypted with sops instead if the sops secrets backend is selected with the --secrets-backend option or the deploy.secretsBackend werf.yaml directive`),
 		Example: `  # Encrypt and save result in file
   $ werf helm secret file encrypt tls.crt -o .helm/secret/tls.crt`,
 		Annotations: map[string]string{
@@ -60,9 +62,15 @@ Encryption key should be in $WERF_SECRET_KEY or .werf_secret_key file`),
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
 
+	common.SetupSecretsBackend(&commonCmdData, cmd)
+
 	common.SetupLogOptions(&commonCmdData, cmd)
 
 	cmd.Flags().StringVarP(&cmdData.OutputFilePath, "output-file-path", "o", "", "Write to file instead of stdout")
@@ -71,7 +79,7 @@ Encryption key should be in $WERF_SECRET_KEY or .werf_secret_key file`),
 }
 
 func runSecretEncrypt(ctx context.Context, filePath string) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -84,7 +92,16 @@ func runSecretEncrypt(ctx context.Context, filePath string) error {
 		return err
 	}
 
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	secretsBackend, err := common.GetProjectSecretsBackend(ctx, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
 	workingDir := common.GetWorkingDir(&commonCmdData)
 
-	return secret_common.SecretFileEncrypt(ctx, secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{}), workingDir, filePath, cmdData.OutputFilePath)
+	return secret_common.SecretFileEncrypt(ctx, secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{SecretsBackend: secretsBackend}), workingDir, filePath, cmdData.OutputFilePath)
 }
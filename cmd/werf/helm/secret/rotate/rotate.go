@@ -0,0 +1,86 @@
+package secret
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	secret_common "github.com/werf/werf/cmd/werf/helm/secret/common"
+)
+
+var cmdData struct {
+	OldKey            string
+	NewKey            string
+	NewSecretsBackend string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "rotate [EXTRA_SECRET_VALUES_FILE_PATH...]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Re-encrypt all secret files with the new secret key",
+		Long: common.GetLongCommandDescription(`Re-encrypt all secret files with the new secret key.
+
+The old key should be specified with the --old-key option or $WERF_OLD_SECRET_KEY.
+The new key should be specified with the --new-key option, otherwise the key from the $WERF_SECRET_KEY or .werf_secret_key file is used.
+
+Command will decrypt data with the old key, encrypt it with the new key and rewrite files:
+* standard raw secret files in the .helm/secret folder;
+* standard secret values yaml file .helm/secret-values.yaml;
+* secret values yaml files of the Dockerfile secrets of werf.yaml images;
+* secret values yaml files specified with the --secret-values option or $WERF_SECRET_VALUES_*;
+* additional secret values yaml files specified with EXTRA_SECRET_VALUES_FILE_PATH params.
+
+All files are decrypted and encrypted before any file is rewritten, and the files are replaced only if all new files are written successfully, so that the secrets are never left encrypted with different keys.
+
+The files encrypted with sops are skipped, their keys are managed by sops.
+The files are converted into the other encryption backend only if the --new-secrets-backend option is specified:
+all werf AES encrypted files are encrypted with sops for the sops backend, the files encrypted with sops are encrypted with the new key for the aes backend.
+
+With the --dry-run option all files are decrypted and encrypted, but only the list of the files to be rewritten is printed.`),
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(common.WerfSecretKey, common.WerfOldSecretKey),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return secret_common.Rotate(common.BackgroundContext(), cmd, &commonCmdData, secret_common.RotateOptions{
+				OldKey:            cmdData.OldKey,
+				NewKey:            cmdData.NewKey,
+				NewSecretsBackend: cmdData.NewSecretsBackend,
+				DryRun:            *commonCmdData.DryRun,
+				SecretValuesPaths: append(common.GetSecretValues(&commonCmdData), args...),
+			})
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupSecretValues(&commonCmdData, cmd)
+	common.SetupDryRun(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.OldKey, "old-key", "", os.Getenv("WERF_OLD_SECRET_KEY"), "Secret key the files are currently encrypted with ($WERF_OLD_SECRET_KEY by default)")
+	cmd.Flags().StringVarP(&cmdData.NewKey, "new-key", "", "", "Secret key to re-encrypt the files with (by default the key from $WERF_SECRET_KEY or .werf_secret_key file is used)")
+	cmd.Flags().StringVarP(&cmdData.NewSecretsBackend, "new-secrets-backend", "", "", "Convert the files into the specified encryption backend: aes or sops (by default the files are re-encrypted with the new key keeping their backend)")
+
+	return cmd
+}
@@ -81,7 +81,7 @@ Encryption key should be in $WERF_SECRET_KEY or .werf_secret_key file`),
 }
 
 func runSecretDecrypt(ctx context.Context, filePath string) error {
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -1,7 +1,12 @@
 package cleanup
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
 
 	"github.com/spf13/cobra"
 
@@ -23,8 +28,16 @@ var commonCmdData common.CmdData
 
 var cmdData struct {
 	Force bool
+
+	AllowedDockerImagesSize   string
+	DockerImagesMaxUnusedTime string
+
+	Watch       bool
+	WatchPeriod string
 }
 
+const defaultWatchPeriod = 10 * time.Minute
+
 func NewCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "cleanup",
@@ -38,7 +51,9 @@ The data include:
   * Remote git clones cache.
   * Git worktree cache.
 
-It is safe to run this command periodically by automated cleanup job in parallel with other werf commands such as build, converge and cleanup.`),
+Least recently used werf images are deleted when the docker storage volume usage exceeds the allowed percentage, when the total size of werf images exceeds the --allowed-docker-images-size or when the images are not used longer than --docker-images-max-unused-time.
+
+It is safe to run this command periodically by automated cleanup job in parallel with other werf commands such as build, converge and cleanup. With the --watch option the command runs the cleanup periodically until interrupted.`),
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
@@ -49,7 +64,11 @@ It is safe to run this command periodically by automated cleanup job in parallel
 			}
 			common.LogVersion()
 
-			return common.LogRunningTime(runGC)
+			ctx := common.GetContext(cmd)
+
+			return common.LogRunningTime(func() error {
+				return runGC(ctx)
+			})
 		},
 	}
 
@@ -74,18 +93,22 @@ It is safe to run this command periodically by automated cleanup job in parallel
 
 	cmd.Flags().BoolVarP(&cmdData.Force, "force", "", common.GetBoolEnvironmentDefaultFalse("WERF_FORCE"), "Force deletion of images which are being used by some containers (default $WERF_FORCE)")
 
+	cmd.Flags().StringVarP(&cmdData.AllowedDockerImagesSize, "allowed-docker-images-size", "", os.Getenv("WERF_ALLOWED_DOCKER_IMAGES_SIZE"), "Set allowed total size of local werf images (e.g. 20GiB or 500MB), least recently used images are deleted until the size becomes below the limit (default $WERF_ALLOWED_DOCKER_IMAGES_SIZE or no limit)")
+	cmd.Flags().StringVarP(&cmdData.DockerImagesMaxUnusedTime, "docker-images-max-unused-time", "", os.Getenv("WERF_DOCKER_IMAGES_MAX_UNUSED_TIME"), "Delete local werf images which are not used longer than the specified duration (e.g. 72h or 30m) (default $WERF_DOCKER_IMAGES_MAX_UNUSED_TIME or no limit)")
+
+	cmd.Flags().BoolVarP(&cmdData.Watch, "watch", "", common.GetBoolEnvironmentDefaultFalse("WERF_WATCH"), "Run the cleanup periodically until interrupted, the failed cleanup is logged and retried on the next run (default $WERF_WATCH)")
+	cmd.Flags().StringVarP(&cmdData.WatchPeriod, "watch-period", "", os.Getenv("WERF_WATCH_PERIOD"), fmt.Sprintf("Period of the cleanup runs in the --watch mode (default $WERF_WATCH_PERIOD or %s)", defaultWatchPeriod))
+
 	return cmd
 }
 
-func runGC() error {
-	ctx := common.BackgroundContext()
-
+func runGC(ctx context.Context) error {
 	projectName := *commonCmdData.ProjectName
 	if projectName != "" {
 		return fmt.Errorf("no functionality for cleaning a certain project is implemented (--project-name=%s)", projectName)
 	}
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -120,9 +143,21 @@ func runGC() error {
 	}
 	ctx = ctxWithDockerCli
 
+	localImagesPolicy, err := getLocalImagesPolicy()
+	if err != nil {
+		return err
+	}
+
+	watchPeriod, err := getWatchPeriod()
+	if err != nil {
+		return err
+	}
+
 	logboek.LogOptionalLn()
 
 	hostCleanupOptions := host_cleaning.HostCleanupOptions{
+		LocalImagesPolicy: localImagesPolicy,
+
 		DryRun: *commonCmdData.DryRun,
 		Force:  cmdData.Force,
 		AllowedDockerStorageVolumeUsagePercentage:       commonCmdData.AllowedDockerStorageVolumeUsage,
@@ -132,5 +167,67 @@ func runGC() error {
 		DockerServerStoragePath:                         *commonCmdData.DockerServerStoragePath,
 	}
 
-	return host_cleaning.RunHostCleanup(ctx, hostCleanupOptions)
+	if !cmdData.Watch {
+		return host_cleaning.RunHostCleanup(ctx, hostCleanupOptions)
+	}
+
+	return runPeriodically(ctx, watchPeriod, func(ctx context.Context) error {
+		return host_cleaning.RunHostCleanup(ctx, hostCleanupOptions)
+	})
+}
+
+// runPeriodically runs the cleanup and then runs it again in the period after the previous run is finished until the context is done.
+// The failed cleanup is logged and retried on the next run.
+func runPeriodically(ctx context.Context, period time.Duration, cleanup func(ctx context.Context) error) error {
+	for {
+		if err := cleanup(ctx); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: host cleanup failed: %s\n", err)
+		}
+
+		logboek.Context(ctx).Default().LogF("Next host cleanup in %s\n", period)
+		logboek.Context(ctx).LogOptionalLn()
+
+		timer := time.NewTimer(period)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func getWatchPeriod() (time.Duration, error) {
+	if cmdData.WatchPeriod == "" {
+		return defaultWatchPeriod, nil
+	}
+
+	watchPeriod, err := time.ParseDuration(cmdData.WatchPeriod)
+	if err != nil || watchPeriod <= 0 {
+		return 0, fmt.Errorf("bad --watch-period value %q: positive duration expected", cmdData.WatchPeriod)
+	}
+
+	return watchPeriod, nil
+}
+
+func getLocalImagesPolicy() (host_cleaning.LocalImagesPolicy, error) {
+	var policy host_cleaning.LocalImagesPolicy
+
+	if cmdData.AllowedDockerImagesSize != "" {
+		size, err := humanize.ParseBytes(cmdData.AllowedDockerImagesSize)
+		if err != nil || size == 0 {
+			return policy, fmt.Errorf("bad --allowed-docker-images-size value %q: positive size expected (e.g. 20GiB or 500MB)", cmdData.AllowedDockerImagesSize)
+		}
+		policy.AllowedSizeBytes = size
+	}
+
+	if cmdData.DockerImagesMaxUnusedTime != "" {
+		duration, err := time.ParseDuration(cmdData.DockerImagesMaxUnusedTime)
+		if err != nil || duration <= 0 {
+			return policy, fmt.Errorf("bad --docker-images-max-unused-time value %q: positive duration expected (e.g. 72h or 30m)", cmdData.DockerImagesMaxUnusedTime)
+		}
+		policy.MaxUnusedTime = duration
+	}
+
+	return policy, nil
 }
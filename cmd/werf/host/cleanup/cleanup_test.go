@@ -0,0 +1,95 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/cmd/werf/common"
+)
+
+func TestRunPeriodically(t *testing.T) {
+	ctx, cancel := context.WithCancel(common.BackgroundContext())
+	defer cancel()
+
+	var runs int
+	startedAt := time.Now()
+	err := runPeriodically(ctx, 20*time.Millisecond, func(ctx context.Context) error {
+		runs++
+		if runs == 3 {
+			cancel()
+		}
+
+		// the failed cleanup does not stop the loop
+		return errors.New("cleanup failed")
+	})
+
+	if err != context.Canceled {
+		t.Fatalf("expected context canceled error, got %v", err)
+	}
+	if runs != 3 {
+		t.Fatalf("expected 3 runs, got %d", runs)
+	}
+	if elapsed := time.Since(startedAt); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected the period between the runs, all runs finished in %s", elapsed)
+	}
+}
+
+func TestRunPeriodically_CanceledDuringWait(t *testing.T) {
+	ctx, cancel := context.WithTimeout(common.BackgroundContext(), 50*time.Millisecond)
+	defer cancel()
+
+	var runs int
+	startedAt := time.Now()
+	err := runPeriodically(ctx, time.Hour, func(ctx context.Context) error {
+		runs++
+		return nil
+	})
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+	if runs != 1 {
+		t.Fatalf("expected 1 run, got %d", runs)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 10*time.Second {
+		t.Fatalf("expected the wait to be interrupted by the context, returned in %s", elapsed)
+	}
+}
+
+func TestGetWatchPeriod(t *testing.T) {
+	defer func(watchPeriod string) { cmdData.WatchPeriod = watchPeriod }(cmdData.WatchPeriod)
+
+	for _, tc := range []struct {
+		value       string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{value: "", expected: defaultWatchPeriod},
+		{value: "30s", expected: 30 * time.Second},
+		{value: "1h30m", expected: 90 * time.Minute},
+		{value: "0s", expectedErr: true},
+		{value: "-1m", expectedErr: true},
+		{value: "10", expectedErr: true},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			cmdData.WatchPeriod = tc.value
+
+			period, err := getWatchPeriod()
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got period %s", period)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if period != tc.expected {
+				t.Fatalf("expected period %s, got %s", tc.expected, period)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ls
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/level"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/locks_inspector"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	Namespace string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "ls",
+		DisableFlagsInUseLine: true,
+		Short:                 "List locks currently held by werf processes",
+		Long: common.GetLongCommandDescription(`List locks currently held by werf processes with the owner, age and type of the lock.
+
+The locks include:
+* Host locks of all werf processes on the host machine (type host). The owner is the pid of the holder process, the age is the lock file creation time. Lock files are named by the hash of the lock name. The held host locks are taken from /proc/locks without locking the files and are listed on linux only.
+* Stage locks of the project stored in the kubernetes synchronization namespace (type stage), when --synchronization=kubernetes://NAMESPACE and --project-name are specified.
+* Release locks stored in the specified --namespace (type release).
+
+The owner of the kubernetes lock is the uuid of the lock lease, the age is the time since the last lease renewal. The lock lease which has not been renewed by the holder in time is marked as stale, such locks can be released with the "werf host locks release --stale" command.
+
+Locks of the http synchronization server cannot be inspected.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run(common.BackgroundContext())
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupProjectName(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.Namespace, "namespace", "", os.Getenv("WERF_NAMESPACE"), "List release locks from the specified Kubernetes namespace (default $WERF_NAMESPACE)")
+
+	return cmd
+}
+
+func run(ctx context.Context) error {
+	if logboek.Context(ctx).IsAcceptedLevel(level.Default) {
+		logboek.Context(ctx).SetAcceptedLevel(level.Warn)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	common.SetupOndemandKubeInitializer(*commonCmdData.KubeContext, *commonCmdData.KubeConfig, *commonCmdData.KubeConfigBase64, *commonCmdData.KubeConfigPathMergeList)
+
+	locks, err := locks_inspector.ListHostLocks(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list host locks: %s", err)
+	}
+
+	sources, err := common.GetKubernetesLocksSources(ctx, &commonCmdData, cmdData.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		kubernetesLocks, err := source.ListLocks(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list %s locks: %s", source.LockType, err)
+		}
+		locks = append(locks, kubernetesLocks...)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tNAME\tOWNER\tAGE\tSTATE")
+	for _, desc := range locks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", desc.Type, desc.Name, valueOrNone(desc.Owner), desc.Age(), lockState(desc))
+	}
+
+	return w.Flush()
+}
+
+func lockState(desc *locks_inspector.LockDesc) string {
+	state := "held"
+	if desc.Stale {
+		state = "stale"
+	}
+
+	if desc.Shared {
+		state = fmt.Sprintf("%s (shared by %d)", state, desc.SharedHolders)
+	}
+
+	return state
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
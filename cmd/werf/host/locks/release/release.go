@@ -0,0 +1,115 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	Namespace string
+	Stale     bool
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "release",
+		DisableFlagsInUseLine: true,
+		Short:                 "Release stale locks left by crashed werf processes",
+		Long: common.GetLongCommandDescription(`Release stale locks left by crashed werf processes (e.g. killed CI jobs).
+
+The kubernetes lock is stale when the lock lease has not been renewed by the holder in time. Stale stage locks of the project are released from the kubernetes synchronization namespace, when --synchronization=kubernetes://NAMESPACE and --project-name are specified. Stale release locks are released from the specified --namespace.
+
+Host locks are released by the operating system automatically when the holder process exits, so there are no stale host locks.`),
+		Example: `  # Release stale stage locks of the project and stale release locks
+  $ werf host locks release --stale --project-name myproject --synchronization kubernetes://werf-synchronization --namespace myproject-production`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if !cmdData.Stale {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("only release of the stale locks is supported: --stale option required")
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return run(common.BackgroundContext())
+			})
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupProjectName(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	common.SetupDryRun(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.Namespace, "namespace", "", os.Getenv("WERF_NAMESPACE"), "Release stale release locks from the specified Kubernetes namespace (default $WERF_NAMESPACE)")
+	cmd.Flags().BoolVarP(&cmdData.Stale, "stale", "", common.GetBoolEnvironmentDefaultFalse("WERF_STALE"), "Release locks which leases have not been renewed by the holder in time (default $WERF_STALE)")
+
+	return cmd
+}
+
+func run(ctx context.Context) error {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	common.SetupOndemandKubeInitializer(*commonCmdData.KubeContext, *commonCmdData.KubeConfig, *commonCmdData.KubeConfigBase64, *commonCmdData.KubeConfigPathMergeList)
+
+	sources, err := common.GetKubernetesLocksSources(ctx, &commonCmdData, cmdData.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		logboek.Context(ctx).Default().LogLn("No kubernetes locks to check: specify --synchronization=kubernetes://NAMESPACE with --project-name or --namespace")
+		return nil
+	}
+
+	for _, source := range sources {
+		if err := logboek.Context(ctx).Default().LogProcess("Releasing stale %s locks in %s", source.LockType, source).DoError(func() error {
+			released, err := source.ReleaseStaleLocks(ctx, *commonCmdData.DryRun)
+			if err != nil {
+				return err
+			}
+
+			if len(released) == 0 {
+				logboek.Context(ctx).Default().LogLn("No stale locks found")
+			}
+
+			for _, desc := range released {
+				logboek.Context(ctx).Default().LogF("Released %q (owner %s, lease last renewed %s ago)\n", desc.Name, desc.Owner, desc.Age())
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
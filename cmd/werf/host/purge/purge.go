@@ -11,6 +11,7 @@ import (
 	"github.com/werf/werf/pkg/cleaning"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/host_cleaning"
@@ -77,7 +78,7 @@ WARNING: Do not run this command during any other werf command is working on the
 func runReset() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -121,11 +122,11 @@ func runReset() error {
 		if err != nil {
 			return err
 		}
-		stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+		stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 		if err != nil {
 			return err
 		}
-		storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+		storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 		if err != nil {
 			return err
 		}
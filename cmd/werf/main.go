@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"github.com/werf/werf/cmd/werf/cleanup"
 	"github.com/werf/werf/cmd/werf/compose"
 	"github.com/werf/werf/cmd/werf/converge"
+	"github.com/werf/werf/cmd/werf/daemon"
 	"github.com/werf/werf/cmd/werf/dismiss"
 	"github.com/werf/werf/cmd/werf/export"
 	"github.com/werf/werf/cmd/werf/helm"
@@ -28,27 +30,43 @@ import (
 	managed_images_ls "github.com/werf/werf/cmd/werf/managed_images/ls"
 	managed_images_rm "github.com/werf/werf/cmd/werf/managed_images/rm"
 
+	cr_publish_cleanup_policies "github.com/werf/werf/cmd/werf/cr/publish_cleanup_policies"
+	cr_usage_report "github.com/werf/werf/cmd/werf/cr/usage_report"
+
 	host_cleanup "github.com/werf/werf/cmd/werf/host/cleanup"
+	host_locks_ls "github.com/werf/werf/cmd/werf/host/locks/ls"
+	host_locks_release "github.com/werf/werf/cmd/werf/host/locks/release"
 	host_purge "github.com/werf/werf/cmd/werf/host/purge"
 
 	bundle_apply "github.com/werf/werf/cmd/werf/bundle/apply"
+	bundle_copy "github.com/werf/werf/cmd/werf/bundle/copy"
+	bundle_diff "github.com/werf/werf/cmd/werf/bundle/diff"
 	bundle_download "github.com/werf/werf/cmd/werf/bundle/download"
 	bundle_export "github.com/werf/werf/cmd/werf/bundle/export"
 	bundle_publish "github.com/werf/werf/cmd/werf/bundle/publish"
+	bundle_rebuild "github.com/werf/werf/cmd/werf/bundle/rebuild"
+	bundle_render "github.com/werf/werf/cmd/werf/bundle/render"
 
+	config_lint "github.com/werf/werf/cmd/werf/config/lint"
 	config_list "github.com/werf/werf/cmd/werf/config/list"
 	config_render "github.com/werf/werf/cmd/werf/config/render"
 	"github.com/werf/werf/cmd/werf/render"
 
+	giterminism_audit "github.com/werf/werf/cmd/werf/giterminism/audit"
+
 	"github.com/werf/werf/cmd/werf/completion"
 	"github.com/werf/werf/cmd/werf/docs"
 	"github.com/werf/werf/cmd/werf/version"
 
 	stage_image "github.com/werf/werf/cmd/werf/stage/image"
+	stage_invalidate "github.com/werf/werf/cmd/werf/stage/invalidate"
+	stage_shell "github.com/werf/werf/cmd/werf/stage/shell"
+	stage_verify "github.com/werf/werf/cmd/werf/stage/verify"
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/cmd/werf/common/templates"
 	"github.com/werf/werf/pkg/process_exterminator"
+	"github.com/werf/werf/pkg/tmp_manager"
 )
 
 func main() {
@@ -62,9 +80,17 @@ func main() {
 
 	rootCmd := constructRootCmd()
 
+	if err := common.GetSetupError(rootCmd); err != nil {
+		common.TerminateWithError(err.Error(), 1)
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		common.TerminateWithError(err.Error(), 1)
 	}
+
+	if err := tmp_manager.CleanupRunData(context.Background()); err != nil {
+		logboek.Warn().LogF("WARNING: tmp data cleanup failed: %s\n", err)
+	}
 }
 
 func constructRootCmd() *cobra.Command {
@@ -115,7 +141,9 @@ Find more information at https://werf.io`),
 			Message: "Low-level management commands",
 			Commands: []*cobra.Command{
 				configCmd(),
+				giterminismCmd(),
 				managedImagesCmd(),
+				crCmd(),
 				hostCmd(),
 				helm.NewCmd(),
 			},
@@ -124,6 +152,7 @@ Find more information at https://werf.io`),
 			Message: "Other commands",
 			Commands: []*cobra.Command{
 				synchronization.NewCmd(),
+				daemon.NewCmd(),
 				completion.NewCmd(rootCmd),
 				version.NewCmd(),
 				docs.NewCmd(groups),
@@ -161,8 +190,12 @@ func bundleCmd() *cobra.Command {
 	cmd.AddCommand(
 		bundle_publish.NewCmd(),
 		bundle_apply.NewCmd(),
+		bundle_render.NewCmd(),
+		bundle_diff.NewCmd(),
+		bundle_copy.NewCmd(),
 		bundle_export.NewCmd(),
 		bundle_download.NewCmd(),
+		bundle_rebuild.NewCmd(),
 	)
 
 	return cmd
@@ -176,6 +209,19 @@ func configCmd() *cobra.Command {
 	cmd.AddCommand(
 		config_render.NewCmd(),
 		config_list.NewCmd(),
+		config_lint.NewCmd(),
+	)
+
+	return cmd
+}
+
+func giterminismCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "giterminism",
+		Short: "Work with giterminism: find places in the project requiring loosening giterminism",
+	}
+	cmd.AddCommand(
+		giterminism_audit.NewCmd(),
 	)
 
 	return cmd
@@ -195,6 +241,19 @@ func managedImagesCmd() *cobra.Command {
 	return cmd
 }
 
+func crCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cr",
+		Short: "Work with container registry: report usage of project images, publish cleanup policies",
+	}
+	cmd.AddCommand(
+		cr_usage_report.NewCmd(),
+		cr_publish_cleanup_policies.NewCmd(),
+	)
+
+	return cmd
+}
+
 func stageCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:    "stage",
@@ -202,6 +261,9 @@ func stageCmd() *cobra.Command {
 	}
 	cmd.AddCommand(
 		stage_image.NewCmd(),
+		stage_verify.NewCmd(),
+		stage_invalidate.NewCmd(),
+		stage_shell.NewCmd(),
 	)
 
 	return cmd
@@ -216,7 +278,21 @@ func hostCmd() *cobra.Command {
 	hostCmd.AddCommand(
 		host_cleanup.NewCmd(),
 		host_purge.NewCmd(),
+		hostLocksCmd(),
 	)
 
 	return hostCmd
 }
+
+func hostLocksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "locks",
+		Short: "Inspect werf locks and release stale locks left by crashed werf processes",
+	}
+	cmd.AddCommand(
+		host_locks_ls.NewCmd(),
+		host_locks_release.NewCmd(),
+	)
+
+	return cmd
+}
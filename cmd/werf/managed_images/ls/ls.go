@@ -11,6 +11,7 @@ import (
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/image"
@@ -78,7 +79,7 @@ func run() error {
 		logboek.Context(ctx).SetAcceptedLevel(level.Error)
 	}
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -158,11 +159,11 @@ func run() error {
 	if err != nil {
 		return err
 	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 	if err != nil {
 		return err
 	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 	if err != nil {
 		return err
 	}
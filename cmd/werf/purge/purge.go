@@ -2,6 +2,7 @@ package purge
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/werf/werf/pkg/cleaning"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/image"
@@ -21,6 +23,10 @@ import (
 	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
+var cmdData struct {
+	Force bool
+}
+
 var commonCmdData common.CmdData
 
 func NewCmd() *cobra.Command {
@@ -30,7 +36,9 @@ func NewCmd() *cobra.Command {
 		Short:                 "Purge all project images in the container registry",
 		Long: common.GetLongCommandDescription(`Purge all project images in the container registry. 
 
-WARNING: Images that are being used in the Kubernetes cluster will also be deleted.`),
+WARNING: Images that are being used in the Kubernetes cluster will also be deleted.
+
+The command refuses to delete the repo or the final repo, which contain the stages built by the other projects (e.g. when several projects share one repo address by mistake), until --force is specified.`),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
 
@@ -60,6 +68,7 @@ WARNING: Images that are being used in the Kubernetes cluster will also be delet
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+	common.SetupParallelTaskTimeout(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to delete images from the specified repo")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
@@ -85,13 +94,15 @@ WARNING: Images that are being used in the Kubernetes cluster will also be delet
 
 	common.SetupPlatform(&commonCmdData, cmd)
 
+	cmd.Flags().BoolVarP(&cmdData.Force, "force", "", common.GetBoolEnvironmentDefaultFalse("WERF_PURGE_FORCE"), "Delete the repo and the final repo content even if they contain the stages of the other projects (default $WERF_PURGE_FORCE)")
+
 	return cmd
 }
 
 func runPurge() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
@@ -137,7 +148,7 @@ func runPurge() error {
 		return err
 	}
 
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
@@ -168,11 +179,11 @@ It is worth noting that auto-cleaning is enabled by default, and manual use is u
 	if err != nil {
 		return err
 	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 	if err != nil {
 		return err
 	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 	if err != nil {
 		return err
 	}
@@ -189,9 +200,11 @@ It is worth noting that auto-cleaning is enabled by default, and manual use is u
 	if *commonCmdData.Parallel {
 		storageManager.EnableParallel(int(*commonCmdData.ParallelTasksLimit))
 	}
+	storageManager.SetParallelTaskTimeout(time.Duration(*commonCmdData.ParallelTaskTimeoutSeconds) * time.Second)
 
 	purgeOptions := cleaning.PurgeOptions{
 		DryRun: *commonCmdData.DryRun,
+		Force:  cmdData.Force,
 	}
 
 	logboek.LogOptionalLn()
@@ -0,0 +1,116 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const gitOpsPluginEnvPrefix = "ARGOCD_ENV_"
+
+// gitOpsPluginOptionsByEnvName are the options which could be set in the plugin env of the ArgoCD Application.
+// The other $ARGOCD_ENV_WERF_* variables are ignored, so that the Application cannot change e.g. the docker config, the tmp dir or the secret key of the plugin container.
+var gitOpsPluginOptionsByEnvName = map[string]string{
+	"WERF_ENV":       "env",
+	"WERF_RELEASE":   "release",
+	"WERF_NAMESPACE": "namespace",
+	"WERF_REPO":      "repo",
+	"WERF_CONFIG":    "config",
+}
+
+// gitOpsPluginOptionsByEnvNamePrefix are the multiple value options which could be set in the plugin env of the ArgoCD Application
+// the same way as with the env name prefix, e.g. $ARGOCD_ENV_WERF_SET_REPLICAS=replicas=2 adds --set replicas=2.
+// The longer prefixes go first.
+var gitOpsPluginOptionsByEnvNamePrefix = []struct {
+	Prefix string
+	Option string
+}{
+	{"WERF_SET_STRING_", "set-string"},
+	{"WERF_SET_FILE_", "set-file"},
+	{"WERF_SET_JSON_", "set-json"},
+	{"WERF_SET_LITERAL_", "set-literal"},
+	{"WERF_SET_", "set"},
+	{"WERF_SECRET_VALUES_", "secret-values"},
+	{"WERF_VALUES_", "values"},
+	{"WERF_ADD_ANNOTATION_", "add-annotation"},
+	{"WERF_ADD_LABEL_", "add-label"},
+}
+
+// processGitOpsPluginEnv sets the allowed options from the plugin env of the ArgoCD Application,
+// which is passed to the plugin with the ARGOCD_ENV_ prefix, and the namespace from the Application destination.
+// The options specified explicitly are not changed. The process env is not modified.
+func processGitOpsPluginEnv(flags *pflag.FlagSet, environ []string) error {
+	for _, keyValue := range environ {
+		parts := strings.SplitN(keyValue, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], gitOpsPluginEnvPrefix+"WERF_") {
+			continue
+		}
+
+		name := strings.TrimPrefix(parts[0], gitOpsPluginEnvPrefix)
+		value := parts[1]
+
+		optionName, isMultiple := getGitOpsPluginOptionName(name)
+		if optionName == "" {
+			return fmt.Errorf("%s is not allowed in the plugin env: only $%sWERF_{%s} and $%sWERF_{%s}* are supported", parts[0], gitOpsPluginEnvPrefix, strings.Join(getGitOpsPluginOptionsEnvNames(), ","), gitOpsPluginEnvPrefix, strings.Join(getGitOpsPluginOptionsEnvNamePrefixes(), ","))
+		}
+
+		flag := flags.Lookup(optionName)
+		if flag == nil || (flag.Changed && !isMultiple) {
+			continue
+		}
+
+		if err := flags.Set(optionName, value); err != nil {
+			return fmt.Errorf("bad %s value %q: %s", parts[0], value, err)
+		}
+	}
+
+	for _, keyValue := range environ {
+		parts := strings.SplitN(keyValue, "=", 2)
+		if len(parts) != 2 || parts[0] != "ARGOCD_APP_NAMESPACE" || parts[1] == "" {
+			continue
+		}
+
+		if flag := flags.Lookup("namespace"); flag != nil && !flag.Changed {
+			if err := flags.Set("namespace", parts[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func getGitOpsPluginOptionName(envName string) (string, bool) {
+	if optionName, ok := gitOpsPluginOptionsByEnvName[envName]; ok {
+		return optionName, false
+	}
+
+	for _, option := range gitOpsPluginOptionsByEnvNamePrefix {
+		if strings.HasPrefix(envName, option.Prefix) && len(envName) > len(option.Prefix) {
+			return option.Option, true
+		}
+	}
+
+	return "", false
+}
+
+func getGitOpsPluginOptionsEnvNames() []string {
+	var names []string
+	for envName := range gitOpsPluginOptionsByEnvName {
+		names = append(names, strings.TrimPrefix(envName, "WERF_"))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func getGitOpsPluginOptionsEnvNamePrefixes() []string {
+	var prefixes []string
+	for _, option := range gitOpsPluginOptionsByEnvNamePrefix {
+		prefixes = append(prefixes, strings.TrimPrefix(option.Prefix, "WERF_"))
+	}
+
+	return prefixes
+}
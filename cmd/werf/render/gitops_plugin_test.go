@@ -0,0 +1,100 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func newGitOpsPluginTestFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("render", pflag.ContinueOnError)
+	flags.String("env", "", "")
+	flags.String("namespace", "", "")
+	flags.String("repo", "", "")
+	flags.String("docker-config", "", "")
+	flags.StringArray("set", nil, "")
+	flags.StringArray("set-string", nil, "")
+	flags.StringArray("values", nil, "")
+
+	return flags
+}
+
+func TestProcessGitOpsPluginEnv(t *testing.T) {
+	flags := newGitOpsPluginTestFlags()
+
+	if err := processGitOpsPluginEnv(flags, []string{
+		"ARGOCD_ENV_WERF_ENV=production",
+		"ARGOCD_ENV_WERF_REPO=registry.example.com/app",
+		"ARGOCD_ENV_WERF_SET_REPLICAS=replicas=2",
+		"ARGOCD_ENV_WERF_SET_STRING_TAG=tag=1.0",
+		"ARGOCD_ENV_WERF_VALUES_PROD=.helm/values-production.yaml",
+		"ARGOCD_APP_NAMESPACE=app-production",
+		"WERF_ENV=staging",
+		"ARGOCD_ENV_OTHER=value",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"env":        "production",
+		"repo":       "registry.example.com/app",
+		"namespace":  "app-production",
+		"set":        "[replicas=2]",
+		"set-string": "[tag=1.0]",
+		"values":     "[.helm/values-production.yaml]",
+	}
+
+	for name, value := range expected {
+		if got := flags.Lookup(name).Value.String(); got != value {
+			t.Errorf("--%s: expected %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestProcessGitOpsPluginEnv_ExplicitOptions(t *testing.T) {
+	flags := newGitOpsPluginTestFlags()
+	if err := flags.Parse([]string{"--env", "staging", "--namespace", "custom", "--set", "a=1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processGitOpsPluginEnv(flags, []string{
+		"ARGOCD_ENV_WERF_ENV=production",
+		"ARGOCD_ENV_WERF_SET_B=b=2",
+		"ARGOCD_APP_NAMESPACE=app-production",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := flags.Lookup("env").Value.String(); got != "staging" {
+		t.Errorf("expected explicit --env to be kept, got %q", got)
+	}
+
+	if got := flags.Lookup("namespace").Value.String(); got != "custom" {
+		t.Errorf("expected explicit --namespace to be kept, got %q", got)
+	}
+
+	if got, _ := flags.GetStringArray("set"); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf("expected --set values from the command line and the plugin env, got %q", got)
+	}
+}
+
+func TestProcessGitOpsPluginEnv_NotAllowed(t *testing.T) {
+	for _, keyValue := range []string{
+		"ARGOCD_ENV_WERF_DOCKER_CONFIG=/tmp/docker",
+		"ARGOCD_ENV_WERF_SECRET_KEY=key",
+		"ARGOCD_ENV_WERF_SET_=value",
+	} {
+		flags := newGitOpsPluginTestFlags()
+
+		err := processGitOpsPluginEnv(flags, []string{keyValue})
+		if err == nil || !strings.Contains(err.Error(), "is not allowed in the plugin env") {
+			t.Errorf("%s: expected not allowed error, got: %v", keyValue, err)
+		}
+
+		if got := flags.Lookup("docker-config").Value.String(); got != "" {
+			t.Errorf("%s: unexpected --docker-config %q", keyValue, got)
+		}
+	}
+}
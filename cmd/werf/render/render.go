@@ -1,6 +1,7 @@
 package render
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -24,6 +25,7 @@ import (
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
 	"github.com/werf/werf/pkg/deploy/secrets_manager"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/image"
@@ -38,9 +40,12 @@ import (
 )
 
 var cmdData struct {
-	RenderOutput string
-	Validate     bool
-	IncludeCRDs  bool
+	RenderOutput              string
+	RenderOutputDir           string
+	RenderOutputKustomization bool
+	GitOpsPlugin              bool
+	Validate                  bool
+	IncludeCRDs               bool
 }
 
 var commonCmdData common.CmdData
@@ -57,6 +62,18 @@ func NewCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
 
+			if cmdData.GitOpsPlugin {
+				if err := processGitOpsPluginEnv(cmd.Flags(), os.Environ()); err != nil {
+					return err
+				}
+
+				// only the manifests are expected in the stdout
+				if !*commonCmdData.LogVerbose && !*commonCmdData.LogDebug {
+					*commonCmdData.LogQuiet = true
+				}
+				*commonCmdData.LogColorMode = "off"
+			}
+
 			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
 				common.PrintHelp(cmd)
 				return err
@@ -77,17 +94,26 @@ func NewCmd() *cobra.Command {
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
+	common.SetupDockerfileSecrets(&commonCmdData, cmd)
 
 	common.SetupIntrospectAfterError(&commonCmdData, cmd)
 	common.SetupIntrospectBeforeError(&commonCmdData, cmd)
+	common.SetupIntrospectShell(&commonCmdData, cmd)
+	common.SetupKeepFailedStageContainer(&commonCmdData, cmd)
 	common.SetupIntrospectStage(&commonCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupRepoReadOnly(&commonCmdData, cmd)
+	common.SetupRegistryMirrors(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo and to pull base images")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
@@ -103,10 +129,14 @@ func NewCmd() *cobra.Command {
 	common.SetupNamespace(&commonCmdData, cmd)
 	common.SetupAddAnnotations(&commonCmdData, cmd)
 	common.SetupAddLabels(&commonCmdData, cmd)
+	common.SetupPostRenderer(&commonCmdData, cmd)
 
 	common.SetupSetDockerConfigJsonValue(&commonCmdData, cmd)
+	common.SetupImagePullSecret(&commonCmdData, cmd, false)
 	common.SetupSet(&commonCmdData, cmd)
 	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetJson(&commonCmdData, cmd)
+	common.SetupSetLiteral(&commonCmdData, cmd)
 	common.SetupSetFile(&commonCmdData, cmd)
 	common.SetupValues(&commonCmdData, cmd)
 	common.SetupSecretValues(&commonCmdData, cmd)
@@ -114,20 +144,29 @@ func NewCmd() *cobra.Command {
 
 	common.SetupReportPath(&commonCmdData, cmd)
 	common.SetupReportFormat(&commonCmdData, cmd)
+	common.SetupReportSupplyChainPath(&commonCmdData, cmd)
+	common.SetupScanOptions(&commonCmdData, cmd)
+	common.SetupStageLogsOptions(&commonCmdData, cmd)
 
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&commonCmdData, cmd)
 
 	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultBuildParallelTasksLimit)
 
 	common.SetupSkipBuild(&commonCmdData, cmd)
 	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
 
 	cmd.Flags().BoolVarP(&cmdData.Validate, "validate", "", common.GetBoolEnvironmentDefaultFalse("WERF_VALIDATE"), "Validate your manifests against the Kubernetes cluster you are currently pointing at (default $WERF_VALIDATE)")
 	cmd.Flags().BoolVarP(&cmdData.IncludeCRDs, "include-crds", "", common.GetBoolEnvironmentDefaultTrue("WERF_INCLUDE_CRDS"), "Include CRDs in the templated output (default $WERF_INCLUDE_CRDS)")
 
 	cmd.Flags().StringVarP(&cmdData.RenderOutput, "output", "", os.Getenv("WERF_RENDER_OUTPUT"), "Write render output to the specified file instead of stdout ($WERF_RENDER_OUTPUT by default)")
+	cmd.Flags().StringVarP(&cmdData.RenderOutputDir, "output-dir", "", os.Getenv("WERF_RENDER_OUTPUT_DIR"), "Write each rendered resource into the separate NAMESPACE/KIND/NAME.yaml file in the specified dir instead of stdout, the content of the dir previously written by werf is replaced, the other non-empty dirs are not allowed ($WERF_RENDER_OUTPUT_DIR by default)")
+	cmd.Flags().BoolVarP(&cmdData.GitOpsPlugin, "gitops-plugin", "", common.GetBoolEnvironmentDefaultFalse("WERF_GITOPS_PLUGIN"), `Run as the GitOps config management plugin (e.g. ArgoCD CMP): the env, release, namespace, repo, config and values options are also read from the $ARGOCD_ENV_WERF_* variables of the Application (e.g. $ARGOCD_ENV_WERF_ENV=production sets --env, other $ARGOCD_ENV_WERF_* variables are not allowed), the namespace is taken from the $ARGOCD_APP_NAMESPACE, images are not built and only the images already built in the repo are used (the docker server is not required), only the rendered manifests are printed into the stdout (default $WERF_GITOPS_PLUGIN)`)
+	cmd.Flags().BoolVarP(&cmdData.RenderOutputKustomization, "output-kustomization", "", common.GetBoolEnvironmentDefaultFalse("WERF_RENDER_OUTPUT_KUSTOMIZATION"), "Generate kustomization.yaml with all rendered resources in the --output-dir (default $WERF_RENDER_OUTPUT_KUSTOMIZATION)")
 
 	return cmd
 }
@@ -135,10 +174,21 @@ func NewCmd() *cobra.Command {
 func runRender() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if cmdData.RenderOutput != "" && cmdData.RenderOutputDir != "" {
+		return fmt.Errorf("--output and --output-dir options cannot be used together")
+	}
+	if cmdData.RenderOutputKustomization && cmdData.RenderOutputDir == "" {
+		return fmt.Errorf("--output-kustomization option requires --output-dir option")
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -156,19 +206,37 @@ func runRender() error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
+		return err
+	}
+
+	// images are not built in the GitOps plugin mode, so the docker server is not required
+	if !cmdData.GitOpsPlugin {
+		if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+			return err
+		}
+
+		ctxWithDockerCli, err := docker.NewContext(ctx)
+		if err != nil {
+			return err
+		}
+		ctx = ctxWithDockerCli
+	} else if err := docker.InitConfig(*commonCmdData.DockerConfig); err != nil {
 		return err
 	}
 
-	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
 		return err
 	}
 
-	ctxWithDockerCli, err := docker.NewContext(ctx)
-	if err != nil {
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
 		return err
 	}
-	ctx = ctxWithDockerCli
 
 	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
 	if err != nil {
@@ -177,14 +245,18 @@ func runRender() error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
-	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	werfConfigPath, werfConfig, dependencies, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.InitRegistryMirrors(&commonCmdData, werfConfig); err != nil {
+		return err
+	}
+
 	projectName := werfConfig.Meta.Project
 
-	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	chartDir, err := flows.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
 	if err != nil {
 		return fmt.Errorf("getting helm chart dir failed: %s", err)
 	}
@@ -205,22 +277,31 @@ func runRender() error {
 		}
 	}()
 
-	releaseName, err := common.GetHelmRelease(*commonCmdData.Release, *commonCmdData.Environment, werfConfig)
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	releaseName, err := flows.GetHelmRelease(*commonCmdData.Release, *commonCmdData.Environment, werfConfig)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := flows.GetKubernetesNamespace(*commonCmdData.Namespace, *commonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
 	}
 
-	namespace, err := common.GetKubernetesNamespace(*commonCmdData.Namespace, *commonCmdData.Environment, werfConfig)
+	userExtraAnnotations, err := common.GetUserExtraAnnotationsWithWerfConfig(&commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
 
-	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
+	commandLineValues, err := helpers.ParseCommandLineValues(common.GetSetJson(&commonCmdData), common.GetSetLiteral(&commonCmdData))
 	if err != nil {
 		return err
 	}
 
-	userExtraLabels, err := common.GetUserExtraLabels(&commonCmdData)
+	userExtraLabels, err := common.GetUserExtraLabelsWithWerfConfig(&commonCmdData, werfConfig, giterminismManager)
 	if err != nil {
 		return err
 	}
@@ -241,7 +322,7 @@ func runRender() error {
 		stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
 
 		if stagesStorageAddress != storage.LocalStorageAddress {
-			if err := common.DockerRegistryInit(ctxWithDockerCli, &commonCmdData); err != nil {
+			if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
 				return err
 			}
 
@@ -250,7 +331,7 @@ func runRender() error {
 			if err != nil {
 				return err
 			}
-			finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+			finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &commonCmdData, werfConfig)
 			if err != nil {
 				return err
 			}
@@ -258,11 +339,11 @@ func runRender() error {
 			if err != nil {
 				return err
 			}
-			stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+			stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 			if err != nil {
 				return err
 			}
-			storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+			storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 			if err != nil {
 				return err
 			}
@@ -276,6 +357,9 @@ func runRender() error {
 			}
 
 			storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+			if common.IsRepoReadOnly(&commonCmdData) {
+				storageManager.EnableReadOnly()
+			}
 
 			imagesRepository = storageManager.StagesStorage.String()
 
@@ -288,14 +372,18 @@ func runRender() error {
 			defer conveyorWithRetry.Terminate()
 
 			if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
-				if *commonCmdData.SkipBuild {
-					if err := c.ShouldBeBuilt(ctx); err != nil {
-						return err
-					}
-				} else {
-					if err := c.Build(ctx, buildOptions); err != nil {
+				if *commonCmdData.SkipBuild || cmdData.GitOpsPlugin {
+					infoGetters, err := c.DetermineImagesInfoGetters(ctx)
+					if err != nil {
 						return err
 					}
+
+					imagesInfoGetters = infoGetters
+					return nil
+				}
+
+				if err := c.Build(ctx, buildOptions); err != nil {
+					return err
 				}
 
 				imagesInfoGetters = c.GetImageInfoGetters()
@@ -339,6 +427,11 @@ func runRender() error {
 		return err
 	}
 
+	var pullTokenSecret string
+	if *commonCmdData.DockerConfigJsonPullTokens {
+		pullTokenSecret = helpers.GetPullTokenSecretName(releaseName)
+	}
+
 	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, imagesRepository, imagesInfoGetters, helpers.ServiceValuesOptions{
 		Namespace:                namespace,
 		Env:                      *commonCmdData.Environment,
@@ -346,11 +439,15 @@ func runRender() error {
 		StubImagesNames:          stubImagesNames,
 		SetDockerConfigJsonValue: *commonCmdData.SetDockerConfigJsonValue,
 		DockerConfigPath:         *commonCmdData.DockerConfig,
+		PullTokenSecret:          pullTokenSecret,
+		ImagePullSecret:          *commonCmdData.ImagePullSecret,
+		Dependencies:             dependencies,
 	}); err != nil {
 		return fmt.Errorf("error creating service values: %s", err)
 	} else {
 		wc.SetServiceValues(vals)
 	}
+	wc.SetCommandLineValues(commandLineValues)
 
 	actionConfig := new(action.Configuration)
 	if err := helm.InitActionConfig(ctx, nil, namespace, cmd_helm.Settings, registryClientHandler, actionConfig, helm.InitActionConfigOptions{}); err != nil {
@@ -358,7 +455,11 @@ func runRender() error {
 	}
 
 	var output io.Writer
-	if cmdData.RenderOutput != "" {
+	var outputDirBuffer *bytes.Buffer
+	if cmdData.RenderOutputDir != "" {
+		outputDirBuffer = bytes.NewBuffer(nil)
+		output = outputDirBuffer
+	} else if cmdData.RenderOutput != "" {
 		if f, err := os.Create(cmdData.RenderOutput); err != nil {
 			return fmt.Errorf("unable to open file %q: %s", cmdData.RenderOutput, err)
 		} else {
@@ -376,7 +477,13 @@ func runRender() error {
 		SubchartExtenderFactoryFunc: func() chart.ChartExtender { return chart_extender.NewWerfSubchart() },
 	}
 
-	postRenderer, err := wc.GetPostRenderer()
+	werfPostRenderer, err := wc.GetPostRenderer()
+	if err != nil {
+		return err
+	}
+	werfPostRenderer.SetImagePullSecrets(common.GetImagePullSecrets(&commonCmdData, pullTokenSecret))
+
+	postRenderer, err := common.GetPostRenderer(ctx, &commonCmdData, giterminismManager, werfPostRenderer)
 	if err != nil {
 		return err
 	}
@@ -396,5 +503,14 @@ func runRender() error {
 		return fmt.Errorf("helm templates rendering failed: %s", err)
 	}
 
+	if outputDirBuffer != nil {
+		if err := helm.WriteManifestsDir(outputDirBuffer.String(), cmdData.RenderOutputDir, helm.WriteManifestsDirOptions{
+			Namespace:     namespace,
+			Kustomization: cmdData.RenderOutputKustomization,
+		}); err != nil {
+			return fmt.Errorf("unable to write rendered resources into %q: %s", cmdData.RenderOutputDir, err)
+		}
+	}
+
 	return nil
 }
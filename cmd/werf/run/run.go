@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
@@ -39,6 +41,15 @@ type cmdDataType struct {
 	Bash             bool
 	RawDockerOptions string
 
+	Publish         []string
+	EnvFiles        []string
+	Volumes         []string
+	Entrypoint      string
+	User            string
+	Interactive     bool
+	TTY             bool
+	MountProjectDir string
+
 	DockerOptions []string
 	DockerCommand []string
 	ImageName     string
@@ -64,6 +75,9 @@ func NewCmd() *cobra.Command {
   # Run image with specified docker run options and command
   $ werf run --docker-options="-d -p 5000:5000 --restart=always --name registry" -- /app/run.sh
 
+  # Run image interactively with published port, env file and project directory mounted into /app
+  $ werf run -it -p 8080:80 --env-file .env --mount-project-dir /app application -- /bin/bash
+
   # Print a resulting docker run command
   $ werf run --shell --dry-run
   docker run -ti --rm image-stage-test:1ffe83860127e68e893b6aece5b0b7619f903f8492a285c6410371c87018c6a0 /bin/sh`,
@@ -88,6 +102,11 @@ func NewCmd() *cobra.Command {
 				cmdData.DockerOptions = strings.Fields(cmdData.RawDockerOptions)
 			}
 
+			if cmdData.MountProjectDir != "" && !path.IsAbs(cmdData.MountProjectDir) {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("--mount-project-dir=%s: absolute container path expected", cmdData.MountProjectDir)
+			}
+
 			if cmdData.Shell && cmdData.Bash {
 				return fmt.Errorf("cannot use --shell and --bash options at the same time")
 			}
@@ -121,13 +140,19 @@ func NewCmd() *cobra.Command {
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupTmpDirQuota(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+	common.SetupGitSubmodules(&commonCmdData, cmd)
+	common.SetupGitSharedObjects(&commonCmdData, cmd)
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupCacheStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupRepoReadOnly(&commonCmdData, cmd)
+	common.SetupRegistryMirrors(&commonCmdData, cmd)
 
 	common.SetupSkipBuild(&commonCmdData, cmd)
 
@@ -151,13 +176,25 @@ func NewCmd() *cobra.Command {
 	common.SetupVirtualMerge(&commonCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&commonCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&commonCmdData, cmd)
+	common.SetupVirtualMergeInto(&commonCmdData, cmd)
 
 	common.SetupPlatform(&commonCmdData, cmd)
+	common.SetupDockerfileBuilder(&commonCmdData, cmd)
+	common.SetupRemoteBuilder(&commonCmdData, cmd)
 
 	cmd.Flags().BoolVarP(&cmdData.Shell, "shell", "", false, "Use predefined docker options and command for debug")
 	cmd.Flags().BoolVarP(&cmdData.Bash, "bash", "", false, "Use predefined docker options and command for debug")
 	cmd.Flags().StringVarP(&cmdData.RawDockerOptions, "docker-options", "", os.Getenv("WERF_DOCKER_OPTIONS"), "Define docker run options (default $WERF_DOCKER_OPTIONS)")
 
+	cmd.Flags().StringArrayVarP(&cmdData.Publish, "publish", "p", []string{}, "Publish a container's port(s) to the host (can specify multiple, see docker run --publish option)")
+	cmd.Flags().StringArrayVarP(&cmdData.EnvFiles, "env-file", "", []string{}, "Read in a file of environment variables (can specify multiple, see docker run --env-file option)")
+	cmd.Flags().StringArrayVarP(&cmdData.Volumes, "volume", "v", []string{}, "Bind mount a volume (can specify multiple, see docker run --volume option)")
+	cmd.Flags().StringVarP(&cmdData.Entrypoint, "entrypoint", "", os.Getenv("WERF_ENTRYPOINT"), "Overwrite the default entrypoint of the image (default $WERF_ENTRYPOINT)")
+	cmd.Flags().StringVarP(&cmdData.User, "user", "u", os.Getenv("WERF_USER"), "Username or UID (format: <name|uid>[:<group|gid>]) to run the container with (default $WERF_USER)")
+	cmd.Flags().BoolVarP(&cmdData.Interactive, "interactive", "i", common.GetBoolEnvironmentDefaultFalse("WERF_INTERACTIVE"), "Keep STDIN open even if not attached (default $WERF_INTERACTIVE)")
+	cmd.Flags().BoolVarP(&cmdData.TTY, "tty", "t", common.GetBoolEnvironmentDefaultFalse("WERF_TTY"), "Allocate a pseudo-TTY (default $WERF_TTY)")
+	cmd.Flags().StringVarP(&cmdData.MountProjectDir, "mount-project-dir", "", os.Getenv("WERF_MOUNT_PROJECT_DIR"), "Mount the project directory into the container at the specified absolute path and use it as the working directory (default $WERF_MOUNT_PROJECT_DIR)")
+
 	return cmd
 }
 
@@ -216,13 +253,65 @@ func getContainerName() string {
 	return ""
 }
 
+func getDockerRunOptions(projectDir string) []string {
+	var options []string
+
+	if cmdData.Interactive {
+		options = append(options, "--interactive")
+	}
+
+	if cmdData.TTY {
+		options = append(options, "--tty")
+	}
+
+	for _, value := range cmdData.Publish {
+		options = append(options, "--publish", value)
+	}
+
+	for _, value := range cmdData.EnvFiles {
+		options = append(options, "--env-file", value)
+	}
+
+	if cmdData.MountProjectDir != "" {
+		options = append(options, "--volume", fmt.Sprintf("%s:%s", projectDir, cmdData.MountProjectDir), "--workdir", cmdData.MountProjectDir)
+	}
+
+	for _, value := range cmdData.Volumes {
+		options = append(options, "--volume", value)
+	}
+
+	if cmdData.Entrypoint != "" {
+		options = append(options, "--entrypoint", cmdData.Entrypoint)
+	}
+
+	if cmdData.User != "" {
+		options = append(options, "--user", cmdData.User)
+	}
+
+	return options
+}
+
+// getDockerRunArgs passes the user docker options after the options of the dedicated flags, so that the user docker options take precedence.
+func getDockerRunArgs(projectDir, dockerImageName string) []string {
+	var args []string
+	args = append(args, getDockerRunOptions(projectDir)...)
+	args = append(args, cmdData.DockerOptions...)
+	args = append(args, dockerImageName)
+	args = append(args, cmdData.DockerCommand...)
+	return args
+}
+
 func runMain() error {
 	ctx := common.BackgroundContext()
 
-	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
 
+	if err := common.InitTmpDirQuota(&commonCmdData); err != nil {
+		return err
+	}
+
 	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting host git data manager: %s", err)
@@ -240,7 +329,12 @@ func runMain() error {
 		return err
 	}
 
-	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+	gitOptions, err := common.GetTrueGitOptions(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := true_git.Init(gitOptions); err != nil {
 		return err
 	}
 
@@ -248,6 +342,14 @@ func runMain() error {
 		return err
 	}
 
+	if err := common.InitDockerfileBuilder(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InitRemoteBuilder(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
 	ctxWithDockerCli, err := docker.NewContext(ctx)
 	if err != nil {
 		return err
@@ -275,6 +377,10 @@ func runMain() error {
 		}
 	}()
 
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
 	if *commonCmdData.Follow {
 		if cmdData.Shell || cmdData.Bash {
 			return fmt.Errorf("follow mode does not work with --shell and --bash options")
@@ -333,11 +439,15 @@ func runMain() error {
 }
 
 func run(ctx context.Context, giterminismManager giterminism_manager.Interface) error {
-	_, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.InitRegistryMirrors(&commonCmdData, werfConfig); err != nil {
+		return err
+	}
+
 	projectName := werfConfig.Meta.Project
 
 	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
@@ -361,7 +471,7 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 	if err != nil {
 		return err
 	}
-	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+	finalStagesStorage, err := common.GetOptionalFinalStagesStorageForEnvironment(ctx, containerRuntime, &commonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -369,11 +479,11 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 	if err != nil {
 		return err
 	}
-	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
 	if err != nil {
 		return err
 	}
-	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
 	if err != nil {
 		return err
 	}
@@ -387,6 +497,9 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 	}
 
 	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
+	if common.IsRepoReadOnly(&commonCmdData) {
+		storageManager.EnableReadOnly()
+	}
 
 	logboek.Context(ctx).Info().LogOptionalLn()
 
@@ -415,10 +528,7 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 		return err
 	}
 
-	var dockerRunArgs []string
-	dockerRunArgs = append(dockerRunArgs, cmdData.DockerOptions...)
-	dockerRunArgs = append(dockerRunArgs, dockerImageName)
-	dockerRunArgs = append(dockerRunArgs, cmdData.DockerCommand...)
+	dockerRunArgs := getDockerRunArgs(giterminismManager.ProjectDir(), dockerImageName)
 
 	if *commonCmdData.DryRun {
 		fmt.Printf("docker run %s\n", strings.Join(dockerRunArgs, " "))
@@ -0,0 +1,101 @@
+package run
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetDockerRunArgs(t *testing.T) {
+	defer func(data cmdDataType) { cmdData = data }(cmdData)
+
+	for _, tc := range []struct {
+		name     string
+		data     cmdDataType
+		expected string
+	}{
+		{
+			name:     "no options",
+			expected: "image",
+		},
+		{
+			name: "docker options and command",
+			data: cmdDataType{
+				DockerOptions: []string{"--rm", "-e", "A=B"},
+				DockerCommand: []string{"echo", "hello"},
+			},
+			expected: "--rm -e A=B image echo hello",
+		},
+		{
+			name: "interactive and tty",
+			data: cmdDataType{
+				Interactive: true,
+				TTY:         true,
+			},
+			expected: "--interactive --tty image",
+		},
+		{
+			name: "publish, env files and volumes",
+			data: cmdDataType{
+				Publish:  []string{"8080:80", "8443:443"},
+				EnvFiles: []string{".env", ".env.local"},
+				Volumes:  []string{"/data:/data", "/cache:/cache:ro"},
+			},
+			expected: "--publish 8080:80 --publish 8443:443 --env-file .env --env-file .env.local --volume /data:/data --volume /cache:/cache:ro image",
+		},
+		{
+			name: "mount project dir before the other volumes",
+			data: cmdDataType{
+				MountProjectDir: "/app",
+				Volumes:         []string{"/data:/data"},
+			},
+			expected: "--volume /project:/app --workdir /app --volume /data:/data image",
+		},
+		{
+			name: "entrypoint and user",
+			data: cmdDataType{
+				Entrypoint:    "/bin/sh",
+				User:          "1000:1000",
+				DockerCommand: []string{"-c", "id"},
+			},
+			expected: "--entrypoint /bin/sh --user 1000:1000 image -c id",
+		},
+		{
+			name: "docker options after the options of the flags take precedence",
+			data: cmdDataType{
+				MountProjectDir: "/app",
+				User:            "1000",
+				TTY:             true,
+				DockerOptions:   []string{"--workdir", "/app/src", "--user=root", "-ti", "--rm"},
+			},
+			expected: "--tty --volume /project:/app --workdir /app --user 1000 --workdir /app/src --user=root -ti --rm image",
+		},
+		{
+			name: "shell defaults with the options of the flags",
+			data: cmdDataType{
+				Shell:         true,
+				Publish:       []string{"3000:3000"},
+				DockerOptions: []string{"-ti", "--rm"},
+				DockerCommand: []string{"/bin/sh"},
+			},
+			expected: "--publish 3000:3000 -ti --rm image /bin/sh",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmdData = tc.data
+
+			if got, expected := getDockerRunArgs("/project", "image"), strings.Fields(tc.expected); !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %q, got %q", expected, got)
+			}
+		})
+	}
+}
+
+func TestGetDockerRunOptions_Empty(t *testing.T) {
+	defer func(data cmdDataType) { cmdData = data }(cmdData)
+
+	cmdData = cmdDataType{DockerOptions: []string{"--rm"}}
+	if options := getDockerRunOptions("/project"); len(options) != 0 {
+		t.Errorf("expected no options without the flags, got %q", options)
+	}
+}
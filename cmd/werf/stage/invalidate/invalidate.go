@@ -0,0 +1,246 @@
+package invalidate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/logging"
+	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	ImageName string
+	StageName string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "invalidate",
+		DisableFlagsInUseLine: true,
+		Short:                 "Invalidate the cache of the image stage",
+		Long: common.GetLongCommandDescription(`Invalidate the cache of the image stage.
+
+The command stores the new cache salt of the stage in the stages storage. The salt takes part in the stage digest, so the next build rebuilds the specified stage and all following stages of the image without changing the cacheVersion directives of werf.yaml. The existing stages are kept and can be removed by the cleanup.
+
+The stage name is one of the stages of the image (e.g. beforeInstall, install, setup for the stapel image or dockerfile for the Dockerfile image), the stages not configured for the image cannot be invalidated.`),
+		Example: `  # Rebuild the install stage and the following stages of the app image
+  $ werf stage invalidate --repo registry.example.com/project --image app --stage install`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if cmdData.StageName == "" {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("--stage is required")
+			}
+
+			return runInvalidate()
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+	common.SetupSSHKnownHosts(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and push images into the specified repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupPlatform(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.ImageName, "image", "", os.Getenv("WERF_IMAGE"), "Name of the image from werf.yaml, can be omitted for the nameless image (default $WERF_IMAGE)")
+	cmd.Flags().StringVarP(&cmdData.StageName, "stage", "", os.Getenv("WERF_STAGE"), "Name of the stage to invalidate (default $WERF_STAGE)")
+
+	return cmd
+}
+
+func runInvalidate() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := lrumeta.Init(); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	if err := common.DockerRegistryInit(ctxWithDockerCli, &commonCmdData); err != nil {
+		return err
+	}
+
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	projectName := werfConfig.Meta.Project
+
+	if !werfConfig.HasImageOrArtifact(cmdData.ImageName) {
+		return fmt.Errorf("specified image %s is not found in werf.yaml", logging.ImageLogName(cmdData.ImageName, false))
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	if err := ssh_agent.Init(ctx, common.GetSSHKey(&commonCmdData)); err != nil {
+		return fmt.Errorf("cannot initialize ssh agent: %s", err)
+	}
+	defer func() {
+		err := ssh_agent.Terminate()
+		if err != nil {
+			logboek.Warn().LogF("WARNING: ssh agent termination failed: %s\n", err)
+		}
+	}()
+
+	if err := common.InitSSHKnownHosts(ctx, &commonCmdData, giterminismManager); err != nil {
+		return fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+
+	stagesStorageAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	if err != nil {
+		return err
+	}
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	finalStagesStorage, err := common.GetOptionalFinalStagesStorage(containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
+	if err != nil {
+		return err
+	}
+	stagesStorageCache, err := flows.GetStagesStorageCache(synchronization)
+	if err != nil {
+		return err
+	}
+	storageLockManager, err := flows.GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return err
+	}
+
+	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, nil, nil, storageLockManager, stagesStorageCache)
+
+	conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, []string{cmdData.ImageName}, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, build.ConveyorOptions{Environment: *commonCmdData.Environment})
+	defer conveyorWithRetry.Terminate()
+
+	if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
+		stageNames, err := c.GetImageStagesNames(ctx, cmdData.ImageName)
+		if err != nil {
+			return err
+		}
+
+		return validateImageStage(cmdData.ImageName, cmdData.StageName, stageNames)
+	}); err != nil {
+		return err
+	}
+
+	var rec *storage.StageCacheSaltRecord
+	if err := logboek.Context(ctx).Default().LogProcess("Invalidating stage %s cache of %s", cmdData.StageName, logging.ImageLogName(cmdData.ImageName, false)).DoError(func() error {
+		rec, err = storageManager.InvalidateStageCache(ctx, cmdData.ImageName, cmdData.StageName)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogF("Stage %s of %s will be rebuilt with the cache salt %d\n", cmdData.StageName, logging.ImageLogName(cmdData.ImageName, false), rec.TimestampMillisec)
+
+	return nil
+}
+
+// validateImageStage checks that the stage takes part in the image build, otherwise the invalidation does not affect the image.
+func validateImageStage(imageName, stageName string, stageNames []string) error {
+	for _, name := range stageNames {
+		if name == stageName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("stage %q is not used by %s: expected one of %s", stageName, logging.ImageLogName(imageName, false), strings.Join(stageNames, ", "))
+}
@@ -0,0 +1,147 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/logging"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	ImageName string
+	Shell     string
+	Rm        bool
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "shell STAGE",
+		DisableFlagsInUseLine: true,
+		Short:                 "Enter the kept container of the failed stage",
+		Long: common.GetLongCommandDescription(`Enter the kept container of the failed stage.
+
+The container of the failed stapel stage is kept by the build with the --keep-failed-stage-container option. The command runs the interactive shell in the state of the container right after the failed assembly instruction. The changes made in the shell are not saved into the kept container, so the command can be run several times.
+
+The kept container of the stage is replaced by the next failed build of the stage and can be removed with the --rm option.`),
+		Example: `  # Enter the failed install stage of the app image
+  $ werf stage shell install --image app
+
+  # Enter the failed setup stage of the nameless image with sh and remove the kept container after exit
+  $ werf stage shell setup --shell /bin/sh --rm`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if len(args) != 1 {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("stage name required")
+			}
+
+			return runShell(args[0])
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "")
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupPlatform(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.ImageName, "image", "", os.Getenv("WERF_IMAGE"), "Name of the image from werf.yaml, can be omitted for the nameless image (default $WERF_IMAGE)")
+	cmd.Flags().StringVarP(&cmdData.Shell, "shell", "", os.Getenv("WERF_INTROSPECT_SHELL"), "Shell command with arguments to run in the stage container (e.g. /bin/sh or \"/bin/sh -l\"), the bash shipped with werf is used by default (default $WERF_INTROSPECT_SHELL)")
+	cmd.Flags().BoolVarP(&cmdData.Rm, "rm", "", common.GetBoolEnvironmentDefaultFalse("WERF_RM"), "Remove the kept container of the stage after the shell exit (default $WERF_RM)")
+
+	return cmd
+}
+
+func runShell(stageName string) error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir, *commonCmdData.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug, *commonCmdData.Platform); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	_, werfConfig, _, err := common.GetRequiredWerfConfigWithDependencies(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	if werfConfig.GetStapelImage(cmdData.ImageName) == nil && werfConfig.GetArtifact(cmdData.ImageName) == nil {
+		return fmt.Errorf("specified stapel image %s is not found in werf.yaml", logging.ImageLogName(cmdData.ImageName, false))
+	}
+
+	containerName := container_runtime.FailedStageContainerName(werfConfig.Meta.Project, cmdData.ImageName, stageName)
+
+	if err := container_runtime.ShellFailedStageContainer(ctx, containerName, cmdData.Shell); err != nil {
+		return err
+	}
+
+	if cmdData.Rm {
+		if err := docker.CliRm(ctx, "--force", containerName); err != nil {
+			return fmt.Errorf("unable to remove container %s: %s", containerName, err)
+		}
+
+		logboek.Context(ctx).Default().LogF("Removed failed stage container %s\n", containerName)
+	}
+
+	return nil
+}
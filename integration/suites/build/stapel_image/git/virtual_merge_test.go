@@ -0,0 +1,71 @@
+package git_test
+
+import (
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/integration/pkg/utils"
+)
+
+var _ = Describe("virtual merge into the target branch", func() {
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("skip on windows")
+		}
+
+		commonBeforeEach(utils.FixturePath("virtual_merge"))
+
+		utils.RunSucceedCommand(SuiteData.TestDirPath, "git", "branch", "-M", "main")
+		utils.RunSucceedCommand(SuiteData.TestDirPath, "git", "checkout", "-b", "feature")
+		createAndCommitFile(SuiteData.TestDirPath, "feature_file", 10)
+
+		utils.RunSucceedCommand(SuiteData.TestDirPath, "git", "checkout", "main")
+		createAndCommitFile(SuiteData.TestDirPath, "main_file", 10)
+
+		utils.RunSucceedCommand(SuiteData.TestDirPath, "git", "checkout", "feature")
+	})
+
+	It("should build the merge of the current commit into the target branch and reuse the stages on the next build", func() {
+		By("first build: the merge commit should be built")
+		out := utils.SucceedCommandOutputString(
+			SuiteData.TestDirPath,
+			SuiteData.WerfBinPath,
+			"build", "--virtual-merge-into", "main",
+		)
+		Ω(out).Should(ContainSubstring("Building stage image/gitArchive"))
+
+		utils.RunSucceedCommand(
+			SuiteData.TestDirPath,
+			SuiteData.WerfBinPath,
+			"run", "--virtual-merge-into", "main", "--docker-options", "--rm", "--", "/bin/bash", "-ec", "test -f /app/feature_file && test -f /app/main_file",
+		)
+
+		By("second build: the recreated merge commit of the same commits should use the cached stages")
+		out = utils.SucceedCommandOutputString(
+			SuiteData.TestDirPath,
+			SuiteData.WerfBinPath,
+			"build", "--virtual-merge-into", "main",
+		)
+		Ω(out).ShouldNot(ContainSubstring("Building stage"))
+
+		By("third build: the changed target branch should be merged")
+		utils.RunSucceedCommand(SuiteData.TestDirPath, "git", "checkout", "main")
+		createAndCommitFile(SuiteData.TestDirPath, "main_file2", 10)
+		utils.RunSucceedCommand(SuiteData.TestDirPath, "git", "checkout", "feature")
+
+		out = utils.SucceedCommandOutputString(
+			SuiteData.TestDirPath,
+			SuiteData.WerfBinPath,
+			"build", "--virtual-merge-into", "main",
+		)
+		Ω(out).Should(ContainSubstring("Building stage image/gitLatestPatch"))
+
+		utils.RunSucceedCommand(
+			SuiteData.TestDirPath,
+			SuiteData.WerfBinPath,
+			"run", "--virtual-merge-into", "main", "--docker-options", "--rm", "--", "/bin/bash", "-ec", "test -f /app/feature_file && test -f /app/main_file2",
+		)
+	})
+})
@@ -16,7 +16,7 @@ import (
 
 var _ = Describe("base", func() {
 	BeforeEach(func() {
-		Ω(werf.Init("", "")).Should(Succeed())
+		Ω(werf.Init("", "", "")).Should(Succeed())
 		SuiteData.CommitProjectWorktree(SuiteData.ProjectName, utils.FixturePath("base"), "initial commit")
 	})
 
@@ -0,0 +1,58 @@
+package giterminism_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/integration/pkg/utils"
+)
+
+var _ = Describe("config include", func() {
+	BeforeEach(CommonBeforeEach)
+
+	type entry struct {
+		includeRef           string
+		allowIncludeRemote   bool
+		expectedErrSubstring string
+	}
+
+	DescribeTable("config.include.allowRemote and config.include.allowBranch",
+		func(e entry) {
+			fileCreateOrAppend("werf.yaml", `
+include:
+- git: https://github.com/werf/werf.git
+  `+e.includeRef+`
+  path: werf.yaml
+`)
+			gitAddAndCommit("werf.yaml")
+
+			if e.allowIncludeRemote {
+				contentToAppend := `
+config:
+  include:
+    allowRemote: true`
+				fileCreateOrAppend("werf-giterminism.yaml", contentToAppend)
+				gitAddAndCommit("werf-giterminism.yaml")
+			}
+
+			output, err := utils.RunCommand(
+				SuiteData.TestDirPath,
+				SuiteData.WerfBinPath,
+				"config", "render",
+			)
+
+			Ω(err).Should(HaveOccurred())
+			Ω(string(output)).Should(ContainSubstring(e.expectedErrSubstring))
+		},
+		Entry("the remote include not allowed", entry{
+			includeRef:           "tag: v1.2.0",
+			expectedErrSubstring: `the configuration with potential external dependency found in the werf config: remote include "https://github.com/werf/werf.git@v1.2.0:werf.yaml" not allowed by giterminism`,
+		}),
+		Entry("the remote include branch not allowed", entry{
+			includeRef:           "branch: main",
+			allowIncludeRemote:   true,
+			expectedErrSubstring: "the configuration with potential external dependency found in the werf config: include branch directive not allowed by giterminism",
+		}),
+	)
+})
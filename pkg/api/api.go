@@ -0,0 +1,458 @@
+// Package api allows to embed werf into other Go programs and run the main werf flows without the werf binary.
+//
+// The werf runtime (the werf home and tmp dirs, the docker client, the container registry client and the ssh agent) is shared by the process,
+// so it is initialized once with Init before the flows are run and released with Terminate.
+// Each flow applies the registry mirrors, the ssh known hosts and the kube config of the project to the runtime,
+// so the flows can be run concurrently only for the projects sharing these settings.
+// The flows are configured only with the options structs of the package, the WERF_* environment variables are not used.
+// The options that are not set explicitly get the defaults of the corresponding werf command flags as described in the CLI reference.
+//
+// The errors are returned to the caller, the process is never terminated.
+// The werf output is written into the logger bound to the passed context (see logboek.NewContext) or into the Logger option.
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/types"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"helm.sh/helm/v3/pkg/cli/values"
+
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/deploy/secrets_manager"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/flows"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var (
+	runtimeMutex       sync.Mutex
+	runtimeInitialized bool
+	// runtimeOptions are used for the container registries of the flows
+	runtimeOptions InitOptions
+)
+
+type InitOptions struct {
+	// TmpDir is the werf tmp dir (--tmp-dir), the system tmp dir is used by default
+	TmpDir string
+	// HomeDir is the werf home dir (--home-dir), ~/.werf is used by default
+	HomeDir string
+	// HomeIsolationKey isolates the werf home and tmp dirs of the process by the key (--home-isolation-key)
+	HomeIsolationKey string
+	// DockerConfig is the docker config dir (--docker-config), ~/.docker is used by default
+	DockerConfig string
+	// Platform is the target platform of the built images (--platform)
+	Platform string
+
+	// InsecureRegistry allows the insecure container registries (--insecure-registry)
+	InsecureRegistry bool
+	// SkipTlsVerifyRegistry skips the TLS verification of the container registries (--skip-tls-verify-registry)
+	SkipTlsVerifyRegistry bool
+
+	// SSHKeys are the ssh keys loaded into the werf ssh agent (--ssh-key), the system ssh agent or ~/.ssh/id_rsa is used by default
+	SSHKeys []string
+
+	// Logger receives the werf output of the initialization, the logger bound to the context or the default logger is used if not specified
+	Logger types.LoggerInterface `json:"-"`
+}
+
+// Init initializes the werf runtime of the process, it should be called once before the flows are run.
+func Init(ctx context.Context, opts InitOptions) error {
+	runtimeMutex.Lock()
+	defer runtimeMutex.Unlock()
+
+	if runtimeInitialized {
+		return fmt.Errorf("werf runtime is already initialized")
+	}
+
+	ctx = contextWithLogger(ctx, opts.Logger)
+
+	if err := werf.Init(opts.TmpDir, opts.HomeDir, opts.HomeIsolationKey); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := lrumeta.Init(); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{}); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, opts.DockerConfig, false, false, opts.Platform); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(ctxWithDockerCli, opts.InsecureRegistry, opts.SkipTlsVerifyRegistry); err != nil {
+		return err
+	}
+
+	if err := ssh_agent.Init(ctx, opts.SSHKeys); err != nil {
+		return fmt.Errorf("cannot initialize ssh agent: %s", err)
+	}
+
+	runtimeInitialized = true
+	runtimeOptions = opts
+
+	return nil
+}
+
+// Terminate releases the werf runtime of the process: stops the werf ssh agent and removes the tmp data of the flows.
+func Terminate(ctx context.Context) error {
+	runtimeMutex.Lock()
+	defer runtimeMutex.Unlock()
+
+	if !runtimeInitialized {
+		return nil
+	}
+	runtimeInitialized = false
+
+	if err := ssh_agent.Terminate(); err != nil {
+		return fmt.Errorf("ssh agent termination failed: %s", err)
+	}
+
+	return tmp_manager.CleanupRunData(contextWithLogger(ctx, nil))
+}
+
+type CommonOptions struct {
+	// Dir is the project directory (--dir), the current directory is used by default
+	Dir string
+	// Env is the werf environment (--env)
+	Env string
+	// Repo is the container registry storage address (--repo), the local docker server is used by the Build flow by default
+	Repo string
+
+	// Logger receives the werf output, the logger bound to the context or the default logger is used if not specified
+	Logger types.LoggerInterface `json:"-"`
+}
+
+type BuildOptions struct {
+	CommonOptions
+
+	// ImageNames limits the images to build, all images from werf.yaml are built by default
+	ImageNames []string
+}
+
+// Build builds images that are described in werf.yaml (werf build).
+func Build(ctx context.Context, opts BuildOptions) error {
+	ctx, err := newFlowContext(ctx, opts.Logger)
+	if err != nil {
+		return err
+	}
+
+	p, err := openProject(ctx, opts.CommonOptions)
+	if err != nil {
+		return err
+	}
+
+	stagesStorageAddress := opts.Repo
+	if stagesStorageAddress == "" {
+		stagesStorageAddress = storage.LocalStorageAddress
+	}
+
+	_, err = p.build(ctx, stagesStorageAddress, opts.ImageNames)
+	return err
+}
+
+type ConvergeOptions struct {
+	CommonOptions
+
+	// Namespace is the kubernetes namespace of the release (--namespace)
+	Namespace string
+	// Release is the helm release name (--release)
+	Release string
+	// Set are the helm values in the KEY=VALUE format (--set)
+	Set []string
+	// ValuesFiles are the paths to the helm values files (--values)
+	ValuesFiles []string
+}
+
+// Converge builds images and deploys the application into kubernetes (werf converge).
+func Converge(ctx context.Context, opts ConvergeOptions) error {
+	ctx, err := newFlowContext(ctx, opts.Logger)
+	if err != nil {
+		return err
+	}
+
+	p, err := openProject(ctx, opts.CommonOptions)
+	if err != nil {
+		return err
+	}
+
+	var imagesInfoGetters []*image.InfoGetter
+	var imagesRepository string
+	if len(p.werfConfig.StapelImages) != 0 || len(p.werfConfig.ImagesFromDockerfile) != 0 {
+		stagesStorageAddress, err := getRequiredRepo(opts.CommonOptions)
+		if err != nil {
+			return err
+		}
+
+		if imagesInfoGetters, err = p.build(ctx, stagesStorageAddress, nil); err != nil {
+			return err
+		}
+		imagesRepository = p.stagesStorage.String()
+	}
+
+	chartDir, err := flows.GetHelmChartDir(p.werfConfigPath, p.werfConfig, p.giterminismManager)
+	if err != nil {
+		return fmt.Errorf("getting helm chart dir failed: %s", err)
+	}
+
+	releaseName, err := flows.GetHelmRelease(opts.Release, opts.Env, p.werfConfig)
+	if err != nil {
+		return err
+	}
+
+	targets, err := flows.GetDeployTargets(opts.Namespace, "", opts.Env, p.werfConfig)
+	if err != nil {
+		return err
+	}
+
+	metadataTemplateData := flows.GetMetadataTemplateData(opts.Env, p.werfConfig, p.giterminismManager)
+	extraAnnotations, err := p.werfConfig.Meta.Metadata.RenderAnnotations(metadataTemplateData)
+	if err != nil {
+		return err
+	}
+	extraLabels, err := p.werfConfig.Meta.Metadata.RenderLabels(metadataTemplateData)
+	if err != nil {
+		return err
+	}
+
+	kubeInitializer := &flows.OndemandKubeInitializer{}
+	if err := kubeInitializer.Init(ctx); err != nil {
+		return err
+	}
+
+	return flows.Deploy(ctx, p.giterminismManager, p.werfConfig, targets, flows.DeployOptions{
+		ChartDir:                  chartDir,
+		ReleaseName:               releaseName,
+		Env:                       opts.Env,
+		ImagesRepository:          imagesRepository,
+		ImagesInfoGetters:         imagesInfoGetters,
+		Dependencies:              p.werfConfig.Dependencies,
+		SecretsManager:            secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{}),
+		ExtraAnnotations:          extraAnnotations,
+		ExtraLabels:               extraLabels,
+		ValuesOptions:             values.Options{ValueFiles: opts.ValuesFiles, Values: opts.Set},
+		DockerConfigPath:          runtimeOptions.DockerConfig,
+		KubeConfigOptions:         kubeInitializer.KubeConfigOptions(),
+		KubeInitializer:           kubeInitializer,
+		StatusProgressPeriod:      5 * time.Second,
+		HooksStatusProgressPeriod: 5 * time.Second,
+	})
+}
+
+type CleanupOptions struct {
+	CommonOptions
+
+	// DryRun only shows the images which would be deleted (--dry-run)
+	DryRun bool
+}
+
+// Cleanup cleans up unused project images in the container registry according to the cleanup policies (werf cleanup).
+func Cleanup(ctx context.Context, opts CleanupOptions) error {
+	ctx, err := newFlowContext(ctx, opts.Logger)
+	if err != nil {
+		return err
+	}
+
+	stagesStorageAddress, err := getRequiredRepo(opts.CommonOptions)
+	if err != nil {
+		return err
+	}
+
+	p, err := openProject(ctx, opts.CommonOptions)
+	if err != nil {
+		return err
+	}
+
+	storageOptions, err := p.getStorageOptions(stagesStorageAddress)
+	if err != nil {
+		return err
+	}
+
+	kubernetesContextClients, err := kube.GetAllContextsClients(kube.GetAllContextsClientsOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
+	}
+
+	// all namespaces of the kube contexts are scanned
+	kubernetesNamespaceRestrictionByContext := map[string]string{}
+	for _, contextClient := range kubernetesContextClients {
+		kubernetesNamespaceRestrictionByContext[contextClient.ContextName] = ""
+	}
+
+	return flows.Cleanup(ctx, p.giterminismManager, p.werfConfig, flows.CleanupOptions{
+		Storage:                                 storageOptions,
+		Parallel:                                true,
+		ParallelTasksLimit:                      flows.DefaultCleanupParallelTasksLimit,
+		KubernetesContextClients:                kubernetesContextClients,
+		KubernetesNamespaceRestrictionByContext: kubernetesNamespaceRestrictionByContext,
+		KeepStagesBuiltWithinLastNHours:         2,
+		DryRun:                                  opts.DryRun,
+	})
+}
+
+// project is the opened werf project of the flow.
+type project struct {
+	env                string
+	giterminismManager giterminism_manager.Interface
+	werfConfigPath     string
+	werfConfig         *config.WerfConfig
+	stagesStorage      storage.StagesStorage
+}
+
+// openProject reads the werf.yaml of the project and applies the registry mirrors and the ssh known hosts of the project to the werf runtime.
+func openProject(ctx context.Context, opts CommonOptions) (*project, error) {
+	giterminismManager, err := flows.NewGiterminismManager(ctx, flows.GiterminismOptions{Dir: opts.Dir})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ssh_agent.InitKnownHosts(ctx, ssh_agent.KnownHostsOptions{PinnedKnownHosts: giterminismManager.SSHKnownHosts()}); err != nil {
+		return nil, fmt.Errorf("cannot initialize ssh known hosts: %s", err)
+	}
+
+	werfConfigPath, werfConfig, err := flows.GetRequiredWerfConfig(ctx, giterminismManager, flows.WerfConfigOptions{
+		WerfConfigOptions: config.WerfConfigOptions{
+			LogRenderedFilePath: true,
+			Env:                 opts.Env,
+			ResolveDependencies: flows.NewDependenciesResolver(giterminismManager, flows.DependenciesOptions{
+				Repo:             opts.Repo,
+				NewStagesStorage: newStagesStorage,
+			}),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	flows.InitRegistryMirrors(nil, werfConfig)
+
+	return &project{env: opts.Env, giterminismManager: giterminismManager, werfConfigPath: werfConfigPath, werfConfig: werfConfig}, nil
+}
+
+func (p *project) build(ctx context.Context, stagesStorageAddress string, imageNames []string) ([]*image.InfoGetter, error) {
+	storageOptions, err := p.getStorageOptions(stagesStorageAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return flows.Build(ctx, p.giterminismManager, p.werfConfig, flows.BuildOptions{
+		ImageNames:       imageNames,
+		ContainerRuntime: &container_runtime.LocalDockerServerRuntime{},
+		Storage:          storageOptions,
+		ConveyorOptions: build.ConveyorOptions{
+			Environment:        p.env,
+			Parallel:           true,
+			ParallelTasksLimit: flows.DefaultBuildParallelTasksLimit,
+		},
+		BuildOptions: build.BuildOptions{ReportFormat: build.ReportJSON},
+	})
+}
+
+// getStorageOptions returns the storages of the project, the local docker server is the secondary storage of the container registry storage.
+func (p *project) getStorageOptions(stagesStorageAddress string) (flows.StorageOptions, error) {
+	stagesStorage, err := newStagesStorage(stagesStorageAddress)
+	if err != nil {
+		return flows.StorageOptions{}, err
+	}
+	p.stagesStorage = stagesStorage
+
+	var secondaryStagesStorageList []storage.StagesStorage
+	if stagesStorageAddress != storage.LocalStorageAddress {
+		localStagesStorage, err := newStagesStorage(storage.LocalStorageAddress)
+		if err != nil {
+			return flows.StorageOptions{}, fmt.Errorf("unable to create local secondary stages storage: %s", err)
+		}
+		secondaryStagesStorageList = append(secondaryStagesStorageList, localStagesStorage)
+	}
+
+	return flows.StorageOptions{StagesStorage: stagesStorage, SecondaryStagesStorageList: secondaryStagesStorageList}, nil
+}
+
+func newStagesStorage(address string) (storage.StagesStorage, error) {
+	return storage.NewStagesStorage(address, &container_runtime.LocalDockerServerRuntime{}, storage.StagesStorageOptions{
+		RepoStagesStorageOptions: storage.RepoStagesStorageOptions{
+			DockerRegistryOptions: docker_registry.DockerRegistryOptions{
+				InsecureRegistry:      runtimeOptions.InsecureRegistry,
+				SkipTlsVerifyRegistry: runtimeOptions.SkipTlsVerifyRegistry,
+			},
+		},
+	})
+}
+
+func getRequiredRepo(opts CommonOptions) (string, error) {
+	if opts.Repo == "" || opts.Repo == storage.LocalStorageAddress {
+		return "", fmt.Errorf("repo is required: the Repo option should be specified")
+	}
+
+	return opts.Repo, nil
+}
+
+// newFlowContext checks the werf runtime is initialized and returns the flow context with the logger and the docker cli.
+func newFlowContext(ctx context.Context, logger types.LoggerInterface) (context.Context, error) {
+	if err := checkRuntimeInitialized(); err != nil {
+		return nil, err
+	}
+
+	return docker.NewContext(contextWithLogger(ctx, logger))
+}
+
+func checkRuntimeInitialized() error {
+	runtimeMutex.Lock()
+	defer runtimeMutex.Unlock()
+
+	if !runtimeInitialized {
+		return fmt.Errorf("werf runtime is not initialized: api.Init should be called first")
+	}
+
+	return nil
+}
+
+func contextWithLogger(ctx context.Context, logger types.LoggerInterface) context.Context {
+	if logger != nil {
+		return logboek.NewContext(ctx, logger)
+	}
+
+	if ctx == context.Background() {
+		return logboek.NewContext(ctx, logboek.DefaultLogger())
+	}
+
+	return ctx
+}
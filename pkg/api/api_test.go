@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+func newTestContext() context.Context {
+	return logboek.NewContext(context.Background(), logboek.NewLogger(ioutil.Discard, ioutil.Discard))
+}
+
+// setupFakeDockerServer points the docker client to the fake docker server, which answers only the system requests of the runtime initialization.
+func setupFakeDockerServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.41")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			_, _ = w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			_, _ = w.Write([]byte(`{"OSType":"linux"}`))
+		case strings.HasSuffix(r.URL.Path, "/version"):
+			_, _ = w.Write([]byte(`{"ApiVersion":"1.41","Os":"linux","Arch":"amd64"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	prevDockerHost, isSet := os.LookupEnv("DOCKER_HOST")
+	if err := os.Setenv("DOCKER_HOST", "tcp://"+server.Listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if isSet {
+			_ = os.Setenv("DOCKER_HOST", prevDockerHost)
+		} else {
+			_ = os.Unsetenv("DOCKER_HOST")
+		}
+	})
+}
+
+func newTestInitOptions(t *testing.T) InitOptions {
+	setupFakeDockerServer(t)
+
+	dir := t.TempDir()
+	return InitOptions{
+		TmpDir:       filepath.Join(dir, "tmp"),
+		HomeDir:      filepath.Join(dir, "home"),
+		DockerConfig: filepath.Join(dir, "docker"),
+		Platform:     "linux/amd64",
+	}
+}
+
+func TestFlows_NotInitialized(t *testing.T) {
+	ctx := newTestContext()
+
+	for name, run := range map[string]func() error{
+		"Build":    func() error { return Build(ctx, BuildOptions{}) },
+		"Converge": func() error { return Converge(ctx, ConvergeOptions{}) },
+		"Cleanup":  func() error { return Cleanup(ctx, CleanupOptions{}) },
+	} {
+		if err := run(); err == nil || !strings.Contains(err.Error(), "werf runtime is not initialized") {
+			t.Fatalf("%s: expected the not initialized runtime error, got %v", name, err)
+		}
+	}
+}
+
+func TestInitTerminate(t *testing.T) {
+	ctx := newTestContext()
+	opts := newTestInitOptions(t)
+
+	if err := Init(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRuntimeInitialized(); err != nil {
+		t.Fatalf("expected the initialized runtime, got %v", err)
+	}
+
+	if err := Init(ctx, opts); err == nil || !strings.Contains(err.Error(), "werf runtime is already initialized") {
+		t.Fatalf("expected the already initialized runtime error, got %v", err)
+	}
+
+	if err := Terminate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRuntimeInitialized(); err == nil {
+		t.Fatal("expected the not initialized runtime error after Terminate")
+	}
+	if err := Build(ctx, BuildOptions{}); err == nil || !strings.Contains(err.Error(), "werf runtime is not initialized") {
+		t.Fatalf("expected the not initialized runtime error after Terminate, got %v", err)
+	}
+
+	// the terminated runtime is not terminated again
+	if err := Terminate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the runtime can be initialized again after Terminate
+	if err := Init(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := Terminate(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInit_HomeIsolationKey(t *testing.T) {
+	ctx := newTestContext()
+	opts := newTestInitOptions(t)
+	opts.HomeIsolationKey = "pipeline-1"
+
+	if err := Init(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := Terminate(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if homeDir := werf.GetHomeDir(); !strings.HasPrefix(homeDir, filepath.Join(opts.HomeDir, "isolated")+string(filepath.Separator)) {
+		t.Fatalf("expected the werf home dir isolated by the key in %s, got %s", opts.HomeDir, homeDir)
+	}
+	if tmpDir := werf.GetTmpDir(); !strings.HasPrefix(filepath.Base(tmpDir), "werf-isolated-") {
+		t.Fatalf("expected the werf tmp dir isolated by the key, got %s", tmpDir)
+	}
+}
+
+func TestCleanup_RepoRequired(t *testing.T) {
+	ctx := newTestContext()
+
+	if err := Init(ctx, newTestInitOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := Terminate(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := Cleanup(ctx, CleanupOptions{CommonOptions: CommonOptions{Dir: t.TempDir()}}); err == nil || !strings.Contains(err.Error(), "repo is required") {
+		t.Fatalf("expected the repo required error, got %v", err)
+	}
+}
@@ -18,6 +18,8 @@ func (phase *BasePhase) AfterImages(_ context.Context) error {
 	return nil
 }
 
+func (phase *BasePhase) AfterImagesFailed(_ context.Context) {}
+
 func (phase *BasePhase) BeforeImageStages(_ context.Context, _ *Image) error {
 	return nil
 }
@@ -8,22 +8,30 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/werf/lockgate"
 	"github.com/werf/logboek"
 	"github.com/werf/logboek/pkg/style"
 	"github.com/werf/logboek/pkg/types"
 
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/events"
 	"github.com/werf/werf/pkg/image"
 	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/remote_builder"
+	"github.com/werf/werf/pkg/ssh_agent"
 	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -31,14 +39,22 @@ import (
 type BuildPhaseOptions struct {
 	BuildOptions
 	ShouldBeBuiltMode bool
+	// CalculateDigestsOnlyMode calculates the stages digests and selects the stages stored in the repo without building, fetching and publishing anything,
+	// the result is saved into the DigestsReport.
+	CalculateDigestsOnlyMode bool
 }
 
 type BuildOptions struct {
 	ImageBuildOptions container_runtime.BuildOptions
 	IntrospectOptions
 
-	ReportPath   string
-	ReportFormat ReportFormat
+	ReportPath            string
+	ReportFormat          ReportFormat
+	ReportSupplyChainPath string
+
+	ScanOptions
+	StageLogsOptions
+	GraphOptions
 }
 
 type IntrospectOptions struct {
@@ -64,7 +80,8 @@ func NewBuildPhase(c *Conveyor, opts BuildPhaseOptions) *BuildPhase {
 	return &BuildPhase{
 		BasePhase:         BasePhase{c},
 		BuildPhaseOptions: opts,
-		ImagesReport:      &ImagesReport{Images: make(map[string]ReportImageRecord)},
+		ImagesReport:      &ImagesReport{Images: make(map[string]ReportImageRecord), supplyChain: make(map[string]*ReportSupplyChainRecord), stages: make(map[string][]ReportStageRecord)},
+		DigestsReport:     newDigestsReport(),
 	}
 }
 
@@ -75,7 +92,20 @@ type BuildPhase struct {
 	StagesIterator              *StagesIterator
 	ShouldAddManagedImageRecord bool
 
-	ImagesReport *ImagesReport
+	// imageUnchanged is set when the image inputs are not changed since the previous build and the image stages are not processed
+	imageUnchanged bool
+
+	// imageNotBuilt is set in the calculate digests only mode when the image stage or dependency is not built and the next stages are not processed
+	imageNotBuilt bool
+
+	// stageBuildDuration and stagePushDuration are the durations of the last built stage
+	stageBuildDuration time.Duration
+	stagePushDuration  time.Duration
+	// stageLogPath is the captured build log of the last built stage (--stage-logs-dir)
+	stageLogPath string
+
+	ImagesReport  *ImagesReport
+	DigestsReport *DigestsReport
 }
 
 const (
@@ -88,14 +118,51 @@ type ReportFormat string
 type ImagesReport struct {
 	mux    sync.Mutex
 	Images map[string]ReportImageRecord
+	// DigestedEnv contains the sha256 hashes of the values of the digested environment variables used in the werf config templates
+	DigestedEnv map[string]string `json:",omitempty"`
+
+	supplyChain map[string]*ReportSupplyChainRecord
+	stages      map[string][]ReportStageRecord
 }
 
 func (report *ImagesReport) SetImageRecord(name string, imageRecord ReportImageRecord) {
 	report.mux.Lock()
 	defer report.mux.Unlock()
+
+	if supplyChainRecord, ok := report.supplyChain[name]; ok {
+		imageRecord.ReportSupplyChainRecord.merge(*supplyChainRecord)
+	}
 	report.Images[name] = imageRecord
 }
 
+// SetDigestedEnv records the hashes of the digested env values, the values themselves may be secret and are not written into the report.
+func (report *ImagesReport) SetDigestedEnv(digestedEnv map[string]string) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	report.DigestedEnv = map[string]string{}
+	for name, value := range digestedEnv {
+		report.DigestedEnv[name] = util.Sha256Hash(value)
+	}
+}
+
+// AddImageSupplyChainRecord adds SBOM references, vulnerability scan summary and attestation digests produced for the image.
+// The data is included into the image record of the report.
+func (report *ImagesReport) AddImageSupplyChainRecord(name string, supplyChainRecord ReportSupplyChainRecord) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	if _, ok := report.supplyChain[name]; !ok {
+		report.supplyChain[name] = &ReportSupplyChainRecord{}
+	}
+	report.supplyChain[name].merge(supplyChainRecord)
+
+	if imageRecord, ok := report.Images[name]; ok {
+		imageRecord.ReportSupplyChainRecord.merge(supplyChainRecord)
+		report.Images[name] = imageRecord
+	}
+}
+
 func (report *ImagesReport) ToJsonData() ([]byte, error) {
 	report.mux.Lock()
 	defer report.mux.Unlock()
@@ -122,20 +189,31 @@ func (report *ImagesReport) ToEnvFileData() []byte {
 	return buf.Bytes()
 }
 
+func baseImagesFromLabels(labels map[string]string) []string {
+	value := labels[imagePkg.WerfBaseImagesLabel]
+	if value == "" {
+		return nil
+	}
+
+	return strings.Split(value, ",")
+}
+
 func generateImageEnv(werfImageName, imageName string) string {
-	var imageEnvName string
+	return fmt.Sprintf("%s=%s", GetImageEnvName(werfImageName), imageName)
+}
+
+// GetImageEnvName returns the name of the environment variable with the docker image name of the werf image.
+func GetImageEnvName(werfImageName string) string {
 	if werfImageName == "" {
-		imageEnvName = "WERF_DOCKER_IMAGE_NAME"
-	} else {
-		werfImageName := strings.ToUpper(werfImageName)
-		for _, l := range []string{"/", "-"} {
-			werfImageName = strings.ReplaceAll(werfImageName, l, "_")
-		}
+		return "WERF_DOCKER_IMAGE_NAME"
+	}
 
-		imageEnvName = fmt.Sprintf("WERF_%s_DOCKER_IMAGE_NAME", werfImageName)
+	werfImageName = strings.ToUpper(werfImageName)
+	for _, l := range []string{"/", "-"} {
+		werfImageName = strings.ReplaceAll(werfImageName, l, "_")
 	}
 
-	return fmt.Sprintf("%s=%s", imageEnvName, imageName)
+	return fmt.Sprintf("WERF_%s_DOCKER_IMAGE_NAME", werfImageName)
 }
 
 type ReportImageRecord struct {
@@ -145,6 +223,10 @@ type ReportImageRecord struct {
 	DockerImageID     string
 	DockerImageDigest string
 	DockerImageName   string
+	BaseImages        []string            `json:",omitempty"`
+	Stages            []ReportStageRecord `json:",omitempty"`
+
+	ReportSupplyChainRecord
 }
 
 func (phase *BuildPhase) Name() string {
@@ -159,10 +241,45 @@ func (phase *BuildPhase) BeforeImages(ctx context.Context) error {
 }
 
 func (phase *BuildPhase) AfterImages(ctx context.Context) error {
+	if phase.CalculateDigestsOnlyMode {
+		return nil
+	}
+
 	return phase.createReport(ctx)
 }
 
+// AfterImagesFailed saves the build graph of the stages processed before the failure.
+func (phase *BuildPhase) AfterImagesFailed(ctx context.Context) {
+	if phase.CalculateDigestsOnlyMode || phase.GraphPath == "" {
+		return
+	}
+
+	if err := phase.saveGraph(); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: %s\n", err)
+	}
+}
+
 func (phase *BuildPhase) createReport(ctx context.Context) error {
+	if phase.ReportSupplyChainPath != "" {
+		supplyChainData, err := LoadReportSupplyChainData(phase.ReportSupplyChainPath)
+		if err != nil {
+			return err
+		}
+
+		exportedImagesNames := phase.Conveyor.GetExportedImagesNames()
+		for imageName, supplyChainRecord := range supplyChainData {
+			if !util.IsStringsContainValue(exportedImagesNames, imageName) {
+				return fmt.Errorf("image %q from supply-chain data %s not found", imageName, phase.ReportSupplyChainPath)
+			}
+
+			phase.ImagesReport.AddImageSupplyChainRecord(imageName, supplyChainRecord)
+		}
+	}
+
+	if len(phase.Conveyor.werfConfig.DigestedEnv) > 0 {
+		phase.ImagesReport.SetDigestedEnv(phase.Conveyor.werfConfig.DigestedEnv)
+	}
+
 	for _, img := range phase.Conveyor.images {
 		if img.isArtifact {
 			continue
@@ -176,9 +293,20 @@ func (phase *BuildPhase) createReport(ctx context.Context) error {
 			DockerImageID:     desc.Info.ID,
 			DockerImageDigest: desc.Info.RepoDigest,
 			DockerImageName:   desc.Info.Name,
+			BaseImages:        baseImagesFromLabels(desc.Info.Labels),
+			Stages:            phase.getImageReportStages(img),
 		})
 	}
 
+	// the report is written before failing on the scan gate to keep the scan results
+	var scanFailedImages []string
+	if phase.Scanner != nil {
+		var err error
+		if scanFailedImages, err = phase.scanImages(ctx); err != nil {
+			return err
+		}
+	}
+
 	debugJsonData, err := phase.ImagesReport.ToJsonData()
 	logboek.Context(ctx).Debug().LogF("ImagesReport: (err: %s)\n%s", err, debugJsonData)
 
@@ -203,26 +331,87 @@ func (phase *BuildPhase) createReport(ctx context.Context) error {
 		}
 	}
 
+	if phase.GraphPath != "" {
+		if err := phase.saveGraph(); err != nil {
+			return err
+		}
+	}
+
+	if len(scanFailedImages) > 0 {
+		return fmt.Errorf("vulnerabilities at or above %s severity found in images: %s", phase.ScanSeverityThreshold, strings.Join(scanFailedImages, ", "))
+	}
+
 	return nil
 }
 
+// getImageReportStages returns the stage records of the image,
+// the storage of the stages fetched from the cache stages storages during the build is changed to the cache.
+func (phase *BuildPhase) getImageReportStages(img *Image) []ReportStageRecord {
+	var records []ReportStageRecord
+	for _, record := range phase.ImagesReport.getImageStageRecords(img.GetName()) {
+		if record.Storage == ReportStageStoragePrimary && phase.Conveyor.StorageManager.GetCacheStagesStorageOfFetchedStage(record.stg) != nil {
+			record.Storage = ReportStageStorageCache
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
 func (phase *BuildPhase) ImageProcessingShouldBeStopped(_ context.Context, _ *Image) bool {
 	return false
 }
 
-func (phase *BuildPhase) BeforeImageStages(_ context.Context, img *Image) error {
+func (phase *BuildPhase) BeforeImageStages(ctx context.Context, img *Image) error {
+	events.Emit(events.Event{Type: events.ImageBuildStarted, Image: img.GetName()})
+
 	phase.StagesIterator = NewStagesIterator(phase.Conveyor)
+	phase.imageUnchanged = false
+	phase.imageNotBuilt = false
+
+	if phase.CalculateDigestsOnlyMode {
+		if dependencyName := phase.getNotBuiltDependencyName(img); dependencyName != "" {
+			logboek.Context(ctx).Default().LogFHighlight("Dependency %s is not built, image stages digests cannot be calculated\n", dependencyName)
+			phase.DigestsReport.SetImageNotBuiltDependency(img, dependencyName)
+			phase.imageNotBuilt = true
+			return nil
+		}
+	}
 
 	img.SetupBaseImage(phase.Conveyor)
 
+	if img.inputsDigest != "" {
+		imageUnchanged, err := phase.useUnchangedImageLastStage(ctx, img)
+		if err != nil {
+			return fmt.Errorf("unable to check image %s is unchanged: %s", img.GetName(), err)
+		}
+
+		phase.imageUnchanged = imageUnchanged
+	}
+
 	return nil
 }
 
 func (phase *BuildPhase) AfterImageStages(ctx context.Context, img *Image) error {
+	if phase.CalculateDigestsOnlyMode {
+		return phase.afterImageStagesDigests(img)
+	}
+
 	img.SetLastNonEmptyStage(phase.StagesIterator.PrevNonEmptyStage)
 	img.SetContentDigest(phase.StagesIterator.PrevNonEmptyStage.GetContentDigest())
 
+	if img.inputsDigest != "" && !phase.imageUnchanged {
+		if err := phase.saveUnchangedImageLastStage(ctx, img); err != nil {
+			return fmt.Errorf("unable to save image %s inputs digest record: %s", img.GetName(), err)
+		}
+	}
+
+	if phase.imageUnchanged {
+		phase.addImageStageRecord(img, newReportStageRecord(img.GetLastNonEmptyStage(), ReportStageCacheHit, ReportStageStoragePrimary, 0, 0))
+	}
+
 	if img.isArtifact {
+		events.Emit(events.Event{Type: events.ImageBuildFinished, Image: img.GetName(), Digest: img.GetLastNonEmptyStage().GetDigest()})
 		return nil
 	}
 
@@ -234,17 +423,41 @@ func (phase *BuildPhase) AfterImageStages(ctx context.Context, img *Image) error
 		return err
 	}
 
-	if phase.Conveyor.StorageManager.GetFinalStagesStorage() != nil {
+	if finalStagesStorage := phase.Conveyor.StorageManager.GetFinalStagesStorage(); finalStagesStorage != nil {
 		if err := phase.Conveyor.StorageManager.CopyStageIntoFinalRepo(ctx, img.GetLastNonEmptyStage(), phase.Conveyor.ContainerRuntime); err != nil {
 			return err
 		}
+
+		events.Emit(events.Event{Type: events.ImagePushed, Image: img.GetName(), Digest: img.GetLastNonEmptyStage().GetDigest(), Repo: finalStagesStorage.String()})
 	}
 
+	events.Emit(events.Event{
+		Type:            events.ImageBuildFinished,
+		Image:           img.GetName(),
+		Digest:          img.GetLastNonEmptyStage().GetDigest(),
+		DockerImageName: img.GetLastNonEmptyStage().GetImage().Name(),
+	})
+
 	return nil
 }
 
+// addImageStageRecord adds the stage record into the report and emits the stage finished event.
+func (phase *BuildPhase) addImageStageRecord(img *Image, record ReportStageRecord) {
+	phase.ImagesReport.AddImageStageRecord(img.GetName(), record)
+
+	events.Emit(events.Event{
+		Type:         events.StageFinished,
+		Image:        img.GetName(),
+		Stage:        record.Name,
+		Digest:       record.Digest,
+		CacheOutcome: record.CacheOutcome,
+		Storage:      record.Storage,
+		Duration:     record.BuildDuration + record.PushDuration,
+	})
+}
+
 func (phase *BuildPhase) addManagedImage(ctx context.Context, img *Image) error {
-	if phase.ShouldAddManagedImageRecord {
+	if phase.ShouldAddManagedImageRecord && !phase.Conveyor.StorageManager.IsReadOnly() {
 		if err := phase.Conveyor.StorageManager.GetStagesStorage().AddManagedImage(ctx, phase.Conveyor.projectName(), img.GetName()); err != nil {
 			return fmt.Errorf("unable to add image %q to the managed images of project %q: %s", img.GetName(), phase.Conveyor.projectName(), err)
 		}
@@ -254,6 +467,10 @@ func (phase *BuildPhase) addManagedImage(ctx context.Context, img *Image) error
 }
 
 func (phase *BuildPhase) publishImageMetadata(ctx context.Context, img *Image) error {
+	if phase.Conveyor.StorageManager.IsReadOnly() {
+		return nil
+	}
+
 	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Processing image %s git metadata", img.GetName())).
 		DoError(func() error {
 			var commits []string
@@ -293,6 +510,10 @@ func (phase *BuildPhase) getPrevNonEmptyStageImageSize() int64 {
 }
 
 func (phase *BuildPhase) OnImageStage(ctx context.Context, img *Image, stg stage.Interface) error {
+	if phase.imageUnchanged || phase.imageNotBuilt {
+		return nil
+	}
+
 	return phase.StagesIterator.OnImageStage(ctx, img, stg, func(img *Image, stg stage.Interface, isEmpty bool) error {
 		return phase.onImageStage(ctx, img, stg, isEmpty)
 	})
@@ -303,11 +524,13 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		return nil
 	}
 
+	events.Emit(events.Event{Type: events.StageStarted, Image: img.GetName(), Stage: string(stg.Name())})
+
 	if err := stg.FetchDependencies(ctx, phase.Conveyor, phase.Conveyor.ContainerRuntime); err != nil {
 		return fmt.Errorf("unable to fetch dependencies for stage %s: %s", stg.LogDetailedName(), err)
 	}
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if _, isDockerfileStage := stg.(*stage.DockerfileStage); stg.Name() != "from" && !isDockerfileStage {
 		if phase.StagesIterator.PrevNonEmptyStage == nil {
 			panic(fmt.Sprintf("expected PrevNonEmptyStage to be set for image %q stage %s", img.GetName(), stg.Name()))
 		}
@@ -334,8 +557,15 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 
 		logboek.Context(ctx).LogOptionalLn()
 
+		phase.addImageStageRecord(img, newReportStageRecord(stg, ReportStageCacheHit, ReportStageStoragePrimary, 0, 0))
+
+		if phase.CalculateDigestsOnlyMode {
+			phase.DigestsReport.AddImageStageRecord(img, DigestsReportStageRecord{Name: string(stg.Name()), Digest: stg.GetDigest(), Built: true})
+			return nil
+		}
+
 		if phase.IntrospectOptions.ImageStageShouldBeIntrospected(img.GetName(), string(stg.Name())) {
-			if err := introspectStage(ctx, stg); err != nil {
+			if err := introspectStage(ctx, stg, phase.ImageBuildOptions.IntrospectShell); err != nil {
 				return err
 			}
 		}
@@ -343,17 +573,32 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		return nil
 	}
 
+	// The stage from the secondary stages storage is not fetched, so the stage has to be built or fetched by the regular build
+	if phase.CalculateDigestsOnlyMode {
+		logboek.Context(ctx).Default().LogFHighlight("Stage %s is not built, the next stages digests cannot be calculated\n", stg.LogDetailedName())
+		phase.DigestsReport.AddImageStageRecord(img, DigestsReportStageRecord{Name: string(stg.Name()), Digest: stg.GetDigest()})
+		phase.imageNotBuilt = true
+		return nil
+	}
+
 	foundSuitableSecondaryStage, err := phase.findAndFetchStageFromSecondaryStagesStorage(ctx, img, stg)
 	if err != nil {
 		return err
 	}
 
-	if !foundSuitableSecondaryStage {
+	if foundSuitableSecondaryStage {
+		phase.addImageStageRecord(img, newReportStageRecord(stg, ReportStageCacheHit, ReportStageStorageSecondary, 0, 0))
+	} else {
 		if phase.ShouldBeBuiltMode {
 			phase.printShouldBeBuiltError(ctx, img, stg)
 			return fmt.Errorf("stages required")
 		}
 
+		// fail before building the stage, which cannot be stored into the read-only repo anyway
+		if phase.Conveyor.StorageManager.IsReadOnly() {
+			return fmt.Errorf("stage %s with digest %s is not found in the %s and should be built: %s", stg.LogDetailedName(), stg.GetDigest(), phase.Conveyor.StorageManager.GetStagesStorage().String(), manager.ErrReadOnly)
+		}
+
 		// Will build a new stage
 		i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), uuid.New().String())
 		stg.SetImage(i)
@@ -367,6 +612,10 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		if err := phase.buildStage(ctx, img, stg); err != nil {
 			return err
 		}
+
+		record := newReportStageRecord(stg, ReportStageCacheMiss, "", phase.stageBuildDuration, phase.stagePushDuration)
+		record.BuildLogPath = phase.stageLogPath
+		phase.addImageStageRecord(img, record)
 	}
 
 	if stg.GetImage().GetStageDescription() == nil {
@@ -482,8 +731,17 @@ func (phase *BuildPhase) fetchBaseImageForStage(ctx context.Context, img *Image,
 		if err := img.FetchBaseImage(ctx, phase.Conveyor); err != nil {
 			return fmt.Errorf("unable to fetch base image %s for stage %s: %s", img.GetBaseImage().Name(), stg.LogDetailedName(), err)
 		}
-	} else if stg.Name() == "dockerfile" {
-		return nil
+	} else if dockerfileStage, ok := stg.(*stage.DockerfileStage); ok {
+		// the remote builder imports the cache stages from the repo itself
+		if remote_builder.IsEnabled() {
+			return nil
+		}
+
+		for _, cacheFromStage := range dockerfileStage.CacheFromStages() {
+			if err := phase.Conveyor.StorageManager.FetchStage(ctx, phase.Conveyor.ContainerRuntime, cacheFromStage); err != nil {
+				return fmt.Errorf("unable to fetch cache stage %s for stage %s: %s", cacheFromStage.LogDetailedName(), stg.LogDetailedName(), err)
+			}
+		}
 	} else {
 		return phase.Conveyor.StorageManager.FetchStage(ctx, phase.Conveyor.ContainerRuntime, phase.StagesIterator.PrevBuiltStage)
 	}
@@ -504,7 +762,19 @@ func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg sta
 		return false, nil, err
 	}
 
-	stageDigest, err := calculateDigest(ctx, string(stg.Name()), stageDependencies, phase.StagesIterator.PrevNonEmptyStage, phase.Conveyor)
+	// Dockerfile stage dependencies already include dependencies of the related Dockerfile stages,
+	// so the digest does not depend on the previous cache stages of the image
+	prevNonEmptyStage := phase.StagesIterator.PrevNonEmptyStage
+	if _, isDockerfileStage := stg.(*stage.DockerfileStage); isDockerfileStage {
+		prevNonEmptyStage = nil
+	}
+
+	stageCacheSalt, err := phase.Conveyor.StorageManager.GetStageCacheSalt(ctx, img.GetName(), string(stg.Name()))
+	if err != nil {
+		return false, nil, err
+	}
+
+	stageDigest, err := calculateDigest(ctx, string(stg.Name()), stageDependencies, stageCacheSalt, prevNonEmptyStage, phase.Conveyor.werfConfig.GetImageDigestedEnv(img.GetName()), phase.Conveyor)
 	if err != nil {
 		return false, nil, err
 	}
@@ -532,7 +802,7 @@ func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg sta
 		}
 	}
 
-	stageContentSig, err := calculateDigest(ctx, fmt.Sprintf("%s-content", stg.Name()), "", stg, phase.Conveyor)
+	stageContentSig, err := calculateDigest(ctx, fmt.Sprintf("%s-content", stg.Name()), "", "", stg, nil, phase.Conveyor)
 	if err != nil {
 		return false, phase.Conveyor.GetStageDigestMutex(stg.GetDigest()).Unlock, fmt.Errorf("unable to calculate stage %s content digest: %s", stg.Name(), err)
 	}
@@ -558,21 +828,61 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 		imagePkg.WerfStageContentDigestLabel: stg.GetContentDigest(),
 	}
 
-	switch stg.(type) {
+	switch stg := stg.(type) {
 	case *stage.DockerfileStage:
+		if baseImagesReferences := stg.BaseImagesReferences(); len(baseImagesReferences) != 0 {
+			serviceLabels[imagePkg.WerfBaseImagesLabel] = strings.Join(baseImagesReferences, ",")
+		}
+
+		// the cleanup keeps the cache stages of the Dockerfile stage along with the stage
+		var cacheFromStageIDs []string
+		for _, cacheFromStage := range stg.CacheFromStages() {
+			if desc := cacheFromStage.GetImage().GetStageDescription(); desc != nil && desc.StageID != nil {
+				cacheFromStageIDs = append(cacheFromStageIDs, desc.StageID.String())
+			}
+		}
+		if len(cacheFromStageIDs) != 0 {
+			serviceLabels[imagePkg.WerfCacheFromStageIDsLabel] = strings.Join(cacheFromStageIDs, ",")
+		}
+
 		var buildArgs []string
 
 		for key, value := range serviceLabels {
 			buildArgs = append(buildArgs, fmt.Sprintf("--label=%s=%s", key, value))
 		}
 
+		buildArgs = append(buildArgs, stg.SSHBuildArgs(phase.Conveyor.sshAuthSock)...)
+
 		stageImage.DockerfileImageBuilder().AppendBuildArgs(buildArgs...)
 
+		if remote_builder.IsEnabled() && phase.Conveyor.StorageManager.GetStagesStorage().Address() != storage.LocalStorageAddress {
+			stageImage.DockerfileImageBuilder().SetRemoteRepo(phase.Conveyor.StorageManager.GetStagesStorage().Address())
+
+			if remote_builder.IsPushedByTag() {
+				stageImage.DockerfileImageBuilder().SetRemoteStageImage(phase.Conveyor.StorageManager.GenerateStageUniqueID(stg.GetDigest(), nil))
+			}
+		}
+
 		phase.Conveyor.AppendOnTerminateFunc(func() error {
 			return stageImage.DockerfileImageBuilder().Cleanup(ctx)
 		})
 
 	default:
+		// the following stages and the images based on this image inherit the label
+		if stg.Name() == stage.From && img.baseImageType == ImageFromRegistryAsBaseImage {
+			if baseImageReference := img.GetBaseImage().GetStageDescription().Info.RepoDigest; baseImageReference != "" {
+				serviceLabels[imagePkg.WerfBaseImagesLabel] = baseImageReference
+			}
+		}
+
+		// the label is reset for the stages based on the images not built by werf, the parent chain is used to import the stages of the image (werf build --cache-from-images)
+		serviceLabels[imagePkg.WerfParentStageIDLabel] = ""
+		if prevBuiltImage := phase.StagesIterator.GetPrevBuiltImage(img, stg); prevBuiltImage != nil {
+			if desc := prevBuiltImage.GetStageDescription(); desc != nil && desc.StageID != nil {
+				serviceLabels[imagePkg.WerfParentStageIDLabel] = desc.StageID.String()
+			}
+		}
+
 		imageServiceCommitChangeOptions := stageImage.Container().ServiceCommitChangeOptions()
 		imageServiceCommitChangeOptions.AddLabel(serviceLabels)
 
@@ -586,6 +896,12 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 				imageRunOptions.AddVolume(fmt.Sprintf("%s:/.werf/tmp/ssh-auth-sock", phase.Conveyor.sshAuthSock))
 				imageRunOptions.AddEnv(map[string]string{"SSH_AUTH_SOCK": "/.werf/tmp/ssh-auth-sock"})
 			}
+
+			// the git cli of the assembly container checks the host keys the same way as werf
+			if ssh_agent.KnownHostsFile != "" {
+				imageRunOptions.AddVolume(fmt.Sprintf("%s:/.werf/tmp/ssh-known-hosts:ro", ssh_agent.KnownHostsFile))
+				imageRunOptions.AddEnv(map[string]string{"GIT_SSH_COMMAND": fmt.Sprintf("ssh %s", ssh_agent.GetSSHCommandOptions("/.werf/tmp/ssh-known-hosts"))})
+			}
 		}
 	}
 
@@ -599,6 +915,13 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 
 func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.Interface) error {
 	if !img.isDockerfileImage {
+		// the stapel container and the stapel builders are linux-only
+		if isWindowsDaemon, err := docker.IsWindowsDaemon(ctx); err != nil {
+			return fmt.Errorf("unable to get docker server info: %s", err)
+		} else if isWindowsDaemon {
+			return fmt.Errorf("stapel image %s cannot be built with the docker server running Windows containers: only Dockerfile images are supported", img.LogName())
+		}
+
 		_, err := stapel.GetOrCreateContainer(ctx)
 		if err != nil {
 			return fmt.Errorf("get or create stapel container failed: %s", err)
@@ -618,6 +941,11 @@ func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.I
 			options.Style(style.Highlight())
 		}).
 		DoError(func() (err error) {
+			// the stage build writes the data of unknown size into the project tmp dir (mounts, import server files, etc.)
+			if err := tmp_manager.CheckQuota(ctx); err != nil {
+				return fmt.Errorf("unable to build stage %s: %s", stg.LogDetailedName(), err)
+			}
+
 			if err := stg.PreRunHook(ctx, phase.Conveyor); err != nil {
 				return fmt.Errorf("%s preRunHook failed: %s", stg.LogDetailedName(), err)
 			}
@@ -628,7 +956,7 @@ func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.I
 	}
 
 	if phase.IntrospectOptions.ImageStageShouldBeIntrospected(img.GetName(), string(stg.Name())) {
-		if err := introspectStage(ctx, stg); err != nil {
+		if err := introspectStage(ctx, stg, phase.ImageBuildOptions.IntrospectShell); err != nil {
 			return err
 		}
 	}
@@ -636,6 +964,19 @@ func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.I
 	return nil
 }
 
+// withMountVolumesLocks holds the host locks of the project volumes mounted into the stage container to prevent concurrent writers,
+// the locks are acquired in the order of the sorted volume names to avoid deadlocks between the stages of different images.
+func (phase *BuildPhase) withMountVolumesLocks(ctx context.Context, volumes []string, f func() error) error {
+	if len(volumes) == 0 {
+		return f()
+	}
+
+	lockName := stage.MountVolumeLockName(phase.Conveyor.projectName(), volumes[0])
+	return werf.WithHostLock(ctx, lockName, lockgate.AcquireOptions{}, func() error {
+		return phase.withMountVolumesLocks(ctx, volumes[1:], f)
+	})
+}
+
 func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image, stg stage.Interface) error {
 	stageImage := stg.GetImage()
 
@@ -646,11 +987,35 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 		time.Sleep(time.Duration(seconds) * time.Second)
 	}
 
+	phase.stageBuildDuration, phase.stagePushDuration = 0, 0
+
+	buildOptions := phase.ImageBuildOptions
+	if buildOptions.KeepFailedStageContainer {
+		buildOptions.FailedStageContainerName = container_runtime.FailedStageContainerName(phase.Conveyor.projectName(), img.GetName(), string(stg.Name()))
+	}
+
+	buildStartTime := time.Now()
 	if err := logboek.Context(ctx).Streams().DoErrorWithTag(fmt.Sprintf("%s/%s", img.LogName(), stg.Name()), img.LogTagStyle(), func() error {
-		return stageImage.Build(ctx, phase.ImageBuildOptions)
+		return phase.buildWithStageLogCapture(ctx, img, stg, func(ctx context.Context) error {
+			return phase.withMountVolumesLocks(ctx, stg.GetMountVolumes(), func() error {
+				return stageImage.Build(ctx, buildOptions)
+			})
+		})
 	}); err != nil {
+		if buildOptions.FailedStageContainerName != "" {
+			if exists, existsErr := docker.ContainerExist(ctx, buildOptions.FailedStageContainerName); existsErr == nil && exists {
+				shellCommand := fmt.Sprintf("werf stage shell %s", stg.Name())
+				if img.GetName() != "" {
+					shellCommand += fmt.Sprintf(" --image %s", img.GetName())
+				}
+
+				logboek.Context(ctx).Warn().LogF("Failed stage container is kept as %s, run `%s` to enter it\n", buildOptions.FailedStageContainerName, shellCommand)
+			}
+		}
+
 		return fmt.Errorf("failed to build image for stage %s with digest %s: %s", stg.Name(), stg.GetDigest(), err)
 	}
+	phase.stageBuildDuration = time.Since(buildStartTime)
 
 	if v := os.Getenv("WERF_TEST_ATOMIC_STAGE_BUILD__SLEEP_SECONDS_BEFORE_STAGE_SAVE"); v != "" {
 		seconds := 0
@@ -691,11 +1056,18 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			return nil
 		} else { // use newly built image
 			newStageImageName, uniqueID := phase.Conveyor.StorageManager.GenerateStageUniqueID(stg.GetDigest(), stages)
+			// the remote builder has already pushed the image as the stage generated before the build
+			if builder := stageImage.DockerfileImageBuilder(); builder != nil {
+				if remoteStageImageName, remoteStageUniqueID := builder.GetRemoteStageImage(); remoteStageImageName != "" {
+					newStageImageName, uniqueID = remoteStageImageName, remoteStageUniqueID
+				}
+			}
 			stageImageObj := phase.Conveyor.GetStageImage(stageImage.Name())
 			phase.Conveyor.UnsetStageImage(stageImageObj.Name())
 			stageImageObj.SetName(newStageImageName)
 			phase.Conveyor.SetStageImage(stageImageObj)
 
+			pushStartTime := time.Now()
 			if err := logboek.Context(ctx).Default().LogProcess("Store stage into %s", phase.Conveyor.StorageManager.GetStagesStorage().String()).DoError(func() error {
 				if err := phase.Conveyor.StorageManager.GetStagesStorage().StoreImage(ctx, &container_runtime.DockerImage{Image: stageImage}); err != nil {
 					return fmt.Errorf("unable to store stage %s digest %s image %s into repo %s: %s", stg.LogDetailedName(), stg.GetDigest(), stageImage.Name(), phase.Conveyor.StorageManager.GetStagesStorage().String(), err)
@@ -709,6 +1081,19 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			}); err != nil {
 				return err
 			}
+			phase.stagePushDuration = time.Since(pushStartTime)
+
+			if stagesStorage := phase.Conveyor.StorageManager.GetStagesStorage(); stagesStorage.Address() != storage.LocalStorageAddress {
+				events.Emit(events.Event{
+					Type:            events.StagePushed,
+					Image:           img.GetName(),
+					Stage:           string(stg.Name()),
+					Digest:          stg.GetDigest(),
+					DockerImageName: stageImage.Name(),
+					Repo:            stagesStorage.String(),
+					Duration:        phase.stagePushDuration.Seconds(),
+				})
+			}
 
 			var stageIDs []image.StageID
 			for _, stageDesc := range stages {
@@ -731,14 +1116,14 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 	}
 }
 
-func introspectStage(ctx context.Context, s stage.Interface) error {
+func introspectStage(ctx context.Context, s stage.Interface, shell string) error {
 	return logboek.Context(ctx).Info().LogProcess("Introspecting stage %s", s.Name()).
 		Options(func(options types.LogProcessOptionsInterface) {
 			options.Style(style.Highlight())
 		}).
 		DoError(func() error {
 			if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-				return s.GetImage().Introspect(ctx)
+				return s.GetImage().Introspect(ctx, shell)
 			}); err != nil {
 				return fmt.Errorf("introspect error failed: %s", err)
 			}
@@ -747,8 +1132,21 @@ func introspectStage(ctx context.Context, s stage.Interface) error {
 		})
 }
 
-func calculateDigest(ctx context.Context, stageName, stageDependencies string, prevNonEmptyStage stage.Interface, conveyor *Conveyor) (string, error) {
+// calculateDigest calculates the stage digest, the digested env takes part only in the digest of the first stage of the image.
+func calculateDigest(ctx context.Context, stageName, stageDependencies, stageCacheSalt string, prevNonEmptyStage stage.Interface, digestedEnv map[string]string, conveyor *Conveyor) (string, error) {
 	checksumArgs := []string{image.BuildCacheVersion, stageName, stageDependencies}
+	checksumArgsNames := []string{
+		"BuildCacheVersion",
+		"stageName",
+		"stageDependencies",
+	}
+
+	// the salt of the stage cache invalidated by the werf stage invalidate command
+	if stageCacheSalt != "" {
+		checksumArgs = append(checksumArgs, stageCacheSalt)
+		checksumArgsNames = append(checksumArgsNames, "stageCacheSalt")
+	}
+
 	if prevNonEmptyStage != nil {
 		prevStageDependencies, err := prevNonEmptyStage.GetNextStageDependencies(ctx, conveyor)
 		if err != nil {
@@ -756,19 +1154,17 @@ func calculateDigest(ctx context.Context, stageName, stageDependencies string, p
 		}
 
 		checksumArgs = append(checksumArgs, prevNonEmptyStage.GetDigest(), prevStageDependencies)
+		checksumArgsNames = append(checksumArgsNames, "prevNonEmptyStage digest", "prevNonEmptyStage dependencies for next stage")
+	} else if len(digestedEnv) > 0 {
+		// the digested env takes part in the first stage digest and thereby in the digests of all following stages
+		checksumArgs = append(checksumArgs, getDigestedEnvChecksum(digestedEnv))
+		checksumArgsNames = append(checksumArgsNames, "digested env")
 	}
 
 	digest := util.Sha3_224Hash(checksumArgs...)
 
 	blockMsg := fmt.Sprintf("Stage %s digest %s", stageName, digest)
 	logboek.Context(ctx).Debug().LogBlock(blockMsg).Do(func() {
-		checksumArgsNames := []string{
-			"BuildCacheVersion",
-			"stageName",
-			"stageDependencies",
-			"prevNonEmptyStage digest",
-			"prevNonEmptyStage dependencies for next stage",
-		}
 		for ind, checksumArg := range checksumArgs {
 			logboek.Context(ctx).Debug().LogF("%s => %q\n", checksumArgsNames[ind], checksumArg)
 		}
@@ -777,6 +1173,21 @@ func calculateDigest(ctx context.Context, stageName, stageDependencies string, p
 	return digest, nil
 }
 
+func getDigestedEnvChecksum(digestedEnv map[string]string) string {
+	var names []string
+	for name := range digestedEnv {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("%s=%s", name, digestedEnv[name]))
+	}
+
+	return util.Sha256Hash(args...)
+}
+
 // TODO: move these prints to the after-images hook, print summary over all images
 func (phase *BuildPhase) printShouldBeBuiltError(ctx context.Context, img *Image, stg stage.Interface) {
 	logboek.Context(ctx).Default().LogProcess("Built stages cache check").
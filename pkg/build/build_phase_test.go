@@ -0,0 +1,74 @@
+package build
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+)
+
+func TestGetDigestedEnvChecksum(t *testing.T) {
+	checksum := getDigestedEnvChecksum(map[string]string{"B": "2", "A": "1"})
+
+	if expected := util.Sha256Hash("A=1", "B=2"); checksum != expected {
+		t.Fatalf("expected the checksum of the sorted values %q, got %q", expected, checksum)
+	}
+
+	for _, digestedEnv := range []map[string]string{
+		{"A": "1", "B": "3"},
+		{"A": "1"},
+		{"A": "1", "B": "2", "C": ""},
+		{"A": "1=B=2"},
+	} {
+		if getDigestedEnvChecksum(digestedEnv) == checksum {
+			t.Fatalf("expected the other checksum for %v", digestedEnv)
+		}
+	}
+}
+
+func TestCalculateDigestWithDigestedEnv(t *testing.T) {
+	ctx := context.Background()
+
+	digest, err := calculateDigest(ctx, "from", "", "", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestWithEnv, err := calculateDigest(ctx, "from", "", "", nil, map[string]string{"A": "1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestWithEnv == digest {
+		t.Fatalf("expected the digested env to change the digest")
+	}
+
+	digestWithOtherEnv, err := calculateDigest(ctx, "from", "", "", nil, map[string]string{"A": "2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestWithOtherEnv == digestWithEnv {
+		t.Fatalf("expected the digested env value to change the digest")
+	}
+}
+
+func TestImagesReportSetDigestedEnv(t *testing.T) {
+	report := &ImagesReport{}
+	report.SetDigestedEnv(map[string]string{"TOKEN": "secret"})
+
+	if value := report.DigestedEnv["TOKEN"]; value != util.Sha256Hash("secret") {
+		t.Fatalf("expected the hash of the value in the report, got %q", value)
+	}
+}
+
+func TestBaseImagesFromLabels(t *testing.T) {
+	if baseImages := baseImagesFromLabels(map[string]string{}); baseImages != nil {
+		t.Fatalf("expected no base images without the label, got %v", baseImages)
+	}
+
+	baseImages := baseImagesFromLabels(map[string]string{imagePkg.WerfBaseImagesLabel: "alpine@sha256:1,golang@sha256:2"})
+	if expected := []string{"alpine@sha256:1", "golang@sha256:2"}; !reflect.DeepEqual(baseImages, expected) {
+		t.Fatalf("expected base images %v, got %v", expected, baseImages)
+	}
+}
@@ -117,6 +117,8 @@ func (b *Ansible) stage(ctx context.Context, userStageName string, container Con
 	}
 	container.AddVolumeFrom(fmt.Sprintf("%s:ro", containerName))
 
+	container.AddServiceRunCommands(b.requirementsInstallCommands()...)
+
 	commandParts := []string{
 		path.Join(b.containerWorkDir(), "ansible-playbook"),
 		path.Join(b.containerWorkDir(), "playbook.yml"),
@@ -152,6 +154,16 @@ func (b *Ansible) stageChecksum(ctx context.Context, userStageName string) strin
 		logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage tasks checksum dependencies %v\n", userStageName, checksumArgs)
 	}
 
+	if len(checksumArgs) != 0 {
+		if requirementsChecksum := b.requirementsChecksum(); requirementsChecksum != "" {
+			if debugUserStageChecksum() {
+				logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage requirements checksum %v\n", userStageName, requirementsChecksum)
+			}
+
+			checksumArgs = append(checksumArgs, requirementsChecksum)
+		}
+	}
+
 	if stageVersionChecksum := b.stageVersionChecksum(userStageName); stageVersionChecksum != "" {
 		if debugUserStageChecksum() {
 			logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage version checksum %v\n", userStageName, stageVersionChecksum)
@@ -167,6 +179,55 @@ func (b *Ansible) stageChecksum(ctx context.Context, userStageName string) strin
 	}
 }
 
+// requirementsChecksum depends only on the pinned versions of the required collections and roles
+func (b *Ansible) requirementsChecksum() string {
+	if !b.hasRequirements() {
+		return ""
+	}
+
+	var requirementsChecksumArgs []string
+	for _, collection := range b.config.Requirements.Collections {
+		requirementsChecksumArgs = append(requirementsChecksumArgs, "collection", collection.Name, collection.Version, collection.Source)
+	}
+
+	for _, role := range b.config.Requirements.Roles {
+		requirementsChecksumArgs = append(requirementsChecksumArgs, "role", role.Name, role.Version, role.Source)
+	}
+
+	return util.Sha256Hash(requirementsChecksumArgs...)
+}
+
+func (b *Ansible) requirementsInstallCommands() []string {
+	if !b.hasRequirements() {
+		return nil
+	}
+
+	var commands []string
+	ansibleGalaxy := path.Join(b.containerWorkDir(), "ansible-galaxy")
+
+	if len(b.config.Requirements.Collections) != 0 {
+		commands = append(commands, strings.Join([]string{
+			ansibleGalaxy, "collection", "install",
+			"-r", path.Join(b.containerWorkDir(), "collections.yml"),
+			"-p", b.containerCollectionsDir(),
+		}, " "))
+	}
+
+	if len(b.config.Requirements.Roles) != 0 {
+		commands = append(commands, strings.Join([]string{
+			ansibleGalaxy, "role", "install",
+			"-r", path.Join(b.containerWorkDir(), "roles.yml"),
+			"-p", b.containerRolesDir(),
+		}, " "))
+	}
+
+	return commands
+}
+
+func (b *Ansible) hasRequirements() bool {
+	return b.config.Requirements != nil && !b.config.Requirements.IsEmpty()
+}
+
 func (b *Ansible) stageVersionChecksum(userStageName string) string {
 	var stageVersionChecksumArgs []string
 
@@ -49,33 +49,19 @@ func (b *Ansible) createStageWorkDirStructure(userStageName string) error {
 	writeFile(filepath.Join(stageWorkDir, "dump_config.json"), string(data))
 
 	// Ansible-playbook starter: setup python path without PYTHONPATH environment var
-	ioutil.WriteFile(
-		filepath.Join(stageWorkDir, "ansible-playbook"),
-		[]byte(fmt.Sprintf(
-			`#!%s
-
-import sys
-sys.path.append("%s")
-
-import os
-path = os.environ.get('PATH', '')
-prepend_path = os.environ.get('ANSIBLE_PREPEND_SYSTEM_PATH', '')
-append_path = os.environ.get('ANSIBLE_APPEND_SYSTEM_PATH', '')
-
-path_components = []
-if prepend_path != '':
-    path_components.append(prepend_path)
-if path != '':
-    path_components.append(path)
-if append_path != '':
-    path_components.append(append_path)
-
-os.environ['PATH'] = os.pathsep.join(path_components)
-
-execfile("%s")
-`, stapel.PythonBinPath(), path.Join(b.containerWorkDir(), "lib"), stapel.AnsiblePlaybookBinPath())),
-		os.FileMode(0777),
-	)
+	ioutil.WriteFile(filepath.Join(stageWorkDir, "ansible-playbook"), []byte(b.assetsStarter(stapel.AnsiblePlaybookBinPath())), os.FileMode(0777))
+
+	if b.hasRequirements() {
+		// Ansible-galaxy starter and requirements files to install collections and roles before the playbook
+		ioutil.WriteFile(filepath.Join(stageWorkDir, "ansible-galaxy"), []byte(b.assetsStarter(stapel.AnsibleGalaxyBinPath())), os.FileMode(0777))
+
+		collectionsData, rolesData, err := b.requirementsFiles()
+		if err != nil {
+			return err
+		}
+		writeFile(filepath.Join(stageWorkDir, "collections.yml"), string(collectionsData))
+		writeFile(filepath.Join(stageWorkDir, "roles.yml"), string(rolesData))
+	}
 
 	stageWorkDirLib := filepath.Join(stageWorkDir, "lib")
 	if err := mkdirP(stageWorkDirLib); err != nil {
@@ -128,6 +114,39 @@ execfile("%s")
 	return nil
 }
 
+// requirementsFiles returns ansible-galaxy requirements files for collections and roles
+func (b *Ansible) requirementsFiles() ([]byte, []byte, error) {
+	var collections, roles []map[string]string
+
+	for _, collection := range b.config.Requirements.Collections {
+		requirement := map[string]string{"name": collection.Name, "version": collection.Version}
+		if collection.Source != "" {
+			requirement["source"] = collection.Source
+		}
+		collections = append(collections, requirement)
+	}
+
+	for _, role := range b.config.Requirements.Roles {
+		requirement := map[string]string{"name": role.Name, "src": role.Name, "version": role.Version}
+		if role.Source != "" {
+			requirement["src"] = role.Source
+		}
+		roles = append(roles, requirement)
+	}
+
+	collectionsData, err := yaml.Marshal(map[string]interface{}{"collections": collections})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rolesData, err := yaml.Marshal(map[string]interface{}{"roles": roles})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return collectionsData, rolesData, nil
+}
+
 func (b *Ansible) stagePlaybook(userStageName string) ([]map[string]interface{}, error) {
 	playbook := map[string]interface{}{
 		"hosts":        "all",
@@ -210,6 +229,14 @@ func (b *Ansible) containerTmpDir() string {
 	return path.Join(b.extra.ContainerWerfPath, "ansible-tmpdir")
 }
 
+func (b *Ansible) containerCollectionsDir() string {
+	return path.Join(b.containerTmpDir(), "collections")
+}
+
+func (b *Ansible) containerRolesDir() string {
+	return path.Join(b.containerTmpDir(), "roles")
+}
+
 func mkdirP(path string) error {
 	return os.MkdirAll(path, os.FileMode(0775))
 }
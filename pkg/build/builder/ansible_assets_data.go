@@ -14,6 +14,8 @@ func (b *Ansible) assetsAnsibleCfg() string {
 	sudoBinPath := stapel.SudoBinPath()
 	localTmpDirPath := path.Join(b.containerTmpDir(), "local")
 	remoteTmpDirPath := path.Join(b.containerTmpDir(), "remote")
+	collectionsPath := b.containerCollectionsDir()
+	rolesPath := b.containerRolesDir()
 
 	format := `[defaults]
 inventory = %[1]s
@@ -29,6 +31,9 @@ force_color = 1
 module_compression = 'ZIP_STORED'
 local_tmp = %[3]s
 remote_tmp = %[4]s
+; collections and roles from the ansible requirements
+collections_paths = %[6]s
+roles_path = %[7]s
 ; keep ansiballz for debug
 ;keep_remote_files = 1
 [privilege_escalation]
@@ -37,7 +42,33 @@ become_method = sudo
 become_exe = %[5]s
 become_flags = -E -H`
 
-	return fmt.Sprintf(format, hostsPath, callbackPluginsPath, localTmpDirPath, remoteTmpDirPath, sudoBinPath)
+	return fmt.Sprintf(format, hostsPath, callbackPluginsPath, localTmpDirPath, remoteTmpDirPath, sudoBinPath, collectionsPath, rolesPath)
+}
+
+func (b *Ansible) assetsStarter(binPath string) string {
+	return fmt.Sprintf(
+		`#!%s
+
+import sys
+sys.path.append("%s")
+
+import os
+path = os.environ.get('PATH', '')
+prepend_path = os.environ.get('ANSIBLE_PREPEND_SYSTEM_PATH', '')
+append_path = os.environ.get('ANSIBLE_APPEND_SYSTEM_PATH', '')
+
+path_components = []
+if prepend_path != '':
+    path_components.append(prepend_path)
+if path != '':
+    path_components.append(path)
+if append_path != '':
+    path_components.append(append_path)
+
+os.environ['PATH'] = os.pathsep.join(path_components)
+
+execfile("%s")
+`, stapel.PythonBinPath(), path.Join(b.containerWorkDir(), "lib"), binPath)
 }
 
 func (b *Ansible) assetsHosts() string {
@@ -0,0 +1,177 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/oleiade/reflections.v1"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/util"
+)
+
+type Scripts struct {
+	config      *config.Scripts
+	scriptsData map[string][]byte
+	extra       *Extra
+}
+
+// NewScriptsBuilder creates the builder, which executes the script files in the build container.
+// The scriptsData is the content of the script files by the paths from the config.
+func NewScriptsBuilder(config *config.Scripts, scriptsData map[string][]byte, extra *Extra) *Scripts {
+	return &Scripts{config: config, scriptsData: scriptsData, extra: extra}
+}
+
+func (b *Scripts) IsBeforeInstallEmpty(ctx context.Context) bool {
+	return b.isEmptyStage(ctx, "BeforeInstall")
+}
+func (b *Scripts) IsInstallEmpty(ctx context.Context) bool { return b.isEmptyStage(ctx, "Install") }
+func (b *Scripts) IsBeforeSetupEmpty(ctx context.Context) bool {
+	return b.isEmptyStage(ctx, "BeforeSetup")
+}
+func (b *Scripts) IsSetupEmpty(ctx context.Context) bool { return b.isEmptyStage(ctx, "Setup") }
+
+func (b *Scripts) BeforeInstall(_ context.Context, container Container) error {
+	return b.stage("BeforeInstall", container)
+}
+func (b *Scripts) Install(_ context.Context, container Container) error {
+	return b.stage("Install", container)
+}
+func (b *Scripts) BeforeSetup(_ context.Context, container Container) error {
+	return b.stage("BeforeSetup", container)
+}
+func (b *Scripts) Setup(_ context.Context, container Container) error {
+	return b.stage("Setup", container)
+}
+
+func (b *Scripts) BeforeInstallChecksum(ctx context.Context) string {
+	return b.stageChecksum(ctx, "BeforeInstall")
+}
+func (b *Scripts) InstallChecksum(ctx context.Context) string { return b.stageChecksum(ctx, "Install") }
+func (b *Scripts) BeforeSetupChecksum(ctx context.Context) string {
+	return b.stageChecksum(ctx, "BeforeSetup")
+}
+func (b *Scripts) SetupChecksum(ctx context.Context) string { return b.stageChecksum(ctx, "Setup") }
+
+func (b *Scripts) isEmptyStage(ctx context.Context, userStageName string) bool {
+	return b.stageChecksum(ctx, userStageName) == ""
+}
+
+func (b *Scripts) stage(userStageName string, container Container) error {
+	stageHostTmpDir, err := b.stageHostTmpDir(userStageName)
+	if err != nil {
+		return err
+	}
+
+	container.AddVolume(
+		fmt.Sprintf("%s:%s:rw", stageHostTmpDir, b.containerTmpDir()),
+	)
+
+	for ind, scriptPath := range b.stageScripts(userStageName) {
+		// the index keeps the order and separates the scripts with the same name from different directories
+		scriptFileName := fmt.Sprintf("%d-%s", ind, path.Base(filepath.ToSlash(scriptPath)))
+
+		if err := ioutil.WriteFile(filepath.Join(stageHostTmpDir, scriptFileName), b.scriptsData[scriptPath], os.FileMode(0755)); err != nil {
+			return fmt.Errorf("unable to write script %q: %s", scriptPath, err)
+		}
+
+		container.AddServiceRunCommands(path.Join(b.containerTmpDir(), scriptFileName))
+	}
+
+	return nil
+}
+
+func (b *Scripts) stageChecksum(ctx context.Context, userStageName string) string {
+	var checksumArgs []string
+
+	for _, scriptPath := range b.stageScripts(userStageName) {
+		checksumArgs = append(checksumArgs, filepath.ToSlash(scriptPath), util.Sha256Hash(string(b.scriptsData[scriptPath])))
+	}
+
+	if debugUserStageChecksum() {
+		logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage scripts checksum dependencies %v\n", userStageName, checksumArgs)
+	}
+
+	if stageVersionChecksum := b.stageVersionChecksum(userStageName); stageVersionChecksum != "" {
+		if debugUserStageChecksum() {
+			logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage version checksum %v\n", userStageName, stageVersionChecksum)
+		}
+		checksumArgs = append(checksumArgs, stageVersionChecksum)
+	}
+
+	if len(checksumArgs) != 0 {
+		return util.Sha256Hash(checksumArgs...)
+	} else {
+		return ""
+	}
+}
+
+func (b *Scripts) stageVersionChecksum(userStageName string) string {
+	var stageVersionChecksumArgs []string
+
+	cacheVersionFieldName := "CacheVersion"
+	stageCacheVersionFieldName := strings.Join([]string{userStageName, cacheVersionFieldName}, "")
+
+	stageChecksum, ok := b.configFieldValue(stageCacheVersionFieldName).(string)
+	if !ok {
+		panic(fmt.Sprintf("runtime error: %#v", stageChecksum))
+	}
+
+	if stageChecksum != "" {
+		stageVersionChecksumArgs = append(stageVersionChecksumArgs, stageChecksum)
+	}
+
+	checksum, ok := b.configFieldValue(cacheVersionFieldName).(string)
+	if !ok {
+		panic(fmt.Sprintf("runtime error: %#v", checksum))
+	}
+
+	if checksum != "" {
+		stageVersionChecksumArgs = append(stageVersionChecksumArgs, checksum)
+	}
+
+	if len(stageVersionChecksumArgs) != 0 {
+		return util.Sha256Hash(stageVersionChecksumArgs...)
+	} else {
+		return ""
+	}
+}
+
+func (b *Scripts) stageScripts(userStageName string) []string {
+	scripts, ok := b.configFieldValue(userStageName).([]string)
+	if !ok {
+		panic("runtime error")
+	}
+
+	return scripts
+}
+
+func (b *Scripts) configFieldValue(fieldName string) interface{} {
+	value, err := reflections.GetField(b.config, fieldName)
+	if err != nil {
+		panic(fmt.Sprintf("runtime error: %s", err))
+	}
+
+	return value
+}
+
+func (b *Scripts) stageHostTmpDir(userStageName string) (string, error) {
+	p := filepath.Join(b.extra.TmpPath, fmt.Sprintf("scripts-%s", userStageName))
+
+	if err := mkdirP(p); err != nil {
+		return "", err
+	}
+
+	return p, nil
+}
+
+func (b *Scripts) containerTmpDir() string {
+	return path.Join(b.extra.ContainerWerfPath, "scripts")
+}
@@ -0,0 +1,113 @@
+package builder
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+type testContainer struct {
+	Container
+
+	serviceRunCommands []string
+	volumes            []string
+}
+
+func (c *testContainer) AddServiceRunCommands(commands ...string) {
+	c.serviceRunCommands = append(c.serviceRunCommands, commands...)
+}
+
+func (c *testContainer) AddVolume(volumes ...string) {
+	c.volumes = append(c.volumes, volumes...)
+}
+
+func TestScripts_Stage(t *testing.T) {
+	tmpDir := t.TempDir()
+	b := NewScriptsBuilder(
+		&config.Scripts{Install: []string{"scripts/install.sh", "other/install.sh"}},
+		map[string][]byte{
+			"scripts/install.sh": []byte("#!/bin/sh\necho scripts\n"),
+			"other/install.sh":   []byte("#!/usr/bin/env python3\nprint('other')\n"),
+		},
+		&Extra{ContainerWerfPath: "/.werf", TmpPath: tmpDir},
+	)
+
+	container := &testContainer{}
+	if err := b.Install(context.Background(), container); err != nil {
+		t.Fatal(err)
+	}
+
+	stageHostTmpDir := filepath.Join(tmpDir, "scripts-Install")
+	if expected := []string{stageHostTmpDir + ":/.werf/scripts:rw"}; !reflect.DeepEqual(container.volumes, expected) {
+		t.Fatalf("expected volumes %v, got %v", expected, container.volumes)
+	}
+
+	if expected := []string{"/.werf/scripts/0-install.sh", "/.werf/scripts/1-install.sh"}; !reflect.DeepEqual(container.serviceRunCommands, expected) {
+		t.Fatalf("expected commands %v, got %v", expected, container.serviceRunCommands)
+	}
+
+	for fileName, expectedData := range map[string]string{
+		"0-install.sh": "#!/bin/sh\necho scripts\n",
+		"1-install.sh": "#!/usr/bin/env python3\nprint('other')\n",
+	} {
+		path := filepath.Join(stageHostTmpDir, fileName)
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expectedData {
+			t.Fatalf("expected script %s content %q, got %q", fileName, expectedData, data)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0100 == 0 {
+			t.Fatalf("expected script %s to be executable, got mode %s", fileName, info.Mode())
+		}
+	}
+}
+
+func TestScripts_StageChecksum(t *testing.T) {
+	ctx := context.Background()
+	scriptsData := map[string][]byte{"install.sh": []byte("echo 1"), "setup.sh": []byte("echo 2")}
+
+	b := NewScriptsBuilder(&config.Scripts{Install: []string{"install.sh"}}, scriptsData, &Extra{})
+	if !b.IsBeforeInstallEmpty(ctx) || !b.IsBeforeSetupEmpty(ctx) || !b.IsSetupEmpty(ctx) {
+		t.Fatal("expected the stages without scripts to be empty")
+	}
+	if b.IsInstallEmpty(ctx) {
+		t.Fatal("expected the install stage not to be empty")
+	}
+
+	checksum := b.InstallChecksum(ctx)
+
+	changedData := map[string][]byte{"install.sh": []byte("echo changed"), "setup.sh": []byte("echo 2")}
+	if NewScriptsBuilder(&config.Scripts{Install: []string{"install.sh"}}, changedData, &Extra{}).InstallChecksum(ctx) == checksum {
+		t.Fatal("expected the checksum to depend on the script content")
+	}
+
+	if NewScriptsBuilder(&config.Scripts{Install: []string{"setup.sh", "install.sh"}}, scriptsData, &Extra{}).InstallChecksum(ctx) == checksum {
+		t.Fatal("expected the checksum to depend on the scripts list")
+	}
+
+	if NewScriptsBuilder(&config.Scripts{Install: []string{"install.sh"}, InstallCacheVersion: "1"}, scriptsData, &Extra{}).InstallChecksum(ctx) == checksum {
+		t.Fatal("expected the checksum to depend on the stage cache version")
+	}
+
+	if NewScriptsBuilder(&config.Scripts{Install: []string{"install.sh"}}, scriptsData, &Extra{}).InstallChecksum(ctx) != checksum {
+		t.Fatal("expected the same checksum for the same scripts")
+	}
+
+	b = NewScriptsBuilder(&config.Scripts{CacheVersion: "1"}, scriptsData, &Extra{})
+	if b.IsSetupEmpty(ctx) {
+		t.Fatal("expected the stage with the cache version not to be empty")
+	}
+}
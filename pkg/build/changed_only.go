@@ -0,0 +1,234 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+)
+
+// getImageInputsDigest calculates the digest of everything the image stages digests are based on:
+// the rendered werf.yaml document of the image, the git trees of the image git mappings, scripts and Dockerfile context,
+// and the inputs digests of the dependency images.
+// The empty digest means that the inputs cannot be determined without digests calculation and the image should be processed as usual.
+func getImageInputsDigest(ctx context.Context, c *Conveyor, imageConfig config.ImageInterface) (string, error) {
+	if c.giterminismManager.Dev() {
+		return "", nil
+	}
+
+	if reason := changedOnlyNotApplicableReason(c, imageConfig); reason != "" {
+		logboek.Context(ctx).Info().LogF("Image %s inputs digest is not used: %s\n", imageConfig.GetName(), reason)
+		return "", nil
+	}
+
+	var args []string
+	args = append(args, imagePkg.BuildCacheVersion, c.projectName(), imageConfig.GetName(), string(config.ImageRawDocument(imageConfig)))
+
+	// the stages invalidated by the werf stage invalidate command get new digests
+	for _, stageName := range stage.AllStages {
+		stageCacheSalt, err := c.StorageManager.GetStageCacheSalt(ctx, imageConfig.GetName(), string(stageName))
+		if err != nil {
+			return "", err
+		}
+
+		if stageCacheSalt != "" {
+			args = append(args, string(stageName), stageCacheSalt)
+		}
+	}
+
+	var gitPaths []string
+	switch imageConfig := imageConfig.(type) {
+	case config.StapelImageInterface:
+		if imageConfig.ImageBaseConfig().Git != nil {
+			for _, gitLocal := range imageConfig.ImageBaseConfig().Git.Local {
+				gitPaths = append(gitPaths, gitLocal.GitMappingAdd())
+			}
+		}
+
+		if imageConfig.ImageBaseConfig().Scripts != nil {
+			for _, scriptPath := range imageConfig.ImageBaseConfig().Scripts.Paths() {
+				gitPaths = append(gitPaths, filepath.Join(c.giterminismManager.RelativeToGitProjectDir(), scriptPath))
+			}
+		}
+	case *config.ImageFromDockerfile:
+		gitPaths = append(gitPaths,
+			filepath.Join(c.giterminismManager.RelativeToGitProjectDir(), imageConfig.Context),
+			filepath.Join(c.giterminismManager.RelativeToGitProjectDir(), imageConfig.Context, imageConfig.Dockerfile),
+		)
+	}
+
+	for _, gitPath := range gitPaths {
+		treeHash, err := getCommitTreeEntryHash(ctx, c, filepath.ToSlash(gitPath))
+		if err != nil {
+			return "", err
+		}
+
+		args = append(args, gitPath, treeHash)
+	}
+
+	for _, dependencyConfig := range c.werfConfig.ImageDependencies(imageConfig) {
+		dependencyImage := c.GetImage(dependencyConfig.GetName())
+		if dependencyImage == nil || dependencyImage.inputsDigest == "" {
+			logboek.Context(ctx).Info().LogF("Image %s inputs digest is not used: dependency %s inputs digest is not available\n", imageConfig.GetName(), dependencyConfig.GetName())
+			return "", nil
+		}
+
+		args = append(args, dependencyImage.GetName(), dependencyImage.inputsDigest)
+	}
+
+	inputsDigest := util.Sha256Hash(args...)
+	logboek.Context(ctx).Info().LogF("Image %s inputs digest: %s\n", imageConfig.GetName(), inputsDigest)
+
+	return inputsDigest, nil
+}
+
+func changedOnlyNotApplicableReason(c *Conveyor, imageConfig config.ImageInterface) string {
+	switch imageConfig := imageConfig.(type) {
+	case config.StapelImageInterface:
+		if imageConfig.ImageBaseConfig().FromLatest {
+			return "fromLatest directive is used"
+		}
+
+		if imageConfig.ImageBaseConfig().Git != nil {
+			for _, gitRemote := range imageConfig.ImageBaseConfig().Git.Remote {
+				if gitRemote.Commit == "" {
+					return fmt.Sprintf("remote git %s is not pinned to the commit", gitRemote.Url)
+				}
+			}
+		}
+
+		if imageConfig.ImageBaseConfig().Scripts != nil && c.giterminismManager.LooseGiterminism() {
+			return "scripts are read from the working directory in loose giterminism mode"
+		}
+	case *config.ImageFromDockerfile:
+		if len(imageConfig.ContextAddFiles) != 0 {
+			return "contextAddFiles directive is used"
+		}
+
+		if c.giterminismManager.LooseGiterminism() {
+			return "Dockerfile context is read from the working directory in loose giterminism mode"
+		}
+	}
+
+	// the stages other than the last one are not available for the unchanged image
+	for _, otherImageConfig := range c.werfConfig.GetAllImages() {
+		stapelImageConfig, ok := otherImageConfig.(config.StapelImageInterface)
+		if !ok {
+			continue
+		}
+
+		for _, imp := range stapelImageConfig.ImageBaseConfig().Import {
+			if (imp.ImageName == imageConfig.GetName() || imp.ArtifactName == imageConfig.GetName()) && imp.Stage != "" {
+				return fmt.Sprintf("image %s imports files from the stage %s", otherImageConfig.GetName(), imp.Stage)
+			}
+		}
+	}
+
+	return ""
+}
+
+func getCommitTreeEntryHash(ctx context.Context, c *Conveyor, gitPath string) (string, error) {
+	localGitRepo := c.giterminismManager.LocalGitRepo()
+	commit := c.giterminismManager.HeadCommit()
+
+	if gitPath == "" || gitPath == "." {
+		repository, err := localGitRepo.PlainOpen()
+		if err != nil {
+			return "", fmt.Errorf("unable to open git repo %s: %s", localGitRepo.GetName(), err)
+		}
+
+		commitObj, err := repository.CommitObject(plumbing.NewHash(commit))
+		if err != nil {
+			return "", fmt.Errorf("unable to get commit %s: %s", commit, err)
+		}
+
+		return commitObj.TreeHash.String(), nil
+	}
+
+	entry, err := localGitRepo.GetCommitTreeEntry(ctx, commit, gitPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to get commit %s tree entry %q: %s", commit, gitPath, err)
+	}
+
+	if entry == nil {
+		return "", nil
+	}
+
+	return entry.Hash.String(), nil
+}
+
+// useUnchangedImageLastStage sets the last stage of the image built from the same inputs in the previous build.
+// The records are kept in the stages storage, so the builds on the other hosts using the same repo are taken into account.
+// The stage should still exist in the stages storage.
+func (phase *BuildPhase) useUnchangedImageLastStage(ctx context.Context, img *Image) (bool, error) {
+	stagesStorage := phase.Conveyor.StorageManager.GetStagesStorage()
+
+	record, err := stagesStorage.GetChangedOnlyRecord(ctx, phase.Conveyor.projectName(), img.inputsDigest)
+	if err != nil || record == nil {
+		return false, err
+	}
+
+	stg := img.GetStage(stage.StageName(record.StageName))
+	if stg == nil {
+		return false, nil
+	}
+
+	stages, err := phase.Conveyor.StorageManager.GetStagesByDigestFromStagesStorage(ctx, stg.LogDetailedName(), record.StageID.Digest, stagesStorage)
+	if err != nil {
+		return false, err
+	}
+
+	var stageDesc *imagePkg.StageDescription
+	for _, desc := range stages {
+		if desc.StageID.UniqueID == record.StageID.UniqueID {
+			stageDesc = desc
+			break
+		}
+	}
+
+	if stageDesc == nil {
+		logboek.Context(ctx).Info().LogF("Stage %s of the unchanged image %s not found in the %s\n", record.StageID.String(), img.GetName(), stagesStorage.String())
+		return false, nil
+	}
+
+	stageImage := phase.Conveyor.GetOrCreateStageImage(nil, stageDesc.Info.Name)
+	stageImage.SetStageDescription(stageDesc)
+	stg.SetImage(stageImage)
+	stg.SetDigest(record.StageID.Digest)
+	stg.SetContentDigest(record.ContentDigest)
+
+	phase.StagesIterator.PrevNonEmptyStage = stg
+
+	logboek.Context(ctx).Default().LogFHighlight("Use cache image for %s: %s (image config and git inputs are not changed)\n", img.LogDetailedName(), stageDesc.Info.Name)
+
+	return true, nil
+}
+
+func (phase *BuildPhase) saveUnchangedImageLastStage(ctx context.Context, img *Image) error {
+	// the record is an optimization only, the read-only build does not save it
+	if phase.Conveyor.StorageManager.IsReadOnly() {
+		return nil
+	}
+
+	return phase.Conveyor.StorageManager.GetStagesStorage().PutChangedOnlyRecord(ctx, phase.Conveyor.projectName(), newChangedOnlyRecord(img))
+}
+
+func newChangedOnlyRecord(img *Image) *storage.ChangedOnlyRecord {
+	lastStage := img.GetLastNonEmptyStage()
+
+	return &storage.ChangedOnlyRecord{
+		InputsDigest:  img.inputsDigest,
+		StageName:     string(lastStage.Name()),
+		StageID:       *lastStage.GetImage().GetStageDescription().StageID,
+		ContentDigest: lastStage.GetContentDigest(),
+	}
+}
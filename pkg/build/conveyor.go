@@ -9,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,6 +28,8 @@ import (
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/context_manager"
+	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_manager"
 	imagePkg "github.com/werf/werf/pkg/image"
@@ -36,6 +39,7 @@ import (
 	"github.com/werf/werf/pkg/storage/manager"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/util/parallel"
+	"github.com/werf/werf/pkg/werf"
 )
 
 type Conveyor struct {
@@ -67,6 +71,7 @@ type Conveyor struct {
 
 	onTerminateFuncs []func() error
 	importServers    map[string]import_server.ImportServer
+	contextCache     *context_manager.Cache
 
 	ConveyorOptions
 
@@ -79,6 +84,13 @@ type ConveyorOptions struct {
 	Parallel                        bool
 	ParallelTasksLimit              int64
 	LocalGitRepoVirtualMergeOptions stage.VirtualMergeOptions
+	DockerfileSecrets               []*stage.DockerfileSecret
+
+	// ChangedOnly enables skipping of the images, which config and git inputs are the same as in the previous build
+	ChangedOnly bool
+
+	// Environment is used to render the werf.yaml metadata labels of the exported images
+	Environment string
 }
 
 func NewConveyor(werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface, imageNamesToProcess []string, projectDir, baseTmpDir, sshAuthSock string, containerRuntime container_runtime.ContainerRuntime, storageManager manager.StorageManagerInterface, storageLockManager storage.LockManager, opts ConveyorOptions) *Conveyor {
@@ -102,6 +114,7 @@ func NewConveyor(werfConfig *config.WerfConfig, giterminismManager giterminism_m
 		remoteGitRepos:         make(map[string]*git_repo.Remote),
 		tmpDir:                 filepath.Join(baseTmpDir, util.GenerateConsistentRandomString(10)),
 		importServers:          make(map[string]import_server.ImportServer),
+		contextCache:           context_manager.NewCache(),
 
 		ContainerRuntime:   containerRuntime,
 		StorageLockManager: storageLockManager,
@@ -114,6 +127,17 @@ func NewConveyor(werfConfig *config.WerfConfig, giterminismManager giterminism_m
 	}
 }
 
+// metadataLabels returns the werf.yaml metadata labels, which are set on the exported images.
+func (c *Conveyor) metadataLabels() (map[string]string, error) {
+	return c.werfConfig.Meta.Metadata.RenderLabels(config.MetadataTemplateData{
+		Project:     c.projectName(),
+		Env:         c.Environment,
+		Commit:      c.giterminismManager.HeadCommit(),
+		WerfVersion: werf.Version,
+		LookupEnv:   config.NewMetadataTemplateLookupEnv(c.giterminismManager),
+	})
+}
+
 func (c *Conveyor) getServiceRWMutex(service string) *sync.RWMutex {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -214,40 +238,63 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 		return nil, fmt.Errorf("unable to fetch stage %s: %s", stg.GetImage().Name(), err)
 	}
 
+	var tmpDir string
+	if stageName == "" {
+		tmpDir = filepath.Join(c.tmpDir, "import-server", imageName)
+	} else {
+		tmpDir = filepath.Join(c.tmpDir, "import-server", fmt.Sprintf("%s-%s", imageName, stageName))
+	}
+
+	var dockerImageName string
+	if stageName == "" {
+		dockerImageName = c.GetImageNameForLastImageStage(imageName)
+	} else {
+		dockerImageName = c.GetImageNameForImageStage(imageName, stageName)
+	}
+
 	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Firing up import rsync server for image %s", imageName)).
 		DoError(func() error {
-			var tmpDir string
-			if stageName == "" {
-				tmpDir = filepath.Join(c.tmpDir, "import-server", imageName)
-			} else {
-				tmpDir = filepath.Join(c.tmpDir, "import-server", fmt.Sprintf("%s-%s", imageName, stageName))
-			}
+			var err error
+			srv, err = c.runImportServer(ctx, dockerImageName, tmpDir)
+			return err
+		}); err != nil {
+		return nil, err
+	}
 
-			if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
-				return fmt.Errorf("unable to create dir %s: %s", tmpDir, err)
-			}
+	c.importServers[importServerName] = srv
 
-			var dockerImageName string
-			if stageName == "" {
-				dockerImageName = c.GetImageNameForLastImageStage(imageName)
-			} else {
-				dockerImageName = c.GetImageNameForImageStage(imageName, stageName)
-			}
+	return srv, nil
+}
+
+// GetImportServerForExternalImage returns the import server of the external image, which is not built by werf.
+// The image is pulled if it does not exist locally.
+func (c *Conveyor) GetImportServerForExternalImage(ctx context.Context, reference string) (import_server.ImportServer, error) {
+	c.getServiceRWMutex("ImportServer").Lock()
+	defer c.getServiceRWMutex("ImportServer").Unlock()
+
+	importServerName := "external/" + reference
+	if srv, hasKey := c.importServers[importServerName]; hasKey {
+		return srv, nil
+	}
+
+	if exist, err := docker.ImageExist(ctx, reference); err != nil {
+		return nil, fmt.Errorf("unable to check existence of image %s: %s", reference, err)
+	} else if !exist {
+		if err := logboek.Context(ctx).Default().LogProcess("Pulling image %s", reference).DoError(func() error {
+			return docker.CliPullWithRetries(ctx, reference)
+		}); err != nil {
+			return nil, fmt.Errorf("unable to pull image %s: %s", reference, err)
+		}
+	}
 
+	var srv *import_server.RsyncServer
+	tmpDir := filepath.Join(c.tmpDir, "import-server", "external", util.Sha256Hash(reference))
+
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Firing up import rsync server for external image %s", reference)).
+		DoError(func() error {
 			var err error
-			srv, err = import_server.RunRsyncServer(ctx, dockerImageName, tmpDir)
-			if srv != nil {
-				c.AppendOnTerminateFunc(func() error {
-					if err := srv.Shutdown(ctx); err != nil {
-						return fmt.Errorf("unable to shutdown import server %s: %s", srv.DockerContainerName, err)
-					}
-					return nil
-				})
-			}
-			if err != nil {
-				return fmt.Errorf("unable to run rsync import server: %s", err)
-			}
-			return nil
+			srv, err = c.runImportServer(ctx, reference, tmpDir)
+			return err
 		}); err != nil {
 		return nil, err
 	}
@@ -257,6 +304,27 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 	return srv, nil
 }
 
+func (c *Conveyor) runImportServer(ctx context.Context, dockerImageName, tmpDir string) (*import_server.RsyncServer, error) {
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create dir %s: %s", tmpDir, err)
+	}
+
+	srv, err := import_server.RunRsyncServer(ctx, dockerImageName, tmpDir)
+	if srv != nil {
+		c.AppendOnTerminateFunc(func() error {
+			if err := srv.Shutdown(ctx); err != nil {
+				return fmt.Errorf("unable to shutdown import server %s: %s", srv.DockerContainerName, err)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to run rsync import server: %s", err)
+	}
+
+	return srv, nil
+}
+
 func (c *Conveyor) AppendOnTerminateFunc(f func() error) {
 	c.onTerminateFuncs = append(c.onTerminateFuncs, f)
 }
@@ -320,6 +388,46 @@ func (c *Conveyor) ShouldBeBuilt(ctx context.Context) error {
 	return nil
 }
 
+// DetermineImagesInfoGetters calculates the digests of the images stages and selects the suitable stages already stored in the repo
+// without building anything, so that the images names and tags could be used without the build (e.g. by the GitOps tools).
+// The error is returned if some images stages are not built yet.
+func (c *Conveyor) DetermineImagesInfoGetters(ctx context.Context) ([]*imagePkg.InfoGetter, error) {
+	if err := c.ShouldBeBuilt(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.GetImageInfoGetters(), nil
+}
+
+// CalculateDigests calculates the images stages digests and selects the stages already stored in the repo
+// without building, fetching and publishing anything, e.g. to check whether the build would be fully cached.
+func (c *Conveyor) CalculateDigests(ctx context.Context) (*DigestsReport, error) {
+	if err := c.determineStages(ctx); err != nil {
+		return nil, err
+	}
+
+	phase := NewBuildPhase(c, BuildPhaseOptions{CalculateDigestsOnlyMode: true})
+	if err := c.runPhases(ctx, []Phase{phase}, false); err != nil {
+		return nil, err
+	}
+
+	return phase.DigestsReport, nil
+}
+
+// GetImageStagesNames determines the stages of the image and returns the names of the stages taking part in the image build.
+func (c *Conveyor) GetImageStagesNames(ctx context.Context, imageName string) ([]string, error) {
+	if err := c.determineStages(ctx); err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, stg := range c.GetImage(imageName).GetStages() {
+		res = append(res, string(stg.Name()))
+	}
+
+	return res, nil
+}
+
 func (c *Conveyor) FetchLastImageStage(ctx context.Context, imageName string) error {
 	lastImageStage := c.GetImage(imageName).GetLastNonEmptyStage()
 	return c.StorageManager.FetchStage(ctx, c.ContainerRuntime, lastImageStage)
@@ -446,6 +554,12 @@ func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 						return err
 					}
 
+					if c.ChangedOnly {
+						if img.inputsDigest, err = getImageInputsDigest(ctx, c, imageInterfaceConfig); err != nil {
+							return fmt.Errorf("unable to calculate image %s inputs digest: %s", img.GetName(), err)
+						}
+					}
+
 					c.images = append(c.images, img)
 					imageSet = append(imageSet, img)
 
@@ -474,6 +588,10 @@ func (c *Conveyor) runPhases(ctx context.Context, phases []Phase, logImages bool
 	}
 
 	if err := c.doImages(ctx, phases, logImages); err != nil {
+		for _, phase := range phases {
+			phase.AfterImagesFailed(ctx)
+		}
+
 		return err
 	}
 
@@ -698,6 +816,11 @@ func (c *Conveyor) GetImportMetadata(ctx context.Context, projectName, id string
 }
 
 func (c *Conveyor) PutImportMetadata(ctx context.Context, projectName string, metadata *storage.ImportMetadata) error {
+	// the import metadata is only the cache of the calculated checksum
+	if c.StorageManager.IsReadOnly() {
+		return nil
+	}
+
 	return c.StorageManager.GetStagesStorage().PutImportMetadata(ctx, projectName, metadata)
 }
 
@@ -786,6 +909,28 @@ func getImageConfigsToProcess(ctx context.Context, c *Conveyor) []config.ImageIn
 	return imageConfigsToProcess
 }
 
+func readImageScripts(ctx context.Context, imageBaseConfig *config.StapelImageBase, c *Conveyor) (map[string][]byte, error) {
+	if imageBaseConfig.Scripts == nil {
+		return nil, nil
+	}
+
+	scriptsData := map[string][]byte{}
+	for _, scriptPath := range imageBaseConfig.Scripts.Paths() {
+		if _, ok := scriptsData[scriptPath]; ok {
+			continue
+		}
+
+		data, err := c.giterminismManager.FileReader().ReadStapelScript(ctx, scriptPath)
+		if err != nil {
+			return nil, err
+		}
+
+		scriptsData[scriptPath] = data
+	}
+
+	return scriptsData, nil
+}
+
 func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.StapelImageInterface, c *Conveyor) error {
 	var stages []stage.Interface
 
@@ -793,12 +938,18 @@ func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.S
 	imageName := imageBaseConfig.Name
 	imageArtifact := imageInterfaceConfig.IsArtifact()
 
+	scriptsData, err := readImageScripts(ctx, imageBaseConfig, c)
+	if err != nil {
+		return err
+	}
+
 	baseStageOptions := &stage.NewBaseStageOptions{
 		ImageName:        imageName,
 		ConfigMounts:     imageBaseConfig.Mount,
 		ImageTmpDir:      c.GetImageTmpDir(imageBaseConfig.Name),
 		ContainerWerfDir: c.containerWerfDir,
 		ProjectName:      c.werfConfig.Meta.Project,
+		ScriptsData:      scriptsData,
 	}
 
 	gitArchiveStageOptions := &stage.NewGitArchiveStageOptions{
@@ -898,7 +1049,7 @@ func generateGitMappings(ctx context.Context, imageBaseConfig *config.StapelImag
 		remoteGitRepo := c.GetRemoteGitRepo(remoteGitMappingConfig.Name)
 		if remoteGitRepo == nil {
 			var err error
-			remoteGitRepo, err = git_repo.OpenRemoteRepo(remoteGitMappingConfig.Name, remoteGitMappingConfig.Url)
+			remoteGitRepo, err = openRemoteGitRepo(remoteGitMappingConfig, c)
 			if err != nil {
 				return nil, fmt.Errorf("unable to open remote git repo %s by url %s: %s", remoteGitMappingConfig.Name, remoteGitMappingConfig.Url, err)
 			}
@@ -941,6 +1092,50 @@ func generateGitMappings(ctx context.Context, imageBaseConfig *config.StapelImag
 	return res, nil
 }
 
+// openRemoteGitRepo opens the partial clone of the remote repo when any git mapping of the repo requires it.
+// Only the paths used by all git mappings of the repo are checked out.
+func openRemoteGitRepo(remoteGitMappingConfig *config.GitRemote, c *Conveyor) (*git_repo.Remote, error) {
+	remoteGitMappings := c.werfConfig.GetRemoteGitMappings(remoteGitMappingConfig.Name)
+
+	var partialClone bool
+	for _, gitMapping := range remoteGitMappings {
+		if gitMapping.PartialClone {
+			partialClone = true
+			break
+		}
+	}
+
+	if !partialClone {
+		return git_repo.OpenRemoteRepo(remoteGitMappingConfig.Name, remoteGitMappingConfig.Url)
+	}
+
+	return git_repo.OpenPartialRemoteRepo(remoteGitMappingConfig.Name, remoteGitMappingConfig.Url, getSparseCheckoutPatterns(remoteGitMappings))
+}
+
+// getSparseCheckoutPatterns returns the patterns matching the add and includePaths of the git mappings, nil means the whole repo is needed.
+func getSparseCheckoutPatterns(gitMappings []*config.GitRemote) []string {
+	var patterns []string
+	for _, gitMapping := range gitMappings {
+		add := gitMapping.GitMappingAdd()
+
+		if len(gitMapping.IncludePaths) == 0 {
+			if add == "" {
+				return nil
+			}
+
+			patterns = append(patterns, "/"+add)
+			continue
+		}
+
+		for _, includePath := range gitMapping.IncludePaths {
+			patterns = append(patterns, "/"+path.Join(add, includePath))
+		}
+	}
+
+	sort.Strings(patterns)
+	return util.UniqStrings(patterns)
+}
+
 func filterAndLogGitMappings(ctx context.Context, c *Conveyor, gitMappings []*stage.GitMapping) ([]*stage.GitMapping, error) {
 	var res []*stage.GitMapping
 
@@ -1228,6 +1423,7 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		util.MapStringInterfaceToMapStringString(imageFromDockerfileConfig.Args),
 		dockerMetaArgs,
 		dockerTargetIndex,
+		p.EscapeToken,
 	)
 	if err != nil {
 		return nil, err
@@ -1247,29 +1443,117 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		ProjectName: c.werfConfig.Meta.Project,
 	}
 
-	dockerfileStage := stage.GenerateDockerfileStage(
-		stage.NewDockerRunArgs(
+	dockerfileSecrets := c.getDockerfileSecrets(imageFromDockerfileConfig)
+	newDockerRunArgs := func(target string) *stage.DockerRunArgs {
+		return stage.NewDockerRunArgs(
 			imageFromDockerfileConfig.Dockerfile,
-			imageFromDockerfileConfig.Target,
+			target,
 			imageFromDockerfileConfig.Context,
 			imageFromDockerfileConfig.ContextAddFiles,
+			imageFromDockerfileConfig.PlatformIndependentContextChecksum,
 			imageFromDockerfileConfig.Args,
 			imageFromDockerfileConfig.AddHost,
 			imageFromDockerfileConfig.Network,
 			imageFromDockerfileConfig.SSH,
-		),
+			imageFromDockerfileConfig.Platform,
+			dockerfileSecrets,
+		)
+	}
+
+	cacheStages := map[int]*stage.DockerfileStage{}
+	getCacheFromStages := func(dockerStageIndex int) []*stage.DockerfileStage {
+		var result []*stage.DockerfileStage
+		for _, ind := range getDockerStageDependencyIndexes(dockerStages, dockerStageIndex) {
+			if cacheStage, ok := cacheStages[ind]; ok {
+				result = append(result, cacheStage)
+			}
+		}
+
+		return result
+	}
+
+	if imageFromDockerfileConfig.CacheStages {
+		for _, ind := range getDockerStageDependencyIndexes(dockerStages, dockerTargetIndex) {
+			// only named stages can be built separately with --target
+			dockerStageName := dockerStages[ind].Name
+			if dockerStageName == "" {
+				continue
+			}
+
+			cacheStageDockerStages, err := stage.NewDockerStages(
+				dockerStages,
+				util.MapStringInterfaceToMapStringString(imageFromDockerfileConfig.Args),
+				dockerMetaArgs,
+				ind,
+				p.EscapeToken,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			cacheStage := stage.GenerateDockerfileCacheStage(
+				dockerStageName,
+				newDockerRunArgs(dockerStageName),
+				cacheStageDockerStages,
+				stage.NewContextChecksum(dockerignorePathMatcher, c.contextCache),
+				baseStageOptions,
+			)
+			cacheStage.SetCacheFromStages(getCacheFromStages(ind))
+			cacheStages[ind] = cacheStage
+
+			img.stages = append(img.stages, cacheStage)
+		}
+	}
+
+	dockerfileStage := stage.GenerateDockerfileStage(
+		newDockerRunArgs(imageFromDockerfileConfig.Target),
 		ds,
-		stage.NewContextChecksum(dockerignorePathMatcher),
+		stage.NewContextChecksum(dockerignorePathMatcher, c.contextCache),
 		baseStageOptions,
 	)
+	dockerfileStage.SetCacheFromStages(getCacheFromStages(dockerTargetIndex))
 
 	img.stages = append(img.stages, dockerfileStage)
 
-	logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", dockerfileStage.Name())
+	for _, stg := range img.stages {
+		logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", stg.Name())
+	}
 
 	return img, nil
 }
 
+// getDockerfileSecrets returns secrets from werf.yaml and --secret options, the options take priority.
+func (c *Conveyor) getDockerfileSecrets(imageFromDockerfileConfig *config.ImageFromDockerfile) []*stage.DockerfileSecret {
+	secrets := append([]*stage.DockerfileSecret{}, c.ConveyorOptions.DockerfileSecrets...)
+
+	defaultSecretValuesFile := filepath.Join(".helm", "secret-values.yaml")
+	if c.werfConfig.Meta.Deploy.HelmChartDir != nil && *c.werfConfig.Meta.Deploy.HelmChartDir != "" {
+		defaultSecretValuesFile = filepath.Join(*c.werfConfig.Meta.Deploy.HelmChartDir, "secret-values.yaml")
+	}
+
+configSecretsLoop:
+	for _, secretConfig := range imageFromDockerfileConfig.Secrets {
+		for _, secret := range c.ConveyorOptions.DockerfileSecrets {
+			if secret.ID == secretConfig.ID {
+				continue configSecretsLoop
+			}
+		}
+
+		secretValuesFile := secretConfig.SecretValuesFile
+		if secretValuesFile == "" {
+			secretValuesFile = defaultSecretValuesFile
+		}
+
+		secrets = append(secrets, &stage.DockerfileSecret{
+			ID:               secretConfig.ID,
+			SecretValue:      secretConfig.SecretValue,
+			SecretValuesFile: secretValuesFile,
+		})
+	}
+
+	return secrets
+}
+
 func resolveDockerStagesFromValue(stages []instructions.Stage) {
 	nameToIndex := make(map[string]string)
 	for i, s := range stages {
@@ -1293,6 +1577,51 @@ func resolveDockerStagesFromValue(stages []instructions.Stage) {
 	}
 }
 
+// getDockerStageDependencyIndexes returns sorted indexes of the Dockerfile stages which the stage depends on directly or indirectly (FROM STAGE or COPY --from=STAGE).
+func getDockerStageDependencyIndexes(dockerStages []instructions.Stage, dockerStageIndex int) []int {
+	isDependency := map[int]bool{}
+
+	var collectFunc func(ind int)
+	collectFunc = func(ind int) {
+		addFunc := func(relatedStageIndex int) {
+			if relatedStageIndex == ind || isDependency[relatedStageIndex] {
+				return
+			}
+
+			isDependency[relatedStageIndex] = true
+			collectFunc(relatedStageIndex)
+		}
+
+		for relatedStageIndex, relatedStage := range dockerStages {
+			if relatedStageIndex != ind && dockerStages[ind].BaseName == relatedStage.Name {
+				addFunc(relatedStageIndex)
+			}
+		}
+
+		for _, cmd := range dockerStages[ind].Commands {
+			switch c := cmd.(type) {
+			case *instructions.CopyCommand:
+				if c.From != "" {
+					relatedStageIndex, err := strconv.Atoi(c.From)
+					if err == nil && relatedStageIndex < len(dockerStages) {
+						addFunc(relatedStageIndex)
+					}
+				}
+			}
+		}
+	}
+	collectFunc(dockerStageIndex)
+
+	var result []int
+	for ind := range dockerStages {
+		if isDependency[ind] {
+			result = append(result, ind)
+		}
+	}
+
+	return result
+}
+
 func getDockerTargetStageIndex(dockerStages []instructions.Stage, dockerTargetStage string) (int, error) {
 	if dockerTargetStage == "" {
 		return len(dockerStages) - 1, nil
@@ -0,0 +1,97 @@
+package build
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+)
+
+func newTestGitRemote(add string, includePaths ...string) *config.GitRemote {
+	return &config.GitRemote{
+		GitRemoteExport: &config.GitRemoteExport{
+			GitLocalExport: &config.GitLocalExport{
+				GitExportBase: &config.GitExportBase{
+					GitExport: &config.GitExport{
+						ExportBase: &config.ExportBase{Add: add, IncludePaths: includePaths},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGetSparseCheckoutPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		gitMappings []*config.GitRemote
+		expected    []string
+	}{
+		{
+			name:        "add",
+			gitMappings: []*config.GitRemote{newTestGitRemote("/app")},
+			expected:    []string{"/app"},
+		},
+		{
+			name:        "include paths",
+			gitMappings: []*config.GitRemote{newTestGitRemote("/app", "src", "go.mod")},
+			expected:    []string{"/app/go.mod", "/app/src"},
+		},
+		{
+			name:        "multiple git mappings",
+			gitMappings: []*config.GitRemote{newTestGitRemote("/b"), newTestGitRemote("/a"), newTestGitRemote("/b")},
+			expected:    []string{"/a", "/b"},
+		},
+		{
+			name:        "root add",
+			gitMappings: []*config.GitRemote{newTestGitRemote("/app"), newTestGitRemote("/")},
+			expected:    nil,
+		},
+		{
+			name:        "root add with include paths",
+			gitMappings: []*config.GitRemote{newTestGitRemote("/", "app")},
+			expected:    []string{"/app"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if patterns := getSparseCheckoutPatterns(tc.gitMappings); !reflect.DeepEqual(patterns, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, patterns)
+			}
+		})
+	}
+}
+
+func TestConveyor_GetDockerfileSecrets(t *testing.T) {
+	imageConfig := &config.ImageFromDockerfile{Secrets: []*config.DockerfileSecret{
+		{ID: "npmrc", SecretValue: "npm.token"},
+		{ID: "pip", SecretValue: "pip.conf", SecretValuesFile: "build-secret-values.yaml"},
+		{ID: "token", SecretValue: "token"},
+	}}
+	optionSecrets := []*stage.DockerfileSecret{{ID: "token", Src: "/tmp/token"}}
+
+	helmChartDir := "chart"
+	for _, tc := range []struct {
+		name                    string
+		helmChartDir            *string
+		expectedSecretValueFile string
+	}{
+		{"default helm chart dir", nil, filepath.Join(".helm", "secret-values.yaml")},
+		{"custom helm chart dir", &helmChartDir, filepath.Join("chart", "secret-values.yaml")},
+	} {
+		c := &Conveyor{
+			werfConfig:      &config.WerfConfig{Meta: &config.Meta{Deploy: config.MetaDeploy{HelmChartDir: tc.helmChartDir}}},
+			ConveyorOptions: ConveyorOptions{DockerfileSecrets: optionSecrets},
+		}
+
+		expected := []*stage.DockerfileSecret{
+			{ID: "token", Src: "/tmp/token"},
+			{ID: "npmrc", SecretValue: "npm.token", SecretValuesFile: tc.expectedSecretValueFile},
+			{ID: "pip", SecretValue: "pip.conf", SecretValuesFile: "build-secret-values.yaml"},
+		}
+		if secrets := c.getDockerfileSecrets(imageConfig); !reflect.DeepEqual(secrets, expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, expected, secrets)
+		}
+	}
+}
@@ -50,12 +50,18 @@ func (phase *ExportPhase) exportLastStageImage(ctx context.Context, img *Image)
 			options.Style(style.Highlight())
 		}).
 		DoError(func() error {
+			// the per-build werf.yaml metadata labels are set only on the exported images, the stages are shared by the builds
+			metadataLabels, err := phase.Conveyor.metadataLabels()
+			if err != nil {
+				return err
+			}
+
 			for _, tagFunc := range phase.ExportTagFuncList {
 				tag := tagFunc(img.GetName())
 				if err := logboek.Context(ctx).Default().LogProcess("tag %s", tag).
 					DoError(func() error {
 						stageDesc := img.GetLastNonEmptyStage().GetImage().GetStageDescription()
-						if err := phase.Conveyor.StorageManager.GetStagesStorage().ExportStage(ctx, stageDesc, tag); err != nil {
+						if err := phase.Conveyor.StorageManager.GetStagesStorage().ExportStage(ctx, stageDesc, tag, metadataLabels); err != nil {
 							return err
 						}
 
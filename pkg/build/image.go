@@ -40,6 +40,8 @@ type Image struct {
 	baseImageType    BaseImageType
 	stageAsBaseImage stage.Interface
 	baseImage        *container_runtime.StageImage
+
+	inputsDigest string
 }
 
 func (i *Image) LogName() string {
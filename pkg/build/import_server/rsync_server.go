@@ -118,6 +118,10 @@ func (srv *RsyncServer) GetCopyCommand(ctx context.Context, importConfig *config
 	var args []string
 
 	rsyncImportPathSpec := fmt.Sprintf("rsync://%s@%s:%s/import/%s", srv.AuthUser, srv.IPAddress, srv.Port, importConfig.Add)
+	if importConfig.IsAddGlob() {
+		// the glob is expanded by the rsync server
+		rsyncImportPathSpec = fmt.Sprintf("'%s'", rsyncImportPathSpec)
+	}
 	rsyncStatImportPathCommand := fmt.Sprintf("RSYNC_PASSWORD='%s' %s -L %s", srv.AuthPassword, stapel.RsyncBinPath(), rsyncImportPathSpec)
 
 	// save stat output to variable
@@ -126,21 +130,31 @@ func (srv *RsyncServer) GetCopyCommand(ctx context.Context, importConfig *config
 	args = append(args, "[ $? -eq 0 ]")
 	// unset old value of IMPORT_PATH_TRAILING_SLASH_OPTIONAL variable from other copy commands
 	args = append(args, "unset IMPORT_PATH_TRAILING_SLASH_OPTIONAL")
-	// set fileTypeField
-	args = append(args, fmt.Sprintf("fileTypeField=$(echo $statOutput | %s -c1)", stapel.HeadBinPath()))
-	// check command exit code from last subshell
-	args = append(args, "[ $? -eq 0 ]")
-	// set optional trailing slash when importing directory so that rsync will automatically
-	// merge already existing directory in the target image
-	args = append(args, "if [ $fileTypeField = d ] ; then IMPORT_PATH_TRAILING_SLASH_OPTIONAL=/ ; fi")
-	// create a parent directory where target file/directory will reside
-	args = append(args, fmt.Sprintf("%s -p %s", stapel.MkdirBinPath(), path.Dir(importConfig.To)))
+	if importConfig.IsAddGlob() {
+		// create the target directory for all files/directories matched by the glob
+		args = append(args, fmt.Sprintf("%s -p %s", stapel.MkdirBinPath(), importConfig.To))
+	} else {
+		// set fileTypeField
+		args = append(args, fmt.Sprintf("fileTypeField=$(echo $statOutput | %s -c1)", stapel.HeadBinPath()))
+		// check command exit code from last subshell
+		args = append(args, "[ $? -eq 0 ]")
+		// set optional trailing slash when importing directory so that rsync will automatically
+		// merge already existing directory in the target image
+		args = append(args, "if [ $fileTypeField = d ] ; then IMPORT_PATH_TRAILING_SLASH_OPTIONAL=/ ; fi")
+		// create a parent directory where target file/directory will reside
+		args = append(args, fmt.Sprintf("%s -p %s", stapel.MkdirBinPath(), path.Dir(importConfig.To)))
+	}
 
+	// the ownership and the permissions are rewritten by rsync while copying, so no extra instructions are needed
 	var rsyncChownOption string
 	if importConfig.Owner != "" || importConfig.Group != "" {
 		rsyncChownOption = fmt.Sprintf("--chown=%s:%s", importConfig.Owner, importConfig.Group)
 	}
-	rsyncCommand := fmt.Sprintf("RSYNC_PASSWORD='%s' %s --archive --links --inplace %s", srv.AuthPassword, stapel.RsyncBinPath(), rsyncChownOption)
+	var rsyncChmodOption string
+	if chmodRules := rsyncChmodRules(importConfig); len(chmodRules) != 0 {
+		rsyncChmodOption = fmt.Sprintf("--chmod=%s", strings.Join(chmodRules, ","))
+	}
+	rsyncCommand := fmt.Sprintf("RSYNC_PASSWORD='%s' %s --archive --links --inplace %s %s", srv.AuthPassword, stapel.RsyncBinPath(), rsyncChownOption, rsyncChmodOption)
 
 	if len(importConfig.IncludePaths) != 0 {
 		/**
@@ -151,11 +165,11 @@ func (srv *RsyncServer) GetCopyCommand(ctx context.Context, importConfig *config
 		        будет обрабатываться в пользу exclude, этот путь не скопируется.
 		*/
 		for _, p := range importConfig.ExcludePaths {
-			rsyncCommand += fmt.Sprintf(" --filter='-/ %s'", path.Join(importConfig.Add, p))
+			rsyncCommand += fmt.Sprintf(" --filter='-/ %s'", path.Join(importConfig.AddBasePath(), p))
 		}
 
 		for _, p := range importConfig.IncludePaths {
-			targetPath := path.Join(importConfig.Add, p)
+			targetPath := path.Join(importConfig.AddBasePath(), p)
 
 			// Генерируем разрешающее правило для каждого элемента пути
 			for _, pathPart := range descentPath(targetPath) {
@@ -174,14 +188,18 @@ func (srv *RsyncServer) GetCopyCommand(ctx context.Context, importConfig *config
 		}
 
 		// Все что не подошло по include — исключается
-		rsyncCommand += fmt.Sprintf(" --filter='-/ %s'", path.Join(importConfig.Add, "**"))
+		rsyncCommand += fmt.Sprintf(" --filter='-/ %s'", path.Join(importConfig.AddBasePath(), "**"))
 	} else {
 		for _, p := range importConfig.ExcludePaths {
-			rsyncCommand += fmt.Sprintf(" --filter='-/ %s'", path.Join(importConfig.Add, p))
+			rsyncCommand += fmt.Sprintf(" --filter='-/ %s'", path.Join(importConfig.AddBasePath(), p))
 		}
 	}
 
-	rsyncCommand += fmt.Sprintf(" %s$IMPORT_PATH_TRAILING_SLASH_OPTIONAL %s", rsyncImportPathSpec, importConfig.To)
+	if importConfig.IsAddGlob() {
+		rsyncCommand += fmt.Sprintf(" %s %s/", rsyncImportPathSpec, strings.TrimSuffix(importConfig.To, "/"))
+	} else {
+		rsyncCommand += fmt.Sprintf(" %s$IMPORT_PATH_TRAILING_SLASH_OPTIONAL %s", rsyncImportPathSpec, importConfig.To)
+	}
 	// run rsync itself
 	args = append(args, rsyncCommand)
 
@@ -192,6 +210,18 @@ func (srv *RsyncServer) GetCopyCommand(ctx context.Context, importConfig *config
 	return command
 }
 
+func rsyncChmodRules(importConfig *config.Import) []string {
+	var rules []string
+	if importConfig.DirMode != "" {
+		rules = append(rules, "D"+importConfig.DirMode)
+	}
+	if importConfig.FileMode != "" {
+		rules = append(rules, "F"+importConfig.FileMode)
+	}
+
+	return rules
+}
+
 func descentPath(filePath string) []string {
 	var parts []string
 
@@ -0,0 +1,71 @@
+package import_server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+func newTestImportConfig(add, to, fileMode, dirMode string) *config.Import {
+	return &config.Import{
+		ArtifactExport: &config.ArtifactExport{ExportBase: &config.ExportBase{Add: add, To: to}},
+		FileMode:       fileMode,
+		DirMode:        dirMode,
+	}
+}
+
+func TestRsyncServerGetCopyCommandModeOverride(t *testing.T) {
+	srv := &RsyncServer{IPAddress: "172.17.0.2", Port: "873", AuthUser: "werf", AuthPassword: "secret"}
+
+	for _, tc := range []struct {
+		name     string
+		config   *config.Import
+		expected string
+	}{
+		{
+			name:   "path with modes",
+			config: newTestImportConfig("/app/build", "/app", "0644", "0755"),
+			expected: "statOutput=$(RSYNC_PASSWORD='secret' /.werf/stapel/embedded/bin/rsync -L rsync://werf@172.17.0.2:873/import//app/build) && " +
+				"[ $? -eq 0 ] && " +
+				"unset IMPORT_PATH_TRAILING_SLASH_OPTIONAL && " +
+				"fileTypeField=$(echo $statOutput | /.werf/stapel/embedded/bin/head -c1) && " +
+				"[ $? -eq 0 ] && " +
+				"if [ $fileTypeField = d ] ; then IMPORT_PATH_TRAILING_SLASH_OPTIONAL=/ ; fi && " +
+				"/.werf/stapel/embedded/bin/mkdir -p / && " +
+				"RSYNC_PASSWORD='secret' /.werf/stapel/embedded/bin/rsync --archive --links --inplace  --chmod=D0755,F0644 rsync://werf@172.17.0.2:873/import//app/build$IMPORT_PATH_TRAILING_SLASH_OPTIONAL /app",
+		},
+		{
+			name:   "glob with file mode",
+			config: newTestImportConfig("/app/build/*.jar", "/app/libs/", "0600", ""),
+			expected: "statOutput=$(RSYNC_PASSWORD='secret' /.werf/stapel/embedded/bin/rsync -L 'rsync://werf@172.17.0.2:873/import//app/build/*.jar') && " +
+				"[ $? -eq 0 ] && " +
+				"unset IMPORT_PATH_TRAILING_SLASH_OPTIONAL && " +
+				"/.werf/stapel/embedded/bin/mkdir -p /app/libs/ && " +
+				"RSYNC_PASSWORD='secret' /.werf/stapel/embedded/bin/rsync --archive --links --inplace  --chmod=F0600 'rsync://werf@172.17.0.2:873/import//app/build/*.jar' /app/libs/",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if command := srv.GetCopyCommand(context.Background(), tc.config); command != tc.expected {
+				t.Errorf("unexpected copy command:\n%s\nexpected:\n%s", command, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRsyncChmodRules(t *testing.T) {
+	for _, tc := range []struct {
+		fileMode, dirMode string
+		expected          []string
+	}{
+		{"", "", nil},
+		{"0644", "", []string{"F0644"}},
+		{"", "755", []string{"D755"}},
+		{"0644", "0755", []string{"D0755", "F0644"}},
+	} {
+		if rules := rsyncChmodRules(newTestImportConfig("/app", "/app", tc.fileMode, tc.dirMode)); !reflect.DeepEqual(rules, tc.expected) {
+			t.Errorf("expected %v for fileMode %q and dirMode %q, got %v", tc.expected, tc.fileMode, tc.dirMode, rules)
+		}
+	}
+}
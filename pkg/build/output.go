@@ -0,0 +1,167 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/google/uuid"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/util"
+)
+
+type OutputType string
+
+const (
+	// OutputDockerArchive saves all images into the tarball in the format of the docker save command
+	OutputDockerArchive OutputType = "docker-archive"
+	// OutputLocal exports the root filesystem of each image into the directory
+	OutputLocal OutputType = "local"
+)
+
+type OutputOptions struct {
+	Type OutputType
+	Dest string
+}
+
+// ParseOutputOptions parses the output in the type=TYPE,dest=PATH format.
+func ParseOutputOptions(spec string) (OutputOptions, error) {
+	var opts OutputOptions
+
+	for _, field := range strings.Split(spec, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return opts, fmt.Errorf("bad output %q: expected format type=TYPE,dest=PATH", spec)
+		}
+
+		switch key, value := parts[0], parts[1]; key {
+		case "type":
+			opts.Type = OutputType(value)
+		case "dest":
+			opts.Dest = util.ExpandPath(value)
+		default:
+			return opts, fmt.Errorf("bad output %q: unsupported field %q, expected format type=TYPE,dest=PATH", spec, key)
+		}
+	}
+
+	switch opts.Type {
+	case OutputDockerArchive, OutputLocal:
+	case "":
+		return opts, fmt.Errorf("bad output %q: type required", spec)
+	default:
+		return opts, fmt.Errorf("bad output %q: unsupported type %q, expected %s or %s", spec, opts.Type, OutputDockerArchive, OutputLocal)
+	}
+
+	if opts.Dest == "" {
+		return opts, fmt.Errorf("bad output %q: dest required", spec)
+	}
+
+	return opts, nil
+}
+
+// OutputImages exports the built images into the docker archive or the local directory.
+// The images should be built with the local stages storage.
+func (c *Conveyor) OutputImages(ctx context.Context, opts OutputOptions) error {
+	var imageNames []string
+	for _, img := range c.images {
+		if img.isArtifact {
+			continue
+		}
+
+		if err := c.FetchLastImageStage(ctx, img.GetName()); err != nil {
+			return fmt.Errorf("unable to fetch image %s last stage: %s", img.GetLogName(), err)
+		}
+
+		imageNames = append(imageNames, img.GetName())
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Exporting images into %s %s", opts.Type, opts.Dest).DoError(func() error {
+		switch opts.Type {
+		case OutputDockerArchive:
+			var refs []string
+			for _, imageName := range imageNames {
+				ref := c.GetImageNameForLastImageStage(imageName)
+				logboek.Context(ctx).Default().LogF("%s: %s\n", c.GetImage(imageName).GetLogName(), ref)
+				refs = append(refs, ref)
+			}
+
+			return saveImagesToDockerArchive(ctx, refs, opts.Dest)
+		case OutputLocal:
+			for _, imageName := range imageNames {
+				// the nameless image is exported into the dest itself
+				dir := filepath.Join(opts.Dest, imageName)
+				logboek.Context(ctx).Default().LogF("%s: %s\n", c.GetImage(imageName).GetLogName(), dir)
+
+				if err := exportImageRootfs(ctx, c.GetImageNameForLastImageStage(imageName), dir); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		default:
+			panic(fmt.Sprintf("unknown output type %q", opts.Type))
+		}
+	})
+}
+
+func saveImagesToDockerArchive(ctx context.Context, refs []string, archivePath string) error {
+	if err := os.MkdirAll(filepath.Dir(archivePath), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(archivePath), err)
+	}
+
+	reader, err := docker.ImageSave(ctx, refs)
+	if err != nil {
+		return fmt.Errorf("unable to save images %v: %s", refs, err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("unable to create %q: %s", archivePath, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("unable to write %q: %s", archivePath, err)
+	}
+
+	return nil
+}
+
+func exportImageRootfs(ctx context.Context, ref, dir string) error {
+	// the command is not executed, the container is only used to export the image filesystem
+	containerID, err := docker.ContainerCreate(ctx, &containertypes.Config{Image: ref, Cmd: []string{"true"}}, fmt.Sprintf("werf-output-%s", uuid.New().String()))
+	if err != nil {
+		return fmt.Errorf("unable to create container for image %s: %s", ref, err)
+	}
+	defer func() {
+		if err := docker.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: unable to remove container %s: %s\n", containerID, err)
+		}
+	}()
+
+	reader, err := docker.ContainerExport(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("unable to export container %s filesystem: %s", containerID, err)
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %q: %s", dir, err)
+	}
+
+	if err := archive.Untar(reader, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		return fmt.Errorf("unable to extract image %s filesystem into %q: %s", ref, dir, err)
+	}
+
+	return nil
+}
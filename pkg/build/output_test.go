@@ -0,0 +1,52 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOutputOptions(t *testing.T) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		spec        string
+		expected    OutputOptions
+		expectedErr bool
+	}{
+		{spec: "type=docker-archive,dest=images.tar", expected: OutputOptions{Type: OutputDockerArchive, Dest: filepath.Join(workingDir, "images.tar")}},
+		{spec: "dest=out,type=local", expected: OutputOptions{Type: OutputLocal, Dest: filepath.Join(workingDir, "out")}},
+		{spec: "type=local,dest=~/out", expected: OutputOptions{Type: OutputLocal, Dest: filepath.Join(homeDir, "out")}},
+		{spec: "type=local", expectedErr: true},
+		{spec: "dest=out", expectedErr: true},
+		{spec: "type=oci,dest=out", expectedErr: true},
+		{spec: "type=local,dest=out,compression=gzip", expectedErr: true},
+		{spec: "local", expectedErr: true},
+		{spec: "", expectedErr: true},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			opts, err := ParseOutputOptions(tc.spec)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", opts)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, opts)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ type Phase interface {
 	Name() string
 	BeforeImages(ctx context.Context) error
 	AfterImages(ctx context.Context) error
+	// AfterImagesFailed is called instead of AfterImages when the images processing is failed
+	AfterImagesFailed(ctx context.Context)
 	BeforeImageStages(ctx context.Context, img *Image) error
 	OnImageStage(ctx context.Context, img *Image, stg stage.Interface) error
 	AfterImageStages(ctx context.Context, img *Image) error
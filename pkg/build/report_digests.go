@@ -0,0 +1,159 @@
+package build
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+// DigestsReport describes the images stages digests calculated without the build
+// and shows whether the images are already built and stored in the repo.
+type DigestsReport struct {
+	mux    sync.Mutex
+	Images map[string]DigestsReportImageRecord
+}
+
+type DigestsReportImageRecord struct {
+	WerfImageName   string
+	IsArtifact      bool
+	Built           bool
+	DockerImageName string `json:",omitempty"`
+	// NotBuiltStage is the first image stage not found in the repo,
+	// the digests of the next stages depend on the build result of this stage and cannot be calculated.
+	NotBuiltStage string `json:",omitempty"`
+	// NotBuiltDependency is the dependency image not built yet,
+	// the digests of the image stages depend on the dependency image and cannot be calculated.
+	NotBuiltDependency string                     `json:",omitempty"`
+	Stages             []DigestsReportStageRecord `json:",omitempty"`
+}
+
+type DigestsReportStageRecord struct {
+	Name   string
+	Digest string
+	Built  bool
+}
+
+func newDigestsReport() *DigestsReport {
+	return &DigestsReport{Images: make(map[string]DigestsReportImageRecord)}
+}
+
+func (report *DigestsReport) AddImageStageRecord(img *Image, stageRecord DigestsReportStageRecord) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	imageRecord := report.getOrCreateImageRecord(img)
+	imageRecord.Stages = append(imageRecord.Stages, stageRecord)
+	if !stageRecord.Built && imageRecord.NotBuiltStage == "" {
+		imageRecord.NotBuiltStage = stageRecord.Name
+	}
+	report.Images[img.GetName()] = imageRecord
+}
+
+func (report *DigestsReport) SetImageNotBuiltDependency(img *Image, dependencyName string) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	imageRecord := report.getOrCreateImageRecord(img)
+	imageRecord.NotBuiltDependency = dependencyName
+	report.Images[img.GetName()] = imageRecord
+}
+
+func (report *DigestsReport) SetImageBuilt(img *Image, dockerImageName string) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	imageRecord := report.getOrCreateImageRecord(img)
+	imageRecord.Built = true
+	imageRecord.DockerImageName = dockerImageName
+	report.Images[img.GetName()] = imageRecord
+}
+
+func (report *DigestsReport) getOrCreateImageRecord(img *Image) DigestsReportImageRecord {
+	if imageRecord, ok := report.Images[img.GetName()]; ok {
+		return imageRecord
+	}
+
+	return DigestsReportImageRecord{
+		WerfImageName: img.GetName(),
+		IsArtifact:    img.isArtifact,
+	}
+}
+
+// NotBuiltImages returns the names of the images and artifacts which should be built.
+func (report *DigestsReport) NotBuiltImages() []string {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	var names []string
+	for name, imageRecord := range report.Images {
+		if !imageRecord.Built {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (report *DigestsReport) ToJsonData() ([]byte, error) {
+	notBuiltImages := report.NotBuiltImages()
+
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	data, err := json.MarshalIndent(struct {
+		Images      map[string]DigestsReportImageRecord
+		FullyCached bool
+	}{
+		Images:      report.Images,
+		FullyCached: len(notBuiltImages) == 0,
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, []byte("\n")...)
+
+	return data, nil
+}
+
+func (phase *BuildPhase) afterImageStagesDigests(img *Image) error {
+	if phase.imageNotBuilt {
+		return nil
+	}
+
+	img.SetLastNonEmptyStage(phase.StagesIterator.PrevNonEmptyStage)
+	img.SetContentDigest(phase.StagesIterator.PrevNonEmptyStage.GetContentDigest())
+
+	lastStage := img.GetLastNonEmptyStage()
+	if phase.imageUnchanged {
+		phase.DigestsReport.AddImageStageRecord(img, DigestsReportStageRecord{Name: string(lastStage.Name()), Digest: lastStage.GetDigest(), Built: true})
+	}
+
+	dockerImageName := lastStage.GetImage().Name()
+	if !img.isArtifact {
+		dockerImageName = phase.Conveyor.StorageManager.GetImageInfoGetter(img.GetName(), lastStage).GetName()
+	}
+	phase.DigestsReport.SetImageBuilt(img, dockerImageName)
+
+	return nil
+}
+
+// getNotBuiltDependencyName returns the name of the first image dependency processed without the last stage found in the repo.
+func (phase *BuildPhase) getNotBuiltDependencyName(img *Image) string {
+	var imageConfig config.ImageInterface
+	imageConfig = phase.Conveyor.werfConfig.GetImage(img.GetName())
+	if imageConfig == nil {
+		imageConfig = phase.Conveyor.werfConfig.GetArtifact(img.GetName())
+	}
+
+	for _, dependencyConfig := range phase.Conveyor.werfConfig.ImageDependencies(imageConfig) {
+		dependencyImage := phase.Conveyor.GetImage(dependencyConfig.GetName())
+		if dependencyImage != nil && dependencyImage.GetLastNonEmptyStage() == nil {
+			return dependencyConfig.GetName()
+		}
+	}
+
+	return ""
+}
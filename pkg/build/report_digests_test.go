@@ -0,0 +1,85 @@
+package build
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDigestsReport(t *testing.T) {
+	report := newDigestsReport()
+
+	builtImage := &Image{name: "built"}
+	report.AddImageStageRecord(builtImage, DigestsReportStageRecord{Name: "from", Digest: "digest-1", Built: true})
+	report.AddImageStageRecord(builtImage, DigestsReportStageRecord{Name: "install", Digest: "digest-2", Built: true})
+	report.SetImageBuilt(builtImage, "registry.example.com/app:digest-2")
+
+	notBuiltImage := &Image{name: "not-built"}
+	report.AddImageStageRecord(notBuiltImage, DigestsReportStageRecord{Name: "from", Digest: "digest-3", Built: true})
+	report.AddImageStageRecord(notBuiltImage, DigestsReportStageRecord{Name: "install", Digest: "digest-4"})
+
+	dependentArtifact := &Image{name: "dependent", isArtifact: true}
+	report.SetImageNotBuiltDependency(dependentArtifact, "not-built")
+
+	if got, expected := report.NotBuiltImages(), []string{"dependent", "not-built"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected not built images %v, got %v", expected, got)
+	}
+
+	if got := report.Images["not-built"].NotBuiltStage; got != "install" {
+		t.Fatalf("expected not built stage install, got %q", got)
+	}
+
+	data, err := report.ToJsonData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed struct {
+		Images      map[string]DigestsReportImageRecord
+		FullyCached bool
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.FullyCached {
+		t.Fatal("expected not fully cached report")
+	}
+
+	expectedDependent := DigestsReportImageRecord{WerfImageName: "dependent", IsArtifact: true, NotBuiltDependency: "not-built"}
+	if !reflect.DeepEqual(parsed.Images["dependent"], expectedDependent) {
+		t.Fatalf("expected %+v, got %+v", expectedDependent, parsed.Images["dependent"])
+	}
+
+	expectedBuilt := DigestsReportImageRecord{
+		WerfImageName:   "built",
+		Built:           true,
+		DockerImageName: "registry.example.com/app:digest-2",
+		Stages: []DigestsReportStageRecord{
+			{Name: "from", Digest: "digest-1", Built: true},
+			{Name: "install", Digest: "digest-2", Built: true},
+		},
+	}
+	if !reflect.DeepEqual(parsed.Images["built"], expectedBuilt) {
+		t.Fatalf("expected %+v, got %+v", expectedBuilt, parsed.Images["built"])
+	}
+}
+
+func TestDigestsReport_FullyCached(t *testing.T) {
+	report := newDigestsReport()
+	report.SetImageBuilt(&Image{name: "app"}, "registry.example.com/app:digest")
+
+	data, err := report.ToJsonData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed struct{ FullyCached bool }
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.FullyCached {
+		t.Fatalf("expected fully cached report: %s", data)
+	}
+}
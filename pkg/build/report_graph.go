@@ -0,0 +1,422 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+)
+
+const (
+	GraphDot     GraphFormat = "dot"
+	GraphMermaid GraphFormat = "mermaid"
+)
+
+type GraphFormat string
+
+type GraphOptions struct {
+	// GraphPath is not set when the build graph is not saved
+	GraphPath   string
+	GraphFormat GraphFormat
+}
+
+// GetGraphFormatByPath selects the build graph format by the file extension: .dot and .gv for graphviz, .mmd and .mermaid for mermaid.
+func GetGraphFormatByPath(path string) (GraphFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot", ".gv":
+		return GraphDot, nil
+	case ".mmd", ".mermaid":
+		return GraphMermaid, nil
+	default:
+		return "", fmt.Errorf("unable to detect graph format of %q: expected .dot, .gv, .mmd or .mermaid file extension", path)
+	}
+}
+
+// buildGraph is the dependency graph of the images, artifacts and their stages processed by the build.
+type buildGraph struct {
+	images       []*buildGraphImage
+	baseImages   []string
+	dependencies []buildGraphDependency
+	edges        []buildGraphEdge
+}
+
+// buildGraphDependency is the image of the other werf project the config depends on (the dependencies directive of the meta section).
+type buildGraphDependency struct {
+	name  string
+	image string
+}
+
+type buildGraphImage struct {
+	name       string
+	isArtifact bool
+	stages     []ReportStageRecord
+}
+
+type buildGraphNode struct {
+	imageName string
+	stageName string
+	// baseImage is set for the base image pulled from the registry
+	baseImage string
+	// dependency is set for the image of the other werf project
+	dependency string
+}
+
+type buildGraphEdge struct {
+	from, to buildGraphNode
+	label    string
+}
+
+func (phase *BuildPhase) saveGraph() error {
+	graph := phase.newBuildGraph()
+
+	var data []byte
+	switch phase.GraphFormat {
+	case GraphDot:
+		data = graph.toDot()
+	case GraphMermaid:
+		data = graph.toMermaid()
+	default:
+		panic(fmt.Sprintf("unknown graph format %q", phase.GraphFormat))
+	}
+
+	if err := ioutil.WriteFile(phase.GraphPath, data, 0o644); err != nil {
+		return fmt.Errorf("unable to write build graph to %s: %s", phase.GraphPath, err)
+	}
+
+	return nil
+}
+
+func (phase *BuildPhase) newBuildGraph() *buildGraph {
+	graph := &buildGraph{}
+
+	var dependencyNames []string
+	for name := range phase.Conveyor.werfConfig.Dependencies {
+		dependencyNames = append(dependencyNames, name)
+	}
+	sort.Strings(dependencyNames)
+
+	for _, name := range dependencyNames {
+		graph.dependencies = append(graph.dependencies, buildGraphDependency{name: name, image: phase.Conveyor.werfConfig.Dependencies[name].Image})
+	}
+
+	for _, img := range phase.Conveyor.images {
+		graph.images = append(graph.images, &buildGraphImage{
+			name:       img.GetName(),
+			isArtifact: img.isArtifact,
+			stages:     phase.getImageReportStages(img),
+		})
+	}
+
+	for _, img := range phase.Conveyor.images {
+		graphImage := graph.getImage(img.GetName())
+		if len(graphImage.stages) == 0 {
+			continue
+		}
+
+		for i := 1; i < len(graphImage.stages); i++ {
+			graph.edges = append(graph.edges, buildGraphEdge{
+				from: buildGraphNode{imageName: img.GetName(), stageName: graphImage.stages[i-1].Name},
+				to:   buildGraphNode{imageName: img.GetName(), stageName: graphImage.stages[i].Name},
+			})
+		}
+
+		firstNode := buildGraphNode{imageName: img.GetName(), stageName: graphImage.stages[0].Name}
+		if img.baseImageImageName != "" {
+			if baseNode, ok := graph.getLastStageNode(img.baseImageImageName); ok {
+				graph.edges = append(graph.edges, buildGraphEdge{from: baseNode, to: firstNode, label: "from"})
+			}
+		} else if dependency, ok := graph.getDependencyByImage(img.baseImageName); ok {
+			graph.edges = append(graph.edges, buildGraphEdge{from: buildGraphNode{dependency: dependency.name}, to: firstNode, label: "from"})
+		} else if img.baseImageName != "" {
+			graph.addBaseImage(img.baseImageName)
+			graph.edges = append(graph.edges, buildGraphEdge{from: buildGraphNode{baseImage: img.baseImageName}, to: firstNode, label: "from"})
+		}
+
+		// the images of the dependencies are passed into the Dockerfile by the build args
+		if dockerfileImageConfig := phase.Conveyor.werfConfig.GetDockerfileImage(img.GetName()); dockerfileImageConfig != nil {
+			for _, dependency := range graph.dependencies {
+				for _, value := range dockerfileImageConfig.Args {
+					if fmt.Sprint(value) == dependency.image {
+						graph.edges = append(graph.edges, buildGraphEdge{from: buildGraphNode{dependency: dependency.name}, to: firstNode, label: "arg"})
+						break
+					}
+				}
+			}
+		}
+
+		for _, imp := range phase.getImageImports(img.GetName()) {
+			sourceImageName := imp.ImageName
+			if sourceImageName == "" {
+				sourceImageName = imp.ArtifactName
+			}
+
+			sourceNode, ok := graph.getStageNode(sourceImageName, imp.Stage)
+			if !ok {
+				continue
+			}
+
+			targetNode, ok := graph.getStageNode(img.GetName(), string(importStageName(imp)))
+			if !ok {
+				continue
+			}
+
+			graph.edges = append(graph.edges, buildGraphEdge{from: sourceNode, to: targetNode, label: "import"})
+		}
+	}
+
+	return graph
+}
+
+func (phase *BuildPhase) getImageImports(imageName string) []*config.Import {
+	if imageConfig := phase.Conveyor.werfConfig.GetStapelImage(imageName); imageConfig != nil {
+		return imageConfig.Import
+	}
+
+	if artifactConfig := phase.Conveyor.werfConfig.GetArtifact(imageName); artifactConfig != nil {
+		return artifactConfig.Import
+	}
+
+	return nil
+}
+
+func importStageName(imp *config.Import) stage.StageName {
+	switch {
+	case imp.Before == "install":
+		return stage.ImportsBeforeInstall
+	case imp.After == "install":
+		return stage.ImportsAfterInstall
+	case imp.Before == "setup":
+		return stage.ImportsBeforeSetup
+	default:
+		return stage.ImportsAfterSetup
+	}
+}
+
+func (graph *buildGraph) getImage(name string) *buildGraphImage {
+	for _, img := range graph.images {
+		if img.name == name {
+			return img
+		}
+	}
+
+	return nil
+}
+
+func (graph *buildGraph) addBaseImage(name string) {
+	for _, baseImage := range graph.baseImages {
+		if baseImage == name {
+			return
+		}
+	}
+
+	graph.baseImages = append(graph.baseImages, name)
+}
+
+// getStageNode returns the node of the image stage, the last stage of the image is used if the stage is not specified.
+// There is no node for the stage which is not processed, e.g. when the build is failed.
+func (graph *buildGraph) getStageNode(imageName, stageName string) (buildGraphNode, bool) {
+	if stageName == "" {
+		return graph.getLastStageNode(imageName)
+	}
+
+	img := graph.getImage(imageName)
+	if img == nil {
+		return buildGraphNode{}, false
+	}
+
+	for _, record := range img.stages {
+		if record.Name == stageName {
+			return buildGraphNode{imageName: imageName, stageName: stageName}, true
+		}
+	}
+
+	return buildGraphNode{}, false
+}
+
+func (graph *buildGraph) getDependencyByImage(image string) (buildGraphDependency, bool) {
+	if image == "" {
+		return buildGraphDependency{}, false
+	}
+
+	for _, dependency := range graph.dependencies {
+		if dependency.image == image {
+			return dependency, true
+		}
+	}
+
+	return buildGraphDependency{}, false
+}
+
+func (graph *buildGraph) getLastStageNode(imageName string) (buildGraphNode, bool) {
+	img := graph.getImage(imageName)
+	if img == nil || len(img.stages) == 0 {
+		return buildGraphNode{}, false
+	}
+
+	return buildGraphNode{imageName: imageName, stageName: img.stages[len(img.stages)-1].Name}, true
+}
+
+// nodeID returns the identifier of the node usable in both dot and mermaid formats.
+func (graph *buildGraph) nodeID(node buildGraphNode) string {
+	if node.baseImage != "" {
+		for ind, baseImage := range graph.baseImages {
+			if baseImage == node.baseImage {
+				return fmt.Sprintf("base%d", ind)
+			}
+		}
+	}
+
+	if node.dependency != "" {
+		for ind, dependency := range graph.dependencies {
+			if dependency.name == node.dependency {
+				return fmt.Sprintf("dependency%d", ind)
+			}
+		}
+	}
+
+	for imageInd, img := range graph.images {
+		if img.name != node.imageName {
+			continue
+		}
+
+		for stageInd, record := range img.stages {
+			if record.Name == node.stageName {
+				return fmt.Sprintf("image%d_stage%d", imageInd, stageInd)
+			}
+		}
+	}
+
+	panic(fmt.Sprintf("unknown graph node %#v", node))
+}
+
+func graphImageLabel(img *buildGraphImage) string {
+	kind := "image"
+	if img.isArtifact {
+		kind = "artifact"
+	}
+
+	if img.name == "" {
+		return fmt.Sprintf("%s ~", kind)
+	}
+
+	return fmt.Sprintf("%s %s", kind, img.name)
+}
+
+func graphDependencyLabel(dependency buildGraphDependency, lineSeparator string) string {
+	return fmt.Sprintf("dependency %s%s%s", dependency.name, lineSeparator, dependency.image)
+}
+
+func graphStageStatus(record ReportStageRecord) string {
+	if record.CacheOutcome == ReportStageCacheMiss {
+		return "built"
+	}
+
+	return fmt.Sprintf("cached (%s)", record.Storage)
+}
+
+func graphStageClass(record ReportStageRecord) string {
+	if record.CacheOutcome == ReportStageCacheMiss {
+		return "built"
+	}
+
+	return "cached"
+}
+
+func graphShortDigest(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+
+	return digest
+}
+
+func (graph *buildGraph) toDot() []byte {
+	buf := bytes.NewBuffer(nil)
+
+	buf.WriteString("digraph werf {\n")
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"monospace\"];\n")
+
+	for _, baseImage := range graph.baseImages {
+		fmt.Fprintf(buf, "  %s [label=%q, shape=ellipse, fillcolor=\"#e0e0e0\"];\n", graph.nodeID(buildGraphNode{baseImage: baseImage}), baseImage)
+	}
+
+	for _, dependency := range graph.dependencies {
+		fmt.Fprintf(buf, "  %s [label=%q, shape=ellipse, fillcolor=\"#bbdefb\"];\n", graph.nodeID(buildGraphNode{dependency: dependency.name}), graphDependencyLabel(dependency, "\n"))
+	}
+
+	for imageInd, img := range graph.images {
+		fmt.Fprintf(buf, "  subgraph cluster_image%d {\n", imageInd)
+		fmt.Fprintf(buf, "    label=%q;\n", graphImageLabel(img))
+
+		for _, record := range img.stages {
+			fillColor := "#c8e6c9"
+			if graphStageClass(record) == "built" {
+				fillColor = "#ffe0b2"
+			}
+
+			label := fmt.Sprintf("%s\\n%s\\n%s", record.Name, graphShortDigest(record.Digest), graphStageStatus(record))
+			fmt.Fprintf(buf, "    %s [label=\"%s\", fillcolor=%q];\n", graph.nodeID(buildGraphNode{imageName: img.name, stageName: record.Name}), label, fillColor)
+		}
+
+		buf.WriteString("  }\n")
+	}
+
+	for _, edge := range graph.edges {
+		if edge.label != "" {
+			fmt.Fprintf(buf, "  %s -> %s [label=%q];\n", graph.nodeID(edge.from), graph.nodeID(edge.to), edge.label)
+		} else {
+			fmt.Fprintf(buf, "  %s -> %s;\n", graph.nodeID(edge.from), graph.nodeID(edge.to))
+		}
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
+
+func (graph *buildGraph) toMermaid() []byte {
+	buf := bytes.NewBuffer(nil)
+
+	buf.WriteString("flowchart LR\n")
+	buf.WriteString("  classDef built fill:#ffe0b2\n")
+	buf.WriteString("  classDef cached fill:#c8e6c9\n")
+
+	for _, baseImage := range graph.baseImages {
+		fmt.Fprintf(buf, "  %s([\"%s\"])\n", graph.nodeID(buildGraphNode{baseImage: baseImage}), mermaidEscape(baseImage))
+	}
+
+	for _, dependency := range graph.dependencies {
+		fmt.Fprintf(buf, "  %s([\"%s\"])\n", graph.nodeID(buildGraphNode{dependency: dependency.name}), mermaidEscape(graphDependencyLabel(dependency, "<br/>")))
+	}
+
+	for imageInd, img := range graph.images {
+		fmt.Fprintf(buf, "  subgraph image%d [\"%s\"]\n", imageInd, mermaidEscape(graphImageLabel(img)))
+
+		for _, record := range img.stages {
+			label := fmt.Sprintf("%s<br/>%s<br/>%s", record.Name, graphShortDigest(record.Digest), graphStageStatus(record))
+			fmt.Fprintf(buf, "    %s[\"%s\"]:::%s\n", graph.nodeID(buildGraphNode{imageName: img.name, stageName: record.Name}), mermaidEscape(label), graphStageClass(record))
+		}
+
+		buf.WriteString("  end\n")
+	}
+
+	for _, edge := range graph.edges {
+		if edge.label != "" {
+			fmt.Fprintf(buf, "  %s -- %s --> %s\n", graph.nodeID(edge.from), edge.label, graph.nodeID(edge.to))
+		} else {
+			fmt.Fprintf(buf, "  %s --> %s\n", graph.nodeID(edge.from), graph.nodeID(edge.to))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, "\"", "#quot;")
+}
@@ -0,0 +1,134 @@
+package build
+
+import (
+	"testing"
+)
+
+func newTestBuildGraph() *buildGraph {
+	return &buildGraph{
+		images: []*buildGraphImage{
+			{name: "builder", isArtifact: true, stages: []ReportStageRecord{
+				{Name: "from", Digest: "0123456789abcdef", CacheOutcome: ReportStageCacheHit, Storage: ReportStageStoragePrimary},
+				{Name: "install", Digest: "fedcba9876543210", CacheOutcome: ReportStageCacheMiss},
+			}},
+			{name: "app", stages: []ReportStageRecord{
+				{Name: "from", Digest: "aaaa", CacheOutcome: ReportStageCacheHit, Storage: ReportStageStorageCache},
+			}},
+		},
+		baseImages:   []string{"alpine:3.12"},
+		dependencies: []buildGraphDependency{{name: "api", image: "registry.example.com/backend:api"}},
+		edges: []buildGraphEdge{
+			{from: buildGraphNode{baseImage: "alpine:3.12"}, to: buildGraphNode{imageName: "builder", stageName: "from"}, label: "from"},
+			{from: buildGraphNode{imageName: "builder", stageName: "from"}, to: buildGraphNode{imageName: "builder", stageName: "install"}},
+			{from: buildGraphNode{dependency: "api"}, to: buildGraphNode{imageName: "app", stageName: "from"}, label: "from"},
+			{from: buildGraphNode{imageName: "builder", stageName: "install"}, to: buildGraphNode{imageName: "app", stageName: "from"}, label: "import"},
+		},
+	}
+}
+
+func TestBuildGraphToDot(t *testing.T) {
+	expected := `digraph werf {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fontname="monospace"];
+  base0 [label="alpine:3.12", shape=ellipse, fillcolor="#e0e0e0"];
+  dependency0 [label="dependency api\nregistry.example.com/backend:api", shape=ellipse, fillcolor="#bbdefb"];
+  subgraph cluster_image0 {
+    label="artifact builder";
+    image0_stage0 [label="from\n0123456789ab\ncached (primary)", fillcolor="#c8e6c9"];
+    image0_stage1 [label="install\nfedcba987654\nbuilt", fillcolor="#ffe0b2"];
+  }
+  subgraph cluster_image1 {
+    label="image app";
+    image1_stage0 [label="from\naaaa\ncached (cache)", fillcolor="#c8e6c9"];
+  }
+  base0 -> image0_stage0 [label="from"];
+  image0_stage0 -> image0_stage1;
+  dependency0 -> image1_stage0 [label="from"];
+  image0_stage1 -> image1_stage0 [label="import"];
+}
+`
+
+	if dot := string(newTestBuildGraph().toDot()); dot != expected {
+		t.Fatalf("unexpected dot graph:\n%s", dot)
+	}
+}
+
+func TestBuildGraphToMermaid(t *testing.T) {
+	expected := `flowchart LR
+  classDef built fill:#ffe0b2
+  classDef cached fill:#c8e6c9
+  base0(["alpine:3.12"])
+  dependency0(["dependency api<br/>registry.example.com/backend:api"])
+  subgraph image0 ["artifact builder"]
+    image0_stage0["from<br/>0123456789ab<br/>cached (primary)"]:::cached
+    image0_stage1["install<br/>fedcba987654<br/>built"]:::built
+  end
+  subgraph image1 ["image app"]
+    image1_stage0["from<br/>aaaa<br/>cached (cache)"]:::cached
+  end
+  base0 -- from --> image0_stage0
+  image0_stage0 --> image0_stage1
+  dependency0 -- from --> image1_stage0
+  image0_stage1 -- import --> image1_stage0
+`
+
+	if mermaid := string(newTestBuildGraph().toMermaid()); mermaid != expected {
+		t.Fatalf("unexpected mermaid graph:\n%s", mermaid)
+	}
+}
+
+func TestBuildGraphGetStageNode(t *testing.T) {
+	graph := newTestBuildGraph()
+
+	if node, ok := graph.getStageNode("builder", ""); !ok || node.stageName != "install" {
+		t.Fatalf("expected the last stage node, got %#v", node)
+	}
+
+	if node, ok := graph.getStageNode("builder", "from"); !ok || node.stageName != "from" {
+		t.Fatalf("expected the from stage node, got %#v", node)
+	}
+
+	if node, ok := graph.getStageNode("builder", "setup"); ok {
+		t.Fatalf("expected no node for the not processed stage, got %#v", node)
+	}
+
+	if node, ok := graph.getStageNode("unknown", ""); ok {
+		t.Fatalf("expected no node for the unknown image, got %#v", node)
+	}
+}
+
+func TestBuildGraphGetDependencyByImage(t *testing.T) {
+	graph := newTestBuildGraph()
+
+	if dependency, ok := graph.getDependencyByImage("registry.example.com/backend:api"); !ok || dependency.name != "api" {
+		t.Fatalf("expected the api dependency, got %#v", dependency)
+	}
+
+	for _, image := range []string{"", "alpine:3.12"} {
+		if dependency, ok := graph.getDependencyByImage(image); ok {
+			t.Fatalf("expected no dependency for %q, got %#v", image, dependency)
+		}
+	}
+}
+
+func TestGetGraphFormatByPath(t *testing.T) {
+	for path, expected := range map[string]GraphFormat{
+		"graph.dot":         GraphDot,
+		"graph.GV":          GraphDot,
+		"dir/graph.mmd":     GraphMermaid,
+		"graph.mermaid":     GraphMermaid,
+		"graph.dot.mermaid": GraphMermaid,
+	} {
+		if format, err := GetGraphFormatByPath(path); err != nil {
+			t.Fatal(err)
+		} else if format != expected {
+			t.Fatalf("expected format %q for %q, got %q", expected, path, format)
+		}
+	}
+
+	for _, path := range []string{"graph", "graph.png", "dot"} {
+		if _, err := GetGraphFormatByPath(path); err == nil {
+			t.Fatalf("expected the error for %q", path)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package build
+
+import (
+	"time"
+
+	"github.com/werf/werf/pkg/build/stage"
+)
+
+const (
+	ReportStageCacheHit  = "hit"
+	ReportStageCacheMiss = "miss"
+
+	ReportStageStoragePrimary   = "primary"
+	ReportStageStorageSecondary = "secondary"
+	ReportStageStorageCache     = "cache"
+)
+
+// ReportStageRecord describes how the image stage has been processed during the build.
+// Durations are in seconds and are only defined for the stages built by the current build.
+type ReportStageRecord struct {
+	Name          string
+	Digest        string
+	CacheOutcome  string // hit or miss
+	Storage       string `json:",omitempty"` // primary, secondary or cache stages storage the stage came from
+	BuildDuration float64
+	PushDuration  float64
+	Size          int64
+	BuildLogPath  string `json:",omitempty"` // captured stage build log (--stage-logs-dir)
+
+	stg stage.Interface
+}
+
+func newReportStageRecord(stg stage.Interface, cacheOutcome, storage string, buildDuration, pushDuration time.Duration) ReportStageRecord {
+	record := ReportStageRecord{
+		Name:          string(stg.Name()),
+		Digest:        stg.GetDigest(),
+		CacheOutcome:  cacheOutcome,
+		Storage:       storage,
+		BuildDuration: buildDuration.Seconds(),
+		PushDuration:  pushDuration.Seconds(),
+		stg:           stg,
+	}
+
+	if desc := stg.GetImage().GetStageDescription(); desc != nil {
+		record.Size = desc.Info.Size
+	}
+
+	return record
+}
+
+// AddImageStageRecord adds the stage record of the image, the records are included into the image record of the report in the stages order.
+func (report *ImagesReport) AddImageStageRecord(name string, stageRecord ReportStageRecord) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	report.stages[name] = append(report.stages[name], stageRecord)
+}
+
+func (report *ImagesReport) getImageStageRecords(name string) []ReportStageRecord {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	return report.stages[name]
+}
@@ -0,0 +1,94 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+)
+
+type testReportStage struct {
+	stage.Interface
+
+	name  stage.StageName
+	image container_runtime.ImageInterface
+}
+
+func (s *testReportStage) Name() stage.StageName {
+	return s.name
+}
+
+func (s *testReportStage) GetDigest() string {
+	return s.image.GetStageDescription().StageID.Digest
+}
+
+func (s *testReportStage) GetImage() container_runtime.ImageInterface {
+	return s.image
+}
+
+func newTestReportStage(name stage.StageName, digest string, size int64) *testReportStage {
+	img := container_runtime.NewStageImage(nil, digest, nil)
+	img.SetStageDescription(&image.StageDescription{
+		StageID: &image.StageID{Digest: digest, UniqueID: 1611836746968},
+		Info:    &image.Info{Size: size},
+	})
+
+	return &testReportStage{name: name, image: img}
+}
+
+type testReportStorageManager struct {
+	manager.StorageManagerInterface
+
+	cacheStagesStorageOfFetchedStage map[string]storage.StagesStorage
+}
+
+func (m *testReportStorageManager) GetCacheStagesStorageOfFetchedStage(stg stage.Interface) storage.StagesStorage {
+	return m.cacheStagesStorageOfFetchedStage[stg.GetImage().GetStageDescription().StageID.String()]
+}
+
+func TestNewReportStageRecord(t *testing.T) {
+	stg := newTestReportStage(stage.Install, "digest", 1024)
+
+	record := newReportStageRecord(stg, ReportStageCacheMiss, "", 1500*time.Millisecond, 250*time.Millisecond)
+	record.stg = nil
+
+	expected := ReportStageRecord{Name: "install", Digest: "digest", CacheOutcome: "miss", BuildDuration: 1.5, PushDuration: 0.25, Size: 1024}
+	if !reflect.DeepEqual(record, expected) {
+		t.Fatalf("expected record %+v, got %+v", expected, record)
+	}
+}
+
+func TestBuildPhase_GetImageReportStages(t *testing.T) {
+	fromStage := newTestReportStage(stage.From, "from", 10)
+	installStage := newTestReportStage(stage.Install, "install", 20)
+	setupStage := newTestReportStage(stage.Setup, "setup", 30)
+
+	storageManager := &testReportStorageManager{cacheStagesStorageOfFetchedStage: map[string]storage.StagesStorage{
+		installStage.GetImage().GetStageDescription().StageID.String(): &storage.RepoStagesStorage{RepoAddress: "registry.example.com/cache"},
+	}}
+	phase := NewBuildPhase(&Conveyor{StorageManager: storageManager}, BuildPhaseOptions{})
+
+	img := &Image{name: "app"}
+	phase.ImagesReport.AddImageStageRecord(img.GetName(), newReportStageRecord(fromStage, ReportStageCacheHit, ReportStageStorageSecondary, 0, 0))
+	phase.ImagesReport.AddImageStageRecord(img.GetName(), newReportStageRecord(installStage, ReportStageCacheHit, ReportStageStoragePrimary, 0, 0))
+	phase.ImagesReport.AddImageStageRecord(img.GetName(), newReportStageRecord(setupStage, ReportStageCacheMiss, "", time.Second, time.Second))
+	phase.ImagesReport.AddImageStageRecord("other", newReportStageRecord(setupStage, ReportStageCacheHit, ReportStageStoragePrimary, 0, 0))
+
+	var names, storages []string
+	for _, record := range phase.getImageReportStages(img) {
+		names = append(names, record.Name)
+		storages = append(storages, record.Storage)
+	}
+
+	if expected := []string{"from", "install", "setup"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected stages %v in the stages order, got %v", expected, names)
+	}
+	if expected := []string{ReportStageStorageSecondary, ReportStageStorageCache, ""}; !reflect.DeepEqual(storages, expected) {
+		t.Fatalf("expected storages %v, got %v", expected, storages)
+	}
+}
@@ -0,0 +1,99 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ReportSupplyChainRecord describes the supply-chain state of the image: SBOM references, vulnerability scan summary and attestation digests.
+// All sections are optional and omitted from the json report when not defined.
+type ReportSupplyChainRecord struct {
+	SBOM              []ReportSBOMRecord             `json:",omitempty"`
+	VulnerabilityScan *ReportVulnerabilityScanRecord `json:",omitempty"`
+	Attestations      []ReportAttestationRecord      `json:",omitempty"`
+}
+
+type ReportSBOMRecord struct {
+	Format    string // e.g. spdx-json or cyclonedx-json
+	Reference string // path, url or image reference of the SBOM document
+	Digest    string `json:",omitempty"`
+}
+
+type ReportVulnerabilityScanRecord struct {
+	Scanner   string
+	Reference string `json:",omitempty"`
+	Critical  int
+	High      int
+	Medium    int
+	Low       int
+	Unknown   int
+}
+
+type ReportAttestationRecord struct {
+	PredicateType string
+	Digest        string
+	Reference     string `json:",omitempty"`
+}
+
+func (r *ReportSupplyChainRecord) validate() error {
+	for _, sbom := range r.SBOM {
+		if sbom.Format == "" || sbom.Reference == "" {
+			return fmt.Errorf("SBOM Format and Reference fields required")
+		}
+	}
+
+	if r.VulnerabilityScan != nil && r.VulnerabilityScan.Scanner == "" {
+		return fmt.Errorf("VulnerabilityScan Scanner field required")
+	}
+
+	for _, attestation := range r.Attestations {
+		if attestation.PredicateType == "" || attestation.Digest == "" {
+			return fmt.Errorf("Attestations PredicateType and Digest fields required")
+		}
+	}
+
+	return nil
+}
+
+// merge appends the sections of the other record, the vulnerability scan summary of the other record takes priority.
+func (r *ReportSupplyChainRecord) merge(other ReportSupplyChainRecord) {
+	r.SBOM = append(r.SBOM, other.SBOM...)
+	if other.VulnerabilityScan != nil {
+		r.VulnerabilityScan = other.VulnerabilityScan
+	}
+	r.Attestations = append(r.Attestations, other.Attestations...)
+}
+
+// LoadReportSupplyChainData reads supply-chain data of the images from the json file:
+//
+//	{
+//	  "Images": {
+//	    "<WERF_IMAGE_NAME>": {
+//	      "SBOM": [{"Format": "spdx-json", "Reference": "<PATH_OR_URL>", "Digest": "<SHA256>"}],
+//	      "VulnerabilityScan": {"Scanner": "trivy", "Critical": 0, "High": 1, "Medium": 5, "Low": 10, "Unknown": 0},
+//	      "Attestations": [{"PredicateType": "https://slsa.dev/provenance/v0.2", "Digest": "<SHA256>"}]
+//	    }
+//	  }
+//	}
+func LoadReportSupplyChainData(path string) (map[string]ReportSupplyChainRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", path, err)
+	}
+
+	var supplyChainData struct {
+		Images map[string]ReportSupplyChainRecord
+	}
+	if err := json.Unmarshal(data, &supplyChainData); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal json %s: %s", path, err)
+	}
+
+	for imageName, record := range supplyChainData.Images {
+		if err := record.validate(); err != nil {
+			return nil, fmt.Errorf("invalid supply-chain data of image %q in %s: %s", imageName, path, err)
+		}
+	}
+
+	return supplyChainData.Images, nil
+}
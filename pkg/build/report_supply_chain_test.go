@@ -0,0 +1,136 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/werf/logboek"
+)
+
+func writeTestSupplyChainData(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "supply-chain.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadReportSupplyChainData(t *testing.T) {
+	supplyChainData, err := LoadReportSupplyChainData(writeTestSupplyChainData(t, `{
+  "Images": {
+    "backend": {
+      "SBOM": [{"Format": "spdx-json", "Reference": "sbom/backend.spdx.json", "Digest": "sha256:sbom"}],
+      "VulnerabilityScan": {"Scanner": "trivy", "Critical": 0, "High": 1, "Medium": 5, "Low": 10, "Unknown": 0},
+      "Attestations": [{"PredicateType": "https://slsa.dev/provenance/v0.2", "Digest": "sha256:provenance"}]
+    },
+    "frontend": {
+      "VulnerabilityScan": {"Scanner": "grype"}
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ReportSupplyChainRecord{
+		"backend": {
+			SBOM:              []ReportSBOMRecord{{Format: "spdx-json", Reference: "sbom/backend.spdx.json", Digest: "sha256:sbom"}},
+			VulnerabilityScan: &ReportVulnerabilityScanRecord{Scanner: "trivy", High: 1, Medium: 5, Low: 10},
+			Attestations:      []ReportAttestationRecord{{PredicateType: "https://slsa.dev/provenance/v0.2", Digest: "sha256:provenance"}},
+		},
+		"frontend": {
+			VulnerabilityScan: &ReportVulnerabilityScanRecord{Scanner: "grype"},
+		},
+	}
+	if !reflect.DeepEqual(supplyChainData, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, supplyChainData)
+	}
+}
+
+func TestLoadReportSupplyChainData_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		data, expectedErr string
+	}{
+		{`{"Images": `, "unable to unmarshal json"},
+		{`{"Images": {"app": {"SBOM": [{"Format": "spdx-json"}]}}}`, `invalid supply-chain data of image "app"`},
+		{`{"Images": {"app": {"SBOM": [{"Reference": "sbom.json"}]}}}`, "SBOM Format and Reference fields required"},
+		{`{"Images": {"app": {"VulnerabilityScan": {"High": 1}}}}`, "VulnerabilityScan Scanner field required"},
+		{`{"Images": {"app": {"Attestations": [{"PredicateType": "https://slsa.dev/provenance/v0.2"}]}}}`, "Attestations PredicateType and Digest fields required"},
+	} {
+		if _, err := LoadReportSupplyChainData(writeTestSupplyChainData(t, tc.data)); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%s: expected error %q, got %v", tc.data, tc.expectedErr, err)
+		}
+	}
+
+	if _, err := LoadReportSupplyChainData(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "unable to read") {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+func TestImagesReport_AddImageSupplyChainRecord(t *testing.T) {
+	report := &ImagesReport{Images: make(map[string]ReportImageRecord), supplyChain: make(map[string]*ReportSupplyChainRecord)}
+
+	// the supply-chain data added before the image record is built
+	report.AddImageSupplyChainRecord("backend", ReportSupplyChainRecord{
+		SBOM:              []ReportSBOMRecord{{Format: "spdx-json", Reference: "sbom/backend.spdx.json"}},
+		VulnerabilityScan: &ReportVulnerabilityScanRecord{Scanner: "trivy", High: 2},
+	})
+	report.SetImageRecord("backend", ReportImageRecord{DockerImageName: "registry.example.com/app:backend"})
+	report.SetImageRecord("frontend", ReportImageRecord{DockerImageName: "registry.example.com/app:frontend"})
+
+	// the supply-chain data added after the image record is built, the last scan summary takes priority
+	report.AddImageSupplyChainRecord("backend", ReportSupplyChainRecord{
+		VulnerabilityScan: &ReportVulnerabilityScanRecord{Scanner: "trivy", High: 1},
+		Attestations:      []ReportAttestationRecord{{PredicateType: "https://slsa.dev/provenance/v0.2", Digest: "sha256:provenance"}},
+	})
+
+	expected := ReportSupplyChainRecord{
+		SBOM:              []ReportSBOMRecord{{Format: "spdx-json", Reference: "sbom/backend.spdx.json"}},
+		VulnerabilityScan: &ReportVulnerabilityScanRecord{Scanner: "trivy", High: 1},
+		Attestations:      []ReportAttestationRecord{{PredicateType: "https://slsa.dev/provenance/v0.2", Digest: "sha256:provenance"}},
+	}
+	if record := report.Images["backend"].ReportSupplyChainRecord; !reflect.DeepEqual(record, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, record)
+	}
+
+	data, err := report.ToJsonData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonReport struct {
+		Images map[string]map[string]interface{}
+	}
+	if err := json.Unmarshal(data, &jsonReport); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"SBOM", "VulnerabilityScan", "Attestations"} {
+		if _, ok := jsonReport.Images["backend"][field]; !ok {
+			t.Errorf("expected %s section of the backend image in the json report", field)
+		}
+		if _, ok := jsonReport.Images["frontend"][field]; ok {
+			t.Errorf("expected no %s section of the frontend image in the json report", field)
+		}
+	}
+}
+
+func TestBuildPhase_CreateReport_SupplyChainImageNotFound(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+	c := &Conveyor{images: []*Image{{name: "backend"}, {name: "builder", isArtifact: true}}}
+
+	for _, imageName := range []string{"frontend", "builder"} {
+		path := writeTestSupplyChainData(t, fmt.Sprintf(`{"Images": {%q: {"VulnerabilityScan": {"Scanner": "trivy"}}}}`, imageName))
+		phase := NewBuildPhase(c, BuildPhaseOptions{BuildOptions: BuildOptions{ReportSupplyChainPath: path}})
+
+		if err := phase.createReport(ctx); err == nil || !strings.Contains(err.Error(), fmt.Sprintf("image %q from supply-chain data %s not found", imageName, path)) {
+			t.Errorf("%s: expected the image not found error, got %v", imageName, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/scan"
+)
+
+type ScanOptions struct {
+	// Scanner is not set when the scan is disabled
+	Scanner               scan.Scanner
+	ScanSeverityThreshold scan.Severity
+}
+
+// scanImages scans the final images of the report and adds the vulnerability scan summaries into the report.
+// The images with the vulnerabilities at or above the severity threshold are returned.
+func (phase *BuildPhase) scanImages(ctx context.Context) ([]string, error) {
+	var imageNames []string
+	for name := range phase.ImagesReport.Images {
+		imageNames = append(imageNames, name)
+	}
+	sort.Strings(imageNames)
+
+	var failedImages []string
+	if err := logboek.Context(ctx).Default().LogProcess("Scanning images with %s", phase.Scanner.Name()).DoError(func() error {
+		for _, name := range imageNames {
+			record := phase.ImagesReport.Images[name]
+
+			result, err := phase.Scanner.Scan(ctx, record.DockerImageName)
+			if err != nil {
+				return fmt.Errorf("unable to scan image %s: %s", record.DockerImageName, err)
+			}
+
+			phase.ImagesReport.AddImageSupplyChainRecord(name, ReportSupplyChainRecord{
+				VulnerabilityScan: &ReportVulnerabilityScanRecord{
+					Scanner:   result.Scanner,
+					Reference: record.DockerImageName,
+					Critical:  result.Count(scan.SeverityCritical),
+					High:      result.Count(scan.SeverityHigh),
+					Medium:    result.Count(scan.SeverityMedium),
+					Low:       result.Count(scan.SeverityLow),
+					Unknown:   result.Count(scan.SeverityUnknown),
+				},
+			})
+
+			count := result.CountAtOrAbove(phase.ScanSeverityThreshold)
+			logboek.Context(ctx).Default().LogF("%s: %d vulnerabilities, %d at or above %s\n", record.DockerImageName, len(result.Vulnerabilities), count, phase.ScanSeverityThreshold)
+			if count > 0 {
+				failedImages = append(failedImages, fmt.Sprintf("%s (%d)", name, count))
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return failedImages, nil
+}
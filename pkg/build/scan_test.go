@@ -0,0 +1,81 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/scan"
+)
+
+type testScanner struct {
+	results map[string]*scan.Result
+}
+
+func (s *testScanner) Name() string {
+	return "test"
+}
+
+func (s *testScanner) Scan(_ context.Context, imageRef string) (*scan.Result, error) {
+	if res, hasKey := s.results[imageRef]; hasKey {
+		return res, nil
+	}
+	return nil, errors.New("image not found")
+}
+
+func newTestScanBuildPhase(scanner scan.Scanner, images map[string]ReportImageRecord) *BuildPhase {
+	phase := NewBuildPhase(nil, BuildPhaseOptions{BuildOptions: BuildOptions{ScanOptions: ScanOptions{Scanner: scanner, ScanSeverityThreshold: scan.SeverityHigh}}})
+	for name, record := range images {
+		phase.ImagesReport.SetImageRecord(name, record)
+	}
+	return phase
+}
+
+func TestBuildPhase_ScanImages(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+	scanner := &testScanner{results: map[string]*scan.Result{
+		"registry/app:backend": {Scanner: "test", Vulnerabilities: []scan.Vulnerability{
+			{ID: "1", Severity: scan.SeverityCritical},
+			{ID: "2", Severity: scan.SeverityHigh},
+			{ID: "3", Severity: scan.SeverityLow},
+		}},
+		"registry/app:frontend": {Scanner: "test", Vulnerabilities: []scan.Vulnerability{
+			{ID: "4", Severity: scan.SeverityMedium},
+		}},
+	}}
+	phase := newTestScanBuildPhase(scanner, map[string]ReportImageRecord{
+		"backend":  {DockerImageName: "registry/app:backend"},
+		"frontend": {DockerImageName: "registry/app:frontend"},
+	})
+
+	failedImages, err := phase.scanImages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"backend (2)"}; !reflect.DeepEqual(failedImages, expected) {
+		t.Fatalf("expected failed images %v, got %v", expected, failedImages)
+	}
+
+	expectedScan := &ReportVulnerabilityScanRecord{Scanner: "test", Reference: "registry/app:backend", Critical: 1, High: 1, Low: 1}
+	if scanRecord := phase.ImagesReport.Images["backend"].VulnerabilityScan; !reflect.DeepEqual(scanRecord, expectedScan) {
+		t.Fatalf("expected the scan record %+v, got %+v", expectedScan, scanRecord)
+	}
+
+	expectedScan = &ReportVulnerabilityScanRecord{Scanner: "test", Reference: "registry/app:frontend", Medium: 1}
+	if scanRecord := phase.ImagesReport.Images["frontend"].VulnerabilityScan; !reflect.DeepEqual(scanRecord, expectedScan) {
+		t.Fatalf("expected the scan record %+v, got %+v", expectedScan, scanRecord)
+	}
+}
+
+func TestBuildPhase_ScanImages_ScannerError(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+	phase := newTestScanBuildPhase(&testScanner{}, map[string]ReportImageRecord{"app": {DockerImageName: "registry/app:app"}})
+	if _, err := phase.scanImages(ctx); err == nil {
+		t.Fatal("expected the scanner error")
+	}
+}
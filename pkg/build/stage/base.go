@@ -66,6 +66,7 @@ type NewBaseStageOptions struct {
 	ImageTmpDir      string
 	ContainerWerfDir string
 	ProjectName      string
+	ScriptsData      map[string][]byte
 }
 
 func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
@@ -89,6 +90,7 @@ type BaseStage struct {
 	imageTmpDir      string
 	containerWerfDir string
 	configMounts     []*config.Mount
+	mountVolumes     []string
 	projectName      string
 }
 
@@ -245,6 +247,16 @@ func (s *BaseStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage
 		return fmt.Errorf("error adding mounts volumes: %s", err)
 	}
 
+	tmpfsMounts := s.getTmpfsMounts(prevBuiltImage)
+	s.addTmpfsMountsLabels(tmpfsMounts, image)
+	s.addTmpfsMountsRunOptions(tmpfsMounts, image)
+
+	volumeMounts := s.getVolumeMounts(prevBuiltImage)
+	s.addVolumeMountLabels(volumeMounts, image)
+	if err := s.addVolumeMountVolumes(volumeMounts, image); err != nil {
+		return fmt.Errorf("error adding mounts volumes: %s", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,196 @@
+package stage
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// GetMountVolumes returns the sorted names of the project volumes mounted into the stage container.
+// The volumes are shared by all images of the project, so the build phase holds the host locks of the volumes while the stage is being built.
+func (s *BaseStage) GetMountVolumes() []string {
+	return s.mountVolumes
+}
+
+// GetMountVolumesDir returns the host directory of the volumes of all projects.
+func GetMountVolumesDir() string {
+	return filepath.Join(werf.GetSharedContextDir(), "mounts", "volumes")
+}
+
+// GetMountVolumeDir returns the host directory of the volume shared by all images of the project.
+// The modification time of the directory is updated on each use, so the host cleanup removes only the volumes not used for a long time.
+func GetMountVolumeDir(projectName, name string) string {
+	return filepath.Join(GetMountVolumesDir(), projectName, name)
+}
+
+// MountVolumeLockName returns the name of the host lock held while the volume is used by the stage build or removed by the host cleanup.
+func MountVolumeLockName(projectName, name string) string {
+	return fmt.Sprintf("mount_volume.%s.%s", projectName, name)
+}
+
+func (s *BaseStage) getTmpfsMounts(prevBuiltImage container_runtime.ImageInterface) map[string]string {
+	sizeByMountpoint := s.getTmpfsMountsFromLabels(prevBuiltImage)
+	for mountpoint, size := range s.getTmpfsMountsFromConfig() {
+		sizeByMountpoint[mountpoint] = size
+	}
+
+	return sizeByMountpoint
+}
+
+func (s *BaseStage) getTmpfsMountsFromLabels(prevBuiltImage container_runtime.ImageInterface) map[string]string {
+	var labels map[string]string
+	if prevBuiltImage != nil {
+		labels = prevBuiltImage.GetStageDescription().Info.Labels
+	}
+
+	return parseTmpfsMountsLabel(labels[imagePkg.WerfMountTmpfsLabel])
+}
+
+// parseTmpfsMountsLabel parses the label value in the format MOUNTPOINT[:SIZE];...
+func parseTmpfsMountsLabel(value string) map[string]string {
+	sizeByMountpoint := map[string]string{}
+
+	for _, mount := range util.RejectEmptyStrings(strings.Split(value, ";")) {
+		parts := strings.SplitN(mount, ":", 2)
+		if len(parts) == 2 {
+			sizeByMountpoint[parts[0]] = parts[1]
+		} else {
+			sizeByMountpoint[parts[0]] = ""
+		}
+	}
+
+	return sizeByMountpoint
+}
+
+func (s *BaseStage) getTmpfsMountsFromConfig() map[string]string {
+	sizeByMountpoint := map[string]string{}
+	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type != "tmpfs" {
+			continue
+		}
+
+		sizeByMountpoint[path.Clean(mountCfg.To)] = mountCfg.Size
+	}
+
+	return sizeByMountpoint
+}
+
+func (s *BaseStage) addTmpfsMountsRunOptions(sizeByMountpoint map[string]string, image container_runtime.ImageInterface) {
+	for mountpoint, size := range sizeByMountpoint {
+		absoluteMountpoint := path.Join("/", mountpoint)
+
+		if size != "" {
+			image.Container().RunOptions().AddTmpfs(fmt.Sprintf("%s:size=%s", absoluteMountpoint, size))
+		} else {
+			image.Container().RunOptions().AddTmpfs(absoluteMountpoint)
+		}
+	}
+}
+
+func (s *BaseStage) addTmpfsMountsLabels(sizeByMountpoint map[string]string, image container_runtime.ImageInterface) {
+	if len(sizeByMountpoint) == 0 {
+		return
+	}
+
+	image.Container().ServiceCommitChangeOptions().AddLabel(map[string]string{imagePkg.WerfMountTmpfsLabel: formatTmpfsMountsLabel(sizeByMountpoint)})
+}
+
+func formatTmpfsMountsLabel(sizeByMountpoint map[string]string) string {
+	var mounts []string
+	for mountpoint, size := range sizeByMountpoint {
+		if size != "" {
+			mounts = append(mounts, fmt.Sprintf("%s:%s", mountpoint, size))
+		} else {
+			mounts = append(mounts, mountpoint)
+		}
+	}
+	sort.Strings(mounts)
+
+	return strings.Join(mounts, ";")
+}
+
+func (s *BaseStage) getVolumeMounts(prevBuiltImage container_runtime.ImageInterface) map[string][]string {
+	return mergeMounts(s.getVolumeMountsFromLabels(prevBuiltImage), s.getVolumeMountsFromConfig())
+}
+
+func (s *BaseStage) getVolumeMountsFromLabels(prevBuiltImage container_runtime.ImageInterface) map[string][]string {
+	var labels map[string]string
+	if prevBuiltImage != nil {
+		labels = prevBuiltImage.GetStageDescription().Info.Labels
+	}
+
+	return parseVolumeMountsLabels(labels)
+}
+
+// parseVolumeMountsLabels parses the labels WERF_MOUNT_VOLUME_LABEL_PREFIX+NAME with the values in the format MOUNTPOINT;...
+func parseVolumeMountsLabels(labels map[string]string) map[string][]string {
+	mountpointsByName := map[string][]string{}
+
+	for k, v := range labels {
+		if !strings.HasPrefix(k, imagePkg.WerfMountVolumeLabelPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(k, imagePkg.WerfMountVolumeLabelPrefix)
+		mountpointsByName[name] = util.RejectEmptyStrings(util.UniqStrings(strings.Split(v, ";")))
+	}
+
+	return mountpointsByName
+}
+
+func (s *BaseStage) getVolumeMountsFromConfig() map[string][]string {
+	mountpointsByName := map[string][]string{}
+	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type != "volume" {
+			continue
+		}
+
+		mountpointsByName[mountCfg.Name] = util.UniqAppendString(mountpointsByName[mountCfg.Name], path.Clean(mountCfg.To))
+	}
+
+	return mountpointsByName
+}
+
+func (s *BaseStage) addVolumeMountVolumes(mountpointsByName map[string][]string, image container_runtime.ImageInterface) error {
+	s.mountVolumes = nil
+
+	for name, mountpoints := range mountpointsByName {
+		absoluteFrom := GetMountVolumeDir(s.projectName, name)
+		if err := os.MkdirAll(absoluteFrom, os.ModePerm); err != nil {
+			return fmt.Errorf("error creating volume path %s for mount: %s", absoluteFrom, err)
+		}
+
+		now := time.Now()
+		if err := os.Chtimes(absoluteFrom, now, now); err != nil {
+			return fmt.Errorf("error updating volume path %s modification time: %s", absoluteFrom, err)
+		}
+
+		for _, mountpoint := range mountpoints {
+			absoluteMountpoint := path.Join("/", mountpoint)
+			image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s", absoluteFrom, absoluteMountpoint))
+		}
+
+		s.mountVolumes = append(s.mountVolumes, name)
+	}
+
+	sort.Strings(s.mountVolumes)
+
+	return nil
+}
+
+func (s *BaseStage) addVolumeMountLabels(mountpointsByName map[string][]string, image container_runtime.ImageInterface) {
+	for name, mountpoints := range mountpointsByName {
+		labelName := fmt.Sprintf("%s%s", imagePkg.WerfMountVolumeLabelPrefix, name)
+		labelValue := strings.Join(mountpoints, ";")
+		image.Container().ServiceCommitChangeOptions().AddLabel(map[string]string{labelName: labelValue})
+	}
+}
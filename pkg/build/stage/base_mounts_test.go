@@ -0,0 +1,82 @@
+package stage
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+	imagePkg "github.com/werf/werf/pkg/image"
+)
+
+func TestTmpfsMountsLabel(t *testing.T) {
+	sizeByMountpoint := map[string]string{
+		"/tmp":       "",
+		"/var/cache": "100m",
+	}
+
+	value := formatTmpfsMountsLabel(sizeByMountpoint)
+	if value != "/tmp;/var/cache:100m" {
+		t.Fatalf("unexpected label value %q", value)
+	}
+
+	if parsed := parseTmpfsMountsLabel(value); !reflect.DeepEqual(parsed, sizeByMountpoint) {
+		t.Fatalf("expected %v, got %v", sizeByMountpoint, parsed)
+	}
+
+	if parsed := parseTmpfsMountsLabel(""); len(parsed) != 0 {
+		t.Fatalf("expected no mounts for the empty label, got %v", parsed)
+	}
+}
+
+func TestParseVolumeMountsLabels(t *testing.T) {
+	labels := map[string]string{
+		imagePkg.WerfMountVolumeLabelPrefix + "cache": "/root/.cache;;/root/.cache;/root/.m2",
+		"werf-other-label": "/ignored",
+	}
+
+	expected := map[string][]string{"cache": {"/root/.cache", "/root/.m2"}}
+	if parsed := parseVolumeMountsLabels(labels); !reflect.DeepEqual(parsed, expected) {
+		t.Fatalf("expected %v, got %v", expected, parsed)
+	}
+}
+
+func TestMountsFromConfig(t *testing.T) {
+	s := &BaseStage{configMounts: []*config.Mount{
+		{Type: "tmpfs", To: "/tmp/"},
+		{Type: "tmpfs", To: "/var/cache", Size: "100m"},
+		{Type: "volume", Name: "cache", To: "/root/.cache/"},
+		{Type: "volume", Name: "cache", To: "/root/.cache"},
+		{Type: "volume", Name: "m2", To: "/root/.m2"},
+		{Type: "bind", From: "/src", To: "/app"},
+	}}
+
+	expectedTmpfs := map[string]string{"/tmp": "", "/var/cache": "100m"}
+	if tmpfs := s.getTmpfsMountsFromConfig(); !reflect.DeepEqual(tmpfs, expectedTmpfs) {
+		t.Fatalf("expected %v, got %v", expectedTmpfs, tmpfs)
+	}
+
+	expectedVolumes := map[string][]string{"cache": {"/root/.cache"}, "m2": {"/root/.m2"}}
+	if volumes := s.getVolumeMountsFromConfig(); !reflect.DeepEqual(volumes, expectedVolumes) {
+		t.Fatalf("expected %v, got %v", expectedVolumes, volumes)
+	}
+}
+
+func TestMergeMounts(t *testing.T) {
+	a := map[string][]string{"cache": {"/root/.cache"}, "m2": {"/root/.m2"}}
+	b := map[string][]string{"cache": {"/root/.cache", "/var/cache"}, "npm": {"/root/.npm"}}
+
+	merged := mergeMounts(a, b)
+	for _, mountpoints := range merged {
+		sort.Strings(mountpoints)
+	}
+
+	expected := map[string][]string{
+		"cache": {"/root/.cache", "/var/cache"},
+		"m2":    {"/root/.m2"},
+		"npm":   {"/root/.npm"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("expected %v, got %v", expected, merged)
+	}
+}
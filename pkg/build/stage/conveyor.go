@@ -23,6 +23,7 @@ type Conveyor interface {
 	GetImageIDForImageStage(imageName, stageName string) string
 
 	GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error)
+	GetImportServerForExternalImage(ctx context.Context, reference string) (import_server.ImportServer, error)
 	GetLocalGitRepoVirtualMergeOptions() VirtualMergeOptions
 
 	GiterminismManager() giterminism_manager.Interface
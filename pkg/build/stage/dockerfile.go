@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -19,11 +20,13 @@ import (
 
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/context_manager"
+	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_manager"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/remote_builder"
 	"github.com/werf/werf/pkg/util"
 )
 
@@ -46,9 +49,12 @@ type DockerfileStage struct {
 	*DockerStages
 	*ContextChecksum
 	*BaseStage
+
+	isCacheStage    bool
+	cacheFromStages []*DockerfileStage
 }
 
-func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFiles []string, buildArgs map[string]interface{}, addHost []string, network, ssh string) *DockerRunArgs {
+func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFiles []string, platformIndependentContextChecksum bool, buildArgs map[string]interface{}, addHost []string, network, ssh, platform string, secrets []*DockerfileSecret) *DockerRunArgs {
 	return &DockerRunArgs{
 		dockerfilePath:  dockerfilePath,
 		target:          target,
@@ -58,6 +64,10 @@ func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFiles []
 		addHost:         addHost,
 		network:         network,
 		ssh:             ssh,
+		platform:        platform,
+		secrets:         secrets,
+
+		platformIndependentContextChecksum: platformIndependentContextChecksum,
 	}
 }
 
@@ -70,6 +80,10 @@ type DockerRunArgs struct {
 	addHost         []string
 	network         string
 	ssh             string
+	platform        string
+	secrets         []*DockerfileSecret
+
+	platformIndependentContextChecksum bool
 }
 
 func (d *DockerRunArgs) contextRelativeToGitWorkTree(giterminismManager giterminism_manager.Interface) string {
@@ -88,22 +102,28 @@ func (d *DockerRunArgs) contextAddFilesRelativeToGitWorkTree(giterminismManager
 type DockerStages struct {
 	dockerStages           []instructions.Stage
 	dockerTargetStageIndex int
+	dockerEscapeToken      rune
 	dockerBuildArgsHash    map[string]string
 	dockerMetaArgsHash     map[string]string
 	dockerStageArgsHash    map[int]map[string]string
 	dockerStageEnvs        map[int]map[string]string
 
 	imageOnBuildInstructions map[string][]string
+	baseImagesReferences     map[string]string
+	baseImagesOS             map[string]string
 }
 
-func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[string]string, dockerMetaArgs []instructions.ArgCommand, dockerTargetStageIndex int) (*DockerStages, error) {
+func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[string]string, dockerMetaArgs []instructions.ArgCommand, dockerTargetStageIndex int, dockerEscapeToken rune) (*DockerStages, error) {
 	ds := &DockerStages{
 		dockerStages:             dockerStages,
 		dockerTargetStageIndex:   dockerTargetStageIndex,
+		dockerEscapeToken:        dockerEscapeToken,
 		dockerBuildArgsHash:      dockerBuildArgsHash,
 		dockerStageArgsHash:      map[int]map[string]string{},
 		dockerStageEnvs:          map[int]map[string]string{},
 		imageOnBuildInstructions: map[string][]string{},
+		baseImagesReferences:     map[string]string{},
+		baseImagesOS:             map[string]string{},
 	}
 
 	ds.dockerMetaArgsHash = map[string]string{}
@@ -189,15 +209,15 @@ func (ds *DockerStages) AddDockerStageEnv(dockerStageID int, key, value string)
 }
 
 func (ds *DockerStages) ShlexProcessWordWithMetaArgs(value string) (string, error) {
-	return shlexProcessWord(value, toArgsArray(ds.dockerMetaArgsHash))
+	return shlexProcessWord(value, toArgsArray(ds.dockerMetaArgsHash), ds.dockerEscapeToken)
 }
 
 func (ds *DockerStages) ShlexProcessWordWithStageArgsAndEnvs(dockerStageID int, value string) (string, error) {
-	return shlexProcessWord(value, toArgsArray(ds.DockerStageArgsHash(dockerStageID), ds.DockerStageEnvs(dockerStageID)))
+	return shlexProcessWord(value, toArgsArray(ds.DockerStageArgsHash(dockerStageID), ds.DockerStageEnvs(dockerStageID)), ds.dockerEscapeToken)
 }
 
 func (ds *DockerStages) ShlexProcessWordWithStageEnvs(dockerStageID int, value string) (string, error) {
-	return shlexProcessWord(value, toArgsArray(ds.DockerStageEnvs(dockerStageID)))
+	return shlexProcessWord(value, toArgsArray(ds.DockerStageEnvs(dockerStageID)), ds.dockerEscapeToken)
 }
 
 func (ds *DockerStages) DockerStageArgsHash(dockerStageID int) map[string]string {
@@ -238,8 +258,9 @@ func toArgsArray(argsHashes ...map[string]string) []string {
 	return argsArray
 }
 
-func shlexProcessWord(value string, argsArray []string) (string, error) {
-	shlex := shell.NewLex(parser.DefaultEscapeToken)
+// shlexProcessWord processes the word with the escape token of the Dockerfile (Windows Dockerfiles usually use the backtick to keep the backslashes in paths).
+func shlexProcessWord(value string, argsArray []string, escapeToken rune) (string, error) {
+	shlex := shell.NewLex(escapeToken)
 	resolvedValue, err := shlex.ProcessWord(value, argsArray)
 	if err != nil {
 		return "", err
@@ -248,14 +269,16 @@ func shlexProcessWord(value string, argsArray []string) (string, error) {
 	return resolvedValue, nil
 }
 
-func NewContextChecksum(dockerignorePathMatcher path_matcher.PathMatcher) *ContextChecksum {
+func NewContextChecksum(dockerignorePathMatcher path_matcher.PathMatcher, contextCache *context_manager.Cache) *ContextChecksum {
 	return &ContextChecksum{
 		dockerignorePathMatcher: dockerignorePathMatcher,
+		contextCache:            contextCache,
 	}
 }
 
 type ContextChecksum struct {
 	dockerignorePathMatcher path_matcher.PathMatcher
+	contextCache            *context_manager.Cache
 }
 
 type dockerfileInstructionInterface interface {
@@ -288,8 +311,8 @@ outerLoop:
 			continue
 		}
 
-		getBaseImageOnBuildLocally := func() ([]string, error) {
-			inspect, err := containerRuntime.GetImageInspect(ctx, resolvedBaseName)
+		getBaseImageOnBuildLocally := func(localRef string) ([]string, error) {
+			inspect, err := containerRuntime.GetImageInspect(ctx, localRef)
 			if err != nil {
 				return nil, err
 			}
@@ -297,35 +320,57 @@ outerLoop:
 			if inspect == nil {
 				return nil, imageNotExistLocally
 			}
+			s.baseImagesOS[resolvedBaseName] = inspect.Os
+
+			// the image which is built locally and never pushed or pulled does not have the repo digest
+			if reference := selectRepoDigest(resolvedBaseName, inspect.RepoDigests); reference != "" {
+				s.baseImagesReferences[resolvedBaseName] = reference
+			}
 
 			return inspect.Config.OnBuild, nil
 		}
 
 		getBaseImageOnBuildRemotely := func() ([]string, error) {
+			repoImage, err := docker_registry.API().GetRepoImage(ctx, resolvedBaseName)
+			if err != nil {
+				return nil, fmt.Errorf("get repo image %s failed: %s", resolvedBaseName, err)
+			}
+			s.baseImagesReferences[resolvedBaseName] = fmt.Sprintf("%s@%s", repoImage.Repository, repoImage.RepoDigest)
+
 			configFile, err := docker_registry.API().GetRepoImageConfigFile(ctx, resolvedBaseName)
 			if err != nil {
 				return nil, fmt.Errorf("get repo image %s config file failed: %s", resolvedBaseName, err)
 			}
+			s.baseImagesOS[resolvedBaseName] = configFile.OS
 
 			return configFile.Config.OnBuild, nil
 		}
 
 		var onBuild []string
-		if onBuild, err = getBaseImageOnBuildLocally(); err != nil && err != imageNotExistLocally {
+		if remote_builder.IsEnabled() || !docker.IsEnabled() {
+			// there is no local docker server in the remote builder mode and when images are not built
+			onBuild, err = getBaseImageOnBuildRemotely()
+		} else {
+			onBuild, err = getBaseImageOnBuildLocally(resolvedBaseName)
+		}
+
+		if err != nil && err != imageNotExistLocally {
 			return err
 		} else if err == imageNotExistLocally {
 			var getRemotelyErr error
 			if onBuild, getRemotelyErr = getBaseImageOnBuildRemotely(); getRemotelyErr != nil {
-				if isUnsupportedMediaTypeError(getRemotelyErr) {
+				if isUnsupportedMediaTypeError(getRemotelyErr) && docker.IsEnabled() {
 					logboek.Context(ctx).Warn().LogF("WARNING: Could not get base image manifest from local docker and from docker registry: %s\n", getRemotelyErr)
 					logboek.Context(ctx).Warn().LogLn("WARNING: The base image pulling is necessary for calculating digest of image correctly\n")
+					var localRef string
 					if err := logboek.Context(ctx).Default().LogProcess("Pulling base image %s", resolvedBaseName).DoError(func() error {
-						return containerRuntime.PullImage(ctx, resolvedBaseName)
+						localRef, err = containerRuntime.PullImage(ctx, resolvedBaseName)
+						return err
 					}); err != nil {
 						return err
 					}
 
-					if onBuild, err = getBaseImageOnBuildLocally(); err != nil {
+					if onBuild, err = getBaseImageOnBuildLocally(localRef); err != nil {
 						return err
 					}
 				} else {
@@ -340,12 +385,87 @@ outerLoop:
 	return nil
 }
 
+// BaseImagesReferences returns the resolved REPO@DIGEST references of the external base images used in the Dockerfile.
+// The references are available after FetchDependencies.
+func (s *DockerfileStage) BaseImagesReferences() []string {
+	var references []string
+	for _, reference := range s.baseImagesReferences {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+
+	return references
+}
+
+// isWindowsDockerStage returns true if the docker stage is based on the Windows image (the stage inherits the OS of the external base image).
+// The OS of the external base images is available after FetchDependencies.
+func (ds *DockerStages) isWindowsDockerStage(dockerStageID int) (bool, error) {
+	stage := ds.dockerStages[dockerStageID]
+
+	// the stage can be based only on the previous stages, so the chain of the base stages is finite
+outerLoop:
+	for {
+		for relatedStageIndex := 0; relatedStageIndex < dockerStageID; relatedStageIndex++ {
+			if stage.BaseName == ds.dockerStages[relatedStageIndex].Name {
+				dockerStageID = relatedStageIndex
+				stage = ds.dockerStages[relatedStageIndex]
+				continue outerLoop
+			}
+		}
+
+		break
+	}
+
+	resolvedBaseName, err := ds.ShlexProcessWordWithMetaArgs(stage.BaseName)
+	if err != nil {
+		return false, err
+	}
+
+	return ds.baseImagesOS[resolvedBaseName] == windowsImageOS, nil
+}
+
+// normalizeWindowsPaths converts the backslash separated paths of the Windows docker stage instruction to the slash separated ones,
+// so that the equivalent instructions take part in the digest the same way.
+func (ds *DockerStages) normalizeWindowsPaths(dockerStageID int, value string) (string, error) {
+	isWindowsDockerStage, err := ds.isWindowsDockerStage(dockerStageID)
+	if err != nil {
+		return "", err
+	}
+
+	if !isWindowsDockerStage {
+		return value, nil
+	}
+
+	return strings.ReplaceAll(value, "\\", "/"), nil
+}
+
+func selectRepoDigest(imageName string, repoDigests []string) string {
+	repository, _ := image.ParseRepositoryAndTag(imageName)
+	for _, repoDigest := range repoDigests {
+		if strings.HasPrefix(repoDigest, repository+"@") {
+			return repoDigest
+		}
+	}
+
+	if len(repoDigests) != 0 {
+		return repoDigests[0]
+	}
+
+	return ""
+}
+
 func isUnsupportedMediaTypeError(err error) bool {
 	return strings.Contains(err.Error(), "unsupported MediaType")
 }
 
 var imageNotExistLocally = errors.New("IMAGE_NOT_EXIST_LOCALLY")
 
+const (
+	windowsImageOS = "windows"
+	// windowsImageDigestSalt separates the digests of the Windows images from the digests of the Linux images built from the similar Dockerfiles
+	windowsImageDigestSalt = "os=windows"
+)
+
 func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
 	var stagesDependencies [][]string
 	var stagesOnBuildDependencies [][]string
@@ -356,6 +476,10 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 
 		dependencies = append(dependencies, s.addHost...)
 
+		if s.platform != "" {
+			dependencies = append(dependencies, s.platform)
+		}
+
 		resolvedBaseName, err := s.ShlexProcessWordWithMetaArgs(stage.BaseName)
 		if err != nil {
 			return "", err
@@ -363,6 +487,15 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 
 		dependencies = append(dependencies, resolvedBaseName)
 
+		isWindowsDockerStage, err := s.isWindowsDockerStage(ind)
+		if err != nil {
+			return "", err
+		}
+
+		if isWindowsDockerStage {
+			dependencies = append(dependencies, windowsImageDigestSalt)
+		}
+
 		onBuildInstructions, ok := s.imageOnBuildInstructions[resolvedBaseName]
 		if ok {
 			for _, instruction := range onBuildInstructions {
@@ -538,27 +671,35 @@ func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context,
 			dependencies = append(dependencies, fmt.Sprintf("ENV %s=%s", resolvedKey, resolvedValue))
 		}
 	case *instructions.AddCommand:
-		dependencies = append(dependencies, c.String())
+		instruction, err := s.normalizeWindowsPaths(dockerStageID, c.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		dependencies = append(dependencies, instruction)
 
 		resolvedSources, err := resolveSourcesFunc(c.SourcesAndDest.Sources())
 		if err != nil {
 			return nil, nil, err
 		}
 
-		checksum, err := s.calculateFilesChecksum(ctx, giterminismManager, resolvedSources, c.String())
+		checksum, err := s.calculateFilesChecksum(ctx, giterminismManager, dockerStageID, resolvedSources, c.String())
 		if err != nil {
 			return nil, nil, err
 		}
 		dependencies = append(dependencies, checksum)
 	case *instructions.CopyCommand:
-		dependencies = append(dependencies, c.String())
+		instruction, err := s.normalizeWindowsPaths(dockerStageID, c.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		dependencies = append(dependencies, instruction)
 		if c.From == "" {
 			resolvedSources, err := resolveSourcesFunc(c.SourcesAndDest.Sources())
 			if err != nil {
 				return nil, nil, err
 			}
 
-			checksum, err := s.calculateFilesChecksum(ctx, giterminismManager, resolvedSources, c.String())
+			checksum, err := s.calculateFilesChecksum(ctx, giterminismManager, dockerStageID, resolvedSources, c.String())
 			if err != nil {
 				return nil, nil, err
 			}
@@ -578,6 +719,12 @@ func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context,
 			return nil, nil, err
 		}
 
+		if _, ok := c.(*instructions.WorkdirCommand); ok {
+			if resolvedValue, err = s.normalizeWindowsPaths(dockerStageID, resolvedValue); err != nil {
+				return nil, nil, err
+			}
+		}
+
 		dependencies = append(dependencies, resolvedValue)
 	default:
 		panic("runtime error")
@@ -617,9 +764,14 @@ func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, _, img c
 	}
 
 	img.DockerfileImageBuilder().AppendBuildArgs(s.DockerBuildArgs()...)
+	img.DockerfileImageBuilder().AppendBuildArgs(s.cacheBuildArgs()...)
 	img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=%s", image.WerfProjectRepoCommitLabel, c.GiterminismManager().HeadCommit()))
 	img.DockerfileImageBuilder().SetFilePathToStdin(archivePath)
 
+	if err := s.setupSecrets(ctx, c.GiterminismManager(), img.DockerfileImageBuilder()); err != nil {
+		return err
+	}
+
 	if c.GiterminismManager().Dev() {
 		img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=true", image.WerfDevLabel))
 	}
@@ -643,11 +795,31 @@ func (s *DockerfileStage) prepareContextArchive(ctx context.Context, giterminism
 		return "", fmt.Errorf("unable to create archive: %s", err)
 	}
 
-	archivePath := archive.GetFilePath()
+	var cacheMountsKey string
+	if s.hasCacheMounts() {
+		cacheMountsKey = s.dockerfilePath + ":" + s.cacheMountIDPrefix()
+	}
+
+	cacheKey := util.Sha256Hash(append([]string{"archive", archive.GetFilePath(), s.context, cacheMountsKey, fmt.Sprintf("%t", s.platformIndependentContextChecksum)}, s.contextAddFiles...)...)
+	archivePath, reused, err := s.contextCache.GetOrCreate(cacheKey, func() (string, error) {
+		return s.createContextArchive(ctx, giterminismManager, archive.GetFilePath())
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if reused {
+		logboek.Context(ctx).Info().LogF("Use build context archive %s prepared for another image with the same context\n", archivePath)
+	}
+
+	return archivePath, nil
+}
+
+func (s *DockerfileStage) createContextArchive(ctx context.Context, giterminismManager giterminism_manager.Interface, archivePath string) (string, error) {
 	if len(s.contextAddFiles) != 0 {
 		if err := logboek.Context(ctx).Debug().LogProcess("Add contextAddFiles to build context archive %s", archivePath).DoError(func() error {
 			var sourceArchivePath = archivePath
-			destinationArchivePath, err := context_manager.AddContextAddFilesToContextArchive(ctx, sourceArchivePath, giterminismManager.ProjectDir(), s.context, s.contextAddFiles)
+			destinationArchivePath, err := context_manager.AddContextAddFilesToContextArchive(ctx, sourceArchivePath, giterminismManager.ProjectDir(), s.context, s.contextAddFiles, s.platformIndependentContextChecksum)
 			if err != nil {
 				return err
 			}
@@ -659,7 +831,7 @@ func (s *DockerfileStage) prepareContextArchive(ctx context.Context, giterminism
 		}
 	}
 
-	return archivePath, nil
+	return s.addCacheMountsDockerfileToContextArchive(ctx, giterminismManager, archivePath)
 }
 
 func (s *DockerfileStage) DockerBuildArgs() []string {
@@ -687,18 +859,27 @@ func (s *DockerfileStage) DockerBuildArgs() []string {
 		result = append(result, fmt.Sprintf("--network=%s", s.network))
 	}
 
-	if s.ssh != "" {
-		result = append(result, fmt.Sprintf("--ssh=%s", s.ssh))
+	if s.platform != "" {
+		result = append(result, fmt.Sprintf("--platform=%s", s.platform))
 	}
 
 	return result
 }
 
-func (s *DockerfileStage) calculateFilesChecksum(ctx context.Context, giterminismManager giterminism_manager.Interface, wildcards []string, dockerfileLine string) (string, error) {
+func (s *DockerfileStage) calculateFilesChecksum(ctx context.Context, giterminismManager giterminism_manager.Interface, dockerStageID int, wildcards []string, dockerfileLine string) (string, error) {
 	var checksum string
 	var err error
 
-	normalizedWildcards := normalizeCopyAddSources(wildcards)
+	var slashWildcards []string
+	for _, wildcard := range wildcards {
+		slashWildcard, err := s.normalizeWindowsPaths(dockerStageID, wildcard)
+		if err != nil {
+			return "", err
+		}
+		slashWildcards = append(slashWildcards, slashWildcard)
+	}
+
+	normalizedWildcards := normalizeCopyAddSources(slashWildcards)
 
 	logProcess := logboek.Context(ctx).Debug().LogProcess("Calculating files checksum (%v) from local git repo", normalizedWildcards)
 	logProcess.Start()
@@ -719,7 +900,10 @@ func (s *DockerfileStage) calculateFilesChecksum(ctx context.Context, giterminis
 			BasePath:     s.contextRelativeToGitWorkTree(giterminismManager),
 			IncludeGlobs: wildcards,
 		})
-		if contextAddChecksum, err := context_manager.ContextAddFilesChecksum(ctx, giterminismManager.ProjectDir(), s.context, s.contextAddFiles, wildcardsPathMatcher); err != nil {
+		cacheKey := util.Sha256Hash(append([]string{"checksum", giterminismManager.ProjectDir(), s.context, wildcardsPathMatcher.ID(), fmt.Sprintf("%t", s.platformIndependentContextChecksum)}, s.contextAddFiles...)...)
+		if contextAddChecksum, _, err := s.contextCache.GetOrCreate(cacheKey, func() (string, error) {
+			return context_manager.ContextAddFilesChecksum(ctx, giterminismManager.ProjectDir(), s.context, s.contextAddFiles, wildcardsPathMatcher, s.platformIndependentContextChecksum)
+		}); err != nil {
 			logProcess.Fail()
 			return "", fmt.Errorf("unable to calculate checksum for contextAddFiles files list: %s", err)
 		} else {
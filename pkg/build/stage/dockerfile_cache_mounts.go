@@ -0,0 +1,149 @@
+package stage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/context_manager"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+// BuildKit shares the RUN --mount=type=cache volumes by the cache id (the target path by default) between all builds on the docker host.
+// werf prefixes the cache ids with the project name, so package manager caches persist between builds of the project and never mix with caches of other projects.
+// Only the Dockerfile passed into the build is changed, the stage digest is calculated by the original instructions.
+
+const dockerfileMountFlagPrefix = "--mount="
+
+func (s *DockerfileStage) cacheMountIDPrefix() string {
+	return fmt.Sprintf("werf/%s/", s.projectName)
+}
+
+func (s *DockerfileStage) hasCacheMounts() bool {
+	for _, dockerStage := range s.dockerStages {
+		for _, cmd := range dockerStage.Commands {
+			runCommand, ok := cmd.(*instructions.RunCommand)
+			if !ok {
+				continue
+			}
+
+			for _, mount := range instructions.GetMounts(runCommand) {
+				if mount.Type == instructions.MountTypeCache {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *DockerfileStage) addCacheMountsDockerfileToContextArchive(ctx context.Context, giterminismManager giterminism_manager.Interface, archivePath string) (string, error) {
+	if !s.hasCacheMounts() {
+		return archivePath, nil
+	}
+
+	dockerfilePath := s.dockerfilePath
+	if dockerfilePath == "" {
+		dockerfilePath = "Dockerfile"
+	}
+
+	dockerfileData, err := giterminismManager.FileReader().ReadDockerfile(ctx, filepath.Join(s.context, dockerfilePath))
+	if err != nil {
+		return "", err
+	}
+
+	logboek.Context(ctx).Debug().LogF("Setting cache id prefix %q for the RUN --mount=type=cache instructions\n", s.cacheMountIDPrefix())
+
+	newDockerfileData, err := setDockerfileCacheMountsIDPrefix(dockerfileData, s.cacheMountIDPrefix())
+	if err != nil {
+		return "", fmt.Errorf("unable to set cache id prefix in dockerfile %s: %s", dockerfilePath, err)
+	}
+
+	return context_manager.ReplaceFileInContextArchive(ctx, archivePath, dockerfilePath, newDockerfileData)
+}
+
+// setDockerfileCacheMountsIDPrefix rewrites the --mount flags of every RUN instruction found by the Dockerfile parser.
+// The flags are replaced in the original lines of the instruction, so line continuations, comments and formatting are preserved.
+func setDockerfileCacheMountsIDPrefix(dockerfileData []byte, prefix string) ([]byte, error) {
+	res, err := parser.Parse(bytes.NewReader(dockerfileData))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(dockerfileData), "\n")
+	for _, node := range res.AST.Children {
+		if node.Value != "run" || node.StartLine < 1 || node.EndLine > len(lines) {
+			continue
+		}
+
+		instruction := strings.Join(lines[node.StartLine-1:node.EndLine], "\n")
+
+		var pos int
+		for _, flag := range node.Flags {
+			if !strings.HasPrefix(flag, dockerfileMountFlagPrefix) {
+				continue
+			}
+
+			ind := strings.Index(instruction[pos:], flag)
+			if ind == -1 {
+				continue
+			}
+			ind += pos
+
+			newFlag := dockerfileMountFlagPrefix + setCacheMountIDPrefix(strings.TrimPrefix(flag, dockerfileMountFlagPrefix), prefix)
+			instruction = instruction[:ind] + newFlag + instruction[ind+len(flag):]
+			pos = ind + len(newFlag)
+		}
+
+		copy(lines[node.StartLine-1:node.EndLine], strings.Split(instruction, "\n"))
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func setCacheMountIDPrefix(mountValue, prefix string) string {
+	fields := strings.Split(mountValue, ",")
+
+	var isCacheMount bool
+	var id, target string
+	idFieldIndex := -1
+	for ind, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		key := strings.ToLower(parts[0])
+
+		var value string
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+
+		switch key {
+		case "type":
+			isCacheMount = value == instructions.MountTypeCache
+		case "id":
+			id = value
+			idFieldIndex = ind
+		case "target", "dst", "destination":
+			target = value
+		}
+	}
+
+	if !isCacheMount {
+		return mountValue
+	}
+
+	if idFieldIndex == -1 {
+		return fmt.Sprintf("%s,id=%s%s", mountValue, prefix, strings.TrimPrefix(target, "/"))
+	}
+
+	fields[idFieldIndex] = fmt.Sprintf("id=%s%s", prefix, strings.TrimPrefix(id, "/"))
+
+	return strings.Join(fields, ",")
+}
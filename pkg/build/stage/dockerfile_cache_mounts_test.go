@@ -0,0 +1,48 @@
+package stage
+
+import "testing"
+
+func TestSetDockerfileCacheMountsIDPrefix(t *testing.T) {
+	dockerfile := `FROM alpine
+RUN --mount=type=cache,target=/root/.cache apk add git
+# RUN --mount=type=cache,target=/in/comment
+run --network=none --mount=type=cache,id=/npm,target=/root/.npm \
+    --mount=type=bind,source=.,target=/src \
+    npm ci
+RUN --mount=type=secret,id=token \
+    --mount=type=cache,target=/var/cache/apt \
+    apt-get update
+RUN echo --mount=type=cache,target=/not/a/flag`
+
+	expected := `FROM alpine
+RUN --mount=type=cache,target=/root/.cache,id=werf/project/root/.cache apk add git
+# RUN --mount=type=cache,target=/in/comment
+run --network=none --mount=type=cache,id=werf/project/npm,target=/root/.npm \
+    --mount=type=bind,source=.,target=/src \
+    npm ci
+RUN --mount=type=secret,id=token \
+    --mount=type=cache,target=/var/cache/apt,id=werf/project/var/cache/apt \
+    apt-get update
+RUN echo --mount=type=cache,target=/not/a/flag`
+
+	res, err := setDockerfileCacheMountsIDPrefix([]byte(dockerfile), "werf/project/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(res) != expected {
+		t.Fatalf("unexpected dockerfile:\n%s\n\nexpected:\n%s", res, expected)
+	}
+}
+
+func TestSetCacheMountIDPrefix(t *testing.T) {
+	for mountValue, expected := range map[string]string{
+		"type=cache,target=/root/.cache":        "type=cache,target=/root/.cache,id=werf/project/root/.cache",
+		"type=cache,id=pip,target=/root/.cache": "type=cache,id=werf/project/pip,target=/root/.cache",
+		"type=bind,source=.,target=/src":        "type=bind,source=.,target=/src",
+	} {
+		if res := setCacheMountIDPrefix(mountValue, "werf/project/"); res != expected {
+			t.Errorf("%q: expected %q, got %q", mountValue, expected, res)
+		}
+	}
+}
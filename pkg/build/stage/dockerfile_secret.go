@@ -0,0 +1,97 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/werf/pkg/deploy/secrets_manager"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+// DockerfileSecret is a secret for the RUN --mount=type=secret,id=ID Dockerfile instructions.
+// Secrets are passed to the build only and never affect the stage digest.
+type DockerfileSecret struct {
+	ID string
+
+	// Src is a path to the host file with the secret data
+	Src string
+
+	// SecretValue is a dot-separated key of the secret value in the encrypted SecretValuesFile
+	SecretValue      string
+	SecretValuesFile string
+}
+
+func (s *DockerfileStage) setupSecrets(ctx context.Context, giterminismManager giterminism_manager.Interface, builder secretsBuilder) error {
+	for _, secret := range s.secrets {
+		if secret.Src != "" {
+			builder.AppendSecretSource(secret.ID, secret.Src)
+			continue
+		}
+
+		data, err := getDockerfileSecretValue(ctx, giterminismManager, secret)
+		if err != nil {
+			return fmt.Errorf("unable to get secret %q: %s", secret.ID, err)
+		}
+
+		builder.AppendSecretData(secret.ID, data)
+	}
+
+	return nil
+}
+
+type secretsBuilder interface {
+	AppendSecretSource(id, src string)
+	AppendSecretData(id string, data []byte)
+}
+
+func getDockerfileSecretValue(ctx context.Context, giterminismManager giterminism_manager.Interface, secret *DockerfileSecret) ([]byte, error) {
+	encodedData, err := giterminismManager.FileReader().ReadChartFile(ctx, filepath.Join(giterminismManager.ProjectDir(), secret.SecretValuesFile))
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := secrets_manager.NewSecretsManager(secrets_manager.SecretsManagerOptions{}).GetYamlEncoder(ctx, giterminismManager.ProjectDir())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encoder.DecryptYamlFile(secret.SecretValuesFile, encodedData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode secret values file %q: %s", secret.SecretValuesFile, err)
+	}
+
+	var values interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal secret values file %q: %s", secret.SecretValuesFile, err)
+	}
+
+	return getDockerfileSecretValueByKey(values, secret.SecretValue, secret.SecretValuesFile)
+}
+
+// getDockerfileSecretValueByKey returns the scalar value by the dot-separated key (e.g. npm.token) from the decoded secret values.
+func getDockerfileSecretValueByKey(values interface{}, key, secretValuesFile string) ([]byte, error) {
+	value := values
+	for _, k := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("secret value %q not found in %q", key, secretValuesFile)
+		}
+
+		if value, ok = m[k]; !ok {
+			return nil, fmt.Errorf("secret value %q not found in %q", key, secretValuesFile)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case map[string]interface{}, []interface{}:
+		return nil, fmt.Errorf("secret value %q in %q must be a scalar", key, secretValuesFile)
+	default:
+		return []byte(fmt.Sprint(v)), nil
+	}
+}
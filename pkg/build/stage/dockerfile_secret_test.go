@@ -0,0 +1,84 @@
+package stage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+type testSecretsBuilder struct {
+	sources map[string]string
+	data    map[string][]byte
+}
+
+func (b *testSecretsBuilder) AppendSecretSource(id, src string) {
+	b.sources[id] = src
+}
+
+func (b *testSecretsBuilder) AppendSecretData(id string, data []byte) {
+	b.data[id] = data
+}
+
+func TestDockerfileStage_SetupSecrets_Sources(t *testing.T) {
+	s := &DockerfileStage{DockerRunArgs: &DockerRunArgs{secrets: []*DockerfileSecret{
+		{ID: "npmrc", Src: "/home/user/.npmrc"},
+		{ID: "token", Src: "/tmp/token"},
+	}}}
+
+	builder := &testSecretsBuilder{sources: map[string]string{}, data: map[string][]byte{}}
+	// the giterminism manager is used only for the secret values
+	if err := s.setupSecrets(context.Background(), nil, builder); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"npmrc": "/home/user/.npmrc", "token": "/tmp/token"}; !reflect.DeepEqual(builder.sources, expected) {
+		t.Errorf("expected secret sources %v, got %v", expected, builder.sources)
+	}
+	if len(builder.data) != 0 {
+		t.Errorf("expected no secret data, got %v", builder.data)
+	}
+}
+
+func TestGetDockerfileSecretValueByKey(t *testing.T) {
+	var values interface{}
+	if err := yaml.Unmarshal([]byte(`
+npm:
+  token: npm-token
+  registries:
+  - registry.example.com
+pip:
+  conf:
+    index: https://pypi.example.com
+port: 8080
+enabled: true
+`), &values); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{
+		"npm.token": "npm-token",
+		"port":      "8080",
+		"enabled":   "true",
+	} {
+		data, err := getDockerfileSecretValueByKey(values, key, "secret-values.yaml")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", key, err)
+		} else if string(data) != expected {
+			t.Errorf("%q: expected %q, got %q", key, expected, data)
+		}
+	}
+
+	for key, expectedErr := range map[string]string{
+		"npm.password":    `secret value "npm.password" not found in "secret-values.yaml"`,
+		"npm.token.value": `secret value "npm.token.value" not found in "secret-values.yaml"`,
+		"npm.registries":  `secret value "npm.registries" in "secret-values.yaml" must be a scalar`,
+		"pip.conf":        `secret value "pip.conf" in "secret-values.yaml" must be a scalar`,
+	} {
+		if _, err := getDockerfileSecretValueByKey(values, key, "secret-values.yaml"); err == nil || !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("%q: expected error %q, got %v", key, expectedErr, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package stage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+const defaultDockerfileSSHID = "default"
+
+// SSHBuildArgs returns --ssh options to forward the werf ssh agent (the agent with --ssh-key keys, the system agent or the agent with the default keys) into the build.
+// The default agent of the ssh directive is replaced with the werf ssh agent.
+// If the ssh directive is not specified, the agent is forwarded for each id of the RUN --mount=type=ssh instructions.
+func (s *DockerfileStage) SSHBuildArgs(sshAuthSock string) []string {
+	if s.ssh != "" {
+		if s.ssh == defaultDockerfileSSHID && sshAuthSock != "" {
+			return []string{fmt.Sprintf("--ssh=%s=%s", defaultDockerfileSSHID, sshAuthSock)}
+		}
+
+		return []string{fmt.Sprintf("--ssh=%s", s.ssh)}
+	}
+
+	if sshAuthSock == "" {
+		return nil
+	}
+
+	var result []string
+	for _, id := range s.sshMountIDs() {
+		result = append(result, fmt.Sprintf("--ssh=%s=%s", id, sshAuthSock))
+	}
+
+	return result
+}
+
+func (s *DockerfileStage) sshMountIDs() []string {
+	isAddedID := map[string]bool{}
+	var ids []string
+	for _, dockerStage := range s.dockerStages[:s.dockerTargetStageIndex+1] {
+		for _, cmd := range dockerStage.Commands {
+			runCommand, ok := cmd.(*instructions.RunCommand)
+			if !ok {
+				continue
+			}
+
+			for _, mount := range instructions.GetMounts(runCommand) {
+				if mount.Type != instructions.MountTypeSSH {
+					continue
+				}
+
+				id := mount.CacheID
+				if id == "" {
+					id = defaultDockerfileSSHID
+				}
+
+				if !isAddedID[id] {
+					isAddedID[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
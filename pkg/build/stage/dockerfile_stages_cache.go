@@ -0,0 +1,46 @@
+package stage
+
+import (
+	"fmt"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/remote_builder"
+)
+
+// GenerateDockerfileCacheStage creates the stage for the named Dockerfile stage (FROM ... AS NAME), which the target Dockerfile stage depends on.
+// The stage is built with --target=NAME and stored separately with its own digest, so the cache survives changes of the dependent Dockerfile stages.
+func GenerateDockerfileCacheStage(dockerStageName string, dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
+	s := newDockerfileStage(dockerRunArgs, dockerStages, contextChecksum, baseStageOptions)
+	s.BaseStage.name = DockerfileCacheStageName(dockerStageName)
+	s.isCacheStage = true
+
+	return s
+}
+
+func DockerfileCacheStageName(dockerStageName string) StageName {
+	return StageName(fmt.Sprintf("%s-%s", Dockerfile, dockerStageName))
+}
+
+// SetCacheFromStages sets the cache stages whose images are used as the build cache source (--cache-from) for the stage.
+func (s *DockerfileStage) SetCacheFromStages(stages []*DockerfileStage) {
+	s.cacheFromStages = stages
+}
+
+func (s *DockerfileStage) CacheFromStages() []*DockerfileStage {
+	return s.cacheFromStages
+}
+
+func (s *DockerfileStage) cacheBuildArgs() []string {
+	var result []string
+
+	for _, cacheFromStage := range s.cacheFromStages {
+		result = append(result, fmt.Sprintf("--cache-from=%s", cacheFromStage.GetImage().Name()))
+	}
+
+	// BuildKit uses the image as the cache source only if the cache metadata is embedded into the image
+	if s.isCacheStage && (docker.IsBuildKitEnabled() || remote_builder.IsEnabled()) {
+		result = append(result, "--build-arg=BUILDKIT_INLINE_CACHE=1")
+	}
+
+	return result
+}
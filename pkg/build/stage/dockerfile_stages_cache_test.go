@@ -0,0 +1,30 @@
+package stage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDockerfileStage_cacheBuildArgs(t *testing.T) {
+	inlineCacheArgs := []string{"--build-arg=BUILDKIT_INLINE_CACHE=1"}
+
+	for _, tc := range []struct {
+		name         string
+		buildKitEnv  string
+		isCacheStage bool
+		expected     []string
+	}{
+		{name: "cache stage", buildKitEnv: "1", isCacheStage: true, expected: inlineCacheArgs},
+		{name: "target stage", buildKitEnv: "1"},
+		{name: "cache stage without BuildKit", buildKitEnv: "0", isCacheStage: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("DOCKER_BUILDKIT", tc.buildKitEnv)
+
+			s := &DockerfileStage{isCacheStage: tc.isCacheStage}
+			if res := s.cacheBuildArgs(); !reflect.DeepEqual(res, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, res)
+			}
+		})
+	}
+}
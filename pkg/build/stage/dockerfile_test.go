@@ -0,0 +1,128 @@
+package stage
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+const testWindowsBaseImage = "mcr.microsoft.com/windows/servercore:ltsc2022"
+
+func newTestDockerStages(t *testing.T, dockerfile string) *DockerStages {
+	t.Helper()
+
+	p, err := parser.Parse(bytes.NewReader([]byte(dockerfile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerStages, dockerMetaArgs, err := instructions.Parse(p.AST)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := NewDockerStages(dockerStages, map[string]string{}, dockerMetaArgs, len(dockerStages)-1, p.EscapeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.baseImagesOS[testWindowsBaseImage] = windowsImageOS
+	ds.baseImagesOS["alpine"] = "linux"
+
+	return ds
+}
+
+func TestIsWindowsDockerStage(t *testing.T) {
+	ds := newTestDockerStages(t, `ARG BASE=`+testWindowsBaseImage+`
+FROM $BASE AS base
+FROM base AS build
+FROM alpine AS linux
+FROM build
+`)
+
+	for dockerStageID, expected := range []bool{true, true, false, true} {
+		if isWindows, err := ds.isWindowsDockerStage(dockerStageID); err != nil {
+			t.Fatal(err)
+		} else if isWindows != expected {
+			t.Fatalf("expected the stage %d windows=%v, got %v", dockerStageID, expected, isWindows)
+		}
+	}
+}
+
+func TestIsWindowsDockerStageReferencingLaterStages(t *testing.T) {
+	// the stages can be based only on the previous stages, the others are the external images
+	ds := newTestDockerStages(t, `FROM second AS first
+FROM first AS second
+FROM third AS third
+`)
+
+	for dockerStageID := range ds.dockerStages {
+		if isWindows, err := ds.isWindowsDockerStage(dockerStageID); err != nil {
+			t.Fatal(err)
+		} else if isWindows {
+			t.Fatalf("expected the stage %d not to be windows", dockerStageID)
+		}
+	}
+}
+
+func TestNormalizeWindowsPaths(t *testing.T) {
+	ds := newTestDockerStages(t, `# escape=`+"`"+`
+FROM alpine AS linux
+FROM `+testWindowsBaseImage+`
+`)
+
+	for _, tc := range []struct {
+		dockerStageID int
+		value         string
+		expected      string
+	}{
+		{dockerStageID: 0, value: `COPY app\src /app`, expected: `COPY app\src /app`},
+		{dockerStageID: 1, value: `COPY app\src C:\app`, expected: `COPY app/src C:/app`},
+		{dockerStageID: 1, value: `COPY app/src C:/app`, expected: `COPY app/src C:/app`},
+	} {
+		if res, err := ds.normalizeWindowsPaths(tc.dockerStageID, tc.value); err != nil {
+			t.Fatal(err)
+		} else if res != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, res)
+		}
+	}
+
+	if resolved, err := ds.ShlexProcessWordWithMetaArgs(`C:\app`); err != nil {
+		t.Fatal(err)
+	} else if resolved != `C:\app` {
+		t.Fatalf("expected the backslashes to be kept with the backtick escape token, got %q", resolved)
+	}
+}
+
+func TestSelectRepoDigest(t *testing.T) {
+	repoDigests := []string{"mirror.example.com/library/alpine@sha256:mirror", "alpine@sha256:hub"}
+
+	if reference := selectRepoDigest("alpine:3.14", repoDigests); reference != "alpine@sha256:hub" {
+		t.Fatalf("expected the repo digest of the image repository, got %q", reference)
+	}
+
+	if reference := selectRepoDigest("other:latest", repoDigests); reference != "mirror.example.com/library/alpine@sha256:mirror" {
+		t.Fatalf("expected the first repo digest for the other repository, got %q", reference)
+	}
+
+	if reference := selectRepoDigest("alpine:3.14", nil); reference != "" {
+		t.Fatalf("expected no reference for the image without repo digests, got %q", reference)
+	}
+}
+
+func TestDockerfileStage_BaseImagesReferences(t *testing.T) {
+	s := &DockerfileStage{DockerStages: &DockerStages{baseImagesReferences: map[string]string{
+		"golang:1.17": "golang@sha256:2",
+		"alpine:3.14": "alpine@sha256:1",
+	}}}
+
+	if references := s.BaseImagesReferences(); !reflect.DeepEqual(references, []string{"alpine@sha256:1", "golang@sha256:2"}) {
+		t.Fatalf("expected the sorted references, got %v", references)
+	}
+
+	if references := (&DockerfileStage{DockerStages: &DockerStages{}}).BaseImagesReferences(); len(references) != 0 {
+		t.Fatalf("expected no references, got %v", references)
+	}
+}
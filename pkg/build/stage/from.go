@@ -60,6 +60,10 @@ func (s *FromStage) GetDependencies(_ context.Context, c Conveyor, prevImage, _
 
 	for _, mount := range s.configMounts {
 		args = append(args, filepath.ToSlash(filepath.Clean(mount.From)), path.Clean(mount.To), mount.Type)
+
+		if mount.Name != "" {
+			args = append(args, mount.Name)
+		}
 	}
 
 	if s.fromImageOrArtifactImageName != "" {
@@ -80,6 +84,9 @@ func (s *FromStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage
 	customMounts := s.getCustomMounts(prevBuiltImage)
 	s.addCustomMountLabels(customMounts, image)
 
+	s.addTmpfsMountsLabels(s.getTmpfsMounts(prevBuiltImage), image)
+	s.addVolumeMountLabels(s.getVolumeMounts(prevBuiltImage), image)
+
 	var mountpoints []string
 	for _, mountCfg := range s.configMounts {
 		mountpoints = append(mountpoints, mountCfg.To)
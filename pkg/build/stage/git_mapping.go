@@ -19,6 +19,7 @@ import (
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/path_matcher"
 	"github.com/werf/werf/pkg/stapel"
+	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/util"
 )
 
@@ -759,6 +760,12 @@ func (gm *GitMapping) GetParamshash() string {
 	parts = append(parts, ":::")
 	parts = append(parts, gm.Commit)
 
+	// the submodules depth changes the content of the git mapping
+	if submodulesOptionsID := true_git.SubmodulesOptionsID(); submodulesOptionsID != "" {
+		parts = append(parts, ":::")
+		parts = append(parts, submodulesOptionsID)
+	}
+
 	for _, part := range parts {
 		_, err = hash.Write([]byte(part))
 		if err != nil {
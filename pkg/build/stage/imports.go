@@ -11,6 +11,7 @@ import (
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/build/import_server"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
@@ -67,6 +68,11 @@ func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ con
 		args = append(args, sourceChecksum)
 		args = append(args, elm.To)
 		args = append(args, elm.Group, elm.Owner)
+
+		// keep the digests of the imports without the mode overrides unchanged
+		if elm.FileMode != "" || elm.DirMode != "" {
+			args = append(args, elm.FileMode, elm.DirMode)
+		}
 	}
 
 	return util.Sha256Hash(args...), nil
@@ -74,10 +80,19 @@ func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ con
 
 func (s *ImportsStage) PrepareImage(ctx context.Context, c Conveyor, _, image container_runtime.ImageInterface) error {
 	for _, elm := range s.imports {
-		sourceImageName := getSourceImageName(elm)
-		srv, err := c.GetImportServer(ctx, sourceImageName, elm.Stage)
-		if err != nil {
-			return fmt.Errorf("unable to get import server for image %q: %s", sourceImageName, err)
+		var srv import_server.ImportServer
+		var err error
+		if elm.From != "" {
+			srv, err = c.GetImportServerForExternalImage(ctx, elm.From)
+			if err != nil {
+				return fmt.Errorf("unable to get import server for external image %q: %s", elm.From, err)
+			}
+		} else {
+			sourceImageName := getSourceImageName(elm)
+			srv, err = c.GetImportServer(ctx, sourceImageName, elm.Stage)
+			if err != nil {
+				return fmt.Errorf("unable to get import server for image %q: %s", sourceImageName, err)
+			}
 		}
 
 		command := srv.GetCopyCommand(ctx, elm)
@@ -87,14 +102,19 @@ func (s *ImportsStage) PrepareImage(ctx context.Context, c Conveyor, _, image co
 
 		labelKey := imagePkg.WerfImportChecksumLabelPrefix + getImportID(elm)
 
-		importSourceID := getImportSourceID(c, elm)
-		importMetadata, err := c.GetImportMetadata(ctx, s.projectName, importSourceID)
-		if err != nil {
-			return fmt.Errorf("unable to get import source checksum: %s", err)
-		} else if importMetadata == nil {
-			panic(fmt.Sprintf("import metadata %s not found", importSourceID))
+		var labelValue string
+		if elm.From != "" {
+			labelValue = getExternalImportSourceChecksum(elm)
+		} else {
+			importSourceID := getImportSourceID(c, elm)
+			importMetadata, err := c.GetImportMetadata(ctx, s.projectName, importSourceID)
+			if err != nil {
+				return fmt.Errorf("unable to get import source checksum: %s", err)
+			} else if importMetadata == nil {
+				panic(fmt.Sprintf("import metadata %s not found", importSourceID))
+			}
+			labelValue = importMetadata.Checksum
 		}
-		labelValue := importMetadata.Checksum
 
 		imageServiceCommitChangeOptions.AddLabel(map[string]string{labelKey: labelValue})
 	}
@@ -103,6 +123,11 @@ func (s *ImportsStage) PrepareImage(ctx context.Context, c Conveyor, _, image co
 }
 
 func (s *ImportsStage) getImportSourceChecksum(ctx context.Context, c Conveyor, importElm *config.Import) (string, error) {
+	// the external image is pinned by the reference, so the files are not checksummed
+	if importElm.From != "" {
+		return getExternalImportSourceChecksum(importElm), nil
+	}
+
 	importSourceID := getImportSourceID(c, importElm)
 	importMetadata, err := c.GetImportMetadata(ctx, s.projectName, importSourceID)
 	if err != nil {
@@ -147,8 +172,13 @@ func (s *ImportsStage) generateImportChecksum(ctx context.Context, c Conveyor, i
 	importScriptContainerPath := path.Join(importContainerDir, "script.sh")
 	resultChecksumContainerPath := path.Join(importContainerDir, "checksum")
 
-	command := generateChecksumCommand(importElm.Add, importElm.IncludePaths, importElm.ExcludePaths, resultChecksumContainerPath)
-	if err := stapel.CreateScript(importScriptHostTmpPath, []string{command}); err != nil {
+	var commands []string
+	if importElm.IsAddGlob() {
+		commands = append(commands, generateGlobMatchCheckCommand(importElm.Add))
+	}
+	commands = append(commands, generateChecksumCommand(importElm.Add, importElm.AddBasePath(), importElm.IncludePaths, importElm.ExcludePaths, resultChecksumContainerPath))
+
+	if err := stapel.CreateScript(importScriptHostTmpPath, commands); err != nil {
 		return "", fmt.Errorf("unable to create script: %s", err)
 	}
 
@@ -181,7 +211,12 @@ func (s *ImportsStage) generateImportChecksum(ctx context.Context, c Conveyor, i
 	return checksum, nil
 }
 
-func generateChecksumCommand(from string, includePaths, excludePaths []string, resultChecksumPath string) string {
+// generateGlobMatchCheckCommand fails if the glob matches nothing, otherwise the checksum of no files is calculated and the import fails only on copying.
+func generateGlobMatchCheckCommand(glob string) string {
+	return fmt.Sprintf("set -- %s ; if [ ! -e \"$1\" ] && [ ! -L \"$1\" ] ; then echo \"No files or directories match the import path %s\" >&2 ; exit 1 ; fi", glob, glob)
+}
+
+func generateChecksumCommand(from, pathsBase string, includePaths, excludePaths []string, resultChecksumPath string) string {
 	findCommandParts := append([]string{}, stapel.FindBinPath(), from, "-type", "f")
 
 	var nameIncludeArgs []string
@@ -189,8 +224,8 @@ func generateChecksumCommand(from string, includePaths, excludePaths []string, r
 		formattedPath := formatIncludeAndExcludePath(includePath)
 		nameIncludeArgs = append(
 			nameIncludeArgs,
-			fmt.Sprintf("-wholename \"%s\"", path.Join(from, formattedPath)),
-			fmt.Sprintf("-wholename \"%s\"", path.Join(from, formattedPath, "**")),
+			fmt.Sprintf("-wholename \"%s\"", path.Join(pathsBase, formattedPath)),
+			fmt.Sprintf("-wholename \"%s\"", path.Join(pathsBase, formattedPath, "**")),
 		)
 	}
 
@@ -203,8 +238,8 @@ func generateChecksumCommand(from string, includePaths, excludePaths []string, r
 		formattedPath := formatIncludeAndExcludePath(excludePath)
 		nameExcludeArgs = append(
 			nameExcludeArgs,
-			fmt.Sprintf("! -wholename \"%s\"", path.Join(from, formattedPath)),
-			fmt.Sprintf("! -wholename \"%s\"", path.Join(from, formattedPath, "**")),
+			fmt.Sprintf("! -wholename \"%s\"", path.Join(pathsBase, formattedPath)),
+			fmt.Sprintf("! -wholename \"%s\"", path.Join(pathsBase, formattedPath, "**")),
 		)
 	}
 
@@ -239,8 +274,17 @@ func formatIncludeAndExcludePath(path string) string {
 	return strings.TrimRight(path, "*/")
 }
 
-func getImportID(importElm *config.Import) string {
+func getExternalImportSourceChecksum(importElm *config.Import) string {
 	return util.Sha256Hash(
+		"From", importElm.From,
+		"Add", importElm.Add,
+		"IncludePaths", strings.Join(importElm.IncludePaths, "///"),
+		"ExcludePaths", strings.Join(importElm.ExcludePaths, "///"),
+	)
+}
+
+func getImportID(importElm *config.Import) string {
+	args := []string{
 		"ImageName", importElm.ImageName,
 		"ArtifactName", importElm.ArtifactName,
 		"Stage", importElm.Stage,
@@ -252,7 +296,18 @@ func getImportID(importElm *config.Import) string {
 		"Owner", importElm.Owner,
 		"IncludePaths", strings.Join(importElm.IncludePaths, "///"),
 		"ExcludePaths", strings.Join(importElm.ExcludePaths, "///"),
-	)
+	}
+
+	// keep the ids of the werf images imports unchanged
+	if importElm.From != "" {
+		args = append(args, "From", importElm.From)
+	}
+
+	if importElm.FileMode != "" || importElm.DirMode != "" {
+		args = append(args, "FileMode", importElm.FileMode, "DirMode", importElm.DirMode)
+	}
+
+	return util.Sha256Hash(args...)
 }
 
 func getImportSourceID(c Conveyor, importElm *config.Import) string {
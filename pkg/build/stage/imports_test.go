@@ -0,0 +1,68 @@
+package stage
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+func TestGenerateGlobMatchCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.jar"), []byte("jar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for glob, shouldMatch := range map[string]bool{
+		filepath.Join(dir, "*.jar"):   true,
+		filepath.Join(dir, "app.ja?"): true,
+		filepath.Join(dir, "*.war"):   false,
+		filepath.Join(dir, "*", "*"):  false,
+	} {
+		output, err := exec.Command("bash", "-c", generateGlobMatchCheckCommand(glob)).CombinedOutput()
+		if shouldMatch && err != nil {
+			t.Errorf("expected the glob %q to match: %s\n%s", glob, err, output)
+		} else if !shouldMatch && err == nil {
+			t.Errorf("expected the glob %q not to match", glob)
+		}
+	}
+}
+
+func TestImportsStage_ExternalImportSourceChecksum(t *testing.T) {
+	imp := &config.Import{From: "alpine@sha256:1", ArtifactExport: &config.ArtifactExport{ExportBase: &config.ExportBase{Add: "/etc/ssl", To: "/etc/ssl"}}}
+
+	checksum, err := (&ImportsStage{}).getImportSourceChecksum(context.Background(), nil, imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != getExternalImportSourceChecksum(imp) {
+		t.Fatalf("expected the external import checksum without the files checksumming, got %q", checksum)
+	}
+
+	otherImp := *imp
+	otherImp.From = "alpine@sha256:2"
+	if getExternalImportSourceChecksum(&otherImp) == checksum {
+		t.Fatal("expected the checksum to depend on the external image reference")
+	}
+}
+
+func TestGetImportID(t *testing.T) {
+	imp := &config.Import{ImageName: "app", ArtifactExport: &config.ArtifactExport{ExportBase: &config.ExportBase{Add: "/app", To: "/app"}}}
+	id := getImportID(imp)
+
+	externalImp := *imp
+	externalImp.ImageName = ""
+	externalImp.From = "alpine@sha256:1"
+	externalID := getImportID(&externalImp)
+	if externalID == id {
+		t.Fatal("expected the import id to depend on the external image reference")
+	}
+
+	externalImp.From = "alpine@sha256:2"
+	if getImportID(&externalImp) == externalID {
+		t.Fatal("expected the import id to depend on the external image digest")
+	}
+}
@@ -18,6 +18,8 @@ func getBuilder(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBa
 		b = builder.NewShellBuilder(imageBaseConfig.Shell, extra)
 	} else if imageBaseConfig.Ansible != nil {
 		b = builder.NewAnsibleBuilder(imageBaseConfig.Ansible, extra)
+	} else if imageBaseConfig.Scripts != nil {
+		b = builder.NewScriptsBuilder(imageBaseConfig.Scripts, baseStageOptions.ScriptsData, extra)
 	}
 
 	return b
@@ -0,0 +1,124 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/slug"
+)
+
+type StageLogsOptions struct {
+	// StageLogsDir is not set when the stage logs capture is disabled
+	StageLogsDir string
+	// StageLogUploader is optional, the captured stage logs are only kept in the StageLogsDir when it is not set
+	StageLogUploader StageLogUploader
+}
+
+type StageLogRecord struct {
+	ImageName string
+	StageName string
+	Digest    string
+	Path      string
+	Failed    bool
+}
+
+// StageLogUploader uploads the captured stage build log into an external storage (S3, GCS, CI artifacts, etc.)
+type StageLogUploader interface {
+	UploadStageLog(ctx context.Context, record StageLogRecord) error
+}
+
+// CommandStageLogUploader runs the shell command for each captured stage build log,
+// the record fields are passed into the command by the WERF_STAGE_LOG_* environment variables.
+type CommandStageLogUploader struct {
+	Command string
+}
+
+func NewCommandStageLogUploader(command string) *CommandStageLogUploader {
+	return &CommandStageLogUploader{Command: command}
+}
+
+func (uploader *CommandStageLogUploader) UploadStageLog(ctx context.Context, record StageLogRecord) error {
+	var output bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", uploader.Command)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("WERF_STAGE_LOG_PATH=%s", record.Path),
+		fmt.Sprintf("WERF_STAGE_LOG_IMAGE_NAME=%s", record.ImageName),
+		fmt.Sprintf("WERF_STAGE_LOG_STAGE_NAME=%s", record.StageName),
+		fmt.Sprintf("WERF_STAGE_LOG_DIGEST=%s", record.Digest),
+		fmt.Sprintf("WERF_STAGE_LOG_FAILED=%t", record.Failed),
+	)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%q failed: %s\n%s", uploader.Command, err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+func (phase *BuildPhase) getStageLogPath(img *Image, stg stage.Interface) string {
+	imageDirName := "~dockerfile"
+	if img.GetName() != "" {
+		imageDirName = slug.Slug(img.GetName())
+	}
+
+	return filepath.Join(phase.StageLogsDir, imageDirName, fmt.Sprintf("%s.log", stg.Name()))
+}
+
+// buildWithStageLogCapture runs the stage build and writes the docker build or stapel container output into the stage log file.
+// The stage log is uploaded by the StageLogUploader regardless of the build result, the upload errors do not fail the build.
+func (phase *BuildPhase) buildWithStageLogCapture(ctx context.Context, img *Image, stg stage.Interface, buildFunc func(ctx context.Context) error) error {
+	phase.stageLogPath = ""
+
+	if phase.StageLogsDir == "" {
+		return buildFunc(ctx)
+	}
+
+	path := phase.getStageLogPath(img, stg)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(path), err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create stage log file %s: %s", path, err)
+	}
+
+	buildErr := docker.DoWithOutputCapture(ctx, f, buildFunc)
+	if buildErr != nil {
+		fmt.Fprintf(f, "\nError: %s\n", buildErr)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close stage log file %s: %s", path, err)
+	}
+
+	phase.stageLogPath = path
+
+	if phase.StageLogUploader != nil {
+		record := StageLogRecord{
+			ImageName: img.GetName(),
+			StageName: string(stg.Name()),
+			Digest:    stg.GetDigest(),
+			Path:      path,
+			Failed:    buildErr != nil,
+		}
+
+		if err := phase.StageLogUploader.UploadStageLog(ctx, record); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: unable to upload stage log %s: %s\n", path, err)
+		}
+	}
+
+	return buildErr
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/docker"
+)
+
+type testStageLogsStage struct {
+	stage.Interface
+
+	name   stage.StageName
+	digest string
+}
+
+func (s *testStageLogsStage) Name() stage.StageName {
+	return s.name
+}
+
+func (s *testStageLogsStage) GetDigest() string {
+	return s.digest
+}
+
+type testStageLogUploader struct {
+	records []StageLogRecord
+	err     error
+}
+
+func (uploader *testStageLogUploader) UploadStageLog(_ context.Context, record StageLogRecord) error {
+	uploader.records = append(uploader.records, record)
+	return uploader.err
+}
+
+func TestBuildPhase_GetStageLogPath(t *testing.T) {
+	phase := &BuildPhase{BuildPhaseOptions: BuildPhaseOptions{BuildOptions: BuildOptions{StageLogsOptions: StageLogsOptions{StageLogsDir: "/logs"}}}}
+	stg := &testStageLogsStage{name: stage.Install}
+
+	if path := phase.getStageLogPath(&Image{name: "backend/App"}, stg); path != filepath.Join("/logs", "backend-app", "install.log") {
+		t.Fatalf("unexpected named image stage log path %q", path)
+	}
+
+	if path := phase.getStageLogPath(&Image{}, stg); path != filepath.Join("/logs", "~dockerfile", "install.log") {
+		t.Fatalf("unexpected nameless image stage log path %q", path)
+	}
+}
+
+func TestBuildPhase_BuildWithStageLogCapture(t *testing.T) {
+	ctx, err := docker.NewContext(logboek.NewContext(context.Background(), logboek.NewLogger(ioutil.Discard, ioutil.Discard)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploader := &testStageLogUploader{err: errors.New("upload failed")}
+	phase := &BuildPhase{BuildPhaseOptions: BuildPhaseOptions{BuildOptions: BuildOptions{StageLogsOptions: StageLogsOptions{StageLogsDir: t.TempDir(), StageLogUploader: uploader}}}}
+	img := &Image{name: "app"}
+	stg := &testStageLogsStage{name: stage.Setup, digest: "digest"}
+
+	buildErr := errors.New("build failed")
+	err = phase.buildWithStageLogCapture(ctx, img, stg, func(ctx context.Context) error {
+		return buildErr
+	})
+	if err != buildErr {
+		t.Fatalf("expected the build error regardless of the upload error, got %v", err)
+	}
+
+	expectedPath := phase.getStageLogPath(img, stg)
+	if phase.stageLogPath != expectedPath {
+		t.Fatalf("expected the stage log path %q, got %q", expectedPath, phase.stageLogPath)
+	}
+
+	data, err := ioutil.ReadFile(expectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Error: build failed") {
+		t.Fatalf("expected the build error in the stage log, got %q", data)
+	}
+
+	expectedRecords := []StageLogRecord{{ImageName: "app", StageName: "setup", Digest: "digest", Path: expectedPath, Failed: true}}
+	if fmt.Sprint(uploader.records) != fmt.Sprint(expectedRecords) {
+		t.Fatalf("expected the uploaded records %v, got %v", expectedRecords, uploader.records)
+	}
+}
+
+func TestBuildPhase_BuildWithStageLogCapture_Disabled(t *testing.T) {
+	phase := &BuildPhase{stageLogPath: "previous.log"}
+
+	var isBuilt bool
+	if err := phase.buildWithStageLogCapture(context.Background(), &Image{}, &testStageLogsStage{}, func(ctx context.Context) error {
+		isBuilt = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isBuilt || phase.stageLogPath != "" {
+		t.Fatalf("expected the stage to be built without the stage log, got built %v and log %q", isBuilt, phase.stageLogPath)
+	}
+}
+
+func TestCommandStageLogUploader(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output")
+	uploader := NewCommandStageLogUploader(fmt.Sprintf(`echo "$WERF_STAGE_LOG_IMAGE_NAME $WERF_STAGE_LOG_STAGE_NAME $WERF_STAGE_LOG_DIGEST $WERF_STAGE_LOG_FAILED $WERF_STAGE_LOG_PATH" > %q`, outputPath))
+
+	if err := uploader.UploadStageLog(context.Background(), StageLogRecord{ImageName: "app", StageName: "install", Digest: "digest", Path: "/logs/app/install.log", Failed: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "app install digest true /logs/app/install.log\n"; string(data) != expected {
+		t.Fatalf("expected the command output %q, got %q", expected, data)
+	}
+
+	err = NewCommandStageLogUploader("echo upload error output; exit 1").UploadStageLog(context.Background(), StageLogRecord{})
+	if err == nil || !strings.Contains(err.Error(), "upload error output") {
+		t.Fatalf("expected the error with the command output, got %v", err)
+	}
+}
@@ -48,7 +48,7 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 	}
 	logboek.Context(ctx).Debug().LogF("%s stage is empty: %v\n", stg.LogDetailedName(), isEmpty)
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if _, isDockerfileStage := stg.(*stage.DockerfileStage); stg.Name() != "from" && !isDockerfileStage {
 		if iterator.PrevStage == nil {
 			panic(fmt.Sprintf("expected PrevStage to be set for image %q stage %s!", img.GetName(), stg.Name()))
 		}
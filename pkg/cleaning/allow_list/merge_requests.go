@@ -0,0 +1,148 @@
+package allow_list
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	MergeRequestsAPIGitLab = "gitlab"
+	MergeRequestsAPIGitHub = "github"
+
+	defaultGitHubAPIURL = "https://api.github.com"
+
+	mergeRequestsPerPage = 100
+	mergeRequestsTimeout = 30 * time.Second
+)
+
+// MergeRequestsAPI lists the open merge requests (GitLab) or pull requests (GitHub) of the project by the git hosting API.
+type MergeRequestsAPI struct {
+	apiType string
+	apiURL  string
+	project string
+	token   string
+
+	httpClient *http.Client
+}
+
+// NewMergeRequestsAPI creates the API client: the project is the GitLab project ID or path (group/project) or the GitHub repository (owner/repo).
+// The API URL is required for GitLab (e.g. $CI_API_V4_URL), https://api.github.com is used for GitHub by default.
+func NewMergeRequestsAPI(apiType, apiURL, project, token string) (*MergeRequestsAPI, error) {
+	switch apiType {
+	case MergeRequestsAPIGitLab:
+		if apiURL == "" {
+			return nil, fmt.Errorf("GitLab API URL required")
+		}
+	case MergeRequestsAPIGitHub:
+		if apiURL == "" {
+			apiURL = defaultGitHubAPIURL
+		}
+	default:
+		return nil, fmt.Errorf("unsupported merge requests API %q, expected %q or %q", apiType, MergeRequestsAPIGitLab, MergeRequestsAPIGitHub)
+	}
+
+	if project == "" {
+		return nil, fmt.Errorf("project required")
+	}
+
+	return &MergeRequestsAPI{
+		apiType: apiType,
+		apiURL:  strings.TrimSuffix(apiURL, "/"),
+		project: project,
+		token:   token,
+
+		httpClient: &http.Client{Timeout: mergeRequestsTimeout},
+	}, nil
+}
+
+// OpenMergeRequestsHeadCommits returns the head commits of the open merge requests.
+func (api *MergeRequestsAPI) OpenMergeRequestsHeadCommits(ctx context.Context) ([]string, error) {
+	var commits []string
+	for page := 1; ; page++ {
+		pageCommits, err := api.getOpenMergeRequestsHeadCommitsPage(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, pageCommits...)
+
+		if len(pageCommits) < mergeRequestsPerPage {
+			return commits, nil
+		}
+	}
+}
+
+func (api *MergeRequestsAPI) getOpenMergeRequestsHeadCommitsPage(ctx context.Context, page int) ([]string, error) {
+	var reqURL string
+	switch api.apiType {
+	case MergeRequestsAPIGitLab:
+		reqURL = fmt.Sprintf("%s/projects/%s/merge_requests?state=opened&per_page=%d&page=%d", api.apiURL, url.PathEscape(api.project), mergeRequestsPerPage, page)
+	case MergeRequestsAPIGitHub:
+		reqURL = fmt.Sprintf("%s/repos/%s/pulls?state=open&per_page=%d&page=%d", api.apiURL, api.project, mergeRequestsPerPage, page)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.token != "" {
+		switch api.apiType {
+		case MergeRequestsAPIGitLab:
+			req.Header.Set("PRIVATE-TOKEN", api.token)
+		case MergeRequestsAPIGitHub:
+			req.Header.Set("Authorization", "token "+api.token)
+		}
+	}
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s API responded with status %s", api.apiType, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []string
+	switch api.apiType {
+	case MergeRequestsAPIGitLab:
+		var mergeRequests []struct {
+			Sha string `json:"sha"`
+		}
+		if err := json.Unmarshal(body, &mergeRequests); err != nil {
+			return nil, fmt.Errorf("unexpected %s API response: %s", api.apiType, err)
+		}
+
+		for _, mr := range mergeRequests {
+			commits = append(commits, mr.Sha)
+		}
+	case MergeRequestsAPIGitHub:
+		var pullRequests []struct {
+			Head struct {
+				Sha string `json:"sha"`
+			} `json:"head"`
+		}
+		if err := json.Unmarshal(body, &pullRequests); err != nil {
+			return nil, fmt.Errorf("unexpected %s API response: %s", api.apiType, err)
+		}
+
+		for _, pr := range pullRequests {
+			commits = append(commits, pr.Head.Sha)
+		}
+	}
+
+	return commits, nil
+}
@@ -0,0 +1,121 @@
+package allow_list
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func newTestMergeRequestsServer(t *testing.T, expectedPath, tokenHeader string, total int, itemFormat string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != expectedPath {
+			t.Errorf("unexpected path %q", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get(tokenHeader) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || r.URL.Query().Get("per_page") != strconv.Itoa(mergeRequestsPerPage) {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprint(w, "[")
+		for i := (page - 1) * mergeRequestsPerPage; i < total && i < page*mergeRequestsPerPage; i++ {
+			if i%mergeRequestsPerPage != 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, itemFormat, i)
+		}
+		fmt.Fprint(w, "]")
+	}))
+}
+
+func expectedCommits(total int) []string {
+	var commits []string
+	for i := 0; i < total; i++ {
+		commits = append(commits, fmt.Sprintf("sha%d", i))
+	}
+
+	return commits
+}
+
+func TestOpenMergeRequestsHeadCommits(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		apiType      string
+		project      string
+		expectedPath string
+		tokenHeader  string
+		itemFormat   string
+		total        int
+	}{
+		{name: "GitLab", apiType: MergeRequestsAPIGitLab, project: "group/project", expectedPath: "/projects/group%2Fproject/merge_requests", tokenHeader: "PRIVATE-TOKEN", itemFormat: `{"iid":%[1]d,"sha":"sha%[1]d"}`, total: 3},
+		{name: "GitLab with the full page", apiType: MergeRequestsAPIGitLab, project: "42", expectedPath: "/projects/42/merge_requests", tokenHeader: "PRIVATE-TOKEN", itemFormat: `{"sha":"sha%d"}`, total: mergeRequestsPerPage},
+		{name: "GitHub with several pages", apiType: MergeRequestsAPIGitHub, project: "owner/repo", expectedPath: "/repos/owner/repo/pulls", tokenHeader: "Authorization", itemFormat: `{"number":%[1]d,"head":{"sha":"sha%[1]d"}}`, total: mergeRequestsPerPage + 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestMergeRequestsServer(t, tc.expectedPath, tc.tokenHeader, tc.total, tc.itemFormat)
+			defer server.Close()
+
+			api, err := NewMergeRequestsAPI(tc.apiType, server.URL+"/", tc.project, "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			commits, err := api.OpenMergeRequestsHeadCommits(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(commits, expectedCommits(tc.total)) {
+				t.Fatalf("unexpected commits %v", commits)
+			}
+		})
+	}
+}
+
+func TestOpenMergeRequestsHeadCommitsError(t *testing.T) {
+	server := newTestMergeRequestsServer(t, "/repos/owner/repo/pulls", "Authorization", 1, `{"head":{"sha":"sha%d"}}`)
+	defer server.Close()
+
+	api, err := NewMergeRequestsAPI(MergeRequestsAPIGitHub, server.URL, "owner/repo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.OpenMergeRequestsHeadCommits(context.Background()); err == nil {
+		t.Fatalf("expected the error of the unauthorized request")
+	}
+}
+
+func TestNewMergeRequestsAPI(t *testing.T) {
+	if _, err := NewMergeRequestsAPI(MergeRequestsAPIGitLab, "", "42", ""); err == nil {
+		t.Fatalf("expected the error without the GitLab API URL")
+	}
+
+	if _, err := NewMergeRequestsAPI("bitbucket", "https://api.bitbucket.org", "owner/repo", ""); err == nil {
+		t.Fatalf("expected the error of the unsupported API")
+	}
+
+	api, err := NewMergeRequestsAPI(MergeRequestsAPIGitHub, "", "owner/repo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.apiURL != defaultGitHubAPIURL {
+		t.Fatalf("expected the default GitHub API URL, got %q", api.apiURL)
+	}
+	if api.httpClient.Timeout == 0 {
+		t.Fatalf("expected the HTTP client timeout")
+	}
+}
@@ -3,8 +3,10 @@ package cleaning
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-git/go-git/v5"
@@ -24,8 +26,12 @@ import (
 	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/storage/manager"
 	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/util/parallel"
 )
 
+// rejectedStageGracePeriod protects the recently rejected stages, which might still be handled by the concurrent werf processes.
+const rejectedStageGracePeriod = 2 * time.Hour
+
 type CleanupOptions struct {
 	ImageNameList                           []string
 	LocalGit                                GitRepo
@@ -34,7 +40,10 @@ type CleanupOptions struct {
 	WithoutKube                             bool
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
-	DryRun                                  bool
+	KeepImagesSeenWithinLastNHours          uint64
+	// MergeRequestsAPI enables keeping the images of the open merge requests head commits (disabled if nil)
+	MergeRequestsAPI *allow_list.MergeRequestsAPI
+	DryRun           bool
 }
 
 func Cleanup(ctx context.Context, projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options CleanupOptions) error {
@@ -54,6 +63,8 @@ func newCleanupManager(projectName string, storageManager *manager.StorageManage
 		WithoutKube:                             options.WithoutKube,
 		GitHistoryBasedCleanupOptions:           options.GitHistoryBasedCleanupOptions,
 		KeepStagesBuiltWithinLastNHours:         options.KeepStagesBuiltWithinLastNHours,
+		KeepImagesSeenWithinLastNHours:          options.KeepImagesSeenWithinLastNHours,
+		MergeRequestsAPI:                        options.MergeRequestsAPI,
 	}
 }
 
@@ -62,6 +73,7 @@ type cleanupManager struct {
 
 	checksumSourceImageIDs       map[string][]string
 	nonexistentImportMetadataIDs []string
+	openMergeRequestsHeadCommits []string
 
 	ProjectName                             string
 	StorageManager                          manager.StorageManagerInterface
@@ -72,6 +84,8 @@ type cleanupManager struct {
 	WithoutKube                             bool
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
+	KeepImagesSeenWithinLastNHours          uint64
+	MergeRequestsAPI                        *allow_list.MergeRequestsAPI
 	DryRun                                  bool
 }
 
@@ -111,6 +125,14 @@ func (m *cleanupManager) run(ctx context.Context) error {
 		return err
 	}
 
+	if m.KeepImagesSeenWithinLastNHours != 0 {
+		if err := logboek.Context(ctx).LogProcess("Skipping repo tags that were seen in Kubernetes within last %d hours", m.KeepImagesSeenWithinLastNHours).DoError(func() error {
+			return m.skipStageIDsThatWereSeenRecently(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
 	if m.LocalGit != nil {
 		if !m.WithoutKube {
 			deployedDockerImagesNames, err := m.deployedDockerImagesNames(ctx)
@@ -131,6 +153,22 @@ func (m *cleanupManager) run(ctx context.Context) error {
 			}
 		}
 
+		if m.MergeRequestsAPI != nil {
+			if err := logboek.Context(ctx).LogProcess("Getting open merge requests head commits").DoError(func() error {
+				commits, err := m.MergeRequestsAPI.OpenMergeRequestsHeadCommits(ctx)
+				if err != nil {
+					return fmt.Errorf("unable to get open merge requests: %s", err)
+				}
+
+				m.openMergeRequestsHeadCommits = commits
+				logboek.Context(ctx).Default().LogF("Found %d open merge requests\n", len(commits))
+
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
 		if err := logboek.Context(ctx).LogProcess("Git history-based cleanup").DoError(func() error {
 			return m.gitHistoryBasedCleanup(ctx)
 		}); err != nil {
@@ -147,6 +185,24 @@ func (m *cleanupManager) run(ctx context.Context) error {
 		return err
 	}
 
+	if err := logboek.Context(ctx).LogProcess("Cleanup changed only records").DoError(func() error {
+		return m.cleanupChangedOnlyRecords(ctx)
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).LogProcess("Cleanup rejected stages").DoError(func() error {
+		return m.cleanupRejectedStages(ctx)
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).LogProcess("Cleanup stage cache salt records").DoError(func() error {
+		return m.cleanupStageCacheSaltRecords(ctx)
+	}); err != nil {
+		return err
+	}
+
 	if m.StorageManager.GetFinalStagesStorage() != nil {
 		if err := logboek.Context(ctx).LogProcess("Cleanup final stages").DoError(func() error {
 			return m.cleanupFinalStages(ctx)
@@ -205,6 +261,38 @@ Loop:
 	return nil
 }
 
+// skipStageIDsThatWereSeenRecently protects the repo and final repo tags by the usage records of the usage report.
+func (m *cleanupManager) skipStageIDsThatWereSeenRecently(ctx context.Context) error {
+	usageRecords, err := m.StorageManager.GetStagesStorage().GetUsageRecords(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get usage records: %s", err)
+	}
+
+	seenStageIDs := map[string]bool{}
+	for _, rec := range usageRecords {
+		if time.Since(rec.GetLastSeenAt()).Hours() <= float64(m.KeepImagesSeenWithinLastNHours) {
+			seenStageIDs[rec.StageID] = true
+		}
+	}
+
+	for _, stageID := range m.stageManager.GetStageIDList() {
+		if seenStageIDs[stageID] {
+			m.stageManager.MarkStageAsProtected(stageID)
+
+			logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
+			logboek.Context(ctx).LogOptionalLn()
+		}
+	}
+
+	for _, stageID := range m.stageManager.GetFinalStageIDList() {
+		if seenStageIDs[stageID] {
+			m.stageManager.MarkFinalStageAsProtected(stageID)
+		}
+	}
+
+	return nil
+}
+
 func (m *cleanupManager) deployedDockerImagesNames(ctx context.Context) ([]string, error) {
 	var deployedDockerImagesNames []string
 	for _, contextClient := range m.KubernetesContextClients {
@@ -240,26 +328,30 @@ func (m *cleanupManager) gitHistoryBasedCleanup(ctx context.Context) error {
 		return err
 	}
 
-	for imageName, stageIDCommitList := range m.stageManager.GetImageStageIDCommitListToCleanup() {
-		var reachedStageIDs []string
-		var hitStageIDCommitList map[string][]string
+	imageStageIDCommitList := m.stageManager.GetImageStageIDCommitListToCleanup()
+
+	var imageNames []string
+	for imageName := range imageStageIDCommitList {
+		imageNames = append(imageNames, imageName)
+	}
+	sort.Strings(imageNames)
+
+	scanResults, err := m.scanImagesReferencesHistory(ctx, git_history_based_cleanup.NewCommitGraph(gitRepository), referencesToScan, imageNames, imageStageIDCommitList)
+	if err != nil {
+		return err
+	}
+
+	for ind, imageName := range imageNames {
+		stageIDCommitList := imageStageIDCommitList[imageName]
+		reachedStageIDs := scanResults[ind].reachedStageIDs
+		hitStageIDCommitList := scanResults[ind].hitStageIDCommitList
+		reachedStageIDs, hitStageIDCommitList = m.addOpenMergeRequestsStageIDs(ctx, imageName, reachedStageIDs, hitStageIDCommitList)
+
 		if err := logboek.Context(ctx).LogProcess(logging.ImageLogProcessName(imageName, false)).DoError(func() error {
 			if logboek.Context(ctx).Streams().Width() > 90 {
 				m.printStageIDCommitListTable(ctx, imageName)
 			}
 
-			if err := logboek.Context(ctx).LogProcess("Scanning git references history").DoError(func() error {
-				if countStageIDCommitList(stageIDCommitList) != 0 {
-					reachedStageIDs, hitStageIDCommitList, err = git_history_based_cleanup.ScanReferencesHistory(ctx, gitRepository, referencesToScan, stageIDCommitList)
-				} else {
-					logboek.Context(ctx).LogLn("Scanning stopped due to nothing to seek")
-				}
-
-				return nil
-			}); err != nil {
-				return err
-			}
-
 			var stageIDToUnlink []string
 		outerLoop:
 			for stageID := range stageIDCommitList {
@@ -295,6 +387,94 @@ func (m *cleanupManager) gitHistoryBasedCleanup(ctx context.Context) error {
 	return nil
 }
 
+type imageReferencesHistoryScanResult struct {
+	reachedStageIDs      []string
+	hitStageIDCommitList map[string][]string
+}
+
+// scanImagesReferencesHistory scans the git references history for the images in parallel, the images share the commit graph, so each commit is read from the repository once.
+func (m *cleanupManager) scanImagesReferencesHistory(ctx context.Context, commitGraph *git_history_based_cleanup.CommitGraph, referencesToScan []*git_history_based_cleanup.ReferenceToScan, imageNames []string, imageStageIDCommitList map[string]map[string][]string) ([]*imageReferencesHistoryScanResult, error) {
+	results := make([]*imageReferencesHistoryScanResult, len(imageNames))
+	var scannedImagesCounter int64
+
+	if err := logboek.Context(ctx).Default().LogProcess("Scanning git references history (%d images)", len(imageNames)).DoError(func() error {
+		return parallel.DoTasks(ctx, len(imageNames), parallel.DoTasksOptions{
+			MaxNumberOfWorkers: m.StorageManager.MaxNumberOfWorkers(),
+		}, func(ctx context.Context, taskId int) error {
+			imageName := imageNames[taskId]
+			stageIDCommitList := imageStageIDCommitList[imageName]
+			result := &imageReferencesHistoryScanResult{}
+
+			if err := logboek.Context(ctx).Info().LogProcess(logging.ImageLogProcessName(imageName, false)).DoError(func() error {
+				if countStageIDCommitList(stageIDCommitList) == 0 {
+					logboek.Context(ctx).Info().LogLn("Scanning stopped due to nothing to seek")
+					return nil
+				}
+
+				var err error
+				result.reachedStageIDs, result.hitStageIDCommitList, err = git_history_based_cleanup.ScanReferencesHistory(ctx, commitGraph, referencesToScan, stageIDCommitList)
+				return err
+			}); err != nil {
+				return fmt.Errorf("unable to scan git references history for %s: %s", logging.ImageLogName(imageName, false), err)
+			}
+			results[taskId] = result
+
+			logboek.Context(ctx).Default().LogF(
+				"Scanned %s (%d/%d images, %d commits read)\n",
+				logging.ImageLogName(imageName, false),
+				atomic.AddInt64(&scannedImagesCounter, 1),
+				len(imageNames),
+				commitGraph.Size(),
+			)
+
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// addOpenMergeRequestsStageIDs keeps the stage IDs related to the head commits of the open merge requests along with the stage IDs reached by the git history scanning.
+func (m *cleanupManager) addOpenMergeRequestsStageIDs(ctx context.Context, imageName string, reachedStageIDs []string, hitStageIDCommitList map[string][]string) ([]string, map[string][]string) {
+	if len(m.openMergeRequestsHeadCommits) == 0 {
+		return reachedStageIDs, hitStageIDCommitList
+	}
+
+	resultHitStageIDCommitList := map[string][]string{}
+	for stageID, commitList := range hitStageIDCommitList {
+		resultHitStageIDCommitList[stageID] = commitList
+	}
+
+	var openMergeRequestsStageIDs []string
+	for _, stageIDCommitList := range []map[string][]string{
+		m.stageManager.GetStageIDCommitListToCleanup(imageName),
+		m.stageManager.GetStageIDNonexistentCommitList(imageName),
+	} {
+		for stageID, commitList := range stageIDCommitList {
+			for _, commit := range commitList {
+				if !util.IsStringsContainValue(m.openMergeRequestsHeadCommits, commit) {
+					continue
+				}
+
+				openMergeRequestsStageIDs = util.AddNewStringsToStringArray(openMergeRequestsStageIDs, stageID)
+				resultHitStageIDCommitList[stageID] = util.AddNewStringsToStringArray(resultHitStageIDCommitList[stageID], commit)
+			}
+		}
+	}
+
+	if len(openMergeRequestsStageIDs) != 0 {
+		logboek.Context(ctx).Default().LogBlock("Kept tags of open merge requests").Do(func() {
+			for _, stageID := range openMergeRequestsStageIDs {
+				logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
+			}
+		})
+	}
+
+	return util.AddNewStringsToStringArray(reachedStageIDs, openMergeRequestsStageIDs...), resultHitStageIDCommitList
+}
+
 func (m *cleanupManager) printStageIDCommitListTable(ctx context.Context, imageName string) {
 	if logboek.Context(ctx).Streams().ContentWidth() < 120 {
 		return
@@ -454,6 +634,10 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 	}
 
 	stageIDNonexistentCommitList := m.stageManager.GetStageIDNonexistentCommitList(imageName)
+	// the head commits of the merge requests from the forks are not fetched, but the metadata is required to keep the images on the next cleanups
+	for stageID, commitList := range stageIDNonexistentCommitList {
+		stageIDNonexistentCommitList[stageID] = util.ExcludeFromStringArray(commitList, m.openMergeRequestsHeadCommits...)
+	}
 	if countStageIDCommitList(stageIDNonexistentCommitList) != 0 {
 		header := fmt.Sprintf("Deleting metadata for nonexistent commits (%d)", countStageIDCommitList(stageIDNonexistentCommitList))
 
@@ -730,6 +914,200 @@ func deleteImportsMetadata(ctx context.Context, projectName string, storageManag
 	})
 }
 
+func (m *cleanupManager) cleanupRejectedStages(ctx context.Context) error {
+	rejectedStages, err := m.StorageManager.GetStagesStorage().GetRejectedStages(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get rejected stages: %s", err)
+	}
+
+	var rejectedStagesToDelete []*storage.RejectedStageRecord
+	var keptRejectedStages []*storage.RejectedStageRecord
+	for _, rec := range rejectedStages {
+		if time.Since(rec.RejectedAt) < rejectedStageGracePeriod {
+			keptRejectedStages = append(keptRejectedStages, rec)
+		} else {
+			rejectedStagesToDelete = append(rejectedStagesToDelete, rec)
+		}
+	}
+
+	if len(keptRejectedStages) != 0 {
+		logboek.Context(ctx).Default().LogBlock("Saved rejected stages within the grace period of %s (%d/%d)", rejectedStageGracePeriod, len(keptRejectedStages), len(rejectedStages)).Do(func() {
+			for _, rec := range keptRejectedStages {
+				logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", rec.String())
+				logboek.Context(ctx).Default().LogOptionalLn()
+			}
+		})
+	}
+
+	if len(rejectedStagesToDelete) == 0 {
+		logboek.Context(ctx).Default().LogLnDetails("No rejected stages to delete")
+		return nil
+	}
+
+	var deletedCount int64
+	if err := logboek.Context(ctx).Default().LogProcess("Deleting rejected stages").DoError(func() error {
+		if m.DryRun {
+			for _, rec := range rejectedStagesToDelete {
+				logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", rec.String())
+				logboek.Context(ctx).LogOptionalLn()
+			}
+			deletedCount = int64(len(rejectedStagesToDelete))
+			return nil
+		}
+
+		return m.StorageManager.ForEachDeleteRejectedStage(ctx, m.ProjectName, rejectedStagesToDelete, func(ctx context.Context, rec *storage.RejectedStageRecord, err error) error {
+			if err != nil {
+				if err := handleDeletionError(err); err != nil {
+					return err
+				}
+
+				logboek.Context(ctx).Warn().LogF("WARNING: Rejected stage %s deletion failed: %s\n", rec.String(), err)
+
+				return nil
+			}
+
+			atomic.AddInt64(&deletedCount, 1)
+			logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", rec.String())
+
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogFDetails("Deleted rejected stages: %d/%d\n", deletedCount, len(rejectedStages))
+
+	return nil
+}
+
+// cleanupStageCacheSaltRecords deletes the stage cache salt records superseded by the later invalidations of the same image stages.
+func (m *cleanupManager) cleanupStageCacheSaltRecords(ctx context.Context) error {
+	records, err := m.StorageManager.GetStagesStorage().GetStageCacheSaltRecords(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get stage cache salt records: %s", err)
+	}
+
+	_, outdatedRecords := storage.SplitStageCacheSaltRecords(records)
+	if len(outdatedRecords) == 0 {
+		logboek.Context(ctx).Default().LogLnDetails("No outdated stage cache salt records to delete")
+		return nil
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Deleting outdated stage cache salt records (%d/%d)", len(outdatedRecords), len(records)).DoError(func() error {
+		return deleteStageCacheSaltRecords(ctx, m.ProjectName, m.StorageManager, outdatedRecords, m.DryRun)
+	})
+}
+
+// cleanupChangedOnlyRecords deletes the changed only records of the stages which do not exist anymore.
+func (m *cleanupManager) cleanupChangedOnlyRecords(ctx context.Context) error {
+	inputsDigests, err := m.StorageManager.GetStagesStorage().GetChangedOnlyRecordsInputsDigests(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get changed only records: %s", err)
+	}
+
+	var records []*storage.ChangedOnlyRecord
+	var invalidInputsDigests []string
+	var mutex sync.Mutex
+	if err := m.StorageManager.ForEachGetChangedOnlyRecord(ctx, m.ProjectName, inputsDigests, func(ctx context.Context, inputsDigest string, rec *storage.ChangedOnlyRecord, err error) error {
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if rec == nil {
+			invalidInputsDigests = append(invalidInputsDigests, inputsDigest)
+		} else {
+			records = append(records, rec)
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to get changed only records: %s", err)
+	}
+
+	stageDescriptionList := m.stageManager.GetStageDescriptionList(stage_manager.StageDescriptionListOptions{})
+	inputsDigestsToDelete := append(invalidInputsDigests, selectStaleChangedOnlyRecordsInputsDigests(records, stageDescriptionList)...)
+	if len(inputsDigestsToDelete) == 0 {
+		logboek.Context(ctx).Default().LogLnDetails("No stale changed only records to delete")
+		return nil
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Deleting stale changed only records (%d/%d)", len(inputsDigestsToDelete), len(inputsDigests)).DoError(func() error {
+		return deleteChangedOnlyRecords(ctx, m.ProjectName, m.StorageManager, inputsDigestsToDelete, m.DryRun)
+	})
+}
+
+// selectStaleChangedOnlyRecordsInputsDigests returns the inputs digests of the records, which stages are not in the list.
+func selectStaleChangedOnlyRecordsInputsDigests(records []*storage.ChangedOnlyRecord, stageDescriptionList []*image.StageDescription) []string {
+	var res []string
+FilterOutRecords:
+	for _, rec := range records {
+		for _, stageDesc := range stageDescriptionList {
+			if stageDesc.StageID.IsEqual(rec.StageID) {
+				continue FilterOutRecords
+			}
+		}
+
+		res = append(res, rec.InputsDigest)
+	}
+
+	return res
+}
+
+func deleteChangedOnlyRecords(ctx context.Context, projectName string, storageManager manager.StorageManagerInterface, inputsDigests []string, dryRun bool) error {
+	if dryRun {
+		for _, inputsDigest := range inputsDigests {
+			logboek.Context(ctx).Default().LogFDetails("  inputsDigest: %s\n", inputsDigest)
+			logboek.Context(ctx).LogOptionalLn()
+		}
+		return nil
+	}
+
+	return storageManager.ForEachRmChangedOnlyRecord(ctx, projectName, inputsDigests, func(ctx context.Context, inputsDigest string, err error) error {
+		if err != nil {
+			if err := handleDeletionError(err); err != nil {
+				return err
+			}
+
+			logboek.Context(ctx).Warn().LogF("WARNING: Changed only record %s deletion failed: %s\n", inputsDigest, err)
+
+			return nil
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  inputsDigest: %s\n", inputsDigest)
+
+		return nil
+	})
+}
+
+func deleteStageCacheSaltRecords(ctx context.Context, projectName string, storageManager manager.StorageManagerInterface, records []*storage.StageCacheSaltRecord, dryRun bool) error {
+	if dryRun {
+		for _, rec := range records {
+			logboek.Context(ctx).Default().LogFDetails("  record: %s\n", rec.String())
+			logboek.Context(ctx).LogOptionalLn()
+		}
+		return nil
+	}
+
+	return storageManager.ForEachRmStageCacheSaltRecord(ctx, projectName, records, func(ctx context.Context, rec *storage.StageCacheSaltRecord, err error) error {
+		if err != nil {
+			if err := handleDeletionError(err); err != nil {
+				return err
+			}
+
+			logboek.Context(ctx).Warn().LogF("WARNING: Stage cache salt record %s deletion failed: %s\n", rec.String(), err)
+
+			return nil
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  record: %s\n", rec.String())
+
+		return nil
+	})
+}
+
 func (m *cleanupManager) excludeStageAndRelativesByImageID(stages []*image.StageDescription, imageID string) ([]*image.StageDescription, []*image.StageDescription) {
 	stage := findStageByImageID(stages, imageID)
 	if stage == nil {
@@ -775,9 +1153,30 @@ func (m *cleanupManager) excludeStageAndRelativesByStage(stages []*image.StageDe
 		}
 	}
 
+	// the separately cached Dockerfile stages (cacheStages directive) are used as the build cache of the stage
+	if cacheFromStageIDs := stage.Info.Labels[image.WerfCacheFromStageIDsLabel]; cacheFromStageIDs != "" {
+		for _, cacheFromStageID := range strings.Split(cacheFromStageIDs, ",") {
+			if cacheFromStage := findStageByStageID(stages, cacheFromStageID); cacheFromStage != nil {
+				var excludedCacheStages []*image.StageDescription
+				stages, excludedCacheStages = m.excludeStageAndRelativesByStage(stages, cacheFromStage)
+				excludedStages = append(excludedStages, excludedCacheStages...)
+			}
+		}
+	}
+
 	return stages, excludedStages
 }
 
+func findStageByStageID(stages []*image.StageDescription, stageID string) *image.StageDescription {
+	for _, stage := range stages {
+		if stage.StageID != nil && stage.StageID.String() == stageID {
+			return stage
+		}
+	}
+
+	return nil
+}
+
 func excludeStages(stages []*image.StageDescription, stagesToExclude ...*image.StageDescription) []*image.StageDescription {
 	var updatedStageList []*image.StageDescription
 
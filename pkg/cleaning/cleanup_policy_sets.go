@@ -0,0 +1,137 @@
+package cleaning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/cosign"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	CleanupPolicySetConfigMediaType = "application/vnd.werf.cleanup-policy-set.config.v1+json"
+	CleanupPolicySetLayerMediaType  = "application/vnd.werf.cleanup-policy-set.v1+yaml"
+)
+
+type cleanupPolicySetConfig struct {
+	WerfVersion string `json:"werfVersion"`
+	Created     string `json:"created"`
+}
+
+// PublishCleanupPolicySet validates the cleanup policy set and pushes it into the container registry as the OCI artifact.
+func PublishCleanupPolicySet(ctx context.Context, data []byte, ref string) error {
+	if _, err := config.ParseCleanupPolicySet(data, ref); err != nil {
+		return err
+	}
+
+	cfg, err := json.Marshal(cleanupPolicySetConfig{
+		WerfVersion: werf.Version,
+		Created:     time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	return docker_registry.API().PushArtifact(ctx, ref, &docker_registry.Artifact{
+		ConfigMediaType: CleanupPolicySetConfigMediaType,
+		Config:          cfg,
+		Layers: []container_registry_extensions.ArtifactLayer{
+			{MediaType: CleanupPolicySetLayerMediaType, Data: data},
+		},
+	})
+}
+
+// MergeCleanupPolicySets fetches and verifies the policy sets referenced in werf.yaml and returns the cleanup config
+// with the keep policies of the policy sets added to the local ones.
+// Images are kept if any of the policies matches, so the policy sets define the minimal retention which cannot be reduced locally.
+func MergeCleanupPolicySets(ctx context.Context, metaCleanup config.MetaCleanup) (config.MetaCleanup, error) {
+	if len(metaCleanup.PolicySets) == 0 {
+		return metaCleanup, nil
+	}
+
+	var keepPolicies []*config.MetaCleanupKeepPolicy
+	for _, policySet := range metaCleanup.PolicySets {
+		var policies []*config.MetaCleanupKeepPolicy
+		if err := logboek.Context(ctx).Default().LogProcess("Fetching cleanup policy set %s", policySet.Ref).DoError(func() error {
+			var err error
+			policies, err = fetchCleanupPolicySet(ctx, policySet)
+			if err != nil {
+				return err
+			}
+
+			for _, policy := range policies {
+				logboek.Context(ctx).Default().LogLnDetails(policy.String())
+			}
+
+			return nil
+		}); err != nil {
+			return metaCleanup, fmt.Errorf("unable to fetch cleanup policy set %s: %s", policySet.Ref, err)
+		}
+
+		keepPolicies = append(keepPolicies, policies...)
+	}
+
+	metaCleanup.KeepPolicies = append(keepPolicies, metaCleanup.KeepPolicies...)
+
+	return metaCleanup, nil
+}
+
+func fetchCleanupPolicySet(ctx context.Context, policySet *config.MetaCleanupPolicySet) ([]*config.MetaCleanupKeepPolicy, error) {
+	ref, err := getCleanupPolicySetDigestRef(ctx, policySet)
+	if err != nil {
+		return nil, err
+	}
+
+	if policySet.PublicKey != "" {
+		if err := cosign.Verify(ctx, ref, policySet.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	artifact, err := docker_registry.API().PullArtifact(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if artifact.ConfigMediaType != CleanupPolicySetConfigMediaType {
+		return nil, fmt.Errorf("unexpected artifact config mediatype %q, expected %q", artifact.ConfigMediaType, CleanupPolicySetConfigMediaType)
+	}
+
+	for _, layer := range artifact.Layers {
+		if layer.MediaType == CleanupPolicySetLayerMediaType {
+			return config.ParseCleanupPolicySet(layer.Data, policySet.Ref)
+		}
+	}
+
+	return nil, fmt.Errorf("manifest does not contain a layer with mediatype %s", CleanupPolicySetLayerMediaType)
+}
+
+// getCleanupPolicySetDigestRef returns the reference of the policy set pinned to the digest: the signature is verified and the artifact is pulled by the same digest,
+// so the tag cannot be moved to another artifact in between. The registry client verifies that the content matches the digest when the artifact is pulled by digest.
+func getCleanupPolicySetDigestRef(ctx context.Context, policySet *config.MetaCleanupPolicySet) (string, error) {
+	parsedRef, err := name.ParseReference(policySet.Ref, name.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %v", policySet.Ref, err)
+	}
+
+	digest := policySet.Digest
+	if digest == "" {
+		digest, err = docker_registry.API().GetArtifactDigest(ctx, policySet.Ref)
+		if err != nil {
+			return "", err
+		}
+
+		logboek.Context(ctx).Default().LogF("Using digest %s\n", digest)
+	}
+
+	return parsedRef.Context().Digest(digest).String(), nil
+}
@@ -0,0 +1,53 @@
+package cleaning
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func TestSelectStaleChangedOnlyRecordsInputsDigests(t *testing.T) {
+	existingStageID := image.StageID{Digest: "digest-1", UniqueID: 1611836746968}
+	stageDescriptionList := []*image.StageDescription{
+		{StageID: &existingStageID},
+		{StageID: &image.StageID{Digest: "digest-2", UniqueID: 1611836746969}},
+	}
+
+	records := []*storage.ChangedOnlyRecord{
+		{InputsDigest: "existing", StageID: existingStageID},
+		{InputsDigest: "deleted-stage", StageID: image.StageID{Digest: "digest-3", UniqueID: 1611836746970}},
+		{InputsDigest: "other-unique-id", StageID: image.StageID{Digest: "digest-2", UniqueID: 1611836746970}},
+	}
+
+	expected := []string{"deleted-stage", "other-unique-id"}
+	if res := selectStaleChangedOnlyRecordsInputsDigests(records, stageDescriptionList); !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+
+	if res := selectStaleChangedOnlyRecordsInputsDigests(records, nil); len(res) != len(records) {
+		t.Fatalf("expected all records to be stale without stages, got %v", res)
+	}
+}
+
+func TestCleanupManager_ExcludeStageAndRelativesByStage_CacheFromStages(t *testing.T) {
+	newStage := func(digest, id string, labels map[string]string) *image.StageDescription {
+		return &image.StageDescription{StageID: &image.StageID{Digest: digest, UniqueID: 1611836746968}, Info: &image.Info{ID: id, Labels: labels}}
+	}
+
+	depsCacheStage := newStage("deps", "deps-id", nil)
+	builderCacheStage := newStage("builder", "builder-id", map[string]string{image.WerfCacheFromStageIDsLabel: depsCacheStage.StageID.String()})
+	dockerfileStage := newStage("dockerfile", "dockerfile-id", map[string]string{image.WerfCacheFromStageIDsLabel: builderCacheStage.StageID.String() + ",missing-1611836746968"})
+	otherStage := newStage("other", "other-id", nil)
+
+	m := &cleanupManager{}
+	stages, excludedStages := m.excludeStageAndRelativesByStage([]*image.StageDescription{otherStage, depsCacheStage, builderCacheStage, dockerfileStage}, dockerfileStage)
+
+	if expected := []*image.StageDescription{otherStage}; !reflect.DeepEqual(stages, expected) {
+		t.Fatalf("expected remaining stages %v, got %v", expected, stages)
+	}
+	if expected := []*image.StageDescription{dockerfileStage, builderCacheStage, depsCacheStage}; !reflect.DeepEqual(excludedStages, expected) {
+		t.Fatalf("expected excluded stages %v, got %v", expected, excludedStages)
+	}
+}
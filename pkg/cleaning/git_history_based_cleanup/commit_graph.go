@@ -0,0 +1,53 @@
+package git_history_based_cleanup
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// CommitGraph is the commit objects cache shared by the references history scans of the images.
+// The go-git repository is not safe for concurrent reads, so each commit is read from the repository once under the lock
+// and the images can be scanned in parallel mostly without touching the repository.
+type CommitGraph struct {
+	gitRepository *git.Repository
+
+	mux     sync.Mutex
+	commits map[string]*object.Commit
+}
+
+func NewCommitGraph(gitRepository *git.Repository) *CommitGraph {
+	return &CommitGraph{
+		gitRepository: gitRepository,
+		commits:       map[string]*object.Commit{},
+	}
+}
+
+// CommitObject returns the commit object, which must be used read-only.
+func (g *CommitGraph) CommitObject(commit string) (*object.Commit, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if co, ok := g.commits[commit]; ok {
+		return co, nil
+	}
+
+	co, err := g.gitRepository.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, fmt.Errorf("commit hash %s resolve failed: %s", commit, err)
+	}
+	g.commits[commit] = co
+
+	return co, nil
+}
+
+// Size returns the number of the commits read from the repository.
+func (g *CommitGraph) Size() int {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	return len(g.commits)
+}
@@ -7,8 +7,6 @@ import (
 	"sort"
 	"time"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 
 	"github.com/werf/logboek"
@@ -17,7 +15,7 @@ import (
 	"github.com/werf/werf/pkg/util"
 )
 
-func ScanReferencesHistory(ctx context.Context, gitRepository *git.Repository, refs []*ReferenceToScan, expectedStageIDCommitList map[string][]string) ([]string, map[string][]string, error) {
+func ScanReferencesHistory(ctx context.Context, commitGraph *CommitGraph, refs []*ReferenceToScan, expectedStageIDCommitList map[string][]string) ([]string, map[string][]string, error) {
 	var reachedStageIDs []string
 	var stopCommitList []string
 	stageIDHitCommitList := map[string][]string{}
@@ -38,7 +36,7 @@ func ScanReferencesHistory(ctx context.Context, gitRepository *git.Repository, r
 		}
 
 		if err := logboek.Context(ctx).Info().LogProcess(logProcessMessage).DoError(func() error {
-			refReachedStageIDs, refStopCommitList, refStageIDHitCommitList, err = scanReferenceHistory(ctx, gitRepository, ref, expectedStageIDCommitList, stopCommitList)
+			refReachedStageIDs, refStopCommitList, refStageIDHitCommitList, err = scanReferenceHistory(ctx, commitGraph, ref, expectedStageIDCommitList, stopCommitList)
 			if err != nil {
 				return fmt.Errorf("scan reference history failed: %s", err)
 			}
@@ -65,7 +63,7 @@ func ScanReferencesHistory(ctx context.Context, gitRepository *git.Repository, r
 	return reachedStageIDs, stageIDHitCommitList, nil
 }
 
-func applyImagesCleanupInPolicy(gitRepository *git.Repository, stageIDCommitList map[string][]string, in *time.Duration) map[string][]string {
+func applyImagesCleanupInPolicy(commitGraph *CommitGraph, stageIDCommitList map[string][]string, in *time.Duration) map[string][]string {
 	if in == nil {
 		return stageIDCommitList
 	}
@@ -74,8 +72,7 @@ func applyImagesCleanupInPolicy(gitRepository *git.Repository, stageIDCommitList
 	for stageID, commitList := range stageIDCommitList {
 		var resultCommitList []string
 		for _, commit := range commitList {
-			commitHash := plumbing.NewHash(commit)
-			c, err := gitRepository.CommitObject(commitHash)
+			c, err := commitGraph.CommitObject(commit)
 			if err != nil {
 				panic("unexpected condition")
 			}
@@ -94,7 +91,7 @@ func applyImagesCleanupInPolicy(gitRepository *git.Repository, stageIDCommitList
 }
 
 type commitHistoryScanner struct {
-	gitRepository             *git.Repository
+	commitGraph               *CommitGraph
 	expectedStageIDCommitList map[string][]string
 	reachedStageIDCommitList  map[string][]string
 	reachedCommitList         []string
@@ -116,8 +113,8 @@ func (s *commitHistoryScanner) reachedStageIDList() []string {
 	return reachedStageIDList
 }
 
-func scanReferenceHistory(ctx context.Context, gitRepository *git.Repository, ref *ReferenceToScan, expectedStageIDCommitList map[string][]string, stopCommitList []string) ([]string, []string, map[string][]string, error) {
-	filteredExpectedStageIDCommitList := applyImagesCleanupInPolicy(gitRepository, expectedStageIDCommitList, ref.imagesCleanupKeepPolicy.In)
+func scanReferenceHistory(ctx context.Context, commitGraph *CommitGraph, ref *ReferenceToScan, expectedStageIDCommitList map[string][]string, stopCommitList []string) ([]string, []string, map[string][]string, error) {
+	filteredExpectedStageIDCommitList := applyImagesCleanupInPolicy(commitGraph, expectedStageIDCommitList, ref.imagesCleanupKeepPolicy.In)
 
 	refExpectedStageIDCommitList := map[string][]string{}
 	isImagesCleanupKeepPolicyOnlyInOrAndBoth := ref.imagesCleanupKeepPolicy.Last == nil || (ref.imagesCleanupKeepPolicy.Operator != nil && *ref.imagesCleanupKeepPolicy.Operator == config.AndOperator)
@@ -133,7 +130,7 @@ func scanReferenceHistory(ctx context.Context, gitRepository *git.Repository, re
 	}
 
 	s := &commitHistoryScanner{
-		gitRepository:             gitRepository,
+		commitGraph:               commitGraph,
 		expectedStageIDCommitList: refExpectedStageIDCommitList,
 		reachedStageIDCommitList:  map[string][]string{},
 		stopCommitList:            stopCommitList,
@@ -312,8 +309,12 @@ func (s *commitHistoryScanner) handleExtraStageIDsByLast(ctx context.Context, la
 	return reachedStageIDList, s.stopCommitList, stageIDHitCommitList, nil
 }
 
+// scanProgressCommitsStep is the number of the scanned commits between the progress messages.
+const scanProgressCommitsStep = 10000
+
 func (s *commitHistoryScanner) scanCommitHistory(ctx context.Context, commit string) error {
 	var currentIteration, nextIteration []string
+	var scannedCommitsCounter int
 
 	currentIteration = append(currentIteration, commit)
 	for {
@@ -334,6 +335,11 @@ func (s *commitHistoryScanner) scanCommitHistory(ctx context.Context, commit str
 				return err
 			}
 
+			scannedCommitsCounter++
+			if scannedCommitsCounter%scanProgressCommitsStep == 0 {
+				logboek.Context(ctx).Info().LogF("Scanned %d commits (depth %d)\n", scannedCommitsCounter, s.scanDepth)
+			}
+
 			if s.scanDepth == s.referenceScanOptions.scanDepthLimit {
 				logboek.Context(ctx).Debug().LogF("Stop scanning commit history %s due to scanDepthLimit (%d)\n", commit, s.referenceScanOptions.scanDepthLimit)
 				continue
@@ -367,7 +373,7 @@ outerLoop:
 		for _, c := range commitList {
 			if c == commit {
 				if s.imagesCleanupKeepPolicy.In != nil {
-					commit, err := s.gitRepository.CommitObject(plumbing.NewHash(commit))
+					commit, err := s.commitGraph.CommitObject(commit)
 					if err != nil {
 						panic("unexpected condition")
 					}
@@ -411,9 +417,9 @@ outerLoop:
 		s.reachedCommitList = append(s.reachedCommitList, commit)
 	}
 
-	co, err := s.gitRepository.CommitObject(plumbing.NewHash(commit))
+	co, err := s.commitGraph.CommitObject(commit)
 	if err != nil {
-		return nil, fmt.Errorf("commit hash %s resolve failed: %s", commit, err)
+		return nil, err
 	}
 
 	var parentHashes []string
@@ -456,7 +462,7 @@ func (s *commitHistoryScanner) latestCommitStageIDs() map[*object.Commit][]strin
 			var err error
 			var ok bool
 			if commitObject, ok = commitObjectCache[commit]; !ok {
-				commitObject, err = s.gitRepository.CommitObject(plumbing.NewHash(commit))
+				commitObject, err = s.commitGraph.CommitObject(commit)
 				if err != nil {
 					panic("unexpected condition")
 				}
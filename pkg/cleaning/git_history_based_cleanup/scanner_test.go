@@ -0,0 +1,163 @@
+package git_history_based_cleanup
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// newTestRepository creates the in-memory repository with the linear history of the commits and returns the commits from the oldest one.
+func newTestRepository(t *testing.T, commitsNumber int) (*git.Repository, []string) {
+	fs := memfs.New()
+	repository, err := git.Init(memory.NewStorage(), fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var commits []string
+	for i := 0; i < commitsNumber; i++ {
+		f, err := fs.Create("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(fmt.Sprintf("content %d", i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := worktree.Add("file"); err != nil {
+			t.Fatal(err)
+		}
+
+		hash, err := worktree.Commit(fmt.Sprintf("commit %d", i), &git.CommitOptions{
+			Author: &object.Signature{Name: "werf", Email: "werf@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		commits = append(commits, hash.String())
+	}
+
+	return repository, commits
+}
+
+func newTestReferenceToScan(t *testing.T, repository *git.Repository, headCommit string) *ReferenceToScan {
+	co, err := repository.CommitObject(plumbing.NewHash(headCommit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ReferenceToScan{
+		Reference:            plumbing.NewHashReference("refs/remotes/origin/master", co.Hash),
+		CreatedAt:            co.Committer.When,
+		HeadCommit:           co,
+		referenceScanOptions: referenceScanOptions{scanDepthLimit: -1},
+	}
+}
+
+func TestCommitGraph_CommitObject(t *testing.T) {
+	repository, commits := newTestRepository(t, 3)
+	commitGraph := NewCommitGraph(repository)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, commit := range commits {
+				co, err := commitGraph.CommitObject(commit)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if co.Hash.String() != commit {
+					t.Errorf("expected commit %s, got %s", commit, co.Hash.String())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if size := commitGraph.Size(); size != len(commits) {
+		t.Fatalf("expected each commit to be read once, got %d commits read", size)
+	}
+
+	if _, err := commitGraph.CommitObject("0000000000000000000000000000000000000000"); err == nil {
+		t.Fatal("expected error for the missing commit")
+	}
+
+	if size := commitGraph.Size(); size != len(commits) {
+		t.Fatalf("expected the missing commit not to be cached, got %d commits read", size)
+	}
+}
+
+func TestScanReferencesHistory_SharedCommitGraph(t *testing.T) {
+	repository, commits := newTestRepository(t, 5)
+	refs := []*ReferenceToScan{newTestReferenceToScan(t, repository, commits[4])}
+	commitGraph := NewCommitGraph(repository)
+
+	imagesStageIDCommitList := []map[string][]string{
+		{"stage-1": {commits[1]}, "stage-2": {"1111111111111111111111111111111111111111"}},
+		{"stage-3": {commits[3]}},
+		{"stage-4": {commits[0], commits[2]}},
+	}
+
+	expectedReachedStageIDs := [][]string{{"stage-1"}, {"stage-3"}, {"stage-4"}}
+	expectedHitStageIDCommitList := []map[string][]string{
+		{"stage-1": {commits[1]}},
+		{"stage-3": {commits[3]}},
+		// the scanning stops when all the expected stages are reached
+		{"stage-4": {commits[2]}},
+	}
+
+	reachedStageIDs := make([][]string, len(imagesStageIDCommitList))
+	hitStageIDCommitList := make([]map[string][]string, len(imagesStageIDCommitList))
+
+	var wg sync.WaitGroup
+	for ind := range imagesStageIDCommitList {
+		wg.Add(1)
+		go func(ind int) {
+			defer wg.Done()
+
+			var err error
+			reachedStageIDs[ind], hitStageIDCommitList[ind], err = ScanReferencesHistory(context.Background(), commitGraph, refs, imagesStageIDCommitList[ind])
+			if err != nil {
+				t.Error(err)
+			}
+		}(ind)
+	}
+	wg.Wait()
+
+	for ind := range imagesStageIDCommitList {
+		if !reflect.DeepEqual(reachedStageIDs[ind], expectedReachedStageIDs[ind]) {
+			t.Errorf("image %d: expected reached stages %v, got %v", ind, expectedReachedStageIDs[ind], reachedStageIDs[ind])
+		}
+
+		if !reflect.DeepEqual(hitStageIDCommitList[ind], expectedHitStageIDCommitList[ind]) {
+			t.Errorf("image %d: expected hit commits %v, got %v", ind, expectedHitStageIDCommitList[ind], hitStageIDCommitList[ind])
+		}
+	}
+
+	if size := commitGraph.Size(); size != len(commits) {
+		t.Fatalf("expected the images to share the read commits, got %d commits read", size)
+	}
+}
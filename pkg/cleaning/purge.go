@@ -2,6 +2,9 @@ package cleaning
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/werf/logboek"
 
@@ -14,6 +17,8 @@ import (
 type PurgeOptions struct {
 	RmContainersThatUseWerfImages bool
 	DryRun                        bool
+	// Force allows deleting the repo content when the repo contains the stages of the other projects
+	Force bool
 }
 
 func Purge(ctx context.Context, projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options PurgeOptions) error {
@@ -26,6 +31,7 @@ func newPurgeManager(projectName string, storageManager *manager.StorageManager,
 		ProjectName:                   projectName,
 		RmContainersThatUseWerfImages: options.RmContainersThatUseWerfImages,
 		DryRun:                        options.DryRun,
+		Force:                         options.Force,
 	}
 }
 
@@ -34,15 +40,29 @@ type purgeManager struct {
 	ProjectName                   string
 	RmContainersThatUseWerfImages bool
 	DryRun                        bool
+	Force                         bool
 }
 
 func (m *purgeManager) run(ctx context.Context) error {
-	if err := logboek.Context(ctx).Default().LogProcess("Deleting stages").DoError(func() error {
-		stages, err := m.StorageManager.GetStageDescriptionList(ctx)
+	stages, err := m.StorageManager.GetStageDescriptionList(ctx)
+	if err != nil {
+		return err
+	}
+
+	var finalStages []*image.StageDescription
+	if m.StorageManager.GetFinalStagesStorage() != nil {
+		finalStages, err = m.StorageManager.GetFinalStageDescriptionList(ctx)
 		if err != nil {
 			return err
 		}
+	}
 
+	// nothing is deleted until both repos are checked
+	if err := m.checkOtherProjectsStages(ctx, append(append([]*image.StageDescription{}, stages...), finalStages...)); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Deleting stages").DoError(func() error {
 		return m.deleteStages(ctx, stages, false)
 	}); err != nil {
 		return err
@@ -59,6 +79,28 @@ func (m *purgeManager) run(ctx context.Context) error {
 		return err
 	}
 
+	if err := logboek.Context(ctx).Default().LogProcess("Deleting changed only records").DoError(func() error {
+		inputsDigests, err := m.StorageManager.GetStagesStorage().GetChangedOnlyRecordsInputsDigests(ctx, m.ProjectName)
+		if err != nil {
+			return err
+		}
+
+		return deleteChangedOnlyRecords(ctx, m.ProjectName, m.StorageManager, inputsDigests, m.DryRun)
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Deleting stage cache salt records").DoError(func() error {
+		records, err := m.StorageManager.GetStagesStorage().GetStageCacheSaltRecords(ctx, m.ProjectName)
+		if err != nil {
+			return err
+		}
+
+		return deleteStageCacheSaltRecords(ctx, m.ProjectName, m.StorageManager, records, m.DryRun)
+	}); err != nil {
+		return err
+	}
+
 	if err := logboek.Context(ctx).Default().LogProcess("Deleting managed images").DoError(func() error {
 		managedImages, err := m.StorageManager.GetStagesStorage().GetManagedImages(ctx, m.ProjectName)
 		if err != nil {
@@ -93,12 +135,7 @@ func (m *purgeManager) run(ctx context.Context) error {
 
 	if m.StorageManager.GetFinalStagesStorage() != nil {
 		if err := logboek.Context(ctx).Default().LogProcess("Deleting final stages").DoError(func() error {
-			stages, err := m.StorageManager.GetStageDescriptionList(ctx)
-			if err != nil {
-				return err
-			}
-
-			return m.deleteStages(ctx, stages, true)
+			return m.deleteStages(ctx, finalStages, true)
 		}); err != nil {
 			return err
 		}
@@ -107,6 +144,44 @@ func (m *purgeManager) run(ctx context.Context) error {
 	return nil
 }
 
+// checkOtherProjectsStages refuses to purge the repo and the final repo, which contain the stages built by the other projects (e.g. several projects share one repo address by mistake), unless forced.
+func (m *purgeManager) checkOtherProjectsStages(ctx context.Context, stages []*image.StageDescription) error {
+	stagesCountByProject := map[string]int{}
+	var total int
+	for _, stageDesc := range stages {
+		if stageDesc.Info == nil {
+			continue
+		}
+
+		if projectName := stageDesc.Info.Labels[image.WerfLabel]; projectName != "" && projectName != m.ProjectName {
+			stagesCountByProject[projectName]++
+			total++
+		}
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	var projectNames []string
+	for projectName := range stagesCountByProject {
+		projectNames = append(projectNames, projectName)
+	}
+	sort.Strings(projectNames)
+
+	logboek.Context(ctx).Warn().LogF("Found %d stage(s) of the other projects in the repo or the final repo:\n", total)
+	for _, projectName := range projectNames {
+		logboek.Context(ctx).Warn().LogF(" - %s: %d stage(s)\n", projectName, stagesCountByProject[projectName])
+	}
+
+	if m.Force {
+		logboek.Context(ctx).Warn().LogF("WARNING: The stages of the other projects will be deleted (--force)\n")
+		return nil
+	}
+
+	return fmt.Errorf("the repo contains the stages of the other projects %s: check the repo address or use --force to delete them along with the project %q stages", strings.Join(projectNames, ", "), m.ProjectName)
+}
+
 func (m *purgeManager) deleteStages(ctx context.Context, stages []*image.StageDescription, isFinal bool) error {
 	deleteStageOptions := manager.ForEachDeleteStageOptions{
 		DeleteImageOptions: storage.DeleteImageOptions{
@@ -0,0 +1,39 @@
+package cleaning
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func newPurgeTestStage(projectName string) *image.StageDescription {
+	return &image.StageDescription{
+		StageID: &image.StageID{Digest: "digest", UniqueID: 1611836746968},
+		Info:    &image.Info{Labels: map[string]string{image.WerfLabel: projectName}},
+	}
+}
+
+func TestPurgeManager_CheckOtherProjectsStages(t *testing.T) {
+	ctx := context.Background()
+
+	ownStages := []*image.StageDescription{newPurgeTestStage("project"), newPurgeTestStage(""), {StageID: &image.StageID{Digest: "digest"}}}
+	if err := (&purgeManager{ProjectName: "project"}).checkOtherProjectsStages(ctx, ownStages); err != nil {
+		t.Fatalf("unexpected error for the project stages: %s", err)
+	}
+
+	stages := append(ownStages, newPurgeTestStage("other-b"), newPurgeTestStage("other-a"), newPurgeTestStage("other-b"))
+
+	err := (&purgeManager{ProjectName: "project"}).checkOtherProjectsStages(ctx, stages)
+	if err == nil {
+		t.Fatal("expected error for the stages of the other projects")
+	}
+	if !strings.Contains(err.Error(), "other-a, other-b") {
+		t.Fatalf("expected the sorted other projects in the error, got: %s", err)
+	}
+
+	if err := (&purgeManager{ProjectName: "project", Force: true}).checkOtherProjectsStages(ctx, stages); err != nil {
+		t.Fatalf("unexpected error with force: %s", err)
+	}
+}
@@ -0,0 +1,165 @@
+package cleaning
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/rodaine/table"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/cleaning/allow_list"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+)
+
+type UsageReportOptions struct {
+	KubernetesContextClients                []*kube.ContextClient
+	KubernetesNamespaceRestrictionByContext map[string]string
+	DryRun                                  bool
+}
+
+// UsageReportRecord describes when the repo tag has been seen running in Kubernetes last time.
+type UsageReportRecord struct {
+	Tag        string
+	LastSeenAt time.Time
+	Contexts   []string // the Kubernetes contexts where the tag is running now
+}
+
+// UsageReport scans Kubernetes clusters for the images of the repo and the final repo,
+// updates the usage records in the repo and returns the per-tag last-seen report.
+func UsageReport(ctx context.Context, projectName string, storageManager *manager.StorageManager, options UsageReportOptions) ([]*UsageReportRecord, error) {
+	stagesStorage := storageManager.GetStagesStorage()
+
+	existingTags := map[string]bool{}
+	var repoAddressList []string
+	for _, repoStagesStorage := range []storage.StagesStorage{stagesStorage, storageManager.GetFinalStagesStorage()} {
+		if repoStagesStorage == nil {
+			continue
+		}
+
+		stageIDs, err := repoStagesStorage.GetStagesIDs(ctx, projectName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get repo %s stages: %s", repoStagesStorage.Address(), err)
+		}
+
+		for _, stageID := range stageIDs {
+			existingTags[stageID.String()] = true
+		}
+
+		repoAddressList = append(repoAddressList, repoStagesStorage.Address())
+	}
+
+	contextsByTag := map[string][]string{}
+	for _, contextClient := range options.KubernetesContextClients {
+		if err := logboek.Context(ctx).LogProcessInline("Getting deployed docker images (context %s)", contextClient.ContextName).
+			DoError(func() error {
+				deployedDockerImagesNames, err := allow_list.DeployedDockerImages(contextClient.Client, options.KubernetesNamespaceRestrictionByContext[contextClient.ContextName])
+				if err != nil {
+					return fmt.Errorf("cannot get deployed images: %s", err)
+				}
+
+				handledTags := map[string]bool{}
+				for _, deployedDockerImageName := range deployedDockerImagesNames {
+					tag := getRepoTagByDockerImageName(repoAddressList, deployedDockerImageName)
+					if tag == "" || !existingTags[tag] || handledTags[tag] {
+						continue
+					}
+
+					contextsByTag[tag] = append(contextsByTag[tag], contextClient.ContextName)
+					handledTags[tag] = true
+				}
+
+				return nil
+			}); err != nil {
+			return nil, err
+		}
+	}
+
+	usageRecords, err := stagesStorage.GetUsageRecords(ctx, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get usage records: %s", err)
+	}
+
+	now := time.Unix(time.Now().Unix(), 0)
+
+	lastSeenAtByTag := map[string]time.Time{}
+	var outdatedUsageRecords []*storage.UsageRecord
+	for _, rec := range usageRecords {
+		// records of the deleted tags and previous records of the tags seen now are not needed anymore
+		if !existingTags[rec.StageID] || (contextsByTag[rec.StageID] != nil && rec.LastSeenTimestamp != now.Unix()) {
+			outdatedUsageRecords = append(outdatedUsageRecords, rec)
+			continue
+		}
+
+		if lastSeenAt, ok := lastSeenAtByTag[rec.StageID]; !ok || rec.GetLastSeenAt().After(lastSeenAt) {
+			lastSeenAtByTag[rec.StageID] = rec.GetLastSeenAt()
+		}
+	}
+
+	for tag := range contextsByTag {
+		lastSeenAtByTag[tag] = now
+	}
+
+	if !options.DryRun {
+		if err := logboek.Context(ctx).Info().LogProcess("Saving usage records (%d)", len(contextsByTag)).DoError(func() error {
+			for tag := range contextsByTag {
+				if err := stagesStorage.PostUsageRecord(ctx, projectName, &storage.UsageRecord{StageID: tag, LastSeenTimestamp: now.Unix()}); err != nil {
+					return fmt.Errorf("unable to save usage record for tag %s: %s", tag, err)
+				}
+			}
+
+			for _, rec := range outdatedUsageRecords {
+				if err := stagesStorage.RmUsageRecord(ctx, projectName, rec); err != nil {
+					return fmt.Errorf("unable to remove usage record %s: %s", rec, err)
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var report []*UsageReportRecord
+	for tag, lastSeenAt := range lastSeenAtByTag {
+		report = append(report, &UsageReportRecord{Tag: tag, LastSeenAt: lastSeenAt, Contexts: contextsByTag[tag]})
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].LastSeenAt.Equal(report[j].LastSeenAt) {
+			return report[i].Tag < report[j].Tag
+		}
+		return report[i].LastSeenAt.After(report[j].LastSeenAt)
+	})
+
+	return report, nil
+}
+
+func PrintUsageReport(ctx context.Context, report []*UsageReportRecord) {
+	tbl := table.New("Tag", "Last seen", "Running in")
+	tbl.WithWriter(logboek.Context(ctx).OutStream())
+	tbl.WithHeaderFormatter(func(format string, a ...interface{}) string {
+		return logboek.ColorizeF(color.New(color.OpUnderscore), format, a...)
+	})
+	for _, rec := range report {
+		tbl.AddRow(rec.Tag, rec.LastSeenAt.Format(time.RFC3339), strings.Join(rec.Contexts, ", "))
+	}
+	tbl.Print()
+}
+
+// getRepoTagByDockerImageName returns the tag of the docker image from one of the repos, or empty string if the image is from another repo.
+func getRepoTagByDockerImageName(repoAddressList []string, dockerImageName string) string {
+	for _, repoAddress := range repoAddressList {
+		if strings.HasPrefix(dockerImageName, repoAddress+":") {
+			return strings.TrimPrefix(dockerImageName, repoAddress+":")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,244 @@
+package cleaning
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/werf/werf/pkg/cleaning/stage_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+)
+
+type testUsageStagesStorage struct {
+	storage.StagesStorage
+
+	address      string
+	stageIDs     []image.StageID
+	usageRecords []*storage.UsageRecord
+
+	postedUsageRecords  []*storage.UsageRecord
+	removedUsageRecords []*storage.UsageRecord
+}
+
+func (s *testUsageStagesStorage) Address() string {
+	return s.address
+}
+
+func (s *testUsageStagesStorage) GetStagesIDs(_ context.Context, _ string) ([]image.StageID, error) {
+	return s.stageIDs, nil
+}
+
+func (s *testUsageStagesStorage) GetUsageRecords(_ context.Context, _ string) ([]*storage.UsageRecord, error) {
+	return s.usageRecords, nil
+}
+
+func (s *testUsageStagesStorage) PostUsageRecord(_ context.Context, _ string, rec *storage.UsageRecord) error {
+	s.postedUsageRecords = append(s.postedUsageRecords, rec)
+	return nil
+}
+
+func (s *testUsageStagesStorage) RmUsageRecord(_ context.Context, _ string, rec *storage.UsageRecord) error {
+	s.removedUsageRecords = append(s.removedUsageRecords, rec)
+	return nil
+}
+
+func newUsageReportTestPod(name string, images ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	for _, img := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name, Image: img})
+	}
+	return pod
+}
+
+func TestUsageReport(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+	running := image.StageID{Digest: "running", UniqueID: 1611836746968}
+	idle := image.StageID{Digest: "idle", UniqueID: 1611836746968}
+	final := image.StageID{Digest: "final", UniqueID: 1611836746968}
+
+	idleRecord := &storage.UsageRecord{StageID: idle.String(), LastSeenTimestamp: 1000}
+	previousRunningRecord := &storage.UsageRecord{StageID: running.String(), LastSeenTimestamp: 1000}
+	deletedRecord := &storage.UsageRecord{StageID: "deleted-1611836746968", LastSeenTimestamp: 1000}
+
+	stagesStorage := &testUsageStagesStorage{
+		address:      "registry.example.com/repo",
+		stageIDs:     []image.StageID{running, idle},
+		usageRecords: []*storage.UsageRecord{idleRecord, previousRunningRecord, deletedRecord},
+	}
+	finalStagesStorage := &testUsageStagesStorage{
+		address:  "registry.example.com/final",
+		stageIDs: []image.StageID{final},
+	}
+	storageManager := manager.NewStorageManager("project", stagesStorage, finalStagesStorage, nil, nil, nil, nil)
+
+	options := UsageReportOptions{KubernetesContextClients: []*kube.ContextClient{
+		{ContextName: "production", Client: fake.NewSimpleClientset(
+			newUsageReportTestPod("app", "registry.example.com/repo:"+running.String(), "registry.example.com/repo:"+running.String()),
+			newUsageReportTestPod("final", "registry.example.com/final:"+final.String()),
+			newUsageReportTestPod("other", "registry.example.com/other:"+idle.String(), "registry.example.com/repo:nonexistent"),
+		)},
+		{ContextName: "staging", Client: fake.NewSimpleClientset(
+			newUsageReportTestPod("app", "registry.example.com/repo:"+running.String()),
+		)},
+	}}
+
+	startedAt := time.Now().Add(-time.Second)
+	report, err := UsageReport(ctx, "project", storageManager, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report) != 3 {
+		t.Fatalf("expected 3 report records, got %d", len(report))
+	}
+	now := report[0].LastSeenAt
+	if now.Before(startedAt) {
+		t.Fatalf("expected the running tags to be seen now, got %s", now)
+	}
+
+	expectedReport := []*UsageReportRecord{
+		{Tag: final.String(), LastSeenAt: now, Contexts: []string{"production"}},
+		{Tag: running.String(), LastSeenAt: now, Contexts: []string{"production", "staging"}},
+		{Tag: idle.String(), LastSeenAt: idleRecord.GetLastSeenAt()},
+	}
+	if !reflect.DeepEqual(report, expectedReport) {
+		t.Fatalf("expected report %v, got %v", expectedReport, report)
+	}
+
+	var postedTags []string
+	for _, rec := range stagesStorage.postedUsageRecords {
+		if rec.LastSeenTimestamp != now.Unix() {
+			t.Fatalf("unexpected posted record %s", rec)
+		}
+		postedTags = append(postedTags, rec.StageID)
+	}
+	sort.Strings(postedTags)
+	if expected := []string{final.String(), running.String()}; !reflect.DeepEqual(postedTags, expected) {
+		t.Fatalf("expected posted records for %v, got %v", expected, postedTags)
+	}
+
+	if expected := []*storage.UsageRecord{previousRunningRecord, deletedRecord}; !reflect.DeepEqual(stagesStorage.removedUsageRecords, expected) {
+		t.Fatalf("expected removed records %v, got %v", expected, stagesStorage.removedUsageRecords)
+	}
+	if len(finalStagesStorage.postedUsageRecords) != 0 || len(finalStagesStorage.removedUsageRecords) != 0 {
+		t.Fatal("expected the usage records to be stored in the repo only")
+	}
+}
+
+func TestUsageReport_DryRun(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+	stageID := image.StageID{Digest: "running", UniqueID: 1611836746968}
+	stagesStorage := &testUsageStagesStorage{
+		address:      "registry.example.com/repo",
+		stageIDs:     []image.StageID{stageID},
+		usageRecords: []*storage.UsageRecord{{StageID: "deleted-1611836746968", LastSeenTimestamp: 1000}},
+	}
+	storageManager := manager.NewStorageManager("project", stagesStorage, nil, nil, nil, nil, nil)
+
+	report, err := UsageReport(ctx, "project", storageManager, UsageReportOptions{
+		KubernetesContextClients: []*kube.ContextClient{{ContextName: "production", Client: fake.NewSimpleClientset(newUsageReportTestPod("app", "registry.example.com/repo:"+stageID.String()))}},
+		DryRun:                   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report) != 1 || report[0].Tag != stageID.String() {
+		t.Fatalf("unexpected report %v", report)
+	}
+	if len(stagesStorage.postedUsageRecords) != 0 || len(stagesStorage.removedUsageRecords) != 0 {
+		t.Fatal("expected no usage records changes in the dry run mode")
+	}
+}
+
+func TestGetRepoTagByDockerImageName(t *testing.T) {
+	repoAddressList := []string{"registry.example.com/repo", "registry.example.com/final"}
+
+	for dockerImageName, expected := range map[string]string{
+		"registry.example.com/repo:tag":       "tag",
+		"registry.example.com/final:tag":      "tag",
+		"registry.example.com/repository:tag": "",
+		"registry.example.com/repo/app:tag":   "",
+		"alpine:3.14":                         "",
+	} {
+		if tag := getRepoTagByDockerImageName(repoAddressList, dockerImageName); tag != expected {
+			t.Fatalf("expected tag %q for %q, got %q", expected, dockerImageName, tag)
+		}
+	}
+}
+
+type testUsageStorageManager struct {
+	manager.StorageManagerInterface
+
+	stagesStorage          storage.StagesStorage
+	stageDescriptions      []*image.StageDescription
+	finalStageDescriptions []*image.StageDescription
+}
+
+func (m *testUsageStorageManager) GetStagesStorage() storage.StagesStorage {
+	return m.stagesStorage
+}
+
+func (m *testUsageStorageManager) GetStageDescriptionList(_ context.Context) ([]*image.StageDescription, error) {
+	return m.stageDescriptions, nil
+}
+
+func (m *testUsageStorageManager) GetFinalStageDescriptionList(_ context.Context) ([]*image.StageDescription, error) {
+	return m.finalStageDescriptions, nil
+}
+
+func newUsageTestStageDescription(tag string) *image.StageDescription {
+	return &image.StageDescription{Info: &image.Info{Tag: tag}}
+}
+
+func TestCleanupManager_SkipStageIDsThatWereSeenRecently(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+	seenRecently := newUsageTestStageDescription("seen-recently-1611836746968")
+	seenLongAgo := newUsageTestStageDescription("seen-long-ago-1611836746968")
+	neverSeen := newUsageTestStageDescription("never-seen-1611836746968")
+	finalSeenRecently := newUsageTestStageDescription("final-seen-recently-1611836746968")
+
+	storageManager := &testUsageStorageManager{
+		stagesStorage: &testUsageStagesStorage{usageRecords: []*storage.UsageRecord{
+			{StageID: seenRecently.Info.Tag, LastSeenTimestamp: time.Now().Add(-time.Hour).Unix()},
+			{StageID: seenLongAgo.Info.Tag, LastSeenTimestamp: time.Now().Add(-3 * time.Hour).Unix()},
+			{StageID: finalSeenRecently.Info.Tag, LastSeenTimestamp: time.Now().Unix()},
+		}},
+		stageDescriptions:      []*image.StageDescription{seenRecently, seenLongAgo, neverSeen},
+		finalStageDescriptions: []*image.StageDescription{finalSeenRecently},
+	}
+
+	m := newCleanupManager("project", nil, CleanupOptions{KeepImagesSeenWithinLastNHours: 2})
+	m.StorageManager = storageManager
+	if err := m.stageManager.InitStages(ctx, storageManager); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.stageManager.InitFinalStages(ctx, storageManager); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.skipStageIDsThatWereSeenRecently(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if protected := m.stageManager.GetProtectedStageDescriptionList(); !reflect.DeepEqual(protected, []*image.StageDescription{seenRecently}) {
+		t.Fatalf("expected only the recently seen stage to be protected, got %v", protected)
+	}
+
+	if stages := m.stageManager.GetFinalStageDescriptionList(stage_manager.StageDescriptionListOptions{ExcludeProtected: true}); len(stages) != 0 {
+		t.Fatalf("expected the recently seen final stage to be protected, got %v", stages)
+	}
+}
@@ -10,6 +10,7 @@ type Ansible struct {
 	InstallCacheVersion       string
 	BeforeSetupCacheVersion   string
 	SetupCacheVersion         string
+	Requirements              *AnsibleRequirements
 
 	raw *rawAnsible
 }
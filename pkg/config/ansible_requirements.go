@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+)
+
+// AnsibleRequirements are the ansible galaxy collections and roles, which are installed into the build container before the ansible stage tasks.
+// Only pinned versions are allowed, so the requirements can be a part of the stage digest.
+type AnsibleRequirements struct {
+	Collections []*AnsibleRequirement
+	Roles       []*AnsibleRequirement
+
+	raw *rawAnsibleRequirements
+}
+
+// HasCollection returns true if the collection NAMESPACE.COLLECTION is required.
+func (c *AnsibleRequirements) HasCollection(name string) bool {
+	for _, collection := range c.Collections {
+		if collection.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *AnsibleRequirements) IsEmpty() bool {
+	return len(c.Collections) == 0 && len(c.Roles) == 0
+}
+
+type AnsibleRequirement struct {
+	Name    string
+	Version string
+	Source  string
+
+	raw *rawAnsibleRequirement
+}
+
+func (c *AnsibleRequirement) validate() error {
+	doc := c.raw.rawAnsibleRequirements.rawAnsible.rawImage.doc
+
+	if c.Name == "" {
+		return newDetailedConfigError("`name: NAME` required for ansible requirement!", c.raw, doc)
+	}
+
+	if c.Version == "" {
+		return newDetailedConfigError("`version: VERSION` required for ansible requirement!", c.raw, doc)
+	}
+
+	if strings.ContainsAny(c.Version, "*<>=!, ") {
+		return newDetailedConfigError("pinned `version: VERSION` required for ansible requirement, version ranges are not allowed!", c.raw, doc)
+	}
+
+	return nil
+}
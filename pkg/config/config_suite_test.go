@@ -5,9 +5,35 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
 )
 
 func TestIntegration(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Config Suite")
 }
+
+type rawSection int
+
+const (
+	metaSection rawSection = iota
+	imageSection
+)
+
+// unmarshalRawDirective unmarshals the werf.yaml directive data into the raw directive as a part of the meta or image section.
+func unmarshalRawDirective(section rawSection, data string, rawDirective interface{}) error {
+	d := &doc{Content: []byte(data), RenderFilePath: "werf.yaml"}
+
+	parentStack = util.NewStack()
+	switch section {
+	case metaSection:
+		parentStack.Push(&rawMeta{doc: d})
+	case imageSection:
+		parentStack.Push(&rawStapelImage{doc: d})
+	}
+	defer parentStack.Pop()
+
+	return yaml.UnmarshalStrict([]byte(data), rawDirective)
+}
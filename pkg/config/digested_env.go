@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+)
+
+// digestedEnvRecorder records the values of the digested environment variables used in the werf config templates.
+// The values used in the image config section take part only in the digests of the image,
+// the values used in the other config sections and in the included configs take part in the digests of all images.
+type digestedEnvRecorder struct {
+	// renderBuf is the buffer of the main werf config template being rendered, the usages are bound to the docs by the offsets in the rendered content
+	renderBuf *bytes.Buffer
+	usages    []digestedEnvUsage
+
+	global map[string]string
+	byDoc  map[string]map[string]string
+}
+
+type digestedEnvUsage struct {
+	name   string
+	value  string
+	offset int
+}
+
+func newDigestedEnvRecorder() *digestedEnvRecorder {
+	return &digestedEnvRecorder{
+		global: map[string]string{},
+		byDoc:  map[string]map[string]string{},
+	}
+}
+
+func (e *digestedEnvRecorder) record(name, value string) {
+	if e.renderBuf == nil {
+		e.global[name] = value
+		return
+	}
+
+	e.usages = append(e.usages, digestedEnvUsage{name: name, value: value, offset: e.renderBuf.Len()})
+}
+
+// setRenderedContent binds the recorded usages to the docs of the rendered main werf config template.
+func (e *digestedEnvRecorder) setRenderedContent(content []byte) {
+	docsContents := splitContent(content)
+
+	for _, usage := range e.usages {
+		// the sentinel byte guarantees the doc starting at the offset is counted
+		prefix := append(append([]byte{}, content[:usage.offset]...), '_')
+		docIndex := len(splitContent(prefix)) - 1
+
+		if docIndex < 0 || docIndex >= len(docsContents) {
+			e.global[usage.name] = usage.value
+			continue
+		}
+
+		docContent := string(docsContents[docIndex])
+		if _, ok := e.byDoc[docContent]; !ok {
+			e.byDoc[docContent] = map[string]string{}
+		}
+		e.byDoc[docContent][usage.name] = usage.value
+	}
+
+	e.usages = nil
+	e.renderBuf = nil
+}
+
+func (e *digestedEnvRecorder) all() map[string]string {
+	res := map[string]string{}
+	copyDigestedEnv(res, e.global)
+	for _, docEnv := range e.byDoc {
+		copyDigestedEnv(res, docEnv)
+	}
+
+	return res
+}
+
+// getImagesDigestedEnv returns the digested env of the images by the names, imagesDocs are the docs of the images config sections by the image names.
+func (e *digestedEnvRecorder) getImagesDigestedEnv(imagesDocs map[string]*doc) map[string]map[string]string {
+	isImageDoc := map[string]bool{}
+	for _, imageDoc := range imagesDocs {
+		isImageDoc[string(imageDoc.Content)] = true
+	}
+
+	common := map[string]string{}
+	copyDigestedEnv(common, e.global)
+	for docContent, docEnv := range e.byDoc {
+		if !isImageDoc[docContent] {
+			copyDigestedEnv(common, docEnv)
+		}
+	}
+
+	res := map[string]map[string]string{}
+	for imageName, imageDoc := range imagesDocs {
+		imageEnv := map[string]string{}
+		copyDigestedEnv(imageEnv, common)
+		copyDigestedEnv(imageEnv, e.byDoc[string(imageDoc.Content)])
+
+		if len(imageEnv) > 0 {
+			res[imageName] = imageEnv
+		}
+	}
+
+	return res
+}
+
+func copyDigestedEnv(dst, src map[string]string) {
+	for name, value := range src {
+		dst[name] = value
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("digestedEnvRecorder", func() {
+	render := func(recorder *digestedEnvRecorder, parts ...interface{}) []*doc {
+		buf := bytes.NewBuffer(nil)
+		recorder.renderBuf = buf
+
+		for _, part := range parts {
+			switch p := part.(type) {
+			case string:
+				buf.WriteString(p)
+			case [2]string:
+				recorder.record(p[0], p[1])
+				buf.WriteString(p[1])
+			}
+		}
+
+		recorder.setRenderedContent(buf.Bytes())
+
+		docs, err := splitByDocs(buf.String(), "werf.yaml")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return docs
+	}
+
+	It("should bind the values to the image docs and share the other values between all images", func() {
+		recorder := newDigestedEnvRecorder()
+		docs := render(recorder,
+			"project: ", [2]string{"PROJECT", "app"}, "\nconfigVersion: 1\n---\n",
+			"image: backend\nfrom: ", [2]string{"BACKEND_BASE", "alpine"}, "\n---\n",
+			[2]string{"FRONTEND_PREFIX", "image: "}, "frontend\nfrom: node\n",
+		)
+		recorder.record("INCLUDED", "1")
+
+		Ω(docs).Should(HaveLen(3))
+		Ω(recorder.all()).Should(Equal(map[string]string{"PROJECT": "app", "BACKEND_BASE": "alpine", "FRONTEND_PREFIX": "image: ", "INCLUDED": "1"}))
+		Ω(recorder.getImagesDigestedEnv(map[string]*doc{"backend": docs[1], "frontend": docs[2], "other": {Content: []byte("image: other\n")}})).Should(Equal(map[string]map[string]string{
+			"backend":  {"PROJECT": "app", "INCLUDED": "1", "BACKEND_BASE": "alpine"},
+			"frontend": {"PROJECT": "app", "INCLUDED": "1", "FRONTEND_PREFIX": "image: "},
+			"other":    {"PROJECT": "app", "INCLUDED": "1"},
+		}))
+	})
+
+	It("should not return the images without the digested env", func() {
+		recorder := newDigestedEnvRecorder()
+		docs := render(recorder, "project: app\n---\nimage: backend\nfrom: alpine\n")
+
+		Ω(recorder.getImagesDigestedEnv(map[string]*doc{"backend": docs[1]})).Should(BeEmpty())
+	})
+})
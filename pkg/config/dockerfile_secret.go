@@ -0,0 +1,27 @@
+package config
+
+// DockerfileSecret is a secret for the RUN --mount=type=secret,id=ID Dockerfile instructions.
+// The secret value is taken from the encrypted secret values file by the dot-separated key (e.g. npm.token).
+type DockerfileSecret struct {
+	ID               string
+	SecretValue      string
+	SecretValuesFile string
+
+	raw *rawDockerfileSecret
+}
+
+func (c *DockerfileSecret) validate() error {
+	if c.ID == "" {
+		return newDetailedConfigError("`id: ID` required for secret!", c.raw, c.raw.rawImageFromDockerfile.doc)
+	}
+
+	if c.SecretValue == "" {
+		return newDetailedConfigError("`secretValue: KEY` required for secret!", c.raw, c.raw.rawImageFromDockerfile.doc)
+	}
+
+	if c.SecretValuesFile != "" && !isRelativePath(c.SecretValuesFile) {
+		return newDetailedConfigError("`secretValuesFile: PATH` should be relative to project directory!", c.raw, c.raw.rawImageFromDockerfile.doc)
+	}
+
+	return nil
+}
@@ -4,6 +4,8 @@ type GitRemote struct {
 	*GitRemoteExport
 	Name string
 	Url  string
+	// PartialClone enables the partial clone of the repository and the sparse checkout of the paths used by the git mappings
+	PartialClone bool
 
 	raw *rawGit
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"path/filepath"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -16,8 +17,14 @@ type ImageFromDockerfile struct {
 	AddHost         []string
 	Network         string
 	SSH             string
+	Platform        string
+	CacheStages     bool
+	Secrets         []*DockerfileSecret
 
-	raw             *rawImageFromDockerfile
+	// PlatformIndependentContextChecksum makes the contextAddFiles checksum the same on all OSes (disabled by default to keep the digests of the existing images)
+	PlatformIndependentContextChecksum bool
+
+	raw *rawImageFromDockerfile
 }
 
 func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.Interface) error {
@@ -29,6 +36,14 @@ func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.In
 		return newDetailedConfigError("`contextAddFiles: [PATH, ...]|PATH` each path should be relative to context!", nil, c.raw.doc)
 	}
 
+	definedSecretIDs := map[string]bool{}
+	for _, secret := range c.Secrets {
+		if definedSecretIDs[secret.ID] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate secret id %q!", secret.ID), nil, c.raw.doc)
+		}
+		definedSecretIDs[secret.ID] = true
+	}
+
 	if len(c.ContextAddFiles) != 0 {
 		for _, contextAddFile := range c.ContextAddFiles {
 			if err := giterminismManager.Inspector().InspectConfigDockerfileContextAddFile(filepath.Join(c.Context, contextAddFile)); err != nil {
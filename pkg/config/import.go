@@ -2,15 +2,27 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	importFromDigestRegexp = regexp.MustCompile(`@sha256:[a-f0-9]{64}$`)
+	importModeRegexp       = regexp.MustCompile(`^[0-7]{3,4}$`)
 )
 
 type Import struct {
 	*ArtifactExport
 	ImageName    string
 	ArtifactName string
+	From         string
 	Before       string
 	After        string
 	Stage        string
+	// FileMode and DirMode override the permissions of the imported files and directories (e.g. 0644 and 0755)
+	FileMode string
+	DirMode  string
 
 	raw *rawImport
 }
@@ -24,10 +36,12 @@ func (c *Import) validate() error {
 		return err
 	}
 
-	if c.ArtifactName == "" && c.ImageName == "" {
-		return newDetailedConfigError("artifact name `artifact: NAME` or image name `image: NAME` required for import!", c.raw, c.raw.rawStapelImage.doc)
-	} else if c.ArtifactName != "" && c.ImageName != "" {
-		return newDetailedConfigError("specify only one artifact name using `artifact: NAME` or image name using `image: NAME` for import!", c.raw, c.raw.rawStapelImage.doc)
+	if c.ArtifactName == "" && c.ImageName == "" && c.From == "" {
+		return newDetailedConfigError("artifact name `artifact: NAME`, image name `image: NAME` or external image `from: DOCKER_IMAGE@DIGEST` required for import!", c.raw, c.raw.rawStapelImage.doc)
+	} else if !oneOrNone([]bool{c.ArtifactName != "", c.ImageName != "", c.From != ""}) {
+		return newDetailedConfigError("specify only one artifact name using `artifact: NAME`, image name using `image: NAME` or external image using `from: DOCKER_IMAGE@DIGEST` for import!", c.raw, c.raw.rawStapelImage.doc)
+	} else if c.From != "" && c.Stage != "" {
+		return newDetailedConfigError("`stage: STAGE` cannot be used with external image `from: DOCKER_IMAGE@DIGEST` for import!", c.raw, c.raw.rawStapelImage.doc)
 	} else if c.Before != "" && c.After != "" {
 		return newDetailedConfigError("specify only one artifact stage using `before: install|setup` or `after: install|setup` for import!", c.raw, c.raw.rawStapelImage.doc)
 	} else if c.Before == "" && c.After == "" {
@@ -38,10 +52,35 @@ func (c *Import) validate() error {
 		return newDetailedConfigError(fmt.Sprintf("invalid artifact stage `after: %s` for import: expected install or setup!", c.After), c.raw, c.raw.rawStapelImage.doc)
 	} else if c.Stage != "" && checkInvalidStage(c.Stage) {
 		return newDetailedConfigError(fmt.Sprintf("invalid stage `stage: %s` for import: expected beforeInstall, install, beforeSetup or setup", c.Stage), c.raw, c.raw.rawStapelImage.doc)
+	} else if c.IsAddGlob() && (c.To == c.Add || strings.ContainsAny(c.To, "*?[")) {
+		return newDetailedConfigError("destination directory `to: PATH` without glob required for import with glob `add: PATH`!", c.raw, c.raw.rawStapelImage.doc)
+	} else if c.FileMode != "" && !importModeRegexp.MatchString(c.FileMode) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `fileMode: %s` for import: expected octal mode, e.g. 0644", c.FileMode), c.raw, c.raw.rawStapelImage.doc)
+	} else if c.DirMode != "" && !importModeRegexp.MatchString(c.DirMode) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `dirMode: %s` for import: expected octal mode, e.g. 0755", c.DirMode), c.raw, c.raw.rawStapelImage.doc)
 	}
 	return nil
 }
 
+// IsAddGlob returns true if the source path is the glob pattern (e.g. /app/build/*.jar), the matched files and directories are imported into the destination directory.
+func (c *Import) IsAddGlob() bool {
+	return strings.ContainsAny(c.Add, "*?[")
+}
+
+// AddBasePath returns the path, which includePaths and excludePaths are relative to: the directory of the glob or the source path itself.
+func (c *Import) AddBasePath() string {
+	if c.IsAddGlob() {
+		return path.Dir(c.Add)
+	}
+
+	return c.Add
+}
+
+// IsFromPinned returns true if the external image is referenced by the digest, e.g. alpine@sha256:DIGEST.
+func (c *Import) IsFromPinned() bool {
+	return importFromDigestRegexp.MatchString(c.From)
+}
+
 func checkInvalidRelation(rel string) bool {
 	return !(rel == "install" || rel == "setup")
 }
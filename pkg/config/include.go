@@ -0,0 +1,231 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+const maxIncludeDepth = 10
+
+type rawIncludeSection struct {
+	Include []*rawInclude `yaml:"include"`
+}
+
+type rawInclude struct {
+	Path   string `yaml:"path,omitempty"`
+	Git    string `yaml:"git,omitempty"`
+	Branch string `yaml:"branch,omitempty"`
+	Tag    string `yaml:"tag,omitempty"`
+	Commit string `yaml:"commit,omitempty"`
+}
+
+func (c *rawInclude) validate() error {
+	if c.Path == "" {
+		return newConfigError(fmt.Sprintf("include path cannot be empty!\n\n%s", dumpConfigSection(c)))
+	}
+
+	if c.Git == "" {
+		if c.Branch != "" || c.Tag != "" || c.Commit != "" {
+			return newConfigError(fmt.Sprintf("branch, tag and commit directives can be specified only for the remote include with the git directive!\n\n%s", dumpConfigSection(c)))
+		}
+
+		return nil
+	}
+
+	var refsNumber int
+	for _, ref := range []string{c.Branch, c.Tag, c.Commit} {
+		if ref != "" {
+			refsNumber++
+		}
+	}
+
+	if refsNumber == 0 {
+		return newConfigError(fmt.Sprintf("one of branch, tag or commit directives required for the remote include!\n\n%s", dumpConfigSection(c)))
+	} else if refsNumber > 1 {
+		return newConfigError(fmt.Sprintf("only one of branch, tag or commit directives can be specified for the remote include!\n\n%s", dumpConfigSection(c)))
+	}
+
+	return nil
+}
+
+func (c *rawInclude) String() string {
+	if c.Git == "" {
+		return c.Path
+	}
+
+	ref := c.Commit
+	if c.Tag != "" {
+		ref = c.Tag
+	} else if c.Branch != "" {
+		ref = c.Branch
+	}
+
+	return fmt.Sprintf("%s@%s:%s", c.Git, ref, c.Path)
+}
+
+type includesExpander struct {
+	giterminismManager giterminism_manager.Interface
+	tmpl               *template.Template
+	templateData       interface{}
+	stack              []string
+}
+
+// expandIncludes replaces include config sections with the rendered config sections from the included files.
+func (e *includesExpander) expandIncludes(ctx context.Context, content string) (string, error) {
+	var docsContents []string
+	var hasIncludes bool
+	for _, docContent := range splitContent([]byte(content)) {
+		if emptyDocContent(docContent) {
+			docsContents = append(docsContents, string(docContent))
+			continue
+		}
+
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(docContent, &raw); err != nil || !isIncludeDoc(raw) {
+			docsContents = append(docsContents, string(docContent))
+			continue
+		}
+
+		if len(raw) != 1 {
+			return "", newConfigError(fmt.Sprintf("include config section cannot contain other directives!\n\n%s", docContent))
+		}
+
+		hasIncludes = true
+
+		includeSection := &rawIncludeSection{}
+		if err := yaml.UnmarshalStrict(docContent, includeSection); err != nil {
+			return "", newConfigError(fmt.Sprintf("invalid include config section: %s\n\n%s", err, docContent))
+		}
+
+		for _, include := range includeSection.Include {
+			includedContent, err := e.renderInclude(ctx, include)
+			if err != nil {
+				return "", err
+			}
+
+			docsContents = append(docsContents, includedContent)
+		}
+	}
+
+	if !hasIncludes {
+		return content, nil
+	}
+
+	return joinDocsContents(docsContents), nil
+}
+
+func (e *includesExpander) renderInclude(ctx context.Context, include *rawInclude) (string, error) {
+	if err := include.validate(); err != nil {
+		return "", err
+	}
+
+	includeID := include.String()
+	for _, id := range e.stack {
+		if id == includeID {
+			return "", newConfigError(fmt.Sprintf("include cycle detected: %s -> %s", strings.Join(e.stack, " -> "), includeID))
+		}
+	}
+
+	if len(e.stack) >= maxIncludeDepth {
+		return "", newConfigError(fmt.Sprintf("include depth limit %d exceeded: %s -> %s", maxIncludeDepth, strings.Join(e.stack, " -> "), includeID))
+	}
+
+	data, err := e.readInclude(ctx, include)
+	if err != nil {
+		return "", err
+	}
+
+	templateName := fmt.Sprintf("include:%s", includeID)
+	if err := addTemplate(e.tmpl, templateName, string(data)); err != nil {
+		return "", fmt.Errorf("unable to parse include %q: %s", includeID, err)
+	}
+
+	content, err := executeTemplate(e.tmpl, templateName, e.templateData)
+	if err != nil {
+		return "", fmt.Errorf("unable to render include %q: %s", includeID, err)
+	}
+
+	e.stack = append(e.stack, includeID)
+	defer func() { e.stack = e.stack[:len(e.stack)-1] }()
+
+	return e.expandIncludes(ctx, content)
+}
+
+func (e *includesExpander) readInclude(ctx context.Context, include *rawInclude) ([]byte, error) {
+	if include.Git == "" {
+		return e.giterminismManager.FileReader().ReadConfigInclude(ctx, include.Path)
+	}
+
+	if err := e.giterminismManager.Inspector().InspectConfigIncludeRemote(include.String()); err != nil {
+		return nil, err
+	}
+
+	if include.Branch != "" {
+		if err := e.giterminismManager.Inspector().InspectConfigIncludeBranch(); err != nil {
+			return nil, err
+		}
+	}
+
+	remoteRepo, err := git_repo.OpenRemoteRepo(include.Git, include.Git)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open remote include repo %q: %s", include.Git, err)
+	}
+
+	if err := remoteRepo.CloneAndFetch(ctx); err != nil {
+		return nil, fmt.Errorf("unable to clone remote include repo %q: %s", include.Git, err)
+	}
+
+	var commit string
+	switch {
+	case include.Commit != "":
+		if exist, err := remoteRepo.IsCommitExists(ctx, include.Commit); err != nil {
+			return nil, fmt.Errorf("unable to check commit %q existence in the remote include repo %q: %s", include.Commit, include.Git, err)
+		} else if !exist {
+			return nil, fmt.Errorf("commit %q not found in the remote include repo %q", include.Commit, include.Git)
+		}
+
+		commit = include.Commit
+	case include.Tag != "":
+		if commit, err = remoteRepo.TagCommit(ctx, include.Tag); err != nil {
+			return nil, err
+		}
+	default:
+		if commit, err = remoteRepo.LatestBranchCommit(ctx, include.Branch); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := remoteRepo.ReadCommitFile(ctx, commit, include.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read include %q: %s", include.String(), err)
+	}
+
+	return data, nil
+}
+
+func isIncludeDoc(h map[string]interface{}) bool {
+	_, ok := h["include"]
+	return ok
+}
+
+func joinDocsContents(docsContents []string) string {
+	var res string
+	for ind, docContent := range docsContents {
+		if ind != 0 {
+			if !strings.HasSuffix(res, "\n") {
+				res += "\n"
+			}
+			res += "---\n"
+		}
+		res += docContent
+	}
+
+	return res
+}
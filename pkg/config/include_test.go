@@ -0,0 +1,27 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type includeEntry struct {
+	include       rawInclude
+	expectedError bool
+}
+
+var _ = DescribeTable("validating include", func(e includeEntry) {
+	err := e.include.validate()
+	if e.expectedError {
+		Ω(err).Should(HaveOccurred())
+	} else {
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+},
+	Entry("local", includeEntry{include: rawInclude{Path: ".werf/app.yaml"}}),
+	Entry("local without path", includeEntry{include: rawInclude{}, expectedError: true}),
+	Entry("local with commit", includeEntry{include: rawInclude{Path: "app.yaml", Commit: "abc"}, expectedError: true}),
+	Entry("remote with commit", includeEntry{include: rawInclude{Path: "app.yaml", Git: "https://github.com/company/name.git", Commit: "abc"}}),
+	Entry("remote without ref", includeEntry{include: rawInclude{Path: "app.yaml", Git: "https://github.com/company/name.git"}, expectedError: true}),
+	Entry("remote with several refs", includeEntry{include: rawInclude{Path: "app.yaml", Git: "https://github.com/company/name.git", Tag: "v1", Branch: "main"}, expectedError: true}),
+)
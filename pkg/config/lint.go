@@ -0,0 +1,318 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml_v3 "gopkg.in/yaml.v3"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+type LintIssueSeverity string
+
+const (
+	LintIssueError   LintIssueSeverity = "error"
+	LintIssueWarning LintIssueSeverity = "warning"
+)
+
+type LintIssue struct {
+	Severity LintIssueSeverity
+	Line     int
+	Column   int
+	Path     string
+	Message  string
+}
+
+func (i *LintIssue) Format(filePath string) string {
+	parts := []string{filePath}
+	if i.Line != 0 {
+		parts[0] = fmt.Sprintf("%s:%d:%d", filePath, i.Line, i.Column)
+	}
+	parts = append(parts, string(i.Severity))
+	if i.Path != "" {
+		parts = append(parts, i.Path)
+	}
+	parts = append(parts, i.Message)
+
+	return strings.Join(parts, ": ")
+}
+
+// LintWerfConfig validates rendered werf.yaml against the config schema and returns all found issues instead of failing on the first one.
+// Lines of the issues refer to the rendered config (see werf config render).
+// The werf config semantic validation is performed only when there are no schema errors.
+func LintWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) (string, []*LintIssue, error) {
+	werfConfigPath, werfConfigRenderContent, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	docs, err := splitByDocs(werfConfigRenderContent, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var issues []*LintIssue
+	var metaDocsNumber int
+	for _, doc := range docs {
+		docIssues, isMetaDoc := lintDoc(doc)
+		issues = append(issues, docIssues...)
+
+		if isMetaDoc {
+			metaDocsNumber++
+			if metaDocsNumber > 1 {
+				issues = append(issues, &LintIssue{Severity: LintIssueError, Line: doc.Line + 1, Column: 1, Message: "duplicate meta config section definition"})
+			}
+		}
+	}
+
+	if metaDocsNumber == 0 {
+		issues = append(issues, &LintIssue{Severity: LintIssueError, Message: "meta config section with configVersion and project fields is not defined"})
+	}
+
+	if !hasLintErrors(issues) {
+		if _, _, err := GetWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts); err != nil {
+			issues = append(issues, &LintIssue{Severity: LintIssueError, Message: err.Error()})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line == 0 || issues[j].Line == 0 {
+			return issues[j].Line == 0 && issues[i].Line != 0
+		}
+		return issues[i].Line < issues[j].Line
+	})
+
+	return werfConfigPath, issues, nil
+}
+
+// LintGiterminismConfig validates werf-giterminism.yaml content against the giterminism config schema.
+// Unlike the giterminism config loading, the unknown fields are reported as errors.
+func LintGiterminismConfig(content []byte) []*LintIssue {
+	d := &doc{Content: content}
+
+	var node yaml_v3.Node
+	if err := yaml_v3.Unmarshal(d.Content, &node); err != nil {
+		return []*LintIssue{newLintYamlSyntaxIssue(err, d)}
+	}
+
+	if len(node.Content) == 0 {
+		return []*LintIssue{{Severity: LintIssueError, Message: "config is empty"}}
+	}
+
+	linter := &docLinter{doc: d}
+	linter.lintNode(node.Content[0], getLintSchemaDefinition("Giterminism"), "")
+
+	return linter.issues
+}
+
+func hasLintErrors(issues []*LintIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == LintIssueError {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lintDoc(doc *doc) ([]*LintIssue, bool) {
+	var node yaml_v3.Node
+	if err := yaml_v3.Unmarshal(doc.Content, &node); err != nil {
+		return []*LintIssue{newLintYamlSyntaxIssue(err, doc)}, false
+	}
+
+	if len(node.Content) == 0 {
+		return nil, false
+	}
+
+	root := node.Content[0]
+	if root.Kind != yaml_v3.MappingNode {
+		return []*LintIssue{{Severity: LintIssueError, Line: doc.Line + root.Line, Column: root.Column, Message: "config section must be a map"}}, false
+	}
+
+	var keys []string
+	for i := 0; i < len(root.Content); i += 2 {
+		keys = append(keys, root.Content[i].Value)
+	}
+
+	hasKey := func(key string) bool {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	var definition string
+	switch {
+	case hasKey("configVersion"):
+		definition = "Meta"
+	case hasKey("dockerfile"):
+		definition = "ImageFromDockerfile"
+	case hasKey("image"), hasKey("artifact"):
+		definition = "StapelImage"
+	default:
+		return []*LintIssue{{Severity: LintIssueError, Line: doc.Line + root.Line, Column: root.Column, Message: "cannot recognize type of config section: 'configVersion' required for meta config section, 'image' required for the image config sections, 'artifact' required for the artifact config sections"}}, false
+	}
+
+	linter := &docLinter{doc: doc}
+	linter.lintNode(root, getLintSchemaDefinition(definition), "")
+
+	return linter.issues, definition == "Meta"
+}
+
+func newLintYamlSyntaxIssue(err error, doc *doc) *LintIssue {
+	issue := &LintIssue{Severity: LintIssueError, Line: doc.Line + 1, Column: 1, Message: err.Error()}
+
+	reg := regexp.MustCompile(`line ([0-9]+): `)
+	if res := reg.FindStringSubmatch(issue.Message); len(res) == 2 {
+		if line, err := strconv.Atoi(res[1]); err == nil {
+			issue.Line = doc.Line + line
+			issue.Message = reg.ReplaceAllString(issue.Message, "")
+		}
+	}
+
+	return issue
+}
+
+type docLinter struct {
+	doc    *doc
+	issues []*LintIssue
+}
+
+func (l *docLinter) addIssue(severity LintIssueSeverity, node *yaml_v3.Node, path, format string, args ...interface{}) {
+	l.issues = append(l.issues, &LintIssue{
+		Severity: severity,
+		Line:     l.doc.Line + node.Line,
+		Column:   node.Column,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *docLinter) lintNode(node *yaml_v3.Node, schema *lintSchema, path string) {
+	schema = schema.resolve()
+
+	if node.Kind == yaml_v3.AliasNode {
+		node = node.Alias
+	}
+
+	nodeType := lintNodeType(node)
+	if types := schema.types(); len(types) != 0 && !isLintNodeTypeAccepted(nodeType, types) {
+		l.addIssue(LintIssueError, node, path, "invalid type %s, expected %s", nodeType, strings.Join(types, " or "))
+		return
+	}
+
+	if len(schema.Enum) != 0 && node.Kind == yaml_v3.ScalarNode {
+		var accepted bool
+		var values []string
+		for _, v := range schema.Enum {
+			values = append(values, fmt.Sprint(v))
+			if fmt.Sprint(v) == node.Value {
+				accepted = true
+			}
+		}
+
+		if !accepted {
+			l.addIssue(LintIssueError, node, path, "unsupported value %q, expected %s", node.Value, strings.Join(values, " or "))
+		}
+	}
+
+	switch node.Kind {
+	case yaml_v3.MappingNode:
+		l.lintMappingNode(node, schema, path)
+	case yaml_v3.SequenceNode:
+		if schema.Items != nil {
+			for ind, itemNode := range node.Content {
+				l.lintNode(itemNode, schema.Items, fmt.Sprintf("%s[%d]", path, ind))
+			}
+		}
+	}
+}
+
+func (l *docLinter) lintMappingNode(node *yaml_v3.Node, schema *lintSchema, path string) {
+	definedKeys := map[string]bool{}
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		key := keyNode.Value
+		keyPath := joinLintPath(path, key)
+
+		if definedKeys[key] {
+			l.addIssue(LintIssueError, keyNode, keyPath, "duplicate field %q", key)
+			continue
+		}
+		definedKeys[key] = true
+
+		propertySchema, ok := schema.Properties[key]
+		if !ok {
+			if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				l.addIssue(LintIssueError, keyNode, path, "unknown field %q", key)
+			}
+			continue
+		}
+
+		if propertySchema.Deprecated {
+			l.addIssue(LintIssueWarning, keyNode, keyPath, "deprecated directive: %s", propertySchema.Description)
+		}
+
+		l.lintNode(valueNode, propertySchema, keyPath)
+	}
+
+	for _, key := range schema.Required {
+		if !definedKeys[key] {
+			l.addIssue(LintIssueError, node, path, "required field %q is not defined", key)
+		}
+	}
+}
+
+func joinLintPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%s.%s", path, key)
+}
+
+func lintNodeType(node *yaml_v3.Node) string {
+	switch node.Kind {
+	case yaml_v3.MappingNode:
+		return "object"
+	case yaml_v3.SequenceNode:
+		return "array"
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func isLintNodeTypeAccepted(nodeType string, expectedTypes []string) bool {
+	for _, expectedType := range expectedTypes {
+		switch {
+		case nodeType == expectedType:
+			return true
+		case expectedType == "number" && nodeType == "integer":
+			return true
+		case expectedType == "string" && (nodeType == "integer" || nodeType == "number" || nodeType == "boolean"):
+			// scalars are implicitly converted into strings on config parsing
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,813 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// lintSchemaYaml is a JSON schema of the werf.yaml config sections.
+// NOTE: the schema must be updated together with the raw_*.go config structures.
+const lintSchemaYaml = `definitions:
+  Meta:
+    type: object
+    additionalProperties: false
+    required: [configVersion, project]
+    properties:
+      configVersion:
+        type: integer
+        enum: [1]
+      project:
+        type: string
+      deploy:
+        $ref: '#/definitions/MetaDeploy'
+      cleanup:
+        $ref: '#/definitions/MetaCleanup'
+      gitWorktree:
+        $ref: '#/definitions/MetaGitWorktree'
+      finalRepo:
+        $ref: '#/definitions/MetaFinalRepo'
+      compose:
+        $ref: '#/definitions/MetaCompose'
+      metadata:
+        $ref: '#/definitions/MetaMetadata'
+      dependencies:
+        type: array
+        items:
+          $ref: '#/definitions/MetaDependency'
+      registryMirrors:
+        type: array
+        items:
+          $ref: '#/definitions/MetaRegistryMirror'
+  MetaDeploy:
+    type: object
+    additionalProperties: false
+    properties:
+      helmChartDir:
+        type: string
+      helmRelease:
+        type: string
+      helmReleaseSlug:
+        type: boolean
+      namespace:
+        type: string
+      namespaceSlug:
+        type: boolean
+      secretsBackend:
+        type: string
+        enum: [aes, sops]
+      tracking:
+        type: array
+        items:
+          $ref: '#/definitions/MetaDeployTracking'
+      targets:
+        type: array
+        items:
+          $ref: '#/definitions/MetaDeployTarget'
+      bootstrap:
+        $ref: '#/definitions/MetaDeployBootstrap'
+  MetaDeployBootstrap:
+    type: object
+    additionalProperties: false
+    properties:
+      namespace:
+        type: object
+        additionalProperties: false
+        properties:
+          labels:
+            type: object
+          annotations:
+            type: object
+      imagePullSecrets:
+        type: array
+        items:
+          type: string
+      serviceAccounts:
+        type: array
+        items:
+          $ref: '#/definitions/MetaDeployBootstrapServiceAccount'
+  MetaDeployBootstrapServiceAccount:
+    type: object
+    additionalProperties: false
+    required: [name]
+    properties:
+      name:
+        type: string
+      clusterRoles:
+        type: array
+        items:
+          type: string
+      rules:
+        type: array
+        items:
+          type: object
+          additionalProperties: false
+          required: [resources, verbs]
+          properties:
+            apiGroups:
+              type: array
+              items:
+                type: string
+            resources:
+              type: array
+              items:
+                type: string
+            resourceNames:
+              type: array
+              items:
+                type: string
+            verbs:
+              type: array
+              items:
+                type: string
+  MetaDeployTarget:
+    type: object
+    additionalProperties: false
+    required: [kubeContext]
+    properties:
+      kubeContext:
+        type: string
+      namespace:
+        type: string
+  MetaDeployTracking:
+    type: object
+    additionalProperties: false
+    properties:
+      kind:
+        type: string
+      name:
+        type: string
+      trackTerminationMode:
+        type: string
+        enum: [WaitUntilResourceReady, NonBlocking]
+      failMode:
+        type: string
+        enum: [FailWholeDeployProcessImmediately, HopeUntilEndOfDeployProcess, IgnoreAndContinueDeployProcess]
+      failuresAllowedPerReplica:
+        type: integer
+      logRegex:
+        type: string
+      skipLogs:
+        type: boolean
+      skipLogsForContainers:
+        type: array
+        items:
+          type: string
+      showLogsOnlyForContainers:
+        type: array
+        items:
+          type: string
+  MetaCleanup:
+    type: object
+    additionalProperties: false
+    properties:
+      keepPolicies:
+        type: array
+        items:
+          $ref: '#/definitions/MetaCleanupKeepPolicy'
+      policySets:
+        type: array
+        items:
+          $ref: '#/definitions/MetaCleanupPolicySet'
+  MetaCleanupPolicySet:
+    type: object
+    additionalProperties: false
+    required: [ref]
+    properties:
+      ref:
+        type: string
+      digest:
+        type: string
+      publicKey:
+        type: string
+  MetaCleanupKeepPolicy:
+    type: object
+    additionalProperties: false
+    properties:
+      references:
+        type: object
+        additionalProperties: false
+        properties:
+          tag:
+            type: string
+          branch:
+            type: string
+          limit:
+            $ref: '#/definitions/MetaCleanupKeepPolicyLimit'
+      imagesPerReference:
+        $ref: '#/definitions/MetaCleanupKeepPolicyLimit'
+  MetaCleanupKeepPolicyLimit:
+    type: object
+    additionalProperties: false
+    properties:
+      last:
+        type: integer
+      in:
+        type: string
+      operator:
+        type: string
+        enum: [And, Or]
+  MetaGitWorktree:
+    type: object
+    additionalProperties: false
+    properties:
+      forceShallowClone:
+        type: boolean
+      allowUnshallow:
+        type: boolean
+      allowFetchOriginBranchesAndTags:
+        type: boolean
+  MetaFinalRepo:
+    type: object
+    additionalProperties: false
+    properties:
+      environments:
+        type: array
+        items:
+          type: string
+  MetaDependency:
+    type: object
+    additionalProperties: false
+    required: [project, image]
+    properties:
+      project:
+        type: string
+      image:
+        type: string
+      as:
+        type: string
+      repo:
+        type: string
+      commit:
+        type: string
+  MetaCompose:
+    type: object
+    additionalProperties: false
+    properties:
+      services:
+        type: object
+  MetaRegistryMirror:
+    type: object
+    additionalProperties: false
+    required: [mirror]
+    properties:
+      registry:
+        type: string
+      mirror:
+        type: string
+  MetaMetadata:
+    type: object
+    additionalProperties: false
+    properties:
+      annotations:
+        type: object
+      labels:
+        type: object
+  ImageFromDockerfile:
+    type: object
+    additionalProperties: false
+    required: [image, dockerfile]
+    properties:
+      image:
+        type: [string, array, "null"]
+        items:
+          type: string
+      dockerfile:
+        type: string
+      context:
+        type: string
+      contextAddFile:
+        type: [string, array]
+        items:
+          type: string
+        deprecated: true
+        description: use contextAddFiles directive instead
+      contextAddFiles:
+        type: [string, array]
+        items:
+          type: string
+      target:
+        type: string
+      args:
+        type: object
+      addHost:
+        type: [string, array]
+        items:
+          type: string
+      network:
+        type: string
+      ssh:
+        type: string
+      platform:
+        type: string
+      cacheStages:
+        type: boolean
+      platformIndependentContextChecksum:
+        type: boolean
+      matrix:
+        type: array
+        items:
+          $ref: '#/definitions/ImageMatrixEntry'
+      secrets:
+        type: array
+        items:
+          $ref: '#/definitions/DockerfileSecret'
+  ImageMatrixEntry:
+    type: object
+    additionalProperties: false
+    required: [name]
+    properties:
+      name:
+        type: string
+      platform:
+        type: string
+      args:
+        type: object
+  DockerfileSecret:
+    type: object
+    additionalProperties: false
+    required: [id, secretValue]
+    properties:
+      id:
+        type: string
+      secretValue:
+        type: string
+      secretValuesFile:
+        type: string
+  StapelImage:
+    type: object
+    additionalProperties: false
+    properties:
+      image:
+        type: [string, array, "null"]
+        items:
+          type: string
+      artifact:
+        type: string
+      from:
+        type: string
+      fromLatest:
+        type: boolean
+      fromCacheVersion:
+        type: string
+      fromImage:
+        type: string
+      fromArtifact:
+        type: string
+        deprecated: true
+        description: the directive will be completely removed in version v1.3
+      git:
+        type: array
+        items:
+          $ref: '#/definitions/StapelGit'
+      shell:
+        $ref: '#/definitions/StapelShell'
+      ansible:
+        $ref: '#/definitions/StapelAnsible'
+      scripts:
+        $ref: '#/definitions/StapelScripts'
+      mount:
+        type: array
+        items:
+          $ref: '#/definitions/StapelMount'
+      docker:
+        $ref: '#/definitions/StapelDocker'
+      import:
+        type: array
+        items:
+          $ref: '#/definitions/StapelImport'
+  StapelGit:
+    type: object
+    additionalProperties: false
+    properties:
+      add:
+        type: string
+      to:
+        type: string
+      includePaths:
+        $ref: '#/definitions/StringOrArray'
+      excludePaths:
+        $ref: '#/definitions/StringOrArray'
+      owner:
+        type: string
+      group:
+        type: string
+      url:
+        type: string
+      branch:
+        type: string
+      tag:
+        type: string
+      commit:
+        type: string
+      partialClone:
+        type: boolean
+      stageDependencies:
+        type: object
+        additionalProperties: false
+        properties:
+          install:
+            $ref: '#/definitions/StringOrArray'
+          beforeSetup:
+            $ref: '#/definitions/StringOrArray'
+          setup:
+            $ref: '#/definitions/StringOrArray'
+  StapelShell:
+    type: object
+    additionalProperties: false
+    properties:
+      beforeInstall:
+        $ref: '#/definitions/StringOrArray'
+      install:
+        $ref: '#/definitions/StringOrArray'
+      beforeSetup:
+        $ref: '#/definitions/StringOrArray'
+      setup:
+        $ref: '#/definitions/StringOrArray'
+      cacheVersion:
+        type: string
+      beforeInstallCacheVersion:
+        type: string
+      installCacheVersion:
+        type: string
+      beforeSetupCacheVersion:
+        type: string
+      setupCacheVersion:
+        type: string
+  StapelScripts:
+    type: object
+    additionalProperties: false
+    properties:
+      beforeInstall:
+        $ref: '#/definitions/StringOrArray'
+      install:
+        $ref: '#/definitions/StringOrArray'
+      beforeSetup:
+        $ref: '#/definitions/StringOrArray'
+      setup:
+        $ref: '#/definitions/StringOrArray'
+      cacheVersion:
+        type: string
+      beforeInstallCacheVersion:
+        type: string
+      installCacheVersion:
+        type: string
+      beforeSetupCacheVersion:
+        type: string
+      setupCacheVersion:
+        type: string
+  StapelAnsible:
+    type: object
+    additionalProperties: false
+    properties:
+      requirements:
+        $ref: '#/definitions/StapelAnsibleRequirements'
+      beforeInstall:
+        $ref: '#/definitions/StapelAnsibleTasks'
+      install:
+        $ref: '#/definitions/StapelAnsibleTasks'
+      beforeSetup:
+        $ref: '#/definitions/StapelAnsibleTasks'
+      setup:
+        $ref: '#/definitions/StapelAnsibleTasks'
+      cacheVersion:
+        type: string
+      beforeInstallCacheVersion:
+        type: string
+      installCacheVersion:
+        type: string
+      beforeSetupCacheVersion:
+        type: string
+      setupCacheVersion:
+        type: string
+  StapelAnsibleRequirements:
+    type: object
+    additionalProperties: false
+    properties:
+      collections:
+        type: array
+        items:
+          $ref: '#/definitions/StapelAnsibleRequirement'
+      roles:
+        type: array
+        items:
+          $ref: '#/definitions/StapelAnsibleRequirement'
+  StapelAnsibleRequirement:
+    type: object
+    additionalProperties: false
+    required: [name, version]
+    properties:
+      name:
+        type: string
+      version:
+        type: string
+      source:
+        type: string
+  StapelAnsibleTasks:
+    type: array
+    items:
+      type: object
+  StapelMount:
+    type: object
+    additionalProperties: false
+    properties:
+      to:
+        type: string
+      from:
+        type: string
+      fromPath:
+        type: string
+      size:
+        type: string
+      name:
+        type: string
+  StapelDocker:
+    type: object
+    additionalProperties: false
+    properties:
+      VOLUME:
+        $ref: '#/definitions/StringOrArray'
+      EXPOSE:
+        $ref: '#/definitions/StringOrArray'
+      ENV:
+        type: object
+      LABEL:
+        type: object
+      CMD:
+        $ref: '#/definitions/StringOrArray'
+      WORKDIR:
+        type: string
+      USER:
+        type: string
+      ENTRYPOINT:
+        $ref: '#/definitions/StringOrArray'
+      HEALTHCHECK:
+        type: string
+  StapelImport:
+    type: object
+    additionalProperties: false
+    properties:
+      image:
+        type: string
+      artifact:
+        type: string
+      from:
+        type: string
+      before:
+        type: string
+        enum: [install, setup]
+      after:
+        type: string
+        enum: [install, setup]
+      stage:
+        type: string
+        enum: [beforeInstall, install, beforeSetup, setup]
+      add:
+        type: string
+      to:
+        type: string
+      includePaths:
+        $ref: '#/definitions/StringOrArray'
+      excludePaths:
+        $ref: '#/definitions/StringOrArray'
+      owner:
+        type: string
+      group:
+        type: string
+      fileMode:
+        type: string
+      dirMode:
+        type: string
+  Include:
+    type: object
+    additionalProperties: false
+    required: [include]
+    properties:
+      include:
+        type: array
+        items:
+          type: object
+          additionalProperties: false
+          required: [path]
+          properties:
+            path:
+              type: string
+            git:
+              type: string
+            branch:
+              type: string
+            tag:
+              type: string
+            commit:
+              type: string
+  StringOrArray:
+    type: [string, array]
+    items:
+      type: string
+`
+
+// giterminismLintSchemaYaml is a JSON schema of the werf-giterminism.yaml config.
+// NOTE: the schema must be updated together with the giterminism config openapi spec (pkg/giterminism_manager/config),
+// which accepts unknown fields and thus does not report the typos.
+const giterminismLintSchemaYaml = `definitions:
+  Giterminism:
+    type: object
+    additionalProperties: false
+    required: [giterminismConfigVersion]
+    properties:
+      giterminismConfigVersion:
+        type: [integer, string]
+        enum: [1]
+      config:
+        $ref: '#/definitions/GiterminismConfig'
+      helm:
+        $ref: '#/definitions/GiterminismHelm'
+      ssh:
+        $ref: '#/definitions/GiterminismSSH'
+  GiterminismConfig:
+    type: object
+    additionalProperties: false
+    properties:
+      allowUncommitted:
+        type: boolean
+      allowUncommittedTemplates:
+        $ref: '#/definitions/GiterminismStringArray'
+      goTemplateRendering:
+        $ref: '#/definitions/GiterminismConfigGoTemplateRendering'
+      stapel:
+        $ref: '#/definitions/GiterminismConfigStapel'
+      dockerfile:
+        $ref: '#/definitions/GiterminismConfigDockerfile'
+      include:
+        $ref: '#/definitions/GiterminismConfigInclude'
+      dependencies:
+        $ref: '#/definitions/GiterminismConfigDependencies'
+  GiterminismConfigGoTemplateRendering:
+    type: object
+    additionalProperties: false
+    properties:
+      allowEnvVariables:
+        $ref: '#/definitions/GiterminismStringArray'
+      allowDigestedEnvVariables:
+        $ref: '#/definitions/GiterminismStringArray'
+      allowUncommittedFiles:
+        $ref: '#/definitions/GiterminismStringArray'
+  GiterminismConfigStapel:
+    type: object
+    additionalProperties: false
+    properties:
+      allowFromLatest:
+        type: boolean
+      git:
+        type: object
+        additionalProperties: false
+        properties:
+          allowBranch:
+            type: boolean
+      mount:
+        type: object
+        additionalProperties: false
+        properties:
+          allowBuildDir:
+            type: boolean
+          allowFromPaths:
+            $ref: '#/definitions/GiterminismStringArray'
+          allowVolumes:
+            $ref: '#/definitions/GiterminismStringArray'
+      import:
+        type: object
+        additionalProperties: false
+        properties:
+          allowFromWithoutDigest:
+            type: boolean
+      allowUncommittedScripts:
+        $ref: '#/definitions/GiterminismStringArray'
+  GiterminismConfigDockerfile:
+    type: object
+    additionalProperties: false
+    properties:
+      allowUncommitted:
+        $ref: '#/definitions/GiterminismStringArray'
+      allowUncommittedDockerignoreFiles:
+        $ref: '#/definitions/GiterminismStringArray'
+      allowContextAddFiles:
+        $ref: '#/definitions/GiterminismStringArray'
+  GiterminismConfigInclude:
+    type: object
+    additionalProperties: false
+    properties:
+      allowRemote:
+        type: boolean
+      allowBranch:
+        type: boolean
+  GiterminismConfigDependencies:
+    type: object
+    additionalProperties: false
+    properties:
+      allowLatest:
+        type: boolean
+  GiterminismHelm:
+    type: object
+    additionalProperties: false
+    properties:
+      allowUncommittedFiles:
+        $ref: '#/definitions/GiterminismStringArray'
+  GiterminismSSH:
+    type: object
+    additionalProperties: false
+    properties:
+      knownHosts:
+        $ref: '#/definitions/GiterminismStringArray'
+  GiterminismStringArray:
+    type: array
+    items:
+      type: string
+`
+
+type lintSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Properties           map[string]*lintSchema `json:"properties,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *lintSchema            `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Deprecated           bool                   `json:"deprecated,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Definitions          map[string]*lintSchema `json:"definitions,omitempty"`
+}
+
+var lintSchemaRoot *lintSchema
+
+func getLintSchemaRoot() *lintSchema {
+	if lintSchemaRoot == nil {
+		lintSchemaRoot = &lintSchema{}
+		if err := yaml.UnmarshalStrict([]byte(lintSchemaYaml), lintSchemaRoot); err != nil {
+			panic(fmt.Sprint("unexpected error: ", err))
+		}
+
+		// the giterminism config definitions are resolved the same way but are not the part of the werf.yaml JSON schema
+		giterminismSchemaRoot := &lintSchema{}
+		if err := yaml.UnmarshalStrict([]byte(giterminismLintSchemaYaml), giterminismSchemaRoot); err != nil {
+			panic(fmt.Sprint("unexpected error: ", err))
+		}
+
+		for name, schema := range giterminismSchemaRoot.Definitions {
+			lintSchemaRoot.Definitions[name] = schema
+		}
+	}
+
+	return lintSchemaRoot
+}
+
+func getLintSchemaDefinition(name string) *lintSchema {
+	schema, ok := getLintSchemaRoot().Definitions[name]
+	if !ok {
+		panic(fmt.Sprintf("unexpected error: schema definition %q not found", name))
+	}
+
+	return schema
+}
+
+func (s *lintSchema) resolve() *lintSchema {
+	if s.Ref == "" {
+		return s
+	}
+
+	return getLintSchemaDefinition(strings.TrimPrefix(s.Ref, "#/definitions/")).resolve()
+}
+
+func (s *lintSchema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var res []string
+		for _, elm := range t {
+			res = append(res, fmt.Sprint(elm))
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+// GetWerfConfigJSONSchema returns the JSON schema which is used by werf config lint.
+func GetWerfConfigJSONSchema() ([]byte, error) {
+	schema := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(lintSchemaYaml), &schema); err != nil {
+		return nil, err
+	}
+
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["oneOf"] = []interface{}{
+		map[string]interface{}{"$ref": "#/definitions/Meta"},
+		map[string]interface{}{"$ref": "#/definitions/StapelImage"},
+		map[string]interface{}{"$ref": "#/definitions/ImageFromDockerfile"},
+		map[string]interface{}{"$ref": "#/definitions/Include"},
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
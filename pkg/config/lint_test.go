@@ -0,0 +1,89 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type lintEntry struct {
+	content        string
+	expectedIssues []string
+}
+
+var _ = DescribeTable("linting werf config section", func(e lintEntry) {
+	issues, _ := lintDoc(&doc{Line: 10, Content: []byte(e.content)})
+
+	var formattedIssues []string
+	for _, issue := range issues {
+		formattedIssues = append(formattedIssues, issue.Format("werf.yaml"))
+	}
+
+	Ω(formattedIssues).Should(Equal(e.expectedIssues))
+},
+	Entry("valid meta", lintEntry{
+		content: "configVersion: 1\nproject: demo\n",
+	}),
+	Entry("meta without project", lintEntry{
+		content:        "configVersion: 1\n",
+		expectedIssues: []string{`werf.yaml:11:1: error: required field "project" is not defined`},
+	}),
+	Entry("unknown field", lintEntry{
+		content:        "image: app\nfrom: alpine\ngit:\n- add: /\n  too: /app\n",
+		expectedIssues: []string{`werf.yaml:15:3: error: git[0]: unknown field "too"`},
+	}),
+	Entry("invalid type", lintEntry{
+		content:        "image: app\nfrom: alpine\nshell:\n  install:\n    cmd: echo\n",
+		expectedIssues: []string{`werf.yaml:15:5: error: shell.install: invalid type object, expected string or array`},
+	}),
+	Entry("scalars are accepted as strings", lintEntry{
+		content: "image: app\nfrom: alpine\nfromCacheVersion: 1\n",
+	}),
+	Entry("deprecated directive", lintEntry{
+		content:        "artifact: app\nfromArtifact: base\n",
+		expectedIssues: []string{`werf.yaml:12:1: warning: fromArtifact: deprecated directive: the directive will be completely removed in version v1.3`},
+	}),
+	Entry("unsupported value", lintEntry{
+		content:        "image: app\nfrom: alpine\nimport:\n- artifact: base\n  add: /app\n  after: build\n",
+		expectedIssues: []string{`werf.yaml:16:10: error: import[0].after: unsupported value "build", expected install or setup`},
+	}),
+	Entry("unrecognized section", lintEntry{
+		content:        "from: alpine\n",
+		expectedIssues: []string{`werf.yaml:11:1: error: cannot recognize type of config section: 'configVersion' required for meta config section, 'image' required for the image config sections, 'artifact' required for the artifact config sections`},
+	}),
+)
+
+var _ = DescribeTable("linting giterminism config", func(e lintEntry) {
+	var formattedIssues []string
+	for _, issue := range LintGiterminismConfig([]byte(e.content)) {
+		formattedIssues = append(formattedIssues, issue.Format("werf-giterminism.yaml"))
+	}
+
+	Ω(formattedIssues).Should(Equal(e.expectedIssues))
+},
+	Entry("valid config", lintEntry{
+		content: "giterminismConfigVersion: 1\nconfig:\n  goTemplateRendering:\n    allowEnvVariables: [CI_COMMIT_SHA]\n  stapel:\n    mount:\n      allowBuildDir: true\n",
+	}),
+	Entry("string version", lintEntry{
+		content: "giterminismConfigVersion: \"1\"\n",
+	}),
+	Entry("unsupported version", lintEntry{
+		content:        "giterminismConfigVersion: 2\n",
+		expectedIssues: []string{`werf-giterminism.yaml:1:27: error: giterminismConfigVersion: unsupported value "2", expected 1`},
+	}),
+	Entry("without version", lintEntry{
+		content:        "config:\n  allowUncommitted: true\n",
+		expectedIssues: []string{`werf-giterminism.yaml:1:1: error: required field "giterminismConfigVersion" is not defined`},
+	}),
+	Entry("unknown field", lintEntry{
+		content:        "giterminismConfigVersion: 1\nconfig:\n  goTemplateRendering:\n    allowEnvVariable: [CI_COMMIT_SHA]\n",
+		expectedIssues: []string{`werf-giterminism.yaml:4:5: error: config.goTemplateRendering: unknown field "allowEnvVariable"`},
+	}),
+	Entry("invalid type", lintEntry{
+		content:        "giterminismConfigVersion: 1\nhelm:\n  allowUncommittedFiles: values.yaml\n",
+		expectedIssues: []string{`werf-giterminism.yaml:3:26: error: helm.allowUncommittedFiles: invalid type string, expected array`},
+	}),
+	Entry("empty config", lintEntry{
+		content:        "",
+		expectedIssues: []string{`werf-giterminism.yaml: error: config is empty`},
+	}),
+)
@@ -1,9 +1,14 @@
 package config
 
 type Meta struct {
-	ConfigVersion int
-	Project       string
-	Deploy        MetaDeploy
-	Cleanup       MetaCleanup
-	GitWorktree   MetaGitWorktree
+	ConfigVersion   int
+	Project         string
+	Deploy          MetaDeploy
+	Cleanup         MetaCleanup
+	GitWorktree     MetaGitWorktree
+	FinalRepo       MetaFinalRepo
+	Compose         MetaCompose
+	Metadata        MetaMetadata
+	Dependencies    []MetaDependency
+	RegistryMirrors []MetaRegistryMirror
 }
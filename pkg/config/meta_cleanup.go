@@ -9,6 +9,15 @@ import (
 
 type MetaCleanup struct {
 	KeepPolicies []*MetaCleanupKeepPolicy
+	PolicySets   []*MetaCleanupPolicySet
+}
+
+// MetaCleanupPolicySet is the reference to the centrally published set of keep policies,
+// which are merged with the local keep policies.
+type MetaCleanupPolicySet struct {
+	Ref       string
+	Digest    string
+	PublicKey string
 }
 
 type MetaCleanupKeepPolicy struct {
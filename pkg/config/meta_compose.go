@@ -0,0 +1,5 @@
+package config
+
+type MetaCompose struct {
+	Services map[string]string
+}
@@ -0,0 +1,21 @@
+package config
+
+// MetaDependency is the image built by the other werf project, which the current project depends on.
+type MetaDependency struct {
+	Project string
+	Image   string
+	// As is the name of the dependency in the werf.yaml templates and helm values, the image name by default
+	As string
+	// Repo is the stages storage of the dependency project, the stages storage of the current project by default
+	Repo string
+	// Commit pins the dependency to the image built for the commit of the dependency project, the latest built image is used by default
+	Commit string
+}
+
+// DependencyTemplateData is the resolved dependency image available in the werf.yaml templates as {{ .Dependencies.NAME }}.
+type DependencyTemplateData struct {
+	Image  string
+	Repo   string
+	Tag    string
+	Digest string
+}
@@ -1,9 +1,84 @@
 package config
 
+import (
+	"regexp"
+	"strings"
+)
+
 type MetaDeploy struct {
 	HelmChartDir    *string
 	HelmRelease     *string
 	HelmReleaseSlug *bool
 	Namespace       *string
 	NamespaceSlug   *bool
+	// SecretsBackend is used to encrypt the secret values and the secret files of the project: aes or sops
+	SecretsBackend *string
+
+	Tracking []*MetaDeployTracking
+	Targets  []*MetaDeployTarget
+
+	Bootstrap *MetaDeployBootstrap
+}
+
+// MetaDeployTarget is the additional kube context (and optionally the namespace) the release is deployed into.
+// The namespace is the template in the same format as the deploy namespace, the deploy namespace is used if not specified.
+type MetaDeployTarget struct {
+	KubeContext string
+	Namespace   string
+}
+
+// MetaDeployTracking configures the tracking of the release resources selected by the kind and the name
+// the same way as the resource tracking annotations do.
+type MetaDeployTracking struct {
+	Kind       string
+	NameRegexp *regexp.Regexp
+
+	TrackTerminationMode      *string
+	FailMode                  *string
+	FailuresAllowedPerReplica *int
+	LogRegex                  *string
+	SkipLogs                  *bool
+	SkipLogsForContainers     []string
+	ShowLogsOnlyForContainers []string
+}
+
+// Match checks whether the resource is selected, the kind is compared case-insensitively.
+func (t *MetaDeployTracking) Match(kind, name string) bool {
+	if t.Kind != "" && !strings.EqualFold(t.Kind, kind) {
+		return false
+	}
+
+	if t.NameRegexp != nil && !t.NameRegexp.MatchString(name) {
+		return false
+	}
+
+	return true
+}
+
+// MetaDeployBootstrap declares the namespace, the image pull secrets and the service accounts with RBAC,
+// which are created or updated before the release is installed.
+type MetaDeployBootstrap struct {
+	Namespace *MetaDeployBootstrapNamespace
+	// ImagePullSecrets are the names of the docker-registry secrets with the images repo credentials.
+	ImagePullSecrets []string
+	ServiceAccounts  []*MetaDeployBootstrapServiceAccount
+}
+
+type MetaDeployBootstrapNamespace struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// MetaDeployBootstrapServiceAccount is bound to the cluster roles and to the Role with the rules in the release namespace.
+type MetaDeployBootstrapServiceAccount struct {
+	Name         string
+	ClusterRoles []string
+	Rules        []*MetaDeployBootstrapPolicyRule
+}
+
+type MetaDeployBootstrapPolicyRule struct {
+	APIGroups     []string
+	Resources     []string
+	ResourceNames []string
+	Verbs         []string
 }
@@ -0,0 +1,22 @@
+package config
+
+import "path"
+
+type MetaFinalRepo struct {
+	Environments []string
+}
+
+// IsEnabledForEnv returns true if the final repo is not limited by environments or the env matches one of the environments patterns.
+func (obj MetaFinalRepo) IsEnabledForEnv(env string) bool {
+	if obj.Environments == nil {
+		return true
+	}
+
+	for _, pattern := range obj.Environments {
+		if matched, _ := path.Match(pattern, env); matched {
+			return true
+		}
+	}
+
+	return false
+}
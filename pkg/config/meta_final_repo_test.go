@@ -0,0 +1,16 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("final repo environments", func(environments []string, env string, expected bool) {
+	Ω(MetaFinalRepo{Environments: environments}.IsEnabledForEnv(env)).Should(Equal(expected))
+},
+	Entry("not limited", nil, "review-1", true),
+	Entry("exact match", []string{"production"}, "production", true),
+	Entry("glob match", []string{"production-*"}, "production-eu", true),
+	Entry("no match", []string{"production", "production-*"}, "review-1", false),
+	Entry("empty env", []string{"production"}, "", false),
+)
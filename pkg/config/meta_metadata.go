@@ -0,0 +1,117 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+// MetaMetadata is the annotations and labels, which werf adds to every deployed resource, the labels are also added to every exported image.
+// The values are the templates with [[ ]] delimiters rendered with MetadataTemplateData.
+type MetaMetadata struct {
+	Annotations map[string]string
+	Labels      map[string]string
+}
+
+type MetadataTemplateData struct {
+	Project     string
+	Env         string
+	Commit      string
+	WerfVersion string
+
+	// LookupEnv returns the value of the environment variable for the env and expandenv functions, os.Getenv is used if not set
+	LookupEnv func(name string) (string, error)
+}
+
+// NewMetadataTemplateLookupEnv returns the environment variables lookup, which is restricted by the giterminism config the same way as the werf.yaml env function.
+func NewMetadataTemplateLookupEnv(giterminismManager giterminism_manager.Interface) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		if err := giterminismManager.Inspector().InspectConfigGoTemplateRenderingEnv(context.Background(), name); err != nil {
+			return "", err
+		}
+		return os.Getenv(name), nil
+	}
+}
+
+func (m MetaMetadata) RenderAnnotations(data MetadataTemplateData) (map[string]string, error) {
+	return renderMetadata("annotations", m.Annotations, data)
+}
+
+func (m MetaMetadata) RenderLabels(data MetadataTemplateData) (map[string]string, error) {
+	return renderMetadata("labels", m.Labels, data)
+}
+
+func renderMetadata(section string, values map[string]string, data MetadataTemplateData) (map[string]string, error) {
+	res := map[string]string{}
+	for key, value := range values {
+		tmpl, err := newMetadataTemplate(key, data).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad metadata.%s %q template: %s", section, key, err)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := tmpl.Execute(buf, nil); err != nil {
+			return nil, fmt.Errorf("unable to render metadata.%s %q: %s", section, key, err)
+		}
+
+		res[key] = buf.String()
+	}
+
+	return res, nil
+}
+
+func newMetadataTemplate(name string, data MetadataTemplateData) *template.Template {
+	lookupEnv := data.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = func(name string) (string, error) {
+			return os.Getenv(name), nil
+		}
+	}
+
+	funcMap := sprig.TxtFuncMap()
+
+	funcMap["project"] = func() string {
+		return data.Project
+	}
+
+	// env without arguments returns the werf environment, otherwise the environment variable value as the sprig env function
+	funcMap["env"] = func(name ...string) (string, error) {
+		switch len(name) {
+		case 0:
+			return data.Env, nil
+		case 1:
+			return lookupEnv(name[0])
+		default:
+			return "", fmt.Errorf("env expects at most 1 argument, got %d", len(name))
+		}
+	}
+
+	funcMap["expandenv"] = func(s string) (string, error) {
+		var lookupErr error
+		res := os.Expand(s, func(name string) string {
+			value, err := lookupEnv(name)
+			if err != nil && lookupErr == nil {
+				lookupErr = err
+			}
+			return value
+		})
+
+		return res, lookupErr
+	}
+
+	funcMap["commit"] = func() string {
+		return data.Commit
+	}
+
+	funcMap["werfVersion"] = func() string {
+		return data.WerfVersion
+	}
+
+	return template.New(name).Delims("[[", "]]").Funcs(funcMap)
+}
@@ -0,0 +1,9 @@
+package config
+
+// MetaRegistryMirror is the mirror or the pull-through proxy tried before the registry when pulling base images and stages.
+type MetaRegistryMirror struct {
+	// Registry is the mirrored registry, Docker Hub by default
+	Registry string
+	// Mirror is the mirror address with the optional repository prefix, the credentials are taken from the docker config
+	Mirror string
+}
@@ -2,14 +2,24 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
 )
 
+var (
+	mountTmpfsSizeRegexp  = regexp.MustCompile(`^[0-9]+[kmgKMG]?$`)
+	mountVolumeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
 type Mount struct {
 	To   string
 	From string
 	Type string
+	// Size is the size limit of the tmpfs mount, the tmpfs size is not limited if empty
+	Size string
+	// Name is the name of the volume shared by all images of the project
+	Name string
 
 	raw *rawMount
 }
@@ -20,6 +30,8 @@ func (c *Mount) validate(giterminismManager giterminism_manager.Interface) error
 		err = giterminismManager.Inspector().InspectConfigStapelMountFromPath(c.raw.FromPath)
 	} else if c.Type == "build_dir" {
 		err = giterminismManager.Inspector().InspectConfigStapelMountBuildDir()
+	} else if c.Type == "volume" {
+		err = giterminismManager.Inspector().InspectConfigStapelMountVolume(c.Name)
 	}
 
 	if err != nil {
@@ -36,8 +48,27 @@ func (c *Mount) validate(giterminismManager giterminism_manager.Interface) error
 		if c.From == "" {
 			return newDetailedConfigError("`fromPath: PATH` absolute or relative path required for mount!", c.raw, c.raw.rawStapelImage.doc)
 		}
-	} else if c.Type != "tmp_dir" && c.Type != "build_dir" {
-		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir` or `build_dir`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+	} else if c.Type != "tmp_dir" && c.Type != "build_dir" && c.Type != "tmpfs" && c.Type != "volume" {
+		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir`, `build_dir`, `tmpfs` or `volume`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.Size != "" {
+		if c.Type != "tmpfs" {
+			return newDetailedConfigError("`size: SIZE` can be used only with `from: tmpfs` mount!", c.raw, c.raw.rawStapelImage.doc)
+		} else if !mountTmpfsSizeRegexp.MatchString(c.Size) {
+			return newDetailedConfigError(fmt.Sprintf("invalid `size: %s` for tmpfs mount: expected the number of bytes with the optional k, m or g suffix!", c.Size), c.raw, c.raw.rawStapelImage.doc)
+		}
 	}
+
+	if c.Type == "volume" {
+		if c.Name == "" {
+			return newDetailedConfigError("`name: NAME` required for volume mount!", c.raw, c.raw.rawStapelImage.doc)
+		} else if !mountVolumeNameRegexp.MatchString(c.Name) {
+			return newDetailedConfigError(fmt.Sprintf("invalid `name: %s` for volume mount: expected alphanumeric characters, '_', '.' and '-'!", c.Name), c.raw, c.raw.rawStapelImage.doc)
+		}
+	} else if c.Name != "" {
+		return newDetailedConfigError("`name: NAME` can be used only with `from: volume` mount!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
 	return nil
 }
@@ -28,20 +28,20 @@ import (
 type WerfConfigOptions struct {
 	LogRenderedFilePath bool
 	Env                 string
+	// ResolveDependencies resolves the images of the meta dependencies, the config is rendered again with the resolved dependencies available as {{ .Dependencies.NAME }}.
+	// The empty values of the dependencies are used in the templates when not set
+	ResolveDependencies func(ctx context.Context, dependencies []MetaDependency) (map[string]DependencyTemplateData, error)
+
+	dependencies map[string]DependencyTemplateData
 }
 
 func RenderWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, imagesToProcess []string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) error {
-	_, werfConfig, err := GetWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts)
+	_, werfConfigRenderContent, werfConfig, err := getWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts)
 	if err != nil {
 		return err
 	}
 
 	if len(imagesToProcess) == 0 {
-		_, werfConfigRenderContent, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
-		if err != nil {
-			return err
-		}
-
 		fmt.Print(werfConfigRenderContent)
 	} else {
 		var imageDocs []string
@@ -67,39 +67,35 @@ func RenderWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfCo
 }
 
 func GetWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) (string, *WerfConfig, error) {
-	werfConfigPath, werfConfigRenderContent, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
-	if err != nil {
-		return "", nil, err
-	}
+	werfConfigPath, _, werfConfig, err := getWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts)
+	return werfConfigPath, werfConfig, err
+}
 
+func getWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) (string, string, *WerfConfig, error) {
 	werfConfigRenderPath, err := tmp_manager.CreateWerfConfigRender(ctx)
 	if err != nil {
-		return "", nil, err
+		return "", "", nil, err
 	}
 
 	if opts.LogRenderedFilePath {
 		logboek.Context(ctx).LogF("Using werf config render file: %s\n", werfConfigRenderPath)
 	}
 
-	err = writeWerfConfigRender(werfConfigRenderContent, werfConfigRenderPath)
-	if err != nil {
-		return "", nil, fmt.Errorf("unable to write rendered config to %s: %s", werfConfigRenderPath, err)
+	var digestedEnv *digestedEnvRecorder
+	render := func(opts WerfConfigOptions) (string, string, []*doc, error) {
+		digestedEnv = newDigestedEnvRecorder()
+		return renderWerfConfigDocs(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts, digestedEnv, werfConfigRenderPath)
 	}
 
-	docs, err := splitByDocs(werfConfigRenderContent, werfConfigRenderPath)
+	parsed, err := renderAndParseWerfConfigWithDependencies(ctx, render, opts)
 	if err != nil {
-		return "", nil, err
+		return "", "", nil, err
 	}
 
-	meta, rawStapelImages, rawImagesFromDockerfile, err := splitByMetaAndRawImages(docs)
-	if err != nil {
-		return "", nil, err
-	}
-
-	if meta == nil {
+	if parsed.meta == nil {
 		defaultProjectName, err := GetDefaultProjectName(ctx, giterminismManager)
 		if err != nil {
-			return "", nil, fmt.Errorf("failed to get default project name: %s", err)
+			return "", "", nil, fmt.Errorf("failed to get default project name: %s", err)
 		}
 
 		format := "meta config section (part of YAML stream separated by three hyphens, https://yaml.org/spec/1.2/spec.html#id2800132) is not defined: add following example config section with required fields, e.g:\n\n" +
@@ -115,15 +111,98 @@ func GetWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfi
 			"###              Read more about meta config section: https://werf.io/documentation/reference/werf_yaml.html               ###\n" +
 			"##############################################################################################################################"
 
-		return "", nil, fmt.Errorf(format, defaultProjectName)
+		return "", "", nil, fmt.Errorf(format, defaultProjectName)
+	}
+
+	werfConfig, err := prepareWerfConfig(giterminismManager, parsed.rawStapelImages, parsed.rawImagesFromDockerfile, parsed.meta)
+	if err != nil {
+		return "", "", nil, err
+	}
+	werfConfig.DigestedEnv = digestedEnv.all()
+	werfConfig.imagesDigestedEnv = digestedEnv.getImagesDigestedEnv(werfConfig.getImagesDocs())
+	werfConfig.Dependencies = parsed.dependencies
+
+	return parsed.werfConfigPath, parsed.werfConfigRenderContent, werfConfig, nil
+}
+
+type parsedWerfConfig struct {
+	werfConfigPath          string
+	werfConfigRenderContent string
+	meta                    *Meta
+	rawStapelImages         []*rawStapelImage
+	rawImagesFromDockerfile []*rawImageFromDockerfile
+	dependencies            map[string]DependencyTemplateData
+}
+
+// renderAndParseWerfConfigWithDependencies renders the werf config and parses the meta section first.
+// If the meta section declares the dependencies, they are resolved and the config is rendered again with the resolved dependencies before the images are parsed,
+// so the images are parsed only once and never with the empty values of the dependencies.
+func renderAndParseWerfConfigWithDependencies(ctx context.Context, render func(opts WerfConfigOptions) (string, string, []*doc, error), opts WerfConfigOptions) (*parsedWerfConfig, error) {
+	parsed := &parsedWerfConfig{}
+
+	var stapelImageDocs, imageFromDockerfileDocs []*doc
+	renderAndParseMeta := func() error {
+		var docs []*doc
+		var metaDoc *doc
+		var err error
+
+		parsed.werfConfigPath, parsed.werfConfigRenderContent, docs, err = render(opts)
+		if err != nil {
+			return err
+		}
+
+		metaDoc, stapelImageDocs, imageFromDockerfileDocs, err = splitByMetaAndImagesDocs(docs)
+		if err != nil {
+			return err
+		}
+
+		parsed.meta, err = parseMeta(metaDoc)
+		return err
+	}
+
+	if err := renderAndParseMeta(); err != nil {
+		return nil, err
+	}
+
+	if parsed.meta != nil && len(parsed.meta.Dependencies) != 0 && opts.ResolveDependencies != nil {
+		dependencies, err := opts.ResolveDependencies(ctx, parsed.meta.Dependencies)
+		if err != nil {
+			return nil, err
+		}
+		opts.dependencies = dependencies
+		parsed.dependencies = dependencies
+
+		if err := renderAndParseMeta(); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	parsed.rawStapelImages, parsed.rawImagesFromDockerfile, err = parseRawImages(stapelImageDocs, imageFromDockerfileDocs)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// renderWerfConfigDocs renders the werf config, writes the rendered config into the werfConfigRenderPath file and splits it by the docs.
+func renderWerfConfigDocs(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions, digestedEnv *digestedEnvRecorder, werfConfigRenderPath string) (string, string, []*doc, error) {
+	werfConfigPath, werfConfigRenderContent, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts, digestedEnv)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if err := writeWerfConfigRender(werfConfigRenderContent, werfConfigRenderPath); err != nil {
+		return "", "", nil, fmt.Errorf("unable to write rendered config to %s: %s", werfConfigRenderPath, err)
 	}
 
-	werfConfig, err := prepareWerfConfig(giterminismManager, rawStapelImages, rawImagesFromDockerfile, meta)
+	docs, err := splitByDocs(werfConfigRenderContent, werfConfigRenderPath)
 	if err != nil {
-		return "", nil, err
+		return "", "", nil, err
 	}
 
-	return werfConfigPath, werfConfig, nil
+	return werfConfigPath, werfConfigRenderContent, docs, nil
 }
 
 func GetDefaultProjectName(ctx context.Context, giterminismManager giterminism_manager.Interface) (string, error) {
@@ -185,9 +264,10 @@ func splitByDocs(werfConfigRenderContent string, werfConfigRenderPath string) ([
 	return docs, nil
 }
 
-func renderWerfConfigYaml(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, env string) (string, string, error) {
+// renderWerfConfigYaml renders the werf config, the values of the digested environment variables used in the templates are recorded into the digestedEnv if it is not nil.
+func renderWerfConfigYaml(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions, digestedEnv *digestedEnvRecorder) (string, string, error) {
 	tmpl := template.New("werfConfig")
-	tmpl.Funcs(funcMap(tmpl, giterminismManager))
+	tmpl.Funcs(funcMap(tmpl, giterminismManager, digestedEnv))
 
 	if err := parseWerfConfigTemplatesDir(ctx, tmpl, giterminismManager, customWerfConfigTemplatesDirRelPath); err != nil {
 		return "", "", err
@@ -203,9 +283,30 @@ func renderWerfConfigYaml(ctx context.Context, customWerfConfigRelPath, customWe
 		ctx:                ctx,
 		giterminismManager: giterminismManager,
 	}
-	templateData["Env"] = env
+	templateData["Env"] = opts.Env
+
+	dependencies := opts.dependencies
+	if dependencies == nil {
+		dependencies = map[string]DependencyTemplateData{}
+	}
+	templateData["Dependencies"] = dependencies
+
+	buf := bytes.NewBuffer(nil)
+	if digestedEnv != nil {
+		digestedEnv.renderBuf = buf
+	}
 
-	config, err := executeTemplate(tmpl, "werfConfig", templateData)
+	if err := tmpl.ExecuteTemplate(buf, "werfConfig", templateData); err != nil {
+		return "", "", err
+	}
+	config := buf.String()
+
+	if digestedEnv != nil {
+		digestedEnv.setRenderedContent(buf.Bytes())
+	}
+
+	expander := &includesExpander{giterminismManager: giterminismManager, tmpl: tmpl, templateData: templateData}
+	config, err = expander.expandIncludes(ctx, config)
 
 	return configPath, config, err
 }
@@ -244,7 +345,7 @@ func addTemplate(tmpl *template.Template, templateName string, templateContent s
 	return err
 }
 
-func funcMap(tmpl *template.Template, giterminismManager giterminism_manager.Interface) template.FuncMap {
+func funcMap(tmpl *template.Template, giterminismManager giterminism_manager.Interface, digestedEnv *digestedEnvRecorder) template.FuncMap {
 	funcMap := sprig.TxtFuncMap()
 	delete(funcMap, "expandenv")
 
@@ -276,13 +377,24 @@ func funcMap(tmpl *template.Template, giterminismManager giterminism_manager.Int
 			return "", err
 		}
 
-		if !giterminismManager.LooseGiterminism() {
+		// the unset environment variables do not break the audit
+		if !giterminismManager.LooseGiterminism() && giterminismManager.Audit() == nil {
 			if _, exist := os.LookupEnv(envName); !exist {
 				return "", fmt.Errorf("the environment variable %q must be set", envName)
 			}
 		}
 
-		return envFunc(envName), nil
+		envValue := envFunc(envName)
+
+		if digestedEnv != nil {
+			if isDigested, err := giterminismManager.Inspector().IsConfigGoTemplateRenderingEnvDigested(envName); err != nil {
+				return "", err
+			} else if isDigested {
+				digestedEnv.record(envName, envValue)
+			}
+		}
+
+		return envValue, nil
 	}
 
 	funcMap["required"] = func(msg string, val interface{}) (interface{}, error) {
@@ -502,6 +614,10 @@ func prepareWerfConfig(giterminismManager giterminism_manager.Interface, rawImag
 		return nil, err
 	}
 
+	if err := werfConfig.validateComposeServices(); err != nil {
+		return nil, err
+	}
+
 	if err := werfConfig.associateImportsArtifacts(); err != nil {
 		return nil, err
 	}
@@ -517,12 +633,10 @@ func prepareWerfConfig(giterminismManager giterminism_manager.Interface, rawImag
 	return werfConfig, nil
 }
 
-func splitByMetaAndRawImages(docs []*doc) (*Meta, []*rawStapelImage, []*rawImageFromDockerfile, error) {
-	var rawStapelImages []*rawStapelImage
-	var rawImagesFromDockerfile []*rawImageFromDockerfile
-	var resultMeta *Meta
+func splitByMetaAndImagesDocs(docs []*doc) (*doc, []*doc, []*doc, error) {
+	var metaDoc *doc
+	var stapelImageDocs, imageFromDockerfileDocs []*doc
 
-	parentStack = util.NewStack()
 	for _, doc := range docs {
 		var raw map[string]interface{}
 		err := yaml.UnmarshalStrict(doc.Content, &raw)
@@ -531,39 +645,63 @@ func splitByMetaAndRawImages(docs []*doc) (*Meta, []*rawStapelImage, []*rawImage
 		}
 
 		if isMetaDoc(raw) {
-			if resultMeta != nil {
+			if metaDoc != nil {
 				return nil, nil, nil, newYamlUnmarshalError(errors.New("duplicate meta config section definition"), doc)
 			}
 
-			rawMeta := &rawMeta{doc: doc}
-			err := yaml.UnmarshalStrict(doc.Content, &rawMeta)
-			if err != nil {
-				return nil, nil, nil, newYamlUnmarshalError(err, doc)
-			}
-
-			resultMeta = rawMeta.toMeta()
+			metaDoc = doc
 		} else if isImageFromDockerfileDoc(raw) {
-			imageFromDockerfile := &rawImageFromDockerfile{doc: doc}
-			err := yaml.UnmarshalStrict(doc.Content, &imageFromDockerfile)
-			if err != nil {
-				return nil, nil, nil, newYamlUnmarshalError(err, doc)
-			}
-
-			rawImagesFromDockerfile = append(rawImagesFromDockerfile, imageFromDockerfile)
+			imageFromDockerfileDocs = append(imageFromDockerfileDocs, doc)
 		} else if isImageDoc(raw) {
-			image := &rawStapelImage{doc: doc}
-			err := yaml.UnmarshalStrict(doc.Content, &image)
-			if err != nil {
-				return nil, nil, nil, newYamlUnmarshalError(err, doc)
-			}
-
-			rawStapelImages = append(rawStapelImages, image)
+			stapelImageDocs = append(stapelImageDocs, doc)
 		} else {
 			return nil, nil, nil, newYamlUnmarshalError(errors.New("cannot recognize type of config section (part of YAML stream separated by three hyphens, https://yaml.org/spec/1.2/spec.html#id2800132):\n * 'configVersion' required for meta config section;\n * 'image' required for the image config sections;\n * 'artifact' required for the artifact config sections;"), doc)
 		}
 	}
 
-	return resultMeta, rawStapelImages, rawImagesFromDockerfile, nil
+	return metaDoc, stapelImageDocs, imageFromDockerfileDocs, nil
+}
+
+func parseMeta(metaDoc *doc) (*Meta, error) {
+	if metaDoc == nil {
+		return nil, nil
+	}
+
+	parentStack = util.NewStack()
+	rawMeta := &rawMeta{doc: metaDoc}
+	if err := yaml.UnmarshalStrict(metaDoc.Content, &rawMeta); err != nil {
+		return nil, newYamlUnmarshalError(err, metaDoc)
+	}
+
+	return rawMeta.toMeta(), nil
+}
+
+func parseRawImages(stapelImageDocs, imageFromDockerfileDocs []*doc) ([]*rawStapelImage, []*rawImageFromDockerfile, error) {
+	var rawStapelImages []*rawStapelImage
+	var rawImagesFromDockerfile []*rawImageFromDockerfile
+
+	parentStack = util.NewStack()
+	for _, doc := range imageFromDockerfileDocs {
+		imageFromDockerfile := &rawImageFromDockerfile{doc: doc}
+		err := yaml.UnmarshalStrict(doc.Content, &imageFromDockerfile)
+		if err != nil {
+			return nil, nil, newYamlUnmarshalError(err, doc)
+		}
+
+		rawImagesFromDockerfile = append(rawImagesFromDockerfile, imageFromDockerfile)
+	}
+
+	for _, doc := range stapelImageDocs {
+		image := &rawStapelImage{doc: doc}
+		err := yaml.UnmarshalStrict(doc.Content, &image)
+		if err != nil {
+			return nil, nil, newYamlUnmarshalError(err, doc)
+		}
+
+		rawStapelImages = append(rawStapelImages, image)
+	}
+
+	return rawStapelImages, rawImagesFromDockerfile, nil
 }
 
 func isMetaDoc(h map[string]interface{}) bool {
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("renderAndParseWerfConfigWithDependencies", func() {
+	var renderedDependencies []map[string]DependencyTemplateData
+	var resolvedDependencies [][]MetaDependency
+
+	const werfConfigWithDependencies = `configVersion: 1
+project: app
+dependencies:
+- project: backend
+  image: api
+---
+image: app
+from: %q
+`
+
+	render := func(werfConfigTemplate string) func(opts WerfConfigOptions) (string, string, []*doc, error) {
+		return func(opts WerfConfigOptions) (string, string, []*doc, error) {
+			renderedDependencies = append(renderedDependencies, opts.dependencies)
+
+			content := fmt.Sprintf(werfConfigTemplate, opts.dependencies["api"].Image)
+			docs, err := splitByDocs(content, "werf.yaml")
+			return "werf.yaml", content, docs, err
+		}
+	}
+
+	resolve := func(_ context.Context, dependencies []MetaDependency) (map[string]DependencyTemplateData, error) {
+		resolvedDependencies = append(resolvedDependencies, dependencies)
+		return map[string]DependencyTemplateData{"api": {Image: "registry.example.com/backend:api"}}, nil
+	}
+
+	BeforeEach(func() {
+		renderedDependencies = nil
+		resolvedDependencies = nil
+	})
+
+	It("should render and parse the config once without the dependencies", func() {
+		parsed, err := renderAndParseWerfConfigWithDependencies(context.Background(), render("configVersion: 1\nproject: app\n---\nimage: app\nfrom: alpine%s\n"), WerfConfigOptions{ResolveDependencies: resolve})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(renderedDependencies).Should(HaveLen(1))
+		Ω(resolvedDependencies).Should(BeEmpty())
+		Ω(parsed.dependencies).Should(BeEmpty())
+		Ω(parsed.rawStapelImages).Should(HaveLen(1))
+		Ω(parsed.rawStapelImages[0].From).Should(Equal("alpine"))
+	})
+
+	It("should render the config again with the resolved dependencies before parsing the images", func() {
+		parsed, err := renderAndParseWerfConfigWithDependencies(context.Background(), render(werfConfigWithDependencies), WerfConfigOptions{ResolveDependencies: resolve})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(resolvedDependencies).Should(Equal([][]MetaDependency{{{Project: "backend", Image: "api", As: "api"}}}))
+		Ω(renderedDependencies).Should(HaveLen(2))
+		Ω(renderedDependencies[0]).Should(BeEmpty())
+		Ω(renderedDependencies[1]).Should(HaveKey("api"))
+
+		Ω(parsed.dependencies).Should(Equal(map[string]DependencyTemplateData{"api": {Image: "registry.example.com/backend:api"}}))
+		Ω(parsed.werfConfigRenderContent).Should(ContainSubstring(`from: "registry.example.com/backend:api"`))
+		Ω(parsed.meta.Dependencies).Should(HaveLen(1))
+		Ω(parsed.rawStapelImages).Should(HaveLen(1))
+		Ω(parsed.rawStapelImages[0].From).Should(Equal("registry.example.com/backend:api"))
+	})
+
+	It("should not resolve the dependencies without the resolver", func() {
+		parsed, err := renderAndParseWerfConfigWithDependencies(context.Background(), render(werfConfigWithDependencies), WerfConfigOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(renderedDependencies).Should(HaveLen(1))
+		Ω(parsed.dependencies).Should(BeEmpty())
+		Ω(parsed.rawStapelImages[0].From).Should(BeEmpty())
+	})
+
+	It("should fail if the dependencies cannot be resolved", func() {
+		_, err := renderAndParseWerfConfigWithDependencies(context.Background(), render(werfConfigWithDependencies), WerfConfigOptions{
+			ResolveDependencies: func(_ context.Context, _ []MetaDependency) (map[string]DependencyTemplateData, error) {
+				return nil, errors.New("image \"api\" of the project \"backend\" is not found")
+			},
+		})
+		Ω(err).Should(MatchError(`image "api" of the project "backend" is not found`))
+		Ω(renderedDependencies).Should(HaveLen(1))
+	})
+})
@@ -11,6 +11,8 @@ type rawAnsible struct {
 	BeforeSetupCacheVersion   string           `yaml:"beforeSetupCacheVersion,omitempty"`
 	SetupCacheVersion         string           `yaml:"setupCacheVersion,omitempty"`
 
+	Requirements *rawAnsibleRequirements `yaml:"requirements,omitempty"`
+
 	rawImage *rawStapelImage `yaml:"-"` // parent
 
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
@@ -33,6 +35,14 @@ func (c *rawAnsible) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	for _, tasks := range [][]rawAnsibleTask{c.BeforeInstall, c.Install, c.BeforeSetup, c.Setup} {
+		for ind := range tasks {
+			if err := tasks[ind].validate(); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -45,6 +55,14 @@ func (c *rawAnsible) toDirective() (ansible *Ansible, err error) {
 	ansible.BeforeSetupCacheVersion = c.BeforeSetupCacheVersion
 	ansible.SetupCacheVersion = c.SetupCacheVersion
 
+	if c.Requirements != nil {
+		if requirements, err := c.Requirements.toDirective(); err != nil {
+			return nil, err
+		} else {
+			ansible.Requirements = requirements
+		}
+	}
+
 	for ind := range c.BeforeInstall {
 		if ansibleTask, err := c.BeforeInstall[ind].toDirective(); err != nil {
 			return nil, err
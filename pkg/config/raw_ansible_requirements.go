@@ -0,0 +1,96 @@
+package config
+
+type rawAnsibleRequirements struct {
+	Collections []rawAnsibleRequirement `yaml:"collections,omitempty"`
+	Roles       []rawAnsibleRequirement `yaml:"roles,omitempty"`
+
+	rawAnsible *rawAnsible `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawAnsibleRequirements) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawAnsible); ok {
+		c.rawAnsible = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawAnsibleRequirements
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawAnsible.rawImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawAnsibleRequirements) toDirective() (requirements *AnsibleRequirements, err error) {
+	requirements = &AnsibleRequirements{}
+
+	for ind := range c.Collections {
+		if requirement, err := c.Collections[ind].toDirective(); err != nil {
+			return nil, err
+		} else {
+			requirements.Collections = append(requirements.Collections, requirement)
+		}
+	}
+
+	for ind := range c.Roles {
+		if requirement, err := c.Roles[ind].toDirective(); err != nil {
+			return nil, err
+		} else {
+			requirements.Roles = append(requirements.Roles, requirement)
+		}
+	}
+
+	requirements.raw = c
+
+	return requirements, nil
+}
+
+type rawAnsibleRequirement struct {
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	Source  string `yaml:"source,omitempty"`
+
+	rawAnsibleRequirements *rawAnsibleRequirements `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawAnsibleRequirement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawAnsibleRequirements); ok {
+		c.rawAnsibleRequirements = parent
+	}
+
+	type plain rawAnsibleRequirement
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawAnsibleRequirements.rawAnsible.rawImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawAnsibleRequirement) toDirective() (requirement *AnsibleRequirement, err error) {
+	requirement = &AnsibleRequirement{}
+	requirement.Name = c.Name
+	requirement.Version = c.Version
+	requirement.Source = c.Source
+
+	requirement.raw = c
+
+	if err := requirement.validate(); err != nil {
+		return nil, err
+	}
+
+	return requirement, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -30,30 +31,83 @@ func (c *rawAnsibleTask) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 
-	if !c.blockDefined() {
-		check := false
-		for _, supportedModule := range supportedModules() {
-			if c.Fields[supportedModule] != nil {
-				if check {
-					return newDetailedConfigError("invalid ansible task!", c, c.rawAnsible.rawImage.doc)
-				} else {
-					check = true
+	return nil
+}
+
+// validate checks the task modules when the whole ansible section is parsed, because the task may use the modules of the required collections
+func (c *rawAnsibleTask) validate() error {
+	if c.blockDefined() {
+		for _, tasks := range [][]rawAnsibleTask{c.Block, c.Rescue, c.Always} {
+			for ind := range tasks {
+				if err := tasks[ind].validate(); err != nil {
+					return err
 				}
 			}
 		}
 
-		if !check {
-			var supportedModulesString string
-			for _, supportedModule := range supportedModules() {
-				supportedModulesString += fmt.Sprintf("* %s\n", supportedModule)
-			}
-			return newConfigError(fmt.Sprintf("unsupported ansible task!\n\n%s\nSupported modules list:\n%s\n%s", dumpConfigSection(c), supportedModulesString, dumpConfigDoc(c.rawAnsible.rawImage.doc)))
+		return nil
+	}
+
+	check := false
+	for field := range c.Fields {
+		if !c.isModule(field) {
+			continue
+		}
+
+		if check {
+			return newDetailedConfigError("invalid ansible task!", c, c.rawAnsible.rawImage.doc)
+		} else {
+			check = true
 		}
 	}
 
+	if !check {
+		var supportedModulesString string
+		for _, supportedModule := range supportedModules() {
+			supportedModulesString += fmt.Sprintf("* %s\n", supportedModule)
+		}
+		return newConfigError(fmt.Sprintf("unsupported ansible task!\n\n%s\nSupported modules list:\n%s\nModules of the collections from the ansible requirements are supported by the fully qualified name NAMESPACE.COLLECTION.MODULE\n\n%s", dumpConfigSection(c), supportedModulesString, dumpConfigDoc(c.rawAnsible.rawImage.doc)))
+	}
+
 	return nil
 }
 
+func (c *rawAnsibleTask) isModule(field string) bool {
+	if c.Fields[field] == nil {
+		return false
+	}
+
+	for _, supportedModule := range supportedModules() {
+		if field == supportedModule {
+			return true
+		}
+	}
+
+	requirements := c.rawAnsible.Requirements
+	if requirements == nil {
+		return false
+	}
+
+	switch field {
+	case "include_role", "import_role":
+		return len(requirements.Roles) != 0 || len(requirements.Collections) != 0
+	}
+
+	// NAMESPACE.COLLECTION.MODULE
+	parts := strings.SplitN(field, ".", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return false
+	}
+
+	for _, collection := range requirements.Collections {
+		if collection.Name == strings.Join(parts[:2], ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *rawAnsibleTask) blockDefined() bool {
 	return c.Block != nil || c.Rescue != nil || c.Always != nil
 }
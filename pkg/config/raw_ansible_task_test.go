@@ -0,0 +1,49 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type ansibleTaskModuleEntry struct {
+	field        string
+	requirements *rawAnsibleRequirements
+	expected     bool
+}
+
+var _ = DescribeTable("checking ansible task module", func(e ansibleTaskModuleEntry) {
+	task := rawAnsibleTask{
+		Fields:     map[string]interface{}{e.field: map[string]interface{}{}},
+		rawAnsible: &rawAnsible{Requirements: e.requirements},
+	}
+
+	Ω(task.isModule(e.field)).Should(Equal(e.expected))
+},
+	Entry("supported module", ansibleTaskModuleEntry{
+		field:    "apt",
+		expected: true,
+	}),
+	Entry("unsupported module", ansibleTaskModuleEntry{
+		field:    "docker_container",
+		expected: false,
+	}),
+	Entry("module of the required collection", ansibleTaskModuleEntry{
+		field:        "community.general.ini_file",
+		requirements: &rawAnsibleRequirements{Collections: []rawAnsibleRequirement{{Name: "community.general", Version: "3.8.0"}}},
+		expected:     true,
+	}),
+	Entry("module of the collection which is not required", ansibleTaskModuleEntry{
+		field:        "community.docker.docker_container",
+		requirements: &rawAnsibleRequirements{Collections: []rawAnsibleRequirement{{Name: "community.general", Version: "3.8.0"}}},
+		expected:     false,
+	}),
+	Entry("include_role without requirements", ansibleTaskModuleEntry{
+		field:    "include_role",
+		expected: false,
+	}),
+	Entry("include_role with required roles", ansibleTaskModuleEntry{
+		field:        "include_role",
+		requirements: &rawAnsibleRequirements{Roles: []rawAnsibleRequirement{{Name: "geerlingguy.nginx", Version: "3.1.0"}}},
+		expected:     true,
+	}),
+)
@@ -0,0 +1,43 @@
+package config
+
+type rawDockerfileSecret struct {
+	ID               string `yaml:"id,omitempty"`
+	SecretValue      string `yaml:"secretValue,omitempty"`
+	SecretValuesFile string `yaml:"secretValuesFile,omitempty"`
+
+	rawImageFromDockerfile *rawImageFromDockerfile `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawDockerfileSecret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawImageFromDockerfile); ok {
+		c.rawImageFromDockerfile = parent
+	}
+
+	type plain rawDockerfileSecret
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawImageFromDockerfile.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawDockerfileSecret) toDirective() (secret *DockerfileSecret, err error) {
+	secret = &DockerfileSecret{}
+	secret.ID = c.ID
+	secret.SecretValue = c.SecretValue
+	secret.SecretValuesFile = c.SecretValuesFile
+
+	secret.raw = c
+
+	if err := secret.validate(); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+func parseImageFromDockerfile(data string) (*ImageFromDockerfile, error) {
+	parentStack = util.NewStack()
+
+	raw := &rawImageFromDockerfile{doc: &doc{Content: []byte(data), RenderFilePath: "werf.yaml"}}
+	if err := yaml.UnmarshalStrict([]byte(data), raw); err != nil {
+		return nil, err
+	}
+
+	// the giterminism manager is not used without the contextAddFiles directive
+	return raw.toImageFromDockerfileDirective(nil, raw.Images[0])
+}
+
+var _ = Describe("dockerfile secrets", func() {
+	It("should parse the secrets of the Dockerfile image", func() {
+		image, err := parseImageFromDockerfile(`
+image: app
+dockerfile: Dockerfile
+secrets:
+- id: npmrc
+  secretValue: npm.token
+- id: pip
+  secretValue: pip.conf
+  secretValuesFile: .helm/build-secret-values.yaml
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(image.Secrets).Should(HaveLen(2))
+
+		Ω(image.Secrets[0].ID).Should(Equal("npmrc"))
+		Ω(image.Secrets[0].SecretValue).Should(Equal("npm.token"))
+		Ω(image.Secrets[0].SecretValuesFile).Should(BeEmpty())
+
+		Ω(image.Secrets[1].ID).Should(Equal("pip"))
+		Ω(image.Secrets[1].SecretValue).Should(Equal("pip.conf"))
+		Ω(image.Secrets[1].SecretValuesFile).Should(Equal(".helm/build-secret-values.yaml"))
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			_, err := parseImageFromDockerfile(data)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("no id", "image: app\nsecrets:\n- secretValue: npm.token\n", "`id: ID` required for secret!"),
+		Entry("no secret value", "image: app\nsecrets:\n- id: npmrc\n", "`secretValue: KEY` required for secret!"),
+		Entry("absolute secret values file", "image: app\nsecrets:\n- id: npmrc\n  secretValue: npm.token\n  secretValuesFile: /secret-values.yaml\n", "`secretValuesFile: PATH` should be relative to project directory!"),
+		Entry("duplicate id", "image: app\nsecrets:\n- id: npmrc\n  secretValue: npm.token\n- id: npmrc\n  secretValue: npm.other\n", `duplicate secret id "npmrc"!`),
+		Entry("unknown field", "image: app\nsecrets:\n- id: npmrc\n  secretValue: npm.token\n  src: ~/.npmrc\n", "src"),
+	)
+})
@@ -12,6 +12,7 @@ type rawGit struct {
 	Branch               string                `yaml:"branch,omitempty"`
 	Tag                  string                `yaml:"tag,omitempty"`
 	Commit               string                `yaml:"commit,omitempty"`
+	PartialClone         bool                  `yaml:"partialClone,omitempty"`
 	RawStageDependencies *rawStageDependencies `yaml:"stageDependencies,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
@@ -80,6 +81,10 @@ func (c *rawGit) validateGitLocalDirective(gitLocal *GitLocal) (err error) {
 		return newDetailedConfigError("specify `branch: BRANCH`, `tag: TAG` and `commit: COMMIT` only for remote git!", nil, c.rawStapelImage.doc)
 	}
 
+	if c.PartialClone {
+		return newDetailedConfigError("specify `partialClone: true` only for remote git!", nil, c.rawStapelImage.doc)
+	}
+
 	if err := gitLocal.validate(); err != nil {
 		return err
 	}
@@ -133,6 +138,7 @@ func (c *rawGit) toGitRemoteDirective(giterminismManager giterminism_manager.Int
 
 	gitRemote.Url = c.Url
 	gitRemote.Name = getRepositoryID(c.Url)
+	gitRemote.PartialClone = c.PartialClone
 	gitRemote.raw = c
 
 	if err := c.validateGitRemoteDirective(gitRemote); err != nil {
@@ -17,6 +17,12 @@ type rawImageFromDockerfile struct {
 	AddHost         interface{}            `yaml:"addHost,omitempty"`
 	Network         string                 `yaml:"network,omitempty"`
 	SSH             string                 `yaml:"ssh,omitempty"`
+	Platform        string                 `yaml:"platform,omitempty"`
+	CacheStages     bool                   `yaml:"cacheStages,omitempty"`
+	Secrets         []*rawDockerfileSecret `yaml:"secrets,omitempty"`
+	Matrix          []*rawImageMatrixEntry `yaml:"matrix,omitempty"`
+
+	PlatformIndependentContextChecksum bool `yaml:"platformIndependentContextChecksum,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -68,11 +74,34 @@ func (c *rawImageFromDockerfile) UnmarshalYAML(unmarshal func(interface{}) error
 }
 
 func (c *rawImageFromDockerfile) toImageFromDockerfileDirectives(giterminismManager giterminism_manager.Interface) (images []*ImageFromDockerfile, err error) {
-	for _, imageName := range c.Images {
-		if image, err := c.toImageFromDockerfileDirective(giterminismManager, imageName); err != nil {
+	if len(c.Matrix) != 0 {
+		if err := validateImageMatrix(c.Matrix, c.doc); err != nil {
 			return nil, err
-		} else {
-			images = append(images, image)
+		}
+	}
+
+	for _, imageName := range c.Images {
+		if len(c.Matrix) == 0 {
+			if image, err := c.toImageFromDockerfileDirective(giterminismManager, imageName); err != nil {
+				return nil, err
+			} else {
+				images = append(images, image)
+			}
+
+			continue
+		}
+
+		for _, matrixEntry := range c.Matrix {
+			if image, err := c.toImageFromDockerfileDirective(giterminismManager, matrixEntry.imageName(imageName)); err != nil {
+				return nil, err
+			} else {
+				image.Args = matrixEntry.args(c.Args)
+				if matrixEntry.Platform != "" {
+					image.Platform = matrixEntry.Platform
+				}
+
+				images = append(images, image)
+			}
 		}
 	}
 
@@ -113,6 +142,17 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(giterminismManag
 
 	image.Network = c.Network
 	image.SSH = c.SSH
+	image.Platform = c.Platform
+	image.CacheStages = c.CacheStages
+	image.PlatformIndependentContextChecksum = c.PlatformIndependentContextChecksum
+
+	for _, rawSecret := range c.Secrets {
+		if secret, err := rawSecret.toDirective(); err != nil {
+			return nil, err
+		} else {
+			image.Secrets = append(image.Secrets, secret)
+		}
+	}
 
 	image.raw = c
 
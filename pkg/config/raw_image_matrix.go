@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+)
+
+type rawImageMatrixEntry struct {
+	Name     string                 `yaml:"name,omitempty"`
+	Platform string                 `yaml:"platform,omitempty"`
+	Args     map[string]interface{} `yaml:"args,omitempty"`
+
+	rawImageFromDockerfile *rawImageFromDockerfile `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawImageMatrixEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawImageFromDockerfile); ok {
+		c.rawImageFromDockerfile = parent
+	}
+
+	type plain rawImageMatrixEntry
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawImageFromDockerfile.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// imageName returns name of the image expanded from the matrix entry: <IMAGE_NAME>-<ENTRY_NAME> or <ENTRY_NAME> for the nameless image.
+func (c *rawImageMatrixEntry) imageName(baseImageName string) string {
+	if baseImageName == "" {
+		return c.Name
+	}
+
+	return fmt.Sprintf("%s-%s", baseImageName, c.Name)
+}
+
+// args returns image build args overridden by the matrix entry build args.
+func (c *rawImageMatrixEntry) args(baseArgs map[string]interface{}) map[string]interface{} {
+	if len(baseArgs) == 0 && len(c.Args) == 0 {
+		return nil
+	}
+
+	args := map[string]interface{}{}
+	for key, value := range baseArgs {
+		args[key] = value
+	}
+	for key, value := range c.Args {
+		args[key] = value
+	}
+
+	return args
+}
+
+func validateImageMatrix(matrix []*rawImageMatrixEntry, d *doc) error {
+	definedNames := map[string]bool{}
+	for _, entry := range matrix {
+		if entry.Name == "" {
+			return newDetailedConfigError("`matrix[].name: NAME` required for each matrix entry!", entry, d)
+		}
+
+		if definedNames[entry.Name] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate matrix entry name %q!", entry.Name), entry, d)
+		}
+		definedNames[entry.Name] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type imageMatrixEntry struct {
+	baseImageName     string
+	baseArgs          map[string]interface{}
+	matrixEntry       rawImageMatrixEntry
+	expectedImageName string
+	expectedArgs      map[string]interface{}
+}
+
+var _ = DescribeTable("expanding image matrix entry", func(e imageMatrixEntry) {
+	Ω(e.matrixEntry.imageName(e.baseImageName)).Should(Equal(e.expectedImageName))
+	Ω(e.matrixEntry.args(e.baseArgs)).Should(Equal(e.expectedArgs))
+},
+	Entry("named image", imageMatrixEntry{
+		baseImageName:     "app",
+		matrixEntry:       rawImageMatrixEntry{Name: "arm64"},
+		expectedImageName: "app-arm64",
+	}),
+	Entry("nameless image", imageMatrixEntry{
+		matrixEntry:       rawImageMatrixEntry{Name: "arm64"},
+		expectedImageName: "arm64",
+	}),
+	Entry("args override", imageMatrixEntry{
+		baseImageName:     "app",
+		baseArgs:          map[string]interface{}{"BASE": "alpine", "GO_VERSION": "1.15"},
+		matrixEntry:       rawImageMatrixEntry{Name: "go1.16", Args: map[string]interface{}{"GO_VERSION": "1.16"}},
+		expectedImageName: "app-go1.16",
+		expectedArgs:      map[string]interface{}{"BASE": "alpine", "GO_VERSION": "1.16"},
+	}),
+)
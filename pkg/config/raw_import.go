@@ -3,9 +3,12 @@ package config
 type rawImport struct {
 	ImageName    string `yaml:"image,omitempty"`
 	ArtifactName string `yaml:"artifact,omitempty"`
+	From         string `yaml:"from,omitempty"`
 	Before       string `yaml:"before,omitempty"`
 	After        string `yaml:"after,omitempty"`
 	Stage        string `yaml:"stage,omitempty"`
+	FileMode     string `yaml:"fileMode,omitempty"`
+	DirMode      string `yaml:"dirMode,omitempty"`
 
 	rawArtifactExport `yaml:",inline"`
 	rawStapelImage    *rawStapelImage `yaml:"-"` // parent
@@ -58,9 +61,12 @@ func (c *rawImport) toDirective() (imp *Import, err error) {
 
 	imp.ImageName = c.ImageName
 	imp.ArtifactName = c.ArtifactName
+	imp.From = c.From
 	imp.Before = c.Before
 	imp.After = c.After
 	imp.Stage = c.Stage
+	imp.FileMode = c.FileMode
+	imp.DirMode = c.DirMode
 
 	imp.raw = c
 
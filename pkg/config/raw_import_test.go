@@ -0,0 +1,80 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func parseImport(data string) (*Import, error) {
+	var rawImports []*rawImport
+	if err := unmarshalRawDirective(imageSection, data, &rawImports); err != nil {
+		return nil, err
+	}
+
+	return rawImports[0].toDirective()
+}
+
+var _ = Describe("import", func() {
+	It("should parse the glob source path and the mode overrides", func() {
+		imp, err := parseImport(`
+- artifact: build
+  add: /build/out/*.so
+  to: /usr/lib
+  owner: app
+  group: app
+  fileMode: 0644
+  dirMode: "0755"
+  after: install
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(imp.IsAddGlob()).Should(BeTrue())
+		Ω(imp.FileMode).Should(Equal("0644"))
+		Ω(imp.DirMode).Should(Equal("0755"))
+	})
+
+	It("should not treat the plain source path as glob", func() {
+		imp, err := parseImport(`
+- artifact: build
+  add: /build/out
+  after: install
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(imp.IsAddGlob()).Should(BeFalse())
+		Ω(imp.To).Should(Equal("/build/out"))
+	})
+
+	It("should parse the external image pinned by the digest", func() {
+		imp, err := parseImport(`
+- from: alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+  add: /etc/ssl/certs
+  after: install
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(imp.From).Should(Equal("alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+		Ω(imp.IsFromPinned()).Should(BeTrue())
+	})
+
+	It("should not treat the external image referenced by the tag as pinned", func() {
+		imp, err := parseImport(`
+- from: alpine:3.14
+  add: /etc/ssl/certs
+  after: install
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(imp.IsFromPinned()).Should(BeFalse())
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			_, err := parseImport(data)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("glob source path without destination directory", "- artifact: build\n  add: /build/out/*.so\n  after: install\n", "destination directory `to: PATH` without glob required"),
+		Entry("without source", "- add: /build/out\n  after: install\n", "external image `from: DOCKER_IMAGE@DIGEST` required for import"),
+		Entry("external image with image", "- from: alpine:3.14\n  image: app\n  add: /build/out\n  after: install\n", "specify only one artifact name"),
+		Entry("external image with stage", "- from: alpine:3.14\n  stage: install\n  add: /build/out\n  after: install\n", "`stage: STAGE` cannot be used with external image"),
+		Entry("symbolic mode", "- artifact: build\n  add: /build/out\n  fileMode: u+x\n  after: install\n", "invalid `fileMode: u+x` for import"),
+	)
+})
@@ -8,11 +8,16 @@ import (
 )
 
 type rawMeta struct {
-	ConfigVersion      *int                `yaml:"configVersion,omitempty"`
-	Project            *string             `yaml:"project,omitempty"`
-	Deploy             *rawMetaDeploy      `yaml:"deploy,omitempty"`
-	Cleanup            *rawMetaCleanup     `yaml:"cleanup,omitempty"`
-	GitWorktree        *rawMetaGitWorktree `yaml:"gitWorktree,omitempty"`
+	ConfigVersion   *int                     `yaml:"configVersion,omitempty"`
+	Project         *string                  `yaml:"project,omitempty"`
+	Deploy          *rawMetaDeploy           `yaml:"deploy,omitempty"`
+	Cleanup         *rawMetaCleanup          `yaml:"cleanup,omitempty"`
+	GitWorktree     *rawMetaGitWorktree      `yaml:"gitWorktree,omitempty"`
+	FinalRepo       *rawMetaFinalRepo        `yaml:"finalRepo,omitempty"`
+	Compose         *rawMetaCompose          `yaml:"compose,omitempty"`
+	Metadata        *rawMetaMetadata         `yaml:"metadata,omitempty"`
+	Dependencies    []*rawMetaDependency     `yaml:"dependencies,omitempty"`
+	RegistryMirrors []*rawMetaRegistryMirror `yaml:"registryMirrors,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -44,6 +49,14 @@ func (c *rawMeta) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return newDetailedConfigError(fmt.Sprintf("bad project name %q specified in config: %s", *c.Project, err), nil, c.doc)
 	}
 
+	dependencyAliases := map[string]bool{}
+	for _, dependency := range c.Dependencies {
+		if dependencyAliases[dependency.alias()] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate dependency name %q: specify unique dependencies[].as", dependency.alias()), nil, c.doc)
+		}
+		dependencyAliases[dependency.alias()] = true
+	}
+
 	return nil
 }
 
@@ -75,5 +88,25 @@ func (c *rawMeta) toMeta() *Meta {
 		meta.GitWorktree = c.GitWorktree.toMetaGitWorktree()
 	}
 
+	if c.FinalRepo != nil {
+		meta.FinalRepo = c.FinalRepo.toMetaFinalRepo()
+	}
+
+	if c.Compose != nil {
+		meta.Compose = c.Compose.toMetaCompose()
+	}
+
+	if c.Metadata != nil {
+		meta.Metadata = c.Metadata.toMetaMetadata()
+	}
+
+	for _, dependency := range c.Dependencies {
+		meta.Dependencies = append(meta.Dependencies, dependency.toMetaDependency())
+	}
+
+	for _, registryMirror := range c.RegistryMirrors {
+		meta.RegistryMirrors = append(meta.RegistryMirrors, registryMirror.toMetaRegistryMirror())
+	}
+
 	return meta
 }
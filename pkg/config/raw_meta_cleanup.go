@@ -5,10 +5,15 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
 )
 
 type rawMetaCleanup struct {
 	KeepPolicies []*rawMetaCleanupKeepPolicy `yaml:"keepPolicies,omitempty"`
+	PolicySets   []*rawMetaCleanupPolicySet  `yaml:"policySets,omitempty"`
 
 	rawMeta               *rawMeta
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
@@ -22,6 +27,15 @@ type rawMetaCleanupKeepPolicy struct {
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
 }
 
+type rawMetaCleanupPolicySet struct {
+	Ref       string `yaml:"ref,omitempty"`
+	Digest    string `yaml:"digest,omitempty"`
+	PublicKey string `yaml:"publicKey,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
 type rawMetaCleanupKeepPolicyReferences struct {
 	Tag    string                                   `yaml:"tag,omitempty"`
 	Branch string                                   `yaml:"branch,omitempty"`
@@ -89,6 +103,34 @@ func (c *rawMetaCleanupKeepPolicy) UnmarshalYAML(unmarshal func(interface{}) err
 	return nil
 }
 
+func (c *rawMetaCleanupPolicySet) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
+		c.rawMetaCleanup = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupPolicySet
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Ref == "" {
+		return newDetailedConfigError("ref `ref: REPO:TAG` required for cleanup policy set!", c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	if c.Digest != "" && !strings.HasPrefix(c.Digest, "sha256:") {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %q for `digest: sha256:DIGEST`!", c.Digest), c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	return nil
+}
+
 func (c *rawMetaCleanupKeepPolicyReferences) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if parent, ok := parentStack.Peek().(*rawMetaCleanupKeepPolicy); ok {
 		c.rawMetaCleanup = parent.rawMetaCleanup
@@ -185,9 +227,35 @@ func (c *rawMetaCleanup) toMetaCleanup() MetaCleanup {
 		metaCleanup.KeepPolicies = append(metaCleanup.KeepPolicies, policy.toMetaCleanupKeepPolicy())
 	}
 
+	for _, policySet := range c.PolicySets {
+		metaCleanup.PolicySets = append(metaCleanup.PolicySets, &MetaCleanupPolicySet{
+			Ref:       policySet.Ref,
+			Digest:    policySet.Digest,
+			PublicKey: policySet.PublicKey,
+		})
+	}
+
 	return metaCleanup
 }
 
+// ParseCleanupPolicySet parses the keep policies of the centrally published cleanup policy set,
+// the policy set has the same format as the cleanup section of werf.yaml.
+func ParseCleanupPolicySet(data []byte, source string) ([]*MetaCleanupKeepPolicy, error) {
+	policySetDoc := &doc{Content: data, RenderFilePath: source}
+	rawCleanup := &rawMetaCleanup{rawMeta: &rawMeta{doc: policySetDoc}}
+
+	parentStack = util.NewStack()
+	if err := yaml.UnmarshalStrict(data, rawCleanup); err != nil {
+		return nil, newYamlUnmarshalError(err, policySetDoc)
+	}
+
+	if len(rawCleanup.PolicySets) != 0 {
+		return nil, newDetailedConfigError("nested policy sets are not supported in the cleanup policy set!", nil, policySetDoc)
+	}
+
+	return rawCleanup.toMetaCleanup().KeepPolicies, nil
+}
+
 func (c *rawMetaCleanupKeepPolicy) toMetaCleanupKeepPolicy() *MetaCleanupKeepPolicy {
 	policy := &MetaCleanupKeepPolicy{}
 
@@ -0,0 +1,47 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseCleanupPolicySet", func() {
+	It("should parse keep policies", func() {
+		policies, err := ParseCleanupPolicySet([]byte(`
+keepPolicies:
+- references:
+    tag: /.*/
+    limit:
+      last: 10
+- references:
+    branch: main
+  imagesPerReference:
+    last: 2
+`), "registry.example.com/policies:v1")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(policies).Should(HaveLen(2))
+		Ω(policies[0].References.TagRegexp.String()).Should(Equal("^.*$"))
+		Ω(*policies[0].References.Limit.Last).Should(Equal(10))
+		Ω(policies[1].References.BranchRegexp.String()).Should(Equal("^main$"))
+		Ω(*policies[1].ImagesPerReference.Last).Should(Equal(2))
+	})
+
+	It("should fail on unknown fields", func() {
+		_, err := ParseCleanupPolicySet([]byte(`
+keepPolicies:
+- references:
+    tag: /.*/
+  unknown: true
+`), "registry.example.com/policies:v1")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should fail on nested policy sets", func() {
+		_, err := ParseCleanupPolicySet([]byte(`
+policySets:
+- ref: registry.example.com/other-policies:v1
+`), "registry.example.com/policies:v1")
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("nested policy sets"))
+	})
+})
@@ -0,0 +1,47 @@
+package config
+
+import "fmt"
+
+type rawMetaCompose struct {
+	Services map[string]string `yaml:"services,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaCompose) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCompose
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, nil, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	for service, imageName := range c.Services {
+		if service == "" {
+			return newDetailedConfigError("compose.services cannot contain empty service name!", nil, c.rawMeta.doc)
+		}
+
+		if imageName == "" {
+			return newDetailedConfigError(fmt.Sprintf("image name for compose service `%s` cannot be empty!", service), nil, c.rawMeta.doc)
+		}
+	}
+
+	return nil
+}
+
+func (c *rawMetaCompose) toMetaCompose() MetaCompose {
+	obj := MetaCompose{}
+	obj.Services = c.Services
+	return obj
+}
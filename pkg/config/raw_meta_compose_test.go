@@ -0,0 +1,44 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("compose", func() {
+	It("should parse the services mapping", func() {
+		rawCompose := &rawMetaCompose{}
+		Ω(unmarshalRawDirective(metaSection, `
+services:
+  web: frontend
+  worker: backend
+`, rawCompose)).Should(Succeed())
+
+		Ω(rawCompose.toMetaCompose()).Should(Equal(MetaCompose{Services: map[string]string{"web": "frontend", "worker": "backend"}}))
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			err := unmarshalRawDirective(metaSection, data, &rawMetaCompose{})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("empty service name", "services:\n  \"\": frontend\n", "compose.services cannot contain empty service name"),
+		Entry("empty image name", "services:\n  web: \"\"\n", "image name for compose service `web` cannot be empty"),
+		Entry("unsupported attribute", "images:\n  web: frontend\n", "images"),
+	)
+
+	It("should validate the images of the compose services", func() {
+		werfConfig := &WerfConfig{
+			Meta:                 &Meta{Compose: MetaCompose{Services: map[string]string{"web": "frontend"}}},
+			ImagesFromDockerfile: []*ImageFromDockerfile{{Name: "frontend"}},
+		}
+		Ω(werfConfig.validateComposeServices()).Should(Succeed())
+
+		werfConfig.Meta.Compose.Services["worker"] = "backend"
+		err := werfConfig.validateComposeServices()
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("no such image `backend` for compose service `worker`"))
+	})
+})
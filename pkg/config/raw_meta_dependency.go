@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/werf/werf/pkg/slug"
+)
+
+var dependencyAliasRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type rawMetaDependency struct {
+	Project string `yaml:"project,omitempty"`
+	Image   string `yaml:"image,omitempty"`
+	As      string `yaml:"as,omitempty"`
+	Repo    string `yaml:"repo,omitempty"`
+	Commit  string `yaml:"commit,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDependency) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDependency
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, nil, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Project == "" {
+		return newDetailedConfigError("dependencies[].project cannot be empty!", nil, c.rawMeta.doc)
+	}
+
+	if err := slug.ValidateProject(c.Project); err != nil {
+		return newDetailedConfigError(fmt.Sprintf("bad dependencies[].project %q: %s", c.Project, err), nil, c.rawMeta.doc)
+	}
+
+	if c.Image == "" {
+		return newDetailedConfigError("dependencies[].image cannot be empty!", nil, c.rawMeta.doc)
+	}
+
+	if !dependencyAliasRegexp.MatchString(c.alias()) {
+		return newDetailedConfigError(fmt.Sprintf("bad dependency name %q: specify dependencies[].as matching %s to use the dependency in templates and values", c.alias(), dependencyAliasRegexp.String()), nil, c.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaDependency) alias() string {
+	if c.As != "" {
+		return c.As
+	}
+	return c.Image
+}
+
+func (c *rawMetaDependency) toMetaDependency() MetaDependency {
+	return MetaDependency{
+		Project: c.Project,
+		Image:   c.Image,
+		As:      c.alias(),
+		Repo:    c.Repo,
+		Commit:  c.Commit,
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dependencies", func() {
+	It("should use the image name as the dependency name by default", func() {
+		var rawDependencies []*rawMetaDependency
+		Ω(unmarshalRawDirective(metaSection, `
+- project: backend
+  image: api
+- project: frontend
+  image: web-app
+  as: web
+  repo: registry.example.com/frontend
+  commit: 1234567
+`, &rawDependencies)).Should(Succeed())
+		Ω(rawDependencies).Should(HaveLen(2))
+
+		Ω(rawDependencies[0].toMetaDependency()).Should(Equal(MetaDependency{Project: "backend", Image: "api", As: "api"}))
+		Ω(rawDependencies[1].toMetaDependency()).Should(Equal(MetaDependency{Project: "frontend", Image: "web-app", As: "web", Repo: "registry.example.com/frontend", Commit: "1234567"}))
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			var rawDependencies []*rawMetaDependency
+			err := unmarshalRawDirective(metaSection, data, &rawDependencies)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("name unusable in templates", "- project: frontend\n  image: web-app\n", "dependencies[].as"),
+		Entry("without project", "- image: api\n", "dependencies[].project"),
+		Entry("without image", "- project: backend\n", "dependencies[].image"),
+	)
+})
@@ -1,11 +1,23 @@
 package config
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
 type rawMetaDeploy struct {
 	HelmChartDir    *string `yaml:"helmChartDir,omitempty"`
 	HelmRelease     *string `yaml:"helmRelease,omitempty"`
 	HelmReleaseSlug *bool   `yaml:"helmReleaseSlug,omitempty"`
 	Namespace       *string `yaml:"namespace,omitempty"`
 	NamespaceSlug   *bool   `yaml:"namespaceSlug,omitempty"`
+	SecretsBackend  *string `yaml:"secretsBackend,omitempty"`
+
+	Tracking []*rawMetaDeployTracking `yaml:"tracking,omitempty"`
+	Targets  []*rawMetaDeployTarget   `yaml:"targets,omitempty"`
+
+	Bootstrap *rawMetaDeployBootstrap `yaml:"bootstrap,omitempty"`
 
 	rawMeta *rawMeta
 
@@ -41,6 +53,23 @@ func (c *rawMetaDeploy) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return newDetailedConfigError("namespace field cannot be empty!", nil, c.rawMeta.doc)
 	}
 
+	if c.SecretsBackend != nil {
+		switch *c.SecretsBackend {
+		case "aes", "sops":
+		default:
+			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `secretsBackend: aes|sops`!", *c.SecretsBackend), nil, c.rawMeta.doc)
+		}
+	}
+
+	targets := map[string]bool{}
+	for _, target := range c.Targets {
+		key := fmt.Sprintf("%s/%s", target.KubeContext, target.Namespace)
+		if targets[key] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate deploy target with kubeContext %q and namespace %q!", target.KubeContext, target.Namespace), nil, c.rawMeta.doc)
+		}
+		targets[key] = true
+	}
+
 	return nil
 }
 
@@ -51,5 +80,155 @@ func (c *rawMetaDeploy) toMetaDeploy() MetaDeploy {
 	metaDeploy.HelmReleaseSlug = c.HelmReleaseSlug
 	metaDeploy.Namespace = c.Namespace
 	metaDeploy.NamespaceSlug = c.NamespaceSlug
+	metaDeploy.SecretsBackend = c.SecretsBackend
+
+	for _, tracking := range c.Tracking {
+		metaDeploy.Tracking = append(metaDeploy.Tracking, tracking.toMetaDeployTracking())
+	}
+
+	for _, target := range c.Targets {
+		metaDeploy.Targets = append(metaDeploy.Targets, target.toMetaDeployTarget())
+	}
+
+	if c.Bootstrap != nil {
+		metaDeploy.Bootstrap = c.Bootstrap.toMetaDeployBootstrap()
+	}
+
 	return metaDeploy
 }
+
+type rawMetaDeployTracking struct {
+	Kind string `yaml:"kind,omitempty"`
+	Name string `yaml:"name,omitempty"`
+
+	TrackTerminationMode      *string  `yaml:"trackTerminationMode,omitempty"`
+	FailMode                  *string  `yaml:"failMode,omitempty"`
+	FailuresAllowedPerReplica *int     `yaml:"failuresAllowedPerReplica,omitempty"`
+	LogRegex                  *string  `yaml:"logRegex,omitempty"`
+	SkipLogs                  *bool    `yaml:"skipLogs,omitempty"`
+	SkipLogsForContainers     []string `yaml:"skipLogsForContainers,omitempty"`
+	ShowLogsOnlyForContainers []string `yaml:"showLogsOnlyForContainers,omitempty"`
+
+	NameRegexp *regexp.Regexp `yaml:"-"`
+
+	rawMetaDeploy         *rawMetaDeploy
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDeployTracking) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaDeploy); ok {
+		c.rawMetaDeploy = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDeployTracking
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaDeploy.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Name != "" {
+		var value string
+		if strings.HasPrefix(c.Name, "/") && strings.HasSuffix(c.Name, "/") && len(c.Name) > 1 {
+			value = strings.TrimSuffix(strings.TrimPrefix(c.Name, "/"), "/")
+		} else {
+			value = regexp.QuoteMeta(c.Name)
+		}
+
+		regex, err := regexp.Compile(fmt.Sprintf("^%s$", value))
+		if err != nil {
+			return newDetailedConfigError(fmt.Sprintf("invalid value %q for `name: string|REGEX`!", c.Name), c, c.rawMetaDeploy.rawMeta.doc)
+		}
+		c.NameRegexp = regex
+	}
+
+	if c.TrackTerminationMode != nil {
+		switch *c.TrackTerminationMode {
+		case "WaitUntilResourceReady", "NonBlocking":
+		default:
+			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `trackTerminationMode: WaitUntilResourceReady|NonBlocking`!", *c.TrackTerminationMode), c, c.rawMetaDeploy.rawMeta.doc)
+		}
+	}
+
+	if c.FailMode != nil {
+		switch *c.FailMode {
+		case "FailWholeDeployProcessImmediately", "HopeUntilEndOfDeployProcess", "IgnoreAndContinueDeployProcess":
+		default:
+			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `failMode: FailWholeDeployProcessImmediately|HopeUntilEndOfDeployProcess|IgnoreAndContinueDeployProcess`!", *c.FailMode), c, c.rawMetaDeploy.rawMeta.doc)
+		}
+	}
+
+	if c.FailuresAllowedPerReplica != nil && *c.FailuresAllowedPerReplica < 0 {
+		return newDetailedConfigError("`failuresAllowedPerReplica: int` cannot be negative!", c, c.rawMetaDeploy.rawMeta.doc)
+	}
+
+	if c.LogRegex != nil {
+		if _, err := regexp.Compile(*c.LogRegex); err != nil {
+			return newDetailedConfigError(fmt.Sprintf("invalid value %q for `logRegex: REGEX`: %s", *c.LogRegex, err), c, c.rawMetaDeploy.rawMeta.doc)
+		}
+	}
+
+	if c.SkipLogsForContainers != nil && c.ShowLogsOnlyForContainers != nil {
+		return newDetailedConfigError("specify only `skipLogsForContainers` or `showLogsOnlyForContainers` for deploy tracking!", c, c.rawMetaDeploy.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaDeployTracking) toMetaDeployTracking() *MetaDeployTracking {
+	return &MetaDeployTracking{
+		Kind:                      c.Kind,
+		NameRegexp:                c.NameRegexp,
+		TrackTerminationMode:      c.TrackTerminationMode,
+		FailMode:                  c.FailMode,
+		FailuresAllowedPerReplica: c.FailuresAllowedPerReplica,
+		LogRegex:                  c.LogRegex,
+		SkipLogs:                  c.SkipLogs,
+		SkipLogsForContainers:     c.SkipLogsForContainers,
+		ShowLogsOnlyForContainers: c.ShowLogsOnlyForContainers,
+	}
+}
+
+type rawMetaDeployTarget struct {
+	KubeContext string `yaml:"kubeContext,omitempty"`
+	Namespace   string `yaml:"namespace,omitempty"`
+
+	rawMetaDeploy         *rawMetaDeploy
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDeployTarget) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaDeploy); ok {
+		c.rawMetaDeploy = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDeployTarget
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaDeploy.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.KubeContext == "" {
+		return newDetailedConfigError("`kubeContext: string` is required for deploy target!", c, c.rawMetaDeploy.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaDeployTarget) toMetaDeployTarget() *MetaDeployTarget {
+	return &MetaDeployTarget{
+		KubeContext: c.KubeContext,
+		Namespace:   c.Namespace,
+	}
+}
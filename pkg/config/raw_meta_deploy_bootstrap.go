@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+)
+
+type rawMetaDeployBootstrap struct {
+	Namespace        *rawMetaDeployBootstrapNamespace        `yaml:"namespace,omitempty"`
+	ImagePullSecrets []string                                `yaml:"imagePullSecrets,omitempty"`
+	ServiceAccounts  []*rawMetaDeployBootstrapServiceAccount `yaml:"serviceAccounts,omitempty"`
+
+	rawMetaDeploy         *rawMetaDeploy
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDeployBootstrap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaDeploy); ok {
+		c.rawMetaDeploy = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDeployBootstrap
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaDeploy.rawMeta.doc); err != nil {
+		return err
+	}
+
+	imagePullSecrets := map[string]bool{}
+	for _, name := range c.ImagePullSecrets {
+		if name == "" {
+			return newDetailedConfigError("`imagePullSecrets: [string, ...]` cannot contain empty names!", c, c.rawMetaDeploy.rawMeta.doc)
+		}
+
+		if imagePullSecrets[name] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate bootstrap image pull secret %q!", name), c, c.rawMetaDeploy.rawMeta.doc)
+		}
+		imagePullSecrets[name] = true
+	}
+
+	serviceAccounts := map[string]bool{}
+	for _, serviceAccount := range c.ServiceAccounts {
+		if serviceAccounts[serviceAccount.Name] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate bootstrap service account %q!", serviceAccount.Name), c, c.rawMetaDeploy.rawMeta.doc)
+		}
+		serviceAccounts[serviceAccount.Name] = true
+	}
+
+	return nil
+}
+
+func (c *rawMetaDeployBootstrap) toMetaDeployBootstrap() *MetaDeployBootstrap {
+	bootstrap := &MetaDeployBootstrap{
+		ImagePullSecrets: c.ImagePullSecrets,
+	}
+
+	if c.Namespace != nil {
+		bootstrap.Namespace = &MetaDeployBootstrapNamespace{
+			Labels:      c.Namespace.Labels,
+			Annotations: c.Namespace.Annotations,
+		}
+	}
+
+	for _, serviceAccount := range c.ServiceAccounts {
+		bootstrap.ServiceAccounts = append(bootstrap.ServiceAccounts, serviceAccount.toMetaDeployBootstrapServiceAccount())
+	}
+
+	return bootstrap
+}
+
+type rawMetaDeployBootstrapNamespace struct {
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	rawMetaDeployBootstrap *rawMetaDeployBootstrap
+	UnsupportedAttributes  map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDeployBootstrapNamespace) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaDeployBootstrap); ok {
+		c.rawMetaDeployBootstrap = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDeployBootstrapNamespace
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	return checkOverflow(c.UnsupportedAttributes, c, c.rawMetaDeployBootstrap.rawMetaDeploy.rawMeta.doc)
+}
+
+type rawMetaDeployBootstrapServiceAccount struct {
+	Name         string                              `yaml:"name,omitempty"`
+	ClusterRoles []string                            `yaml:"clusterRoles,omitempty"`
+	Rules        []*rawMetaDeployBootstrapPolicyRule `yaml:"rules,omitempty"`
+
+	rawMetaDeployBootstrap *rawMetaDeployBootstrap
+	UnsupportedAttributes  map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDeployBootstrapServiceAccount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaDeployBootstrap); ok {
+		c.rawMetaDeployBootstrap = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDeployBootstrapServiceAccount
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	doc := c.rawMetaDeployBootstrap.rawMetaDeploy.rawMeta.doc
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, doc); err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		return newDetailedConfigError("`name: string` is required for bootstrap service account!", c, doc)
+	}
+
+	for _, rule := range c.Rules {
+		if len(rule.Verbs) == 0 || len(rule.Resources) == 0 {
+			return newDetailedConfigError(fmt.Sprintf("`verbs: [string, ...]` and `resources: [string, ...]` are required for bootstrap service account %q rule!", c.Name), c, doc)
+		}
+	}
+
+	return nil
+}
+
+func (c *rawMetaDeployBootstrapServiceAccount) toMetaDeployBootstrapServiceAccount() *MetaDeployBootstrapServiceAccount {
+	serviceAccount := &MetaDeployBootstrapServiceAccount{
+		Name:         c.Name,
+		ClusterRoles: c.ClusterRoles,
+	}
+
+	for _, rule := range c.Rules {
+		serviceAccount.Rules = append(serviceAccount.Rules, &MetaDeployBootstrapPolicyRule{
+			APIGroups:     rule.APIGroups,
+			Resources:     rule.Resources,
+			ResourceNames: rule.ResourceNames,
+			Verbs:         rule.Verbs,
+		})
+	}
+
+	return serviceAccount
+}
+
+type rawMetaDeployBootstrapPolicyRule struct {
+	APIGroups     []string `yaml:"apiGroups,omitempty"`
+	Resources     []string `yaml:"resources,omitempty"`
+	ResourceNames []string `yaml:"resourceNames,omitempty"`
+	Verbs         []string `yaml:"verbs,omitempty"`
+
+	rawMetaDeployBootstrapServiceAccount *rawMetaDeployBootstrapServiceAccount
+	UnsupportedAttributes                map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaDeployBootstrapPolicyRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaDeployBootstrapServiceAccount); ok {
+		c.rawMetaDeployBootstrapServiceAccount = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaDeployBootstrapPolicyRule
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	return checkOverflow(c.UnsupportedAttributes, c, c.rawMetaDeployBootstrapServiceAccount.rawMetaDeployBootstrap.rawMetaDeploy.rawMeta.doc)
+}
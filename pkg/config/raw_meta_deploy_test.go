@@ -0,0 +1,97 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func parseMetaDeploy(data string) MetaDeploy {
+	rawDeploy := &rawMetaDeploy{}
+	Ω(unmarshalRawDirective(metaSection, data, rawDeploy)).Should(Succeed())
+	return rawDeploy.toMetaDeploy()
+}
+
+var _ = Describe("deploy", func() {
+	It("should match tracked resources by kind and name", func() {
+		tracking := parseMetaDeploy(`
+tracking:
+- kind: deployment
+  name: /redis-.*/
+  failMode: IgnoreAndContinueDeployProcess
+  skipLogsForContainers: [exporter]
+- name: migrations
+  trackTerminationMode: NonBlocking
+`).Tracking
+		Ω(tracking).Should(HaveLen(2))
+
+		Ω(tracking[0].Match("Deployment", "redis-master")).Should(BeTrue())
+		Ω(tracking[0].Match("StatefulSet", "redis-master")).Should(BeFalse())
+		Ω(tracking[0].Match("Deployment", "backend")).Should(BeFalse())
+		Ω(*tracking[0].FailMode).Should(Equal("IgnoreAndContinueDeployProcess"))
+		Ω(tracking[0].SkipLogsForContainers).Should(Equal([]string{"exporter"}))
+
+		Ω(tracking[1].Match("Job", "migrations")).Should(BeTrue())
+		Ω(tracking[1].Match("Job", "migrations-2")).Should(BeFalse())
+	})
+
+	It("should parse targets", func() {
+		targets := parseMetaDeploy(`
+targets:
+- kubeContext: eu-central
+- kubeContext: us-east
+  namespace: "[[ project ]]-us"
+`).Targets
+		Ω(targets).Should(HaveLen(2))
+		Ω(*targets[0]).Should(Equal(MetaDeployTarget{KubeContext: "eu-central"}))
+		Ω(*targets[1]).Should(Equal(MetaDeployTarget{KubeContext: "us-east", Namespace: "[[ project ]]-us"}))
+	})
+
+	It("should parse bootstrap", func() {
+		bootstrap := parseMetaDeploy(`
+bootstrap:
+  namespace:
+    labels:
+      team: backend
+    annotations:
+      owner: backend@example.com
+  imagePullSecrets: [registry]
+  serviceAccounts:
+  - name: app
+    clusterRoles: [view]
+    rules:
+    - apiGroups: [""]
+      resources: [configmaps]
+      verbs: [get, list]
+`).Bootstrap
+		Ω(bootstrap).ShouldNot(BeNil())
+		Ω(bootstrap.Namespace.Labels).Should(Equal(map[string]string{"team": "backend"}))
+		Ω(bootstrap.Namespace.Annotations).Should(Equal(map[string]string{"owner": "backend@example.com"}))
+		Ω(bootstrap.ImagePullSecrets).Should(Equal([]string{"registry"}))
+		Ω(bootstrap.ServiceAccounts).Should(HaveLen(1))
+		Ω(bootstrap.ServiceAccounts[0].ClusterRoles).Should(Equal([]string{"view"}))
+		Ω(*bootstrap.ServiceAccounts[0].Rules[0]).Should(Equal(MetaDeployBootstrapPolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "list"},
+		}))
+	})
+
+	It("should parse secrets backend", func() {
+		Ω(*parseMetaDeploy("secretsBackend: sops\n").SecretsBackend).Should(Equal("sops"))
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			err := unmarshalRawDirective(metaSection, data, &rawMetaDeploy{})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("unsupported track termination mode", "tracking:\n- kind: Deployment\n  trackTerminationMode: Never\n", "trackTerminationMode"),
+		Entry("target without kube context", "targets:\n- namespace: myapp\n", "kubeContext"),
+		Entry("duplicate targets", "targets:\n- kubeContext: eu-central\n- kubeContext: eu-central\n", "duplicate deploy target"),
+		Entry("service account without name", "bootstrap:\n  serviceAccounts:\n  - clusterRoles: [view]\n", "name"),
+		Entry("rule without verbs", "bootstrap:\n  serviceAccounts:\n  - name: app\n    rules:\n    - resources: [configmaps]\n", "verbs"),
+		Entry("unsupported secrets backend", "secretsBackend: vault\n", "secretsBackend"),
+	)
+})
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+type rawMetaFinalRepo struct {
+	Environments []string `yaml:"environments,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaFinalRepo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaFinalRepo
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, nil, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	for _, env := range c.Environments {
+		if env == "" {
+			return newDetailedConfigError("finalRepo.environments cannot contain empty values!", nil, c.rawMeta.doc)
+		}
+
+		if _, err := path.Match(env, ""); err != nil {
+			return newDetailedConfigError(fmt.Sprintf("bad finalRepo.environments pattern %q: %s", env, err), nil, c.rawMeta.doc)
+		}
+	}
+
+	return nil
+}
+
+func (c *rawMetaFinalRepo) toMetaFinalRepo() MetaFinalRepo {
+	obj := MetaFinalRepo{}
+	obj.Environments = c.Environments
+	return obj
+}
@@ -0,0 +1,51 @@
+package config
+
+import "fmt"
+
+type rawMetaMetadata struct {
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaMetadata) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaMetadata
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, nil, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	for section, values := range map[string]map[string]string{"annotations": c.Annotations, "labels": c.Labels} {
+		for key, value := range values {
+			if key == "" {
+				return newDetailedConfigError(fmt.Sprintf("metadata.%s cannot contain empty key!", section), nil, c.rawMeta.doc)
+			}
+
+			if _, err := newMetadataTemplate(key, MetadataTemplateData{}).Parse(value); err != nil {
+				return newDetailedConfigError(fmt.Sprintf("bad metadata.%s %q template: %s", section, key, err), nil, c.rawMeta.doc)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *rawMetaMetadata) toMetaMetadata() MetaMetadata {
+	obj := MetaMetadata{}
+	obj.Annotations = c.Annotations
+	obj.Labels = c.Labels
+	return obj
+}
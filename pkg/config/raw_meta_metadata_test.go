@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metadata", func() {
+	It("should render the annotations and labels templates", func() {
+		rawMetadata := &rawMetaMetadata{}
+		Ω(unmarshalRawDirective(metaSection, `
+annotations:
+  ci.werf.io/commit: "[[ commit ]]"
+  ci.werf.io/env: "[[ env | default \"none\" ]]"
+labels:
+  werf.io/built-by: werf-[[ werfVersion ]]
+  app: "[[ project ]]"
+`, rawMetadata)).Should(Succeed())
+
+		metadata := rawMetadata.toMetaMetadata()
+		data := MetadataTemplateData{Project: "myproject", Commit: "abc", WerfVersion: "v1.2.0"}
+
+		annotations, err := metadata.RenderAnnotations(data)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(annotations).Should(Equal(map[string]string{"ci.werf.io/commit": "abc", "ci.werf.io/env": "none"}))
+
+		labels, err := metadata.RenderLabels(data)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(labels).Should(Equal(map[string]string{"werf.io/built-by": "werf-v1.2.0", "app": "myproject"}))
+	})
+
+	It("should render the env and expandenv functions", func() {
+		rawMetadata := &rawMetaMetadata{}
+		Ω(unmarshalRawDirective(metaSection, `
+labels:
+  env: "[[ env ]]"
+  pipeline: "[[ env \"CI_PIPELINE_ID\" ]]"
+  url: "[[ expandenv \"https://ci.example.com/$CI_PIPELINE_ID\" ]]"
+`, rawMetadata)).Should(Succeed())
+
+		lookupEnv := func(name string) (string, error) {
+			if name != "CI_PIPELINE_ID" {
+				return "", fmt.Errorf("env %q is not allowed", name)
+			}
+			return "42", nil
+		}
+
+		labels, err := rawMetadata.toMetaMetadata().RenderLabels(MetadataTemplateData{Env: "production", LookupEnv: lookupEnv})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(labels).Should(Equal(map[string]string{"env": "production", "pipeline": "42", "url": "https://ci.example.com/42"}))
+
+		_, err = MetaMetadata{Labels: map[string]string{"home": "[[ expandenv \"$HOME\" ]]"}}.RenderLabels(MetadataTemplateData{LookupEnv: lookupEnv})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(`env "HOME" is not allowed`))
+	})
+
+	It("should fail on the bad template", func() {
+		err := unmarshalRawDirective(metaSection, `
+labels:
+  app: "[[ project "
+`, &rawMetaMetadata{})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(`bad metadata.labels "app" template`))
+	})
+
+	It("should render the empty metadata", func() {
+		annotations, err := MetaMetadata{}.RenderAnnotations(MetadataTemplateData{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(annotations).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+type rawMetaRegistryMirror struct {
+	Registry string `yaml:"registry,omitempty"`
+	Mirror   string `yaml:"mirror,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaRegistryMirror) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaRegistryMirror
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, nil, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Mirror == "" {
+		return newDetailedConfigError("registryMirrors[].mirror cannot be empty!", nil, c.rawMeta.doc)
+	}
+
+	if err := docker_registry.ValidateRegistryMirror(&docker_registry.RegistryMirror{Registry: c.Registry, Mirror: c.Mirror}); err != nil {
+		return newDetailedConfigError(fmt.Sprintf("bad registryMirrors[] item: %s", err), nil, c.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaRegistryMirror) toMetaRegistryMirror() MetaRegistryMirror {
+	return MetaRegistryMirror{
+		Registry: c.Registry,
+		Mirror:   c.Mirror,
+	}
+}
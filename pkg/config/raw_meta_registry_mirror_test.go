@@ -0,0 +1,33 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("registryMirrors", func() {
+	It("should parse the mirrors of Docker Hub and other registries", func() {
+		var rawRegistryMirrors []*rawMetaRegistryMirror
+		Ω(unmarshalRawDirective(metaSection, `
+- mirror: mirror.gcr.io
+- registry: quay.io
+  mirror: https://nexus.example.com/quay-proxy/
+`, &rawRegistryMirrors)).Should(Succeed())
+		Ω(rawRegistryMirrors).Should(HaveLen(2))
+
+		Ω(rawRegistryMirrors[0].toMetaRegistryMirror()).Should(Equal(MetaRegistryMirror{Mirror: "mirror.gcr.io"}))
+		Ω(rawRegistryMirrors[1].toMetaRegistryMirror()).Should(Equal(MetaRegistryMirror{Registry: "quay.io", Mirror: "https://nexus.example.com/quay-proxy/"}))
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			var rawRegistryMirrors []*rawMetaRegistryMirror
+			err := unmarshalRawDirective(metaSection, data, &rawRegistryMirrors)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("without mirror address", "- registry: quay.io\n", "registryMirrors[].mirror"),
+		Entry("bad mirror address", "- mirror: \"mirror.example.com/Bad Repo\"\n", "bad registryMirrors[] item"),
+	)
+})
@@ -6,6 +6,8 @@ type rawMount struct {
 	To       string `yaml:"to,omitempty"`
 	From     string `yaml:"from,omitempty"`
 	FromPath string `yaml:"fromPath,omitempty"`
+	Size     string `yaml:"size,omitempty"`
+	Name     string `yaml:"name,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
 
@@ -33,6 +35,8 @@ func (c *rawMount) toDirective(giterminismManager giterminism_manager.Interface)
 	mount = &Mount{}
 	mount.To = c.To
 	mount.From = c.FromPath
+	mount.Size = c.Size
+	mount.Name = c.Name
 
 	if c.From == "" {
 		mount.Type = "custom_dir"
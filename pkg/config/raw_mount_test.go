@@ -0,0 +1,56 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func parseMounts(data string) ([]*Mount, error) {
+	var rawMounts []*rawMount
+	if err := unmarshalRawDirective(imageSection, data, &rawMounts); err != nil {
+		return nil, err
+	}
+
+	var mounts []*Mount
+	for _, rawMnt := range rawMounts {
+		// the giterminism manager is not used by the tmpfs mount validation
+		mnt, err := rawMnt.toDirective(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		mounts = append(mounts, mnt)
+	}
+
+	return mounts, nil
+}
+
+var _ = Describe("mount", func() {
+	It("should parse the tmpfs mount with the size limit", func() {
+		mounts, err := parseMounts(`
+- from: tmpfs
+  to: /tmp/build
+  size: 512m
+- from: tmpfs
+  to: /var/cache
+`)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(mounts).Should(HaveLen(2))
+
+		Ω(mounts[0].Type).Should(Equal("tmpfs"))
+		Ω(mounts[0].Size).Should(Equal("512m"))
+		Ω(mounts[1].Size).Should(BeEmpty())
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			_, err := parseMounts(data)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("bad tmpfs size", "- from: tmpfs\n  to: /tmp/build\n  size: 512MB\n", "invalid `size: 512MB` for tmpfs mount"),
+		Entry("size of not tmpfs mount", "- from: tmp_dir\n  to: /tmp/build\n  size: 512m\n", "`size: SIZE` can be used only with `from: tmpfs` mount"),
+		Entry("name of not volume mount", "- from: tmpfs\n  to: /tmp/build\n  name: cache\n", "`name: NAME` can be used only with `from: volume` mount"),
+	)
+})
@@ -0,0 +1,75 @@
+package config
+
+type rawScripts struct {
+	BeforeInstall             interface{} `yaml:"beforeInstall,omitempty"`
+	Install                   interface{} `yaml:"install,omitempty"`
+	BeforeSetup               interface{} `yaml:"beforeSetup,omitempty"`
+	Setup                     interface{} `yaml:"setup,omitempty"`
+	CacheVersion              string      `yaml:"cacheVersion,omitempty"`
+	BeforeInstallCacheVersion string      `yaml:"beforeInstallCacheVersion,omitempty"`
+	InstallCacheVersion       string      `yaml:"installCacheVersion,omitempty"`
+	BeforeSetupCacheVersion   string      `yaml:"beforeSetupCacheVersion,omitempty"`
+	SetupCacheVersion         string      `yaml:"setupCacheVersion,omitempty"`
+
+	rawStapelImage *rawStapelImage `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawScripts) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawStapelImage); ok {
+		c.rawStapelImage = parent
+	}
+
+	type plain rawScripts
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawScripts) toDirective() (scripts *Scripts, err error) {
+	scripts = &Scripts{}
+	scripts.CacheVersion = c.CacheVersion
+	scripts.BeforeInstallCacheVersion = c.BeforeInstallCacheVersion
+	scripts.InstallCacheVersion = c.InstallCacheVersion
+	scripts.BeforeSetupCacheVersion = c.BeforeSetupCacheVersion
+	scripts.SetupCacheVersion = c.SetupCacheVersion
+
+	if beforeInstall, err := InterfaceToStringArray(c.BeforeInstall, c, c.rawStapelImage.doc); err != nil {
+		return nil, err
+	} else {
+		scripts.BeforeInstall = beforeInstall
+	}
+
+	if install, err := InterfaceToStringArray(c.Install, c, c.rawStapelImage.doc); err != nil {
+		return nil, err
+	} else {
+		scripts.Install = install
+	}
+
+	if beforeSetup, err := InterfaceToStringArray(c.BeforeSetup, c, c.rawStapelImage.doc); err != nil {
+		return nil, err
+	} else {
+		scripts.BeforeSetup = beforeSetup
+	}
+
+	if setup, err := InterfaceToStringArray(c.Setup, c, c.rawStapelImage.doc); err != nil {
+		return nil, err
+	} else {
+		scripts.Setup = setup
+	}
+
+	scripts.raw = c
+
+	if err := scripts.validate(); err != nil {
+		return nil, err
+	}
+
+	return scripts, nil
+}
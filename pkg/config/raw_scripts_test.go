@@ -0,0 +1,45 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scripts", func() {
+	It("should parse the single script and the list of scripts", func() {
+		rawScripts := &rawScripts{}
+		Ω(unmarshalRawDirective(imageSection, `
+beforeInstall: scripts/packages.sh
+install:
+- scripts/deps.sh
+- scripts/assets.py
+setupCacheVersion: "2"
+`, rawScripts)).Should(Succeed())
+
+		scripts, err := rawScripts.toDirective()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(scripts.BeforeInstall).Should(Equal([]string{"scripts/packages.sh"}))
+		Ω(scripts.Install).Should(Equal([]string{"scripts/deps.sh", "scripts/assets.py"}))
+		Ω(scripts.BeforeSetup).Should(BeEmpty())
+		Ω(scripts.SetupCacheVersion).Should(Equal("2"))
+		Ω(scripts.Paths()).Should(Equal([]string{"scripts/packages.sh", "scripts/deps.sh", "scripts/assets.py"}))
+	})
+
+	DescribeTable("validation",
+		func(data, expectedErrSubstring string) {
+			rawScripts := &rawScripts{}
+			err := unmarshalRawDirective(imageSection, data, rawScripts)
+			if err == nil {
+				_, err = rawScripts.toDirective()
+			}
+
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("absolute path", "install: /scripts/deps.sh\n", "script path should be relative to project directory"),
+		Entry("path outside the project", "setup: ../deps.sh\n", "script path should be relative to project directory"),
+		Entry("non-string item", "install:\n- deps.sh\n- 1\n", "single string or array of strings expected"),
+		Entry("unsupported attribute", "shell: deps.sh\n", "shell"),
+	)
+})
@@ -17,6 +17,7 @@ type rawStapelImage struct {
 	RawGit           []*rawGit    `yaml:"git,omitempty"`
 	RawShell         *rawShell    `yaml:"shell,omitempty"`
 	RawAnsible       *rawAnsible  `yaml:"ansible,omitempty"`
+	RawScripts       *rawScripts  `yaml:"scripts,omitempty"`
 	RawMount         []*rawMount  `yaml:"mount,omitempty"`
 	RawDocker        *rawDocker   `yaml:"docker,omitempty"`
 	RawImport        []*rawImport `yaml:"import,omitempty"`
@@ -245,6 +246,14 @@ func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager gitermini
 		}
 	}
 
+	if c.RawScripts != nil {
+		if scripts, err := c.RawScripts.toDirective(); err != nil {
+			return nil, err
+		} else {
+			imageBase.Scripts = scripts
+		}
+	}
+
 	for _, importArtifact := range c.RawImport {
 		if importArtifactDirective, err := importArtifact.toDirective(); err != nil {
 			return nil, err
@@ -0,0 +1,39 @@
+package config
+
+// Scripts are the paths to the script files relative to the project directory for each user stage.
+// The scripts are executed in the build container in the specified order, and the interpreter is selected by the shebang line.
+type Scripts struct {
+	BeforeInstall             []string
+	Install                   []string
+	BeforeSetup               []string
+	Setup                     []string
+	CacheVersion              string
+	BeforeInstallCacheVersion string
+	InstallCacheVersion       string
+	BeforeSetupCacheVersion   string
+	SetupCacheVersion         string
+
+	raw *rawScripts
+}
+
+// Paths returns the script paths of all user stages.
+func (c *Scripts) Paths() []string {
+	var paths []string
+	for _, stagePaths := range [][]string{c.BeforeInstall, c.Install, c.BeforeSetup, c.Setup} {
+		paths = append(paths, stagePaths...)
+	}
+
+	return paths
+}
+
+func (c *Scripts) GetDumpConfigSection() string {
+	return dumpConfigDoc(c.raw.rawStapelImage.doc)
+}
+
+func (c *Scripts) validate() error {
+	if !allRelativePaths(c.Paths()) {
+		return newDetailedConfigError("script path should be relative to project directory!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	return nil
+}
@@ -12,8 +12,8 @@ type StapelImage struct {
 }
 
 func (c *StapelImage) validate() error {
-	if !oneOrNone([]bool{c.Shell != nil, c.Ansible != nil}) {
-		return newDetailedConfigError("can not use shell and ansible builders at the same time!", nil, c.StapelImageBase.raw.doc)
+	if !oneOrNone([]bool{c.Shell != nil, c.Ansible != nil, c.Scripts != nil}) {
+		return newDetailedConfigError("can not use shell, ansible and scripts builders at the same time!", nil, c.StapelImageBase.raw.doc)
 	}
 
 	if c.Name == "" {
@@ -9,8 +9,8 @@ func (c *StapelImageArtifact) IsArtifact() bool {
 }
 
 func (c *StapelImageArtifact) validate() error {
-	if !oneOrNone([]bool{c.Shell != nil, c.Ansible != nil}) {
-		return newDetailedConfigError("can not use shell and ansible builders at the same time!", nil, c.StapelImageBase.raw.doc)
+	if !oneOrNone([]bool{c.Shell != nil, c.Ansible != nil, c.Scripts != nil}) {
+		return newDetailedConfigError("can not use shell, ansible and scripts builders at the same time!", nil, c.StapelImageBase.raw.doc)
 	}
 
 	return nil
@@ -19,6 +19,7 @@ type StapelImageBase struct {
 	Git              *GitManager
 	Shell            *Shell
 	Ansible          *Ansible
+	Scripts          *Scripts
 	Mount            []*Mount
 	Import           []*Import
 
@@ -84,6 +85,14 @@ func (c *StapelImageBase) validate(giterminismManager giterminism_manager.Interf
 		}
 	}
 
+	for _, imp := range c.Import {
+		if imp.From != "" && !imp.IsFromPinned() {
+			if err := giterminismManager.Inspector().InspectConfigStapelImportFromWithoutDigest(); err != nil {
+				return newDetailedConfigError(err.Error(), imp.raw, c.raw.doc)
+			}
+		}
+	}
+
 	if c.From == "" && c.raw.FromImage == "" && c.raw.FromArtifact == "" && c.FromImageName == "" && c.FromArtifactName == "" {
 		return newDetailedConfigError("`from: DOCKER_IMAGE`, `fromImage: IMAGE_NAME`, `fromArtifact: IMAGE_ARTIFACT_NAME` required!", nil, c.raw.doc)
 	}
@@ -11,6 +11,13 @@ type WerfConfig struct {
 	StapelImages         []*StapelImage
 	ImagesFromDockerfile []*ImageFromDockerfile
 	Artifacts            []*StapelImageArtifact
+
+	// DigestedEnv contains the values of the environment variables allowed by the giterminism config to take part in the stages digests
+	DigestedEnv map[string]string
+	// imagesDigestedEnv contains the digested env values taking part in the stages digests of the images by the names
+	imagesDigestedEnv map[string]map[string]string
+	// Dependencies are the resolved meta dependencies the config is rendered with by the names
+	Dependencies map[string]DependencyTemplateData
 }
 
 func (c *WerfConfig) HasImageOrArtifact(imageName string) bool {
@@ -51,6 +58,26 @@ func (c *WerfConfig) GetImage(imageName string) ImageInterface {
 	return nil
 }
 
+// GetImageDigestedEnv returns the values of the digested environment variables used in the config section of the image and in the config sections not related to any image.
+func (c *WerfConfig) GetImageDigestedEnv(imageName string) map[string]string {
+	return c.imagesDigestedEnv[imageName]
+}
+
+func (c *WerfConfig) getImagesDocs() map[string]*doc {
+	imagesDocs := map[string]*doc{}
+	for _, image := range c.StapelImages {
+		imagesDocs[image.Name] = image.raw.doc
+	}
+	for _, artifact := range c.Artifacts {
+		imagesDocs[artifact.Name] = artifact.raw.doc
+	}
+	for _, image := range c.ImagesFromDockerfile {
+		imagesDocs[image.Name] = image.raw.doc
+	}
+
+	return imagesDocs
+}
+
 func (c *WerfConfig) GetStapelImage(imageName string) *StapelImage {
 	for _, image := range c.StapelImages {
 		if image.Name == imageName {
@@ -81,6 +108,32 @@ func (c *WerfConfig) GetArtifact(imageName string) *StapelImageArtifact {
 	return nil
 }
 
+// GetRemoteGitMappings returns the remote git mappings of all images and artifacts using the remote repository with the specified name.
+func (c *WerfConfig) GetRemoteGitMappings(name string) []*GitRemote {
+	var imageBaseConfigs []*StapelImageBase
+	for _, image := range c.StapelImages {
+		imageBaseConfigs = append(imageBaseConfigs, image.ImageBaseConfig())
+	}
+	for _, artifact := range c.Artifacts {
+		imageBaseConfigs = append(imageBaseConfigs, artifact.ImageBaseConfig())
+	}
+
+	var res []*GitRemote
+	for _, imageBaseConfig := range imageBaseConfigs {
+		if imageBaseConfig.Git == nil {
+			continue
+		}
+
+		for _, gitRemote := range imageBaseConfig.Git.Remote {
+			if gitRemote.Name == name {
+				res = append(res, gitRemote)
+			}
+		}
+	}
+
+	return res
+}
+
 func (c *WerfConfig) exportsAutoExcluding() error {
 	for _, image := range c.StapelImages {
 		if err := image.exportsAutoExcluding(); err != nil {
@@ -257,6 +310,16 @@ func (c *WerfConfig) validateImageFrom(i *StapelImageBase) error {
 	return nil
 }
 
+func (c *WerfConfig) validateComposeServices() error {
+	for service, imageName := range c.Meta.Compose.Services {
+		if !c.HasImage(imageName) {
+			return newConfigError(fmt.Sprintf("no such image `%s` for compose service `%s`!", imageName, service))
+		}
+	}
+
+	return nil
+}
+
 func (c *WerfConfig) validateInfiniteLoopBetweenRelatedImages() error {
 	var imageAndArtifactNames []string
 
@@ -438,3 +501,20 @@ func (c *WerfConfig) validateImageInfiniteLoop(imageOrArtifactName string, image
 
 	return nil, nil
 }
+
+// ImageDependencies returns the images and artifacts which the image is based on or imports files from.
+func (c *WerfConfig) ImageDependencies(image ImageInterface) []ImageInterface {
+	return c.imageDependencies(image)
+}
+
+// ImageRawDocument returns the rendered werf.yaml document of the image.
+func ImageRawDocument(image ImageInterface) []byte {
+	switch i := image.(type) {
+	case StapelImageInterface:
+		return i.ImageBaseConfig().raw.doc.Content
+	case *ImageFromDockerfile:
+		return i.raw.doc.Content
+	}
+
+	return nil
+}
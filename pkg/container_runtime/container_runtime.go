@@ -31,13 +31,14 @@ func (runtime *LocalDockerServerRuntime) GetImageInspect(ctx context.Context, re
 	return inspect, err
 }
 
-// PullImage only available for LocalDockerServerRuntime
-func (runtime *LocalDockerServerRuntime) PullImage(ctx context.Context, ref string) error {
-	if err := docker.CliPull(ctx, ref); err != nil {
-		return fmt.Errorf("unable to pull image %s: %s", ref, err)
+// PullImage only available for LocalDockerServerRuntime, returns the local reference of the pulled image
+func (runtime *LocalDockerServerRuntime) PullImage(ctx context.Context, ref string) (string, error) {
+	localRef, err := pullImageWithRegistryMirrors(ctx, ref, docker.CliPull)
+	if err != nil {
+		return "", fmt.Errorf("unable to pull image %s: %s", ref, err)
 	}
 
-	return nil
+	return localRef, nil
 }
 
 func (runtime *LocalDockerServerRuntime) RefreshImageObject(ctx context.Context, img Image) error {
@@ -3,12 +3,15 @@ package container_runtime
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/google/uuid"
 
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/remote_builder"
+	"github.com/werf/werf/pkg/werf"
 )
 
 type DockerfileImageBuilder struct {
@@ -16,6 +19,18 @@ type DockerfileImageBuilder struct {
 	isBuilt         bool
 	buildArgs       []string
 	filePathToStdin string
+	secrets         []*dockerfileSecret
+
+	remoteRepo           string
+	remoteStageImageName string
+	remoteStageUniqueID  int64
+	remoteBuiltReference string
+}
+
+type dockerfileSecret struct {
+	id   string
+	src  string
+	data []byte
 }
 
 func NewDockerfileImageBuilder() *DockerfileImageBuilder {
@@ -26,9 +41,36 @@ func (b *DockerfileImageBuilder) GetBuiltId() string {
 	if !b.isBuilt {
 		return ""
 	}
+	if b.remoteBuiltReference != "" {
+		return b.remoteBuiltReference
+	}
 	return b.temporalId
 }
 
+// SetRemoteRepo sets the repo, which the remote builder pushes the built image into (see remote_builder.Build).
+func (b *DockerfileImageBuilder) SetRemoteRepo(repo string) {
+	b.remoteRepo = repo
+}
+
+// SetRemoteStageImage sets the stage image name, which the remote builder pushes the built image as, if the builder cannot push by digest (see remote_builder.IsPushedByTag).
+func (b *DockerfileImageBuilder) SetRemoteStageImage(name string, uniqueID int64) {
+	b.remoteStageImageName = name
+	b.remoteStageUniqueID = uniqueID
+}
+
+// GetRemoteStageImage returns the stage image name and the unique id of the image pushed by the remote builder by tag (empty if the image is pushed by digest).
+func (b *DockerfileImageBuilder) GetRemoteStageImage() (string, int64) {
+	if !b.IsBuiltRemotely() {
+		return "", 0
+	}
+	return b.remoteStageImageName, b.remoteStageUniqueID
+}
+
+// IsBuiltRemotely returns true if the image has been built by the remote builder and the built id is the REPO@DIGEST reference of the pushed image.
+func (b *DockerfileImageBuilder) IsBuiltRemotely() bool {
+	return b.isBuilt && b.remoteBuiltReference != ""
+}
+
 func (b *DockerfileImageBuilder) AppendBuildArgs(buildArgs ...string) {
 	b.buildArgs = append(b.buildArgs, buildArgs...)
 }
@@ -37,8 +79,38 @@ func (b *DockerfileImageBuilder) SetFilePathToStdin(path string) {
 	b.filePathToStdin = path
 }
 
+// AppendSecretSource adds the secret for the RUN --mount=type=secret,id=ID instructions from the file (see docker build --secret option).
+func (b *DockerfileImageBuilder) AppendSecretSource(id, src string) {
+	b.secrets = append(b.secrets, &dockerfileSecret{id: id, src: src})
+}
+
+// AppendSecretData adds the secret for the RUN --mount=type=secret,id=ID instructions with the specified data.
+// The data is passed to the build through the tmp file, which is removed right after the build.
+func (b *DockerfileImageBuilder) AppendSecretData(id string, data []byte) {
+	b.secrets = append(b.secrets, &dockerfileSecret{id: id, data: data})
+}
+
 func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
-	buildArgs := append(b.buildArgs, fmt.Sprintf("--tag=%s", b.temporalId))
+	var buildArgs []string
+	if remote_builder.IsEnabled() {
+		buildArgs = append(buildArgs, b.buildArgs...)
+	} else {
+		buildArgs = append(b.buildArgs, fmt.Sprintf("--tag=%s", b.temporalId))
+	}
+
+	if len(b.secrets) != 0 {
+		secretsBuildArgs, cleanupFunc, err := b.prepareSecretsBuildArgs()
+		defer cleanupFunc()
+		if err != nil {
+			return err
+		}
+
+		buildArgs = append(buildArgs, secretsBuildArgs...)
+	}
+
+	if remote_builder.IsEnabled() {
+		return b.buildRemotely(ctx, buildArgs)
+	}
 
 	if b.filePathToStdin != "" {
 		buildArgs = append(buildArgs, "-")
@@ -71,9 +143,73 @@ func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
 	return nil
 }
 
+func (b *DockerfileImageBuilder) buildRemotely(ctx context.Context, buildArgs []string) error {
+	if b.remoteRepo == "" {
+		return fmt.Errorf("remote builder requires the container registry repo to store the built images: use --repo option")
+	}
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Remote build command:\ndocker build %s < %s\n", strings.Join(buildArgs, " "), b.filePathToStdin)
+	}
+
+	reference, err := remote_builder.Build(ctx, b.filePathToStdin, buildArgs, b.remoteRepo, b.remoteStageImageName)
+	if err != nil {
+		return err
+	}
+
+	b.remoteBuiltReference = reference
+	b.isBuilt = true
+
+	return nil
+}
+
 func (b *DockerfileImageBuilder) Cleanup(ctx context.Context) error {
+	// the remotely built image is not stored locally and the untagged manifest in the repo is removed by the registry garbage collection
+	if remote_builder.IsEnabled() {
+		return nil
+	}
+
 	if err := docker.CliRmi(ctx, b.temporalId, "--force"); err != nil {
 		return fmt.Errorf("unable to remove temporal dockerfile image %q: %s", b.temporalId, err)
 	}
 	return nil
 }
+
+func (b *DockerfileImageBuilder) prepareSecretsBuildArgs() ([]string, func(), error) {
+	var tmpFiles []string
+	cleanupFunc := func() {
+		for _, path := range tmpFiles {
+			os.Remove(path)
+		}
+	}
+
+	if !docker.IsBuildKitEnabled() && !remote_builder.IsEnabled() {
+		return nil, cleanupFunc, fmt.Errorf("dockerfile secrets require BuildKit: use --dockerfile-builder=buildkit option or set DOCKER_BUILDKIT=1 environment variable")
+	}
+
+	var buildArgs []string
+	for _, secret := range b.secrets {
+		src := secret.src
+		if src == "" {
+			f, err := ioutil.TempFile(werf.GetTmpDir(), "werf-dockerfile-secret-")
+			if err != nil {
+				return nil, cleanupFunc, fmt.Errorf("unable to create tmp file for secret %q: %s", secret.id, err)
+			}
+			tmpFiles = append(tmpFiles, f.Name())
+
+			_, err = f.Write(secret.data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, cleanupFunc, fmt.Errorf("unable to write secret %q into tmp file: %s", secret.id, err)
+			}
+
+			src = f.Name()
+		}
+
+		buildArgs = append(buildArgs, fmt.Sprintf("--secret=id=%s,src=%s", secret.id, src))
+	}
+
+	return buildArgs, cleanupFunc, nil
+}
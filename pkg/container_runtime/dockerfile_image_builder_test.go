@@ -0,0 +1,77 @@
+package container_runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+func setDockerBuildKitEnv(t *testing.T, value string) {
+	oldValue, isSet := os.LookupEnv("DOCKER_BUILDKIT")
+	if err := os.Setenv("DOCKER_BUILDKIT", value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if isSet {
+			_ = os.Setenv("DOCKER_BUILDKIT", oldValue)
+		} else {
+			_ = os.Unsetenv("DOCKER_BUILDKIT")
+		}
+	})
+}
+
+func TestDockerfileImageBuilder_PrepareSecretsBuildArgs(t *testing.T) {
+	setDockerBuildKitEnv(t, "1")
+
+	dir := t.TempDir()
+	if err := werf.Init(dir, filepath.Join(dir, "home"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewDockerfileImageBuilder()
+	b.AppendSecretSource("npmrc", "/home/user/.npmrc")
+	b.AppendSecretData("token", []byte("secret-token"))
+
+	buildArgs, cleanupFunc, err := b.prepareSecretsBuildArgs()
+	if err != nil {
+		cleanupFunc()
+		t.Fatal(err)
+	}
+
+	if len(buildArgs) != 2 || buildArgs[0] != "--secret=id=npmrc,src=/home/user/.npmrc" || !strings.HasPrefix(buildArgs[1], "--secret=id=token,src=") {
+		cleanupFunc()
+		t.Fatalf("unexpected secrets build args %q", buildArgs)
+	}
+
+	tmpFile := strings.TrimPrefix(buildArgs[1], "--secret=id=token,src=")
+	if filepath.Dir(tmpFile) != werf.GetTmpDir() {
+		t.Errorf("expected the secret data file in the werf tmp dir, got %s", tmpFile)
+	}
+	if data, err := ioutil.ReadFile(tmpFile); err != nil {
+		t.Errorf("unable to read the secret data file: %s", err)
+	} else if string(data) != "secret-token" {
+		t.Errorf("expected the secret data in the file, got %q", data)
+	}
+
+	cleanupFunc()
+	if _, err := os.Stat(tmpFile); !os.IsNotExist(err) {
+		t.Errorf("expected the secret data file to be removed by the cleanup, got %v", err)
+	}
+}
+
+func TestDockerfileImageBuilder_PrepareSecretsBuildArgs_BuildKitRequired(t *testing.T) {
+	setDockerBuildKitEnv(t, "0")
+
+	b := NewDockerfileImageBuilder()
+	b.AppendSecretSource("npmrc", "/home/user/.npmrc")
+
+	_, cleanupFunc, err := b.prepareSecretsBuildArgs()
+	defer cleanupFunc()
+	if err == nil || !strings.Contains(err.Error(), "dockerfile secrets require BuildKit") {
+		t.Errorf("expected the BuildKit required error, got %v", err)
+	}
+}
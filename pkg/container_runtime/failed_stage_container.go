@@ -0,0 +1,109 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/stapel"
+)
+
+var failedStageContainerNameInvalidCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// FailedStageContainerName returns the name of the kept failed stage container, which is unique for the project image stage.
+func FailedStageContainerName(projectName, imageName, stageName string) string {
+	if imageName == "" {
+		imageName = "__nameless__"
+	}
+
+	imageName = failedStageContainerNameInvalidCharsRegexp.ReplaceAllString(imageName, "_")
+
+	return fmt.Sprintf("%s%s.%s.%s", image.FailedStageContainerNamePrefix, projectName, imageName, stageName)
+}
+
+// ShellFailedStageContainer runs the interactive shell in the state of the kept failed stage container.
+// The container is committed into the temporary image, which is removed after the shell exit.
+func ShellFailedStageContainer(ctx context.Context, containerName, shell string) error {
+	if exists, err := docker.ContainerExist(ctx, containerName); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("failed stage container %s not found: the stage should be built with --keep-failed-stage-container option", containerName)
+	}
+
+	inspect, err := docker.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("unable to inspect container %s: %s", containerName, err)
+	}
+
+	stapelContainerName, err := stapel.GetOrCreateContainer(ctx)
+	if err != nil {
+		return err
+	}
+
+	imageID, err := docker.ContainerCommit(ctx, containerName, types.ContainerCommitOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to commit container %s: %s", containerName, err)
+	}
+	defer func() {
+		if err := docker.CliRmi(ctx, "--force", imageID); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: unable to remove temporary image %s: %s\n", imageID, err)
+		}
+	}()
+
+	shellArgs, err := introspectShellArgs(shell)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-ti", "--rm", fmt.Sprintf("--entrypoint=%s", shellArgs[0]), fmt.Sprintf("--volumes-from=%s", stapelContainerName)}
+
+	if inspect.Config != nil {
+		if inspect.Config.User != "" {
+			args = append(args, fmt.Sprintf("--user=%s", inspect.Config.User))
+		}
+
+		if inspect.Config.WorkingDir != "" {
+			args = append(args, fmt.Sprintf("--workdir=%s", inspect.Config.WorkingDir))
+		}
+	}
+
+	if inspect.HostConfig != nil {
+		for _, bind := range inspect.HostConfig.Binds {
+			// the temporary build directories could be removed after the build
+			hostPath := strings.SplitN(bind, ":", 2)[0]
+			if !filepath.IsAbs(hostPath) {
+				args = append(args, fmt.Sprintf("--volume=%s", bind))
+				continue
+			}
+
+			if _, err := os.Stat(hostPath); err != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: skipping volume %s: %s\n", bind, err)
+				continue
+			}
+
+			args = append(args, fmt.Sprintf("--volume=%s", bind))
+		}
+	}
+
+	args = append(args, imageID)
+	args = append(args, shellArgs[1:]...)
+
+	if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
+		return docker.CliRun_LiveOutput(ctx, args...)
+	}); err != nil {
+		if !strings.Contains(err.Error(), "Code: ") || IsStartContainerErr(err) {
+			return err
+		}
+	}
+
+	return nil
+}
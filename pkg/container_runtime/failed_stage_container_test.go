@@ -0,0 +1,48 @@
+package container_runtime
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/stapel"
+)
+
+func TestFailedStageContainerName(t *testing.T) {
+	for _, tc := range []struct {
+		imageName string
+		expected  string
+	}{
+		{"app", "werf.failed-stage.project.app.install"},
+		{"", "werf.failed-stage.project.__nameless__.install"},
+		{"backend/api", "werf.failed-stage.project.backend_api.install"},
+		{"my app:v1", "werf.failed-stage.project.my_app_v1.install"},
+		{"a_b-c.d", "werf.failed-stage.project.a_b-c.d.install"},
+	} {
+		if name := FailedStageContainerName("project", tc.imageName, "install"); name != tc.expected {
+			t.Errorf("expected %q for the image %q, got %q", tc.expected, tc.imageName, name)
+		}
+	}
+}
+
+func TestIntrospectShellArgs(t *testing.T) {
+	for shell, expected := range map[string][]string{
+		"":                           {stapel.BashBinPath()},
+		"  ":                         {stapel.BashBinPath()},
+		"/bin/sh":                    {"/bin/sh"},
+		"/bin/sh -l":                 {"/bin/sh", "-l"},
+		`/bin/sh -c "cd /app && sh"`: {"/bin/sh", "-c", "cd /app && sh"},
+	} {
+		args, err := introspectShellArgs(shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("expected %q for the shell %q, got %q", expected, shell, args)
+		}
+	}
+
+	if _, err := introspectShellArgs(`/bin/sh -c "unterminated`); err == nil {
+		t.Errorf("expected the error for the unterminated quote")
+	}
+}
@@ -11,6 +11,12 @@ import (
 type BuildOptions struct {
 	IntrospectBeforeError bool
 	IntrospectAfterError  bool
+	// IntrospectShell is the shell command run in the introspected container, the stapel bash by default
+	IntrospectShell string
+
+	KeepFailedStageContainer bool
+	// FailedStageContainerName is set for each stage when KeepFailedStageContainer is enabled, the failed stage container is renamed instead of removal
+	FailedStageContainerName string
 }
 
 type ImageInterface interface {
@@ -30,7 +36,7 @@ type ImageInterface interface {
 	GetBuiltId() string
 	TagBuiltImage(ctx context.Context) error
 
-	Introspect(ctx context.Context) error
+	Introspect(ctx context.Context, shell string) error
 
 	SetInspect(inspect *types.ImageInspect)
 	IsExistsLocally() bool
@@ -67,6 +73,7 @@ type BuilderContainer interface {
 type ContainerOptions interface {
 	AddVolume(volumes ...string)
 	AddVolumeFrom(volumesFrom ...string)
+	AddTmpfs(tmpfs ...string)
 	AddExpose(exposes ...string)
 	AddEnv(envs map[string]string)
 	AddLabel(labels map[string]string)
@@ -0,0 +1,67 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+// pullImageWithRegistryMirrors tries to pull the image from the registry mirrors configured for its registry before pulling it by the specified func,
+// and returns the local reference of the pulled image.
+// The image pulled from the mirror by tag is tagged by the original reference.
+// The local image cannot be tagged by the digest reference, so the image pulled from the mirror by digest is verified and available by the mirror reference.
+func pullImageWithRegistryMirrors(ctx context.Context, ref string, pull func(ctx context.Context, args ...string) error) (string, error) {
+	isDigestReference := strings.Contains(ref, "@")
+
+	mirrorReferences, err := docker_registry.GetRegistryMirrorReferences(ref)
+	if err != nil {
+		return "", err
+	}
+
+	for _, mirrorReference := range mirrorReferences {
+		if err := docker.CliPullWithRetries(ctx, mirrorReference.Reference); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to pull %s from the registry mirror: %s\n", mirrorReference.Reference, err)
+			continue
+		}
+
+		if isDigestReference {
+			if err := verifyRegistryMirrorDigestImage(ctx, mirrorReference.Reference); err != nil {
+				return "", err
+			}
+
+			return mirrorReference.Reference, nil
+		}
+
+		if err := docker.CliTag(ctx, mirrorReference.Reference, ref); err != nil {
+			return "", fmt.Errorf("unable to tag image %s by name %s: %s", mirrorReference.Reference, ref, err)
+		}
+
+		if err := docker.CliRmi(ctx, mirrorReference.Reference); err != nil {
+			return "", fmt.Errorf("unable to remove image tag %s: %s", mirrorReference.Reference, err)
+		}
+
+		return ref, nil
+	}
+
+	return ref, pull(ctx, ref)
+}
+
+func verifyRegistryMirrorDigestImage(ctx context.Context, mirrorReference string) error {
+	inspect, err := docker.ImageInspect(ctx, mirrorReference)
+	if err != nil {
+		return fmt.Errorf("unable to inspect image %s pulled from the registry mirror: %s", mirrorReference, err)
+	}
+
+	if isMatched, err := docker_registry.IsRepoDigestMatched(inspect.RepoDigests, mirrorReference); err != nil {
+		return err
+	} else if !isMatched {
+		return fmt.Errorf("image pulled from the registry mirror does not match the digest %s: the image repo digests are %v", mirrorReference, inspect.RepoDigests)
+	}
+
+	return nil
+}
@@ -58,12 +58,22 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 		if err := i.dockerfileImageBuilder.Build(ctx); err != nil {
 			return err
 		}
+
+		if i.dockerfileImageBuilder.IsBuiltRemotely() {
+			// the image is not available locally, the stage description is received from the repo when the stage is stored
+			i.SetStageDescription(&image.StageDescription{
+				StageID: nil,
+				Info:    &image.Info{Name: i.Name()},
+			})
+
+			return nil
+		}
 	} else {
 		containerLockName := ContainerLockName(i.container.Name())
-		if _, lock, err := werf.AcquireHostLock(ctx, containerLockName, lockgate.AcquireOptions{}); err != nil {
+		if _, lock, err := werf.AcquireGlobalHostLock(ctx, containerLockName, lockgate.AcquireOptions{}); err != nil {
 			return fmt.Errorf("failed to lock %s: %s", containerLockName, err)
 		} else {
-			defer werf.ReleaseHostLock(lock)
+			defer werf.ReleaseGlobalHostLock(lock)
 		}
 
 		if debugDockerRunCommand() {
@@ -85,7 +95,7 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 					logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
 
 					if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-						return i.introspectBefore(ctx)
+						return i.introspectBefore(ctx, options.IntrospectShell)
 					}); err != nil {
 						return fmt.Errorf("introspect error failed: %s", err)
 					}
@@ -97,13 +107,17 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 					logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
 
 					if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-						return i.Introspect(ctx)
+						return i.Introspect(ctx, options.IntrospectShell)
 					}); err != nil {
 						return fmt.Errorf("introspect error failed: %s", err)
 					}
 				}
 
-				if err := i.container.rm(ctx); err != nil {
+				if options.FailedStageContainerName != "" {
+					if err := i.container.keep(ctx, options.FailedStageContainerName); err != nil {
+						return fmt.Errorf("unable to keep failed stage container: %s", err)
+					}
+				} else if err := i.container.rm(ctx); err != nil {
 					return fmt.Errorf("introspect error failed: %s", err)
 				}
 			}
@@ -144,16 +158,16 @@ func (i *StageImage) Commit(ctx context.Context) error {
 	return nil
 }
 
-func (i *StageImage) Introspect(ctx context.Context) error {
-	if err := i.container.introspect(ctx); err != nil {
+func (i *StageImage) Introspect(ctx context.Context, shell string) error {
+	if err := i.container.introspect(ctx, shell); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (i *StageImage) introspectBefore(ctx context.Context) error {
-	if err := i.container.introspectBefore(ctx); err != nil {
+func (i *StageImage) introspectBefore(ctx context.Context, shell string) error {
+	if err := i.container.introspectBefore(ctx, shell); err != nil {
 		return err
 	}
 
@@ -203,10 +217,14 @@ func (i *StageImage) Tag(ctx context.Context, name string) error {
 }
 
 func (i *StageImage) Pull(ctx context.Context) error {
-	if err := docker.CliPullWithRetries(ctx, i.name); err != nil {
+	localRef, err := pullImageWithRegistryMirrors(ctx, i.name, docker.CliPullWithRetries)
+	if err != nil {
 		return err
 	}
 
+	// the image pulled from the registry mirror by digest is available only by the mirror reference
+	i.SetName(localRef)
+
 	i.baseImage.UnsetInspect()
 
 	return nil
@@ -9,6 +9,7 @@ import (
 	"github.com/werf/werf/pkg/image"
 
 	"github.com/docker/docker/api/types"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
 
 	"github.com/werf/logboek"
 	"github.com/werf/werf/pkg/docker"
@@ -125,43 +126,27 @@ func ShelloutPack(command string) string {
 	return fmt.Sprintf("eval $(echo %s | %s --decode)", base64.StdEncoding.EncodeToString([]byte(command)), stapel.Base64BinPath())
 }
 
-func (c *StageImageContainer) prepareIntrospectBeforeArgs(ctx context.Context) ([]string, error) {
-	args, err := c.prepareIntrospectArgsBase(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	fromImageId := c.image.fromImage.GetID()
-
-	args = append(args, fromImageId)
-	args = append(args, "-ec")
-	args = append(args, stapel.BashBinPath())
+func (c *StageImageContainer) prepareIntrospectBeforeArgs(ctx context.Context, shell string) ([]string, error) {
+	return c.prepareIntrospectArgsBase(ctx, c.image.fromImage.GetID(), shell)
+}
 
-	return args, nil
+func (c *StageImageContainer) prepareIntrospectArgs(ctx context.Context, shell string) ([]string, error) {
+	return c.prepareIntrospectArgsBase(ctx, c.image.GetID(), shell)
 }
 
-func (c *StageImageContainer) prepareIntrospectArgs(ctx context.Context) ([]string, error) {
-	args, err := c.prepareIntrospectArgsBase(ctx)
+func (c *StageImageContainer) prepareIntrospectArgsBase(ctx context.Context, imageId, shell string) ([]string, error) {
+	var args []string
+
+	shellArgs, err := introspectShellArgs(shell)
 	if err != nil {
 		return nil, err
 	}
 
-	imageId := c.image.GetID()
-
-	args = append(args, imageId)
-	args = append(args, "-ec")
-	args = append(args, stapel.BashBinPath())
-
-	return args, nil
-}
-
-func (c *StageImageContainer) prepareIntrospectArgsBase(ctx context.Context) ([]string, error) {
-	var args []string
-
 	runOptions, err := c.prepareIntrospectOptions(ctx)
 	if err != nil {
 		return nil, err
 	}
+	runOptions.Entrypoint = shellArgs[0]
 
 	runArgs, err := runOptions.toRunArgs()
 	if err != nil {
@@ -170,6 +155,8 @@ func (c *StageImageContainer) prepareIntrospectArgsBase(ctx context.Context) ([]
 
 	args = append(args, []string{"-ti", "--rm"}...)
 	args = append(args, runArgs...)
+	args = append(args, imageId)
+	args = append(args, shellArgs[1:]...)
 
 	return args, nil
 }
@@ -267,8 +254,8 @@ func (c *StageImageContainer) run(ctx context.Context) error {
 	return nil
 }
 
-func (c *StageImageContainer) introspect(ctx context.Context) error {
-	runArgs, err := c.prepareIntrospectArgs(ctx)
+func (c *StageImageContainer) introspect(ctx context.Context, shell string) error {
+	runArgs, err := c.prepareIntrospectArgs(ctx, shell)
 	if err != nil {
 		return err
 	}
@@ -282,8 +269,8 @@ func (c *StageImageContainer) introspect(ctx context.Context) error {
 	return nil
 }
 
-func (c *StageImageContainer) introspectBefore(ctx context.Context) error {
-	runArgs, err := c.prepareIntrospectBeforeArgs(ctx)
+func (c *StageImageContainer) introspectBefore(ctx context.Context, shell string) error {
+	runArgs, err := c.prepareIntrospectBeforeArgs(ctx, shell)
 	if err != nil {
 		return err
 	}
@@ -308,6 +295,25 @@ func IsStartContainerErr(err error) bool {
 	return false
 }
 
+// introspectShellArgs splits the shell command run in the introspected container into the entrypoint and its arguments,
+// the stapel bash is used if the shell is not specified.
+func introspectShellArgs(shellCommand string) ([]string, error) {
+	if strings.TrimSpace(shellCommand) == "" {
+		return []string{stapel.BashBinPath()}, nil
+	}
+
+	shellArgs, err := shell.NewLex('\\').ProcessWords(shellCommand, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse introspect shell %q: %s", shellCommand, err)
+	}
+
+	if len(shellArgs) == 0 {
+		return []string{stapel.BashBinPath()}, nil
+	}
+
+	return shellArgs, nil
+}
+
 func (c *StageImageContainer) commit(ctx context.Context) (string, error) {
 	commitChanges, err := c.prepareCommitChanges(ctx)
 	if err != nil {
@@ -326,3 +332,20 @@ func (c *StageImageContainer) commit(ctx context.Context) (string, error) {
 func (c *StageImageContainer) rm(ctx context.Context) error {
 	return docker.ContainerRemove(ctx, c.name, types.ContainerRemoveOptions{})
 }
+
+// keep renames the failed container to the specified name replacing the previously kept container of the stage.
+func (c *StageImageContainer) keep(ctx context.Context, name string) error {
+	if exists, err := docker.ContainerExist(ctx, name); err != nil {
+		return err
+	} else if exists {
+		if err := docker.ContainerRemove(ctx, name, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("unable to remove previously kept container %s: %s", name, err)
+		}
+	}
+
+	if err := docker.ContainerRename(ctx, c.name, name); err != nil {
+		return fmt.Errorf("unable to rename container %s to %s: %s", c.name, name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package context_manager
+
+import "sync"
+
+// Cache shares the prepared context archives and the contextAddFiles checksums between the Dockerfile images
+// with the same context, contextAddFiles and .dockerignore within a single conveyor run.
+// The cache must not outlive the conveyor, because contextAddFiles are read from the project directory, which may change between runs.
+type Cache struct {
+	mux     sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once  sync.Once
+	value string
+	err   error
+}
+
+func NewCache() *Cache {
+	return &Cache{entries: map[string]*cacheEntry{}}
+}
+
+// GetOrCreate returns the value by the key and whether it has been created earlier.
+// The value is created once by the first caller, concurrent callers with the same key wait for it.
+// Nil cache creates the value on every call.
+func (c *Cache) GetOrCreate(key string, createFunc func() (string, error)) (string, bool, error) {
+	if c == nil {
+		value, err := createFunc()
+		return value, false, err
+	}
+
+	c.mux.Lock()
+	entry, exists := c.entries[key]
+	if !exists {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mux.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = createFunc()
+	})
+
+	return entry.value, exists, entry.err
+}
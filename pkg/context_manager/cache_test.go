@@ -0,0 +1,107 @@
+package context_manager
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache_GetOrCreate(t *testing.T) {
+	cache := NewCache()
+
+	var creations int32
+	createFunc := func(value string) func() (string, error) {
+		return func() (string, error) {
+			atomic.AddInt32(&creations, 1)
+			return value, nil
+		}
+	}
+
+	value, reused, err := cache.GetOrCreate("key-1", createFunc("archive-1"))
+	if err != nil || value != "archive-1" || reused {
+		t.Fatalf("expected the created value archive-1, got %q reused=%v err=%v", value, reused, err)
+	}
+
+	value, reused, err = cache.GetOrCreate("key-1", createFunc("archive-2"))
+	if err != nil || value != "archive-1" || !reused {
+		t.Fatalf("expected the reused value archive-1, got %q reused=%v err=%v", value, reused, err)
+	}
+
+	value, reused, err = cache.GetOrCreate("key-2", createFunc("archive-2"))
+	if err != nil || value != "archive-2" || reused {
+		t.Fatalf("expected the created value archive-2 by the other key, got %q reused=%v err=%v", value, reused, err)
+	}
+
+	if creations != 2 {
+		t.Errorf("expected the value to be created once per key, got %d creations", creations)
+	}
+}
+
+func TestCache_GetOrCreate_Concurrent(t *testing.T) {
+	cache := NewCache()
+
+	var creations int32
+	var wg sync.WaitGroup
+	values := make([]string, 20)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			value, _, err := cache.GetOrCreate(fmt.Sprintf("key-%d", i%2), func() (string, error) {
+				atomic.AddInt32(&creations, 1)
+				return fmt.Sprintf("archive-%d", i%2), nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			values[i] = value
+		}(i)
+	}
+	wg.Wait()
+
+	if creations != 2 {
+		t.Errorf("expected the value to be created once per key by the concurrent callers, got %d creations", creations)
+	}
+	for i, value := range values {
+		if expected := fmt.Sprintf("archive-%d", i%2); value != expected {
+			t.Errorf("caller %d: expected %q, got %q", i, expected, value)
+		}
+	}
+}
+
+func TestCache_GetOrCreate_Error(t *testing.T) {
+	cache := NewCache()
+
+	createErr := errors.New("unable to create archive")
+	var creations int
+	for i := 0; i < 2; i++ {
+		if _, _, err := cache.GetOrCreate("key", func() (string, error) {
+			creations++
+			return "", createErr
+		}); err != createErr {
+			t.Errorf("expected the creation error, got %v", err)
+		}
+	}
+
+	if creations != 1 {
+		t.Errorf("expected the failed creation not to be retried within the run, got %d creations", creations)
+	}
+}
+
+func TestCache_GetOrCreate_Nil(t *testing.T) {
+	var cache *Cache
+
+	var creations int
+	for i := 0; i < 2; i++ {
+		value, reused, err := cache.GetOrCreate("key", func() (string, error) {
+			creations++
+			return fmt.Sprintf("archive-%d", creations), nil
+		})
+		if err != nil || reused || value != fmt.Sprintf("archive-%d", i+1) {
+			t.Errorf("expected the created value without the cache, got %q reused=%v err=%v", value, reused, err)
+		}
+	}
+}
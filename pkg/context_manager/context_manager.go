@@ -2,22 +2,32 @@ package context_manager
 
 import (
 	"archive/tar"
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	uuid "github.com/satori/go.uuid"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/tmp_manager"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
 
+// tarBlockSize is the size of the tar header and the max size of the tar entry padding.
+const tarBlockSize = 512
+
+// binaryDataDetectionSize is the size of the file beginning checked for NUL byte to detect the binary file.
+const binaryDataDetectionSize = 8000
+
 func GetTmpDir() string {
 	return filepath.Join(werf.GetServiceDir(), "tmp", "context")
 }
@@ -57,7 +67,10 @@ func GetContextAddFilesPaths(projectDir string, contextDir string, contextAddFil
 	return util.UniqStrings(addFilePaths), nil
 }
 
-func ContextAddFilesChecksum(ctx context.Context, projectDir string, contextDir string, contextAddFiles []string, matcher path_matcher.PathMatcher) (string, error) {
+// ContextAddFilesChecksum calculates the checksum of the contextAddFiles matched by the matcher.
+// The platform independent checksum is the same for the same files on all OSes: the files are ordered by the slash separated paths,
+// the text files are read with LF line endings, the symlinks are taken into account by the slash separated targets and the file modes are ignored.
+func ContextAddFilesChecksum(ctx context.Context, projectDir string, contextDir string, contextAddFiles []string, matcher path_matcher.PathMatcher, platformIndependent bool) (string, error) {
 	addFilePaths, err := GetContextAddFilesPaths(projectDir, contextDir, contextAddFiles)
 	if err != nil {
 		return "", err
@@ -79,12 +92,29 @@ func ContextAddFilesChecksum(ctx context.Context, projectDir string, contextDir
 		return "", nil
 	}
 
+	if platformIndependent {
+		// the walk order depends on the path separator
+		sort.Slice(projectRelativeAddFilePaths, func(i, j int) bool {
+			return filepath.ToSlash(projectRelativeAddFilePaths[i]) < filepath.ToSlash(projectRelativeAddFilePaths[j])
+		})
+	}
+
 	h := sha256.New()
 	for _, projectRelativeAddFilePath := range projectRelativeAddFilePaths {
 		projectRelativeAddFilePath = filepath.ToSlash(projectRelativeAddFilePath)
 		h.Write([]byte(projectRelativeAddFilePath))
 
 		addFilePath := filepath.Join(projectDir, projectRelativeAddFilePath)
+
+		if platformIndependent {
+			if err := writePlatformIndependentFileData(h, addFilePath); err != nil {
+				return "", err
+			}
+
+			logboek.Context(ctx).Debug().LogF("File was added: %q\n", projectRelativeAddFilePath)
+			continue
+		}
+
 		if exists, err := util.RegularFileExists(addFilePath); err != nil {
 			return "", fmt.Errorf("unable to check existence of file %q: %s", addFilePath, err)
 		} else if !exists {
@@ -113,23 +143,162 @@ func ContextAddFilesChecksum(ctx context.Context, projectDir string, contextDir
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func AddContextAddFilesToContextArchive(ctx context.Context, originalArchivePath string, projectDir string, contextDir string, contextAddFiles []string) (string, error) {
-	destinationArchivePath := GetTmpArchivePath()
+func writePlatformIndependentFileData(w io.Writer, path string) error {
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("unable to get file info for %q: %s", path, err)
+	}
 
-	pathsToExcludeFromSourceArchive := contextAddFiles
-	if err := util.CreateArchiveBasedOnAnotherOne(ctx, originalArchivePath, destinationArchivePath, pathsToExcludeFromSourceArchive, func(tw *tar.Writer) error {
-		addFilePathsToCopy, err := GetContextAddFilesPaths(projectDir, contextDir, contextAddFiles)
+	switch {
+	case fileInfo.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("unable to read symlink %q: %s", path, err)
+		}
+
+		w.Write([]byte("symlink:" + filepath.ToSlash(target)))
+	case fileInfo.Mode().IsRegular():
+		data, err := readFileWithLFLineEndings(path)
 		if err != nil {
 			return err
 		}
 
+		w.Write(data)
+	}
+
+	return nil
+}
+
+// readFileWithLFLineEndings reads the text file with the CRLF line endings replaced by LF and the binary file as is.
+func readFileWithLFLineEndings(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %q: %s", path, err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	r := bufio.NewReaderSize(f, binaryDataDetectionSize)
+	if isBinary, err := isBinaryData(r); err != nil {
+		return nil, fmt.Errorf("unable to read %q: %s", path, err)
+	} else if isBinary {
+		_, err = io.Copy(&buf, r)
+	} else {
+		err = copyWithLFLineEndings(&buf, r)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %q: %s", path, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// copyPlatformIndependentFileIntoTar adds the text file with LF line endings to the archive,
+// so that the build context matches the platform independent checksum. The other files are added as is.
+func copyPlatformIndependentFileIntoTar(tw *tar.Writer, tarEntryName string, path string) error {
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("unable to get file info for %q: %s", path, err)
+	}
+
+	if !fileInfo.Mode().IsRegular() {
+		return util.CopyFileIntoTar(tw, tarEntryName, path)
+	}
+
+	data, err := readFileWithLFLineEndings(path)
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:       tarEntryName,
+		Mode:       int64(fileInfo.Mode()),
+		Size:       int64(len(data)),
+		ModTime:    fileInfo.ModTime(),
+		AccessTime: fileInfo.ModTime(),
+		ChangeTime: fileInfo.ModTime(),
+	}); err != nil {
+		return fmt.Errorf("unable to write tar header for file %s: %s", tarEntryName, err)
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("unable to write file %s into tar: %s", tarEntryName, err)
+	}
+
+	return nil
+}
+
+// isBinaryData detects binary data the same way as git does: the data is binary if there is NUL byte within the first 8000 bytes.
+func isBinaryData(r *bufio.Reader) (bool, error) {
+	data, err := r.Peek(binaryDataDetectionSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return false, err
+	}
+
+	return bytes.IndexByte(data, 0) != -1, nil
+}
+
+func copyWithLFLineEndings(w io.Writer, r *bufio.Reader) error {
+	bw := bufio.NewWriter(w)
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if b == '\r' {
+			if next, err := r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+
+		if err := bw.WriteByte(b); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// AddContextAddFilesToContextArchive creates a copy of the context archive with the contextAddFiles,
+// the text files are added with LF line endings when the platform independent checksum is used.
+func AddContextAddFilesToContextArchive(ctx context.Context, originalArchivePath string, projectDir string, contextDir string, contextAddFiles []string, platformIndependent bool) (string, error) {
+	destinationArchivePath := GetTmpArchivePath()
+
+	addFilePathsToCopy, err := GetContextAddFilesPaths(projectDir, contextDir, contextAddFiles)
+	if err != nil {
+		return "", err
+	}
+
+	projectedArchiveSize, err := getProjectedArchiveSize(originalArchivePath, addFilePathsToCopy)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tmp_manager.CheckProjectedUsage(ctx, GetTmpDir(), projectedArchiveSize); err != nil {
+		return "", fmt.Errorf("unable to create context archive: %s", err)
+	}
+
+	tmp_manager.RegisterRunPath(destinationArchivePath)
+
+	pathsToExcludeFromSourceArchive := contextAddFiles
+	if err := util.CreateArchiveBasedOnAnotherOne(ctx, originalArchivePath, destinationArchivePath, pathsToExcludeFromSourceArchive, func(tw *tar.Writer) error {
 		for _, addFilePathToCopy := range addFilePathsToCopy {
 			tarEntryName, err := filepath.Rel(filepath.Join(projectDir, contextDir), addFilePathToCopy)
 			if err != nil {
 				return fmt.Errorf("unable to get context relative path for %q: %s", addFilePathToCopy, err)
 			}
 			tarEntryName = filepath.ToSlash(tarEntryName)
-			if err := util.CopyFileIntoTar(tw, tarEntryName, addFilePathToCopy); err != nil {
+
+			copyFileIntoTar := util.CopyFileIntoTar
+			if platformIndependent {
+				copyFileIntoTar = copyPlatformIndependentFileIntoTar
+			}
+
+			if err := copyFileIntoTar(tw, tarEntryName, addFilePathToCopy); err != nil {
 				return fmt.Errorf("unable to add contextAddFile %q to archive %q: %s", addFilePathToCopy, destinationArchivePath, err)
 			}
 			logboek.Context(ctx).Debug().LogF("Extra file was added to the current context: %q\n", tarEntryName)
@@ -141,3 +310,64 @@ func AddContextAddFilesToContextArchive(ctx context.Context, originalArchivePath
 
 	return destinationArchivePath, nil
 }
+
+// ReplaceFileInContextArchive creates a copy of the context archive with the file data replaced (or added) by the context relative path.
+func ReplaceFileInContextArchive(ctx context.Context, originalArchivePath string, contextRelPath string, data []byte) (string, error) {
+	destinationArchivePath := GetTmpArchivePath()
+
+	originalArchiveInfo, err := os.Stat(originalArchivePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to get file info for %q: %s", originalArchivePath, err)
+	}
+
+	projectedArchiveSize := uint64(originalArchiveInfo.Size()) + uint64(len(data)) + 2*tarBlockSize
+	if err := tmp_manager.CheckProjectedUsage(ctx, GetTmpDir(), projectedArchiveSize); err != nil {
+		return "", fmt.Errorf("unable to create context archive: %s", err)
+	}
+
+	tmp_manager.RegisterRunPath(destinationArchivePath)
+
+	tarEntryName := filepath.ToSlash(filepath.Clean(contextRelPath))
+	if err := util.CreateArchiveBasedOnAnotherOne(ctx, originalArchivePath, destinationArchivePath, []string{tarEntryName}, func(tw *tar.Writer) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     tarEntryName,
+			Mode:     0644,
+			Size:     int64(len(data)),
+		}); err != nil {
+			return fmt.Errorf("unable to write tar header for %q: %s", tarEntryName, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("unable to write %q into archive %q: %s", tarEntryName, destinationArchivePath, err)
+		}
+
+		logboek.Context(ctx).Debug().LogF("File was replaced in the current context: %q\n", tarEntryName)
+
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return destinationArchivePath, nil
+}
+
+// getProjectedArchiveSize returns the upper bound of the context archive size: the original archive and all added files with the tar headers.
+func getProjectedArchiveSize(originalArchivePath string, addFilePaths []string) (uint64, error) {
+	originalArchiveInfo, err := os.Stat(originalArchivePath)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get file info for %q: %s", originalArchivePath, err)
+	}
+
+	size := uint64(originalArchiveInfo.Size())
+	for _, addFilePath := range addFilePaths {
+		addFileInfo, err := os.Stat(addFilePath)
+		if err != nil {
+			return 0, fmt.Errorf("unable to get file info for %q: %s", addFilePath, err)
+		}
+
+		size += uint64(addFileInfo.Size()) + 2*tarBlockSize
+	}
+
+	return size, nil
+}
@@ -0,0 +1,137 @@
+package context_manager
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+func TestCopyWithLFLineEndings(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                            "",
+		"a\r\nb\r\n":                  "a\nb\n",
+		"a\nb":                        "a\nb",
+		"a\rb\r":                      "a\rb\r",
+		"a\r\r\nb":                    "a\r\nb",
+		strings.Repeat("x\r\n", 5000): strings.Repeat("x\n", 5000),
+	} {
+		var buf bytes.Buffer
+		if err := copyWithLFLineEndings(&buf, bufio.NewReader(strings.NewReader(input))); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != expected {
+			t.Errorf("%q: expected %q, got %q", input, expected, buf.String())
+		}
+	}
+}
+
+func TestIsBinaryData(t *testing.T) {
+	for input, expected := range map[string]bool{
+		"":                                 false,
+		"text\r\n":                         false,
+		"bin\x00ary":                       true,
+		strings.Repeat("x", 7999) + "\x00": true,
+		strings.Repeat("x", 8000) + "\x00": false,
+	} {
+		r := bufio.NewReaderSize(strings.NewReader(input), binaryDataDetectionSize)
+
+		isBinary, err := isBinaryData(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isBinary != expected {
+			t.Errorf("%.20q...: expected binary %v, got %v", input, expected, isBinary)
+		}
+
+		// the detection does not consume the data
+		if data, _ := ioutil.ReadAll(r); string(data) != input {
+			t.Errorf("%.20q...: data is consumed by the detection", input)
+		}
+	}
+}
+
+func writeTestFile(t *testing.T, path, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextAddFilesChecksum_PlatformIndependent(t *testing.T) {
+	ctx := context.Background()
+
+	checksum := func(files map[string]string, platformIndependent bool) string {
+		dir := t.TempDir()
+		for path, data := range files {
+			writeTestFile(t, filepath.Join(dir, "context", path), data)
+		}
+
+		res, err := ContextAddFilesChecksum(ctx, dir, "context", []string{"dir", "file"}, path_matcher.NewTruePathMatcher(), platformIndependent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	lf := map[string]string{"file": "a\nb\n", "dir/bin": "\x00\r\n"}
+	crlf := map[string]string{"file": "a\r\nb\r\n", "dir/bin": "\x00\r\n"}
+	binaryChanged := map[string]string{"file": "a\nb\n", "dir/bin": "\x00\n"}
+
+	if checksum(lf, true) != checksum(crlf, true) {
+		t.Fatal("expected the same checksum for the CRLF and LF text files")
+	}
+	if checksum(lf, true) == checksum(binaryChanged, true) {
+		t.Fatal("expected the binary file to be taken into account as is")
+	}
+	if checksum(lf, false) == checksum(crlf, false) {
+		t.Fatal("expected the default checksum to depend on the line endings")
+	}
+}
+
+func TestCopyPlatformIndependentFileIntoTar(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "text"), "a\r\nb\r\n")
+	writeTestFile(t, filepath.Join(dir, "bin"), "\x00\r\n")
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"text", "bin"} {
+		if err := copyPlatformIndependentFileIntoTar(tw, name, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"text": "a\nb\n", "bin": "\x00\r\n"}
+
+	tr := tar.NewReader(&buf)
+	for range expected {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected[hdr.Name] || hdr.Size != int64(len(data)) {
+			t.Errorf("%s: expected %q, got %q (size %d)", hdr.Name, expected[hdr.Name], data, hdr.Size)
+		}
+	}
+}
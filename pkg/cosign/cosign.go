@@ -0,0 +1,32 @@
+// Package cosign signs and verifies the container registry artifacts with the cosign CLI,
+// cosign uses the same docker config to access the registry.
+package cosign
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/werf/logboek"
+)
+
+func Sign(ctx context.Context, reference, key string) error {
+	logboek.Context(ctx).Default().LogF("Signing %s\n", reference)
+
+	return run(ctx, "sign", "--key", key, reference)
+}
+
+func Verify(ctx context.Context, reference, key string) error {
+	logboek.Context(ctx).Default().LogF("Verifying signature of %s\n", reference)
+
+	return run(ctx, "verify", "--key", key, reference)
+}
+
+func run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "cosign", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cosign %s failed: %s\n%s", args[0], err, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"google.golang.org/grpc"
+
+	"github.com/werf/werf/pkg/api"
+)
+
+// Client runs the operations in the werf daemon listening on the unix socket.
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+func NewClient(ctx context.Context, socketPath string) (*Client, error) {
+	conn, err := grpc.DialContext(ctx, socketPath,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		}),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(CodecName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to werf daemon %s: %s", socketPath, err)
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Build runs the build in the daemon, the events are passed to the handler until the finished event.
+func (c *Client) Build(ctx context.Context, opts api.BuildOptions, handler func(Event)) error {
+	return c.runOperation(ctx, "Build", opts, handler)
+}
+
+func (c *Client) Converge(ctx context.Context, opts api.ConvergeOptions, handler func(Event)) error {
+	return c.runOperation(ctx, "Converge", opts, handler)
+}
+
+func (c *Client) Cleanup(ctx context.Context, opts api.CleanupOptions, handler func(Event)) error {
+	return c.runOperation(ctx, "Cleanup", opts, handler)
+}
+
+// runOperation returns the operation error from the finished event or the stream error.
+func (c *Client) runOperation(ctx context.Context, method string, opts interface{}, handler func(Event)) error {
+	streamDesc := &grpc.StreamDesc{StreamName: method, ServerStreams: true}
+
+	stream, err := c.conn.NewStream(ctx, streamDesc, fmt.Sprintf("/%s/%s", ServiceName, method))
+	if err != nil {
+		return err
+	}
+
+	if err := stream.SendMsg(opts); err != nil {
+		return err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var event Event
+		if err := stream.RecvMsg(&event); err == io.EOF {
+			return fmt.Errorf("werf daemon closed the stream without the finished event")
+		} else if err != nil {
+			return err
+		}
+
+		handler(event)
+
+		if event.Type == EventFinished {
+			if event.Error != "" {
+				return fmt.Errorf("%s", event.Error)
+			}
+
+			return nil
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/api"
+	"github.com/werf/werf/pkg/events"
+)
+
+type fakeOperations struct {
+	buildOpts api.BuildOptions
+}
+
+func (o *fakeOperations) Build(ctx context.Context, opts api.BuildOptions) error {
+	o.buildOpts = opts
+
+	logboek.Context(ctx).LogF("building %s\n", opts.ImageNames[0])
+	events.Emit(events.Event{Type: events.StageStarted, Image: opts.ImageNames[0], Stage: "from"})
+	logboek.Context(ctx).Warn().LogF("warning\n")
+
+	return nil
+}
+
+func (o *fakeOperations) Converge(_ context.Context, _ api.ConvergeOptions) error {
+	return fmt.Errorf("converge failed")
+}
+
+func (o *fakeOperations) Cleanup(_ context.Context, _ api.CleanupOptions) error {
+	return nil
+}
+
+func startServer(t *testing.T, operations Operations) *Client {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grpcServer := grpc.NewServer()
+	NewServer(operations).Register(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	client, err := NewClient(context.Background(), socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestBuild(t *testing.T) {
+	operations := &fakeOperations{}
+	client := startServer(t, operations)
+
+	var got []Event
+	err := client.Build(context.Background(), api.BuildOptions{CommonOptions: api.CommonOptions{Dir: "/project"}, ImageNames: []string{"backend"}}, func(event Event) {
+		got = append(got, event)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if operations.buildOpts.Dir != "/project" || len(operations.buildOpts.ImageNames) != 1 {
+		t.Errorf("unexpected build options: %+v", operations.buildOpts)
+	}
+
+	var types, logs []string
+	var progress *events.Event
+	for _, event := range got {
+		types = append(types, event.Type)
+
+		switch event.Type {
+		case EventLog:
+			logs = append(logs, event.Stream+": "+event.Data)
+		case EventProgress:
+			progress = event.Progress
+		}
+	}
+
+	if types[0] != EventStarted || types[len(types)-1] != EventFinished {
+		t.Errorf("expected started and finished events, got: %v", types)
+	}
+
+	if progress == nil || progress.Type != events.StageStarted || progress.Image != "backend" || progress.Stage != "from" {
+		t.Errorf("unexpected progress event: %+v", progress)
+	}
+
+	expectedLogs := []string{"out: building backend\n", "err: warning\n"}
+	if fmt.Sprint(logs) != fmt.Sprint(expectedLogs) {
+		t.Errorf("\n[EXPECTED]: %q\n[GOT]: %q", expectedLogs, logs)
+	}
+
+	if got[len(got)-1].Error != "" {
+		t.Errorf("unexpected error in the finished event: %s", got[len(got)-1].Error)
+	}
+}
+
+func TestConvergeError(t *testing.T) {
+	client := startServer(t, &fakeOperations{})
+
+	var finished Event
+	err := client.Converge(context.Background(), api.ConvergeOptions{}, func(event Event) {
+		if event.Type == EventFinished {
+			finished = event
+		}
+	})
+
+	if err == nil || err.Error() != "converge failed" {
+		t.Fatalf("expected operation error, got: %v", err)
+	}
+
+	if finished.Operation != "converge" || finished.Error != "converge failed" {
+		t.Errorf("unexpected finished event: %+v", finished)
+	}
+}
+
+func TestUnsubscribeAfterOperation(t *testing.T) {
+	client := startServer(t, &fakeOperations{})
+
+	if err := client.Cleanup(context.Background(), api.CleanupOptions{}, func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	if events.IsEnabled() {
+		t.Error("expected the progress subscription to be removed after the operation")
+	}
+}
+
+func TestListen(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+
+	listener, err := Listen(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	stat, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode()&os.ModeSocket == 0 || stat.Mode().Perm() != 0600 {
+		t.Errorf("expected the socket with 0600 mode, got %s", stat.Mode())
+	}
+
+	files, err := ioutil.ReadDir(filepath.Dir(socketPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the socket in the dir, got %d files", len(files))
+	}
+
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	if conn, err := net.Dial("unix", socketPath); err != nil {
+		t.Errorf("expected the socket to be served: %s", err)
+	} else {
+		conn.Close()
+	}
+}
+
+func TestListen_ServedSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if _, err := Listen(socketPath); err == nil {
+		t.Fatal("expected the error for the socket served by the other daemon")
+	}
+
+	if _, err := os.Stat(socketPath); err != nil {
+		t.Errorf("expected the served socket to be kept: %s", err)
+	}
+}
+
+func TestListen_StaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+
+	staleListener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleListener.(*net.UnixListener).SetUnlinkOnClose(false)
+	staleListener.Close()
+
+	listener, err := Listen(socketPath)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced: %s", err)
+	}
+	listener.Close()
+}
+
+func TestListen_NotSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+	if err := ioutil.WriteFile(socketPath, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Listen(socketPath); err == nil {
+		t.Fatal("expected the error for the existing file which is not a socket")
+	}
+
+	if data, err := ioutil.ReadFile(socketPath); err != nil || string(data) != "data" {
+		t.Errorf("expected the file to be kept, got %q: %v", data, err)
+	}
+}
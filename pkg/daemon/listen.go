@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Listen listens on the unix socket, which is accessible only by the owner of the daemon process.
+// Listen fails if the socket is served by the other daemon, the socket left by the daemon which was not stopped gracefully is replaced.
+func Listen(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create dir %s: %s", filepath.Dir(socketPath), err)
+	}
+
+	if stat, err := os.Lstat(socketPath); err == nil {
+		if stat.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unable to listen on %s: the file exists and it is not a socket", socketPath)
+		}
+
+		if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to listen on %s: the socket is served by the other werf daemon", socketPath)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to stat %s: %s", socketPath, err)
+	}
+
+	// the socket is created in the private dir and then moved into place, so it is never accessible by the other users
+	privateDir, err := ioutil.TempDir(filepath.Dir(socketPath), ".daemon-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(privateDir)
+
+	privateSocketPath := filepath.Join(privateDir, filepath.Base(socketPath))
+
+	listener, err := net.Listen("unix", privateSocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %s", socketPath, err)
+	}
+	// the socket file is removed by the daemon on shutdown, since the listener knows only the private path
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(privateSocketPath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("unable to change %s mode: %s", socketPath, err)
+	}
+
+	if err := os.Rename(privateSocketPath, socketPath); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("unable to move socket into %s: %s", socketPath, err)
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,211 @@
+// Package daemon implements the werf daemon gRPC service, which runs the build, converge and cleanup operations in the daemon process
+// and streams the logs and the progress events of the operation to the client.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/api"
+	"github.com/werf/werf/pkg/events"
+)
+
+const ServiceName = "werf.daemon.v1.Daemon"
+
+const (
+	EventStarted  = "started"
+	EventLog      = "log"
+	EventProgress = "progress"
+	EventFinished = "finished"
+)
+
+type Event struct {
+	Type      string
+	Operation string `json:",omitempty"`
+	// Stream is out or err for the log event
+	Stream string `json:",omitempty"`
+	Data   string `json:",omitempty"`
+	// Progress is the werf event (image and stage build, resource tracking) for the progress event
+	Progress *events.Event `json:",omitempty"`
+	Error    string        `json:",omitempty"`
+}
+
+// Operations run the daemon operations, the werf runtime is initialized by the daemon.
+type Operations interface {
+	Build(ctx context.Context, opts api.BuildOptions) error
+	Converge(ctx context.Context, opts api.ConvergeOptions) error
+	Cleanup(ctx context.Context, opts api.CleanupOptions) error
+}
+
+// APIOperations run the operations with the pkg/api functions.
+type APIOperations struct{}
+
+func (APIOperations) Build(ctx context.Context, opts api.BuildOptions) error {
+	return api.Build(ctx, opts)
+}
+
+func (APIOperations) Converge(ctx context.Context, opts api.ConvergeOptions) error {
+	return api.Converge(ctx, opts)
+}
+
+func (APIOperations) Cleanup(ctx context.Context, opts api.CleanupOptions) error {
+	return api.Cleanup(ctx, opts)
+}
+
+// Server runs the requested operations one at a time: the operations for the different projects cannot share the werf runtime concurrently
+// and the progress events of the runtime cannot be attributed to the concurrent operations.
+type Server struct {
+	operations     Operations
+	operationMutex sync.Mutex
+}
+
+func NewServer(operations Operations) *Server {
+	return &Server{operations: operations}
+}
+
+// Register registers the daemon service in the gRPC server.
+func (s *Server) Register(grpcServer *grpc.Server) {
+	grpcServer.RegisterService(&serviceDesc, s)
+}
+
+func (s *Server) build(stream grpc.ServerStream) error {
+	var opts api.BuildOptions
+	if err := stream.RecvMsg(&opts); err != nil {
+		return err
+	}
+
+	return s.runOperation(stream, "build", func(ctx context.Context) error {
+		return s.operations.Build(ctx, opts)
+	})
+}
+
+func (s *Server) converge(stream grpc.ServerStream) error {
+	var opts api.ConvergeOptions
+	if err := stream.RecvMsg(&opts); err != nil {
+		return err
+	}
+
+	return s.runOperation(stream, "converge", func(ctx context.Context) error {
+		return s.operations.Converge(ctx, opts)
+	})
+}
+
+func (s *Server) cleanup(stream grpc.ServerStream) error {
+	var opts api.CleanupOptions
+	if err := stream.RecvMsg(&opts); err != nil {
+		return err
+	}
+
+	return s.runOperation(stream, "cleanup", func(ctx context.Context) error {
+		return s.operations.Cleanup(ctx, opts)
+	})
+}
+
+// runOperation streams the operation events, the operation error is sent in the finished event,
+// the returned error is the stream error only.
+func (s *Server) runOperation(stream grpc.ServerStream, operation string, runFunc func(ctx context.Context) error) error {
+	sender := &eventsSender{stream: stream}
+
+	s.operationMutex.Lock()
+	defer s.operationMutex.Unlock()
+
+	sender.Send(Event{Type: EventStarted, Operation: operation})
+
+	unsubscribe := events.Subscribe(func(e events.Event) {
+		sender.Send(Event{Type: EventProgress, Operation: operation, Progress: &e})
+	})
+
+	logger := logboek.NewLogger(sender.logStream("out"), sender.logStream("err"))
+	err := runFunc(logboek.NewContext(stream.Context(), logger))
+
+	unsubscribe()
+
+	finishedEvent := Event{Type: EventFinished, Operation: operation}
+	if err != nil {
+		finishedEvent.Error = err.Error()
+	}
+	sender.Send(finishedEvent)
+
+	return sender.Err()
+}
+
+// eventsSender serializes the sending of the events from the logger streams and the progress subscription,
+// the first send error is kept and the next events are dropped, so that the operation is not broken by the gone client.
+type eventsSender struct {
+	stream grpc.ServerStream
+	mutex  sync.Mutex
+	err    error
+}
+
+func (s *eventsSender) Send(event Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return
+	}
+
+	if err := s.stream.SendMsg(&event); err != nil {
+		s.err = fmt.Errorf("unable to send %s event: %s", event.Type, err)
+	}
+}
+
+func (s *eventsSender) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+func (s *eventsSender) logStream(stream string) *logStreamWriter {
+	return &logStreamWriter{sender: s, stream: stream}
+}
+
+type logStreamWriter struct {
+	sender *eventsSender
+	stream string
+}
+
+func (w *logStreamWriter) Write(p []byte) (int, error) {
+	w.sender.Send(Event{Type: EventLog, Stream: w.stream, Data: string(p)})
+	return len(p), nil
+}
+
+type daemonServer interface {
+	build(stream grpc.ServerStream) error
+	converge(stream grpc.ServerStream) error
+	cleanup(stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*daemonServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Build",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(daemonServer).build(stream)
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "Converge",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(daemonServer).converge(stream)
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "Cleanup",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(daemonServer).cleanup(stream)
+			},
+			ServerStreams: true,
+		},
+	},
+}
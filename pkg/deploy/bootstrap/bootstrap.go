@@ -0,0 +1,343 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/kubeutils"
+	"github.com/werf/werf/pkg/storage"
+)
+
+const (
+	managedByLabelName  = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "werf"
+)
+
+type Options struct {
+	// ImagesRepository is the repo, which pull-only token is saved into the image pull secrets.
+	ImagesRepository string
+}
+
+// Bootstrap creates or updates the namespace, the image pull secrets and the service accounts with RBAC
+// declared in the deploy bootstrap section of werf.yaml before the release is installed,
+// so that the release resources and hooks could use them from the very first deploy.
+// Bootstrap resources are not the part of the release and are not deleted by the dismiss.
+func Bootstrap(ctx context.Context, client kubernetes.Interface, namespace string, bootstrap *config.MetaDeployBootstrap, opts Options) error {
+	if err := bootstrapNamespace(ctx, client, namespace, bootstrap.Namespace); err != nil {
+		return err
+	}
+
+	if len(bootstrap.ImagePullSecrets) != 0 {
+		if !isRemoteRepository(opts.ImagesRepository) {
+			return fmt.Errorf("bootstrap image pull secrets require the images repo (--repo option)")
+		}
+
+		dockerConfigJson, _, err := docker_registry.API().GetPullTokenDockerConfigJson(ctx, opts.ImagesRepository)
+		if err != nil {
+			return fmt.Errorf("unable to get %q pull token: %s", opts.ImagesRepository, err)
+		}
+
+		for _, name := range bootstrap.ImagePullSecrets {
+			if err := ApplyImagePullSecret(ctx, client, namespace, name, dockerConfigJson); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, serviceAccount := range bootstrap.ServiceAccounts {
+		if err := bootstrapServiceAccount(ctx, client, namespace, serviceAccount, bootstrap.ImagePullSecrets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func bootstrapNamespace(ctx context.Context, client kubernetes.Interface, name string, bootstrapNamespace *config.MetaDeployBootstrapNamespace) error {
+	var labels, annotations map[string]string
+	if bootstrapNamespace != nil {
+		labels, annotations = bootstrapNamespace.Labels, bootstrapNamespace.Annotations
+	}
+
+	namespace, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		namespace.Labels = mergeStringMaps(map[string]string{"name": name}, labels)
+		namespace.Annotations = annotations
+
+		logboek.Context(ctx).Default().LogF("Creating Namespace %q\n", name)
+		if _, err := client.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create Namespace %q: %s", name, err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get Namespace %q: %s", name, err)
+	}
+
+	if isStringMapSubset(labels, namespace.Labels) && isStringMapSubset(annotations, namespace.Annotations) {
+		return nil
+	}
+
+	namespace.Labels = mergeStringMaps(namespace.Labels, labels)
+	namespace.Annotations = mergeStringMaps(namespace.Annotations, annotations)
+
+	logboek.Context(ctx).Default().LogF("Updating Namespace %q\n", name)
+	if _, err := client.CoreV1().Namespaces().Update(ctx, namespace, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update Namespace %q: %s", name, err)
+	}
+
+	return nil
+}
+
+// SyncImagePullSecret creates or updates the image pull secret with the short-lived pull-only token of the repository,
+// the secret is updated on each run to get the new token before the old one expires and to keep it in sync with the rotated credentials.
+func SyncImagePullSecret(ctx context.Context, client kubernetes.Interface, namespace, name, repository string) error {
+	if !isRemoteRepository(repository) {
+		return fmt.Errorf("image pull secret requires the images repo (--repo option)")
+	}
+
+	dockerConfigJson, pullToken, err := docker_registry.API().GetPullTokenDockerConfigJson(ctx, repository)
+	if err != nil {
+		return fmt.Errorf("unable to get %q pull token: %s", repository, err)
+	}
+
+	if err := kubeutils.CreateNamespaceIfNotExists(client, namespace); err != nil {
+		return err
+	}
+
+	if err := ApplyImagePullSecret(ctx, client, namespace, name, dockerConfigJson); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogF("NOTE: Secret %q contains the pull token for the repo %q, which expires at %s.\n", name, repository, pullToken.ExpiresAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// ApplyImagePullSecret creates or updates the kubernetes.io/dockerconfigjson secret with the specified docker config.
+func ApplyImagePullSecret(ctx context.Context, client kubernetes.Interface, namespace, name string, dockerConfigJson []byte) error {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{managedByLabelName: managedByLabelValue},
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: dockerConfigJson},
+		}
+
+		logboek.Context(ctx).Default().LogF("Creating Secret %q\n", name)
+		if _, err := client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create Secret %q: %s", name, err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get Secret %q: %s", name, err)
+	}
+
+	if secret.Type != corev1.SecretTypeDockerConfigJson {
+		return fmt.Errorf("unable to update Secret %q: type %q found, %q expected", name, secret.Type, corev1.SecretTypeDockerConfigJson)
+	}
+
+	if string(secret.Data[corev1.DockerConfigJsonKey]) == string(dockerConfigJson) {
+		return nil
+	}
+
+	secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: dockerConfigJson}
+
+	logboek.Context(ctx).Default().LogF("Updating Secret %q\n", name)
+	if _, err := client.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update Secret %q: %s", name, err)
+	}
+
+	return nil
+}
+
+func bootstrapServiceAccount(ctx context.Context, client kubernetes.Interface, namespace string, bootstrapServiceAccount *config.MetaDeployBootstrapServiceAccount, imagePullSecrets []string) error {
+	name := bootstrapServiceAccount.Name
+
+	serviceAccount, err := client.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		serviceAccount = &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{managedByLabelName: managedByLabelValue},
+			},
+		}
+		for _, secretName := range imagePullSecrets {
+			serviceAccount.ImagePullSecrets = append(serviceAccount.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+		}
+
+		logboek.Context(ctx).Default().LogF("Creating ServiceAccount %q\n", name)
+		if _, err := client.CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create ServiceAccount %q: %s", name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("unable to get ServiceAccount %q: %s", name, err)
+	} else {
+		existingSecrets := map[string]bool{}
+		for _, ref := range serviceAccount.ImagePullSecrets {
+			existingSecrets[ref.Name] = true
+		}
+
+		var changed bool
+		for _, secretName := range imagePullSecrets {
+			if !existingSecrets[secretName] {
+				serviceAccount.ImagePullSecrets = append(serviceAccount.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+				changed = true
+			}
+		}
+
+		if changed {
+			logboek.Context(ctx).Default().LogF("Updating ServiceAccount %q\n", name)
+			if _, err := client.CoreV1().ServiceAccounts(namespace).Update(ctx, serviceAccount, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("unable to update ServiceAccount %q: %s", name, err)
+			}
+		}
+	}
+
+	if len(bootstrapServiceAccount.Rules) != 0 {
+		var rules []rbacv1.PolicyRule
+		for _, rule := range bootstrapServiceAccount.Rules {
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups:     rule.APIGroups,
+				Resources:     rule.Resources,
+				ResourceNames: rule.ResourceNames,
+				Verbs:         rule.Verbs,
+			})
+		}
+
+		if err := applyRole(ctx, client, namespace, name, rules); err != nil {
+			return err
+		}
+
+		if err := applyRoleBinding(ctx, client, namespace, name, name, rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name}); err != nil {
+			return err
+		}
+	}
+
+	for _, clusterRole := range bootstrapServiceAccount.ClusterRoles {
+		roleBindingName := fmt.Sprintf("%s-%s", name, clusterRole)
+		if err := applyRoleBinding(ctx, client, namespace, roleBindingName, name, rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyRole(ctx context.Context, client kubernetes.Interface, namespace, name string, rules []rbacv1.PolicyRule) error {
+	role, err := client.RbacV1().Roles(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		role = &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{managedByLabelName: managedByLabelValue},
+			},
+			Rules: rules,
+		}
+
+		logboek.Context(ctx).Default().LogF("Creating Role %q\n", name)
+		if _, err := client.RbacV1().Roles(namespace).Create(ctx, role, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create Role %q: %s", name, err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get Role %q: %s", name, err)
+	}
+
+	role.Rules = rules
+
+	if _, err := client.RbacV1().Roles(namespace).Update(ctx, role, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update Role %q: %s", name, err)
+	}
+
+	return nil
+}
+
+func applyRoleBinding(ctx context.Context, client kubernetes.Interface, namespace, name, serviceAccountName string, roleRef rbacv1.RoleRef) error {
+	newRoleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{managedByLabelName: managedByLabelValue},
+		},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: serviceAccountName, Namespace: namespace},
+		},
+		RoleRef: roleRef,
+	}
+
+	roleBinding, err := client.RbacV1().RoleBindings(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		logboek.Context(ctx).Default().LogF("Creating RoleBinding %q\n", name)
+		if _, err := client.RbacV1().RoleBindings(namespace).Create(ctx, newRoleBinding, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create RoleBinding %q: %s", name, err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get RoleBinding %q: %s", name, err)
+	}
+
+	// The role reference is immutable, so the binding to another role is recreated.
+	if roleBinding.RoleRef != roleRef {
+		logboek.Context(ctx).Default().LogF("Recreating RoleBinding %q\n", name)
+		if err := client.RbacV1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("unable to delete RoleBinding %q: %s", name, err)
+		}
+
+		if _, err := client.RbacV1().RoleBindings(namespace).Create(ctx, newRoleBinding, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create RoleBinding %q: %s", name, err)
+		}
+
+		return nil
+	}
+
+	roleBinding.Subjects = newRoleBinding.Subjects
+	if _, err := client.RbacV1().RoleBindings(namespace).Update(ctx, roleBinding, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update RoleBinding %q: %s", name, err)
+	}
+
+	return nil
+}
+
+func isRemoteRepository(repository string) bool {
+	return repository != "" && repository != storage.LocalStorageAddress
+}
+
+func mergeStringMaps(base, overrides map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range base {
+		res[k] = v
+	}
+	for k, v := range overrides {
+		res[k] = v
+	}
+
+	return res
+}
+
+func isStringMapSubset(subset, set map[string]string) bool {
+	for k, v := range subset {
+		if value, hasKey := set[k]; !hasKey || value != v {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,104 @@
+package bootstrap
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+var _ = Describe("Bootstrap", func() {
+	ctx := context.Background()
+
+	It("should create namespace and service account with RBAC", func() {
+		client := fake.NewSimpleClientset()
+
+		Ω(Bootstrap(ctx, client, "myapp", &config.MetaDeployBootstrap{
+			Namespace: &config.MetaDeployBootstrapNamespace{
+				Labels: map[string]string{"team": "backend"},
+			},
+			ServiceAccounts: []*config.MetaDeployBootstrapServiceAccount{
+				{
+					Name:         "app",
+					ClusterRoles: []string{"view"},
+					Rules: []*config.MetaDeployBootstrapPolicyRule{
+						{Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+					},
+				},
+			},
+		}, Options{})).Should(Succeed())
+
+		namespace, err := client.CoreV1().Namespaces().Get(ctx, "myapp", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(namespace.Labels).Should(Equal(map[string]string{"name": "myapp", "team": "backend"}))
+
+		_, err = client.CoreV1().ServiceAccounts("myapp").Get(ctx, "app", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		role, err := client.RbacV1().Roles("myapp").Get(ctx, "app", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(role.Rules).Should(HaveLen(1))
+
+		roleBinding, err := client.RbacV1().RoleBindings("myapp").Get(ctx, "app-view", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(roleBinding.RoleRef).Should(Equal(rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"}))
+	})
+
+	It("should update labels of the existing namespace", func() {
+		client := fake.NewSimpleClientset(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "myapp", Labels: map[string]string{"name": "myapp", "existing": "true"}},
+		})
+
+		Ω(Bootstrap(ctx, client, "myapp", &config.MetaDeployBootstrap{
+			Namespace: &config.MetaDeployBootstrapNamespace{
+				Labels:      map[string]string{"team": "backend"},
+				Annotations: map[string]string{"owner": "backend"},
+			},
+		}, Options{})).Should(Succeed())
+
+		namespace, err := client.CoreV1().Namespaces().Get(ctx, "myapp", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(namespace.Labels).Should(Equal(map[string]string{"name": "myapp", "existing": "true", "team": "backend"}))
+		Ω(namespace.Annotations).Should(Equal(map[string]string{"owner": "backend"}))
+	})
+
+	It("should require images repo for image pull secrets", func() {
+		err := Bootstrap(ctx, fake.NewSimpleClientset(), "myapp", &config.MetaDeployBootstrap{
+			ImagePullSecrets: []string{"registry"},
+		}, Options{})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("--repo"))
+	})
+
+	It("should update image pull secret and attach it to service account", func() {
+		client := fake.NewSimpleClientset()
+
+		Ω(ApplyImagePullSecret(ctx, client, "myapp", "registry", []byte(`{"auths":{}}`))).Should(Succeed())
+		Ω(ApplyImagePullSecret(ctx, client, "myapp", "registry", []byte(`{"auths":{"example.com":{}}}`))).Should(Succeed())
+
+		secret, err := client.CoreV1().Secrets("myapp").Get(ctx, "registry", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(secret.Type).Should(Equal(corev1.SecretTypeDockerConfigJson))
+		Ω(string(secret.Data[corev1.DockerConfigJsonKey])).Should(Equal(`{"auths":{"example.com":{}}}`))
+
+		Ω(bootstrapServiceAccount(ctx, client, "myapp", &config.MetaDeployBootstrapServiceAccount{Name: "app"}, []string{"registry"})).Should(Succeed())
+
+		serviceAccount, err := client.CoreV1().ServiceAccounts("myapp").Get(ctx, "app", metav1.GetOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(serviceAccount.ImagePullSecrets).Should(Equal([]corev1.LocalObjectReference{{Name: "registry"}}))
+	})
+})
+
+var _ = Describe("SyncImagePullSecret", func() {
+	It("should require remote images repo", func() {
+		err := SyncImagePullSecret(context.Background(), fake.NewSimpleClientset(), "myapp", "registry", ":local")
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("--repo"))
+	})
+})
@@ -0,0 +1,169 @@
+// Package bundles stores werf bundles in the container registry as the standard OCI helm charts,
+// so that published bundles could be pulled and installed by the helm CLI.
+package bundles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	ChartConfigMediaType       = "application/vnd.cncf.helm.config.v1+json"
+	ChartContentLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	// LegacyChartContentLayerMediaType is used by the bundles published with the experimental helm OCI support.
+	LegacyChartContentLayerMediaType = "application/tar+gzip"
+)
+
+const (
+	annotationTitle       = "org.opencontainers.image.title"
+	annotationVersion     = "org.opencontainers.image.version"
+	annotationDescription = "org.opencontainers.image.description"
+	annotationCreated     = "org.opencontainers.image.created"
+	annotationRevision    = "org.opencontainers.image.revision"
+
+	annotationWerfVersion = "io.werf.version"
+	annotationProject     = "io.werf.project"
+	annotationEnv         = "io.werf.env"
+)
+
+// Publish packages the bundle dir as the helm chart archive and pushes it by the reference.
+// The bundle build info is kept in the manifest annotations.
+// The chart version is set to the tag, so that the bundle could be pulled by the helm CLI with the --version option.
+func Publish(ctx context.Context, bundleDir, bundleRef string) error {
+	ch, err := loader.Load(bundleDir)
+	if err != nil {
+		return fmt.Errorf("unable to load bundle %s: %s", bundleDir, err)
+	}
+
+	_, tag := splitReference(bundleRef)
+	if version, ok := chartVersionByTag(tag); ok {
+		ch.Metadata.Version = version
+	} else {
+		logboek.Context(ctx).Warn().LogF("WARNING: Bundle tag %q is not a semantic version, the chart version %q is kept and the bundle cannot be pulled by the helm CLI with the --version option\n", tag, ch.Metadata.Version)
+	}
+
+	buildInfo, err := chart_extender.ReadBundleBuildInfo(bundleDir)
+	if err != nil {
+		return fmt.Errorf("unable to read bundle build info: %s", err)
+	}
+
+	chartArchive, err := packageChart(ch)
+	if err != nil {
+		return fmt.Errorf("unable to package bundle: %s", err)
+	}
+
+	config, err := json.Marshal(ch.Metadata)
+	if err != nil {
+		return fmt.Errorf("unable to marshal chart metadata: %s", err)
+	}
+
+	return docker_registry.API().PushArtifact(ctx, bundleRef, &docker_registry.Artifact{
+		ConfigMediaType: ChartConfigMediaType,
+		Config:          config,
+		Layers: []container_registry_extensions.ArtifactLayer{
+			{MediaType: ChartContentLayerMediaType, Data: chartArchive},
+		},
+		Annotations: bundleAnnotations(ch.Metadata, buildInfo),
+	})
+}
+
+// Pull downloads the bundle by the reference and saves it into the destination dir,
+// the chart is saved into the dir named as the chart in the current working directory if destination is not specified.
+// Bundles published by the older werf versions are supported.
+func Pull(ctx context.Context, bundleRef, destination string) error {
+	artifact, err := docker_registry.API().PullArtifact(ctx, bundleRef)
+	if err != nil {
+		return err
+	}
+
+	var chartArchive []byte
+	for _, layer := range artifact.Layers {
+		switch layer.MediaType {
+		case ChartContentLayerMediaType, LegacyChartContentLayerMediaType:
+			chartArchive = layer.Data
+		}
+	}
+
+	if chartArchive == nil {
+		return fmt.Errorf("manifest does not contain a layer with mediatype %s", ChartContentLayerMediaType)
+	}
+
+	ch, err := loader.LoadArchive(bytes.NewReader(chartArchive))
+	if err != nil {
+		return fmt.Errorf("unable to load chart archive: %s", err)
+	}
+
+	if destination == "" {
+		return chartutil.SaveDir(ch, ".")
+	}
+
+	return chartutil.SaveIntoDir(ch, destination)
+}
+
+// chartVersionByTag returns the chart version for the tag, the helm CLI replaces "+" with "_" in the tags of OCI charts.
+func chartVersionByTag(tag string) (string, bool) {
+	version := strings.ReplaceAll(tag, "_", "+")
+	if _, err := semver.NewVersion(version); err != nil {
+		return "", false
+	}
+
+	return version, true
+}
+
+func packageChart(ch *chart.Chart) ([]byte, error) {
+	tmpDir, err := ioutil.TempDir(werf.GetTmpDir(), "bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath, err := chartutil.Save(ch, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(archivePath)
+}
+
+func bundleAnnotations(metadata *chart.Metadata, buildInfo *chart_extender.BundleBuildInfo) map[string]string {
+	annotations := map[string]string{
+		annotationTitle:   metadata.Name,
+		annotationVersion: metadata.Version,
+		annotationCreated: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if metadata.Description != "" {
+		annotations[annotationDescription] = metadata.Description
+	}
+
+	if buildInfo != nil {
+		annotations[annotationWerfVersion] = buildInfo.WerfVersion
+		annotations[annotationProject] = buildInfo.Project
+		annotations[annotationRevision] = buildInfo.Commit
+
+		if buildInfo.Env != "" {
+			annotations[annotationEnv] = buildInfo.Env
+		}
+	}
+
+	return annotations
+}
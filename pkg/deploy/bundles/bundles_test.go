@@ -0,0 +1,30 @@
+package bundles
+
+import "testing"
+
+func TestChartVersionByTag(t *testing.T) {
+	tests := []struct {
+		tag         string
+		wantVersion string
+		wantOk      bool
+	}{
+		{tag: "1.2.3", wantVersion: "1.2.3", wantOk: true},
+		{tag: "v1.2.3", wantVersion: "v1.2.3", wantOk: true},
+		{tag: "1.2.3-rc.1", wantVersion: "1.2.3-rc.1", wantOk: true},
+		{tag: "1.2.3_build.5", wantVersion: "1.2.3+build.5", wantOk: true},
+		{tag: "latest", wantOk: false},
+		{tag: "main-4f2a1c", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			version, ok := chartVersionByTag(tt.tag)
+			if ok != tt.wantOk {
+				t.Fatalf("expected ok %v, got %v", tt.wantOk, ok)
+			}
+			if version != tt.wantVersion {
+				t.Fatalf("expected version %q, got %q", tt.wantVersion, version)
+			}
+		})
+	}
+}
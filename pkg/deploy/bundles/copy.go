@@ -0,0 +1,196 @@
+package bundles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/cosign"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type CopyOptions struct {
+	// SignKey is the cosign private key reference, the copied images and the bundle are signed with this key if specified.
+	SignKey string
+}
+
+// Copy copies the bundle and all images referenced in the bundle values into another repo (e.g. to promote the bundle into an air-gapped registry).
+// Image references in the bundle values are rewritten to point to the destination repo, image tags are preserved.
+func Copy(ctx context.Context, fromRef, toRef string, opts CopyOptions) error {
+	toRepo, _ := splitReference(toRef)
+
+	bundleDir, err := ioutil.TempDir(werf.GetTmpDir(), "bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(bundleDir)
+
+	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", fromRef).DoError(func() error {
+		return Pull(ctx, fromRef, bundleDir)
+	}); err != nil {
+		return fmt.Errorf("error pulling bundle %q: %s", fromRef, err)
+	}
+
+	valuesPath := filepath.Join(bundleDir, "values.yaml")
+	vals, err := readValues(valuesPath)
+	if err != nil {
+		return fmt.Errorf("unable to read bundle values: %s", err)
+	}
+
+	images := rewriteImagesValues(vals, toRepo)
+
+	var sourceImages []string
+	for sourceImage := range images {
+		sourceImages = append(sourceImages, sourceImage)
+	}
+	sort.Strings(sourceImages)
+
+	for _, sourceImage := range sourceImages {
+		destinationImage := images[sourceImage]
+
+		if err := logboek.Context(ctx).LogProcess("Copying image %q to %q", sourceImage, destinationImage).DoError(func() error {
+			digest, err := docker_registry.API().CopyImage(ctx, sourceImage, destinationImage)
+			if err != nil {
+				return err
+			}
+
+			if opts.SignKey != "" {
+				repo, _ := splitReference(destinationImage)
+				return cosign.Sign(ctx, fmt.Sprintf("%s@%s", repo, digest), opts.SignKey)
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("error copying image %q: %s", sourceImage, err)
+		}
+	}
+
+	if err := writeValues(valuesPath, vals); err != nil {
+		return fmt.Errorf("unable to write bundle values: %s", err)
+	}
+
+	return logboek.Context(ctx).LogProcess("Publishing bundle %q", toRef).DoError(func() error {
+		if err := Publish(ctx, bundleDir, toRef); err != nil {
+			return err
+		}
+
+		if opts.SignKey != "" {
+			return cosign.Sign(ctx, toRef, opts.SignKey)
+		}
+
+		return nil
+	})
+}
+
+// rewriteImagesValues points the werf service values, including the dependencies images, to the destination repo
+// and returns the source to destination mapping of the images to be copied.
+func rewriteImagesValues(vals map[string]interface{}, toRepo string) map[string]string {
+	images := map[string]string{}
+
+	werfVals, ok := vals["werf"].(map[string]interface{})
+	if !ok {
+		return images
+	}
+
+	rewriteImage := func(sourceImage string) string {
+		_, tag := splitReference(sourceImage)
+		destinationImage := fmt.Sprintf("%s:%s", toRepo, tag)
+		images[sourceImage] = destinationImage
+		return destinationImage
+	}
+
+	if _, hasRepo := werfVals["repo"]; hasRepo {
+		werfVals["repo"] = toRepo
+	}
+
+	if imageVals, ok := werfVals["image"].(map[string]interface{}); ok {
+		for imageName, sourceImage := range imageVals {
+			if sourceImage, ok := sourceImage.(string); ok {
+				imageVals[imageName] = rewriteImage(sourceImage)
+			}
+		}
+	}
+
+	if sourceImage, ok := werfVals["nameless_image"].(string); ok {
+		werfVals["nameless_image"] = rewriteImage(sourceImage)
+	}
+
+	// the image digest is preserved by the copy, so only the repo is changed
+	rewriteImageDigest := func(sourceImageDigest string) string {
+		if i := strings.LastIndex(sourceImageDigest, "@"); i != -1 {
+			return fmt.Sprintf("%s%s", toRepo, sourceImageDigest[i:])
+		}
+		return sourceImageDigest
+	}
+
+	if imageDigestVals, ok := werfVals["image_digest"].(map[string]interface{}); ok {
+		for imageName, sourceImageDigest := range imageDigestVals {
+			if sourceImageDigest, ok := sourceImageDigest.(string); ok {
+				imageDigestVals[imageName] = rewriteImageDigest(sourceImageDigest)
+			}
+		}
+	}
+
+	if sourceImageDigest, ok := werfVals["nameless_image_digest"].(string); ok {
+		werfVals["nameless_image_digest"] = rewriteImageDigest(sourceImageDigest)
+	}
+
+	// the images of the other projects are copied into the same repo, the tag and the digest are preserved
+	if dependenciesVals, ok := werfVals["dependencies"].(map[string]interface{}); ok {
+		for _, dependencyVals := range dependenciesVals {
+			dependencyVals, ok := dependencyVals.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if sourceImage, ok := dependencyVals["image"].(string); ok && sourceImage != "" {
+				dependencyVals["image"] = rewriteImage(sourceImage)
+				dependencyVals["repo"] = toRepo
+			}
+		}
+	}
+
+	return images
+}
+
+// splitReference splits the REPO:TAG reference, the latest tag is used if the tag is not specified.
+func splitReference(reference string) (string, string) {
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		return reference[:i], reference[i+1:]
+	}
+
+	return reference, "latest"
+}
+
+func readValues(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vals); err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
+
+func writeValues(path string, vals map[string]interface{}) error {
+	data, err := json.MarshalIndent(vals, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, []byte("\n")...), os.ModePerm)
+}
@@ -0,0 +1,89 @@
+package bundles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRewriteImagesValues(t *testing.T) {
+	vals := map[string]interface{}{
+		"werf": map[string]interface{}{
+			"repo": "registry.example.com/app",
+			"image": map[string]interface{}{
+				"backend": "registry.example.com/app:backend-tag",
+			},
+			"image_digest": map[string]interface{}{
+				"backend": "registry.example.com/app@sha256:backend",
+			},
+			"nameless_image":        "registry.example.com/app:nameless-tag",
+			"nameless_image_digest": "registry.example.com/app@sha256:nameless",
+			"dependencies": map[string]interface{}{
+				"auth": map[string]interface{}{
+					"image":  "registry.example.com/auth:auth-tag",
+					"repo":   "registry.example.com/auth",
+					"tag":    "auth-tag",
+					"digest": "sha256:auth",
+				},
+			},
+		},
+		"app": map[string]interface{}{"image": "registry.example.com/app:user-value"},
+	}
+
+	images := rewriteImagesValues(vals, "airgap.example.com:5000/app")
+
+	expectedImages := map[string]string{
+		"registry.example.com/app:backend-tag":  "airgap.example.com:5000/app:backend-tag",
+		"registry.example.com/app:nameless-tag": "airgap.example.com:5000/app:nameless-tag",
+		"registry.example.com/auth:auth-tag":    "airgap.example.com:5000/app:auth-tag",
+	}
+	if !reflect.DeepEqual(images, expectedImages) {
+		t.Fatalf("expected images %v, got %v", expectedImages, images)
+	}
+
+	expectedVals := map[string]interface{}{
+		"werf": map[string]interface{}{
+			"repo": "airgap.example.com:5000/app",
+			"image": map[string]interface{}{
+				"backend": "airgap.example.com:5000/app:backend-tag",
+			},
+			"image_digest": map[string]interface{}{
+				"backend": "airgap.example.com:5000/app@sha256:backend",
+			},
+			"nameless_image":        "airgap.example.com:5000/app:nameless-tag",
+			"nameless_image_digest": "airgap.example.com:5000/app@sha256:nameless",
+			"dependencies": map[string]interface{}{
+				"auth": map[string]interface{}{
+					"image":  "airgap.example.com:5000/app:auth-tag",
+					"repo":   "airgap.example.com:5000/app",
+					"tag":    "auth-tag",
+					"digest": "sha256:auth",
+				},
+			},
+		},
+		"app": map[string]interface{}{"image": "registry.example.com/app:user-value"},
+	}
+	if !reflect.DeepEqual(vals, expectedVals) {
+		t.Fatalf("expected values %v, got %v", expectedVals, vals)
+	}
+}
+
+func TestRewriteImagesValues_WithoutWerfValues(t *testing.T) {
+	vals := map[string]interface{}{"app": "value"}
+	if images := rewriteImagesValues(vals, "airgap.example.com/app"); len(images) != 0 {
+		t.Fatalf("expected no images, got %v", images)
+	}
+}
+
+func TestSplitReference(t *testing.T) {
+	for reference, expected := range map[string][2]string{
+		"registry.example.com/app:tag":      {"registry.example.com/app", "tag"},
+		"registry.example.com:5000/app:tag": {"registry.example.com:5000/app", "tag"},
+		"registry.example.com:5000/app":     {"registry.example.com:5000/app", "latest"},
+		"app":                               {"app", "latest"},
+	} {
+		repo, tag := splitReference(reference)
+		if repo != expected[0] || tag != expected[1] {
+			t.Fatalf("expected %q to be split into %v, got %q and %q", reference, expected, repo, tag)
+		}
+	}
+}
@@ -19,4 +19,23 @@ const (
 	ShowEventsAnnoName = "werf.io/show-service-messages"
 
 	ReplicasOnCreationAnnoName = "werf.io/replicas-on-creation"
+
+	DeployBeforeHooksAnnoName = "werf.io/deploy-before-hooks"
+	DeployWaveAnnoName        = "werf.io/deploy-wave"
+
+	CanaryAnnoName                   = "werf.io/canary"
+	CanaryReplicasPercentageAnnoName = "werf.io/canary-replicas-percentage"
+	CanaryBakeTimeAnnoName           = "werf.io/canary-bake-time"
+	CanaryRestartsAllowedAnnoName    = "werf.io/canary-restarts-allowed"
+	CanaryVariantLabelName           = "werf.io/canary-variant"
+
+	WaitForEndpointsAnnoName = "werf.io/wait-for-endpoints"
+	WaitForAddressAnnoName   = "werf.io/wait-for-address"
+	HealthURLAnnoName        = "werf.io/health-url"
+
+	// ReleaseNameAnnoName, ReleaseNamespaceAnnoName and ManagedByLabelName mark the resources owned by the helm 3 release.
+	ReleaseNameAnnoName      = "meta.helm.sh/release-name"
+	ReleaseNamespaceAnnoName = "meta.helm.sh/release-namespace"
+	ManagedByLabelName       = "app.kubernetes.io/managed-by"
+	ManagedByLabelValue      = "Helm"
 )
@@ -0,0 +1,161 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/werf/logboek"
+)
+
+// BeforeHooksResourcesCreator creates the release resources annotated with werf.io/deploy-before-hooks=true
+// before the pre-install and pre-upgrade hooks are run, so that hooks could rely on the NetworkPolicies, ServiceAccounts and other resources of the release.
+// Resources are created with the helm release ownership metadata and adopted by the release later,
+// already existing resources are left as is and updated at the regular deploy stage.
+// Created resources are deleted if the creation or the next deploy steps fail.
+type BeforeHooksResourcesCreator struct {
+	KubeClient       helm_kube.Interface
+	ReleaseName      string
+	ReleaseNamespace string
+
+	created helm_kube.ResourceList
+}
+
+func NewBeforeHooksResourcesCreator(kubeClient helm_kube.Interface, releaseName, releaseNamespace string) *BeforeHooksResourcesCreator {
+	return &BeforeHooksResourcesCreator{
+		KubeClient:       kubeClient,
+		ReleaseName:      releaseName,
+		ReleaseNamespace: releaseNamespace,
+	}
+}
+
+func (creator *BeforeHooksResourcesCreator) Run(ctx context.Context, plan *DeployPlan, _ *release.Release) error {
+	creator.created = nil
+
+	if err := creator.create(ctx, plan.BeforeHooksManifests); err != nil {
+		if rollbackErr := creator.Rollback(ctx); rollbackErr != nil {
+			return fmt.Errorf("%s\nrollback failed: %s", err, rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Rollback deletes the resources created by the last Run.
+func (creator *BeforeHooksResourcesCreator) Rollback(ctx context.Context) error {
+	if len(creator.created) == 0 {
+		return nil
+	}
+
+	for _, info := range creator.created {
+		logboek.Context(ctx).Default().LogF("Deleting %s created before hooks\n", info.ObjectName())
+	}
+
+	_, errs := creator.KubeClient.Delete(creator.created, helm_kube.DeleteOptions{Wait: true})
+	creator.created = nil
+
+	var errMsgs []string
+	for _, err := range errs {
+		if !apierrors.IsNotFound(err) {
+			errMsgs = append(errMsgs, err.Error())
+		}
+	}
+
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("unable to delete resources created before hooks: %s", strings.Join(errMsgs, "; "))
+	}
+
+	return nil
+}
+
+func (creator *BeforeHooksResourcesCreator) create(ctx context.Context, manifests []string) error {
+	if len(manifests) == 0 {
+		return nil
+	}
+
+	if err := creator.createNamespaceIfNotExists(); err != nil {
+		return err
+	}
+
+	resources, err := creator.KubeClient.Build(bytes.NewBufferString(strings.Join(manifests, "\n---\n")), false)
+	if err != nil {
+		return fmt.Errorf("unable to build resources to deploy before hooks: %s", err)
+	}
+
+	return resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		if _, err := helper.Get(info.Namespace, info.Name); err == nil {
+			return nil
+		} else if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to get %s: %s", info.ObjectName(), err)
+		}
+
+		if err := setReleaseOwnershipMetadata(info.Object, creator.ReleaseName, creator.ReleaseNamespace); err != nil {
+			return fmt.Errorf("unable to set release ownership metadata for %s: %s", info.ObjectName(), err)
+		}
+
+		logboek.Context(ctx).Default().LogF("Creating %s before hooks\n", info.ObjectName())
+
+		obj, err := helper.Create(info.Namespace, true, info.Object)
+		if err != nil {
+			return fmt.Errorf("unable to create %s: %s", info.ObjectName(), err)
+		}
+		creator.created.Append(info)
+
+		return info.Refresh(obj, true)
+	})
+}
+
+func (creator *BeforeHooksResourcesCreator) createNamespaceIfNotExists() error {
+	namespaceManifest := fmt.Sprintf("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: %s\n", creator.ReleaseNamespace)
+
+	resources, err := creator.KubeClient.Build(bytes.NewBufferString(namespaceManifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build namespace %q: %s", creator.ReleaseNamespace, err)
+	}
+
+	if _, err := creator.KubeClient.Create(resources); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create namespace %q: %s", creator.ReleaseNamespace, err)
+	}
+
+	return nil
+}
+
+// setReleaseOwnershipMetadata sets the metadata helm requires to adopt the existing resource into the release.
+func setReleaseOwnershipMetadata(obj runtime.Object, releaseName, releaseNamespace string) error {
+	labels, err := metadataAccessor.Labels(obj)
+	if err != nil {
+		return err
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ManagedByLabelName] = ManagedByLabelValue
+	if err := metadataAccessor.SetLabels(obj, labels); err != nil {
+		return err
+	}
+
+	annotations, err := metadataAccessor.Annotations(obj)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ReleaseNameAnnoName] = releaseName
+	annotations[ReleaseNamespaceAnnoName] = releaseNamespace
+
+	return metadataAccessor.SetAnnotations(obj, annotations)
+}
@@ -0,0 +1,334 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/werf/logboek"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	canaryNameSuffix             = "-canary"
+	canaryVariantLabelValue      = "canary"
+	canaryAnalysisPollPeriod     = 5 * time.Second
+	canaryDeletionTimeout        = 5 * time.Minute
+	canaryDeploymentKindShort    = "deploy"
+	defaultCanaryReplicasPercent = 10
+	defaultCanaryBakeTime        = 5 * time.Minute
+)
+
+// CanaryDeployer deploys the canary variant of each Deployment annotated with werf.io/canary=true before the release is updated.
+// The canary variant is the separate Deployment with the new pod template and the percentage of the replicas,
+// which shares the pod labels with the primary Deployment, so that the Services send the part of the traffic to the canary pods.
+// The canary is tracked until ready and then analysed during the bake time: the release update is continued (the canary is promoted)
+// only if all canary replicas stay ready and the containers are not restarted, otherwise the canary is rolled back:
+// the canary Deployment is deleted and all traffic is returned to the primary Deployment before the release update is aborted.
+type CanaryDeployer struct {
+	KubeClient helm_kube.Interface
+	Client     kubernetes.Interface
+	Timeout    time.Duration
+}
+
+type canarySpec struct {
+	Deployment      *appsv1.Deployment
+	BakeTime        time.Duration
+	RestartsAllowed int32
+}
+
+func NewCanaryDeployer(kubeClient helm_kube.Interface, client kubernetes.Interface, timeout time.Duration) *CanaryDeployer {
+	return &CanaryDeployer{
+		KubeClient: kubeClient,
+		Client:     client,
+		Timeout:    timeout,
+	}
+}
+
+func (deployer *CanaryDeployer) Run(ctx context.Context, plan *DeployPlan, _ *release.Release) error {
+	for _, manifest := range plan.CanaryManifests {
+		primary, err := deployer.buildDeployment(manifest)
+		if err != nil {
+			return err
+		}
+
+		if _, err := deployer.Client.AppsV1().Deployments(primary.Namespace).Get(ctx, primary.Name, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			logboek.Context(ctx).Default().LogF("Skipping canary for %s/%s: nothing to compare with, Deployment does not exist yet\n", canaryDeploymentKindShort, primary.Name)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("unable to get %s/%s: %s", canaryDeploymentKindShort, primary.Name, err)
+		}
+
+		spec, err := newCanarySpec(primary)
+		if err != nil {
+			return err
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Deploying canary %s/%s", canaryDeploymentKindShort, spec.Deployment.Name).DoError(func() error {
+			return deployer.deployCanary(ctx, spec)
+		}); err != nil {
+			if rollbackErr := deployer.rollbackCanary(ctx, spec.Deployment); rollbackErr != nil {
+				return fmt.Errorf("canary of %s/%s failed, the release is not updated: %s\nrollback failed: %s", canaryDeploymentKindShort, primary.Name, err, rollbackErr)
+			}
+			return fmt.Errorf("canary of %s/%s failed and rolled back, the release is not updated: %s", canaryDeploymentKindShort, primary.Name, err)
+		}
+
+		// The primary Deployment takes over the canary traffic, when the release is updated.
+		deployer.deleteCanary(ctx, spec.Deployment)
+	}
+
+	return nil
+}
+
+// Rollback does nothing: the canaries are deleted right after the analysis and the primary Deployments are not changed until the release is updated.
+func (deployer *CanaryDeployer) Rollback(_ context.Context) error {
+	return nil
+}
+
+// rollbackCanary deletes the failed canary and waits until the canary pods are gone, so that all traffic is served by the primary Deployment.
+func (deployer *CanaryDeployer) rollbackCanary(ctx context.Context, canary *appsv1.Deployment) error {
+	logboek.Context(ctx).Default().LogF("Rolling back canary %s/%s\n", canaryDeploymentKindShort, canary.Name)
+
+	deployer.deleteCanary(ctx, canary)
+	if err := deployer.waitCanaryDeleted(ctx, canary); err != nil {
+		return fmt.Errorf("unable to delete canary: %s", err)
+	}
+
+	return nil
+}
+
+func (deployer *CanaryDeployer) waitCanaryDeleted(ctx context.Context, canary *appsv1.Deployment) error {
+	ctx, cancel := context.WithTimeout(ctx, canaryDeletionTimeout)
+	defer cancel()
+
+	return wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		_, err := deployer.Client.AppsV1().Deployments(canary.Namespace).Get(ctx, canary.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}, ctx.Done())
+}
+
+func (deployer *CanaryDeployer) buildDeployment(manifest string) (*appsv1.Deployment, error) {
+	resources, err := deployer.KubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build canary resource: %s", err)
+	}
+
+	if len(resources) != 1 {
+		return nil, fmt.Errorf("unexpected canary resources count %d", len(resources))
+	}
+
+	obj, ok := resources[0].Object.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", resources[0].Object)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+		return nil, fmt.Errorf("unable to convert %s/%s to Deployment: %s", canaryDeploymentKindShort, obj.GetName(), err)
+	}
+	deployment.Namespace = resources[0].Namespace
+
+	return deployment, nil
+}
+
+func newCanarySpec(primary *appsv1.Deployment) (*canarySpec, error) {
+	resourceName := fmt.Sprintf("%s/%s", canaryDeploymentKindShort, primary.Name)
+
+	replicasPercentage := defaultCanaryReplicasPercent
+	if value, hasKey := primary.Annotations[CanaryReplicasPercentageAnnoName]; hasKey {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 || intValue > 100 {
+			return nil, fmt.Errorf("%s annotation %s with invalid value %q: integer from 1 to 100 expected", resourceName, CanaryReplicasPercentageAnnoName, value)
+		}
+		replicasPercentage = intValue
+	}
+
+	bakeTime := defaultCanaryBakeTime
+	if value, hasKey := primary.Annotations[CanaryBakeTimeAnnoName]; hasKey {
+		durationValue, err := time.ParseDuration(value)
+		if err != nil || durationValue < 0 {
+			return nil, fmt.Errorf("%s annotation %s with invalid value %q: positive duration expected", resourceName, CanaryBakeTimeAnnoName, value)
+		}
+		bakeTime = durationValue
+	}
+
+	var restartsAllowed int32
+	if value, hasKey := primary.Annotations[CanaryRestartsAllowedAnnoName]; hasKey {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue < 0 {
+			return nil, fmt.Errorf("%s annotation %s with invalid value %q: positive or zero integer expected", resourceName, CanaryRestartsAllowedAnnoName, value)
+		}
+		restartsAllowed = int32(intValue)
+	}
+
+	canary := primary.DeepCopy()
+	canary.Name = primary.Name + canaryNameSuffix
+	canary.ResourceVersion = ""
+
+	replicas := int32(math.Ceil(float64(extractSpecReplicas(primary.Spec.Replicas)) * float64(replicasPercentage) / 100))
+	if replicas < 1 {
+		replicas = 1
+	}
+	canary.Spec.Replicas = &replicas
+
+	if canary.Spec.Selector == nil {
+		canary.Spec.Selector = &metav1.LabelSelector{}
+	}
+	if canary.Spec.Selector.MatchLabels == nil {
+		canary.Spec.Selector.MatchLabels = map[string]string{}
+	}
+	canary.Spec.Selector.MatchLabels[CanaryVariantLabelName] = canaryVariantLabelValue
+
+	if canary.Spec.Template.Labels == nil {
+		canary.Spec.Template.Labels = map[string]string{}
+	}
+	canary.Spec.Template.Labels[CanaryVariantLabelName] = canaryVariantLabelValue
+
+	if canary.Labels == nil {
+		canary.Labels = map[string]string{}
+	}
+	canary.Labels[CanaryVariantLabelName] = canaryVariantLabelValue
+
+	return &canarySpec{
+		Deployment:      canary,
+		BakeTime:        bakeTime,
+		RestartsAllowed: restartsAllowed,
+	}, nil
+}
+
+func (deployer *CanaryDeployer) deployCanary(ctx context.Context, spec *canarySpec) error {
+	// The canary left by the interrupted deploy process is replaced.
+	deployer.deleteCanary(ctx, spec.Deployment)
+	if err := deployer.waitCanaryDeleted(ctx, spec.Deployment); err != nil {
+		return fmt.Errorf("unable to delete previous canary: %s", err)
+	}
+
+	if _, err := deployer.Client.AppsV1().Deployments(spec.Deployment.Namespace).Create(ctx, spec.Deployment, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to create canary: %s", err)
+	}
+
+	data, err := yaml.Marshal(spec.Deployment)
+	if err != nil {
+		return err
+	}
+
+	resources, err := deployer.KubeClient.Build(bytes.NewBuffer(data), false)
+	if err != nil {
+		return fmt.Errorf("unable to build canary resource: %s", err)
+	}
+
+	if err := deployer.KubeClient.Wait(resources, deployer.Timeout); err != nil {
+		return err
+	}
+
+	return deployer.analyseCanary(ctx, spec)
+}
+
+// analyseCanary checks that the canary replicas stay ready and the canary containers are not restarted during the bake time.
+func (deployer *CanaryDeployer) analyseCanary(ctx context.Context, spec *canarySpec) error {
+	initialRestarts, err := deployer.getDeploymentRestarts(ctx, spec.Deployment)
+	if err != nil {
+		return err
+	}
+
+	bakeUntil := time.Now().Add(spec.BakeTime)
+	for {
+		deployment, err := deployer.Client.AppsV1().Deployments(spec.Deployment.Namespace).Get(ctx, spec.Deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to get canary: %s", err)
+		}
+
+		if deployment.Status.ReadyReplicas < *spec.Deployment.Spec.Replicas {
+			return fmt.Errorf("canary has %d of %d replicas ready", deployment.Status.ReadyReplicas, *spec.Deployment.Spec.Replicas)
+		}
+
+		restarts, err := deployer.getDeploymentRestarts(ctx, spec.Deployment)
+		if err != nil {
+			return err
+		}
+
+		if restarts-initialRestarts > spec.RestartsAllowed {
+			return fmt.Errorf("canary containers restarted %d times, allowed %d", restarts-initialRestarts, spec.RestartsAllowed)
+		}
+
+		left := time.Until(bakeUntil)
+		if left <= 0 {
+			logboek.Context(ctx).Default().LogF("Canary %s/%s is healthy, promoting\n", canaryDeploymentKindShort, spec.Deployment.Name)
+			return nil
+		}
+
+		logboek.Context(ctx).Default().LogF("Canary %s/%s is healthy, %s of bake time left\n", canaryDeploymentKindShort, spec.Deployment.Name, left.Round(time.Second))
+
+		if left > canaryAnalysisPollPeriod {
+			left = canaryAnalysisPollPeriod
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(left):
+		}
+	}
+}
+
+func (deployer *CanaryDeployer) deleteCanary(ctx context.Context, canary *appsv1.Deployment) {
+	propagationPolicy := metav1.DeletePropagationForeground
+	err := deployer.Client.AppsV1().Deployments(canary.Namespace).Delete(ctx, canary.Name, metav1.DeleteOptions{PropagationPolicy: &propagationPolicy})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logboek.Context(ctx).Warn().LogF("WARNING unable to delete canary %s/%s: %s\n", canaryDeploymentKindShort, canary.Name, err)
+	}
+}
+
+func (deployer *CanaryDeployer) getDeploymentRestarts(ctx context.Context, deployment *appsv1.Deployment) (int32, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return 0, fmt.Errorf("invalid canary selector: %s", err)
+	}
+
+	pods, err := deployer.Client.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, fmt.Errorf("unable to list canary pods: %s", err)
+	}
+
+	var restarts int32
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+	}
+
+	return restarts, nil
+}
+
+func isCanary(obj unstructured.Unstructured) (bool, error) {
+	value, hasKey := obj.GetAnnotations()[CanaryAnnoName]
+	if !hasKey {
+		return false, nil
+	}
+
+	canary, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s/%s annotation %s with invalid value %q: boolean expected", obj.GetKind(), obj.GetName(), CanaryAnnoName, value)
+	}
+
+	if canary && obj.GetKind() != "Deployment" {
+		return false, fmt.Errorf("%s/%s annotation %s is supported only for Deployment", obj.GetKind(), obj.GetName(), CanaryAnnoName)
+	}
+
+	return canary, nil
+}
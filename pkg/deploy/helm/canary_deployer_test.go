@@ -0,0 +1,128 @@
+package helm
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/werf/logboek"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const canaryTestManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: backend
+  annotations:
+    werf.io/canary: "true"
+    werf.io/canary-bake-time: "0s"
+spec:
+  replicas: 4
+  selector:
+    matchLabels:
+      app: backend
+  template:
+    metadata:
+      labels:
+        app: backend
+    spec:
+      containers:
+      - name: backend
+        image: backend:new
+`
+
+var _ = Describe("CanaryDeployer", func() {
+	var client *fake.Clientset
+	var deployer *CanaryDeployer
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "default"},
+		})
+		deployer = NewCanaryDeployer(newFakeKubeClient(), client, 0)
+	})
+
+	getCanary := func() error {
+		_, err := client.AppsV1().Deployments("default").Get(context.Background(), "backend-canary", metav1.GetOptions{})
+		return err
+	}
+
+	It("should promote the healthy canary and delete the canary Deployment", func() {
+		var createdCanary *appsv1.Deployment
+		client.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			createdCanary = action.(k8stesting.CreateAction).GetObject().(*appsv1.Deployment)
+			createdCanary.Status.ReadyReplicas = *createdCanary.Spec.Replicas
+			return false, nil, nil
+		})
+
+		Ω(deployer.Run(context.Background(), &DeployPlan{CanaryManifests: []string{canaryTestManifest}}, nil)).Should(Succeed())
+
+		Ω(createdCanary).ShouldNot(BeNil())
+		Ω(*createdCanary.Spec.Replicas).Should(Equal(int32(1)))
+		Ω(createdCanary.Spec.Selector.MatchLabels).Should(HaveKeyWithValue(CanaryVariantLabelName, canaryVariantLabelValue))
+		Ω(createdCanary.Spec.Template.Spec.Containers[0].Image).Should(Equal("backend:new"))
+		Ω(apierrors.IsNotFound(getCanary())).Should(BeTrue())
+	})
+
+	It("should roll back the unhealthy canary and fail", func() {
+		err := deployer.Run(context.Background(), &DeployPlan{CanaryManifests: []string{canaryTestManifest}}, nil)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("failed and rolled back"))
+		Ω(err.Error()).Should(ContainSubstring("canary has 0 of 1 replicas ready"))
+		Ω(apierrors.IsNotFound(getCanary())).Should(BeTrue())
+	})
+
+	It("should stop the analysis when the context is canceled", func() {
+		canary := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend-canary", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: new(int32), Selector: &metav1.LabelSelector{}},
+		}
+		_, err := client.AppsV1().Deployments("default").Create(context.Background(), canary, metav1.CreateOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(logboek.NewContext(context.Background(), logboek.DefaultLogger()))
+		cancel()
+
+		Ω(deployer.analyseCanary(ctx, &canarySpec{Deployment: canary, BakeTime: defaultCanaryBakeTime})).Should(MatchError(context.Canceled))
+	})
+
+	It("should skip the canary for the new Deployment", func() {
+		Ω(client.AppsV1().Deployments("default").Delete(context.Background(), "backend", metav1.DeleteOptions{})).Should(Succeed())
+
+		Ω(deployer.Run(context.Background(), &DeployPlan{CanaryManifests: []string{canaryTestManifest}}, nil)).Should(Succeed())
+		Ω(apierrors.IsNotFound(getCanary())).Should(BeTrue())
+	})
+})
+
+var _ = DescribeTable("newCanarySpec",
+	func(annotations map[string]string, replicas int32, expectedReplicas int32, expectedErr bool) {
+		primary := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend", Annotations: annotations},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		}
+
+		spec, err := newCanarySpec(primary)
+		if expectedErr {
+			Ω(err).Should(HaveOccurred())
+			return
+		}
+
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(spec.Deployment.Name).Should(Equal("backend-canary"))
+		Ω(*spec.Deployment.Spec.Replicas).Should(Equal(expectedReplicas))
+		Ω(spec.Deployment.Spec.Template.Labels).Should(HaveKeyWithValue(CanaryVariantLabelName, canaryVariantLabelValue))
+		Ω(*primary.Spec.Replicas).Should(Equal(replicas))
+	},
+	Entry("default percentage", nil, int32(20), int32(2), false),
+	Entry("at least one replica", nil, int32(3), int32(1), false),
+	Entry("custom percentage", map[string]string{CanaryReplicasPercentageAnnoName: "50"}, int32(5), int32(3), false),
+	Entry("invalid percentage", map[string]string{CanaryReplicasPercentageAnnoName: "150"}, int32(5), int32(0), true),
+	Entry("invalid bake time", map[string]string{CanaryBakeTimeAnnoName: "-1m"}, int32(5), int32(0), true),
+	Entry("invalid restarts allowed", map[string]string{CanaryRestartsAllowedAnnoName: "many"}, int32(5), int32(0), true),
+)
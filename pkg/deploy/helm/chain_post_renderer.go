@@ -0,0 +1,29 @@
+package helm
+
+import (
+	"bytes"
+
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// ChainPostRenderer passes the rendered manifests through the post-renderers in the specified order.
+type ChainPostRenderer struct {
+	PostRenderers []postrender.PostRenderer
+}
+
+func NewChainPostRenderer(postRenderers ...postrender.PostRenderer) *ChainPostRenderer {
+	return &ChainPostRenderer{PostRenderers: postRenderers}
+}
+
+func (pr *ChainPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	for _, postRenderer := range pr.PostRenderers {
+		modifiedManifests, err := postRenderer.Run(renderedManifests)
+		if err != nil {
+			return nil, err
+		}
+
+		renderedManifests = modifiedManifests
+	}
+
+	return renderedManifests, nil
+}
@@ -33,12 +33,13 @@ type BundleOptions struct {
 
 func NewBundle(ctx context.Context, dir string, helmEnvSettings *cli.EnvSettings, registryClientHandle *helm_v3.RegistryClientHandle, opts BundleOptions) *Bundle {
 	return &Bundle{
-		Dir:                            dir,
-		HelmEnvSettings:                helmEnvSettings,
-		RegistryClientHandle:           registryClientHandle,
-		BuildChartDependenciesOpts:     opts.BuildChartDependenciesOpts,
-		ChartExtenderServiceValuesData: helpers.NewChartExtenderServiceValuesData(),
-		ChartExtenderContextData:       helpers.NewChartExtenderContextData(ctx),
+		Dir:                                dir,
+		HelmEnvSettings:                    helmEnvSettings,
+		RegistryClientHandle:               registryClientHandle,
+		BuildChartDependenciesOpts:         opts.BuildChartDependenciesOpts,
+		ChartExtenderServiceValuesData:     helpers.NewChartExtenderServiceValuesData(),
+		ChartExtenderCommandLineValuesData: helpers.NewChartExtenderCommandLineValuesData(),
+		ChartExtenderContextData:           helpers.NewChartExtenderContextData(ctx),
 	}
 }
 
@@ -54,6 +55,7 @@ type Bundle struct {
 	BuildChartDependenciesOpts command_helpers.BuildChartDependenciesOptions
 
 	*helpers.ChartExtenderServiceValuesData
+	*helpers.ChartExtenderCommandLineValuesData
 	*helpers.ChartExtenderContextData
 }
 
@@ -96,6 +98,7 @@ func (bundle *Bundle) MakeValues(inputVals map[string]interface{}) (map[string]i
 	vals := make(map[string]interface{})
 
 	chartutil.CoalesceTables(vals, bundle.ServiceValues)
+	chartutil.CoalesceTables(vals, bundle.CommandLineValues)
 	chartutil.CoalesceTables(vals, inputVals)
 
 	data, err := yaml.Marshal(vals)
@@ -0,0 +1,61 @@
+package chart_extender
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const BundleBuildInfoFileName = "build_info.json"
+
+// BundleBuildInfo is the metadata of the sources the bundle images have been built from.
+// It is saved into the bundle on publish to allow rebuilding of the same images later.
+type BundleBuildInfo struct {
+	WerfVersion string `json:"werfVersion"`
+	Project     string `json:"project"`
+	Env         string `json:"env,omitempty"`
+
+	Commit                  string `json:"commit"`
+	RelativeToGitProjectDir string `json:"relativeToGitProjectDir,omitempty"`
+	WerfConfigPath          string `json:"werfConfigPath,omitempty"`
+	WerfConfigTemplatesDir  string `json:"werfConfigTemplatesDir,omitempty"`
+
+	Images map[string]BundleBuildInfoImage `json:"images"`
+}
+
+type BundleBuildInfoImage struct {
+	Name        string `json:"name"`
+	StageDigest string `json:"stageDigest"`
+	// ImageID is the digest of the built image config, which is the same for the reproduced image.
+	ImageID string `json:"imageID"`
+}
+
+func writeBundleBuildInfo(info *BundleBuildInfo, path string) error {
+	if data, err := json.MarshalIndent(info, "", "  "); err != nil {
+		return fmt.Errorf("unable to prepare %q data: %s", path, err)
+	} else if err := ioutil.WriteFile(path, append(data, []byte("\n")...), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write %q: %s", path, err)
+	} else {
+		return nil
+	}
+}
+
+// ReadBundleBuildInfo reads the build info of the bundle in the dir, nil is returned if the bundle has no build info.
+func ReadBundleBuildInfo(dir string) (*BundleBuildInfo, error) {
+	path := filepath.Join(dir, BundleBuildInfoFileName)
+
+	var info *BundleBuildInfo
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error accessing %q: %s", path, err)
+	} else if data, err := ioutil.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("error reading %q: %s", path, err)
+	} else if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("error unmarshalling json from %q: %s", path, err)
+	} else {
+		return info, nil
+	}
+}
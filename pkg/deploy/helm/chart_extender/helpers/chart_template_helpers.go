@@ -39,4 +39,16 @@ var ChartTemplateHelpers = `{{- define "_werf_image" -}}
 {{      tuple $context | include "_werf_image" }}
 {{-   end -}}
 {{- end -}}
+
+{{- define "werf_image_digest" -}}
+{{-   $context := last . -}}
+{{-   if $context.Values.werf.is_stub -}}
+{{      $context.Values.werf.stub_image }}
+{{-   else if eq (len .) 1 -}}
+{{      required "No digest of the image known: digests are known only for the images in the repo" $context.Values.werf.nameless_image_digest }}
+{{-   else -}}
+{{-     $name := index . 0 -}}
+{{      required (printf "No digest of the image '%s' known: digests are known only for the images in the repo" $name) (pluck $name $context.Values.werf.image_digest | first) }}
+{{-   end -}}
+{{- end -}}
 `
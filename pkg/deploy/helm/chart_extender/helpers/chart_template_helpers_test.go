@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// testChartExtender sets up only the werf template funcs and passes the values as is, the other chart extender methods are not used by the engine.
+type testChartExtender struct {
+	chart.ChartExtender
+}
+
+func (e *testChartExtender) MakeValues(inputVals map[string]interface{}) (map[string]interface{}, error) {
+	return inputVals, nil
+}
+
+func (e *testChartExtender) SetupTemplateFuncs(_ *template.Template, funcMap template.FuncMap) {
+	SetupIncludeWrapperFuncs(funcMap)
+	SetupWerfImageDeprecationFunc(context.Background(), funcMap)
+}
+
+func renderWerfTemplate(t *testing.T, tpl string, imageInfoGetters []*image.InfoGetter, opts ServiceValuesOptions) (string, error) {
+	serviceValues, err := GetServiceValues(context.Background(), "myproject", "registry.example.com/app", imageInfoGetters, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "myproject", Version: "1.0.0", APIVersion: chart.APIVersionV2},
+		Templates: []*chart.File{
+			{Name: "templates/_werf_helpers.tpl", Data: []byte(ChartTemplateHelpers)},
+			{Name: "templates/test.yaml", Data: []byte(tpl)},
+		},
+		ChartExtender: &testChartExtender{},
+	}
+
+	vals, err := chartutil.ToRenderValues(chrt, serviceValues, chartutil.ReleaseOptions{Name: "myproject"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := engine.Render(chrt, vals)
+	if err != nil {
+		return "", err
+	}
+
+	return rendered["myproject/templates/test.yaml"], nil
+}
+
+func TestWerfImageDigestTemplate(t *testing.T) {
+	imageInfoGetters := []*image.InfoGetter{
+		image.NewInfoGetter("backend", "registry.example.com/app:abc-1611836746968", "abc-1611836746968", "abc", "sha256:0123456789abcdef", ""),
+		image.NewInfoGetter("frontend", "registry.example.com/app:def-1611836746968", "def-1611836746968", "def", "", ""),
+	}
+
+	for _, tc := range []struct {
+		tpl, expected, expectedErr string
+		opts                       ServiceValuesOptions
+	}{
+		{tpl: `{{ werf_image_digest (list "backend" .) }}`, expected: "registry.example.com/app@sha256:0123456789abcdef"},
+		{tpl: `{{ include "werf_image_digest" (list "backend" .) }}`, expected: "registry.example.com/app@sha256:0123456789abcdef"},
+		{tpl: `{{ werf_image_digest (list "frontend" .) }}`, expectedErr: "No digest of the image 'frontend' known"},
+		{tpl: `{{ werf_image_digest (list "unknown" .) }}`, expectedErr: "No digest of the image 'unknown' known"},
+		{tpl: `{{ werf_image_digest (list .) }}`, expectedErr: "No digest of the image known"},
+		{tpl: `{{ werf_image_digest (list "backend" .) }}`, opts: ServiceValuesOptions{IsStub: true}, expected: "registry.example.com/app:TAG"},
+	} {
+		rendered, err := renderWerfTemplate(t, tc.tpl, imageInfoGetters, tc.opts)
+		if tc.expectedErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("%s: expected error %q, got %v (%q)", tc.tpl, tc.expectedErr, err, rendered)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.tpl, err)
+			continue
+		}
+
+		if strings.TrimSpace(rendered) != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.tpl, tc.expected, rendered)
+		}
+	}
+}
+
+func TestWerfImageDigestTemplate_NamelessImage(t *testing.T) {
+	rendered, err := renderWerfTemplate(t, `{{ werf_image_digest (list .) }}`, []*image.InfoGetter{
+		image.NewInfoGetter("", "registry.example.com/app:abc-1611836746968", "abc-1611836746968", "abc", "sha256:0123456789abcdef", ""),
+	}, ServiceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "registry.example.com/app@sha256:0123456789abcdef"; strings.TrimSpace(rendered) != expected {
+		t.Errorf("expected %q, got %q", expected, rendered)
+	}
+}
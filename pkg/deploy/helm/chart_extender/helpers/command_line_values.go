@@ -0,0 +1,248 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxKeyPathIndex limits the list index in the key path the same way as helm does, to avoid allocation of huge lists.
+const maxKeyPathIndex = 65536
+
+func NewChartExtenderCommandLineValuesData() *ChartExtenderCommandLineValuesData {
+	return &ChartExtenderCommandLineValuesData{CommandLineValues: make(map[string]interface{})}
+}
+
+// ChartExtenderCommandLineValuesData holds the values passed with --set-json and --set-literal options.
+// These values are not supported by the helm values options, so werf merges them on top of the user values.
+type ChartExtenderCommandLineValuesData struct {
+	CommandLineValues map[string]interface{}
+}
+
+func (d *ChartExtenderCommandLineValuesData) GetCommandLineValues() map[string]interface{} {
+	return d.CommandLineValues
+}
+
+func (d *ChartExtenderCommandLineValuesData) SetCommandLineValues(vals map[string]interface{}) {
+	d.CommandLineValues = vals
+}
+
+// ParseCommandLineValues parses --set-json values in the key1=jsonval1,key2=jsonval2 format and
+// --set-literal values in the key=val format, where the whole val after the first "=" is the string value.
+// The keys have the same format as the helm --set keys: nested keys are separated with ".", list items are specified with [INDEX].
+// --set-literal values are applied after --set-json values as in helm.
+func ParseCommandLineValues(setJson, setLiteral []string) (map[string]interface{}, error) {
+	vals := make(map[string]interface{})
+
+	for _, value := range setJson {
+		if err := parseSetJsonInto(value, vals); err != nil {
+			return nil, fmt.Errorf("failed parsing --set-json data %q: %s", value, err)
+		}
+	}
+
+	for _, value := range setLiteral {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("failed parsing --set-literal data %q: key %q has no value", value, parts[0])
+		}
+
+		if err := setKeyPathValue(vals, parts[0], parts[1]); err != nil {
+			return nil, fmt.Errorf("failed parsing --set-literal data %q: %s", value, err)
+		}
+	}
+
+	return vals, nil
+}
+
+func parseSetJsonInto(data string, vals map[string]interface{}) error {
+	for rest := data; rest != ""; {
+		parts := strings.SplitN(rest, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("key %q has no value", parts[0])
+		}
+		keyPath, jsonData := parts[0], parts[1]
+
+		decoder := json.NewDecoder(strings.NewReader(jsonData))
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("key %q: invalid JSON value: %s", keyPath, err)
+		}
+
+		rest = strings.TrimSpace(jsonData[decoder.InputOffset():])
+		if rest != "" {
+			if !strings.HasPrefix(rest, ",") {
+				return fmt.Errorf("key %q: unexpected data %q after JSON value", keyPath, rest)
+			}
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+		}
+
+		if err := setKeyPathValue(vals, keyPath, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type keyPathElement struct {
+	Key     string
+	Index   int
+	IsIndex bool
+
+	// Path is the key path up to and including the element, used in errors
+	Path string
+}
+
+func parseKeyPath(keyPath string) ([]keyPathElement, error) {
+	var elements []keyPathElement
+	var key []rune
+	var keyTerminated bool
+
+	addKey := func(path string) error {
+		if len(key) == 0 {
+			if keyTerminated {
+				keyTerminated = false
+				return nil
+			}
+			return fmt.Errorf("key %q: empty key in the key path", path)
+		}
+
+		elements = append(elements, keyPathElement{Key: string(key), Path: path})
+		key = nil
+
+		return nil
+	}
+
+	runes := []rune(keyPath)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			key = append(key, runes[i])
+		case '.':
+			if err := addKey(string(runes[:i])); err != nil {
+				return nil, err
+			}
+		case '[':
+			if len(key) != 0 || len(elements) == 0 {
+				if err := addKey(string(runes[:i])); err != nil {
+					return nil, err
+				}
+			}
+
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("key %q: unclosed list index", keyPath)
+			}
+
+			path := string(runes[:end+1])
+			index, err := strconv.Atoi(string(runes[i+1 : end]))
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid list index %q", path, string(runes[i+1:end]))
+			} else if index < 0 {
+				return nil, fmt.Errorf("key %q: negative list index %d not allowed", path, index)
+			} else if index > maxKeyPathIndex {
+				return nil, fmt.Errorf("key %q: list index %d exceeds the maximum %d", path, index, maxKeyPathIndex)
+			}
+
+			elements = append(elements, keyPathElement{Index: index, IsIndex: true, Path: path})
+			keyTerminated = true
+			i = end
+		default:
+			if keyTerminated {
+				return nil, fmt.Errorf("key %q: unexpected %q after list index", string(runes[:i+1]), r)
+			}
+			key = append(key, r)
+		}
+	}
+
+	if err := addKey(keyPath); err != nil {
+		return nil, err
+	}
+
+	return elements, nil
+}
+
+func setKeyPathValue(vals map[string]interface{}, keyPath string, value interface{}) error {
+	elements, err := parseKeyPath(keyPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = setElementsValue(vals, "", elements, value)
+	return err
+}
+
+func setElementsValue(node interface{}, nodePath string, elements []keyPathElement, value interface{}) (interface{}, error) {
+	if len(elements) == 0 {
+		return value, nil
+	}
+
+	element := elements[0]
+
+	if element.IsIndex {
+		var list []interface{}
+		switch n := node.(type) {
+		case nil:
+		case []interface{}:
+			list = n
+		default:
+			return nil, fmt.Errorf("key %q: %q is %s, not a list", element.Path, nodePath, describeValueType(node))
+		}
+
+		if element.Index >= len(list) {
+			newList := make([]interface{}, element.Index+1)
+			copy(newList, list)
+			list = newList
+		}
+
+		item, err := setElementsValue(list[element.Index], element.Path, elements[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[element.Index] = item
+
+		return list, nil
+	}
+
+	var m map[string]interface{}
+	switch n := node.(type) {
+	case nil:
+		m = make(map[string]interface{})
+	case map[string]interface{}:
+		m = n
+	default:
+		return nil, fmt.Errorf("key %q: %q is %s, not a map", element.Path, nodePath, describeValueType(node))
+	}
+
+	item, err := setElementsValue(m[element.Key], element.Path, elements[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	m[element.Key] = item
+
+	return m, nil
+}
+
+func describeValueType(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "a map"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
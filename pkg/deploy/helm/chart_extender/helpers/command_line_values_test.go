@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandLineValues(t *testing.T) {
+	vals, err := ParseCommandLineValues(
+		[]string{`app.replicas=3,app.ports=[80,443]`, `app.labels={"tier":"web"},list[1]=true`},
+		[]string{`app.tier=a,b=c`, `app\.name=x`},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"app": map[string]interface{}{
+			"replicas": float64(3),
+			"ports":    []interface{}{float64(80), float64(443)},
+			"labels":   map[string]interface{}{"tier": "web"},
+			"tier":     "a,b=c",
+		},
+		"app.name": "x",
+		"list":     []interface{}{nil, true},
+	}
+	if !reflect.DeepEqual(vals, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, vals)
+	}
+}
+
+func TestParseCommandLineValuesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		setJson, setLiteral []string
+		expectedErr         string
+	}{
+		{setJson: []string{"a"}, expectedErr: `key "a" has no value`},
+		{setJson: []string{"a={"}, expectedErr: `key "a": invalid JSON value`},
+		{setJson: []string{"a=1 2"}, expectedErr: `key "a": unexpected data "2" after JSON value`},
+		{setJson: []string{"a=1,a.b=2"}, expectedErr: `key "a.b": "a" is a number, not a map`},
+		{setJson: []string{"a[-1]=1"}, expectedErr: `negative list index -1 not allowed`},
+		{setJson: []string{"a[70000]=1"}, expectedErr: `exceeds the maximum`},
+		{setLiteral: []string{"a..b=1"}, expectedErr: `empty key in the key path`},
+		{setLiteral: []string{"a"}, expectedErr: `failed parsing --set-literal data "a": key "a" has no value`},
+	} {
+		_, err := ParseCommandLineValues(tc.setJson, tc.setLiteral)
+		if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%v %v: expected error containing %q, got %v", tc.setJson, tc.setLiteral, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestParseCommandLineValuesLiteralAfterJson(t *testing.T) {
+	vals, err := ParseCommandLineValues([]string{"a=1"}, []string{"a=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vals["a"] != "2" {
+		t.Fatalf("expected --set-literal value to override --set-json value, got %#v", vals["a"])
+	}
+}
@@ -15,7 +15,7 @@ func SetupIncludeWrapperFuncs(funcMap template.FuncMap) {
 		}
 	}
 
-	for _, name := range []string{"werf_image"} {
+	for _, name := range []string{"werf_image", "werf_image_digest"} {
 		setupIncludeWrapperFunc(name)
 	}
 }
@@ -47,7 +47,7 @@ func LoadChartSecretValueFiles(chartDir string, secretDirFiles []*chart.ChartExt
 	var res map[string]interface{}
 
 	for _, file := range secretDirFiles {
-		decodedData, err := encoder.DecryptYamlData(file.Data)
+		decodedData, err := encoder.DecryptYamlFile(getSecretFilePath(chartDir, file), file.Data)
 		if err != nil {
 			return nil, fmt.Errorf("cannot decode file %q secret data: %s", filepath.Join(chartDir, file.Name), err)
 		}
@@ -71,7 +71,7 @@ func LoadChartSecretDirFilesData(chartDir string, secretFiles []*chart.ChartExte
 			continue
 		}
 
-		decodedData, err := encoder.Decrypt([]byte(strings.TrimRightFunc(string(file.Data), unicode.IsSpace)))
+		decodedData, err := encoder.DecryptFile(getSecretFilePath(chartDir, file), []byte(strings.TrimRightFunc(string(file.Data), unicode.IsSpace)))
 		if err != nil {
 			return nil, fmt.Errorf("error decoding %s: %s", filepath.Join(chartDir, file.Name), err)
 		}
@@ -82,3 +82,12 @@ func LoadChartSecretDirFilesData(chartDir string, secretFiles []*chart.ChartExte
 
 	return res, nil
 }
+
+// getSecretFilePath returns the path of the chart file or the custom secret values file, which is used by sops to select the creation rule.
+func getSecretFilePath(chartDir string, file *chart.ChartExtenderBufferedFile) string {
+	if filepath.IsAbs(file.Name) {
+		return file.Name
+	}
+
+	return filepath.Join(chartDir, file.Name)
+}
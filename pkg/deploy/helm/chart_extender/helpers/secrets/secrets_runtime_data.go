@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io/ioutil"
 
+	"github.com/werf/werf/pkg/deploy/secret_values_provider"
 	"github.com/werf/werf/pkg/deploy/secrets_manager"
 	"github.com/werf/werf/pkg/giterminism_manager"
 	"github.com/werf/werf/pkg/secret"
 	"github.com/werf/werf/pkg/util/secretvalues"
 	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 )
 
 type SecretsRuntimeData struct {
@@ -24,6 +26,12 @@ func NewSecretsRuntimeData() *SecretsRuntimeData {
 	}
 }
 
+// secretValuesSource is either the encrypted secret values file or the external secret manager uri.
+type secretValuesSource struct {
+	File *chart.ChartExtenderBufferedFile
+	URI  string
+}
+
 type DecodeAndLoadSecretsOptions struct {
 	GiterminismManager      giterminism_manager.Interface
 	CustomSecretValueFiles  []string
@@ -34,11 +42,18 @@ func (secretsRuntimeData *SecretsRuntimeData) DecodeAndLoadSecrets(ctx context.C
 	secretDirFiles := GetSecretDirFiles(loadedChartFiles)
 
 	var loadedSecretValuesFiles []*chart.ChartExtenderBufferedFile
+	var secretValuesSources []*secretValuesSource
 	if defaultSecretValues := GetDefaultSecretValuesFile(chartDir, loadedChartFiles); defaultSecretValues != nil {
 		loadedSecretValuesFiles = append(loadedSecretValuesFiles, defaultSecretValues)
+		secretValuesSources = append(secretValuesSources, &secretValuesSource{File: defaultSecretValues})
 	}
 
 	for _, customSecretValuesFileName := range opts.CustomSecretValueFiles {
+		if secret_values_provider.IsSecretValuesURI(customSecretValuesFileName) {
+			secretValuesSources = append(secretValuesSources, &secretValuesSource{URI: customSecretValuesFileName})
+			continue
+		}
+
 		file := &chart.ChartExtenderBufferedFile{Name: customSecretValuesFileName}
 
 		if opts.LoadFromLocalFilesystem {
@@ -58,6 +73,7 @@ func (secretsRuntimeData *SecretsRuntimeData) DecodeAndLoadSecrets(ctx context.C
 		}
 
 		loadedSecretValuesFiles = append(loadedSecretValuesFiles, file)
+		secretValuesSources = append(secretValuesSources, &secretValuesSource{File: file})
 	}
 
 	var encoder *secret.YamlEncoder
@@ -80,13 +96,31 @@ func (secretsRuntimeData *SecretsRuntimeData) DecodeAndLoadSecrets(ctx context.C
 		}
 	}
 
-	if len(loadedSecretValuesFiles) > 0 {
-		if values, err := LoadChartSecretValueFiles(chartDir, loadedSecretValuesFiles, encoder); err != nil {
-			return fmt.Errorf("error loading secret value files: %s", err)
-		} else {
-			secretsRuntimeData.DecodedSecretValues = values
-			secretsRuntimeData.SecretValuesToMask = append(secretsRuntimeData.SecretValuesToMask, secretvalues.ExtractSecretValuesFromMap(values)...)
+	if len(secretValuesSources) > 0 {
+		var values map[string]interface{}
+
+		// Secret values files and external secret managers values are merged in the specified order, the latter take precedence.
+		for _, source := range secretValuesSources {
+			var sourceValues map[string]interface{}
+			if source.URI != "" {
+				if vals, err := secret_values_provider.GetSecretValues(ctx, source.URI); err != nil {
+					return err
+				} else {
+					sourceValues = vals
+				}
+			} else {
+				if vals, err := LoadChartSecretValueFiles(chartDir, []*chart.ChartExtenderBufferedFile{source.File}, encoder); err != nil {
+					return fmt.Errorf("error loading secret value files: %s", err)
+				} else {
+					sourceValues = vals
+				}
+			}
+
+			values = chartutil.CoalesceTables(sourceValues, values)
 		}
+
+		secretsRuntimeData.DecodedSecretValues = values
+		secretsRuntimeData.SecretValuesToMask = append(secretsRuntimeData.SecretValuesToMask, secretvalues.ExtractSecretValuesFromMap(values)...)
 	}
 
 	return nil
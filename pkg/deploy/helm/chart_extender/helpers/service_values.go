@@ -11,6 +11,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/werf/logboek"
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -39,6 +40,12 @@ type ServiceValuesOptions struct {
 
 	SetDockerConfigJsonValue bool
 	DockerConfigPath         string
+	// PullTokenSecret is the name of the image pull secret with the short-lived pull token of the repo
+	PullTokenSecret string
+	// ImagePullSecret is the name of the image pull secret with the repo credentials
+	ImagePullSecret string
+	// Dependencies are the resolved images of the other werf projects declared in the meta.dependencies directive
+	Dependencies map[string]config.DependencyTemplateData
 }
 
 func GetServiceValues(ctx context.Context, projectName string, repo string, imageInfoGetters []*image.InfoGetter, opts ServiceValuesOptions) (map[string]interface{}, error) {
@@ -55,6 +62,9 @@ func GetServiceValues(ctx context.Context, projectName string, repo string, imag
 		"repo":    repo,
 		"image":   map[string]interface{}{},
 		"tag":     map[string]interface{}{},
+
+		"stage_digest": map[string]interface{}{},
+		"image_digest": map[string]interface{}{},
 	}
 
 	if opts.Env != "" {
@@ -69,6 +79,14 @@ func GetServiceValues(ctx context.Context, projectName string, repo string, imag
 		werfInfo["namespace"] = opts.Namespace
 	}
 
+	if opts.ImagePullSecret != "" {
+		werfInfo["image_pull_secret"] = opts.ImagePullSecret
+	}
+
+	if opts.PullTokenSecret != "" {
+		werfInfo["pull_token_secret"] = opts.PullTokenSecret
+	}
+
 	if opts.IsStub {
 		stubTag := "TAG"
 		stubImage := fmt.Sprintf("%s:%s", repo, stubTag)
@@ -78,6 +96,8 @@ func GetServiceValues(ctx context.Context, projectName string, repo string, imag
 		for _, name := range opts.StubImagesNames {
 			werfInfo["image"].(map[string]interface{})[name] = stubImage
 			werfInfo["tag"].(map[string]interface{})[name] = stubTag
+			werfInfo["stage_digest"].(map[string]interface{})[name] = image.StubStageDigest
+			werfInfo["image_digest"].(map[string]interface{})[name] = stubImage
 		}
 	}
 
@@ -85,10 +105,30 @@ func GetServiceValues(ctx context.Context, projectName string, repo string, imag
 		if imageInfoGetter.IsNameless() {
 			werfInfo["is_nameless_image"] = true
 			werfInfo["nameless_image"] = imageInfoGetter.GetName()
+			if nameWithDigest := imageInfoGetter.GetNameWithDigest(); nameWithDigest != "" {
+				werfInfo["nameless_image_digest"] = nameWithDigest
+			}
 		} else {
 			werfInfo["image"].(map[string]interface{})[imageInfoGetter.GetWerfImageName()] = imageInfoGetter.GetName()
 			werfInfo["tag"].(map[string]interface{})[imageInfoGetter.GetWerfImageName()] = imageInfoGetter.GetTag()
+			werfInfo["stage_digest"].(map[string]interface{})[imageInfoGetter.GetWerfImageName()] = imageInfoGetter.GetStageDigest()
+			if nameWithDigest := imageInfoGetter.GetNameWithDigest(); nameWithDigest != "" {
+				werfInfo["image_digest"].(map[string]interface{})[imageInfoGetter.GetWerfImageName()] = nameWithDigest
+			}
+		}
+	}
+
+	if len(opts.Dependencies) > 0 {
+		dependencies := map[string]interface{}{}
+		for name, dep := range opts.Dependencies {
+			dependencies[name] = map[string]interface{}{
+				"image":  dep.Image,
+				"repo":   dep.Repo,
+				"tag":    dep.Tag,
+				"digest": dep.Digest,
+			}
 		}
+		werfInfo["dependencies"] = dependencies
 	}
 
 	res := map[string]interface{}{
@@ -97,7 +137,7 @@ func GetServiceValues(ctx context.Context, projectName string, repo string, imag
 	}
 
 	if opts.SetDockerConfigJsonValue {
-		if err := writeDockerConfigJsonValue(ctx, res, opts.DockerConfigPath); err != nil {
+		if err := writeDockerConfigJsonValue(ctx, res, opts); err != nil {
 			return nil, fmt.Errorf("error writing docker config value: %s", err)
 		}
 	}
@@ -128,13 +168,17 @@ func GetBundleServiceValues(ctx context.Context, opts ServiceValuesOptions) (map
 		werfInfo["namespace"] = opts.Namespace
 	}
 
+	if opts.PullTokenSecret != "" {
+		werfInfo["pull_token_secret"] = opts.PullTokenSecret
+	}
+
 	res := map[string]interface{}{
 		"werf":   werfInfo,
 		"global": globalInfo,
 	}
 
 	if opts.SetDockerConfigJsonValue {
-		if err := writeDockerConfigJsonValue(ctx, res, opts.DockerConfigPath); err != nil {
+		if err := writeDockerConfigJsonValue(ctx, res, opts); err != nil {
 			return nil, fmt.Errorf("error writing docker config value: %s", err)
 		}
 	}
@@ -145,7 +189,8 @@ func GetBundleServiceValues(ctx context.Context, opts ServiceValuesOptions) (map
 	return res, nil
 }
 
-func writeDockerConfigJsonValue(ctx context.Context, values map[string]interface{}, dockerConfigPath string) error {
+func writeDockerConfigJsonValue(ctx context.Context, values map[string]interface{}, opts ServiceValuesOptions) error {
+	dockerConfigPath := opts.DockerConfigPath
 	if dockerConfigPath == "" {
 		dockerConfigPath = filepath.Join(os.Getenv("HOME"), ".docker")
 	}
@@ -171,3 +216,8 @@ func writeDockerConfigJsonValue(ctx context.Context, values map[string]interface
 
 	return nil
 }
+
+// GetPullTokenSecretName returns the name of the release image pull secret with the short-lived pull token of the repo.
+func GetPullTokenSecretName(releaseName string) string {
+	return fmt.Sprintf("%s-werf-pull-token", releaseName)
+}
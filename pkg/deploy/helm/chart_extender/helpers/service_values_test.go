@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestGetServiceValues_ImageDigest(t *testing.T) {
+	vals, err := GetServiceValues(context.Background(), "myproject", "registry.example.com/app", []*image.InfoGetter{
+		image.NewInfoGetter("backend", "registry.example.com/app:abc-1611836746968", "abc-1611836746968", "abc", "registry.example.com/app@sha256:0123456789abcdef", ""),
+		// the repo digest of the image is unknown, e.g. for the image copied into the final repo
+		image.NewInfoGetter("frontend", "registry.example.com/app:def-1611836746968", "def-1611836746968", "def", "", ""),
+	}, ServiceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	werfVals := vals["werf"].(map[string]interface{})
+	if expected := map[string]interface{}{"backend": "registry.example.com/app@sha256:0123456789abcdef"}; !reflect.DeepEqual(werfVals["image_digest"], expected) {
+		t.Errorf("expected image_digest %v, got %v", expected, werfVals["image_digest"])
+	}
+	if _, ok := werfVals["nameless_image_digest"]; ok {
+		t.Errorf("expected no nameless_image_digest without the nameless image, got %v", werfVals["nameless_image_digest"])
+	}
+}
+
+func TestGetServiceValues_NamelessImageDigest(t *testing.T) {
+	vals, err := GetServiceValues(context.Background(), "myproject", "registry.example.com/app", []*image.InfoGetter{
+		image.NewInfoGetter("", "registry.example.com/app:abc-1611836746968", "abc-1611836746968", "abc", "sha256:0123456789abcdef", ""),
+	}, ServiceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	werfVals := vals["werf"].(map[string]interface{})
+	if expected := "registry.example.com/app@sha256:0123456789abcdef"; werfVals["nameless_image_digest"] != expected {
+		t.Errorf("expected nameless_image_digest %q, got %v", expected, werfVals["nameless_image_digest"])
+	}
+	if len(werfVals["image_digest"].(map[string]interface{})) != 0 {
+		t.Errorf("expected empty image_digest for the nameless image, got %v", werfVals["image_digest"])
+	}
+}
+
+func TestGetServiceValues_StubImageDigest(t *testing.T) {
+	vals, err := GetServiceValues(context.Background(), "myproject", "registry.example.com/app", nil, ServiceValuesOptions{
+		IsStub:          true,
+		StubImagesNames: []string{"backend"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	werfVals := vals["werf"].(map[string]interface{})
+	if expected := map[string]interface{}{"backend": "registry.example.com/app:TAG"}; !reflect.DeepEqual(werfVals["image_digest"], expected) {
+		t.Errorf("expected stub image_digest %v, got %v", expected, werfVals["image_digest"])
+	}
+}
+
+func TestGetServiceValues_StageDigest(t *testing.T) {
+	vals, err := GetServiceValues(context.Background(), "myproject", "registry.example.com/app", []*image.InfoGetter{
+		image.NewInfoGetter("backend", "registry.example.com/app:abc-1611836746968", "abc-1611836746968", "abc", "", ""),
+		image.NewInfoGetter("frontend", "registry.example.com/app:def-1611836746968", "def-1611836746968", "def", "", ""),
+	}, ServiceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	werfVals := vals["werf"].(map[string]interface{})
+	if expected := map[string]interface{}{"backend": "abc", "frontend": "def"}; !reflect.DeepEqual(werfVals["stage_digest"], expected) {
+		t.Errorf("expected stage_digest %v, got %v", expected, werfVals["stage_digest"])
+	}
+}
+
+func TestGetServiceValues_StubStageDigest(t *testing.T) {
+	vals, err := GetServiceValues(context.Background(), "myproject", "registry.example.com/app", nil, ServiceValuesOptions{
+		IsStub:          true,
+		StubImagesNames: []string{"backend", "frontend"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	werfVals := vals["werf"].(map[string]interface{})
+	if expected := map[string]interface{}{"backend": image.StubStageDigest, "frontend": image.StubStageDigest}; !reflect.DeepEqual(werfVals["stage_digest"], expected) {
+		t.Errorf("expected stub stage_digest %v, got %v", expected, werfVals["stage_digest"])
+	}
+}
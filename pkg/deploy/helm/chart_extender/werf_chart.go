@@ -53,8 +53,9 @@ func NewWerfChart(ctx context.Context, giterminismManager giterminism_manager.In
 
 		extraAnnotationsAndLabelsPostRenderer: helm.NewExtraAnnotationsAndLabelsPostRenderer(nil, nil),
 
-		ChartExtenderServiceValuesData: helpers.NewChartExtenderServiceValuesData(),
-		ChartExtenderContextData:       helpers.NewChartExtenderContextData(ctx),
+		ChartExtenderServiceValuesData:     helpers.NewChartExtenderServiceValuesData(),
+		ChartExtenderCommandLineValuesData: helpers.NewChartExtenderCommandLineValuesData(),
+		ChartExtenderContextData:           helpers.NewChartExtenderContextData(ctx),
 	}
 
 	wc.extraAnnotationsAndLabelsPostRenderer.Add(opts.ExtraAnnotations, opts.ExtraLabels)
@@ -83,9 +84,11 @@ type WerfChart struct {
 
 	extraAnnotationsAndLabelsPostRenderer *helm.ExtraAnnotationsAndLabelsPostRenderer
 	werfConfig                            *config.WerfConfig
+	bundleBuildInfo                       *BundleBuildInfo
 
 	*secrets.SecretsRuntimeData
 	*helpers.ChartExtenderServiceValuesData
+	*helpers.ChartExtenderCommandLineValuesData
 	*helpers.ChartExtenderContextData
 }
 
@@ -130,7 +133,9 @@ func (wc *WerfChart) ChartDependenciesLoaded() error {
 func (wc *WerfChart) makeValues(inputVals map[string]interface{}, withSecrets bool) (map[string]interface{}, error) {
 	vals := make(map[string]interface{})
 
+	// NOTE: the values coalesced first take precedence, so the command line values override the secret values and values files
 	chartutil.CoalesceTables(vals, wc.ServiceValues) // NOTE: service values will not be saved into the marshalled release
+	chartutil.CoalesceTables(vals, wc.CommandLineValues)
 
 	if withSecrets {
 		chartutil.CoalesceTables(vals, wc.SecretsRuntimeData.DecodedSecretValues)
@@ -235,11 +240,18 @@ func (wc *WerfChart) SetWerfConfig(werfConfig *config.WerfConfig) error {
 		"project.werf.io/name": werfConfig.Meta.Project,
 	}, nil)
 
+	wc.extraAnnotationsAndLabelsPostRenderer.SetTrackingConfig(werfConfig.Meta.Deploy.Tracking)
+
 	wc.werfConfig = werfConfig
 
 	return nil
 }
 
+// SetBundleBuildInfo sets the build info, which will be saved into the bundle created by the CreateNewBundle.
+func (wc *WerfChart) SetBundleBuildInfo(info *BundleBuildInfo) {
+	wc.bundleBuildInfo = info
+}
+
 func (wc *WerfChart) SetEnv(env string) error {
 	wc.extraAnnotationsAndLabelsPostRenderer.Add(map[string]string{
 		"project.werf.io/env": env,
@@ -366,5 +378,11 @@ func (wc *WerfChart) CreateNewBundle(ctx context.Context, destDir string, inputV
 		}
 	}
 
+	if wc.bundleBuildInfo != nil {
+		if err := writeBundleBuildInfo(wc.bundleBuildInfo, filepath.Join(destDir, BundleBuildInfoFileName)); err != nil {
+			return nil, err
+		}
+	}
+
 	return NewBundle(ctx, destDir, wc.HelmEnvSettings, wc.RegistryClientHandle, BundleOptions{BuildChartDependenciesOpts: wc.BuildChartDependenciesOpts}), nil
 }
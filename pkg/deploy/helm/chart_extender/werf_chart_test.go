@@ -0,0 +1,46 @@
+package chart_extender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers/secrets"
+)
+
+func TestWerfChartMakeValuesCommandLineValuesPrecedence(t *testing.T) {
+	wc := &WerfChart{
+		SecretsRuntimeData:                 &secrets.SecretsRuntimeData{DecodedSecretValues: map[string]interface{}{"key": "secret", "secretKey": "secret"}},
+		ChartExtenderServiceValuesData:     &helpers.ChartExtenderServiceValuesData{ServiceValues: map[string]interface{}{"werf": map[string]interface{}{"env": "test"}}},
+		ChartExtenderCommandLineValuesData: &helpers.ChartExtenderCommandLineValuesData{CommandLineValues: map[string]interface{}{"key": "cli"}},
+		ChartExtenderContextData:           helpers.NewChartExtenderContextData(context.Background()),
+	}
+
+	vals, err := wc.MakeValues(map[string]interface{}{"key": "file", "fileKey": "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{"key": "cli", "secretKey": "secret", "fileKey": "file"} {
+		if vals[key] != expected {
+			t.Errorf("%s: expected %q, got %#v", key, expected, vals[key])
+		}
+	}
+}
+
+func TestBundleMakeValuesCommandLineValuesPrecedence(t *testing.T) {
+	bundle := &Bundle{
+		ChartExtenderServiceValuesData:     helpers.NewChartExtenderServiceValuesData(),
+		ChartExtenderCommandLineValuesData: &helpers.ChartExtenderCommandLineValuesData{CommandLineValues: map[string]interface{}{"key": "cli"}},
+		ChartExtenderContextData:           helpers.NewChartExtenderContextData(context.Background()),
+	}
+
+	vals, err := bundle.MakeValues(map[string]interface{}{"key": "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vals["key"] != "cli" {
+		t.Fatalf("expected the command line value, got %#v", vals["key"])
+	}
+}
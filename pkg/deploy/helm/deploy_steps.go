@@ -0,0 +1,118 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// DeployPlan contains the rendered release manifests deployed by the werf deploy steps.
+type DeployPlan struct {
+	BeforeHooksManifests []string
+	// WavesManifests are the release manifests by the werf.io/deploy-wave annotation value
+	WavesManifests map[int][]string
+	// CanaryManifests are the Deployments annotated with werf.io/canary=true
+	CanaryManifests []string
+}
+
+// DeployStep changes the cluster before the new release revision is recorded.
+type DeployStep interface {
+	// Run deploys the resources of the plan, the partial changes are reverted by the step itself if Run fails.
+	// The deployedRelease is the last deployed revision of the release or nil.
+	Run(ctx context.Context, plan *DeployPlan, deployedRelease *release.Release) error
+	// Rollback reverts the changes of the successful Run when one of the next steps fails.
+	Rollback(ctx context.Context) error
+}
+
+// DeploySteps runs the deploy steps inside the helm install or upgrade action right before the pending release revision is recorded:
+// after the chart is rendered by the werf post-renderer, under the release lock held by the command, but before the hooks and the regular deploy stage.
+// The release revision is not recorded if any step fails, the changes of the previous steps are reverted.
+type DeploySteps struct {
+	ctx          context.Context
+	postRenderer *ExtraAnnotationsAndLabelsPostRenderer
+	steps        []DeployStep
+}
+
+func NewDeploySteps(ctx context.Context, postRenderer *ExtraAnnotationsAndLabelsPostRenderer) *DeploySteps {
+	return &DeploySteps{
+		ctx:          ctx,
+		postRenderer: postRenderer,
+	}
+}
+
+func (s *DeploySteps) Add(step DeployStep) *DeploySteps {
+	s.steps = append(s.steps, step)
+	return s
+}
+
+// Attach makes the releases storage run the deploy steps when the pending install or upgrade revision is recorded.
+func (s *DeploySteps) Attach(releases *storage.Storage) {
+	releases.Driver = &deployStepsDriver{
+		Driver:   releases.Driver,
+		releases: releases,
+		steps:    s,
+	}
+}
+
+func (s *DeploySteps) Run(deployedRelease *release.Release) error {
+	plan := s.postRenderer.GetDeployPlan()
+	if plan == nil {
+		return fmt.Errorf("deploy plan is not available: chart is not rendered by the werf post-renderer")
+	}
+
+	for i, step := range s.steps {
+		if err := step.Run(s.ctx, plan, deployedRelease); err != nil {
+			if rollbackErr := s.rollback(s.steps[:i]); rollbackErr != nil {
+				return fmt.Errorf("%s\nrollback failed: %s", err, rollbackErr)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *DeploySteps) rollback(steps []DeployStep) error {
+	var errs []string
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := steps[i].Rollback(s.ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// deployStepsDriver runs the deploy steps before the pending install or upgrade revision is created in the underlying driver.
+// The revisions created by the rollback and the revisions updates are passed to the underlying driver as is.
+type deployStepsDriver struct {
+	driver.Driver
+	releases *storage.Storage
+	steps    *DeploySteps
+}
+
+func (d *deployStepsDriver) Create(key string, rls *release.Release) error {
+	if rls.Info != nil && (rls.Info.Status == release.StatusPendingInstall || rls.Info.Status == release.StatusPendingUpgrade) {
+		deployedRelease, err := d.releases.Deployed(rls.Name)
+		if errors.Is(err, driver.ErrNoDeployedReleases) {
+			deployedRelease = nil
+		} else if err != nil {
+			return fmt.Errorf("unable to get deployed revision of the release %q: %s", rls.Name, err)
+		}
+
+		if err := d.steps.Run(deployedRelease); err != nil {
+			return err
+		}
+	}
+
+	return d.Driver.Create(key, rls)
+}
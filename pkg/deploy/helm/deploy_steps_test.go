@@ -0,0 +1,245 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// fakeKubeClient builds the resources from the manifests without the cluster and records the changes.
+type fakeKubeClient struct {
+	kubefake.PrintingKubeClient
+
+	deleted  helm_kube.ResourceList
+	original helm_kube.ResourceList
+	target   helm_kube.ResourceList
+}
+
+func newFakeKubeClient() *fakeKubeClient {
+	return &fakeKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+}
+
+func (c *fakeKubeClient) Build(reader io.Reader, _ bool) (helm_kube.ResourceList, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources helm_kube.ResourceList
+	for _, manifest := range strings.Split(string(data), "\n---\n") {
+		if strings.TrimSpace(manifest) == "" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(manifest), &obj.Object); err != nil {
+			return nil, err
+		}
+
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = "default"
+		}
+
+		resources.Append(&resource.Info{
+			Name:      obj.GetName(),
+			Namespace: namespace,
+			Object:    obj,
+			Mapping:   &meta.RESTMapping{GroupVersionKind: obj.GroupVersionKind()},
+		})
+	}
+
+	return resources, nil
+}
+
+func (c *fakeKubeClient) Delete(resources helm_kube.ResourceList, _ helm_kube.DeleteOptions) (*helm_kube.Result, []error) {
+	c.deleted = append(c.deleted, resources...)
+	return &helm_kube.Result{Deleted: resources}, nil
+}
+
+func (c *fakeKubeClient) Update(original, target helm_kube.ResourceList, _ bool) (*helm_kube.Result, error) {
+	c.original = original
+	c.target = target
+	return &helm_kube.Result{Updated: target}, nil
+}
+
+func resourceNames(resources helm_kube.ResourceList) []string {
+	var names []string
+	for _, info := range resources {
+		names = append(names, fmt.Sprintf("%s/%s", info.Mapping.GroupVersionKind.Kind, info.Name))
+	}
+	return names
+}
+
+type fakeDeployStep struct {
+	name   string
+	err    error
+	events *[]string
+}
+
+func (step *fakeDeployStep) Run(_ context.Context, _ *DeployPlan, deployedRelease *release.Release) error {
+	revision := 0
+	if deployedRelease != nil {
+		revision = deployedRelease.Version
+	}
+	*step.events = append(*step.events, fmt.Sprintf("run %s (deployed revision %d)", step.name, revision))
+	return step.err
+}
+
+func (step *fakeDeployStep) Rollback(_ context.Context) error {
+	*step.events = append(*step.events, fmt.Sprintf("rollback %s", step.name))
+	return nil
+}
+
+func newTestRelease(version int, status release.Status) *release.Release {
+	return &release.Release{
+		Name:      "myrelease",
+		Namespace: "default",
+		Version:   version,
+		Info:      &release.Info{Status: status},
+	}
+}
+
+var _ = Describe("DeploySteps", func() {
+	var events []string
+	var postRenderer *ExtraAnnotationsAndLabelsPostRenderer
+	var releases *storage.Storage
+
+	BeforeEach(func() {
+		events = nil
+
+		postRenderer = NewExtraAnnotationsAndLabelsPostRenderer(nil, nil)
+		_, err := postRenderer.Run(bytes.NewBufferString("---\n# Source: chart/templates/sa.yaml\napiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		releases = storage.Init(driver.NewMemory())
+		Ω(releases.Create(newTestRelease(1, release.StatusDeployed))).Should(Succeed())
+	})
+
+	It("should run the steps before the pending revision is recorded", func() {
+		NewDeploySteps(context.Background(), postRenderer).
+			Add(&fakeDeployStep{name: "first", events: &events}).
+			Add(&fakeDeployStep{name: "second", events: &events}).
+			Attach(releases)
+
+		Ω(releases.Create(newTestRelease(2, release.StatusPendingUpgrade))).Should(Succeed())
+		Ω(events).Should(Equal([]string{"run first (deployed revision 1)", "run second (deployed revision 1)"}))
+
+		_, err := releases.Get("myrelease", 2)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should not record the revision and roll back the previous steps if the step fails", func() {
+		NewDeploySteps(context.Background(), postRenderer).
+			Add(&fakeDeployStep{name: "first", events: &events}).
+			Add(&fakeDeployStep{name: "second", events: &events}).
+			Add(&fakeDeployStep{name: "third", err: fmt.Errorf("third failed"), events: &events}).
+			Attach(releases)
+
+		err := releases.Create(newTestRelease(2, release.StatusPendingUpgrade))
+		Ω(err).Should(MatchError("third failed"))
+		Ω(events).Should(Equal([]string{
+			"run first (deployed revision 1)",
+			"run second (deployed revision 1)",
+			"run third (deployed revision 1)",
+			"rollback second",
+			"rollback first",
+		}))
+
+		_, err = releases.Get("myrelease", 2)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should pass the revisions created by the rollback as is", func() {
+		NewDeploySteps(context.Background(), postRenderer).
+			Add(&fakeDeployStep{name: "first", events: &events}).
+			Attach(releases)
+
+		Ω(releases.Create(newTestRelease(2, release.StatusPendingRollback))).Should(Succeed())
+		Ω(events).Should(BeEmpty())
+	})
+
+	It("should run the steps without the deployed revision on install", func() {
+		releases = storage.Init(driver.NewMemory())
+		NewDeploySteps(context.Background(), postRenderer).
+			Add(&fakeDeployStep{name: "first", events: &events}).
+			Attach(releases)
+
+		Ω(releases.Create(newTestRelease(1, release.StatusPendingInstall))).Should(Succeed())
+		Ω(events).Should(Equal([]string{"run first (deployed revision 0)"}))
+	})
+
+	It("should fail if the chart is not rendered by the werf post-renderer", func() {
+		NewDeploySteps(context.Background(), NewExtraAnnotationsAndLabelsPostRenderer(nil, nil)).
+			Add(&fakeDeployStep{name: "first", events: &events}).
+			Attach(releases)
+
+		Ω(releases.Create(newTestRelease(2, release.StatusPendingUpgrade))).ShouldNot(Succeed())
+		Ω(events).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("BeforeHooksResourcesCreator", func() {
+	It("should collect the resources annotated with werf.io/deploy-before-hooks", func() {
+		postRenderer := NewExtraAnnotationsAndLabelsPostRenderer(nil, nil)
+		_, err := postRenderer.Run(bytes.NewBufferString(`---
+# Source: chart/templates/sa.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: sa
+  annotations:
+    werf.io/deploy-before-hooks: "true"
+---
+# Source: chart/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		plan := postRenderer.GetDeployPlan()
+		Ω(plan.BeforeHooksManifests).Should(HaveLen(1))
+		Ω(plan.BeforeHooksManifests[0]).Should(ContainSubstring("name: sa"))
+	})
+
+	It("should delete the created resources on rollback", func() {
+		kubeClient := newFakeKubeClient()
+		creator := NewBeforeHooksResourcesCreator(kubeClient, "myrelease", "default")
+
+		created, err := kubeClient.Build(bytes.NewBufferString("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa\n"), false)
+		Ω(err).ShouldNot(HaveOccurred())
+		creator.created = created
+
+		Ω(creator.Rollback(context.Background())).Should(Succeed())
+		Ω(resourceNames(kubeClient.deleted)).Should(Equal([]string{"ServiceAccount/sa"}))
+		Ω(creator.created).Should(BeEmpty())
+
+		Ω(creator.Rollback(context.Background())).Should(Succeed())
+		Ω(kubeClient.deleted).Should(HaveLen(1))
+	})
+
+	It("should do nothing without the resources to create", func() {
+		kubeClient := newFakeKubeClient()
+		creator := NewBeforeHooksResourcesCreator(kubeClient, "myrelease", "default")
+
+		Ω(creator.Run(context.Background(), &DeployPlan{}, nil)).Should(Succeed())
+		Ω(kubeClient.deleted).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,266 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/werf/logboek"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	endpointsWaiterPollPeriod    = 2 * time.Second
+	endpointsWaiterHealthTimeout = 5 * time.Second
+	endpointsWaiterServiceKind   = "svc"
+	endpointsWaiterIngressKind   = "ing"
+)
+
+// endpointsWaitSpec describes the readiness conditions of the Service or Ingress,
+// which are checked after the release resources became ready.
+type endpointsWaitSpec struct {
+	Kind      string
+	Name      string
+	Namespace string
+
+	WaitForEndpoints bool
+	WaitForAddress   bool
+	HealthURL        string
+}
+
+func (spec *endpointsWaitSpec) String() string {
+	return fmt.Sprintf("%s/%s", spec.Kind, spec.Name)
+}
+
+func makeServiceEndpointsWaitSpec(ctx context.Context, svc *v1.Service, namespace string) *endpointsWaitSpec {
+	spec, err := prepareEndpointsWaitSpec(svc.Name, endpointsWaiterServiceKind, namespace, svc.Annotations)
+	if err != nil {
+		logboek.Context(ctx).Warn().LogLn()
+		logboek.Context(ctx).Warn().LogF("WARNING %s\n", err)
+		return nil
+	}
+
+	if spec != nil && spec.WaitForAddress && svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		logboek.Context(ctx).Warn().LogLn()
+		logboek.Context(ctx).Warn().LogF("WARNING %s/%s annotation %s ignored: only LoadBalancer service gets the address\n", endpointsWaiterServiceKind, svc.Name, WaitForAddressAnnoName)
+		spec.WaitForAddress = false
+	}
+
+	return spec
+}
+
+func makeIngressEndpointsWaitSpec(ctx context.Context, objMeta *metav1.ObjectMeta, namespace string) *endpointsWaitSpec {
+	spec, err := prepareEndpointsWaitSpec(objMeta.Name, endpointsWaiterIngressKind, namespace, objMeta.Annotations)
+	if err != nil {
+		logboek.Context(ctx).Warn().LogLn()
+		logboek.Context(ctx).Warn().LogF("WARNING %s\n", err)
+		return nil
+	}
+
+	if spec != nil && spec.WaitForEndpoints {
+		logboek.Context(ctx).Warn().LogLn()
+		logboek.Context(ctx).Warn().LogF("WARNING %s/%s annotation %s ignored: only service has endpoints\n", endpointsWaiterIngressKind, objMeta.Name, WaitForEndpointsAnnoName)
+		spec.WaitForEndpoints = false
+	}
+
+	return spec
+}
+
+// prepareEndpointsWaitSpec returns nil if the resource has no endpoints tracking annotations.
+func prepareEndpointsWaitSpec(metadataName, kind, namespace string, annotations map[string]string) (*endpointsWaitSpec, error) {
+	spec := &endpointsWaitSpec{
+		Kind:      kind,
+		Name:      metadataName,
+		Namespace: namespace,
+	}
+
+	for annoName, annoValue := range annotations {
+		invalidAnnoValueError := fmt.Errorf("%s/%s annotation %s with invalid value %s", kind, metadataName, annoName, annoValue)
+
+		switch annoName {
+		case WaitForEndpointsAnnoName:
+			boolValue, err := strconv.ParseBool(annoValue)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bool expected: %s", invalidAnnoValueError, err)
+			}
+
+			spec.WaitForEndpoints = boolValue
+		case WaitForAddressAnnoName:
+			boolValue, err := strconv.ParseBool(annoValue)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bool expected: %s", invalidAnnoValueError, err)
+			}
+
+			spec.WaitForAddress = boolValue
+		case HealthURLAnnoName:
+			u, err := url.Parse(annoValue)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", invalidAnnoValueError, err)
+			} else if u.Scheme != "http" && u.Scheme != "https" {
+				return nil, fmt.Errorf("%s: http or https url expected", invalidAnnoValueError)
+			}
+
+			spec.HealthURL = annoValue
+		}
+	}
+
+	if !spec.WaitForEndpoints && !spec.WaitForAddress && spec.HealthURL == "" {
+		return nil, nil
+	}
+
+	return spec, nil
+}
+
+func (waiter *ResourcesWaiter) waitForEndpoints(ctx context.Context, specs []*endpointsWaitSpec, timeout time.Duration) error {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	logboek.Context(ctx).LogOptionalLn()
+	return logboek.Context(ctx).LogProcess("Waiting for release endpoints to become ready").
+		DoError(func() error {
+			startedAt := time.Now()
+			lastStatusAt := startedAt
+			pendingSpecs := specs
+
+			for {
+				var notReadySpecs []*endpointsWaitSpec
+				var notReadyDescs []string
+
+				for _, spec := range pendingSpecs {
+					reason, err := checkEndpointsWaitSpec(ctx, waiter.kubeClient(), spec)
+					if err != nil {
+						return fmt.Errorf("unable to check %s readiness: %s", spec, err)
+					}
+
+					if reason == "" {
+						logboek.Context(ctx).Default().LogF("%s: ready\n", spec)
+						continue
+					}
+
+					notReadySpecs = append(notReadySpecs, spec)
+					notReadyDescs = append(notReadyDescs, fmt.Sprintf("%s (%s)", spec, reason))
+				}
+
+				if len(notReadySpecs) == 0 {
+					return nil
+				}
+
+				if timeout > 0 && time.Since(startedAt) > timeout {
+					return fmt.Errorf("timed out waiting for endpoints readiness: %s", strings.Join(notReadyDescs, ", "))
+				}
+
+				if waiter.StatusProgressPeriod > 0 && time.Since(lastStatusAt) >= waiter.StatusProgressPeriod {
+					logboek.Context(ctx).Default().LogF("Waiting for %s\n", strings.Join(notReadyDescs, ", "))
+					lastStatusAt = time.Now()
+				}
+
+				pendingSpecs = notReadySpecs
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(endpointsWaiterPollPeriod):
+				}
+			}
+		})
+}
+
+// checkEndpointsWaitSpec returns the reason why the resource is not ready yet, or empty string if the resource is ready.
+func checkEndpointsWaitSpec(ctx context.Context, client kubernetes.Interface, spec *endpointsWaitSpec) (string, error) {
+	if spec.WaitForEndpoints {
+		endpoints, err := client.CoreV1().Endpoints(spec.Namespace).Get(ctx, spec.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return "no endpoints", nil
+		} else if err != nil {
+			return "", err
+		}
+
+		if !hasReadyEndpointAddresses(endpoints) {
+			return "no ready endpoints", nil
+		}
+	}
+
+	if spec.WaitForAddress {
+		ingresses, err := getLoadBalancerIngresses(ctx, client, spec)
+		if err != nil {
+			return "", err
+		}
+
+		if len(ingresses) == 0 {
+			return "no address assigned", nil
+		}
+	}
+
+	if spec.HealthURL != "" {
+		if reason := probeHealthURL(ctx, spec.HealthURL); reason != "" {
+			return reason, nil
+		}
+	}
+
+	return "", nil
+}
+
+func hasReadyEndpointAddresses(endpoints *v1.Endpoints) bool {
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getLoadBalancerIngresses(ctx context.Context, client kubernetes.Interface, spec *endpointsWaitSpec) ([]v1.LoadBalancerIngress, error) {
+	switch spec.Kind {
+	case endpointsWaiterServiceKind:
+		svc, err := client.CoreV1().Services(spec.Namespace).Get(ctx, spec.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return svc.Status.LoadBalancer.Ingress, nil
+	case endpointsWaiterIngressKind:
+		ing, err := client.NetworkingV1().Ingresses(spec.Namespace).Get(ctx, spec.Name, metav1.GetOptions{})
+		if err == nil {
+			return ing.Status.LoadBalancer.Ingress, nil
+		}
+
+		// networking.k8s.io/v1 is not available before kubernetes 1.19
+		ingV1beta1, errV1beta1 := client.NetworkingV1beta1().Ingresses(spec.Namespace).Get(ctx, spec.Name, metav1.GetOptions{})
+		if errV1beta1 != nil {
+			return nil, err
+		}
+		return ingV1beta1.Status.LoadBalancer.Ingress, nil
+	default:
+		panic(fmt.Sprintf("unexpected kind %q", spec.Kind))
+	}
+}
+
+func probeHealthURL(ctx context.Context, healthURL string) string {
+	ctx, cancel := context.WithTimeout(ctx, endpointsWaiterHealthTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return fmt.Sprintf("health url %s: %s", healthURL, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Sprintf("health url %s: %s", healthURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Sprintf("health url %s: %s", healthURL, resp.Status)
+	}
+
+	return ""
+}
@@ -0,0 +1,169 @@
+package helm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("endpoints waiter", func() {
+	DescribeTable("prepareEndpointsWaitSpec",
+		func(annotations map[string]string, expectedSpec *endpointsWaitSpec, expectedErr bool) {
+			spec, err := prepareEndpointsWaitSpec("backend", endpointsWaiterServiceKind, "default", annotations)
+			if expectedErr {
+				Ω(err).Should(HaveOccurred())
+				return
+			}
+
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec).Should(Equal(expectedSpec))
+		},
+		Entry("without annotations", map[string]string{"other": "true"}, nil, false),
+		Entry("with disabled annotations", map[string]string{WaitForEndpointsAnnoName: "false", WaitForAddressAnnoName: "false"}, nil, false),
+		Entry("with all annotations",
+			map[string]string{WaitForEndpointsAnnoName: "true", WaitForAddressAnnoName: "true", HealthURLAnnoName: "https://example.com/healthz"},
+			&endpointsWaitSpec{Kind: "svc", Name: "backend", Namespace: "default", WaitForEndpoints: true, WaitForAddress: true, HealthURL: "https://example.com/healthz"},
+			false,
+		),
+		Entry("with invalid bool value", map[string]string{WaitForEndpointsAnnoName: "yes please"}, nil, true),
+		Entry("with non-http health url", map[string]string{HealthURLAnnoName: "ftp://example.com"}, nil, true),
+		Entry("with invalid health url", map[string]string{HealthURLAnnoName: "http://[::1"}, nil, true),
+	)
+
+	It("should ignore the address annotation of the non-LoadBalancer service", func() {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend", Annotations: map[string]string{WaitForEndpointsAnnoName: "true", WaitForAddressAnnoName: "true"}},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeClusterIP},
+		}
+
+		spec := makeServiceEndpointsWaitSpec(context.Background(), svc, "default")
+		Ω(spec).ShouldNot(BeNil())
+		Ω(spec.WaitForEndpoints).Should(BeTrue())
+		Ω(spec.WaitForAddress).Should(BeFalse())
+	})
+
+	It("should ignore the endpoints annotation of the ingress", func() {
+		spec := makeIngressEndpointsWaitSpec(context.Background(), &metav1.ObjectMeta{Name: "backend", Annotations: map[string]string{WaitForEndpointsAnnoName: "true", WaitForAddressAnnoName: "true"}}, "default")
+		Ω(spec).ShouldNot(BeNil())
+		Ω(spec.WaitForEndpoints).Should(BeFalse())
+		Ω(spec.WaitForAddress).Should(BeTrue())
+	})
+
+	It("should skip the resource with the invalid annotation", func() {
+		spec := makeIngressEndpointsWaitSpec(context.Background(), &metav1.ObjectMeta{Name: "backend", Annotations: map[string]string{WaitForAddressAnnoName: "maybe"}}, "default")
+		Ω(spec).Should(BeNil())
+	})
+
+	Context("checking the readiness", func() {
+		var client *fake.Clientset
+		var prevClient kubernetes.Interface
+
+		BeforeEach(func() {
+			client = fake.NewSimpleClientset()
+			prevClient = kube.Client
+			kube.Client = client
+		})
+
+		AfterEach(func() {
+			kube.Client = prevClient
+		})
+
+		It("should wait for the ready endpoint addresses", func() {
+			spec := &endpointsWaitSpec{Kind: endpointsWaiterServiceKind, Name: "backend", Namespace: "default", WaitForEndpoints: true}
+
+			reason, err := checkEndpointsWaitSpec(context.Background(), client, spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reason).Should(Equal("no endpoints"))
+
+			endpoints := &v1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "default"},
+				Subsets:    []v1.EndpointSubset{{NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.1"}}}},
+			}
+			_, err = client.CoreV1().Endpoints("default").Create(context.Background(), endpoints, metav1.CreateOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			reason, err = checkEndpointsWaitSpec(context.Background(), client, spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reason).Should(Equal("no ready endpoints"))
+
+			endpoints.Subsets = append(endpoints.Subsets, v1.EndpointSubset{Addresses: []v1.EndpointAddress{{IP: "10.0.0.2"}}})
+			_, err = client.CoreV1().Endpoints("default").Update(context.Background(), endpoints, metav1.UpdateOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			reason, err = checkEndpointsWaitSpec(context.Background(), client, spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reason).Should(BeEmpty())
+		})
+
+		It("should wait for the load balancer address of the service", func() {
+			svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "default"}}
+			_, err := client.CoreV1().Services("default").Create(context.Background(), svc, metav1.CreateOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			spec := &endpointsWaitSpec{Kind: endpointsWaiterServiceKind, Name: "backend", Namespace: "default", WaitForAddress: true}
+
+			reason, err := checkEndpointsWaitSpec(context.Background(), client, spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reason).Should(Equal("no address assigned"))
+
+			svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+			_, err = client.CoreV1().Services("default").UpdateStatus(context.Background(), svc, metav1.UpdateOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			reason, err = checkEndpointsWaitSpec(context.Background(), client, spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reason).Should(BeEmpty())
+		})
+
+		It("should return the error for the missing ingress", func() {
+			_, err := checkEndpointsWaitSpec(context.Background(), client, &endpointsWaitSpec{Kind: endpointsWaiterIngressKind, Name: "backend", Namespace: "default", WaitForAddress: true})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should finish waiting when all resources are ready", func() {
+			_, err := client.CoreV1().Endpoints("default").Create(context.Background(), &v1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "default"},
+				Subsets:    []v1.EndpointSubset{{Addresses: []v1.EndpointAddress{{IP: "10.0.0.1"}}}},
+			}, metav1.CreateOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+			specs := []*endpointsWaitSpec{{Kind: endpointsWaiterServiceKind, Name: "backend", Namespace: "default", WaitForEndpoints: true}}
+			Ω((&ResourcesWaiter{}).waitForEndpoints(ctx, specs, time.Minute)).Should(Succeed())
+		})
+
+		It("should time out when the resource is not ready", func() {
+			ctx := logboek.NewContext(context.Background(), logboek.DefaultLogger())
+			specs := []*endpointsWaitSpec{{Kind: endpointsWaiterServiceKind, Name: "backend", Namespace: "default", WaitForEndpoints: true}}
+
+			err := (&ResourcesWaiter{KubeClient: client}).waitForEndpoints(ctx, specs, time.Nanosecond)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("svc/backend (no endpoints)"))
+		})
+	})
+
+	It("should probe the health url", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		Ω(probeHealthURL(context.Background(), server.URL+"/healthz")).Should(BeEmpty())
+		Ω(probeHealthURL(context.Background(), server.URL+"/other")).Should(ContainSubstring("503 Service Unavailable"))
+	})
+})
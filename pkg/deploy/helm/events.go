@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"github.com/werf/kubedog/pkg/trackers/rollout/multitrack"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+
+	"github.com/werf/werf/pkg/events"
+)
+
+func emitResourcesAppliedEvents(resources helm_kube.ResourceList) {
+	if !events.IsEnabled() {
+		return
+	}
+
+	for _, info := range resources {
+		kind := info.Object.GetObjectKind().GroupVersionKind().Kind
+		if info.Mapping != nil {
+			kind = info.Mapping.GroupVersionKind.Kind
+		}
+
+		events.Emit(events.Event{
+			Type:     events.ResourceApplied,
+			Resource: &events.Resource{Kind: kind, Name: info.Name, Namespace: info.Namespace},
+		})
+	}
+}
+
+// trackResourcesWithEvents emits the tracking state events of the tracked resources around the multitrack call:
+// the multitracker does not report the state of the particular resources, so the failure is reported for all of them.
+func trackResourcesWithEvents(specs multitrack.MultitrackSpecs, trackFunc func() error) error {
+	if !events.IsEnabled() {
+		return trackFunc()
+	}
+
+	var resources []*events.Resource
+	for _, s := range []struct {
+		kind  string
+		specs []multitrack.MultitrackSpec
+	}{
+		{"Deployment", specs.Deployments},
+		{"StatefulSet", specs.StatefulSets},
+		{"DaemonSet", specs.DaemonSets},
+		{"Job", specs.Jobs},
+		{"Canary", specs.Canaries},
+	} {
+		for _, spec := range s.specs {
+			resources = append(resources, &events.Resource{Kind: s.kind, Name: spec.ResourceName, Namespace: spec.Namespace})
+		}
+	}
+
+	for _, resource := range resources {
+		events.Emit(events.Event{Type: events.ResourceTrackingState, Resource: resource, State: events.TrackingStateTracking})
+	}
+
+	if err := trackFunc(); err != nil {
+		events.Emit(events.Event{Type: events.ResourcesTrackingFailed, Error: err.Error()})
+		return err
+	}
+
+	for _, resource := range resources {
+		events.Emit(events.Event{Type: events.ResourceTrackingState, Resource: resource, State: events.TrackingStateReady})
+	}
+
+	return nil
+}
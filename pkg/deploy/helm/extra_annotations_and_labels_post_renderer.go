@@ -6,8 +6,10 @@ import (
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/werf"
 
 	"github.com/werf/logboek"
@@ -35,6 +37,26 @@ func NewExtraAnnotationsAndLabelsPostRenderer(extraAnnotations, extraLabels map[
 type ExtraAnnotationsAndLabelsPostRenderer struct {
 	ExtraAnnotations map[string]string
 	ExtraLabels      map[string]string
+
+	trackingConfig   []*config.MetaDeployTracking
+	imagePullSecrets []string
+	deployPlan       *DeployPlan
+}
+
+// GetDeployPlan returns the manifests for the deploy steps collected by the last Run.
+func (pr *ExtraAnnotationsAndLabelsPostRenderer) GetDeployPlan() *DeployPlan {
+	return pr.deployPlan
+}
+
+// SetTrackingConfig enables the werf.yaml deploy tracking configuration, which is added to the resources as the tracking annotations.
+// Annotations set in the chart templates take precedence.
+func (pr *ExtraAnnotationsAndLabelsPostRenderer) SetTrackingConfig(tracking []*config.MetaDeployTracking) {
+	pr.trackingConfig = tracking
+}
+
+// SetImagePullSecrets enables adding of the image pull secrets managed by werf into the imagePullSecrets of the workloads.
+func (pr *ExtraAnnotationsAndLabelsPostRenderer) SetImagePullSecrets(secretNames []string) {
+	pr.imagePullSecrets = secretNames
 }
 
 func (pr *ExtraAnnotationsAndLabelsPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
@@ -63,6 +85,9 @@ func (pr *ExtraAnnotationsAndLabelsPostRenderer) Run(renderedManifests *bytes.Bu
 	sort.Sort(releaseutil.BySplitManifestsOrder(manifestsKeys))
 
 	splitModifiedManifests := make([]string, 0)
+	var beforeHooksManifests []string
+	wavesManifests := map[int][]string{}
+	var canaryManifests []string
 
 	manifestNameRegex := regexp.MustCompile("# Source: .*")
 	for _, manifestKey := range manifestsKeys {
@@ -103,6 +128,19 @@ func (pr *ExtraAnnotationsAndLabelsPostRenderer) Run(renderedManifests *bytes.Bu
 			obj.SetAnnotations(annotations)
 		}
 
+		if trackingAnnotations := getTrackingAnnotations(pr.trackingConfig, obj.GetKind(), obj.GetName()); len(trackingAnnotations) > 0 {
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			for k, v := range trackingAnnotations {
+				if _, hasKey := annotations[k]; !hasKey {
+					annotations[k] = v
+				}
+			}
+			obj.SetAnnotations(annotations)
+		}
+
 		if len(extraLabels) > 0 {
 			labels := obj.GetLabels()
 			if labels == nil {
@@ -114,11 +152,40 @@ func (pr *ExtraAnnotationsAndLabelsPostRenderer) Run(renderedManifests *bytes.Bu
 			obj.SetLabels(labels)
 		}
 
+		if err := addImagePullSecrets(&obj, pr.imagePullSecrets); err != nil {
+			return nil, err
+		}
+
+		deployBeforeHooks, err := isDeployBeforeHooks(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		deployWave, err := getDeployWave(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		canary, err := isCanary(obj)
+		if err != nil {
+			return nil, err
+		}
+
 		if modifiedManifestContent, err := yaml.Marshal(obj.Object); err != nil {
 			return nil, fmt.Errorf("unable to modify manifest: %s\n%s\n---\n", err, manifestContent)
 		} else {
 			splitModifiedManifests = append(splitModifiedManifests, manifestSource+"\n"+string(modifiedManifestContent))
 
+			if deployBeforeHooks {
+				beforeHooksManifests = append(beforeHooksManifests, string(modifiedManifestContent))
+			}
+
+			wavesManifests[deployWave] = append(wavesManifests[deployWave], string(modifiedManifestContent))
+
+			if canary {
+				canaryManifests = append(canaryManifests, string(modifiedManifestContent))
+			}
+
 			if os.Getenv("WERF_HELM_V3_EXTRA_ANNOTATIONS_AND_LABELS_DEBUG") == "1" {
 				fmt.Printf("ExtraAnnotationsAndLabelsPostRenderer -- modified manifest BEGIN\n")
 				fmt.Printf("%s\n", modifiedManifestContent)
@@ -134,9 +201,43 @@ func (pr *ExtraAnnotationsAndLabelsPostRenderer) Run(renderedManifests *bytes.Bu
 		fmt.Printf("ExtraAnnotationsAndLabelsPostRenderer -- modified manifests RESULT END\n")
 	}
 
+	pr.deployPlan = &DeployPlan{
+		BeforeHooksManifests: beforeHooksManifests,
+		WavesManifests:       wavesManifests,
+		CanaryManifests:      canaryManifests,
+	}
+
 	return modifiedManifests, nil
 }
 
+func isDeployBeforeHooks(obj unstructured.Unstructured) (bool, error) {
+	value, hasKey := obj.GetAnnotations()[DeployBeforeHooksAnnoName]
+	if !hasKey {
+		return false, nil
+	}
+
+	deployBeforeHooks, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s/%s annotation %s with invalid value %q: boolean expected", obj.GetKind(), obj.GetName(), DeployBeforeHooksAnnoName, value)
+	}
+
+	return deployBeforeHooks, nil
+}
+
+func getDeployWave(obj unstructured.Unstructured) (int, error) {
+	value, hasKey := obj.GetAnnotations()[DeployWaveAnnoName]
+	if !hasKey {
+		return 0, nil
+	}
+
+	deployWave, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s/%s annotation %s with invalid value %q: integer expected", obj.GetKind(), obj.GetName(), DeployWaveAnnoName, value)
+	}
+
+	return deployWave, nil
+}
+
 func (pr *ExtraAnnotationsAndLabelsPostRenderer) Add(extraAnnotations, extraLabels map[string]string) {
 	if len(extraAnnotations) > 0 {
 		if pr.ExtraAnnotations == nil {
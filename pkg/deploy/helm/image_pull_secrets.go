@@ -0,0 +1,66 @@
+package helm
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// podSpecPaths are the paths of the pod spec in the workload kinds, which image pull secrets are managed by werf.
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// addImagePullSecrets adds the secrets missing in the imagePullSecrets of the workload pod spec, the other kinds are not changed.
+func addImagePullSecrets(obj *unstructured.Unstructured, secretNames []string) error {
+	podSpecPath, isWorkload := podSpecPaths[obj.GetKind()]
+	if !isWorkload || len(secretNames) == 0 {
+		return nil
+	}
+
+	podSpec, found, err := unstructured.NestedMap(obj.Object, podSpecPath...)
+	if err != nil {
+		return fmt.Errorf("%s/%s: unable to get pod spec: %s", obj.GetKind(), obj.GetName(), err)
+	} else if !found {
+		return nil
+	}
+
+	imagePullSecrets, _, err := unstructured.NestedSlice(podSpec, "imagePullSecrets")
+	if err != nil {
+		return fmt.Errorf("%s/%s: unable to get imagePullSecrets: %s", obj.GetKind(), obj.GetName(), err)
+	}
+
+	existingSecrets := map[string]bool{}
+	for _, ref := range imagePullSecrets {
+		if refMap, ok := ref.(map[string]interface{}); ok {
+			if name, ok := refMap["name"].(string); ok {
+				existingSecrets[name] = true
+			}
+		}
+	}
+
+	var changed bool
+	for _, name := range secretNames {
+		if !existingSecrets[name] {
+			imagePullSecrets = append(imagePullSecrets, map[string]interface{}{"name": name})
+			existingSecrets[name] = true
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := unstructured.SetNestedSlice(podSpec, imagePullSecrets, "imagePullSecrets"); err != nil {
+		return fmt.Errorf("%s/%s: unable to set imagePullSecrets: %s", obj.GetKind(), obj.GetName(), err)
+	}
+
+	return unstructured.SetNestedMap(obj.Object, podSpec, podSpecPath...)
+}
@@ -0,0 +1,51 @@
+package helm
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = DescribeTable("addImagePullSecrets",
+	func(manifest string, expectedImagePullSecrets []interface{}, expectedPath ...string) {
+		var obj unstructured.Unstructured
+		Ω(yaml.Unmarshal([]byte(manifest), &obj.Object)).Should(Succeed())
+
+		Ω(addImagePullSecrets(&obj, []string{"registry", "pull-token"})).Should(Succeed())
+
+		imagePullSecrets, found, err := unstructured.NestedSlice(obj.Object, append(expectedPath, "imagePullSecrets")...)
+		Ω(err).ShouldNot(HaveOccurred())
+		if expectedImagePullSecrets == nil {
+			Ω(found).Should(BeFalse())
+		} else {
+			Ω(imagePullSecrets).Should(Equal(expectedImagePullSecrets))
+		}
+	},
+	Entry("Deployment",
+		"kind: Deployment\nmetadata:\n  name: app\nspec:\n  template:\n    spec:\n      containers: []\n",
+		[]interface{}{map[string]interface{}{"name": "registry"}, map[string]interface{}{"name": "pull-token"}},
+		"spec", "template", "spec",
+	),
+	Entry("Pod with the existing secret",
+		"kind: Pod\nmetadata:\n  name: app\nspec:\n  imagePullSecrets:\n  - name: own\n  - name: registry\n",
+		[]interface{}{map[string]interface{}{"name": "own"}, map[string]interface{}{"name": "registry"}, map[string]interface{}{"name": "pull-token"}},
+		"spec",
+	),
+	Entry("CronJob",
+		"kind: CronJob\nmetadata:\n  name: app\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers: []\n",
+		[]interface{}{map[string]interface{}{"name": "registry"}, map[string]interface{}{"name": "pull-token"}},
+		"spec", "jobTemplate", "spec", "template", "spec",
+	),
+	Entry("ConfigMap is not changed",
+		"kind: ConfigMap\nmetadata:\n  name: app\nspec:\n  template:\n    spec: {}\n",
+		nil,
+		"spec", "template", "spec",
+	),
+	Entry("Deployment without the pod template is not changed",
+		"kind: Deployment\nmetadata:\n  name: app\n",
+		nil,
+		"spec", "template", "spec",
+	),
+)
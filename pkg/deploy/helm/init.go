@@ -18,6 +18,8 @@ import (
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/werf/kubedog/pkg/kube"
 	"github.com/werf/logboek"
@@ -28,6 +30,9 @@ type InitActionConfigOptions struct {
 	HooksStatusProgressPeriod time.Duration
 	KubeConfigOptions         kube.KubeConfigOptions
 	ReleasesHistoryMax        int
+	// KubeClient and KubeDynamicClient of the kube context are used by the resources waiter instead of the global kube clients when set.
+	KubeClient        kubernetes.Interface
+	KubeDynamicClient dynamic.Interface
 }
 
 func InitActionConfig(ctx context.Context, kubeInitializer KubeInitializer, namespace string, envSettings *cli.EnvSettings, registryClientHandle *helm_v3.RegistryClientHandle, actionConfig *action.Configuration, opts InitActionConfigOptions) error {
@@ -61,7 +66,10 @@ func InitActionConfig(ctx context.Context, kubeInitializer KubeInitializer, name
 
 	kubeClient := actionConfig.KubeClient.(*helm_kube.Client)
 	kubeClient.Namespace = namespace
-	kubeClient.ResourcesWaiter = NewResourcesWaiter(kubeInitializer, kubeClient, time.Now(), opts.StatusProgressPeriod, opts.HooksStatusProgressPeriod)
+	resourcesWaiter := NewResourcesWaiter(kubeInitializer, kubeClient, time.Now(), opts.StatusProgressPeriod, opts.HooksStatusProgressPeriod)
+	resourcesWaiter.KubeClient = opts.KubeClient
+	resourcesWaiter.KubeDynamicClient = opts.KubeDynamicClient
+	kubeClient.ResourcesWaiter = resourcesWaiter
 	kubeClient.Extender = NewHelmKubeClientExtender()
 
 	actionConfig.RegistryClient = registryClientHandle.RegistryClient
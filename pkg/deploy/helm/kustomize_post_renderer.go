@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	kustomizeBuildDir              = "/kustomization"
+	kustomizeRenderedManifestsFile = "werf-rendered-manifests.yaml"
+)
+
+// KustomizePostRenderer applies the kustomization to the rendered manifests.
+// The rendered manifests are added into the resources of the kustomization automatically,
+// so that kustomization could patch, label or extend the chart resources.
+// The kustomization is built in memory from the files of the dir, thus all files used by the kustomization should be located in the dir.
+type KustomizePostRenderer struct {
+	Dir string
+	// Files are the files of the dir with the paths relative to the dir, read by the giterminism file reader
+	Files []*chart.ChartExtenderBufferedFile
+}
+
+func NewKustomizePostRenderer(dir string, files []*chart.ChartExtenderBufferedFile) *KustomizePostRenderer {
+	return &KustomizePostRenderer{Dir: dir, Files: files}
+}
+
+func (pr *KustomizePostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	fs := filesys.MakeFsInMemory()
+	for _, file := range pr.Files {
+		if err := fs.WriteFile(path.Join(kustomizeBuildDir, file.Name), file.Data); err != nil {
+			return nil, fmt.Errorf("unable to write kustomization file %q: %s", file.Name, err)
+		}
+	}
+
+	if err := fs.WriteFile(path.Join(kustomizeBuildDir, kustomizeRenderedManifestsFile), renderedManifests.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if err := addKustomizationResource(fs, kustomizeBuildDir, kustomizeRenderedManifestsFile); err != nil {
+		return nil, fmt.Errorf("bad kustomization dir %q: %s", pr.Dir, err)
+	}
+
+	resMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fs, kustomizeBuildDir)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build of %q failed: %s", pr.Dir, err)
+	}
+
+	data, err := resMap.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(data), nil
+}
+
+func addKustomizationResource(fs filesys.FileSystem, dir, resource string) error {
+	var kustomizationPath string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if fs.Exists(path.Join(dir, name)) {
+			kustomizationPath = path.Join(dir, name)
+			break
+		}
+	}
+
+	if kustomizationPath == "" {
+		return fmt.Errorf("kustomization file not found, expected one of %v", konfig.RecognizedKustomizationFileNames())
+	}
+
+	data, err := fs.ReadFile(kustomizationPath)
+	if err != nil {
+		return err
+	}
+
+	kustomization := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &kustomization); err != nil {
+		return fmt.Errorf("unable to parse %s: %s", path.Base(kustomizationPath), err)
+	}
+
+	resources, _ := kustomization["resources"].([]interface{})
+	kustomization["resources"] = append([]interface{}{resource}, resources...)
+
+	if data, err = yaml.Marshal(kustomization); err != nil {
+		return err
+	}
+
+	return fs.WriteFile(kustomizationPath, data)
+}
@@ -0,0 +1,64 @@
+package helm
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const kustomizeTestRenderedManifests = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  key: value
+`
+
+var _ = Describe("KustomizePostRenderer", func() {
+	It("should apply the kustomization from the files to the rendered manifests", func() {
+		postRenderer := NewKustomizePostRenderer(".helm/kustomize", []*chart.ChartExtenderBufferedFile{
+			{Name: "kustomization.yaml", Data: []byte("commonLabels:\n  team: backend\nresources:\n- resources/sa.yaml\npatchesStrategicMerge:\n- patches/cm.yaml\n")},
+			{Name: "resources/sa.yaml", Data: []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa\n")},
+			{Name: "patches/cm.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\ndata:\n  key: patched\n")},
+		})
+
+		result, err := postRenderer.Run(bytes.NewBufferString(kustomizeTestRenderedManifests))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(result.String()).Should(ContainSubstring("kind: ConfigMap"))
+		Ω(result.String()).Should(ContainSubstring("key: patched"))
+		Ω(result.String()).Should(ContainSubstring("kind: ServiceAccount"))
+		Ω(result.String()).Should(ContainSubstring("team: backend"))
+	})
+
+	It("should not modify the files", func() {
+		kustomization := []byte("resources: []\n")
+		postRenderer := NewKustomizePostRenderer(".helm/kustomize", []*chart.ChartExtenderBufferedFile{{Name: "kustomization.yaml", Data: kustomization}})
+
+		_, err := postRenderer.Run(bytes.NewBufferString(kustomizeTestRenderedManifests))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(postRenderer.Files[0].Data)).Should(Equal("resources: []\n"))
+	})
+
+	It("should fail if the kustomization file is not found", func() {
+		postRenderer := NewKustomizePostRenderer(".helm/kustomize", []*chart.ChartExtenderBufferedFile{
+			{Name: "resources/sa.yaml", Data: []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa\n")},
+		})
+
+		_, err := postRenderer.Run(bytes.NewBufferString(kustomizeTestRenderedManifests))
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("kustomization file not found"))
+	})
+
+	It("should not read the files outside of the dir", func() {
+		postRenderer := NewKustomizePostRenderer(".helm/kustomize", []*chart.ChartExtenderBufferedFile{
+			{Name: "kustomization.yaml", Data: []byte("resources:\n- /etc/passwd\n")},
+		})
+
+		_, err := postRenderer.Run(bytes.NewBufferString(kustomizeTestRenderedManifests))
+		Ω(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,245 @@
+package maintenance_helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/deploy/helm"
+)
+
+type AdoptResourcesOptions struct {
+	// Force takes over the resources annotated with the release which does not exist anymore.
+	// The resources of the existing releases are never taken over.
+	Force bool
+	// DryRun only reports the resources to be adopted without changing anything in the cluster.
+	DryRun bool
+	// ManifestsPath is the file with the rendered manifests of the release (e.g. the werf render command output).
+	// The adopted resources are checked with the server-side dry-run update to these manifests to report the immutable fields conflicts.
+	ManifestsPath string
+}
+
+// AdoptResources makes the existing resources specified in the TYPE/NAME format owned by the helm 3 release,
+// so that the resources created outside of werf (e.g. with kubectl or kustomize) are updated by the release instead of recreation.
+// Only the release annotations and the managed-by label are patched, other resource fields are not changed.
+func (helper *MaintenanceHelper) AdoptResources(ctx context.Context, releaseName, releaseNamespace string, resources []string, opts AdoptResourcesOptions) error {
+	factory, err := helper.getResourcesFactory()
+	if err != nil {
+		return err
+	}
+
+	client, err := factory.KubernetesClientSet()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %s", err)
+	}
+
+	infos, err := newBuilder(factory, releaseNamespace).
+		Unstructured().
+		ResourceTypeOrNameArgs(false, resources...).
+		Do().Infos()
+	if err != nil {
+		return fmt.Errorf("error getting resources: %s", err)
+	}
+
+	var manifests []*resource.Info
+	if opts.ManifestsPath != "" {
+		manifests, err = newBuilder(factory, releaseNamespace).
+			Unstructured().
+			FilenameParam(false, &resource.FilenameOptions{Filenames: []string{opts.ManifestsPath}}).
+			Do().Infos()
+		if err != nil {
+			return fmt.Errorf("error reading manifests %s: %s", opts.ManifestsPath, err)
+		}
+	}
+
+	var adopted, skipped, conflicts int
+	for _, info := range infos {
+		isAdopted, err := helper.adoptResource(ctx, client, info, releaseName, releaseNamespace, opts)
+		if err != nil {
+			return err
+		}
+
+		if isAdopted {
+			adopted++
+		} else {
+			skipped++
+		}
+
+		if opts.ManifestsPath == "" {
+			continue
+		}
+
+		manifest := findResourceManifest(manifests, info)
+		if manifest == nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: %s is not found in the manifests %s\n", info.ObjectName(), opts.ManifestsPath)
+			continue
+		}
+
+		fields, err := getImmutableFieldsConflicts(manifest)
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			logboek.Context(ctx).Warn().LogF("WARNING: %s immutable field %s differs from the manifest, the next deploy will fail unless the manifest keeps the current value\n", info.ObjectName(), field)
+		}
+		if len(fields) != 0 {
+			conflicts++
+		}
+	}
+
+	logboek.Context(ctx).LogOptionalLn()
+	if opts.DryRun {
+		logboek.Context(ctx).Default().LogFDetails("%d resources would be adopted by the release %q in the %q namespace, %d resources already belong to the release\n", adopted, releaseName, releaseNamespace, skipped)
+	} else {
+		logboek.Context(ctx).Default().LogFDetails("%d resources adopted by the release %q in the %q namespace, %d resources already belong to the release\n", adopted, releaseName, releaseNamespace, skipped)
+	}
+
+	if conflicts != 0 {
+		logboek.Context(ctx).Warn().LogF("WARNING: %d resources have immutable fields conflicts with the manifests\n", conflicts)
+	}
+
+	return nil
+}
+
+func (helper *MaintenanceHelper) adoptResource(ctx context.Context, client kubernetes.Interface, info *resource.Info, releaseName, releaseNamespace string, opts AdoptResourcesOptions) (bool, error) {
+	accessor, err := meta.Accessor(info.Object)
+	if err != nil {
+		return false, fmt.Errorf("error accessing metadata of %s: %s", info.ObjectName(), err)
+	}
+
+	switch ownerName, ownerNamespace := getResourceOwnerRelease(accessor); {
+	case ownerName == releaseName && ownerNamespace == releaseNamespace:
+		if accessor.GetLabels()[helm.ManagedByLabelName] == helm.ManagedByLabelValue {
+			logboek.Context(ctx).Default().LogF("%s already belongs to the release: skipping\n", info.ObjectName())
+			return false, nil
+		}
+	case ownerName != "":
+		exist, err := isHelm3ReleaseExistInNamespace(ctx, client, ownerName, ownerNamespace)
+		if err != nil {
+			return false, fmt.Errorf("error checking release %q in the %q namespace: %s", ownerName, ownerNamespace, err)
+		}
+
+		if exist {
+			return false, fmt.Errorf("%s belongs to the existing release %q in the %q namespace: remove the resource from the release chart first", info.ObjectName(), ownerName, ownerNamespace)
+		}
+
+		if !opts.Force {
+			return false, fmt.Errorf("%s is annotated with the release %q in the %q namespace, which does not exist: use force option to take it over", info.ObjectName(), ownerName, ownerNamespace)
+		}
+
+		logboek.Context(ctx).Warn().LogF("WARNING: %s is taken over from the deleted release %q in the %q namespace\n", info.ObjectName(), ownerName, ownerNamespace)
+	}
+
+	if opts.DryRun {
+		logboek.Context(ctx).Default().LogF("%s would be adopted\n", info.ObjectName())
+		return true, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				helm.ReleaseNameAnnoName:      releaseName,
+				helm.ReleaseNamespaceAnnoName: releaseNamespace,
+			},
+			"labels": map[string]string{
+				helm.ManagedByLabelName: helm.ManagedByLabelValue,
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := resource.NewHelper(info.Client, info.Mapping).Patch(info.Namespace, info.Name, types.MergePatchType, patch, nil); err != nil {
+		return false, fmt.Errorf("error patching %s: %s", info.ObjectName(), err)
+	}
+
+	logboek.Context(ctx).Default().LogF("%s adopted\n", info.ObjectName())
+
+	return true, nil
+}
+
+// getResourceOwnerRelease returns the name and the namespace of the release the resource is annotated with.
+func getResourceOwnerRelease(obj metav1.Object) (string, string) {
+	annotations := obj.GetAnnotations()
+	return annotations[helm.ReleaseNameAnnoName], annotations[helm.ReleaseNamespaceAnnoName]
+}
+
+// isHelm3ReleaseExistInNamespace looks up the release records in the helm 3 secrets and configmaps storages of the namespace.
+func isHelm3ReleaseExistInNamespace(ctx context.Context, client kubernetes.Interface, releaseName, namespace string) (bool, error) {
+	listOptions := metav1.ListOptions{LabelSelector: fmt.Sprintf("owner=helm,name=%s", releaseName)}
+
+	secrets, err := client.CoreV1().Secrets(namespace).List(ctx, listOptions)
+	if err != nil {
+		return false, err
+	}
+	if len(secrets.Items) != 0 {
+		return true, nil
+	}
+
+	configMaps, err := client.CoreV1().ConfigMaps(namespace).List(ctx, listOptions)
+	if err != nil {
+		return false, err
+	}
+
+	return len(configMaps.Items) != 0, nil
+}
+
+func findResourceManifest(manifests []*resource.Info, info *resource.Info) *resource.Info {
+	for _, manifest := range manifests {
+		if manifest.Mapping.GroupVersionKind.GroupKind() == info.Mapping.GroupVersionKind.GroupKind() && manifest.Namespace == info.Namespace && manifest.Name == info.Name {
+			return manifest
+		}
+	}
+
+	return nil
+}
+
+// getImmutableFieldsConflicts patches the resource with the manifest using the server-side dry-run,
+// so that the immutable fields are validated by the kubernetes api server itself.
+// The fields missing in the manifest are kept as helm does for the adopted resources.
+func getImmutableFieldsConflicts(manifest *resource.Info) ([]string, error) {
+	data, err := json.Marshal(manifest.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = resource.NewHelper(manifest.Client, manifest.Mapping).DryRun(true).Patch(manifest.Namespace, manifest.Name, types.MergePatchType, data, nil)
+	if err == nil {
+		return nil, nil
+	}
+
+	if fields := immutableFieldsFromError(err); len(fields) != 0 {
+		return fields, nil
+	}
+
+	return nil, fmt.Errorf("error checking %s update to the manifest: %s", manifest.ObjectName(), err)
+}
+
+// immutableFieldsFromError returns the fields of the invalid resource error caused by the change of the immutable fields.
+func immutableFieldsFromError(err error) []string {
+	statusErr, ok := err.(apierrors.APIStatus)
+	if !ok || !apierrors.IsInvalid(err) || statusErr.Status().Details == nil {
+		return nil
+	}
+
+	var fields []string
+	for _, cause := range statusErr.Status().Details.Causes {
+		if strings.Contains(cause.Message, "immutable") {
+			fields = append(fields, cause.Field)
+		}
+	}
+
+	return fields
+}
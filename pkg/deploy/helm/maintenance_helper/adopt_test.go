@@ -0,0 +1,100 @@
+package maintenance_helper
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/werf/werf/pkg/deploy/helm"
+)
+
+func newHelmStorageObjectMeta(name, namespace, releaseName string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"owner": "helm", "name": releaseName}}
+}
+
+func newTestResourceInfo(group, kind, namespace, name string) *resource.Info {
+	return &resource.Info{
+		Namespace: namespace,
+		Name:      name,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Group: group, Version: "v1", Kind: kind}},
+	}
+}
+
+var _ = Describe("isHelm3ReleaseExistInNamespace", func() {
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{ObjectMeta: newHelmStorageObjectMeta("sh.helm.release.v1.secret-release.v1", "ns", "secret-release")},
+		&corev1.ConfigMap{ObjectMeta: newHelmStorageObjectMeta("sh.helm.release.v1.configmap-release.v1", "ns", "configmap-release")},
+		&corev1.Secret{ObjectMeta: newHelmStorageObjectMeta("sh.helm.release.v1.other-ns-release.v1", "other", "other-ns-release")},
+	)
+
+	DescribeTable("should look up the release in the secrets and configmaps of the namespace",
+		func(releaseName string, expected bool) {
+			exist, err := isHelm3ReleaseExistInNamespace(context.Background(), client, releaseName, "ns")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(exist).To(Equal(expected))
+		},
+		Entry("secrets storage", "secret-release", true),
+		Entry("configmaps storage", "configmap-release", true),
+		Entry("release in another namespace", "other-ns-release", false),
+		Entry("deleted release", "deleted-release", false),
+	)
+})
+
+var _ = Describe("getResourceOwnerRelease", func() {
+	It("should return the release annotations", func() {
+		name, namespace := getResourceOwnerRelease(&metav1.ObjectMeta{Annotations: map[string]string{
+			helm.ReleaseNameAnnoName:      "app",
+			helm.ReleaseNamespaceAnnoName: "app-production",
+		}})
+		Expect(name).To(Equal("app"))
+		Expect(namespace).To(Equal("app-production"))
+	})
+
+	It("should return empty release for the resource created outside of helm", func() {
+		name, namespace := getResourceOwnerRelease(&metav1.ObjectMeta{})
+		Expect(name).To(BeEmpty())
+		Expect(namespace).To(BeEmpty())
+	})
+})
+
+var _ = Describe("findResourceManifest", func() {
+	deployment := newTestResourceInfo("apps", "Deployment", "ns", "backend")
+	service := newTestResourceInfo("", "Service", "ns", "backend")
+	otherNamespaceDeployment := newTestResourceInfo("apps", "Deployment", "other", "backend")
+
+	manifests := []*resource.Info{otherNamespaceDeployment, service, deployment}
+
+	It("should match the manifest by the group, kind, namespace and name", func() {
+		Expect(findResourceManifest(manifests, newTestResourceInfo("apps", "Deployment", "ns", "backend"))).To(BeIdenticalTo(deployment))
+		Expect(findResourceManifest(manifests, newTestResourceInfo("", "Service", "ns", "backend"))).To(BeIdenticalTo(service))
+		Expect(findResourceManifest(manifests, newTestResourceInfo("apps", "StatefulSet", "ns", "backend"))).To(BeNil())
+	})
+})
+
+var _ = Describe("immutableFieldsFromError", func() {
+	gk := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+	It("should return the immutable fields of the invalid resource error", func() {
+		err := apierrors.NewInvalid(gk, "backend", field.ErrorList{
+			field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable"),
+			field.Required(field.NewPath("spec", "template"), ""),
+		})
+		Expect(immutableFieldsFromError(err)).To(Equal([]string{"spec.selector"}))
+	})
+
+	It("should ignore other errors", func() {
+		Expect(immutableFieldsFromError(apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "backend"))).To(BeNil())
+		Expect(immutableFieldsFromError(errors.New("field is immutable"))).To(BeNil())
+	})
+})
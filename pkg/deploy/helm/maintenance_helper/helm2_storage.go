@@ -0,0 +1,160 @@
+package maintenance_helper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	v2_storage "k8s.io/helm/pkg/storage"
+	v2_driver "k8s.io/helm/pkg/storage/driver"
+)
+
+const (
+	helm2ConfigMapStorageType = "configmap"
+	helm2SecretStorageType    = "secret"
+	helm2MemoryStorageType    = "memory"
+
+	helm2StorageOwnerLabelSelector = "OWNER=TILLER"
+)
+
+type helm2Storage struct {
+	*v2_storage.Storage
+
+	Namespace string
+	Type      string
+}
+
+func (storage *helm2Storage) String() string {
+	return helm2StorageDescription(storage.Namespace, storage.Type)
+}
+
+func (data *Helm2ReleaseData) storageDescription() string {
+	return helm2StorageDescription(data.StorageNamespace, data.StorageType)
+}
+
+func helm2StorageDescription(namespace, storageType string) string {
+	if storageType == helm2MemoryStorageType {
+		return fmt.Sprintf("%s storage", storageType)
+	}
+
+	return fmt.Sprintf("%s storage in the %q namespace", storageType, namespace)
+}
+
+func newHelm2Storage(client kubernetes.Interface, namespace, storageType string) (*helm2Storage, error) {
+	var drv v2_driver.Driver
+	switch storageType {
+	case helm2ConfigMapStorageType:
+		drv = v2_driver.NewConfigMaps(client.CoreV1().ConfigMaps(namespace))
+	case helm2SecretStorageType:
+		drv = v2_driver.NewSecrets(client.CoreV1().Secrets(namespace))
+	case helm2MemoryStorageType:
+		return nil, fmt.Errorf("helm 2 %s is not persisted by Tiller and cannot be read: the releases are lost on Tiller restart, use %q or %q storage type", helm2StorageDescription(namespace, storageType), helm2ConfigMapStorageType, helm2SecretStorageType)
+	default:
+		return nil, fmt.Errorf("unknown helm 2 release storage type %q", storageType)
+	}
+
+	return &helm2Storage{
+		Storage:   v2_storage.Init(drv),
+		Namespace: namespace,
+		Type:      storageType,
+	}, nil
+}
+
+func (helper *MaintenanceHelper) initHelm2Storages(ctx context.Context) ([]*helm2Storage, error) {
+	if helper.v2Storages != nil {
+		return helper.v2Storages, nil
+	}
+
+	if helper.discoverReleaseStorages {
+		storages, err := discoverHelm2Storages(ctx, kube.Client, helper.Helm2ReleaseStorageNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("unable to discover helm 2 release storages: %s", err)
+		}
+
+		if len(storages) != 0 {
+			helper.v2Storages = storages
+			return helper.v2Storages, nil
+		}
+
+		logboek.Context(ctx).Info().LogF("No helm 2 release storages discovered, using the default %s\n", helm2StorageDescription(helper.Helm2ReleaseStorageNamespace, helper.Helm2ReleaseStorageType))
+	}
+
+	storage, err := newHelm2Storage(kube.Client, helper.Helm2ReleaseStorageNamespace, helper.Helm2ReleaseStorageType)
+	if err != nil {
+		return nil, err
+	}
+	helper.v2Storages = []*helm2Storage{storage}
+
+	return helper.v2Storages, nil
+}
+
+// discoverHelm2Storages looks for the configmaps and secrets created by Tiller in all namespaces,
+// every namespace containing such objects is considered as the helm 2 release storage of the corresponding type.
+// If the user is not allowed to list the objects cluster-wide, only the fallback namespace is inspected.
+func discoverHelm2Storages(ctx context.Context, client kubernetes.Interface, fallbackNamespace string) ([]*helm2Storage, error) {
+	var storages []*helm2Storage
+	for _, storageType := range []string{helm2ConfigMapStorageType, helm2SecretStorageType} {
+		namespaces, err := discoverHelm2StorageNamespaces(ctx, client, storageType, metav1.NamespaceAll)
+		if apierrors.IsForbidden(err) {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to list helm 2 release %ss in all namespaces, looking in the %q namespace only: %s\n", storageType, fallbackNamespace, err)
+			namespaces, err = discoverHelm2StorageNamespaces(ctx, client, storageType, fallbackNamespace)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing helm 2 release %ss: %s", storageType, err)
+		}
+
+		for _, namespace := range namespaces {
+			storage, err := newHelm2Storage(client, namespace, storageType)
+			if err != nil {
+				return nil, err
+			}
+
+			logboek.Context(ctx).Default().LogFDetails("Discovered helm 2 release storage: %s\n", storage)
+			storages = append(storages, storage)
+		}
+	}
+
+	return storages, nil
+}
+
+// discoverHelm2StorageNamespaces returns the sorted namespaces containing the Tiller objects of the storage type.
+func discoverHelm2StorageNamespaces(ctx context.Context, client kubernetes.Interface, storageType, namespace string) ([]string, error) {
+	listOpts := metav1.ListOptions{LabelSelector: helm2StorageOwnerLabelSelector}
+
+	namespacesSet := map[string]bool{}
+	switch storageType {
+	case helm2ConfigMapStorageType:
+		configMaps, err := client.CoreV1().ConfigMaps(namespace).List(ctx, listOpts)
+		if err != nil {
+			return nil, err
+		}
+		for _, cm := range configMaps.Items {
+			namespacesSet[cm.Namespace] = true
+		}
+	case helm2SecretStorageType:
+		secrets, err := client.CoreV1().Secrets(namespace).List(ctx, listOpts)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets.Items {
+			namespacesSet[secret.Namespace] = true
+		}
+	default:
+		return nil, fmt.Errorf("unknown helm 2 release storage type %q", storageType)
+	}
+
+	var namespaces []string
+	for ns := range namespacesSet {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
@@ -0,0 +1,102 @@
+package maintenance_helper
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8s_testing "k8s.io/client-go/testing"
+)
+
+func newTillerObjectMeta(name, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"OWNER": "TILLER", "NAME": name}}
+}
+
+func helm2StoragesDescriptions(storages []*helm2Storage) []string {
+	var res []string
+	for _, storage := range storages {
+		res = append(res, storage.String())
+	}
+	return res
+}
+
+// forbidClusterWideList makes the fake client reject the list requests of the resource in all namespaces.
+func forbidClusterWideList(client *fake.Clientset, resource string) {
+	client.PrependReactor("list", resource, func(action k8s_testing.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != metav1.NamespaceAll {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: resource}, "", nil)
+	})
+}
+
+var _ = Describe("discoverHelm2Storages", func() {
+	var client *fake.Clientset
+
+	BeforeEach(func() {
+		client = fake.NewSimpleClientset(
+			&corev1.ConfigMap{ObjectMeta: newTillerObjectMeta("app.v1", "tiller-a")},
+			&corev1.ConfigMap{ObjectMeta: newTillerObjectMeta("app.v1", "kube-system")},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}},
+			&corev1.Secret{ObjectMeta: newTillerObjectMeta("app.v1", "tiller-b")},
+		)
+	})
+
+	It("should discover the storages in all namespaces", func() {
+		storages, err := discoverHelm2Storages(context.Background(), client, "kube-system")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(helm2StoragesDescriptions(storages)).Should(Equal([]string{
+			`configmap storage in the "kube-system" namespace`,
+			`configmap storage in the "tiller-a" namespace`,
+			`secret storage in the "tiller-b" namespace`,
+		}))
+	})
+
+	It("should look in the fallback namespace only if the cluster-wide list is forbidden", func() {
+		forbidClusterWideList(client, "configmaps")
+		forbidClusterWideList(client, "secrets")
+
+		storages, err := discoverHelm2Storages(context.Background(), client, "kube-system")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(helm2StoragesDescriptions(storages)).Should(Equal([]string{
+			`configmap storage in the "kube-system" namespace`,
+		}))
+	})
+
+	It("should fail on the other list errors", func() {
+		client.PrependReactor("list", "secrets", func(action k8s_testing.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewServiceUnavailable("unavailable")
+		})
+
+		_, err := discoverHelm2Storages(context.Background(), client, "kube-system")
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("error listing helm 2 release secrets"))
+	})
+})
+
+var _ = Describe("newHelm2Storage", func() {
+	It("should read the releases from the configmap storage", func() {
+		storage, err := newHelm2Storage(fake.NewSimpleClientset(), "kube-system", helm2ConfigMapStorageType)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = storage.History("app")
+		Ω(IsReleaseNotFoundErr(err) || err == nil).Should(BeTrue())
+	})
+
+	It("should reject the memory storage", func() {
+		_, err := newHelm2Storage(fake.NewSimpleClientset(), "kube-system", helm2MemoryStorageType)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("not persisted"))
+	})
+
+	It("should reject the unknown storage type", func() {
+		_, err := newHelm2Storage(fake.NewSimpleClientset(), "kube-system", "sql")
+		Ω(err).Should(MatchError(`unknown helm 2 release storage type "sql"`))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"k8s.io/cli-runtime/pkg/resource"
@@ -19,8 +20,6 @@ import (
 
 	v2_rspb "k8s.io/helm/pkg/proto/hapi/release"
 	v2_releaseutil "k8s.io/helm/pkg/releaseutil"
-	v2_storage "k8s.io/helm/pkg/storage"
-	v2_driver "k8s.io/helm/pkg/storage/driver"
 )
 
 func IsReleaseNotFoundErr(err error) bool {
@@ -29,6 +28,10 @@ func IsReleaseNotFoundErr(err error) bool {
 
 type Helm2ReleaseData struct {
 	Release *v2_rspb.Release
+
+	// StorageNamespace and StorageType describe the helm 2 release storage where the release was found.
+	StorageNamespace string
+	StorageType      string
 }
 
 type Helm3ReleaseData struct {
@@ -39,12 +42,18 @@ type MaintenanceHelperOptions struct {
 	Helm2ReleaseStorageNamespace string
 	Helm2ReleaseStorageType      string
 	KubeConfigOptions            kube.KubeConfigOptions
+
+	// DiscoverHelm2ReleaseStorages enables the lookup of the helm 2 release storages (configmaps and secrets) in all namespaces
+	// when the storage namespace and the storage type are not specified explicitly.
+	DiscoverHelm2ReleaseStorages bool
 }
 
 func NewMaintenanceHelper(v3ActionConfig *v3_action.Configuration, opts MaintenanceHelperOptions) *MaintenanceHelper {
+	discoverReleaseStorages := opts.DiscoverHelm2ReleaseStorages && opts.Helm2ReleaseStorageType == "" && opts.Helm2ReleaseStorageNamespace == ""
+
 	releaseStorageType := opts.Helm2ReleaseStorageType
 	if releaseStorageType == "" {
-		releaseStorageType = "configmap"
+		releaseStorageType = helm2ConfigMapStorageType
 	}
 
 	releaseStorageNamespace := opts.Helm2ReleaseStorageNamespace
@@ -56,6 +65,7 @@ func NewMaintenanceHelper(v3ActionConfig *v3_action.Configuration, opts Maintena
 		Helm2ReleaseStorageNamespace: releaseStorageNamespace,
 		Helm2ReleaseStorageType:      releaseStorageType,
 		KubeConfigOptions:            opts.KubeConfigOptions,
+		discoverReleaseStorages:      discoverReleaseStorages,
 		v3ActionConfig:               v3ActionConfig,
 	}
 }
@@ -66,27 +76,9 @@ type MaintenanceHelper struct {
 	Helm2ReleaseStorageNamespace string
 	Helm2ReleaseStorageType      string
 
-	v2Storage      *v2_storage.Storage
-	v3ActionConfig *v3_action.Configuration
-}
-
-func (helper *MaintenanceHelper) initHelm2Storage() (*v2_storage.Storage, error) {
-	if helper.v2Storage != nil {
-		return helper.v2Storage, nil
-	}
-
-	var drv v2_driver.Driver
-	switch helper.Helm2ReleaseStorageType {
-	case "configmap":
-		drv = v2_driver.NewConfigMaps(kube.Client.CoreV1().ConfigMaps(helper.Helm2ReleaseStorageNamespace))
-	case "secret":
-		drv = v2_driver.NewSecrets(kube.Client.CoreV1().Secrets(helper.Helm2ReleaseStorageNamespace))
-	default:
-		return nil, fmt.Errorf("unknown helm 2 release v2Storage type %q", helper.Helm2ReleaseStorageType)
-	}
-	helper.v2Storage = v2_storage.Init(drv)
-
-	return helper.v2Storage, nil
+	discoverReleaseStorages bool
+	v2Storages              []*helm2Storage
+	v3ActionConfig          *v3_action.Configuration
 }
 
 func (helper *MaintenanceHelper) getResourcesFactory() (util.Factory, error) {
@@ -114,22 +106,24 @@ func (helper *MaintenanceHelper) CheckHelm3StorageAvailable(ctx context.Context)
 }
 
 func (helper *MaintenanceHelper) CheckHelm2StorageAvailable(ctx context.Context) (bool, error) {
-	storage, err := helper.initHelm2Storage()
+	storages, err := helper.initHelm2Storages(ctx)
 	if err != nil {
-		return false, fmt.Errorf("error initializing helm 2 v2Storage: %s", err)
+		return false, fmt.Errorf("error initializing helm 2 storage: %s", err)
 	}
 
-	logboek.Context(ctx).Debug().LogProcess("Checking helm 2 storage availability using history command").Do(func() {
-		_, err = storage.History("no-such-release")
-	})
+	for _, storage := range storages {
+		logboek.Context(ctx).Debug().LogProcess("Checking helm 2 %s availability using history command", storage).Do(func() {
+			_, err = storage.History("no-such-release")
+		})
 
-	if IsReleaseNotFoundErr(err) || err == nil {
-		logboek.Context(ctx).Info().LogFDetails("+ Helm 2 storage available\n")
-		return true, nil
+		if !IsReleaseNotFoundErr(err) && err != nil {
+			logboek.Context(ctx).Info().LogFDetails("- Helm 2 %s is not available: %s\n", storage, err)
+			return false, nil
+		}
 	}
 
-	logboek.Context(ctx).Info().LogFDetails("- Helm 2 storage is not available: %s\n", err)
-	return false, nil
+	logboek.Context(ctx).Info().LogFDetails("+ Helm 2 storage available\n")
+	return true, nil
 }
 
 func (helper *MaintenanceHelper) IsHelm3ReleaseExist(ctx context.Context, releaseName string) (bool, error) {
@@ -151,24 +145,28 @@ func (helper *MaintenanceHelper) IsHelm3ReleaseExist(ctx context.Context, releas
 }
 
 func (helper *MaintenanceHelper) IsHelm2ReleaseExist(ctx context.Context, releaseName string) (bool, error) {
-	storage, err := helper.initHelm2Storage()
+	storages, err := helper.initHelm2Storages(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	logboek.Context(ctx).Debug().LogProcess("Getting helm 2 release %q history", releaseName).Do(func() {
-		_, err = storage.History(releaseName)
-	})
+	for _, storage := range storages {
+		logboek.Context(ctx).Debug().LogProcess("Getting helm 2 release %q history from the %s", releaseName, storage).Do(func() {
+			_, err = storage.History(releaseName)
+		})
 
-	if IsReleaseNotFoundErr(err) {
-		return false, nil
-	}
+		if IsReleaseNotFoundErr(err) {
+			continue
+		}
 
-	if err != nil {
-		return false, fmt.Errorf("error getting helm 2 release %q history: %s", releaseName, err)
+		if err != nil {
+			return false, fmt.Errorf("error getting helm 2 release %q history: %s", releaseName, err)
+		}
+
+		return true, nil
 	}
 
-	return true, nil
+	return false, nil
 }
 
 func (helper *MaintenanceHelper) CreateHelm3ReleaseMetadataFromHelm2Release(ctx context.Context, release, namespace string, releaseData *Helm2ReleaseData) error {
@@ -185,25 +183,77 @@ func (helper *MaintenanceHelper) CreateHelm3ReleaseMetadataFromHelm2Release(ctx
 }
 
 func (helper *MaintenanceHelper) GetHelm2ReleaseData(ctx context.Context, releaseName string) (*Helm2ReleaseData, error) {
-	storage, err := helper.initHelm2Storage()
+	storages, err := helper.initHelm2Storages(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	releases, err := storage.ListFilterAll(func(rel *v2_rspb.Release) bool {
-		return rel.Name == releaseName
-	})
+	var res *Helm2ReleaseData
+	for _, storage := range storages {
+		releases, err := storage.ListFilterAll(func(rel *v2_rspb.Release) bool {
+			return rel.Name == releaseName
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(releases) == 0 {
+			continue
+		}
+
+		if res != nil {
+			return nil, fmt.Errorf("release found both in the %s and in the %s", res.storageDescription(), storage)
+		}
+
+		v2_releaseutil.Reverse(releases, v2_releaseutil.SortByRevision)
+
+		res = &Helm2ReleaseData{Release: releases[0], StorageNamespace: storage.Namespace, StorageType: storage.Type}
+	}
+
+	if res == nil {
+		return nil, fmt.Errorf("release not found")
+	}
+
+	return res, nil
+}
+
+// GetHelm2ReleasesData returns the latest revision of every helm 2 release in the storage sorted by the release name.
+// Releases deleted without the purge option are skipped, because there are no resources left to migrate.
+func (helper *MaintenanceHelper) GetHelm2ReleasesData(ctx context.Context) ([]*Helm2ReleaseData, error) {
+	storages, err := helper.initHelm2Storages(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(releases) == 0 {
-		return nil, fmt.Errorf("release not found")
+	var res []*Helm2ReleaseData
+	for _, storage := range storages {
+		releases, err := storage.ListReleases()
+		if err != nil {
+			return nil, fmt.Errorf("error listing helm 2 releases in the %s: %s", storage, err)
+		}
+
+		latestReleases := map[string]*v2_rspb.Release{}
+		for _, rel := range releases {
+			if latest, hasKey := latestReleases[rel.Name]; !hasKey || rel.Version > latest.Version {
+				latestReleases[rel.Name] = rel
+			}
+		}
+
+		for _, rel := range latestReleases {
+			if rel.GetInfo().GetStatus().GetCode() == v2_rspb.Status_DELETED {
+				logboek.Context(ctx).Info().LogF("Skipping deleted helm 2 release %q found in the %s\n", rel.Name, storage)
+				continue
+			}
+
+			res = append(res, &Helm2ReleaseData{Release: rel, StorageNamespace: storage.Namespace, StorageType: storage.Type})
+		}
 	}
 
-	v2_releaseutil.Reverse(releases, v2_releaseutil.SortByRevision)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Release.Name < res[j].Release.Name
+	})
 
-	return &Helm2ReleaseData{Release: releases[0]}, nil
+	return res, nil
 }
 
 func (helper *MaintenanceHelper) BuildHelm2ResourcesInfos(releaseData *Helm2ReleaseData) ([]*resource.Info, error) {
@@ -227,25 +277,23 @@ func (helper *MaintenanceHelper) BuildHelm2ResourcesInfos(releaseData *Helm2Rele
 }
 
 func (helper *MaintenanceHelper) DeleteHelm2ReleaseMetadata(ctx context.Context, releaseName string) error {
-	storage, err := helper.initHelm2Storage()
+	storages, err := helper.initHelm2Storages(ctx)
 	if err != nil {
 		return err
 	}
 
-	releases, err := storage.ListFilterAll(func(rel *v2_rspb.Release) bool {
-		return rel.Name == releaseName
-	})
-	if err != nil {
-		return err
-	}
-
-	if len(releases) == 0 {
-		return nil
-	}
+	for _, storage := range storages {
+		releases, err := storage.ListFilterAll(func(rel *v2_rspb.Release) bool {
+			return rel.Name == releaseName
+		})
+		if err != nil {
+			return err
+		}
 
-	for _, rel := range releases {
-		if _, err := storage.Delete(rel.Name, rel.Version); err != nil {
-			return fmt.Errorf("error deleting helm 2 release %q version %d: %s", rel.Name, rel.Version, err)
+		for _, rel := range releases {
+			if _, err := storage.Delete(rel.Name, rel.Version); err != nil {
+				return fmt.Errorf("error deleting helm 2 release %q version %d from the %s: %s", rel.Name, rel.Version, storage, err)
+			}
 		}
 	}
 
@@ -0,0 +1,68 @@
+package maintenance_helper
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+	v2_rspb "k8s.io/helm/pkg/proto/hapi/release"
+)
+
+func newHelm2Release(name, namespace string, version int32, statusCode v2_rspb.Status_Code) *v2_rspb.Release {
+	return &v2_rspb.Release{
+		Name:      name,
+		Namespace: namespace,
+		Version:   version,
+		Info:      &v2_rspb.Info{Status: &v2_rspb.Status{Code: statusCode}},
+	}
+}
+
+var _ = Describe("GetHelm2ReleasesData", func() {
+	var helper *MaintenanceHelper
+
+	BeforeEach(func() {
+		client := fake.NewSimpleClientset()
+
+		configMapStorage, err := newHelm2Storage(client, "kube-system", helm2ConfigMapStorageType)
+		Ω(err).ShouldNot(HaveOccurred())
+		secretStorage, err := newHelm2Storage(client, "tiller", helm2SecretStorageType)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for _, rel := range []*v2_rspb.Release{
+			newHelm2Release("web", "web-production", 1, v2_rspb.Status_SUPERSEDED),
+			newHelm2Release("web", "web-production", 3, v2_rspb.Status_DEPLOYED),
+			newHelm2Release("web", "web-production", 2, v2_rspb.Status_SUPERSEDED),
+			newHelm2Release("deleted", "deleted", 1, v2_rspb.Status_DEPLOYED),
+			newHelm2Release("deleted", "deleted", 2, v2_rspb.Status_DELETED),
+		} {
+			Ω(configMapStorage.Create(rel)).Should(Succeed())
+		}
+		Ω(secretStorage.Create(newHelm2Release("api", "api", 1, v2_rspb.Status_FAILED))).Should(Succeed())
+
+		helper = &MaintenanceHelper{v2Storages: []*helm2Storage{configMapStorage, secretStorage}}
+	})
+
+	It("should return the latest revisions of the releases from all storages sorted by the release name", func() {
+		releasesData, err := helper.GetHelm2ReleasesData(context.Background())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(releasesData).Should(HaveLen(2))
+
+		Ω(releasesData[0].Release.Name).Should(Equal("api"))
+		Ω(releasesData[0].storageDescription()).Should(Equal(`secret storage in the "tiller" namespace`))
+
+		Ω(releasesData[1].Release.Name).Should(Equal("web"))
+		Ω(releasesData[1].Release.Version).Should(Equal(int32(3)))
+		Ω(releasesData[1].Release.Namespace).Should(Equal("web-production"))
+		Ω(releasesData[1].storageDescription()).Should(Equal(`configmap storage in the "kube-system" namespace`))
+	})
+
+	It("should return nothing for the empty storage", func() {
+		storage, err := newHelm2Storage(fake.NewSimpleClientset(), "kube-system", helm2ConfigMapStorageType)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		releasesData, err := (&MaintenanceHelper{v2Storages: []*helm2Storage{storage}}).GetHelm2ReleasesData(context.Background())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(releasesData).Should(BeEmpty())
+	})
+})
@@ -10,9 +10,16 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/werf/werf/pkg/deploy/helm"
 )
 
-func Migrate2To3(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3Namespace string, maintenanceHelper *MaintenanceHelper) error {
+type Migrate2To3Options struct {
+	// DryRun only checks the releases and prints the planned conversion without changing anything in the cluster.
+	DryRun bool
+}
+
+func Migrate2To3(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3Namespace string, maintenanceHelper *MaintenanceHelper, opts Migrate2To3Options) error {
 	foundHelm3Release, err := maintenanceHelper.IsHelm3ReleaseExist(ctx, helm3ReleaseName)
 	if err != nil {
 		return fmt.Errorf("error checking existance of helm 3 release %q: %s", helm2ReleaseName, err)
@@ -36,11 +43,17 @@ func Migrate2To3(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3N
 		return fmt.Errorf("unable to get helm 2 release %q info: %s", helm2ReleaseName, err)
 	}
 
+	logboek.Context(ctx).Default().LogFDetails("Found helm 2 release %q in the %s\n", helm2ReleaseName, releaseData.storageDescription())
+
 	infos, err := maintenanceHelper.BuildHelm2ResourcesInfos(releaseData)
 	if err != nil {
 		return fmt.Errorf("error building resources infos for release %q: %s", helm2ReleaseName, err)
 	}
 
+	if opts.DryRun {
+		return printMigrate2To3Plan(ctx, helm2ReleaseName, helm3ReleaseName, helm3Namespace, infos)
+	}
+
 	var metadataAccessor = meta.NewAccessor()
 
 	logboek.Context(ctx).LogOptionalLn()
@@ -65,8 +78,8 @@ func Migrate2To3(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3N
 			if annotations == nil {
 				annotations = make(map[string]string)
 			}
-			annotations["meta.helm.sh/release-name"] = helm3ReleaseName
-			annotations["meta.helm.sh/release-namespace"] = helm3Namespace
+			annotations[helm.ReleaseNameAnnoName] = helm3ReleaseName
+			annotations[helm.ReleaseNamespaceAnnoName] = helm3Namespace
 			if err := metadataAccessor.SetAnnotations(obj, annotations); err != nil {
 				return fmt.Errorf("error setting annotations of %s: %s", info.ObjectName(), err)
 			}
@@ -78,7 +91,7 @@ func Migrate2To3(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3N
 			if labels == nil {
 				labels = make(map[string]string)
 			}
-			labels["app.kubernetes.io/managed-by"] = "Helm"
+			labels[helm.ManagedByLabelName] = helm.ManagedByLabelValue
 			if err := metadataAccessor.SetLabels(obj, labels); err != nil {
 				return fmt.Errorf("error setting labels of %s: %s", info.ObjectName(), err)
 			}
@@ -117,3 +130,26 @@ func Migrate2To3(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3N
 
 	return nil
 }
+
+func printMigrate2To3Plan(ctx context.Context, helm2ReleaseName, helm3ReleaseName, helm3Namespace string, infos []*resource.Info) error {
+	logboek.Context(ctx).LogOptionalLn()
+	return logboek.Context(ctx).Default().LogProcess("Planned migration of helm 2 release %q into helm 3 release %q in the %q namespace", helm2ReleaseName, helm3ReleaseName, helm3Namespace).DoError(func() error {
+		logboek.Context(ctx).Default().LogF("Adopt %d resources:\n", len(infos))
+		for _, info := range infos {
+			_, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+			if apierrors.IsNotFound(err) {
+				logboek.Context(ctx).Default().LogF("  - %s (not found: will be ignored)\n", info.ObjectName())
+				continue
+			} else if err != nil {
+				return fmt.Errorf("error getting resource %s spec from %q namespace: %s", info.ObjectName(), info.Namespace, err)
+			}
+
+			logboek.Context(ctx).Default().LogF("  - %s\n", info.ObjectName())
+		}
+
+		logboek.Context(ctx).Default().LogF("Create helm 3 release %q in the %q namespace\n", helm3ReleaseName, helm3Namespace)
+		logboek.Context(ctx).Default().LogF("Delete helm 2 metadata for release %q\n", helm2ReleaseName)
+
+		return nil
+	})
+}
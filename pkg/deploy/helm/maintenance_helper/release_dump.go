@@ -0,0 +1,161 @@
+package maintenance_helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/logboek"
+
+	v3_rspb "helm.sh/helm/v3/pkg/release"
+	v3_releaseutil "helm.sh/helm/v3/pkg/releaseutil"
+)
+
+const (
+	releaseDumpFile   = "release.json"
+	manifestDumpFile  = "manifest.yaml"
+	valuesDumpFile    = "values.yaml"
+	hooksDumpFile     = "hooks.yaml"
+	revisionDirPrefix = "v"
+)
+
+type ImportHelm3ReleaseOptions struct {
+	// Overwrite replaces the revisions already existing in the helm 3 storage.
+	Overwrite bool
+}
+
+// ExportHelm3Release saves every stored revision of the helm 3 release into the dir named as the revision (v1, v2, ...) inside the destination dir.
+// The release.json file contains the whole release record and is used by ImportHelm3Release,
+// manifest.yaml, hooks.yaml and values.yaml files are saved for inspection only.
+func (helper *MaintenanceHelper) ExportHelm3Release(ctx context.Context, releaseName, destDir string) error {
+	releases, err := helper.v3ActionConfig.Releases.History(releaseName)
+	if err != nil {
+		return fmt.Errorf("error getting helm 3 release %q history: %s", releaseName, err)
+	}
+
+	v3_releaseutil.SortByRevision(releases)
+
+	for _, rel := range releases {
+		revisionDir := filepath.Join(destDir, fmt.Sprintf("%s%d", revisionDirPrefix, rel.Version))
+
+		if err := logboek.Context(ctx).Default().LogProcess("Exporting release %q revision %d into %s", rel.Name, rel.Version, revisionDir).DoError(func() error {
+			return exportHelm3ReleaseRevision(rel, revisionDir)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportHelm3Release creates the helm 3 release revisions from the release.json files previously saved by ExportHelm3Release.
+// The revisions already existing in the storage are skipped unless the overwrite option is specified.
+func (helper *MaintenanceHelper) ImportHelm3Release(ctx context.Context, srcDir, namespace string, opts ImportHelm3ReleaseOptions) error {
+	releases, err := readHelm3ReleaseRevisions(srcDir)
+	if err != nil {
+		return err
+	}
+
+	if len(releases) == 0 {
+		return fmt.Errorf("no release revisions found in %s", srcDir)
+	}
+
+	for _, rel := range releases {
+		if namespace != "" && rel.Namespace != namespace {
+			return fmt.Errorf("release %q revision %d belongs to the namespace %q, but %q namespace specified", rel.Name, rel.Version, rel.Namespace, namespace)
+		}
+
+		_, err := helper.v3ActionConfig.Releases.Get(rel.Name, rel.Version)
+		exists := err == nil
+		if err != nil && !IsReleaseNotFoundErr(err) {
+			return fmt.Errorf("error getting helm 3 release %q revision %d: %s", rel.Name, rel.Version, err)
+		}
+
+		switch {
+		case exists && !opts.Overwrite:
+			logboek.Context(ctx).Default().LogF("Skipping release %q revision %d: already exists\n", rel.Name, rel.Version)
+		case exists:
+			logboek.Context(ctx).Default().LogF("Overwriting release %q revision %d\n", rel.Name, rel.Version)
+			if err := helper.v3ActionConfig.Releases.Update(rel); err != nil {
+				return fmt.Errorf("error updating helm 3 release %q revision %d: %s", rel.Name, rel.Version, err)
+			}
+		default:
+			logboek.Context(ctx).Default().LogF("Importing release %q revision %d\n", rel.Name, rel.Version)
+			if err := helper.v3ActionConfig.Releases.Create(rel); err != nil {
+				return fmt.Errorf("error saving helm 3 release %q revision %d into storage: %s", rel.Name, rel.Version, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func exportHelm3ReleaseRevision(rel *v3_rspb.Release, revisionDir string) error {
+	if err := os.MkdirAll(revisionDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %q: %s", revisionDir, err)
+	}
+
+	releaseData, err := json.MarshalIndent(rel, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal release: %s", err)
+	}
+
+	valuesData, err := yaml.Marshal(rel.Config)
+	if err != nil {
+		return fmt.Errorf("unable to marshal release values: %s", err)
+	}
+
+	var hooks []string
+	for _, hook := range rel.Hooks {
+		hooks = append(hooks, fmt.Sprintf("---\n# Source: %s\n%s\n", hook.Path, strings.TrimSpace(hook.Manifest)))
+	}
+
+	for fileName, data := range map[string][]byte{
+		releaseDumpFile:  append(releaseData, []byte("\n")...),
+		manifestDumpFile: []byte(rel.Manifest),
+		valuesDumpFile:   valuesData,
+		hooksDumpFile:    []byte(strings.Join(hooks, "")),
+	} {
+		path := filepath.Join(revisionDir, fileName)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("unable to write %q: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+func readHelm3ReleaseRevisions(srcDir string) ([]*v3_rspb.Release, error) {
+	paths, err := filepath.Glob(filepath.Join(srcDir, revisionDirPrefix+"*", releaseDumpFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []*v3_rspb.Release
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %q: %s", path, err)
+		}
+
+		rel := &v3_rspb.Release{}
+		if err := json.Unmarshal(data, rel); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal release from %q: %s", path, err)
+		}
+
+		releases = append(releases, rel)
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].Version < releases[j].Version
+	})
+
+	return releases, nil
+}
@@ -0,0 +1,126 @@
+package maintenance_helper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/werf/logboek"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func newReleaseDumpTestHelper() *MaintenanceHelper {
+	return &MaintenanceHelper{v3ActionConfig: &action.Configuration{Releases: storage.Init(driver.NewMemory())}}
+}
+
+func newReleaseDumpTestRelease(version int, status release.Status, replicas string) *release.Release {
+	return &release.Release{
+		Name:      "app",
+		Namespace: "production",
+		Version:   version,
+		Info:      &release.Info{Status: status},
+		Config:    map[string]interface{}{"replicas": replicas},
+		Manifest:  "---\n# Source: app/templates/deployment.yaml\nkind: Deployment\n",
+		Hooks: []*release.Hook{
+			{Name: "migrate", Path: "app/templates/job.yaml", Manifest: "kind: Job\n"},
+		},
+	}
+}
+
+var _ = Describe("release dump", func() {
+	var ctx context.Context
+	var dir string
+
+	BeforeEach(func() {
+		ctx = logboek.NewContext(context.Background(), logboek.DefaultLogger())
+
+		var err error
+		dir, err = ioutil.TempDir("", "werf-release-dump-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	exportRelease := func() {
+		helper := newReleaseDumpTestHelper()
+		Ω(helper.v3ActionConfig.Releases.Create(newReleaseDumpTestRelease(2, release.StatusDeployed, "2"))).Should(Succeed())
+		Ω(helper.v3ActionConfig.Releases.Create(newReleaseDumpTestRelease(1, release.StatusSuperseded, "1"))).Should(Succeed())
+
+		Ω(helper.ExportHelm3Release(ctx, "app", dir)).Should(Succeed())
+	}
+
+	It("should export every revision into the revision dir", func() {
+		exportRelease()
+
+		for _, revisionDir := range []string{"v1", "v2"} {
+			for _, fileName := range []string{releaseDumpFile, manifestDumpFile, valuesDumpFile, hooksDumpFile} {
+				Ω(filepath.Join(dir, revisionDir, fileName)).Should(BeAnExistingFile())
+			}
+		}
+
+		Ω(ioutil.ReadFile(filepath.Join(dir, "v2", valuesDumpFile))).Should(Equal([]byte("replicas: \"2\"\n")))
+		Ω(ioutil.ReadFile(filepath.Join(dir, "v2", manifestDumpFile))).Should(Equal([]byte("---\n# Source: app/templates/deployment.yaml\nkind: Deployment\n")))
+		Ω(ioutil.ReadFile(filepath.Join(dir, "v2", hooksDumpFile))).Should(Equal([]byte("---\n# Source: app/templates/job.yaml\nkind: Job\n")))
+	})
+
+	It("should import the exported revisions", func() {
+		exportRelease()
+
+		helper := newReleaseDumpTestHelper()
+		Ω(helper.ImportHelm3Release(ctx, dir, "production", ImportHelm3ReleaseOptions{})).Should(Succeed())
+
+		history, err := helper.v3ActionConfig.Releases.History("app")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(history).Should(HaveLen(2))
+
+		rel, err := helper.v3ActionConfig.Releases.Get("app", 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(rel.Info.Status).Should(Equal(release.StatusDeployed))
+		Ω(rel.Config).Should(Equal(map[string]interface{}{"replicas": "2"}))
+		Ω(rel.Hooks).Should(HaveLen(1))
+		Ω(rel.Hooks[0].Name).Should(Equal("migrate"))
+	})
+
+	It("should skip the existing revisions unless overwrite specified", func() {
+		exportRelease()
+
+		helper := newReleaseDumpTestHelper()
+		Ω(helper.v3ActionConfig.Releases.Create(newReleaseDumpTestRelease(2, release.StatusFailed, "existing"))).Should(Succeed())
+
+		Ω(helper.ImportHelm3Release(ctx, dir, "", ImportHelm3ReleaseOptions{})).Should(Succeed())
+		rel, err := helper.v3ActionConfig.Releases.Get("app", 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(rel.Config).Should(Equal(map[string]interface{}{"replicas": "existing"}))
+
+		_, err = helper.v3ActionConfig.Releases.Get("app", 1)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(helper.ImportHelm3Release(ctx, dir, "", ImportHelm3ReleaseOptions{Overwrite: true})).Should(Succeed())
+		rel, err = helper.v3ActionConfig.Releases.Get("app", 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(rel.Config).Should(Equal(map[string]interface{}{"replicas": "2"}))
+		Ω(rel.Info.Status).Should(Equal(release.StatusDeployed))
+	})
+
+	It("should not import the revisions into another namespace", func() {
+		exportRelease()
+
+		err := newReleaseDumpTestHelper().ImportHelm3Release(ctx, dir, "staging", ImportHelm3ReleaseOptions{})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(`belongs to the namespace "production", but "staging" namespace specified`))
+	})
+
+	It("should fail when there are no exported revisions", func() {
+		err := newReleaseDumpTestHelper().ImportHelm3Release(ctx, dir, "", ImportHelm3ReleaseOptions{})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("no release revisions found"))
+	})
+})
@@ -0,0 +1,208 @@
+package maintenance_helper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/werf/logboek"
+
+	"helm.sh/helm/v3/pkg/release"
+	v3_releaseutil "helm.sh/helm/v3/pkg/releaseutil"
+	helm_time "helm.sh/helm/v3/pkg/time"
+
+	"github.com/werf/werf/pkg/deploy/helm"
+)
+
+type RepairHelm3ReleaseOptions struct {
+	// DryRun only prints the diff of the stored and the rebuilt manifests without saving the release.
+	DryRun bool
+}
+
+// RepairHelm3Release rebuilds the manifest of the last helm 3 release revision from the current cluster state
+// and saves it as the new release revision.
+// Helm performs the three-way merge of the stored manifest, the live object and the new manifest, so that the stored manifest
+// which drifted from the cluster state results in wrong patches. Each stored resource is replaced with the live object
+// pruned to the fields of the stored resource, so that the fields set by the cluster (status, defaults, etc.) are not added into the manifest.
+// Resources which are not found in the cluster or cannot be parsed are kept as is.
+func (helper *MaintenanceHelper) RepairHelm3Release(ctx context.Context, releaseName string, opts RepairHelm3ReleaseOptions) error {
+	rel, err := helper.v3ActionConfig.Releases.Last(releaseName)
+	if err != nil {
+		return fmt.Errorf("error getting helm 3 release %q: %s", releaseName, err)
+	}
+
+	manifests := v3_releaseutil.SplitManifests(rel.Manifest)
+
+	var keys []string
+	for key := range manifests {
+		keys = append(keys, key)
+	}
+	sort.Sort(v3_releaseutil.BySplitManifestsOrder(keys))
+
+	var repairedManifest bytes.Buffer
+	for _, key := range keys {
+		manifest, err := helper.repairManifest(ctx, manifests[key])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&repairedManifest, "---\n%s\n", manifest)
+	}
+
+	if opts.DryRun {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(rel.Manifest),
+			B:        difflib.SplitLines(repairedManifest.String()),
+			FromFile: fmt.Sprintf("%s revision %d stored manifest", rel.Name, rel.Version),
+			ToFile:   fmt.Sprintf("%s revision %d repaired manifest", rel.Name, rel.Version+1),
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+
+		logboek.Context(ctx).Default().LogF("%s", diff)
+
+		return nil
+	}
+
+	newRel := newRepairedRelease(rel, repairedManifest.String())
+
+	return logboek.Context(ctx).Default().LogProcess("Saving release %q revision %d", newRel.Name, newRel.Version).DoError(func() error {
+		if err := helper.v3ActionConfig.Releases.Create(newRel); err != nil {
+			return fmt.Errorf("error creating helm 3 release %q revision %d: %s", newRel.Name, newRel.Version, err)
+		}
+
+		if rel.Info.Status == release.StatusDeployed {
+			rel.Info.Status = release.StatusSuperseded
+			if err := helper.v3ActionConfig.Releases.Update(rel); err != nil {
+				return fmt.Errorf("error updating helm 3 release %q revision %d: %s", rel.Name, rel.Version, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// newRepairedRelease returns the next revision of the release with the repaired manifest,
+// so that the previous revision is kept in the history and the release can be rolled back to it.
+func newRepairedRelease(rel *release.Release, manifest string) *release.Release {
+	info := *rel.Info
+	info.LastDeployed = helm_time.Now()
+	info.Description = "Repaired from the cluster state"
+
+	return &release.Release{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Chart:     rel.Chart,
+		Config:    rel.Config,
+		Manifest:  manifest,
+		Hooks:     rel.Hooks,
+		Version:   rel.Version + 1,
+		Info:      &info,
+	}
+}
+
+func (helper *MaintenanceHelper) repairManifest(ctx context.Context, manifest string) (string, error) {
+	resources, err := helper.v3ActionConfig.KubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: unable to parse stored manifest, keeping it as is: %s\n", err)
+		return manifest, nil
+	}
+
+	var objects []string
+	for _, info := range resources {
+		stored, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			return "", fmt.Errorf("unexpected object type %T", info.Object)
+		}
+		stored = stored.DeepCopy()
+
+		if err := info.Get(); apierrors.IsNotFound(err) {
+			logboek.Context(ctx).Warn().LogF("WARNING: %s not found in the cluster, keeping stored manifest\n", info.ObjectName())
+			return manifest, nil
+		} else if err != nil {
+			return "", fmt.Errorf("error getting %s: %s", info.ObjectName(), err)
+		}
+
+		live, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			return "", fmt.Errorf("unexpected object type %T", info.Object)
+		}
+
+		data, err := yaml.Marshal(pruneToStoredFields(live.Object, stored.Object))
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal %s: %s", info.ObjectName(), err)
+		}
+
+		logboek.Context(ctx).Default().LogF("%s rebuilt from the cluster state\n", info.ObjectName())
+		objects = append(objects, strings.TrimSpace(string(data)))
+	}
+
+	return helm.ReplaceManifestContent(manifest, strings.Join(objects, "\n---\n")), nil
+}
+
+// pruneToStoredFields returns the live value pruned to the fields of the stored value.
+// List items are matched by the name field if there is one, otherwise by the index.
+func pruneToStoredFields(live, stored interface{}) interface{} {
+	switch storedValue := stored.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+
+		res := map[string]interface{}{}
+		for key, storedItem := range storedValue {
+			if liveItem, hasKey := liveMap[key]; hasKey {
+				res[key] = pruneToStoredFields(liveItem, storedItem)
+			}
+		}
+
+		return res
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+
+		res := []interface{}{}
+		for ind, storedItem := range storedValue {
+			if liveItem, found := findLiveListItem(liveList, storedItem, ind); found {
+				res = append(res, pruneToStoredFields(liveItem, storedItem))
+			}
+		}
+
+		return res
+	default:
+		return live
+	}
+}
+
+func findLiveListItem(liveList []interface{}, storedItem interface{}, ind int) (interface{}, bool) {
+	if storedMap, ok := storedItem.(map[string]interface{}); ok {
+		if name, hasName := storedMap["name"]; hasName {
+			for _, liveItem := range liveList {
+				if liveMap, ok := liveItem.(map[string]interface{}); ok && liveMap["name"] == name {
+					return liveItem, true
+				}
+			}
+
+			return nil, false
+		}
+	}
+
+	if ind < len(liveList) {
+		return liveList[ind], true
+	}
+
+	return nil, false
+}
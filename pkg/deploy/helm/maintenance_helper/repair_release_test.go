@@ -0,0 +1,113 @@
+package maintenance_helper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+var _ = DescribeTable("pruneToStoredFields",
+	func(live, stored, expected interface{}) {
+		Ω(pruneToStoredFields(live, stored)).Should(Equal(expected))
+	},
+	Entry("drops the fields set by the cluster",
+		map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "app", "uid": "123", "resourceVersion": "1"},
+			"spec":     map[string]interface{}{"replicas": int64(3), "revisionHistoryLimit": int64(10)},
+			"status":   map[string]interface{}{"readyReplicas": int64(3)},
+		},
+		map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "app"},
+			"spec":     map[string]interface{}{"replicas": int64(1)},
+		},
+		map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "app"},
+			"spec":     map[string]interface{}{"replicas": int64(3)},
+		},
+	),
+	Entry("drops the stored fields missing in the live object",
+		map[string]interface{}{"data": map[string]interface{}{"a": "1"}},
+		map[string]interface{}{"data": map[string]interface{}{"a": "0", "b": "0"}},
+		map[string]interface{}{"data": map[string]interface{}{"a": "1"}},
+	),
+	Entry("matches the list items by name",
+		map[string]interface{}{"containers": []interface{}{
+			map[string]interface{}{"name": "sidecar", "image": "sidecar:2", "imagePullPolicy": "Always"},
+			map[string]interface{}{"name": "app", "image": "app:2", "imagePullPolicy": "Always"},
+		}},
+		map[string]interface{}{"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:1"},
+			map[string]interface{}{"name": "removed", "image": "removed:1"},
+		}},
+		map[string]interface{}{"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:2"},
+		}},
+	),
+	Entry("matches the list items without name by index",
+		map[string]interface{}{"args": []interface{}{"--live", "--extra"}},
+		map[string]interface{}{"args": []interface{}{"--stored"}},
+		map[string]interface{}{"args": []interface{}{"--live"}},
+	),
+	Entry("takes the live value of the changed type",
+		map[string]interface{}{"port": "http"},
+		map[string]interface{}{"port": map[string]interface{}{"number": int64(80)}},
+		map[string]interface{}{"port": "http"},
+	),
+)
+
+var _ = DescribeTable("findLiveListItem",
+	func(liveList []interface{}, storedItem interface{}, ind int, expectedItem interface{}, expectedFound bool) {
+		item, found := findLiveListItem(liveList, storedItem, ind)
+		Ω(found).Should(Equal(expectedFound))
+		if expectedItem == nil {
+			Ω(item).Should(BeNil())
+		} else {
+			Ω(item).Should(Equal(expectedItem))
+		}
+	},
+	Entry("by name",
+		[]interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}},
+		map[string]interface{}{"name": "b"}, 0,
+		map[string]interface{}{"name": "b"}, true,
+	),
+	Entry("name not found",
+		[]interface{}{map[string]interface{}{"name": "a"}},
+		map[string]interface{}{"name": "b"}, 0,
+		nil, false,
+	),
+	Entry("by index",
+		[]interface{}{"a", "b"},
+		"c", 1,
+		"b", true,
+	),
+	Entry("index out of range",
+		[]interface{}{"a"},
+		"c", 1,
+		nil, false,
+	),
+)
+
+var _ = Describe("newRepairedRelease", func() {
+	It("should return the next revision with the repaired manifest", func() {
+		rel := &release.Release{
+			Name:      "app",
+			Namespace: "ns",
+			Manifest:  "stored",
+			Version:   3,
+			Info:      &release.Info{Status: release.StatusDeployed, Description: "Upgrade complete"},
+		}
+
+		newRel := newRepairedRelease(rel, "repaired")
+
+		Ω(newRel.Name).Should(Equal("app"))
+		Ω(newRel.Namespace).Should(Equal("ns"))
+		Ω(newRel.Manifest).Should(Equal("repaired"))
+		Ω(newRel.Version).Should(Equal(4))
+		Ω(newRel.Info.Status).Should(Equal(release.StatusDeployed))
+		Ω(newRel.Info.Description).Should(Equal("Repaired from the cluster state"))
+
+		Ω(rel.Manifest).Should(Equal("stored"))
+		Ω(rel.Info.Description).Should(Equal("Upgrade complete"))
+	})
+})
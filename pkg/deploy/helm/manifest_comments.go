@@ -0,0 +1,17 @@
+package helm
+
+import "strings"
+
+// ReplaceManifestContent returns the new content of the release manifest
+// with the leading comments of the original manifest (e.g. "# Source: ...") kept.
+func ReplaceManifestContent(manifest, content string) string {
+	var lines []string
+	for _, line := range strings.Split(manifest, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(append(lines, content), "\n")
+}
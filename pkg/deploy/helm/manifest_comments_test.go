@@ -0,0 +1,27 @@
+package helm
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("ReplaceManifestContent",
+	func(manifest, content, expected string) {
+		Ω(ReplaceManifestContent(manifest, content)).Should(Equal(expected))
+	},
+	Entry("keeps the source comment",
+		"# Source: chart/templates/cm.yaml\nkind: ConfigMap\n# comment\n",
+		"kind: Secret",
+		"# Source: chart/templates/cm.yaml\nkind: Secret",
+	),
+	Entry("keeps multiple leading comments",
+		"# Source: chart/templates/cm.yaml\n# other\nkind: ConfigMap",
+		"kind: Secret",
+		"# Source: chart/templates/cm.yaml\n# other\nkind: Secret",
+	),
+	Entry("manifest without comments",
+		"kind: ConfigMap",
+		"kind: Secret",
+		"kind: Secret",
+	),
+)
@@ -0,0 +1,102 @@
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+type manifestHead struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+// DiffManifests matches the resources of the two multi-document manifests by kind, namespace and name
+// and returns the unified diff of the changed, added and removed resources.
+// An empty string is returned when there are no changes.
+func DiffManifests(fromName, fromManifests, toName, toManifests string) (string, error) {
+	fromResources, err := splitManifestsByResource(fromManifests)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse %s manifests: %s", fromName, err)
+	}
+
+	toResources, err := splitManifestsByResource(toManifests)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse %s manifests: %s", toName, err)
+	}
+
+	keysSet := map[string]bool{}
+	for key := range fromResources {
+		keysSet[key] = true
+	}
+	for key := range toResources {
+		keysSet[key] = true
+	}
+
+	var keys []string
+	for key := range keysSet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result strings.Builder
+	for _, key := range keys {
+		fromFile, fromLines := diffSide(fromName, key, fromResources)
+		toFile, toLines := diffSide(toName, key, toResources)
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        fromLines,
+			B:        toLines,
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to diff %s: %s", key, err)
+		}
+
+		result.WriteString(diff)
+	}
+
+	return result.String(), nil
+}
+
+func diffSide(name, key string, resources map[string]string) (string, []string) {
+	manifest, ok := resources[key]
+	if !ok {
+		return "/dev/null", nil
+	}
+
+	return fmt.Sprintf("%s/%s", name, key), difflib.SplitLines(manifest)
+}
+
+func splitManifestsByResource(manifests string) (map[string]string, error) {
+	res := map[string]string{}
+
+	for _, manifest := range releaseutil.SplitManifests(manifests) {
+		var head manifestHead
+		if err := yaml.Unmarshal([]byte(manifest), &head); err != nil {
+			return nil, err
+		}
+
+		if head.Kind == "" && head.Metadata.Name == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", strings.ToLower(head.Kind), head.Metadata.Name)
+		if head.Metadata.Namespace != "" {
+			key = fmt.Sprintf("%s/%s", head.Metadata.Namespace, key)
+		}
+
+		res[key] = strings.TrimSpace(manifest)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package helm
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const manifestsDiffTestFrom = `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: backend
+spec:
+  replicas: 1
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: backend
+---
+# Source: app/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+  namespace: other
+`
+
+const manifestsDiffTestTo = `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: backend
+spec:
+  replicas: 2
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: backend
+---
+# Source: app/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: added
+---
+# Source: app/templates/empty.yaml
+# nothing rendered
+`
+
+var _ = Describe("DiffManifests", func() {
+	It("should diff the changed, added and removed resources sorted by the resource key", func() {
+		diff, err := DiffManifests("deployed", manifestsDiffTestFrom, "bundle", manifestsDiffTestTo)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(diff).Should(Equal(`--- deployed/deployment/backend
++++ bundle/deployment/backend
+@@ -4,4 +4,4 @@
+ metadata:
+   name: backend
+ spec:
+-  replicas: 1
++  replicas: 2
+--- deployed/other/configmap/removed
++++ /dev/null
+@@ -1,6 +0,0 @@
+-# Source: app/templates/configmap.yaml
+-apiVersion: v1
+-kind: ConfigMap
+-metadata:
+-  name: removed
+-  namespace: other
+--- /dev/null
++++ bundle/secret/added
+@@ -0,0 +1,5 @@
++# Source: app/templates/secret.yaml
++apiVersion: v1
++kind: Secret
++metadata:
++  name: added
+`))
+	})
+
+	It("should return an empty diff for the same resources", func() {
+		diff, err := DiffManifests("deployed", manifestsDiffTestFrom, "bundle", manifestsDiffTestFrom)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(diff).Should(BeEmpty())
+	})
+
+	It("should return an error for the invalid manifests", func() {
+		_, err := DiffManifests("deployed", "kind: [", "bundle", manifestsDiffTestTo)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("unable to parse deployed manifests"))
+	})
+})
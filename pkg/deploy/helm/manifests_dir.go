@@ -0,0 +1,176 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	clusterScopedManifestsDir = "_cluster"
+	kustomizationFileName     = "kustomization.yaml"
+	// manifestsDirMarkerFileName marks the dir written by werf, only such dir content is replaced
+	manifestsDirMarkerFileName = ".werf-manifests-dir"
+)
+
+// clusterScopedKinds are the built-in kinds of the cluster-scoped resources, which are written outside the namespace dirs.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CertificateSigningRequest":      true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"CustomResourceDefinition":       true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+	"VolumeSnapshotClass":            true,
+}
+
+type WriteManifestsDirOptions struct {
+	// Namespace is the dir of the namespaced resources without the explicit namespace (the release namespace).
+	Namespace string
+	// Kustomization generates the kustomization.yaml file with all written resources.
+	Kustomization bool
+}
+
+// WriteManifestsDir writes each rendered resource into the separate NAMESPACE/KIND/NAME.yaml file inside the dir,
+// cluster-scoped resources are written into the _cluster/KIND/NAME.yaml files.
+// The content of the dir previously written by werf is replaced, so that the resources removed from the chart do not remain in the dir,
+// the other non-empty dirs are never removed.
+func WriteManifestsDir(manifests, dir string, opts WriteManifestsDirOptions) error {
+	if err := prepareManifestsDir(dir); err != nil {
+		return err
+	}
+
+	splitManifests := releaseutil.SplitManifests(manifests)
+
+	var keys []string
+	for key := range splitManifests {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var paths []string
+	writtenPaths := map[string]bool{}
+	for _, key := range keys {
+		manifest := strings.TrimSpace(splitManifests[key])
+
+		var head manifestHead
+		if err := yaml.Unmarshal([]byte(manifest), &head); err != nil {
+			return fmt.Errorf("unable to parse rendered manifest: %s\n\n%s", err, manifest)
+		}
+
+		// skip the documents with comments only
+		if head.Kind == "" || head.Metadata.Name == "" {
+			continue
+		}
+
+		path := manifestPath(head, opts.Namespace)
+		for i := 2; writtenPaths[path]; i++ {
+			path = fmt.Sprintf("%s-%d.yaml", strings.TrimSuffix(manifestPath(head, opts.Namespace), ".yaml"), i)
+		}
+		writtenPaths[path] = true
+		paths = append(paths, path)
+
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+			return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(fullPath), err)
+		}
+
+		if err := ioutil.WriteFile(fullPath, []byte(manifest+"\n"), 0644); err != nil {
+			return fmt.Errorf("unable to write %q: %s", fullPath, err)
+		}
+	}
+
+	if opts.Kustomization {
+		sort.Strings(paths)
+
+		data, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "kustomize.config.k8s.io/v1beta1",
+			"kind":       "Kustomization",
+			"resources":  paths,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to marshal kustomization: %s", err)
+		}
+
+		kustomizationPath := filepath.Join(dir, kustomizationFileName)
+		if err := ioutil.WriteFile(kustomizationPath, data, 0644); err != nil {
+			return fmt.Errorf("unable to write %q: %s", kustomizationPath, err)
+		}
+	}
+
+	return nil
+}
+
+func prepareManifestsDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("unable to read dir %q: %s", dir, err)
+	case len(entries) == 0:
+	default:
+		if _, err := os.Stat(filepath.Join(dir, manifestsDirMarkerFileName)); os.IsNotExist(err) {
+			return fmt.Errorf("refusing to write into non-empty dir %q not written by werf: specify the empty or non-existing dir", dir)
+		} else if err != nil {
+			return err
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to remove %q: %s", dir, err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %q: %s", dir, err)
+	}
+
+	markerPath := filepath.Join(dir, manifestsDirMarkerFileName)
+	if err := ioutil.WriteFile(markerPath, []byte("# the dir content is replaced by werf render --output-dir\n"), 0o644); err != nil {
+		return fmt.Errorf("unable to write %q: %s", markerPath, err)
+	}
+
+	return nil
+}
+
+func manifestPath(head manifestHead, defaultNamespace string) string {
+	namespaceDir := clusterScopedManifestsDir
+	if !clusterScopedKinds[head.Kind] {
+		namespaceDir = defaultNamespace
+		if head.Metadata.Namespace != "" {
+			namespaceDir = head.Metadata.Namespace
+		}
+	}
+
+	return strings.Join([]string{sanitizePathPart(namespaceDir), sanitizePathPart(strings.ToLower(head.Kind)), fmt.Sprintf("%s.yaml", sanitizePathPart(head.Metadata.Name))}, "/")
+}
+
+var unsafePathCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// sanitizePathPart makes the namespace, the kind or the name usable as a single path element inside the dir.
+func sanitizePathPart(part string) string {
+	part = unsafePathCharsRegexp.ReplaceAllString(part, "_")
+	if part == "" || strings.Trim(part, ".") == "" {
+		return "_" + part
+	}
+
+	return part
+}
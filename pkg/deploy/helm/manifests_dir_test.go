@@ -0,0 +1,104 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+const testManifests = `---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+# Source: chart/templates/role.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: role
+---
+# Source: chart/templates/other.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: ../../../etc/passwd
+  namespace: ..
+`
+
+var _ = Describe("WriteManifestsDir", func() {
+	var tmpDir, dir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-manifests-dir-test-")
+		Ω(err).ShouldNot(HaveOccurred())
+		dir = filepath.Join(tmpDir, "out")
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	It("should write each resource into the sanitized NAMESPACE/KIND/NAME.yaml path inside the dir", func() {
+		Ω(WriteManifestsDir(testManifests, dir, WriteManifestsDirOptions{Namespace: "ns", Kustomization: true})).Should(Succeed())
+
+		Ω(filepath.Join(dir, manifestsDirMarkerFileName)).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, "ns", "configmap", "config.yaml")).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, clusterScopedManifestsDir, "clusterrole", "role.yaml")).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, "_..", "secret", ".._.._.._etc_passwd.yaml")).Should(BeAnExistingFile())
+		Ω(filepath.Join(tmpDir, "etc")).ShouldNot(BeAnExistingFile())
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, kustomizationFileName))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).Should(ContainSubstring("- ns/configmap/config.yaml"))
+		Ω(string(data)).Should(ContainSubstring("- _../secret/.._.._.._etc_passwd.yaml"))
+	})
+
+	It("should replace the content of the dir written by werf", func() {
+		Ω(WriteManifestsDir(testManifests, dir, WriteManifestsDirOptions{Namespace: "ns"})).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(dir, "ns", "configmap", "removed.yaml"), []byte("kind: ConfigMap\n"), 0o644)).Should(Succeed())
+
+		Ω(WriteManifestsDir(testManifests, dir, WriteManifestsDirOptions{Namespace: "ns"})).Should(Succeed())
+
+		Ω(filepath.Join(dir, "ns", "configmap", "config.yaml")).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, "ns", "configmap", "removed.yaml")).ShouldNot(BeAnExistingFile())
+	})
+
+	It("should refuse to write into the non-empty dir not written by werf and keep its content", func() {
+		Ω(os.MkdirAll(dir, os.ModePerm)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(dir, "important.txt"), []byte("data"), 0o644)).Should(Succeed())
+
+		err := WriteManifestsDir(testManifests, dir, WriteManifestsDirOptions{Namespace: "ns"})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("refusing to write into non-empty dir"))
+
+		Ω(filepath.Join(dir, "important.txt")).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, manifestsDirMarkerFileName)).ShouldNot(BeAnExistingFile())
+	})
+
+	It("should write into the existing empty dir", func() {
+		Ω(os.MkdirAll(dir, os.ModePerm)).Should(Succeed())
+		Ω(WriteManifestsDir(testManifests, dir, WriteManifestsDirOptions{Namespace: "ns"})).Should(Succeed())
+		Ω(filepath.Join(dir, "ns", "configmap", "config.yaml")).Should(BeAnExistingFile())
+	})
+})
+
+var _ = Describe("sanitizePathPart", func() {
+	DescribeTable("should return the single safe path element",
+		func(part, expected string) {
+			Ω(sanitizePathPart(part)).Should(Equal(expected))
+		},
+		Entry("regular name", "my-app.v1_2", "my-app.v1_2"),
+		Entry("empty", "", "_"),
+		Entry("dot", ".", "_."),
+		Entry("dot-dot", "..", "_.."),
+		Entry("separators", "a/b\\c", "a_b_c"),
+		Entry("traversal", "../x", ".._x"),
+	)
+})
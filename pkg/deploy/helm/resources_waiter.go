@@ -23,10 +23,14 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/kubectl/pkg/scheme"
 
 	flaggerv1beta1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -43,6 +47,10 @@ type ResourcesWaiter struct {
 	LogsFromTime              time.Time
 	StatusProgressPeriod      time.Duration
 	HooksStatusProgressPeriod time.Duration
+
+	// KubeClient and KubeDynamicClient are used to track the resources instead of the global kube clients when set.
+	KubeClient        kubernetes.Interface
+	KubeDynamicClient dynamic.Interface
 }
 
 func NewResourcesWaiter(kubeInitializer KubeInitializer, client *helm_kube.Client, logsFromTime time.Time, statusProgressPeriod, hooksStatusProgressPeriod time.Duration) *ResourcesWaiter {
@@ -55,6 +63,20 @@ func NewResourcesWaiter(kubeInitializer KubeInitializer, client *helm_kube.Clien
 	}
 }
 
+func (waiter *ResourcesWaiter) kubeClient() kubernetes.Interface {
+	if waiter.KubeClient != nil {
+		return waiter.KubeClient
+	}
+	return kube.Client
+}
+
+func (waiter *ResourcesWaiter) kubeDynamicClient() dynamic.Interface {
+	if waiter.KubeDynamicClient != nil {
+		return waiter.KubeDynamicClient
+	}
+	return kube.DynamicClient
+}
+
 func extractSpecReplicas(specReplicas *int32) int {
 	if specReplicas != nil {
 		return int(*specReplicas)
@@ -73,7 +95,10 @@ func (waiter *ResourcesWaiter) Wait(ctx context.Context, namespace string, resou
 		}
 	}
 
+	emitResourcesAppliedEvents(resources)
+
 	specs := multitrack.MultitrackSpecs{}
+	var endpointsSpecs []*endpointsWaitSpec
 
 	for _, v := range resources {
 		switch value := asVersioned(v).(type) {
@@ -177,6 +202,21 @@ func (waiter *ResourcesWaiter) Wait(ctx context.Context, namespace string, resou
 		case *appsv1.ReplicaSet:
 		case *v1.PersistentVolumeClaim:
 		case *v1.Service:
+			if spec := makeServiceEndpointsWaitSpec(ctx, value, v.Namespace); spec != nil {
+				endpointsSpecs = append(endpointsSpecs, spec)
+			}
+		case *networkingv1.Ingress:
+			if spec := makeIngressEndpointsWaitSpec(ctx, &value.ObjectMeta, v.Namespace); spec != nil {
+				endpointsSpecs = append(endpointsSpecs, spec)
+			}
+		case *networkingv1beta1.Ingress:
+			if spec := makeIngressEndpointsWaitSpec(ctx, &value.ObjectMeta, v.Namespace); spec != nil {
+				endpointsSpecs = append(endpointsSpecs, spec)
+			}
+		case *extensions.Ingress:
+			if spec := makeIngressEndpointsWaitSpec(ctx, &value.ObjectMeta, v.Namespace); spec != nil {
+				endpointsSpecs = append(endpointsSpecs, spec)
+			}
 		case *flaggerv1beta1.Canary:
 			spec, err := makeMultitrackSpec(ctx, &value.ObjectMeta, allowedFailuresCountOptions{multiplier: 1, defaultPerReplica: 0}, "canary")
 			if err != nil {
@@ -188,18 +228,34 @@ func (waiter *ResourcesWaiter) Wait(ctx context.Context, namespace string, resou
 		}
 	}
 
+	startedAt := time.Now()
+
 	// NOTE: use context from resources-waiter object here, will be changed in helm 3
 	logboek.Context(ctx).LogOptionalLn()
-	return logboek.Context(ctx).LogProcess("Waiting for release resources to become ready").
+	if err := logboek.Context(ctx).LogProcess("Waiting for release resources to become ready").
 		DoError(func() error {
-			return multitrack.Multitrack(kube.Client, specs, multitrack.MultitrackOptions{
-				StatusProgressPeriod: waiter.StatusProgressPeriod,
-				Options: tracker.Options{
-					Timeout:      timeout,
-					LogsFromTime: waiter.LogsFromTime,
-				},
+			return trackResourcesWithEvents(specs, func() error {
+				return multitrack.Multitrack(waiter.kubeClient(), specs, multitrack.MultitrackOptions{
+					StatusProgressPeriod: waiter.StatusProgressPeriod,
+					Options: tracker.Options{
+						Timeout:      timeout,
+						LogsFromTime: waiter.LogsFromTime,
+					},
+				})
 			})
-		})
+		}); err != nil {
+		return err
+	}
+
+	// endpoints become ready only after the pods are ready, so the rest of the timeout is used
+	if timeout > 0 {
+		timeout -= time.Since(startedAt)
+		if timeout <= 0 {
+			timeout = time.Nanosecond
+		}
+	}
+
+	return waiter.waitForEndpoints(ctx, endpointsSpecs, timeout)
 }
 
 func makeMultitrackSpec(ctx context.Context, objMeta *metav1.ObjectMeta, failuresCountOptions allowedFailuresCountOptions, kind string) (*multitrack.MultitrackSpec, error) {
@@ -369,6 +425,8 @@ func (waiter *ResourcesWaiter) WatchUntilReady(ctx context.Context, namespace st
 		}
 	}
 
+	emitResourcesAppliedEvents(resources)
+
 	for _, info := range resources {
 		name := info.Name
 		kind := info.Mapping.GroupVersionKind.Kind
@@ -387,12 +445,14 @@ func (waiter *ResourcesWaiter) WatchUntilReady(ctx context.Context, namespace st
 
 			return logboek.Context(ctx).LogProcess("Waiting for helm hook job/%s termination", name).
 				DoError(func() error {
-					return multitrack.Multitrack(kube.Client, specs, multitrack.MultitrackOptions{
-						StatusProgressPeriod: waiter.HooksStatusProgressPeriod,
-						Options: tracker.Options{
-							Timeout:      timeout,
-							LogsFromTime: waiter.LogsFromTime,
-						},
+					return trackResourcesWithEvents(specs, func() error {
+						return multitrack.Multitrack(waiter.kubeClient(), specs, multitrack.MultitrackOptions{
+							StatusProgressPeriod: waiter.HooksStatusProgressPeriod,
+							Options: tracker.Options{
+								Timeout:      timeout,
+								LogsFromTime: waiter.LogsFromTime,
+							},
+						})
 					})
 				})
 
@@ -438,6 +498,6 @@ func (waiter *ResourcesWaiter) WaitUntilDeleted(ctx context.Context, specs []*he
 	}
 
 	return logboek.Context(ctx).Default().LogProcess("Waiting for resources elimination: %s", strings.Join(resourcesDescParts, ", ")).DoError(func() error {
-		return elimination.TrackUntilEliminated(ctx, kube.DynamicClient, eliminationSpecs, elimination.EliminationTrackerOptions{Timeout: timeout, StatusProgressPeriod: waiter.StatusProgressPeriod})
+		return elimination.TrackUntilEliminated(ctx, waiter.kubeDynamicClient(), eliminationSpecs, elimination.EliminationTrackerOptions{Timeout: timeout, StatusProgressPeriod: waiter.StatusProgressPeriod})
 	})
 }
@@ -0,0 +1,235 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/logboek"
+)
+
+// ResourceSelector selects the release resources by the kind and the name in the KIND/NAME format.
+// The kind is compared case-insensitively, the name is the glob pattern (e.g. PersistentVolumeClaim/*, Secret/db-*).
+type ResourceSelector struct {
+	Kind        string
+	NamePattern string
+}
+
+func ParseResourceSelectors(values []string) ([]*ResourceSelector, error) {
+	var selectors []*ResourceSelector
+	for _, value := range values {
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("bad resource selector %q: KIND/NAME format expected", value)
+		}
+
+		if _, err := path.Match(parts[1], ""); err != nil {
+			return nil, fmt.Errorf("bad resource selector %q name pattern: %s", value, err)
+		}
+
+		selectors = append(selectors, &ResourceSelector{Kind: parts[0], NamePattern: parts[1]})
+	}
+
+	return selectors, nil
+}
+
+func (selector *ResourceSelector) Match(kind, name string) bool {
+	if !strings.EqualFold(selector.Kind, kind) {
+		return false
+	}
+
+	matched, _ := path.Match(selector.NamePattern, name)
+	return matched
+}
+
+func matchResourceSelectors(selectors []*ResourceSelector, kind, name string) bool {
+	for _, selector := range selectors {
+		if selector.Match(kind, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type SelectiveDismissOptions struct {
+	// KeepResources selects the release resources which should not be deleted.
+	KeepResources []*ResourceSelector
+	// OnlyKinds limits the deletion to the release resources of the specified kinds, the release itself is not deleted.
+	OnlyKinds []string
+	// DryRun only lists the resources to be deleted and kept.
+	DryRun bool
+}
+
+func (opts SelectiveDismissOptions) IsSelective() bool {
+	return len(opts.KeepResources) != 0 || len(opts.OnlyKinds) != 0
+}
+
+type selectiveDismissManifest struct {
+	Kind    string
+	Name    string
+	Content string
+	Keep    bool
+}
+
+// LogSelectiveDismiss lists the resources of the last release revision to be deleted and kept by the release uninstall.
+func LogSelectiveDismiss(ctx context.Context, actionConfig *action.Configuration, releaseName string, opts SelectiveDismissOptions) error {
+	rel, err := actionConfig.Releases.Last(releaseName)
+	if err != nil {
+		return fmt.Errorf("unable to get release %q: %s", releaseName, err)
+	}
+
+	manifests, err := getSelectiveDismissManifests(rel, opts)
+	if err != nil {
+		return err
+	}
+
+	logSelectiveDismissManifests(ctx, manifests, opts.DryRun)
+
+	return nil
+}
+
+// NewSelectiveDismissKubeClient returns the kube client which does not delete the resources selected to be kept,
+// so that the release uninstall keeps these resources without changing the stored release.
+func NewSelectiveDismissKubeClient(kubeClient helm_kube.Interface, keepResources []*ResourceSelector) helm_kube.Interface {
+	return &selectiveDismissKubeClient{Interface: kubeClient, keepResources: keepResources}
+}
+
+type selectiveDismissKubeClient struct {
+	helm_kube.Interface
+	keepResources []*ResourceSelector
+}
+
+func (client *selectiveDismissKubeClient) Delete(resources helm_kube.ResourceList, opts helm_kube.DeleteOptions) (*helm_kube.Result, []error) {
+	var resourcesToDelete helm_kube.ResourceList
+	for _, info := range resources {
+		if info.Mapping != nil && matchResourceSelectors(client.keepResources, info.Mapping.GroupVersionKind.Kind, info.Name) {
+			continue
+		}
+
+		resourcesToDelete = append(resourcesToDelete, info)
+	}
+
+	if len(resourcesToDelete) == 0 {
+		return &helm_kube.Result{}, nil
+	}
+
+	return client.Interface.Delete(resourcesToDelete, opts)
+}
+
+// DeleteReleaseResourcesByKinds deletes the release resources of the kinds specified with the only kinds option
+// except the resources selected to be kept. The release itself and other resources are not changed,
+// the deleted resources are recreated by the next release deploy.
+func DeleteReleaseResourcesByKinds(ctx context.Context, actionConfig *action.Configuration, releaseName string, opts SelectiveDismissOptions) error {
+	rel, err := actionConfig.Releases.Last(releaseName)
+	if err != nil {
+		return fmt.Errorf("unable to get release %q: %s", releaseName, err)
+	}
+
+	manifests, err := getSelectiveDismissManifests(rel, opts)
+	if err != nil {
+		return err
+	}
+
+	logSelectiveDismissManifests(ctx, manifests, opts.DryRun)
+
+	if opts.DryRun {
+		return nil
+	}
+
+	var manifestsToDelete []string
+	for _, manifest := range manifests {
+		if !manifest.Keep {
+			manifestsToDelete = append(manifestsToDelete, manifest.Content)
+		}
+	}
+
+	if len(manifestsToDelete) == 0 {
+		return nil
+	}
+
+	resources, err := actionConfig.KubeClient.Build(strings.NewReader(strings.Join(manifestsToDelete, "\n---\n")), false)
+	if err != nil {
+		return fmt.Errorf("unable to build resources to delete: %s", err)
+	}
+
+	if _, errs := actionConfig.KubeClient.Delete(resources, helm_kube.DeleteOptions{Wait: true}); len(errs) != 0 {
+		var errMsgs []string
+		for _, err := range errs {
+			errMsgs = append(errMsgs, err.Error())
+		}
+		return fmt.Errorf("unable to delete resources: %s", strings.Join(errMsgs, "; "))
+	}
+
+	return nil
+}
+
+func getSelectiveDismissManifests(rel *release.Release, opts SelectiveDismissOptions) ([]*selectiveDismissManifest, error) {
+	splitManifests := releaseutil.SplitManifests(rel.Manifest)
+
+	var keys []string
+	for key := range splitManifests {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var res []*selectiveDismissManifest
+	for _, key := range keys {
+		var head releaseutil.SimpleHead
+		if err := yaml.Unmarshal([]byte(splitManifests[key]), &head); err != nil {
+			return nil, fmt.Errorf("unable to parse release %q manifest: %s", rel.Name, err)
+		}
+
+		if head.Metadata == nil {
+			continue
+		}
+
+		manifest := &selectiveDismissManifest{
+			Kind:    head.Kind,
+			Name:    head.Metadata.Name,
+			Content: splitManifests[key],
+			Keep:    matchResourceSelectors(opts.KeepResources, head.Kind, head.Metadata.Name),
+		}
+
+		if len(opts.OnlyKinds) != 0 && !isKindOneOf(head.Kind, opts.OnlyKinds) {
+			manifest.Keep = true
+		}
+
+		res = append(res, manifest)
+	}
+
+	return res, nil
+}
+
+func logSelectiveDismissManifests(ctx context.Context, manifests []*selectiveDismissManifest, dryRun bool) {
+	deleteVerb, keepVerb := "Deleting", "Keeping"
+	if dryRun {
+		deleteVerb, keepVerb = "Would delete", "Would keep"
+	}
+
+	for _, manifest := range manifests {
+		if manifest.Keep {
+			logboek.Context(ctx).Default().LogF("%s %s/%s\n", keepVerb, manifest.Kind, manifest.Name)
+		} else {
+			logboek.Context(ctx).Default().LogF("%s %s/%s\n", deleteVerb, manifest.Kind, manifest.Name)
+		}
+	}
+}
+
+func isKindOneOf(kind string, kinds []string) bool {
+	for _, k := range kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package helm
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+var _ = Describe("ParseResourceSelectors", func() {
+	It("should parse the KIND/NAME selectors", func() {
+		selectors, err := ParseResourceSelectors([]string{"PersistentVolumeClaim/*", "Secret/db-*"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(selectors).Should(Equal([]*ResourceSelector{
+			{Kind: "PersistentVolumeClaim", NamePattern: "*"},
+			{Kind: "Secret", NamePattern: "db-*"},
+		}))
+	})
+
+	DescribeTable("should fail on the bad selector",
+		func(value string) {
+			_, err := ParseResourceSelectors([]string{value})
+			Ω(err).Should(HaveOccurred())
+		},
+		Entry("without name", "Secret"),
+		Entry("empty kind", "/db"),
+		Entry("empty name", "Secret/"),
+		Entry("bad pattern", "Secret/[db"),
+	)
+})
+
+var _ = DescribeTable("ResourceSelector.Match",
+	func(selector ResourceSelector, kind, name string, expected bool) {
+		Ω(selector.Match(kind, name)).Should(Equal(expected))
+	},
+	Entry("exact", ResourceSelector{Kind: "Secret", NamePattern: "db"}, "Secret", "db", true),
+	Entry("case-insensitive kind", ResourceSelector{Kind: "secret", NamePattern: "db"}, "Secret", "db", true),
+	Entry("name pattern", ResourceSelector{Kind: "Secret", NamePattern: "db-*"}, "Secret", "db-password", true),
+	Entry("other kind", ResourceSelector{Kind: "ConfigMap", NamePattern: "*"}, "Secret", "db", false),
+	Entry("other name", ResourceSelector{Kind: "Secret", NamePattern: "db-*"}, "Secret", "app", false),
+)
+
+var _ = DescribeTable("isKindOneOf",
+	func(kind string, kinds []string, expected bool) {
+		Ω(isKindOneOf(kind, kinds)).Should(Equal(expected))
+	},
+	Entry("case-insensitive", "Deployment", []string{"statefulset", "deployment"}, true),
+	Entry("not found", "Secret", []string{"Deployment"}, false),
+	Entry("no kinds", "Secret", nil, false),
+)
+
+var _ = Describe("getSelectiveDismissManifests", func() {
+	rel := &release.Release{
+		Name: "app",
+		Manifest: `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+# Source: app/templates/pvc.yaml
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+---
+# Source: app/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-password
+`,
+	}
+
+	getKeep := func(manifests []*selectiveDismissManifest) map[string]bool {
+		res := map[string]bool{}
+		for _, manifest := range manifests {
+			res[manifest.Kind+"/"+manifest.Name] = manifest.Keep
+		}
+		return res
+	}
+
+	It("should keep the resources matching the keep selectors", func() {
+		manifests, err := getSelectiveDismissManifests(rel, SelectiveDismissOptions{
+			KeepResources: []*ResourceSelector{{Kind: "PersistentVolumeClaim", NamePattern: "*"}},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(getKeep(manifests)).Should(Equal(map[string]bool{
+			"Deployment/app":             false,
+			"PersistentVolumeClaim/data": true,
+			"Secret/db-password":         false,
+		}))
+	})
+
+	It("should keep the resources of other kinds than the only kinds", func() {
+		manifests, err := getSelectiveDismissManifests(rel, SelectiveDismissOptions{
+			KeepResources: []*ResourceSelector{{Kind: "Secret", NamePattern: "db-*"}},
+			OnlyKinds:     []string{"deployment", "secret"},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(getKeep(manifests)).Should(Equal(map[string]bool{
+			"Deployment/app":             false,
+			"PersistentVolumeClaim/data": true,
+			"Secret/db-password":         true,
+		}))
+	})
+})
+
+type deleteRecordingKubeClient struct {
+	helm_kube.Interface
+	deleted []string
+}
+
+func (client *deleteRecordingKubeClient) Delete(resources helm_kube.ResourceList, _ helm_kube.DeleteOptions) (*helm_kube.Result, []error) {
+	for _, info := range resources {
+		client.deleted = append(client.deleted, info.Mapping.GroupVersionKind.Kind+"/"+info.Name)
+	}
+	return &helm_kube.Result{Deleted: resources}, nil
+}
+
+var _ = Describe("selectiveDismissKubeClient", func() {
+	newInfo := func(kind, name string) *resource.Info {
+		return &resource.Info{Name: name, Mapping: &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: kind}}}
+	}
+
+	It("should not delete the resources matching the keep selectors", func() {
+		recordingClient := &deleteRecordingKubeClient{}
+		client := NewSelectiveDismissKubeClient(recordingClient, []*ResourceSelector{{Kind: "persistentvolumeclaim", NamePattern: "*"}})
+
+		_, errs := client.Delete(helm_kube.ResourceList{newInfo("Deployment", "app"), newInfo("PersistentVolumeClaim", "data")}, helm_kube.DeleteOptions{})
+		Ω(errs).Should(BeEmpty())
+		Ω(recordingClient.deleted).Should(Equal([]string{"Deployment/app"}))
+
+		_, errs = client.Delete(helm_kube.ResourceList{newInfo("PersistentVolumeClaim", "data")}, helm_kube.DeleteOptions{})
+		Ω(errs).Should(BeEmpty())
+		Ω(recordingClient.deleted).Should(Equal([]string{"Deployment/app"}))
+	})
+})
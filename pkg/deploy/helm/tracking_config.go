@@ -0,0 +1,44 @@
+package helm
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+// getTrackingAnnotations converts the werf.yaml deploy tracking configuration matching the resource into the tracking annotations,
+// the later matching configuration entries override the earlier ones.
+func getTrackingAnnotations(tracking []*config.MetaDeployTracking, kind, name string) map[string]string {
+	annotations := map[string]string{}
+
+	for _, t := range tracking {
+		if !t.Match(kind, name) {
+			continue
+		}
+
+		if t.TrackTerminationMode != nil {
+			annotations[TrackTerminationModeAnnoName] = *t.TrackTerminationMode
+		}
+		if t.FailMode != nil {
+			annotations[FailModeAnnoName] = *t.FailMode
+		}
+		if t.FailuresAllowedPerReplica != nil {
+			annotations[FailuresAllowedPerReplicaAnnoName] = strconv.Itoa(*t.FailuresAllowedPerReplica)
+		}
+		if t.LogRegex != nil {
+			annotations[LogRegexAnnoName] = *t.LogRegex
+		}
+		if t.SkipLogs != nil {
+			annotations[SkipLogsAnnoName] = strconv.FormatBool(*t.SkipLogs)
+		}
+		if t.SkipLogsForContainers != nil {
+			annotations[SkipLogsForContainersAnnoName] = strings.Join(t.SkipLogsForContainers, ",")
+		}
+		if t.ShowLogsOnlyForContainers != nil {
+			annotations[ShowLogsOnlyForContainers] = strings.Join(t.ShowLogsOnlyForContainers, ",")
+		}
+	}
+
+	return annotations
+}
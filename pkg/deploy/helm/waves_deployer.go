@@ -0,0 +1,251 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/werf/logboek"
+)
+
+const crdEstablishTimeout = 60 * time.Second
+
+// WavesDeployer deploys the release resources in the ordered waves specified by the werf.io/deploy-wave annotation.
+// Resources of each wave except the last one are applied and tracked until ready before the next wave is started,
+// the last wave is left to the regular deploy stage, which also updates the resources of the previous waves in the release.
+// Resources without annotation belong to the wave 0.
+// If the wave fails, the resources of the applied waves are restored to the deployed release revision.
+type WavesDeployer struct {
+	KubeClient       helm_kube.Interface
+	ReleaseName      string
+	ReleaseNamespace string
+	Timeout          time.Duration
+
+	applied          helm_kube.ResourceList
+	deployedManifest string
+}
+
+func NewWavesDeployer(kubeClient helm_kube.Interface, releaseName, releaseNamespace string, timeout time.Duration) *WavesDeployer {
+	return &WavesDeployer{
+		KubeClient:       kubeClient,
+		ReleaseName:      releaseName,
+		ReleaseNamespace: releaseNamespace,
+		Timeout:          timeout,
+	}
+}
+
+func (deployer *WavesDeployer) Run(ctx context.Context, plan *DeployPlan, deployedRelease *release.Release) error {
+	deployer.applied = nil
+	deployer.deployedManifest = ""
+	if deployedRelease != nil {
+		deployer.deployedManifest = deployedRelease.Manifest
+	}
+
+	if err := deployer.deploy(ctx, plan.WavesManifests); err != nil {
+		if rollbackErr := deployer.Rollback(ctx); rollbackErr != nil {
+			return fmt.Errorf("%s\nrollback failed: %s", err, rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Rollback restores the resources of the applied waves to the deployed release revision the same way as helm rollback does:
+// the resources of the deployed revision are updated with its manifests, the other resources are deleted.
+func (deployer *WavesDeployer) Rollback(ctx context.Context) error {
+	if len(deployer.applied) == 0 {
+		return nil
+	}
+
+	var deployed helm_kube.ResourceList
+	if deployer.deployedManifest != "" {
+		resources, err := deployer.KubeClient.Build(bytes.NewBufferString(deployer.deployedManifest), false)
+		if err != nil {
+			return fmt.Errorf("unable to build resources of the deployed release revision: %s", err)
+		}
+		deployed = resources.Intersect(deployer.applied)
+	}
+
+	logboek.Context(ctx).Default().LogF("Rolling back %d resources of the applied waves\n", len(deployer.applied))
+
+	if _, err := deployer.KubeClient.Update(deployer.applied, deployed, false); err != nil {
+		return fmt.Errorf("unable to roll back resources of the applied waves: %s", err)
+	}
+	deployer.applied = nil
+
+	return nil
+}
+
+func (deployer *WavesDeployer) deploy(ctx context.Context, wavesManifests map[int][]string) error {
+	if len(wavesManifests) < 2 {
+		return nil
+	}
+
+	var waves []int
+	for wave := range wavesManifests {
+		waves = append(waves, wave)
+	}
+	sort.Ints(waves)
+
+	for _, wave := range waves[:len(waves)-1] {
+		if err := logboek.Context(ctx).Default().LogProcess("Deploying wave %d", wave).DoError(func() error {
+			return deployer.deployWave(wavesManifests[wave])
+		}); err != nil {
+			return fmt.Errorf("unable to deploy wave %d: %s", wave, err)
+		}
+	}
+
+	return nil
+}
+
+func (deployer *WavesDeployer) deployWave(manifests []string) error {
+	// Resources are built right before the wave is applied, because the kinds of the custom resources
+	// are known only after the CRDs of the previous waves are established.
+	resources, err := deployer.KubeClient.Build(bytes.NewBufferString(strings.Join(manifests, "\n---\n")), false)
+	if err != nil {
+		return fmt.Errorf("unable to build resources: %s", err)
+	}
+
+	if err := resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := deployer.checkReleaseOwnership(info); err != nil {
+			return err
+		}
+
+		return setReleaseOwnershipMetadata(info.Object, deployer.ReleaseName, deployer.ReleaseNamespace)
+	}); err != nil {
+		return err
+	}
+
+	for _, info := range resources {
+		deployer.applied.Append(info)
+	}
+
+	// Target resources are used as the original ones, so that the fields set by the previous release revision are kept
+	// until the regular deploy stage, which performs the three-way merge with the previous release manifests.
+	if _, err := deployer.KubeClient.Update(resources, resources, false); err != nil {
+		return fmt.Errorf("unable to apply resources: %s", err)
+	}
+
+	if err := deployer.KubeClient.Wait(resources, deployer.Timeout); err != nil {
+		return err
+	}
+
+	return deployer.waitForCRDsEstablished(resources)
+}
+
+// checkReleaseOwnership prevents modification of the existing resources which belong to another release.
+func (deployer *WavesDeployer) checkReleaseOwnership(info *resource.Info) error {
+	helper := resource.NewHelper(info.Client, info.Mapping)
+
+	existing, err := helper.Get(info.Namespace, info.Name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get %s: %s", info.ObjectName(), err)
+	}
+
+	annotations, err := metadataAccessor.Annotations(existing)
+	if err != nil {
+		return err
+	}
+
+	if releaseName := annotations[ReleaseNameAnnoName]; releaseName != deployer.ReleaseName {
+		return fmt.Errorf("%s exists and cannot be imported into the release %q: annotation %s has value %q", info.ObjectName(), deployer.ReleaseName, ReleaseNameAnnoName, releaseName)
+	}
+	if releaseNamespace := annotations[ReleaseNamespaceAnnoName]; releaseNamespace != deployer.ReleaseNamespace {
+		return fmt.Errorf("%s exists and cannot be imported into the release %q: annotation %s has value %q", info.ObjectName(), deployer.ReleaseName, ReleaseNamespaceAnnoName, releaseNamespace)
+	}
+
+	return nil
+}
+
+func (deployer *WavesDeployer) waitForCRDsEstablished(resources helm_kube.ResourceList) error {
+	var crds helm_kube.ResourceList
+	for _, info := range resources {
+		if info.Mapping.GroupVersionKind.Kind == "CustomResourceDefinition" {
+			crds = append(crds, info)
+		}
+	}
+
+	if len(crds) == 0 {
+		return nil
+	}
+
+	for _, info := range crds {
+		if err := wait.PollImmediate(time.Second, crdEstablishTimeout, func() (bool, error) {
+			if err := info.Get(); err != nil {
+				return false, err
+			}
+
+			return isCRDEstablished(info)
+		}); err != nil {
+			return fmt.Errorf("unable to wait for %s to be established: %s", info.ObjectName(), err)
+		}
+	}
+
+	return deployer.invalidateDiscoveryCache()
+}
+
+// invalidateDiscoveryCache drops the cached API resources, so that the custom resources of the next waves could be built.
+func (deployer *WavesDeployer) invalidateDiscoveryCache() error {
+	kubeClient, ok := deployer.KubeClient.(*helm_kube.Client)
+	if !ok {
+		return nil
+	}
+
+	restClientGetter, ok := kubeClient.Factory.(genericclioptions.RESTClientGetter)
+	if !ok {
+		return nil
+	}
+
+	discoveryClient, err := restClientGetter.ToDiscoveryClient()
+	if err != nil {
+		return err
+	}
+	discoveryClient.Invalidate()
+
+	// Force the rebuild of the cache.
+	_, err = discoveryClient.ServerGroups()
+	return err
+}
+
+func isCRDEstablished(info *resource.Info) (bool, error) {
+	obj, ok := info.Object.(*unstructured.Unstructured)
+	if !ok {
+		return false, fmt.Errorf("unexpected object type %T", info.Object)
+	}
+
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+
+	for _, cond := range conditions {
+		cond, ok := cond.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if cond["type"] == "Established" {
+			return cond["status"] == "True", nil
+		}
+	}
+
+	return false, nil
+}
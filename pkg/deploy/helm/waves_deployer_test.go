@@ -0,0 +1,78 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+var _ = Describe("WavesDeployer", func() {
+	It("should collect the resources by the werf.io/deploy-wave annotation", func() {
+		postRenderer := NewExtraAnnotationsAndLabelsPostRenderer(nil, nil)
+		_, err := postRenderer.Run(bytes.NewBufferString(`---
+# Source: chart/templates/crd.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: crontabs.example.com
+  annotations:
+    werf.io/deploy-wave: "-1"
+---
+# Source: chart/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		plan := postRenderer.GetDeployPlan()
+		Ω(plan.WavesManifests).Should(HaveLen(2))
+		Ω(plan.WavesManifests[-1]).Should(HaveLen(1))
+		Ω(plan.WavesManifests[-1][0]).Should(ContainSubstring("name: crontabs.example.com"))
+		Ω(plan.WavesManifests[0]).Should(HaveLen(1))
+		Ω(plan.WavesManifests[0][0]).Should(ContainSubstring("name: cm"))
+	})
+
+	It("should not apply anything if there is the only wave", func() {
+		kubeClient := newFakeKubeClient()
+		deployer := NewWavesDeployer(kubeClient, "myrelease", "default", 0)
+
+		Ω(deployer.Run(context.Background(), &DeployPlan{WavesManifests: map[int][]string{0: {"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"}}}, nil)).Should(Succeed())
+		Ω(kubeClient.target).Should(BeEmpty())
+		Ω(deployer.applied).Should(BeEmpty())
+	})
+
+	It("should restore the applied resources to the deployed release revision on rollback", func() {
+		kubeClient := newFakeKubeClient()
+		deployer := NewWavesDeployer(kubeClient, "myrelease", "default", 0)
+		deployer.deployedManifest = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: existing\n---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: not-applied\n"
+
+		applied, err := kubeClient.Build(bytes.NewBufferString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: existing\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n"), false)
+		Ω(err).ShouldNot(HaveOccurred())
+		deployer.applied = applied
+
+		Ω(deployer.Rollback(context.Background())).Should(Succeed())
+		Ω(resourceNames(kubeClient.original)).Should(Equal([]string{"ConfigMap/existing", "ConfigMap/new"}))
+		Ω(resourceNames(kubeClient.target)).Should(Equal([]string{"ConfigMap/existing"}))
+		Ω(deployer.applied).Should(BeEmpty())
+	})
+
+	It("should delete the applied resources on rollback of the first release revision", func() {
+		kubeClient := newFakeKubeClient()
+		deployer := NewWavesDeployer(kubeClient, "myrelease", "default", 0)
+
+		Ω(deployer.Run(context.Background(), &DeployPlan{}, &release.Release{})).Should(Succeed())
+
+		applied, err := kubeClient.Build(bytes.NewBufferString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n"), false)
+		Ω(err).ShouldNot(HaveOccurred())
+		deployer.applied = applied
+
+		Ω(deployer.Rollback(context.Background())).Should(Succeed())
+		Ω(resourceNames(kubeClient.original)).Should(Equal([]string{"ConfigMap/new"}))
+		Ω(kubeClient.target).Should(BeEmpty())
+	})
+})
@@ -12,10 +12,15 @@ import (
 	"github.com/werf/werf/pkg/werf"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/werf/lockgate"
 )
 
+// ConfigMapName is the name of the ConfigMap in the release namespace which stores the release locks.
+const ConfigMapName = "werf-synchronization"
+
 // NOTE: LockManager for not is not multithreaded due to the lack of support of contexts in the lockgate library
 type LockManager struct {
 	Namespace       string
@@ -23,18 +28,21 @@ type LockManager struct {
 }
 
 func NewLockManager(namespace string) (*LockManager, error) {
-	configMapName := "werf-synchronization"
+	return NewLockManagerWithKubeClients(namespace, kube.Client, kube.DynamicClient)
+}
 
-	if _, err := kubeutils.GetOrCreateConfigMapWithNamespaceIfNotExists(kube.Client, namespace, configMapName); err != nil {
+// NewLockManagerWithKubeClients creates the lock manager which stores the locks using the specified kube clients instead of the global ones.
+func NewLockManagerWithKubeClients(namespace string, client kubernetes.Interface, dynamicClient dynamic.Interface) (*LockManager, error) {
+	if _, err := kubeutils.GetOrCreateConfigMapWithNamespaceIfNotExists(client, namespace, ConfigMapName); err != nil {
 		return nil, err
 	}
 
 	locker := distributed_locker.NewKubernetesLocker(
-		kube.DynamicClient, schema.GroupVersionResource{
+		dynamicClient, schema.GroupVersionResource{
 			Group:    "",
 			Version:  "v1",
 			Resource: "configmaps",
-		}, configMapName, namespace,
+		}, ConfigMapName, namespace,
 	)
 	lockerWithRetry := locker_with_retry.NewLockerWithRetry(context.Background(), locker, locker_with_retry.LockerWithRetryOptions{MaxAcquireAttempts: 10, MaxReleaseAttempts: 10})
 
@@ -0,0 +1,34 @@
+package secret_values_provider
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// AWSSecretsManagerProvider fetches the secret from the AWS Secrets Manager: aws-sm://NAME reads the secret by the name or the ARN.
+// Credentials and region are taken from the standard AWS environment variables and shared config.
+// The secret string should contain the values in the YAML or JSON format.
+type AWSSecretsManagerProvider struct{}
+
+func (p *AWSSecretsManagerProvider) Fetch(ctx context.Context, path string) ([]byte, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(path),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if output.SecretString != nil {
+		return []byte(*output.SecretString), nil
+	}
+
+	return output.SecretBinary, nil
+}
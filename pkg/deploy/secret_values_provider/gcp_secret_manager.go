@@ -0,0 +1,87 @@
+package secret_values_provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+const gcpSecretManagerAPI = "https://secretmanager.googleapis.com/v1"
+
+// GCPSecretManagerProvider fetches the secret from the Google Cloud Secret Manager:
+// gcp-sm://projects/PROJECT/secrets/NAME[/versions/VERSION] or gcp-sm://NAME (the project is taken from GOOGLE_CLOUD_PROJECT).
+// The latest version is used by default, credentials are taken from the application default credentials.
+// The secret payload should contain the values in the YAML or JSON format.
+type GCPSecretManagerProvider struct{}
+
+func (p *GCPSecretManagerProvider) Fetch(ctx context.Context, path string) ([]byte, error) {
+	name, err := getGCPSecretVersionName(path)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s:access", gcpSecretManagerAPI, name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secret manager responded with status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &version); err != nil {
+		return nil, fmt.Errorf("unexpected secret manager response")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected secret manager response: invalid payload encoding")
+	}
+
+	return data, nil
+}
+
+func getGCPSecretVersionName(path string) (string, error) {
+	if !strings.HasPrefix(path, "projects/") {
+		project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+		if project == "" {
+			return "", fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable or full secret name projects/PROJECT/secrets/NAME required")
+		}
+
+		path = fmt.Sprintf("projects/%s/secrets/%s", project, path)
+	}
+
+	if !strings.Contains(path, "/versions/") {
+		path += "/versions/latest"
+	}
+
+	return path, nil
+}
@@ -0,0 +1,90 @@
+package secret_values_provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Provider fetches the secret document (YAML or JSON) from the external secret manager.
+// Implementations must not log the secret data and must not include it into the returned errors.
+type Provider interface {
+	Fetch(ctx context.Context, path string) ([]byte, error)
+}
+
+var (
+	providers = map[string]Provider{
+		"vault":  &VaultProvider{},
+		"aws-sm": &AWSSecretsManagerProvider{},
+		"gcp-sm": &GCPSecretManagerProvider{},
+	}
+
+	cache      = map[string][]byte{}
+	cacheMutex sync.Mutex
+)
+
+// IsSecretValuesURI checks whether the secret values should be fetched from the external secret manager (e.g. vault://secret/data/app)
+// instead of being read from the encrypted secret values file.
+func IsSecretValuesURI(uri string) bool {
+	scheme, _, ok := parseURI(uri)
+	if !ok {
+		return false
+	}
+
+	_, isSupported := providers[scheme]
+	return isSupported
+}
+
+// GetSecretValues fetches the secret values from the external secret manager.
+// The fetched secret is cached in memory only for the lifetime of the process, so that every secret is fetched once.
+func GetSecretValues(ctx context.Context, uri string) (map[string]interface{}, error) {
+	scheme, path, ok := parseURI(uri)
+	if !ok {
+		return nil, fmt.Errorf("invalid secret values uri %q", uri)
+	}
+
+	provider, isSupported := providers[scheme]
+	if !isSupported {
+		return nil, fmt.Errorf("unsupported secret values provider %q", scheme)
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("invalid secret values uri %q: secret path required", uri)
+	}
+
+	cacheMutex.Lock()
+	data, isCached := cache[uri]
+	cacheMutex.Unlock()
+
+	if !isCached {
+		var err error
+		data, err = provider.Fetch(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch secret values %q: %s", uri, err)
+		}
+
+		cacheMutex.Lock()
+		cache[uri] = data
+		cacheMutex.Unlock()
+	}
+
+	values := map[string]interface{}{}
+	// The parsing error is not returned as is, because it could contain the part of the secret data.
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("unable to parse secret values %q: YAML or JSON map expected", uri)
+	}
+
+	return values, nil
+}
+
+func parseURI(uri string) (string, string, bool) {
+	parts := strings.SplitN(uri, "://", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	return parts[0], strings.Trim(parts[1], "/"), true
+}
@@ -0,0 +1,92 @@
+package secret_values_provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("secret values provider", func() {
+	It("should detect secret values uri", func() {
+		Ω(IsSecretValuesURI("vault://secret/data/app")).Should(BeTrue())
+		Ω(IsSecretValuesURI("aws-sm://app")).Should(BeTrue())
+		Ω(IsSecretValuesURI("gcp-sm://projects/p/secrets/app")).Should(BeTrue())
+		Ω(IsSecretValuesURI(".helm/secret-values.yaml")).Should(BeFalse())
+		Ω(IsSecretValuesURI("unknown://app")).Should(BeFalse())
+	})
+
+	It("should build gcp secret version name", func() {
+		name, err := getGCPSecretVersionName("projects/p/secrets/app")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("projects/p/secrets/app/versions/latest"))
+
+		name, err = getGCPSecretVersionName("projects/p/secrets/app/versions/3")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("projects/p/secrets/app/versions/3"))
+	})
+
+	Context("vault", func() {
+		var server *httptest.Server
+		var requests int
+
+		BeforeEach(func() {
+			requests = 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				if r.Header.Get("X-Vault-Token") != "token" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				switch r.URL.Path {
+				case "/v1/secret/data/app":
+					_, _ = w.Write([]byte(`{"data": {"data": {"db": {"password": "qwerty"}}, "metadata": {"version": 1}}}`))
+				case "/v1/kv/app":
+					_, _ = w.Write([]byte(`{"data": {"token": "abc"}}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+
+			os.Setenv("VAULT_ADDR", server.URL)
+			os.Setenv("VAULT_TOKEN", "token")
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.Unsetenv("VAULT_ADDR")
+			os.Unsetenv("VAULT_TOKEN")
+
+			cacheMutex.Lock()
+			cache = map[string][]byte{}
+			cacheMutex.Unlock()
+		})
+
+		It("should fetch kv v2 secret and cache it", func() {
+			for i := 0; i < 2; i++ {
+				values, err := GetSecretValues(context.Background(), "vault://secret/data/app")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(values).Should(Equal(map[string]interface{}{"db": map[string]interface{}{"password": "qwerty"}}))
+			}
+
+			Ω(requests).Should(Equal(1))
+		})
+
+		It("should fetch kv v1 secret", func() {
+			values, err := GetSecretValues(context.Background(), "vault://kv/app")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(values).Should(Equal(map[string]interface{}{"token": "abc"}))
+		})
+
+		It("should fail without leaking the response", func() {
+			_, err := GetSecretValues(context.Background(), "vault://secret/data/missing")
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("404"))
+		})
+	})
+})
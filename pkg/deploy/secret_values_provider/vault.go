@@ -0,0 +1,90 @@
+package secret_values_provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VaultProvider fetches the secret from the HashiCorp Vault HTTP API: vault://secret/data/app reads the secret/data/app path.
+// Vault address and token are taken from the standard VAULT_ADDR, VAULT_TOKEN (or ~/.vault-token) and VAULT_NAMESPACE environment variables.
+// The keys of the secret (the data of the KV v2 secret) become the values.
+type VaultProvider struct{}
+
+func (p *VaultProvider) Fetch(ctx context.Context, path string) ([]byte, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR environment variable required")
+	}
+
+	token, err := getVaultToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(addr, "/"), path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault responded with status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("unexpected vault response")
+	}
+
+	data := secret.Data
+	// KV v2 secrets engine wraps the secret data with the metadata.
+	if kvData, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = kvData
+		}
+	}
+
+	return json.Marshal(data)
+}
+
+func getVaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(homeDir, ".vault-token"))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("VAULT_TOKEN environment variable or ~/.vault-token file required")
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read ~/.vault-token: %s", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
@@ -3,23 +3,34 @@ package secrets_manager
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/secret"
 )
 
+const (
+	SecretsBackendAes  = "aes"
+	SecretsBackendSops = "sops"
+)
+
 type SecretsManager struct {
 	DisableSecretsDecryption bool
+	// SecretsBackend is used to encrypt the data, the backend of the encrypted data is detected for each file on decryption.
+	// The secret key is loaded on the first use of werf AES encryption if the backend is not specified.
+	SecretsBackend string
 }
 
 type SecretsManagerOptions struct {
 	DisableSecretsDecryption bool
+	SecretsBackend           string
 }
 
 func NewSecretsManager(opts SecretsManagerOptions) *SecretsManager {
 	return &SecretsManager{
 		DisableSecretsDecryption: opts.DisableSecretsDecryption,
+		SecretsBackend:           opts.SecretsBackend,
 	}
 }
 
@@ -29,13 +40,26 @@ func (manager *SecretsManager) GetYamlEncoder(ctx context.Context, workingDir st
 		return secret.NewYamlEncoder(nil), nil
 	}
 
-	if key, err := GetRequiredSecretKey(workingDir); err != nil {
-		return nil, fmt.Errorf("unable to load secret key: %s", err)
-	} else if enc, err := secret.NewAesEncoder(key); err != nil {
-		return nil, fmt.Errorf("check encryption key: %s", err)
-	} else {
-		return secret.NewYamlEncoder(enc), nil
+	if err := ValidateSecretsBackend(manager.SecretsBackend); err != nil {
+		return nil, err
+	}
+
+	encryptWithSops := manager.SecretsBackend == SecretsBackendSops
+	if encryptWithSops {
+		logboek.Context(ctx).Info().LogLn("Using sops secrets backend")
 	}
+
+	aesEncoder := &lazyAesEncoder{loadKey: func() ([]byte, error) {
+		return GetRequiredSecretKey(workingDir)
+	}}
+
+	if manager.SecretsBackend == SecretsBackendAes {
+		if _, err := aesEncoder.getEncoder(); err != nil {
+			return nil, err
+		}
+	}
+
+	return secret.NewYamlEncoder(aesEncoder).WithSops(workingDir, encryptWithSops), nil
 }
 
 func (manager *SecretsManager) GetYamlEncoderForOldKey(ctx context.Context) (*secret.YamlEncoder, error) {
@@ -47,3 +71,55 @@ func (manager *SecretsManager) GetYamlEncoderForOldKey(ctx context.Context) (*se
 		return secret.NewYamlEncoder(enc), nil
 	}
 }
+
+func ValidateSecretsBackend(backend string) error {
+	switch backend {
+	case "", SecretsBackendAes, SecretsBackendSops:
+		return nil
+	default:
+		return fmt.Errorf("bad secrets backend %q: expected %s or %s", backend, SecretsBackendAes, SecretsBackendSops)
+	}
+}
+
+// lazyAesEncoder loads the secret key on the first use, so that the key is required only to process the data encrypted with werf AES encryption.
+type lazyAesEncoder struct {
+	loadKey func() ([]byte, error)
+
+	once    sync.Once
+	encoder *secret.AesEncoder
+	err     error
+}
+
+func (e *lazyAesEncoder) Encrypt(data []byte) ([]byte, error) {
+	encoder, err := e.getEncoder()
+	if err != nil {
+		return nil, err
+	}
+
+	return encoder.Encrypt(data)
+}
+
+func (e *lazyAesEncoder) Decrypt(data []byte) ([]byte, error) {
+	encoder, err := e.getEncoder()
+	if err != nil {
+		return nil, err
+	}
+
+	return encoder.Decrypt(data)
+}
+
+func (e *lazyAesEncoder) getEncoder() (*secret.AesEncoder, error) {
+	e.once.Do(func() {
+		key, err := e.loadKey()
+		if err != nil {
+			e.err = fmt.Errorf("unable to load secret key: %s", err)
+			return
+		}
+
+		if e.encoder, err = secret.NewAesEncoder(key); err != nil {
+			e.err = fmt.Errorf("check encryption key: %s", err)
+		}
+	})
+
+	return e.encoder, e.err
+}
@@ -0,0 +1,119 @@
+package dev_sync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+func TestParseMappings(t *testing.T) {
+	mappings, err := ParseMappings("src:/app/src, ./static/:/app/static")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []Mapping{{LocalPath: "src", ContainerPath: "/app/src"}, {LocalPath: "static", ContainerPath: "/app/static"}}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Errorf("unexpected mappings: %v", mappings)
+	}
+
+	for _, value := range []string{"", "src", "src:app", "/src:/app", "../src:/app"} {
+		if _, err := ParseMappings(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestDiscoverTargets(t *testing.T) {
+	newDeployment := func(name string, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev", Annotations: annotations},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}, {Name: "sidecar"}}}},
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		newDeployment("backend", map[string]string{helm.ReleaseNameAnnoName: "myapp", SyncAnnoName: "src:/app/src"}),
+		newDeployment("frontend", map[string]string{helm.ReleaseNameAnnoName: "myapp", SyncAnnoName: "web:/srv", SyncContainerAnnoName: "sidecar"}),
+		newDeployment("other-release", map[string]string{helm.ReleaseNameAnnoName: "other", SyncAnnoName: "src:/app/src"}),
+		newDeployment("not-synced", map[string]string{helm.ReleaseNameAnnoName: "myapp"}),
+	)
+
+	targets, err := DiscoverTargets(context.Background(), client, "dev", "myapp")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if targets[0].Name != "backend" || targets[0].Container != "main" {
+		t.Errorf("unexpected target: %+v", targets[0])
+	}
+	if targets[1].Name != "frontend" || targets[1].Container != "sidecar" {
+		t.Errorf("unexpected target: %+v", targets[1])
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "werf-dev-sync-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(projectDir)
+
+	writeFile := func(relPath, data string) {
+		p := filepath.Join(projectDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("src/main.go", "package main")
+	writeFile("src/util/util.go", "package util")
+	writeFile("src/tmp/cache", "ignored")
+
+	pathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{ExcludeGlobs: []string{"src/tmp"}})
+
+	prev, err := takeSnapshot(projectDir, "src", pathMatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile("src/main.go", "package main // changed")
+	writeFile("src/new.go", "package main")
+	writeFile("src/tmp/cache", "ignored changed")
+	if err := os.Remove(filepath.Join(projectDir, "src/util/util.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	cur, err := takeSnapshot(projectDir, "src", pathMatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, deleted := diffSnapshots(prev, cur)
+	if !reflect.DeepEqual(changed, []string{"main.go", "new.go"}) {
+		t.Errorf("unexpected changed files: %v", changed)
+	}
+	if !reflect.DeepEqual(deleted, []string{"util/util.go"}) {
+		t.Errorf("unexpected deleted files: %v", deleted)
+	}
+}
@@ -0,0 +1,78 @@
+package dev_sync
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+type fileState struct {
+	ModTime int64
+	Size    int64
+	Mode    os.FileMode
+}
+
+// snapshot maps the file paths relative to the dir to the file states.
+type snapshot map[string]fileState
+
+// takeSnapshot walks the localPath inside the projectDir and records the state of each regular file, which is not excluded by the pathMatcher.
+// The pathMatcher gets the paths relative to the projectDir, the missing localPath results in the empty snapshot.
+func takeSnapshot(projectDir, localPath string, pathMatcher path_matcher.PathMatcher) (snapshot, error) {
+	res := snapshot{}
+	dir := filepath.Join(projectDir, filepath.FromSlash(localPath))
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		projectRelPath := filepath.ToSlash(filepath.Join(localPath, relPath))
+
+		if info.IsDir() {
+			if info.Name() == ".git" || (relPath != "." && !pathMatcher.IsDirOrSubmodulePathMatched(projectRelPath)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() || !pathMatcher.IsPathMatched(projectRelPath) {
+			return nil
+		}
+
+		res[relPath] = fileState{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Mode: info.Mode()}
+		return nil
+	})
+
+	return res, err
+}
+
+// diffSnapshots returns the sorted lists of the created or modified and the deleted files.
+func diffSnapshots(prev, cur snapshot) (changed, deleted []string) {
+	for path, state := range cur {
+		if prevState, ok := prev[path]; !ok || prevState != state {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			deleted = append(deleted, path)
+		}
+	}
+
+	sort.Strings(changed)
+	sort.Strings(deleted)
+
+	return changed, deleted
+}
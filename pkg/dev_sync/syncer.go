@@ -0,0 +1,238 @@
+package dev_sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/werf/logboek"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+const DefaultPollPeriod = time.Second
+
+// Syncer copies the changed project files into the running containers of the targets
+// and removes the deleted ones: the files are streamed as the tar archive into the `tar` command executed in the container,
+// so the container image should include tar.
+type Syncer struct {
+	KubeClient kubernetes.Interface
+	KubeConfig *rest.Config
+	ProjectDir string
+	Targets    []*Target
+	// IgnoreGlobs are the globs of the project files, which are never synced (--dev-ignore)
+	IgnoreGlobs []string
+	PollPeriod  time.Duration
+}
+
+func NewSyncer(kubeClient kubernetes.Interface, kubeConfig *rest.Config, projectDir string, targets []*Target, ignoreGlobs []string) *Syncer {
+	return &Syncer{
+		KubeClient:  kubeClient,
+		KubeConfig:  kubeConfig,
+		ProjectDir:  projectDir,
+		Targets:     targets,
+		IgnoreGlobs: ignoreGlobs,
+		PollPeriod:  DefaultPollPeriod,
+	}
+}
+
+// Run watches the mappings local paths and syncs the changes until the context is done.
+// The files changed before the start are not synced: the deployed images are expected to contain the current files.
+// The sync errors are reported as warnings, so that the pod restarts during the development do not stop the sync.
+func (syncer *Syncer) Run(ctx context.Context) error {
+	pathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{ExcludeGlobs: syncer.IgnoreGlobs})
+
+	snapshots := map[string]snapshot{}
+	for _, localPath := range syncer.localPaths() {
+		s, err := takeSnapshot(syncer.ProjectDir, localPath, pathMatcher)
+		if err != nil {
+			return fmt.Errorf("unable to scan %s: %s", localPath, err)
+		}
+		snapshots[localPath] = s
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(syncer.PollPeriod):
+		}
+
+		for _, localPath := range syncer.localPaths() {
+			s, err := takeSnapshot(syncer.ProjectDir, localPath, pathMatcher)
+			if err != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: unable to scan %s: %s\n", localPath, err)
+				continue
+			}
+
+			changed, deleted := diffSnapshots(snapshots[localPath], s)
+			snapshots[localPath] = s
+			if len(changed) == 0 && len(deleted) == 0 {
+				continue
+			}
+
+			for _, target := range syncer.Targets {
+				for _, mapping := range target.Mappings {
+					if mapping.LocalPath != localPath {
+						continue
+					}
+
+					if err := syncer.syncMapping(ctx, target, mapping, changed, deleted); err != nil {
+						logboek.Context(ctx).Warn().LogF("WARNING: unable to sync %s into %s: %s\n", mapping.LocalPath, target, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (syncer *Syncer) localPaths() []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, target := range syncer.Targets {
+		for _, mapping := range target.Mappings {
+			if !seen[mapping.LocalPath] {
+				seen[mapping.LocalPath] = true
+				res = append(res, mapping.LocalPath)
+			}
+		}
+	}
+
+	return res
+}
+
+func (syncer *Syncer) syncMapping(ctx context.Context, target *Target, mapping Mapping, changed, deleted []string) error {
+	selector, err := metav1.LabelSelectorAsSelector(target.Selector)
+	if err != nil {
+		return fmt.Errorf("bad selector: %s", err)
+	}
+
+	pods, err := syncer.KubeClient.CoreV1().Pods(target.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return fmt.Errorf("unable to list pods: %s", err)
+	}
+
+	var archive []byte
+	if len(changed) > 0 {
+		if archive, err = createArchive(filepath.Join(syncer.ProjectDir, filepath.FromSlash(mapping.LocalPath)), changed); err != nil {
+			return err
+		}
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		if len(deleted) > 0 {
+			cmd := []string{"rm", "-f", "--"}
+			for _, p := range deleted {
+				cmd = append(cmd, path.Join(mapping.ContainerPath, p))
+			}
+
+			if err := syncer.exec(ctx, pod.Namespace, pod.Name, target.Container, cmd, nil); err != nil {
+				return fmt.Errorf("pod/%s: %s", pod.Name, err)
+			}
+		}
+
+		if len(changed) > 0 {
+			cmd := []string{"sh", "-c", fmt.Sprintf("mkdir -p '%[1]s' && tar -xmf - -C '%[1]s'", mapping.ContainerPath)}
+			if err := syncer.exec(ctx, pod.Namespace, pod.Name, target.Container, cmd, bytes.NewReader(archive)); err != nil {
+				return fmt.Errorf("pod/%s: %s", pod.Name, err)
+			}
+		}
+
+		logboek.Context(ctx).Default().LogF("Synced %s into %s pod/%s container %s: %d changed, %d deleted\n", mapping.LocalPath, target, pod.Name, target.Container, len(changed), len(deleted))
+	}
+
+	return nil
+}
+
+func (syncer *Syncer) exec(ctx context.Context, namespace, podName, containerName string, cmd []string, stdin io.Reader) error {
+	req := syncer.KubeClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: containerName,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(syncer.KubeConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("unable to create executor: %s", err)
+	}
+
+	var output bytes.Buffer
+	if err := executor.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: &output, Stderr: &output}); err != nil {
+		return fmt.Errorf("%s failed: %s\n%s", strings.Join(cmd, " "), err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+// createArchive creates the tar archive of the files, the paths are relative to the dir.
+func createArchive(dir string, paths []string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for _, p := range paths {
+		if err := addArchiveFile(tw, dir, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func addArchiveFile(tw *tar.Writer, dir, relPath string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(relPath)))
+	if os.IsNotExist(err) {
+		// the file is deleted after the scan and will be deleted from the container with the next sync
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = relPath
+	// the local file owner is meaningless inside the container
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,145 @@
+package dev_sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/werf/pkg/deploy/helm"
+)
+
+const (
+	// SyncAnnoName is the comma separated list of LOCAL_PATH:CONTAINER_PATH mappings of the workload files to sync,
+	// LOCAL_PATH is relative to the project dir and CONTAINER_PATH is absolute.
+	SyncAnnoName = "werf.io/dev-sync"
+	// SyncContainerAnnoName selects the container of the workload pods to sync the files into, the first container by default.
+	SyncContainerAnnoName = "werf.io/dev-sync-container"
+)
+
+type Mapping struct {
+	LocalPath     string
+	ContainerPath string
+}
+
+// Target is the workload of the release annotated with werf.io/dev-sync.
+type Target struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Selector  *metav1.LabelSelector
+	Container string
+	Mappings  []Mapping
+}
+
+func (target *Target) String() string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(target.Kind), target.Name)
+}
+
+func ParseMappings(value string) ([]Mapping, error) {
+	var mappings []Mapping
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		parts := strings.SplitN(part, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("bad mapping %q: expected LOCAL_PATH:CONTAINER_PATH", part)
+		}
+
+		localPath := path.Clean(parts[0])
+		if path.IsAbs(localPath) || localPath == ".." || strings.HasPrefix(localPath, "../") {
+			return nil, fmt.Errorf("bad mapping %q: local path should be relative to the project dir", part)
+		}
+
+		if !path.IsAbs(parts[1]) {
+			return nil, fmt.Errorf("bad mapping %q: container path should be absolute", part)
+		}
+
+		mappings = append(mappings, Mapping{LocalPath: localPath, ContainerPath: path.Clean(parts[1])})
+	}
+
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("no mappings specified")
+	}
+
+	return mappings, nil
+}
+
+// DiscoverTargets returns the Deployments, StatefulSets and DaemonSets of the release annotated with werf.io/dev-sync.
+func DiscoverTargets(ctx context.Context, client kubernetes.Interface, namespace, releaseName string) ([]*Target, error) {
+	var targets []*Target
+	addTarget := func(kind string, meta metav1.ObjectMeta, selector *metav1.LabelSelector, containerNames []string) error {
+		value, ok := meta.Annotations[SyncAnnoName]
+		if !ok || meta.Annotations[helm.ReleaseNameAnnoName] != releaseName {
+			return nil
+		}
+
+		target := &Target{Kind: kind, Name: meta.Name, Namespace: namespace, Selector: selector}
+
+		mappings, err := ParseMappings(value)
+		if err != nil {
+			return fmt.Errorf("bad %s annotation of %s: %s", SyncAnnoName, target, err)
+		}
+		target.Mappings = mappings
+
+		target.Container = meta.Annotations[SyncContainerAnnoName]
+		if target.Container == "" && len(containerNames) > 0 {
+			target.Container = containerNames[0]
+		}
+
+		targets = append(targets, target)
+		return nil
+	}
+
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list deployments: %s", err)
+	}
+	for _, obj := range deployments.Items {
+		if err := addTarget("Deployment", obj.ObjectMeta, obj.Spec.Selector, podContainerNames(obj.Spec.Template.Spec.Containers)); err != nil {
+			return nil, err
+		}
+	}
+
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list statefulsets: %s", err)
+	}
+	for _, obj := range statefulSets.Items {
+		if err := addTarget("StatefulSet", obj.ObjectMeta, obj.Spec.Selector, podContainerNames(obj.Spec.Template.Spec.Containers)); err != nil {
+			return nil, err
+		}
+	}
+
+	daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list daemonsets: %s", err)
+	}
+	for _, obj := range daemonSets.Items {
+		if err := addTarget("DaemonSet", obj.ObjectMeta, obj.Spec.Selector, podContainerNames(obj.Spec.Template.Spec.Containers)); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].String() < targets[j].String()
+	})
+
+	return targets, nil
+}
+
+func podContainerNames(containers []corev1.Container) []string {
+	var names []string
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
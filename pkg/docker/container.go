@@ -1,9 +1,12 @@
 package docker
 
 import (
+	"io"
+
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/command/container"
 	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"golang.org/x/net/context"
 )
@@ -43,6 +46,23 @@ func ContainerRemove(ctx context.Context, ref string, options types.ContainerRem
 	return apiCli(ctx).ContainerRemove(ctx, ref, options)
 }
 
+func ContainerRename(ctx context.Context, ref, newName string) error {
+	return apiCli(ctx).ContainerRename(ctx, ref, newName)
+}
+
+func ContainerCreate(ctx context.Context, config *containertypes.Config, name string) (string, error) {
+	response, err := apiCli(ctx).ContainerCreate(ctx, config, nil, nil, nil, name)
+	if err != nil {
+		return "", err
+	}
+
+	return response.ID, nil
+}
+
+func ContainerExport(ctx context.Context, ref string) (io.ReadCloser, error) {
+	return apiCli(ctx).ContainerExport(ctx, ref)
+}
+
 func doCliCreate(c command.Cli, args ...string) error {
 	return prepareCliCmd(container.NewCreateCommand(c), args...).Execute()
 }
@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	// DockerfileBuilderDocker is the legacy docker server builder
+	DockerfileBuilderDocker = "docker"
+	// DockerfileBuilderBuildKit is the docker server BuildKit mode
+	DockerfileBuilderBuildKit = "buildkit"
+
+	dockerBuildkitEnvName = "DOCKER_BUILDKIT"
+)
+
+var (
+	dockerfileBuilder        string
+	platformEmulationEnabled bool
+)
+
+// SetDockerfileBuilder selects the backend for the Dockerfile images builds of the werf process.
+// The environment is not changed, the backend is passed only to the docker cli build command (see IsBuildKitEnabled).
+func SetDockerfileBuilder(builder string) error {
+	switch builder {
+	case DockerfileBuilderDocker, DockerfileBuilderBuildKit:
+	default:
+		return fmt.Errorf("unsupported builder %q, expected %q or %q", builder, DockerfileBuilderDocker, DockerfileBuilderBuildKit)
+	}
+
+	if buildKit, isSet, err := getBuildKitEnv(); err != nil {
+		return err
+	} else if isSet && buildKit != (builder == DockerfileBuilderBuildKit) {
+		return fmt.Errorf("builder %q conflicts with %s=%s environment variable", builder, dockerBuildkitEnvName, os.Getenv(dockerBuildkitEnvName))
+	}
+
+	if platformEmulationEnabled && builder == DockerfileBuilderDocker {
+		return fmt.Errorf("builder %q cannot be used with the platform emulation, which requires BuildKit", builder)
+	}
+
+	dockerfileBuilder = builder
+
+	return nil
+}
+
+// IsBuildKitEnabled returns true if Dockerfile images are built in the docker server BuildKit mode.
+// The builder selected by SetDockerfileBuilder takes priority over DOCKER_BUILDKIT environment variable,
+// the legacy builder is used by default unless the platform emulation is enabled.
+func IsBuildKitEnabled() bool {
+	if dockerfileBuilder != "" {
+		return dockerfileBuilder == DockerfileBuilderBuildKit
+	}
+
+	if buildKit, isSet, err := getBuildKitEnv(); err == nil && isSet {
+		return buildKit
+	}
+
+	return platformEmulationEnabled
+}
+
+func getBuildKitEnv() (bool, bool, error) {
+	value := os.Getenv(dockerBuildkitEnvName)
+	if value == "" {
+		return false, false, nil
+	}
+
+	buildKit, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, true, fmt.Errorf("%s environment variable expects boolean value: %s", dockerBuildkitEnvName, err)
+	}
+
+	return buildKit, true, nil
+}
+
+// dockerfileBuilderCli passes the selected builder to the docker cli build command, which decides on BuildKit usage by the server info
+// unless DOCKER_BUILDKIT environment variable is set (the variable cannot conflict with the selected builder).
+type dockerfileBuilderCli struct {
+	command.Cli
+	buildKit bool
+}
+
+func newDockerfileBuilderCli(cli command.Cli) *dockerfileBuilderCli {
+	return &dockerfileBuilderCli{Cli: cli, buildKit: IsBuildKitEnabled()}
+}
+
+func (c *dockerfileBuilderCli) ServerInfo() command.ServerInfo {
+	info := c.Cli.ServerInfo()
+
+	if c.buildKit {
+		info.BuildkitVersion = types.BuilderBuildKit
+	} else {
+		info.BuildkitVersion = types.BuilderV1
+	}
+
+	return info
+}
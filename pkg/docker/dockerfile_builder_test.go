@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/docker/api/types"
+)
+
+func resetDockerfileBuilder(t *testing.T) {
+	t.Cleanup(func() {
+		dockerfileBuilder = ""
+		platformEmulationEnabled = false
+	})
+}
+
+func TestIsBuildKitEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		env               string
+		builder           string
+		platformEmulation bool
+		expected          bool
+	}{
+		{name: "default"},
+		{name: "env enabled", env: "1", expected: true},
+		{name: "env disabled", env: "0"},
+		{name: "builder", builder: DockerfileBuilderBuildKit, expected: true},
+		{name: "builder matches env", env: "true", builder: DockerfileBuilderBuildKit, expected: true},
+		{name: "legacy builder matches env", env: "0", builder: DockerfileBuilderDocker},
+		{name: "platform emulation", platformEmulation: true, expected: true},
+		{name: "platform emulation with builder", platformEmulation: true, builder: DockerfileBuilderBuildKit, expected: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resetDockerfileBuilder(t)
+			t.Setenv(dockerBuildkitEnvName, tc.env)
+			platformEmulationEnabled = tc.platformEmulation
+
+			if tc.builder != "" {
+				if err := SetDockerfileBuilder(tc.builder); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if res := IsBuildKitEnabled(); res != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestSetDockerfileBuilder_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		env               string
+		builder           string
+		platformEmulation bool
+	}{
+		{name: "unsupported builder", builder: "kaniko"},
+		{name: "conflict with env", env: "1", builder: DockerfileBuilderDocker},
+		{name: "conflict with disabled env", env: "0", builder: DockerfileBuilderBuildKit},
+		{name: "invalid env", env: "yes", builder: DockerfileBuilderBuildKit},
+		{name: "conflict with platform emulation", builder: DockerfileBuilderDocker, platformEmulation: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resetDockerfileBuilder(t)
+			t.Setenv(dockerBuildkitEnvName, tc.env)
+			platformEmulationEnabled = tc.platformEmulation
+
+			if err := SetDockerfileBuilder(tc.builder); err == nil {
+				t.Fatal("expected error")
+			}
+
+			if dockerfileBuilder != "" {
+				t.Fatalf("expected builder not to be set, got %q", dockerfileBuilder)
+			}
+		})
+	}
+}
+
+type serverInfoCli struct {
+	command.Cli
+	info command.ServerInfo
+}
+
+func (c *serverInfoCli) ServerInfo() command.ServerInfo {
+	return c.info
+}
+
+func TestDockerfileBuilderCli_ServerInfo(t *testing.T) {
+	cli := &serverInfoCli{info: command.ServerInfo{OSType: "linux", BuildkitVersion: types.BuilderBuildKit}}
+
+	for _, tc := range []struct {
+		buildKit bool
+		expected types.BuilderVersion
+	}{
+		{buildKit: false, expected: types.BuilderV1},
+		{buildKit: true, expected: types.BuilderBuildKit},
+	} {
+		info := (&dockerfileBuilderCli{Cli: cli, buildKit: tc.buildKit}).ServerInfo()
+		if info.BuildkitVersion != tc.expected {
+			t.Errorf("buildKit=%v: expected builder version %q, got %q", tc.buildKit, tc.expected, info.BuildkitVersion)
+		}
+
+		if info.OSType != "linux" {
+			t.Errorf("buildKit=%v: expected server info to be kept, got %#v", tc.buildKit, info)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
-	"os"
 	"strings"
 	"time"
 
@@ -65,6 +64,10 @@ func ImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error)
 	return &inspect, nil
 }
 
+func ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	return apiCli(ctx).ImageSave(ctx, refs)
+}
+
 func doCliPull(c command.Cli, args ...string) error {
 	return prepareCliCmd(image.NewPullCommand(c), args...).Execute()
 }
@@ -189,20 +192,11 @@ func CliRmi_LiveOutput(ctx context.Context, args ...string) error {
 }
 
 func doCliBuild(c command.Cli, args ...string) error {
-	return prepareCliCmd(image.NewBuildCommand(c), args...).Execute()
+	return prepareCliCmd(image.NewBuildCommand(newDockerfileBuilderCli(c)), args...).Execute()
 }
 
 func CliBuild_LiveOutputWithCustomIn(ctx context.Context, rc io.ReadCloser, args ...string) error {
-	dockerBuildkitEnvName := "DOCKER_BUILDKIT"
-	dockerBuildkitEnvValue := os.Getenv(dockerBuildkitEnvName)
-
-	switch dockerBuildkitEnvValue {
-	case "":
-		// disable buildkit by default
-		if err := os.Setenv(dockerBuildkitEnvName, "0"); err != nil {
-			return err
-		}
-	case "1":
+	if IsBuildKitEnabled() {
 		// disable buildkit output in background tasks due to https://github.com/docker/cli/issues/2889
 		// there is no true way to get output, because buildkit uses the standard output and error streams instead of defined ones in the cli instance
 		if ctx.Value(parallelConstant.CtxBackgroundTaskIDKey) != nil {
@@ -3,6 +3,7 @@ package docker
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -28,7 +29,8 @@ var (
 )
 
 const (
-	ctxDockerCliKey = "docker_cli"
+	ctxDockerCliKey              = "docker_cli"
+	ctxDockerCliOutputCaptureKey = "docker_cli_output_capture"
 )
 
 func Init(ctx context.Context, dockerConfigDir string, verbose, debug bool, platform string) error {
@@ -39,22 +41,22 @@ func Init(ctx context.Context, dockerConfigDir string, verbose, debug bool, plat
 		return fmt.Errorf("unsupported platform")
 	}
 	if platform != "" {
-		os.Setenv("DOCKER_DEFAULT_PLATFORM", platform)
-		os.Setenv("DOCKER_BUILDKIT", "1")
-	}
+		if dockerfileBuilder == DockerfileBuilderDocker {
+			return fmt.Errorf("builder %q cannot be used with the platform emulation, which requires BuildKit", dockerfileBuilder)
+		}
 
-	if dockerConfigDir != "" {
-		cliconfig.SetDir(dockerConfigDir)
+		os.Setenv("DOCKER_DEFAULT_PLATFORM", platform)
+		platformEmulationEnabled = true
 	}
 
-	err := os.Setenv("DOCKER_CONFIG", dockerConfigDir)
-	if err != nil {
-		return fmt.Errorf("cannot set DOCKER_CONFIG to %s: %s", dockerConfigDir, err)
+	if err := InitConfig(dockerConfigDir); err != nil {
+		return err
 	}
 
 	isDebug = os.Getenv("WERF_DEBUG_DOCKER") == "1"
 	liveCliOutputEnabled = verbose || debug
 
+	var err error
 	defaultCLi, err = newDockerCli(defaultCliOptions(ctx))
 	if err != nil {
 		return err
@@ -63,6 +65,25 @@ func Init(ctx context.Context, dockerConfigDir string, verbose, debug bool, plat
 	return nil
 }
 
+// InitConfig sets the docker config dir with the registry credentials without the docker cli initialization.
+func InitConfig(dockerConfigDir string) error {
+	if dockerConfigDir != "" {
+		cliconfig.SetDir(dockerConfigDir)
+	}
+
+	if err := os.Setenv("DOCKER_CONFIG", dockerConfigDir); err != nil {
+		return fmt.Errorf("cannot set DOCKER_CONFIG to %s: %s", dockerConfigDir, err)
+	}
+
+	return nil
+}
+
+// IsEnabled returns true if the docker cli is initialized.
+// The commands which do not build images (e.g. werf render in the GitOps plugin mode) work without the docker server.
+func IsEnabled() bool {
+	return defaultCLi != nil
+}
+
 func ServerVersion(ctx context.Context) (*types.Version, error) {
 	version, err := cli(ctx).Client().ServerVersion(ctx)
 	if err != nil {
@@ -124,10 +145,15 @@ func apiCli(ctx context.Context) client.APIClient {
 }
 
 func defaultCliOptions(ctx context.Context) []command.DockerCliOption {
+	outStream, errStream := logboek.Context(ctx).OutStream(), logboek.Context(ctx).ErrStream()
+	if captureWriter, ok := ctx.Value(ctxDockerCliOutputCaptureKey).(io.Writer); ok {
+		outStream, errStream = io.MultiWriter(outStream, captureWriter), io.MultiWriter(errStream, captureWriter)
+	}
+
 	return []command.DockerCliOption{
 		command.WithInputStream(os.Stdin),
-		command.WithOutputStream(logboek.Context(ctx).OutStream()),
-		command.WithErrorStream(logboek.Context(ctx).ErrStream()),
+		command.WithOutputStream(outStream),
+		command.WithErrorStream(errStream),
 		command.WithContentTrust(false),
 	}
 }
@@ -168,6 +194,22 @@ func SyncContextCliWithLogger(ctx context.Context) error {
 	return cli(ctx).Apply(defaultCliOptions(ctx)...)
 }
 
+// DoWithOutputCapture runs f with the context in which the live output of the docker cli commands is also written into the captureWriter
+func DoWithOutputCapture(ctx context.Context, captureWriter io.Writer, f func(ctx context.Context) error) error {
+	captureCtx := context.WithValue(ctx, ctxDockerCliOutputCaptureKey, captureWriter)
+	if err := SyncContextCliWithLogger(captureCtx); err != nil {
+		return err
+	}
+
+	err := f(captureCtx)
+
+	if syncErr := SyncContextCliWithLogger(ctx); syncErr != nil && err == nil {
+		return syncErr
+	}
+
+	return err
+}
+
 func callCliWithRecordedOutput(ctx context.Context, commandCaller func(c command.Cli) error) (string, error) {
 	var output bytes.Buffer
 
@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/werf/logboek"
+)
+
+func TestDoWithOutputCapture(t *testing.T) {
+	ctx, err := NewContext(logboek.NewContext(context.Background(), logboek.NewLogger(ioutil.Discard, ioutil.Discard)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var capturedOutput bytes.Buffer
+	buildErr := errors.New("build failed")
+	err = DoWithOutputCapture(ctx, &capturedOutput, func(ctx context.Context) error {
+		fmt.Fprint(cli(ctx).Out(), "out ")
+		fmt.Fprint(cli(ctx).Err(), "err")
+		return buildErr
+	})
+	if err != buildErr {
+		t.Fatalf("expected the build error, got %v", err)
+	}
+
+	if capturedOutput.String() != "out err" {
+		t.Fatalf("expected the captured output %q, got %q", "out err", capturedOutput.String())
+	}
+
+	fmt.Fprint(cli(ctx).Out(), " after")
+	if capturedOutput.String() != "out err" {
+		t.Fatalf("expected the output not to be captured after the function, got %q", capturedOutput.String())
+	}
+}
@@ -2,10 +2,34 @@ package docker
 
 import (
 	"context"
+	"sync"
 
 	"github.com/docker/docker/api/types"
 )
 
+var (
+	isWindowsDaemon      *bool
+	isWindowsDaemonMutex sync.Mutex
+)
+
 func Info(ctx context.Context) (types.Info, error) {
 	return apiCli(ctx).Info(ctx)
 }
+
+// IsWindowsDaemon returns true if the docker server runs Windows containers, the docker server info is requested once.
+func IsWindowsDaemon(ctx context.Context) (bool, error) {
+	isWindowsDaemonMutex.Lock()
+	defer isWindowsDaemonMutex.Unlock()
+
+	if isWindowsDaemon == nil {
+		info, err := Info(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		res := info.OSType == "windows"
+		isWindowsDaemon = &res
+	}
+
+	return *isWindowsDaemon, nil
+}
@@ -0,0 +1,134 @@
+package docker_registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+)
+
+// Artifact is the non-image OCI artifact: the config and the layers have custom media types.
+type Artifact struct {
+	ConfigMediaType string
+	Config          []byte
+	Layers          []container_registry_extensions.ArtifactLayer
+	Annotations     map[string]string
+}
+
+func (api *genericApi) PushArtifact(ctx context.Context, reference string, artifact *Artifact) error {
+	return api.commonApi.PushArtifact(ctx, reference, artifact)
+}
+
+func (api *genericApi) PullArtifact(ctx context.Context, reference string) (*Artifact, error) {
+	return api.commonApi.PullArtifact(ctx, reference)
+}
+
+// GetArtifactDigest returns the manifest digest of the artifact, so that the artifact selected by the tag can be verified and pulled by the digest.
+func (api *genericApi) GetArtifactDigest(ctx context.Context, reference string) (string, error) {
+	return api.commonApi.GetArtifactDigest(ctx, reference)
+}
+
+func (api *api) PushArtifact(ctx context.Context, reference string, artifact *Artifact) error {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	img := container_registry_extensions.NewArtifactImage(artifact.ConfigMediaType, artifact.Config, artifact.Layers, artifact.Annotations)
+	if err := remote.Write(ref, img, api.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("write to the remote %s have failed: %s", ref.String(), err)
+	}
+
+	return nil
+}
+
+func (api *api) PullArtifact(ctx context.Context, reference string) (*Artifact, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	desc, err := remote.Get(ref, api.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest %q: %v", ref, err)
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest %q: %v", ref, err)
+	}
+
+	artifact := &Artifact{
+		ConfigMediaType: string(manifest.Config.MediaType),
+		Annotations:     manifest.Annotations,
+	}
+
+	if artifact.Config, err = api.fetchBlob(ctx, ref, manifest.Config.Digest); err != nil {
+		return nil, err
+	}
+
+	for _, l := range manifest.Layers {
+		data, err := api.fetchBlob(ctx, ref, l.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		artifact.Layers = append(artifact.Layers, container_registry_extensions.ArtifactLayer{
+			MediaType: string(l.MediaType),
+			Data:      data,
+		})
+	}
+
+	return artifact, nil
+}
+
+func (api *api) GetArtifactDigest(ctx context.Context, reference string) (string, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	desc, err := remote.Head(ref, api.remoteOptions(ctx)...)
+	if err != nil {
+		return "", fmt.Errorf("getting manifest %q: %v", ref, err)
+	}
+
+	return desc.Digest.String(), nil
+}
+
+func (api *api) fetchBlob(ctx context.Context, ref name.Reference, digest v1.Hash) ([]byte, error) {
+	blobRef := ref.Context().Digest(digest.String())
+
+	layer, err := remote.Layer(blobRef, api.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("getting blob %q: %v", blobRef, err)
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %q: %v", blobRef, err)
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %q: %v", blobRef, err)
+	}
+
+	return data, nil
+}
+
+func (api *api) remoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithTransport(api.getHttpTransport()),
+		remote.WithContext(ctx),
+	}
+}
@@ -0,0 +1,65 @@
+package docker_registry
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+)
+
+var _ = Describe("Artifact", func() {
+	It("should be pushed and pulled with custom media types and annotations", func() {
+		server := httptest.NewServer(registry.New())
+		defer server.Close()
+
+		reference := strings.TrimPrefix(server.URL, "http://") + "/project/bundle:1.0.0"
+		api := newAPI(apiOptions{InsecureRegistry: true})
+
+		artifact := &Artifact{
+			ConfigMediaType: "application/vnd.cncf.helm.config.v1+json",
+			Config:          []byte(`{"name":"bundle","version":"1.0.0"}`),
+			Layers: []container_registry_extensions.ArtifactLayer{
+				{MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip", Data: []byte("chart")},
+			},
+			Annotations: map[string]string{"org.opencontainers.image.title": "bundle"},
+		}
+
+		Ω(api.PushArtifact(context.Background(), reference, artifact)).Should(Succeed())
+
+		pulled, err := api.PullArtifact(context.Background(), reference)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(pulled).Should(Equal(artifact))
+	})
+
+	It("should be pulled by the digest of the tag", func() {
+		server := httptest.NewServer(registry.New())
+		defer server.Close()
+
+		repository := strings.TrimPrefix(server.URL, "http://") + "/project/policies"
+		api := newAPI(apiOptions{InsecureRegistry: true})
+
+		artifact := &Artifact{
+			ConfigMediaType: "application/vnd.werf.cleanup-policy-set.config.v1+json",
+			Config:          []byte(`{}`),
+			Layers:          []container_registry_extensions.ArtifactLayer{{MediaType: "application/vnd.werf.cleanup-policy-set.v1+yaml", Data: []byte("v1")}},
+		}
+		Ω(api.PushArtifact(context.Background(), repository+":latest", artifact)).Should(Succeed())
+
+		digest, err := api.GetArtifactDigest(context.Background(), repository+":latest")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(digest).Should(HavePrefix("sha256:"))
+
+		updatedArtifact := *artifact
+		updatedArtifact.Layers = []container_registry_extensions.ArtifactLayer{{MediaType: "application/vnd.werf.cleanup-policy-set.v1+yaml", Data: []byte("v2")}}
+		Ω(api.PushArtifact(context.Background(), repository+":latest", &updatedArtifact)).Should(Succeed())
+
+		pulled, err := api.PullArtifact(context.Background(), repository+"@"+digest)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(pulled).Should(Equal(artifact))
+	})
+})
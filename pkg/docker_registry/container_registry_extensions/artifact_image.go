@@ -0,0 +1,129 @@
+package container_registry_extensions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ArtifactLayer is the blob of the non-image OCI artifact with the custom media type.
+type ArtifactLayer struct {
+	MediaType string
+	Data      []byte
+}
+
+// artifactImage is the OCI manifest with the custom config and layers media types (e.g. helm chart),
+// which could be written into the container registry as a regular image.
+type artifactImage struct {
+	configMediaType string
+	config          []byte
+	layers          []*artifactLayer
+	annotations     map[string]string
+}
+
+func NewArtifactImage(configMediaType string, config []byte, layers []ArtifactLayer, annotations map[string]string) v1.Image {
+	core := &artifactImage{
+		configMediaType: configMediaType,
+		config:          config,
+		annotations:     annotations,
+	}
+
+	for _, l := range layers {
+		core.layers = append(core.layers, newArtifactLayer(l))
+	}
+
+	img, err := partial.CompressedToImage(core)
+	if err != nil {
+		panic(fmt.Sprintf("unable to create new ArtifactImage: %s", err))
+	}
+
+	return img
+}
+
+// MediaType implements partial.CompressedImageCore.
+func (i *artifactImage) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+// RawConfigFile implements partial.CompressedImageCore.
+func (i *artifactImage) RawConfigFile() ([]byte, error) {
+	return i.config, nil
+}
+
+// RawManifest implements partial.CompressedImageCore.
+func (i *artifactImage) RawManifest() ([]byte, error) {
+	configDigest, configSize, err := v1.SHA256(bytes.NewReader(i.config))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config: v1.Descriptor{
+			MediaType: types.MediaType(i.configMediaType),
+			Size:      configSize,
+			Digest:    configDigest,
+		},
+		Annotations: i.annotations,
+	}
+
+	for _, l := range i.layers {
+		manifest.Layers = append(manifest.Layers, v1.Descriptor{
+			MediaType: l.mediaType,
+			Size:      int64(len(l.data)),
+			Digest:    l.digest,
+		})
+	}
+
+	return json.Marshal(manifest)
+}
+
+// LayerByDigest implements partial.CompressedImageCore.
+func (i *artifactImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	for _, l := range i.layers {
+		if l.digest == h {
+			return l, nil
+		}
+	}
+
+	return nil, fmt.Errorf("layer with digest %s not found", h)
+}
+
+type artifactLayer struct {
+	mediaType types.MediaType
+	data      []byte
+	digest    v1.Hash
+}
+
+func newArtifactLayer(l ArtifactLayer) *artifactLayer {
+	digest, _, _ := v1.SHA256(bytes.NewReader(l.Data))
+
+	return &artifactLayer{
+		mediaType: types.MediaType(l.MediaType),
+		data:      l.Data,
+		digest:    digest,
+	}
+}
+
+func (l *artifactLayer) Digest() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *artifactLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.data)), nil
+}
+
+func (l *artifactLayer) Size() (int64, error) {
+	return int64(len(l.data)), nil
+}
+
+func (l *artifactLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
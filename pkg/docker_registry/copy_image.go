@@ -0,0 +1,56 @@
+package docker_registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func (api *genericApi) CopyImage(ctx context.Context, sourceReference, destinationReference string) (string, error) {
+	return api.commonApi.CopyImage(ctx, sourceReference, destinationReference)
+}
+
+// CopyImage copies the image or the multi-platform image index as is, so that the digest is preserved,
+// and returns the digest of the copied manifest.
+func (api *api) CopyImage(ctx context.Context, sourceReference, destinationReference string) (string, error) {
+	srcRef, err := name.ParseReference(sourceReference, api.parseReferenceOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %v", sourceReference, err)
+	}
+
+	dstRef, err := name.ParseReference(destinationReference, api.parseReferenceOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %v", destinationReference, err)
+	}
+
+	desc, err := remote.Get(srcRef, api.remoteOptions(ctx)...)
+	if err != nil {
+		return "", fmt.Errorf("getting manifest %q: %v", srcRef, err)
+	}
+
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return "", fmt.Errorf("reading image index %q: %v", srcRef, err)
+		}
+
+		if err := remote.WriteIndex(dstRef, idx, api.remoteOptions(ctx)...); err != nil {
+			return "", fmt.Errorf("write to the remote %s have failed: %s", dstRef.String(), err)
+		}
+	default:
+		img, err := desc.Image()
+		if err != nil {
+			return "", fmt.Errorf("reading image %q: %v", srcRef, err)
+		}
+
+		if err := remote.Write(dstRef, img, api.remoteOptions(ctx)...); err != nil {
+			return "", fmt.Errorf("write to the remote %s have failed: %s", dstRef.String(), err)
+		}
+	}
+
+	return desc.Digest.String(), nil
+}
@@ -0,0 +1,47 @@
+package docker_registry
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CopyImage", func() {
+	It("should copy image between registries preserving digest", func() {
+		sourceServer := httptest.NewServer(registry.New())
+		defer sourceServer.Close()
+
+		destinationServer := httptest.NewServer(registry.New())
+		defer destinationServer.Close()
+
+		sourceReference := strings.TrimPrefix(sourceServer.URL, "http://") + "/project:tag"
+		destinationReference := strings.TrimPrefix(destinationServer.URL, "http://") + "/mirror/project:tag"
+
+		img, err := random.Image(1024, 2)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		expectedDigest, err := img.Digest()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		ref, err := name.ParseReference(sourceReference, name.Insecure)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remote.Write(ref, img)).Should(Succeed())
+
+		api := newAPI(apiOptions{InsecureRegistry: true})
+
+		digest, err := api.CopyImage(context.Background(), sourceReference, destinationReference)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(digest).Should(Equal(expectedDigest.String()))
+
+		info, err := api.GetRepoImage(context.Background(), destinationReference)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(info.RepoDigest).Should(HaveSuffix(expectedDigest.String()))
+	})
+})
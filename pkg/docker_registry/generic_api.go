@@ -8,6 +8,8 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 
+	"github.com/werf/logboek"
+
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
 )
@@ -49,11 +51,11 @@ func (api *genericApi) GetRepoImageConfigFile(ctx context.Context, reference str
 	for _, mirrorReference := range mirrorReferenceList {
 		config, err := api.getRepoImageConfigFile(ctx, mirrorReference)
 		if err != nil {
-			if IsBlobUnknownError(err) || IsManifestUnknownError(err) || IsNameUnknownError(err) {
-				continue
+			if !(IsBlobUnknownError(err) || IsManifestUnknownError(err) || IsNameUnknownError(err)) {
+				logboek.Context(ctx).Warn().LogF("WARNING: Unable to get mirror repo image %q config file: %s\n", mirrorReference, err)
 			}
 
-			return nil, err
+			continue
 		}
 
 		return config, nil
@@ -80,7 +82,9 @@ func (api *genericApi) GetRepoImage(ctx context.Context, reference string) (*ima
 	for _, mirrorReference := range mirrorReferenceList {
 		info, err := api.commonApi.TryGetRepoImage(ctx, mirrorReference)
 		if err != nil {
-			return nil, fmt.Errorf("unable to try getting mirror repo image %q: %s", mirrorReference, err)
+			// the unavailable mirror should not break the build while the registry itself is available
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to get mirror repo image %q: %s\n", mirrorReference, err)
+			continue
 		}
 
 		if info != nil {
@@ -94,14 +98,24 @@ func (api *genericApi) GetRepoImage(ctx context.Context, reference string) (*ima
 func (api *genericApi) mirrorReferenceList(reference string) ([]string, error) {
 	var referenceList []string
 
+	// the mirrors configured by the user are preferred over the docker daemon ones
+	registryMirrorReferences, err := GetRegistryMirrorReferences(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, registryMirrorReference := range registryMirrorReferences {
+		referenceList = append(referenceList, registryMirrorReference.Reference)
+	}
+
 	referenceParts, err := api.commonApi.ParseReferenceParts(reference)
 	if err != nil {
 		return nil, err
 	}
 
-	// nothing if container registry is not Docker Hub
+	// the docker daemon mirrors are used only for Docker Hub
 	if referenceParts.registry != name.DefaultRegistry {
-		return nil, nil
+		return referenceList, nil
 	}
 
 	for _, mirrorRegistry := range api.mirrors {
@@ -0,0 +1,243 @@
+package docker_registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// ErrPullTokenNotSupported means that the registry does not issue tokens with the docker registry v2 token authentication.
+var ErrPullTokenNotSupported = errors.New("registry does not support token authentication")
+
+// PullToken is the short-lived token issued by the registry token service for the repository pull scope only.
+// The token is used as the password of the local registry user and should be stored in the cluster secrets only.
+type PullToken struct {
+	Registry  string
+	Username  string
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (api *genericApi) GetPullToken(ctx context.Context, repository string) (*PullToken, error) {
+	return api.commonApi.GetPullToken(ctx, repository)
+}
+
+func (api *genericApi) GetPullTokenDockerConfigJson(ctx context.Context, repository string) ([]byte, *PullToken, error) {
+	return api.commonApi.GetPullTokenDockerConfigJson(ctx, repository)
+}
+
+// GetPullToken exchanges the local registry credentials for the token issued for the repository pull scope only.
+// The token is rejected if the token service grants any other action or repository, so that the push credentials never leave the host.
+func (api *api) GetPullToken(ctx context.Context, repository string) (*PullToken, error) {
+	repo, err := name.NewRepository(repository, api.newRepositoryOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repo %q: %v", repository, err)
+	}
+
+	realm, service, err := api.getTokenServiceChallenge(ctx, repo.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := authn.DefaultKeychain.Resolve(repo.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("getting creds for %q: %v", repo.RegistryStr(), err)
+	}
+
+	authConfig, err := auth.Authorization()
+	if err != nil {
+		return nil, fmt.Errorf("getting creds for %q: %v", repo.RegistryStr(), err)
+	}
+
+	if authConfig.Username == "" || authConfig.Password == "" {
+		return nil, fmt.Errorf("no username and password credentials for %q found in the docker config", repo.RegistryStr())
+	}
+
+	token, expiresIn, err := api.requestToken(ctx, realm, service, repo.Scope(transport.PullScope), authConfig.Username, authConfig.Password)
+	if err != nil {
+		return nil, fmt.Errorf("unable to exchange %q credentials for the pull token: %s", repo.RegistryStr(), err)
+	}
+
+	if err := checkPullOnlyToken(token, repo.RepositoryStr()); err != nil {
+		return nil, err
+	}
+
+	return &PullToken{
+		Registry:  repo.RegistryStr(),
+		Username:  authConfig.Username,
+		Token:     token,
+		ExpiresAt: time.Now().Add(expiresIn),
+	}, nil
+}
+
+// GetPullTokenDockerConfigJson returns the docker config with the pull token of the repository only,
+// which is used as the kubernetes.io/dockerconfigjson secret data instead of the local credentials.
+func (api *api) GetPullTokenDockerConfigJson(ctx context.Context, repository string) ([]byte, *PullToken, error) {
+	pullToken, err := api.GetPullToken(ctx, repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := pullToken.DockerConfigJson()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, pullToken, nil
+}
+
+// DockerConfigJson returns the docker config with the username and the pull token as the password, the only credentials form kubelet supports.
+func (t *PullToken) DockerConfigJson() ([]byte, error) {
+	// docker and kubelet expect the legacy Docker Hub address in the docker config
+	registry := t.Registry
+	if registry == name.DefaultRegistry {
+		registry = "https://index.docker.io/v1/"
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			registry: map[string]interface{}{
+				"username": t.Username,
+				"password": t.Token,
+				"auth":     base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", t.Username, t.Token))),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal docker config: %s", err)
+	}
+
+	return data, nil
+}
+
+// checkPullOnlyToken checks the access claims of the JWT token issued by the docker registry v2 token authentication:
+// the token must grant the pull action of the repository only. Opaque tokens are issued for the requested pull scope.
+func checkPullOnlyToken(token, repository string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+
+	var claims struct {
+		Access []struct {
+			Type    string   `json:"type"`
+			Name    string   `json:"name"`
+			Actions []string `json:"actions"`
+		} `json:"access"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+
+	for _, access := range claims.Access {
+		if access.Type != "repository" || access.Name != repository {
+			return fmt.Errorf("the pull token grants access to %s %q, only the repository %q is allowed", access.Type, access.Name, repository)
+		}
+
+		for _, action := range access.Actions {
+			if action != transport.PullScope {
+				return fmt.Errorf("the pull token grants %q action for the repository %q, only %q is allowed", action, repository, transport.PullScope)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (api *api) getTokenServiceChallenge(ctx context.Context, registry name.Registry) (string, string, error) {
+	u := url.URL{Scheme: registry.Scheme(), Host: registry.RegistryStr(), Path: "/v2/"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	resp, err := api.getHttpTransport().RoundTrip(req)
+	if err != nil {
+		return "", "", fmt.Errorf("error requesting url %q: %s", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	for _, c := range challenge.ResponseChallenges(resp) {
+		if c.Scheme == "bearer" && c.Parameters["realm"] != "" {
+			return c.Parameters["realm"], c.Parameters["service"], nil
+		}
+	}
+
+	return "", "", ErrPullTokenNotSupported
+}
+
+func (api *api) requestToken(ctx context.Context, realm, service, scope, username, password string) (string, time.Duration, error) {
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to parse token service realm %q: %s", realm, err)
+	}
+
+	query := u.Query()
+	query.Set("scope", scope)
+	if service != "" {
+		query.Set("service", service)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.SetBasicAuth(username, password)
+
+	resp, err := api.getHttpTransport().RoundTrip(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("error requesting url %q: %s", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading response of %q request: %s", u.String(), err)
+	} else if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("got bad response %s by url %q request", resp.Status, u.String())
+	}
+
+	var tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", 0, fmt.Errorf("unable to unmarshal json body by url %q request: %s", u.String(), err)
+	}
+
+	token := tokenResponse.Token
+	if token == "" {
+		token = tokenResponse.AccessToken
+	}
+	if token == "" {
+		return "", 0, fmt.Errorf("no token in the response by url %q request", u.String())
+	}
+
+	// the default token lifetime by the docker registry v2 token authentication specification
+	expiresIn := 60 * time.Second
+	if tokenResponse.ExpiresIn > 0 {
+		expiresIn = time.Duration(tokenResponse.ExpiresIn) * time.Second
+	}
+
+	return token, expiresIn, nil
+}
@@ -0,0 +1,131 @@
+package docker_registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cliconfig "github.com/docker/cli/cli/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func newTestJWT(access string) string {
+	encode := func(data string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(data))
+	}
+
+	return strings.Join([]string{encode(`{"alg":"none"}`), encode(fmt.Sprintf(`{"access":%s}`, access)), encode("signature")}, ".")
+}
+
+var _ = Describe("GetPullToken", func() {
+	var server *httptest.Server
+	var issuedToken string
+	var tokenRequests []*http.Request
+	var tmpDir, prevDockerConfigDir string
+
+	BeforeEach(func() {
+		tokenRequests = nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/":
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test-registry"`, r.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+			case "/token":
+				tokenRequests = append(tokenRequests, r)
+				_, _ = fmt.Fprintf(w, `{"token":%q,"expires_in":300}`, issuedToken)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-pull-token-test-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		registry := strings.TrimPrefix(server.URL, "http://")
+		dockerConfig := fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, registry, base64.StdEncoding.EncodeToString([]byte("user:push-password")))
+		Ω(ioutil.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(dockerConfig), 0o600)).Should(Succeed())
+
+		prevDockerConfigDir = cliconfig.Dir()
+		cliconfig.SetDir(tmpDir)
+	})
+
+	AfterEach(func() {
+		cliconfig.SetDir(prevDockerConfigDir)
+		server.Close()
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	It("should request the pull scope only and save the token into the docker config", func() {
+		issuedToken = newTestJWT(`[{"type":"repository","name":"group/project","actions":["pull"]}]`)
+		repository := strings.TrimPrefix(server.URL, "http://") + "/group/project"
+
+		data, pullToken, err := newAPI(apiOptions{}).GetPullTokenDockerConfigJson(context.Background(), repository)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(pullToken.Token).Should(Equal(issuedToken))
+
+		Ω(tokenRequests).Should(HaveLen(1))
+		Ω(tokenRequests[0].URL.Query().Get("scope")).Should(Equal("repository:group/project:pull"))
+		Ω(tokenRequests[0].URL.Query().Get("service")).Should(Equal("test-registry"))
+
+		var dockerConfig struct {
+			Auths map[string]struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+			} `json:"auths"`
+		}
+		Ω(json.Unmarshal(data, &dockerConfig)).Should(Succeed())
+		Ω(dockerConfig.Auths).Should(HaveLen(1))
+		Ω(dockerConfig.Auths[pullToken.Registry].Username).Should(Equal("user"))
+		Ω(dockerConfig.Auths[pullToken.Registry].Password).Should(Equal(issuedToken))
+		Ω(string(data)).ShouldNot(ContainSubstring("push-password"))
+	})
+
+	It("should reject the token granting the push access", func() {
+		issuedToken = newTestJWT(`[{"type":"repository","name":"group/project","actions":["pull","push"]}]`)
+		repository := strings.TrimPrefix(server.URL, "http://") + "/group/project"
+
+		_, err := newAPI(apiOptions{}).GetPullToken(context.Background(), repository)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(`grants "push" action`))
+	})
+
+	It("should return ErrPullTokenNotSupported if the registry does not use the token authentication", func() {
+		basicServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer basicServer.Close()
+
+		_, err := newAPI(apiOptions{}).GetPullToken(context.Background(), strings.TrimPrefix(basicServer.URL, "http://")+"/project")
+		Ω(err).Should(Equal(ErrPullTokenNotSupported))
+	})
+})
+
+var _ = DescribeTable("checkPullOnlyToken",
+	func(token string, expectedErr bool) {
+		err := checkPullOnlyToken(token, "group/project")
+		if expectedErr {
+			Ω(err).Should(HaveOccurred())
+		} else {
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+	},
+	Entry("opaque token", "opaque-token", false),
+	Entry("pull only", newTestJWT(`[{"type":"repository","name":"group/project","actions":["pull"]}]`), false),
+	Entry("no access claims", newTestJWT(`[]`), false),
+	Entry("push action", newTestJWT(`[{"type":"repository","name":"group/project","actions":["pull","push"]}]`), true),
+	Entry("wildcard action", newTestJWT(`[{"type":"repository","name":"group/project","actions":["*"]}]`), true),
+	Entry("other repository", newTestJWT(`[{"type":"repository","name":"group/other","actions":["pull"]}]`), true),
+	Entry("registry catalog", newTestJWT(`[{"type":"registry","name":"catalog","actions":["*"]}]`), true),
+)
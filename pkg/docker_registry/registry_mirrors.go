@@ -0,0 +1,143 @@
+package docker_registry
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// RegistryMirror is the mirror or the pull-through proxy of the registry, which is tried before the registry itself when pulling images.
+type RegistryMirror struct {
+	// Registry is the mirrored registry address, Docker Hub by default
+	Registry string
+	// Mirror is the mirror registry address with the optional repository prefix (e.g. nexus.example.com/dockerhub-proxy), the credentials are taken from the docker config
+	Mirror string
+}
+
+// RegistryMirrorReference is the reference of the image in the registry mirror.
+type RegistryMirrorReference struct {
+	Reference string
+	Mirror    *RegistryMirror
+}
+
+var (
+	registryMirrors    []*RegistryMirror
+	registryMirrorsMux sync.RWMutex
+)
+
+// ParseRegistryMirror parses the mirror specification in the format [REGISTRY=]MIRROR.
+func ParseRegistryMirror(spec string) (*RegistryMirror, error) {
+	mirror := &RegistryMirror{}
+
+	if parts := strings.SplitN(spec, "=", 2); len(parts) == 2 {
+		mirror.Registry = parts[0]
+		spec = parts[1]
+	}
+
+	// the credentials passed by the options or the environment leak into the process list and the logs
+	if strings.Contains(spec, "@") {
+		return nil, fmt.Errorf("registry mirror credentials cannot be specified in the mirror address: use docker login or the docker config instead")
+	}
+
+	mirror.Mirror = spec
+
+	if err := ValidateRegistryMirror(mirror); err != nil {
+		return nil, err
+	}
+
+	return mirror, nil
+}
+
+func ValidateRegistryMirror(mirror *RegistryMirror) error {
+	if mirror.Mirror == "" {
+		return fmt.Errorf("registry mirror address cannot be empty")
+	}
+
+	if _, err := name.NewRepository(normalizeRegistryMirrorAddress(mirror.Mirror) + "/image"); err != nil {
+		return fmt.Errorf("bad registry mirror address %q: %s", mirror.Mirror, err)
+	}
+
+	if mirror.Registry != "" {
+		if _, err := name.NewRegistry(mirror.Registry); err != nil {
+			return fmt.Errorf("bad mirrored registry %q: %s", mirror.Registry, err)
+		}
+	}
+
+	return nil
+}
+
+// SetRegistryMirrors sets the registry mirrors used by the API and by the image pulls, the mirrors are tried in the specified order.
+func SetRegistryMirrors(mirrors []*RegistryMirror) {
+	registryMirrorsMux.Lock()
+	defer registryMirrorsMux.Unlock()
+
+	registryMirrors = mirrors
+}
+
+// GetRegistryMirrorReferences returns the references of the image in the registry mirrors configured for its registry.
+func GetRegistryMirrorReferences(reference string) ([]*RegistryMirrorReference, error) {
+	parsedReference, err := name.ParseReference(reference)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse reference %q: %s", reference, err)
+	}
+
+	registryMirrorsMux.RLock()
+	defer registryMirrorsMux.RUnlock()
+
+	var res []*RegistryMirrorReference
+	for _, mirror := range registryMirrors {
+		if normalizeRegistry(mirror.Registry) != parsedReference.Context().RegistryStr() {
+			continue
+		}
+
+		mirrorReference := normalizeRegistryMirrorAddress(mirror.Mirror) + "/" + parsedReference.Context().RepositoryStr()
+		switch ref := parsedReference.(type) {
+		case name.Tag:
+			mirrorReference += ":" + ref.TagStr()
+		case name.Digest:
+			mirrorReference += "@" + ref.DigestStr()
+		}
+
+		res = append(res, &RegistryMirrorReference{Reference: mirrorReference, Mirror: mirror})
+	}
+
+	return res, nil
+}
+
+func normalizeRegistry(registry string) string {
+	switch registry {
+	case "", "docker.io", "registry-1.docker.io":
+		return name.DefaultRegistry
+	default:
+		return registry
+	}
+}
+
+func normalizeRegistryMirrorAddress(address string) string {
+	address = strings.TrimPrefix(address, "https://")
+	address = strings.TrimPrefix(address, "http://")
+	return strings.TrimSuffix(address, "/")
+}
+
+// IsRepoDigestMatched returns true if the repo digests of the local image contain the specified digest reference.
+func IsRepoDigestMatched(repoDigests []string, reference string) (bool, error) {
+	digestReference, err := name.NewDigest(reference)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse digest reference %q: %s", reference, err)
+	}
+
+	for _, repoDigest := range repoDigests {
+		parsedRepoDigest, err := name.NewDigest(repoDigest)
+		if err != nil {
+			continue
+		}
+
+		if parsedRepoDigest.Context().Name() == digestReference.Context().Name() && parsedRepoDigest.DigestStr() == digestReference.DigestStr() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
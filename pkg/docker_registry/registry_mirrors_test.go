@@ -0,0 +1,73 @@
+package docker_registry
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("ParseRegistryMirror", func(spec string, expectation *RegistryMirror) {
+	mirror, err := ParseRegistryMirror(spec)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(mirror).Should(Equal(expectation))
+},
+	Entry("mirror", "mirror.gcr.io", &RegistryMirror{Mirror: "mirror.gcr.io"}),
+	Entry("registry and mirror", "quay.io=nexus.example.com/quay-proxy", &RegistryMirror{Registry: "quay.io", Mirror: "nexus.example.com/quay-proxy"}),
+	Entry("registry and mirror with scheme", "quay.io=https://nexus.example.com/", &RegistryMirror{Registry: "quay.io", Mirror: "https://nexus.example.com/"}),
+)
+
+var _ = Describe("ParseRegistryMirror", func() {
+	It("should not accept the credentials", func() {
+		_, err := ParseRegistryMirror("quay.io=user:pass@nexus.example.com")
+		Ω(err).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("GetRegistryMirrorReferences", func() {
+	BeforeEach(func() {
+		SetRegistryMirrors([]*RegistryMirror{
+			{Mirror: "mirror.gcr.io"},
+			{Registry: "quay.io", Mirror: "https://nexus.example.com/quay-proxy/"},
+			{Registry: "docker.io", Mirror: "nexus.example.com/dockerhub-proxy"},
+		})
+	})
+
+	AfterEach(func() {
+		SetRegistryMirrors(nil)
+	})
+
+	It("should return the mirror references of Docker Hub image in the specified order", func() {
+		refs, err := GetRegistryMirrorReferences("alpine:3.12")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(refs).Should(HaveLen(2))
+		Ω(refs[0].Reference).Should(Equal("mirror.gcr.io/library/alpine:3.12"))
+		Ω(refs[1].Reference).Should(Equal("nexus.example.com/dockerhub-proxy/library/alpine:3.12"))
+	})
+
+	It("should return the mirror references of the other registry image", func() {
+		refs, err := GetRegistryMirrorReferences("quay.io/prometheus/node-exporter@sha256:0000000000000000000000000000000000000000000000000000000000000000")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(refs).Should(HaveLen(1))
+		Ω(refs[0].Reference).Should(Equal("nexus.example.com/quay-proxy/prometheus/node-exporter@sha256:0000000000000000000000000000000000000000000000000000000000000000"))
+	})
+
+	It("should not mirror the registry without mirrors", func() {
+		refs, err := GetRegistryMirrorReferences("registry.example.com/app:latest")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(refs).Should(BeEmpty())
+	})
+})
+
+const testDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+var _ = DescribeTable("IsRepoDigestMatched", func(repoDigests []string, reference string, expectation bool) {
+	isMatched, err := IsRepoDigestMatched(repoDigests, reference)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(isMatched).Should(Equal(expectation))
+},
+	Entry("same repo digest", []string{"mirror.gcr.io/library/alpine@" + testDigest}, "mirror.gcr.io/library/alpine@"+testDigest, true),
+	Entry("normalized repo digest", []string{"alpine@" + testDigest}, "index.docker.io/library/alpine@"+testDigest, true),
+	Entry("other repo", []string{"registry.example.com/alpine@" + testDigest}, "mirror.gcr.io/library/alpine@"+testDigest, false),
+	Entry("other digest", []string{"mirror.gcr.io/library/alpine@sha256:1111111111111111111111111111111111111111111111111111111111111111"}, "mirror.gcr.io/library/alpine@"+testDigest, false),
+	Entry("no repo digests", nil, "mirror.gcr.io/library/alpine@"+testDigest, false),
+)
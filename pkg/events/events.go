@@ -0,0 +1,163 @@
+// Package events writes the machine-readable stream of werf events in the newline-delimited JSON format,
+// so that wrapper UIs can show the live progress without parsing the human-oriented logs.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fdPathPrefix = "fd://"
+
+const (
+	ImageBuildStarted  = "image_build_started"
+	ImageBuildFinished = "image_build_finished"
+	StageStarted       = "stage_started"
+	StageFinished      = "stage_finished"
+	StagePushed        = "stage_pushed"
+	ImagePushed        = "image_pushed"
+
+	ResourceApplied         = "resource_applied"
+	ResourceTrackingState   = "resource_tracking_state"
+	ResourcesTrackingFailed = "resources_tracking_failed"
+
+	TrackingStateTracking = "tracking"
+	TrackingStateReady    = "ready"
+)
+
+type Event struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	Image           string  `json:"image,omitempty"`
+	Stage           string  `json:"stage,omitempty"`
+	Digest          string  `json:"digest,omitempty"`
+	DockerImageName string  `json:"dockerImageName,omitempty"`
+	Repo            string  `json:"repo,omitempty"`
+	CacheOutcome    string  `json:"cacheOutcome,omitempty"`
+	Storage         string  `json:"storage,omitempty"`
+	Duration        float64 `json:"duration,omitempty"`
+
+	Resource *Resource `json:"resource,omitempty"`
+	State    string    `json:"state,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type Resource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+var (
+	mux     sync.Mutex
+	writer  io.WriteCloser
+	encoder *json.Encoder
+
+	subscribers      = map[int]func(Event){}
+	lastSubscriberID int
+)
+
+// Init opens the events stream: a file path or a file descriptor in the fd://N format.
+// Events are discarded when the stream is not initialized.
+func Init(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if strings.HasPrefix(path, fdPathPrefix) {
+		fd, err := strconv.Atoi(strings.TrimPrefix(path, fdPathPrefix))
+		if err != nil || fd < 0 {
+			return fmt.Errorf("bad events path %q: file descriptor number expected", path)
+		}
+
+		writer = os.NewFile(uintptr(fd), path)
+	} else {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return fmt.Errorf("unable to open events file %s: %s", path, err)
+		}
+
+		writer = f
+	}
+
+	encoder = json.NewEncoder(writer)
+
+	return nil
+}
+
+func Close() error {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if writer == nil {
+		return nil
+	}
+
+	err := writer.Close()
+	writer, encoder = nil, nil
+
+	return err
+}
+
+func IsEnabled() bool {
+	mux.Lock()
+	defer mux.Unlock()
+
+	return encoder != nil || len(subscribers) > 0
+}
+
+// Subscribe passes the emitted events to the handler in addition to the stream until the returned unsubscribe function is called.
+// The handler is called synchronously by Emit and should not emit the events itself.
+func Subscribe(handler func(Event)) func() {
+	mux.Lock()
+	defer mux.Unlock()
+
+	lastSubscriberID++
+	id := lastSubscriberID
+	subscribers[id] = handler
+
+	return func() {
+		mux.Lock()
+		defer mux.Unlock()
+
+		delete(subscribers, id)
+	}
+}
+
+// Emit writes the event into the stream and passes it to the subscribers, the stream is disabled on the first write error to not break the command.
+func Emit(e Event) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if encoder == nil && len(subscribers) == 0 {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	for _, handler := range subscribers {
+		handler(e)
+	}
+
+	if encoder == nil {
+		return
+	}
+
+	if err := encoder.Encode(e); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "WARNING: unable to write werf event, events stream is disabled: %s\n", err)
+		_ = writer.Close()
+		writer, encoder = nil, nil
+	}
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	Emit(Event{Type: StageStarted, Image: "discarded"})
+
+	if err := Init(path); err != nil {
+		t.Fatal(err)
+	}
+
+	Emit(Event{Type: StageStarted, Image: "app", Stage: "install"})
+	Emit(Event{Type: ResourceApplied, Resource: &Resource{Kind: "Deployment", Name: "app"}})
+
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	Emit(Event{Type: StageFinished, Image: "discarded"})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad event line %q: %s", scanner.Text(), err)
+		}
+		got = append(got, e)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %d: %+v", len(got), got)
+	}
+
+	if got[0].Type != StageStarted || got[0].Image != "app" || got[0].Stage != "install" || got[0].Time.IsZero() {
+		t.Errorf("unexpected first event: %+v", got[0])
+	}
+
+	if got[1].Type != ResourceApplied || got[1].Resource == nil || got[1].Resource.Kind != "Deployment" {
+		t.Errorf("unexpected second event: %+v", got[1])
+	}
+}
+
+func TestInitBadFd(t *testing.T) {
+	if err := Init("fd://x"); err == nil {
+		t.Fatal("expected error for bad file descriptor")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	var got []Event
+	unsubscribe := Subscribe(func(e Event) {
+		got = append(got, e)
+	})
+
+	if !IsEnabled() {
+		t.Fatal("expected events to be enabled with the subscriber")
+	}
+
+	Emit(Event{Type: StageStarted, Image: "app", Stage: "install"})
+	unsubscribe()
+	Emit(Event{Type: StageFinished, Image: "discarded"})
+
+	if IsEnabled() {
+		t.Fatal("expected events to be disabled without the subscribers and the stream")
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d: %+v", len(got), got)
+	}
+
+	if got[0].Type != StageStarted || got[0].Image != "app" || got[0].Time.IsZero() {
+		t.Errorf("unexpected event: %+v", got[0])
+	}
+}
@@ -0,0 +1,123 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/logging"
+	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/tmp_manager"
+)
+
+// DefaultBuildParallelTasksLimit is the default limit of the images built in parallel.
+const DefaultBuildParallelTasksLimit = 5
+
+type BuildOptions struct {
+	// ImageNames limits the images to build, all images from werf.yaml are built by default
+	ImageNames []string
+
+	ContainerRuntime container_runtime.ContainerRuntime
+	Storage          StorageOptions
+
+	ConveyorOptions build.ConveyorOptions
+	BuildOptions    build.BuildOptions
+	// CacheFromImages are imported into the stages storage before the build
+	CacheFromImages []string
+	// OutputOptions saves the built images outside the repo
+	OutputOptions *build.OutputOptions
+
+	// SkipBuild only checks that the images are built
+	SkipBuild bool
+	// CalculateDigestsOnly only calculates the digests of the stages and writes the digests report in json into the DigestsOutput
+	CalculateDigestsOnly bool
+	DigestsOutput        io.Writer
+}
+
+// Build builds the images of the project and returns the info getters of the built images.
+func Build(ctx context.Context, giterminismManager giterminism_manager.Interface, werfConfig *config.WerfConfig, opts BuildOptions) ([]*image.InfoGetter, error) {
+	projectName := werfConfig.Meta.Project
+
+	for _, imageName := range opts.ImageNames {
+		if !werfConfig.HasImageOrArtifact(imageName) {
+			return nil, fmt.Errorf("specified image %s is not found in werf.yaml", logging.ImageLogName(imageName, false))
+		}
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	storageManager, storageLockManager, err := NewStorageManager(ctx, projectName, opts.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.CacheFromImages) != 0 && !opts.CalculateDigestsOnly {
+		if err := logboek.Context(ctx).Default().LogProcess("Warming up build cache").DoError(func() error {
+			for _, reference := range opts.CacheFromImages {
+				if err := storageManager.ImportStageFromImage(ctx, opts.ContainerRuntime, reference); err != nil {
+					logboek.Context(ctx).Warn().LogF("WARNING: Unable to import stage from %s: %s\n", reference, err)
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	logboek.LogOptionalLn()
+
+	conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, opts.ImageNames, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, opts.ContainerRuntime, storageManager, storageLockManager, opts.ConveyorOptions)
+	defer conveyorWithRetry.Terminate()
+
+	var imagesInfoGetters []*image.InfoGetter
+	if err := conveyorWithRetry.WithRetryBlock(ctx, func(conveyor *build.Conveyor) error {
+		switch {
+		case opts.CalculateDigestsOnly:
+			report, err := conveyor.CalculateDigests(ctx)
+			if err != nil {
+				return err
+			}
+
+			data, err := report.ToJsonData()
+			if err != nil {
+				return err
+			}
+
+			_, err = opts.DigestsOutput.Write(data)
+			return err
+		case opts.SkipBuild:
+			if err := conveyor.ShouldBeBuilt(ctx); err != nil {
+				return err
+			}
+		default:
+			if err := conveyor.Build(ctx, opts.BuildOptions); err != nil {
+				return err
+			}
+
+			if opts.OutputOptions != nil {
+				if err := conveyor.OutputImages(ctx, *opts.OutputOptions); err != nil {
+					return err
+				}
+			}
+		}
+
+		imagesInfoGetters = conveyor.GetImageInfoGetters()
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return imagesInfoGetters, nil
+}
@@ -0,0 +1,100 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/cleaning"
+	"github.com/werf/werf/pkg/cleaning/allow_list"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+// DefaultCleanupParallelTasksLimit is the default limit of the images deleted in parallel.
+const DefaultCleanupParallelTasksLimit = 10
+
+type CleanupOptions struct {
+	Storage StorageOptions
+
+	// Parallel deletes the images in parallel with the ParallelTasksLimit, -1 removes the limitation
+	Parallel            bool
+	ParallelTasksLimit  int
+	ParallelTaskTimeout time.Duration
+
+	// KubernetesContextClients are scanned for the deployed images unless WithoutKube is set
+	KubernetesContextClients []*kube.ContextClient
+	// KubernetesNamespaceRestrictionByContext limits the scanned namespace of the kube context, all namespaces are scanned by default
+	KubernetesNamespaceRestrictionByContext map[string]string
+	WithoutKube                             bool
+
+	KeepStagesBuiltWithinLastNHours uint64
+	KeepImagesSeenWithinLastNHours  uint64
+	MergeRequestsAPI                *allow_list.MergeRequestsAPI
+
+	DryRun bool
+}
+
+// Cleanup deletes the unused images of the project from the repo according to the cleanup policies.
+func Cleanup(ctx context.Context, giterminismManager giterminism_manager.Interface, werfConfig *config.WerfConfig, opts CleanupOptions) error {
+	if !werfConfig.Meta.GitWorktree.GetForceShallowClone() && !werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
+		isShallow, err := giterminismManager.LocalGitRepo().IsShallowClone()
+		if err != nil {
+			return fmt.Errorf("check shallow clone failed: %s", err)
+		}
+
+		if isShallow {
+			logboek.Warn().LogLn("Git shallow clone should not be used with images cleanup commands due to incompleteness of the repository history that is extremely essential for proper work.")
+			logboek.Warn().LogLn("It is recommended to enable automatic fetch of origin git branches and tags during cleanup process with the gitWorktree.allowFetchOriginBranchesAndTags=true werf.yaml directive (which is enabled by default, http://werf.io/documentation/reference/werf_yaml.html#git-worktree).")
+			logboek.Warn().LogLn("If you still want to use shallow clone, add gitWorktree.forceShallowClone=true directive into werf.yaml (http://werf.io/documentation/reference/werf_yaml.html#git-worktree).")
+
+			return fmt.Errorf("git shallow clone is not allowed")
+		}
+	}
+
+	if werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
+		if err := giterminismManager.LocalGitRepo().SyncWithOrigin(ctx); err != nil {
+			return fmt.Errorf("synchronization failed: %s", err)
+		}
+	}
+
+	projectName := werfConfig.Meta.Project
+
+	storageManager, storageLockManager, err := NewStorageManager(ctx, projectName, opts.Storage)
+	if err != nil {
+		return err
+	}
+
+	if opts.Parallel {
+		storageManager.EnableParallel(opts.ParallelTasksLimit)
+	}
+	storageManager.SetParallelTaskTimeout(opts.ParallelTaskTimeout)
+
+	imagesNames, err := GetManagedImagesNames(ctx, projectName, opts.Storage.StagesStorage, werfConfig)
+	if err != nil {
+		return err
+	}
+	logboek.Debug().LogF("Managed images names: %v\n", imagesNames)
+
+	metaCleanup, err := cleaning.MergeCleanupPolicySets(ctx, werfConfig.Meta.Cleanup)
+	if err != nil {
+		return err
+	}
+
+	logboek.LogOptionalLn()
+	return cleaning.Cleanup(ctx, projectName, storageManager, storageLockManager, cleaning.CleanupOptions{
+		ImageNameList:                           imagesNames,
+		LocalGit:                                giterminismManager.LocalGitRepo(),
+		KubernetesContextClients:                opts.KubernetesContextClients,
+		KubernetesNamespaceRestrictionByContext: opts.KubernetesNamespaceRestrictionByContext,
+		WithoutKube:                             opts.WithoutKube,
+		GitHistoryBasedCleanupOptions:           metaCleanup,
+		KeepStagesBuiltWithinLastNHours:         opts.KeepStagesBuiltWithinLastNHours,
+		KeepImagesSeenWithinLastNHours:          opts.KeepImagesSeenWithinLastNHours,
+		MergeRequestsAPI:                        opts.MergeRequestsAPI,
+		DryRun:                                  opts.DryRun,
+	})
+}
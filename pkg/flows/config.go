@@ -0,0 +1,92 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/util"
+)
+
+type WerfConfigOptions struct {
+	// ConfigPath is the custom werf config path, werf.yaml or werf.yml in the project dir is used by default
+	ConfigPath string
+	// ConfigTemplatesDir is the custom werf config templates dir, .werf in the project dir is used by default
+	ConfigTemplatesDir string
+
+	config.WerfConfigOptions
+}
+
+func GetRequiredWerfConfig(ctx context.Context, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) (string, *config.WerfConfig, error) {
+	customWerfConfigRelPath, err := GetCustomWerfConfigRelPath(giterminismManager, opts.ConfigPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	customWerfConfigTemplatesDirRelPath, err := GetCustomWerfConfigTemplatesDirRelPath(giterminismManager, opts.ConfigTemplatesDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return config.GetWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.WerfConfigOptions)
+}
+
+// GetOptionalWerfConfig returns nil config if the werf config does not exist.
+func GetOptionalWerfConfig(ctx context.Context, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) (string, *config.WerfConfig, error) {
+	customWerfConfigRelPath, err := GetCustomWerfConfigRelPath(giterminismManager, opts.ConfigPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if exist, err := giterminismManager.FileReader().IsConfigExistAnywhere(ctx, customWerfConfigRelPath); err != nil {
+		return "", nil, err
+	} else if !exist {
+		return "", nil, nil
+	}
+
+	return GetRequiredWerfConfig(ctx, giterminismManager, opts)
+}
+
+func GetCustomWerfConfigRelPath(giterminismManager giterminism_manager.Interface, customConfigPath string) (string, error) {
+	if customConfigPath == "" {
+		return "", nil
+	}
+
+	customConfigPath = util.GetAbsoluteFilepath(customConfigPath)
+	if !util.IsSubpathOfBasePath(giterminismManager.LocalGitRepo().WorkTreeDir, customConfigPath) {
+		return "", fmt.Errorf("the werf config %q must be in the project git work tree %q", customConfigPath, giterminismManager.LocalGitRepo().WorkTreeDir)
+	}
+
+	return util.GetRelativeToBaseFilepath(giterminismManager.ProjectDir(), customConfigPath), nil
+}
+
+func GetCustomWerfConfigTemplatesDirRelPath(giterminismManager giterminism_manager.Interface, customConfigTemplatesDirPath string) (string, error) {
+	if customConfigTemplatesDirPath == "" {
+		return "", nil
+	}
+
+	customConfigTemplatesDirPath = util.GetAbsoluteFilepath(customConfigTemplatesDirPath)
+	if !util.IsSubpathOfBasePath(giterminismManager.LocalGitRepo().WorkTreeDir, customConfigTemplatesDirPath) {
+		return "", fmt.Errorf("the werf configuration templates directory %q must be in the project git work tree %q", customConfigTemplatesDirPath, giterminismManager.LocalGitRepo().WorkTreeDir)
+	}
+
+	return util.GetRelativeToBaseFilepath(giterminismManager.ProjectDir(), customConfigTemplatesDirPath), nil
+}
+
+func GetHelmChartDir(werfConfigPath string, werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface) (string, error) {
+	var helmChartDir string
+	if werfConfig.Meta.Deploy.HelmChartDir != nil && *werfConfig.Meta.Deploy.HelmChartDir != "" {
+		helmChartDir = *werfConfig.Meta.Deploy.HelmChartDir
+	} else {
+		helmChartDir = filepath.Join(filepath.Dir(werfConfigPath), ".helm")
+	}
+
+	absHelmChartDir := filepath.Join(giterminismManager.ProjectDir(), helmChartDir)
+	if !util.IsSubpathOfBasePath(giterminismManager.LocalGitRepo().WorkTreeDir, absHelmChartDir) {
+		return "", fmt.Errorf("the chart directory %s must be in the project git work tree %s", absHelmChartDir, giterminismManager.LocalGitRepo().WorkTreeDir)
+	}
+
+	return helmChartDir, nil
+}
@@ -0,0 +1,141 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+)
+
+type DependenciesOptions struct {
+	// Repo is the repo of the dependencies without the repo directive
+	Repo string
+	// NewStagesStorage creates the stages storage of the dependency repo
+	NewStagesStorage func(repo string) (storage.StagesStorage, error)
+}
+
+// NewDependenciesResolver returns the resolver of the meta dependencies for the config.WerfConfigOptions.
+func NewDependenciesResolver(giterminismManager giterminism_manager.Interface, opts DependenciesOptions) func(ctx context.Context, dependencies []config.MetaDependency) (map[string]config.DependencyTemplateData, error) {
+	return func(ctx context.Context, dependencies []config.MetaDependency) (map[string]config.DependencyTemplateData, error) {
+		return ResolveDependencies(ctx, giterminismManager, dependencies, opts)
+	}
+}
+
+// ResolveDependencies selects the latest stages of the images of the other werf projects declared in the meta.dependencies directive.
+func ResolveDependencies(ctx context.Context, giterminismManager giterminism_manager.Interface, dependencies []config.MetaDependency, opts DependenciesOptions) (map[string]config.DependencyTemplateData, error) {
+	for _, dep := range dependencies {
+		if dep.Commit == "" {
+			if err := giterminismManager.Inspector().InspectConfigDependencyLatest(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res := map[string]config.DependencyTemplateData{}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Resolving dependencies").DoError(func() error {
+		for _, dep := range dependencies {
+			data, err := resolveDependency(ctx, dep, opts)
+			if err != nil {
+				return fmt.Errorf("unable to resolve dependency %q: %s", dep.As, err)
+			}
+
+			logboek.Context(ctx).Default().LogFDetails("%s: %s\n", dep.As, data.Image)
+			res[dep.As] = data
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func resolveDependency(ctx context.Context, dep config.MetaDependency, opts DependenciesOptions) (config.DependencyTemplateData, error) {
+	repo := dep.Repo
+	if repo == "" {
+		if opts.Repo == "" || opts.Repo == storage.LocalStorageAddress {
+			return config.DependencyTemplateData{}, fmt.Errorf("repo of the dependency is not specified: --repo=ADDRESS param required")
+		}
+		repo = opts.Repo
+	}
+
+	stagesStorage, err := opts.NewStagesStorage(repo)
+	if err != nil {
+		return config.DependencyTemplateData{}, err
+	}
+
+	stageID, err := getDependencyLatestStageID(ctx, stagesStorage, dep)
+	if err != nil {
+		return config.DependencyTemplateData{}, err
+	}
+
+	stageDesc, err := stagesStorage.GetStageDescription(ctx, dep.Project, stageID.Digest, stageID.UniqueID)
+	if err != nil {
+		return config.DependencyTemplateData{}, fmt.Errorf("unable to get stage %s description: %s", stageID.String(), err)
+	} else if stageDesc == nil {
+		return config.DependencyTemplateData{}, fmt.Errorf("stage %s is not found in the repo %s", stageID.String(), repo)
+	}
+
+	digest := stageDesc.Info.RepoDigest
+	if parts := strings.SplitN(digest, "@", 2); len(parts) == 2 {
+		digest = parts[1]
+	}
+
+	return config.DependencyTemplateData{
+		Image:  stageDesc.Info.Name,
+		Repo:   stageDesc.Info.Repository,
+		Tag:    stageDesc.Info.Tag,
+		Digest: digest,
+	}, nil
+}
+
+func getDependencyLatestStageID(ctx context.Context, stagesStorage storage.StagesStorage, dep config.MetaDependency) (*image.StageID, error) {
+	imageMetadataByImageName, _, err := stagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, dep.Project, []string{dep.Image})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get images metadata of the project %q: %s", dep.Project, err)
+	}
+
+	var stageIDList []string
+	for stageID, commitList := range imageMetadataByImageName[dep.Image] {
+		if dep.Commit != "" && !util.IsStringsContainValue(commitList, dep.Commit) {
+			continue
+		}
+
+		stageIDList = append(stageIDList, stageID)
+	}
+
+	var latestStageID *image.StageID
+	for _, stageIDStr := range stageIDList {
+		parts := strings.SplitN(stageIDStr, "-", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		uniqueID, err := image.ParseUniqueIDAsTimestamp(parts[1])
+		if err != nil {
+			continue
+		}
+
+		if latestStageID == nil || uniqueID > latestStageID.UniqueID {
+			latestStageID = &image.StageID{Digest: parts[0], UniqueID: uniqueID}
+		}
+	}
+
+	if latestStageID == nil {
+		if dep.Commit != "" {
+			return nil, fmt.Errorf("image %q of the project %q built for the commit %s is not found", dep.Image, dep.Project, dep.Commit)
+		}
+		return nil, fmt.Errorf("image %q of the project %q is not found", dep.Image, dep.Project)
+	}
+
+	return latestStageID, nil
+}
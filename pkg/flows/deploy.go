@@ -0,0 +1,450 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/postrender"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/deploy/bootstrap"
+	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
+	"github.com/werf/werf/pkg/deploy/helm/command_helpers"
+	"github.com/werf/werf/pkg/deploy/helm/maintenance_helper"
+	"github.com/werf/werf/pkg/deploy/lock_manager"
+	"github.com/werf/werf/pkg/deploy/secrets_manager"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+type DeployOptions struct {
+	// ChartDir is the chart dir relative to the project dir
+	ChartDir    string
+	ReleaseName string
+	Env         string
+
+	ImagesRepository  string
+	ImagesInfoGetters []*image.InfoGetter
+	Dependencies      map[string]config.DependencyTemplateData
+
+	SecretsManager   *secrets_manager.SecretsManager
+	SecretValueFiles []string
+	// ExtraAnnotations and ExtraLabels are set on all resources of the release
+	ExtraAnnotations map[string]string
+	ExtraLabels      map[string]string
+	// CommandLineValues take precedence over the values of the ValuesOptions
+	CommandLineValues map[string]interface{}
+	ValuesOptions     values.Options
+
+	// ImagePullSecret is synced from the docker config and added into the imagePullSecrets of the workloads
+	ImagePullSecret string
+	// DockerConfigJsonPullTokens adds the secret with the pull-only token of the repo into the imagePullSecrets of the workloads
+	DockerConfigJsonPullTokens bool
+	SetDockerConfigJsonValue   bool
+	DockerConfigPath           string
+
+	// PostRenderers are run before the werf post-renderer
+	PostRenderers []postrender.PostRenderer
+
+	// KubeConfigOptions are used for all targets, the kube context of the target takes precedence
+	KubeConfigOptions kube.KubeConfigOptions
+	// KubeInitializer initializes the global kube clients for the deploy without the werf.yaml deploy targets
+	KubeInitializer           helm.KubeInitializer
+	InsecureHelmDependencies  bool
+	StatusProgressPeriod      time.Duration
+	HooksStatusProgressPeriod time.Duration
+	ReleasesHistoryMax        int
+
+	Timeout      time.Duration
+	AutoRollback bool
+	Canary       bool
+}
+
+// Deploy deploys the release of the project into the targets returned by the GetDeployTargets.
+// Without the werf.yaml deploy targets the release is deployed with the global kube clients, otherwise the release is deployed into the targets in parallel.
+func Deploy(ctx context.Context, giterminismManager giterminism_manager.Interface, werfConfig *config.WerfConfig, targets []*DeployTarget, opts DeployOptions) error {
+	deployIntoTarget := func(ctx context.Context, target *DeployTarget) error {
+		return deploy(ctx, target, giterminismManager, werfConfig, opts)
+	}
+
+	if len(werfConfig.Meta.Deploy.Targets) == 0 {
+		return deployIntoTarget(ctx, targets[0])
+	}
+
+	return deployIntoTargets(ctx, targets, opts.KubeConfigOptions, deployIntoTarget)
+}
+
+// deployIntoTargets deploys the release into all targets in parallel using the kube clients created once for every kube context.
+// The output of each target is printed when the target deploy is finished. The failed target does not stop the deploy into the other targets,
+// the result of each target is reported at the end.
+func deployIntoTargets(ctx context.Context, targets []*DeployTarget, kubeConfigOptions kube.KubeConfigOptions, deployIntoTarget func(ctx context.Context, target *DeployTarget) error) error {
+	if err := InitDeployTargetsKubeClients(targets, kubeConfigOptions); err != nil {
+		return err
+	}
+
+	targetErrors := make([]error, len(targets))
+	if err := parallel.DoTasks(ctx, len(targets), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		target := targets[taskId]
+		targetErrors[taskId] = logboek.Context(ctx).Default().LogProcess("Deploying into kube context %q namespace %q", target.KubeContext, target.Namespace).DoError(func() error {
+			return deployIntoTarget(ctx, target)
+		})
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var failedTargets []string
+	logboek.Context(ctx).LogOptionalLn()
+	logboek.Context(ctx).Default().LogBlock("Deploy targets summary").Do(func() {
+		for ind, target := range targets {
+			if targetErrors[ind] != nil {
+				logboek.Context(ctx).Default().LogF("- kube context %q namespace %q: FAILED: %s\n", target.KubeContext, target.Namespace, targetErrors[ind])
+				failedTargets = append(failedTargets, fmt.Sprintf("%s/%s", target.KubeContext, target.Namespace))
+			} else {
+				logboek.Context(ctx).Default().LogF("+ kube context %q namespace %q: OK\n", target.KubeContext, target.Namespace)
+			}
+		}
+	})
+
+	if len(failedTargets) != 0 {
+		return fmt.Errorf("deploy failed for %d of %d targets: %s", len(failedTargets), len(targets), strings.Join(failedTargets, ", "))
+	}
+
+	return nil
+}
+
+var helmGlobalsMutex sync.Mutex
+
+// lockHelmGlobals locks the global helm settings and chart loader options and returns the function releasing the lock,
+// the function can be called several times.
+func lockHelmGlobals() func() {
+	helmGlobalsMutex.Lock()
+
+	var once sync.Once
+	return func() {
+		once.Do(helmGlobalsMutex.Unlock)
+	}
+}
+
+// chartLoadedHook calls onLoaded when the chart and the chart dependencies are loaded.
+type chartLoadedHook struct {
+	chart.ChartExtender
+	onLoaded func()
+}
+
+func (hook *chartLoadedHook) ChartDependenciesLoaded() error {
+	defer hook.onLoaded()
+	return hook.ChartExtender.ChartDependenciesLoaded()
+}
+
+func deploy(ctx context.Context, target *DeployTarget, giterminismManager giterminism_manager.Interface, werfConfig *config.WerfConfig, opts DeployOptions) error {
+	namespace := target.Namespace
+	releaseName := opts.ReleaseName
+	kubeClient, kubeDynamicClient := target.GetKubeClients()
+
+	kubeConfigOptions := opts.KubeConfigOptions
+	kubeConfigOptions.Context = target.KubeContext
+
+	if werfConfig.Meta.Deploy.Bootstrap != nil {
+		if err := logboek.Context(ctx).Default().LogProcess("Bootstrapping namespace %q", namespace).DoError(func() error {
+			return bootstrap.Bootstrap(ctx, kubeClient, namespace, werfConfig.Meta.Deploy.Bootstrap, bootstrap.Options{ImagesRepository: opts.ImagesRepository})
+		}); err != nil {
+			return fmt.Errorf("bootstrap failed: %s", err)
+		}
+	}
+
+	if opts.ImagePullSecret != "" {
+		if err := bootstrap.SyncImagePullSecret(ctx, kubeClient, namespace, opts.ImagePullSecret, opts.ImagesRepository); err != nil {
+			return fmt.Errorf("unable to sync image pull secret: %s", err)
+		}
+	}
+
+	var pullTokenSecret string
+	if opts.DockerConfigJsonPullTokens {
+		pullTokenSecret = helpers.GetPullTokenSecretName(releaseName)
+		if err := bootstrap.SyncImagePullSecret(ctx, kubeClient, namespace, pullTokenSecret, opts.ImagesRepository); err != nil {
+			return fmt.Errorf("unable to sync pull token secret: %s", err)
+		}
+	}
+
+	var lockManager *lock_manager.LockManager
+	if m, err := lock_manager.NewLockManagerWithKubeClients(namespace, kubeClient, kubeDynamicClient); err != nil {
+		return fmt.Errorf("unable to create lock manager: %s", err)
+	} else {
+		lockManager = m
+	}
+
+	registryClient, err := cmd_helm.NewRegistryClient(logboek.Context(ctx).Debug().IsAccepted(), opts.InsecureHelmDependencies, logboek.Context(ctx).OutStream())
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+	registryClientHandle := cmd_helm.NewRegistryClientHandle(registryClient)
+
+	// The helm settings and the chart loader options are global, so the parallel deploys into several targets prepare the release one by one.
+	// The lock is released as soon as the chart of the release is loaded, the release is upgraded and tracked in parallel.
+	releaseHelmGlobals := lockHelmGlobals()
+	defer releaseHelmGlobals()
+
+	wc := chart_extender.NewWerfChart(ctx, giterminismManager, opts.SecretsManager, opts.ChartDir, cmd_helm.Settings, registryClientHandle, chart_extender.WerfChartOptions{
+		SecretValueFiles: opts.SecretValueFiles,
+		ExtraAnnotations: opts.ExtraAnnotations,
+		ExtraLabels:      opts.ExtraLabels,
+	})
+
+	if err := wc.SetEnv(opts.Env); err != nil {
+		return err
+	}
+	if err := wc.SetWerfConfig(werfConfig); err != nil {
+		return err
+	}
+
+	if vals, err := helpers.GetServiceValues(ctx, werfConfig.Meta.Project, opts.ImagesRepository, opts.ImagesInfoGetters, helpers.ServiceValuesOptions{
+		Namespace:                namespace,
+		Env:                      opts.Env,
+		SetDockerConfigJsonValue: opts.SetDockerConfigJsonValue,
+		DockerConfigPath:         opts.DockerConfigPath,
+		PullTokenSecret:          pullTokenSecret,
+		ImagePullSecret:          opts.ImagePullSecret,
+		Dependencies:             opts.Dependencies,
+	}); err != nil {
+		return fmt.Errorf("error creating service values: %s", err)
+	} else {
+		wc.SetServiceValues(vals)
+	}
+	wc.SetCommandLineValues(opts.CommandLineValues)
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{
+		ChartExtender:               wc,
+		SubchartExtenderFactoryFunc: func() chart.ChartExtender { return chart_extender.NewWerfSubchart() },
+	}
+
+	valueOpts := opts.ValuesOptions
+
+	werfPostRenderer, err := wc.GetPostRenderer()
+	if err != nil {
+		return err
+	}
+
+	var imagePullSecrets []string
+	if opts.ImagePullSecret != "" {
+		imagePullSecrets = append(imagePullSecrets, opts.ImagePullSecret)
+	}
+	if pullTokenSecret != "" {
+		imagePullSecrets = append(imagePullSecrets, pullTokenSecret)
+	}
+	werfPostRenderer.SetImagePullSecrets(imagePullSecrets)
+
+	var postRenderer postrender.PostRenderer = werfPostRenderer
+	if len(opts.PostRenderers) != 0 {
+		postRenderer = helm.NewChainPostRenderer(append(append([]postrender.PostRenderer{}, opts.PostRenderers...), werfPostRenderer)...)
+	}
+
+	newActionConfig := func() (*action.Configuration, error) {
+		return newDeployTargetActionConfig(ctx, target, registryClientHandle, opts)
+	}
+
+	actionConfig, err := newActionConfig()
+	if err != nil {
+		return err
+	}
+	maintenanceHelper := createMaintenanceHelper(ctx, actionConfig, kubeConfigOptions)
+
+	fullChartDir := filepath.Join(giterminismManager.ProjectDir(), opts.ChartDir)
+	if err := migrateHelm2ToHelm3(ctx, releaseName, namespace, maintenanceHelper, newActionConfig, postRenderer, &valueOpts, fullChartDir); err != nil {
+		return err
+	}
+
+	actionConfig, err = newActionConfig()
+	if err != nil {
+		return err
+	}
+
+	// the chart extender releases the helm globals lock when the chart is loaded by the helm upgrade command
+	loader.GlobalLoadOptions = &loader.LoadOptions{
+		ChartExtender:               &chartLoadedHook{ChartExtender: wc, onLoaded: releaseHelmGlobals},
+		SubchartExtenderFactoryFunc: loader.GlobalLoadOptions.SubchartExtenderFactoryFunc,
+	}
+
+	deploySteps := helm.NewDeploySteps(ctx, werfPostRenderer).
+		Add(helm.NewBeforeHooksResourcesCreator(actionConfig.KubeClient, releaseName, namespace))
+	if opts.Canary {
+		deploySteps.Add(helm.NewCanaryDeployer(actionConfig.KubeClient, kubeClient, opts.Timeout))
+	}
+	deploySteps.
+		Add(helm.NewWavesDeployer(actionConfig.KubeClient, releaseName, namespace, opts.Timeout)).
+		Attach(actionConfig.Releases)
+
+	createNamespace, install, wait := true, true, true
+	// The promoted canary is rolled back along with the release, if the release update fails.
+	atomic := opts.AutoRollback || opts.Canary
+	timeout := opts.Timeout
+	helmUpgradeCmd, _ := cmd_helm.NewUpgradeCmd(actionConfig, logboek.OutStream(), cmd_helm.UpgradeCmdOptions{
+		PostRenderer:    postRenderer,
+		ValueOpts:       &valueOpts,
+		CreateNamespace: &createNamespace,
+		Install:         &install,
+		Wait:            &wait,
+		Atomic:          &atomic,
+		Timeout:         &timeout,
+	})
+
+	return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
+		if err := helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, fullChartDir}); err != nil {
+			return fmt.Errorf("helm upgrade have failed: %s", err)
+		}
+		return nil
+	})
+}
+
+// newDeployTargetActionConfig creates the action config for the kube context and the namespace of the deploy target.
+// The resources are tracked with the kube clients of the target if initialized, otherwise the global kube clients are initialized on demand.
+func newDeployTargetActionConfig(ctx context.Context, target *DeployTarget, registryClientHandle *cmd_helm.RegistryClientHandle, opts DeployOptions) (*action.Configuration, error) {
+	initOptions := helm.InitActionConfigOptions{
+		StatusProgressPeriod:      opts.StatusProgressPeriod,
+		HooksStatusProgressPeriod: opts.HooksStatusProgressPeriod,
+		KubeConfigOptions:         opts.KubeConfigOptions,
+		ReleasesHistoryMax:        opts.ReleasesHistoryMax,
+		KubeClient:                target.KubeClient,
+		KubeDynamicClient:         target.KubeDynamicClient,
+	}
+	initOptions.KubeConfigOptions.Context = target.KubeContext
+
+	kubeInitializer := opts.KubeInitializer
+	if target.KubeClient != nil {
+		kubeInitializer = nil
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := helm.InitActionConfig(ctx, kubeInitializer, target.Namespace, cmd_helm.Settings, registryClientHandle, actionConfig, initOptions); err != nil {
+		return nil, err
+	}
+
+	return actionConfig, nil
+}
+
+func createMaintenanceHelper(ctx context.Context, actionConfig *action.Configuration, kubeConfigOptions kube.KubeConfigOptions) *maintenance_helper.MaintenanceHelper {
+	maintenanceOpts := maintenance_helper.MaintenanceHelperOptions{
+		KubeConfigOptions: kubeConfigOptions,
+	}
+
+	for _, val := range []string{
+		os.Getenv("WERF_HELM2_RELEASE_STORAGE_NAMESPACE"),
+		os.Getenv("WERF_HELM_RELEASE_STORAGE_NAMESPACE"),
+		os.Getenv("TILLER_NAMESPACE"),
+	} {
+		if val != "" {
+			maintenanceOpts.Helm2ReleaseStorageNamespace = val
+			break
+		}
+	}
+
+	for _, val := range []string{
+		os.Getenv("WERF_HELM2_RELEASE_STORAGE_TYPE"),
+		os.Getenv("WERF_HELM_RELEASE_STORAGE_TYPE"),
+	} {
+		if val != "" {
+			maintenanceOpts.Helm2ReleaseStorageType = val
+			break
+		}
+	}
+
+	return maintenance_helper.NewMaintenanceHelper(actionConfig, maintenanceOpts)
+}
+
+func migrateHelm2ToHelm3(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper, newActionConfig func() (*action.Configuration, error), postRenderer postrender.PostRenderer, valueOpts *values.Options, fullChartDir string) error {
+	if helm2Exists, err := checkHelm2AvailableAndReleaseExists(ctx, releaseName, namespace, maintenanceHelper); err != nil {
+		return fmt.Errorf("error checking availability of helm 2 and existance of helm 2 release %q: %s", releaseName, err)
+	} else if !helm2Exists {
+		return nil
+	}
+
+	if helm3Exists, err := checkHelm3ReleaseExists(ctx, releaseName, namespace, maintenanceHelper); err != nil {
+		return fmt.Errorf("error checking existance of helm 3 release %q: %s", releaseName, err)
+	} else if helm3Exists {
+		// helm 2 exists and helm 3 exists
+		// migration not needed, but we should warn user that some helm 2 release with the same name exists
+
+		logboek.Context(ctx).Warn().LogF("### Helm 2 and helm 3 release %q exists at the same time ###\n", releaseName)
+		logboek.Context(ctx).Warn().LogLn()
+		logboek.Context(ctx).Warn().LogF("Found existing helm 2 release %q while there is existing helm 3 release %q in the %q namespace!\n", releaseName, releaseName, namespace)
+		logboek.Context(ctx).Warn().LogF("werf will continue deploy process into helm 3 release %q in the %q namespace\n", releaseName, namespace)
+		logboek.Context(ctx).Warn().LogF("To disable this warning please remove old helm 2 release %q metadata (fox example using: kubectl -n kube-system delete cm RELEASE_NAME.VERSION)\n", releaseName)
+		logboek.Context(ctx).Warn().LogLn()
+
+		return nil
+	}
+
+	logboek.Context(ctx).Warn().LogFDetails("Found existing helm 2 release %q, will try to render helm 3 templates and migrate existing release resources to helm 3\n", releaseName)
+
+	logboek.Context(ctx).Default().LogOptionalLn()
+	if err := logboek.Context(ctx).LogProcess("Rendering helm 3 templates for the current project state").DoError(func() error {
+		actionConfig, err := newActionConfig()
+		if err != nil {
+			return err
+		}
+
+		validate, includeCrds, isUpgrade := true, true, true
+		helmTemplateCmd, _ := cmd_helm.NewTemplateCmd(actionConfig, ioutil.Discard, cmd_helm.TemplateCmdOptions{
+			PostRenderer: postRenderer,
+			ValueOpts:    valueOpts,
+			Validate:     &validate,
+			IncludeCrds:  &includeCrds,
+			IsUpgrade:    &isUpgrade,
+		})
+		return helmTemplateCmd.RunE(helmTemplateCmd, []string{releaseName, fullChartDir})
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Migrating helm 2 release %q to helm 3 in the %q namespace", releaseName, namespace).DoError(func() error {
+		if err := maintenance_helper.Migrate2To3(ctx, releaseName, releaseName, namespace, maintenanceHelper, maintenance_helper.Migrate2To3Options{}); err != nil {
+			return fmt.Errorf("error migrating existing helm 2 release %q to helm 3 release %q in the namespace %q: %s", releaseName, releaseName, namespace, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkHelm2AvailableAndReleaseExists(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper) (bool, error) {
+	if available, err := maintenanceHelper.CheckHelm2StorageAvailable(ctx); err != nil {
+		return false, err
+	} else if available {
+		foundHelm2Release, err := maintenanceHelper.IsHelm2ReleaseExist(ctx, releaseName)
+		if err != nil {
+			return false, fmt.Errorf("error checking existance of helm 2 release %q: %s", releaseName, err)
+		}
+
+		return foundHelm2Release, nil
+	}
+
+	return false, nil
+}
+
+func checkHelm3ReleaseExists(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper) (bool, error) {
+	foundHelm3Release, err := maintenanceHelper.IsHelm3ReleaseExist(ctx, releaseName)
+	if err != nil {
+		return false, fmt.Errorf("error checking existance of helm 3 release %q: %s", releaseName, err)
+	}
+
+	return foundHelm3Release, nil
+}
@@ -0,0 +1,250 @@
+package flows
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/werf/kubedog/pkg/kube"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/slug"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func GetHelmRelease(releaseOption string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
+	if releaseOption != "" {
+		err := slug.ValidateHelmRelease(releaseOption)
+		if err != nil {
+			return "", fmt.Errorf("bad Helm release specified %q: %s", releaseOption, err)
+		}
+		return releaseOption, nil
+	}
+
+	var releaseTemplate string
+	if werfConfig.Meta.Deploy.HelmRelease != nil {
+		releaseTemplate = *werfConfig.Meta.Deploy.HelmRelease
+	} else if environmentOption == "" {
+		releaseTemplate = "[[ project ]]"
+	} else {
+		releaseTemplate = "[[ project ]]-[[ env ]]"
+	}
+
+	renderedRelease, err := renderDeployParamTemplate("release", releaseTemplate, environmentOption, werfConfig)
+	if err != nil {
+		return "", fmt.Errorf("cannot render Helm release name by template %q: %s", releaseTemplate, err)
+	}
+
+	if renderedRelease == "" {
+		return "", fmt.Errorf("Helm release rendered by template %q is empty: release name cannot be empty", releaseTemplate)
+	}
+
+	var helmReleaseSlug bool
+	if werfConfig.Meta.Deploy.HelmReleaseSlug != nil {
+		helmReleaseSlug = *werfConfig.Meta.Deploy.HelmReleaseSlug
+	} else {
+		helmReleaseSlug = true
+	}
+
+	if helmReleaseSlug {
+		return slug.HelmRelease(renderedRelease), nil
+	}
+
+	err = slug.ValidateHelmRelease(renderedRelease)
+	if err != nil {
+		return "", fmt.Errorf("bad Helm release %q rendered by template %q: %s", renderedRelease, releaseTemplate, err)
+	}
+
+	return renderedRelease, nil
+}
+
+func GetKubernetesNamespace(namespaceOption string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
+	if namespaceOption != "" {
+		err := slug.ValidateKubernetesNamespace(namespaceOption)
+		if err != nil {
+			return "", fmt.Errorf("bad Kubernetes namespace specified %q: %s", namespaceOption, err)
+		}
+		return namespaceOption, nil
+	}
+
+	var namespaceTemplate string
+	if werfConfig.Meta.Deploy.Namespace != nil {
+		namespaceTemplate = *werfConfig.Meta.Deploy.Namespace
+	} else if environmentOption == "" {
+		namespaceTemplate = "[[ project ]]"
+	} else {
+		namespaceTemplate = "[[ project ]]-[[ env ]]"
+	}
+
+	return getKubernetesNamespaceByTemplate(namespaceTemplate, environmentOption, werfConfig)
+}
+
+func getKubernetesNamespaceByTemplate(namespaceTemplate string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
+	renderedNamespace, err := renderDeployParamTemplate("namespace", namespaceTemplate, environmentOption, werfConfig)
+	if err != nil {
+		return "", fmt.Errorf("cannot render Kubernetes namespace by template %q: %s", namespaceTemplate, err)
+	}
+
+	if renderedNamespace == "" {
+		return "", fmt.Errorf("Kubernetes namespace rendered by template %q is empty: namespace cannot be empty", namespaceTemplate)
+	}
+
+	var namespaceSlug bool
+	if werfConfig.Meta.Deploy.NamespaceSlug != nil {
+		namespaceSlug = *werfConfig.Meta.Deploy.NamespaceSlug
+	} else {
+		namespaceSlug = true
+	}
+
+	if namespaceSlug {
+		return slug.KubernetesNamespace(renderedNamespace), nil
+	}
+
+	err = slug.ValidateKubernetesNamespace(renderedNamespace)
+	if err != nil {
+		return "", fmt.Errorf("bad Kubernetes namespace %q rendered by template %q: %s", renderedNamespace, namespaceTemplate, err)
+	}
+
+	return renderedNamespace, nil
+}
+
+type DeployTarget struct {
+	KubeContext string
+	Namespace   string
+
+	// KubeClient and KubeDynamicClient are set by the InitDeployTargetsKubeClients, the global kube clients are used otherwise.
+	KubeClient        kubernetes.Interface
+	KubeDynamicClient dynamic.Interface
+}
+
+// InitDeployTargetsKubeClients creates the kube clients of the targets. The clients are created once for every kube context
+// and shared by the targets with the same kube context, the kube context of the kubeConfigOptions is ignored.
+func InitDeployTargetsKubeClients(targets []*DeployTarget, kubeConfigOptions kube.KubeConfigOptions) error {
+	type kubeContextClients struct {
+		client        kubernetes.Interface
+		dynamicClient dynamic.Interface
+	}
+
+	clientsByKubeContext := map[string]*kubeContextClients{}
+	for _, target := range targets {
+		clients, ok := clientsByKubeContext[target.KubeContext]
+		if !ok {
+			targetKubeConfigOptions := kubeConfigOptions
+			targetKubeConfigOptions.Context = target.KubeContext
+
+			kubeConfig, err := kube.GetKubeConfig(targetKubeConfigOptions)
+			if err != nil {
+				return fmt.Errorf("unable to load kube config of the kube context %q: %s", target.KubeContext, err)
+			}
+			if kubeConfig == nil {
+				return fmt.Errorf("kube config of the kube context %q not found", target.KubeContext)
+			}
+
+			clients = &kubeContextClients{}
+			if clients.client, err = kubernetes.NewForConfig(kubeConfig.Config); err != nil {
+				return fmt.Errorf("unable to create kube client of the kube context %q: %s", target.KubeContext, err)
+			}
+			if clients.dynamicClient, err = dynamic.NewForConfig(kubeConfig.Config); err != nil {
+				return fmt.Errorf("unable to create kube dynamic client of the kube context %q: %s", target.KubeContext, err)
+			}
+
+			clientsByKubeContext[target.KubeContext] = clients
+		}
+
+		target.KubeClient = clients.client
+		target.KubeDynamicClient = clients.dynamicClient
+	}
+
+	return nil
+}
+
+// GetKubeClients returns the kube clients of the target, or the global kube clients if the target clients are not initialized.
+func (target *DeployTarget) GetKubeClients() (kubernetes.Interface, dynamic.Interface) {
+	if target.KubeClient != nil {
+		return target.KubeClient, target.KubeDynamicClient
+	}
+	return kube.Client, kube.DynamicClient
+}
+
+// GetDeployTargets returns the kube contexts and the namespaces the release should be deployed into.
+// Without deploy targets in the werf.yaml the release is deployed into the single kube context specified with the options.
+// Otherwise the release is deployed into every target, the kubeContextOption selects the targets with the matching context
+// and the namespaceOption overrides the namespaces of the targets.
+func GetDeployTargets(namespaceOption, kubeContextOption, environmentOption string, werfConfig *config.WerfConfig) ([]*DeployTarget, error) {
+	namespace, err := GetKubernetesNamespace(namespaceOption, environmentOption, werfConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(werfConfig.Meta.Deploy.Targets) == 0 {
+		return []*DeployTarget{{KubeContext: kubeContextOption, Namespace: namespace}}, nil
+	}
+
+	var targets []*DeployTarget
+	for _, target := range werfConfig.Meta.Deploy.Targets {
+		if kubeContextOption != "" && target.KubeContext != kubeContextOption {
+			continue
+		}
+
+		targetNamespace := namespace
+		if namespaceOption == "" && target.Namespace != "" {
+			targetNamespace, err = getKubernetesNamespaceByTemplate(target.Namespace, environmentOption, werfConfig)
+			if err != nil {
+				return nil, fmt.Errorf("bad namespace of the deploy target %q: %s", target.KubeContext, err)
+			}
+		}
+
+		targets = append(targets, &DeployTarget{KubeContext: target.KubeContext, Namespace: targetNamespace})
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("kube context %q not found among werf.yaml deploy targets", kubeContextOption)
+	}
+
+	return targets, nil
+}
+
+// GetMetadataTemplateData returns the data of the werf.yaml metadata.annotations and metadata.labels templates.
+func GetMetadataTemplateData(environment string, werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface) config.MetadataTemplateData {
+	return config.MetadataTemplateData{
+		Project:     werfConfig.Meta.Project,
+		Env:         environment,
+		Commit:      giterminismManager.HeadCommit(),
+		WerfVersion: werf.Version,
+		LookupEnv:   config.NewMetadataTemplateLookupEnv(giterminismManager),
+	}
+}
+
+func renderDeployParamTemplate(templateName, templateText string, environmentOption string, werfConfig *config.WerfConfig) (string, error) {
+	tmpl := template.New(templateName).Delims("[[", "]]")
+
+	funcMap := sprig.TxtFuncMap()
+	delete(funcMap, "env")
+	delete(funcMap, "expandenv")
+
+	funcMap["project"] = func() string {
+		return werfConfig.Meta.Project
+	}
+
+	funcMap["env"] = func() (string, error) {
+		return environmentOption, nil
+	}
+
+	tmpl = tmpl.Funcs(funcMap)
+
+	tmpl, err := tmpl.Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("bad template: %s", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := tmpl.ExecuteTemplate(buf, templateName, nil); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
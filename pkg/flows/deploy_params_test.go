@@ -0,0 +1,79 @@
+package flows
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/kubedog/pkg/kube"
+)
+
+const testDeployTargetsKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: first
+  cluster:
+    server: https://first.example.com
+- name: second
+  cluster:
+    server: https://second.example.com
+users:
+- name: user
+  user:
+    token: token
+contexts:
+- name: first
+  context:
+    cluster: first
+    user: user
+- name: second
+  context:
+    cluster: second
+    user: user
+current-context: first
+`
+
+func newDeployTargetsTestKubeConfigOptions(t *testing.T) kube.KubeConfigOptions {
+	kubeConfigPath := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(kubeConfigPath, []byte(testDeployTargetsKubeConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return kube.KubeConfigOptions{ConfigPath: kubeConfigPath}
+}
+
+func TestInitDeployTargetsKubeClients(t *testing.T) {
+	targets := []*DeployTarget{
+		{KubeContext: "first", Namespace: "production"},
+		{KubeContext: "second", Namespace: "production"},
+		{KubeContext: "first", Namespace: "staging"},
+	}
+
+	if err := InitDeployTargetsKubeClients(targets, newDeployTargetsTestKubeConfigOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range targets {
+		if target.KubeClient == nil || target.KubeDynamicClient == nil {
+			t.Fatalf("expected the kube clients of the target %+v", target)
+		}
+
+		client, dynamicClient := target.GetKubeClients()
+		if client != target.KubeClient || dynamicClient != target.KubeDynamicClient {
+			t.Fatalf("expected the target kube clients to be returned for the target %+v", target)
+		}
+	}
+
+	if targets[0].KubeClient != targets[2].KubeClient || targets[0].KubeDynamicClient != targets[2].KubeDynamicClient {
+		t.Fatal("expected the kube clients to be shared by the targets with the same kube context")
+	}
+	if targets[0].KubeClient == targets[1].KubeClient {
+		t.Fatal("expected the separate kube clients for the different kube contexts")
+	}
+}
+
+func TestInitDeployTargetsKubeClients_UnknownKubeContext(t *testing.T) {
+	if err := InitDeployTargetsKubeClients([]*DeployTarget{{KubeContext: "unknown"}}, newDeployTargetsTestKubeConfigOptions(t)); err == nil {
+		t.Fatal("expected the error for the unknown kube context")
+	}
+}
@@ -0,0 +1,94 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+)
+
+const testDeployKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster
+  cluster:
+    server: https://cluster.example.com
+users:
+- name: user
+  user:
+    token: token
+contexts:
+- name: first
+  context:
+    cluster: cluster
+    user: user
+- name: second
+  context:
+    cluster: cluster
+    user: user
+current-context: first
+`
+
+func newDeployTestKubeConfigOptions(t *testing.T) kube.KubeConfigOptions {
+	kubeConfigPath := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(kubeConfigPath, []byte(testDeployKubeConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return kube.KubeConfigOptions{ConfigPath: kubeConfigPath}
+}
+
+func TestDeployIntoTargets(t *testing.T) {
+	ctx := logboek.NewContext(context.Background(), logboek.NewLogger(ioutil.Discard, ioutil.Discard))
+	targets := []*DeployTarget{
+		{KubeContext: "first", Namespace: "production"},
+		{KubeContext: "second", Namespace: "production"},
+	}
+
+	// every deploy waits for the other one to start, so the test hangs if the targets are deployed one by one
+	var started sync.WaitGroup
+	started.Add(len(targets))
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+
+	err := deployIntoTargets(ctx, targets, newDeployTestKubeConfigOptions(t), func(ctx context.Context, target *DeployTarget) error {
+		if target.KubeClient == nil {
+			t.Errorf("expected the kube client of the target %+v", target)
+		}
+
+		started.Done()
+		select {
+		case <-allStarted:
+		case <-time.After(10 * time.Second):
+			return errors.New("the targets are not deployed in parallel")
+		}
+
+		if target.KubeContext == "second" {
+			return errors.New("deploy failed")
+		}
+		return nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "deploy failed for 1 of 2 targets: second/production") {
+		t.Fatalf("expected the error of the failed target, got %v", err)
+	}
+}
+
+func TestLockHelmGlobals(t *testing.T) {
+	release := lockHelmGlobals()
+	release()
+	release()
+
+	// the lock is released once, so it can be acquired again
+	lockHelmGlobals()()
+}
@@ -0,0 +1,134 @@
+// Package flows implements the main werf flows (build, converge and cleanup) and the project setup shared by them.
+// The flows are configured with the options structs and do not depend on the werf command line,
+// they are run by the werf commands and by the programs embedding werf (see the pkg/api package).
+// The werf runtime (the werf home and tmp dirs, the docker client, the container registry client and the ssh agent) should be initialized by the caller.
+package flows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/util"
+)
+
+type GiterminismOptions struct {
+	// Dir is the project dir, the current dir is used by default
+	Dir string
+	// GitWorkTree is the git work tree of the project, it is looked up from the project dir by default
+	GitWorkTree string
+	// Commit is the commit the project files are read from instead of the HEAD commit
+	Commit string
+	// VirtualMergeInto is the branch the HEAD commit is merged into before the project files are read, it is ignored with Commit
+	VirtualMergeInto string
+
+	Dev              bool
+	DevBranchPrefix  string
+	DevIgnore        []string
+	LooseGiterminism bool
+	// Audit records the places requiring loosening giterminism instead of failing
+	Audit bool
+}
+
+func NewGiterminismManager(ctx context.Context, opts GiterminismOptions) (giterminism_manager.Interface, error) {
+	workingDir, localGitRepo, headCommit, err := openGiterminismLocalRepo(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return giterminism_manager.NewManager(ctx, workingDir, localGitRepo, headCommit, getGiterminismManagerOptions(opts))
+}
+
+// ReadGiterminismConfig reads the giterminism config without the validation (see giterminism_manager.ReadConfig).
+func ReadGiterminismConfig(ctx context.Context, opts GiterminismOptions) ([]byte, error) {
+	workingDir, localGitRepo, headCommit, err := openGiterminismLocalRepo(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return giterminism_manager.ReadConfig(ctx, workingDir, localGitRepo, headCommit, getGiterminismManagerOptions(opts))
+}
+
+func getGiterminismManagerOptions(opts GiterminismOptions) giterminism_manager.NewManagerOptions {
+	return giterminism_manager.NewManagerOptions{
+		LooseGiterminism: opts.LooseGiterminism,
+		Dev:              opts.Dev,
+		Audit:            opts.Audit,
+	}
+}
+
+// openGiterminismLocalRepo returns the project dir, the local git repo and the commit the giterminism manager works with.
+func openGiterminismLocalRepo(ctx context.Context, opts GiterminismOptions) (string, *git_repo.Local, string, error) {
+	workingDir := GetWorkingDir(opts.Dir)
+
+	gitWorkTree, err := GetGitWorkTree(opts.GitWorkTree, workingDir)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	isWorkingDirInsideGitWorkTree := util.IsSubpathOfBasePath(gitWorkTree, workingDir)
+	areWorkingDirAndGitWorkTreeTheSame := gitWorkTree == workingDir
+	if !(isWorkingDirInsideGitWorkTree || areWorkingDirAndGitWorkTreeTheSame) {
+		return "", nil, "", fmt.Errorf("werf requires project dir — the current working directory or directory specified with --dir option (or WERF_DIR env var) — to be located inside the git work tree: %q is located outside of the git work tree %q", gitWorkTree, workingDir)
+	}
+
+	var openLocalRepoOptions git_repo.OpenLocalRepoOptions
+	if opts.Dev {
+		openLocalRepoOptions.WithServiceHeadCommit = true
+		openLocalRepoOptions.ServiceBranchOptions.Prefix = opts.DevBranchPrefix
+		openLocalRepoOptions.ServiceBranchOptions.GlobExcludeList = opts.DevIgnore
+	}
+
+	if opts.Commit == "" {
+		openLocalRepoOptions.VirtualMergeInto = opts.VirtualMergeInto
+	}
+
+	localGitRepo, err := git_repo.OpenLocalRepo(ctx, "own", gitWorkTree, openLocalRepoOptions)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	headCommit := opts.Commit
+	if headCommit == "" {
+		headCommit, err = localGitRepo.HeadCommit(ctx)
+		if err != nil {
+			return "", nil, "", err
+		}
+	} else if exist, err := localGitRepo.IsCommitExists(ctx, headCommit); err != nil {
+		return "", nil, "", fmt.Errorf("unable to check existence of commit %s: %s", headCommit, err)
+	} else if !exist {
+		return "", nil, "", fmt.Errorf("commit %s not found in the git repo %s: fetch the commit and try again", headCommit, gitWorkTree)
+	}
+
+	return workingDir, localGitRepo, headCommit, nil
+}
+
+func GetGitWorkTree(gitWorkTree, workingDir string) (string, error) {
+	if gitWorkTree != "" {
+		if isValid, err := true_git.IsValidWorkTree(gitWorkTree); err != nil {
+			return "", err
+		} else if isValid {
+			return util.GetAbsoluteFilepath(gitWorkTree), nil
+		}
+
+		return "", fmt.Errorf("werf requires a git work tree for the project to exist: not a valid git work tree %q specified", gitWorkTree)
+	}
+
+	if found, workTree, err := true_git.UpwardLookupAndVerifyWorkTree(workingDir); err != nil {
+		return "", err
+	} else if found {
+		return util.GetAbsoluteFilepath(workTree), nil
+	}
+
+	return "", fmt.Errorf("werf requires a git work tree for the project to exist: unable to find a valid .git in the current directory %q or parent directories, you may also specify git work tree explicitly with --git-work-tree option (or WERF_GIT_WORK_TREE env var)", util.GetAbsoluteFilepath("."))
+}
+
+func GetWorkingDir(dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+
+	return util.GetAbsoluteFilepath(dir)
+}
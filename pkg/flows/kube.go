@@ -0,0 +1,45 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/werf/kubedog/pkg/kube"
+)
+
+// OndemandKubeInitializer initializes the global kube clients and kubedog on the first use.
+type OndemandKubeInitializer struct {
+	KubeContext             string
+	KubeConfig              string
+	KubeConfigBase64        string
+	KubeConfigPathMergeList []string
+
+	initialized bool
+}
+
+func (initializer *OndemandKubeInitializer) Init(ctx context.Context) error {
+	if initializer.initialized {
+		return nil
+	}
+
+	if err := kube.Init(kube.InitOptions{KubeConfigOptions: initializer.KubeConfigOptions()}); err != nil {
+		return fmt.Errorf("cannot initialize kube: %s", err)
+	}
+
+	if err := InitKubedog(ctx); err != nil {
+		return fmt.Errorf("cannot init kubedog: %s", err)
+	}
+
+	initializer.initialized = true
+
+	return nil
+}
+
+func (initializer *OndemandKubeInitializer) KubeConfigOptions() kube.KubeConfigOptions {
+	return kube.KubeConfigOptions{
+		Context:             initializer.KubeContext,
+		ConfigPath:          initializer.KubeConfig,
+		ConfigDataBase64:    initializer.KubeConfigBase64,
+		ConfigPathMergeList: initializer.KubeConfigPathMergeList,
+	}
+}
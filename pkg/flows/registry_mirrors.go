@@ -0,0 +1,19 @@
+package flows
+
+import (
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+// InitRegistryMirrors configures the registry mirrors for all pulls of the process, the mirrors from werf.yaml registryMirrors directive are tried after the specified ones.
+func InitRegistryMirrors(mirrors []*docker_registry.RegistryMirror, werfConfig *config.WerfConfig) {
+	mirrors = append([]*docker_registry.RegistryMirror{}, mirrors...)
+
+	if werfConfig != nil {
+		for _, mirror := range werfConfig.Meta.RegistryMirrors {
+			mirrors = append(mirrors, &docker_registry.RegistryMirror{Registry: mirror.Registry, Mirror: mirror.Mirror})
+		}
+	}
+
+	docker_registry.SetRegistryMirrors(mirrors)
+}
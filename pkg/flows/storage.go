@@ -0,0 +1,70 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/util"
+)
+
+type StorageOptions struct {
+	StagesStorage storage.StagesStorage
+	// FinalStagesStorage is the optional final repo
+	FinalStagesStorage         storage.StagesStorage
+	SecondaryStagesStorageList []storage.StagesStorage
+	CacheStagesStorageList     []storage.StagesStorage
+
+	Synchronization SynchronizationOptions
+
+	// ReadOnly does not store anything into the repo
+	ReadOnly bool
+}
+
+// NewStorageManager creates the storage manager of the project and the lock manager of the project synchronization.
+func NewStorageManager(ctx context.Context, projectName string, opts StorageOptions) (*manager.StorageManager, storage.LockManager, error) {
+	synchronizationOptions := opts.Synchronization
+	synchronizationOptions.ReadOnly = synchronizationOptions.ReadOnly || opts.ReadOnly
+
+	synchronization, err := GetSynchronization(ctx, projectName, opts.StagesStorage, synchronizationOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	stagesStorageCache, err := GetStagesStorageCache(synchronization)
+	if err != nil {
+		return nil, nil, err
+	}
+	storageLockManager, err := GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storageManager := manager.NewStorageManager(projectName, opts.StagesStorage, opts.FinalStagesStorage, opts.SecondaryStagesStorageList, opts.CacheStagesStorageList, storageLockManager, stagesStorageCache)
+	if opts.ReadOnly {
+		storageManager.EnableReadOnly()
+	}
+
+	return storageManager, storageLockManager, nil
+}
+
+func GetManagedImagesNames(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, werfConfig *config.WerfConfig) ([]string, error) {
+	var imagesNames []string
+	if managedImages, err := stagesStorage.GetManagedImages(ctx, projectName); err != nil {
+		return nil, fmt.Errorf("unable to get managed images for project %q: %s", projectName, err)
+	} else {
+		imagesNames = append(imagesNames, managedImages...)
+	}
+	for _, image := range werfConfig.StapelImages {
+		imagesNames = append(imagesNames, image.Name)
+	}
+	for _, image := range werfConfig.ImagesFromDockerfile {
+		imagesNames = append(imagesNames, image.Name)
+	}
+	uniqImagesNames := util.UniqStrings(imagesNames)
+	sort.Strings(uniqImagesNames)
+
+	return uniqImagesNames, nil
+}
@@ -0,0 +1,224 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	"github.com/werf/logboek"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/synchronization_server"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/locker_with_retry"
+)
+
+type SynchronizationOptions struct {
+	// Address is the address of the synchronizer: :local, kubernetes://NAMESPACE[:CONTEXT][@(base64:BASE64_CONFIG_DATA)|CONFIG_PATH] or http[s]://HOST:PORT.
+	// The local synchronization is used for the local stages storage and the default http synchronization server otherwise
+	Address string
+	// KubeConfigOptions are used by the kubernetes synchronization if the kube config is not specified in the address
+	KubeConfigOptions kube.KubeConfigOptions
+	// KubeLeases stores the locks in the Lease objects and the stages storage cache in the ConfigMap keys
+	KubeLeases bool
+
+	// TLSCert, TLSKey and TLSCA are the client certificate, the client key and the CA certificate files of the https synchronization server
+	TLSCert, TLSKey, TLSCA string
+	// OIDCIssuer, OIDCClientID, OIDCClientSecret and OIDCScopes get the bearer token of the http synchronization server with the client credentials flow
+	OIDCIssuer, OIDCClientID, OIDCClientSecret string
+	OIDCScopes                                 []string
+	// Token is the token of the http synchronization server, it cannot be used with OIDCIssuer
+	Token string
+
+	// ReadOnly does not save the client id of the http synchronization server into the stages storage
+	ReadOnly bool
+}
+
+type SynchronizationType string
+
+const (
+	LocalSynchronization      SynchronizationType = "LocalSynchronization"
+	KubernetesSynchronization SynchronizationType = "KubernetesSynchronization"
+	HttpSynchronization       SynchronizationType = "HttpSynchronization"
+)
+
+type SynchronizationParams struct {
+	Address             string
+	SynchronizationType SynchronizationType
+	KubeParams          *storage.KubernetesSynchronizationParams
+	KubeLeases          bool
+	HttpClient          *http.Client
+}
+
+func GetSynchronization(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, opts SynchronizationOptions) (*SynchronizationParams, error) {
+	switch {
+	case opts.Address == "" && stagesStorage.Address() == storage.LocalStorageAddress, opts.Address == storage.LocalStorageAddress:
+		return &SynchronizationParams{SynchronizationType: LocalSynchronization, Address: storage.LocalStorageAddress}, nil
+	case opts.Address == "":
+		return getHttpSynchronization(ctx, storage.DefaultHttpSynchronizationServer, projectName, stagesStorage, opts)
+	case strings.HasPrefix(opts.Address, "kubernetes://"):
+		return getKubernetesSynchronization(opts)
+	case strings.HasPrefix(opts.Address, "http://"), strings.HasPrefix(opts.Address, "https://"):
+		return getHttpSynchronization(ctx, opts.Address, projectName, stagesStorage, opts)
+	default:
+		return nil, fmt.Errorf("only --synchronization=%s or --synchronization=kubernetes://NAMESPACE or --synchronization=http[s]://HOST:PORT/CLIENT_ID is supported, got %q", storage.LocalStorageAddress, opts.Address)
+	}
+}
+
+func getKubernetesSynchronization(opts SynchronizationOptions) (*SynchronizationParams, error) {
+	res := &SynchronizationParams{
+		SynchronizationType: KubernetesSynchronization,
+		Address:             opts.Address,
+		KubeLeases:          opts.KubeLeases,
+	}
+
+	if params, err := storage.ParseKubernetesSynchronization(res.Address); err != nil {
+		return nil, fmt.Errorf("unable to parse synchronization address %s: %s", res.Address, err)
+	} else {
+		res.KubeParams = params
+	}
+
+	if res.KubeParams.ConfigPath == "" {
+		res.KubeParams.ConfigPath = opts.KubeConfigOptions.ConfigPath
+	}
+	if res.KubeParams.ConfigContext == "" {
+		res.KubeParams.ConfigContext = opts.KubeConfigOptions.Context
+	}
+	if res.KubeParams.ConfigDataBase64 == "" {
+		res.KubeParams.ConfigDataBase64 = opts.KubeConfigOptions.ConfigDataBase64
+	}
+	if res.KubeParams.ConfigPathMergeList == nil {
+		res.KubeParams.ConfigPathMergeList = opts.KubeConfigOptions.ConfigPathMergeList
+	}
+
+	return res, nil
+}
+
+func getHttpSynchronization(ctx context.Context, synchronization, projectName string, stagesStorage storage.StagesStorage, opts SynchronizationOptions) (*SynchronizationParams, error) {
+	httpClient, err := newSynchronizationHttpClient(ctx, projectName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var address string
+	if err := logboek.Default().LogProcess("Getting client id for the http synchronization server").
+		DoError(func() error {
+			if clientID, err := synchronization_server.GetOrCreateClientID(ctx, projectName, synchronization_server.NewSynchronizationClient(synchronization, httpClient), stagesStorage, opts.ReadOnly); err != nil {
+				return fmt.Errorf("unable to get synchronization client id: %s", err)
+			} else {
+				address = fmt.Sprintf("%s/%s", synchronization, clientID)
+				logboek.Default().LogF("Using clientID %q for http synchronization server at address %s\n", clientID, address)
+				return nil
+			}
+		}); err != nil {
+		return nil, err
+	}
+
+	return &SynchronizationParams{Address: address, SynchronizationType: HttpSynchronization, HttpClient: httpClient}, nil
+}
+
+func newSynchronizationHttpClient(ctx context.Context, projectName string, opts SynchronizationOptions) (*http.Client, error) {
+	var providers []synchronization_server.CredentialsProvider
+
+	if opts.TLSCert != "" || opts.TLSKey != "" || opts.TLSCA != "" {
+		providers = append(providers, synchronization_server.NewTLSCredentialsProvider(opts.TLSCert, opts.TLSKey, opts.TLSCA))
+	}
+
+	if opts.OIDCIssuer != "" {
+		providers = append(providers, synchronization_server.NewOIDCCredentialsProvider(ctx, opts.OIDCIssuer, opts.OIDCClientID, opts.OIDCClientSecret, opts.OIDCScopes, projectName))
+	}
+
+	if opts.Token != "" {
+		providers = append(providers, synchronization_server.NewTokenCredentialsProvider(opts.Token, projectName))
+	}
+
+	client, err := synchronization_server.NewHttpClient(providers...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create synchronization server http client: %s", err)
+	}
+
+	return client, nil
+}
+
+func GetStagesStorageCache(synchronization *SynchronizationParams) (storage.StagesStorageCache, error) {
+	switch synchronization.SynchronizationType {
+	case LocalSynchronization:
+		return storage.NewFileStagesStorageCache(werf.GetStagesStorageCacheDir()), nil
+	case KubernetesSynchronization:
+		client, err := GetSynchronizationKubeClient(synchronization)
+		if err != nil {
+			return nil, err
+		}
+
+		if synchronization.KubeLeases {
+			return storage.NewKubernetesPatchStagesStorageCache(synchronization.KubeParams.Namespace, client, getSynchronizationStagesStorageCacheConfigMapName), nil
+		}
+		return storage.NewKubernetesStagesStorageCache(synchronization.KubeParams.Namespace, client, GetSynchronizationConfigMapName), nil
+	case HttpSynchronization:
+		return synchronization_server.NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/stages-storage-cache", synchronization.Address), synchronization.HttpClient), nil
+	default:
+		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
+	}
+}
+
+func GetSynchronizationConfigMapName(projectName string) string {
+	return fmt.Sprintf("werf-%s", projectName)
+}
+
+// getSynchronizationStagesStorageCacheConfigMapName is used with the kubernetes leases, the format of the cache differs from the cm/werf-PROJECT one.
+func getSynchronizationStagesStorageCacheConfigMapName(projectName string) string {
+	return fmt.Sprintf("werf-%s-stages-storage-cache", projectName)
+}
+
+func GetSynchronizationKubeClient(synchronization *SynchronizationParams) (kubernetes.Interface, error) {
+	if config, err := kube.GetKubeConfig(kube.KubeConfigOptions{
+		ConfigPath:          synchronization.KubeParams.ConfigPath,
+		ConfigDataBase64:    synchronization.KubeParams.ConfigDataBase64,
+		ConfigPathMergeList: synchronization.KubeParams.ConfigPathMergeList,
+		Context:             synchronization.KubeParams.ConfigContext,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to load synchronization kube config %q (context %q): %s", synchronization.KubeParams.ConfigPath, synchronization.KubeParams.ConfigContext, err)
+	} else if client, err := kubernetes.NewForConfig(config.Config); err != nil {
+		return nil, fmt.Errorf("unable to create synchronization kubernetes client: %s", err)
+	} else {
+		return client, nil
+	}
+}
+
+func GetStorageLockManager(ctx context.Context, synchronization *SynchronizationParams) (storage.LockManager, error) {
+	switch synchronization.SynchronizationType {
+	case LocalSynchronization:
+		return storage.NewGenericLockManager(werf.GetGlobalHostLocker()), nil
+	case KubernetesSynchronization:
+		if config, err := kube.GetKubeConfig(kube.KubeConfigOptions{
+			ConfigPath:          synchronization.KubeParams.ConfigPath,
+			ConfigDataBase64:    synchronization.KubeParams.ConfigDataBase64,
+			ConfigPathMergeList: synchronization.KubeParams.ConfigPathMergeList,
+			Context:             synchronization.KubeParams.ConfigContext,
+		}); err != nil {
+			return nil, fmt.Errorf("unable to load synchronization kube config %q (context %q): %s", synchronization.KubeParams.ConfigPath, synchronization.KubeParams.ConfigContext, err)
+		} else if dynamicClient, err := dynamic.NewForConfig(config.Config); err != nil {
+			return nil, fmt.Errorf("unable to create synchronization kubernetes dynamic client: %s", err)
+		} else if client, err := kubernetes.NewForConfig(config.Config); err != nil {
+			return nil, fmt.Errorf("unable to create synchronization kubernetes client: %s", err)
+		} else {
+			lockManager := storage.NewKubernetesLockManager(synchronization.KubeParams.Namespace, client, dynamicClient, GetSynchronizationConfigMapName)
+			lockManager.UseLeases = synchronization.KubeLeases
+			return lockManager, nil
+		}
+	case HttpSynchronization:
+		locker := distributed_locker.NewDistributedLocker(&distributed_locker.HttpBackend{
+			URLEndpoint: fmt.Sprintf("%s/locker", synchronization.Address),
+			HttpClient:  synchronization.HttpClient,
+		})
+		lockerWithRetry := locker_with_retry.NewLockerWithRetry(ctx, locker, locker_with_retry.LockerWithRetryOptions{MaxAcquireAttempts: 10, MaxReleaseAttempts: 10})
+		return storage.NewGenericLockManager(lockerWithRetry), nil
+	default:
+		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	pathPkg "path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -447,6 +448,48 @@ func (repo *Base) tagsList(repoPath string) ([]string, error) {
 	return res, nil
 }
 
+func (repo *Base) commitTagsList(repoPath, commit string) ([]string, error) {
+	repository, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo %q: %s", repoPath, err)
+	}
+
+	tags, err := repository.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0)
+
+	if err := tags.ForEach(func(ref *plumbing.Reference) error {
+		tagName := strings.TrimPrefix(ref.Name().String(), "refs/tags/")
+		tagCommit := ref.Hash().String()
+
+		obj, err := repository.TagObject(ref.Hash())
+		switch err {
+		case nil:
+			// Annotated tag
+			tagCommit = obj.Target.String()
+		case plumbing.ErrObjectNotFound:
+			// Lightweight tag
+		default:
+			return err
+		}
+
+		if tagCommit == commit {
+			res = append(res, tagName)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
 func (repo *Base) remoteBranchesList(repoPath string) ([]string, error) {
 	repository, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
 	if err != nil {
@@ -0,0 +1,51 @@
+package git_repo
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBase_CommitTagsList(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+
+	commit := func(message string) string {
+		if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte(message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, dir, "add", "file")
+		runGit(t, dir, "commit", "-q", "-m", message)
+		return strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD"))
+	}
+
+	firstCommit := commit("first")
+	runGit(t, dir, "tag", "v1.0.0")
+
+	headCommit := commit("second")
+	runGit(t, dir, "tag", "v2.0.0")
+	runGit(t, dir, "tag", "-a", "release-2", "-m", "release 2")
+
+	untaggedCommit := commit("third")
+
+	for _, tc := range []struct {
+		commit   string
+		expected []string
+	}{
+		{firstCommit, []string{"v1.0.0"}},
+		// the lightweight and the annotated tags
+		{headCommit, []string{"release-2", "v2.0.0"}},
+		{untaggedCommit, []string{}},
+	} {
+		tags, err := (&Base{}).commitTagsList(dir, tc.commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(tags, tc.expected) {
+			t.Errorf("commit %s: expected tags %v, got %v", tc.commit, tc.expected, tags)
+		}
+	}
+}
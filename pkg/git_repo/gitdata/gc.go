@@ -89,6 +89,17 @@ func RunGC(ctx context.Context, allowedVolumeUsagePercentage, allowedVolumeUsage
 		}
 	}
 
+	{
+		cacheRoot := filepath.Join(werf.GetLocalCacheDir(), "git_shared_objects")
+		if err := wipeCacheDirs(ctx, cacheRoot, []string{git_repo.GitSharedObjectsCacheVersion}); err != nil {
+			return fmt.Errorf("unable to wipe old git shared objects cache dirs in %q: %s", cacheRoot, err)
+		}
+
+		if err := git_repo.PruneSharedObjects(ctx); err != nil {
+			return fmt.Errorf("unable to prune git shared objects: %s", err)
+		}
+	}
+
 	{
 		keepCacheVersions := []string{git_repo.GitWorktreesCacheVersion}
 		if keepGitDataV1_1 {
@@ -218,6 +229,10 @@ func RunGC(ctx context.Context, allowedVolumeUsagePercentage, allowedVolumeUsage
 		}
 	}
 
+	if err := git_repo.PruneSharedObjects(ctx); err != nil {
+		return fmt.Errorf("unable to prune git shared objects: %s", err)
+	}
+
 	return nil
 }
 
@@ -21,8 +21,8 @@ import (
 )
 
 const (
-	GitArchivesCacheVersion = "6"
-	GitPatchesCacheVersion  = "6"
+	GitArchivesCacheVersion = "7"
+	GitPatchesCacheVersion  = "8"
 )
 
 func GetHostGitDataManager(ctx context.Context) (*GitDataManager, error) {
@@ -32,21 +32,29 @@ func GetHostGitDataManager(ctx context.Context) (*GitDataManager, error) {
 		defer werf.ReleaseHostLock(lock)
 	}
 
-	archivesCacheDir := filepath.Join(werf.GetLocalCacheDir(), "git_archives", GitArchivesCacheVersion)
-	patchesCacheDir := filepath.Join(werf.GetLocalCacheDir(), "git_patches", GitPatchesCacheVersion)
-	tmpGitDataDir := filepath.Join(werf.GetServiceDir(), "tmp", "git_data")
+	manager := GetHostGitDataManagerReadOnly()
 
-	if err := os.MkdirAll(archivesCacheDir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("unable to create dir %q: %s", archivesCacheDir, err)
+	if err := os.MkdirAll(manager.ArchivesCacheDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create dir %q: %s", manager.ArchivesCacheDir, err)
 	}
-	if err := os.MkdirAll(patchesCacheDir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("unable to create dir %q: %s", patchesCacheDir, err)
+	if err := os.MkdirAll(manager.PatchesCacheDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create dir %q: %s", manager.PatchesCacheDir, err)
 	}
-	if err := os.MkdirAll(tmpGitDataDir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("unable to create dir %q: %s", tmpGitDataDir, err)
+	if err := os.MkdirAll(manager.TmpDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create dir %q: %s", manager.TmpDir, err)
 	}
 
-	return NewGitDataManager(archivesCacheDir, patchesCacheDir, tmpGitDataDir), nil
+	return manager, nil
+}
+
+// GetHostGitDataManagerReadOnly returns the host git data manager without taking the host lock and creating the cache dirs.
+// It is used by the informational commands (see werf.InitReadOnly), the dirs are created on demand if the git data is stored.
+func GetHostGitDataManagerReadOnly() *GitDataManager {
+	archivesCacheDir := filepath.Join(werf.GetLocalCacheDir(), "git_archives", GitArchivesCacheVersion)
+	patchesCacheDir := filepath.Join(werf.GetLocalCacheDir(), "git_patches", GitPatchesCacheVersion)
+	tmpGitDataDir := filepath.Join(werf.GetServiceDir(), "tmp", "git_data")
+
+	return NewGitDataManager(archivesCacheDir, patchesCacheDir, tmpGitDataDir)
 }
 
 func NewGitDataManager(archivesCacheDir, patchesCacheDir, tmpDir string) *GitDataManager {
@@ -0,0 +1,47 @@
+package gitdata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestGetHostGitDataManagerReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	homeDir := filepath.Join(dir, "home")
+
+	if err := werf.InitReadOnly(dir, homeDir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := GetHostGitDataManagerReadOnly()
+	for _, path := range []string{manager.ArchivesCacheDir, manager.PatchesCacheDir, manager.TmpDir} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %q not to be created by the read-only git data manager, got %v", path, err)
+		}
+	}
+	if _, err := os.Stat(homeDir); !os.IsNotExist(err) {
+		t.Fatalf("expected the werf home dir %q not to be created, got %v", homeDir, err)
+	}
+
+	if err := werf.Init(dir, homeDir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	hostManager, err := GetHostGitDataManager(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *hostManager != *manager {
+		t.Fatalf("expected the same dirs of the host git data manager %+v, got %+v", *hostManager, *manager)
+	}
+	for _, path := range []string{hostManager.ArchivesCacheDir, hostManager.PatchesCacheDir, hostManager.TmpDir} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %q to be created by the host git data manager: %s", path, err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 
 	"github.com/werf/logboek"
 	"github.com/werf/logboek/pkg/types"
@@ -37,6 +38,8 @@ type Local struct {
 type OpenLocalRepoOptions struct {
 	WithServiceHeadCommit bool
 	ServiceBranchOptions  ServiceBranchOptions
+	// VirtualMergeInto is the branch or other revision, which the head commit is merged into, the detached merge commit is used as the head commit
+	VirtualMergeInto string
 }
 
 type ServiceBranchOptions struct {
@@ -64,6 +67,12 @@ func OpenLocalRepo(ctx context.Context, name, workTreeDir string, opts OpenLocal
 		return l, err
 	}
 
+	if opts.VirtualMergeInto != "" {
+		if err := l.setVirtualMergeHeadCommit(ctx, opts.VirtualMergeInto); err != nil {
+			return l, err
+		}
+	}
+
 	if opts.WithServiceHeadCommit {
 		if lock, err := CommonGitDataManager.LockGC(ctx, true); err != nil {
 			return nil, err
@@ -193,6 +202,40 @@ func (repo *Local) CreateDetachedMergeCommit(ctx context.Context, fromCommit, to
 	return repo.createDetachedMergeCommit(ctx, repo.GitDir, repo.WorkTreeDir, repo.getRepoWorkTreeCacheDir(repo.getRepoID()), fromCommit, toCommit)
 }
 
+// setVirtualMergeHeadCommit replaces the head commit with the detached merge commit of the head commit into the specified revision.
+func (repo *Local) setVirtualMergeHeadCommit(ctx context.Context, intoRevision string) error {
+	intoCommit, err := repo.ResolveRevision(ctx, intoRevision)
+	if err != nil {
+		return fmt.Errorf("unable to resolve virtual merge target %q: %s", intoRevision, err)
+	}
+
+	mergeCommit, err := repo.CreateDetachedMergeCommit(ctx, repo.headCommit, intoCommit)
+	if err != nil {
+		return fmt.Errorf("unable to create virtual merge commit of %s into %s (%s): %s", repo.headCommit, intoRevision, intoCommit, err)
+	}
+
+	logboek.Context(ctx).Info().LogF("Created virtual merge commit %s (merge %s into %s %s)\n", mergeCommit, repo.headCommit, intoRevision, intoCommit)
+
+	repo.headCommit = mergeCommit
+
+	return nil
+}
+
+// ResolveRevision returns the commit of the branch, the tag, the remote branch (e.g. origin/main) or the commit hash.
+func (repo *Local) ResolveRevision(_ context.Context, revision string) (string, error) {
+	repository, err := repo.PlainOpen()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", err
+	}
+
+	return hash.String(), nil
+}
+
 func (repo *Local) GetMergeCommitParents(_ context.Context, commit string) ([]string, error) {
 	return repo.getMergeCommitParents(repo.GitDir, commit)
 }
@@ -254,6 +297,11 @@ func (repo *Local) TagsList(_ context.Context) ([]string, error) {
 	return repo.tagsList(repo.WorkTreeDir)
 }
 
+// CommitTagsList returns the names of the tags pointing to the commit.
+func (repo *Local) CommitTagsList(_ context.Context, commit string) ([]string, error) {
+	return repo.commitTagsList(repo.WorkTreeDir, commit)
+}
+
 func (repo *Local) RemoteBranchesList(_ context.Context) ([]string, error) {
 	return repo.remoteBranchesList(repo.WorkTreeDir)
 }
@@ -29,6 +29,11 @@ type Remote struct {
 	Url      string
 	IsDryRun bool
 
+	// PartialClone enables the partial clone without blobs, the blobs are fetched on demand when the work tree is checked out
+	PartialClone bool
+	// SparseCheckoutPatterns limits the paths checked out into the work tree of the partial clone, all paths are checked out by default
+	SparseCheckoutPatterns []string
+
 	Endpoint *transport.Endpoint
 }
 
@@ -38,6 +43,14 @@ func OpenRemoteRepo(name, url string) (*Remote, error) {
 	return repo, repo.ValidateEndpoint()
 }
 
+// OpenPartialRemoteRepo opens the remote repo, which transfers only the blobs of the paths matching the sparse checkout patterns.
+// The partial clone is stored separately from the complete clone of the same repo.
+func OpenPartialRemoteRepo(name, url string, sparseCheckoutPatterns []string) (*Remote, error) {
+	repo := &Remote{Url: url, PartialClone: true, SparseCheckoutPatterns: sparseCheckoutPatterns}
+	repo.Base = NewBase(name, repo.initRepoHandleBackedByWorkTree)
+	return repo, repo.ValidateEndpoint()
+}
+
 func (repo *Remote) ValidateEndpoint() error {
 	if ep, err := transport.NewEndpoint(repo.Url); err != nil {
 		return fmt.Errorf("bad url %q: %s", repo.Url, err)
@@ -84,6 +97,12 @@ func (repo *Remote) CloneAndFetch(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if len(repo.SparseCheckoutPatterns) > 0 && !repo.IsDryRun {
+		if err := true_git.SetWorkTreeSparseCheckoutPatterns(repo.getWorkTreeCacheDir(repo.getRepoID()), repo.SparseCheckoutPatterns); err != nil {
+			return err
+		}
+	}
+
 	if isCloned {
 		return nil
 	}
@@ -93,15 +112,20 @@ func (repo *Remote) CloneAndFetch(ctx context.Context) error {
 
 func (repo *Remote) isCloneExists() (bool, error) {
 	_, err := os.Stat(repo.GetClonePath())
-	if err == nil {
-		return true, nil
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot clone git repo: %s", err)
 	}
 
-	if !os.IsNotExist(err) {
+	// The clone borrowing objects from the removed shared objects pool is broken and should be cloned again
+	if isValid, err := isAlternatesValid(repo.GetClonePath()); err != nil {
 		return false, fmt.Errorf("cannot clone git repo: %s", err)
+	} else if !isValid {
+		return false, nil
 	}
 
-	return false, nil
+	return true, nil
 }
 
 func (repo *Remote) updateLastAccessAt(ctx context.Context, repoPath string) error {
@@ -153,6 +177,11 @@ func (repo *Remote) Clone(ctx context.Context) (bool, error) {
 			return nil
 		}
 
+		// Remove the broken clone if exists, the work trees of the removed clone are recreated on demand
+		if err := os.RemoveAll(repo.GetClonePath()); err != nil {
+			return fmt.Errorf("unable to remove broken clone %s: %s", repo.GetClonePath(), err)
+		}
+
 		logboek.Context(ctx).Default().LogFDetails("Clone %s\n", repo.Url)
 
 		if err := os.MkdirAll(filepath.Dir(repo.GetClonePath()), 0o755); err != nil {
@@ -167,12 +196,32 @@ func (repo *Remote) Clone(ctx context.Context) (bool, error) {
 		// Ensure cleanup on failure
 		defer os.RemoveAll(tmpPath)
 
-		_, err = git.PlainClone(tmpPath, true, &git.CloneOptions{
-			URL:               repo.Url,
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-		})
-		if err != nil {
-			return err
+		if repo.PartialClone {
+			// The objects of the partial clone cannot be shared: fetching from the partial clone would download all blobs
+			opts := true_git.CloneBareOptions{Filter: "blob:none"}
+			if isSharedObjectsEnabled() {
+				opts.ReferenceIfAble = GetSharedObjectsDir()
+			}
+
+			if err := true_git.CloneBare(ctx, repo.Url, tmpPath, opts); err != nil {
+				return err
+			}
+		} else if isSharedObjectsEnabled() {
+			if err := true_git.CloneBare(ctx, repo.Url, tmpPath, true_git.CloneBareOptions{ReferenceIfAble: GetSharedObjectsDir()}); err != nil {
+				return err
+			}
+
+			if err := shareCloneObjects(ctx, repo.getRepoID(), tmpPath); err != nil {
+				return err
+			}
+		} else {
+			_, err = git.PlainClone(tmpPath, true, &git.CloneOptions{
+				URL:               repo.Url,
+				RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+			})
+			if err != nil {
+				return err
+			}
 		}
 
 		if err := repo.updateLastAccessAt(ctx, tmpPath); err != nil {
@@ -192,6 +241,12 @@ func (repo *Remote) Fetch(ctx context.Context) error {
 		return nil
 	}
 
+	if lock, err := CommonGitDataManager.LockGC(ctx, true); err != nil {
+		return err
+	} else {
+		defer werf.ReleaseHostLock(lock)
+	}
+
 	cfgPath := filepath.Join(repo.GetClonePath(), "config")
 
 	cfg, err := ini.Load(cfgPath)
@@ -211,19 +266,29 @@ func (repo *Remote) Fetch(ctx context.Context) error {
 	}
 
 	return repo.withRemoteRepoLock(ctx, func() error {
+		logboek.Context(ctx).Default().LogFDetails("Fetch remote %s of %s\n", remoteName, repo.Url)
+
+		// go-git does not support partial clones and would fetch all blobs
+		if repo.PartialClone {
+			if err := true_git.Fetch(ctx, repo.GetClonePath(), true_git.FetchOptions{TagsOnly: true, RefSpecs: map[string]string{remoteName: "+refs/heads/*:refs/remotes/origin/*"}}); err != nil {
+				return fmt.Errorf("cannot fetch remote %q of repo %q: %s", remoteName, repo.String(), err)
+			}
+			return nil
+		}
+
 		rawRepo, err := git.PlainOpenWithOptions(repo.GetClonePath(), &git.PlainOpenOptions{EnableDotGitCommonDir: true})
 		if err != nil {
 			return fmt.Errorf("cannot open repo: %s", err)
 		}
 
-		logboek.Context(ctx).Default().LogFDetails("Fetch remote %s of %s\n", remoteName, repo.Url)
-
 		err = rawRepo.Fetch(&git.FetchOptions{RemoteName: remoteName, Force: true, Tags: git.AllTags})
-		if err != nil && err != git.NoErrAlreadyUpToDate {
+		if err == git.NoErrAlreadyUpToDate {
+			return nil
+		} else if err != nil {
 			return fmt.Errorf("cannot fetch remote %q of repo %q: %s", remoteName, repo.String(), err)
 		}
 
-		return nil
+		return shareCloneObjects(ctx, repo.getRepoID(), repo.GetClonePath())
 	})
 }
 
@@ -328,15 +393,23 @@ func (repo *Remote) IsCommitExists(ctx context.Context, commit string) (bool, er
 }
 
 func (repo *Remote) getRepoID() string {
+	if repo.PartialClone {
+		return util.Sha256Hash(repo.getFilesystemRelativePathByEndpoint(), "partial")
+	}
 	return util.Sha256Hash(repo.getFilesystemRelativePathByEndpoint())
 }
 
 func (repo *Remote) getWorkTreeCacheDir(repoID string) string {
+	// The sparse checkout patterns of the existing work tree cannot be changed
+	if len(repo.SparseCheckoutPatterns) > 0 {
+		return filepath.Join(GetWorkTreeCacheDir(), "remote", util.Sha256Hash(append([]string{repoID}, repo.SparseCheckoutPatterns...)...))
+	}
 	return filepath.Join(GetWorkTreeCacheDir(), "remote", repoID)
 }
 
+// withRemoteRepoLock locks the clone, which is shared by all repos with the same url on the host regardless of the repo name.
 func (repo *Remote) withRemoteRepoLock(ctx context.Context, f func() error) error {
-	lockName := fmt.Sprintf("remote_git_mapping.%s", repo.Name)
+	lockName := fmt.Sprintf("remote_git_mapping.%s", repo.getRepoID())
 	return werf.WithHostLock(ctx, lockName, lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
 }
 
@@ -376,7 +449,7 @@ func (repo *Remote) initRepoHandleBackedByWorkTree(ctx context.Context, commit s
 	}
 
 	var repoHandle repo_handle.Handle
-	if err := true_git.WithWorkTree(ctx, repo.GetClonePath(), repo.getWorkTreeCacheDir(repo.getRepoID()), commit, true_git.WithWorkTreeOptions{HasSubmodules: hasSubmodules}, func(preparedWorkTreeDir string) error {
+	if err := true_git.WithWorkTree(ctx, repo.GetClonePath(), repo.getWorkTreeCacheDir(repo.getRepoID()), commit, true_git.WithWorkTreeOptions{HasSubmodules: hasSubmodules, SparseCheckoutPatterns: repo.SparseCheckoutPatterns}, func(preparedWorkTreeDir string) error {
 		repositoryWithPreparedWorktree, err := true_git.GitOpenWithCustomWorktreeDir(repo.GetClonePath(), preparedWorkTreeDir)
 		if err != nil {
 			return err
@@ -0,0 +1,75 @@
+package git_repo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/true_git"
+)
+
+func TestOpenPartialRemoteRepo(t *testing.T) {
+	ctx := context.Background()
+	sourceDir := initSharedObjectsTest(t, false)
+
+	for _, path := range []string{"a/file", "b/file"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, filepath.Dir(path)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(sourceDir, path), []byte(path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, sourceDir, "add", ".")
+	runGit(t, sourceDir, "commit", "--quiet", "-m", "Add files")
+	runGit(t, sourceDir, "config", "uploadpack.allowFilter", "true")
+	commit := strings.TrimSpace(runGit(t, sourceDir, "rev-parse", "HEAD"))
+
+	url := "file://" + sourceDir
+
+	repo, err := OpenPartialRemoteRepo("partial", url, []string{"/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fullRepo, err := OpenRemoteRepo("full", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.GetClonePath() == fullRepo.GetClonePath() {
+		t.Fatalf("expected the partial clone to be stored separately from the complete clone")
+	}
+
+	if err := repo.CloneAndFetch(ctx); err != nil {
+		t.Fatalf("unable to clone: %s", err)
+	}
+	if err := repo.Fetch(ctx); err != nil {
+		t.Fatalf("unable to fetch: %s", err)
+	}
+
+	if isPartialClone, err := true_git.IsPartialClone(ctx, repo.GetClonePath()); err != nil {
+		t.Fatal(err)
+	} else if !isPartialClone {
+		t.Fatalf("expected the partial clone")
+	}
+
+	if _, err := repo.initRepoHandleBackedByWorkTree(ctx, commit); err != nil {
+		t.Fatalf("unable to prepare work tree: %s", err)
+	}
+
+	workTreeDir := filepath.Join(repo.getWorkTreeCacheDir(repo.getRepoID()), "worktree")
+	if _, err := os.Stat(filepath.Join(workTreeDir, "a", "file")); err != nil {
+		t.Fatalf("expected the matching path to be checked out: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(workTreeDir, "b", "file")); !os.IsNotExist(err) {
+		t.Fatalf("expected the other path not to be checked out, got %v", err)
+	}
+
+	// The sparse checkout does not affect other work trees of the clone
+	if value := strings.TrimSpace(runGit(t, repo.GetClonePath(), "config", "--default", "", "--get", "core.sparseCheckout")); value != "" {
+		t.Fatalf("unexpected core.sparseCheckout value %q in the clone config", value)
+	}
+}
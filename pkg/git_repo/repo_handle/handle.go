@@ -1,6 +1,7 @@
 package repo_handle
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -8,9 +9,12 @@ import (
 	"github.com/go-git/go-git/v5/config"
 )
 
+var ErrSubmoduleNotInitialized = errors.New("submodule not initialized")
+
 type handle struct {
-	repository          *git.Repository
-	submoduleHandleList []SubmoduleHandle
+	repository                  *git.Repository
+	submoduleHandleList         []SubmoduleHandle
+	uninitializedSubmodulePaths []string
 
 	mutex sync.Mutex
 }
@@ -35,6 +39,12 @@ func (h *handle) Submodule(submodulePath string) (SubmoduleHandle, error) {
 		}
 	}
 
+	for _, path := range h.uninitializedSubmodulePaths {
+		if path == submodulePath {
+			return nil, ErrSubmoduleNotInitialized
+		}
+	}
+
 	return nil, fmt.Errorf("submodule %q not found", submodulePath)
 }
 
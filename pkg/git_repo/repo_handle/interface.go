@@ -32,48 +32,54 @@ type Repository interface {
 func NewHandle(repository *git.Repository) (Handle, error) {
 	h := newHandle(repository)
 
-	submoduleHandleList, err := getSubmoduleHandleList(repository)
+	submoduleHandleList, uninitializedSubmodulePaths, err := getSubmoduleHandleList(repository)
 	if err != nil {
 		return nil, err
 	}
 
 	h.submoduleHandleList = submoduleHandleList
+	h.uninitializedSubmodulePaths = uninitializedSubmodulePaths
 
 	return h, nil
 }
 
-func getSubmoduleHandleList(parentRepository *git.Repository) ([]SubmoduleHandle, error) {
+func getSubmoduleHandleList(parentRepository *git.Repository) ([]SubmoduleHandle, []string, error) {
 	var list []SubmoduleHandle
+	var uninitializedPaths []string
 
 	w, err := parentRepository.Worktree()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	ss, err := w.Submodules()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, s := range ss {
 		submoduleRepository, err := s.Repository()
-		if err != nil {
-			return nil, fmt.Errorf("unable to get submodule %q repository: %s", s.Config().Path, err)
+		if err == git.ErrSubmoduleNotInitialized {
+			// the submodules deeper than the submodules depth limit are not initialized
+			uninitializedPaths = append(uninitializedPaths, s.Config().Path)
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("unable to get submodule %q repository: %s", s.Config().Path, err)
 		}
 
 		submoduleStatus, err := s.Status()
 		if err != nil {
-			return nil, fmt.Errorf("unable to get submodule %q status: %s", s.Config().Path, err)
+			return nil, nil, fmt.Errorf("unable to get submodule %q status: %s", s.Config().Path, err)
 		}
 
 		handle, err := NewHandle(submoduleRepository)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		submoduleHandle := newRepositorySubmoduleHandle(handle, s.Config(), submoduleStatus)
 		list = append(list, submoduleHandle)
 	}
 
-	return list, nil
+	return list, uninitializedPaths, nil
 }
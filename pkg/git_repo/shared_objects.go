@@ -0,0 +1,205 @@
+package git_repo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	GitSharedObjectsCacheVersion = "1"
+
+	sharedObjectsRefsPrefix = "refs/werf-clones"
+)
+
+// The remote repos clones on the host share the objects pool (the bare repository) using the git alternates mechanism:
+// the new clone borrows the existing objects from the pool, after each clone or fetch the clone objects are moved into the pool.
+// The pool keeps the refs of each clone in the separate refs namespace, so the objects used by the existing clones are never pruned.
+
+func GetSharedObjectsDir() string {
+	return filepath.Join(werf.GetLocalCacheDir(), "git_shared_objects", GitSharedObjectsCacheVersion)
+}
+
+func isSharedObjectsEnabled() bool {
+	return true_git.IsSharedObjectsEnabled()
+}
+
+func withSharedObjectsLock(ctx context.Context, f func() error) error {
+	return werf.WithHostLock(ctx, "git_shared_objects", lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
+}
+
+func getSharedObjectsCloneRefsNamespace(cloneID string) string {
+	return fmt.Sprintf("%s/%s", sharedObjectsRefsPrefix, cloneID)
+}
+
+func getAlternatesFilePath(gitDir string) string {
+	return filepath.Join(gitDir, "objects", "info", "alternates")
+}
+
+func readAlternates(gitDir string) ([]string, error) {
+	data, err := ioutil.ReadFile(getAlternatesFilePath(gitDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", getAlternatesFilePath(gitDir), err)
+	}
+
+	var res []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			res = append(res, line)
+		}
+	}
+
+	return res, nil
+}
+
+// isAlternatesValid checks that all alternate objects dirs of the repository exist, the repository cannot be used otherwise.
+func isAlternatesValid(gitDir string) (bool, error) {
+	alternates, err := readAlternates(gitDir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, objectsDir := range alternates {
+		if !filepath.IsAbs(objectsDir) {
+			objectsDir = filepath.Join(gitDir, "objects", objectsDir)
+		}
+
+		if _, err := os.Stat(objectsDir); os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("error accessing %s: %s", objectsDir, err)
+		}
+	}
+
+	return true, nil
+}
+
+func ensureSharedObjectsRepo(ctx context.Context) error {
+	if _, err := os.Stat(GetSharedObjectsDir()); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error accessing %s: %s", GetSharedObjectsDir(), err)
+	}
+
+	tmpPath := fmt.Sprintf("%s.tmp", GetSharedObjectsDir())
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return fmt.Errorf("unable to prepare tmp path %s: failed to remove: %s", tmpPath, err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	if err := true_git.InitBare(ctx, tmpPath); err != nil {
+		return fmt.Errorf("unable to init shared objects repo: %s", err)
+	}
+
+	if err := os.Rename(tmpPath, GetSharedObjectsDir()); err != nil {
+		return fmt.Errorf("rename %s to %s failed: %s", tmpPath, GetSharedObjectsDir(), err)
+	}
+
+	return nil
+}
+
+func ensureSharedObjectsAlternate(gitDir string) error {
+	objectsDir := filepath.Join(GetSharedObjectsDir(), "objects")
+
+	alternates, err := readAlternates(gitDir)
+	if err != nil {
+		return err
+	}
+
+	for _, alternate := range alternates {
+		if alternate == objectsDir {
+			return nil
+		}
+	}
+
+	alternates = append(alternates, objectsDir)
+	if err := ioutil.WriteFile(getAlternatesFilePath(gitDir), []byte(strings.Join(alternates, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("unable to write %s: %s", getAlternatesFilePath(gitDir), err)
+	}
+
+	return nil
+}
+
+// shareCloneObjects moves the clone objects into the shared objects pool.
+// Should be called with the shared GC lock.
+func shareCloneObjects(ctx context.Context, cloneID, gitDir string) error {
+	if !isSharedObjectsEnabled() {
+		return nil
+	}
+
+	return withSharedObjectsLock(ctx, func() error {
+		if err := ensureSharedObjectsRepo(ctx); err != nil {
+			return err
+		}
+
+		if err := true_git.FetchAllRefs(ctx, GetSharedObjectsDir(), gitDir, getSharedObjectsCloneRefsNamespace(cloneID)); err != nil {
+			return fmt.Errorf("unable to fetch objects into shared objects repo: %s", err)
+		}
+
+		if err := ensureSharedObjectsAlternate(gitDir); err != nil {
+			return err
+		}
+
+		if err := true_git.RepackLocal(ctx, gitDir); err != nil {
+			return fmt.Errorf("unable to repack %s: %s", gitDir, err)
+		}
+
+		return nil
+	})
+}
+
+// PruneSharedObjects removes the refs of the removed clones from the shared objects pool and prunes the objects not used anymore.
+// Should be called with the exclusive GC lock.
+func PruneSharedObjects(ctx context.Context) error {
+	if _, err := os.Stat(GetSharedObjectsDir()); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error accessing %s: %s", GetSharedObjectsDir(), err)
+	}
+
+	return withSharedObjectsLock(ctx, func() error {
+		refs, err := true_git.ListRefs(ctx, GetSharedObjectsDir(), sharedObjectsRefsPrefix)
+		if err != nil {
+			return fmt.Errorf("unable to list shared objects refs: %s", err)
+		}
+
+		var refsToDelete []string
+		for _, ref := range refs {
+			cloneID := strings.SplitN(strings.TrimPrefix(ref, sharedObjectsRefsPrefix+"/"), "/", 2)[0]
+
+			if _, err := os.Stat(filepath.Join(GetGitRepoCacheDir(), cloneID)); os.IsNotExist(err) {
+				refsToDelete = append(refsToDelete, ref)
+			} else if err != nil {
+				return fmt.Errorf("error accessing clone %s: %s", cloneID, err)
+			}
+		}
+
+		if len(refsToDelete) == 0 {
+			return nil
+		}
+
+		logboek.Context(ctx).Default().LogF("Pruning shared git objects of %d removed refs\n", len(refsToDelete))
+
+		if err := true_git.DeleteRefs(ctx, GetSharedObjectsDir(), refsToDelete); err != nil {
+			return fmt.Errorf("unable to delete shared objects refs: %s", err)
+		}
+
+		if err := true_git.GC(ctx, GetSharedObjectsDir()); err != nil {
+			return fmt.Errorf("unable to gc shared objects repo: %s", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,161 @@
+package git_repo
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type testGitDataManager struct {
+	GitDataManager
+}
+
+func (m *testGitDataManager) LockGC(ctx context.Context, shared bool) (lockgate.LockHandle, error) {
+	_, lock, err := werf.AcquireHostLock(ctx, "git_data_manager", lockgate.AcquireOptions{Shared: shared})
+	return lock, err
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=werf", "GIT_AUTHOR_EMAIL=werf@example.com",
+		"GIT_COMMITTER_NAME=werf", "GIT_COMMITTER_EMAIL=werf@example.com",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %s\n%s", args, err, output)
+	}
+
+	return string(output)
+}
+
+func initSharedObjectsTest(t *testing.T, sharedObjects bool) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	if err := werf.Init(filepath.Join(tmpDir, "tmp"), filepath.Join(tmpDir, "home"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := true_git.Init(true_git.Options{SharedObjects: sharedObjects}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(&testGitDataManager{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sourceDir := filepath.Join(tmpDir, "source")
+	if err := os.MkdirAll(sourceDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, sourceDir, "init", "--quiet")
+	runGit(t, sourceDir, "commit", "--quiet", "--allow-empty", "-m", "Initial commit")
+
+	return sourceDir
+}
+
+func TestRemoteSharedObjects(t *testing.T) {
+	ctx := context.Background()
+	sourceDir := initSharedObjectsTest(t, true)
+
+	repo, err := OpenRemoteRepo("first", sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CloneAndFetch(ctx); err != nil {
+		t.Fatalf("unable to clone: %s", err)
+	}
+
+	alternates, err := readAlternates(repo.GetClonePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alternates) != 1 || alternates[0] != filepath.Join(GetSharedObjectsDir(), "objects") {
+		t.Fatalf("unexpected clone alternates: %v", alternates)
+	}
+
+	refs, err := true_git.ListRefs(ctx, GetSharedObjectsDir(), getSharedObjectsCloneRefsNamespace(repo.getRepoID()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) == 0 {
+		t.Fatalf("expected the clone refs in the shared objects repo")
+	}
+
+	// The repo with the other name and the same url uses the same clone
+	otherRepo, err := OpenRemoteRepo("second", sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isCloned, err := otherRepo.Clone(ctx); err != nil {
+		t.Fatal(err)
+	} else if isCloned {
+		t.Fatalf("expected the existing clone to be used")
+	}
+
+	// The clone borrowing objects from the removed pool is cloned again
+	if err := os.RemoveAll(GetSharedObjectsDir()); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := repo.isCloneExists(); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatalf("expected the broken clone not to be used")
+	}
+	if isCloned, err := repo.Clone(ctx); err != nil {
+		t.Fatalf("unable to clone again: %s", err)
+	} else if !isCloned {
+		t.Fatalf("expected the broken clone to be cloned again")
+	}
+	if isValid, err := isAlternatesValid(repo.GetClonePath()); err != nil {
+		t.Fatal(err)
+	} else if !isValid {
+		t.Fatalf("expected the valid alternates of the new clone")
+	}
+
+	// The refs of the removed clone are pruned
+	if err := os.RemoveAll(repo.GetClonePath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := PruneSharedObjects(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if refs, err := true_git.ListRefs(ctx, GetSharedObjectsDir(), sharedObjectsRefsPrefix); err != nil {
+		t.Fatal(err)
+	} else if len(refs) != 0 {
+		t.Fatalf("expected no refs after prune, got %v", refs)
+	}
+}
+
+func TestRemoteSharedObjectsDisabled(t *testing.T) {
+	ctx := context.Background()
+	sourceDir := initSharedObjectsTest(t, false)
+
+	repo, err := OpenRemoteRepo("repo", sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CloneAndFetch(ctx); err != nil {
+		t.Fatalf("unable to clone: %s", err)
+	}
+
+	if alternates, err := readAlternates(repo.GetClonePath()); err != nil {
+		t.Fatal(err)
+	} else if len(alternates) != 0 {
+		t.Fatalf("unexpected clone alternates: %v", alternates)
+	}
+
+	if _, err := os.Stat(GetSharedObjectsDir()); !os.IsNotExist(err) {
+		t.Fatalf("expected no shared objects repo, got %v", err)
+	}
+}
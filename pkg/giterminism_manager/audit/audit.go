@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Record is the place in the project which requires loosening giterminism.
+type Record struct {
+	// Message describes the place, e.g. env name "CI_JOB_ID" used in werf.yaml
+	Message string
+	// Directive is the werf-giterminism.yaml directive which allows the place (e.g. config.goTemplateRendering.allowEnvVariables), empty if the place cannot be allowed
+	Directive string
+	// Value is the value to add to the list Directive, empty if the Directive is bool
+	Value string
+}
+
+type Audit struct {
+	mux     sync.Mutex
+	records []Record
+}
+
+func NewAudit() *Audit {
+	return &Audit{}
+}
+
+func (a *Audit) Add(record Record) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	for _, r := range a.records {
+		if r == record {
+			return
+		}
+	}
+
+	a.records = append(a.records, record)
+}
+
+func (a *Audit) Records() []Record {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	res := append([]Record{}, a.records...)
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Directive != res[j].Directive {
+			return res[i].Directive < res[j].Directive
+		}
+		return res[i].Value < res[j].Value
+	})
+
+	return res
+}
+
+// GiterminismConfig returns the werf-giterminism.yaml, which allows all recorded places.
+func (a *Audit) GiterminismConfig() ([]byte, error) {
+	root := yaml.MapSlice{{Key: "giterminismConfigVersion", Value: 1}}
+
+	for _, record := range a.Records() {
+		if record.Directive == "" {
+			continue
+		}
+
+		var err error
+		if root, err = setDirective(root, strings.Split(record.Directive, "."), record.Value); err != nil {
+			return nil, fmt.Errorf("unable to set directive %q: %s", record.Directive, err)
+		}
+	}
+
+	return yaml.Marshal(root)
+}
+
+func setDirective(section yaml.MapSlice, path []string, value string) (yaml.MapSlice, error) {
+	ind := -1
+	for i, item := range section {
+		if item.Key == path[0] {
+			ind = i
+			break
+		}
+	}
+
+	if len(path) == 1 {
+		if value == "" {
+			if ind == -1 {
+				section = append(section, yaml.MapItem{Key: path[0], Value: true})
+			}
+
+			return section, nil
+		}
+
+		if ind == -1 {
+			return append(section, yaml.MapItem{Key: path[0], Value: []string{value}}), nil
+		}
+
+		values, ok := section[ind].Value.([]string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value type %T", section[ind].Value)
+		}
+
+		for _, v := range values {
+			if v == value {
+				return section, nil
+			}
+		}
+		section[ind].Value = append(values, value)
+
+		return section, nil
+	}
+
+	var subsection yaml.MapSlice
+	if ind == -1 {
+		section = append(section, yaml.MapItem{Key: path[0]})
+		ind = len(section) - 1
+	} else if s, ok := section[ind].Value.(yaml.MapSlice); ok {
+		subsection = s
+	} else {
+		return nil, fmt.Errorf("unexpected value type %T", section[ind].Value)
+	}
+
+	subsection, err := setDirective(subsection, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	section[ind].Value = subsection
+
+	return section, nil
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestAudit_Records(t *testing.T) {
+	a := NewAudit()
+
+	var wg sync.WaitGroup
+	for _, record := range []Record{
+		{Message: "env name \"B\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "B"},
+		{Message: "fromLatest directive used in werf.yaml", Directive: "config.stapel.allowFromLatest"},
+		{Message: "env name \"A\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "A"},
+		{Message: "uncommitted file \"file\" used in the build context (the file must be committed)"},
+		{Message: "env name \"B\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "B"},
+	} {
+		wg.Add(1)
+		go func(record Record) {
+			defer wg.Done()
+			a.Add(record)
+		}(record)
+	}
+	wg.Wait()
+
+	expected := []Record{
+		{Message: "uncommitted file \"file\" used in the build context (the file must be committed)"},
+		{Message: "env name \"A\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "A"},
+		{Message: "env name \"B\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "B"},
+		{Message: "fromLatest directive used in werf.yaml", Directive: "config.stapel.allowFromLatest"},
+	}
+
+	if records := a.Records(); !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+}
+
+func TestAudit_GiterminismConfig(t *testing.T) {
+	a := NewAudit()
+	a.Add(Record{Message: "uncommitted file \"werf.yaml\" used", Directive: "config.allowUncommitted"})
+	a.Add(Record{Message: "uncommitted file \"werf.yaml\" used again", Directive: "config.allowUncommitted"})
+	a.Add(Record{Message: "env name \"A\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "A"})
+	a.Add(Record{Message: "env name \"A\" used in werf.yaml again", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "A"})
+	a.Add(Record{Message: "env name \"B\" used in werf.yaml", Directive: "config.goTemplateRendering.allowEnvVariables", Value: "B"})
+	a.Add(Record{Message: "fromLatest directive used in werf.yaml", Directive: "config.stapel.allowFromLatest"})
+	a.Add(Record{Message: "symlink \"link\" points outside the git work tree"})
+
+	data, err := a.GiterminismConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `giterminismConfigVersion: 1
+config:
+  allowUncommitted: true
+  goTemplateRendering:
+    allowEnvVariables:
+    - A
+    - B
+  stapel:
+    allowFromLatest: true
+`
+	if string(data) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
+
+func TestAudit_GiterminismConfig_ConflictingDirectives(t *testing.T) {
+	a := NewAudit()
+	a.Add(Record{Message: "bool", Directive: "config.stapel"})
+	a.Add(Record{Message: "nested", Directive: "config.stapel.allowFromLatest"})
+
+	if _, err := a.GiterminismConfig(); err == nil {
+		t.Fatal("expected error for the directive nested in the bool one")
+	}
+}
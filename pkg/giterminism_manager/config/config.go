@@ -45,6 +45,11 @@ type fileReader interface {
 type Config struct {
 	Config config `json:"config"`
 	Helm   helm   `json:"helm"`
+	SSH    ssh    `json:"ssh"`
+}
+
+func (c Config) SSHKnownHosts() []string {
+	return c.SSH.KnownHosts
 }
 
 func (c Config) IsUncommittedConfigAccepted() bool {
@@ -63,18 +68,42 @@ func (c Config) IsConfigGoTemplateRenderingEnvNameAccepted(envName string) (bool
 	return c.Config.GoTemplateRendering.IsEnvNameAccepted(envName)
 }
 
+func (c Config) IsConfigGoTemplateRenderingDigestedEnvNameAccepted(envName string) (bool, error) {
+	return c.Config.GoTemplateRendering.IsDigestedEnvNameAccepted(envName)
+}
+
 func (c Config) IsConfigStapelFromLatestAccepted() bool {
 	return c.Config.Stapel.AllowFromLatest
 }
 
+func (c Config) IsConfigStapelImportFromWithoutDigestAccepted() bool {
+	return c.Config.Stapel.Import.AllowFromWithoutDigest
+}
+
 func (c Config) IsConfigStapelGitBranchAccepted() bool {
 	return c.Config.Stapel.Git.AllowBranch
 }
 
+func (c Config) IsConfigIncludeRemoteAccepted() bool {
+	return c.Config.Include.AllowRemote
+}
+
+func (c Config) IsConfigIncludeBranchAccepted() bool {
+	return c.Config.Include.AllowBranch
+}
+
+func (c Config) IsConfigDependencyLatestAccepted() bool {
+	return c.Config.Dependencies.AllowLatest
+}
+
 func (c Config) IsConfigStapelMountBuildDirAccepted() bool {
 	return c.Config.Stapel.Mount.AllowBuildDir
 }
 
+func (c Config) IsConfigStapelMountVolumeAccepted(name string) bool {
+	return c.Config.Stapel.Mount.IsVolumeAccepted(name)
+}
+
 func (c Config) IsConfigStapelMountFromPathAccepted(fromPath string) bool {
 	return c.Config.Stapel.Mount.IsFromPathAccepted(fromPath)
 }
@@ -91,6 +120,10 @@ func (c Config) IsUncommittedDockerignoreAccepted(relPath string) bool {
 	return c.Config.Dockerfile.IsUncommittedDockerignoreAccepted(relPath)
 }
 
+func (c Config) IsUncommittedStapelScriptAccepted(relPath string) bool {
+	return c.Config.Stapel.IsUncommittedScriptAccepted(relPath)
+}
+
 func (c Config) UncommittedHelmFilePathMatcher() path_matcher.PathMatcher {
 	return c.Helm.UncommittedHelmFilePathMatcher()
 }
@@ -101,6 +134,8 @@ type config struct {
 	GoTemplateRendering       goTemplateRendering `json:"goTemplateRendering"`
 	Stapel                    stapel              `json:"stapel"`
 	Dockerfile                dockerfile          `json:"dockerfile"`
+	Include                   include             `json:"include"`
+	Dependencies              dependencies        `json:"dependencies"`
 }
 
 func (c config) UncommittedTemplateFilePathMatcher() path_matcher.PathMatcher {
@@ -108,36 +143,17 @@ func (c config) UncommittedTemplateFilePathMatcher() path_matcher.PathMatcher {
 }
 
 type goTemplateRendering struct {
-	AllowEnvVariables     []string `json:"allowEnvVariables"`
-	AllowUncommittedFiles []string `json:"allowUncommittedFiles"`
+	AllowEnvVariables         []string `json:"allowEnvVariables"`
+	AllowDigestedEnvVariables []string `json:"allowDigestedEnvVariables"`
+	AllowUncommittedFiles     []string `json:"allowUncommittedFiles"`
 }
 
 func (r goTemplateRendering) IsEnvNameAccepted(name string) (bool, error) {
-	for _, pattern := range r.AllowEnvVariables {
-		match, err := func() (bool, error) {
-			if strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
-				expr := fmt.Sprintf("^%s$", pattern[1:len(pattern)-1])
-				r, err := regexp.Compile(expr)
-				if err != nil {
-					return false, err
-				}
-
-				return r.MatchString(name), nil
-			} else {
-				return pattern == name, nil
-			}
-		}()
-
-		if err != nil {
-			return false, err
-		}
-
-		if match {
-			return true, nil
-		}
-	}
+	return isEnvNameMatched(r.AllowEnvVariables, name)
+}
 
-	return false, nil
+func (r goTemplateRendering) IsDigestedEnvNameAccepted(name string) (bool, error) {
+	return isEnvNameMatched(r.AllowDigestedEnvVariables, name)
 }
 
 func (r goTemplateRendering) UncommittedFilePathMatcher() path_matcher.PathMatcher {
@@ -145,24 +161,39 @@ func (r goTemplateRendering) UncommittedFilePathMatcher() path_matcher.PathMatch
 }
 
 type stapel struct {
-	AllowFromLatest bool  `json:"allowFromLatest"`
-	Git             git   `json:"git"`
-	Mount           mount `json:"mount"`
+	AllowFromLatest         bool     `json:"allowFromLatest"`
+	Git                     git      `json:"git"`
+	Mount                   mount    `json:"mount"`
+	Import                  import_  `json:"import"`
+	AllowUncommittedScripts []string `json:"allowUncommittedScripts"`
+}
+
+func (s stapel) IsUncommittedScriptAccepted(path string) bool {
+	return isPathMatched(s.AllowUncommittedScripts, path)
 }
 
 type git struct {
 	AllowBranch bool `json:"allowBranch"`
 }
 
+type import_ struct {
+	AllowFromWithoutDigest bool `json:"allowFromWithoutDigest"`
+}
+
 type mount struct {
 	AllowBuildDir  bool     `json:"allowBuildDir"`
 	AllowFromPaths []string `json:"allowFromPaths"`
+	AllowVolumes   []string `json:"allowVolumes"`
 }
 
 func (m mount) IsFromPathAccepted(path string) bool {
 	return isPathMatched(m.AllowFromPaths, path)
 }
 
+func (m mount) IsVolumeAccepted(name string) bool {
+	return isPathMatched(m.AllowVolumes, name)
+}
+
 type dockerfile struct {
 	AllowUncommitted                  []string `json:"allowUncommitted"`
 	AllowUncommittedDockerignoreFiles []string `json:"allowUncommittedDockerignoreFiles"`
@@ -181,6 +212,15 @@ func (d dockerfile) IsUncommittedDockerignoreAccepted(path string) bool {
 	return isPathMatched(d.AllowUncommittedDockerignoreFiles, path)
 }
 
+type include struct {
+	AllowRemote bool `json:"allowRemote"`
+	AllowBranch bool `json:"allowBranch"`
+}
+
+type dependencies struct {
+	AllowLatest bool `json:"allowLatest"`
+}
+
 type helm struct {
 	AllowUncommittedFiles []string `json:"allowUncommittedFiles"`
 }
@@ -189,6 +229,10 @@ func (h helm) UncommittedHelmFilePathMatcher() path_matcher.PathMatcher {
 	return pathMatcher(h.AllowUncommittedFiles)
 }
 
+type ssh struct {
+	KnownHosts []string `json:"knownHosts"`
+}
+
 func isPathMatched(patterns []string, p string) bool {
 	return pathMatcher(patterns).IsPathMatched(p)
 }
@@ -200,3 +244,31 @@ func pathMatcher(patterns []string) path_matcher.PathMatcher {
 		return path_matcher.NewFalsePathMatcher()
 	}
 }
+
+func isEnvNameMatched(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		match, err := func() (bool, error) {
+			if strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+				expr := fmt.Sprintf("^%s$", pattern[1:len(pattern)-1])
+				r, err := regexp.Compile(expr)
+				if err != nil {
+					return false, err
+				}
+
+				return r.MatchString(name), nil
+			} else {
+				return pattern == name, nil
+			}
+		}()
+
+		if err != nil {
+			return false, err
+		}
+
+		if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
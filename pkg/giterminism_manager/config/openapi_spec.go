@@ -29,6 +29,8 @@ properties:
     $ref: '#/definitions/Config'
   helm:
     $ref: '#/definitions/Helm'
+  ssh:
+    $ref: '#/definitions/SSH'
 definitions:
   Config:
     type: object
@@ -46,6 +48,10 @@ definitions:
         $ref: '#/definitions/ConfigStapel'
       dockerfile:
         $ref: '#/definitions/ConfigDockerfile'
+      include:
+        $ref: '#/definitions/ConfigInclude'
+      dependencies:
+        $ref: '#/definitions/ConfigDependencies'
   ConfigGoTemplateRendering:
     type: object
     additionalProperties: {}
@@ -54,6 +60,10 @@ definitions:
         type: array
         items:
           type: string
+      allowDigestedEnvVariables:
+        type: array
+        items:
+          type: string
       allowUncommittedFiles:
         type: array
         items:
@@ -68,6 +78,18 @@ definitions:
         $ref: '#/definitions/ConfigStapelGit'
       mount:
         $ref: '#/definitions/ConfigStapelMount'
+      import:
+        $ref: '#/definitions/ConfigStapelImport'
+      allowUncommittedScripts:
+        type: array
+        items:
+          type: string
+  ConfigStapelImport:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowFromWithoutDigest:
+        type: boolean
   ConfigStapelGit:
     type: object
     additionalProperties: {}
@@ -84,6 +106,10 @@ definitions:
         type: array
         items:
           type: string
+      allowVolumes:
+        type: array
+        items:
+          type: string
   ConfigDockerfile:
     type: object
     additionalProperties: {}
@@ -100,6 +126,20 @@ definitions:
         type: array
         items:
           type: string
+  ConfigInclude:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowRemote:
+        type: boolean
+      allowBranch:
+        type: boolean
+  ConfigDependencies:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowLatest:
+        type: boolean
   Helm:
     type: object
     additionalProperties: {}
@@ -108,6 +148,14 @@ definitions:
         type: array
         items:
           type: string
+  SSH:
+    type: object
+    additionalProperties: {}
+    properties:
+      knownHosts:
+        type: array
+        items:
+          type: string
 `
 )
 
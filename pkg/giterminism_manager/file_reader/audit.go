@@ -0,0 +1,60 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
+)
+
+// withAuditListDirective returns the file reader, which records the uncommitted files in the audit mode as the values of the list directive.
+func (r FileReader) withAuditListDirective(directive string) FileReader {
+	r.auditDirective = directive
+	r.auditDirectiveIsBool = false
+	return r
+}
+
+// withAuditBoolDirective returns the file reader, which records the uncommitted files in the audit mode as requiring the bool directive.
+func (r FileReader) withAuditBoolDirective(directive string) FileReader {
+	r.auditDirective = directive
+	r.auditDirectiveIsBool = true
+	return r
+}
+
+func (r FileReader) auditUncommittedFile(relPath string) {
+	record := audit.Record{
+		Message:   fmt.Sprintf("uncommitted file %q used", filepath.ToSlash(relPath)),
+		Directive: r.auditDirective,
+	}
+
+	if r.auditDirective == "" {
+		record.Message += " (the file must be committed)"
+	} else if !r.auditDirectiveIsBool {
+		record.Value = filepath.ToSlash(relPath)
+	}
+
+	r.sharedOptions.Audit().Add(record)
+}
+
+func (r FileReader) auditSymlinkOutsideWorkTree(relPath, link string) {
+	r.sharedOptions.Audit().Add(audit.Record{
+		Message: fmt.Sprintf("symlink %q points to %q outside the git work tree (the link target must be inside the git work tree)", filepath.ToSlash(relPath), link),
+	})
+}
+
+// readAuditedUncommittedConfigurationFile reads the uncommitted file from the project directory, the file deleted in the project directory is read from the commit.
+func (r FileReader) readAuditedUncommittedConfigurationFile(ctx context.Context, relPath string) ([]byte, error) {
+	r.auditUncommittedFile(relPath)
+
+	exist, err := r.IsRegularFileExist(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if exist {
+		return r.ReadFile(ctx, relPath)
+	}
+
+	return r.ReadCommitFile(ctx, relPath)
+}
@@ -0,0 +1,59 @@
+package file_reader
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
+)
+
+type testSharedOptions struct {
+	audit *audit.Audit
+}
+
+func (o testSharedOptions) ProjectDir() string              { return "" }
+func (o testSharedOptions) RelativeToGitProjectDir() string { return "" }
+func (o testSharedOptions) LocalGitRepo() *git_repo.Local   { return nil }
+func (o testSharedOptions) HeadCommit() string              { return "" }
+func (o testSharedOptions) LooseGiterminism() bool          { return false }
+func (o testSharedOptions) Dev() bool                       { return false }
+func (o testSharedOptions) Audit() *audit.Audit             { return o.audit }
+
+func TestFileReader_AuditUncommittedFile(t *testing.T) {
+	a := audit.NewAudit()
+	r := NewFileReader(testSharedOptions{audit: a})
+
+	r.withAuditBoolDirective("config.allowUncommitted").auditUncommittedFile("werf.yaml")
+	r.withAuditListDirective("config.dockerfile.allowUncommitted").auditUncommittedFile("dir/Dockerfile")
+	r.auditUncommittedFile("file")
+	r.auditSymlinkOutsideWorkTree("link", "/outside")
+
+	expected := []audit.Record{
+		{Message: `uncommitted file "file" used (the file must be committed)`},
+		{Message: `symlink "link" points to "/outside" outside the git work tree (the link target must be inside the git work tree)`},
+		{Message: `uncommitted file "werf.yaml" used`, Directive: "config.allowUncommitted"},
+		{Message: `uncommitted file "dir/Dockerfile" used`, Directive: "config.dockerfile.allowUncommitted", Value: "dir/Dockerfile"},
+	}
+	if records := a.Records(); !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+}
+
+func TestFileReader_WithAuditDirective(t *testing.T) {
+	r := NewFileReader(testSharedOptions{})
+
+	listReader := r.withAuditListDirective("config.allowUncommittedTemplates")
+	if listReader.auditDirective != "config.allowUncommittedTemplates" || listReader.auditDirectiveIsBool {
+		t.Fatalf("unexpected list directive reader %q %v", listReader.auditDirective, listReader.auditDirectiveIsBool)
+	}
+
+	boolReader := listReader.withAuditBoolDirective("config.allowUncommitted")
+	if boolReader.auditDirective != "config.allowUncommitted" || !boolReader.auditDirectiveIsBool {
+		t.Fatalf("unexpected bool directive reader %q %v", boolReader.auditDirective, boolReader.auditDirectiveIsBool)
+	}
+
+	if r.auditDirective != "" || listReader.auditDirective != "config.allowUncommittedTemplates" {
+		t.Fatal("expected the file reader copies not to affect each other")
+	}
+}
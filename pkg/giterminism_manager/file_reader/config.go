@@ -69,7 +69,7 @@ func (r FileReader) readConfig(ctx context.Context, customRelPath string) (strin
 	configRelPathList := r.configPathList(customRelPath)
 
 	for _, configPath := range configRelPathList {
-		data, err := r.ReadAndCheckConfigurationFile(ctx, configPath, func(_ string) bool {
+		data, err := r.withAuditBoolDirective("config.allowUncommitted").ReadAndCheckConfigurationFile(ctx, configPath, func(_ string) bool {
 			return r.giterminismConfig.IsUncommittedConfigAccepted()
 		})
 		if err != nil {
@@ -9,7 +9,7 @@ import (
 func (r FileReader) ConfigGoTemplateFilesGlob(ctx context.Context, glob string) (map[string]interface{}, error) {
 	result := map[string]interface{}{}
 
-	if err := r.WalkConfigurationFilesWithGlob(
+	if err := r.withAuditListDirective("config.goTemplateRendering.allowUncommittedFiles").WalkConfigurationFilesWithGlob(
 		ctx,
 		"",
 		glob,
@@ -31,7 +31,7 @@ func (r FileReader) ConfigGoTemplateFilesGlob(ctx context.Context, glob string)
 }
 
 func (r FileReader) ConfigGoTemplateFilesGet(ctx context.Context, relPath string) ([]byte, error) {
-	data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedConfigGoTemplateRenderingFilePathMatcher().IsPathMatched)
+	data, err := r.withAuditListDirective("config.goTemplateRendering.allowUncommittedFiles").ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedConfigGoTemplateRenderingFilePathMatcher().IsPathMatched)
 	if err != nil {
 		return nil, fmt.Errorf("{{ .Files.Get %q }}: %s", relPath, err)
 	}
@@ -0,0 +1,32 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/types"
+)
+
+func (r FileReader) ReadConfigInclude(ctx context.Context, relPath string) (data []byte, err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("ReadConfigInclude %q", relPath).
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			data, err = r.withAuditListDirective("config.allowUncommittedTemplates").ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedConfigTemplateFilePathMatcher().IsPathMatched)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("dataLength: %v\nerr: %q\n", len(data), err)
+			}
+		})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read werf config include %q: %s", relPath, err)
+	}
+
+	return data, nil
+}
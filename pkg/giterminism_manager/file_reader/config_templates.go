@@ -40,7 +40,7 @@ func (r FileReader) readConfigTemplateFiles(ctx context.Context, customDirRelPat
 		templatesDirRelPath = customDirRelPath
 	}
 
-	return r.WalkConfigurationFilesWithGlob(
+	return r.withAuditListDirective("config.allowUncommittedTemplates").WalkConfigurationFilesWithGlob(
 		ctx,
 		templatesDirRelPath,
 		"**/*.tmpl",
@@ -110,6 +110,13 @@ func (r FileReader) ReadAndCheckConfigurationFile(ctx context.Context, relPath s
 
 func (r FileReader) readAndCheckConfigurationFile(ctx context.Context, relPath string, isFileAcceptedCheckFunc func(relPath string) bool) ([]byte, error) {
 	if err := r.CheckConfigurationFileExistenceAndAcceptance(ctx, relPath, isFileAcceptedCheckFunc); err != nil {
+		if r.sharedOptions.Audit() != nil {
+			switch err.(type) {
+			case UncommittedFilesError, UntrackedFilesError:
+				return r.readAuditedUncommittedConfigurationFile(ctx, relPath)
+			}
+		}
+
 		return nil, err
 	}
 
@@ -4,12 +4,17 @@ import (
 	"os"
 
 	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 	"github.com/werf/werf/pkg/path_matcher"
 )
 
 type FileReader struct {
 	sharedOptions     sharedOptions
 	giterminismConfig giterminismConfig
+
+	// the giterminism config directive to record the uncommitted files in the audit mode
+	auditDirective       string
+	auditDirectiveIsBool bool
 }
 
 func (r *FileReader) SetGiterminismConfig(giterminismConfig giterminismConfig) {
@@ -26,6 +31,7 @@ type giterminismConfig interface {
 	UncommittedConfigGoTemplateRenderingFilePathMatcher() path_matcher.PathMatcher
 	IsUncommittedDockerfileAccepted(relPath string) bool
 	IsUncommittedDockerignoreAccepted(relPath string) bool
+	IsUncommittedStapelScriptAccepted(relPath string) bool
 	UncommittedHelmFilePathMatcher() path_matcher.PathMatcher
 }
 
@@ -36,6 +42,7 @@ type sharedOptions interface {
 	HeadCommit() string
 	LooseGiterminism() bool
 	Dev() bool
+	Audit() *audit.Audit
 }
 
 func debug() bool {
@@ -230,6 +230,11 @@ func (r FileReader) skipFileFunc(acceptedFilePathMatcher path_matcher.PathMatche
 		pathsToCheck := []string{existingRelPath}
 		resolvedFilePath, err := r.ResolveFilePath(ctx, existingRelPath)
 		if err != nil {
+			// the unresolvable symlinks (e.g. pointing outside the git work tree) are skipped in the audit mode, the audit should go on
+			if r.sharedOptions.Audit() != nil && IsFileNotFoundInProjectDirectoryError(err) {
+				return true, nil
+			}
+
 			return false, err
 		}
 
@@ -574,6 +579,10 @@ func (r FileReader) resolveFilePath(ctx context.Context, relPath string, depth i
 			}
 
 			if !r.isSubpathOfWorkTreeDir(resolvedLink) {
+				if r.sharedOptions.Audit() != nil {
+					r.auditSymlinkOutsideWorkTree(pathToResolve, link)
+				}
+
 				return "", r.NewFileNotFoundInProjectDirectoryError(resolvedLink)
 			}
 
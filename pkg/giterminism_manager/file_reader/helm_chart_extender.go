@@ -45,7 +45,7 @@ func (r FileReader) ReadChartFile(ctx context.Context, path string) ([]byte, err
 }
 
 func (r FileReader) readChartFile(ctx context.Context, relPath string) ([]byte, error) {
-	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedHelmFilePathMatcher().IsPathMatched)
+	return r.withAuditListDirective("helm.allowUncommittedFiles").ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedHelmFilePathMatcher().IsPathMatched)
 }
 
 func (r FileReader) LoadChartDir(ctx context.Context, chartDir string) ([]*chart.ChartExtenderBufferedFile, error) {
@@ -63,7 +63,7 @@ func (r FileReader) LoadChartDir(ctx context.Context, chartDir string) ([]*chart
 func (r FileReader) loadChartDir(ctx context.Context, relDir string) ([]*chart.ChartExtenderBufferedFile, error) {
 	var res []*chart.ChartExtenderBufferedFile
 
-	if err := r.WalkConfigurationFilesWithGlob(
+	if err := r.withAuditListDirective("helm.allowUncommittedFiles").WalkConfigurationFilesWithGlob(
 		ctx,
 		relDir,
 		"**/*",
@@ -84,3 +84,19 @@ func (r FileReader) loadChartDir(ctx context.Context, relDir string) ([]*chart.C
 
 	return res, nil
 }
+
+// LoadKustomizationDir loads the files of the kustomization dir (--post-renderer-kustomize-dir) with the same giterminism rules as the chart files.
+func (r FileReader) LoadKustomizationDir(ctx context.Context, dir string) ([]*chart.ChartExtenderBufferedFile, error) {
+	relDir := r.absolutePathToProjectDirRelativePath(dir)
+
+	files, err := r.loadChartDir(ctx, relDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kustomization directory: %s", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("the directory %q not found in the project git repository", relDir)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,37 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/types"
+)
+
+func (r FileReader) ReadStapelScript(ctx context.Context, relPath string) (data []byte, err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("ReadStapelScript %q", relPath).
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			data, err = r.readStapelScript(ctx, relPath)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("dataLength: %d\nerr: %q\n", len(data), err)
+			}
+		})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read script %q: %s", filepath.ToSlash(relPath), err)
+	}
+
+	return data, nil
+}
+
+func (r FileReader) readStapelScript(ctx context.Context, relPath string) ([]byte, error) {
+	return r.withAuditListDirective("config.stapel.allowUncommittedScripts").ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.IsUncommittedStapelScriptAccepted)
+}
@@ -2,7 +2,10 @@ package inspector
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 	"github.com/werf/werf/pkg/path_matcher"
 )
 
@@ -11,5 +14,18 @@ func (i Inspector) InspectBuildContextFiles(ctx context.Context, matcher path_ma
 		return nil
 	}
 
+	if a := i.sharedOptions.Audit(); a != nil {
+		relPaths, err := i.fileReader.StatusPathList(ctx, matcher)
+		if err != nil {
+			return err
+		}
+
+		for _, relPath := range relPaths {
+			a.Add(audit.Record{Message: fmt.Sprintf("uncommitted file %q used in the build context (the file must be committed)", filepath.ToSlash(relPath))})
+		}
+
+		return nil
+	}
+
 	return i.fileReader.ValidateStatusResult(ctx, matcher)
 }
@@ -0,0 +1,15 @@
+package inspector
+
+import "github.com/werf/werf/pkg/giterminism_manager/audit"
+
+func (i Inspector) InspectConfigDependencyLatest() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigDependencyLatestAccepted() {
+		return nil
+	}
+
+	return i.auditOrError(audit.Record{Message: "dependency without commit directive used in werf.yaml", Directive: "config.dependencies.allowLatest"}, NewExternalDependencyFoundError(`dependency without commit directive not allowed by giterminism
+
+The dependency without commit is resolved to the latest image built by the dependency project. The new build of the dependency project changes the rendered werf config and thus may change the images and make all previously built images unusable.
+
+As an alternative, we recommend pinning the dependency to the commit of the dependency project to guarantee the application's controllable and predictable life cycle.`))
+}
@@ -3,6 +3,8 @@ package inspector
 import (
 	"context"
 	"fmt"
+
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 )
 
 func (i Inspector) InspectConfigGoTemplateRenderingEnv(ctx context.Context, envName string) error {
@@ -16,7 +18,18 @@ func (i Inspector) InspectConfigGoTemplateRenderingEnv(ctx context.Context, envN
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(fmt.Sprintf(`env name %q not allowed by giterminism
+	if isDigested, err := i.IsConfigGoTemplateRenderingEnvDigested(envName); err != nil {
+		return err
+	} else if isDigested {
+		return nil
+	}
+
+	return i.auditOrError(audit.Record{Message: fmt.Sprintf("env name %q used in werf.yaml", envName), Directive: "config.goTemplateRendering.allowEnvVariables", Value: envName}, NewExternalDependencyFoundError(fmt.Sprintf(`env name %q not allowed by giterminism
+
+The use of the function env complicates the sharing and reproducibility of the configuration in CI jobs and among developers, because the value of the environment variable affects the final digest of built images.`, envName)))
+}
 
-The use of the function env complicates the sharing and reproducibility of the configuration in CI jobs and among developers, because the value of the environment variable affects the final digest of built images.`, envName))
+// IsConfigGoTemplateRenderingEnvDigested checks whether the value of the environment variable should be recorded and take part in the stages digests.
+func (i Inspector) IsConfigGoTemplateRenderingEnvDigested(envName string) (bool, error) {
+	return i.giterminismConfig.IsConfigGoTemplateRenderingDigestedEnvNameAccepted(envName)
 }
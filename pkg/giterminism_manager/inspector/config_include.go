@@ -0,0 +1,31 @@
+package inspector
+
+import (
+	"fmt"
+
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
+)
+
+func (i Inspector) InspectConfigIncludeRemote(include string) error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigIncludeRemoteAccepted() {
+		return nil
+	}
+
+	return i.auditOrError(audit.Record{Message: fmt.Sprintf("remote include %q used in werf.yaml", include), Directive: "config.include.allowRemote", Value: include}, NewExternalDependencyFoundError(fmt.Sprintf(`remote include %q not allowed by giterminism
+
+The werf config fragment from the remote git repository is not the part of the project repository, so the rendered werf config is not determined by the project commit only and may change with the remote repository (e.g. the moved tag or the rewritten history).
+
+Remote includes should be allowed explicitly, the use of the commit directive is recommended to guarantee the application's controllable and predictable life cycle.`, include)))
+}
+
+func (i Inspector) InspectConfigIncludeBranch() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigIncludeBranchAccepted() {
+		return nil
+	}
+
+	return i.auditOrError(audit.Record{Message: "include branch directive used in werf.yaml", Directive: "config.include.allowBranch"}, NewExternalDependencyFoundError(`include branch directive not allowed by giterminism
+
+Remote include with a branch may break the previous builds' reproducibility. The new commit in the branch changes the included werf config fragments and thus may change the images and make all previously built images unusable.
+
+As an alternative, we recommend using unchangeable reference, tag, or commit to guarantee the application's controllable and predictable life cycle.`))
+}
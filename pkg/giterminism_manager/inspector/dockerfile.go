@@ -3,6 +3,8 @@ package inspector
 import (
 	"fmt"
 	"path/filepath"
+
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 )
 
 func (i Inspector) InspectConfigDockerfileContextAddFile(relPath string) error {
@@ -14,7 +16,7 @@ func (i Inspector) InspectConfigDockerfileContextAddFile(relPath string) error {
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(fmt.Sprintf(`contextAddFile %q not allowed by giterminism
+	return i.auditOrError(audit.Record{Message: fmt.Sprintf("contextAddFile %q used in werf.yaml", filepath.ToSlash(relPath)), Directive: "config.dockerfile.allowContextAddFiles", Value: filepath.ToSlash(relPath)}, NewExternalDependencyFoundError(fmt.Sprintf(`contextAddFile %q not allowed by giterminism
 
-The use of the directive contextAddFiles complicates the sharing and reproducibility of the configuration in CI jobs and among developers because the file data affects the final digest of built images and must be identical at all steps of the pipeline and during local development.`, filepath.ToSlash(relPath)))
+The use of the directive contextAddFiles complicates the sharing and reproducibility of the configuration in CI jobs and among developers because the file data affects the final digest of built images and must be identical at all steps of the pipeline and during local development.`, filepath.ToSlash(relPath))))
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 	"github.com/werf/werf/pkg/path_matcher"
 )
 
@@ -18,17 +19,34 @@ func NewInspector(giterminismConfig giterminismConfig, fileReader fileReader, sh
 	return Inspector{giterminismConfig: giterminismConfig, fileReader: fileReader, sharedOptions: sharedOptions}
 }
 
+// auditOrError records the place requiring loosening giterminism in the audit mode and returns the error otherwise.
+func (i Inspector) auditOrError(record audit.Record, err error) error {
+	if a := i.sharedOptions.Audit(); a != nil {
+		a.Add(record)
+		return nil
+	}
+
+	return err
+}
+
 type giterminismConfig interface {
 	IsConfigGoTemplateRenderingEnvNameAccepted(envName string) (bool, error)
+	IsConfigGoTemplateRenderingDigestedEnvNameAccepted(envName string) (bool, error)
 	IsConfigStapelFromLatestAccepted() bool
 	IsConfigStapelGitBranchAccepted() bool
+	IsConfigStapelImportFromWithoutDigestAccepted() bool
+	IsConfigIncludeRemoteAccepted() bool
+	IsConfigIncludeBranchAccepted() bool
+	IsConfigDependencyLatestAccepted() bool
 	IsConfigStapelMountBuildDirAccepted() bool
+	IsConfigStapelMountVolumeAccepted(name string) bool
 	IsConfigStapelMountFromPathAccepted(fromPath string) bool
 	IsConfigDockerfileContextAddFileAccepted(relPath string) bool
 }
 
 type fileReader interface {
 	ValidateStatusResult(ctx context.Context, pathMatcher path_matcher.PathMatcher) error
+	StatusPathList(ctx context.Context, pathMatcher path_matcher.PathMatcher) ([]string, error)
 }
 
 type sharedOptions interface {
@@ -37,4 +55,5 @@ type sharedOptions interface {
 	HeadCommit() string
 	LooseGiterminism() bool
 	Dev() bool
+	Audit() *audit.Audit
 }
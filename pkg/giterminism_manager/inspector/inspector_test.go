@@ -0,0 +1,140 @@
+package inspector
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+type testSharedOptions struct {
+	looseGiterminism bool
+	audit            *audit.Audit
+}
+
+func (o testSharedOptions) RelativeToGitProjectDir() string { return "" }
+func (o testSharedOptions) LocalGitRepo() *git_repo.Local   { return nil }
+func (o testSharedOptions) HeadCommit() string              { return "" }
+func (o testSharedOptions) LooseGiterminism() bool          { return o.looseGiterminism }
+func (o testSharedOptions) Dev() bool                       { return false }
+func (o testSharedOptions) Audit() *audit.Audit             { return o.audit }
+
+type testFileReader struct {
+	statusPathList []string
+}
+
+var errTestUncommittedFiles = errors.New("uncommitted files found")
+
+func (r testFileReader) ValidateStatusResult(_ context.Context, _ path_matcher.PathMatcher) error {
+	if len(r.statusPathList) != 0 {
+		return errTestUncommittedFiles
+	}
+	return nil
+}
+
+func (r testFileReader) StatusPathList(_ context.Context, _ path_matcher.PathMatcher) ([]string, error) {
+	return r.statusPathList, nil
+}
+
+// testGiterminismConfig does not accept anything.
+type testGiterminismConfig struct{}
+
+func (testGiterminismConfig) IsConfigGoTemplateRenderingEnvNameAccepted(string) (bool, error) {
+	return false, nil
+}
+
+func (testGiterminismConfig) IsConfigGoTemplateRenderingDigestedEnvNameAccepted(string) (bool, error) {
+	return false, nil
+}
+
+func (testGiterminismConfig) IsConfigStapelFromLatestAccepted() bool               { return false }
+func (testGiterminismConfig) IsConfigStapelGitBranchAccepted() bool                { return false }
+func (testGiterminismConfig) IsConfigStapelImportFromWithoutDigestAccepted() bool  { return false }
+func (testGiterminismConfig) IsConfigIncludeRemoteAccepted() bool                  { return false }
+func (testGiterminismConfig) IsConfigIncludeBranchAccepted() bool                  { return false }
+func (testGiterminismConfig) IsConfigDependencyLatestAccepted() bool               { return false }
+func (testGiterminismConfig) IsConfigStapelMountBuildDirAccepted() bool            { return false }
+func (testGiterminismConfig) IsConfigStapelMountVolumeAccepted(string) bool        { return false }
+func (testGiterminismConfig) IsConfigStapelMountFromPathAccepted(string) bool      { return false }
+func (testGiterminismConfig) IsConfigDockerfileContextAddFileAccepted(string) bool { return false }
+
+func TestInspector_AuditOrError(t *testing.T) {
+	i := NewInspector(testGiterminismConfig{}, testFileReader{}, testSharedOptions{})
+	if err := i.InspectConfigStapelFromLatest(); err == nil || !strings.Contains(err.Error(), "fromLatest directive not allowed by giterminism") {
+		t.Fatalf("expected fromLatest not allowed error, got %v", err)
+	}
+
+	a := audit.NewAudit()
+	i = NewInspector(testGiterminismConfig{}, testFileReader{}, testSharedOptions{audit: a})
+	if err := i.InspectConfigStapelFromLatest(); err != nil {
+		t.Fatalf("expected no error in the audit mode, got %s", err)
+	}
+	if err := i.InspectConfigStapelMountFromPath("/tmp"); err != nil {
+		t.Fatalf("expected no error in the audit mode, got %s", err)
+	}
+
+	expected := []audit.Record{
+		{Message: "fromLatest directive used in werf.yaml", Directive: "config.stapel.allowFromLatest"},
+		{Message: `"mount { fromPath: /tmp, ... }" used in werf.yaml`, Directive: "config.stapel.mount.allowFromPaths", Value: "/tmp"},
+	}
+	if records := a.Records(); !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+}
+
+func TestInspector_InspectBuildContextFiles(t *testing.T) {
+	fileReader := testFileReader{statusPathList: []string{"dir/file", "other"}}
+	matcher := path_matcher.NewTruePathMatcher()
+
+	i := NewInspector(testGiterminismConfig{}, fileReader, testSharedOptions{})
+	if err := i.InspectBuildContextFiles(context.Background(), matcher); err != errTestUncommittedFiles {
+		t.Fatalf("expected uncommitted files error, got %v", err)
+	}
+
+	i = NewInspector(testGiterminismConfig{}, fileReader, testSharedOptions{looseGiterminism: true})
+	if err := i.InspectBuildContextFiles(context.Background(), matcher); err != nil {
+		t.Fatalf("expected no error with loose giterminism, got %s", err)
+	}
+
+	a := audit.NewAudit()
+	i = NewInspector(testGiterminismConfig{}, fileReader, testSharedOptions{audit: a})
+	if err := i.InspectBuildContextFiles(context.Background(), matcher); err != nil {
+		t.Fatalf("expected no error in the audit mode, got %s", err)
+	}
+
+	expected := []audit.Record{
+		{Message: `uncommitted file "dir/file" used in the build context (the file must be committed)`},
+		{Message: `uncommitted file "other" used in the build context (the file must be committed)`},
+	}
+	if records := a.Records(); !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+}
+
+func TestInspector_InspectConfigStapelImportFromWithoutDigest(t *testing.T) {
+	i := NewInspector(testGiterminismConfig{}, testFileReader{}, testSharedOptions{})
+	if err := i.InspectConfigStapelImportFromWithoutDigest(); err == nil || !strings.Contains(err.Error(), "import from external image without digest not allowed by giterminism") {
+		t.Fatalf("expected import from without digest not allowed error, got %v", err)
+	}
+
+	i = NewInspector(testGiterminismConfig{}, testFileReader{}, testSharedOptions{looseGiterminism: true})
+	if err := i.InspectConfigStapelImportFromWithoutDigest(); err != nil {
+		t.Fatalf("expected no error with loose giterminism, got %s", err)
+	}
+
+	a := audit.NewAudit()
+	i = NewInspector(testGiterminismConfig{}, testFileReader{}, testSharedOptions{audit: a})
+	if err := i.InspectConfigStapelImportFromWithoutDigest(); err != nil {
+		t.Fatalf("expected no error in the audit mode, got %s", err)
+	}
+
+	expected := []audit.Record{{Message: "import from external image without digest used in werf.yaml", Directive: "config.stapel.import.allowFromWithoutDigest"}}
+	if records := a.Records(); !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+}
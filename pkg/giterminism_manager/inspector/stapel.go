@@ -2,6 +2,8 @@ package inspector
 
 import (
 	"fmt"
+
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 )
 
 func (i Inspector) InspectConfigStapelFromLatest() error {
@@ -9,14 +11,14 @@ func (i Inspector) InspectConfigStapelFromLatest() error {
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(`fromLatest directive not allowed by giterminism
+	return i.auditOrError(audit.Record{Message: "fromLatest directive used in werf.yaml", Directive: "config.stapel.allowFromLatest"}, NewExternalDependencyFoundError(`fromLatest directive not allowed by giterminism
 
 If fromLatest is true, then werf starts using the actual base image digest in the stage digest. Thus, using this directive may break the reproducibility of previous builds. The changing of the base image in the registry makes all previously built images unusable.
 
  * Previous pipeline jobs (e.g., converge) cannot be retried without the image rebuilding after changing a registry base image.
  * If the base image is modified unexpectedly, it may lead to an inexplicably failed pipeline. For instance, the modification occurs after a successful build, and the following jobs will be failed due to changing stages digests alongside base image digest.
 
-As an alternative, we recommend using unchangeable tag or periodically change 'fromCacheVersion' value to guarantee the application's controllable and predictable life cycle.`)
+As an alternative, we recommend using unchangeable tag or periodically change 'fromCacheVersion' value to guarantee the application's controllable and predictable life cycle.`))
 }
 
 func (i Inspector) InspectConfigStapelGitBranch() error {
@@ -24,14 +26,26 @@ func (i Inspector) InspectConfigStapelGitBranch() error {
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(`git branch directive not allowed by giterminism
+	return i.auditOrError(audit.Record{Message: "git branch directive used in werf.yaml", Directive: "config.stapel.git.allowBranch"}, NewExternalDependencyFoundError(`git branch directive not allowed by giterminism
 
 Remote git mapping with a branch (master branch by default) may break the previous builds' reproducibility. werf uses the history of a git repository to calculate the stage digest. Thus, the new commit in the branch makes all previously built images unusable.
 
  * The existing pipeline jobs (e.g., converge) would not run and would require rebuilding an image if a remote git branch has been changed.
  * Unplanned commits to a remote git branch might lead to the pipeline failing seemingly for no apparent reasons. For instance, changes may occur after the build process is completed successfully. In this case, the related pipeline jobs will fail due to changes in stage digests along with the branch HEAD.
 
-As an alternative, we recommend using unchangeable reference, tag, or commit to guarantee the application's controllable and predictable life cycle.`)
+As an alternative, we recommend using unchangeable reference, tag, or commit to guarantee the application's controllable and predictable life cycle.`))
+}
+
+func (i Inspector) InspectConfigStapelImportFromWithoutDigest() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigStapelImportFromWithoutDigestAccepted() {
+		return nil
+	}
+
+	return i.auditOrError(audit.Record{Message: "import from external image without digest used in werf.yaml", Directive: "config.stapel.import.allowFromWithoutDigest"}, NewExternalDependencyFoundError(`import from external image without digest not allowed by giterminism
+
+The external image of the import directive is identified only by the reference. If the image is referenced by a tag, werf uses the tag in the stage digest, and the changing of the image in the registry is not detected. Thus, the same stage may contain different files, and previous builds cannot be reproduced.
+
+As an alternative, we recommend pinning the image by the digest (e.g., alpine@sha256:DIGEST) to guarantee the application's controllable and predictable life cycle.`))
 }
 
 func (i Inspector) InspectConfigStapelMountBuildDir() error {
@@ -39,9 +53,19 @@ func (i Inspector) InspectConfigStapelMountBuildDir() error {
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(`"mount { from: build_dir, ... }" not allowed by giterminism
+	return i.auditOrError(audit.Record{Message: `"mount { from: build_dir, ... }" used in werf.yaml`, Directive: "config.stapel.mount.allowBuildDir"}, NewExternalDependencyFoundError(`"mount { from: build_dir, ... }" not allowed by giterminism
+
+The use of the build_dir mount may lead to unpredictable behavior when used in parallel and potentially affect reproducibility and reliability.`))
+}
+
+func (i Inspector) InspectConfigStapelMountVolume(name string) error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigStapelMountVolumeAccepted(name) {
+		return nil
+	}
+
+	return i.auditOrError(audit.Record{Message: fmt.Sprintf(`"mount { from: volume, name: %s, ... }" used in werf.yaml`, name), Directive: "config.stapel.mount.allowVolumes", Value: name}, NewExternalDependencyFoundError(fmt.Sprintf(`"mount { from: volume, name: %s, ... }" not allowed by giterminism
 
-The use of the build_dir mount may lead to unpredictable behavior when used in parallel and potentially affect reproducibility and reliability.`)
+The volume mount is stored between builds and shared by all images of the project, so the data in the volume may affect reproducibility and reliability. The data in the mounted directory has no effect on the final image digest, which can lead to invalid images and hard-to-trace issues.`, name)))
 }
 
 func (i Inspector) InspectConfigStapelMountFromPath(fromPath string) error {
@@ -53,7 +77,7 @@ func (i Inspector) InspectConfigStapelMountFromPath(fromPath string) error {
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(fmt.Sprintf(`"mount { fromPath: %s, ... }" not allowed by giterminism
+	return i.auditOrError(audit.Record{Message: fmt.Sprintf(`"mount { fromPath: %s, ... }" used in werf.yaml`, fromPath), Directive: "config.stapel.mount.allowFromPaths", Value: fromPath}, NewExternalDependencyFoundError(fmt.Sprintf(`"mount { fromPath: %s, ... }" not allowed by giterminism
 
-The use of the fromPath mount may lead to unpredictable behavior when used in parallel and potentially affect reproducibility and reliability. The data in the mounted directory has no effect on the final image digest, which can lead to invalid images and hard-to-trace issues.`, fromPath))
+The use of the fromPath mount may lead to unpredictable behavior when used in parallel and potentially affect reproducibility and reliability. The data in the mounted directory has no effect on the final image digest, which can lead to invalid images and hard-to-trace issues.`, fromPath)))
 }
@@ -7,12 +7,14 @@ import (
 	"helm.sh/helm/v3/pkg/cli"
 
 	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 	"github.com/werf/werf/pkg/path_matcher"
 )
 
 type Interface interface {
 	FileReader() FileReader
 	Inspector() Inspector
+	SSHKnownHosts() []string
 
 	LocalGitRepo() *git_repo.Local
 	HeadCommit() string
@@ -20,6 +22,7 @@ type Interface interface {
 	RelativeToGitProjectDir() string
 	LooseGiterminism() bool
 	Dev() bool
+	Audit() *audit.Audit
 }
 
 type FileReader interface {
@@ -28,9 +31,12 @@ type FileReader interface {
 	ReadConfigTemplateFiles(ctx context.Context, customRelDirPath string, tmplFunc func(templatePathInsideDir string, data []byte, err error) error) error
 	ConfigGoTemplateFilesGet(ctx context.Context, relPath string) ([]byte, error)
 	ConfigGoTemplateFilesGlob(ctx context.Context, pattern string) (map[string]interface{}, error)
+	ReadConfigInclude(ctx context.Context, relPath string) ([]byte, error)
 	ReadDockerfile(ctx context.Context, relPath string) ([]byte, error)
 	IsDockerignoreExistAnywhere(ctx context.Context, relPath string) (bool, error)
 	ReadDockerignore(ctx context.Context, relPath string) ([]byte, error)
+	ReadStapelScript(ctx context.Context, relPath string) ([]byte, error)
+	LoadKustomizationDir(ctx context.Context, dir string) ([]*chart.ChartExtenderBufferedFile, error)
 
 	HelmChartExtender
 }
@@ -43,9 +49,15 @@ type HelmChartExtender interface {
 
 type Inspector interface {
 	InspectConfigGoTemplateRenderingEnv(ctx context.Context, envName string) error
+	IsConfigGoTemplateRenderingEnvDigested(envName string) (bool, error)
 	InspectConfigStapelFromLatest() error
 	InspectConfigStapelGitBranch() error
+	InspectConfigStapelImportFromWithoutDigest() error
+	InspectConfigIncludeRemote(include string) error
+	InspectConfigIncludeBranch() error
+	InspectConfigDependencyLatest() error
 	InspectConfigStapelMountBuildDir() error
+	InspectConfigStapelMountVolume(name string) error
 	InspectConfigStapelMountFromPath(fromPath string) error
 	InspectConfigDockerfileContextAddFile(relPath string) error
 	InspectBuildContextFiles(ctx context.Context, matcher path_matcher.PathMatcher) error
@@ -6,6 +6,7 @@ import (
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/audit"
 	"github.com/werf/werf/pkg/giterminism_manager/config"
 	"github.com/werf/werf/pkg/giterminism_manager/errors"
 	"github.com/werf/werf/pkg/giterminism_manager/file_reader"
@@ -16,16 +17,12 @@ import (
 type NewManagerOptions struct {
 	LooseGiterminism bool
 	Dev              bool
+	// Audit enables the audit mode: the places requiring loosening giterminism are recorded instead of failing
+	Audit bool
 }
 
 func NewManager(ctx context.Context, projectDir string, localGitRepo *git_repo.Local, headCommit string, options NewManagerOptions) (Interface, error) {
-	sharedOptions := &sharedOptions{
-		projectDir:       projectDir,
-		localGitRepo:     localGitRepo,
-		headCommit:       headCommit,
-		looseGiterminism: options.LooseGiterminism,
-		dev:              options.Dev,
-	}
+	sharedOptions := newSharedOptions(projectDir, localGitRepo, headCommit, options)
 
 	if options.LooseGiterminism {
 		err := errors.NewError(`DEPRECATION WARNING: The --loose-giterminism option (and WERF_LOOSE_GITERMINISM env variable) is forbidden and will be removed in v1.2!
@@ -48,6 +45,7 @@ Please use werf-giterminism.yaml config instead to loosen giterminism restrictio
 		sharedOptions: sharedOptions,
 		fileReader:    fr,
 		inspector:     i,
+		config:        c,
 	}
 
 	logboek.Context(ctx).Debug().LogF("-- giterminism_manager.NewManager: projectDir=%q localGitRepo.WorkTreeDir=%q\n", projectDir, localGitRepo.WorkTreeDir)
@@ -55,9 +53,23 @@ Please use werf-giterminism.yaml config instead to loosen giterminism restrictio
 	return m, nil
 }
 
+// ReadConfig reads the giterminism config the same way as the manager does but without the validation, so that the config could be linted.
+// The nil data is returned if the config does not exist.
+func ReadConfig(ctx context.Context, projectDir string, localGitRepo *git_repo.Local, headCommit string, options NewManagerOptions) ([]byte, error) {
+	fr := file_reader.NewFileReader(newSharedOptions(projectDir, localGitRepo, headCommit, options))
+
+	exist, err := fr.IsGiterminismConfigExistAnywhere(ctx)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	return fr.ReadGiterminismConfig(ctx)
+}
+
 type Manager struct {
 	fileReader FileReader
 	inspector  Inspector
+	config     config.Config
 
 	*sharedOptions
 }
@@ -70,12 +82,34 @@ func (m Manager) Inspector() Inspector {
 	return m.inspector
 }
 
+// SSHKnownHosts returns the known_hosts lines pinning the ssh host keys in the giterminism config.
+func (m Manager) SSHKnownHosts() []string {
+	return m.config.SSHKnownHosts()
+}
+
 type sharedOptions struct {
 	projectDir       string
 	headCommit       string
 	localGitRepo     *git_repo.Local
 	looseGiterminism bool
 	dev              bool
+	audit            *audit.Audit
+}
+
+func newSharedOptions(projectDir string, localGitRepo *git_repo.Local, headCommit string, options NewManagerOptions) *sharedOptions {
+	s := &sharedOptions{
+		projectDir:       projectDir,
+		localGitRepo:     localGitRepo,
+		headCommit:       headCommit,
+		looseGiterminism: options.LooseGiterminism,
+		dev:              options.Dev,
+	}
+
+	if options.Audit {
+		s.audit = audit.NewAudit()
+	}
+
+	return s
 }
 
 func (s *sharedOptions) ProjectDir() string {
@@ -101,3 +135,8 @@ func (s *sharedOptions) LooseGiterminism() bool {
 func (s *sharedOptions) Dev() bool {
 	return s.dev
 }
+
+// Audit returns the audit records storage, nil if the audit mode is disabled.
+func (s *sharedOptions) Audit() *audit.Audit {
+	return s.audit
+}
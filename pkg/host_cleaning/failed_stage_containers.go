@@ -0,0 +1,51 @@
+package host_cleaning
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// FailedStageContainersMaxAge is the age of the kept failed stage container after which it is removed by the host cleanup.
+const FailedStageContainersMaxAge = 3 * 24 * time.Hour
+
+func failedStageContainers(ctx context.Context) ([]types.Container, error) {
+	filterSet := filters.NewArgs()
+	filterSet.Add("name", image.FailedStageContainerNamePrefix)
+	return containersByFilterSet(ctx, filterSet)
+}
+
+// RunGCForFailedStageContainers removes the kept failed stage containers created more than maxAge ago.
+func RunGCForFailedStageContainers(ctx context.Context, maxAge time.Duration, dryRun bool) error {
+	containers, err := failedStageContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot get failed stage containers: %s", err)
+	}
+
+	expiredContainers := selectExpiredContainers(containers, maxAge, time.Now())
+	if len(expiredContainers) == 0 {
+		return nil
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Removing failed stage containers older than %s", maxAge).DoError(func() error {
+		return containersRemove(ctx, expiredContainers, CommonOptions{RmForce: true, DryRun: dryRun})
+	})
+}
+
+func selectExpiredContainers(containers []types.Container, maxAge time.Duration, now time.Time) []types.Container {
+	var res []types.Container
+	for _, container := range containers {
+		if now.Sub(time.Unix(container.Created, 0)) > maxAge {
+			res = append(res, container)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,26 @@
+package host_cleaning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestSelectExpiredContainers(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	containers := []types.Container{
+		{ID: "fresh", Created: now.Add(-time.Hour).Unix()},
+		{ID: "expired", Created: now.Add(-FailedStageContainersMaxAge - time.Hour).Unix()},
+		{ID: "boundary", Created: now.Add(-FailedStageContainersMaxAge).Unix()},
+	}
+
+	expired := selectExpiredContainers(containers, FailedStageContainersMaxAge, now)
+	if len(expired) != 1 || expired[0].ID != "expired" {
+		t.Fatalf("expected only the expired container, got %v", expired)
+	}
+
+	if expired := selectExpiredContainers(nil, FailedStageContainersMaxAge, now); len(expired) != 0 {
+		t.Fatalf("expected no containers, got %v", expired)
+	}
+}
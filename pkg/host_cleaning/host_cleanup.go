@@ -25,9 +25,15 @@ type HostCleanupOptions struct {
 	AllowedLocalCacheVolumeUsagePercentage          *uint
 	AllowedLocalCacheVolumeUsageMarginPercentage    *uint
 
+	// LocalImagesPolicy limits the local werf images regardless of the docker storage volume usage.
+	LocalImagesPolicy LocalImagesPolicy
+
 	DryRun                  bool
 	Force                   bool
 	DockerServerStoragePath string
+
+	// SkipLocalDockerServer skips the local docker server GC, when the images are built without the local docker server (e.g. by the remote builder).
+	SkipLocalDockerServer bool
 }
 
 func getOptionValueOrDefault(optionValue *uint, defaultValue float64) float64 {
@@ -56,6 +62,8 @@ func RunAutoHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 		logboek.Context(ctx).Default().LogFDetails(" - old unused files from werf caches (which are stored in the ~/.werf/local_cache);\n")
 		logboek.Context(ctx).Default().LogFDetails(" - old temporary service files /tmp/werf-project-data-* and /tmp/werf-config-render-*;\n")
 		logboek.Context(ctx).Default().LogFDetails(" - least recently used werf images;\n")
+		logboek.Context(ctx).Default().LogFDetails(" - failed stage containers kept more than 3 days ago;\n")
+		logboek.Context(ctx).Default().LogFDetails(" - mount volumes not used by the builds for more than 7 days;\n")
 		logboek.Context(ctx).Default().LogLn()
 		logboek.Context(ctx).Default().LogFDetails("NOTE: Werf-host-cleanup procedure of v1.2 werf version will not cleanup --stages-storage=:local stages of v1.1 werf version, because this is primary stages storage data, and it can only be cleaned by the regular per-project werf-cleanup command with git-history based algorithm.\n")
 		logboek.Context(ctx).Default().LogLn()
@@ -83,6 +91,15 @@ func RunHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 	allowedDockerStorageVolumeUsagePercentage := getOptionValueOrDefault(options.AllowedDockerStorageVolumeUsagePercentage, DefaultAllowedDockerStorageVolumeUsagePercentage)
 	allowedDockerStorageVolumeUsageMarginPercentage := getOptionValueOrDefault(options.AllowedDockerStorageVolumeUsageMarginPercentage, DefaultAllowedDockerStorageVolumeUsageMarginPercentage)
 
+	if err := logboek.Context(ctx).Default().LogProcess("Running GC for mount volumes").DoError(func() error {
+		if err := RunGCForMountVolumes(ctx, MountVolumesMaxAge, options.DryRun); err != nil {
+			return fmt.Errorf("mount volumes GC failed: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
 	if err := logboek.Context(ctx).Default().LogProcess("Running GC for git data").DoError(func() error {
 		if err := gitdata.RunGC(ctx, allowedLocalCacheVolumeUsagePercentage, allowedLocalCacheVolumeUsageMarginPercentage); err != nil {
 			return fmt.Errorf("git repo GC failed: %s", err)
@@ -92,12 +109,24 @@ func RunHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 		return err
 	}
 
+	if options.SkipLocalDockerServer {
+		return nil
+	}
+
 	dockerServerStoragePath, err := getDockerServerStoragePath(ctx, options.DockerServerStoragePath)
 	if err != nil {
 		return fmt.Errorf("error getting local docker server storage path: %s", err)
 	}
 
 	return logboek.Context(ctx).Default().LogProcess("Running GC for local docker server").DoError(func() error {
+		if err := RunGCForFailedStageContainers(ctx, FailedStageContainersMaxAge, options.DryRun); err != nil {
+			return fmt.Errorf("failed stage containers GC failed: %s", err)
+		}
+
+		if err := RunGCForLocalDockerServerByPolicy(ctx, options.LocalImagesPolicy, options.Force, options.DryRun); err != nil {
+			return fmt.Errorf("local docker server GC by local images policy failed: %s", err)
+		}
+
 		if err := RunGCForLocalDockerServer(ctx, allowedDockerStorageVolumeUsagePercentage, allowedDockerStorageVolumeUsageMarginPercentage, dockerServerStoragePath, options.Force, options.DryRun); err != nil {
 			return fmt.Errorf("local docker server GC failed: %s", err)
 		}
@@ -130,8 +159,8 @@ func ShouldRunAutoHostCleanup(ctx context.Context, options HostCleanupOptions) (
 	if err != nil {
 		return false, fmt.Errorf("failed to check git repo GC: %s", err)
 	}
-	if shouldRun {
-		return true, nil
+	if shouldRun || options.SkipLocalDockerServer {
+		return shouldRun, nil
 	}
 
 	dockerServerStoragePath, err := getDockerServerStoragePath(ctx, options.DockerServerStoragePath)
@@ -35,6 +35,15 @@ func HostPurge(ctx context.Context, options HostPurgeOptions) error {
 			return err
 		}
 
+		containers, err := failedStageContainers(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := containersRemove(ctx, containers, commonOptions); err != nil {
+			return err
+		}
+
 		return nil
 	}); err != nil {
 		return err
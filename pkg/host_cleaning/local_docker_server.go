@@ -109,6 +109,19 @@ func GetLocalDockerServerStorageCheck(ctx context.Context, dockerServerStoragePa
 	}
 	res.VolumeUsage = vu
 
+	res.ImagesDescs, res.TotalImagesBytes, err = getLocalDockerServerImagesDescs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// getLocalDockerServerImagesDescs returns the werf images sorted from the least recently used and the total size of these images.
+func getLocalDockerServerImagesDescs(ctx context.Context) ([]*LocalImageDesc, uint64, error) {
+	var imagesDescs []*LocalImageDesc
+	var totalImagesBytes uint64
+
 	var images []types.ImageSummary
 
 	{
@@ -118,7 +131,7 @@ func GetLocalDockerServerStorageCheck(ctx context.Context, dockerServerStoragePa
 
 		imgs, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
 		if err != nil {
-			return nil, fmt.Errorf("unable to get werf docker images: %s", err)
+			return nil, 0, fmt.Errorf("unable to get werf docker images: %s", err)
 		}
 		images = append(images, imgs...)
 	}
@@ -130,7 +143,7 @@ func GetLocalDockerServerStorageCheck(ctx context.Context, dockerServerStoragePa
 
 		imgs, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
 		if err != nil {
-			return nil, fmt.Errorf("unable to get werf v1.1 legacy docker images: %s", err)
+			return nil, 0, fmt.Errorf("unable to get werf v1.1 legacy docker images: %s", err)
 		}
 
 	ExcludeLocalV1_1StagesStorage:
@@ -151,7 +164,7 @@ func GetLocalDockerServerStorageCheck(ctx context.Context, dockerServerStoragePa
 
 		t, err := werf.GetWerfLastRunAtV1_1(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("error getting v1.1 last run timestamp: %s", err)
+			return nil, 0, fmt.Errorf("error getting v1.1 last run timestamp: %s", err)
 		}
 
 		// No werf v1.1 runs on this host.
@@ -172,7 +185,7 @@ func GetLocalDockerServerStorageCheck(ctx context.Context, dockerServerStoragePa
 
 			imgs, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
 			if err != nil {
-				return nil, fmt.Errorf("unable to get werf service images: %s", err)
+				return nil, 0, fmt.Errorf("unable to get werf service images: %s", err)
 			}
 
 			for _, img := range imgs {
@@ -192,7 +205,7 @@ CreateImagesDescs:
 		data, _ := json.Marshal(imageSummary)
 		logboek.Context(ctx).Debug().LogF("Image summary:\n%s\n---\n", data)
 
-		res.TotalImagesBytes += uint64(imageSummary.VirtualSize - imageSummary.SharedSize)
+		totalImagesBytes += getImageSize(imageSummary)
 
 		lastUsedAt := time.Unix(imageSummary.Created, 0)
 
@@ -205,7 +218,7 @@ CreateImagesDescs:
 
 			lastRecentlyUsedAt, err := lrumeta.CommonLRUImagesCache.GetImageLastAccessTime(ctx, ref)
 			if err != nil {
-				return nil, fmt.Errorf("error accessing last recently used images cache: %s", err)
+				return nil, 0, fmt.Errorf("error accessing last recently used images cache: %s", err)
 			}
 
 			if lastRecentlyUsedAt.IsZero() {
@@ -220,12 +233,12 @@ CreateImagesDescs:
 			ImageSummary: imageSummary,
 			LastUsedAt:   lastUsedAt,
 		}
-		res.ImagesDescs = append(res.ImagesDescs, desc)
+		imagesDescs = append(imagesDescs, desc)
 	}
 
-	sort.Sort(ImagesLruSort(res.ImagesDescs))
+	sort.Sort(ImagesLruSort(imagesDescs))
 
-	return res, nil
+	return imagesDescs, totalImagesBytes, nil
 }
 
 func RunGCForLocalDockerServer(ctx context.Context, allowedVolumeUsagePercentage, allowedVolumeUsageMarginPercentage float64, dockerServerStoragePath string, force, dryRun bool) error {
@@ -266,6 +279,7 @@ func RunGCForLocalDockerServer(ctx context.Context, allowedVolumeUsagePercentage
 
 	var processedDockerImagesIDs []string
 	var processedDockerContainersIDs []string
+	var reclaimedBytes uint64
 
 	for {
 		var freedBytes uint64
@@ -284,59 +298,14 @@ func RunGCForLocalDockerServer(ctx context.Context, allowedVolumeUsagePercentage
 					}
 					processedDockerImagesIDs = append(processedDockerImagesIDs, desc.ImageSummary.ID)
 
-					imageRemoved := false
-
-					if len(desc.ImageSummary.RepoTags) > 0 {
-						allTagsRemoved := true
-
-						for _, ref := range desc.ImageSummary.RepoTags {
-							if ref == "<none>:<none>" {
-								if err := removeImage(ctx, desc.ImageSummary.ID, force, dryRun); err != nil {
-									logboek.Context(ctx).Warn().LogF("failed to remove local docker image by ID %q: %s\n", desc.ImageSummary.ID, err)
-									allTagsRemoved = false
-								}
-							} else {
-								lockName := container_runtime.ImageLockName(ref)
-
-								isLocked, lock, err := werf.AcquireHostLock(ctx, lockName, lockgate.AcquireOptions{NonBlocking: true})
-								if err != nil {
-									return fmt.Errorf("error locking image %q: %s", lockName, err)
-								}
-
-								if !isLocked {
-									logboek.Context(ctx).Default().LogFDetails("Image %q is locked at the moment: skip removal\n", ref)
-									continue DeleteImages
-								}
-
-								acquiredHostLocks = append(acquiredHostLocks, lock)
-
-								if err := removeImage(ctx, ref, force, dryRun); err != nil {
-									logboek.Context(ctx).Warn().LogF("failed to remove local docker image by repo tag %q: %s\n", ref, err)
-									allTagsRemoved = false
-								}
-							}
-						}
-
-						if allTagsRemoved {
-							imageRemoved = true
-						}
-					} else if len(desc.ImageSummary.RepoDigests) > 0 {
-						allDigestsRemoved := true
-
-						for _, repoDigest := range desc.ImageSummary.RepoDigests {
-							if err := removeImage(ctx, repoDigest, force, dryRun); err != nil {
-								logboek.Context(ctx).Warn().LogF("failed to remove local docker image by repo digest %q: %s\n", repoDigest, err)
-								allDigestsRemoved = false
-							}
-						}
-
-						if allDigestsRemoved {
-							imageRemoved = true
-						}
+					imageRemoved, locks, err := removeLocalImage(ctx, desc, force, dryRun)
+					acquiredHostLocks = append(acquiredHostLocks, locks...)
+					if err != nil {
+						return err
 					}
 
 					if imageRemoved {
-						freedBytes += uint64(desc.ImageSummary.VirtualSize - desc.ImageSummary.SharedSize)
+						freedBytes += getImageSize(desc.ImageSummary)
 						freedImagesCount++
 					}
 
@@ -350,6 +319,7 @@ func RunGCForLocalDockerServer(ctx context.Context, allowedVolumeUsagePercentage
 				}
 
 				logboek.Context(ctx).Default().LogF("Freed images: %s\n", utils.GreenF("%d", freedImagesCount))
+				reclaimedBytes += freedBytes
 
 				return nil
 			}); err != nil {
@@ -370,7 +340,7 @@ func RunGCForLocalDockerServer(ctx context.Context, allowedVolumeUsagePercentage
 		}
 
 		for _, lock := range acquiredHostLocks {
-			if err := werf.ReleaseHostLock(lock); err != nil {
+			if err := werf.ReleaseGlobalHostLock(lock); err != nil {
 				return fmt.Errorf("unable to release lock %q: %s", lock.LockName, err)
 			}
 		}
@@ -437,9 +407,182 @@ func RunGCForLocalDockerServer(ctx context.Context, allowedVolumeUsagePercentage
 		})
 	}
 
+	logReclaimedSpace(ctx, reclaimedBytes, dryRun)
+
 	return nil
 }
 
+// LocalImagesPolicy limits the local werf images regardless of the docker storage volume usage.
+type LocalImagesPolicy struct {
+	// AllowedSizeBytes is the allowed total size of the local werf images, the least recently used images are deleted when the size is exceeded.
+	AllowedSizeBytes uint64
+	// MaxUnusedTime is the time since the last use after which the local werf image is deleted.
+	MaxUnusedTime time.Duration
+}
+
+func (policy LocalImagesPolicy) IsEmpty() bool {
+	return policy.AllowedSizeBytes == 0 && policy.MaxUnusedTime == 0
+}
+
+// selectImages returns the least recently used images exceeding the policy limits.
+func (policy LocalImagesPolicy) selectImages(imagesDescs []*LocalImageDesc, totalImagesBytes uint64, now time.Time) []*LocalImageDesc {
+	var res []*LocalImageDesc
+
+	for _, desc := range imagesDescs {
+		isExpired := policy.MaxUnusedTime != 0 && now.Sub(desc.LastUsedAt) > policy.MaxUnusedTime
+		isSizeExceeded := policy.AllowedSizeBytes != 0 && totalImagesBytes > policy.AllowedSizeBytes
+
+		// images are sorted from the least recently used, so the next images are neither expired nor exceed the size
+		if !isExpired && !isSizeExceeded {
+			break
+		}
+
+		res = append(res, desc)
+
+		imageBytes := getImageSize(desc.ImageSummary)
+		if imageBytes > totalImagesBytes {
+			imageBytes = totalImagesBytes
+		}
+		totalImagesBytes -= imageBytes
+	}
+
+	return res
+}
+
+// RunGCForLocalDockerServerByPolicy deletes the least recently used local werf images exceeding the size and the age limits of the policy.
+func RunGCForLocalDockerServerByPolicy(ctx context.Context, policy LocalImagesPolicy, force, dryRun bool) error {
+	if policy.IsEmpty() {
+		return nil
+	}
+
+	imagesDescs, totalImagesBytes, err := getLocalDockerServerImagesDescs(ctx)
+	if err != nil {
+		return err
+	}
+
+	imagesDescsToDelete := policy.selectImages(imagesDescs, totalImagesBytes, time.Now())
+
+	logboek.Context(ctx).Default().LogBlock("Local werf images check").Do(func() {
+		logboek.Context(ctx).Default().LogF("Images size: %s\n", humanize.Bytes(totalImagesBytes))
+		if policy.AllowedSizeBytes != 0 {
+			logboek.Context(ctx).Default().LogF("Allowed images size: %s\n", utils.BlueF("%s", humanize.Bytes(policy.AllowedSizeBytes)))
+		}
+		if policy.MaxUnusedTime != 0 {
+			logboek.Context(ctx).Default().LogF("Max unused time of images: %s\n", utils.BlueF("%s", policy.MaxUnusedTime))
+		}
+		logboek.Context(ctx).Default().LogF("Images to free: %s\n", utils.YellowF("%d", len(imagesDescsToDelete)))
+	})
+
+	if len(imagesDescsToDelete) == 0 {
+		return nil
+	}
+
+	var acquiredHostLocks []lockgate.LockHandle
+	defer func() {
+		for _, lock := range acquiredHostLocks {
+			if err := werf.ReleaseGlobalHostLock(lock); err != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: unable to release lock %q: %s\n", lock.LockName, err)
+			}
+		}
+	}()
+
+	var reclaimedBytes uint64
+	if err := logboek.Context(ctx).Default().LogProcess("Running cleanup for least recently used docker images created by werf").DoError(func() error {
+		var freedImagesCount uint64
+		for _, desc := range imagesDescsToDelete {
+			imageRemoved, locks, err := removeLocalImage(ctx, desc, force, dryRun)
+			acquiredHostLocks = append(acquiredHostLocks, locks...)
+			if err != nil {
+				return err
+			}
+
+			if imageRemoved {
+				reclaimedBytes += getImageSize(desc.ImageSummary)
+				freedImagesCount++
+			}
+		}
+
+		logboek.Context(ctx).Default().LogF("Freed images: %s\n", utils.GreenF("%d", freedImagesCount))
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	logReclaimedSpace(ctx, reclaimedBytes, dryRun)
+
+	return nil
+}
+
+func logReclaimedSpace(ctx context.Context, reclaimedBytes uint64, dryRun bool) {
+	if reclaimedBytes == 0 {
+		return
+	}
+
+	if dryRun {
+		logboek.Context(ctx).Default().LogF("Space to be reclaimed: %s\n", utils.GreenF("%s", humanize.Bytes(reclaimedBytes)))
+	} else {
+		logboek.Context(ctx).Default().LogF("Reclaimed space: %s\n", utils.GreenF("%s", humanize.Bytes(reclaimedBytes)))
+	}
+}
+
+// removeLocalImage removes all tags of the image, the image is skipped if some tag is locked by another process.
+// The acquired host locks should be released by the caller after the cleanup.
+func removeLocalImage(ctx context.Context, desc *LocalImageDesc, force, dryRun bool) (bool, []lockgate.LockHandle, error) {
+	var acquiredHostLocks []lockgate.LockHandle
+
+	if len(desc.ImageSummary.RepoTags) > 0 {
+		allTagsRemoved := true
+
+		for _, ref := range desc.ImageSummary.RepoTags {
+			if ref == "<none>:<none>" {
+				if err := removeImage(ctx, desc.ImageSummary.ID, force, dryRun); err != nil {
+					logboek.Context(ctx).Warn().LogF("failed to remove local docker image by ID %q: %s\n", desc.ImageSummary.ID, err)
+					allTagsRemoved = false
+				}
+			} else {
+				lockName := container_runtime.ImageLockName(ref)
+
+				isLocked, lock, err := werf.AcquireGlobalHostLock(ctx, lockName, lockgate.AcquireOptions{NonBlocking: true})
+				if err != nil {
+					return false, acquiredHostLocks, fmt.Errorf("error locking image %q: %s", lockName, err)
+				}
+
+				if !isLocked {
+					logboek.Context(ctx).Default().LogFDetails("Image %q is locked at the moment: skip removal\n", ref)
+					return false, acquiredHostLocks, nil
+				}
+
+				acquiredHostLocks = append(acquiredHostLocks, lock)
+
+				if err := removeImage(ctx, ref, force, dryRun); err != nil {
+					logboek.Context(ctx).Warn().LogF("failed to remove local docker image by repo tag %q: %s\n", ref, err)
+					allTagsRemoved = false
+				}
+			}
+		}
+
+		return allTagsRemoved, acquiredHostLocks, nil
+	} else if len(desc.ImageSummary.RepoDigests) > 0 {
+		allDigestsRemoved := true
+
+		for _, repoDigest := range desc.ImageSummary.RepoDigests {
+			if err := removeImage(ctx, repoDigest, force, dryRun); err != nil {
+				logboek.Context(ctx).Warn().LogF("failed to remove local docker image by repo digest %q: %s\n", repoDigest, err)
+				allDigestsRemoved = false
+			}
+		}
+
+		return allDigestsRemoved, acquiredHostLocks, nil
+	}
+
+	return false, acquiredHostLocks, nil
+}
+
+func getImageSize(imageSummary types.ImageSummary) uint64 {
+	return uint64(imageSummary.VirtualSize - imageSummary.SharedSize)
+}
+
 func removeImage(ctx context.Context, ref string, force, dryRun bool) error {
 	logboek.Context(ctx).Default().LogF("Removing %s\n", ref)
 	if dryRun {
@@ -520,7 +663,7 @@ ProcessContainers:
 
 		if err := func() error {
 			containerLockName := container_runtime.ContainerLockName(containerName)
-			isLocked, lock, err := werf.AcquireHostLock(ctx, containerLockName, lockgate.AcquireOptions{NonBlocking: true})
+			isLocked, lock, err := werf.AcquireGlobalHostLock(ctx, containerLockName, lockgate.AcquireOptions{NonBlocking: true})
 			if err != nil {
 				return fmt.Errorf("failed to lock %s for container %s: %s", containerLockName, logContainerName(container), err)
 			}
@@ -529,7 +672,7 @@ ProcessContainers:
 				logboek.Context(ctx).Default().LogFDetails("Ignore container %s used by another process\n", logContainerName(container))
 				return nil
 			}
-			defer werf.ReleaseHostLock(lock)
+			defer werf.ReleaseGlobalHostLock(lock)
 
 			if err := containersRemove(ctx, []types.Container{container}, options); err != nil {
 				return fmt.Errorf("failed to remove container %s: %s", logContainerName(container), err)
@@ -0,0 +1,112 @@
+package host_cleaning
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// MountVolumesMaxAge is the time since the last build using the project volume, after which the volume is removed by the host cleanup.
+const MountVolumesMaxAge = 7 * 24 * time.Hour
+
+type mountVolume struct {
+	projectName string
+	name        string
+	dir         string
+}
+
+// RunGCForMountVolumes removes the project volumes of the stapel mounts not used by the builds for more than maxAge.
+func RunGCForMountVolumes(ctx context.Context, maxAge time.Duration, dryRun bool) error {
+	volumesDir := stage.GetMountVolumesDir()
+
+	volumes, err := getExpiredMountVolumes(volumesDir, maxAge, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to get mount volumes: %s", err)
+	}
+
+	for _, volume := range volumes {
+		if err := removeMountVolume(ctx, volumesDir, volume, dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeMountVolume(ctx context.Context, volumesDir string, volume mountVolume, dryRun bool) error {
+	lockName := stage.MountVolumeLockName(volume.projectName, volume.name)
+	isAcquired, lock, err := werf.AcquireHostLock(ctx, lockName, lockgate.AcquireOptions{NonBlocking: true})
+	if err != nil {
+		return fmt.Errorf("failed to lock %s for volume %s: %s", lockName, volume.dir, err)
+	}
+
+	if !isAcquired {
+		logboek.Context(ctx).Default().LogFDetails("Ignore volume %s used by another process\n", volume.dir)
+		return nil
+	}
+	defer werf.ReleaseHostLock(lock)
+
+	logboek.Context(ctx).LogLn(volume.dir)
+
+	if dryRun {
+		return nil
+	}
+
+	if runtime.GOOS == "windows" {
+		if err := os.RemoveAll(volume.dir); err != nil {
+			return fmt.Errorf("unable to remove volume %s: %s", volume.dir, err)
+		}
+	} else if err := util.RemoveHostDirsWithLinuxContainer(ctx, volumesDir, []string{volume.dir}); err != nil {
+		return fmt.Errorf("unable to remove volume %s: %s", volume.dir, err)
+	}
+
+	return nil
+}
+
+// getExpiredMountVolumes returns the volumes of the VOLUMES_DIR/PROJECT_NAME/VOLUME_NAME directories modified more than maxAge ago.
+func getExpiredMountVolumes(volumesDir string, maxAge time.Duration, now time.Time) ([]mountVolume, error) {
+	projectsInfos, err := ioutil.ReadDir(volumesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var res []mountVolume
+	for _, projectInfo := range projectsInfos {
+		if !projectInfo.IsDir() {
+			continue
+		}
+
+		projectDir := filepath.Join(volumesDir, projectInfo.Name())
+		volumesInfos, err := ioutil.ReadDir(projectDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, volumeInfo := range volumesInfos {
+			if !volumeInfo.IsDir() || now.Sub(volumeInfo.ModTime()) <= maxAge {
+				continue
+			}
+
+			res = append(res, mountVolume{
+				projectName: projectInfo.Name(),
+				name:        volumeInfo.Name(),
+				dir:         filepath.Join(projectDir, volumeInfo.Name()),
+			})
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package host_cleaning
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetExpiredMountVolumes(t *testing.T) {
+	now := time.Now()
+	volumesDir := t.TempDir()
+
+	for dir, modTime := range map[string]time.Time{
+		filepath.Join(volumesDir, "project", "fresh"):   now.Add(-time.Hour),
+		filepath.Join(volumesDir, "project", "expired"): now.Add(-MountVolumesMaxAge - time.Hour),
+	} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	volumes, err := getExpiredMountVolumes(volumesDir, MountVolumesMaxAge, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := mountVolume{projectName: "project", name: "expired", dir: filepath.Join(volumesDir, "project", "expired")}
+	if len(volumes) != 1 || volumes[0] != expected {
+		t.Fatalf("expected only %v, got %v", expected, volumes)
+	}
+}
+
+func TestGetExpiredMountVolumesNoVolumesDir(t *testing.T) {
+	volumes, err := getExpiredMountVolumes(filepath.Join(t.TempDir(), "volumes"), MountVolumesMaxAge, time.Now())
+	if err != nil || volumes != nil {
+		t.Fatalf("expected no volumes and no error, got %v, %v", volumes, err)
+	}
+}
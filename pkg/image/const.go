@@ -11,17 +11,33 @@ const (
 	WerfStageDigestLabel          = "werf-stage-digest"
 	WerfStageContentDigestLabel   = "werf-stage-content-digest"
 	WerfProjectRepoCommitLabel    = "werf-project-repo-commit"
+	WerfBaseImagesLabel           = "werf-base-images"
+	WerfParentStageIDLabel        = "werf-parent-stage-id"
+	WerfCacheFromStageIDsLabel    = "werf-cache-from-stage-ids"
 	WerfImportChecksumLabelPrefix = "werf-import-checksum-"
 
 	WerfImportMetadataChecksumLabel       = "checksum"
 	WerfImportMetadataSourceImageIDLabel  = "source-image-id"
 	WerfImportMetadataImportSourceIDLabel = "import-source-id"
 
+	WerfChangedOnlyInputsDigestLabel  = "changed-only-inputs-digest"
+	WerfChangedOnlyStageNameLabel     = "changed-only-stage-name"
+	WerfChangedOnlyStageIDLabel       = "changed-only-stage-id"
+	WerfChangedOnlyContentDigestLabel = "changed-only-content-digest"
+
 	WerfMountTmpDirLabel          = "werf-mount-type-tmp-dir"
 	WerfMountBuildDirLabel        = "werf-mount-type-build-dir"
 	WerfMountCustomDirLabelPrefix = "werf-mount-type-custom-dir-"
+	WerfMountTmpfsLabel           = "werf-mount-type-tmpfs"
+	WerfMountVolumeLabelPrefix    = "werf-mount-type-volume-"
 
 	BuildCacheVersion = "1.2"
 
 	StageContainerNamePrefix = "werf.build."
+	// FailedStageContainerNamePrefix does not match StageContainerNamePrefix, so the kept failed stage containers are not removed by the host cleanup as the build containers,
+	// but only after host_cleaning.FailedStageContainersMaxAge
+	FailedStageContainerNamePrefix = "werf.failed-stage."
+
+	// StubStageDigest is the stage digest of the stub images used when the images are not built (werf render without --repo, werf lint)
+	StubStageDigest = "STAGE_DIGEST"
 )
@@ -1,16 +1,27 @@
 package image
 
+import (
+	"fmt"
+	"strings"
+)
+
 type InfoGetter struct {
 	WerfImageName string
 	Tag           string
 	Name          string
+	StageDigest   string
+	RepoDigest    string
+	ImageID       string
 }
 
-func NewInfoGetter(imageName string, name, tag string) *InfoGetter {
+func NewInfoGetter(imageName string, name, tag, stageDigest, repoDigest, imageID string) *InfoGetter {
 	return &InfoGetter{
 		WerfImageName: imageName,
 		Name:          name,
 		Tag:           tag,
+		StageDigest:   stageDigest,
+		RepoDigest:    repoDigest,
+		ImageID:       imageID,
 	}
 }
 
@@ -29,3 +40,23 @@ func (d *InfoGetter) GetName() string {
 func (d *InfoGetter) GetTag() string {
 	return d.Tag
 }
+
+func (d *InfoGetter) GetStageDigest() string {
+	return d.StageDigest
+}
+
+// GetNameWithDigest returns the image name in the REPO@sha256:DIGEST format or an empty string if the repo digest is unknown.
+func (d *InfoGetter) GetNameWithDigest() string {
+	if d.RepoDigest == "" {
+		return ""
+	}
+
+	// the digest of the local image is in the REPO@sha256:DIGEST format
+	digest := d.RepoDigest
+	if i := strings.LastIndex(digest, "@"); i != -1 {
+		digest = digest[i+1:]
+	}
+
+	repository, _ := ParseRepositoryAndTag(d.Name)
+	return fmt.Sprintf("%s@%s", repository, digest)
+}
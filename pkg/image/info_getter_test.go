@@ -0,0 +1,22 @@
+package image
+
+import "testing"
+
+func TestInfoGetter_GetNameWithDigest(t *testing.T) {
+	for _, tc := range []struct {
+		name, repoDigest string
+		expected         string
+	}{
+		{"registry.example.com/app:abc-1611836746968", "", ""},
+		{"registry.example.com/app:abc-1611836746968", "registry.example.com/app@sha256:0123456789abcdef", "registry.example.com/app@sha256:0123456789abcdef"},
+		{"registry.example.com/app:abc-1611836746968", "sha256:0123456789abcdef", "registry.example.com/app@sha256:0123456789abcdef"},
+		// the digest of the image copied into the final repo is taken with the repository of the final image name
+		{"final.example.com/app:abc-1611836746968", "registry.example.com/app@sha256:0123456789abcdef", "final.example.com/app@sha256:0123456789abcdef"},
+		{"localhost:5000/app:abc-1611836746968", "localhost:5000/app@sha256:0123456789abcdef", "localhost:5000/app@sha256:0123456789abcdef"},
+	} {
+		info := NewInfoGetter("app", tc.name, "abc-1611836746968", "abc", tc.repoDigest, "")
+		if nameWithDigest := info.GetNameWithDigest(); nameWithDigest != tc.expected {
+			t.Errorf("%q with the repo digest %q: expected %q, got %q", tc.name, tc.repoDigest, tc.expected, nameWithDigest)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package locks_inspector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/werf/logboek"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// GetHostLocksDirs returns the dirs of the host locks of the werf home and the global host locks shared by all werf homes.
+func GetHostLocksDirs() []string {
+	if werf.GetGlobalHostLocksDir() == werf.GetHostLocksDir() {
+		return []string{werf.GetHostLocksDir()}
+	}
+
+	return []string{werf.GetHostLocksDir(), werf.GetGlobalHostLocksDir()}
+}
+
+// hostLockFileID identifies the lock file as in /proc/locks: by the device numbers and the inode.
+type hostLockFileID struct {
+	Major uint32
+	Minor uint32
+	Inode uint64
+}
+
+var errHostLocksInspectionNotSupported = errors.New("host locks inspection is not supported")
+
+// ListHostLocks returns the host locks held by the werf processes at the moment.
+// The held locks are taken from the kernel locks table without locking the files, so that the inspection never interferes with the werf processes.
+// Host lock files are named by the hash of the lock name, so the original lock name is not available.
+func ListHostLocks(ctx context.Context) ([]*LockDesc, error) {
+	var files []os.FileInfo
+	for _, locksDir := range GetHostLocksDirs() {
+		dirFiles, err := ioutil.ReadDir(locksDir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read dir %s: %s", locksDir, err)
+		}
+
+		files = append(files, dirFiles...)
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	heldLocks, err := getHeldHostLocks()
+	if errors.Is(err, errHostLocksInspectionNotSupported) {
+		logboek.Context(ctx).Warn().LogF("WARNING: Skipping host locks: %s\n", err)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get held host locks: %s", err)
+	}
+
+	var res []*LockDesc
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		fileID, ok := getHostLockFileID(file)
+		if !ok {
+			continue
+		}
+
+		owner, held := heldLocks[fileID]
+		if !held {
+			continue
+		}
+
+		res = append(res, &LockDesc{
+			Type:           HostLock,
+			Name:           file.Name(),
+			Owner:          owner,
+			LastActivityAt: file.ModTime(),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LastActivityAt.Before(res[j].LastActivityAt)
+	})
+
+	return res, nil
+}
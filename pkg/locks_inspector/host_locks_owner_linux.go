@@ -0,0 +1,109 @@
+package locks_inspector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// getHeldHostLocks maps the locked files to the holder processes from /proc/locks.
+// The owner is empty if the holder process is not visible in the current pid namespace.
+func getHeldHostLocks() (map[hostLockFileID]string, error) {
+	f, err := os.Open("/proc/locks")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pids, err := parseProcLocks(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse /proc/locks: %s", err)
+	}
+
+	res := make(map[hostLockFileID]string)
+	for fileID, pid := range pids {
+		if pid <= 0 {
+			res[fileID] = ""
+			continue
+		}
+
+		owner := fmt.Sprintf("pid %d", pid)
+		if data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+			owner = fmt.Sprintf("%s (%s)", owner, strings.TrimSpace(string(data)))
+		}
+		res[fileID] = owner
+	}
+
+	return res, nil
+}
+
+// parseProcLocks parses lines like "1: FLOCK  ADVISORY  WRITE 12345 fd:01:123456 0 EOF", the device major and minor numbers are in hex.
+// Lines of the blocked waiters ("1: -> FLOCK ...") are skipped, the pid of the holder from other pid namespace is 0 or -1.
+func parseProcLocks(r io.Reader) (map[hostLockFileID]int, error) {
+	res := make(map[hostLockFileID]int)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[1] != "FLOCK" {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("bad pid %q: %s", fields[4], err)
+		}
+
+		fileID, err := parseProcLocksFileID(fields[5])
+		if err != nil {
+			return nil, err
+		}
+
+		res[fileID] = pid
+	}
+
+	return res, scanner.Err()
+}
+
+func parseProcLocksFileID(field string) (hostLockFileID, error) {
+	parts := strings.Split(field, ":")
+	if len(parts) != 3 {
+		return hostLockFileID{}, fmt.Errorf("bad file id %q: expected MAJOR:MINOR:INODE", field)
+	}
+
+	major, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return hostLockFileID{}, fmt.Errorf("bad device major %q: %s", field, err)
+	}
+
+	minor, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return hostLockFileID{}, fmt.Errorf("bad device minor %q: %s", field, err)
+	}
+
+	inode, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return hostLockFileID{}, fmt.Errorf("bad inode %q: %s", field, err)
+	}
+
+	return hostLockFileID{Major: uint32(major), Minor: uint32(minor), Inode: inode}, nil
+}
+
+func getHostLockFileID(info os.FileInfo) (hostLockFileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return hostLockFileID{}, false
+	}
+
+	dev := uint64(stat.Dev)
+	return hostLockFileID{
+		Major: uint32(((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)),
+		Minor: uint32((dev & 0xff) | ((dev >> 12) &^ 0xff)),
+		Inode: stat.Ino,
+	}, true
+}
@@ -0,0 +1,88 @@
+package locks_inspector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/flock"
+)
+
+func TestParseProcLocks(t *testing.T) {
+	data := `1: FLOCK  ADVISORY  WRITE 12345 fd:01:1048602 0 EOF
+1: -> FLOCK  ADVISORY  WRITE 12346 fd:01:1048602 0 EOF
+2: FLOCK  ADVISORY  READ 555 00:2e:77 0 EOF
+3: POSIX  ADVISORY  WRITE 777 fd:01:88 0 EOF
+4: FLOCK  ADVISORY  WRITE 0 fd:01:99 0 EOF
+`
+
+	pids, err := parseProcLocks(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[hostLockFileID]int{
+		{Major: 0xfd, Minor: 0x01, Inode: 1048602}: 12345,
+		{Major: 0x00, Minor: 0x2e, Inode: 77}:      555,
+		{Major: 0xfd, Minor: 0x01, Inode: 99}:      0,
+	}
+	if len(pids) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, pids)
+	}
+	for fileID, pid := range expected {
+		if actualPid, ok := pids[fileID]; !ok || actualPid != pid {
+			t.Errorf("expected pid %d for %v, got %d", pid, fileID, actualPid)
+		}
+	}
+
+	if _, err := parseProcLocks(strings.NewReader("1: FLOCK  ADVISORY  WRITE 1 bad 0 EOF\n")); err == nil {
+		t.Error("expected bad file id error")
+	}
+}
+
+func TestGetHeldHostLocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "werf-host-locks-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	heldPath := filepath.Join(dir, "held")
+	freePath := filepath.Join(dir, "free")
+	if err := ioutil.WriteFile(freePath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileLock := flock.New(heldPath)
+	if err := fileLock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer fileLock.Unlock()
+
+	heldLocks, err := getHeldHostLocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expectedHeld := range map[string]bool{heldPath: true, freePath: false} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fileID, ok := getHostLockFileID(info)
+		if !ok {
+			t.Fatalf("unable to get file id of %s", path)
+		}
+
+		owner, held := heldLocks[fileID]
+		if held != expectedHeld {
+			t.Errorf("expected held=%v for %s, got %v", expectedHeld, path, held)
+		}
+		if held && !strings.HasPrefix(owner, "pid ") {
+			t.Errorf("unexpected owner %q of %s", owner, path)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// +build !linux
+
+package locks_inspector
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// getHeldHostLocks is not supported on this platform: the held locks are listed by the kernel in /proc/locks on linux only.
+func getHeldHostLocks() (map[hostLockFileID]string, error) {
+	return nil, fmt.Errorf("%w on %s", errHostLocksInspectionNotSupported, runtime.GOOS)
+}
+
+func getHostLockFileID(_ os.FileInfo) (hostLockFileID, bool) {
+	return hostLockFileID{}, false
+}
@@ -0,0 +1,84 @@
+package locks_inspector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/werf/pkg/storage"
+)
+
+// ListKubernetesLeaseLocks returns the locks of the project stored in the Lease objects (--synchronization-kubernetes-leases).
+func ListKubernetesLeaseLocks(ctx context.Context, client kubernetes.Interface, namespace, projectName string, lockType LockType) ([]*LockDesc, error) {
+	list, err := client.CoordinationV1().Leases(namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", storage.LeaseProjectLabel, projectName)})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list leases in the namespace %q: %s", namespace, err)
+	}
+
+	var res []*LockDesc
+	for i := range list.Items {
+		lease := &list.Items[i]
+
+		desc := &LockDesc{
+			Type:          lockType,
+			Name:          lease.Annotations[storage.LeaseLockNameAnnotation],
+			Stale:         storage.IsLockLeaseExpired(lease),
+			SharedHolders: storage.GetLockLeaseSharedHoldersCount(lease),
+			lease:         lease,
+		}
+		desc.Shared = desc.SharedHolders > 0
+		if lease.Spec.HolderIdentity != nil {
+			desc.Owner = *lease.Spec.HolderIdentity
+		}
+		if lease.Spec.RenewTime != nil {
+			desc.LastActivityAt = lease.Spec.RenewTime.Time
+		}
+
+		res = append(res, desc)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// ReleaseStaleKubernetesLeaseLocks deletes the stale Lease objects of the project and returns the released locks.
+// The lease is deleted only if it has not been changed since listing, so the lease taken over by another werf process concurrently is kept.
+func ReleaseStaleKubernetesLeaseLocks(ctx context.Context, client kubernetes.Interface, namespace, projectName string, lockType LockType, dryRun bool) ([]*LockDesc, error) {
+	locks, err := ListKubernetesLeaseLocks(ctx, client, namespace, projectName, lockType)
+	if err != nil {
+		return nil, err
+	}
+
+	var released []*LockDesc
+	for _, desc := range locks {
+		if !desc.Stale {
+			continue
+		}
+
+		if !dryRun {
+			if err := deleteLease(ctx, client, desc.lease); errors.IsConflict(err) || errors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("unable to delete lease/%s in the namespace %q: %s", desc.lease.Name, namespace, err)
+			}
+		}
+
+		released = append(released, desc)
+	}
+
+	return released, nil
+}
+
+func deleteLease(ctx context.Context, client kubernetes.Interface, lease *coordinationv1.Lease) error {
+	return client.CoordinationV1().Leases(lease.Namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion},
+	})
+}
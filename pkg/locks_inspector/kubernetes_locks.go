@@ -0,0 +1,106 @@
+package locks_inspector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"github.com/werf/werf/pkg/storage"
+)
+
+// ListKubernetesLocks returns the locks stored in the specified ConfigMap by the lockgate kubernetes locker.
+// The lease of the living werf process is renewed periodically, the lease not renewed within the ttl is considered stale.
+func ListKubernetesLocks(ctx context.Context, client kubernetes.Interface, namespace, configMapName string, lockType LockType) ([]*LockDesc, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get cm/%s in the namespace %q: %s", configMapName, namespace, err)
+	}
+
+	return parseKubernetesLocks(cm, lockType)
+}
+
+// ReleaseStaleKubernetesLocks removes the stale lock leases from the specified ConfigMap and returns the released locks.
+// The ConfigMap is updated with the optimistic locking, so the lease taken over by another werf process concurrently is kept.
+func ReleaseStaleKubernetesLocks(ctx context.Context, client kubernetes.Interface, namespace, configMapName string, lockType LockType, dryRun bool) ([]*LockDesc, error) {
+	var released []*LockDesc
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		released = nil
+
+		cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		locks, err := parseKubernetesLocks(cm, lockType)
+		if err != nil {
+			return err
+		}
+
+		for _, desc := range locks {
+			if desc.Stale {
+				released = append(released, desc)
+				delete(cm.Annotations, desc.annotation)
+			}
+		}
+
+		if len(released) == 0 || dryRun {
+			return nil
+		}
+
+		_, err = client.CoreV1().ConfigMaps(namespace).Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to release stale locks in cm/%s in the namespace %q: %s", configMapName, namespace, err)
+	}
+
+	return released, nil
+}
+
+func parseKubernetesLocks(cm *corev1.ConfigMap, lockType LockType) ([]*LockDesc, error) {
+	var res []*LockDesc
+	for annotation, data := range cm.Annotations {
+		if !strings.HasPrefix(annotation, storage.LockgateAnnotationPrefix) || data == "" {
+			continue
+		}
+
+		var lease *distributed_locker.LockLeaseRecord
+		if err := json.Unmarshal([]byte(data), &lease); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal lock lease from cm/%s annotation %s: %s", cm.Name, annotation, err)
+		}
+
+		expireAt := time.Unix(lease.ExpireAtTimestamp, 0)
+		res = append(res, &LockDesc{
+			Type:           lockType,
+			Name:           lease.LockName,
+			Owner:          lease.UUID,
+			LastActivityAt: expireAt.Add(-distributed_locker.DistributedLockLeaseTTLSeconds * time.Second),
+			Stale:          time.Now().After(expireAt),
+			Shared:         lease.IsShared,
+			SharedHolders:  lease.SharedHoldersCount,
+			annotation:     annotation,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
@@ -0,0 +1,101 @@
+package locks_inspector
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+)
+
+func leaseAnnotation(t *testing.T, lockName, uuid string, expireAt time.Time) string {
+	data, err := json.Marshal(distributed_locker.LockLeaseRecord{
+		LockHandle:         lockgate.LockHandle{UUID: uuid, LockName: lockName},
+		ExpireAtTimestamp:  expireAt.Unix(),
+		SharedHoldersCount: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReleaseStaleKubernetesLocks(t *testing.T) {
+	ctx := context.Background()
+
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "werf-myproject",
+			Namespace: "werf-synchronization",
+			Annotations: map[string]string{
+				"lockgate.io/held":  leaseAnnotation(t, "myproject/stage/held", "uuid-1", time.Now().Add(time.Minute)),
+				"lockgate.io/stale": leaseAnnotation(t, "myproject/stage/stale", "uuid-2", time.Now().Add(-time.Minute)),
+				"lockgate.io/empty": "",
+				"other":             "value",
+			},
+		},
+	})
+
+	locks, err := ListKubernetesLocks(ctx, client, "werf-synchronization", "werf-myproject", StageLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 {
+		t.Fatalf("expected 2 locks, got %d", len(locks))
+	}
+	if locks[0].Name != "myproject/stage/held" || locks[0].Owner != "uuid-1" || locks[0].Stale {
+		t.Errorf("unexpected held lock: %#v", locks[0])
+	}
+	if locks[1].Name != "myproject/stage/stale" || locks[1].Owner != "uuid-2" || !locks[1].Stale {
+		t.Errorf("unexpected stale lock: %#v", locks[1])
+	}
+
+	released, err := ReleaseStaleKubernetesLocks(ctx, client, "werf-synchronization", "werf-myproject", StageLock, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 {
+		t.Fatalf("expected 1 released lock in dry run mode, got %d", len(released))
+	}
+	if locks, _ := ListKubernetesLocks(ctx, client, "werf-synchronization", "werf-myproject", StageLock); len(locks) != 2 {
+		t.Errorf("expected locks to be kept in dry run mode, got %d", len(locks))
+	}
+
+	released, err = ReleaseStaleKubernetesLocks(ctx, client, "werf-synchronization", "werf-myproject", StageLock, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0].Name != "myproject/stage/stale" {
+		t.Fatalf("unexpected released locks: %#v", released)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("werf-synchronization").Get(ctx, "werf-myproject", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, hasKey := cm.Annotations["lockgate.io/stale"]; hasKey {
+		t.Errorf("stale lock annotation should be removed")
+	}
+	if _, hasKey := cm.Annotations["lockgate.io/held"]; !hasKey {
+		t.Errorf("held lock annotation should be kept")
+	}
+	if cm.Annotations["other"] != "value" {
+		t.Errorf("other annotations should be kept")
+	}
+}
+
+func TestListKubernetesLocksWithoutConfigMap(t *testing.T) {
+	locks, err := ListKubernetesLocks(context.Background(), fake.NewSimpleClientset(), "default", "werf-synchronization", ReleaseLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("expected no locks, got %d", len(locks))
+	}
+}
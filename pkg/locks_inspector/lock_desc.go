@@ -0,0 +1,36 @@
+package locks_inspector
+
+import (
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+)
+
+type LockType string
+
+const (
+	HostLock    LockType = "host"
+	StageLock   LockType = "stage"
+	ReleaseLock LockType = "release"
+)
+
+// LockDesc describes the lock currently held by some werf process.
+type LockDesc struct {
+	Type LockType
+	Name string
+	// Owner is the pid of the host lock holder process or the uuid of the kubernetes lock lease.
+	Owner string
+	// LastActivityAt is the lock file creation time for the host lock and the last lease renewal time for the kubernetes lock.
+	LastActivityAt time.Time
+	// Stale lock is not renewed by the holder anymore (e.g. werf process has been killed in the crashed CI job).
+	Stale         bool
+	Shared        bool
+	SharedHolders int64
+
+	annotation string
+	lease      *coordinationv1.Lease
+}
+
+func (desc *LockDesc) Age() time.Duration {
+	return time.Since(desc.LastActivityAt).Truncate(time.Second)
+}
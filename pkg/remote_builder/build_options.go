@@ -0,0 +1,110 @@
+package remote_builder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+)
+
+// buildOptions are the docker build options supported by the remote builders.
+type buildOptions struct {
+	Filename  string
+	Target    string
+	Platform  string
+	Network   string
+	AddHosts  []string
+	BuildArgs map[string]string
+	Labels    map[string]string
+	CacheFrom []string
+	Secrets   []secretsprovider.Source
+	SSH       []sshprovider.AgentConfig
+}
+
+// parseDockerBuildArgs parses the docker build options in the --option=value format.
+func parseDockerBuildArgs(dockerBuildArgs []string) (*buildOptions, error) {
+	opts := &buildOptions{
+		Filename:  "Dockerfile",
+		BuildArgs: map[string]string{},
+		Labels:    map[string]string{},
+	}
+
+	for _, arg := range dockerBuildArgs {
+		parts := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(arg, "--") {
+			return nil, fmt.Errorf("unsupported docker build option %q for the remote builder", arg)
+		}
+
+		option, value := parts[0], parts[1]
+		switch option {
+		case "file":
+			opts.Filename = value
+		case "target":
+			opts.Target = value
+		case "platform":
+			opts.Platform = value
+		case "network":
+			opts.Network = value
+		case "add-host":
+			opts.AddHosts = append(opts.AddHosts, value)
+		case "build-arg", "label":
+			keyValue := strings.SplitN(value, "=", 2)
+			if len(keyValue) != 2 {
+				// docker takes the value of the build arg without value from the environment
+				keyValue = append(keyValue, os.Getenv(keyValue[0]))
+			}
+
+			if option == "build-arg" {
+				opts.BuildArgs[keyValue[0]] = keyValue[1]
+			} else {
+				opts.Labels[keyValue[0]] = keyValue[1]
+			}
+		case "cache-from":
+			opts.CacheFrom = append(opts.CacheFrom, value)
+		case "secret":
+			source, err := parseSecretSource(value)
+			if err != nil {
+				return nil, err
+			}
+
+			opts.Secrets = append(opts.Secrets, source)
+		case "ssh":
+			idPaths := strings.SplitN(value, "=", 2)
+
+			config := sshprovider.AgentConfig{ID: idPaths[0]}
+			if len(idPaths) == 2 {
+				config.Paths = strings.Split(idPaths[1], ",")
+			}
+
+			opts.SSH = append(opts.SSH, config)
+		default:
+			return nil, fmt.Errorf("unsupported docker build option %q for the remote builder", arg)
+		}
+	}
+
+	return opts, nil
+}
+
+func parseSecretSource(value string) (secretsprovider.Source, error) {
+	var source secretsprovider.Source
+
+	for _, field := range strings.Split(value, ",") {
+		keyValue := strings.SplitN(field, "=", 2)
+		if len(keyValue) != 2 {
+			return source, fmt.Errorf("unable to parse secret %q: expected id=ID,src=PATH format", value)
+		}
+
+		switch keyValue[0] {
+		case "id":
+			source.ID = keyValue[1]
+		case "src", "source":
+			source.FilePath = keyValue[1]
+		default:
+			return source, fmt.Errorf("unable to parse secret %q: unexpected field %q", value, keyValue[0])
+		}
+	}
+
+	return source, nil
+}
@@ -0,0 +1,285 @@
+package remote_builder
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/werf/logboek"
+)
+
+const (
+	KanikoAddressScheme = "kaniko"
+
+	DefaultKanikoImage = "gcr.io/kaniko-project/executor:v1.6.0"
+
+	kanikoContainerName = "kaniko"
+	// kaniko cannot push the image by digest, so the digest of the pushed image is returned by the termination message
+	kanikoDigestFile = "/dev/termination-log"
+)
+
+// kanikoOptions are parsed from the kaniko://NAMESPACE?image=IMAGE&docker-config-secret=SECRET&service-account=NAME address.
+type kanikoOptions struct {
+	Namespace          string
+	Image              string
+	DockerConfigSecret string
+	ServiceAccount     string
+}
+
+type kanikoBuilder struct {
+	kanikoOptions
+
+	KubeClient kubernetes.Interface
+	KubeConfig *rest.Config
+}
+
+func parseKanikoAddress(address string) (*kanikoOptions, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse remote builder address %q: %s", address, err)
+	}
+
+	if u.Scheme != KanikoAddressScheme {
+		return nil, fmt.Errorf("unexpected kaniko remote builder address %q: expected %s://NAMESPACE", address, KanikoAddressScheme)
+	}
+
+	opts := &kanikoOptions{
+		Namespace: u.Host,
+		Image:     DefaultKanikoImage,
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+
+		switch key {
+		case "image":
+			opts.Image = value
+		case "docker-config-secret":
+			opts.DockerConfigSecret = value
+		case "service-account":
+			opts.ServiceAccount = value
+		default:
+			return nil, fmt.Errorf("unexpected kaniko remote builder address %q parameter %q", address, key)
+		}
+	}
+
+	return opts, nil
+}
+
+// kanikoArgs translates the docker build options into the kaniko executor options.
+// The --cache-from images are not supported by kaniko and skipped, the build is not cached in this case.
+func kanikoArgs(opts *buildOptions, destination string) ([]string, error) {
+	switch {
+	case opts.Network != "":
+		return nil, fmt.Errorf("docker build option --network is not supported by kaniko")
+	case len(opts.AddHosts) != 0:
+		return nil, fmt.Errorf("docker build option --add-host is not supported by kaniko")
+	case len(opts.Secrets) != 0:
+		return nil, fmt.Errorf("dockerfile secrets are not supported by kaniko")
+	case len(opts.SSH) != 0:
+		return nil, fmt.Errorf("dockerfile ssh mounts are not supported by kaniko")
+	}
+
+	args := []string{
+		"--context=tar://stdin",
+		fmt.Sprintf("--dockerfile=%s", opts.Filename),
+		fmt.Sprintf("--destination=%s", destination),
+		fmt.Sprintf("--digest-file=%s", kanikoDigestFile),
+	}
+
+	if opts.Target != "" {
+		args = append(args, fmt.Sprintf("--target=%s", opts.Target))
+	}
+	if opts.Platform != "" {
+		args = append(args, fmt.Sprintf("--custom-platform=%s", opts.Platform))
+	}
+
+	args = append(args, sortedKeyValueArgs("--build-arg", opts.BuildArgs)...)
+	args = append(args, sortedKeyValueArgs("--label", opts.Labels)...)
+
+	return args, nil
+}
+
+func sortedKeyValueArgs(option string, values map[string]string) []string {
+	var args []string
+	for key, value := range values {
+		args = append(args, fmt.Sprintf("%s=%s=%s", option, key, value))
+	}
+	sort.Strings(args)
+
+	return args
+}
+
+func (b *kanikoBuilder) newPod(args []string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "werf-kaniko-",
+			Namespace:    b.Namespace,
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "werf"},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: b.ServiceAccount,
+			Containers: []corev1.Container{
+				{
+					Name:                   kanikoContainerName,
+					Image:                  b.Image,
+					Args:                   args,
+					Stdin:                  true,
+					StdinOnce:              true,
+					TerminationMessagePath: kanikoDigestFile,
+				},
+			},
+		},
+	}
+
+	if b.DockerConfigSecret != "" {
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "docker-config",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: b.DockerConfigSecret,
+						Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+					},
+				},
+			},
+		}
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "docker-config", MountPath: "/kaniko/.docker"}}
+	}
+
+	return pod
+}
+
+// Build runs the kaniko executor pod, passes the context archive into the pod stdin and returns the digest of the pushed image.
+func (b *kanikoBuilder) Build(ctx context.Context, contextArchivePath string, opts *buildOptions, destination string) (string, error) {
+	args, err := kanikoArgs(opts, destination)
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := b.KubeClient.CoreV1().Pods(b.Namespace).Create(ctx, b.newPod(args), metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to create kaniko pod in the namespace %s: %s", b.Namespace, err)
+	}
+	defer func() {
+		if err := b.KubeClient.CoreV1().Pods(b.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{}); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to delete kaniko pod %s/%s: %s\n", b.Namespace, pod.Name, err)
+		}
+	}()
+
+	if _, err := b.waitForPod(ctx, pod.Name, func(pod *corev1.Pod) bool {
+		return pod.Status.Phase != corev1.PodPending
+	}); err != nil {
+		return "", fmt.Errorf("kaniko pod %s/%s is not started: %s", b.Namespace, pod.Name, err)
+	}
+
+	if err := b.attach(ctx, pod.Name, contextArchivePath); err != nil {
+		return "", fmt.Errorf("kaniko pod %s/%s build failed: %s", b.Namespace, pod.Name, err)
+	}
+
+	pod, err = b.waitForPod(ctx, pod.Name, func(pod *corev1.Pod) bool {
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+	})
+	if err != nil {
+		return "", fmt.Errorf("kaniko pod %s/%s is not finished: %s", b.Namespace, pod.Name, err)
+	}
+
+	return getKanikoPodDigest(pod)
+}
+
+func (b *kanikoBuilder) waitForPod(ctx context.Context, name string, condition func(pod *corev1.Pod) bool) (*corev1.Pod, error) {
+	var pod *corev1.Pod
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		var err error
+		pod, err = b.KubeClient.CoreV1().Pods(b.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		return condition(pod), nil
+	}, ctx.Done())
+
+	return pod, err
+}
+
+// attach streams the gzipped context archive into the kaniko stdin (tar://stdin context) and the build output into the log.
+func (b *kanikoBuilder) attach(ctx context.Context, podName, contextArchivePath string) error {
+	req := b.KubeClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(b.Namespace).
+		SubResource("attach").
+		VersionedParams(&corev1.PodAttachOptions{
+			Container: kanikoContainerName,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(b.KubeConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("unable to create executor: %s", err)
+	}
+
+	f, err := os.Open(contextArchivePath)
+	if err != nil {
+		return fmt.Errorf("unable to open context archive %q: %s", contextArchivePath, err)
+	}
+	defer f.Close()
+
+	stdin, stdinWriter := io.Pipe()
+	go func() {
+		gzipWriter := gzip.NewWriter(stdinWriter)
+		_, err := io.Copy(gzipWriter, f)
+		if err == nil {
+			err = gzipWriter.Close()
+		}
+		stdinWriter.CloseWithError(err)
+	}()
+
+	return executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: logboek.Context(ctx).OutStream(),
+		Stderr: logboek.Context(ctx).ErrStream(),
+	})
+}
+
+func getKanikoPodDigest(pod *corev1.Pod) (string, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != kanikoContainerName || status.State.Terminated == nil {
+			continue
+		}
+
+		terminated := status.State.Terminated
+		if terminated.ExitCode != 0 {
+			return "", fmt.Errorf("kaniko exited with code %d: %s", terminated.ExitCode, strings.TrimSpace(terminated.Message))
+		}
+
+		digest := strings.TrimSpace(terminated.Message)
+		if !strings.HasPrefix(digest, "sha256:") {
+			return "", fmt.Errorf("kaniko did not return the pushed image digest, got %q", digest)
+		}
+
+		return digest, nil
+	}
+
+	return "", fmt.Errorf("kaniko container of pod %s/%s is not terminated", pod.Namespace, pod.Name)
+}
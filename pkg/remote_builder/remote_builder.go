@@ -0,0 +1,194 @@
+package remote_builder
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/moby/buildkit/client"
+	_ "github.com/moby/buildkit/client/connhelper/dockercontainer"
+	_ "github.com/moby/buildkit/client/connhelper/kubepod"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+var (
+	defaultClient        *client.Client
+	defaultKanikoBuilder *kanikoBuilder
+	builderAddress       string
+)
+
+type Options struct {
+	// Address is the buildkitd address: tcp://HOST:PORT, unix:///PATH, kube-pod://POD?namespace=NAMESPACE or docker-container://CONTAINER,
+	// or the kaniko address: kaniko://NAMESPACE?image=IMAGE&docker-config-secret=SECRET&service-account=NAME
+	Address string
+
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+
+	// KubeConfigOptions are used to run the kaniko pods
+	KubeConfigOptions kube.KubeConfigOptions
+}
+
+func Init(ctx context.Context, opts Options) error {
+	if opts.Address == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(opts.Address, KanikoAddressScheme+"://") {
+		return initKaniko(opts)
+	}
+
+	var clientOpts []client.ClientOpt
+	if opts.TLSCA != "" || opts.TLSCert != "" || opts.TLSKey != "" {
+		if opts.TLSCA == "" {
+			return fmt.Errorf("CA certificate should be specified to connect to the remote builder %s with TLS", opts.Address)
+		}
+
+		u, err := url.Parse(opts.Address)
+		if err != nil {
+			return fmt.Errorf("unable to parse remote builder address %q: %s", opts.Address, err)
+		}
+
+		clientOpts = append(clientOpts, client.WithCredentials(u.Hostname(), opts.TLSCA, opts.TLSCert, opts.TLSKey))
+	}
+
+	c, err := client.New(ctx, opts.Address, clientOpts...)
+	if err != nil {
+		return fmt.Errorf("unable to create remote builder %s client: %s", opts.Address, err)
+	}
+
+	defaultClient = c
+	builderAddress = opts.Address
+
+	return nil
+}
+
+func initKaniko(opts Options) error {
+	kanikoOpts, err := parseKanikoAddress(opts.Address)
+	if err != nil {
+		return err
+	}
+
+	config, err := kube.GetKubeConfig(opts.KubeConfigOptions)
+	if err != nil {
+		return fmt.Errorf("unable to load kube config to run kaniko pods: %s", err)
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(config.Config)
+	if err != nil {
+		return fmt.Errorf("unable to create kubernetes client to run kaniko pods: %s", err)
+	}
+
+	defaultKanikoBuilder = &kanikoBuilder{kanikoOptions: *kanikoOpts, KubeClient: kubeClient, KubeConfig: config.Config}
+	builderAddress = opts.Address
+
+	return nil
+}
+
+// IsEnabled returns true if Dockerfile stages are built by the remote builder instead of the local docker server.
+func IsEnabled() bool {
+	return defaultClient != nil || defaultKanikoBuilder != nil
+}
+
+// IsPushedByTag returns true if the remote builder cannot push the image by digest (kaniko),
+// so the image should be pushed with the stage image name generated before the build (see Build).
+func IsPushedByTag() bool {
+	return defaultKanikoBuilder != nil
+}
+
+func Address() string {
+	return builderAddress
+}
+
+// Build builds the Dockerfile from the context archive with the remote builder.
+// The buildkitd pushes the image into the repo by digest without a tag, kaniko pushes the image as the stageImageName.
+// The build options are the docker build options in the --option=value format, which are translated into the remote builder options.
+// The result is the pushed image reference in the REPO@DIGEST format.
+func Build(ctx context.Context, contextArchivePath string, dockerBuildArgs []string, repo, stageImageName string) (string, error) {
+	opts, err := parseDockerBuildArgs(dockerBuildArgs)
+	if err != nil {
+		return "", err
+	}
+
+	if defaultKanikoBuilder != nil {
+		if stageImageName == "" {
+			return "", fmt.Errorf("kaniko remote builder requires the stage image name to push the built image")
+		}
+
+		digest, err := defaultKanikoBuilder.Build(ctx, contextArchivePath, opts, stageImageName)
+		if err != nil {
+			return "", fmt.Errorf("remote build by %s failed: %s", builderAddress, err)
+		}
+
+		return fmt.Sprintf("%s@%s", repo, digest), nil
+	}
+
+	return buildWithBuildkit(ctx, contextArchivePath, opts, repo)
+}
+
+func buildWithBuildkit(ctx context.Context, contextArchivePath string, opts *buildOptions, repo string) (string, error) {
+	contextDir, err := ioutil.TempDir(werf.GetTmpDir(), "werf-remote-build-context-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	if err := extractContextArchive(contextArchivePath, contextDir); err != nil {
+		return "", err
+	}
+
+	solveOpt, err := newSolveOpt(contextDir, opts, repo)
+	if err != nil {
+		return "", err
+	}
+
+	var solveResponse *client.SolveResponse
+	statusChan := make(chan *client.SolveStatus)
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		resp, err := defaultClient.Solve(egCtx, nil, solveOpt, statusChan)
+		solveResponse = resp
+		return err
+	})
+	eg.Go(func() error {
+		return progressui.DisplaySolveStatus(context.Background(), "", nil, logboek.Context(ctx).OutStream(), statusChan)
+	})
+
+	if err := eg.Wait(); err != nil {
+		return "", fmt.Errorf("remote build by %s failed: %s", builderAddress, err)
+	}
+
+	digest, ok := solveResponse.ExporterResponse["containerimage.digest"]
+	if !ok {
+		return "", fmt.Errorf("remote builder %s did not return the pushed image digest", builderAddress)
+	}
+
+	return fmt.Sprintf("%s@%s", repo, digest), nil
+}
+
+func extractContextArchive(contextArchivePath, contextDir string) error {
+	f, err := os.Open(contextArchivePath)
+	if err != nil {
+		return fmt.Errorf("unable to open context archive %q: %s", contextArchivePath, err)
+	}
+	defer f.Close()
+
+	if err := archive.Untar(f, contextDir, &archive.TarOptions{NoLchown: true}); err != nil {
+		return fmt.Errorf("unable to extract context archive %q: %s", contextArchivePath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package remote_builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseDockerBuildArgs(t *testing.T) {
+	t.Setenv("WERF_TEST_ARG", "from-env")
+
+	opts, err := parseDockerBuildArgs([]string{
+		"--file=build/Dockerfile",
+		"--target=app",
+		"--build-arg=VERSION=1.0",
+		"--build-arg=WERF_TEST_ARG",
+		"--label=werf=project",
+		"--cache-from=registry.example.com/app:cache",
+		"--secret=id=token,src=/tmp/token",
+		"--ssh=default=/tmp/agent.sock",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.Filename != "build/Dockerfile" || opts.Target != "app" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if !reflect.DeepEqual(opts.BuildArgs, map[string]string{"VERSION": "1.0", "WERF_TEST_ARG": "from-env"}) {
+		t.Fatalf("unexpected build args %v", opts.BuildArgs)
+	}
+	if !reflect.DeepEqual(opts.Labels, map[string]string{"werf": "project"}) {
+		t.Fatalf("unexpected labels %v", opts.Labels)
+	}
+	if len(opts.CacheFrom) != 1 || len(opts.Secrets) != 1 || opts.Secrets[0].ID != "token" || opts.Secrets[0].FilePath != "/tmp/token" {
+		t.Fatalf("unexpected cache from or secrets %+v", opts)
+	}
+	if len(opts.SSH) != 1 || opts.SSH[0].ID != "default" || !reflect.DeepEqual(opts.SSH[0].Paths, []string{"/tmp/agent.sock"}) {
+		t.Fatalf("unexpected ssh %+v", opts.SSH)
+	}
+
+	for _, arg := range []string{"--squash", "-t=image", "--compress=true", "--secret=id=token,mode=0400"} {
+		if _, err := parseDockerBuildArgs([]string{arg}); err == nil {
+			t.Errorf("%q: expected error", arg)
+		}
+	}
+}
+
+func TestNewSolveOpt(t *testing.T) {
+	opts, err := parseDockerBuildArgs([]string{"--target=app", "--network=host", "--add-host=a:1.1.1.1", "--add-host=b:2.2.2.2", "--build-arg=A=1", "--label=L=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solveOpt, err := newSolveOpt("/context", opts, "registry.example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedAttrs := map[string]string{
+		"filename":           "Dockerfile",
+		"target":             "app",
+		"force-network-mode": "host",
+		"add-hosts":          "a:1.1.1.1,b:2.2.2.2",
+		"build-arg:A":        "1",
+		"label:L":            "2",
+	}
+	if !reflect.DeepEqual(solveOpt.FrontendAttrs, expectedAttrs) {
+		t.Fatalf("expected %v, got %v", expectedAttrs, solveOpt.FrontendAttrs)
+	}
+
+	exportAttrs := solveOpt.Exports[0].Attrs
+	if exportAttrs["name"] != "registry.example.com/app" || exportAttrs["push-by-digest"] != "true" {
+		t.Fatalf("unexpected export attrs %v", exportAttrs)
+	}
+}
+
+func TestParseKanikoAddress(t *testing.T) {
+	opts, err := parseKanikoAddress("kaniko://builds?image=kaniko:debug&docker-config-secret=regcred&service-account=builder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := kanikoOptions{Namespace: "builds", Image: "kaniko:debug", DockerConfigSecret: "regcred", ServiceAccount: "builder"}
+	if *opts != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *opts)
+	}
+
+	opts, err = parseKanikoAddress("kaniko://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Namespace != "default" || opts.Image != DefaultKanikoImage {
+		t.Fatalf("unexpected defaults %+v", opts)
+	}
+
+	if _, err := parseKanikoAddress("kaniko://builds?unknown=1"); err == nil {
+		t.Fatal("expected error for the unknown parameter")
+	}
+}
+
+func TestKanikoArgs(t *testing.T) {
+	opts, err := parseDockerBuildArgs([]string{"--file=Dockerfile.app", "--target=app", "--build-arg=B=2", "--build-arg=A=1", "--label=werf=project", "--cache-from=registry.example.com/app:cache"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := kanikoArgs(opts, "registry.example.com/app:abc-1611836746968")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"--context=tar://stdin",
+		"--dockerfile=Dockerfile.app",
+		"--destination=registry.example.com/app:abc-1611836746968",
+		"--digest-file=/dev/termination-log",
+		"--target=app",
+		"--build-arg=A=1",
+		"--build-arg=B=2",
+		"--label=werf=project",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected %v, got %v", expected, args)
+	}
+
+	for _, arg := range []string{"--network=host", "--add-host=a:1.1.1.1", "--secret=id=token,src=/tmp/token", "--ssh=default"} {
+		opts, err := parseDockerBuildArgs([]string{arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := kanikoArgs(opts, "registry.example.com/app:tag"); err == nil {
+			t.Errorf("%q: expected error", arg)
+		}
+	}
+}
+
+func TestKanikoBuilderNewPod(t *testing.T) {
+	b := &kanikoBuilder{kanikoOptions: kanikoOptions{Namespace: "builds", Image: "kaniko", DockerConfigSecret: "regcred", ServiceAccount: "builder"}}
+	pod := b.newPod([]string{"--context=tar://stdin"})
+
+	if pod.Namespace != "builds" || pod.Spec.RestartPolicy != corev1.RestartPolicyNever || pod.Spec.ServiceAccountName != "builder" {
+		t.Fatalf("unexpected pod %+v", pod)
+	}
+
+	container := pod.Spec.Containers[0]
+	if !container.Stdin || !container.StdinOnce || container.TerminationMessagePath != kanikoDigestFile {
+		t.Fatalf("unexpected container %+v", container)
+	}
+
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].Secret.SecretName != "regcred" || container.VolumeMounts[0].MountPath != "/kaniko/.docker" {
+		t.Fatalf("unexpected docker config volume %+v", pod.Spec.Volumes)
+	}
+}
+
+func TestGetKanikoPodDigest(t *testing.T) {
+	newPod := func(exitCode int32, message string) *corev1.Pod {
+		return &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: kanikoContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message}}},
+		}}}
+	}
+
+	digest, err := getKanikoPodDigest(newPod(0, "sha256:0123456789abcdef\n"))
+	if err != nil || digest != "sha256:0123456789abcdef" {
+		t.Fatalf("unexpected digest %q, %v", digest, err)
+	}
+
+	if _, err := getKanikoPodDigest(newPod(1, "error building image")); err == nil || !strings.Contains(err.Error(), "exited with code 1") {
+		t.Fatalf("expected exit code error, got %v", err)
+	}
+
+	if _, err := getKanikoPodDigest(newPod(0, "")); err == nil {
+		t.Fatal("expected error for the empty digest")
+	}
+
+	if _, err := getKanikoPodDigest(&corev1.Pod{}); err == nil {
+		t.Fatal("expected error for the not terminated container")
+	}
+}
@@ -0,0 +1,83 @@
+package remote_builder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+)
+
+// newSolveOpt translates the docker build options into the buildkit dockerfile frontend options.
+func newSolveOpt(contextDir string, opts *buildOptions, repo string) (client.SolveOpt, error) {
+	frontendAttrs := map[string]string{"filename": opts.Filename}
+
+	if opts.Target != "" {
+		frontendAttrs["target"] = opts.Target
+	}
+	if opts.Platform != "" {
+		frontendAttrs["platform"] = opts.Platform
+	}
+	if opts.Network != "" {
+		frontendAttrs["force-network-mode"] = opts.Network
+	}
+	if len(opts.AddHosts) != 0 {
+		frontendAttrs["add-hosts"] = strings.Join(opts.AddHosts, ",")
+	}
+	for key, value := range opts.BuildArgs {
+		frontendAttrs[fmt.Sprintf("build-arg:%s", key)] = value
+	}
+	for key, value := range opts.Labels {
+		frontendAttrs[fmt.Sprintf("label:%s", key)] = value
+	}
+
+	var cacheImports []client.CacheOptionsEntry
+	for _, ref := range opts.CacheFrom {
+		cacheImports = append(cacheImports, client.CacheOptionsEntry{Type: "registry", Attrs: map[string]string{"ref": ref}})
+	}
+
+	attachables := []session.Attachable{authprovider.NewDockerAuthProvider(os.Stderr)}
+
+	if len(opts.Secrets) != 0 {
+		store, err := secretsprovider.NewStore(opts.Secrets)
+		if err != nil {
+			return client.SolveOpt{}, fmt.Errorf("unable to create secrets store: %s", err)
+		}
+
+		attachables = append(attachables, secretsprovider.NewSecretProvider(store))
+	}
+
+	if len(opts.SSH) != 0 {
+		sshProvider, err := sshprovider.NewSSHAgentProvider(opts.SSH)
+		if err != nil {
+			return client.SolveOpt{}, fmt.Errorf("unable to create ssh agent provider: %s", err)
+		}
+
+		attachables = append(attachables, sshProvider)
+	}
+
+	return client.SolveOpt{
+		Frontend:      "dockerfile.v0",
+		FrontendAttrs: frontendAttrs,
+		LocalDirs: map[string]string{
+			"context":    contextDir,
+			"dockerfile": contextDir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type: client.ExporterImage,
+				Attrs: map[string]string{
+					"name":           repo,
+					"push":           "true",
+					"push-by-digest": "true",
+				},
+			},
+		},
+		CacheImports: cacheImports,
+		Session:      attachables,
+	}, nil
+}
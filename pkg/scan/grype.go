@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func NewGrypeScanner() *GrypeScanner {
+	return &GrypeScanner{}
+}
+
+// GrypeScanner runs grype binary available in the PATH.
+type GrypeScanner struct{}
+
+func (s *GrypeScanner) Name() string {
+	return ScannerGrype
+}
+
+func (s *GrypeScanner) Scan(ctx context.Context, imageRef string) (*Result, error) {
+	output, err := runScanner(ctx, "grype", "--quiet", "--output", "json", imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGrypeOutput(output)
+}
+
+func parseGrypeOutput(data []byte) (*Result, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID       string `json:"id"`
+				Severity string `json:"severity"`
+				Fix      struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal grype json output: %s", err)
+	}
+
+	res := &Result{Scanner: ScannerGrype}
+	for _, match := range report.Matches {
+		severity, err := ParseSeverity(match.Vulnerability.Severity)
+		if err != nil {
+			// grype negligible severity is below low
+			severity = SeverityUnknown
+			if strings.EqualFold(match.Vulnerability.Severity, "negligible") {
+				severity = SeverityLow
+			}
+		}
+
+		res.Vulnerabilities = append(res.Vulnerabilities, Vulnerability{
+			ID:               match.Vulnerability.ID,
+			Package:          match.Artifact.Name,
+			InstalledVersion: match.Artifact.Version,
+			FixedVersion:     strings.Join(match.Vulnerability.Fix.Versions, ", "),
+			Severity:         severity,
+		})
+	}
+
+	return res, nil
+}
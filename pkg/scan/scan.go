@@ -0,0 +1,116 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityUnknown  Severity = "UNKNOWN"
+	SeverityLow      Severity = "LOW"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityHigh     Severity = "HIGH"
+	SeverityCritical Severity = "CRITICAL"
+)
+
+// Severities are ordered from the lowest to the highest.
+var Severities = []Severity{SeverityUnknown, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+func ParseSeverity(value string) (Severity, error) {
+	for _, severity := range Severities {
+		if strings.EqualFold(string(severity), value) {
+			return severity, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported severity %q, expected one of %v", value, Severities)
+}
+
+func (s Severity) rank() int {
+	for ind, severity := range Severities {
+		if severity == s {
+			return ind
+		}
+	}
+
+	return 0
+}
+
+type Vulnerability struct {
+	ID               string
+	Package          string
+	InstalledVersion string
+	FixedVersion     string
+	Severity         Severity
+}
+
+type Result struct {
+	Scanner         string
+	Vulnerabilities []Vulnerability
+}
+
+func (r *Result) Count(severity Severity) int {
+	var count int
+	for _, vuln := range r.Vulnerabilities {
+		if vuln.Severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
+
+// CountAtOrAbove returns the number of vulnerabilities with the severity equal to or higher than the threshold.
+func (r *Result) CountAtOrAbove(threshold Severity) int {
+	var count int
+	for _, vuln := range r.Vulnerabilities {
+		if vuln.Severity.rank() >= threshold.rank() {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Scanner scans the image available in the registry or in the local docker server for vulnerabilities.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, imageRef string) (*Result, error)
+}
+
+const (
+	ScannerTrivy = "trivy"
+	ScannerGrype = "grype"
+)
+
+var ScannerList = []string{ScannerTrivy, ScannerGrype}
+
+func NewScanner(name string) (Scanner, error) {
+	switch name {
+	case ScannerTrivy:
+		return NewTrivyScanner(), nil
+	case ScannerGrype:
+		return NewGrypeScanner(), nil
+	default:
+		return nil, fmt.Errorf("unsupported scanner %q, expected one of %v", name, ScannerList)
+	}
+}
+
+func runScanner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s %s failed: %s\n%s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
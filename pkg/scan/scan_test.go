@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	for value, expected := range map[string]Severity{
+		"critical": SeverityCritical,
+		"High":     SeverityHigh,
+		"MEDIUM":   SeverityMedium,
+		"low":      SeverityLow,
+		"Unknown":  SeverityUnknown,
+	} {
+		severity, err := ParseSeverity(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if severity != expected {
+			t.Fatalf("expected severity %s for %q, got %s", expected, value, severity)
+		}
+	}
+
+	if _, err := ParseSeverity("negligible"); err == nil {
+		t.Fatal("expected unsupported severity error")
+	}
+}
+
+func TestResult_Count(t *testing.T) {
+	res := &Result{Vulnerabilities: []Vulnerability{
+		{ID: "1", Severity: SeverityCritical},
+		{ID: "2", Severity: SeverityHigh},
+		{ID: "3", Severity: SeverityHigh},
+		{ID: "4", Severity: SeverityLow},
+		{ID: "5", Severity: SeverityUnknown},
+	}}
+
+	if count := res.Count(SeverityHigh); count != 2 {
+		t.Fatalf("expected 2 high vulnerabilities, got %d", count)
+	}
+	if count := res.Count(SeverityMedium); count != 0 {
+		t.Fatalf("expected no medium vulnerabilities, got %d", count)
+	}
+
+	for threshold, expected := range map[Severity]int{
+		SeverityCritical: 1,
+		SeverityHigh:     3,
+		SeverityMedium:   3,
+		SeverityLow:      4,
+		SeverityUnknown:  5,
+	} {
+		if count := res.CountAtOrAbove(threshold); count != expected {
+			t.Fatalf("expected %d vulnerabilities at or above %s, got %d", expected, threshold, count)
+		}
+	}
+}
+
+func TestParseTrivyOutput(t *testing.T) {
+	expected := []Vulnerability{
+		{ID: "CVE-1", Package: "openssl", InstalledVersion: "1.1.1", FixedVersion: "1.1.2", Severity: SeverityCritical},
+		{ID: "CVE-2", Package: "zlib", InstalledVersion: "1.2", Severity: SeverityUnknown},
+	}
+
+	for _, output := range []string{
+		// trivy v0.20 and later
+		`{"Results": [{"Target": "alpine", "Vulnerabilities": [
+			{"VulnerabilityID": "CVE-1", "PkgName": "openssl", "InstalledVersion": "1.1.1", "FixedVersion": "1.1.2", "Severity": "CRITICAL"},
+			{"VulnerabilityID": "CVE-2", "PkgName": "zlib", "InstalledVersion": "1.2", "Severity": "NONE"}
+		]}, {"Target": "app"}]}`,
+		// trivy prior to v0.20
+		`[{"Target": "alpine", "Vulnerabilities": [
+			{"VulnerabilityID": "CVE-1", "PkgName": "openssl", "InstalledVersion": "1.1.1", "FixedVersion": "1.1.2", "Severity": "CRITICAL"},
+			{"VulnerabilityID": "CVE-2", "PkgName": "zlib", "InstalledVersion": "1.2", "Severity": "NONE"}
+		]}]`,
+	} {
+		res, err := parseTrivyOutput([]byte(output))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Scanner != ScannerTrivy {
+			t.Fatalf("unexpected scanner %q", res.Scanner)
+		}
+		if !reflect.DeepEqual(res.Vulnerabilities, expected) {
+			t.Fatalf("expected vulnerabilities %v, got %v", expected, res.Vulnerabilities)
+		}
+	}
+
+	if _, err := parseTrivyOutput([]byte("not json")); err == nil {
+		t.Fatal("expected unmarshal error")
+	}
+}
+
+func TestParseGrypeOutput(t *testing.T) {
+	output := `{"matches": [
+		{"vulnerability": {"id": "CVE-1", "severity": "High", "fix": {"versions": ["1.1.2", "1.2.0"]}}, "artifact": {"name": "openssl", "version": "1.1.1"}},
+		{"vulnerability": {"id": "CVE-2", "severity": "Negligible"}, "artifact": {"name": "zlib", "version": "1.2"}},
+		{"vulnerability": {"id": "CVE-3", "severity": "Whatever"}, "artifact": {"name": "curl", "version": "7.0"}}
+	]}`
+
+	res, err := parseGrypeOutput([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Vulnerability{
+		{ID: "CVE-1", Package: "openssl", InstalledVersion: "1.1.1", FixedVersion: "1.1.2, 1.2.0", Severity: SeverityHigh},
+		{ID: "CVE-2", Package: "zlib", InstalledVersion: "1.2", Severity: SeverityLow},
+		{ID: "CVE-3", Package: "curl", InstalledVersion: "7.0", Severity: SeverityUnknown},
+	}
+	if res.Scanner != ScannerGrype {
+		t.Fatalf("unexpected scanner %q", res.Scanner)
+	}
+	if !reflect.DeepEqual(res.Vulnerabilities, expected) {
+		t.Fatalf("expected vulnerabilities %v, got %v", expected, res.Vulnerabilities)
+	}
+}
+
+func TestNewScanner(t *testing.T) {
+	for _, name := range ScannerList {
+		scanner, err := NewScanner(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scanner.Name() != name {
+			t.Fatalf("expected scanner %q, got %q", name, scanner.Name())
+		}
+	}
+
+	if _, err := NewScanner("clair"); err == nil {
+		t.Fatal("expected unsupported scanner error")
+	}
+}
+
+func TestRunScanner(t *testing.T) {
+	output, err := runScanner(context.Background(), "sh", "-c", "echo out; echo err >&2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "out\n" {
+		t.Fatalf("expected only stdout in the output, got %q", output)
+	}
+
+	_, err = runScanner(context.Background(), "sh", "-c", "echo scan error >&2; exit 1")
+	if expected := "sh -c echo scan error >&2; exit 1 failed: exit status 1\nscan error"; err == nil || err.Error() != expected {
+		t.Fatalf("expected the error %q, got %v", expected, err)
+	}
+}
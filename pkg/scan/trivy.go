@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+func NewTrivyScanner() *TrivyScanner {
+	return &TrivyScanner{}
+}
+
+// TrivyScanner runs trivy binary available in the PATH.
+type TrivyScanner struct{}
+
+func (s *TrivyScanner) Name() string {
+	return ScannerTrivy
+}
+
+func (s *TrivyScanner) Scan(ctx context.Context, imageRef string) (*Result, error) {
+	output, err := runScanner(ctx, "trivy", "image", "--quiet", "--no-progress", "--format", "json", imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTrivyOutput(output)
+}
+
+type trivyResult struct {
+	Target          string
+	Vulnerabilities []struct {
+		VulnerabilityID  string
+		PkgName          string
+		InstalledVersion string
+		FixedVersion     string
+		Severity         string
+	}
+}
+
+func parseTrivyOutput(data []byte) (*Result, error) {
+	// trivy prior to v0.20 outputs the list of results, the later versions output the report object
+	var report struct {
+		Results []trivyResult
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		if err := json.Unmarshal(data, &report.Results); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal trivy json output: %s", err)
+		}
+	}
+
+	res := &Result{Scanner: ScannerTrivy}
+	for _, trivyRes := range report.Results {
+		for _, vuln := range trivyRes.Vulnerabilities {
+			severity, err := ParseSeverity(vuln.Severity)
+			if err != nil {
+				severity = SeverityUnknown
+			}
+
+			res.Vulnerabilities = append(res.Vulnerabilities, Vulnerability{
+				ID:               vuln.VulnerabilityID,
+				Package:          vuln.PkgName,
+				InstalledVersion: vuln.InstalledVersion,
+				FixedVersion:     vuln.FixedVersion,
+				Severity:         severity,
+			})
+		}
+	}
+
+	return res, nil
+}
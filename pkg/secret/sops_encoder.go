@@ -0,0 +1,122 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	SopsDataTypeYaml   = "yaml"
+	SopsDataTypeBinary = "binary"
+)
+
+// SopsEncoder encrypts and decrypts the data with the sops binary (https://github.com/mozilla/sops).
+// The keys (age, PGP, AWS KMS, GCP KMS, Azure Key Vault, HashiCorp Vault) are selected by the creation rules of the .sops.yaml file found in the working directory or its parents.
+type SopsEncoder struct {
+	WorkingDir string
+	// DataType is the sops type of the plain data: yaml for the secret values, binary for the secret files
+	DataType string
+}
+
+func NewSopsEncoder(workingDir, dataType string) *SopsEncoder {
+	return &SopsEncoder{WorkingDir: workingDir, DataType: dataType}
+}
+
+func (s *SopsEncoder) Encrypt(data []byte) ([]byte, error) {
+	return s.EncryptFile("", data)
+}
+
+func (s *SopsEncoder) Decrypt(data []byte) ([]byte, error) {
+	return s.DecryptFile("", data)
+}
+
+// EncryptFile encrypts the data of the file, the file path is used to select the creation rule by path_regex.
+func (s *SopsEncoder) EncryptFile(filePath string, data []byte) ([]byte, error) {
+	return s.run(filePath, data, "--encrypt", "--input-type", s.DataType, "--output-type", SopsDataTypeYaml)
+}
+
+func (s *SopsEncoder) DecryptFile(filePath string, data []byte) ([]byte, error) {
+	if !IsSopsEncryptedData(data) {
+		return nil, fmt.Errorf("sops metadata is not found: the data is not encrypted with sops")
+	}
+
+	return s.run(filePath, data, "--decrypt", "--input-type", SopsDataTypeYaml, "--output-type", s.DataType)
+}
+
+// run passes the data to sops in the temporary file named as the original file,
+// the original path relative to the working dir is passed with --filename-override to match the .sops.yaml creation rules.
+func (s *SopsEncoder) run(filePath string, data []byte, args ...string) ([]byte, error) {
+	sopsBin := os.Getenv("WERF_SOPS_BIN")
+	if sopsBin == "" {
+		sopsBin = "sops"
+	}
+
+	tmpDir, err := ioutil.TempDir(werf.GetTmpDir(), "werf-sops-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpFileName := "data"
+	if filePath != "" {
+		tmpFileName = filepath.Base(filePath)
+		args = append(args, "--filename-override", s.getOverrideFilePath(filePath))
+	}
+
+	tmpFilePath := filepath.Join(tmpDir, tmpFileName)
+	if err := ioutil.WriteFile(tmpFilePath, data, 0o600); err != nil {
+		return nil, fmt.Errorf("unable to write tmp file %s: %s", tmpFilePath, err)
+	}
+
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.Command(sopsBin, append(args, tmpFilePath)...)
+	cmd.Dir = s.WorkingDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s %s failed: %s\n%s", sopsBin, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (s *SopsEncoder) getOverrideFilePath(filePath string) string {
+	if !filepath.IsAbs(filePath) || s.WorkingDir == "" {
+		return filepath.ToSlash(filePath)
+	}
+
+	relPath, err := filepath.Rel(s.WorkingDir, filePath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return filepath.ToSlash(filePath)
+	}
+
+	return filepath.ToSlash(relPath)
+}
+
+// IsSopsEncryptedData checks that the data is the yaml document with the sops metadata: the top-level sops map with the mac and the version.
+func IsSopsEncryptedData(data []byte) bool {
+	var document struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return false
+	}
+
+	_, hasMac := document.Sops["mac"]
+	_, hasVersion := document.Sops["version"]
+
+	return hasMac && hasVersion
+}
@@ -0,0 +1,156 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+const sopsEncryptedData = "key: ENC[AES256_GCM,data:Tr7o=,iv:1=,tag:2=,type:str]\nsops:\n  mac: ENC[AES256_GCM,data:3=,iv:4=,tag:5=,type:str]\n  version: 3.7.1\n"
+
+func TestIsSopsEncryptedData(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected bool
+	}{
+		{data: "key: value\n", expected: false},
+		{data: "key: 1000ab\nsops: value\n", expected: false},
+		{data: "key: 1000ab\nsops:\n  enabled: true\n", expected: false},
+		{data: "key: 1000ab\nsops:\n  version: 3.7.1\n", expected: false},
+		{data: sopsEncryptedData, expected: true},
+		{data: "data: ENC[AES256_GCM,data:Tr7o=,iv:1=,tag:2=,type:str]\nsops:\n  age:\n  - recipient: age1\n  mac: ENC[]\n  version: 3.8.1\n", expected: true},
+		{data: "1000ab", expected: false},
+		{data: "- sops\n", expected: false},
+	}
+
+	for _, test := range tests {
+		if result := IsSopsEncryptedData([]byte(test.data)); result != test.expected {
+			t.Errorf("\n[EXPECTED]: %v\n[GOT]: %v\n[DATA]: %q", test.expected, result, test.data)
+		}
+	}
+}
+
+// setupFakeSops creates the sops script printing its arguments and the input file data, the returned dir is used as the project dir.
+func setupFakeSops(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake sops script requires sh")
+	}
+
+	dir, err := ioutil.TempDir("", "werf-sops-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := "#!/bin/sh\necho \"args: $*\"\nfor last; do :; done\ncat \"$last\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "sops"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("WERF_SOPS_BIN", filepath.Join(dir, "sops"))
+
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := werf.Init(filepath.Join(dir, "tmp"), filepath.Join(dir, "home"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestSopsEncoder_EncryptFile(t *testing.T) {
+	dir := setupFakeSops(t)
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("WERF_SOPS_BIN")
+
+	encoder := NewSopsEncoder(dir, SopsDataTypeYaml)
+
+	result, err := encoder.EncryptFile(filepath.Join(dir, ".helm", "secret-values.yaml"), []byte("key: value\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(result), "--encrypt --input-type yaml --output-type yaml --filename-override .helm/secret-values.yaml ") {
+		t.Errorf("unexpected sops args: %s", result)
+	}
+
+	if !strings.HasSuffix(string(result), "/secret-values.yaml\nkey: value\n") {
+		t.Errorf("expected tmp file with the original file name, got: %s", result)
+	}
+
+	result, err = encoder.EncryptFile("", []byte("key: value\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(result), "--filename-override") {
+		t.Errorf("unexpected --filename-override without file path: %s", result)
+	}
+}
+
+func TestYamlEncoder_DecryptDetectsBackend(t *testing.T) {
+	dir := setupFakeSops(t)
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("WERF_SOPS_BIN")
+
+	encoder := NewYamlEncoder(&EncoderMock{}).WithSops(dir, false)
+
+	result, err := encoder.DecryptYamlFile(filepath.Join(dir, ".helm", "secret-values.yaml"), []byte(sopsEncryptedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(result), "--decrypt --input-type yaml --output-type yaml --filename-override .helm/secret-values.yaml ") {
+		t.Errorf("expected sops decryption, got: %s", result)
+	}
+
+	result, err = encoder.DecryptFile(filepath.Join(dir, ".helm", "secret", "file"), []byte("1000ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result) != "data" {
+		t.Errorf("expected decryption with the encoder, got: %s", result)
+	}
+
+	result, err = encoder.EncryptFile(filepath.Join(dir, ".helm", "secret", "file"), []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result) != "encoded data" {
+		t.Errorf("expected encryption with the encoder, got: %s", result)
+	}
+
+	result, err = encoder.WithSopsEncryption(true).EncryptFile(filepath.Join(dir, ".helm", "secret", "file"), []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(result), "--encrypt --input-type binary --output-type yaml --filename-override .helm/secret/file ") {
+		t.Errorf("expected sops encryption, got: %s", result)
+	}
+}
+
+func TestYamlEncoder_DecryptSopsDataWithoutSops(t *testing.T) {
+	encoder := NewYamlEncoder(&EncoderMock{})
+
+	if _, err := encoder.DecryptYamlData([]byte(sopsEncryptedData)); err == nil || !strings.Contains(err.Error(), "encrypted with sops") {
+		t.Errorf("expected sops data error, got: %v", err)
+	}
+
+	data, err := NewYamlEncoder(nil).DecryptYamlData([]byte(sopsEncryptedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "ENC[AES256_GCM") {
+		t.Errorf("expected data as is with disabled decryption, got: %s", data)
+	}
+}
@@ -12,6 +12,12 @@ type YamlEncoder struct {
 
 	generateFunc func([]byte) ([]byte, error)
 	extractFunc  func([]byte) ([]byte, error)
+
+	// sopsFileEncoder and sopsYamlDataEncoder decrypt the data encrypted with sops, if set
+	sopsFileEncoder     *SopsEncoder
+	sopsYamlDataEncoder *SopsEncoder
+	// encryptWithSops selects sops instead of the Encoder to encrypt the data
+	encryptWithSops bool
 }
 
 func NewYamlEncoder(encoder Encoder) *YamlEncoder {
@@ -28,8 +34,42 @@ func NewYamlEncoder(encoder Encoder) *YamlEncoder {
 	return yamlEncoder
 }
 
+// WithSops enables the decryption of the data encrypted with sops, the backend is detected for each file.
+// If encrypt is true, the secret files and the whole secret values yaml data are encrypted with sops instead of the Encoder.
+func (s *YamlEncoder) WithSops(workingDir string, encrypt bool) *YamlEncoder {
+	s.sopsFileEncoder = NewSopsEncoder(workingDir, SopsDataTypeBinary)
+	s.sopsYamlDataEncoder = NewSopsEncoder(workingDir, SopsDataTypeYaml)
+	s.encryptWithSops = encrypt
+
+	return s
+}
+
+// WithSopsEncryption returns the copy of the encoder, which encrypts the data with sops or with the Encoder.
+func (s *YamlEncoder) WithSopsEncryption(encrypt bool) *YamlEncoder {
+	res := *s
+	res.encryptWithSops = encrypt && s.sopsFileEncoder != nil
+
+	return &res
+}
+
+// IsSopsEncryption checks that the encoder encrypts the data with sops.
+func (s *YamlEncoder) IsSopsEncryption() bool {
+	return s.encryptWithSops
+}
+
 func (s *YamlEncoder) Encrypt(data []byte) ([]byte, error) {
-	resultData, err := s.generateFunc(data)
+	return s.EncryptFile("", data)
+}
+
+// EncryptFile encrypts the secret file data, the file path is used by sops to select the creation rule.
+func (s *YamlEncoder) EncryptFile(filePath string, data []byte) ([]byte, error) {
+	var resultData []byte
+	var err error
+	if s.encryptWithSops {
+		resultData, err = s.sopsFileEncoder.EncryptFile(filePath, data)
+	} else {
+		resultData, err = s.generateFunc(data)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("encryption failed: check encryption key and data: %s", err)
 	}
@@ -38,7 +78,18 @@ func (s *YamlEncoder) Encrypt(data []byte) ([]byte, error) {
 }
 
 func (s *YamlEncoder) EncryptYamlData(data []byte) ([]byte, error) {
-	resultData, err := doYamlData(s.generateFunc, data)
+	return s.EncryptYamlFile("", data)
+}
+
+// EncryptYamlFile encrypts the secret values file data, the file path is used by sops to select the creation rule.
+func (s *YamlEncoder) EncryptYamlFile(filePath string, data []byte) ([]byte, error) {
+	var resultData []byte
+	var err error
+	if s.encryptWithSops {
+		resultData, err = s.sopsYamlDataEncoder.EncryptFile(filePath, data)
+	} else {
+		resultData, err = doYamlData(s.generateFunc, data)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("encryption failed: check encryption key and data: %s", err)
 	}
@@ -47,7 +98,21 @@ func (s *YamlEncoder) EncryptYamlData(data []byte) ([]byte, error) {
 }
 
 func (s *YamlEncoder) Decrypt(data []byte) ([]byte, error) {
-	resultData, err := s.extractFunc(data)
+	return s.DecryptFile("", data)
+}
+
+// DecryptFile decrypts the secret file data with sops or with the Encoder depending on the data.
+func (s *YamlEncoder) DecryptFile(filePath string, data []byte) ([]byte, error) {
+	var resultData []byte
+	var err error
+	if s.isSopsData(data) {
+		if s.sopsFileEncoder == nil {
+			return nil, fmt.Errorf("decryption failed: data is encrypted with sops")
+		}
+		resultData, err = s.sopsFileEncoder.DecryptFile(filePath, data)
+	} else {
+		resultData, err = s.extractFunc(data)
+	}
 	if err != nil {
 		if IsExtractDataError(err) {
 			return nil, fmt.Errorf("decryption failed: check data `%s`: %s", string(data), err)
@@ -60,7 +125,21 @@ func (s *YamlEncoder) Decrypt(data []byte) ([]byte, error) {
 }
 
 func (s *YamlEncoder) DecryptYamlData(data []byte) ([]byte, error) {
-	resultData, err := doYamlData(s.extractFunc, data)
+	return s.DecryptYamlFile("", data)
+}
+
+// DecryptYamlFile decrypts the secret values file data with sops or with the Encoder depending on the data.
+func (s *YamlEncoder) DecryptYamlFile(filePath string, data []byte) ([]byte, error) {
+	var resultData []byte
+	var err error
+	if s.isSopsData(data) {
+		if s.sopsYamlDataEncoder == nil {
+			return nil, fmt.Errorf("decryption failed: data is encrypted with sops")
+		}
+		resultData, err = s.sopsYamlDataEncoder.DecryptFile(filePath, data)
+	} else {
+		resultData, err = doYamlData(s.extractFunc, data)
+	}
 	if err != nil {
 		if IsExtractDataError(err) {
 			return nil, fmt.Errorf("decryption failed: check data `%s`: %s", string(data), err)
@@ -72,6 +151,11 @@ func (s *YamlEncoder) DecryptYamlData(data []byte) ([]byte, error) {
 	return resultData, nil
 }
 
+// isSopsData checks that the data should be decrypted with sops, the data is passed as is if the decryption is disabled.
+func (s *YamlEncoder) isSopsData(data []byte) bool {
+	return s.Encoder != nil && IsSopsEncryptedData(data)
+}
+
 func doYamlData(doFunc func([]byte) ([]byte, error), data []byte) ([]byte, error) {
 	config := make(yaml.MapSlice, 0)
 	err := yaml.UnmarshalStrict(data, &config)
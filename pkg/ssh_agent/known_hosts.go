@@ -0,0 +1,307 @@
+package ssh_agent
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/werf/logboek"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	StrictHostKeyCheckingYes       = "yes"
+	StrictHostKeyCheckingAcceptNew = "accept-new"
+	StrictHostKeyCheckingNo        = "no"
+)
+
+var (
+	// KnownHostsFile is the merged known_hosts file used by the git cli, go-git and the stapel assembly containers, not set if the known hosts are not configured
+	KnownHostsFile        string
+	StrictHostKeyChecking string
+
+	knownHostsFileMux sync.Mutex
+)
+
+type KnownHostsOptions struct {
+	// KnownHostsFiles are used instead of the default ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts files
+	KnownHostsFiles []string
+	// PinnedKnownHosts are the known_hosts lines from the giterminism config, the keys of the other known_hosts files are ignored for the pinned hosts
+	PinnedKnownHosts []string
+	// StrictHostKeyChecking is the ssh StrictHostKeyChecking option value (yes, accept-new or no), the ssh default is used if empty
+	StrictHostKeyChecking string
+}
+
+func InitKnownHosts(ctx context.Context, opts KnownHostsOptions) error {
+	switch opts.StrictHostKeyChecking {
+	case "", StrictHostKeyCheckingYes, StrictHostKeyCheckingAcceptNew, StrictHostKeyCheckingNo:
+	default:
+		return fmt.Errorf("bad strict host key checking mode %q: expected %s, %s or %s", opts.StrictHostKeyChecking, StrictHostKeyCheckingYes, StrictHostKeyCheckingAcceptNew, StrictHostKeyCheckingNo)
+	}
+
+	if len(opts.PinnedKnownHosts) > 0 && opts.StrictHostKeyChecking == StrictHostKeyCheckingNo {
+		return fmt.Errorf("host key checking cannot be disabled when the known hosts are pinned in the giterminism config")
+	}
+
+	if len(opts.KnownHostsFiles) == 0 && len(opts.PinnedKnownHosts) == 0 && opts.StrictHostKeyChecking == "" {
+		return nil
+	}
+
+	var lines []string
+	var pinnedHosts []string
+	for _, line := range opts.PinnedKnownHosts {
+		hosts, err := parseKnownHostsLine(line)
+		if err != nil {
+			return fmt.Errorf("bad pinned known hosts line %q: %s", line, err)
+		} else if hosts == nil {
+			continue
+		}
+
+		for _, host := range hosts {
+			if isHashedKnownHost(host) || strings.ContainsAny(host, "*?!") {
+				return fmt.Errorf("bad pinned known hosts line %q: the hashed and wildcard hosts cannot be pinned", line)
+			}
+			pinnedHosts = append(pinnedHosts, knownhosts.Normalize(host))
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+
+	knownHostsFiles := opts.KnownHostsFiles
+	if len(knownHostsFiles) == 0 {
+		knownHostsFiles = getDefaultKnownHostsFiles()
+	}
+
+	for _, file := range knownHostsFiles {
+		data, err := ioutil.ReadFile(util.ExpandPath(file))
+		if err != nil {
+			return fmt.Errorf("unable to read known hosts file %s: %s", file, err)
+		}
+
+	LinesLoop:
+		for _, line := range strings.Split(string(data), "\n") {
+			hosts, err := parseKnownHostsLine(line)
+			if err != nil {
+				return fmt.Errorf("bad known hosts file %s: %s", file, err)
+			} else if hosts == nil {
+				continue
+			}
+
+			for _, pinnedHost := range pinnedHosts {
+				if isKnownHostsEntryMatched(hosts, pinnedHost) {
+					logboek.Context(ctx).Debug().LogF("Skipping known hosts entry %s from %s: the host %s is pinned in the giterminism config\n", strings.Join(hosts, ","), file, pinnedHost)
+					continue LinesLoop
+				}
+			}
+
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	knownHostsFile := filepath.Join(werf.GetTmpDir(), "werf-ssh-known-hosts", uuid.NewV4().String())
+	if err := os.MkdirAll(filepath.Dir(knownHostsFile), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(knownHostsFile, []byte(strings.Join(append(lines, ""), "\n")), 0o644); err != nil {
+		return fmt.Errorf("unable to write known hosts file %s: %s", knownHostsFile, err)
+	}
+
+	logboek.Context(ctx).Info().LogF("Using ssh known hosts file %s (%d entries)\n", knownHostsFile, len(lines))
+
+	KnownHostsFile = knownHostsFile
+	StrictHostKeyChecking = opts.StrictHostKeyChecking
+
+	// git cli uses the system ssh client
+	gitSSHCommand := os.Getenv("GIT_SSH_COMMAND")
+	if gitSSHCommand == "" {
+		gitSSHCommand = "ssh"
+	}
+	if err := os.Setenv("GIT_SSH_COMMAND", fmt.Sprintf("%s %s", gitSSHCommand, GetSSHCommandOptions(KnownHostsFile))); err != nil {
+		return fmt.Errorf("unable to set GIT_SSH_COMMAND: %s", err)
+	}
+
+	// go-git uses the known hosts callback of the default ssh agent auth method
+	gitssh.DefaultAuthBuilder = func(user string) (gitssh.AuthMethod, error) {
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, err
+		}
+
+		if auth.HostKeyCallback, err = newHostKeyCallback(); err != nil {
+			return nil, err
+		}
+
+		return auth, nil
+	}
+
+	return nil
+}
+
+// GetSSHCommandOptions returns the ssh client options to use the known hosts file instead of the user and the global ones.
+func GetSSHCommandOptions(knownHostsFile string) string {
+	opts := fmt.Sprintf("-o UserKnownHostsFile='%s' -o GlobalKnownHostsFile=/dev/null", knownHostsFile)
+	if StrictHostKeyChecking != "" {
+		opts += fmt.Sprintf(" -o StrictHostKeyChecking=%s", StrictHostKeyChecking)
+	}
+
+	return opts
+}
+
+func getDefaultKnownHostsFiles() []string {
+	var files []string
+	for _, file := range []string{filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"), "/etc/ssh/ssh_known_hosts"} {
+		if exists, _ := util.FileExists(file); exists {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// parseKnownHostsLine returns the hosts of the known_hosts line or nil for the empty and comment lines.
+func parseKnownHostsLine(line string) ([]string, error) {
+	_, hosts, _, _, _, err := ssh.ParseKnownHosts([]byte(line))
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+// isKnownHostsEntryMatched checks whether the hosts of the known_hosts entry match the normalized host the same way as the ssh client does:
+// the hashed hosts are compared by the hash, the wildcard patterns are matched and the negated patterns exclude the host.
+func isKnownHostsEntryMatched(entryHosts []string, host string) bool {
+	var matched bool
+	for _, entryHost := range entryHosts {
+		if isHashedKnownHost(entryHost) {
+			if isHashedKnownHostMatched(entryHost, host) {
+				matched = true
+			}
+			continue
+		}
+
+		pattern := strings.TrimPrefix(entryHost, "!")
+		if !isWildcardMatched(knownhosts.Normalize(pattern), host) {
+			continue
+		}
+
+		if pattern != entryHost {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
+
+func isHashedKnownHost(host string) bool {
+	return strings.HasPrefix(host, "|1|")
+}
+
+// isHashedKnownHostMatched checks the hashed host in format |1|base64(salt)|base64(hmac-sha1(salt, host)).
+func isHashedKnownHostMatched(hashedHost, host string) bool {
+	parts := strings.Split(strings.TrimPrefix(hashedHost, "|1|"), "|")
+	if len(parts) != 2 {
+		return false
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// isWildcardMatched matches the ssh pattern, where * matches zero or more characters and ? matches exactly one character.
+func isWildcardMatched(pattern, s string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if isWildcardMatched(pattern[1:], s[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return s != "" && isWildcardMatched(pattern[1:], s[1:])
+	default:
+		return s != "" && pattern[0] == s[0] && isWildcardMatched(pattern[1:], s[1:])
+	}
+}
+
+func newHostKeyCallback() (ssh.HostKeyCallback, error) {
+	if StrictHostKeyChecking == StrictHostKeyCheckingNo {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	knownHostsFileMux.Lock()
+	callback, err := knownhosts.New(KnownHostsFile)
+	knownHostsFileMux.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load known hosts file %s: %s", KnownHostsFile, err)
+	}
+
+	if StrictHostKeyChecking != StrictHostKeyCheckingAcceptNew {
+		return callback, nil
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			// the host is unknown, changed host keys are still rejected
+			return addKnownHost(hostname, key)
+		}
+
+		return err
+	}, nil
+}
+
+func addKnownHost(hostname string, key ssh.PublicKey) error {
+	knownHostsFileMux.Lock()
+	defer knownHostsFileMux.Unlock()
+
+	f, err := os.OpenFile(KnownHostsFile, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open known hosts file %s: %s", KnownHostsFile, err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+		return fmt.Errorf("unable to add known host %s: %s", hostname, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package ssh_agent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+func newTestPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func hashTestHost(host string) string {
+	salt := []byte("0123456789abcdefghij")
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestIsKnownHostsEntryMatched(t *testing.T) {
+	for _, tc := range []struct {
+		entryHosts []string
+		host       string
+		expected   bool
+	}{
+		{[]string{"github.com"}, "github.com", true},
+		{[]string{"gitlab.com", "github.com"}, "github.com", true},
+		{[]string{"gitlab.com"}, "github.com", false},
+		{[]string{"[github.com]:22"}, "github.com", true},
+		{[]string{"[git.example.com]:2222"}, "[git.example.com]:2222", true},
+		{[]string{"git.example.com"}, "[git.example.com]:2222", false},
+		{[]string{"*.example.com"}, "git.example.com", true},
+		{[]string{"git?.example.com"}, "git1.example.com", true},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*.example.com", "!git.example.com"}, "git.example.com", false},
+		{[]string{"*"}, "[git.example.com]:2222", true},
+		{[]string{hashTestHost("github.com")}, "github.com", true},
+		{[]string{hashTestHost("[git.example.com]:2222")}, "[git.example.com]:2222", true},
+		{[]string{hashTestHost("gitlab.com")}, "github.com", false},
+	} {
+		if matched := isKnownHostsEntryMatched(tc.entryHosts, tc.host); matched != tc.expected {
+			t.Errorf("entry %v and host %q: expected %v, got %v", tc.entryHosts, tc.host, tc.expected, matched)
+		}
+	}
+}
+
+func TestInitKnownHostsMerge(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := werf.Init(filepath.Join(tmpDir, "tmp"), filepath.Join(tmpDir, "home"), ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_SSH_COMMAND", "")
+
+	pinnedKey := newTestPublicKey(t)
+	otherKey := newTestPublicKey(t)
+
+	knownHostsLines := []string{
+		"# comment",
+		knownhosts.Line([]string{"github.com"}, otherKey),
+		knownhosts.Line([]string{hashTestHost("github.com")}, otherKey),
+		knownhosts.Line([]string{"*.com"}, otherKey),
+		knownhosts.Line([]string{"[git.example.com]:2222"}, otherKey),
+		knownhosts.Line([]string{"gitlab.com"}, otherKey),
+		knownhosts.Line([]string{hashTestHost("gitlab.com")}, otherKey),
+	}
+	knownHostsFile := filepath.Join(tmpDir, "known_hosts")
+	if err := ioutil.WriteFile(knownHostsFile, []byte(strings.Join(knownHostsLines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pinnedLine := knownhosts.Line([]string{"github.com", "[git.example.com]:2222"}, pinnedKey)
+	if err := InitKnownHosts(context.Background(), KnownHostsOptions{
+		KnownHostsFiles:  []string{knownHostsFile},
+		PinnedKnownHosts: []string{pinnedLine},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(KnownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLines := []string{pinnedLine, knownHostsLines[5], knownHostsLines[6]}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); strings.Join(lines, "\n") != strings.Join(expectedLines, "\n") {
+		t.Fatalf("unexpected merged known hosts:\n%s", data)
+	}
+
+	if !strings.Contains(os.Getenv("GIT_SSH_COMMAND"), KnownHostsFile) {
+		t.Fatalf("unexpected GIT_SSH_COMMAND %q", os.Getenv("GIT_SSH_COMMAND"))
+	}
+
+	if err := InitKnownHosts(context.Background(), KnownHostsOptions{
+		KnownHostsFiles:  []string{knownHostsFile},
+		PinnedKnownHosts: []string{knownhosts.Line([]string{"*.example.com"}, pinnedKey)},
+	}); err == nil {
+		t.Fatalf("expected error for the pinned wildcard host")
+	}
+}
+
+func TestAcceptNewHostKeyCallback(t *testing.T) {
+	knownKey := newTestPublicKey(t)
+
+	KnownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+	StrictHostKeyChecking = StrictHostKeyCheckingAcceptNew
+	defer func() {
+		KnownHostsFile = ""
+		StrictHostKeyChecking = ""
+	}()
+
+	if err := ioutil.WriteFile(KnownHostsFile, []byte(knownhosts.Line([]string{"github.com"}, knownKey)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	callback, err := newHostKeyCallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+
+	if err := callback("github.com:22", remote, knownKey); err != nil {
+		t.Fatalf("expected the known host key to be accepted: %s", err)
+	}
+
+	if err := callback("github.com:22", remote, newTestPublicKey(t)); err == nil {
+		t.Fatalf("expected the changed host key to be rejected")
+	}
+
+	newKey := newTestPublicKey(t)
+	if err := callback("git.example.com:2222", remote, newKey); err != nil {
+		t.Fatalf("expected the new host key to be accepted: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(KnownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), knownhosts.Line([]string{"[git.example.com]:2222"}, newKey)) {
+		t.Fatalf("expected the new host key to be added:\n%s", data)
+	}
+
+	// The accepted key is used by the new callbacks
+	callback, err = newHostKeyCallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := callback("git.example.com:2222", remote, newTestPublicKey(t)); err == nil {
+		t.Fatalf("expected the changed key of the accepted host to be rejected")
+	}
+}
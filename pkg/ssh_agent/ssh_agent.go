@@ -97,6 +97,13 @@ func Terminate() error {
 		}
 	}
 
+	if KnownHostsFile != "" {
+		err := os.RemoveAll(KnownHostsFile)
+		if err != nil {
+			return fmt.Errorf("unable to remove tmp ssh known hosts file %s: %s", KnownHostsFile, err)
+		}
+	}
+
 	return nil
 }
 
@@ -41,7 +41,7 @@ func (c *container) CreateIfNotExist(ctx context.Context) error {
 	}
 
 	if !exist {
-		err := werf.WithHostLock(ctx, fmt.Sprintf("stapel.container.%s", c.Name), lockgate.AcquireOptions{Timeout: time.Second * 600}, func() error {
+		err := werf.WithGlobalHostLock(ctx, fmt.Sprintf("stapel.container.%s", c.Name), lockgate.AcquireOptions{Timeout: time.Second * 600}, func() error {
 			return logboek.Context(ctx).LogProcess("Creating container %s from image %s", c.Name, c.ImageName).DoError(func() error {
 				exist, err := docker.ContainerExist(ctx, c.Name)
 				if err != nil {
@@ -12,7 +12,7 @@ import (
 	"github.com/werf/werf/pkg/docker"
 )
 
-const VERSION = "0.6.1"
+const VERSION = "0.7.0"
 const IMAGE = "flant/werf-stapel"
 
 func getVersion() string {
@@ -155,6 +155,10 @@ func AnsiblePlaybookBinPath() string {
 	return embeddedBinPath("ansible-playbook")
 }
 
+func AnsibleGalaxyBinPath() string {
+	return embeddedBinPath("ansible-galaxy")
+}
+
 /*
  * Ansible tools and libs overlay path is like /usr/local which has more priority than /usr.
  * Ansible tools and libs overlay path used to force ansible to use tools directly from stapel rather than find it in the base system.
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"github.com/werf/werf/pkg/image"
+)
+
+// ChangedOnlyRecord is the last stage of the image built from the inputs with the same digest,
+// so that the build with the changed only option skips the image, which inputs are not changed.
+type ChangedOnlyRecord struct {
+	InputsDigest  string
+	StageName     string
+	StageID       image.StageID
+	ContentDigest string
+}
+
+func (rec *ChangedOnlyRecord) ToLabels() map[string]string {
+	return map[string]string{
+		image.WerfChangedOnlyInputsDigestLabel:  rec.InputsDigest,
+		image.WerfChangedOnlyStageNameLabel:     rec.StageName,
+		image.WerfChangedOnlyStageIDLabel:       rec.StageID.String(),
+		image.WerfChangedOnlyContentDigestLabel: rec.ContentDigest,
+	}
+}
+
+// newChangedOnlyRecordFromLabels returns nil if the labels do not contain the valid record.
+func newChangedOnlyRecordFromLabels(labels map[string]string) *ChangedOnlyRecord {
+	digest, uniqueID, err := getDigestAndUniqueIDFromRepoStageImageTag(labels[image.WerfChangedOnlyStageIDLabel])
+	if err != nil || labels[image.WerfChangedOnlyInputsDigestLabel] == "" || labels[image.WerfChangedOnlyStageNameLabel] == "" {
+		return nil
+	}
+
+	return &ChangedOnlyRecord{
+		InputsDigest:  labels[image.WerfChangedOnlyInputsDigestLabel],
+		StageName:     labels[image.WerfChangedOnlyStageNameLabel],
+		StageID:       image.StageID{Digest: digest, UniqueID: uniqueID},
+		ContentDigest: labels[image.WerfChangedOnlyContentDigestLabel],
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestChangedOnlyRecord_Labels(t *testing.T) {
+	rec := &ChangedOnlyRecord{
+		InputsDigest:  "a2f1b3a6c8d54e7f9b0a1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f",
+		StageName:     "install",
+		StageID:       image.StageID{Digest: "9b9ee2a1d3d67d4cf3a0b2e2d0b3f1c6b84c2e8fd3a6ad08a6c4f1fa", UniqueID: 1611836746968},
+		ContentDigest: "b84c2e8fd3a6ad08a6c4f1fa9b9ee2a1d3d67d4cf3a0b2e2d0b3f1c6",
+	}
+
+	parsedRec := newChangedOnlyRecordFromLabels(rec.ToLabels())
+	if !reflect.DeepEqual(parsedRec, rec) {
+		t.Fatalf("expected record %#v, got %#v", rec, parsedRec)
+	}
+}
+
+func TestChangedOnlyRecord_InvalidLabels(t *testing.T) {
+	validLabels := func() map[string]string {
+		return (&ChangedOnlyRecord{
+			InputsDigest: "a2f1b3a6c8d54e7f",
+			StageName:    "install",
+			StageID:      image.StageID{Digest: "9b9ee2a1d3d67d4cf3a0b2e2d0b3f1c6b84c2e8fd3a6ad08a6c4f1fa", UniqueID: 1611836746968},
+		}).ToLabels()
+	}
+
+	for name, modify := range map[string]func(labels map[string]string){
+		"no labels": func(labels map[string]string) {
+			delete(labels, image.WerfChangedOnlyInputsDigestLabel)
+			delete(labels, image.WerfChangedOnlyStageNameLabel)
+			delete(labels, image.WerfChangedOnlyStageIDLabel)
+		},
+		"no inputs digest": func(labels map[string]string) { delete(labels, image.WerfChangedOnlyInputsDigestLabel) },
+		"no stage name":    func(labels map[string]string) { delete(labels, image.WerfChangedOnlyStageNameLabel) },
+		"no stage id":      func(labels map[string]string) { delete(labels, image.WerfChangedOnlyStageIDLabel) },
+		"invalid stage id": func(labels map[string]string) { labels[image.WerfChangedOnlyStageIDLabel] = "9b9ee2a1d3d67d4c" },
+		"invalid unique id": func(labels map[string]string) {
+			labels[image.WerfChangedOnlyStageIDLabel] = "9b9ee2a1d3d67d4cf3a0b2e2d0b3f1c6b84c2e8fd3a6ad08a6c4f1fa-abc"
+		},
+	} {
+		labels := validLabels()
+		modify(labels)
+
+		if rec := newChangedOnlyRecordFromLabels(labels); rec != nil {
+			t.Errorf("%s: expected nil record, got %#v", name, rec)
+		}
+	}
+}
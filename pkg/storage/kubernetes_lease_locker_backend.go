@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+const (
+	LeaseProjectLabel               = "werf.io/synchronization-project"
+	LeaseLockNameAnnotation         = "werf.io/lock-name"
+	LeaseSharedHoldersAnnotation    = "werf.io/shared-holders-count"
+	leaseNamePrefix                 = "werf-lock-"
+	leaseConflictRetryPeriodSeconds = 1
+)
+
+// KubernetesLeaseLockerBackend stores each lock in the separate coordination.k8s.io Lease object,
+// so that unrelated locks do not conflict with each other as the locks stored in the annotations of the single ConfigMap.
+// The lease is deleted on release, so the number of Lease objects is limited by the number of the held locks.
+type KubernetesLeaseLockerBackend struct {
+	KubeClient  kubernetes.Interface
+	Namespace   string
+	ProjectName string
+}
+
+func NewKubernetesLeaseLockerBackend(kubeClient kubernetes.Interface, namespace, projectName string) *KubernetesLeaseLockerBackend {
+	return &KubernetesLeaseLockerBackend{KubeClient: kubeClient, Namespace: namespace, ProjectName: projectName}
+}
+
+func GetLockLeaseName(lockName string) string {
+	return fmt.Sprintf("%s%s", leaseNamePrefix, util.Sha3_224Hash(lockName))
+}
+
+func (backend *KubernetesLeaseLockerBackend) Acquire(lockName string, opts distributed_locker.AcquireOptions) (lockgate.LockHandle, error) {
+	ctx := context.Background()
+	leases := backend.KubeClient.CoordinationV1().Leases(backend.Namespace)
+
+	for {
+		lease, err := leases.Get(ctx, GetLockLeaseName(lockName), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			handle := lockgate.LockHandle{UUID: uuid.New().String(), LockName: lockName}
+			if _, err := leases.Create(ctx, backend.newLease(handle, opts.Shared), metav1.CreateOptions{}); errors.IsAlreadyExists(err) {
+				continue
+			} else if err != nil {
+				return lockgate.LockHandle{}, fmt.Errorf("unable to create lease for lock %q: %s", lockName, err)
+			}
+			return handle, nil
+		} else if err != nil {
+			return lockgate.LockHandle{}, fmt.Errorf("unable to get lease for lock %q: %s", lockName, err)
+		}
+
+		var handle lockgate.LockHandle
+		switch {
+		case IsLockLeaseExpired(lease):
+			handle = lockgate.LockHandle{UUID: uuid.New().String(), LockName: lockName}
+			resourceVersion := lease.ResourceVersion
+			lease = backend.newLease(handle, opts.Shared)
+			lease.ResourceVersion = resourceVersion
+		case opts.Shared && GetLockLeaseSharedHoldersCount(lease) > 0:
+			handle = lockgate.LockHandle{UUID: *lease.Spec.HolderIdentity, LockName: lockName}
+			setLeaseSharedHoldersCount(lease, GetLockLeaseSharedHoldersCount(lease)+1)
+			renewLease(lease)
+		default:
+			return lockgate.LockHandle{}, distributed_locker.ErrShouldWait
+		}
+
+		if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); errors.IsConflict(err) || errors.IsNotFound(err) {
+			time.Sleep(leaseConflictRetryPeriodSeconds * time.Second)
+			continue
+		} else if err != nil {
+			return lockgate.LockHandle{}, fmt.Errorf("unable to update lease for lock %q: %s", lockName, err)
+		}
+
+		return handle, nil
+	}
+}
+
+func (backend *KubernetesLeaseLockerBackend) RenewLease(handle lockgate.LockHandle) error {
+	return backend.changeLease(handle, func(lease *coordinationv1.Lease) (bool, error) {
+		renewLease(lease)
+		return false, nil
+	})
+}
+
+func (backend *KubernetesLeaseLockerBackend) Release(handle lockgate.LockHandle) error {
+	return backend.changeLease(handle, func(lease *coordinationv1.Lease) (bool, error) {
+		if count := GetLockLeaseSharedHoldersCount(lease); count > 1 {
+			setLeaseSharedHoldersCount(lease, count-1)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// changeLease updates the lease or deletes it when changeFunc returns true, the change is retried on the conflict.
+func (backend *KubernetesLeaseLockerBackend) changeLease(handle lockgate.LockHandle, changeFunc func(lease *coordinationv1.Lease) (bool, error)) error {
+	ctx := context.Background()
+	leases := backend.KubeClient.CoordinationV1().Leases(backend.Namespace)
+
+	for {
+		lease, err := leases.Get(ctx, GetLockLeaseName(handle.LockName), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return distributed_locker.ErrNoExistingLockLeaseFound
+		} else if err != nil {
+			return fmt.Errorf("unable to get lease for lock %q: %s", handle.LockName, err)
+		}
+
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != handle.UUID {
+			return distributed_locker.ErrLockAlreadyLeased
+		}
+
+		shouldDelete, err := changeFunc(lease)
+		if err != nil {
+			return err
+		}
+
+		if shouldDelete {
+			err = leases.Delete(ctx, lease.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion}})
+		} else {
+			_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		}
+
+		if errors.IsConflict(err) {
+			time.Sleep(leaseConflictRetryPeriodSeconds * time.Second)
+			continue
+		} else if errors.IsNotFound(err) {
+			return distributed_locker.ErrNoExistingLockLeaseFound
+		} else if err != nil {
+			return fmt.Errorf("unable to change lease for lock %q: %s", handle.LockName, err)
+		}
+
+		return nil
+	}
+}
+
+func (backend *KubernetesLeaseLockerBackend) newLease(handle lockgate.LockHandle, shared bool) *coordinationv1.Lease {
+	now := metav1.NewMicroTime(time.Now())
+	leaseDurationSeconds := int32(distributed_locker.DistributedLockLeaseTTLSeconds)
+	holderIdentity := handle.UUID
+
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GetLockLeaseName(handle.LockName),
+			Namespace:   backend.Namespace,
+			Labels:      map[string]string{LeaseProjectLabel: backend.ProjectName},
+			Annotations: map[string]string{LeaseLockNameAnnotation: handle.LockName},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holderIdentity,
+			LeaseDurationSeconds: &leaseDurationSeconds,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+
+	if shared {
+		setLeaseSharedHoldersCount(lease, 1)
+	}
+
+	return lease
+}
+
+func renewLease(lease *coordinationv1.Lease) {
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+}
+
+// IsLockLeaseExpired returns true when the lease is not held or has not been renewed in time by the holder.
+func IsLockLeaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return time.Now().After(lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// GetLockLeaseSharedHoldersCount returns 0 for the exclusive lock.
+func GetLockLeaseSharedHoldersCount(lease *coordinationv1.Lease) int64 {
+	count, _ := strconv.ParseInt(lease.Annotations[LeaseSharedHoldersAnnotation], 10, 64)
+	return count
+}
+
+func setLeaseSharedHoldersCount(lease *coordinationv1.Lease, count int64) {
+	if lease.Annotations == nil {
+		lease.Annotations = make(map[string]string)
+	}
+	lease.Annotations[LeaseSharedHoldersAnnotation] = strconv.FormatInt(count, 10)
+}
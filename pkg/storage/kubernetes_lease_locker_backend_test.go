@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesLeaseLockerBackend(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	backend := NewKubernetesLeaseLockerBackend(client, "werf-synchronization", "myproject")
+
+	handle, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{})
+	if err != nil {
+		t.Fatalf("unexpected acquire error: %s", err)
+	}
+
+	if _, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{}); err != distributed_locker.ErrShouldWait {
+		t.Errorf("expected ErrShouldWait for the held lock, got: %v", err)
+	}
+
+	lease, err := client.CoordinationV1().Leases("werf-synchronization").Get(context.Background(), GetLockLeaseName("mylock"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unable to get lease: %s", err)
+	}
+	if lease.Labels[LeaseProjectLabel] != "myproject" || lease.Annotations[LeaseLockNameAnnotation] != "mylock" {
+		t.Errorf("unexpected lease metadata: %v %v", lease.Labels, lease.Annotations)
+	}
+	if IsLockLeaseExpired(lease) {
+		t.Errorf("fresh lease should not be expired")
+	}
+
+	if err := backend.RenewLease(handle); err != nil {
+		t.Errorf("unexpected renew error: %s", err)
+	}
+
+	if err := backend.Release(handle); err != nil {
+		t.Fatalf("unexpected release error: %s", err)
+	}
+
+	list, err := client.CoordinationV1().Leases("werf-synchronization").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unable to list leases: %s", err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected lease to be deleted on release, got %d leases", len(list.Items))
+	}
+}
+
+func TestKubernetesLeaseLockerBackend_Shared(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	backend := NewKubernetesLeaseLockerBackend(client, "werf-synchronization", "myproject")
+
+	handle1, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{Shared: true})
+	if err != nil {
+		t.Fatalf("unexpected acquire error: %s", err)
+	}
+	handle2, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{Shared: true})
+	if err != nil {
+		t.Fatalf("unexpected shared acquire error: %s", err)
+	}
+
+	if _, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{}); err != distributed_locker.ErrShouldWait {
+		t.Errorf("expected ErrShouldWait for exclusive acquire of the shared lock, got: %v", err)
+	}
+
+	if err := backend.Release(handle1); err != nil {
+		t.Fatalf("unexpected release error: %s", err)
+	}
+
+	lease, err := client.CoordinationV1().Leases("werf-synchronization").Get(context.Background(), GetLockLeaseName("mylock"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("shared lease should be kept while held: %s", err)
+	}
+	if count := GetLockLeaseSharedHoldersCount(lease); count != 1 {
+		t.Errorf("expected 1 shared holder, got %d", count)
+	}
+
+	if err := backend.Release(handle2); err != nil {
+		t.Fatalf("unexpected release error: %s", err)
+	}
+
+	if _, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{}); err != nil {
+		t.Errorf("expected exclusive acquire after the release of all shared holders, got: %v", err)
+	}
+}
+
+func TestKubernetesLeaseLockerBackend_Expired(t *testing.T) {
+	for _, shared := range []bool{false, true} {
+		client := fake.NewSimpleClientset()
+		backend := NewKubernetesLeaseLockerBackend(client, "werf-synchronization", "myproject")
+		leases := client.CoordinationV1().Leases("werf-synchronization")
+
+		staleHandle, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{})
+		if err != nil {
+			t.Fatalf("unexpected acquire error: %s", err)
+		}
+
+		lease, err := leases.Get(context.Background(), GetLockLeaseName("mylock"), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unable to get lease: %s", err)
+		}
+		staleRenewTime := metav1.NewMicroTime(time.Now().Add(-2 * time.Duration(distributed_locker.DistributedLockLeaseTTLSeconds) * time.Second))
+		lease.Spec.RenewTime = &staleRenewTime
+		if lease, err = leases.Update(context.Background(), lease, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("unable to update lease: %s", err)
+		}
+		if !IsLockLeaseExpired(lease) {
+			t.Errorf("lease not renewed in time should be expired")
+		}
+
+		handle, err := backend.Acquire("mylock", distributed_locker.AcquireOptions{Shared: shared})
+		if err != nil {
+			t.Fatalf("expected acquire of the expired lease (shared=%v), got: %s", shared, err)
+		}
+		if handle.UUID == staleHandle.UUID {
+			t.Errorf("expected the new holder of the expired lease (shared=%v)", shared)
+		}
+
+		lease, err = leases.Get(context.Background(), GetLockLeaseName("mylock"), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unable to get lease: %s", err)
+		}
+		if IsLockLeaseExpired(lease) {
+			t.Errorf("taken over lease should not be expired (shared=%v)", shared)
+		}
+		expectedCount := int64(0)
+		if shared {
+			expectedCount = 1
+		}
+		if count := GetLockLeaseSharedHoldersCount(lease); count != expectedCount {
+			t.Errorf("expected %d shared holders (shared=%v), got %d", expectedCount, shared, count)
+		}
+
+		if err := backend.Release(staleHandle); err != distributed_locker.ErrLockAlreadyLeased {
+			t.Errorf("expected ErrLockAlreadyLeased for the release by the stale holder (shared=%v), got: %v", shared, err)
+		}
+		if err := backend.Release(handle); err != nil {
+			t.Errorf("unexpected release error (shared=%v): %s", shared, err)
+		}
+	}
+}
+
+func TestIsLockLeaseExpired_NoHolder(t *testing.T) {
+	backend := NewKubernetesLeaseLockerBackend(fake.NewSimpleClientset(), "werf-synchronization", "myproject")
+	lease := backend.newLease(lockgate.LockHandle{UUID: "uuid", LockName: "mylock"}, false)
+	if IsLockLeaseExpired(lease) {
+		t.Errorf("fresh lease should not be expired")
+	}
+
+	lease.Spec.HolderIdentity = nil
+	if !IsLockLeaseExpired(lease) {
+		t.Errorf("lease without holder should be expired")
+	}
+}
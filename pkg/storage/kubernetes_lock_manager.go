@@ -9,7 +9,6 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/werf/lockgate/pkg/distributed_locker"
-	"github.com/werf/werf/pkg/kubeutils"
 	"github.com/werf/werf/pkg/werf/locker_with_retry"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -35,6 +34,8 @@ type KuberntesLockManager struct {
 	Namespace            string
 	LockerPerProject     map[string]lockgate.Locker
 	GetConfigMapNameFunc func(projectName string) string
+	// UseLeases enables storing each lock in the separate Lease object instead of the annotations of the project ConfigMap
+	UseLeases bool
 
 	mux sync.Mutex
 }
@@ -48,17 +49,22 @@ func (manager *KuberntesLockManager) getLockerForProject(ctx context.Context, pr
 	}
 
 	name := manager.GetConfigMapNameFunc(projectName)
-	if _, err := kubeutils.GetOrCreateConfigMapWithNamespaceIfNotExists(manager.KubeClient, manager.Namespace, name); err != nil {
+	if err := ensureKubernetesLocksMode(ctx, manager.KubeClient, manager.Namespace, name, manager.UseLeases); err != nil {
 		return nil, err
 	}
 
-	locker := distributed_locker.NewKubernetesLocker(
-		manager.KubeDynamicClient, schema.GroupVersionResource{
-			Group:    "",
-			Version:  "v1",
-			Resource: "configmaps",
-		}, name, manager.Namespace,
-	)
+	var locker lockgate.Locker
+	if manager.UseLeases {
+		locker = distributed_locker.NewDistributedLocker(NewKubernetesLeaseLockerBackend(manager.KubeClient, manager.Namespace, projectName))
+	} else {
+		locker = distributed_locker.NewKubernetesLocker(
+			manager.KubeDynamicClient, schema.GroupVersionResource{
+				Group:    "",
+				Version:  "v1",
+				Resource: "configmaps",
+			}, name, manager.Namespace,
+		)
+	}
 	lockerWithRetry := locker_with_retry.NewLockerWithRetry(ctx, locker, locker_with_retry.LockerWithRetryOptions{MaxAcquireAttempts: 10, MaxReleaseAttempts: 10})
 
 	manager.LockerPerProject[projectName] = lockerWithRetry
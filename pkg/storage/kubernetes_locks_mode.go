@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"github.com/werf/werf/pkg/kubeutils"
+)
+
+// KubernetesLeasesModeAnnotation marks the project ConfigMap when the project locks are stored in the Lease objects.
+// The locks of the different modes do not exclude each other, so the werf processes storing the locks in the ConfigMap annotations
+// fail on the marked ConfigMap instead of running concurrently with the leases mode processes.
+const KubernetesLeasesModeAnnotation = "werf.io/synchronization-kubernetes-leases"
+
+// LockgateAnnotationPrefix is used by the lockgate kubernetes locker to store the lock leases in the ConfigMap annotations.
+const LockgateAnnotationPrefix = "lockgate.io/"
+
+// ensureKubernetesLocksMode checks that the project locks are not stored in the other mode and marks the ConfigMap on the switch to the leases mode.
+// The mark is set with the optimistic locking, so the ConfigMap annotations lock acquired concurrently is not missed.
+func ensureKubernetesLocksMode(ctx context.Context, client kubernetes.Interface, namespace, configMapName string, useLeases bool) error {
+	if _, err := kubeutils.GetOrCreateConfigMapWithNamespaceIfNotExists(client, namespace, configMapName); err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to get cm/%s in the namespace %q: %s", configMapName, namespace, err)
+		}
+
+		shouldMark, err := checkKubernetesLocksMode(cm, useLeases, time.Now())
+		if err != nil || !shouldMark {
+			return err
+		}
+
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[KubernetesLeasesModeAnnotation] = "true"
+
+		if _, err := client.CoreV1().ConfigMaps(namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// checkKubernetesLocksMode returns true if the ConfigMap should be marked with the leases mode annotation.
+func checkKubernetesLocksMode(cm *corev1.ConfigMap, useLeases bool, now time.Time) (bool, error) {
+	isMarked := cm.Annotations[KubernetesLeasesModeAnnotation] == "true"
+
+	switch {
+	case !useLeases && isMarked:
+		return false, fmt.Errorf("the project locks are stored in the Lease objects (cm/%s is annotated with %s=true): use --synchronization-kubernetes-leases option (or WERF_SYNCHRONIZATION_KUBERNETES_LEASES=true) as the other werf processes of the project do", cm.Name, KubernetesLeasesModeAnnotation)
+	case !useLeases || isMarked:
+		return false, nil
+	}
+
+	for annotation, data := range cm.Annotations {
+		if !strings.HasPrefix(annotation, LockgateAnnotationPrefix) || data == "" {
+			continue
+		}
+
+		var lease *distributed_locker.LockLeaseRecord
+		if err := json.Unmarshal([]byte(data), &lease); err != nil {
+			return false, fmt.Errorf("unable to unmarshal lock lease from cm/%s annotation %s: %s", cm.Name, annotation, err)
+		}
+
+		if now.Before(time.Unix(lease.ExpireAtTimestamp, 0)) {
+			return false, fmt.Errorf("unable to switch the project locks to the Lease objects: lock %q in cm/%s is held by the werf process without --synchronization-kubernetes-leases option, wait for such processes to finish", lease.LockName, cm.Name)
+		}
+	}
+
+	return true, nil
+}
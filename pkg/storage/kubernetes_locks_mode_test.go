@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newLockgateAnnotation(t *testing.T, lockName string, expireAt time.Time) string {
+	record := &distributed_locker.LockLeaseRecord{ExpireAtTimestamp: expireAt.Unix()}
+	record.LockName = lockName
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestCheckKubernetesLocksMode(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name               string
+		annotations        map[string]string
+		useLeases          bool
+		expectedShouldMark bool
+		expectedErr        bool
+	}{
+		{name: "annotations mode"},
+		{name: "annotations mode with held lock", annotations: map[string]string{"lockgate.io/a": newLockgateAnnotation(t, "a", now.Add(time.Minute))}},
+		{name: "annotations mode on marked ConfigMap", annotations: map[string]string{KubernetesLeasesModeAnnotation: "true"}, expectedErr: true},
+		{name: "switch to leases", useLeases: true, expectedShouldMark: true},
+		{name: "switch to leases with stale lock", useLeases: true, annotations: map[string]string{"lockgate.io/a": newLockgateAnnotation(t, "a", now.Add(-time.Minute))}, expectedShouldMark: true},
+		{name: "switch to leases with released lock", useLeases: true, annotations: map[string]string{"lockgate.io/a": ""}, expectedShouldMark: true},
+		{name: "switch to leases with held lock", useLeases: true, annotations: map[string]string{"lockgate.io/a": newLockgateAnnotation(t, "a", now.Add(time.Minute))}, expectedErr: true},
+		{name: "leases mode on marked ConfigMap", useLeases: true, annotations: map[string]string{KubernetesLeasesModeAnnotation: "true"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "werf-myproject", Annotations: tc.annotations}}
+
+			shouldMark, err := checkKubernetesLocksMode(cm, tc.useLeases, now)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectedErr, err)
+			}
+
+			if shouldMark != tc.expectedShouldMark {
+				t.Fatalf("expected should mark %v, got %v", tc.expectedShouldMark, shouldMark)
+			}
+		})
+	}
+}
+
+func TestEnsureKubernetesLocksMode(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	if err := ensureKubernetesLocksMode(ctx, client, "werf-synchronization", "werf-myproject", false); err != nil {
+		t.Fatalf("unexpected error in the annotations mode: %s", err)
+	}
+
+	if err := ensureKubernetesLocksMode(ctx, client, "werf-synchronization", "werf-myproject", true); err != nil {
+		t.Fatalf("unexpected error on the switch to the leases mode: %s", err)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("werf-synchronization").Get(ctx, "werf-myproject", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Annotations[KubernetesLeasesModeAnnotation] != "true" {
+		t.Fatalf("expected ConfigMap to be marked, got annotations %v", cm.Annotations)
+	}
+
+	if err := ensureKubernetesLocksMode(ctx, client, "werf-synchronization", "werf-myproject", true); err != nil {
+		t.Fatalf("unexpected error in the leases mode: %s", err)
+	}
+
+	if err := ensureKubernetesLocksMode(ctx, client, "werf-synchronization", "werf-myproject", false); err == nil {
+		t.Fatal("expected error in the annotations mode after the switch to the leases mode")
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/kubeutils"
+)
+
+// KubernetesPatchStagesStorageCacheFieldManagerPrefix is the prefix of the server-side apply field manager of the digest key.
+const KubernetesPatchStagesStorageCacheFieldManagerPrefix = "werf-stages-storage-cache-"
+
+// KubernetesPatchStagesStorageCache stores the stages of each digest in the separate key of the ConfigMap data.
+// Each key is set with the server-side apply by the field manager of the digest, so concurrent changes of different digests
+// do not require reading the whole cache and do not conflict with each other: the apply changes only the key owned by the field manager.
+// The ConfigMap is created by the first apply.
+type KubernetesPatchStagesStorageCache struct {
+	KubeClient           kubernetes.Interface
+	Namespace            string
+	GetConfigMapNameFunc func(projectName string) string
+}
+
+func NewKubernetesPatchStagesStorageCache(namespace string, kubeClient kubernetes.Interface, getConfigMapNameFunc func(projectName string) string) *KubernetesPatchStagesStorageCache {
+	return &KubernetesPatchStagesStorageCache{KubeClient: kubeClient, Namespace: namespace, GetConfigMapNameFunc: getConfigMapNameFunc}
+}
+
+func (cache *KubernetesPatchStagesStorageCache) String() string {
+	return fmt.Sprintf("kubernetes ns/%s", cache.Namespace)
+}
+
+func (cache *KubernetesPatchStagesStorageCache) getData(ctx context.Context, projectName string) (map[string]string, bool, error) {
+	name := cache.GetConfigMapNameFunc(projectName)
+
+	obj, err := cache.KubeClient.CoreV1().ConfigMaps(cache.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("get cm/%s error: %s", name, err)
+	}
+
+	return obj.Data, true, nil
+}
+
+func (cache *KubernetesPatchStagesStorageCache) unmarshalStages(ctx context.Context, projectName, digest, data string) ([]image.StageID, bool) {
+	var stages []image.StageID
+	if err := json.Unmarshal([]byte(data), &stages); err != nil {
+		logboek.Context(ctx).Error().LogF("Error unmarshalling storage cache json in cm/%s by key %q: %s: will ignore cache\n", cache.GetConfigMapNameFunc(projectName), digest, err)
+		return nil, false
+	}
+	return stages, true
+}
+
+func (cache *KubernetesPatchStagesStorageCache) GetAllStages(ctx context.Context, projectName string) (bool, []image.StageID, error) {
+	data, found, err := cache.getData(ctx, projectName)
+	if err != nil || !found {
+		return false, nil, err
+	}
+
+	var res []image.StageID
+	for digest, stagesData := range data {
+		stages, ok := cache.unmarshalStages(ctx, projectName, digest, stagesData)
+		if !ok {
+			return false, nil, nil
+		}
+		res = append(res, stages...)
+	}
+
+	return true, res, nil
+}
+
+func (cache *KubernetesPatchStagesStorageCache) DeleteAllStages(ctx context.Context, projectName string) error {
+	name := cache.GetConfigMapNameFunc(projectName)
+	if err := cache.KubeClient.CoreV1().ConfigMaps(cache.Namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete cm/%s error: %s", name, err)
+	}
+	return nil
+}
+
+func (cache *KubernetesPatchStagesStorageCache) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	data, found, err := cache.getData(ctx, projectName)
+	if err != nil || !found {
+		return false, nil, err
+	}
+
+	stagesData, hasKey := data[digest]
+	if !hasKey {
+		return false, nil, nil
+	}
+
+	stages, ok := cache.unmarshalStages(ctx, projectName, digest, stagesData)
+	return ok, stages, nil
+}
+
+func (cache *KubernetesPatchStagesStorageCache) StoreStagesByDigest(ctx context.Context, projectName, digest string, stages []image.StageID) error {
+	stagesData, err := json.Marshal(stages)
+	if err != nil {
+		return fmt.Errorf("unable to marshal stages: %s", err)
+	}
+
+	return cache.apply(ctx, projectName, digest, map[string]string{digest: string(stagesData)})
+}
+
+// DeleteStagesByDigest removes the key of the digest with the apply of the digest field manager without the key.
+func (cache *KubernetesPatchStagesStorageCache) DeleteStagesByDigest(ctx context.Context, projectName, digest string) error {
+	return cache.apply(ctx, projectName, digest, map[string]string{})
+}
+
+// apply sets the data keys owned by the field manager of the digest, the other keys owned by the field manager are removed.
+// The apply is retried when the namespace is not found or the ConfigMap is created concurrently.
+func (cache *KubernetesPatchStagesStorageCache) apply(ctx context.Context, projectName, digest string, data map[string]string) error {
+	name := cache.GetConfigMapNameFunc(projectName)
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name},
+		"data":       data,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshal cm/%s apply patch: %s", name, err)
+	}
+
+	force := true
+	patchOptions := metav1.PatchOptions{FieldManager: KubernetesPatchStagesStorageCacheFieldManagerPrefix + digest, Force: &force}
+
+	isRetriable := func(err error) bool {
+		return errors.IsNotFound(err) || errors.IsAlreadyExists(err) || errors.IsConflict(err)
+	}
+
+	if err := retry.OnError(retry.DefaultRetry, isRetriable, func() error {
+		_, err := cache.KubeClient.CoreV1().ConfigMaps(cache.Namespace).Patch(ctx, name, types.ApplyPatchType, patch, patchOptions)
+		if errors.IsNotFound(err) {
+			// the apply creates the ConfigMap, but not the namespace
+			if err := kubeutils.CreateNamespaceIfNotExists(cache.KubeClient, cache.Namespace); err != nil {
+				return err
+			}
+		}
+		return err
+	}); err != nil {
+		return fmt.Errorf("apply cm/%s error: %s", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// applyClientset emulates the server-side apply of the ConfigMap data keys, which is not supported by the fake clientset:
+// the data keys are owned by the field managers, the apply removes the keys owned only by the field manager and not set by the apply.
+// Unlike the kube-apiserver, the apply fails when the namespace does not exist.
+type applyClientset struct {
+	*fake.Clientset
+
+	mux    sync.Mutex
+	owners map[string]map[string]map[string]bool // ConfigMap -> data key -> field managers
+}
+
+func newApplyClientset(namespaces ...string) *applyClientset {
+	var objects []runtime.Object
+	for _, namespace := range namespaces {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	}
+
+	return &applyClientset{Clientset: fake.NewSimpleClientset(objects...), owners: map[string]map[string]map[string]bool{}}
+}
+
+func (c *applyClientset) CoreV1() corev1client.CoreV1Interface {
+	return &applyCoreV1{CoreV1Interface: c.Clientset.CoreV1(), clientset: c}
+}
+
+type applyCoreV1 struct {
+	corev1client.CoreV1Interface
+	clientset *applyClientset
+}
+
+func (c *applyCoreV1) ConfigMaps(namespace string) corev1client.ConfigMapInterface {
+	return &applyConfigMaps{ConfigMapInterface: c.CoreV1Interface.ConfigMaps(namespace), clientset: c.clientset, namespace: namespace}
+}
+
+type applyConfigMaps struct {
+	corev1client.ConfigMapInterface
+	clientset *applyClientset
+	namespace string
+}
+
+func (c *applyConfigMaps) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*corev1.ConfigMap, error) {
+	if pt != types.ApplyPatchType {
+		return c.ConfigMapInterface.Patch(ctx, name, pt, data, opts, subresources...)
+	}
+	if opts.FieldManager == "" || opts.Force == nil || !*opts.Force {
+		return nil, fmt.Errorf("the forced apply with the field manager expected, got %+v", opts)
+	}
+
+	var applied corev1.ConfigMap
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return nil, err
+	}
+
+	c.clientset.mux.Lock()
+	defer c.clientset.mux.Unlock()
+
+	if _, err := c.clientset.Clientset.CoreV1().Namespaces().Get(ctx, c.namespace, metav1.GetOptions{}); err != nil {
+		return nil, err
+	}
+
+	cm, err := c.ConfigMapInterface.Get(ctx, name, metav1.GetOptions{})
+	isNew := errors.IsNotFound(err)
+	if isNew {
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.namespace}}
+	} else if err != nil {
+		return nil, err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	ownersKey := c.namespace + "/" + name
+	owners := c.clientset.owners[ownersKey]
+	if owners == nil || isNew {
+		owners = map[string]map[string]bool{}
+		c.clientset.owners[ownersKey] = owners
+	}
+
+	for key, managers := range owners {
+		if _, isApplied := applied.Data[key]; isApplied || !managers[opts.FieldManager] {
+			continue
+		}
+
+		delete(managers, opts.FieldManager)
+		if len(managers) == 0 {
+			delete(owners, key)
+			delete(cm.Data, key)
+		}
+	}
+
+	for key, value := range applied.Data {
+		cm.Data[key] = value
+		owners[key] = map[string]bool{opts.FieldManager: true}
+	}
+
+	if isNew {
+		return c.ConfigMapInterface.Create(ctx, cm, metav1.CreateOptions{})
+	}
+	return c.ConfigMapInterface.Update(ctx, cm, metav1.UpdateOptions{})
+}
+
+func TestKubernetesPatchStagesStorageCache(t *testing.T) {
+	ctx := context.Background()
+	client := newApplyClientset()
+	cache := NewKubernetesPatchStagesStorageCache("werf-synchronization", client, func(projectName string) string {
+		return "werf-" + projectName + "-stages-storage-cache"
+	})
+
+	if found, _, err := cache.GetStagesByDigest(ctx, "myproject", "digest-1"); err != nil || found {
+		t.Fatalf("expected no stages in the empty cache, got found=%v err=%v", found, err)
+	}
+
+	stages1 := []image.StageID{{Digest: "digest-1", UniqueID: 1}, {Digest: "digest-1", UniqueID: 2}}
+	stages2 := []image.StageID{{Digest: "digest-2", UniqueID: 3}}
+
+	// the first store creates the namespace and the ConfigMap
+	if err := cache.StoreStagesByDigest(ctx, "myproject", "digest-1", stages1); err != nil {
+		t.Fatalf("unexpected store error: %s", err)
+	}
+	if err := cache.StoreStagesByDigest(ctx, "myproject", "digest-2", stages2); err != nil {
+		t.Fatalf("unexpected store error: %s", err)
+	}
+
+	if found, stages, err := cache.GetStagesByDigest(ctx, "myproject", "digest-1"); err != nil || !found || !reflect.DeepEqual(stages, stages1) {
+		t.Fatalf("expected stages %v, got %v (found=%v err=%v)", stages1, stages, found, err)
+	}
+
+	found, allStages, err := cache.GetAllStages(ctx, "myproject")
+	if err != nil || !found {
+		t.Fatalf("expected all stages, got found=%v err=%v", found, err)
+	}
+	sort.Slice(allStages, func(i, j int) bool { return allStages[i].UniqueID < allStages[j].UniqueID })
+	if expected := append(append([]image.StageID{}, stages1...), stages2...); !reflect.DeepEqual(allStages, expected) {
+		t.Fatalf("expected all stages %v, got %v", expected, allStages)
+	}
+
+	if err := cache.DeleteStagesByDigest(ctx, "myproject", "digest-1"); err != nil {
+		t.Fatalf("unexpected delete error: %s", err)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("werf-synchronization").Get(ctx, "werf-myproject-stages-storage-cache", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, hasKey := cm.Data["digest-1"]; hasKey || len(cm.Data) != 1 {
+		t.Fatalf("expected only digest-2 key to be kept, got %v", cm.Data)
+	}
+
+	if err := cache.DeleteAllStages(ctx, "myproject"); err != nil {
+		t.Fatalf("unexpected delete all error: %s", err)
+	}
+	if found, _, err := cache.GetAllStages(ctx, "myproject"); err != nil || found {
+		t.Fatalf("expected no stages after delete all, got found=%v err=%v", found, err)
+	}
+
+	// the delete of the missing ConfigMap key does not fail
+	if err := cache.DeleteStagesByDigest(ctx, "myproject", "digest-1"); err != nil {
+		t.Fatalf("unexpected delete error: %s", err)
+	}
+}
+
+func TestKubernetesPatchStagesStorageCache_InvalidData(t *testing.T) {
+	ctx := context.Background()
+	client := newApplyClientset("werf-synchronization")
+	cache := NewKubernetesPatchStagesStorageCache("werf-synchronization", client, func(projectName string) string {
+		return "werf-" + projectName
+	})
+
+	if err := cache.apply(ctx, "myproject", "digest-1", map[string]string{"digest-1": "invalid"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, _, err := cache.GetStagesByDigest(ctx, "myproject", "digest-1"); err != nil || found {
+		t.Fatalf("expected invalid record to be ignored, got found=%v err=%v", found, err)
+	}
+
+	if found, _, err := cache.GetAllStages(ctx, "myproject"); err != nil || found {
+		t.Fatalf("expected invalid cache to be ignored, got found=%v err=%v", found, err)
+	}
+}
+
+func TestKubernetesPatchStagesStorageCache_ConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	client := newApplyClientset("werf-synchronization")
+	cache := NewKubernetesPatchStagesStorageCache("werf-synchronization", client, func(projectName string) string {
+		return "werf-" + projectName + "-stages-storage-cache"
+	})
+
+	const digestsCount = 20
+	getDigest := func(ind int) string { return fmt.Sprintf("digest-%d", ind) }
+	getStages := func(ind int) []image.StageID {
+		return []image.StageID{{Digest: getDigest(ind), UniqueID: int64(ind)}}
+	}
+
+	// the odd digests are stored before the test, the even digests are stored and the odd digests are deleted concurrently
+	for ind := 1; ind < digestsCount; ind += 2 {
+		if err := cache.StoreStagesByDigest(ctx, "myproject", getDigest(ind), getStages(ind)); err != nil {
+			t.Fatalf("unexpected store error: %s", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, digestsCount)
+	for ind := 0; ind < digestsCount; ind++ {
+		wg.Add(1)
+		go func(ind int) {
+			defer wg.Done()
+
+			if ind%2 == 0 {
+				errs <- cache.StoreStagesByDigest(ctx, "myproject", getDigest(ind), getStages(ind))
+			} else {
+				errs <- cache.DeleteStagesByDigest(ctx, "myproject", getDigest(ind))
+			}
+		}(ind)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected concurrent change error: %s", err)
+		}
+	}
+
+	for ind := 0; ind < digestsCount; ind++ {
+		found, stages, err := cache.GetStagesByDigest(ctx, "myproject", getDigest(ind))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ind%2 == 0 && (!found || !reflect.DeepEqual(stages, getStages(ind))) {
+			t.Errorf("expected stages %v of the stored %s, got %v (found=%v)", getStages(ind), getDigest(ind), stages, found)
+		} else if ind%2 != 0 && found {
+			t.Errorf("expected the deleted %s not to be found, got %v", getDigest(ind), stages)
+		}
+	}
+}
@@ -25,8 +25,17 @@ const (
 	LocalImportMetadata_ImageNameFormat = "werf-import-metadata/%s"
 	LocalImportMetadata_TagFormat       = "%s"
 
+	LocalChangedOnlyRecord_ImageNameFormat = "werf-changed-only/%s"
+	LocalChangedOnlyRecord_ImageFormat     = "werf-changed-only/%s:%s"
+
 	LocalClientIDRecord_ImageNameFormat = "werf-client-id/%s"
 	LocalClientIDRecord_ImageFormat     = "werf-client-id/%s:%s-%d"
+
+	LocalUsageRecord_ImageNameFormat = "werf-usage/%s"
+	LocalUsageRecord_ImageFormat     = "werf-usage/%s:%s-%d"
+
+	LocalStageCacheSaltRecord_ImageNameFormat = "werf-cache-salt/%s"
+	LocalStageCacheSaltRecord_ImageFormat     = "werf-cache-salt/%s:%s-%s-%d"
 )
 
 const ImageDeletionFailedDueToUsedByContainerErrorTip = "Use --force option to remove all containers that are based on deleting werf docker images"
@@ -67,7 +76,7 @@ func (storage *LocalDockerServerStagesStorage) GetStagesIDs(ctx context.Context,
 	return convertToStagesList(images)
 }
 
-func (storage *LocalDockerServerStagesStorage) ExportStage(ctx context.Context, stageDescription *image.StageDescription, destinationReference string) error {
+func (storage *LocalDockerServerStagesStorage) ExportStage(ctx context.Context, stageDescription *image.StageDescription, destinationReference string, extraLabels map[string]string) error {
 	if err := docker.CliTag(ctx, stageDescription.Info.Name, destinationReference); err != nil {
 		return err
 	}
@@ -77,7 +86,7 @@ func (storage *LocalDockerServerStagesStorage) ExportStage(ctx context.Context,
 		return err
 	}
 
-	return docker_registry.API().MutateAndPushImage(ctx, destinationReference, destinationReference, mutateExportStageConfig)
+	return docker_registry.API().MutateAndPushImage(ctx, destinationReference, destinationReference, getMutateExportStageConfigFunc(extraLabels))
 }
 
 func (storage *LocalDockerServerStagesStorage) DeleteStage(ctx context.Context, stageDescription *image.StageDescription, options DeleteImageOptions) error {
@@ -88,6 +97,14 @@ func (storage *LocalDockerServerStagesStorage) RejectStage(ctx context.Context,
 	return nil
 }
 
+func (storage *LocalDockerServerStagesStorage) GetRejectedStages(_ context.Context, _ string) ([]*RejectedStageRecord, error) {
+	return nil, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) DeleteRejectedStage(_ context.Context, _ string, _ *RejectedStageRecord) error {
+	return nil
+}
+
 type FilterStagesAndProcessRelatedDataOptions struct {
 	SkipUsedImage            bool
 	RmForce                  bool
@@ -261,6 +278,78 @@ func (storage *LocalDockerServerStagesStorage) GetImportMetadataIDs(ctx context.
 	return tags, nil
 }
 
+func (storage *LocalDockerServerStagesStorage) GetChangedOnlyRecord(ctx context.Context, projectName, inputsDigest string) (*ChangedOnlyRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetChangedOnlyRecord %s %s\n", projectName, inputsDigest)
+
+	fullImageName := fmt.Sprintf(LocalChangedOnlyRecord_ImageFormat, projectName, inputsDigest)
+	if inspect, err := storage.LocalDockerServerRuntime.GetImageInspect(ctx, fullImageName); err != nil {
+		return nil, fmt.Errorf("unable to get image %s inspect: %s", fullImageName, err)
+	} else if inspect != nil {
+		return newChangedOnlyRecordFromLabels(inspect.Config.Labels), nil
+	} else {
+		return nil, nil
+	}
+}
+
+func (storage *LocalDockerServerStagesStorage) PutChangedOnlyRecord(ctx context.Context, projectName string, rec *ChangedOnlyRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.PutChangedOnlyRecord %s %v\n", projectName, rec)
+
+	// the record of the same inputs digest is replaced with the new one
+	if err := storage.RmChangedOnlyRecord(ctx, projectName, rec.InputsDigest); err != nil {
+		return err
+	}
+
+	fullImageName := fmt.Sprintf(LocalChangedOnlyRecord_ImageFormat, projectName, rec.InputsDigest)
+
+	labels := rec.ToLabels()
+	labels[image.WerfLabel] = projectName
+
+	if err := docker.CreateImage(ctx, fullImageName, labels); err != nil {
+		return fmt.Errorf("unable to create image %q: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *LocalDockerServerStagesStorage) RmChangedOnlyRecord(ctx context.Context, projectName, inputsDigest string) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.RmChangedOnlyRecord %s %s\n", projectName, inputsDigest)
+
+	fullImageName := fmt.Sprintf(LocalChangedOnlyRecord_ImageFormat, projectName, inputsDigest)
+	if exists, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to check existence of image %s: %s", fullImageName, err)
+	} else if !exists {
+		return nil
+	}
+
+	if err := docker.CliRmi(ctx, "--force", fullImageName); err != nil {
+		return fmt.Errorf("unable to remove image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *LocalDockerServerStagesStorage) GetChangedOnlyRecordsInputsDigests(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetChangedOnlyRecordsInputsDigests %s\n", projectName)
+
+	filterSet := filters.NewArgs()
+	filterSet.Add("reference", fmt.Sprintf(LocalChangedOnlyRecord_ImageNameFormat, projectName))
+
+	images, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get docker images: %s", err)
+	}
+
+	var res []string
+	for _, img := range images {
+		for _, repoTag := range img.RepoTags {
+			_, tag := image.ParseRepositoryAndTag(repoTag)
+			res = append(res, tag)
+		}
+	}
+
+	return res, nil
+}
+
 func makeLocalImportMetadataName(projectName, importSourceID string) string {
 	return strings.Join(
 		[]string{
@@ -340,6 +429,134 @@ func (storage *LocalDockerServerStagesStorage) PostClientIDRecord(ctx context.Co
 	return nil
 }
 
+func (storage *LocalDockerServerStagesStorage) GetUsageRecords(ctx context.Context, projectName string) ([]*UsageRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetUsageRecords for project %s\n", projectName)
+
+	filterSet := filters.NewArgs()
+	filterSet.Add("reference", fmt.Sprintf(LocalUsageRecord_ImageNameFormat, projectName))
+
+	images, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get docker images: %s", err)
+	}
+
+	var res []*UsageRecord
+	for _, img := range images {
+		for _, repoTag := range img.RepoTags {
+			_, tag := image.ParseRepositoryAndTag(repoTag)
+
+			rec, err := parseUsageRecordTag(tag)
+			if err != nil {
+				continue
+			}
+			res = append(res, rec)
+
+			logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetUsageRecords got usage record: %s\n", rec)
+		}
+	}
+
+	return res, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) PostUsageRecord(ctx context.Context, projectName string, rec *UsageRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.PostUsageRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(LocalUsageRecord_ImageFormat, projectName, rec.StageID, rec.LastSeenTimestamp)
+
+	if exists, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to check existence of image %q: %s", fullImageName, err)
+	} else if exists {
+		return nil
+	}
+
+	labels := map[string]string{image.WerfLabel: projectName}
+
+	if err := docker.CreateImage(ctx, fullImageName, labels); err != nil {
+		return fmt.Errorf("unable to create image %q: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *LocalDockerServerStagesStorage) RmUsageRecord(ctx context.Context, projectName string, rec *UsageRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.RmUsageRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(LocalUsageRecord_ImageFormat, projectName, rec.StageID, rec.LastSeenTimestamp)
+
+	if exists, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to check existence of image %s: %s", fullImageName, err)
+	} else if !exists {
+		return nil
+	}
+
+	if err := docker.CliRmi(ctx, "--force", fullImageName); err != nil {
+		return fmt.Errorf("unable to remove image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *LocalDockerServerStagesStorage) GetStageCacheSaltRecords(ctx context.Context, projectName string) ([]*StageCacheSaltRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetStageCacheSaltRecords for project %s\n", projectName)
+
+	filterSet := filters.NewArgs()
+	filterSet.Add("reference", fmt.Sprintf(LocalStageCacheSaltRecord_ImageNameFormat, projectName))
+
+	images, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get docker images: %s", err)
+	}
+
+	var res []*StageCacheSaltRecord
+	for _, img := range images {
+		for _, repoTag := range img.RepoTags {
+			_, tag := image.ParseRepositoryAndTag(repoTag)
+
+			rec, err := parseStageCacheSaltRecordTag(tag)
+			if err != nil {
+				continue
+			}
+			res = append(res, rec)
+
+			logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetStageCacheSaltRecords got stage cache salt record: %s\n", rec)
+		}
+	}
+
+	return res, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) PostStageCacheSaltRecord(ctx context.Context, projectName string, rec *StageCacheSaltRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.PostStageCacheSaltRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(LocalStageCacheSaltRecord_ImageFormat, projectName, slugImageNameAsDockerImageTag(rec.ImageName), rec.StageName, rec.TimestampMillisec)
+
+	labels := map[string]string{image.WerfLabel: projectName}
+
+	if err := docker.CreateImage(ctx, fullImageName, labels); err != nil {
+		return fmt.Errorf("unable to create image %q: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *LocalDockerServerStagesStorage) RmStageCacheSaltRecord(ctx context.Context, projectName string, rec *StageCacheSaltRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.RmStageCacheSaltRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(LocalStageCacheSaltRecord_ImageFormat, projectName, slugImageNameAsDockerImageTag(rec.ImageName), rec.StageName, rec.TimestampMillisec)
+
+	if exists, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to check existence of image %s: %s", fullImageName, err)
+	} else if !exists {
+		return nil
+	}
+
+	if err := docker.CliRmi(ctx, "--force", fullImageName); err != nil {
+		return fmt.Errorf("unable to remove image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
 type processRelatedContainersOptions struct {
 	skipUsedImages           bool
 	rmContainersThatUseImage bool
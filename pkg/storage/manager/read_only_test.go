@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// readOnlyTestStagesStorage implements only the reading methods, the other methods of the nil embedded interface panic.
+type readOnlyTestStagesStorage struct {
+	storage.StagesStorage
+	stages map[string][]image.StageID
+}
+
+func (s *readOnlyTestStagesStorage) String() string  { return "test storage" }
+func (s *readOnlyTestStagesStorage) Address() string { return storage.LocalStorageAddress }
+
+func (s *readOnlyTestStagesStorage) GetStagesIDsByDigest(_ context.Context, _, digest string) ([]image.StageID, error) {
+	return s.stages[digest], nil
+}
+
+func (s *readOnlyTestStagesStorage) GetStageDescription(_ context.Context, _, digest string, uniqueID int64) (*image.StageDescription, error) {
+	return &image.StageDescription{StageID: &image.StageID{Digest: digest, UniqueID: uniqueID}, Info: &image.Info{}}, nil
+}
+
+// newReadOnlyTestStorageManager creates the read-only storage manager without the stages storage cache and the lock manager,
+// so that any write attempt panics.
+func newReadOnlyTestStorageManager() *StorageManager {
+	stagesStorage := &readOnlyTestStagesStorage{stages: map[string][]image.StageID{"digest": {{Digest: "digest", UniqueID: 1}}}}
+
+	m := NewStorageManager("project", stagesStorage, stagesStorage, nil, nil, nil, nil)
+	m.EnableReadOnly()
+
+	return m
+}
+
+func TestStorageManager_ReadOnly_Writes(t *testing.T) {
+	ctx := context.Background()
+	m := newReadOnlyTestStorageManager()
+
+	stageDesc := &image.StageDescription{StageID: &image.StageID{Digest: "digest", UniqueID: 1}, Info: &image.Info{Name: "project:digest-1"}}
+
+	for name, f := range map[string]func() error{
+		"ForEachDeleteStage": func() error {
+			return m.ForEachDeleteStage(ctx, ForEachDeleteStageOptions{}, []*image.StageDescription{stageDesc}, nil)
+		},
+		"ForEachDeleteFinalStage": func() error {
+			return m.ForEachDeleteFinalStage(ctx, ForEachDeleteStageOptions{}, []*image.StageDescription{stageDesc}, nil)
+		},
+		"ForEachRmImageMetadata": func() error {
+			return m.ForEachRmImageMetadata(ctx, "project", "image", map[string][]string{"digest-1": {"commit"}}, nil)
+		},
+		"ForEachRmManagedImage": func() error {
+			return m.ForEachRmManagedImage(ctx, "project", []string{"image"}, nil)
+		},
+		"ForEachDeleteRejectedStage": func() error {
+			return m.ForEachDeleteRejectedStage(ctx, "project", []*storage.RejectedStageRecord{{}}, nil)
+		},
+		"ForEachRmImportMetadata": func() error {
+			return m.ForEachRmImportMetadata(ctx, "project", []string{"id"}, nil)
+		},
+		"ForEachRmChangedOnlyRecord": func() error {
+			return m.ForEachRmChangedOnlyRecord(ctx, "project", []string{"digest"}, nil)
+		},
+		"ForEachRmStageCacheSaltRecord": func() error {
+			return m.ForEachRmStageCacheSaltRecord(ctx, "project", []*storage.StageCacheSaltRecord{{}}, nil)
+		},
+		"InvalidateStageCache": func() error {
+			_, err := m.InvalidateStageCache(ctx, "image", "install")
+			return err
+		},
+		"CopySuitableByDigestStage": func() error {
+			_, err := m.CopySuitableByDigestStage(ctx, stageDesc, m.StagesStorage, m.StagesStorage, nil)
+			return err
+		},
+	} {
+		// the wrapped errors are formatted with the ErrReadOnly message at the end
+		if err := f(); err == nil || !strings.HasSuffix(err.Error(), ErrReadOnly.Error()) {
+			t.Errorf("%s: expected ErrReadOnly, got: %v", name, err)
+		}
+	}
+}
+
+func TestStorageManager_ReadOnly_StagesStorageCache(t *testing.T) {
+	ctx := context.Background()
+	m := newReadOnlyTestStorageManager()
+
+	if err := m.ResetStagesStorageCache(ctx); err != nil {
+		t.Fatalf("expected the cache reset to be skipped, got: %s", err)
+	}
+
+	if err := m.AtomicStoreStagesByDigestToCache(ctx, "install", "digest", []image.StageID{{Digest: "digest", UniqueID: 1}}); err != nil {
+		t.Fatalf("expected the cache store to be skipped, got: %s", err)
+	}
+
+	// the stages are read from the stages storage bypassing the cache
+	stages, err := m.GetStagesByDigest(ctx, "install", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 1 || *stages[0].StageID != (image.StageID{Digest: "digest", UniqueID: 1}) {
+		t.Fatalf("unexpected stages %v", stages)
+	}
+}
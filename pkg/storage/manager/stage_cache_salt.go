@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+// GetStageCacheSalt returns the salt of the latest cache invalidation of the image stage or an empty string if the stage cache has never been invalidated.
+func (m *StorageManager) GetStageCacheSalt(ctx context.Context, imageName, stageName string) (string, error) {
+	m.stageCacheSaltsOnce.Do(func() {
+		records, err := m.StagesStorage.GetStageCacheSaltRecords(ctx, m.ProjectName)
+		if err != nil {
+			m.stageCacheSaltsErr = fmt.Errorf("unable to get stage cache salt records from %s: %s", m.StagesStorage.String(), err)
+			return
+		}
+
+		latestRecords, _ := storage.SplitStageCacheSaltRecords(records)
+
+		m.stageCacheSalts = map[string]string{}
+		for _, rec := range latestRecords {
+			m.stageCacheSalts[stageCacheSaltKey(rec.ImageName, rec.StageName)] = strconv.FormatInt(rec.TimestampMillisec, 10)
+		}
+	})
+
+	if m.stageCacheSaltsErr != nil {
+		return "", m.stageCacheSaltsErr
+	}
+
+	return m.stageCacheSalts[stageCacheSaltKey(imageName, stageName)], nil
+}
+
+// InvalidateStageCache stores the new cache salt of the image stage, so the stage and all following stages of the image get new digests and are rebuilt.
+func (m *StorageManager) InvalidateStageCache(ctx context.Context, imageName, stageName string) (*storage.StageCacheSaltRecord, error) {
+	if m.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	rec := &storage.StageCacheSaltRecord{
+		ImageName:         imageName,
+		StageName:         stageName,
+		TimestampMillisec: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+
+	if err := m.StagesStorage.PostStageCacheSaltRecord(ctx, m.ProjectName, rec); err != nil {
+		return nil, fmt.Errorf("unable to post stage cache salt record into %s: %s", m.StagesStorage.String(), err)
+	}
+
+	logboek.Context(ctx).Info().LogF("Posted stage cache salt record %s\n", rec)
+
+	return rec, nil
+}
+
+func (m *StorageManager) ForEachRmStageCacheSaltRecord(ctx context.Context, projectName string, records []*storage.StageCacheSaltRecord, f func(ctx context.Context, rec *storage.StageCacheSaltRecord, err error) error) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	return m.doTasks(ctx, len(records), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		rec := records[taskId]
+		err := m.StagesStorage.RmStageCacheSaltRecord(ctx, projectName, rec)
+		return f(ctx, rec, err)
+	})
+}
+
+func stageCacheSaltKey(imageName, stageName string) string {
+	return fmt.Sprintf("%s/%s", imageName, stageName)
+}
@@ -14,7 +14,9 @@ import (
 	"github.com/werf/logboek/pkg/types"
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/remote_builder"
 	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/storage/lrumeta"
 	"github.com/werf/werf/pkg/util/parallel"
@@ -25,6 +27,7 @@ import (
 var (
 	ErrShouldResetStagesStorageCache = errors.New("should reset storage cache")
 	ErrStageNotFound                 = errors.New("stage not found")
+	ErrReadOnly                      = errors.New("stages storage is in read-only mode")
 )
 
 func IsStageNotFound(err error) bool {
@@ -49,6 +52,7 @@ type StorageManagerInterface interface {
 
 	EnableParallel(parallelTasksLimit int)
 	MaxNumberOfWorkers() int
+	IsReadOnly() bool
 	GenerateStageUniqueID(digest string, stages []*image.StageDescription) (string, int64)
 
 	LockStageImage(ctx context.Context, imageName string) error
@@ -60,6 +64,7 @@ type StorageManagerInterface interface {
 	ResetStagesStorageCache(ctx context.Context) error
 
 	FetchStage(ctx context.Context, containerRuntime container_runtime.ContainerRuntime, stg stage.Interface) error
+	GetCacheStagesStorageOfFetchedStage(stg stage.Interface) storage.StagesStorage
 	SelectSuitableStage(ctx context.Context, c stage.Conveyor, stg stage.Interface, stages []*image.StageDescription) (*image.StageDescription, error)
 	CopySuitableByDigestStage(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage, destinationStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) (*image.StageDescription, error)
 	CopyStageIntoCache(ctx context.Context, stg stage.Interface, containerRuntime container_runtime.ContainerRuntime) error
@@ -69,8 +74,15 @@ type StorageManagerInterface interface {
 	ForEachDeleteFinalStage(ctx context.Context, options ForEachDeleteStageOptions, stagesDescriptions []*image.StageDescription, f func(ctx context.Context, stageDesc *image.StageDescription, err error) error) error
 	ForEachRmImageMetadata(ctx context.Context, projectName, imageNameOrID string, stageIDCommitList map[string][]string, f func(ctx context.Context, commit, stageID string, err error) error) error
 	ForEachRmManagedImage(ctx context.Context, projectName string, managedImages []string, f func(ctx context.Context, managedImage string, err error) error) error
+	ForEachDeleteRejectedStage(ctx context.Context, projectName string, rejectedStages []*storage.RejectedStageRecord, f func(ctx context.Context, rejectedStage *storage.RejectedStageRecord, err error) error) error
 	ForEachGetImportMetadata(ctx context.Context, projectName string, ids []string, f func(ctx context.Context, metadataID string, metadata *storage.ImportMetadata, err error) error) error
 	ForEachRmImportMetadata(ctx context.Context, projectName string, ids []string, f func(ctx context.Context, id string, err error) error) error
+	ForEachGetChangedOnlyRecord(ctx context.Context, projectName string, inputsDigests []string, f func(ctx context.Context, inputsDigest string, rec *storage.ChangedOnlyRecord, err error) error) error
+	ForEachRmChangedOnlyRecord(ctx context.Context, projectName string, inputsDigests []string, f func(ctx context.Context, inputsDigest string, err error) error) error
+
+	GetStageCacheSalt(ctx context.Context, imageName, stageName string) (string, error)
+	InvalidateStageCache(ctx context.Context, imageName, stageName string) (*storage.StageCacheSaltRecord, error)
+	ForEachRmStageCacheSaltRecord(ctx context.Context, projectName string, records []*storage.StageCacheSaltRecord, f func(ctx context.Context, rec *storage.StageCacheSaltRecord, err error) error) error
 }
 
 func ShouldResetStagesStorageCache(err error) bool {
@@ -131,8 +143,12 @@ func (stages *StagesList) AddStageID(stageID image.StageID) {
 }
 
 type StorageManager struct {
-	parallel           bool
-	parallelTasksLimit int
+	parallel            bool
+	parallelTasksLimit  int
+	parallelTaskTimeout time.Duration
+
+	// readOnly forbids any writes into the stages storages and the stages storage cache
+	readOnly bool
 
 	ProjectName string
 
@@ -149,6 +165,19 @@ type StorageManager struct {
 
 	FinalStagesListCacheMux sync.Mutex
 	FinalStagesListCache    *StagesList
+
+	// finalStagesDescriptions are the descriptions of the stages copied into the final repo by the stage ID
+	finalStagesDescriptionsMux sync.Mutex
+	finalStagesDescriptions    map[string]*image.StageDescription
+
+	// cacheStagesStorageOfFetchedStage is the cache stages storage the stage has been fetched from by the stage ID
+	cacheStagesStorageOfFetchedStageMux sync.Mutex
+	cacheStagesStorageOfFetchedStage    map[string]storage.StagesStorage
+
+	// stageCacheSalts is the latest stage cache salt by the image and the stage name, loaded once from the stages storage
+	stageCacheSaltsOnce sync.Once
+	stageCacheSalts     map[string]string
+	stageCacheSaltsErr  error
 }
 
 func (m *StorageManager) GetStagesStorage() storage.StagesStorage {
@@ -170,13 +199,23 @@ func (m *StorageManager) GetImageInfoGetter(imageName string, stg stage.Interfac
 	if m.FinalStagesStorage != nil {
 		finalImageName := m.FinalStagesStorage.ConstructStageImageName(m.ProjectName, stageID.Digest, stageID.UniqueID)
 		_, tag := image.ParseRepositoryAndTag(finalImageName)
-		return image.NewInfoGetter(imageName, finalImageName, tag)
+
+		// the repo digest is known only for the stage copied into the final repo by the CopyStageIntoFinalRepo
+		var repoDigest string
+		if finalStageDesc := m.getFinalStageDescription(*stageID); finalStageDesc != nil {
+			repoDigest = finalStageDesc.Info.RepoDigest
+		}
+
+		return image.NewInfoGetter(imageName, finalImageName, tag, stageID.Digest, repoDigest, info.ID)
 	}
 
 	return image.NewInfoGetter(
 		imageName,
 		info.Name,
 		info.Tag,
+		stageID.Digest,
+		info.RepoDigest,
+		info.ID,
 	)
 }
 
@@ -197,6 +236,21 @@ func (m *StorageManager) EnableParallel(parallelTasksLimit int) {
 	m.parallelTasksLimit = parallelTasksLimit
 }
 
+// SetParallelTaskTimeout limits the duration of each task of the bulk operations (no limit by default).
+func (m *StorageManager) SetParallelTaskTimeout(timeout time.Duration) {
+	m.parallelTaskTimeout = timeout
+}
+
+// EnableReadOnly makes the storage manager use the stages storages without any writes: the stages storage cache is bypassed,
+// the cache repos are not refilled, the broken stages are not rejected and the operations which require pushing fail with ErrReadOnly.
+func (m *StorageManager) EnableReadOnly() {
+	m.readOnly = true
+}
+
+func (m *StorageManager) IsReadOnly() bool {
+	return m.readOnly
+}
+
 func (m *StorageManager) MaxNumberOfWorkers() int {
 	if m.parallel && m.parallelTasksLimit > 0 {
 		return m.parallelTasksLimit
@@ -205,7 +259,32 @@ func (m *StorageManager) MaxNumberOfWorkers() int {
 	return 1
 }
 
+// doTasks runs the bulk operation tasks in parallel and reports the number of the finished tasks when the operation is interrupted.
+func (m *StorageManager) doTasks(ctx context.Context, numberOfTasks int, options parallel.DoTasksOptions, taskFunc func(ctx context.Context, taskId int) error) error {
+	options.MaxNumberOfWorkers = m.MaxNumberOfWorkers()
+	options.TaskTimeout = m.parallelTaskTimeout
+
+	results, err := parallel.DoTasksWithResults(ctx, numberOfTasks, options, taskFunc)
+	if err != nil && len(results) < numberOfTasks {
+		var numberOfSucceededTasks int
+		for _, result := range results {
+			if result.Err == nil {
+				numberOfSucceededTasks++
+			}
+		}
+
+		// the original error is kept at the end to be checked by suffix (e.g. ShouldResetStagesStorageCache)
+		return fmt.Errorf("interrupted with %d/%d tasks done: %w", numberOfSucceededTasks, numberOfTasks, err)
+	}
+
+	return err
+}
+
 func (m *StorageManager) ResetStagesStorageCache(ctx context.Context) error {
+	if m.readOnly {
+		return nil
+	}
+
 	msg := fmt.Sprintf("Reset storage cache %s for project %q", m.StagesStorageCache.String(), m.ProjectName)
 	return logboek.Context(ctx).Default().LogProcess(msg).DoError(func() error {
 		return m.StagesStorageCache.DeleteAllStages(ctx, m.ProjectName)
@@ -229,9 +308,7 @@ func (m *StorageManager) GetStageDescriptionList(ctx context.Context) ([]*image.
 	var mutex sync.Mutex
 	var stages []*image.StageDescription
 
-	if err := parallel.DoTasks(ctx, len(stageIDs), parallel.DoTasksOptions{
-		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
-	}, func(ctx context.Context, taskId int) error {
+	if err := m.doTasks(ctx, len(stageIDs), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
 		stageID := stageIDs[taskId]
 
 		if stageDesc, err := getStageDescription(ctx, m.ProjectName, stageID, m.StagesStorage, m.CacheStagesStorageList, getStageDescriptionOptions{AllowStagesStorageCacheReset: true, WithLocalManifestCache: m.getWithLocalManifestCacheOption()}); err != nil {
@@ -266,9 +343,7 @@ func (m *StorageManager) GetFinalStageDescriptionList(ctx context.Context) ([]*i
 	var mutex sync.Mutex
 	var stages []*image.StageDescription
 
-	if err := parallel.DoTasks(ctx, len(stageIDs), parallel.DoTasksOptions{
-		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
-	}, func(ctx context.Context, taskId int) error {
+	if err := m.doTasks(ctx, len(stageIDs), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
 		stageID := stageIDs[taskId]
 
 		if stageDesc, err := getStageDescription(ctx, m.ProjectName, stageID, m.FinalStagesStorage, nil, getStageDescriptionOptions{AllowStagesStorageCacheReset: true, WithLocalManifestCache: true}); err != nil {
@@ -291,10 +366,11 @@ func (m *StorageManager) GetFinalStageDescriptionList(ctx context.Context) ([]*i
 }
 
 func (m *StorageManager) ForEachDeleteFinalStage(ctx context.Context, options ForEachDeleteStageOptions, stagesDescriptions []*image.StageDescription, f func(ctx context.Context, stageDesc *image.StageDescription, err error) error) error {
-	return parallel.DoTasks(ctx, len(stagesDescriptions), parallel.DoTasksOptions{
-		MaxNumberOfWorkers:         m.MaxNumberOfWorkers(),
-		InitDockerCLIForEachWorker: true,
-	}, func(ctx context.Context, taskId int) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	return m.doTasks(ctx, len(stagesDescriptions), parallel.DoTasksOptions{InitDockerCLIForEachWorker: true}, func(ctx context.Context, taskId int) error {
 		stageDescription := stagesDescriptions[taskId]
 
 		err := m.FinalStagesStorage.DeleteStage(ctx, stageDescription, options.DeleteImageOptions)
@@ -303,6 +379,10 @@ func (m *StorageManager) ForEachDeleteFinalStage(ctx context.Context, options Fo
 }
 
 func (m *StorageManager) ForEachDeleteStage(ctx context.Context, options ForEachDeleteStageOptions, stagesDescriptions []*image.StageDescription, f func(ctx context.Context, stageDesc *image.StageDescription, err error) error) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
 	if localStagesStorage, isLocal := m.StagesStorage.(*storage.LocalDockerServerStagesStorage); isLocal {
 		filteredStagesDescriptions, err := localStagesStorage.FilterStagesAndProcessRelatedData(ctx, stagesDescriptions, options.FilterStagesAndProcessRelatedDataOptions)
 		if err != nil {
@@ -318,10 +398,7 @@ func (m *StorageManager) ForEachDeleteStage(ctx context.Context, options ForEach
 		}
 	}
 
-	return parallel.DoTasks(ctx, len(stagesDescriptions), parallel.DoTasksOptions{
-		MaxNumberOfWorkers:         m.MaxNumberOfWorkers(),
-		InitDockerCLIForEachWorker: true,
-	}, func(ctx context.Context, taskId int) error {
+	return m.doTasks(ctx, len(stagesDescriptions), parallel.DoTasksOptions{InitDockerCLIForEachWorker: true}, func(ctx context.Context, taskId int) error {
 		stageDescription := stagesDescriptions[taskId]
 
 		for _, cacheStagesStorage := range m.CacheStagesStorageList {
@@ -338,7 +415,7 @@ func (m *StorageManager) ForEachDeleteStage(ctx context.Context, options ForEach
 func (m *StorageManager) LockStageImage(ctx context.Context, imageName string) error {
 	imageLockName := container_runtime.ImageLockName(imageName)
 
-	_, lock, err := werf.AcquireHostLock(ctx, imageLockName, lockgate.AcquireOptions{Shared: true})
+	_, lock, err := werf.AcquireGlobalHostLock(ctx, imageLockName, lockgate.AcquireOptions{Shared: true})
 	if err != nil {
 		return fmt.Errorf("error locking %q shared lock: %s", imageLockName, err)
 	}
@@ -399,6 +476,26 @@ func copyStageIntoStagesStorage(ctx context.Context, projectName string, stageID
 	return nil
 }
 
+// isStageCopiedInRegistry returns true if the stage is copied between the repos by the registry api,
+// because there is no local docker server to pull and push the stage in the remote builder mode.
+func isStageCopiedInRegistry(sourceStagesStorage, destinationStagesStorage storage.StagesStorage) bool {
+	return remote_builder.IsEnabled() && sourceStagesStorage.Address() != storage.LocalStorageAddress && destinationStagesStorage.Address() != storage.LocalStorageAddress
+}
+
+func copyStageInRegistry(ctx context.Context, projectName string, stageDesc *image.StageDescription, stagesStorage storage.StagesStorage) error {
+	targetStagesStorageImageName := stagesStorage.ConstructStageImageName(projectName, stageDesc.StageID.Digest, stageDesc.StageID.UniqueID)
+
+	if _, err := docker_registry.API().CopyImage(ctx, stageDesc.Info.Name, targetStagesStorageImageName); err != nil {
+		return fmt.Errorf("unable to copy image %s to %s: %s", stageDesc.Info.Name, targetStagesStorageImageName, err)
+	}
+
+	if err := storeStageDescriptionIntoLocalManifestCache(ctx, projectName, *stageDesc.StageID, stagesStorage, convertStageDescriptionForStagesStorage(stageDesc, stagesStorage)); err != nil {
+		return fmt.Errorf("error storing stage %s description into local manifest cache: %s", targetStagesStorageImageName, err)
+	}
+
+	return nil
+}
+
 func (m *StorageManager) FetchStage(ctx context.Context, containerRuntime container_runtime.ContainerRuntime, stg stage.Interface) error {
 	logboek.Context(ctx).Debug().LogF("-- StagesManager.FetchStage %s\n", stg.LogDetailedName())
 
@@ -516,6 +613,7 @@ func (m *StorageManager) FetchStage(ctx context.Context, containerRuntime contai
 		}
 
 		fetchedDockerImage = cacheDockerImage
+		m.setCacheStagesStorageOfFetchedStage(stg, cacheStagesStorage)
 		break
 	}
 
@@ -533,6 +631,10 @@ func (m *StorageManager) FetchStage(ctx context.Context, containerRuntime contai
 			return ErrShouldResetStagesStorageCache
 		}
 
+		if err == storage.ErrBrokenImage && m.readOnly {
+			return fmt.Errorf("stage %s image %q is broken in the %s and cannot be rejected: %s", stg.LogDetailedName(), stg.GetImage().Name(), m.StagesStorage.String(), ErrReadOnly)
+		}
+
 		if err == storage.ErrBrokenImage {
 			logboek.Context(ctx).Error().LogF("Invalid stage %s image %q! Stage image is broken and is no longer available in the %s. Stages storage cache for project %q should be reset!\n", stg.LogDetailedName(), stg.GetImage().Name(), m.StagesStorage.String(), m.ProjectName)
 
@@ -551,6 +653,10 @@ func (m *StorageManager) FetchStage(ctx context.Context, containerRuntime contai
 		fetchedDockerImage = dockerImage
 	}
 
+	if m.readOnly {
+		return nil
+	}
+
 	for _, cacheStagesStorage := range cacheStagesStorageListToRefill {
 		stageID := stg.GetImage().GetStageDescription().StageID
 
@@ -569,13 +675,42 @@ func (m *StorageManager) FetchStage(ctx context.Context, containerRuntime contai
 	return nil
 }
 
+func (m *StorageManager) setCacheStagesStorageOfFetchedStage(stg stage.Interface, cacheStagesStorage storage.StagesStorage) {
+	m.cacheStagesStorageOfFetchedStageMux.Lock()
+	defer m.cacheStagesStorageOfFetchedStageMux.Unlock()
+
+	if m.cacheStagesStorageOfFetchedStage == nil {
+		m.cacheStagesStorageOfFetchedStage = make(map[string]storage.StagesStorage)
+	}
+	m.cacheStagesStorageOfFetchedStage[stg.GetImage().GetStageDescription().StageID.String()] = cacheStagesStorage
+}
+
+// GetCacheStagesStorageOfFetchedStage returns the cache stages storage the stage has been fetched from, nil is returned if the stage has not been fetched from the cache stages storages.
+func (m *StorageManager) GetCacheStagesStorageOfFetchedStage(stg stage.Interface) storage.StagesStorage {
+	m.cacheStagesStorageOfFetchedStageMux.Lock()
+	defer m.cacheStagesStorageOfFetchedStageMux.Unlock()
+
+	return m.cacheStagesStorageOfFetchedStage[stg.GetImage().GetStageDescription().StageID.String()]
+}
+
 func (m *StorageManager) CopyStageIntoCache(ctx context.Context, stg stage.Interface, containerRuntime container_runtime.ContainerRuntime) error {
+	if m.readOnly {
+		return nil
+	}
+
 	for _, cacheStagesStorage := range m.CacheStagesStorageList {
 		stageID := stg.GetImage().GetStageDescription().StageID
 		dockerImage := &container_runtime.DockerImage{Image: stg.GetImage()}
 
 		err := logboek.Context(ctx).Default().LogProcess("Copy stage %s into cache %s", stg.LogDetailedName(), cacheStagesStorage.String()).
 			DoError(func() error {
+				if isStageCopiedInRegistry(m.StagesStorage, cacheStagesStorage) {
+					if err := copyStageInRegistry(ctx, m.ProjectName, stg.GetImage().GetStageDescription(), cacheStagesStorage); err != nil {
+						return fmt.Errorf("unable to copy stage %s into cache stages storage %s: %s", stageID.String(), cacheStagesStorage.String(), err)
+					}
+					return nil
+				}
+
 				if err := copyStageIntoStagesStorage(ctx, m.ProjectName, *stageID, dockerImage, cacheStagesStorage, containerRuntime); err != nil {
 					return fmt.Errorf("unable to copy stage %s into cache stages storage %s: %s", stageID.String(), cacheStagesStorage.String(), err)
 				}
@@ -589,6 +724,148 @@ func (m *StorageManager) CopyStageIntoCache(ctx context.Context, stg stage.Inter
 	return nil
 }
 
+// ImportStageFromImage imports the stages of the existing image built by werf (e.g. the image of the last release)
+// into the local and cache stages storages, so that the stages with the same digests will not be built again.
+// The parent stages are found by the werf-parent-stage-id label in the repository of the image,
+// the import stops at the first stage that already exists in all stages storages.
+func (m *StorageManager) ImportStageFromImage(ctx context.Context, containerRuntime container_runtime.ContainerRuntime, reference string) error {
+	localDockerServerRuntime, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime)
+	if !ok {
+		return fmt.Errorf("importing stages is not supported by the %T container runtime", containerRuntime)
+	}
+
+	repository := getReferenceRepository(reference)
+	for reference != "" {
+		parentStageID, err := m.importStageFromImage(ctx, localDockerServerRuntime, reference)
+		if err != nil {
+			return err
+		}
+
+		if parentStageID == "" {
+			return nil
+		}
+
+		reference = fmt.Sprintf("%s:%s", repository, parentStageID)
+	}
+
+	return nil
+}
+
+// importStageFromImage imports the stage of the image and returns the parent stage id to import next (empty if nothing more to import).
+func (m *StorageManager) importStageFromImage(ctx context.Context, containerRuntime *container_runtime.LocalDockerServerRuntime, reference string) (string, error) {
+	info, err := docker_registry.API().GetRepoImage(ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf("unable to get image %s info: %s", reference, err)
+	}
+
+	if info.Labels[image.WerfLabel] != m.ProjectName {
+		return "", fmt.Errorf("image %s is not built by werf for the project %q", reference, m.ProjectName)
+	}
+
+	digest := info.Labels[image.WerfStageDigestLabel]
+	if digest == "" {
+		return "", fmt.Errorf("image %s has no %s label", reference, image.WerfStageDigestLabel)
+	}
+
+	var stagesStorageList []storage.StagesStorage
+	for _, stagesStorage := range append([]storage.StagesStorage{m.StagesStorage}, m.SecondaryStagesStorageList...) {
+		if stagesStorage.Address() == storage.LocalStorageAddress {
+			stagesStorageList = append(stagesStorageList, stagesStorage)
+		}
+	}
+	if !m.readOnly {
+		stagesStorageList = append(stagesStorageList, m.CacheStagesStorageList...)
+	}
+
+	var stagesStorageListToImport []storage.StagesStorage
+	for _, stagesStorage := range stagesStorageList {
+		stageIDs, err := stagesStorage.GetStagesIDsByDigest(ctx, m.ProjectName, digest)
+		if err != nil {
+			return "", fmt.Errorf("unable to get stages by digest %s from %s: %s", digest, stagesStorage.String(), err)
+		}
+
+		if len(stageIDs) == 0 {
+			stagesStorageListToImport = append(stagesStorageListToImport, stagesStorage)
+		}
+	}
+
+	if len(stagesStorageListToImport) == 0 {
+		logboek.Context(ctx).Default().LogF("Stage %s from %s already exists\n", digest, reference)
+		return "", nil
+	}
+
+	stageID, ok := getStageIDFromReference(reference, digest)
+	if !ok {
+		_, uniqueID := m.GenerateStageUniqueID(digest, nil)
+		stageID = image.StageID{Digest: digest, UniqueID: uniqueID}
+	}
+
+	stageImage := container_runtime.NewStageImage(nil, reference, containerRuntime)
+	dockerImage := &container_runtime.DockerImage{Image: stageImage}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Pulling %s", reference).DoError(func() error {
+		return containerRuntime.PullImageFromRegistry(ctx, stageImage)
+	}); err != nil {
+		return "", fmt.Errorf("unable to pull %s: %s", reference, err)
+	}
+
+	stageImage.SetStageDescription(&image.StageDescription{StageID: &stageID, Info: info})
+
+	for _, stagesStorage := range stagesStorageListToImport {
+		err := logboek.Context(ctx).Default().LogProcess("Import stage %s into %s", stageID.String(), stagesStorage.String()).
+			DoError(func() error {
+				if err := copyStageIntoStagesStorage(ctx, m.ProjectName, stageID, dockerImage, stagesStorage, containerRuntime); err != nil {
+					return fmt.Errorf("unable to import stage %s into stages storage %s: %s", stageID.String(), stagesStorage.String(), err)
+				}
+				return nil
+			})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if !m.readOnly {
+		// stages storage cache may have an empty record for the digest
+		if err := m.StagesStorageCache.DeleteStagesByDigest(ctx, m.ProjectName, digest); err != nil {
+			return "", fmt.Errorf("unable to delete stages by digest %s from stages storage cache: %s", digest, err)
+		}
+	}
+
+	return info.Labels[image.WerfParentStageIDLabel], nil
+}
+
+// getReferenceRepository returns the repository of the image reference without the tag and the digest.
+func getReferenceRepository(reference string) string {
+	if ind := strings.Index(reference, "@"); ind != -1 {
+		return reference[:ind]
+	}
+
+	if ind := strings.LastIndex(reference, ":"); ind != -1 && !strings.Contains(reference[ind:], "/") {
+		return reference[:ind]
+	}
+
+	return reference
+}
+
+// getStageIDFromReference returns the stage id of the stage image tagged in the repo as DIGEST-UNIQUEID.
+func getStageIDFromReference(reference, digest string) (image.StageID, bool) {
+	if strings.Contains(reference, "@") {
+		return image.StageID{}, false
+	}
+
+	_, tag := image.ParseRepositoryAndTag(reference)
+	if !strings.HasPrefix(tag, digest+"-") {
+		return image.StageID{}, false
+	}
+
+	uniqueID, err := image.ParseUniqueIDAsTimestamp(strings.TrimPrefix(tag, digest+"-"))
+	if err != nil {
+		return image.StageID{}, false
+	}
+
+	return image.StageID{Digest: digest, UniqueID: uniqueID}, true
+}
+
 func (m *StorageManager) getOrCreateFinalStagesListCache(ctx context.Context) (*StagesList, error) {
 	m.FinalStagesListCacheMux.Lock()
 	defer m.FinalStagesListCacheMux.Unlock()
@@ -624,10 +901,14 @@ func (m *StorageManager) CopyStageIntoFinalRepo(ctx context.Context, stg stage.I
 			logboek.Context(ctx).Default().LogFHighlight("Use cache final image for %s\n", stg.LogDetailedName())
 			container_runtime.LogImageName(ctx, finalImageName)
 
-			return nil
+			return m.storeFinalStageDescription(ctx, *stageID, true)
 		}
 	}
 
+	if m.readOnly {
+		return fmt.Errorf("unable to copy stage %s into the final repo %s: %s", stageID.String(), m.FinalStagesStorage.String(), ErrReadOnly)
+	}
+
 	if err := m.FetchStage(ctx, containerRuntime, stg); err != nil {
 		return fmt.Errorf("unable to fetch stage %s: %s", stg.LogDetailedName(), err)
 	}
@@ -655,9 +936,42 @@ func (m *StorageManager) CopyStageIntoFinalRepo(ctx context.Context, stg stage.I
 
 	logboek.Context(ctx).Debug().LogF("Updated existing final stages list: %#v\n", m.FinalStagesListCache.StageIDs)
 
+	// the local manifest cache contains the description of the source stage, the repo digest of the pushed image is requested from the final repo
+	return m.storeFinalStageDescription(ctx, *stageID, false)
+}
+
+func (m *StorageManager) storeFinalStageDescription(ctx context.Context, stageID image.StageID, withLocalManifestCache bool) error {
+	stageDesc, err := getStageDescription(ctx, m.ProjectName, stageID, m.FinalStagesStorage, nil, getStageDescriptionOptions{WithLocalManifestCache: withLocalManifestCache})
+	if err != nil {
+		return fmt.Errorf("error getting stage %s description from %s: %s", stageID.String(), m.FinalStagesStorage.String(), err)
+	} else if stageDesc == nil {
+		return fmt.Errorf("stage %s is not found in the final repo %s", stageID.String(), m.FinalStagesStorage.String())
+	}
+
+	if !withLocalManifestCache {
+		if err := storeStageDescriptionIntoLocalManifestCache(ctx, m.ProjectName, stageID, m.FinalStagesStorage, stageDesc); err != nil {
+			return fmt.Errorf("error storing stage %s description into local manifest cache: %s", stageID.String(), err)
+		}
+	}
+
+	m.finalStagesDescriptionsMux.Lock()
+	defer m.finalStagesDescriptionsMux.Unlock()
+
+	if m.finalStagesDescriptions == nil {
+		m.finalStagesDescriptions = make(map[string]*image.StageDescription)
+	}
+	m.finalStagesDescriptions[stageID.String()] = stageDesc
+
 	return nil
 }
 
+func (m *StorageManager) getFinalStageDescription(stageID image.StageID) *image.StageDescription {
+	m.finalStagesDescriptionsMux.Lock()
+	defer m.finalStagesDescriptionsMux.Unlock()
+
+	return m.finalStagesDescriptions[stageID.String()]
+}
+
 func (m *StorageManager) SelectSuitableStage(ctx context.Context, c stage.Conveyor, stg stage.Interface, stages []*image.StageDescription) (*image.StageDescription, error) {
 	if len(stages) == 0 {
 		return nil, nil
@@ -693,6 +1007,10 @@ func (m *StorageManager) SelectSuitableStage(ctx context.Context, c stage.Convey
 }
 
 func (m *StorageManager) AtomicStoreStagesByDigestToCache(ctx context.Context, stageName, stageDigest string, stageIDs []image.StageID) error {
+	if m.readOnly {
+		return nil
+	}
+
 	if lock, err := m.StorageLockManager.LockStageCache(ctx, m.ProjectName, stageDigest); err != nil {
 		return fmt.Errorf("error locking stage %s cache by digest %s: %s", stageName, stageDigest, err)
 	} else {
@@ -709,6 +1027,11 @@ func (m *StorageManager) AtomicStoreStagesByDigestToCache(ctx context.Context, s
 }
 
 func (m *StorageManager) GetStagesByDigest(ctx context.Context, stageName, stageDigest string) ([]*image.StageDescription, error) {
+	// the stages storage cache cannot be refilled in the read-only mode, so it is not used at all to avoid the stale records
+	if m.readOnly {
+		return m.GetStagesByDigestFromStagesStorage(ctx, stageName, stageDigest, m.StagesStorage)
+	}
+
 	cacheExists, cacheStages, err := m.getStagesByDigestFromCache(ctx, stageName, stageDigest)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get %s stages by digest %q from cache: %s", stageName, stageDigest, err)
@@ -739,6 +1062,19 @@ func (m *StorageManager) GetStagesByDigestFromStagesStorage(ctx context.Context,
 }
 
 func (m *StorageManager) CopySuitableByDigestStage(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage, destinationStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) (*image.StageDescription, error) {
+	if m.readOnly {
+		return nil, fmt.Errorf("unable to store %s to %s: %s", stageDesc.Info.Name, destinationStagesStorage.String(), ErrReadOnly)
+	}
+
+	if isStageCopiedInRegistry(sourceStagesStorage, destinationStagesStorage) {
+		logboek.Context(ctx).Info().LogF("Copying %s into %s\n", stageDesc.Info.Name, destinationStagesStorage.String())
+		if err := copyStageInRegistry(ctx, m.ProjectName, stageDesc, destinationStagesStorage); err != nil {
+			return nil, fmt.Errorf("unable to copy %s to %s: %s", stageDesc.Info.Name, destinationStagesStorage.String(), err)
+		}
+
+		return getStageDescription(ctx, m.ProjectName, *stageDesc.StageID, destinationStagesStorage, m.CacheStagesStorageList, getStageDescriptionOptions{AllowStagesStorageCacheReset: true, WithLocalManifestCache: m.getWithLocalManifestCacheOption()})
+	}
+
 	img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime.(*container_runtime.LocalDockerServerRuntime))
 
 	logboek.Context(ctx).Info().LogF("Fetching %s\n", img.Name())
@@ -1028,6 +1364,10 @@ type rmImageMetadataTask struct {
 }
 
 func (m *StorageManager) ForEachRmImageMetadata(ctx context.Context, projectName, imageNameOrID string, stageIDCommitList map[string][]string, f func(ctx context.Context, commit, stageID string, err error) error) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
 	var tasks []rmImageMetadataTask
 	for stageID, commitList := range stageIDCommitList {
 		for _, commit := range commitList {
@@ -1038,9 +1378,7 @@ func (m *StorageManager) ForEachRmImageMetadata(ctx context.Context, projectName
 		}
 	}
 
-	return parallel.DoTasks(ctx, len(tasks), parallel.DoTasksOptions{
-		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
-	}, func(ctx context.Context, taskId int) error {
+	return m.doTasks(ctx, len(tasks), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
 		task := tasks[taskId]
 		err := m.StagesStorage.RmImageMetadata(ctx, projectName, imageNameOrID, task.commit, task.stageID)
 		return f(ctx, task.commit, task.stageID, err)
@@ -1048,19 +1386,31 @@ func (m *StorageManager) ForEachRmImageMetadata(ctx context.Context, projectName
 }
 
 func (m *StorageManager) ForEachRmManagedImage(ctx context.Context, projectName string, managedImages []string, f func(ctx context.Context, managedImage string, err error) error) error {
-	return parallel.DoTasks(ctx, len(managedImages), parallel.DoTasksOptions{
-		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
-	}, func(ctx context.Context, taskId int) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	return m.doTasks(ctx, len(managedImages), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
 		managedImage := managedImages[taskId]
 		err := m.StagesStorage.RmManagedImage(ctx, projectName, managedImage)
 		return f(ctx, managedImage, err)
 	})
 }
 
+func (m *StorageManager) ForEachDeleteRejectedStage(ctx context.Context, projectName string, rejectedStages []*storage.RejectedStageRecord, f func(ctx context.Context, rejectedStage *storage.RejectedStageRecord, err error) error) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	return m.doTasks(ctx, len(rejectedStages), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		rejectedStage := rejectedStages[taskId]
+		err := m.StagesStorage.DeleteRejectedStage(ctx, projectName, rejectedStage)
+		return f(ctx, rejectedStage, err)
+	})
+}
+
 func (m *StorageManager) ForEachGetImportMetadata(ctx context.Context, projectName string, ids []string, f func(ctx context.Context, metadataID string, metadata *storage.ImportMetadata, err error) error) error {
-	return parallel.DoTasks(ctx, len(ids), parallel.DoTasksOptions{
-		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
-	}, func(ctx context.Context, taskId int) error {
+	return m.doTasks(ctx, len(ids), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
 		id := ids[taskId]
 		metadata, err := m.StagesStorage.GetImportMetadata(ctx, projectName, id)
 		return f(ctx, id, metadata, err)
@@ -1068,11 +1418,33 @@ func (m *StorageManager) ForEachGetImportMetadata(ctx context.Context, projectNa
 }
 
 func (m *StorageManager) ForEachRmImportMetadata(ctx context.Context, projectName string, ids []string, f func(ctx context.Context, id string, err error) error) error {
-	return parallel.DoTasks(ctx, len(ids), parallel.DoTasksOptions{
-		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
-	}, func(ctx context.Context, taskId int) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	return m.doTasks(ctx, len(ids), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
 		id := ids[taskId]
 		err := m.StagesStorage.RmImportMetadata(ctx, projectName, id)
 		return f(ctx, id, err)
 	})
 }
+
+func (m *StorageManager) ForEachGetChangedOnlyRecord(ctx context.Context, projectName string, inputsDigests []string, f func(ctx context.Context, inputsDigest string, rec *storage.ChangedOnlyRecord, err error) error) error {
+	return m.doTasks(ctx, len(inputsDigests), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		inputsDigest := inputsDigests[taskId]
+		rec, err := m.StagesStorage.GetChangedOnlyRecord(ctx, projectName, inputsDigest)
+		return f(ctx, inputsDigest, rec, err)
+	})
+}
+
+func (m *StorageManager) ForEachRmChangedOnlyRecord(ctx context.Context, projectName string, inputsDigests []string, f func(ctx context.Context, inputsDigest string, err error) error) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	return m.doTasks(ctx, len(inputsDigests), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		inputsDigest := inputsDigests[taskId]
+		err := m.StagesStorage.RmChangedOnlyRecord(ctx, projectName, inputsDigest)
+		return f(ctx, inputsDigest, err)
+	})
+}
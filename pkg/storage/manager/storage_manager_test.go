@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func TestGetReferenceRepository(t *testing.T) {
+	for reference, expected := range map[string]string{
+		"registry.example.com/app:abc-1611836746968":       "registry.example.com/app",
+		"localhost:5000/app:abc-1611836746968":             "localhost:5000/app",
+		"localhost:5000/app":                               "localhost:5000/app",
+		"registry.example.com/app@sha256:0123456789abcdef": "registry.example.com/app",
+		"app": "app",
+	} {
+		if repository := getReferenceRepository(reference); repository != expected {
+			t.Errorf("%q: expected %q, got %q", reference, expected, repository)
+		}
+	}
+}
+
+func TestGetStageIDFromReference(t *testing.T) {
+	stageID, ok := getStageIDFromReference("localhost:5000/app:abc-1611836746968", "abc")
+	if !ok || stageID != (image.StageID{Digest: "abc", UniqueID: 1611836746968}) {
+		t.Fatalf("unexpected stage id %v (%v)", stageID, ok)
+	}
+
+	for _, reference := range []string{
+		"localhost:5000/app:v1.0",
+		"localhost:5000/app:other-1611836746968",
+		"localhost:5000/app:abc-latest",
+		"localhost:5000/app@sha256:0123456789abcdef",
+	} {
+		if _, ok := getStageIDFromReference(reference, "abc"); ok {
+			t.Errorf("%q: expected no stage id", reference)
+		}
+	}
+}
+
+type testFetchedStage struct {
+	stage.Interface
+
+	image container_runtime.ImageInterface
+}
+
+func (s *testFetchedStage) GetImage() container_runtime.ImageInterface {
+	return s.image
+}
+
+func newTestFetchedStage(digest string) *testFetchedStage {
+	img := container_runtime.NewStageImage(nil, digest, nil)
+	img.SetStageDescription(&image.StageDescription{StageID: &image.StageID{Digest: digest, UniqueID: 1611836746968}})
+
+	return &testFetchedStage{image: img}
+}
+
+func TestStorageManager_CacheStagesStorageOfFetchedStage(t *testing.T) {
+	m := &StorageManager{}
+	cacheStagesStorage := &storage.RepoStagesStorage{RepoAddress: "registry.example.com/cache"}
+
+	fetchedStage := newTestFetchedStage("fetched")
+	if res := m.GetCacheStagesStorageOfFetchedStage(fetchedStage); res != nil {
+		t.Fatalf("expected no cache stages storage before fetching, got %s", res)
+	}
+
+	m.setCacheStagesStorageOfFetchedStage(fetchedStage, cacheStagesStorage)
+
+	// the stage is matched by the stage id
+	if res := m.GetCacheStagesStorageOfFetchedStage(newTestFetchedStage("fetched")); res != cacheStagesStorage {
+		t.Fatalf("expected the cache stages storage of the fetched stage, got %v", res)
+	}
+
+	if res := m.GetCacheStagesStorageOfFetchedStage(newTestFetchedStage("other")); res != nil {
+		t.Fatalf("expected no cache stages storage of the other stage, got %s", res)
+	}
+}
+
+func newTestBuiltStage(digest, repoDigest string) *testFetchedStage {
+	img := container_runtime.NewStageImage(nil, digest, nil)
+	img.SetStageDescription(&image.StageDescription{
+		StageID: &image.StageID{Digest: digest, UniqueID: 1611836746968},
+		Info: &image.Info{
+			Name:       "registry.example.com/app:" + digest + "-1611836746968",
+			Tag:        digest + "-1611836746968",
+			RepoDigest: repoDigest,
+		},
+	})
+
+	return &testFetchedStage{image: img}
+}
+
+func TestStorageManager_GetImageInfoGetter_RepoDigest(t *testing.T) {
+	m := &StorageManager{}
+	info := m.GetImageInfoGetter("app", newTestBuiltStage("abc", "registry.example.com/app@sha256:0123456789abcdef"))
+	if expected := "registry.example.com/app@sha256:0123456789abcdef"; info.GetNameWithDigest() != expected {
+		t.Errorf("expected the repo digest of the stage %q, got %q", expected, info.GetNameWithDigest())
+	}
+
+	m = &StorageManager{FinalStagesStorage: &storage.RepoStagesStorage{RepoAddress: "final.example.com/app"}}
+	info = m.GetImageInfoGetter("app", newTestBuiltStage("abc", "registry.example.com/app@sha256:0123456789abcdef"))
+	if info.GetName() != "final.example.com/app:abc-1611836746968" {
+		t.Errorf("expected the final image name, got %q", info.GetName())
+	}
+	if info.GetNameWithDigest() != "" {
+		t.Errorf("expected no repo digest of the stage not copied into the final repo, got %q", info.GetNameWithDigest())
+	}
+
+	m.finalStagesDescriptions = map[string]*image.StageDescription{
+		(image.StageID{Digest: "abc", UniqueID: 1611836746968}).String(): {
+			StageID: &image.StageID{Digest: "abc", UniqueID: 1611836746968},
+			Info:    &image.Info{RepoDigest: "final.example.com/app@sha256:fedcba9876543210"},
+		},
+	}
+	info = m.GetImageInfoGetter("app", newTestBuiltStage("abc", "registry.example.com/app@sha256:0123456789abcdef"))
+	if expected := "final.example.com/app@sha256:fedcba9876543210"; info.GetNameWithDigest() != expected {
+		t.Errorf("expected the repo digest of the stage copied into the final repo %q, got %q", expected, info.GetNameWithDigest())
+	}
+}
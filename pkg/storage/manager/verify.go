@@ -0,0 +1,198 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+type VerifyStagesOptions struct {
+	// RepairStagesStorageCache resets the stages storage cache records, which do not match the verified stages
+	RepairStagesStorageCache bool
+}
+
+// StageProblem is the problem of the stage found by the stages verification.
+type StageProblem struct {
+	StageID image.StageID
+	Message string
+}
+
+// VerifyStages re-reads the descriptions of all stages bypassing the local manifest cache and checks that the stages are available and not rejected,
+// the required werf labels are present and match the project and the stage digest, the parent stages stored in the stages storage are valid.
+func (m *StorageManager) VerifyStages(ctx context.Context, opts VerifyStagesOptions) ([]StageProblem, error) {
+	stageIDs, err := m.StagesStorage.GetStagesIDs(ctx, m.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting stages ids from %s: %s", m.StagesStorage, err)
+	}
+
+	var mutex sync.Mutex
+	var problems []StageProblem
+	var stages []*image.StageDescription
+
+	if err := m.doTasks(ctx, len(stageIDs), parallel.DoTasksOptions{}, func(ctx context.Context, taskId int) error {
+		stageID := stageIDs[taskId]
+
+		stageDesc, err := m.StagesStorage.GetStageDescription(ctx, m.ProjectName, stageID.Digest, stageID.UniqueID)
+
+		var problem string
+		switch {
+		case storage.BrokenImageErr(err):
+			problem = "stage image is broken: the image config or layer blob is not found"
+		case err != nil:
+			problem = fmt.Sprintf("unable to get stage description: %s", err)
+		case stageDesc == nil:
+			problem = "stage is rejected or its manifest is not found"
+		default:
+			problem = verifyStageLabels(m.ProjectName, stageID, stageDesc.Info.Labels)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if problem != "" {
+			problems = append(problems, StageProblem{StageID: stageID, Message: problem})
+		}
+		if stageDesc != nil {
+			stages = append(stages, stageDesc)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	problems = append(problems, verifyStagesParents(stages, problems)...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].StageID.String() < problems[j].StageID.String()
+	})
+
+	if opts.RepairStagesStorageCache {
+		if err := m.repairStagesStorageCache(ctx, stageIDs, problems); err != nil {
+			return nil, fmt.Errorf("unable to repair stages storage cache: %s", err)
+		}
+	}
+
+	return problems, nil
+}
+
+func verifyStageLabels(projectName string, stageID image.StageID, labels map[string]string) string {
+	for _, label := range []string{image.WerfLabel, image.WerfVersionLabel, image.WerfCacheVersionLabel, image.WerfStageDigestLabel} {
+		if labels[label] == "" {
+			return fmt.Sprintf("required label %s is not found", label)
+		}
+	}
+
+	if labels[image.WerfLabel] != projectName {
+		return fmt.Sprintf("stage belongs to the other project %q", labels[image.WerfLabel])
+	}
+
+	if labels[image.WerfStageDigestLabel] != stageID.Digest {
+		return fmt.Sprintf("stage digest label %s does not match the stage digest", labels[image.WerfStageDigestLabel])
+	}
+
+	return ""
+}
+
+// verifyStagesParents finds the stages based on the invalid stages of the stages storage.
+// The parent stage is defined by the werf-parent-stage-id label, which is set for the stapel stages and kept by the imported stages (werf build --cache-from-images),
+// the empty label means the stage is based on the image not built by werf.
+// The docker parent image id is used only for the stages built before the label was introduced and only if it matches a single stage,
+// because the Dockerfile stages (including the cacheStages ones) and the imported stages may share the image id with the other stages.
+func verifyStagesParents(stages []*image.StageDescription, problems []StageProblem) []StageProblem {
+	invalidStageIDs := map[string]bool{}
+	for _, problem := range problems {
+		invalidStageIDs[problem.StageID.String()] = true
+	}
+
+	stagesByImageID := map[string][]*image.StageDescription{}
+	for _, stageDesc := range stages {
+		if stageDesc.Info.ID != "" {
+			stagesByImageID[stageDesc.Info.ID] = append(stagesByImageID[stageDesc.Info.ID], stageDesc)
+		}
+	}
+
+	var res []StageProblem
+	for _, stageDesc := range stages {
+		if invalidStageIDs[stageDesc.StageID.String()] {
+			continue
+		}
+
+		var parentStageID string
+		if parentStageIDLabel, hasLabel := stageDesc.Info.Labels[image.WerfParentStageIDLabel]; hasLabel {
+			parentStageID = parentStageIDLabel
+		} else if parentStages := stagesByImageID[stageDesc.Info.ParentID]; len(parentStages) == 1 {
+			parentStageID = parentStages[0].StageID.String()
+		}
+
+		// the parent is the base image or the stage stored in the other storage, which is not verified
+		if parentStageID == "" || parentStageID == stageDesc.StageID.String() {
+			continue
+		}
+
+		if invalidStageIDs[parentStageID] {
+			res = append(res, StageProblem{
+				StageID: *stageDesc.StageID,
+				Message: fmt.Sprintf("parent stage %s is invalid", parentStageID),
+			})
+		}
+	}
+
+	return res
+}
+
+// repairStagesStorageCache resets the cache of all stages if it does not match the stages storage and the cached stages by digest of the invalid stages.
+func (m *StorageManager) repairStagesStorageCache(ctx context.Context, stageIDs []image.StageID, problems []StageProblem) error {
+	found, cachedStageIDs, err := m.StagesStorageCache.GetAllStages(ctx, m.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	if found && !isSameStageIDs(stageIDs, cachedStageIDs) {
+		logboek.Context(ctx).Default().LogF("Resetting all stages in %s: cached stages do not match %s\n", m.StagesStorageCache.String(), m.StagesStorage.String())
+		if err := m.StagesStorageCache.DeleteAllStages(ctx, m.ProjectName); err != nil {
+			return err
+		}
+	}
+
+	resetDigests := map[string]bool{}
+	for _, problem := range problems {
+		if resetDigests[problem.StageID.Digest] {
+			continue
+		}
+		resetDigests[problem.StageID.Digest] = true
+
+		logboek.Context(ctx).Default().LogF("Resetting stages by digest %s in %s\n", problem.StageID.Digest, m.StagesStorageCache.String())
+		if err := m.StagesStorageCache.DeleteStagesByDigest(ctx, m.ProjectName, problem.StageID.Digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isSameStageIDs(a, b []image.StageID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := map[string]bool{}
+	for _, stageID := range a {
+		set[stageID.String()] = true
+	}
+
+	for _, stageID := range b {
+		if !set[stageID.String()] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func newTestStageLabels(projectName, digest string) map[string]string {
+	return map[string]string{
+		image.WerfLabel:             projectName,
+		image.WerfVersionLabel:      "v1.2.0",
+		image.WerfCacheVersionLabel: image.BuildCacheVersion,
+		image.WerfStageDigestLabel:  digest,
+	}
+}
+
+func TestVerifyStageLabels(t *testing.T) {
+	stageID := image.StageID{Digest: "abc", UniqueID: 1}
+
+	if problem := verifyStageLabels("project", stageID, newTestStageLabels("project", "abc")); problem != "" {
+		t.Fatalf("unexpected problem: %s", problem)
+	}
+
+	withoutVersion := newTestStageLabels("project", "abc")
+	delete(withoutVersion, image.WerfVersionLabel)
+
+	for name, labels := range map[string]map[string]string{
+		"no labels":     nil,
+		"no version":    withoutVersion,
+		"other project": newTestStageLabels("other", "abc"),
+		"other digest":  newTestStageLabels("project", "def"),
+	} {
+		if problem := verifyStageLabels("project", stageID, labels); problem == "" {
+			t.Errorf("%s: expected problem", name)
+		}
+	}
+}
+
+func newTestStageDescription(digest string, uniqueID int64, imageID, parentImageID string, labels map[string]string) *image.StageDescription {
+	return &image.StageDescription{
+		StageID: &image.StageID{Digest: digest, UniqueID: uniqueID},
+		Info:    &image.Info{ID: imageID, ParentID: parentImageID, Labels: labels},
+	}
+}
+
+func TestVerifyStagesParents(t *testing.T) {
+	invalid := newTestStageDescription("invalid", 1, "sha256:invalid", "", map[string]string{})
+	problems := []StageProblem{{StageID: *invalid.StageID, Message: "stage is broken"}}
+
+	// the imported stage has no docker parent and refers to the parent by the label
+	imported := newTestStageDescription("imported", 2, "sha256:imported", "", map[string]string{image.WerfParentStageIDLabel: invalid.StageID.String()})
+	// the stage built on the base image not built by werf
+	based := newTestStageDescription("based", 3, "sha256:based", "sha256:invalid", map[string]string{image.WerfParentStageIDLabel: ""})
+	// the legacy stage without the label
+	legacy := newTestStageDescription("legacy", 4, "sha256:legacy", "sha256:invalid", map[string]string{})
+	// the Dockerfile stage and the cache stage with the same image id
+	dockerfileCache := newTestStageDescription("cache", 5, "sha256:shared", "", map[string]string{})
+	dockerfile := newTestStageDescription("dockerfile", 6, "sha256:shared", "", map[string]string{})
+	dockerfileChild := newTestStageDescription("child", 7, "sha256:child", "sha256:shared", map[string]string{})
+
+	stages := []*image.StageDescription{invalid, imported, based, legacy, dockerfileCache, dockerfile, dockerfileChild}
+
+	res := verifyStagesParents(stages, problems)
+
+	var stageIDs []string
+	for _, problem := range res {
+		stageIDs = append(stageIDs, problem.StageID.String())
+	}
+
+	if expected := []string{imported.StageID.String(), legacy.StageID.String()}; !reflect.DeepEqual(stageIDs, expected) {
+		t.Fatalf("expected problems of %v, got %v", expected, stageIDs)
+	}
+}
+
+func TestVerifyStagesParents_ValidParents(t *testing.T) {
+	parent := newTestStageDescription("parent", 1, "sha256:parent", "", map[string]string{image.WerfParentStageIDLabel: ""})
+	child := newTestStageDescription("child", 2, "sha256:child", "sha256:parent", map[string]string{image.WerfParentStageIDLabel: parent.StageID.String()})
+	// the parent stage is stored in the other stages storage
+	imported := newTestStageDescription("imported", 3, "sha256:imported", "", map[string]string{image.WerfParentStageIDLabel: "missing-4"})
+
+	if res := verifyStagesParents([]*image.StageDescription{parent, child, imported}, nil); len(res) != 0 {
+		t.Fatalf("unexpected problems: %v", res)
+	}
+}
+
+func TestIsSameStageIDs(t *testing.T) {
+	a := image.StageID{Digest: "a", UniqueID: 1}
+	b := image.StageID{Digest: "b", UniqueID: 2}
+	c := image.StageID{Digest: "a", UniqueID: 3}
+
+	for _, tc := range []struct {
+		x, y     []image.StageID
+		expected bool
+	}{
+		{nil, nil, true},
+		{[]image.StageID{a, b}, []image.StageID{b, a}, true},
+		{[]image.StageID{a, b}, []image.StageID{a}, false},
+		{[]image.StageID{a, b}, []image.StageID{a, c}, false},
+	} {
+		if res := isSameStageIDs(tc.x, tc.y); res != tc.expected {
+			t.Errorf("isSameStageIDs(%v, %v): expected %v, got %v", tc.x, tc.y, tc.expected, res)
+		}
+	}
+}
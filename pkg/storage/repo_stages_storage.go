@@ -31,9 +31,18 @@ const (
 	RepoImportMetadata_ImageTagPrefix  = "import-metadata-"
 	RepoImportMetadata_ImageNameFormat = "%s:import-metadata-%s"
 
+	RepoChangedOnlyRecord_ImageTagPrefix  = "changed-only-"
+	RepoChangedOnlyRecord_ImageNameFormat = "%s:changed-only-%s"
+
 	RepoClientIDRecrod_ImageTagPrefix  = "client-id-"
 	RepoClientIDRecrod_ImageNameFormat = "%s:client-id-%s-%d"
 
+	RepoUsageRecord_ImageTagPrefix  = "usage-"
+	RepoUsageRecord_ImageNameFormat = "%s:usage-%s-%d"
+
+	RepoStageCacheSaltRecord_ImageTagPrefix  = "cache-salt-"
+	RepoStageCacheSaltRecord_ImageNameFormat = "%s:cache-salt-%s-%s-%d"
+
 	UnexpectedTagFormatErrorPrefix = "unexpected tag format"
 )
 
@@ -117,22 +126,28 @@ func (storage *RepoStagesStorage) GetStagesIDs(ctx context.Context, _ string) ([
 	}
 }
 
-func (storage *RepoStagesStorage) ExportStage(ctx context.Context, stageDescription *image.StageDescription, destinationReference string) error {
-	return storage.DockerRegistry.MutateAndPushImage(ctx, stageDescription.Info.Name, destinationReference, mutateExportStageConfig)
+func (storage *RepoStagesStorage) ExportStage(ctx context.Context, stageDescription *image.StageDescription, destinationReference string, extraLabels map[string]string) error {
+	return storage.DockerRegistry.MutateAndPushImage(ctx, stageDescription.Info.Name, destinationReference, getMutateExportStageConfigFunc(extraLabels))
 }
 
-func mutateExportStageConfig(config v1.Config) (v1.Config, error) {
-	if config.Labels == nil {
-		panic("unexpected condition: stage image without labels")
-	}
+func getMutateExportStageConfigFunc(extraLabels map[string]string) func(config v1.Config) (v1.Config, error) {
+	return func(config v1.Config) (v1.Config, error) {
+		if config.Labels == nil {
+			panic("unexpected condition: stage image without labels")
+		}
 
-	for name := range config.Labels {
-		if strings.HasPrefix(name, image.WerfLabelPrefix) {
-			delete(config.Labels, name)
+		for name := range config.Labels {
+			if strings.HasPrefix(name, image.WerfLabelPrefix) {
+				delete(config.Labels, name)
+			}
 		}
-	}
 
-	return config, nil
+		for name, value := range extraLabels {
+			config.Labels[name] = value
+		}
+
+		return config, nil
+	}
 }
 
 func (storage *RepoStagesStorage) DeleteStage(ctx context.Context, stageDescription *image.StageDescription, _ DeleteImageOptions) error {
@@ -182,6 +197,76 @@ func (storage *RepoStagesStorage) RejectStage(ctx context.Context, projectName,
 	return nil
 }
 
+func (storage *RepoStagesStorage) GetRejectedStages(ctx context.Context, projectName string) ([]*RejectedStageRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetRejectedStages %s\n", projectName)
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch tags for repo %q: %s", storage.RepoAddress, err)
+	}
+
+	var res []*RejectedStageRecord
+	for _, tag := range tags {
+		if !strings.HasSuffix(tag, RepoRejectedStageImageRecord_ImageTagSuffix) {
+			continue
+		}
+
+		digest, uniqueID, err := getDigestAndUniqueIDFromRepoStageImageTag(strings.TrimSuffix(tag, RepoRejectedStageImageRecord_ImageTagSuffix))
+		if err != nil {
+			if isUnexpectedTagFormatError(err) {
+				logboek.Context(ctx).Debug().LogLn(err.Error())
+				continue
+			}
+			return nil, fmt.Errorf("unable to get digest and uniqueID from rejected stage tag %q: %s", tag, err)
+		}
+
+		rejectedImageName := makeRepoRejectedStageImageRecord(storage.RepoAddress, digest, uniqueID)
+		rejectedImgInfo, err := storage.DockerRegistry.TryGetRepoImage(ctx, rejectedImageName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get rejected image record %q: %s", rejectedImageName, err)
+		} else if rejectedImgInfo == nil {
+			continue
+		}
+
+		// The repo can be shared by multiple projects
+		if rejectedImgInfo.Labels[image.WerfLabel] != projectName {
+			logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetRejectedStages record %q belongs to other project %q => skipping\n", rejectedImageName, rejectedImgInfo.Labels[image.WerfLabel])
+			continue
+		}
+
+		res = append(res, &RejectedStageRecord{
+			StageID:    image.StageID{Digest: digest, UniqueID: uniqueID},
+			RejectedAt: rejectedImgInfo.GetCreatedAt(),
+		})
+	}
+
+	return res, nil
+}
+
+func (storage *RepoStagesStorage) DeleteRejectedStage(ctx context.Context, projectName string, rec *RejectedStageRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.DeleteRejectedStage %s %s\n", projectName, rec.StageID.String())
+
+	stageImageName := storage.ConstructStageImageName(projectName, rec.StageID.Digest, rec.StageID.UniqueID)
+	if stageImgInfo, err := storage.DockerRegistry.TryGetRepoImage(ctx, stageImageName); err != nil {
+		return fmt.Errorf("unable to get repo image %q: %s", stageImageName, err)
+	} else if stageImgInfo != nil {
+		if err := storage.DockerRegistry.DeleteRepoImage(ctx, stageImgInfo); err != nil {
+			return fmt.Errorf("unable to remove repo image %s: %s", stageImageName, err)
+		}
+	}
+
+	rejectedImageName := makeRepoRejectedStageImageRecord(storage.RepoAddress, rec.StageID.Digest, rec.StageID.UniqueID)
+	if rejectedImgInfo, err := storage.DockerRegistry.TryGetRepoImage(ctx, rejectedImageName); err != nil {
+		return fmt.Errorf("unable to get rejected image record %q: %s", rejectedImageName, err)
+	} else if rejectedImgInfo != nil {
+		if err := storage.DockerRegistry.DeleteRepoImage(ctx, rejectedImgInfo); err != nil {
+			return fmt.Errorf("unable to remove rejected image record %q: %s", rejectedImageName, err)
+		}
+	}
+
+	return nil
+}
+
 func (storage *RepoStagesStorage) CreateRepo(ctx context.Context) error {
 	return storage.DockerRegistry.CreateRepo(ctx, storage.RepoAddress)
 }
@@ -385,6 +470,20 @@ func (storage *RepoStagesStorage) FetchImage(ctx context.Context, img container_
 }
 
 func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image) error {
+	if dockerImage, ok := img.(*container_runtime.DockerImage); ok {
+		if builder := dockerImage.Image.DockerfileImageBuilder(); builder != nil && builder.IsBuiltRemotely() {
+			if remoteStageImageName, _ := builder.GetRemoteStageImage(); remoteStageImageName == dockerImage.Image.Name() {
+				// the image has been pushed by the remote builder with the stage image name
+				return nil
+			}
+
+			// the remote builder has pushed the image into the repo by digest, so the stage is stored by the tagging of the pushed manifest
+			return storage.DockerRegistry.MutateAndPushImage(ctx, builder.GetBuiltId(), dockerImage.Image.Name(), func(config v1.Config) (v1.Config, error) {
+				return config, nil
+			})
+		}
+	}
+
 	switch containerRuntime := storage.ContainerRuntime.(type) {
 	case *container_runtime.LocalDockerServerRuntime:
 		dockerImage := img.(*container_runtime.DockerImage)
@@ -568,6 +667,77 @@ func (storage *RepoStagesStorage) GetImportMetadataIDs(ctx context.Context, _ st
 	return ids, nil
 }
 
+func (storage *RepoStagesStorage) GetChangedOnlyRecord(ctx context.Context, _, inputsDigest string) (*ChangedOnlyRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetChangedOnlyRecord %s\n", inputsDigest)
+
+	fullImageName := makeRepoChangedOnlyRecordName(storage.RepoAddress, inputsDigest)
+	img, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+	} else if img == nil {
+		return nil, nil
+	}
+
+	return newChangedOnlyRecordFromLabels(img.Labels), nil
+}
+
+func (storage *RepoStagesStorage) PutChangedOnlyRecord(ctx context.Context, projectName string, rec *ChangedOnlyRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutChangedOnlyRecord %v\n", rec)
+
+	fullImageName := makeRepoChangedOnlyRecordName(storage.RepoAddress, rec.InputsDigest)
+
+	opts := &docker_registry.PushImageOptions{
+		Labels: rec.ToLabels(),
+	}
+	opts.Labels[image.WerfLabel] = projectName
+
+	if err := storage.DockerRegistry.PushImage(ctx, fullImageName, opts); err != nil {
+		return fmt.Errorf("unable to push image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) RmChangedOnlyRecord(ctx context.Context, _, inputsDigest string) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmChangedOnlyRecord %s\n", inputsDigest)
+
+	fullImageName := makeRepoChangedOnlyRecordName(storage.RepoAddress, inputsDigest)
+	img, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+	if err != nil {
+		return fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+	} else if img == nil {
+		return nil
+	}
+
+	if err := storage.DockerRegistry.DeleteRepoImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to remove repo image %s: %s", img.Tag, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) GetChangedOnlyRecordsInputsDigests(ctx context.Context, _ string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetChangedOnlyRecordsInputsDigests\n")
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var res []string
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, RepoChangedOnlyRecord_ImageTagPrefix) {
+			res = append(res, strings.TrimPrefix(tag, RepoChangedOnlyRecord_ImageTagPrefix))
+		}
+	}
+
+	return res, nil
+}
+
+func makeRepoChangedOnlyRecordName(repoAddress, inputsDigest string) string {
+	return fmt.Sprintf(RepoChangedOnlyRecord_ImageNameFormat, repoAddress, inputsDigest)
+}
+
 func getImportMetadataIDFromRepoTag(tag string) string {
 	return strings.TrimPrefix(tag, RepoImportMetadata_ImageTagPrefix)
 }
@@ -748,3 +918,168 @@ func (storage *RepoStagesStorage) PostClientIDRecord(ctx context.Context, projec
 
 	return nil
 }
+
+func (storage *RepoStagesStorage) GetUsageRecords(ctx context.Context, projectName string) ([]*UsageRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetUsageRecords for project %s\n", projectName)
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var res []*UsageRecord
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoUsageRecord_ImageTagPrefix) {
+			continue
+		}
+
+		rec, err := parseUsageRecordTag(strings.TrimPrefix(tag, RepoUsageRecord_ImageTagPrefix))
+		if err != nil {
+			logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetUsageRecords skip tag %q: %s\n", tag, err)
+			continue
+		}
+		res = append(res, rec)
+
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetUsageRecords got usage record: %s\n", rec)
+	}
+
+	return res, nil
+}
+
+func (storage *RepoStagesStorage) PostUsageRecord(ctx context.Context, projectName string, rec *UsageRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostUsageRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(RepoUsageRecord_ImageNameFormat, storage.RepoAddress, rec.StageID, rec.LastSeenTimestamp)
+
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostUsageRecord full image name: %s\n", fullImageName)
+
+	if isExists, err := storage.DockerRegistry.IsRepoImageExists(ctx, fullImageName); err != nil {
+		return err
+	} else if isExists {
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostUsageRecord record %q is exists => exiting\n", fullImageName)
+		return nil
+	}
+
+	opts := &docker_registry.PushImageOptions{Labels: map[string]string{image.WerfLabel: projectName}}
+
+	if err := storage.DockerRegistry.PushImage(ctx, fullImageName, opts); err != nil {
+		return fmt.Errorf("unable to push image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) RmUsageRecord(ctx context.Context, projectName string, rec *UsageRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmUsageRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(RepoUsageRecord_ImageNameFormat, storage.RepoAddress, rec.StageID, rec.LastSeenTimestamp)
+
+	img, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+	if err != nil {
+		return fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+	} else if img == nil {
+		return nil
+	}
+
+	if err := storage.DockerRegistry.DeleteRepoImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to remove repo image %s: %s", img.Tag, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) GetStageCacheSaltRecords(ctx context.Context, projectName string) ([]*StageCacheSaltRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetStageCacheSaltRecords for project %s\n", projectName)
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var res []*StageCacheSaltRecord
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoStageCacheSaltRecord_ImageTagPrefix) {
+			continue
+		}
+
+		rec, err := parseStageCacheSaltRecordTag(strings.TrimPrefix(tag, RepoStageCacheSaltRecord_ImageTagPrefix))
+		if err != nil {
+			logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetStageCacheSaltRecords skip tag %q: %s\n", tag, err)
+			continue
+		}
+		res = append(res, rec)
+
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetStageCacheSaltRecords got stage cache salt record: %s\n", rec)
+	}
+
+	return res, nil
+}
+
+func (storage *RepoStagesStorage) PostStageCacheSaltRecord(ctx context.Context, projectName string, rec *StageCacheSaltRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostStageCacheSaltRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(RepoStageCacheSaltRecord_ImageNameFormat, storage.RepoAddress, slugImageNameAsDockerImageTag(rec.ImageName), rec.StageName, rec.TimestampMillisec)
+
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostStageCacheSaltRecord full image name: %s\n", fullImageName)
+
+	opts := &docker_registry.PushImageOptions{Labels: map[string]string{image.WerfLabel: projectName}}
+
+	if err := storage.DockerRegistry.PushImage(ctx, fullImageName, opts); err != nil {
+		return fmt.Errorf("unable to push image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) RmStageCacheSaltRecord(ctx context.Context, projectName string, rec *StageCacheSaltRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmStageCacheSaltRecord %s for project %s\n", rec, projectName)
+
+	fullImageName := fmt.Sprintf(RepoStageCacheSaltRecord_ImageNameFormat, storage.RepoAddress, slugImageNameAsDockerImageTag(rec.ImageName), rec.StageName, rec.TimestampMillisec)
+
+	img, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+	if err != nil {
+		return fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+	} else if img == nil {
+		return nil
+	}
+
+	if err := storage.DockerRegistry.DeleteRepoImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to remove repo image %s: %s", img.Tag, err)
+	}
+
+	return nil
+}
+
+// parseStageCacheSaltRecordTag parses the IMAGE_NAME-STAGE_NAME-TIMESTAMP_MILLISEC tag part, where IMAGE_NAME is slugged and can contain dashes.
+func parseStageCacheSaltRecordTag(tag string) (*StageCacheSaltRecord, error) {
+	dataParts := strings.SplitN(util.Reverse(tag), "-", 3)
+	if len(dataParts) != 3 {
+		return nil, fmt.Errorf("%s %s", UnexpectedTagFormatErrorPrefix, tag)
+	}
+
+	imageName, stageName, timestampStr := unslugDockerImageTagAsImageName(util.Reverse(dataParts[2])), util.Reverse(dataParts[1]), util.Reverse(dataParts[0])
+
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: unable to parse timestamp %s: %s", UnexpectedTagFormatErrorPrefix, tag, timestampStr, err)
+	}
+
+	return &StageCacheSaltRecord{ImageName: imageName, StageName: stageName, TimestampMillisec: timestamp}, nil
+}
+
+// parseUsageRecordTag parses the STAGE_ID-TIMESTAMP tag part, where STAGE_ID itself is in the DIGEST-UNIQUE_ID format.
+func parseUsageRecordTag(tag string) (*UsageRecord, error) {
+	dataParts := strings.SplitN(util.Reverse(tag), "-", 2)
+	if len(dataParts) != 2 {
+		return nil, fmt.Errorf("%s %s", UnexpectedTagFormatErrorPrefix, tag)
+	}
+
+	stageID, timestampStr := util.Reverse(dataParts[1]), util.Reverse(dataParts[0])
+
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: unable to parse timestamp %s: %s", UnexpectedTagFormatErrorPrefix, tag, timestampStr, err)
+	}
+
+	return &UsageRecord{StageID: stageID, LastSeenTimestamp: timestamp}, nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestParseStageCacheSaltRecordTag(t *testing.T) {
+	for _, rec := range []*StageCacheSaltRecord{
+		{ImageName: "app", StageName: "install", TimestampMillisec: 1611836746968},
+		{ImageName: "backend-api", StageName: "beforeInstall", TimestampMillisec: 1611836746968},
+		{ImageName: "group/app", StageName: "dockerfile", TimestampMillisec: 1611836746968},
+		{ImageName: "", StageName: "setup", TimestampMillisec: 1611836746968},
+	} {
+		tag := fmt.Sprintf("%s-%s-%d", slugImageNameAsDockerImageTag(rec.ImageName), rec.StageName, rec.TimestampMillisec)
+
+		parsedRec, err := parseStageCacheSaltRecordTag(tag)
+		if err != nil {
+			t.Fatalf("unable to parse tag %q: %s", tag, err)
+		}
+
+		if *parsedRec != *rec {
+			t.Errorf("unexpected record parsed from tag %q: %s", tag, parsedRec)
+		}
+	}
+
+	if _, err := parseStageCacheSaltRecordTag("install-1611836746968"); err == nil {
+		t.Errorf("expected error for tag without image name")
+	}
+
+	if _, err := parseStageCacheSaltRecordTag("app-install-now"); err == nil {
+		t.Errorf("expected error for tag with bad timestamp")
+	}
+}
+
+func TestSplitStageCacheSaltRecords(t *testing.T) {
+	appInstallOld := &StageCacheSaltRecord{ImageName: "app", StageName: "install", TimestampMillisec: 1}
+	appInstallLatest := &StageCacheSaltRecord{ImageName: "app", StageName: "install", TimestampMillisec: 3}
+	appInstallOlder := &StageCacheSaltRecord{ImageName: "app", StageName: "install", TimestampMillisec: 2}
+	appSetup := &StageCacheSaltRecord{ImageName: "app", StageName: "setup", TimestampMillisec: 1}
+	backendInstall := &StageCacheSaltRecord{ImageName: "backend", StageName: "install", TimestampMillisec: 1}
+
+	latest, outdated := SplitStageCacheSaltRecords([]*StageCacheSaltRecord{appInstallOld, appInstallLatest, appSetup, appInstallOlder, backendInstall})
+
+	if expected := []*StageCacheSaltRecord{appInstallLatest, appSetup, backendInstall}; !reflect.DeepEqual(latest, expected) {
+		t.Errorf("unexpected latest records: %v", latest)
+	}
+
+	if expected := []*StageCacheSaltRecord{appInstallOld, appInstallOlder}; !reflect.DeepEqual(outdated, expected) {
+		t.Errorf("unexpected outdated records: %v", outdated)
+	}
+
+	if latest, outdated := SplitStageCacheSaltRecords(nil); len(latest) != 0 || len(outdated) != 0 {
+		t.Errorf("expected no records, got %v and %v", latest, outdated)
+	}
+}
+
+type testDockerRegistry struct {
+	docker_registry.DockerRegistry
+
+	images map[string]*image.Info
+}
+
+func (r *testDockerRegistry) Tags(_ context.Context, reference string) ([]string, error) {
+	var tags []string
+	for name := range r.images {
+		if parts := strings.SplitN(name, ":", 2); parts[0] == reference {
+			tags = append(tags, parts[1])
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (r *testDockerRegistry) TryGetRepoImage(_ context.Context, reference string) (*image.Info, error) {
+	return r.images[reference], nil
+}
+
+func TestGetRejectedStages(t *testing.T) {
+	storage := &RepoStagesStorage{
+		RepoAddress: "registry.example.com/repo",
+		DockerRegistry: &testDockerRegistry{
+			images: map[string]*image.Info{
+				"registry.example.com/repo:digest1-1611836746968-rejected":       {Labels: map[string]string{image.WerfLabel: "project"}},
+				"registry.example.com/repo:digest2-1611836746968-rejected":       {Labels: map[string]string{image.WerfLabel: "other-project"}},
+				"registry.example.com/repo:digest3-1611836746968-rejected":       {Labels: map[string]string{}},
+				"registry.example.com/repo:digest1-1611836746968":                {Labels: map[string]string{image.WerfLabel: "project"}},
+				"registry.example.com/other-repo:digest4-1611836746968-rejected": {Labels: map[string]string{image.WerfLabel: "project"}},
+			},
+		},
+	}
+
+	records, err := storage.GetRejectedStages(context.Background(), "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].StageID != (image.StageID{Digest: "digest1", UniqueID: 1611836746968}) {
+		t.Fatalf("unexpected rejected stages: %v", records)
+	}
+}
+
+func TestMutateExportStageConfig(t *testing.T) {
+	config := v1.Config{Labels: map[string]string{
+		image.WerfLabel:          "project",
+		image.WerfVersionLabel:   "v1.2.0",
+		"org.opencontainers.key": "value",
+	}}
+
+	config, err := getMutateExportStageConfigFunc(map[string]string{"werf.io/built-by": "werf", "app": "project"})(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"org.opencontainers.key": "value", "werf.io/built-by": "werf", "app": "project"}
+	if !reflect.DeepEqual(config.Labels, expected) {
+		t.Fatalf("expected labels %v, got %v", expected, config.Labels)
+	}
+}
+
+func TestParseUsageRecordTag(t *testing.T) {
+	rec, err := parseUsageRecordTag("digest-1611836746968-1632300000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (UsageRecord{StageID: "digest-1611836746968", LastSeenTimestamp: 1632300000}); *rec != expected {
+		t.Fatalf("expected record %s, got %s", &expected, rec)
+	}
+
+	if _, err := parseUsageRecordTag("1632300000"); err == nil {
+		t.Errorf("expected error for tag without stage id")
+	}
+
+	if _, err := parseUsageRecordTag("digest-1611836746968-now"); err == nil {
+		t.Errorf("expected error for tag with bad timestamp")
+	}
+}
+
+func TestGetUsageRecords(t *testing.T) {
+	storage := &RepoStagesStorage{
+		RepoAddress: "registry.example.com/repo",
+		DockerRegistry: &testDockerRegistry{
+			images: map[string]*image.Info{
+				"registry.example.com/repo:usage-digest1-1611836746968-1632300000": {},
+				"registry.example.com/repo:usage-digest2-1611836746968-1632300001": {},
+				"registry.example.com/repo:usage-broken":                           {},
+				"registry.example.com/repo:digest1-1611836746968":                  {},
+				"registry.example.com/other-repo:usage-digest3-1611836746968-1":    {},
+			},
+		},
+	}
+
+	records, err := storage.GetUsageRecords(context.Background(), "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*UsageRecord{
+		{StageID: "digest1-1611836746968", LastSeenTimestamp: 1632300000},
+		{StageID: "digest2-1611836746968", LastSeenTimestamp: 1632300001},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+}
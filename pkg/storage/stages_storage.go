@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
@@ -30,10 +31,14 @@ type StagesStorage interface {
 	GetStagesIDs(ctx context.Context, projectName string) ([]image.StageID, error)
 	GetStagesIDsByDigest(ctx context.Context, projectName, digest string) ([]image.StageID, error)
 	GetStageDescription(ctx context.Context, projectName, digest string, uniqueID int64) (*image.StageDescription, error)
-	ExportStage(ctx context.Context, stageDescription *image.StageDescription, destinationReference string) error
+	// ExportStage pushes the stage image without the werf service labels and with the extra labels
+	ExportStage(ctx context.Context, stageDescription *image.StageDescription, destinationReference string, extraLabels map[string]string) error
 	DeleteStage(ctx context.Context, stageDescription *image.StageDescription, options DeleteImageOptions) error
 
 	RejectStage(ctx context.Context, projectName, digest string, uniqueID int64) error
+	GetRejectedStages(ctx context.Context, projectName string) ([]*RejectedStageRecord, error)
+	// DeleteRejectedStage deletes the rejected stage image if it still exists and the rejection record
+	DeleteRejectedStage(ctx context.Context, projectName string, rec *RejectedStageRecord) error
 
 	ConstructStageImageName(projectName, digest string, uniqueID int64) string
 
@@ -60,13 +65,35 @@ type StagesStorage interface {
 	RmImportMetadata(ctx context.Context, projectName, id string) error
 	GetImportMetadataIDs(ctx context.Context, projectName string) ([]string, error)
 
+	GetChangedOnlyRecord(ctx context.Context, projectName, inputsDigest string) (*ChangedOnlyRecord, error)
+	PutChangedOnlyRecord(ctx context.Context, projectName string, rec *ChangedOnlyRecord) error
+	RmChangedOnlyRecord(ctx context.Context, projectName, inputsDigest string) error
+	GetChangedOnlyRecordsInputsDigests(ctx context.Context, projectName string) ([]string, error)
+
 	GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error)
 	PostClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error
 
+	GetUsageRecords(ctx context.Context, projectName string) ([]*UsageRecord, error)
+	PostUsageRecord(ctx context.Context, projectName string, rec *UsageRecord) error
+	RmUsageRecord(ctx context.Context, projectName string, rec *UsageRecord) error
+
+	GetStageCacheSaltRecords(ctx context.Context, projectName string) ([]*StageCacheSaltRecord, error)
+	PostStageCacheSaltRecord(ctx context.Context, projectName string, rec *StageCacheSaltRecord) error
+	RmStageCacheSaltRecord(ctx context.Context, projectName string, rec *StageCacheSaltRecord) error
+
 	String() string
 	Address() string
 }
 
+type RejectedStageRecord struct {
+	StageID    image.StageID
+	RejectedAt time.Time
+}
+
+func (rec *RejectedStageRecord) String() string {
+	return rec.StageID.String()
+}
+
 type ClientIDRecord struct {
 	ClientID          string
 	TimestampMillisec int64
@@ -76,6 +103,55 @@ func (rec *ClientIDRecord) String() string {
 	return fmt.Sprintf("clientID:%s tsMillisec:%d", rec.ClientID, rec.TimestampMillisec)
 }
 
+// UsageRecord is the last time the stage image has been seen running in the Kubernetes clusters.
+type UsageRecord struct {
+	StageID           string
+	LastSeenTimestamp int64
+}
+
+func (rec *UsageRecord) String() string {
+	return fmt.Sprintf("stageID:%s lastSeenTs:%d", rec.StageID, rec.LastSeenTimestamp)
+}
+
+func (rec *UsageRecord) GetLastSeenAt() time.Time {
+	return time.Unix(rec.LastSeenTimestamp, 0)
+}
+
+// StageCacheSaltRecord is the invalidation of the image stage cache, the latest record of the stage takes part in the stage digest.
+type StageCacheSaltRecord struct {
+	ImageName         string
+	StageName         string
+	TimestampMillisec int64
+}
+
+func (rec *StageCacheSaltRecord) String() string {
+	return fmt.Sprintf("image:%s stage:%s tsMillisec:%d", rec.ImageName, rec.StageName, rec.TimestampMillisec)
+}
+
+// SplitStageCacheSaltRecords splits the records into the latest records of the image stages and the outdated records, which do not take part in the stages digests anymore.
+func SplitStageCacheSaltRecords(records []*StageCacheSaltRecord) ([]*StageCacheSaltRecord, []*StageCacheSaltRecord) {
+	type imageStage struct{ imageName, stageName string }
+
+	latestRecords := map[imageStage]*StageCacheSaltRecord{}
+	for _, rec := range records {
+		key := imageStage{rec.ImageName, rec.StageName}
+		if latest, ok := latestRecords[key]; !ok || rec.TimestampMillisec > latest.TimestampMillisec {
+			latestRecords[key] = rec
+		}
+	}
+
+	var latest, outdated []*StageCacheSaltRecord
+	for _, rec := range records {
+		if latestRecords[imageStage{rec.ImageName, rec.StageName}] == rec {
+			latest = append(latest, rec)
+		} else {
+			outdated = append(outdated, rec)
+		}
+	}
+
+	return latest, outdated
+}
+
 type ImageMetadata struct {
 	ContentDigest string
 }
@@ -9,13 +9,16 @@ import (
 	"github.com/werf/werf/pkg/storage"
 )
 
-func GetOrCreateClientID(ctx context.Context, projectName string, synchronizationClient *SynchronizationClient, stagesStorage storage.StagesStorage) (string, error) {
+// GetOrCreateClientID returns the oldest client id stored in the stages storage, the new client id is posted into the stages storage if there is none and readOnly is not set.
+func GetOrCreateClientID(ctx context.Context, projectName string, synchronizationClient *SynchronizationClient, stagesStorage storage.StagesStorage, readOnly bool) (string, error) {
 	if clientIDRecords, err := stagesStorage.GetClientIDRecords(ctx, projectName); err != nil {
 		return "", err
 	} else if len(clientIDRecords) > 0 {
 		res := selectOldestClientIDRecord(clientIDRecords)
 		logboek.Context(ctx).Debug().LogF("GetOrCreateClientID %s selected clientID: %s\n", projectName, res.String())
 		return res.ClientID, nil
+	} else if readOnly {
+		return "", fmt.Errorf("client id record is not found in the read-only storage %s: use the other synchronization (e.g. --synchronization=:local) or run werf without the read-only mode once to create the record", stagesStorage.String())
 	} else {
 		newClientID, err := synchronizationClient.NewClientID()
 		if err != nil {
@@ -0,0 +1,50 @@
+package synchronization_server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CredentialsProvider authenticates werf requests to the http synchronization server,
+// e.g. when the server is behind the identity-aware proxy.
+type CredentialsProvider interface {
+	// SetupTransport is called once on the http client creation
+	SetupTransport(transport *http.Transport) error
+	// SetupRequest is called for each request to the server
+	SetupRequest(req *http.Request) error
+}
+
+// NewHttpClient creates the http client for the synchronization server requests authenticated by the credentials providers.
+func NewHttpClient(providers ...CredentialsProvider) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	for _, provider := range providers {
+		if err := provider.SetupTransport(transport); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(providers) == 0 {
+		return &http.Client{Transport: transport}, nil
+	}
+
+	return &http.Client{Transport: &credentialsRoundTripper{transport: transport, providers: providers}}, nil
+}
+
+type credentialsRoundTripper struct {
+	transport http.RoundTripper
+	providers []CredentialsProvider
+}
+
+func (rt *credentialsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the round tripper must not modify the original request
+	req = req.Clone(req.Context())
+
+	for _, provider := range rt.providers {
+		if err := provider.SetupRequest(req); err != nil {
+			return nil, fmt.Errorf("unable to set up synchronization server credentials: %s", err)
+		}
+	}
+
+	return rt.transport.RoundTrip(req)
+}
@@ -0,0 +1,122 @@
+package synchronization_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// OIDCCredentialsProvider authenticates werf by the bearer token, which is obtained from the OIDC issuer with the client credentials flow.
+// The token is cached and refreshed automatically before the expiration.
+type OIDCCredentialsProvider struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// ProjectName is passed to the synchronization server with the enabled authentication
+	ProjectName string
+
+	ctx         context.Context
+	tokenSource oauth2.TokenSource
+	mutex       sync.Mutex
+}
+
+func NewOIDCCredentialsProvider(ctx context.Context, issuerURL, clientID, clientSecret string, scopes []string, projectName string) *OIDCCredentialsProvider {
+	return &OIDCCredentialsProvider{
+		IssuerURL:    issuerURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		ProjectName:  projectName,
+		ctx:          ctx,
+	}
+}
+
+func (provider *OIDCCredentialsProvider) SetupTransport(_ *http.Transport) error {
+	return nil
+}
+
+func (provider *OIDCCredentialsProvider) SetupRequest(req *http.Request) error {
+	tokenSource, err := provider.getTokenSource()
+	if err != nil {
+		return err
+	}
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("unable to get OIDC token from %q: %s", provider.IssuerURL, err)
+	}
+
+	token.SetAuthHeader(req)
+	req.Header.Set(ProjectNameHeader, provider.ProjectName)
+
+	return nil
+}
+
+func (provider *OIDCCredentialsProvider) getTokenSource() (oauth2.TokenSource, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.tokenSource != nil {
+		return provider.tokenSource, nil
+	}
+
+	tokenURL, err := discoverOIDCTokenURL(provider.ctx, provider.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &clientcredentials.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		TokenURL:     tokenURL,
+		Scopes:       provider.Scopes,
+	}
+
+	// the token source reuses the token until it expires
+	provider.tokenSource = config.TokenSource(context.WithValue(provider.ctx, oauth2.HTTPClient, oidcHttpClient))
+
+	return provider.tokenSource, nil
+}
+
+func discoverOIDCTokenURL(ctx context.Context, issuerURL string) (string, error) {
+	url := fmt.Sprintf("%s/.well-known/openid-configuration", strings.TrimSuffix(issuerURL, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create GET request for %q: %s", url, err)
+	}
+
+	resp, err := oidcHttpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting url %q: %s", url, err)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response of %q request: %s", url, err)
+	} else if resp.StatusCode != 200 {
+		return "", fmt.Errorf("got bad response %s by url %q request:\n%s", resp.Status, url, body)
+	}
+
+	var discovery struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := json.Unmarshal(body, &discovery); err != nil {
+		return "", fmt.Errorf("unable to unmarshal json body by url %q request: %s", url, err)
+	}
+
+	if discovery.TokenEndpoint == "" {
+		return "", fmt.Errorf("token_endpoint not found in the OIDC discovery document %q", url)
+	}
+
+	return discovery.TokenEndpoint, nil
+}
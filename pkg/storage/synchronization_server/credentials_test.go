@@ -0,0 +1,349 @@
+package synchronization_server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHttpClient_TokenCredentials(t *testing.T) {
+	handler := NewSynchronizationServerHandler(nil, nil)
+	handler.Auth = testAuthConfig
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		token, projectName string
+		expectedStatus     int
+	}{
+		{"secret-a", "team-a-app", http.StatusOK},
+		{"secret-a", "team-b-app", http.StatusForbidden},
+		{"wrong", "team-a-app", http.StatusUnauthorized},
+	} {
+		client, err := NewHttpClient(NewTokenCredentialsProvider(tc.token, tc.projectName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("POST", server.URL+"/new-client-id", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.expectedStatus {
+			t.Errorf("token %q project %q: expected status %d, got %d", tc.token, tc.projectName, tc.expectedStatus, resp.StatusCode)
+		}
+		if req.Header.Get("Authorization") != "" || req.Header.Get(ProjectNameHeader) != "" {
+			t.Errorf("token %q project %q: the original request should not be modified, got headers %v", tc.token, tc.projectName, req.Header)
+		}
+	}
+}
+
+func TestNewHttpClient_WithoutCredentials(t *testing.T) {
+	handler := NewSynchronizationServerHandler(nil, nil)
+	handler.Auth = testAuthConfig
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := NewHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.Transport.(*http.Transport); !ok {
+		t.Errorf("expected the plain transport without the credentials providers, got %T", client.Transport)
+	}
+
+	resp, err := client.Post(server.URL+"/new-client-id", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the unauthenticated request to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+type failingCredentialsProvider struct {
+	transportErr, requestErr error
+}
+
+func (provider *failingCredentialsProvider) SetupTransport(_ *http.Transport) error {
+	return provider.transportErr
+}
+
+func (provider *failingCredentialsProvider) SetupRequest(_ *http.Request) error {
+	return provider.requestErr
+}
+
+func TestNewHttpClient_CredentialsErrors(t *testing.T) {
+	if _, err := NewHttpClient(&failingCredentialsProvider{transportErr: fmt.Errorf("bad transport")}); err == nil || err.Error() != "bad transport" {
+		t.Errorf("expected the transport setup error, got %v", err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	client, err := NewHttpClient(NewTokenCredentialsProvider("secret-a", "team-a-app"), &failingCredentialsProvider{requestErr: fmt.Errorf("bad request")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "unable to set up synchronization server credentials: bad request") {
+		t.Errorf("expected the request setup error, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests to the server without the credentials, got %d", requests)
+	}
+}
+
+func TestOIDCCredentialsProvider_TokenCached(t *testing.T) {
+	issuer := newTestOIDCIssuer(t)
+
+	var discoveryRequests, tokenRequests int
+	var tokenClientID, tokenScope string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			discoveryRequests++
+			_, _ = fmt.Fprintf(w, `{"token_endpoint":"http://%s/token"}`, r.Host)
+		case "/token":
+			tokenRequests++
+			tokenClientID, _, _ = r.BasicAuth()
+			tokenScope = r.FormValue("scope")
+			issuer.Config.Handler.ServeHTTP(w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer proxy.Close()
+
+	provider := NewOIDCCredentialsProvider(context.Background(), proxy.URL+"/", "team-b-ci", "secret", []string{"openid", "werf"}, "team-b-app")
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/new-client-id", nil)
+		if err := provider.SetupRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get(ProjectNameHeader) != "team-b-app" {
+			t.Errorf("unexpected project name header %q", req.Header.Get(ProjectNameHeader))
+		}
+		tokens = append(tokens, req.Header.Get("Authorization"))
+	}
+
+	if !strings.HasPrefix(tokens[0], "Bearer ") || tokens[0] != tokens[1] {
+		t.Errorf("expected the same cached bearer token, got %q and %q", tokens[0], tokens[1])
+	}
+	if discoveryRequests != 1 || tokenRequests != 1 {
+		t.Errorf("expected the token to be discovered and fetched once, got %d discovery and %d token requests", discoveryRequests, tokenRequests)
+	}
+	if tokenClientID != "team-b-ci" || tokenScope != "openid werf" {
+		t.Errorf("unexpected token request client id %q and scope %q", tokenClientID, tokenScope)
+	}
+}
+
+func TestOIDCCredentialsProvider_Errors(t *testing.T) {
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-token-endpoint/.well-known/openid-configuration":
+			_, _ = fmt.Fprint(w, `{"issuer":"no-token-endpoint"}`)
+		case "/invalid/.well-known/openid-configuration":
+			_, _ = fmt.Fprint(w, `not json`)
+		case "/token-error/.well-known/openid-configuration":
+			_, _ = fmt.Fprintf(w, `{"token_endpoint":"http://%s/token-error/token"}`, r.Host)
+		case "/token-error/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer issuer.Close()
+
+	for _, tc := range []struct {
+		path, expectedErr string
+	}{
+		{"/not-found", "got bad response 404 Not Found"},
+		{"/no-token-endpoint", "token_endpoint not found in the OIDC discovery document"},
+		{"/invalid", "unable to unmarshal json body"},
+		{"/token-error", "unable to get OIDC token from"},
+	} {
+		provider := NewOIDCCredentialsProvider(context.Background(), issuer.URL+tc.path, "team-b-ci", "secret", nil, "team-b-app")
+
+		if err := provider.SetupRequest(httptest.NewRequest("POST", "/new-client-id", nil)); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%s: expected error %q, got %v", tc.path, tc.expectedErr, err)
+		}
+	}
+}
+
+type testCertificate struct {
+	cert     *x509.Certificate
+	key      *rsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCertificate creates the certificate signed by the parent certificate or the self-signed CA certificate when the parent is nil.
+func newTestCertificate(t *testing.T, name string, parent *testCertificate) *testCertificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signerCert, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certificate := &testCertificate{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+
+	if err := ioutil.WriteFile(certificate.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certificate.keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate
+}
+
+func TestTLSCredentialsProvider_MutualTLS(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	serverCert := newTestCertificate(t, "server", ca)
+	clientCert := newTestCertificate(t, "client", ca)
+	otherCA := newTestCertificate(t, "other-ca", nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.cert.Raw}, PrivateKey: serverCert.key}},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name                   string
+		provider               *TLSCredentialsProvider
+		expectedRequestErr     bool
+		expectedPeerCommonName string
+	}{
+		{"client certificate and CA", NewTLSCredentialsProvider(clientCert.certFile, clientCert.keyFile, ca.certFile), false, "client"},
+		{"no client certificate", NewTLSCredentialsProvider("", "", ca.certFile), true, ""},
+		{"other CA", NewTLSCredentialsProvider(clientCert.certFile, clientCert.keyFile, otherCA.certFile), true, ""},
+	} {
+		client, err := NewHttpClient(tc.provider)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if tc.expectedRequestErr {
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("%s: expected the request error", tc.name)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(body) != tc.expectedPeerCommonName {
+			t.Errorf("%s: expected the client certificate %q, got %q", tc.name, tc.expectedPeerCommonName, body)
+		}
+	}
+}
+
+func TestTLSCredentialsProvider_Errors(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	clientCert := newTestCertificate(t, "client", ca)
+
+	invalidFile := filepath.Join(t.TempDir(), "invalid.pem")
+	if err := ioutil.WriteFile(invalidFile, []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missingFile := filepath.Join(t.TempDir(), "missing.pem")
+
+	for _, tc := range []struct {
+		name        string
+		provider    *TLSCredentialsProvider
+		expectedErr string
+	}{
+		{"cert without key", NewTLSCredentialsProvider(clientCert.certFile, "", ""), "both client certificate and key should be specified"},
+		{"key without cert", NewTLSCredentialsProvider("", clientCert.keyFile, ""), "both client certificate and key should be specified"},
+		{"invalid key", NewTLSCredentialsProvider(clientCert.certFile, invalidFile, ""), "unable to load client certificate"},
+		{"mismatched key", NewTLSCredentialsProvider(clientCert.certFile, ca.keyFile, ""), "unable to load client certificate"},
+		{"missing CA", NewTLSCredentialsProvider("", "", missingFile), "unable to read CA certificate"},
+		{"invalid CA", NewTLSCredentialsProvider("", "", invalidFile), "no valid certificates found"},
+	} {
+		if _, err := NewHttpClient(tc.provider); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%s: expected error %q, got %v", tc.name, tc.expectedErr, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package synchronization_server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSCredentialsProvider authenticates werf by the client certificate (mTLS) and verifies the server certificate by the custom CA.
+type TLSCredentialsProvider struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+func NewTLSCredentialsProvider(certFile, keyFile, caFile string) *TLSCredentialsProvider {
+	return &TLSCredentialsProvider{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}
+}
+
+func (provider *TLSCredentialsProvider) SetupTransport(transport *http.Transport) error {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+
+	if provider.CertFile != "" || provider.KeyFile != "" {
+		if provider.CertFile == "" || provider.KeyFile == "" {
+			return fmt.Errorf("both client certificate and key should be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(provider.CertFile, provider.KeyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load client certificate %q and key %q: %s", provider.CertFile, provider.KeyFile, err)
+		}
+
+		transport.TLSClientConfig.Certificates = append(transport.TLSClientConfig.Certificates, cert)
+	}
+
+	if provider.CAFile != "" {
+		data, err := ioutil.ReadFile(provider.CAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read CA certificate %q: %s", provider.CAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no valid certificates found in %q", provider.CAFile)
+		}
+
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	return nil
+}
+
+func (provider *TLSCredentialsProvider) SetupRequest(_ *http.Request) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package synchronization_server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ProjectNameHeader identifies the project on the synchronization server with the enabled authentication.
+// The server isolates the data of each project and checks that the token is allowed to access the project.
+const ProjectNameHeader = "X-Werf-Project-Name"
+
+// TokenCredentialsProvider authenticates werf by the static bearer token issued by the synchronization server administrator.
+type TokenCredentialsProvider struct {
+	Token       string
+	ProjectName string
+}
+
+func NewTokenCredentialsProvider(token, projectName string) *TokenCredentialsProvider {
+	return &TokenCredentialsProvider{Token: token, ProjectName: projectName}
+}
+
+func (provider *TokenCredentialsProvider) SetupTransport(_ *http.Transport) error {
+	return nil
+}
+
+func (provider *TokenCredentialsProvider) SetupRequest(req *http.Request) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", provider.Token))
+	req.Header.Set(ProjectNameHeader, provider.ProjectName)
+	return nil
+}
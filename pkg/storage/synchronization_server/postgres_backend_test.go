@@ -0,0 +1,102 @@
+package synchronization_server
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// newTestPostgresBackend connects to the database specified by the WERF_TEST_POSTGRES_DSN env var,
+// the test data is isolated by the random client id.
+func newTestPostgresBackend(t *testing.T) (*PostgresBackend, string) {
+	dsn := os.Getenv("WERF_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("WERF_TEST_POSTGRES_DSN is not set")
+	}
+
+	backend, err := NewPostgresBackend(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { backend.DB.Close() })
+
+	return backend, uuid.New().String()
+}
+
+func countPostgresRows(t *testing.T, backend *PostgresBackend, table, clientID string) int {
+	var count int
+	if err := backend.DB.QueryRow(`SELECT count(*) FROM `+table+` WHERE client_id = $1`, clientID).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestPostgresDistributedLockerBackend(t *testing.T) {
+	backend, clientID := newTestPostgresBackend(t)
+	locker := backend.NewDistributedLockerBackend("", clientID)
+
+	handle, err := locker.Acquire("lock", distributed_locker.AcquireOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := locker.Acquire("lock", distributed_locker.AcquireOptions{}); err != distributed_locker.ErrShouldWait {
+		t.Fatalf("expected the second acquire to wait, got: %v", err)
+	}
+
+	if err := locker.RenewLease(handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := locker.Release(handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countPostgresRows(t, backend, "werf_synchronization_locks", clientID); count != 0 {
+		t.Fatalf("expected the released lock row to be deleted, got %d rows", count)
+	}
+
+	if err := locker.Release(handle); err != distributed_locker.ErrNoExistingLockLeaseFound {
+		t.Fatalf("expected no lease error, got: %v", err)
+	}
+}
+
+func TestPostgresBackend_DeleteExpiredRecords(t *testing.T) {
+	backend, clientID := newTestPostgresBackend(t)
+	ctx := context.Background()
+
+	if _, err := backend.NewDistributedLockerBackend("", clientID).Acquire("lock", distributed_locker.AcquireOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := backend.NewStagesStorageCache("", clientID)
+	stages := []image.StageID{{Digest: "digest", UniqueID: 1}}
+	if err := cache.StoreStagesByDigest(ctx, "project", "digest", stages); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, res, err := cache.GetStagesByDigest(ctx, "project", "digest"); err != nil || !found || !reflect.DeepEqual(res, stages) {
+		t.Fatalf("expected stages %v, got %v (found=%v err=%v)", stages, res, found, err)
+	}
+
+	if err := backend.DeleteExpiredRecords(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if countPostgresRows(t, backend, "werf_synchronization_locks", clientID) != 1 || countPostgresRows(t, backend, "werf_synchronization_stages_storage_cache", clientID) != 1 {
+		t.Fatal("expected the active records to be kept")
+	}
+
+	if err := backend.DeleteExpiredRecords(ctx, time.Now().Add(backend.StagesStorageCacheTTL+time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if countPostgresRows(t, backend, "werf_synchronization_locks", clientID) != 0 || countPostgresRows(t, backend, "werf_synchronization_stages_storage_cache", clientID) != 0 {
+		t.Fatal("expected the expired records to be deleted")
+	}
+}
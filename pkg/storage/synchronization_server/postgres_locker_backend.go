@@ -0,0 +1,148 @@
+package synchronization_server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+)
+
+// PostgresDistributedLockerBackend implements the lockgate distributed locker backend with the same lease semantics as the kubernetes backend,
+// concurrent changes of the lock lease are serialized by the row lock in the transaction.
+type PostgresDistributedLockerBackend struct {
+	DB        *sql.DB
+	Namespace string
+	ClientID  string
+
+	ctx context.Context
+}
+
+func (backend *PostgresDistributedLockerBackend) Acquire(lockName string, opts distributed_locker.AcquireOptions) (lockgate.LockHandle, error) {
+	var handle lockgate.LockHandle
+
+	err := backend.changeLease(lockName, func(lease *distributed_locker.LockLeaseRecord) (*distributed_locker.LockLeaseRecord, error) {
+		newLease, err := acquireLease(lease, lockName, opts, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		handle = newLease.LockHandle
+		return newLease, nil
+	})
+
+	return handle, err
+}
+
+func (backend *PostgresDistributedLockerBackend) RenewLease(handle lockgate.LockHandle) error {
+	return backend.changeLease(handle.LockName, func(lease *distributed_locker.LockLeaseRecord) (*distributed_locker.LockLeaseRecord, error) {
+		return renewLease(lease, handle, time.Now())
+	})
+}
+
+func (backend *PostgresDistributedLockerBackend) Release(handle lockgate.LockHandle) error {
+	return backend.changeLease(handle.LockName, func(lease *distributed_locker.LockLeaseRecord) (*distributed_locker.LockLeaseRecord, error) {
+		return releaseLease(lease, handle)
+	})
+}
+
+func acquireLease(lease *distributed_locker.LockLeaseRecord, lockName string, opts distributed_locker.AcquireOptions, now time.Time) (*distributed_locker.LockLeaseRecord, error) {
+	switch {
+	case lease == nil || now.After(time.Unix(lease.ExpireAtTimestamp, 0)):
+		lease = distributed_locker.NewLockLeaseRecord(lockName, opts.Shared)
+		lease.ExpireAtTimestamp = now.Unix() + distributed_locker.DistributedLockLeaseTTLSeconds
+	case opts.Shared && lease.IsShared:
+		lease.SharedHoldersCount++
+		lease.ExpireAtTimestamp = now.Unix() + distributed_locker.DistributedLockLeaseTTLSeconds
+	default:
+		return nil, distributed_locker.ErrShouldWait
+	}
+
+	return lease, nil
+}
+
+func renewLease(lease *distributed_locker.LockLeaseRecord, handle lockgate.LockHandle, now time.Time) (*distributed_locker.LockLeaseRecord, error) {
+	if err := checkLeaseHandle(lease, handle); err != nil {
+		return nil, err
+	}
+
+	lease.ExpireAtTimestamp = now.Unix() + distributed_locker.DistributedLockLeaseTTLSeconds
+	return lease, nil
+}
+
+// releaseLease returns nil lease when the last holder releases the lock.
+func releaseLease(lease *distributed_locker.LockLeaseRecord, handle lockgate.LockHandle) (*distributed_locker.LockLeaseRecord, error) {
+	if err := checkLeaseHandle(lease, handle); err != nil {
+		return nil, err
+	}
+
+	lease.SharedHoldersCount--
+	if lease.SharedHoldersCount == 0 {
+		return nil, nil
+	}
+	return lease, nil
+}
+
+func checkLeaseHandle(lease *distributed_locker.LockLeaseRecord, handle lockgate.LockHandle) error {
+	if lease == nil {
+		return distributed_locker.ErrNoExistingLockLeaseFound
+	} else if lease.UUID != handle.UUID {
+		return distributed_locker.ErrLockAlreadyLeased
+	}
+	return nil
+}
+
+// changeLease locks the lease row and saves the lease returned by the changeFunc, nil lease deletes the row.
+func (backend *PostgresDistributedLockerBackend) changeLease(lockName string, changeFunc func(lease *distributed_locker.LockLeaseRecord) (*distributed_locker.LockLeaseRecord, error)) error {
+	ctx := backend.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, PostgresQueryTimeout)
+	defer cancel()
+
+	return withPostgresTx(ctx, backend.DB, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO werf_synchronization_locks (namespace, client_id, lock_name) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, backend.Namespace, backend.ClientID, lockName); err != nil {
+			return fmt.Errorf("unable to insert lock %q: %s", lockName, err)
+		}
+
+		var data string
+		if err := tx.QueryRowContext(ctx, `SELECT lease FROM werf_synchronization_locks WHERE namespace = $1 AND client_id = $2 AND lock_name = $3 FOR UPDATE`, backend.Namespace, backend.ClientID, lockName).Scan(&data); err != nil {
+			return fmt.Errorf("unable to select lock %q: %s", lockName, err)
+		}
+
+		var lease *distributed_locker.LockLeaseRecord
+		if data != "" {
+			if err := json.Unmarshal([]byte(data), &lease); err != nil {
+				return fmt.Errorf("unable to unmarshal lock %q lease: %s", lockName, err)
+			}
+		}
+
+		newLease, err := changeFunc(lease)
+		if err != nil {
+			return err
+		}
+
+		if newLease == nil {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM werf_synchronization_locks WHERE namespace = $1 AND client_id = $2 AND lock_name = $3`, backend.Namespace, backend.ClientID, lockName); err != nil {
+				return fmt.Errorf("unable to delete lock %q: %s", lockName, err)
+			}
+			return nil
+		}
+
+		newData, err := json.Marshal(newLease)
+		if err != nil {
+			return fmt.Errorf("unable to marshal lock %q lease: %s", lockName, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE werf_synchronization_locks SET lease = $4, expire_at = $5 WHERE namespace = $1 AND client_id = $2 AND lock_name = $3`, backend.Namespace, backend.ClientID, lockName, string(newData), newLease.ExpireAtTimestamp); err != nil {
+			return fmt.Errorf("unable to update lock %q: %s", lockName, err)
+		}
+
+		return nil
+	})
+}
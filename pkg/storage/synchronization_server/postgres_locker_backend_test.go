@@ -0,0 +1,62 @@
+package synchronization_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+)
+
+func TestPostgresLease(t *testing.T) {
+	now := time.Now()
+
+	lease, err := acquireLease(nil, "lock", distributed_locker.AcquireOptions{Shared: true}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := lease.LockHandle
+
+	if _, err := acquireLease(lease, "lock", distributed_locker.AcquireOptions{}, now); err != distributed_locker.ErrShouldWait {
+		t.Fatalf("expected exclusive acquire to wait for the shared lease, got: %v", err)
+	}
+
+	if lease, err = acquireLease(lease, "lock", distributed_locker.AcquireOptions{Shared: true}, now); err != nil || lease.SharedHoldersCount != 2 {
+		t.Fatalf("expected the second shared holder, got %#v: %v", lease, err)
+	}
+
+	if lease, err = renewLease(lease, handle, now.Add(time.Minute)); err != nil || lease.ExpireAtTimestamp != now.Add(time.Minute).Unix()+distributed_locker.DistributedLockLeaseTTLSeconds {
+		t.Fatalf("expected renewed lease, got %#v: %v", lease, err)
+	}
+
+	if lease, err = releaseLease(lease, handle); err != nil || lease == nil {
+		t.Fatalf("expected the lease to be kept for the second holder, got %#v: %v", lease, err)
+	}
+
+	if lease, err = releaseLease(lease, handle); err != nil || lease != nil {
+		t.Fatalf("expected the lease to be deleted on the last release, got %#v: %v", lease, err)
+	}
+
+	if _, err := releaseLease(nil, handle); err != distributed_locker.ErrNoExistingLockLeaseFound {
+		t.Fatalf("expected no lease error, got: %v", err)
+	}
+}
+
+func TestPostgresLease_Expired(t *testing.T) {
+	now := time.Now()
+
+	lease, err := acquireLease(nil, "lock", distributed_locker.AcquireOptions{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := lease.LockHandle
+
+	expiredAt := now.Add(time.Duration(distributed_locker.DistributedLockLeaseTTLSeconds+1) * time.Second)
+	newLease, err := acquireLease(lease, "lock", distributed_locker.AcquireOptions{}, expiredAt)
+	if err != nil {
+		t.Fatalf("expected the expired lease to be taken over, got: %v", err)
+	}
+
+	if _, err := renewLease(newLease, handle, expiredAt); err != distributed_locker.ErrLockAlreadyLeased {
+		t.Fatalf("expected the old holder renew to fail, got: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package synchronization_server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// PostgresStagesStorageCache records are deleted by the PostgresBackend garbage collection when not stored for the TTL.
+type PostgresStagesStorageCache struct {
+	DB        *sql.DB
+	Namespace string
+	ClientID  string
+}
+
+func (cache *PostgresStagesStorageCache) String() string {
+	return fmt.Sprintf("postgres client %s", cache.ClientID)
+}
+
+func (cache *PostgresStagesStorageCache) GetAllStages(ctx context.Context, projectName string) (bool, []image.StageID, error) {
+	rows, err := cache.DB.QueryContext(ctx, `SELECT stages FROM werf_synchronization_stages_storage_cache WHERE namespace = $1 AND client_id = $2 AND project_name = $3`, cache.Namespace, cache.ClientID, projectName)
+	if err != nil {
+		return false, nil, fmt.Errorf("unable to select stages storage cache records: %s", err)
+	}
+	defer rows.Close()
+
+	found := false
+	var res []image.StageID
+	for rows.Next() {
+		found = true
+
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return false, nil, fmt.Errorf("unable to scan stages storage cache record: %s", err)
+		}
+
+		var stages []image.StageID
+		if err := json.Unmarshal([]byte(data), &stages); err != nil {
+			return false, nil, fmt.Errorf("unable to unmarshal stages storage cache record: %s", err)
+		}
+		res = append(res, stages...)
+	}
+
+	if err := rows.Err(); err != nil {
+		return false, nil, fmt.Errorf("unable to select stages storage cache records: %s", err)
+	}
+
+	return found, res, nil
+}
+
+func (cache *PostgresStagesStorageCache) DeleteAllStages(ctx context.Context, projectName string) error {
+	if _, err := cache.DB.ExecContext(ctx, `DELETE FROM werf_synchronization_stages_storage_cache WHERE namespace = $1 AND client_id = $2 AND project_name = $3`, cache.Namespace, cache.ClientID, projectName); err != nil {
+		return fmt.Errorf("unable to delete stages storage cache records: %s", err)
+	}
+	return nil
+}
+
+func (cache *PostgresStagesStorageCache) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	var data string
+	err := cache.DB.QueryRowContext(ctx, `SELECT stages FROM werf_synchronization_stages_storage_cache WHERE namespace = $1 AND client_id = $2 AND project_name = $3 AND digest = $4`, cache.Namespace, cache.ClientID, projectName, digest).Scan(&data)
+	if err == sql.ErrNoRows {
+		return false, nil, nil
+	} else if err != nil {
+		return false, nil, fmt.Errorf("unable to select stages storage cache record by digest %s: %s", digest, err)
+	}
+
+	var stages []image.StageID
+	if err := json.Unmarshal([]byte(data), &stages); err != nil {
+		return false, nil, fmt.Errorf("unable to unmarshal stages storage cache record by digest %s: %s", digest, err)
+	}
+
+	return true, stages, nil
+}
+
+func (cache *PostgresStagesStorageCache) StoreStagesByDigest(ctx context.Context, projectName, digest string, stages []image.StageID) error {
+	data, err := json.Marshal(stages)
+	if err != nil {
+		return fmt.Errorf("unable to marshal stages: %s", err)
+	}
+
+	if _, err := cache.DB.ExecContext(ctx, `INSERT INTO werf_synchronization_stages_storage_cache (namespace, client_id, project_name, digest, stages, updated_at) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (namespace, client_id, project_name, digest) DO UPDATE SET stages = EXCLUDED.stages, updated_at = EXCLUDED.updated_at`, cache.Namespace, cache.ClientID, projectName, digest, string(data), time.Now().Unix()); err != nil {
+		return fmt.Errorf("unable to store stages storage cache record by digest %s: %s", digest, err)
+	}
+
+	return nil
+}
+
+func (cache *PostgresStagesStorageCache) DeleteStagesByDigest(ctx context.Context, projectName, digest string) error {
+	if _, err := cache.DB.ExecContext(ctx, `DELETE FROM werf_synchronization_stages_storage_cache WHERE namespace = $1 AND client_id = $2 AND project_name = $3 AND digest = $4`, cache.Namespace, cache.ClientID, projectName, digest); err != nil {
+		return fmt.Errorf("unable to delete stages storage cache record by digest %s: %s", digest, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package synchronization_server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/form3tech-oss/jwt-go"
+	"sigs.k8s.io/yaml"
+)
+
+// ServerAuthConfig describes the tokens accepted by the synchronization server and the projects allowed for each token.
+// The static tokens and the tokens of the OIDC issuers are supported.
+//
+//	tokens:
+//	- name: team-a
+//	  token: "secret-1"
+//	  projects: ["team-a-*"]
+//	- name: admin
+//	  token: "secret-2"
+//	  projects: ["*"]
+//	oidc:
+//	- issuer: https://keycloak.example.com/realms/ci
+//	  audience: werf-synchronization
+//	  clients:
+//	  - clientID: team-b-ci
+//	    projects: ["team-b-*"]
+type ServerAuthConfig struct {
+	Tokens []ServerAuthToken       `json:"tokens"`
+	OIDC   []*ServerAuthOIDCIssuer `json:"oidc,omitempty"`
+}
+
+type ServerAuthToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Projects are the project name patterns in the path.Match format
+	Projects []string `json:"projects"`
+}
+
+func LoadServerAuthConfig(configPath string) (*ServerAuthConfig, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", configPath, err)
+	}
+
+	config := &ServerAuthConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", configPath, err)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("bad %s: %s", configPath, err)
+	}
+
+	return config, nil
+}
+
+func (config *ServerAuthConfig) validate() error {
+	for ind, token := range config.Tokens {
+		if token.Token == "" {
+			return fmt.Errorf("token #%d %q should not be empty", ind+1, token.Name)
+		}
+
+		if err := validateProjectsPatterns(token.Projects); err != nil {
+			return fmt.Errorf("token #%d %q: %s", ind+1, token.Name, err)
+		}
+	}
+
+	for ind, issuer := range config.OIDC {
+		if issuer.Issuer == "" {
+			return fmt.Errorf("oidc issuer #%d should not be empty", ind+1)
+		}
+
+		for _, client := range issuer.Clients {
+			if client.ClientID == "" {
+				return fmt.Errorf("oidc issuer %q: clientID should not be empty", issuer.Issuer)
+			}
+
+			if err := validateProjectsPatterns(client.Projects); err != nil {
+				return fmt.Errorf("oidc issuer %q client %q: %s", issuer.Issuer, client.ClientID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateProjectsPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("project pattern %q: %s", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Authorize checks the bearer token of the request and returns the project name which the request is allowed to access.
+func (config *ServerAuthConfig) Authorize(r *http.Request) (string, int, error) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", http.StatusUnauthorized, fmt.Errorf("bearer token required")
+	}
+	requestToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	principalName, projectsPatterns, err := config.authenticate(r.Context(), requestToken)
+	if err != nil {
+		return "", http.StatusUnauthorized, err
+	}
+
+	projectName := r.Header.Get(ProjectNameHeader)
+	if projectName == "" {
+		return "", http.StatusBadRequest, fmt.Errorf("%s header required", ProjectNameHeader)
+	}
+	if strings.ContainsAny(projectName, "/\\") || projectName == "." || projectName == ".." {
+		return "", http.StatusBadRequest, fmt.Errorf("bad project name %q", projectName)
+	}
+
+	for _, pattern := range projectsPatterns {
+		if matched, _ := path.Match(pattern, projectName); matched {
+			return projectName, http.StatusOK, nil
+		}
+	}
+
+	return "", http.StatusForbidden, fmt.Errorf("%s is not allowed to access project %q", principalName, projectName)
+}
+
+// authenticate returns the description of the token owner and the project name patterns allowed for the token.
+func (config *ServerAuthConfig) authenticate(ctx context.Context, requestToken string) (string, []string, error) {
+	if token := config.findToken(requestToken); token != nil {
+		return fmt.Sprintf("token %q", token.Name), token.Projects, nil
+	}
+
+	if len(config.OIDC) == 0 {
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(requestToken, claims); err != nil {
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	tokenIssuer, _ := claims["iss"].(string)
+	for _, issuer := range config.OIDC {
+		if issuer.Issuer != tokenIssuer {
+			continue
+		}
+
+		client, err := issuer.authorizeToken(ctx, requestToken)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid OIDC token: %s", err)
+		}
+
+		return fmt.Sprintf("OIDC client %q", client.ClientID), client.Projects, nil
+	}
+
+	return "", nil, fmt.Errorf("invalid token: issuer %q is not allowed", tokenIssuer)
+}
+
+func (config *ServerAuthConfig) findToken(requestToken string) *ServerAuthToken {
+	var res *ServerAuthToken
+	for ind := range config.Tokens {
+		// compare all tokens in the constant time to not reveal the matched token by the response time
+		if subtle.ConstantTimeCompare([]byte(config.Tokens[ind].Token), []byte(requestToken)) == 1 && res == nil {
+			res = &config.Tokens[ind]
+		}
+	}
+	return res
+}
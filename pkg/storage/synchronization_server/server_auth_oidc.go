@@ -0,0 +1,276 @@
+package synchronization_server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+const oidcKeysRefreshMinInterval = time.Minute
+
+var oidcHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+// ServerAuthOIDCIssuer describes the OIDC issuer which tokens are accepted by the synchronization server,
+// e.g. the tokens obtained by werf with the client credentials flow (--synchronization-oidc-issuer option).
+type ServerAuthOIDCIssuer struct {
+	Issuer string `json:"issuer"`
+	// Audience is checked against the aud claim of the token if specified
+	Audience string                 `json:"audience,omitempty"`
+	Clients  []ServerAuthOIDCClient `json:"clients"`
+
+	keysOnce sync.Once
+	keys     *oidcKeySet
+}
+
+// ServerAuthOIDCClient matches the token by the client id claim: azp or client_id.
+type ServerAuthOIDCClient struct {
+	ClientID string `json:"clientID"`
+	// Projects are the project name patterns in the path.Match format
+	Projects []string `json:"projects"`
+}
+
+// authorizeToken verifies the signature and the claims of the token and returns the client allowed to use the token.
+func (issuer *ServerAuthOIDCIssuer) authorizeToken(ctx context.Context, requestToken string) (*ServerAuthOIDCClient, error) {
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}}
+	if _, err := parser.ParseWithClaims(requestToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return issuer.getKeySet().getKey(ctx, kid)
+	}); err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(issuer.Issuer, true) {
+		return nil, fmt.Errorf("token is not issued by %q", issuer.Issuer)
+	}
+
+	if issuer.Audience != "" && !isOIDCAudienceMatched(claims["aud"], issuer.Audience) {
+		return nil, fmt.Errorf("token audience does not contain %q", issuer.Audience)
+	}
+
+	if _, hasExp := claims["exp"]; !hasExp {
+		return nil, fmt.Errorf("token without expiration is not allowed")
+	}
+
+	clientID, _ := claims["azp"].(string)
+	if clientID == "" {
+		clientID, _ = claims["client_id"].(string)
+	}
+
+	for ind := range issuer.Clients {
+		if issuer.Clients[ind].ClientID == clientID {
+			return &issuer.Clients[ind], nil
+		}
+	}
+
+	return nil, fmt.Errorf("client %q of issuer %q is not allowed", clientID, issuer.Issuer)
+}
+
+// isOIDCAudienceMatched checks the aud claim, which is either the string or the array of strings.
+func isOIDCAudienceMatched(aud interface{}, audience string) bool {
+	switch value := aud.(type) {
+	case string:
+		return value == audience
+	case []interface{}:
+		for _, item := range value {
+			if item == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (issuer *ServerAuthOIDCIssuer) getKeySet() *oidcKeySet {
+	issuer.keysOnce.Do(func() {
+		issuer.keys = &oidcKeySet{issuerURL: issuer.Issuer}
+	})
+	return issuer.keys
+}
+
+// oidcKeySet caches the issuer signing keys, the keys are fetched again when the token is signed with the unknown key (keys rotation).
+type oidcKeySet struct {
+	issuerURL string
+
+	mutex       sync.Mutex
+	keys        map[string]interface{}
+	refreshedAt time.Time
+}
+
+func (set *oidcKeySet) getKey(ctx context.Context, kid string) (interface{}, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	if key := set.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	if time.Since(set.refreshedAt) < oidcKeysRefreshMinInterval {
+		return nil, fmt.Errorf("signing key %q of issuer %q not found", kid, set.issuerURL)
+	}
+
+	keys, err := fetchOIDCKeys(ctx, set.issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	set.keys = keys
+	set.refreshedAt = time.Now()
+
+	if key := set.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("signing key %q of issuer %q not found", kid, set.issuerURL)
+}
+
+// findKey returns the key by kid, the token without kid is accepted only if the issuer has the single key.
+func (set *oidcKeySet) findKey(kid string) interface{} {
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key
+		}
+	}
+
+	return set.keys[kid]
+}
+
+func fetchOIDCKeys(ctx context.Context, issuerURL string) (map[string]interface{}, error) {
+	var discovery struct {
+		JwksURI string `json:"jwks_uri"`
+	}
+	if err := getOIDCJson(ctx, fmt.Sprintf("%s/.well-known/openid-configuration", strings.TrimSuffix(issuerURL, "/")), &discovery); err != nil {
+		return nil, err
+	}
+
+	if discovery.JwksURI == "" {
+		return nil, fmt.Errorf("jwks_uri not found in the OIDC discovery document of %q", issuerURL)
+	}
+
+	var jwks struct {
+		Keys []oidcJSONWebKey `json:"keys"`
+	}
+	if err := getOIDCJson(ctx, discovery.JwksURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("bad key %q of issuer %q: %s", jwk.Kid, issuerURL, err)
+		}
+
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+func getOIDCJson(ctx context.Context, url string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create GET request for %q: %s", url, err)
+	}
+
+	resp, err := oidcHttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error requesting url %q: %s", url, err)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response of %q request: %s", url, err)
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("got bad response %s by url %q request:\n%s", resp.Status, url, body)
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("unable to unmarshal json body by url %q request: %s", url, err)
+	}
+
+	return nil
+}
+
+type oidcJSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns nil for the unsupported key types.
+func (jwk oidcJSONWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJWKBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeJWKBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := decodeJWKBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeJWKBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeJWKBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("bad base64url value %q: %s", value, err)
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,168 @@
+package synchronization_server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+// testOIDCIssuer serves the discovery document, the signing keys and the client credentials token endpoint.
+type testOIDCIssuer struct {
+	*httptest.Server
+
+	key            *rsa.PrivateKey
+	keysRequests   int
+	tokenAudience  string
+	tokenExpiresIn time.Duration
+}
+
+func newTestOIDCIssuer(t *testing.T) *testOIDCIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testOIDCIssuer{key: key, tokenAudience: "werf-synchronization", tokenExpiresIn: time.Hour}
+	issuer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_, _ = fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys","token_endpoint":"%s/token"}`, issuer.URL, issuer.URL, issuer.URL)
+		case "/keys":
+			issuer.keysRequests++
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": "key-1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		case "/token":
+			clientID, _, _ := r.BasicAuth()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":300}`, issuer.newToken(t, clientID, issuer.key))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func (issuer *testOIDCIssuer) newToken(t *testing.T, clientID string, key *rsa.PrivateKey) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": issuer.URL,
+		"aud": []string{issuer.tokenAudience},
+		"azp": clientID,
+		"exp": time.Now().Add(issuer.tokenExpiresIn).Unix(),
+	})
+	token.Header["kid"] = "key-1"
+
+	signedToken, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signedToken
+}
+
+func newTestOIDCAuthConfig(issuerURL string) *ServerAuthConfig {
+	return &ServerAuthConfig{
+		Tokens: []ServerAuthToken{{Name: "team-a", Token: "secret-a", Projects: []string{"team-a-*"}}},
+		OIDC: []*ServerAuthOIDCIssuer{{
+			Issuer:   issuerURL,
+			Audience: "werf-synchronization",
+			Clients:  []ServerAuthOIDCClient{{ClientID: "team-b-ci", Projects: []string{"team-b-*"}}},
+		}},
+	}
+}
+
+func TestServerAuthConfig_AuthorizeOIDC(t *testing.T) {
+	issuer := newTestOIDCIssuer(t)
+	config := newTestOIDCAuthConfig(issuer.URL)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validToken := issuer.newToken(t, "team-b-ci", issuer.key)
+	unknownClientToken := issuer.newToken(t, "team-c-ci", issuer.key)
+	badSignatureToken := issuer.newToken(t, "team-b-ci", otherKey)
+
+	issuer.tokenAudience = "other"
+	otherAudienceToken := issuer.newToken(t, "team-b-ci", issuer.key)
+	issuer.tokenAudience = "werf-synchronization"
+
+	issuer.tokenExpiresIn = -time.Minute
+	expiredToken := issuer.newToken(t, "team-b-ci", issuer.key)
+
+	for _, tc := range []struct {
+		name, token, projectName string
+		expectedStatus           int
+	}{
+		{"valid token", validToken, "team-b-app", http.StatusOK},
+		{"project is not allowed", validToken, "team-a-app", http.StatusForbidden},
+		{"static token", "secret-a", "team-a-app", http.StatusOK},
+		{"unknown client", unknownClientToken, "team-b-app", http.StatusUnauthorized},
+		{"bad signature", badSignatureToken, "team-b-app", http.StatusUnauthorized},
+		{"other audience", otherAudienceToken, "team-b-app", http.StatusUnauthorized},
+		{"expired token", expiredToken, "team-b-app", http.StatusUnauthorized},
+		{"not a jwt", "secret-b", "team-b-app", http.StatusUnauthorized},
+	} {
+		projectName, status, err := config.Authorize(newTestRequest(tc.token, tc.projectName))
+		if status != tc.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d (%v)", tc.name, tc.expectedStatus, status, err)
+		}
+		if status == http.StatusOK && projectName != tc.projectName {
+			t.Errorf("%s: unexpected authorized project %q", tc.name, projectName)
+		}
+	}
+
+	if issuer.keysRequests != 1 {
+		t.Errorf("expected the signing keys to be fetched once, got %d requests", issuer.keysRequests)
+	}
+}
+
+func TestOIDCCredentialsProvider_AuthorizedByServer(t *testing.T) {
+	issuer := newTestOIDCIssuer(t)
+
+	handler := NewSynchronizationServerHandler(nil, nil)
+	handler.Auth = newTestOIDCAuthConfig(issuer.URL)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		projectName    string
+		expectedStatus int
+	}{
+		{"team-b-app", http.StatusOK},
+		{"team-a-app", http.StatusForbidden},
+	} {
+		client, err := NewHttpClient(NewOIDCCredentialsProvider(context.Background(), issuer.URL, "team-b-ci", "secret", nil, tc.projectName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Post(server.URL+"/new-client-id", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.expectedStatus {
+			t.Errorf("project %q: expected status %d, got %d", tc.projectName, tc.expectedStatus, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package synchronization_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	"github.com/werf/lockgate/pkg/distributed_locker/optimistic_locking_store"
+
+	"github.com/werf/werf/pkg/storage"
+)
+
+var testAuthConfig = &ServerAuthConfig{
+	Tokens: []ServerAuthToken{
+		{Name: "team-a", Token: "secret-a", Projects: []string{"team-a-*"}},
+		{Name: "admin", Token: "secret-admin", Projects: []string{"*"}},
+	},
+}
+
+func newTestRequest(token, projectName string) *http.Request {
+	req := httptest.NewRequest("POST", "/new-client-id", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if projectName != "" {
+		req.Header.Set(ProjectNameHeader, projectName)
+	}
+	return req
+}
+
+func TestServerAuthConfig_Authorize(t *testing.T) {
+	for _, tc := range []struct {
+		token, projectName string
+		expectedStatus     int
+	}{
+		{"", "team-a-app", http.StatusUnauthorized},
+		{"wrong", "team-a-app", http.StatusUnauthorized},
+		{"secret-a", "", http.StatusBadRequest},
+		{"secret-a", "../team-a-app", http.StatusBadRequest},
+		{"secret-a", "team-a-app", http.StatusOK},
+		{"secret-a", "team-b-app", http.StatusForbidden},
+		{"secret-admin", "team-b-app", http.StatusOK},
+	} {
+		projectName, status, err := testAuthConfig.Authorize(newTestRequest(tc.token, tc.projectName))
+		if status != tc.expectedStatus {
+			t.Errorf("token %q project %q: expected status %d, got %d (%v)", tc.token, tc.projectName, tc.expectedStatus, status, err)
+		}
+		if status == http.StatusOK && projectName != tc.projectName {
+			t.Errorf("token %q project %q: unexpected authorized project %q", tc.token, tc.projectName, projectName)
+		}
+	}
+}
+
+func TestSynchronizationServerHandler_ProjectsIsolation(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	var createdFor []string
+	handler := NewSynchronizationServerHandler(
+		func(projectName, clientID string) (distributed_locker.DistributedLockerBackend, error) {
+			createdFor = append(createdFor, projectName+"/"+clientID)
+			return distributed_locker.NewOptimisticLockingStorageBasedBackend(optimistic_locking_store.NewInMemoryStore()), nil
+		},
+		func(projectName, clientID string) (storage.StagesStorageCache, error) {
+			return storage.NewFileStagesStorageCache(filepath.Join(cacheDir, projectName, clientID)), nil
+		},
+	)
+	handler.Auth = testAuthConfig
+
+	for _, req := range []*http.Request{
+		newTestRequest("secret-a", "team-a-app"),
+		newTestRequest("secret-admin", "team-b-app"),
+		newTestRequest("secret-a", "team-b-app"),
+	} {
+		req.URL.Path = "/client-1/locker/acquire"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(createdFor) != 2 || createdFor[0] != "team-a-app/client-1" || createdFor[1] != "team-b-app/client-1" {
+		t.Errorf("unexpected handlers created: %v", createdFor)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newTestRequest("", ""))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected unauthenticated new client id request to be rejected, got %d", rec.Code)
+	}
+}
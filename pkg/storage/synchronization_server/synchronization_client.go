@@ -10,10 +10,10 @@ type SynchronizationClient struct {
 	URL        string
 }
 
-func NewSynchronizationClient(url string) *SynchronizationClient {
+func NewSynchronizationClient(url string, httpClient *http.Client) *SynchronizationClient {
 	return &SynchronizationClient{
 		URL:        url,
-		HttpClient: &http.Client{},
+		HttpClient: httpClient,
 	}
 }
 
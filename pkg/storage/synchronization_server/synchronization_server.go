@@ -2,7 +2,10 @@ package synchronization_server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"sync"
@@ -17,22 +20,61 @@ import (
 	"github.com/werf/werf/pkg/storage"
 )
 
-func RunSynchronizationServer(_ context.Context, ip, port string, distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error)) error {
+type DistributedLockerBackendFactoryFunc func(projectName, clientID string) (distributed_locker.DistributedLockerBackend, error)
+type StagesStorageCacheFactoryFunc func(projectName, clientID string) (storage.StagesStorageCache, error)
+
+type SynchronizationServerOptions struct {
+	// Auth enables the token authentication and the isolation of the projects data, the project name passed to the factory funcs is empty when the authentication is disabled
+	Auth *ServerAuthConfig
+
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile enables the verification of the client certificates (mTLS)
+	TLSClientCAFile string
+}
+
+func RunSynchronizationServer(_ context.Context, ip, port string, distributedLockerBackendFactoryFunc DistributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc StagesStorageCacheFactoryFunc, opts SynchronizationServerOptions) error {
 	handler := NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc)
-	return http.ListenAndServe(fmt.Sprintf("%s:%s", ip, port), handler)
+	handler.Auth = opts.Auth
+
+	server := &http.Server{Addr: fmt.Sprintf("%s:%s", ip, port), Handler: handler}
+
+	if opts.TLSCertFile == "" && opts.TLSKeyFile == "" {
+		if opts.TLSClientCAFile != "" {
+			return fmt.Errorf("server certificate and key should be specified to verify the client certificates")
+		}
+		return server.ListenAndServe()
+	}
+
+	if opts.TLSClientCAFile != "" {
+		caData, err := ioutil.ReadFile(opts.TLSClientCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read client CA certificate %q: %s", opts.TLSClientCAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return fmt.Errorf("no certificates found in client CA certificate %q", opts.TLSClientCAFile)
+		}
+
+		server.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+
+	return server.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
 }
 
 type SynchronizationServerHandler struct {
 	*http.ServeMux
 
-	DistributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error)
-	StagesStorageCacheFactoryFunc       func(clientID string) (storage.StagesStorageCache, error)
+	DistributedLockerBackendFactoryFunc DistributedLockerBackendFactoryFunc
+	StagesStorageCacheFactoryFunc       StagesStorageCacheFactoryFunc
+	Auth                                *ServerAuthConfig
 
 	mux                             sync.Mutex
 	SynchronizationServerByClientID map[string]*SynchronizationServerHandlerByClientID
 }
 
-func NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(requestID string) (storage.StagesStorageCache, error)) *SynchronizationServerHandler {
+func NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc DistributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc StagesStorageCacheFactoryFunc) *SynchronizationServerHandler {
 	srv := &SynchronizationServerHandler{
 		ServeMux:                            http.NewServeMux(),
 		DistributedLockerBackendFactoryFunc: distributedLockerBackendFactoryFunc,
@@ -73,6 +115,10 @@ type NewClientIDResponse struct {
 }
 
 func (server *SynchronizationServerHandler) handleNewClientID(w http.ResponseWriter, r *http.Request) {
+	if _, ok := server.authorize(w, r); !ok {
+		return
+	}
+
 	var request NewClientIDRequest
 	var response NewClientIDResponse
 	HandleRequest(w, r, &request, &response, func() {
@@ -119,7 +165,12 @@ func (server *SynchronizationServerHandler) handleRequestByClientID(w http.Respo
 		return
 	}
 
-	if clientServer, err := server.getOrCreateHandlerByClientID(clientID); err != nil {
+	projectName, ok := server.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if clientServer, err := server.getOrCreateHandlerByClientID(projectName, clientID); err != nil {
 		http.Error(w, fmt.Sprintf("Internal error: %s", err), http.StatusInternalServerError)
 		return
 	} else {
@@ -127,25 +178,48 @@ func (server *SynchronizationServerHandler) handleRequestByClientID(w http.Respo
 	}
 }
 
-func (server *SynchronizationServerHandler) getOrCreateHandlerByClientID(clientID string) (*SynchronizationServerHandlerByClientID, error) {
+// authorize writes the error response and returns false when the request is not allowed.
+// The project name is empty when the authentication is disabled.
+func (server *SynchronizationServerHandler) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if server.Auth == nil {
+		return "", true
+	}
+
+	projectName, status, err := server.Auth.Authorize(r)
+	if err != nil {
+		logboek.Debug().LogF("SynchronizationServerHandler -- Unauthorized request %q: %s\n", r.URL.Path, err)
+		http.Error(w, fmt.Sprintf("%s: %s", http.StatusText(status), err), status)
+		return "", false
+	}
+
+	return projectName, true
+}
+
+func (server *SynchronizationServerHandler) getOrCreateHandlerByClientID(projectName, clientID string) (*SynchronizationServerHandlerByClientID, error) {
 	server.mux.Lock()
 	defer server.mux.Unlock()
 
-	if handler, hasKey := server.SynchronizationServerByClientID[clientID]; hasKey {
+	// data of the client is isolated by the project, so the token of one project cannot access the data of another project by the known client id
+	key := clientID
+	if projectName != "" {
+		key = fmt.Sprintf("%s/%s", projectName, clientID)
+	}
+
+	if handler, hasKey := server.SynchronizationServerByClientID[key]; hasKey {
 		return handler, nil
 	} else {
-		distributedLockerBackend, err := server.DistributedLockerBackendFactoryFunc(clientID)
+		distributedLockerBackend, err := server.DistributedLockerBackendFactoryFunc(projectName, clientID)
 		if err != nil {
 			return nil, fmt.Errorf("unable to create distributed locker backend for clientID %q: %s", clientID, err)
 		}
 
-		stagesStorageCache, err := server.StagesStorageCacheFactoryFunc(clientID)
+		stagesStorageCache, err := server.StagesStorageCacheFactoryFunc(projectName, clientID)
 		if err != nil {
 			return nil, fmt.Errorf("unable to create stages storage cache for clientID %q: %s", clientID, err)
 		}
 
 		handler := NewSynchronizationServerHandlerByClientID(clientID, distributedLockerBackend, stagesStorageCache)
-		server.SynchronizationServerByClientID[clientID] = handler
+		server.SynchronizationServerByClientID[key] = handler
 
 		logboek.Debug().LogF("SynchronizationServerHandler -- Created new synchronization server handler by clientID %q: %v\n", clientID, handler)
 		return handler, nil
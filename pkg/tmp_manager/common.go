@@ -1,6 +1,7 @@
 package tmp_manager
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -63,7 +64,11 @@ func releasePath(path, createdPathsDir, releasedPathsDir string) error {
 	return nil
 }
 
-func newTmpDir(prefix string) (string, error) {
+func newTmpDir(ctx context.Context, prefix string) (string, error) {
+	if err := CheckQuota(ctx); err != nil {
+		return "", err
+	}
+
 	newDir, err := ioutil.TempDir(werf.GetTmpDir(), prefix)
 	if err != nil {
 		return "", err
@@ -72,7 +77,11 @@ func newTmpDir(prefix string) (string, error) {
 	return newDir, nil
 }
 
-func newTmpFile(prefix string) (string, error) {
+func newTmpFile(ctx context.Context, prefix string) (string, error) {
+	if err := CheckQuota(ctx); err != nil {
+		return "", err
+	}
+
 	newFile, err := ioutil.TempFile(werf.GetTmpDir(), prefix)
 	if err != nil {
 		return "", err
@@ -11,7 +11,7 @@ import (
 )
 
 func CreateDockerConfigDir(ctx context.Context, fromDockerConfig string) (string, error) {
-	newDir, err := newTmpDir(DockerConfigDirPrefix)
+	newDir, err := newTmpDir(ctx, DockerConfigDirPrefix)
 	if err != nil {
 		return "", err
 	}
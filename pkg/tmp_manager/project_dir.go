@@ -7,7 +7,7 @@ import (
 )
 
 func CreateProjectDir(ctx context.Context) (string, error) {
-	newDir, err := newTmpDir(ProjectDirPrefix)
+	newDir, err := newTmpDir(ctx, ProjectDirPrefix)
 	if err != nil {
 		return "", err
 	}
@@ -17,6 +17,15 @@ func CreateProjectDir(ctx context.Context) (string, error) {
 		return "", err
 	}
 
+	registerRunPath(&runPath{
+		Path: newDir,
+		RegistryLinks: []string{
+			filepath.Join(GetCreatedTmpDirs(), projectsServiceDir, filepath.Base(newDir)),
+			filepath.Join(GetReleasedTmpDirs(), projectsServiceDir, filepath.Base(newDir)),
+		},
+		KeepOnRemoveError: true,
+	})
+
 	return newDir, nil
 }
 
@@ -0,0 +1,158 @@
+package tmp_manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/volumeutils"
+)
+
+var (
+	tmpDirQuotaBytes uint64
+
+	runPathsMutex sync.Mutex
+	runPaths      []*runPath
+)
+
+type runPath struct {
+	Path string
+	// RegistryLinks are created and released registry symlinks which become useless after the path removal
+	RegistryLinks []string
+	// KeepOnRemoveError means that the path could contain files of build containers, which cannot be removed without privileges, and will be removed by the werf host cleanup
+	KeepOnRemoveError bool
+}
+
+// SetTmpDirQuota limits the summary size of the tmp data created during the current werf run (0 means no limit).
+func SetTmpDirQuota(quotaBytes uint64) {
+	tmpDirQuotaBytes = quotaBytes
+}
+
+// RegisterRunPath registers tmp file or dir created during the current werf run.
+// Registered paths are taken into account by CheckProjectedUsage and removed by CleanupRunData.
+func RegisterRunPath(path string) {
+	registerRunPath(&runPath{Path: path})
+}
+
+func registerRunPath(p *runPath) {
+	runPathsMutex.Lock()
+	defer runPathsMutex.Unlock()
+
+	runPaths = append(runPaths, p)
+}
+
+func GetRunUsageBytes() (uint64, error) {
+	runPathsMutex.Lock()
+	defer runPathsMutex.Unlock()
+
+	var usage uint64
+	for _, p := range runPaths {
+		path := p.Path
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, fmt.Errorf("unable to get info for %s: %s", path, err)
+		}
+
+		size, err := volumeutils.DirSizeBytes(path)
+		if err != nil {
+			return 0, fmt.Errorf("unable to calculate size of %s: %s", path, err)
+		}
+		usage += size
+	}
+
+	return usage, nil
+}
+
+// CheckQuota returns an error when the tmp data registered during the current werf run exceeds the tmp dir quota.
+// It should be called before writing the tmp data of unknown size (e.g. before the stage build or the tmp dir creation).
+func CheckQuota(ctx context.Context) error {
+	return checkQuota(0)
+}
+
+func checkQuota(projectedBytes uint64) error {
+	if tmpDirQuotaBytes == 0 {
+		return nil
+	}
+
+	runUsage, err := GetRunUsageBytes()
+	if err != nil {
+		return err
+	}
+
+	if runUsage+projectedBytes > tmpDirQuotaBytes {
+		return fmt.Errorf("tmp dir quota exceeded: %s already used by the current run and %s more required, quota is %s (adjust --tmp-dir-quota or $WERF_TMP_DIR_QUOTA)", humanize.Bytes(runUsage), humanize.Bytes(projectedBytes), humanize.Bytes(tmpDirQuotaBytes))
+	}
+
+	return nil
+}
+
+// CheckProjectedUsage should be called before writing projectedBytes of tmp data into the dir.
+// An error is returned when the data cannot fit into the free space of the dir volume or exceeds the tmp dir quota.
+func CheckProjectedUsage(ctx context.Context, dir string, projectedBytes uint64) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", dir, err)
+	}
+
+	if err := checkQuota(projectedBytes); err != nil {
+		return err
+	}
+
+	vu, err := volumeutils.GetVolumeUsageByPath(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("error getting volume usage by path %s: %s", dir, err)
+	}
+
+	availableBytes := vu.TotalBytes - vu.UsedBytes
+	if projectedBytes > availableBytes {
+		return fmt.Errorf("not enough space in %s: %s required, only %s available (free up space, run werf host cleanup or use another tmp dir with --tmp-dir or $WERF_TMP_DIR)", dir, humanize.Bytes(projectedBytes), humanize.Bytes(availableBytes))
+	}
+
+	logboek.Context(ctx).Debug().LogF("Tmp data projected usage in %s: %s (available %s)\n", dir, humanize.Bytes(projectedBytes), humanize.Bytes(availableBytes))
+
+	return nil
+}
+
+// CleanupRunData removes all tmp data registered during the current werf run.
+// It should be called only after the successful run, otherwise the data is left to the werf host cleanup.
+func CleanupRunData(ctx context.Context) error {
+	runPathsMutex.Lock()
+	defer runPathsMutex.Unlock()
+
+	var removeErrors []error
+	for _, p := range runPaths {
+		logboek.Context(ctx).Debug().LogF("Removing tmp data %s\n", p.Path)
+
+		if err := os.RemoveAll(p.Path); err != nil {
+			if p.KeepOnRemoveError {
+				logboek.Context(ctx).Debug().LogF("Unable to remove %s, leaving it to the host cleanup: %s\n", p.Path, err)
+				continue
+			}
+
+			removeErrors = append(removeErrors, fmt.Errorf("unable to remove %s: %s", p.Path, err))
+			continue
+		}
+
+		for _, link := range p.RegistryLinks {
+			if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+				removeErrors = append(removeErrors, fmt.Errorf("unable to remove %s: %s", link, err))
+			}
+		}
+	}
+	runPaths = nil
+
+	if len(removeErrors) > 0 {
+		msg := ""
+		for _, err := range removeErrors {
+			msg += fmt.Sprintf("%s\n", err)
+		}
+		return fmt.Errorf("%s", msg)
+	}
+
+	return nil
+}
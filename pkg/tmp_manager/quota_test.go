@@ -0,0 +1,44 @@
+package tmp_manager
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckQuota(t *testing.T) {
+	t.Cleanup(func() {
+		SetTmpDirQuota(0)
+		runPaths = nil
+	})
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	RegisterRunPath(dir)
+
+	ctx := context.Background()
+
+	SetTmpDirQuota(0)
+	if err := CheckQuota(ctx); err != nil {
+		t.Fatalf("expected no limit, got: %s", err)
+	}
+
+	SetTmpDirQuota(2000)
+	if err := CheckQuota(ctx); err != nil {
+		t.Fatalf("expected usage within the quota, got: %s", err)
+	}
+	if err := checkQuota(1500); err == nil {
+		t.Fatal("expected projected usage to exceed the quota")
+	}
+
+	SetTmpDirQuota(500)
+	if err := CheckQuota(ctx); err == nil {
+		t.Fatal("expected usage to exceed the quota")
+	}
+	if _, err := newTmpDir(ctx, "test"); err == nil {
+		t.Fatal("expected tmp dir creation to fail when the quota is exceeded")
+	}
+}
@@ -4,18 +4,31 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+
+	"github.com/werf/werf/pkg/werf"
 )
 
 func CreateWerfConfigRender(ctx context.Context) (string, error) {
-	newFile, err := newTmpFile(WerfConfigRenderPrefix)
+	newFile, err := newTmpFile(ctx, WerfConfigRenderPrefix)
 	if err != nil {
 		return "", err
 	}
 
+	// the render is removed at the end of the run, the registry link only allows the host cleanup to remove the render left by the failed process
+	if werf.IsReadOnly() {
+		registerRunPath(&runPath{Path: newFile})
+		return newFile, nil
+	}
+
 	if err := registerCreatedPath(newFile, filepath.Join(GetCreatedTmpDirs(), werfConfigRendersServiceDir)); err != nil {
 		os.RemoveAll(newFile)
 		return "", err
 	}
 
+	registerRunPath(&runPath{
+		Path:          newFile,
+		RegistryLinks: []string{filepath.Join(GetCreatedTmpDirs(), werfConfigRendersServiceDir, filepath.Base(newFile))},
+	})
+
 	return newFile, nil
 }
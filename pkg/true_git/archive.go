@@ -35,14 +35,19 @@ func (opts ArchiveOptions) ID() string {
 		renamedNewFileNames = append(renamedNewFileNames, renamedNewFileName)
 	}
 
-	return util.Sha256Hash(
-		append(
-			append(renamedOldFilePaths, renamedNewFileNames...),
-			opts.Commit,
-			opts.PathScope,
-			opts.PathMatcher.ID(),
-		)...
+	args := append(
+		append(renamedOldFilePaths, renamedNewFileNames...),
+		opts.Commit,
+		opts.PathScope,
+		opts.PathMatcher.ID(),
 	)
+
+	// the work trees content depends on the submodules depth
+	if submodulesOptionsID := SubmodulesOptionsID(); submodulesOptionsID != "" {
+		args = append(args, submodulesOptionsID)
+	}
+
+	return util.Sha256Hash(args...)
 }
 
 func ArchiveWithSubmodules(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir string, opts ArchiveOptions) error {
@@ -107,6 +112,10 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 	}
 	logProcess.End()
 
+	if err := ensureLFSContent(ctx, repository, workTreeDir, opts.Commit, result); err != nil {
+		return err
+	}
+
 	logProcess = logboek.Context(ctx).Debug().LogProcess("ls-tree result walk (%s)", opts.PathMatcher.String())
 	logProcess.Start()
 	if err := result.Walk(func(lsTreeEntry *ls_tree.LsTreeEntry) error {
@@ -54,6 +54,10 @@ type diffParser struct {
 
 	state   parserState
 	lineBuf []byte
+
+	// hunkOldLine and hunkNewLine are the old and the new file line numbers of the next hunk line (0 outside of the hunk)
+	hunkOldLine int
+	hunkNewLine int
 }
 
 func appendUnique(list []string, value string) []string {
@@ -226,12 +230,63 @@ func (p *diffParser) handleDiffLine(line string) error {
 		if strings.HasPrefix(line, "Submodule ") {
 			return p.handleSubmoduleLine(line)
 		}
+		if strings.HasPrefix(line, "@@ ") {
+			return p.handleHunkHeader(line)
+		}
+		if isLFSPointerDiffLine(line, p.hunkOldLine, p.hunkNewLine) {
+			p.moveHunkLine(line)
+			return p.handleLFSPointerLine(line)
+		}
+		p.moveHunkLine(line)
 		return p.writeOutLine(line)
 	}
 
 	return nil
 }
 
+// handleHunkHeader parses the start lines of the hunk header "@@ -OLD_START[,OLD_COUNT] +NEW_START[,NEW_COUNT] @@".
+func (p *diffParser) handleHunkHeader(line string) error {
+	p.hunkOldLine, p.hunkNewLine = 0, 0
+
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && strings.HasPrefix(fields[1], "-") && strings.HasPrefix(fields[2], "+") {
+		p.hunkOldLine = parseHunkStartLine(fields[1][1:])
+		p.hunkNewLine = parseHunkStartLine(fields[2][1:])
+	}
+
+	return p.writeOutLine(line)
+}
+
+func parseHunkStartLine(rangeStr string) int {
+	start, err := strconv.Atoi(strings.SplitN(rangeStr, ",", 2)[0])
+	if err != nil {
+		return 0
+	}
+
+	// the empty range of the new or deleted file starts with 0, the first line of the other file is 1
+	if start == 0 {
+		return 1
+	}
+
+	return start
+}
+
+func (p *diffParser) moveHunkLine(line string) {
+	if p.hunkOldLine == 0 || len(line) == 0 {
+		return
+	}
+
+	switch line[0] {
+	case ' ':
+		p.hunkOldLine++
+		p.hunkNewLine++
+	case '-':
+		p.hunkOldLine++
+	case '+':
+		p.hunkNewLine++
+	}
+}
+
 func (p *diffParser) handleDiffBegin(line string) (err error) {
 	var lineParts []string
 	var aAndBParts []string
@@ -254,6 +309,7 @@ func (p *diffParser) handleDiffBegin(line string) (err error) {
 	trimmedPaths := make(map[string]string)
 
 	p.LastSeenPaths = nil
+	p.hunkOldLine, p.hunkNewLine = 0, 0
 
 	for _, data := range []struct{ PathWithPrefix, Prefix string }{{a, "a/"}, {b, "b/"}} {
 		isPathQuoted := strings.HasPrefix(data.PathWithPrefix, "\"") && strings.HasSuffix(data.PathWithPrefix, "\"")
@@ -427,6 +483,15 @@ func (p *diffParser) handleShortBinaryHeader(line string) error {
 	return p.writeOutLine(line)
 }
 
+// The git LFS pointer files changes cannot be applied as the text patch, so these files are handled the same way as binaries.
+func (p *diffParser) handleLFSPointerLine(line string) error {
+	for _, path := range p.LastSeenPaths {
+		p.BinaryPaths = appendUnique(p.BinaryPaths, path)
+	}
+
+	return p.writeOutLine(line)
+}
+
 func (p *diffParser) applyFileRenames(path string) string {
 	if renamedFileName, willRename := p.FileRenames[path]; willRename {
 		return filepath.ToSlash(filepath.Join(p.PathScope, renamedFileName))
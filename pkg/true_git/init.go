@@ -26,14 +26,34 @@ var (
 	forbiddenGitVersionErrorMsg = fmt.Sprintf("Forbidden git versions: %s", strings.Join(ForbiddenGitVersionsConstraintValues, ", "))
 
 	liveGitOutput bool
+
+	submodulesDepth       int
+	shallowSubmodules     bool
+	submodulesURLRewrites []URLRewrite
+
+	sharedObjects bool
 )
 
 type Options struct {
 	LiveGitOutput bool
+
+	// SubmodulesDepth limits the recursion depth of the submodules, 0 means no limit
+	SubmodulesDepth int
+	// ShallowSubmodules enables fetching only the required commits of the submodules
+	ShallowSubmodules bool
+	// SubmodulesURLRewrites are applied to the submodules urls the same way as the url.<base>.insteadOf git option
+	SubmodulesURLRewrites []URLRewrite
+
+	// SharedObjects enables sharing the objects of the remote repos clones on the host
+	SharedObjects bool
 }
 
 func Init(opts Options) error {
 	liveGitOutput = opts.LiveGitOutput
+	submodulesDepth = opts.SubmodulesDepth
+	shallowSubmodules = opts.ShallowSubmodules
+	submodulesURLRewrites = opts.SubmodulesURLRewrites
+	sharedObjects = opts.SharedObjects
 
 	var err error
 
@@ -61,6 +81,10 @@ func Init(opts Options) error {
 	return nil
 }
 
+func IsSharedObjectsEnabled() bool {
+	return sharedObjects
+}
+
 func getGitCliVersion() (string, error) {
 	cmd := exec.Command("git", "version")
 
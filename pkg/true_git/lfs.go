@@ -0,0 +1,295 @@
+package true_git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/true_git/ls_tree"
+)
+
+// The git LFS tracked files are stored in the repository as the small pointer files, the content is stored on the LFS server.
+// The work tree contains the pointer files when the git-lfs smudge filter is not configured or the LFS objects are not available.
+
+const (
+	lfsPointerVersionLine = "version https://git-lfs.github.com/spec/v1"
+	lfsPointerMaxSize     = 1024
+	lfsPullPathsChunkSize = 100
+)
+
+type lfsPointer struct {
+	Oid  string
+	Size string
+}
+
+// parseLFSPointer parses the git LFS pointer file content, ok is false when the data is not the pointer.
+func parseLFSPointer(data []byte) (pointer *lfsPointer, ok bool) {
+	if len(data) > lfsPointerMaxSize {
+		return nil, false
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 3 || lines[0] != lfsPointerVersionLine {
+		return nil, false
+	}
+
+	pointer = &lfsPointer{}
+	for _, line := range lines[1:] {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil, false
+		}
+
+		switch parts[0] {
+		case "oid":
+			pointer.Oid = parts[1]
+		case "size":
+			pointer.Size = parts[1]
+		}
+	}
+
+	if pointer.Oid == "" || pointer.Size == "" {
+		return nil, false
+	}
+
+	return pointer, true
+}
+
+// isLFSPointerDiffLine checks whether the diff line is the version line of the git LFS pointer file,
+// the version line is the first line of the pointer file (oldLine and newLine are the line numbers of the diff line in the old and the new file).
+func isLFSPointerDiffLine(line string, oldLine, newLine int) bool {
+	if len(line) == 0 || line[1:] != lfsPointerVersionLine {
+		return false
+	}
+
+	switch line[0] {
+	case ' ':
+		return oldLine == 1 || newLine == 1
+	case '-':
+		return oldLine == 1
+	case '+':
+		return newLine == 1
+	}
+
+	return false
+}
+
+func isLFSPointerFile(absFilepath string) (bool, error) {
+	info, err := os.Lstat(absFilepath)
+	if err != nil {
+		return false, fmt.Errorf("lstat %s failed: %s", absFilepath, err)
+	}
+
+	if !info.Mode().IsRegular() || info.Size() > lfsPointerMaxSize {
+		return false, nil
+	}
+
+	data, err := ioutil.ReadFile(absFilepath)
+	if err != nil {
+		return false, fmt.Errorf("cannot read file %s: %s", absFilepath, err)
+	}
+
+	_, ok := parseLFSPointer(data)
+	return ok, nil
+}
+
+// ensureLFSContent replaces the git LFS pointer files of the ls-tree result in the work tree with the LFS objects content.
+func ensureLFSContent(ctx context.Context, repository *git.Repository, workTreeDir, commit string, result *ls_tree.Result) error {
+	var pointerPaths []string
+	if err := result.Walk(func(lsTreeEntry *ls_tree.LsTreeEntry) error {
+		switch lsTreeEntry.Mode {
+		case filemode.Regular, filemode.Executable, filemode.Deprecated:
+		default:
+			return nil
+		}
+
+		isPointer, err := isLFSPointerFile(filepath.Join(workTreeDir, lsTreeEntry.FullFilepath))
+		if err != nil {
+			return err
+		}
+
+		if isPointer {
+			pointerPaths = append(pointerPaths, filepath.ToSlash(lsTreeEntry.FullFilepath))
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(pointerPaths) == 0 {
+		return nil
+	}
+
+	lfsPaths, err := getLFSTrackedPaths(ctx, repository, workTreeDir, commit, pointerPaths)
+	if err != nil {
+		return fmt.Errorf("unable to get git LFS tracked files: %s", err)
+	}
+
+	if len(lfsPaths) == 0 {
+		return nil
+	}
+
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		return fmt.Errorf("git-lfs is required to get the content of %d git LFS tracked files (%s): git-lfs not found: %s", len(lfsPaths), strings.Join(lfsPaths, ", "), err)
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess("Fetching git LFS objects (%d files)", len(lfsPaths)).DoError(func() error {
+		for start := 0; start < len(lfsPaths); start += lfsPullPathsChunkSize {
+			end := start + lfsPullPathsChunkSize
+			if end > len(lfsPaths) {
+				end = len(lfsPaths)
+			}
+
+			if _, err := runGitCmd(ctx, []string{"lfs", "pull", "--include", strings.Join(lfsPaths[start:end], ",")}, workTreeDir, runGitCmdOptions{}); err != nil {
+				return fmt.Errorf("git lfs pull failed: %s", err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var missingPaths []string
+	for _, lfsPath := range lfsPaths {
+		isPointer, err := isLFSPointerFile(filepath.Join(workTreeDir, lfsPath))
+		if err != nil {
+			return err
+		}
+
+		if isPointer {
+			missingPaths = append(missingPaths, lfsPath)
+		}
+	}
+
+	if len(missingPaths) > 0 {
+		return fmt.Errorf("git LFS content is missing for the following files of the commit %s (check that LFS objects are pushed to the LFS server):\n%s", commit, strings.Join(missingPaths, "\n"))
+	}
+
+	return nil
+}
+
+// getLFSTrackedPaths returns the paths with the filter=lfs attribute set by the .gitattributes files of the commit.
+func getLFSTrackedPaths(ctx context.Context, repository *git.Repository, workTreeDir, commit string, paths []string) ([]string, error) {
+	commitObj, err := repository.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get commit %s: %s", commit, err)
+	}
+
+	tree, err := commitObj.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get commit %s tree: %s", commit, err)
+	}
+
+	dirsMap := map[string]bool{}
+	for _, p := range paths {
+		dir := p
+		for dir != "." {
+			dir = path.Dir(dir)
+			dirsMap[dir] = true
+		}
+	}
+
+	var dirs []string
+	for dir := range dirsMap {
+		dirs = append(dirs, dir)
+	}
+
+	// the patterns should be given in the order of increasing priority: the root .gitattributes first, then .gitattributes down the path
+	sort.Slice(dirs, func(i, j int) bool {
+		iDepth, jDepth := getDirDepth(dirs[i]), getDirDepth(dirs[j])
+		if iDepth != jDepth {
+			return iDepth < jDepth
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	var stack []gitattributes.MatchAttribute
+	for _, dir := range dirs {
+		var domain []string
+		if dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+
+		gitattributesPath := path.Join(dir, ".gitattributes")
+		entry, err := tree.FindEntry(gitattributesPath)
+		if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get %s: %s", gitattributesPath, err)
+		}
+
+		data, err := readBlob(ctx, repository, workTreeDir, entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %s", gitattributesPath, err)
+		}
+
+		attributes, err := gitattributes.ReadAttributes(bytes.NewReader(data), domain, dir == ".")
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %s", gitattributesPath, err)
+		}
+
+		stack = append(stack, attributes...)
+	}
+
+	matcher := gitattributes.NewMatcher(stack)
+
+	var res []string
+	for _, p := range paths {
+		results, _ := matcher.Match(strings.Split(p, "/"), []string{"filter"})
+		if attr, ok := results["filter"]; ok && attr.IsValueSet() && attr.Value() == "lfs" {
+			res = append(res, p)
+		}
+	}
+
+	return res, nil
+}
+
+// readBlob reads the blob from the repository, the blob missing in the partial clone is fetched by git from the promisor remote.
+func readBlob(ctx context.Context, repository *git.Repository, workTreeDir string, hash plumbing.Hash) ([]byte, error) {
+	blob, err := repository.BlobObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		cmd := exec.CommandContext(ctx, "git", append(getCommonGitOptions(), "cat-file", "blob", hash.String())...)
+		cmd.Dir = workTreeDir
+
+		data, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git cat-file blob %s failed: %s", hash, err)
+		}
+
+		return data, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+func getDirDepth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
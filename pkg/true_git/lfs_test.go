@@ -0,0 +1,114 @@
+package true_git
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/integration/pkg/utils"
+	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const lfsPointerContent = `version https://git-lfs.github.com/spec/v1
+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size 12345
+`
+
+var _ = Describe("git LFS", func() {
+	It("should parse the pointer file", func() {
+		pointer, ok := parseLFSPointer([]byte(lfsPointerContent))
+		Ω(ok).Should(BeTrue())
+		Ω(pointer.Oid).Should(Equal("sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"))
+		Ω(pointer.Size).Should(Equal("12345"))
+
+		_, ok = parseLFSPointer([]byte("version https://git-lfs.github.com/spec/v1\n"))
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should detect the pointer version line in the diff only at the beginning of the file", func() {
+		Ω(isLFSPointerDiffLine("+version https://git-lfs.github.com/spec/v1", 1, 1)).Should(BeTrue())
+		Ω(isLFSPointerDiffLine(" version https://git-lfs.github.com/spec/v1", 1, 1)).Should(BeTrue())
+		Ω(isLFSPointerDiffLine("-version https://git-lfs.github.com/spec/v1", 1, 3)).Should(BeTrue())
+		Ω(isLFSPointerDiffLine("+version https://git-lfs.github.com/spec/v1", 1, 3)).Should(BeFalse())
+		Ω(isLFSPointerDiffLine(" version https://git-lfs.github.com/spec/v1", 5, 5)).Should(BeFalse())
+		Ω(isLFSPointerDiffLine("+version https://git-lfs.github.com/spec/v1", 0, 0)).Should(BeFalse())
+		Ω(isLFSPointerDiffLine("+oid sha256:4d7a2146", 1, 1)).Should(BeFalse())
+	})
+
+	It("should handle the changed pointer files as binaries in the diff", func() {
+		diff := `diff --git a/file.bin b/file.bin
+index 1111111..2222222 100644
+--- a/file.bin
++++ b/file.bin
+@@ -1,3 +1,3 @@
+ version https://git-lfs.github.com/spec/v1
+-oid sha256:1111111111111111111111111111111111111111111111111111111111111111
++oid sha256:2222222222222222222222222222222222222222222222222222222222222222
+ size 12345
+diff --git a/README.md b/README.md
+index 3333333..4444444 100644
+--- a/README.md
++++ b/README.md
+@@ -4,2 +4,3 @@ LFS pointer example:
+ 
++version https://git-lfs.github.com/spec/v1
+ end
+diff --git a/new.bin b/new.bin
+new file mode 100644
+index 0000000..5555555
+--- /dev/null
++++ b/new.bin
+@@ -0,0 +1,3 @@
++version https://git-lfs.github.com/spec/v1
++oid sha256:5555555555555555555555555555555555555555555555555555555555555555
++size 1
+`
+
+		p := makeDiffParser(&bytes.Buffer{}, "", path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{}), nil)
+		Ω(p.HandleStdout([]byte(diff))).Should(Succeed())
+		Ω(p.BinaryPaths).Should(Equal([]string{"file.bin", "new.bin"}))
+	})
+
+	Context("when the repository has the LFS tracked files", func() {
+		var sourceWorkTreeDir string
+		var headCommit string
+
+		BeforeEach(func() {
+			sourceWorkTreeDir = filepath.Join(SuiteData.TestDirPath, "source")
+			utils.MkdirAll(filepath.Join(sourceWorkTreeDir, "dir"))
+
+			utils.RunSucceedCommand(sourceWorkTreeDir, "git", "init")
+			utils.WriteFile(filepath.Join(sourceWorkTreeDir, ".gitattributes"), []byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"))
+			utils.WriteFile(filepath.Join(sourceWorkTreeDir, "dir", "file.bin"), []byte(lfsPointerContent))
+			utils.WriteFile(filepath.Join(sourceWorkTreeDir, "dir", "file.txt"), []byte(lfsPointerContent))
+			utils.RunSucceedCommand(sourceWorkTreeDir, "git", "add", "-A")
+			utils.RunSucceedCommand(sourceWorkTreeDir, "git", "commit", "-m", "Initial commit")
+			headCommit = utils.GetHeadCommit(sourceWorkTreeDir)
+
+			Ω(werf.Init("", "", "")).Should(Succeed())
+			Ω(Init(Options{})).Should(Succeed())
+		})
+
+		It("should detect the LFS tracked paths by the .gitattributes", func() {
+			repository, err := GitOpenWithCustomWorktreeDir(filepath.Join(sourceWorkTreeDir, ".git"), sourceWorkTreeDir)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			paths, err := getLFSTrackedPaths(context.Background(), repository, sourceWorkTreeDir, headCommit, []string{"dir/file.bin", "dir/file.txt"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(paths).Should(Equal([]string{"dir/file.bin"}))
+		})
+
+		It("should fail to archive the LFS pointer instead of the content", func() {
+			err := Archive(context.Background(), &bytes.Buffer{}, filepath.Join(sourceWorkTreeDir, ".git"), filepath.Join(SuiteData.TestDirPath, "work_tree"), ArchiveOptions{
+				Commit:      headCommit,
+				PathMatcher: path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{}),
+			})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("dir/file.bin"))
+		})
+	})
+})
@@ -329,7 +329,13 @@ func lsTreeSubmoduleEntryMatch(ctx context.Context, repoHandle repo_handle.Handl
 
 			submodulePath := filepath.ToSlash(submoduleFilepath)
 			submoduleHandle, err := repoHandle.Submodule(submodulePath)
-			if err != nil {
+			if err == repo_handle.ErrSubmoduleNotInitialized {
+				if debug() {
+					logboek.Context(ctx).Debug().LogLn("Submodule entry is not initialized:", lsTreeEntry.FullFilepath)
+				}
+
+				return nil
+			} else if err != nil {
 				return err
 			}
 
@@ -31,17 +31,22 @@ func (opts PatchOptions) ID() string {
 		renamedNewFileNames = append(renamedNewFileNames, renamedNewFileName)
 	}
 
-	return util.Sha256Hash(
-		append(
-			append(renamedOldFilePaths, renamedNewFileNames...),
-			opts.FromCommit,
-			opts.ToCommit,
-			opts.PathScope,
-			opts.PathMatcher.ID(),
-			fmt.Sprint(opts.WithBinary),
-			fmt.Sprint(opts.WithEntireFileContext),
-		)...
+	args := append(
+		append(renamedOldFilePaths, renamedNewFileNames...),
+		opts.FromCommit,
+		opts.ToCommit,
+		opts.PathScope,
+		opts.PathMatcher.ID(),
+		fmt.Sprint(opts.WithBinary),
+		fmt.Sprint(opts.WithEntireFileContext),
 	)
+
+	// the work trees content depends on the submodules depth
+	if submodulesOptionsID := SubmodulesOptionsID(); submodulesOptionsID != "" {
+		args = append(args, submodulesOptionsID)
+	}
+
+	return util.Sha256Hash(args...)
 }
 
 type PatchDescriptor struct {
@@ -112,6 +117,12 @@ func writePatch(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir str
 			return nil, fmt.Errorf("cannot prepare work tree in cache %s for commit %s: %s", workTreeCacheDir, opts.ToCommit, err)
 		}
 
+		if shallowSubmodules {
+			if err := fetchShallowSubmodulesCommits(ctx, workTreeDir, opts.FromCommit, opts.ToCommit); err != nil {
+				return nil, fmt.Errorf("unable to fetch submodules commits: %s", err)
+			}
+		}
+
 		gitArgs := append(commonGitOpts, "-C", workTreeDir)
 		gitArgs = append(gitArgs, "diff")
 		gitArgs = append(gitArgs, diffOpts...)
@@ -130,6 +141,15 @@ func writePatch(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir str
 		gitArgs = append(gitArgs, diffOpts...)
 		gitArgs = append(gitArgs, opts.FromCommit, opts.ToCommit)
 
+		// Limiting the diff by the path scope prevents fetching the unneeded blobs for the partial clone
+		if pathScope := strings.Trim(filepath.ToSlash(opts.PathScope), "/"); pathScope != "" && pathScope != "." && len(opts.FileRenames) == 0 {
+			if isPartialClone, err := IsPartialClone(ctx, gitDir); err != nil {
+				return nil, err
+			} else if isPartialClone {
+				gitArgs = append(gitArgs, "--", fmt.Sprintf(":(top,literal)%s", pathScope))
+			}
+		}
+
 		if debugPatch() {
 			fmt.Printf("# git %s\n", strings.Join(gitArgs, " "))
 		}
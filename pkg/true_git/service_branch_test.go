@@ -40,7 +40,7 @@ var _ = Describe("SyncSourceWorktreeWithServiceBranch", func() {
 
 		headCommit = utils.GetHeadCommit(sourceWorkTreeDir)
 
-		Ω(werf.Init("", "")).Should(Succeed())
+		Ω(werf.Init("", "", "")).Should(Succeed())
 		Ω(Init(Options{})).Should(Succeed())
 	})
 
@@ -0,0 +1,124 @@
+package true_git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+type CloneBareOptions struct {
+	// ReferenceIfAble is the repository to borrow objects from using the git alternates mechanism, ignored when it does not exist
+	ReferenceIfAble string
+	// Filter is the partial clone filter spec, e.g. blob:none
+	Filter string
+}
+
+// CloneBare clones the repository into the bare repository with the same refs layout as the go-git bare clone (refs/remotes/origin/*).
+func CloneBare(ctx context.Context, url, path string, opts CloneBareOptions) error {
+	args := []string{"clone", "--bare", "--quiet"}
+	if opts.ReferenceIfAble != "" {
+		args = append(args, "--reference-if-able", opts.ReferenceIfAble)
+	}
+	if opts.Filter != "" {
+		args = append(args, "--filter", opts.Filter)
+	}
+	args = append(args, url, path)
+
+	if _, err := runGitCmd(ctx, args, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git clone failed: %s", err)
+	}
+
+	if _, err := runGitCmd(ctx, []string{"--git-dir", path, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"}, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git config failed: %s", err)
+	}
+
+	if _, err := runGitCmd(ctx, []string{"--git-dir", path, "fetch", "--quiet", "--tags", "origin"}, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git fetch failed: %s", err)
+	}
+
+	return nil
+}
+
+// IsPartialClone checks whether the repository is the partial clone, which fetches the missing objects on demand.
+func IsPartialClone(ctx context.Context, gitDir string) (bool, error) {
+	// The promisor remote is set by the newer git versions, the older ones set the extensions.partialClone option
+	output, err := runGitCmd(ctx, []string{"--git-dir", gitDir, "config", "--get-regexp", `^(extensions\.partialclone|remote\..*\.promisor)$`}, "", runGitCmdOptions{})
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("git config failed: %s", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[1] != "false" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func InitBare(ctx context.Context, path string) error {
+	if _, err := runGitCmd(ctx, []string{"init", "--bare", "--quiet", path}, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git init failed: %s", err)
+	}
+	return nil
+}
+
+// FetchAllRefs fetches all refs of the source repository into the refs namespace of the repository, the refs removed in the source repository are pruned.
+func FetchAllRefs(ctx context.Context, gitDir, sourceGitDir, refsNamespace string) error {
+	if _, err := runGitCmd(ctx, []string{"--git-dir", gitDir, "fetch", "--quiet", "--no-tags", "--prune", "--force", sourceGitDir, fmt.Sprintf("+refs/*:%s/*", refsNamespace)}, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git fetch failed: %s", err)
+	}
+	return nil
+}
+
+func ListRefs(ctx context.Context, gitDir, refsPrefix string) ([]string, error) {
+	output, err := runGitCmd(ctx, []string{"--git-dir", gitDir, "for-each-ref", "--format=%(refname)", refsPrefix}, "", runGitCmdOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %s", err)
+	}
+
+	var res []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line != "" {
+			res = append(res, line)
+		}
+	}
+
+	return res, nil
+}
+
+func DeleteRefs(ctx context.Context, gitDir string, refs []string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	stdin := &bytes.Buffer{}
+	for _, ref := range refs {
+		fmt.Fprintf(stdin, "delete %s\n", ref)
+	}
+
+	if _, err := runGitCmd(ctx, []string{"--git-dir", gitDir, "update-ref", "--stdin"}, "", runGitCmdOptions{stdin: stdin}); err != nil {
+		return fmt.Errorf("git update-ref failed: %s", err)
+	}
+
+	return nil
+}
+
+// RepackLocal repacks the repository objects, the objects borrowed from the alternates are removed from the repository.
+func RepackLocal(ctx context.Context, gitDir string) error {
+	if _, err := runGitCmd(ctx, []string{"--git-dir", gitDir, "repack", "-a", "-d", "-l", "-q"}, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git repack failed: %s", err)
+	}
+	return nil
+}
+
+func GC(ctx context.Context, gitDir string) error {
+	if _, err := runGitCmd(ctx, []string{"--git-dir", gitDir, "gc", "--prune=now", "--quiet"}, "", runGitCmdOptions{}); err != nil {
+		return fmt.Errorf("git gc failed: %s", err)
+	}
+	return nil
+}
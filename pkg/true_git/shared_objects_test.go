@@ -0,0 +1,68 @@
+package true_git
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/integration/pkg/utils"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var _ = Describe("shared objects", func() {
+	var sourceWorkTreeDir string
+	var poolDir string
+	var headCommit string
+
+	BeforeEach(func() {
+		sourceWorkTreeDir = filepath.Join(SuiteData.TestDirPath, "source")
+		utils.MkdirAll(sourceWorkTreeDir)
+		poolDir = filepath.Join(SuiteData.TestDirPath, "pool")
+
+		utils.RunSucceedCommand(sourceWorkTreeDir, "git", "init")
+		utils.WriteFile(filepath.Join(sourceWorkTreeDir, "file"), []byte("content"))
+		utils.RunSucceedCommand(sourceWorkTreeDir, "git", "add", "file")
+		utils.RunSucceedCommand(sourceWorkTreeDir, "git", "commit", "-m", "Initial commit")
+		headCommit = utils.GetHeadCommit(sourceWorkTreeDir)
+
+		Ω(werf.Init("", "", "")).Should(Succeed())
+		Ω(Init(Options{})).Should(Succeed())
+	})
+
+	It("should clone with the go-git refs layout and borrow objects from the pool", func() {
+		ctx := context.Background()
+
+		firstCloneDir := filepath.Join(SuiteData.TestDirPath, "first")
+		Ω(CloneBare(ctx, sourceWorkTreeDir, firstCloneDir, CloneBareOptions{ReferenceIfAble: poolDir})).Should(Succeed())
+
+		refs, err := ListRefs(ctx, firstCloneDir, "refs/remotes/origin")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(refs).ShouldNot(BeEmpty())
+
+		Ω(InitBare(ctx, poolDir)).Should(Succeed())
+		Ω(FetchAllRefs(ctx, poolDir, firstCloneDir, "refs/werf-clones/first")).Should(Succeed())
+
+		refs, err = ListRefs(ctx, poolDir, "refs/werf-clones/first")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(strings.HasPrefix(refs[0], "refs/werf-clones/first/")).Should(BeTrue())
+
+		secondCloneDir := filepath.Join(SuiteData.TestDirPath, "second")
+		Ω(CloneBare(ctx, sourceWorkTreeDir, secondCloneDir, CloneBareOptions{ReferenceIfAble: poolDir})).Should(Succeed())
+
+		alternates, err := ioutil.ReadFile(filepath.Join(secondCloneDir, "objects", "info", "alternates"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(strings.TrimSpace(string(alternates))).Should(Equal(filepath.Join(poolDir, "objects")))
+
+		Ω(RepackLocal(ctx, secondCloneDir)).Should(Succeed())
+		utils.RunSucceedCommand(secondCloneDir, "git", "cat-file", "-e", headCommit)
+
+		Ω(DeleteRefs(ctx, poolDir, refs)).Should(Succeed())
+		refs, err = ListRefs(ctx, poolDir, "refs/werf-clones")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(refs).Should(BeEmpty())
+	})
+})
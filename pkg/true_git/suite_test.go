@@ -1,12 +1,13 @@
 package true_git
 
 import (
+	"os"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/werf/werf/integration/pkg/suite_init"
+	"github.com/werf/werf/integration/pkg/utils"
 )
 
 func TestSuite(t *testing.T) {
@@ -14,7 +15,46 @@ func TestSuite(t *testing.T) {
 	RunSpecs(t, "True Git Suite")
 }
 
-var (
-	SuiteData suite_init.SuiteData
-	_         = SuiteData.SetupTmp(suite_init.NewTmpDirData())
-)
+// SuiteData does not use the integration suite_init package, which requires the docker server.
+var SuiteData struct {
+	TestDirPath string
+}
+
+// savedGitEnv keeps the values of the git env vars overridden by the suite: nil for the unset var.
+var savedGitEnv = map[string]*string{}
+
+var _ = BeforeSuite(func() {
+	// the test repos commits do not depend on the git config of the host
+	for name, value := range map[string]string{
+		"GIT_AUTHOR_NAME":     "werf",
+		"GIT_AUTHOR_EMAIL":    "werf@example.com",
+		"GIT_COMMITTER_NAME":  "werf",
+		"GIT_COMMITTER_EMAIL": "werf@example.com",
+	} {
+		if oldValue, isSet := os.LookupEnv(name); isSet {
+			savedGitEnv[name] = &oldValue
+		} else {
+			savedGitEnv[name] = nil
+		}
+
+		Ω(os.Setenv(name, value)).Should(Succeed())
+	}
+})
+
+var _ = AfterSuite(func() {
+	for name, oldValue := range savedGitEnv {
+		if oldValue != nil {
+			Ω(os.Setenv(name, *oldValue)).Should(Succeed())
+		} else {
+			Ω(os.Unsetenv(name)).Should(Succeed())
+		}
+	}
+})
+
+var _ = BeforeEach(func() {
+	SuiteData.TestDirPath = utils.GetTempDir()
+})
+
+var _ = AfterEach(func() {
+	Ω(os.RemoveAll(SuiteData.TestDirPath)).Should(Succeed())
+})
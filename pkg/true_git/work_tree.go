@@ -21,6 +21,8 @@ import (
 
 type WithWorkTreeOptions struct {
 	HasSubmodules bool
+	// SparseCheckoutPatterns limits the paths checked out into the new work tree, all paths are checked out by default
+	SparseCheckoutPatterns []string
 }
 
 func WithWorkTree(ctx context.Context, gitDir, workTreeCacheDir string, commit string, opts WithWorkTreeOptions, f func(workTreeDir string) error) error {
@@ -37,6 +39,12 @@ func WithWorkTree(ctx context.Context, gitDir, workTreeCacheDir string, commit s
 			return fmt.Errorf("bad work tree cache dir %s: %s", workTreeCacheDir, err)
 		}
 
+		if len(opts.SparseCheckoutPatterns) > 0 {
+			if err := SetWorkTreeSparseCheckoutPatterns(workTreeCacheDir, opts.SparseCheckoutPatterns); err != nil {
+				return err
+			}
+		}
+
 		workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, commit, opts.HasSubmodules)
 		if err != nil {
 			return fmt.Errorf("cannot prepare worktree: %s", err)
@@ -46,6 +54,39 @@ func WithWorkTree(ctx context.Context, gitDir, workTreeCacheDir string, commit s
 	})
 }
 
+// SetWorkTreeSparseCheckoutPatterns saves the sparse checkout patterns used to create the work tree in the work tree cache dir.
+// The patterns of the existing work tree cannot be changed, so the work tree cache dir should be specific to the patterns.
+func SetWorkTreeSparseCheckoutPatterns(workTreeCacheDir string, patterns []string) error {
+	path := filepath.Join(workTreeCacheDir, "sparse_checkout")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("unable to access %s: %s", path, err)
+	}
+
+	if err := os.MkdirAll(workTreeCacheDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", workTreeCacheDir, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(strings.Join(patterns, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func getWorkTreeSparseCheckoutPatterns(workTreeCacheDir string) ([]byte, error) {
+	path := filepath.Join(workTreeCacheDir, "sparse_checkout")
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", path, err)
+	}
+
+	return data, nil
+}
+
 func withWorkTreeCacheLock(ctx context.Context, workTreeCacheDir string, f func() error) error {
 	lockName := fmt.Sprintf("git_work_tree_cache %s", workTreeCacheDir)
 	return werf.WithHostLock(ctx, lockName, lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
@@ -99,6 +140,24 @@ func prepareWorkTree(ctx context.Context, repoDir, workTreeCacheDir string, comm
 		return "", fmt.Errorf("unable to access %s: %s", currentCommitPath, err)
 	}
 
+	if isWorkTreeDirExist && withSubmodules {
+		if changed, err := isWorkTreeSubmodulesOptionsChanged(workTreeCacheDir); err != nil {
+			return "", err
+		} else if changed {
+			logboek.Context(ctx).Info().LogFDetails("Removing work tree dir %s of repo %s prepared with other submodules options\n", workTreeDir, repoDir)
+
+			if err := os.RemoveAll(currentCommitPath); err != nil {
+				return "", fmt.Errorf("unable to remove %s: %s", currentCommitPath, err)
+			}
+			currentCommitPathExists = false
+
+			if err := os.RemoveAll(workTreeDir); err != nil {
+				return "", fmt.Errorf("unable to remove invalidated work tree dir %s: %s", workTreeDir, err)
+			}
+			isWorkTreeDirExist = false
+		}
+	}
+
 	if isWorkTreeDirExist && !isWorkTreeRegistered {
 		logboek.Context(ctx).Info().LogFDetails("Removing unregistered work tree dir %s of repo %s\n", workTreeDir, repoDir)
 
@@ -136,11 +195,22 @@ func prepareWorkTree(ctx context.Context, repoDir, workTreeCacheDir string, comm
 		if currentCommit != "" {
 			logboek.Context(ctx).Info().LogFDetails("Current commit: %s\n", currentCommit)
 		}
-		return switchWorkTree(ctx, repoDir, workTreeDir, commit, withSubmodules)
+		sparseCheckoutPatterns, err := getWorkTreeSparseCheckoutPatterns(workTreeCacheDir)
+		if err != nil {
+			return err
+		}
+
+		return switchWorkTree(ctx, repoDir, workTreeDir, commit, withSubmodules, sparseCheckoutPatterns)
 	}); err != nil {
 		return "", fmt.Errorf("unable to switch work tree %s to commit %s: %s", workTreeDir, commit, err)
 	}
 
+	if withSubmodules {
+		if err := writeWorkTreeSubmodulesOptions(workTreeCacheDir); err != nil {
+			return "", err
+		}
+	}
+
 	if err := ioutil.WriteFile(currentCommitPath, []byte(commit+"\n"), 0644); err != nil {
 		return "", fmt.Errorf("error writing %s: %s", currentCommitPath, err)
 	}
@@ -148,19 +218,58 @@ func prepareWorkTree(ctx context.Context, repoDir, workTreeCacheDir string, comm
 	return workTreeDir, nil
 }
 
+// setupSparseCheckout writes the sparse checkout patterns of the work tree, other work trees of the repository are not affected.
+func setupSparseCheckout(ctx context.Context, workTreeDir string, patterns []byte) error {
+	output, err := runGitCmd(ctx, []string{"rev-parse", "--git-path", "info/sparse-checkout"}, workTreeDir, runGitCmdOptions{})
+	if err != nil {
+		return fmt.Errorf("git rev-parse failed: %s", err)
+	}
+
+	sparseCheckoutPath := strings.TrimSpace(output.String())
+	if !filepath.IsAbs(sparseCheckoutPath) {
+		sparseCheckoutPath = filepath.Join(workTreeDir, sparseCheckoutPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sparseCheckoutPath), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(sparseCheckoutPath), err)
+	}
+
+	if err := ioutil.WriteFile(sparseCheckoutPath, patterns, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %s", sparseCheckoutPath, err)
+	}
+
+	return nil
+}
+
+// getSwitchWorkTreeGitOptions enables the sparse checkout only for the git commands switching the work tree,
+// the core.sparseCheckout option is not set in the repository config shared by all work trees of the repository.
+func getSwitchWorkTreeGitOptions(sparseCheckoutPatterns []byte) []string {
+	opts := getCommonGitOptions()
+	if len(sparseCheckoutPatterns) > 0 {
+		opts = append(opts, "-c", "core.sparseCheckout=true")
+	}
+	return opts
+}
+
 func debugWorktreeSwitch() bool {
 	return os.Getenv("WERF_TRUE_GIT_DEBUG_WORKTREE_SWITCH") == "1"
 }
 
-func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit string, withSubmodules bool) error {
+func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit string, withSubmodules bool, sparseCheckoutPatterns []byte) error {
 	var err error
 	var cmd *exec.Cmd
 	var output *bytes.Buffer
 
 	if _, err := os.Stat(workTreeDir); os.IsNotExist(err) {
+		worktreeAddArgs := []string{"worktree", "add", "--force", "--detach"}
+		if len(sparseCheckoutPatterns) > 0 {
+			// The files are checked out by the following git reset according to the sparse checkout patterns
+			worktreeAddArgs = append(worktreeAddArgs, "--no-checkout")
+		}
+		worktreeAddArgs = append(worktreeAddArgs, workTreeDir, commit)
+
 		cmd = exec.Command(
-			"git", append(getCommonGitOptions(), "-C", repoDir,
-				"worktree", "add", "--force", "--detach", workTreeDir, commit)...,
+			"git", append(append(getCommonGitOptions(), "-C", repoDir), worktreeAddArgs...)...,
 		)
 		output = SetCommandRecordingLiveOutput(ctx, cmd)
 		if debugWorktreeSwitch() {
@@ -170,10 +279,16 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		if err != nil {
 			return fmt.Errorf("git worktree add failed: %s\n%s", err, output.String())
 		}
+
+		if len(sparseCheckoutPatterns) > 0 {
+			if err := setupSparseCheckout(ctx, workTreeDir, sparseCheckoutPatterns); err != nil {
+				return fmt.Errorf("unable to setup sparse checkout: %s", err)
+			}
+		}
 	} else if err != nil {
 		return fmt.Errorf("error accessing %s: %s", workTreeDir, err)
 	} else {
-		cmd = exec.Command("git", append(getCommonGitOptions(), "checkout", "--force", "--detach", commit)...)
+		cmd = exec.Command("git", append(getSwitchWorkTreeGitOptions(sparseCheckoutPatterns), "checkout", "--force", "--detach", commit)...)
 		cmd.Dir = workTreeDir
 		output = SetCommandRecordingLiveOutput(ctx, cmd)
 		if debugWorktreeSwitch() {
@@ -185,7 +300,7 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		}
 	}
 
-	cmd = exec.Command("git", append(getCommonGitOptions(), "reset", "--hard", commit)...)
+	cmd = exec.Command("git", append(getSwitchWorkTreeGitOptions(sparseCheckoutPatterns), "reset", "--hard", commit)...)
 	cmd.Dir = workTreeDir
 	output = SetCommandRecordingLiveOutput(ctx, cmd)
 	if debugWorktreeSwitch() {
@@ -0,0 +1,197 @@
+// Package fswatcher waits for the changes of the files in the watched directories using the file system notifications.
+package fswatcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+type Options struct {
+	// SkipDirs are the names of the subdirectories of the trees, which are not watched (e.g. ".git").
+	SkipDirs []string
+	// Gitignore enables skipping the subdirectories of the trees ignored by the .gitignore files.
+	Gitignore bool
+}
+
+// Watcher watches the directories and the trees of the directories.
+// The subdirectories created in the watched trees are watched as well.
+type Watcher struct {
+	fsWatcher *fsnotify.Watcher
+	opts      Options
+
+	trees map[string]*treeDir
+}
+
+// treeDir is the watched directory of the tree with the .gitignore patterns inherited from the parent directories.
+type treeDir struct {
+	root     string
+	patterns []gitignore.Pattern
+}
+
+func New(opts Options) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file system watcher: %s", err)
+	}
+
+	return &Watcher{fsWatcher: fsWatcher, opts: opts, trees: map[string]*treeDir{}}, nil
+}
+
+// AddDir watches the files of the directory without the subdirectories.
+func (w *Watcher) AddDir(dir string) error {
+	if err := w.fsWatcher.Add(dir); err != nil {
+		return fmt.Errorf("unable to watch %s: %s", dir, err)
+	}
+
+	return nil
+}
+
+// AddTree watches the directory and all its subdirectories except the skipped ones.
+func (w *Watcher) AddTree(dir string) error {
+	return w.addTreeDir(dir, &treeDir{root: dir})
+}
+
+func (w *Watcher) addTreeDir(dir string, parent *treeDir) error {
+	patterns, err := readGitignorePatterns(parent.root, dir)
+	if err != nil {
+		return err
+	}
+
+	td := &treeDir{root: parent.root, patterns: append(append([]gitignore.Pattern{}, parent.patterns...), patterns...)}
+	if err := w.AddDir(dir); err != nil {
+		return err
+	}
+	w.trees[dir] = td
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read dir %s: %s", dir, err)
+	}
+
+	for _, info := range infos {
+		subDir := filepath.Join(dir, info.Name())
+		if !info.IsDir() || w.isSkippedDir(td, subDir) {
+			continue
+		}
+
+		if err := w.addTreeDir(subDir, td); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) isSkippedDir(td *treeDir, dir string) bool {
+	for _, name := range w.opts.SkipDirs {
+		if filepath.Base(dir) == name {
+			return true
+		}
+	}
+
+	if !w.opts.Gitignore || len(td.patterns) == 0 {
+		return false
+	}
+
+	return gitignore.NewMatcher(td.patterns).Match(splitRelPath(td.root, dir), true)
+}
+
+// WaitForChanges blocks until the change of the watched files and then until there are no new changes for the debounce duration,
+// so that a series of rapid changes is reported once.
+func (w *Watcher) WaitForChanges(ctx context.Context, debounce time.Duration) error {
+	var debounceTimer <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-debounceTimer:
+			return nil
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return fmt.Errorf("file system watcher is closed")
+			}
+
+			if err := w.handleEvent(event); err != nil {
+				return err
+			}
+
+			debounceTimer = time.After(debounce)
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return fmt.Errorf("file system watcher is closed")
+			}
+
+			// the dropped events are the changes anyway
+			if err == fsnotify.ErrEventOverflow {
+				debounceTimer = time.After(debounce)
+				continue
+			}
+
+			return fmt.Errorf("file system watcher error: %s", err)
+		}
+	}
+}
+
+func (w *Watcher) handleEvent(event fsnotify.Event) error {
+	if event.Op&fsnotify.Create == 0 {
+		return nil
+	}
+
+	parent, ok := w.trees[filepath.Dir(event.Name)]
+	if !ok {
+		return nil
+	}
+
+	info, err := os.Stat(event.Name)
+	if err != nil || !info.IsDir() || w.isSkippedDir(parent, event.Name) {
+		// the created file could be removed before the event is handled
+		return nil
+	}
+
+	return w.addTreeDir(event.Name, parent)
+}
+
+func (w *Watcher) Close() error {
+	return w.fsWatcher.Close()
+}
+
+func readGitignorePatterns(root, dir string) ([]gitignore.Pattern, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", filepath.Join(dir, ".gitignore"), err)
+	}
+
+	domain := splitRelPath(root, dir)
+
+	var patterns []gitignore.Pattern
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+
+	return patterns, nil
+}
+
+func splitRelPath(root, path string) []string {
+	relPath, err := filepath.Rel(root, path)
+	if err != nil || relPath == "." {
+		return nil
+	}
+
+	return strings.Split(filepath.ToSlash(relPath), "/")
+}
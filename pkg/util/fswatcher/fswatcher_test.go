@@ -0,0 +1,124 @@
+package fswatcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T, dir string, opts Options) *Watcher {
+	w, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	if err := w.AddTree(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func waitForChanges(t *testing.T, w *Watcher, debounce time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return w.WaitForChanges(ctx, debounce)
+}
+
+func writeFile(t *testing.T, path, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_WaitForChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "b", "file"), "")
+
+	w := newTestWatcher(t, dir, Options{})
+
+	writeFile(t, filepath.Join(dir, "a", "b", "file"), "changed")
+	if err := waitForChanges(t, w, 10*time.Millisecond); err != nil {
+		t.Fatalf("expected change in the nested dir, got: %s", err)
+	}
+
+	if err := waitForChanges(t, w, 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("expected no changes, got: %v", err)
+	}
+}
+
+func TestWatcher_WaitForChanges_CreatedDir(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWatcher(t, dir, Options{})
+
+	if err := os.Mkdir(filepath.Join(dir, "new"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForChanges(t, w, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "new", "file"), "")
+	if err := waitForChanges(t, w, 10*time.Millisecond); err != nil {
+		t.Fatalf("expected change in the created dir, got: %s", err)
+	}
+}
+
+func TestWatcher_WaitForChanges_Debounce(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWatcher(t, dir, Options{})
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			_ = ioutil.WriteFile(filepath.Join(dir, "file"), []byte(time.Now().String()), 0o644)
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	startedAt := time.Now()
+	if err := waitForChanges(t, w, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(startedAt); elapsed < 180*time.Millisecond {
+		t.Fatalf("expected the series of changes to be reported once after the debounce, returned in %s", elapsed)
+	}
+}
+
+func TestWatcher_SkippedDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".gitignore"), "# comment\n/build\n")
+	writeFile(t, filepath.Join(dir, "sub", ".gitignore"), "cache/\n")
+	writeFile(t, filepath.Join(dir, ".git", "file"), "")
+	writeFile(t, filepath.Join(dir, "build", "file"), "")
+	writeFile(t, filepath.Join(dir, "sub", "cache", "file"), "")
+	writeFile(t, filepath.Join(dir, "sub", "build", "file"), "")
+	writeFile(t, filepath.Join(dir, "cache", "file"), "")
+
+	w := newTestWatcher(t, dir, Options{SkipDirs: []string{".git"}, Gitignore: true})
+
+	var watchedDirs []string
+	for watchedDir := range w.trees {
+		rel, _ := filepath.Rel(dir, watchedDir)
+		watchedDirs = append(watchedDirs, filepath.ToSlash(rel))
+	}
+
+	expected := map[string]bool{".": true, "sub": true, "sub/build": true, "cache": true}
+	if len(watchedDirs) != len(expected) {
+		t.Fatalf("expected watched dirs %v, got %v", expected, watchedDirs)
+	}
+	for _, watchedDir := range watchedDirs {
+		if !expected[watchedDir] {
+			t.Fatalf("unexpected watched dir %q", watchedDir)
+		}
+	}
+}
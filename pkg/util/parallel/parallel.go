@@ -3,8 +3,10 @@ package parallel
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/werf/logboek"
@@ -19,11 +21,28 @@ type DoTasksOptions struct {
 	InitDockerCLIForEachWorker bool
 	MaxNumberOfWorkers         int
 	LiveOutput                 bool
+	// TaskTimeout limits each task duration: the task context is cancelled after the timeout (no limit by default)
+	TaskTimeout time.Duration
+}
+
+// TaskResult is the result of the finished task.
+type TaskResult struct {
+	TaskId int
+	Err    error
 }
 
 func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, taskFunc func(ctx context.Context, taskId int) error) error {
+	_, err := DoTasksWithResults(ctx, numberOfTasks, options, taskFunc)
+	return err
+}
+
+// DoTasksWithResults runs the tasks by the workers and returns the results of the finished tasks.
+// The first failed task or the parent context cancellation stops the processing: the running tasks get the cancelled context
+// and the remaining tasks are not started, so the results may be partial.
+// The function returns only when all started tasks are finished.
+func DoTasksWithResults(ctx context.Context, numberOfTasks int, options DoTasksOptions, taskFunc func(ctx context.Context, taskId int) error) ([]TaskResult, error) {
 	if numberOfTasks == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// determine number of tasks
@@ -49,6 +68,15 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 	workerDoneCh := make(chan *bufWorker)
 	quitCh := make(chan bool)
 
+	// the workers are stopped when the tasks processing is finished or interrupted, the running tasks are awaited
+	var workersWg sync.WaitGroup
+	workersCtx, cancelWorkers := context.WithCancel(ctx)
+	defer func() {
+		cancelWorkers()
+		close(quitCh)
+		workersWg.Wait()
+	}()
+
 	var workers []*bufWorker
 	for i := 0; i < numberOfWorkers; i++ {
 		var workerContext context.Context
@@ -58,27 +86,34 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 		worker := &bufWorker{buf: workerBuf}
 		workers = append(workers, worker)
 
-		ctxWithBackgroundTaskID := context.WithValue(ctx, constant.CtxBackgroundTaskIDKey, workerID)
+		ctxWithBackgroundTaskID := context.WithValue(workersCtx, constant.CtxBackgroundTaskIDKey, workerID)
 		workerContext = logboek.NewContext(ctxWithBackgroundTaskID, logboek.Context(ctx).NewSubLogger(workerBuf, workerBuf))
 		logboek.Context(workerContext).Streams().SetPrefixStyle(style.Highlight())
 
 		if options.InitDockerCLIForEachWorker {
 			workerContextWithDockerCli, err := docker.NewContext(workerContext)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			workerContext = workerContextWithDockerCli
 		}
 
+		workersWg.Add(1)
 		go func() {
+			defer workersWg.Done()
+
 			workerNumberOfTasks := numberOfTasksPerWorker[workerID]
 			for workerTaskId := 0; workerTaskId < workerNumberOfTasks; workerTaskId++ {
+				if workerContext.Err() != nil {
+					break
+				}
+
 				taskId := calculateTaskId(numberOfTasks, numberOfWorkers, workerID, workerTaskId)
 				if debug() {
 					logboek.Context(workerContext).LogF("Running worker %d task %d/%d (%d)\n", workerID, workerTaskId+1, workerNumberOfTasks, numberOfTasks)
 				}
-				err := taskFunc(workerContext, taskId)
+				err := doTask(workerContext, taskId, options.TaskTimeout, taskFunc)
 
 				ch := taskResultDoneCh
 				if err != nil {
@@ -86,7 +121,7 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 				}
 
 				select {
-				case ch <- worker.TaskResult(err):
+				case ch <- worker.TaskResult(taskId, err):
 					if err != nil {
 						return
 					}
@@ -95,28 +130,54 @@ func DoTasks(ctx context.Context, numberOfTasks int, options DoTasksOptions, tas
 				}
 			}
 
-			workerDoneCh <- worker
+			select {
+			case workerDoneCh <- worker:
+			case <-quitCh:
+			}
 		}()
 	}
 
+	var results []TaskResult
 	var err error
 	if options.LiveOutput {
-		err = workersHandlerLiveOutput(ctx, workers, taskResultDoneCh, taskResultFailedCh, quitCh, workerDoneCh)
+		err = workersHandlerLiveOutput(ctx, workers, taskResultDoneCh, taskResultFailedCh, workerDoneCh, &results)
 	} else {
-		err = workersHandlerStandard(ctx, workers, taskResultDoneCh, taskResultFailedCh, quitCh, workerDoneCh)
+		err = workersHandlerStandard(ctx, workers, taskResultDoneCh, taskResultFailedCh, workerDoneCh, &results)
+	}
+
+	// the workers skip the remaining tasks when the parent context is cancelled
+	if err == nil && len(results) < numberOfTasks {
+		err = ctx.Err()
+	}
+
+	return results, err
+}
+
+func doTask(ctx context.Context, taskId int, timeout time.Duration, taskFunc func(ctx context.Context, taskId int) error) error {
+	if timeout <= 0 {
+		return taskFunc(ctx, taskId)
+	}
+
+	taskCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := taskFunc(taskCtx, taskId)
+	if err != nil && ctx.Err() == nil && taskCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("task timed out after %s: %w", timeout, err)
 	}
 
 	return err
 }
 
-func workersHandlerLiveOutput(ctx context.Context, workers []*bufWorker, taskResultDoneCh chan *bufWorkerTaskResult, taskResultFailedCh chan *bufWorkerTaskResult, quitCh chan bool, workerDoneCh chan *bufWorker) error {
+func workersHandlerLiveOutput(ctx context.Context, workers []*bufWorker, taskResultDoneCh chan *bufWorkerTaskResult, taskResultFailedCh chan *bufWorkerTaskResult, workerDoneCh chan *bufWorker, results *[]TaskResult) error {
 workerLoop:
 	for _, currentWorker := range workers {
 		for {
 			select {
-			case <-taskResultDoneCh:
+			case taskResult := <-taskResultDoneCh:
+				*results = append(*results, taskResult.Result())
 			case taskResult := <-taskResultFailedCh:
-				close(quitCh)
+				*results = append(*results, taskResult.Result())
 
 				if taskResult.worker != currentWorker {
 					logboek.Context(ctx).LogLn()
@@ -134,6 +195,8 @@ workerLoop:
 				return taskResult.err
 			case worker := <-workerDoneCh:
 				worker.isDone = true
+			case <-ctx.Done():
+				return flushWorkerBuf(ctx, currentWorker, ctx.Err())
 			default:
 				var n int64
 				var err error
@@ -159,21 +222,12 @@ workerLoop:
 	return nil
 }
 
-func workersHandlerStandard(ctx context.Context, workers []*bufWorker, taskResultDoneCh chan *bufWorkerTaskResult, taskResultFailedCh chan *bufWorkerTaskResult, quitCh chan bool, workerDoneCh chan *bufWorker) error {
+func workersHandlerStandard(ctx context.Context, workers []*bufWorker, taskResultDoneCh chan *bufWorkerTaskResult, taskResultFailedCh chan *bufWorkerTaskResult, workerDoneCh chan *bufWorker, results *[]TaskResult) error {
 	var workerDoneCounter int
 	for {
 		select {
 		case taskResult := <-taskResultDoneCh:
-			if err := logboek.Context(ctx).Streams().DoErrorWithoutIndent(func() error {
-				_, err := io.Copy(logboek.Context(ctx).OutStream(), taskResult.worker.buf)
-				return err
-			}); err != nil {
-				return err
-			}
-
-			logboek.Context(ctx).LogOptionalLn()
-		case taskResult := <-taskResultFailedCh:
-			close(quitCh)
+			*results = append(*results, taskResult.Result())
 
 			if err := logboek.Context(ctx).Streams().DoErrorWithoutIndent(func() error {
 				_, err := io.Copy(logboek.Context(ctx).OutStream(), taskResult.worker.buf)
@@ -183,17 +237,35 @@ func workersHandlerStandard(ctx context.Context, workers []*bufWorker, taskResul
 			}
 
 			logboek.Context(ctx).LogOptionalLn()
+		case taskResult := <-taskResultFailedCh:
+			*results = append(*results, taskResult.Result())
 
-			return taskResult.err
+			return flushWorkerBuf(ctx, taskResult.worker, taskResult.err)
 		case <-workerDoneCh:
 			workerDoneCounter++
 			if workerDoneCounter == len(workers) {
 				return nil
 			}
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
 
+// flushWorkerBuf prints the output of the interrupted worker and returns the interruption error.
+func flushWorkerBuf(ctx context.Context, worker *bufWorker, err error) error {
+	if err := logboek.Context(ctx).Streams().DoErrorWithoutIndent(func() error {
+		_, err := io.Copy(logboek.Context(ctx).OutStream(), worker.buf)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).LogOptionalLn()
+
+	return err
+}
+
 func calculateTaskId(tasksNumber, workersNumber, workerInd, workerTaskId int) int {
 	taskId := workerInd*(tasksNumber/workersNumber) + workerTaskId
 
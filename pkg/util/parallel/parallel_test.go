@@ -0,0 +1,121 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/werf/logboek"
+)
+
+func newTestContext() context.Context {
+	return logboek.NewContext(context.Background(), logboek.DefaultLogger())
+}
+
+func TestDoTasksWithResults(t *testing.T) {
+	for _, liveOutput := range []bool{false, true} {
+		results, err := DoTasksWithResults(newTestContext(), 10, DoTasksOptions{MaxNumberOfWorkers: 3, LiveOutput: liveOutput}, func(ctx context.Context, taskId int) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var taskIds []int
+		for _, result := range results {
+			taskIds = append(taskIds, result.TaskId)
+		}
+		sort.Ints(taskIds)
+
+		if len(taskIds) != 10 {
+			t.Fatalf("expected 10 results, got %v", taskIds)
+		}
+		for i, taskId := range taskIds {
+			if taskId != i {
+				t.Fatalf("expected each task to be done once, got %v", taskIds)
+			}
+		}
+	}
+}
+
+func TestDoTasksWithResultsWaitsForRunningTasks(t *testing.T) {
+	taskErr := errors.New("task failed")
+
+	for _, liveOutput := range []bool{false, true} {
+		var runningTasks int32
+		_, err := DoTasksWithResults(newTestContext(), 4, DoTasksOptions{LiveOutput: liveOutput}, func(ctx context.Context, taskId int) error {
+			atomic.AddInt32(&runningTasks, 1)
+			defer atomic.AddInt32(&runningTasks, -1)
+
+			if taskId == 0 {
+				return taskErr
+			}
+
+			<-ctx.Done()
+			time.Sleep(50 * time.Millisecond)
+
+			return ctx.Err()
+		})
+
+		if !errors.Is(err, taskErr) {
+			t.Fatalf("expected the task error, got %v", err)
+		}
+
+		if n := atomic.LoadInt32(&runningTasks); n != 0 {
+			t.Fatalf("expected no running tasks after return, got %d", n)
+		}
+	}
+}
+
+func TestDoTasksWithResultsTaskTimeout(t *testing.T) {
+	_, err := DoTasksWithResults(newTestContext(), 1, DoTasksOptions{TaskTimeout: 10 * time.Millisecond}, func(ctx context.Context, taskId int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline exceeded error, got %v", err)
+	}
+}
+
+func TestDoTasksWithResultsParentContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(newTestContext())
+	cancel()
+
+	results, err := DoTasksWithResults(ctx, 5, DoTasksOptions{MaxNumberOfWorkers: 1}, func(ctx context.Context, taskId int) error {
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation error, got %v", err)
+	}
+
+	if len(results) == 5 {
+		t.Fatalf("expected the remaining tasks to be skipped")
+	}
+}
+
+func TestCalculateTaskId(t *testing.T) {
+	for _, tc := range []struct{ tasks, workers int }{{10, 3}, {7, 7}, {5, 2}, {1, 1}} {
+		seen := map[int]bool{}
+		for workerInd := 0; workerInd < tc.workers; workerInd++ {
+			workerNumberOfTasks := tc.tasks / tc.workers
+			if tc.tasks%tc.workers > workerInd {
+				workerNumberOfTasks++
+			}
+
+			for workerTaskId := 0; workerTaskId < workerNumberOfTasks; workerTaskId++ {
+				seen[calculateTaskId(tc.tasks, tc.workers, workerInd, workerTaskId)] = true
+			}
+		}
+
+		for taskId := 0; taskId < tc.tasks; taskId++ {
+			if !seen[taskId] {
+				t.Errorf("%d tasks by %d workers: task %d is not distributed", tc.tasks, tc.workers, taskId)
+			}
+		}
+	}
+}
@@ -7,14 +7,20 @@ type bufWorker struct {
 	isDone bool
 }
 
-func (w *bufWorker) TaskResult(err error) *bufWorkerTaskResult {
+func (w *bufWorker) TaskResult(taskId int, err error) *bufWorkerTaskResult {
 	return &bufWorkerTaskResult{
 		worker: w,
+		taskId: taskId,
 		err:    err,
 	}
 }
 
 type bufWorkerTaskResult struct {
 	worker *bufWorker
+	taskId int
 	err    error
 }
+
+func (r *bufWorkerTaskResult) Result() TaskResult {
+	return TaskResult{TaskId: r.taskId, Err: r.err}
+}
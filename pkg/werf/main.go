@@ -6,12 +6,15 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 
 	"github.com/werf/lockgate/pkg/file_lock"
 	"github.com/werf/lockgate/pkg/file_locker"
 	"github.com/werf/logboek"
 
 	"github.com/werf/lockgate"
+
+	"github.com/werf/werf/pkg/slug"
 )
 
 var (
@@ -23,8 +26,13 @@ var (
 	sharedContextDir string
 	localCacheDir    string
 	serviceDir       string
+	// globalServiceDir is the service dir of the werf home without the home isolation key
+	globalServiceDir string
 
-	hostLocker lockgate.Locker
+	hostLocker       lockgate.Locker
+	globalHostLocker lockgate.Locker
+	hostLockerMutex  sync.Mutex
+	readOnly         bool
 )
 
 func GetSharedContextDir() string {
@@ -51,6 +59,20 @@ func GetServiceDir() string {
 	return serviceDir
 }
 
+// GetHostLocksDir returns the dir of the host locks of the werf home, the locks are isolated by the home isolation key.
+func GetHostLocksDir() string {
+	return filepath.Join(GetServiceDir(), "locks")
+}
+
+// GetGlobalHostLocksDir returns the dir of the global host locks, which are shared by the werf processes with any home isolation key.
+func GetGlobalHostLocksDir() string {
+	if globalServiceDir == "" {
+		panic("bug: init required!")
+	}
+
+	return filepath.Join(globalServiceDir, "locks")
+}
+
 func GetHomeDir() string {
 	if homeDir == "" {
 		panic("bug: init required!")
@@ -71,8 +93,66 @@ func GetStagesStorageCacheDir() string {
 	return filepath.Join(GetSharedContextDir(), "storage", "stages_storage_cache", "1")
 }
 
+// IsReadOnly returns true if werf is initialized by InitReadOnly and should not write into the werf home dir without a need.
+func IsReadOnly() bool {
+	hostLockerMutex.Lock()
+	defer hostLockerMutex.Unlock()
+
+	return readOnly
+}
+
 func GetHostLocker() lockgate.Locker {
-	return hostLocker
+	locker, err := getHostLocker()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return locker
+}
+
+// GetGlobalHostLocker returns the locker of the host resources shared by all werf homes regardless of the home isolation key:
+// the images and the containers of the local docker server and the stages of the local stages storage.
+func GetGlobalHostLocker() lockgate.Locker {
+	locker, err := getGlobalHostLocker()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return locker
+}
+
+func getHostLocker() (lockgate.Locker, error) {
+	if err := initHostLockersOnDemand(); err != nil {
+		return nil, err
+	}
+
+	return hostLocker, nil
+}
+
+func getGlobalHostLocker() (lockgate.Locker, error) {
+	if err := initHostLockersOnDemand(); err != nil {
+		return nil, err
+	}
+
+	return globalHostLocker, nil
+}
+
+func initHostLockersOnDemand() error {
+	hostLockerMutex.Lock()
+	defer hostLockerMutex.Unlock()
+
+	if hostLocker == nil {
+		if !readOnly {
+			panic("bug: init required!")
+		}
+
+		// the locks dir is created only when the first host lock is required (see InitReadOnly)
+		if err := initHostLockers(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func SetupLockerDefaultOptions(ctx context.Context, opts lockgate.AcquireOptions) lockgate.AcquireOptions {
@@ -86,19 +166,53 @@ func SetupLockerDefaultOptions(ctx context.Context, opts lockgate.AcquireOptions
 }
 
 func WithHostLock(ctx context.Context, lockName string, opts lockgate.AcquireOptions, f func() error) error {
-	return lockgate.WithAcquire(GetHostLocker(), lockName, SetupLockerDefaultOptions(ctx, opts), func(_ bool) error {
+	locker, err := getHostLocker()
+	if err != nil {
+		return err
+	}
+
+	return lockgate.WithAcquire(locker, lockName, SetupLockerDefaultOptions(ctx, opts), func(_ bool) error {
 		return f()
 	})
 }
 
 func AcquireHostLock(ctx context.Context, lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error) {
-	return GetHostLocker().Acquire(lockName, SetupLockerDefaultOptions(ctx, opts))
+	locker, err := getHostLocker()
+	if err != nil {
+		return false, lockgate.LockHandle{}, err
+	}
+
+	return locker.Acquire(lockName, SetupLockerDefaultOptions(ctx, opts))
 }
 
 func ReleaseHostLock(lock lockgate.LockHandle) error {
 	return GetHostLocker().Release(lock)
 }
 
+func WithGlobalHostLock(ctx context.Context, lockName string, opts lockgate.AcquireOptions, f func() error) error {
+	locker, err := getGlobalHostLocker()
+	if err != nil {
+		return err
+	}
+
+	return lockgate.WithAcquire(locker, lockName, SetupLockerDefaultOptions(ctx, opts), func(_ bool) error {
+		return f()
+	})
+}
+
+func AcquireGlobalHostLock(ctx context.Context, lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error) {
+	locker, err := getGlobalHostLocker()
+	if err != nil {
+		return false, lockgate.LockHandle{}, err
+	}
+
+	return locker.Acquire(lockName, SetupLockerDefaultOptions(ctx, opts))
+}
+
+func ReleaseGlobalHostLock(lock lockgate.LockHandle) error {
+	return GetGlobalHostLocker().Release(lock)
+}
+
 func DefaultLockerOnWait(ctx context.Context) func(lockName string, doWait func() error) error {
 	return func(lockName string, doWait func() error) error {
 		logProcessMsg := fmt.Sprintf("Waiting for locked %q", lockName)
@@ -110,7 +224,7 @@ func DefaultLockerOnLostLease(lock lockgate.LockHandle) error {
 	panic(fmt.Sprintf("Locker has lost lease for locked %q uuid %s. Will crash current process immediately!", lock.LockName, lock.UUID))
 }
 
-func Init(tmpDirOption, homeDirOption string) error {
+func initDirs(tmpDirOption, homeDirOption, homeIsolationKeyOption string, readOnly bool) error {
 	if val, ok := os.LookupEnv("WERF_TMP_DIR"); ok {
 		tmpDir = val
 	} else if tmpDirOption != "" {
@@ -141,20 +255,72 @@ func Init(tmpDirOption, homeDirOption string) error {
 		homeDir = filepath.Join(userHomeDir, ".werf")
 	}
 
+	var homeIsolationKey string
+	if val, ok := os.LookupEnv("WERF_HOME_ISOLATION_KEY"); ok {
+		homeIsolationKey = val
+	} else {
+		homeIsolationKey = homeIsolationKeyOption
+	}
+
+	globalServiceDir = filepath.Join(homeDir, "service")
+
+	if homeIsolationKey != "" {
+		isolationDirName := slug.LimitedSlug(homeIsolationKey, slug.DefaultSlugMaxSize)
+		homeDir = filepath.Join(homeDir, "isolated", isolationDirName)
+		tmpDir = filepath.Join(tmpDir, fmt.Sprintf("werf-isolated-%s", isolationDirName))
+
+		if !readOnly {
+			for _, dir := range []string{homeDir, tmpDir} {
+				if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+					return fmt.Errorf("unable to create isolated werf dir %s: %s", dir, err)
+				}
+			}
+		}
+	}
+
 	// TODO: options + update purgeHomeWerfFiles
 
 	sharedContextDir = filepath.Join(homeDir, "shared_context")
 	localCacheDir = filepath.Join(homeDir, "local_cache")
 	serviceDir = filepath.Join(homeDir, "service")
 
+	return nil
+}
+
+func initHostLockers() error {
 	file_lock.LegacyHashFunction = true
 
-	if locker, err := file_locker.NewFileLocker(filepath.Join(serviceDir, "locks")); err != nil {
+	if locker, err := file_locker.NewFileLocker(GetHostLocksDir()); err != nil {
 		return fmt.Errorf("error creating werf host file locker: %s", err)
 	} else {
 		hostLocker = locker
 	}
 
+	// without the home isolation key the host locks are global
+	if GetGlobalHostLocksDir() == GetHostLocksDir() {
+		globalHostLocker = hostLocker
+	} else if locker, err := file_locker.NewFileLocker(GetGlobalHostLocksDir()); err != nil {
+		return fmt.Errorf("error creating werf global host file locker: %s", err)
+	} else {
+		globalHostLocker = locker
+	}
+
+	return nil
+}
+
+func Init(tmpDirOption, homeDirOption, homeIsolationKeyOption string) error {
+	if err := initDirs(tmpDirOption, homeDirOption, homeIsolationKeyOption, false); err != nil {
+		return err
+	}
+
+	hostLockerMutex.Lock()
+	readOnly = false
+	err := initHostLockers()
+	hostLockerMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
 	if err := SetWerfFirstRunAt(context.Background()); err != nil {
 		return fmt.Errorf("error setting werf first run at timestamp: %s", err)
 	}
@@ -165,3 +331,21 @@ func Init(tmpDirOption, homeDirOption string) error {
 
 	return nil
 }
+
+// InitReadOnly initializes werf for the informational commands, which only read the project and must work in minimal containers.
+// Unlike Init it does not write the run timestamps and does not create the host locker and the werf home dirs:
+// the locker is created on the first host lock, which is required only in rare cases (e.g. for the git worktree of a commit with submodules).
+func InitReadOnly(tmpDirOption, homeDirOption, homeIsolationKeyOption string) error {
+	if err := initDirs(tmpDirOption, homeDirOption, homeIsolationKeyOption, true); err != nil {
+		return err
+	}
+
+	hostLockerMutex.Lock()
+	defer hostLockerMutex.Unlock()
+
+	hostLocker = nil
+	globalHostLocker = nil
+	readOnly = true
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package werf
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/lockgate"
+)
+
+func TestInit_HomeIsolationKey(t *testing.T) {
+	dir := t.TempDir()
+	homeDir := filepath.Join(dir, "home")
+
+	if err := Init(filepath.Join(dir, "tmp"), homeDir, "project-job-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	isolatedHomeDir := filepath.Join(homeDir, "isolated", "project-job-1")
+	if GetHomeDir() != isolatedHomeDir {
+		t.Fatalf("expected the isolated home dir %q, got %q", isolatedHomeDir, GetHomeDir())
+	}
+	if expected := filepath.Join(isolatedHomeDir, "service", "locks"); GetHostLocksDir() != expected {
+		t.Fatalf("expected the isolated host locks dir %q, got %q", expected, GetHostLocksDir())
+	}
+	if expected := filepath.Join(homeDir, "service", "locks"); GetGlobalHostLocksDir() != expected {
+		t.Fatalf("expected the global host locks dir %q, got %q", expected, GetGlobalHostLocksDir())
+	}
+	if GetHostLocker() == GetGlobalHostLocker() {
+		t.Fatal("expected the separate lockers for the isolated and the global host locks")
+	}
+
+	countLockFiles := func(locksDir string) int {
+		files, err := ioutil.ReadDir(locksDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+
+	isolatedLocksCount, globalLocksCount := countLockFiles(GetHostLocksDir()), countLockFiles(GetGlobalHostLocksDir())
+	if err := WithGlobalHostLock(context.Background(), "global", lockgate.AcquireOptions{}, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countLockFiles(GetGlobalHostLocksDir()); count != globalLocksCount+1 {
+		t.Fatalf("expected the global lock file in the global host locks dir, got %d files instead of %d", count, globalLocksCount+1)
+	}
+	if count := countLockFiles(GetHostLocksDir()); count != isolatedLocksCount {
+		t.Fatalf("expected no global lock file in the isolated host locks dir, got %d files instead of %d", count, isolatedLocksCount)
+	}
+}
+
+func TestInit_WithoutHomeIsolationKey(t *testing.T) {
+	dir := t.TempDir()
+	homeDir := filepath.Join(dir, "home")
+
+	if err := Init(filepath.Join(dir, "tmp"), homeDir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := filepath.Join(homeDir, "service", "locks"); GetHostLocksDir() != expected || GetGlobalHostLocksDir() != expected {
+		t.Fatalf("expected the host locks dir %q, got %q and the global %q", expected, GetHostLocksDir(), GetGlobalHostLocksDir())
+	}
+	if GetHostLocker() != GetGlobalHostLocker() {
+		t.Fatal("expected the same locker for the host locks without the home isolation key")
+	}
+}
+
+func TestInitReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	homeDir := filepath.Join(dir, "home")
+	tmpDir := filepath.Join(dir, "tmp")
+
+	if err := InitReadOnly(tmpDir, homeDir, "project-job-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsReadOnly() {
+		t.Fatal("expected the read-only mode")
+	}
+	if expected := filepath.Join(homeDir, "isolated", "project-job-1"); GetHomeDir() != expected {
+		t.Fatalf("expected the isolated home dir %q, got %q", expected, GetHomeDir())
+	}
+
+	for _, path := range []string{homeDir, tmpDir} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %q not to be created by the read-only init, got %v", path, err)
+		}
+	}
+
+	if err := WithHostLock(context.Background(), "read-only", lockgate.AcquireOptions{}, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(GetHostLocksDir()); err != nil {
+		t.Fatalf("expected the host locks dir to be created on the first host lock: %s", err)
+	}
+	if _, err := os.Stat(getWerfFirstRunAtPath()); !os.IsNotExist(err) {
+		t.Fatalf("expected no run timestamps to be written by the read-only init, got %v", err)
+	}
+
+	if err := Init(tmpDir, homeDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if IsReadOnly() {
+		t.Fatal("expected Init to reset the read-only mode")
+	}
+}
@@ -1,6 +1,6 @@
 name "ansible"
 
-ANSIBLE_GIT_TAG = "v2.7.15"
+ANSIBLE_GIT_TAG = "v2.9.27"
 
 dependency "python"
 dependency "pip"